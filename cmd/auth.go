@@ -1,11 +1,9 @@
 package cmd
 
 import (
-	"context"
 	"fmt"
 	"os"
 	"text/tabwriter"
-	"time"
 
 	"freightliner/pkg/auth"
 	"freightliner/pkg/client/factory"
@@ -125,7 +123,7 @@ func runAuthList(cmd *cobra.Command, args []string) error {
 // runAuthTest executes the auth test command
 func runAuthTest(cmd *cobra.Command, args []string) error {
 	registry := args[0]
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := withCommandTimeout(cmd.Context())
 	defer cancel()
 
 	logger := log.NewBasicLogger(log.InfoLevel)
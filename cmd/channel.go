@@ -0,0 +1,219 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"freightliner/pkg/attestation"
+	"freightliner/pkg/channel"
+	"freightliner/pkg/client"
+	"freightliner/pkg/config"
+	"freightliner/pkg/helper/log"
+	"freightliner/pkg/interfaces"
+	"freightliner/pkg/report"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/spf13/cobra"
+)
+
+// newChannelCmd creates a new channel command
+func newChannelCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "channel",
+		Short: "Mirror release channels",
+		Long:  `Commands for mirroring release channels, fixed sets of images pinned by digest`,
+	}
+
+	cmd.AddCommand(newChannelMirrorCmd())
+
+	return cmd
+}
+
+// channelMirrorOptions holds the channel mirror command flags
+type channelMirrorOptions struct {
+	dryRun bool
+	format string
+}
+
+// newChannelMirrorCmd creates a new channel mirror command
+func newChannelMirrorCmd() *cobra.Command {
+	opts := &channelMirrorOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "mirror CHANNEL_FILE DESTINATION",
+		Short: "Mirror every image of a release channel byte for byte",
+		Long: `Mirrors exactly the images listed in a release channel file to DESTINATION,
+a registry with an optional path prefix. Each image keeps its source
+repository path and is pushed without changing a byte of its manifest, so
+the pinned digests resolve in the mirror. Every pushed manifest is read back
+and compared with the source; images already in the destination are only
+verified.
+
+When every image is mirrored and verified, a signed in-toto attestation of
+the channel is written to --attestation-output with --attestation-key. No
+attestation is written when any image fails.
+
+The channel file is JSON (.json) or YAML:
+
+  name: platform
+  version: "2024.10"
+  images:
+    - image: quay.io/acme/api@sha256:...
+      tag: "2024.10"    # optional destination tag
+    - image: quay.io/acme/worker:2024.10@sha256:...`,
+		Example: `  # Mirror a release and sign its completion attestation
+  freightliner channel mirror platform-2024.10.yaml registry.internal/releases \
+    --attestation-output platform-2024.10.att.json --attestation-key release.pem
+
+  # Check the sources and show what would be pushed
+  freightliner channel mirror platform-2024.10.yaml registry.internal/releases --dry-run`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runChannelMirror(cmd.Context(), args[0], args[1], opts)
+		},
+	}
+
+	cmd.Flags().BoolVar(&opts.dryRun, "dry-run", false, "Check the channel and show what would be mirrored without pushing")
+	cmd.Flags().StringVar(&opts.format, "format", "text", "Output format: text or json")
+
+	return cmd
+}
+
+// runChannelMirror mirrors the channel in file to destination
+func runChannelMirror(ctx context.Context, file, destination string, opts *channelMirrorOptions) error {
+	if opts.format != "text" && opts.format != "json" {
+		return fmt.Errorf("unsupported format %q, expected text or json", opts.format)
+	}
+
+	ch, err := channel.Load(file)
+	if err != nil {
+		return err
+	}
+
+	// Fail before pushing anything when the attestation could not be signed
+	if !opts.dryRun {
+		if cfg.Attestation.Output == "" || cfg.Attestation.KeyFile == "" {
+			return fmt.Errorf("channel mirror requires --attestation-output and --attestation-key for its completion attestation")
+		}
+		if _, err := attestation.LoadSigner(cfg.Attestation.KeyFile); err != nil {
+			return fmt.Errorf("failed to load attestation key: %w", err)
+		}
+	}
+
+	logger, ctx, cancel := setupCommand(ctx)
+	defer cancel()
+
+	logger.WithFields(map[string]interface{}{
+		"channel":     ch.Name,
+		"version":     ch.Version,
+		"digest":      ch.Digest,
+		"images":      len(ch.Images),
+		"destination": destination,
+		"dry_run":     opts.dryRun,
+	}).Info("Mirroring release channel")
+
+	runReport := report.New("channel mirror", file, destination)
+	runReport.DryRun = opts.dryRun
+	ledger := attestation.NewLedger()
+	if !opts.dryRun {
+		runReport.AttachLedger(ledger)
+		runReport.AttachChannel(&attestation.Channel{
+			Name:    ch.Name,
+			Version: ch.Version,
+			Digest:  ch.Digest,
+			Images:  len(ch.Images),
+		})
+	}
+
+	results, mirrorErr := channel.Mirror(ctx, ch, channel.MirrorOptions{
+		Destination:   destination,
+		RemoteOptions: channelRemoteOptions(logger),
+		Ledger:        ledger,
+		DryRun:        opts.dryRun,
+		Logger:        logger,
+	})
+
+	var mirrored, present int64
+	for _, result := range results {
+		runReport.AddPlanned(result.Source, result.Destination)
+		switch result.Status {
+		case channel.StatusFailed:
+			runReport.AddFailure(result.Source, result.Destination, errors.New(result.Error))
+		case channel.StatusPresent:
+			present++
+		case channel.StatusMirrored:
+			mirrored++
+		}
+	}
+	runReport.SetSummary("images_mirrored", mirrored)
+	runReport.SetSummary("images_present", present)
+	publishRunReport(ctx, logger, runReport, mirrorErr)
+
+	if err := writeChannelResults(os.Stdout, ch, results, opts.format); err != nil {
+		return err
+	}
+	return mirrorErr
+}
+
+// channelRemoteOptions returns registry options from the configured registry
+// clients, creating missing destination repositories unless
+// --create-missing-repos=false
+func channelRemoteOptions(logger log.Logger) channel.RemoteOptionsFunc {
+	factory := client.NewFactory(cfg, logger)
+	clients := make(map[string]interfaces.RegistryClient)
+
+	return func(ctx context.Context, ref name.Reference, push bool) ([]remote.Option, error) {
+		registry := ref.Context().RegistryStr()
+		registryClient, ok := clients[registry]
+		if !ok {
+			var err error
+			registryClient, err = factory.CreateClientForRegistry(ctx, registry)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create client for registry %s: %w", registry, err)
+			}
+			clients[registry] = registryClient
+		}
+
+		repoName := ref.Context().RepositoryStr()
+		repo, err := registryClient.GetRepository(ctx, repoName)
+		if err != nil && push && cfg.Replicate.CreateMissingRepos != config.CreateMissingReposFalse {
+			repo, err = interfaces.CreateRepository(ctx, registryClient, repoName, interfaces.RepositorySettings{})
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get repository %s: %w", ref.Context(), err)
+		}
+
+		remoteOpts, err := repo.GetRemoteOptions()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get remote options for %s: %w", ref.Context(), err)
+		}
+		return append(remoteOpts, remote.WithContext(ctx)), nil
+	}
+}
+
+// writeChannelResults prints the outcome of every channel image
+func writeChannelResults(out io.Writer, ch *channel.Channel, results []channel.Result, format string) error {
+	if format == "json" {
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(map[string]interface{}{
+			"channel": ch.Name,
+			"version": ch.Version,
+			"digest":  ch.Digest,
+			"images":  results,
+		})
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SOURCE\tDESTINATION\tSTATUS\tERROR")
+	for _, result := range results {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", result.Source, result.Destination, result.Status, result.Error)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"freightliner/pkg/attestation"
+	"freightliner/pkg/config"
+	"freightliner/pkg/helper/log"
+	"freightliner/pkg/report"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChannelAttestation(t *testing.T) {
+	dir := t.TempDir()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	keyFile := filepath.Join(dir, "release.pem")
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0o600))
+
+	originalCfg := cfg
+	cfg = config.NewDefaultConfig()
+	defer func() { cfg = originalCfg }()
+	cfg.Attestation.KeyFile = keyFile
+	logger := log.NewBasicLogger(log.ErrorLevel)
+
+	channel := &attestation.Channel{Name: "platform", Version: "2024.10", Digest: "sha256:abc", Images: 1}
+	newRun := func(output string) *report.Report {
+		cfg.Attestation.Output = filepath.Join(dir, output)
+		ledger := attestation.NewLedger()
+		ledger.Record(attestation.Transfer{
+			Source:            "quay.io/acme/api@sha256:aaa",
+			Destination:       "registry.internal/releases/acme/api@sha256:aaa",
+			SourceDigest:      "sha256:aaa",
+			DestinationDigest: "sha256:aaa",
+		})
+		r := report.New("channel mirror", "platform.yaml", "registry.internal/releases")
+		r.AttachLedger(ledger)
+		r.AttachChannel(channel)
+		return r
+	}
+
+	// A complete mirror gets a signed attestation of the channel
+	complete := newRun("complete.json")
+	complete.Finish(nil)
+	writeAttestation(logger, complete)
+
+	data, err := os.ReadFile(filepath.Join(dir, "complete.json"))
+	require.NoError(t, err)
+	var envelope attestation.Envelope
+	require.NoError(t, json.Unmarshal(data, &envelope))
+	statement, err := attestation.Verify(&envelope, key.Public())
+	require.NoError(t, err)
+	assert.Equal(t, channel, statement.Predicate.Channel)
+	assert.Len(t, statement.Subject, 1)
+
+	// An incomplete mirror gets none
+	incomplete := newRun("incomplete.json")
+	incomplete.Finish(errors.New("failed to mirror 1 of 1 images of channel platform"))
+	writeAttestation(logger, incomplete)
+	assert.NoFileExists(t, filepath.Join(dir, "incomplete.json"))
+}
@@ -1,8 +1,12 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"text/tabwriter"
+	"time"
 
 	"freightliner/pkg/service"
 
@@ -24,6 +28,7 @@ func newCheckpointCmd() *cobra.Command {
 	cmd.AddCommand(newCheckpointListCmd())
 	cmd.AddCommand(newCheckpointShowCmd())
 	cmd.AddCommand(newCheckpointDeleteCmd())
+	cmd.AddCommand(newCheckpointPruneCmd())
 	cmd.AddCommand(newCheckpointExportCmd())
 	cmd.AddCommand(newCheckpointImportCmd())
 
@@ -32,15 +37,20 @@ func newCheckpointCmd() *cobra.Command {
 
 // newCheckpointListCmd creates a new checkpoint list command
 func newCheckpointListCmd() *cobra.Command {
-	return &cobra.Command{
+	var format string
+
+	cmd := &cobra.Command{
 		Use:   "list",
 		Short: "List checkpoints",
-		Long:  `Lists all available replication checkpoints`,
+		Long:  `Lists all available replication checkpoints, newest first`,
+		Args:  cobra.NoArgs,
 		Run: func(cmd *cobra.Command, args []string) {
 			// Create logger and context
 			logger, ctx, cancel := setupCommand(cmd.Context())
 			defer cancel()
 
+			checkCheckpointFormat(format)
+
 			logger.WithFields(map[string]interface{}{
 				"dir": cfg.Checkpoint.Directory,
 			}).Info("Listing checkpoints")
@@ -56,45 +66,45 @@ func newCheckpointListCmd() *cobra.Command {
 				os.Exit(1)
 			}
 
+			if format == "json" {
+				writeCheckpointJSON(map[string]interface{}{"checkpoints": checkpoints, "count": len(checkpoints)})
+				return
+			}
+
 			// Print checkpoints
 			if len(checkpoints) == 0 {
 				fmt.Println("No checkpoints found")
 				return
 			}
 
-			fmt.Printf("Found %d checkpoints:\n", len(checkpoints))
-			fmt.Println("\nID                                   | Created               | Source -> Destination                | Repos | Status")
-			fmt.Println("--------------------------------------|----------------------|---------------------------------------|-------|-------")
-			for _, cp := range checkpoints {
-				fmt.Printf("%-36s | %-20s | %-37s | %5d | %s\n",
-					cp.ID,
-					cp.CreatedAt.Format("2006-01-02 15:04:05"),
-					cp.Source+" -> "+cp.Destination,
-					cp.TotalRepositories,
-					cp.Status)
-			}
+			fmt.Printf("Found %d checkpoints:\n\n", len(checkpoints))
+			writeCheckpointTable(os.Stdout, checkpoints)
 		},
 	}
+
+	cmd.Flags().StringVar(&format, "format", "table", "Output format: table or json")
+	return cmd
 }
 
 // newCheckpointShowCmd creates a new checkpoint show command
 func newCheckpointShowCmd() *cobra.Command {
-	return &cobra.Command{
-		Use:   "show",
+	var format string
+
+	cmd := &cobra.Command{
+		Use:   "show [ID]",
 		Short: "Show checkpoint details",
-		Long:  `Shows detailed information about a specific checkpoint`,
+		Long:  `Shows detailed information about a specific checkpoint, given as an argument or with --id`,
+		Args:  cobra.MaximumNArgs(1),
 		Run: func(cmd *cobra.Command, args []string) {
 			// Create logger and context
 			logger, ctx, cancel := setupCommand(cmd.Context())
 			defer cancel()
 
-			if cfg.Checkpoint.ID == "" {
-				fmt.Println("Error: checkpoint ID is required")
-				os.Exit(1)
-			}
+			checkCheckpointFormat(format)
+			ids := checkpointIDs(args)
 
 			logger.WithFields(map[string]interface{}{
-				"id":  cfg.Checkpoint.ID,
+				"id":  ids[0],
 				"dir": cfg.Checkpoint.Directory,
 			}).Info("Showing checkpoint")
 
@@ -102,80 +112,196 @@ func newCheckpointShowCmd() *cobra.Command {
 			checkpointSvc := service.NewCheckpointService(cfg, logger)
 
 			// Get checkpoint details
-			checkpoint, err := checkpointSvc.GetCheckpoint(ctx, cfg.Checkpoint.ID)
+			checkpoint, err := checkpointSvc.GetCheckpoint(ctx, ids[0])
 			if err != nil {
 				logger.Error("Failed to get checkpoint", err)
 				fmt.Printf("Error getting checkpoint: %s\n", err)
 				os.Exit(1)
 			}
 
+			if format == "json" {
+				writeCheckpointJSON(checkpoint)
+				return
+			}
+
 			// Print checkpoint details
 			fmt.Printf("Checkpoint ID: %s\n", checkpoint.ID)
 			fmt.Printf("Created At: %s\n", checkpoint.CreatedAt.Format("2006-01-02 15:04:05"))
+			fmt.Printf("Updated At: %s\n", checkpoint.UpdatedAt.Format("2006-01-02 15:04:05"))
+			if checkpoint.RunID != "" {
+				fmt.Printf("Run ID: %s\n", checkpoint.RunID)
+			}
 			fmt.Printf("Source: %s\n", checkpoint.Source)
 			fmt.Printf("Destination: %s\n", checkpoint.Destination)
 			fmt.Printf("Status: %s\n", checkpoint.Status)
 			fmt.Printf("Total Repositories: %d\n", checkpoint.TotalRepositories)
 			fmt.Printf("Completed Repositories: %d\n", checkpoint.CompletedRepositories)
 			fmt.Printf("Failed Repositories: %d\n", checkpoint.FailedRepositories)
-			fmt.Printf("Total Tags Copied: %d\n", checkpoint.TotalTagsCopied)
-			fmt.Printf("Total Tags Skipped: %d\n", checkpoint.TotalTagsSkipped)
-			fmt.Printf("Total Errors: %d\n", checkpoint.TotalErrors)
-			fmt.Printf("Total Bytes Transferred: %d\n", checkpoint.TotalBytesTransferred)
 
 			// Print repository details
 			if len(checkpoint.Repositories) > 0 {
 				fmt.Println("\nRepositories:")
-				fmt.Println("Name                                  | Status    | Tags Copied | Tags Skipped | Errors")
-				fmt.Println("--------------------------------------|-----------|-------------|--------------|-------")
+				w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+				fmt.Fprintln(w, "NAME\tSTATUS")
 				for _, repo := range checkpoint.Repositories {
-					fmt.Printf("%-36s | %-9s | %11d | %12d | %6d\n",
-						repo.Name,
-						repo.Status,
-						repo.TagsCopied,
-						repo.TagsSkipped,
-						repo.Errors)
+					fmt.Fprintf(w, "%s\t%s\n", repo.Name, repo.Status)
 				}
+				_ = w.Flush()
 			}
 		},
 	}
+
+	cmd.Flags().StringVar(&format, "format", "table", "Output format: table or json")
+	return cmd
 }
 
 // newCheckpointDeleteCmd creates a new checkpoint delete command
 func newCheckpointDeleteCmd() *cobra.Command {
 	return &cobra.Command{
-		Use:   "delete",
-		Short: "Delete a checkpoint",
-		Long:  `Deletes a specific checkpoint`,
+		Use:   "delete [ID...]",
+		Short: "Delete checkpoints",
+		Long:  `Deletes the checkpoints given as arguments, or the one given with --id`,
 		Run: func(cmd *cobra.Command, args []string) {
 			// Create logger and context
 			logger, ctx, cancel := setupCommand(cmd.Context())
 			defer cancel()
 
-			if cfg.Checkpoint.ID == "" {
-				fmt.Println("Error: checkpoint ID is required")
-				os.Exit(1)
+			// Create checkpoint service
+			checkpointSvc := service.NewCheckpointService(cfg, logger)
+
+			for _, id := range checkpointIDs(args) {
+				logger.WithFields(map[string]interface{}{
+					"id":  id,
+					"dir": cfg.Checkpoint.Directory,
+				}).Info("Deleting checkpoint")
+
+				// Delete checkpoint
+				if err := checkpointSvc.DeleteCheckpoint(ctx, id); err != nil {
+					logger.Error("Failed to delete checkpoint", err)
+					fmt.Printf("Error deleting checkpoint: %s\n", err)
+					os.Exit(1)
+				}
+
+				fmt.Printf("Checkpoint '%s' deleted successfully\n", id)
 			}
+		},
+	}
+}
 
-			logger.WithFields(map[string]interface{}{
-				"id":  cfg.Checkpoint.ID,
-				"dir": cfg.Checkpoint.Directory,
-			}).Info("Deleting checkpoint")
+// newCheckpointPruneCmd creates a new checkpoint prune command
+func newCheckpointPruneCmd() *cobra.Command {
+	var (
+		olderThan time.Duration
+		statuses  []string
+		dryRun    bool
+		format    string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "prune",
+		Short: "Delete stale checkpoints",
+		Long: `Deletes the checkpoints that have not been updated for --older-than, for
+example those of runs that finished or crashed long ago. --status limits
+pruning to checkpoints in the given states.`,
+		Example: `  # Delete checkpoints untouched for a week
+  freightliner checkpoint prune --older-than 168h
+
+  # Preview which finished checkpoints older than a day would go
+  freightliner checkpoint prune --older-than 24h --status completed --dry-run`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			// Create logger and context
+			logger, ctx, cancel := setupCommand(cmd.Context())
+			defer cancel()
+
+			checkCheckpointFormat(format)
 
 			// Create checkpoint service
 			checkpointSvc := service.NewCheckpointService(cfg, logger)
 
-			// Delete checkpoint
-			err := checkpointSvc.DeleteCheckpoint(ctx, cfg.Checkpoint.ID)
+			pruned, err := checkpointSvc.PruneCheckpoints(ctx, olderThan, statuses, dryRun)
 			if err != nil {
-				logger.Error("Failed to delete checkpoint", err)
-				fmt.Printf("Error deleting checkpoint: %s\n", err)
+				logger.Error("Failed to prune checkpoints", err)
+				fmt.Printf("Error pruning checkpoints: %s\n", err)
 				os.Exit(1)
 			}
 
-			fmt.Printf("Checkpoint '%s' deleted successfully\n", cfg.Checkpoint.ID)
+			if format == "json" {
+				writeCheckpointJSON(map[string]interface{}{"checkpoints": pruned, "count": len(pruned), "dry_run": dryRun})
+				return
+			}
+
+			if len(pruned) == 0 {
+				fmt.Println("No checkpoints to prune")
+				return
+			}
+			verb := "Deleted"
+			if dryRun {
+				verb = "Would delete"
+			}
+			fmt.Printf("%s %d checkpoints:\n\n", verb, len(pruned))
+			writeCheckpointTable(os.Stdout, pruned)
 		},
 	}
+
+	cmd.Flags().DurationVar(&olderThan, "older-than", 0, "Delete checkpoints not updated for this long, e.g. 168h")
+	cmd.Flags().StringSliceVar(&statuses, "status", nil, "Only delete checkpoints with this status: pending, in_progress, completed, failed, interrupted (repeatable)")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "List the checkpoints that would be deleted without deleting them")
+	cmd.Flags().StringVar(&format, "format", "table", "Output format: table or json")
+	if err := cmd.MarkFlagRequired("older-than"); err != nil {
+		panic(fmt.Sprintf("failed to mark flag as required: %v", err))
+	}
+	return cmd
+}
+
+// checkpointIDs returns the checkpoint IDs given as arguments, or the --id
+// flag, exiting when there are none
+func checkpointIDs(args []string) []string {
+	if len(args) > 0 {
+		return args
+	}
+	if cfg.Checkpoint.ID != "" {
+		return []string{cfg.Checkpoint.ID}
+	}
+	fmt.Println("Error: checkpoint ID is required")
+	os.Exit(1)
+	return nil
+}
+
+// checkCheckpointFormat exits unless format is a supported checkpoint output format
+func checkCheckpointFormat(format string) {
+	if format != "table" && format != "json" {
+		fmt.Printf("Error: unsupported format %q, expected table or json\n", format)
+		os.Exit(1)
+	}
+}
+
+// writeCheckpointJSON prints v as indented JSON
+func writeCheckpointJSON(v interface{}) {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		fmt.Printf("Error encoding JSON: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+// writeCheckpointTable writes one line per checkpoint
+func writeCheckpointTable(out io.Writer, checkpoints []service.CheckpointInfo) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tCREATED\tUPDATED\tSOURCE -> DESTINATION\tREPOS\tDONE\tFAILED\tSTATUS")
+	for _, cp := range checkpoints {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%d\t%d\t%s\n",
+			cp.ID,
+			cp.CreatedAt.Format("2006-01-02 15:04:05"),
+			cp.UpdatedAt.Format("2006-01-02 15:04:05"),
+			cp.Source+" -> "+cp.Destination,
+			cp.TotalRepositories,
+			cp.CompletedRepositories,
+			cp.FailedRepositories,
+			cp.Status)
+	}
+	_ = w.Flush()
 }
 
 // newCheckpointExportCmd creates a new checkpoint export command
@@ -0,0 +1,252 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// newClusterCmd creates a new cluster command
+func newClusterCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "cluster",
+		Short: "Manage nodes of a distributed cluster",
+		Long: `Commands for the admin endpoint of distributed cluster nodes. A node is
+addressed by its admin address, e.g. node-2.internal:7070.`,
+	}
+
+	cmd.AddCommand(newClusterStatusCmd())
+	cmd.AddCommand(newClusterDrainCmd())
+	cmd.AddCommand(newClusterResumeCmd())
+	cmd.AddCommand(newClusterRebalanceCmd())
+
+	return cmd
+}
+
+// newClusterStatusCmd creates a new cluster status command
+func newClusterStatusCmd() *cobra.Command {
+	var timeout time.Duration
+
+	cmd := &cobra.Command{
+		Use:   "status <node>...",
+		Short: "Show the mode and load of cluster nodes",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx, cancel := context.WithTimeout(cmd.Context(), timeout)
+			defer cancel()
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "NODE\tADDRESS\tMODE\tQUEUED\tIN FLIGHT\tLEADER\tERROR")
+			var failed int
+			for _, node := range args {
+				status, err := clusterRequest(ctx, http.MethodGet, node, "/cluster/status")
+				if err != nil {
+					failed++
+					fmt.Fprintf(w, "-\t%s\tunreachable\t-\t-\t-\t%s\n", node, err)
+					continue
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\t%t\t%s\n", status.Node.NodeID, node, status.Node.Mode,
+					status.Node.QueueDepth, status.Node.InFlight, status.IsLeader, status.Error)
+			}
+			w.Flush()
+
+			if failed > 0 {
+				return fmt.Errorf("%d of %d nodes could not be reached", failed, len(args))
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().DurationVar(&timeout, "timeout", 30*time.Second, "How long to wait for the nodes to answer")
+
+	return cmd
+}
+
+// newClusterDrainCmd creates a new cluster drain command
+func newClusterDrainCmd() *cobra.Command {
+	var wait time.Duration
+
+	cmd := &cobra.Command{
+		Use:   "drain <node>",
+		Short: "Put a node in maintenance mode before shutting it down",
+		Long: `Stops assigning new jobs to the node, hands its queued repository jobs
+to peers and waits for the jobs it is running to finish. Once the node reports
+"drained" it can be shut down safely. Use "cluster resume" to bring it back.`,
+		Example: `  # Drain a node and wait up to 30 minutes for its jobs to finish
+  freightliner cluster drain node-2.internal:7070 --wait 30m`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			node := args[0]
+			ctx, cancel := context.WithTimeout(cmd.Context(), wait+30*time.Second)
+			defer cancel()
+
+			status, err := clusterRequest(ctx, http.MethodPost, node, "/cluster/drain")
+			if err != nil {
+				return err
+			}
+
+			// Poll rather than hold one request open for the whole wait
+			deadline := time.Now().Add(wait)
+			for status.Node.Mode != "drained" && status.Error == "" && time.Now().Before(deadline) {
+				fmt.Printf("Node %s draining: %d queued, %d in flight\n", status.Node.NodeID, status.Node.QueueDepth, status.Node.InFlight)
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-time.After(5 * time.Second):
+				}
+				if status, err = clusterRequest(ctx, http.MethodGet, node, "/cluster/status"); err != nil {
+					return err
+				}
+			}
+
+			switch {
+			case status.Error != "":
+				return fmt.Errorf("failed to drain node %s: %s", status.Node.NodeID, status.Error)
+			case status.Node.Mode == "drained":
+				fmt.Printf("Node %s drained, safe to shut down\n", status.Node.NodeID)
+			case wait > 0:
+				return fmt.Errorf("node %s still has %d jobs in flight after %s", status.Node.NodeID, status.Node.InFlight, wait)
+			default:
+				fmt.Printf("Node %s is draining; check progress with \"freightliner cluster status %s\"\n", status.Node.NodeID, node)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().DurationVar(&wait, "wait", 0, "How long to wait for the node to finish draining (0 = don't wait)")
+
+	return cmd
+}
+
+// newClusterResumeCmd creates a new cluster resume command
+func newClusterResumeCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "resume <node>",
+		Short: "Take a node out of maintenance mode",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx, cancel := context.WithTimeout(cmd.Context(), 30*time.Second)
+			defer cancel()
+
+			status, err := clusterRequest(ctx, http.MethodPost, args[0], "/cluster/resume")
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Node %s is %s\n", status.Node.NodeID, status.Node.Mode)
+			return nil
+		},
+	}
+}
+
+// newClusterRebalanceCmd creates a new cluster rebalance command
+func newClusterRebalanceCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "rebalance <node>",
+		Short: "Spread a node's queued jobs over its peers by capacity",
+		Long: `Moves queued repository jobs from the node to peers until each reachable
+node holds a share of the queued jobs proportional to its queue capacity. Nodes
+also rebalance on their own when a peer joins.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx, cancel := context.WithTimeout(cmd.Context(), 2*time.Minute)
+			defer cancel()
+
+			var result clusterRebalanceResult
+			if err := clusterCall(ctx, http.MethodPost, args[0], "/cluster/rebalance", &result); err != nil {
+				return err
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "NODE\tQUEUED\tCAPACITY\tRECEIVED\tERROR")
+			for _, load := range result.Snapshot.Nodes {
+				fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%s\n", load.NodeID, load.QueueDepth, load.Capacity,
+					result.Moved[load.NodeID], load.Error)
+			}
+			w.Flush()
+
+			fmt.Printf("Moved %d queued jobs from node %s\n", result.Total, result.Snapshot.NodeID)
+			return nil
+		},
+	}
+}
+
+// clusterRebalanceResult is a node's reply to a rebalance request; the
+// snapshot shows the load before jobs were moved
+type clusterRebalanceResult struct {
+	Moved    map[string]int `json:"moved"`
+	Total    int            `json:"total"`
+	Snapshot struct {
+		NodeID string `json:"node_id"`
+		Nodes  []struct {
+			NodeID     string `json:"node_id"`
+			QueueDepth int    `json:"queue_depth"`
+			Capacity   int    `json:"capacity"`
+			Error      string `json:"error"`
+		} `json:"nodes"`
+	} `json:"snapshot"`
+}
+
+// clusterNodeStatus is a node's reply from its admin endpoint
+type clusterNodeStatus struct {
+	Node struct {
+		NodeID     string `json:"node_id"`
+		Mode       string `json:"mode"`
+		QueueDepth int64  `json:"queue_depth"`
+		InFlight   int64  `json:"in_flight"`
+	} `json:"node"`
+	IsLeader bool   `json:"is_leader"`
+	Error    string `json:"error"`
+}
+
+// clusterRequest calls an admin endpoint of node and decodes its status
+func clusterRequest(ctx context.Context, method, node, path string) (*clusterNodeStatus, error) {
+	var status clusterNodeStatus
+	if err := clusterCall(ctx, method, node, path, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// clusterCall calls an admin endpoint of node and decodes its reply into out
+func clusterCall(ctx context.Context, method, node, path string, out interface{}) error {
+	base := node
+	if !strings.Contains(base, "://") {
+		base = "http://" + base
+	}
+	if _, err := url.Parse(base); err != nil {
+		return fmt.Errorf("invalid node address %q: %w", node, err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(base, "/")+path, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach node %s: %w", node, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("failed to read response from node %s: %w", node, err)
+	}
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusAccepted {
+		return fmt.Errorf("node %s returned %s: %s", node, resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("failed to decode reply from node %s: %w", node, err)
+	}
+	return nil
+}
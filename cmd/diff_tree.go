@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"freightliner/pkg/service"
+	"freightliner/pkg/tree"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	diffTreeFormat        string
+	diffTreeShowUnchanged bool
+)
+
+// newDiffTreeCmd creates the diff-tree command
+func newDiffTreeCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "diff-tree [source] [destination]",
+		Short: "Show how a destination tree differs from its source",
+		Long: `Compares every repository under the source prefix with the matching
+destination repository and lists the tags that replicate-tree would add or
+change, and those that only exist at the destination. Entries are written as
+soon as they are known, followed by per-repository counts.`,
+		Example: `  # Preview the changes a tree replication would make
+  freightliner diff-tree ecr/my-company gcr.io/my-project
+
+  # Stream JSON lines for a large tree with 16 repositories in parallel
+  freightliner diff-tree --format json --workers 16 ecr/prod gcr.io/prod-backup`,
+		Args: cobra.ExactArgs(2),
+		RunE: runDiffTree,
+	}
+
+	cmd.Flags().StringVar(&diffTreeFormat, "format", "text", "Output format: text or json (one JSON object per line)")
+	cmd.Flags().BoolVar(&diffTreeShowUnchanged, "show-unchanged", false, "Also list tags that are the same on both sides")
+	cmd.Flags().IntVar(&cfg.TreeReplicate.Workers, "workers", cfg.TreeReplicate.Workers, "Number of repositories diffed concurrently (0 = auto-detect)")
+	cmd.Flags().IntVar(&cfg.TreeReplicate.TagWorkers, "tag-workers", cfg.TreeReplicate.TagWorkers, "Number of digest lookups in flight per repository (0 = auto-detect)")
+	cmd.Flags().StringSliceVar(&cfg.TreeReplicate.ExcludeRepos, "exclude-repo", cfg.TreeReplicate.ExcludeRepos, "Repository patterns to exclude (e.g. 'helper-*')")
+	cmd.Flags().StringSliceVar(&cfg.TreeReplicate.ExcludeTags, "exclude-tag", cfg.TreeReplicate.ExcludeTags, "Tag patterns to exclude (e.g. 'dev-*')")
+	cmd.Flags().StringSliceVar(&cfg.TreeReplicate.IncludeTags, "include-tag", cfg.TreeReplicate.IncludeTags, "Tag patterns to include (e.g. 'v*')")
+	cfg.AddNameMappingFlags(cmd)
+
+	return cmd
+}
+
+// runDiffTree executes the diff-tree command
+func runDiffTree(cmd *cobra.Command, args []string) error {
+	if diffTreeFormat != "text" && diffTreeFormat != "json" {
+		return fmt.Errorf("unsupported format %q, expected text or json", diffTreeFormat)
+	}
+
+	logger, ctx, cancel := setupCommand(cmd.Context())
+	defer cancel()
+
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+
+	encoder := json.NewEncoder(out)
+	emit := func(entry tree.DiffEntry) error {
+		if diffTreeFormat == "json" {
+			return encoder.Encode(entry)
+		}
+		_, err := fmt.Fprintf(out, "%-9s %s:%s\n", entry.Status, entry.DestRepository, entry.Tag)
+		return err
+	}
+
+	treeSvc := service.NewTreeReplicationService(cfg, logger)
+	summary, err := treeSvc.DiffTree(ctx, args[0], args[1], diffTreeShowUnchanged, emit)
+	if err != nil {
+		return err
+	}
+
+	if diffTreeFormat == "json" {
+		if err := encoder.Encode(map[string]interface{}{"summary": summary}); err != nil {
+			return err
+		}
+	} else {
+		writeDiffSummary(out, summary)
+	}
+
+	if summary.Failed > 0 {
+		return fmt.Errorf("%d repositories could not be diffed", summary.Failed)
+	}
+	return nil
+}
+
+// writeDiffSummary prints the counts of every repository with differences
+// or errors, then the totals
+func writeDiffSummary(out *bufio.Writer, summary *tree.DiffSummary) {
+	fmt.Fprintln(out)
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "REPOSITORY\tADDED\tCHANGED\tMISSING\tUNCHANGED\tERROR")
+	for _, repo := range summary.Repositories {
+		if repo.Added+repo.Changed+repo.Missing == 0 && repo.Error == "" {
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%s\n", repo.DestRepository, repo.Added, repo.Changed, repo.Missing, repo.Unchanged, repo.Error)
+	}
+	w.Flush()
+
+	fmt.Fprintf(out, "\nRepositories: %d (%d failed)\n", len(summary.Repositories), summary.Failed)
+	fmt.Fprintf(out, "Tags added: %d, changed: %d, missing: %d, unchanged: %d\n", summary.Added, summary.Changed, summary.Missing, summary.Unchanged)
+}
@@ -0,0 +1,145 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"freightliner/pkg/client"
+	"freightliner/pkg/client/ecr"
+	"freightliner/pkg/sync"
+
+	"github.com/spf13/cobra"
+)
+
+// newECRCmd creates the ecr command group
+func newECRCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "ecr",
+		Short: "AWS ECR specific operations",
+		Long:  `Operations that only apply to AWS Elastic Container Registry`,
+	}
+
+	cmd.AddCommand(newECRReplicationConfigCmd())
+
+	return cmd
+}
+
+// newECRReplicationConfigCmd creates the ecr replication-config command
+func newECRReplicationConfigCmd() *cobra.Command {
+	var (
+		syncFile   string
+		outputFile string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "replication-config --from FILE",
+		Short: "Generate native ECR replication configuration from sync rules",
+		Long: `Generates an ECR registry replication configuration from a sync configuration
+so that ECR-to-ECR copies can be offloaded to the native replication feature.
+
+Native replication always copies every tag and keeps repository names, so images
+that rename their destination or filter tags are reported and left out. Filters
+use ECR prefix matching: "team/app" also replicates "team/app-legacy".
+
+Apply the result with:
+  aws ecr put-replication-configuration --replication-configuration file://replication.json`,
+		Example: `  # Print replication configuration for a sync file
+  freightliner ecr replication-config --from sync.yaml
+
+  # Write it to a file
+  freightliner ecr replication-config --from sync.yaml --output replication.json`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			syncConfig, err := sync.LoadConfig(syncFile)
+			if err != nil {
+				return fmt.Errorf("failed to load sync configuration: %w", err)
+			}
+			if len(syncConfig.Generators) > 0 {
+				logger, ctx, cancel := setupCommand(context.Background())
+				defer cancel()
+				if err := syncConfig.ExpandGenerators(ctx, sync.NewRepositoryLister(client.NewFactory(syncFactoryConfig(), logger))); err != nil {
+					return fmt.Errorf("failed to expand generators: %w", err)
+				}
+			}
+
+			replicationConfig, skipped, err := buildECRReplicationConfig(syncConfig)
+			if err != nil {
+				return err
+			}
+
+			for _, reason := range skipped {
+				fmt.Fprintf(os.Stderr, "Skipped %s\n", reason)
+			}
+
+			data, err := json.MarshalIndent(replicationConfig, "", "  ")
+			if err != nil {
+				return fmt.Errorf("failed to encode replication configuration: %w", err)
+			}
+
+			if outputFile == "" {
+				fmt.Println(string(data))
+				return nil
+			}
+
+			if err := os.WriteFile(outputFile, append(data, '\n'), 0600); err != nil {
+				return fmt.Errorf("failed to write replication configuration: %w", err)
+			}
+			fmt.Printf("Replication configuration written to %s\n", outputFile)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&syncFile, "from", "", "Path to sync configuration file (required)")
+	cmd.Flags().StringVarP(&outputFile, "output", "o", "", "Write configuration to file instead of stdout")
+	_ = cmd.MarkFlagRequired("from")
+
+	return cmd
+}
+
+// buildECRReplicationConfig converts ECR-to-ECR sync rules into a native replication
+// configuration, returning a description of every image that cannot be expressed
+func buildECRReplicationConfig(syncConfig *sync.Config) (*ecr.ReplicationConfiguration, []string, error) {
+	if syncConfig.Source.Type != "ecr" || syncConfig.Destination.Type != "ecr" {
+		return nil, nil, fmt.Errorf("native replication requires ECR source and destination, got %s and %s",
+			syncConfig.Source.Type, syncConfig.Destination.Type)
+	}
+
+	dest := ecr.ReplicationDestination{
+		Region:     syncConfig.Destination.Region,
+		RegistryID: syncConfig.Destination.Account,
+	}
+	if account, region, ok := ecr.ParseRegistryHost(syncConfig.Destination.Registry); ok {
+		if dest.Region == "" {
+			dest.Region = region
+		}
+		if dest.RegistryID == "" {
+			dest.RegistryID = account
+		}
+	}
+	if dest.Region == "" || dest.RegistryID == "" {
+		return nil, nil, fmt.Errorf("destination region and account are required for native replication")
+	}
+
+	var repositories, skipped []string
+	for _, img := range syncConfig.Images {
+		switch {
+		case img.DestinationRepository != "" && img.DestinationRepository != img.Repository:
+			skipped = append(skipped, fmt.Sprintf("%s: native replication cannot rename to %s", img.Repository, img.DestinationRepository))
+		case !img.AllTags:
+			skipped = append(skipped, fmt.Sprintf("%s: native replication copies all tags, tag filters are not supported", img.Repository))
+		case img.DestinationPrefix != "" || img.DestinationSuffix != "":
+			skipped = append(skipped, fmt.Sprintf("%s: native replication cannot rewrite tags", img.Repository))
+		default:
+			repositories = append(repositories, img.Repository)
+		}
+	}
+
+	replicationConfig, err := ecr.BuildReplicationConfiguration(repositories, dest)
+	if err != nil {
+		return nil, skipped, err
+	}
+
+	return replicationConfig, skipped, nil
+}
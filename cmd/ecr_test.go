@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"testing"
+
+	"freightliner/pkg/sync"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildECRReplicationConfig(t *testing.T) {
+	syncConfig := &sync.Config{
+		Source:      sync.RegistryConfig{Registry: "123456789012.dkr.ecr.us-east-1.amazonaws.com", Type: "ecr"},
+		Destination: sync.RegistryConfig{Registry: "123456789012.dkr.ecr.eu-west-1.amazonaws.com", Type: "ecr"},
+		Images: []sync.ImageSync{
+			{Repository: "team/app", AllTags: true},
+			{Repository: "team/api", Tags: []string{"v1"}},
+			{Repository: "team/web", AllTags: true, DestinationRepository: "mirror/web"},
+		},
+	}
+
+	replicationConfig, skipped, err := buildECRReplicationConfig(syncConfig)
+	require.NoError(t, err)
+	assert.Len(t, skipped, 2)
+	require.Len(t, replicationConfig.Rules, 1)
+	assert.Equal(t, "eu-west-1", replicationConfig.Rules[0].Destinations[0].Region)
+	assert.Equal(t, "123456789012", replicationConfig.Rules[0].Destinations[0].RegistryID)
+	assert.True(t, replicationConfig.Covers("team/app", "eu-west-1", "123456789012"))
+	assert.False(t, replicationConfig.Covers("team/api", "eu-west-1", "123456789012"))
+}
+
+func TestBuildECRReplicationConfigRequiresECR(t *testing.T) {
+	syncConfig := &sync.Config{
+		Source:      sync.RegistryConfig{Registry: "docker.io", Type: "docker"},
+		Destination: sync.RegistryConfig{Registry: "123456789012.dkr.ecr.eu-west-1.amazonaws.com", Type: "ecr"},
+		Images:      []sync.ImageSync{{Repository: "library/nginx", AllTags: true}},
+	}
+
+	_, _, err := buildECRReplicationConfig(syncConfig)
+	assert.Error(t, err)
+}
@@ -0,0 +1,133 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"freightliner/pkg/k8sjob"
+
+	"github.com/spf13/cobra"
+)
+
+// newGenerateCmd creates the generate command group
+func newGenerateCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "generate",
+		Short: "Generate deployment manifests",
+		Long:  `Generates manifests that run Freightliner somewhere other than this machine`,
+	}
+
+	cmd.AddCommand(newGenerateK8sJobCmd())
+
+	return cmd
+}
+
+// newGenerateK8sJobCmd creates the generate k8s-job command
+func newGenerateK8sJobCmd() *cobra.Command {
+	var (
+		opts         k8sjob.Options
+		files        []string
+		secretEnv    []string
+		dockerConfig string
+		outputFile   string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "k8s-job [flags] -- COMMAND [ARGS...]",
+		Short: "Generate a Kubernetes Job running one replication",
+		Long: `Generates a Job, with the ConfigMap, Secret and PersistentVolumeClaim it
+needs, that runs the given freightliner command on a cluster.
+
+Checkpoints are kept on a volume claim, created unless --checkpoint-pvc names
+an existing one, or restored from and uploaded to --checkpoint-s3. A retried
+pod continues the run: replicate-tree resumes its latest checkpoint and sync
+skips the tags it already copied.
+
+Files given with --file are put in the ConfigMap, and arguments naming them
+are rewritten to their path in the container. Secrets are written in plain
+text, so keep the output out of version control.`,
+		Example: `  # Replicate a tree with checkpoints on a new 5Gi volume claim
+  freightliner generate k8s-job --name prod-migration --checkpoint-size 5Gi \
+    --secret-env AWS_ACCESS_KEY_ID --secret-env AWS_SECRET_ACCESS_KEY \
+    -- replicate-tree ecr/prod gcr/prod-backup --workers 16 | kubectl apply -f -
+
+  # Run a sync file, keeping progress in S3
+  freightliner generate k8s-job --name mirror --file sync.yaml \
+    --checkpoint-s3 s3://migrations/mirror --service-account freightliner \
+    -- sync --config sync.yaml`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts.Args = args
+
+			if len(files) > 0 {
+				opts.Files = make(map[string][]byte, len(files))
+				for _, file := range files {
+					data, err := os.ReadFile(file)
+					if err != nil {
+						return fmt.Errorf("failed to read %s: %w", file, err)
+					}
+					opts.Files[file] = data
+				}
+			}
+
+			if len(secretEnv) > 0 {
+				opts.SecretEnv = make(map[string]string, len(secretEnv))
+				for _, name := range secretEnv {
+					value, ok := os.LookupEnv(name)
+					if !ok {
+						return fmt.Errorf("environment variable %s is not set", name)
+					}
+					opts.SecretEnv[name] = value
+				}
+			}
+
+			if dockerConfig != "" {
+				data, err := os.ReadFile(dockerConfig)
+				if err != nil {
+					return fmt.Errorf("failed to read Docker config: %w", err)
+				}
+				opts.DockerConfig = data
+			}
+
+			if opts.Image == "" && version != "dev" {
+				opts.Image = "ghcr.io/hemzaz/freightliner:" + version
+			}
+
+			manifests, err := k8sjob.Render(opts)
+			if err != nil {
+				return err
+			}
+
+			if outputFile == "" {
+				fmt.Print(string(manifests))
+				return nil
+			}
+			if err := os.WriteFile(outputFile, manifests, 0600); err != nil {
+				return fmt.Errorf("failed to write manifests: %w", err)
+			}
+			fmt.Printf("Manifests written to %s\n", outputFile)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.Name, "name", "freightliner-migration", "Name of the Job and the objects created with it")
+	cmd.Flags().StringVar(&opts.Namespace, "namespace", "", "Namespace of the generated objects")
+	cmd.Flags().StringVar(&opts.Image, "image", "", "Freightliner image (default: this version's release image)")
+	cmd.Flags().StringArrayVar(&files, "file", nil, "Local file to put in the ConfigMap, e.g. a sync config (repeatable)")
+	cmd.Flags().StringArrayVar(&secretEnv, "secret-env", nil, "Environment variable to copy from this shell into the Secret (repeatable)")
+	cmd.Flags().StringVar(&dockerConfig, "docker-config", "", "Docker config.json with registry credentials to put in the Secret")
+	cmd.Flags().StringVar(&opts.CheckpointPVC, "checkpoint-pvc", "", "Existing PersistentVolumeClaim to keep checkpoints on")
+	cmd.Flags().StringVar(&opts.CheckpointSize, "checkpoint-size", k8sjob.DefaultCheckpointSize, "Size of the checkpoint volume claim created without --checkpoint-pvc")
+	cmd.Flags().StringVar(&opts.CheckpointS3, "checkpoint-s3", "", "Keep checkpoints in S3 instead of a volume (s3://bucket/prefix)")
+	cmd.Flags().StringVar(&opts.S3Region, "checkpoint-s3-region", "", "AWS region of the checkpoint bucket")
+	cmd.Flags().StringVar(&opts.SyncImage, "checkpoint-s3-image", k8sjob.DefaultSyncImage, "AWS CLI image copying checkpoints to and from S3")
+	cmd.Flags().StringVar(&opts.CPU, "cpu", "", "CPU request of the Freightliner container, e.g. 2")
+	cmd.Flags().StringVar(&opts.Memory, "memory", "", "Memory request and limit of the Freightliner container, e.g. 4Gi")
+	cmd.Flags().IntVar(&opts.BackoffLimit, "backoff-limit", 3, "Retries of a failed pod before the Job fails")
+	cmd.Flags().DurationVar(&opts.ActiveDeadline, "active-deadline", 0, "Stop the Job after this long (0 = no limit)")
+	cmd.Flags().DurationVar(&opts.TTLAfterFinished, "ttl-after-finished", 0, "Delete the finished Job after this long (0 = keep it)")
+	cmd.Flags().StringVar(&opts.ServiceAccount, "service-account", "", "Service account of the Job's pods, e.g. one with cloud workload identity")
+	cmd.Flags().StringVarP(&outputFile, "output", "o", "", "Write manifests to file instead of stdout")
+
+	return cmd
+}
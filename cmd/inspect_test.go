@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"freightliner/pkg/helper/log"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitInspectSource(t *testing.T) {
+	const dgst = "sha256:b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9"
+	tests := []struct {
+		source    string
+		registry  string
+		repo      string
+		reference string
+	}{
+		{source: "nginx", registry: "index.docker.io", repo: "library/nginx", reference: "latest"},
+		{source: "bitnami/redis:7", registry: "index.docker.io", repo: "bitnami/redis", reference: "7"},
+		{source: "ecr/team/app:v1.4.0", registry: "ecr", repo: "team/app", reference: "v1.4.0"},
+		{source: "gcr.io/proj/app@" + dgst, registry: "gcr.io", repo: "proj/app", reference: dgst},
+		{source: "localhost:5000/app", registry: "localhost:5000", repo: "app", reference: "latest"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			registry, repo, reference, err := splitInspectSource(tt.source)
+			require.NoError(t, err)
+			assert.Equal(t, tt.registry, registry)
+			assert.Equal(t, tt.repo, repo)
+			assert.Equal(t, tt.reference, reference)
+		})
+	}
+}
+
+func TestManifestTypeName(t *testing.T) {
+	assert.Equal(t, "OCI image index", manifestTypeName(types.OCIImageIndex))
+	assert.Equal(t, "Docker manifest list", manifestTypeName(types.DockerManifestList))
+	assert.Equal(t, "Docker image manifest v2", manifestTypeName(types.DockerManifestSchema2))
+	assert.Equal(t, "application/x-custom", manifestTypeName("application/x-custom"))
+}
+
+func TestInspectReferrers_CosignSignature(t *testing.T) {
+	server := httptest.NewServer(registry.New())
+	defer server.Close()
+
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	repo, err := name.NewRepository(u.Host + "/mirror/app")
+	require.NoError(t, err)
+
+	img, err := random.Image(256, 1)
+	require.NoError(t, err)
+	require.NoError(t, remote.Write(repo.Tag("v1"), img))
+
+	digest, err := img.Digest()
+	require.NoError(t, err)
+
+	logger := log.NewBasicLogger(log.ErrorLevel)
+
+	unsigned := &ImageInspectResult{}
+	inspectReferrers(logger, repo, digest, nil, unsigned)
+	assert.False(t, unsigned.Signed)
+
+	sig, err := random.Image(64, 1)
+	require.NoError(t, err)
+	require.NoError(t, remote.Write(repo.Tag(digest.Algorithm+"-"+digest.Hex+".sig"), sig))
+
+	signed := &ImageInspectResult{}
+	inspectReferrers(logger, repo, digest, nil, signed)
+	assert.True(t, signed.Signed)
+	require.NotEmpty(t, signed.Referrers)
+}
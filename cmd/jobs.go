@@ -0,0 +1,130 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// newJobsCmd creates a new jobs command
+func newJobsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "jobs",
+		Short: "Manage jobs on a running server",
+		Long:  `Commands for managing replication jobs submitted to a freightliner server`,
+	}
+
+	cmd.AddCommand(newJobsCancelCmd())
+
+	return cmd
+}
+
+// jobsCancelOptions holds the jobs cancel command flags
+type jobsCancelOptions struct {
+	server  string
+	apiKey  string
+	timeout time.Duration
+}
+
+// newJobsCancelCmd creates a new jobs cancel command
+func newJobsCancelCmd() *cobra.Command {
+	opts := &jobsCancelOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "cancel <job-id>",
+		Short: "Cancel a running job",
+		Long: `Cancels a pending or running job on a freightliner server. The job's
+workers are drained and tree replications finalize their checkpoint, so the
+job can be resumed later.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx, cancel := context.WithTimeout(cmd.Context(), opts.timeout)
+			defer cancel()
+
+			serverURL := opts.server
+			if serverURL == "" {
+				serverURL = cfg.Server.BaseURL()
+			}
+			apiKey := opts.apiKey
+			if apiKey == "" {
+				apiKey = cfg.Server.APIKey
+			}
+			if apiKey == "" {
+				apiKey = os.Getenv("FREIGHTLINER_API_KEY")
+			}
+
+			result, err := cancelServerJob(ctx, serverURL, apiKey, args[0])
+			if err != nil {
+				return err
+			}
+
+			if result.Drained {
+				fmt.Printf("Job %s canceled\n", result.JobID)
+			} else {
+				fmt.Printf("Job %s canceled; workers are still draining\n", result.JobID)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.server, "server", "", "Server URL (defaults to the configured server address)")
+	cmd.Flags().StringVar(&opts.apiKey, "api-key", "", "API key or tenant token (defaults to FREIGHTLINER_API_KEY)")
+	cmd.Flags().DurationVar(&opts.timeout, "timeout", 2*time.Minute, "How long to wait for the job to drain")
+
+	return cmd
+}
+
+// cancelJobResponse is the server's reply to a job cancellation
+type cancelJobResponse struct {
+	JobID   string `json:"job_id"`
+	Status  string `json:"status"`
+	Drained bool   `json:"drained"`
+	Message string `json:"message"`
+}
+
+// cancelServerJob asks the server at serverURL to cancel a job
+func cancelServerJob(ctx context.Context, serverURL, apiKey, jobID string) (*cancelJobResponse, error) {
+	endpoint := strings.TrimSuffix(serverURL, "/") + "/api/v1/jobs/" + url.PathEscape(jobID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cancel request: %w", err)
+	}
+	if apiKey != "" {
+		req.Header.Set("X-API-Key", apiKey)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach server %s: %w", serverURL, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cancel response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusAccepted {
+		var errResp struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(body, &errResp) == nil && errResp.Error != "" {
+			return nil, fmt.Errorf("failed to cancel job %s: %s", jobID, errResp.Error)
+		}
+		return nil, fmt.Errorf("failed to cancel job %s: server returned %s", jobID, resp.Status)
+	}
+
+	var result cancelJobResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("failed to decode cancel response: %w", err)
+	}
+	return &result, nil
+}
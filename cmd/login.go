@@ -2,12 +2,10 @@ package cmd
 
 import (
 	"bufio"
-	"context"
 	"fmt"
 	"os"
 	"strings"
 	"syscall"
-	"time"
 
 	"freightliner/pkg/auth"
 	"freightliner/pkg/client/factory"
@@ -23,8 +21,11 @@ var loginCmd = &cobra.Command{
 	Short: "Log in to a container registry",
 	Long: `Authenticate with a container registry and store credentials securely.
 
-Credentials are stored in ~/.docker/config.json by default, compatible with Docker and other tools.
-Supports credential helpers (keychain, pass, secretservice) for secure storage.
+Credentials are stored in the OS keychain (macOS Keychain, Windows Credential
+Manager or Secret Service) when its docker-credential helper is installed, and
+in ~/.docker/config.json otherwise. Either way Docker and other tools can use
+them, and freightliner uses them for registries that have no credentials in
+its configuration.
 
 Examples:
   # Login to Docker Hub
@@ -36,6 +37,9 @@ Examples:
   # Login with username (will prompt for password)
   freightliner login --username myuser registry.io
 
+  # Force storage in the OS keychain
+  freightliner login --credential-store keychain registry.io
+
   # Login with environment variables
   export REGISTRY_USERNAME=myuser
   export REGISTRY_PASSWORD=mypass
@@ -46,9 +50,10 @@ Examples:
 }
 
 var (
-	loginUsername string
-	loginPassword string
-	loginInsecure bool
+	loginUsername        string
+	loginPassword        string
+	loginInsecure        bool
+	loginCredentialStore string
 )
 
 func init() {
@@ -57,11 +62,12 @@ func init() {
 	loginCmd.Flags().StringVarP(&loginUsername, "username", "u", "", "Username for authentication")
 	loginCmd.Flags().StringVarP(&loginPassword, "password", "p", "", "Password for authentication (insecure, use stdin or prompt)")
 	loginCmd.Flags().BoolVar(&loginInsecure, "insecure", false, "Allow insecure connections (skip TLS verification)")
+	loginCmd.Flags().StringVar(&loginCredentialStore, "credential-store", "auto", "Where to store credentials (auto, keychain, file)")
 }
 
 func runLogin(cmd *cobra.Command, args []string) error {
 	registry := args[0]
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := withCommandTimeout(cmd.Context())
 	defer cancel()
 
 	logger := log.NewBasicLogger(log.InfoLevel)
@@ -141,7 +147,7 @@ func runLogin(cmd *cobra.Command, args []string) error {
 
 	// Store credentials
 	store := auth.NewCredentialStore()
-	err = store.Store(registry, username, password)
+	location, err := storeLoginCredentials(store, loginCredentialStore, registry, username, password)
 	if err != nil {
 		return fmt.Errorf("failed to store credentials: %w", err)
 	}
@@ -149,7 +155,28 @@ func runLogin(cmd *cobra.Command, args []string) error {
 	fmt.Printf("Login Succeeded\n")
 	logger.WithFields(map[string]interface{}{
 		"registry": registry,
-	}).Info("Credentials stored in Docker config")
+	}).Info("Credentials stored in " + location)
 
 	return nil
 }
+
+// storeLoginCredentials saves credentials according to the --credential-store
+// mode and returns where they were stored
+func storeLoginCredentials(store *auth.CredentialStore, mode, registry, username, password string) (string, error) {
+	switch mode {
+	case "auto":
+		if !auth.IsKeychainAvailable() {
+			return "Docker config", store.Store(registry, username, password)
+		}
+		fallthrough
+	case "keychain":
+		if !auth.IsKeychainAvailable() {
+			return "", fmt.Errorf("OS keychain is not available: install docker-credential-%s", auth.NativeKeychainHelper())
+		}
+		return "OS keychain", store.StoreInKeychain(registry, username, password)
+	case "file":
+		return "Docker config", store.Store(registry, username, password)
+	default:
+		return "", fmt.Errorf("invalid credential store %q (must be auto, keychain or file)", mode)
+	}
+}
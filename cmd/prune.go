@@ -0,0 +1,342 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"freightliner/pkg/client"
+	"freightliner/pkg/helper/apicalls"
+	"freightliner/pkg/report"
+	"freightliner/pkg/sync"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	pruneConfigFile string
+	pruneDryRun     bool
+	pruneOutput     string
+)
+
+// newPruneCmd creates the prune command
+func newPruneCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "prune --config FILE",
+		Short: "Remove old tags from destination repositories",
+		Long: `Applies the prune rules of a sync configuration file to the destination
+registry. Rules run now, whatever their schedule; the server runs them on their
+schedules.
+
+A rule only removes tags once it sets "delete: true". Until then every run is
+a dry run that reports what would be removed.
+
+Configuration file format:
+  destination:
+    registry: "my-registry.io"
+
+  prune:
+    - repository: "mirror/nginx"
+      keep_last: 10            # keep the 10 newest tags
+      max_age: "720h"          # ...and any tag younger than 30 days
+      protected_tags: ["latest", "stable", "v*"]
+      schedule: "0 0 3 * * *"  # server mode: daily at 03:00
+      delete: false            # report only
+      trash:                   # move removed tags to trash/mirror/nginx
+        retention: "168h"      # ...until empty-trash deletes them a week later
+
+  garbage_collection:          # after tags are removed from Harbor or registry:2
+    trigger: true              # start Harbor GC; otherwise print the command
+    delete_untagged: true`,
+		Example: `  # Show what the rules would remove
+  freightliner prune --config sync.yaml --dry-run
+
+  # Apply the rules and keep a report
+  freightliner prune --config sync.yaml --output prune-report.json
+
+  # Delete trashed tags whose retention is over
+  freightliner prune empty-trash --config sync.yaml`,
+		RunE: runPrune,
+	}
+
+	cmd.Flags().StringVar(&pruneConfigFile, "config", "", "Path to sync configuration file with prune rules (required)")
+	cmd.Flags().BoolVar(&pruneDryRun, "dry-run", false, "Report what would be removed, even for rules with delete enabled")
+	cmd.Flags().StringVar(&pruneOutput, "output", "", "Write the prune results as JSON to this file")
+
+	cmd.MarkFlagRequired("config")
+
+	cmd.AddCommand(newPruneEmptyTrashCmd())
+
+	return cmd
+}
+
+var (
+	emptyTrashConfigFile string
+	emptyTrashDryRun     bool
+	emptyTrashOutput     string
+)
+
+// newPruneEmptyTrashCmd creates the prune empty-trash command
+func newPruneEmptyTrashCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "empty-trash --config FILE",
+		Short: "Delete trashed tags whose retention is over",
+		Long: `Deletes the tags that prune rules with a trash moved to their trash
+repository once the rule's retention is over. Until then a trashed tag can be
+copied back to restore it. Rules without a trash are skipped.
+
+Like prune, a rule only deletes once it sets "delete: true", and its freeze
+windows apply.`,
+		Example: `  # Show which trashed tags would be deleted
+  freightliner prune empty-trash --config sync.yaml --dry-run
+
+  # Empty the trash and keep a report
+  freightliner prune empty-trash --config sync.yaml --output trash-report.json`,
+		RunE: runPruneEmptyTrash,
+	}
+
+	cmd.Flags().StringVar(&emptyTrashConfigFile, "config", "", "Path to sync configuration file with prune rules (required)")
+	cmd.Flags().BoolVar(&emptyTrashDryRun, "dry-run", false, "Report what would be deleted, even for rules with delete enabled")
+	cmd.Flags().StringVar(&emptyTrashOutput, "output", "", "Write the results as JSON to this file")
+
+	cmd.MarkFlagRequired("config")
+
+	return cmd
+}
+
+// runPrune executes the prune command
+func runPrune(cmd *cobra.Command, args []string) error {
+	logger, ctx, cancel := setupCommand(context.Background())
+	defer cancel()
+
+	syncConfig, err := sync.LoadConfig(pruneConfigFile)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	factory := client.NewFactory(syncFactoryConfig(), logger)
+	if err := syncConfig.ExpandGenerators(ctx, sync.NewRepositoryLister(factory)); err != nil {
+		return fmt.Errorf("failed to expand generators: %w", err)
+	}
+	if len(syncConfig.Prune) == 0 {
+		fmt.Println("No prune rules configured")
+		return nil
+	}
+
+	pruner := sync.NewPruner(logger)
+
+	runReport := report.New("prune", "", syncConfig.Destination.Registry)
+	runReport.DryRun = true
+
+	var results []*sync.PruneResult
+	var kept, removed, failed int
+	var reclaimable int64
+	var runErr error
+	ruleCalls := apicalls.NewGroup()
+	for _, rule := range syncConfig.Prune {
+		ruleCtx := apicalls.WithCounter(ctx, ruleCalls.Counter(rule.Repository))
+		result, err := pruner.PruneDestination(ruleCtx, factory, syncConfig, rule, pruneDryRun)
+		if result != nil {
+			results = append(results, result)
+			kept += len(result.Kept)
+			removed += len(result.Removed)
+			failed += len(result.Failed)
+			reclaimable += result.ReclaimableBytes
+			recordPruneResult(runReport, result)
+			displayPruneResult(result)
+		}
+		if err != nil {
+			fmt.Printf("Failed to prune %s: %s\n", rule.Repository, err)
+			if result == nil {
+				runReport.AddFailure(syncConfig.Destination.Registry+"/"+rule.Repository, "", err)
+			}
+			runErr = errors.Join(runErr, fmt.Errorf("%s: %w", rule.Repository, err))
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	if ctx.Err() == nil {
+		gc, err := pruner.CollectGarbage(ctx, factory, syncConfig, results)
+		if gc != nil {
+			displayGarbageCollection(gc)
+			if gc.Triggered {
+				runReport.SetSummary("garbage_collections_started", 1)
+			}
+		}
+		if err != nil {
+			runErr = errors.Join(runErr, err)
+		}
+	}
+
+	if err := writePruneResults(pruneOutput, results); err != nil {
+		return err
+	}
+
+	runReport.SetSummary("tags_kept", int64(kept))
+	runReport.SetSummary("tags_removed", int64(removed))
+	runReport.SetSummary("tags_failed", int64(failed))
+	runReport.SetSummary("reclaimable_bytes", reclaimable)
+	runReport.RecordRuleAPICalls(ruleCalls)
+	publishRunReport(ctx, logger, runReport, runErr)
+	if runErr != nil {
+		return fmt.Errorf("prune failed: %w", runErr)
+	}
+	return nil
+}
+
+// runPruneEmptyTrash executes the prune empty-trash command
+func runPruneEmptyTrash(cmd *cobra.Command, args []string) error {
+	logger, ctx, cancel := setupCommand(context.Background())
+	defer cancel()
+
+	syncConfig, err := sync.LoadConfig(emptyTrashConfigFile)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	factory := client.NewFactory(syncFactoryConfig(), logger)
+	if err := syncConfig.ExpandGenerators(ctx, sync.NewRepositoryLister(factory)); err != nil {
+		return fmt.Errorf("failed to expand generators: %w", err)
+	}
+
+	pruner := sync.NewPruner(logger)
+
+	runReport := report.New("empty trash", "", syncConfig.Destination.Registry)
+	runReport.DryRun = true
+
+	var results []*sync.PruneResult
+	var removed, failed int
+	var reclaimable int64
+	var runErr error
+	for _, rule := range syncConfig.Prune {
+		if rule.Trash == nil {
+			continue
+		}
+		result, err := pruner.EmptyTrashDestination(ctx, factory, syncConfig, rule, emptyTrashDryRun)
+		if result != nil {
+			results = append(results, result)
+			removed += len(result.Removed)
+			failed += len(result.Failed)
+			reclaimable += result.ReclaimableBytes
+			recordPruneResult(runReport, result)
+			displayPruneResult(result)
+		}
+		if err != nil {
+			fmt.Printf("Failed to empty the trash of %s: %s\n", rule.Repository, err)
+			if result == nil {
+				runReport.AddFailure(syncConfig.Destination.Registry+"/"+rule.Trash.Repository(rule.Repository), "", err)
+			}
+			runErr = errors.Join(runErr, fmt.Errorf("%s: %w", rule.Repository, err))
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	if len(results) == 0 && runErr == nil {
+		fmt.Println("No prune rules with a trash configured")
+		return nil
+	}
+
+	if ctx.Err() == nil {
+		gc, err := pruner.CollectGarbage(ctx, factory, syncConfig, results)
+		if gc != nil {
+			displayGarbageCollection(gc)
+			if gc.Triggered {
+				runReport.SetSummary("garbage_collections_started", 1)
+			}
+		}
+		if err != nil {
+			runErr = errors.Join(runErr, err)
+		}
+	}
+
+	if err := writePruneResults(emptyTrashOutput, results); err != nil {
+		return err
+	}
+
+	runReport.SetSummary("tags_removed", int64(removed))
+	runReport.SetSummary("tags_failed", int64(failed))
+	runReport.SetSummary("reclaimable_bytes", reclaimable)
+	publishRunReport(ctx, logger, runReport, runErr)
+	if runErr != nil {
+		return fmt.Errorf("empty trash failed: %w", runErr)
+	}
+	return nil
+}
+
+// writePruneResults writes results as JSON to path, if one is given
+func writePruneResults(path string, results []*sync.PruneResult) error {
+	if path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode prune results: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write prune results: %w", err)
+	}
+	fmt.Printf("Prune results written to %s\n", path)
+	return nil
+}
+
+// recordPruneResult adds a repository's prune result to the run report. Tags
+// removed, or that would be removed in a dry run, are the report's plan.
+func recordPruneResult(r *report.Report, result *sync.PruneResult) {
+	if !result.DryRun {
+		r.DryRun = false
+	}
+	for _, decision := range result.Removed {
+		r.AddPlanned(result.Reference(decision.Tag), "")
+	}
+	for _, decision := range result.Failed {
+		r.AddFailure(result.Reference(decision.Tag), "", errors.New(decision.Error))
+	}
+}
+
+// displayPruneResult prints what a prune run did to one repository
+func displayPruneResult(result *sync.PruneResult) {
+	verb := "removed"
+	if result.DryRun {
+		verb = "would remove"
+	}
+
+	fmt.Printf("%s/%s: kept %d, %s %d", result.Registry, result.Repository, len(result.Kept), verb, len(result.Removed))
+	if len(result.Failed) > 0 {
+		fmt.Printf(", failed %d", len(result.Failed))
+	}
+	if result.ReclaimableBytes > 0 {
+		fmt.Printf(", about %s reclaimable", formatBytes(result.ReclaimableBytes))
+	}
+	fmt.Println()
+
+	for _, decision := range result.Removed {
+		if decision.Trash != "" {
+			fmt.Printf("  - %s (%s), moved to %s\n", decision.Tag, decision.Reason, decision.Trash)
+			continue
+		}
+		fmt.Printf("  - %s (%s)\n", decision.Tag, decision.Reason)
+	}
+	for _, decision := range result.Failed {
+		fmt.Printf("  ! %s: %s\n", decision.Tag, decision.Error)
+	}
+}
+
+// displayGarbageCollection prints the garbage collection started after the
+// prune, or the command to start it
+func displayGarbageCollection(gc *sync.GarbageCollectionResult) {
+	if gc.Triggered {
+		fmt.Printf("Started garbage collection on %s", gc.Registry)
+		if gc.RunID != "" {
+			fmt.Printf(" (run %s)", gc.RunID)
+		}
+		fmt.Printf(" to reclaim about %s\n", formatBytes(gc.ReclaimableBytes))
+		return
+	}
+	fmt.Printf("Removed tags keep about %s on %s until garbage collection runs:\n  %s\n",
+		formatBytes(gc.ReclaimableBytes), gc.Registry, gc.Reminder)
+}
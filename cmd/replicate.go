@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 
+	"freightliner/pkg/report"
 	"freightliner/pkg/service"
 
 	"github.com/spf13/cobra"
@@ -25,7 +26,11 @@ func newReplicateCmd() *cobra.Command {
   freightliner replicate --tags v1.0,v1.1 ghcr.io/owner/repo gcr.io/my-project/repo
 
   # Dry run to preview what would be copied
-  freightliner replicate --dry-run docker.io/library/nginx:latest gcr.io/my-project/nginx:latest`,
+  freightliner replicate --dry-run docker.io/library/nginx:latest gcr.io/my-project/nginx:latest
+
+  # Checkpoint a large repository and resume it after an interruption
+  freightliner replicate --checkpoint ecr/prod/app gcr.io/my-project/app
+  freightliner replicate --resume latest ecr/prod/app gcr.io/my-project/app`,
 		Args: cobra.ExactArgs(2),
 		Run: func(cmd *cobra.Command, args []string) {
 			// Create logger and context
@@ -45,15 +50,41 @@ func newReplicateCmd() *cobra.Command {
 				"destination": destination,
 				"force":       cfg.Replicate.Force,
 				"dry_run":     cfg.Replicate.DryRun,
+				"checkpoint":  cfg.Replicate.Checkpoint,
+				"resume_id":   cfg.Replicate.ResumeID,
 			}).Info("Starting replication")
 
+			runReport := report.New("replicate", source, destination)
+			runReport.DryRun = cfg.Replicate.DryRun
+			runReport.AddPlanned(source, destination)
+
 			result, err := replicationSvc.ReplicateRepository(ctx, source, destination)
+			recordRetryBudget(runReport, replicationSvc)
+			attachLedger(runReport, replicationSvc)
+			attachLayerReuse(runReport, replicationSvc)
 			if err != nil {
 				logger.Error("Replication failed", err)
+				runReport.AddFailure(source, destination, err)
+				publishRunReport(ctx, logger, runReport, err)
 				fmt.Printf("Error during replication: %s\n", err)
+				if result != nil && result.CheckpointID != "" {
+					fmt.Printf("Resume with --resume %s\n", result.CheckpointID)
+				}
 				os.Exit(1)
 			}
 
+			if result.Error != nil {
+				runReport.AddFailure(source, destination, result.Error)
+			}
+			for _, skip := range result.Skipped {
+				runReport.AddSkip(skip.Source, skip.Destination, skip.Reason)
+			}
+			runReport.SetSummary("layers_copied", int64(result.LayersCopied))
+			runReport.SetSummary("bytes_copied", result.BytesCopied)
+			if result.TagsResumed > 0 {
+				runReport.SetSummary("tags_resumed", int64(result.TagsResumed))
+			}
+
 			// Print results
 			fmt.Println("\nReplication complete")
 			fmt.Printf("Tags copied: %d\n", result.LayersCopied)
@@ -65,6 +96,14 @@ func newReplicateCmd() *cobra.Command {
 				return "none"
 			}())
 			fmt.Printf("Total bytes transferred: %d\n", result.BytesCopied)
+			if result.TagsResumed > 0 {
+				fmt.Printf("Tags completed by the resumed run: %d\n", result.TagsResumed)
+			}
+			if result.CheckpointID != "" {
+				fmt.Printf("Checkpoint ID: %s\n", result.CheckpointID)
+			}
+
+			publishRunReport(ctx, logger, runReport, nil)
 		},
 	}
 
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 
+	"freightliner/pkg/report"
 	"freightliner/pkg/service"
 
 	"github.com/spf13/cobra"
@@ -49,13 +50,33 @@ func newReplicateTreeCmd() *cobra.Command {
 				"resume_id":   cfg.TreeReplicate.ResumeID,
 			}).Info("Starting tree replication")
 
+			runReport := report.New("replicate-tree", source, destination)
+			runReport.DryRun = cfg.TreeReplicate.DryRun
+			runReport.AddPlanned(source, destination)
+
 			result, err := treeReplicationSvc.ReplicateTree(ctx, source, destination)
+			recordRetryBudget(runReport, treeReplicationSvc)
+			attachLedger(runReport, treeReplicationSvc)
+			attachLayerReuse(runReport, treeReplicationSvc)
 			if err != nil {
 				logger.Error("Tree replication failed", err)
+				runReport.AddFailure(source, destination, err)
+				publishRunReport(ctx, logger, runReport, err)
 				fmt.Printf("Error during tree replication: %s\n", err)
 				os.Exit(1)
 			}
 
+			runReport.SetSummary("repositories_found", int64(result.RepositoriesFound))
+			runReport.SetSummary("repositories_replicated", int64(result.RepositoriesReplicated))
+			runReport.SetSummary("repositories_skipped", int64(result.RepositoriesSkipped))
+			runReport.SetSummary("repositories_failed", int64(result.RepositoriesFailed))
+			runReport.SetSummary("tags_copied", int64(result.TotalTagsCopied))
+			runReport.SetSummary("tags_skipped", int64(result.TotalTagsSkipped))
+			runReport.SetSummary("errors", int64(result.TotalErrors))
+			runReport.SetSummary("bytes_copied", result.TotalBytesTransferred)
+			runReport.SetSummary("blobs_deduplicated", result.BlobsDeduplicated)
+			runReport.SetSummary("bytes_deduplicated", result.BytesDeduplicated)
+
 			// Print results
 			fmt.Println("\nTree replication complete")
 			fmt.Printf("Repositories found: %d\n", result.RepositoriesFound)
@@ -66,10 +87,19 @@ func newReplicateTreeCmd() *cobra.Command {
 			fmt.Printf("Total tags skipped: %d\n", result.TotalTagsSkipped)
 			fmt.Printf("Total errors: %d\n", result.TotalErrors)
 			fmt.Printf("Total bytes transferred: %d\n", result.TotalBytesTransferred)
+			if result.BlobsDeduplicated > 0 {
+				fmt.Printf("Shared layers deduplicated: %d (%d bytes not re-uploaded)\n", result.BlobsDeduplicated, result.BytesDeduplicated)
+			}
 
 			if cfg.TreeReplicate.EnableCheckpoint && result.CheckpointID != "" {
 				fmt.Printf("Checkpoint ID: %s\n", result.CheckpointID)
 			}
+
+			var runErr error
+			if result.RepositoriesFailed > 0 {
+				runErr = fmt.Errorf("%d repositories failed to replicate", result.RepositoriesFailed)
+			}
+			publishRunReport(ctx, logger, runReport, runErr)
 		},
 	}
 
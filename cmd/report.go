@@ -0,0 +1,215 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"freightliner/pkg/attestation"
+	"freightliner/pkg/helper/apicalls"
+	"freightliner/pkg/helper/log"
+	"freightliner/pkg/helper/runid"
+	"freightliner/pkg/report"
+	"freightliner/pkg/service"
+)
+
+// reportUploadTimeout bounds the time spent uploading run reports
+const reportUploadTimeout = 2 * time.Minute
+
+// publishRunReport finishes the report with the run ID and registry API
+// calls of runCtx and uploads it when a report bucket is configured. Upload
+// failures are logged but never fail the run.
+func publishRunReport(runCtx context.Context, logger log.Logger, r *report.Report, runErr error) {
+	if r.RunID == "" {
+		r.RunID = runid.FromContext(runCtx)
+	}
+	r.RecordAPICalls(apicalls.FromContext(runCtx))
+	r.Finish(runErr)
+	logAPICalls(logger, r)
+	writeAttestation(logger, r)
+	writeLayerReuse(logger, r)
+
+	if cfg == nil || cfg.Reports.UploadURL == "" {
+		return
+	}
+
+	// The command context may already be canceled, so uploads get their own deadline
+	ctx, cancel := context.WithTimeout(context.Background(), reportUploadTimeout)
+	defer cancel()
+
+	publisher, err := report.NewPublisher(ctx, report.PublisherOptions{
+		URL:         cfg.Reports.UploadURL,
+		KeyTemplate: cfg.Reports.KeyTemplate,
+		Region:      cfg.Reports.Region,
+	})
+	if err != nil {
+		logger.WithFields(map[string]interface{}{
+			"url":   cfg.Reports.UploadURL,
+			"error": err.Error(),
+		}).Warn("Failed to set up report upload")
+		return
+	}
+
+	keys, err := publisher.Publish(ctx, r)
+	if err != nil {
+		logger.WithFields(map[string]interface{}{
+			"job_id": r.JobID,
+			"error":  err.Error(),
+		}).Warn("Failed to upload run report")
+		return
+	}
+
+	logger.WithFields(map[string]interface{}{
+		"job_id": r.JobID,
+		"url":    cfg.Reports.UploadURL,
+		"keys":   keys,
+	}).Info("Uploaded run report")
+	fmt.Printf("Report uploaded: %s (job %s)\n", cfg.Reports.UploadURL, r.JobID)
+}
+
+// logAPICalls logs the registry API calls of the run, per registry
+func logAPICalls(logger log.Logger, r *report.Report) {
+	for _, registry := range r.APICalls.Registries() {
+		fields := map[string]interface{}{
+			"job_id":   r.JobID,
+			"registry": registry,
+		}
+		var total int64
+		for call, n := range r.APICalls[registry] {
+			fields[call] = n
+			total += n
+		}
+		fields["total"] = total
+		logger.WithFields(fields).Info("Registry API calls")
+	}
+}
+
+// recordRetryBudget adds retry budget usage to the report when svc tracks one
+func recordRetryBudget(r *report.Report, svc interface{}) {
+	if reporter, ok := svc.(service.RetryBudgetReporter); ok {
+		r.RecordRetryBudget(reporter.RetryBudget())
+	}
+}
+
+// attachLedger attaches the transfer ledger to the report when svc keeps one
+func attachLedger(r *report.Report, svc interface{}) {
+	if reporter, ok := svc.(service.LedgerReporter); ok && reporter.Ledger() != nil {
+		r.AttachLedger(reporter.Ledger())
+	}
+}
+
+// attachLayerReuse attaches the layer reuse collector to the report when svc
+// keeps one
+func attachLayerReuse(r *report.Report, svc interface{}) {
+	if reporter, ok := svc.(service.LayerReuseReporter); ok && reporter.LayerReuse() != nil {
+		r.AttachLayerReuse(reporter.LayerReuse())
+	}
+}
+
+// writeLayerReuse writes the layer reuse report and adds its totals to the
+// run report's summary. Failures are logged but never fail the run.
+func writeLayerReuse(logger log.Logger, r *report.Report) {
+	reuse := r.LayerReuse()
+	if cfg == nil || cfg.Reports.LayerReuseOutput == "" || reuse == nil {
+		return
+	}
+
+	summary := reuse.Report()
+	r.SetSummary("layers_uploaded", int64(summary.Uploaded))
+	r.SetSummary("layers_mounted", int64(summary.Mounted))
+	r.SetSummary("layers_skipped", int64(summary.Skipped))
+	r.SetSummary("bytes_reused", summary.BytesReused)
+
+	data, err := json.MarshalIndent(summary, "", "  ")
+	if err == nil {
+		err = os.WriteFile(cfg.Reports.LayerReuseOutput, data, 0o600)
+	}
+	if err != nil {
+		logger.WithFields(map[string]interface{}{
+			"job_id": r.JobID,
+			"output": cfg.Reports.LayerReuseOutput,
+			"error":  err.Error(),
+		}).Warn("Failed to write layer reuse report")
+		return
+	}
+
+	logger.WithFields(map[string]interface{}{
+		"job_id":        r.JobID,
+		"output":        cfg.Reports.LayerReuseOutput,
+		"images":        len(summary.Images),
+		"layers":        summary.Layers,
+		"uploaded":      summary.Uploaded,
+		"mounted":       summary.Mounted,
+		"skipped":       summary.Skipped,
+		"bytes_reused":  summary.BytesReused,
+		"mount_sources": len(summary.MountSources),
+	}).Info("Wrote layer reuse report")
+}
+
+// writeAttestation writes the run attestation and adds it to the report's artifacts.
+// Failures are logged but never fail the run.
+func writeAttestation(logger log.Logger, r *report.Report) {
+	ledger := r.Ledger()
+	if cfg == nil || cfg.Attestation.Output == "" || ledger == nil {
+		return
+	}
+
+	// A channel attestation attests that the whole channel was mirrored, so
+	// incomplete channel mirrors get none
+	statement := attestation.NewStatement(r.RunInfo(), ledger)
+	if channel := r.Channel(); channel != nil {
+		if statement.Predicate.Run.Status != report.StatusSucceeded {
+			logger.WithFields(map[string]interface{}{
+				"job_id":  r.JobID,
+				"channel": channel.Name,
+			}).Warn("Channel mirror incomplete, no completion attestation written")
+			return
+		}
+		statement.Predicate.Channel = channel
+	}
+
+	data, err := renderAttestation(statement, cfg.Attestation.KeyFile)
+	if err == nil {
+		err = os.WriteFile(cfg.Attestation.Output, data, 0o600)
+	}
+	if err != nil {
+		logger.WithFields(map[string]interface{}{
+			"job_id": r.JobID,
+			"output": cfg.Attestation.Output,
+			"error":  err.Error(),
+		}).Warn("Failed to write run attestation")
+		return
+	}
+	r.SetAttestation(data)
+
+	fields := map[string]interface{}{
+		"job_id":    r.JobID,
+		"output":    cfg.Attestation.Output,
+		"transfers": len(ledger.Transfers()),
+	}
+	if cfg.Attestation.KeyFile == "" {
+		logger.WithFields(fields).Warn("Wrote unsigned run attestation; set --attestation-key to sign it")
+		return
+	}
+	logger.WithFields(fields).Info("Wrote signed run attestation")
+}
+
+// renderAttestation returns the statement as a DSSE envelope when keyFile is set,
+// or as a bare in-toto statement otherwise
+func renderAttestation(statement *attestation.Statement, keyFile string) ([]byte, error) {
+	if keyFile == "" {
+		return json.MarshalIndent(statement, "", "  ")
+	}
+
+	signer, err := attestation.LoadSigner(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	envelope, err := attestation.Sign(statement, signer)
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(envelope, "", "  ")
+}
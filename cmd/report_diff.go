@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"freightliner/pkg/report"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	reportDiffFormat           string
+	reportDiffFailOnRegression bool
+)
+
+// newReportCmd creates the report command
+func newReportCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "report",
+		Short: "Work with run reports",
+		Long:  `Commands for reviewing the run reports replication commands write`,
+	}
+
+	cmd.AddCommand(newReportDiffCmd())
+
+	return cmd
+}
+
+// newReportDiffCmd creates the report diff command
+func newReportDiffCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "diff [base-report] [head-report]",
+		Short: "Compare two run reports and highlight regressions",
+		Long: `Compares two run reports, as written to report.json, and lists every copy
+whose outcome changed, every summary counter and registry API call count that
+changed, and the regressions among them: a run that started failing, copies
+that started failing or are no longer made, and counters such as
+*_failed or tags_copied that moved the wrong way.
+
+Job IDs and timestamps are ignored and every list is sorted, so the same two
+reports always give the same diff. Use it to validate a configuration or
+version upgrade in staging before rolling it out.`,
+		Example: `  # Review a staging run after an upgrade
+  freightliner report diff before/report.json after/report.json
+
+  # Fail a pipeline when the new run regressed
+  freightliner report diff --fail-on-regression --format json before.json after.json`,
+		Args: cobra.ExactArgs(2),
+		RunE: runReportDiff,
+	}
+
+	cmd.Flags().StringVar(&reportDiffFormat, "format", "text", "Output format: text or json")
+	cmd.Flags().BoolVar(&reportDiffFailOnRegression, "fail-on-regression", false, "Exit with an error when the head run regressed")
+
+	return cmd
+}
+
+// runReportDiff executes the report diff command
+func runReportDiff(cmd *cobra.Command, args []string) error {
+	if reportDiffFormat != "text" && reportDiffFormat != "json" {
+		return fmt.Errorf("unsupported format %q, expected text or json", reportDiffFormat)
+	}
+
+	base, err := report.Load(args[0])
+	if err != nil {
+		return err
+	}
+	head, err := report.Load(args[1])
+	if err != nil {
+		return err
+	}
+
+	diff := report.Compare(base, head)
+	if reportDiffFormat == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(diff); err != nil {
+			return err
+		}
+	} else if err := diff.WriteText(os.Stdout); err != nil {
+		return err
+	}
+
+	if reportDiffFailOnRegression && diff.HasRegressions() {
+		return fmt.Errorf("head run has %d regressions", len(diff.Regressions))
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"freightliner/pkg/client"
+	"freightliner/pkg/helper/log"
+	"freightliner/pkg/interfaces"
+
+	"github.com/spf13/cobra"
+)
+
+var rmDryRun bool
+
+// newRmCmd creates the rm command
+func newRmCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "rm IMAGE [IMAGE...]",
+		Short: "Remove a tag or digest from a registry",
+		Long: `Removes single tags or digests, typically to clean up replication
+destinations. Removing a tag leaves the image and its other tags in place where
+the registry supports untagging; removing a digest deletes the image.
+
+IMAGE format: REGISTRY/REPOSITORY:TAG or REGISTRY/REPOSITORY@DIGEST
+
+The "ecr" and "gcr" registry shorthands select the configured ECR account and
+GCR project. Credentials are resolved the same way as for replication.`,
+		Example: `  # Remove an old tag from a GCR mirror
+  freightliner rm gcr/mirror/app:old-tag
+
+  # Remove an image by digest from ECR
+  freightliner rm ecr/team/app@sha256:abc123...
+
+  # Show what would be removed
+  freightliner rm --dry-run gcr/mirror/app:rc-1 gcr/mirror/app:rc-2`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: runRm,
+	}
+
+	cmd.Flags().BoolVar(&rmDryRun, "dry-run", false, "Show what would be removed without deleting")
+
+	return cmd
+}
+
+// runRm executes the rm command
+func runRm(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+	logger, ctx, cancel := setupCommand(ctx)
+	defer cancel()
+
+	factory := client.NewFactory(cfg, logger)
+
+	for _, image := range args {
+		registry, repoName, reference, err := parseTagSource(image)
+		if err != nil {
+			return err
+		}
+
+		registryClient, err := factory.CreateClientForRegistry(ctx, registry)
+		if err != nil {
+			return fmt.Errorf("failed to create client for registry %s: %w", registry, err)
+		}
+
+		repo, err := registryClient.GetRepository(ctx, repoName)
+		if err != nil {
+			return fmt.Errorf("failed to get repository %s: %w", repoName, err)
+		}
+
+		if err := removeReference(ctx, logger, repo, reference, rmDryRun); err != nil {
+			return fmt.Errorf("failed to remove %s: %w", image, err)
+		}
+
+		if rmDryRun {
+			fmt.Printf("Would remove %s\n", image)
+		} else {
+			fmt.Printf("Removed %s\n", image)
+		}
+	}
+
+	return nil
+}
+
+// removeReference deletes a tag or digest from repo
+func removeReference(
+	ctx context.Context,
+	logger log.Logger,
+	repo interfaces.RepositoryInfo,
+	reference string,
+	dryRun bool,
+) error {
+	deleter, ok := repo.(interfaces.ReferenceDeleter)
+	if !ok {
+		return fmt.Errorf("registry does not support deleting tags or digests")
+	}
+
+	logger.WithFields(map[string]interface{}{
+		"repository": repo.GetRepositoryName(),
+		"reference":  reference,
+		"dry_run":    dryRun,
+	}).Info("Removing image reference")
+
+	if dryRun {
+		return nil
+	}
+
+	return deleter.DeleteReference(ctx, reference)
+}
@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"context"
+	"testing"
+
+	"freightliner/pkg/helper/log"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeDeleterRepo struct {
+	deleted []string
+}
+
+func (f *fakeDeleterRepo) GetName() string           { return "mirror/app" }
+func (f *fakeDeleterRepo) GetRepositoryName() string { return "mirror/app" }
+
+func (f *fakeDeleterRepo) DeleteReference(_ context.Context, reference string) error {
+	f.deleted = append(f.deleted, reference)
+	return nil
+}
+
+type fakeReadOnlyRepo struct{}
+
+func (fakeReadOnlyRepo) GetName() string           { return "mirror/app" }
+func (fakeReadOnlyRepo) GetRepositoryName() string { return "mirror/app" }
+
+func TestRemoveReference(t *testing.T) {
+	logger := log.NewBasicLogger(log.ErrorLevel)
+	ctx := context.Background()
+
+	repo := &fakeDeleterRepo{}
+	require.NoError(t, removeReference(ctx, logger, repo, "old-tag", false))
+	require.NoError(t, removeReference(ctx, logger, repo, "sha256:abc", false))
+	assert.Equal(t, []string{"old-tag", "sha256:abc"}, repo.deleted)
+
+	dryRun := &fakeDeleterRepo{}
+	require.NoError(t, removeReference(ctx, logger, dryRun, "old-tag", true))
+	assert.Empty(t, dryRun.deleted)
+
+	err := removeReference(ctx, logger, fakeReadOnlyRepo{}, "old-tag", false)
+	assert.Error(t, err)
+}
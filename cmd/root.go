@@ -2,14 +2,26 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
-	"os/signal"
 	"strconv"
-	"syscall"
+	"strings"
+	"time"
 
 	"freightliner/pkg/config"
+	"freightliner/pkg/diagnostics"
+	"freightliner/pkg/helper/apicalls"
+	"freightliner/pkg/helper/clockskew"
+	"freightliner/pkg/helper/httplog"
 	"freightliner/pkg/helper/log"
+	"freightliner/pkg/helper/readonly"
+	"freightliner/pkg/helper/runid"
+	"freightliner/pkg/helper/shutdown"
+	"freightliner/pkg/helper/upload"
+	"freightliner/pkg/network"
+	"freightliner/pkg/storage"
+	"freightliner/pkg/transport"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
@@ -53,10 +65,26 @@ var (
 				switch f.Name {
 				case "log-level":
 					cfg.LogLevel = f.Value.String()
+				case "log-sample-first":
+					if val, err := strconv.Atoi(f.Value.String()); err == nil {
+						cfg.LogSampling.First = val
+					}
+				case "log-sample-thereafter":
+					if val, err := strconv.Atoi(f.Value.String()); err == nil {
+						cfg.LogSampling.Thereafter = val
+					}
+				case "timeout":
+					if val, err := time.ParseDuration(f.Value.String()); err == nil {
+						cfg.Timeout = val
+					}
 				case "ecr-region":
 					cfg.ECR.Region = f.Value.String()
 				case "ecr-account":
 					cfg.ECR.AccountID = f.Value.String()
+				case "ecr-native-replication":
+					cfg.ECR.NativeReplication = f.Value.String()
+				case "ecr-pull-through-cache":
+					cfg.ECR.PullThroughCache = f.Value.String()
 				case "gcr-project":
 					cfg.GCR.Project = f.Value.String()
 				case "gcr-location":
@@ -111,6 +139,94 @@ var (
 					cfg.Secrets.EncryptionKeysSecret = f.Value.String()
 				case "checkpoint-dir":
 					cfg.Checkpoint.Directory = f.Value.String()
+				case "report-upload-url":
+					cfg.Reports.UploadURL = f.Value.String()
+				case "report-key-template":
+					cfg.Reports.KeyTemplate = f.Value.String()
+				case "report-region":
+					cfg.Reports.Region = f.Value.String()
+				case "layer-reuse-report":
+					cfg.Reports.LayerReuseOutput = f.Value.String()
+				case "attestation-output":
+					cfg.Attestation.Output = f.Value.String()
+				case "attestation-key":
+					cfg.Attestation.KeyFile = f.Value.String()
+				case "resign-key":
+					cfg.Resign.KeyFile = f.Value.String()
+				case "prune-config":
+					cfg.Prune.SyncConfig = f.Value.String()
+				case "prune-report-dir":
+					cfg.Prune.ReportDir = f.Value.String()
+				case "prune-state-file":
+					cfg.Prune.StateFile = f.Value.String()
+				case "prune-pause-webhook":
+					cfg.Prune.PauseWebhook = f.Value.String()
+				case "webhook-secret":
+					cfg.Webhooks.Secret = f.Value.String()
+				case "webhook-signing-key":
+					cfg.Webhooks.SigningKeyFile = f.Value.String()
+				case "webhook-verify-key":
+					cfg.Webhooks.VerifyKeyFile = f.Value.String()
+				case "require-webhook-signature":
+					if val, err := strconv.ParseBool(f.Value.String()); err == nil {
+						cfg.Webhooks.RequireSignature = val
+					}
+				case "debug-addr":
+					cfg.Debug.Addr = f.Value.String()
+				case "debug-bundle-dir":
+					cfg.Debug.BundleDir = f.Value.String()
+				case "work-dir":
+					cfg.WorkDir = f.Value.String()
+				case "explain-filters":
+					if val, err := strconv.ParseBool(f.Value.String()); err == nil {
+						cfg.ExplainFilters = val
+					}
+				case "read-only":
+					if val, err := strconv.ParseBool(f.Value.String()); err == nil {
+						cfg.ReadOnly = val
+					}
+				case "run-id":
+					cfg.RunID = f.Value.String()
+				case "annotate-run-id":
+					if val, err := strconv.ParseBool(f.Value.String()); err == nil {
+						cfg.AnnotateRunID = val
+					}
+				case "log-http":
+					if hosts, err := cmd.Flags().GetStringSlice("log-http"); err == nil {
+						cfg.LogHTTP = hosts
+					}
+				case "retry-budget":
+					if val, err := strconv.Atoi(f.Value.String()); err == nil {
+						cfg.Retry.Budget = val
+					}
+				case "max-retries":
+					if val, err := strconv.Atoi(f.Value.String()); err == nil {
+						cfg.Retry.MaxRetries = val
+					}
+				case "registry-insecure":
+					if hosts, err := cmd.Flags().GetStringSlice("registry-insecure"); err == nil {
+						cfg.Registries.InsecureRegistries = hosts
+					}
+				case "dns-cache":
+					if val, err := strconv.ParseBool(f.Value.String()); err == nil {
+						cfg.Network.DNS.Cache = val
+					}
+				case "dns-negative-ttl":
+					if val, err := time.ParseDuration(f.Value.String()); err == nil {
+						cfg.Network.DNS.NegativeTTL = val
+					}
+				case "dns-retries":
+					if val, err := strconv.Atoi(f.Value.String()); err == nil {
+						cfg.Network.DNS.Retries = val
+					}
+				case "max-requests-per-second":
+					if val, err := strconv.ParseFloat(f.Value.String(), 64); err == nil {
+						cfg.Network.MaxRequestsPerSecond = val
+					}
+				case "resolve":
+					if values, err := cmd.Flags().GetStringArray("resolve"); err == nil {
+						cfg.Network.Resolve = values
+					}
 				case "force":
 					if val, err := strconv.ParseBool(f.Value.String()); err == nil {
 						cfg.Replicate.Force = val
@@ -125,18 +241,119 @@ var (
 					if tags, err := cmd.Flags().GetStringSlice("tags"); err == nil {
 						cfg.Replicate.Tags = tags
 					}
+				case "create-missing-repos":
+					cfg.Replicate.CreateMissingRepos = f.Value.String()
+				case "copy-scan-findings":
+					if val, err := strconv.ParseBool(f.Value.String()); err == nil {
+						cfg.Replicate.ScanFindings = val
+					}
+				case "copy-repo-metadata":
+					if val, err := strconv.ParseBool(f.Value.String()); err == nil {
+						cfg.Replicate.CopyRepoMetadata = val
+					}
 				}
 			})
 
-			return nil
+			// A profile given on the command line replaces the config file's
+			// values too, but never flags given alongside it
+			if cmd.Flags().Changed("profile") {
+				name, _ := cmd.Flags().GetString("profile")
+				profile, err := config.LookupProfile(name)
+				if err != nil {
+					return err
+				}
+				cfg.Profile = name
+				profile.Apply(cfg, cmd.Flags().Changed)
+			}
+
+			commandTimeout = resolveCommandTimeout(cmd, cfg.Timeout)
+
+			if err := configureNetwork(cfg.Network); err != nil {
+				return err
+			}
+			if err := configureUploads(cfg.Registries); err != nil {
+				return err
+			}
+			// Log requests innermost so the log shows what reaches the
+			// registry, after rate limiting and with final headers
+			if len(cfg.LogHTTP) > 0 {
+				httplog.Install(httplog.Options{
+					Hosts:  cfg.LogHTTP,
+					Logger: createLogger(cfg.LogLevel),
+				})
+			}
+			// Watch registry clocks first so the counting and read-only
+			// transports wrap it
+			if cfg.ClockSkewThreshold > 0 {
+				clockskew.Install(clockskew.Options{
+					Threshold:  cfg.ClockSkewThreshold,
+					Compensate: cfg.CompensateClockSkew,
+					Logger:     createLogger(cfg.LogLevel),
+				})
+			}
+			apicalls.Install()
+			if cfg.ReadOnly {
+				readonly.Enable()
+			}
+			return configureWorkDir(cfg.WorkDir)
 		},
 	}
+
+	// commandTimeout bounds the running command; zero means no limit
+	commandTimeout time.Duration
 )
 
+// defaultCommandTimeouts bound quick commands when --timeout is not set, so a
+// wedged registry fails CI jobs instead of hanging them. Long-running commands
+// such as replicate, sync and serve have no default limit. diff-tree only reads
+// tags and digests, but walks whole trees, so it gets a longer limit; report
+// diff reads local files and needs none.
+var defaultCommandTimeouts = map[string]time.Duration{
+	"inspect":          2 * time.Minute,
+	"list-tags":        2 * time.Minute,
+	"layers":           2 * time.Minute,
+	"manifest digest":  2 * time.Minute,
+	"manifest inspect": 2 * time.Minute,
+	"checkpoint list":  2 * time.Minute,
+	"checkpoint show":  2 * time.Minute,
+	"diff-tree":        30 * time.Minute,
+	"tag":              5 * time.Minute,
+	"delete":           5 * time.Minute,
+	"rm":               5 * time.Minute,
+	"auth test":        30 * time.Second,
+	"login":            30 * time.Second,
+}
+
+// resolveCommandTimeout returns the configured timeout, falling back to the
+// command's default
+func resolveCommandTimeout(cmd *cobra.Command, configured time.Duration) time.Duration {
+	if configured > 0 {
+		return configured
+	}
+
+	path := strings.TrimPrefix(cmd.CommandPath(), cmd.Root().Name()+" ")
+	return defaultCommandTimeouts[path]
+}
+
+// withCommandTimeout applies the command timeout to ctx
+func withCommandTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if commandTimeout > 0 {
+		return context.WithTimeout(ctx, commandTimeout)
+	}
+	return context.WithCancel(ctx)
+}
+
 // Execute runs the root command
 func Execute() {
 	if err := rootCmd.Execute(); err != nil {
-		fmt.Println(err)
+		if errors.Is(err, context.DeadlineExceeded) && commandTimeout > 0 {
+			fmt.Printf("command timed out after %s: %v\n", commandTimeout, err)
+		} else {
+			fmt.Println(err)
+		}
 		os.Exit(1)
 	}
 }
@@ -157,16 +374,26 @@ func init() {
 	rootCmd.AddCommand(newHealthCheckCmd())
 	rootCmd.AddCommand(newReplicateCmd())
 	rootCmd.AddCommand(newReplicateTreeCmd())
+	rootCmd.AddCommand(newChannelCmd())
+	rootCmd.AddCommand(newDiffTreeCmd())
 	rootCmd.AddCommand(newCheckpointCmd())
 	rootCmd.AddCommand(newServeCmd())
+	rootCmd.AddCommand(newJobsCmd())
+	rootCmd.AddCommand(newRulesCmd())
+	rootCmd.AddCommand(newClusterCmd())
 	rootCmd.AddCommand(newSBOMCmd())
 	rootCmd.AddCommand(newScanCmd())
+	rootCmd.AddCommand(newSelfAuditCmd())
+	rootCmd.AddCommand(newReportCmd())
 
 	// Add new advanced CLI commands (Skopeo-like functionality)
 	rootCmd.AddCommand(newInspectCmd())
 	rootCmd.AddCommand(newListTagsCmd())
 	rootCmd.AddCommand(newDeleteCmd())
+	rootCmd.AddCommand(newRmCmd())
+	rootCmd.AddCommand(newTagCmd())
 	rootCmd.AddCommand(newSyncCmd())
+	rootCmd.AddCommand(newPruneCmd())
 
 	// Add manifest operations
 	rootCmd.AddCommand(newManifestCmd())
@@ -176,17 +403,108 @@ func init() {
 
 	// Add auth management
 	rootCmd.AddCommand(newAuthCmd())
+
+	// Add registry-specific operations
+	rootCmd.AddCommand(newECRCmd())
+
+	// Add manifest generators
+	rootCmd.AddCommand(newGenerateCmd())
+}
+
+// configureNetwork applies DNS resolution overrides, the DNS cache and
+// dual-stack dialing to the shared registry transports
+func configureNetwork(networkCfg config.NetworkConfig) error {
+	overrides, err := config.ParseResolveOverrides(networkCfg.Resolve)
+	if err != nil {
+		return err
+	}
+
+	pinned := make(map[string][]string, len(overrides))
+	for _, override := range overrides {
+		pinned[override.HostPort()] = override.Addresses
+	}
+	network.SetResolveOverrides(pinned)
+
+	if networkCfg.DNS.Cache {
+		network.SetDNSCache(network.NewDNSCache(network.DNSCacheOptions{
+			MinTTL:       networkCfg.DNS.MinTTL,
+			MaxTTL:       networkCfg.DNS.MaxTTL,
+			NegativeTTL:  networkCfg.DNS.NegativeTTL,
+			Retries:      networkCfg.DNS.Retries,
+			RetryBackoff: network.DefaultDNSRetryBackoff,
+			ServeStale:   networkCfg.DNS.ServeStale,
+		}))
+	}
+	network.ConfigureDefaultTransports()
+	apicalls.SetLimit(networkCfg.MaxRequestsPerSecond)
+
+	return nil
+}
+
+// configureUploads applies the upload strategy of each configured registry
+// to blob uploads made to its host
+func configureUploads(registries config.RegistriesConfig) error {
+	policies := make(map[string]upload.Policy)
+	for i := range registries.Registries {
+		reg := &registries.Registries[i]
+		if reg.Upload.Strategy == "" {
+			continue
+		}
+		if err := reg.Upload.Validate(); err != nil {
+			return fmt.Errorf("invalid upload config for registry %s: %w", reg.Name, err)
+		}
+		host, err := reg.GetRegistryHost()
+		if err != nil || host == "" {
+			return fmt.Errorf("registry %s needs an endpoint for its upload config", reg.Name)
+		}
+		policies[host] = upload.Policy{
+			Strategy:     upload.Strategy(reg.Upload.Strategy),
+			ChunkSize:    int64(reg.Upload.ChunkSizeMB) << 20,
+			MaxChunkSize: int64(reg.Upload.MaxChunkSizeMB) << 20,
+		}
+	}
+	if len(policies) == 0 {
+		return nil
+	}
+
+	upload.Configure(policies)
+	upload.Install()
+	return nil
 }
 
-// setupCommand creates a logger and a cancellable context
+// configureWorkDir points blob spooling at dir and removes partial files
+// left there by interrupted runs. Without a work directory, destinations
+// clean the default one when they first use it.
+func configureWorkDir(dir string) error {
+	if dir == "" {
+		return nil
+	}
+	if _, err := storage.OpenSpool(dir, createLogger(cfg.LogLevel)); err != nil {
+		return fmt.Errorf("failed to prepare work directory: %w", err)
+	}
+	transport.SetWorkDir(dir)
+	return nil
+}
+
+// setupCommand creates a logger and a cancellable context bounded by the
+// command timeout, counting the registry API calls made under it. Both carry
+// the run ID, which is generated unless --run-id sets it.
 func setupCommand(ctx context.Context) (log.Logger, context.Context, context.CancelFunc) {
-	logger := createLogger(cfg.LogLevel)
-	ctx, cancel := context.WithCancel(ctx)
+	// Every log line, metric scrape, checkpoint and report of the run carries its ID
+	if cfg.RunID == "" {
+		cfg.RunID = runid.New()
+	}
+	runid.Publish(cfg.RunID)
+	logger := createLogger(cfg.LogLevel).WithFields(map[string]interface{}{"run_id": cfg.RunID})
+	ctx = runid.WithRunID(ctx, cfg.RunID)
+
+	ctx, cancel := withCommandTimeout(apicalls.WithCounter(ctx, apicalls.NewCounter()))
+	startDiagnostics(ctx, logger)
 
 	// Set up signal handling
 	go func() {
-		sigCh := make(chan os.Signal, 1)
-		signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+		sigCh, release := shutdown.Notify()
+		defer release()
 		select {
 		case <-sigCh:
 			logger.Info("Received termination signal, shutting down")
@@ -199,20 +517,31 @@ func setupCommand(ctx context.Context) (log.Logger, context.Context, context.Can
 	return logger, ctx, cancel
 }
 
-// createLogger creates a new logger with the specified level
+// startDiagnostics serves the debug endpoints and writes a diagnostics bundle
+// on SIGQUIT while ctx is live, when a debug address is configured
+func startDiagnostics(ctx context.Context, logger log.Logger) {
+	if cfg.Debug.Addr == "" {
+		return
+	}
+
+	if _, err := diagnostics.Serve(ctx, cfg.Debug.Addr, logger); err != nil {
+		logger.Error("Failed to start diagnostics server", err)
+	}
+	diagnostics.HandleSignals(ctx, cfg.Debug.BundleDir, logger)
+}
+
+// createLogger creates a logger from a level specification such as "info" or
+// "info,copy=debug,tree=warn", sampling repeated debug lines
 func createLogger(level string) log.Logger {
-	var logLevel log.Level
-	switch level {
-	case "debug":
-		logLevel = log.DebugLevel
-	case "info":
-		logLevel = log.InfoLevel
-	case "warn":
-		logLevel = log.WarnLevel
-	case "error":
-		logLevel = log.ErrorLevel
-	default:
-		logLevel = log.InfoLevel
-	}
-	return log.NewBasicLogger(logLevel)
+	spec, err := log.ParseLevelSpec(level)
+	if err != nil {
+		spec = log.LevelSpec{Default: log.InfoLevel}
+	}
+
+	logger := log.NewBasicLogger(spec.Min())
+	logger = log.NewSampledLogger(logger, log.SamplingConfig{
+		First:      cfg.LogSampling.First,
+		Thereafter: cfg.LogSampling.Thereafter,
+	})
+	return log.NewPackageLevelLogger(logger, spec)
 }
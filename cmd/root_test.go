@@ -272,6 +272,11 @@ func TestCreateLogger(t *testing.T) {
 			logLevel: "",
 			expected: log.InfoLevel,
 		},
+		{
+			name:     "package overrides",
+			logLevel: "info,copy=debug,tree=warn",
+			expected: log.InfoLevel,
+		},
 	}
 
 	for _, tt := range tests {
@@ -880,7 +885,7 @@ func TestCheckpointShowCommand(t *testing.T) {
 
 	cmd := newCheckpointShowCmd()
 
-	assert.Equal(t, "show", cmd.Use)
+	assert.Equal(t, "show [ID]", cmd.Use)
 	assert.NotEmpty(t, cmd.Short)
 }
 
@@ -893,7 +898,7 @@ func TestCheckpointDeleteCommand(t *testing.T) {
 
 	cmd := newCheckpointDeleteCmd()
 
-	assert.Equal(t, "delete", cmd.Use)
+	assert.Equal(t, "delete [ID...]", cmd.Use)
 	assert.NotEmpty(t, cmd.Short)
 }
 
@@ -952,6 +957,67 @@ func TestSetupCommandCancellation(t *testing.T) {
 	}
 }
 
+// TestSetupCommandTimeout tests that the command timeout bounds the context
+func TestSetupCommandTimeout(t *testing.T) {
+	originalCfg, originalTimeout := cfg, commandTimeout
+	cfg = &config.Config{LogLevel: "info"}
+	commandTimeout = 20 * time.Millisecond
+	defer func() { cfg, commandTimeout = originalCfg, originalTimeout }()
+
+	_, ctx, cancel := setupCommand(context.Background())
+	defer cancel()
+
+	select {
+	case <-ctx.Done():
+		assert.ErrorIs(t, ctx.Err(), context.DeadlineExceeded)
+	case <-time.After(time.Second):
+		t.Error("context should time out")
+	}
+}
+
+// TestResolveCommandTimeout tests the --timeout flag and per-command defaults
+func TestResolveCommandTimeout(t *testing.T) {
+	root := &cobra.Command{Use: "freightliner"}
+	manifest := &cobra.Command{Use: "manifest"}
+	digest := &cobra.Command{Use: "digest IMAGE"}
+	checkpoint := &cobra.Command{Use: "checkpoint"}
+	checkpointList := &cobra.Command{Use: "list"}
+	checkpointShow := &cobra.Command{Use: "show [ID]"}
+	checkpointDelete := &cobra.Command{Use: "delete [ID...]"}
+	report := &cobra.Command{Use: "report"}
+	reportDiff := &cobra.Command{Use: "diff [base-report] [head-report]"}
+	diffTree := &cobra.Command{Use: "diff-tree [source] [destination]"}
+	replicate := &cobra.Command{Use: "replicate"}
+	manifest.AddCommand(digest)
+	checkpoint.AddCommand(checkpointList, checkpointShow, checkpointDelete)
+	report.AddCommand(reportDiff)
+	root.AddCommand(manifest, checkpoint, report, diffTree, replicate)
+
+	tests := []struct {
+		name       string
+		cmd        *cobra.Command
+		configured time.Duration
+		want       time.Duration
+	}{
+		{name: "manifest digest default", cmd: digest, want: 2 * time.Minute},
+		{name: "checkpoint list default", cmd: checkpointList, want: 2 * time.Minute},
+		{name: "checkpoint show default", cmd: checkpointShow, want: 2 * time.Minute},
+		{name: "diff-tree default", cmd: diffTree, want: 30 * time.Minute},
+		{name: "checkpoint delete has no default", cmd: checkpointDelete, want: 0},
+		{name: "report diff has no default", cmd: reportDiff, want: 0},
+		{name: "replicate has no default", cmd: replicate, want: 0},
+		{name: "flag overrides default", cmd: digest, configured: 10 * time.Second, want: 10 * time.Second},
+		{name: "flag bounds diff-tree", cmd: diffTree, configured: time.Minute, want: time.Minute},
+		{name: "flag bounds replicate", cmd: replicate, configured: time.Hour, want: time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, resolveCommandTimeout(tt.cmd, tt.configured))
+		})
+	}
+}
+
 // TestConfigFileLoading tests configuration file loading
 func TestConfigFileLoading(t *testing.T) {
 	// Create temporary config file
@@ -0,0 +1,176 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"freightliner/pkg/server"
+
+	"github.com/spf13/cobra"
+)
+
+// newRulesCmd creates a new rules command
+func newRulesCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "rules",
+		Short: "Inspect scheduled rules on a running server",
+		Long:  `Commands for inspecting the rules a freightliner server runs on a schedule`,
+	}
+
+	cmd.AddCommand(newRulesStatusCmd())
+
+	return cmd
+}
+
+// rulesStatusOptions holds the rules status command flags
+type rulesStatusOptions struct {
+	server  string
+	apiKey  string
+	format  string
+	timeout time.Duration
+}
+
+// newRulesStatusCmd creates a new rules status command
+func newRulesStatusCmd() *cobra.Command {
+	opts := &rulesStatusOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "Show the health, last run and next run of every rule",
+		Long: `Shows every rule a freightliner server runs on a schedule with its health,
+the outcome and counters of its last run, and when it runs next. A rule is
+healthy when its last run succeeded, failing when it failed, paused when it
+exceeded its error budget, and not_run until it first runs.
+
+Statuses survive server restarts when the server has a prune state file.`,
+		Example: `  # Check which mirrors are healthy
+  freightliner rules status --server http://mirror:8080
+
+  # Feed the statuses to another tool
+  freightliner rules status --format json | jq '.rules[] | select(.health != "healthy")'`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if opts.format != "text" && opts.format != "json" {
+				return fmt.Errorf("unsupported format %q, expected text or json", opts.format)
+			}
+
+			ctx, cancel := context.WithTimeout(cmd.Context(), opts.timeout)
+			defer cancel()
+
+			serverURL := opts.server
+			if serverURL == "" {
+				serverURL = cfg.Server.BaseURL()
+			}
+			apiKey := opts.apiKey
+			if apiKey == "" {
+				apiKey = cfg.Server.APIKey
+			}
+			if apiKey == "" {
+				apiKey = os.Getenv("FREIGHTLINER_API_KEY")
+			}
+
+			rules, err := fetchServerRules(ctx, serverURL, apiKey)
+			if err != nil {
+				return err
+			}
+
+			if opts.format == "json" {
+				encoder := json.NewEncoder(os.Stdout)
+				encoder.SetIndent("", "  ")
+				return encoder.Encode(map[string]interface{}{"rules": rules, "count": len(rules)})
+			}
+			return writeRuleStatuses(os.Stdout, rules, time.Now())
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.server, "server", "", "Server URL (defaults to the configured server address)")
+	cmd.Flags().StringVar(&opts.apiKey, "api-key", "", "API key or tenant token (defaults to FREIGHTLINER_API_KEY)")
+	cmd.Flags().StringVar(&opts.format, "format", "text", "Output format: text or json")
+	cmd.Flags().DurationVar(&opts.timeout, "timeout", 30*time.Second, "How long to wait for the server")
+
+	return cmd
+}
+
+// fetchServerRules lists the rules of the server at serverURL
+func fetchServerRules(ctx context.Context, serverURL, apiKey string) ([]server.RuleSummary, error) {
+	endpoint := strings.TrimSuffix(serverURL, "/") + "/api/v1/rules"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create rules request: %w", err)
+	}
+	if apiKey != "" {
+		req.Header.Set("X-API-Key", apiKey)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach server %s: %w", serverURL, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 10<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rules response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		var errResp struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(body, &errResp) == nil && errResp.Error != "" {
+			return nil, fmt.Errorf("failed to list rules: %s", errResp.Error)
+		}
+		return nil, fmt.Errorf("failed to list rules: server returned %s", resp.Status)
+	}
+
+	var result struct {
+		Rules []server.RuleSummary `json:"rules"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("failed to decode rules response: %w", err)
+	}
+	return result.Rules, nil
+}
+
+// writeRuleStatuses writes one line per rule, with times relative to now
+func writeRuleStatuses(out io.Writer, rules []server.RuleSummary, now time.Time) error {
+	if len(rules) == 0 {
+		_, err := fmt.Fprintln(out, "No scheduled rules")
+		return err
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KIND\tREPOSITORY\tHEALTH\tLAST RUN\tRUNS\tFAILED\tNEXT RUN\tDETAIL")
+	for _, rule := range rules {
+		lastRun, detail := "-", ""
+		if rule.LastRun != nil {
+			lastRun = relativeTime(rule.LastRun.FinishedAt, now)
+			detail = rule.LastRun.Error
+		}
+		if rule.Pause != nil {
+			detail = rule.Pause.Reason
+		}
+		nextRun := "-"
+		if rule.NextRun != nil {
+			nextRun = relativeTime(*rule.NextRun, now)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%d\t%s\t%s\n",
+			rule.Kind, rule.Repository, rule.Health, lastRun, rule.Runs, rule.Failures, nextRun, detail)
+	}
+	return w.Flush()
+}
+
+// relativeTime describes at as a duration before or after now
+func relativeTime(at, now time.Time) string {
+	d := at.Sub(now).Round(time.Second)
+	if d < 0 {
+		return (-d).String() + " ago"
+	}
+	return "in " + d.String()
+}
@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRulesStatus(t *testing.T) {
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/rules" || r.Header.Get("X-API-Key") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"error":"Invalid API key"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"count":2,"rules":[
+			{"id":"mirror/app","kind":"prune","repository":"mirror/app","schedule":"@hourly","health":"healthy",
+			 "last_run":{"finished_at":"2026-10-16T11:00:00Z","outcome":"succeeded"},"next_run":"2026-10-16T13:00:00Z","runs":4,"failures":0},
+			{"id":"mirror/db","kind":"prune","repository":"mirror/db","schedule":"@daily","health":"failing",
+			 "last_run":{"finished_at":"2026-10-16T10:00:00Z","outcome":"failed","error":"registry unavailable"},"runs":3,"failures":2}
+		]}`))
+	}))
+	defer api.Close()
+
+	rules, err := fetchServerRules(context.Background(), api.URL, "secret")
+	require.NoError(t, err)
+	require.Len(t, rules, 2)
+
+	var out bytes.Buffer
+	require.NoError(t, writeRuleStatuses(&out, rules, time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)))
+	text := out.String()
+	assert.Contains(t, text, "HEALTH")
+	assert.Regexp(t, `mirror/app\s+healthy\s+1h0m0s ago\s+4\s+0\s+in 1h0m0s`, text)
+	assert.Regexp(t, `mirror/db\s+failing\s+2h0m0s ago\s+3\s+2\s+-\s+registry unavailable`, text)
+
+	_, err = fetchServerRules(context.Background(), api.URL, "wrong")
+	assert.EqualError(t, err, "failed to list rules: Invalid API key")
+}
@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"freightliner/pkg/config"
+	"freightliner/pkg/security/audit"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	selfAuditFormat      string
+	selfAuditOutput      string
+	selfAuditFailOn      string
+	selfAuditMinSeverity string
+)
+
+// newSelfAuditCmd creates the self-audit command
+func newSelfAuditCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "self-audit",
+		Short: "Audit the deployed configuration and environment for security problems",
+		Long: `Audit the configuration this installation runs with for security problems,
+using the rules of the source security scanner at runtime:
+
+  - Hardcoded secrets in the configuration file and environment variables
+  - Weak cryptography: disabled TLS verification, plain HTTP endpoints,
+    certificates with weak signatures, short keys or close expiry
+  - Injection payloads (SQL, XSS, command, LDAP, path traversal) in
+    configuration values
+  - Exposed server and debug endpoints, weak API keys and loose file
+    permissions
+
+Findings are ranked by severity. Secret values are never printed.
+
+Examples:
+  # Audit the configuration a deployment uses
+  freightliner self-audit --config /etc/freightliner/config.yaml
+
+  # Fail a pipeline on high or critical findings
+  freightliner self-audit --config config.yaml --fail-on high
+
+  # Write a JSON report of medium and higher findings
+  freightliner self-audit --config config.yaml --min-severity medium --format json --output audit.json`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			minSeverity, err := audit.ParseSeverity(selfAuditMinSeverity)
+			if err != nil {
+				return fmt.Errorf("invalid --min-severity: %w", err)
+			}
+			var failOn audit.Severity
+			if selfAuditFailOn != "" {
+				if failOn, err = audit.ParseSeverity(selfAuditFailOn); err != nil {
+					return fmt.Errorf("invalid --fail-on: %w", err)
+				}
+			}
+
+			auditCfg := cfg
+			if auditCfg == nil {
+				auditCfg = config.NewDefaultConfig()
+			}
+			report := audit.Run(auditCfg, audit.Options{
+				ConfigFile: configFile,
+				Environ:    os.Environ(),
+			})
+
+			var output []byte
+			switch selfAuditFormat {
+			case "json":
+				if output, err = report.Filter(minSeverity).ExportJSON(); err != nil {
+					return fmt.Errorf("failed to export report: %w", err)
+				}
+			case "table", "":
+				output = []byte(report.Filter(minSeverity).ExportTable())
+			default:
+				return fmt.Errorf("unsupported output format: %s", selfAuditFormat)
+			}
+
+			if selfAuditOutput != "" {
+				if err := os.WriteFile(selfAuditOutput, output, 0600); err != nil {
+					return fmt.Errorf("failed to write report: %w", err)
+				}
+				fmt.Printf("Self-audit report written to: %s\n", selfAuditOutput)
+			} else {
+				fmt.Println(string(output))
+			}
+
+			if selfAuditFailOn != "" {
+				if count := report.Count(failOn); count > 0 {
+					return fmt.Errorf("self-audit found %d problems at or above %s severity", count, failOn)
+				}
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&selfAuditFormat, "format", "f", "table", "Output format (table, json)")
+	cmd.Flags().StringVarP(&selfAuditOutput, "output", "o", "", "Output file path (default: stdout)")
+	cmd.Flags().StringVar(&selfAuditFailOn, "fail-on", "",
+		"Exit with an error if problems are found at or above this severity (critical, high, medium, low)")
+	cmd.Flags().StringVar(&selfAuditMinSeverity, "min-severity", "low",
+		"Only report problems at or above this severity")
+
+	return cmd
+}
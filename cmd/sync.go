@@ -2,22 +2,38 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
+	"freightliner/pkg/attestation"
 	"freightliner/pkg/client"
+	"freightliner/pkg/client/ecr"
 	"freightliner/pkg/client/generic"
 	"freightliner/pkg/config"
+	copyutil "freightliner/pkg/copy"
+	"freightliner/pkg/helper/apicalls"
 	"freightliner/pkg/helper/log"
+	"freightliner/pkg/interfaces"
+	"freightliner/pkg/report"
+	"freightliner/pkg/resilience"
+	"freightliner/pkg/security/encryption"
+	"freightliner/pkg/service"
 	"freightliner/pkg/sync"
 
+	"github.com/google/go-containerregistry/pkg/v1/remote"
 	"github.com/spf13/cobra"
 )
 
 var (
-	syncConfigFile string
-	syncDryRun     bool
-	syncParallel   int
+	syncConfigFile       string
+	syncDryRun           bool
+	syncParallel         int
+	syncSinceLastSuccess bool
+	syncStateFile        string
+	syncRepoMetadata     bool
+	syncDue              bool
 )
 
 // newSyncCmd creates the sync command
@@ -67,6 +83,21 @@ Configuration file format:
       semver_constraint: ">=20.04"
       latest_n: 5
 
+    - repository: "library/alpine"
+      latest_n: 3
+      schedule: "0 0 */6 * * *"  # With --due, at most every 6 hours
+
+  # Further source/destination pairs, synced in the same run
+  mirrors:
+    - name: "quay"
+      source:
+        registry: "quay.io"
+      destination:
+        registry: "my-registry.io"
+      images:
+        - repository: "prometheus/prometheus"
+          semver_constraint: ">=2.50.0"
+
 Examples:
   # Sync using configuration file
   freightliner sync --config sync.yaml
@@ -76,6 +107,12 @@ Examples:
 
   # Override parallelism
   freightliner sync --config sync.yaml --parallel 10
+
+  # Scheduled runs: only copy tags whose digest changed since the last run
+  freightliner sync --config sync.yaml --since-last-success
+
+  # Run from cron every few minutes; each rule runs on its own schedule
+  freightliner sync --config sync.yaml --due --since-last-success
 `,
 		RunE: runSync,
 	}
@@ -83,6 +120,10 @@ Examples:
 	cmd.Flags().StringVar(&syncConfigFile, "config", "", "Path to sync configuration file (required)")
 	cmd.Flags().BoolVar(&syncDryRun, "dry-run", false, "Show what would be synced without actually syncing")
 	cmd.Flags().IntVar(&syncParallel, "parallel", 0, "Override parallel workers from config (default: from config or 3)")
+	cmd.Flags().BoolVar(&syncSinceLastSuccess, "since-last-success", false, "Only sync tags pushed or changed since they were last synced, and record the digests copied")
+	cmd.Flags().StringVar(&syncStateFile, "state-file", "", "Where --since-last-success and --due keep per-rule results (default: <config>.state.json)")
+	cmd.Flags().BoolVar(&syncDue, "due", false, "Only run image rules whose schedule has come due since they last ran; rules without a schedule always run")
+	cmd.Flags().BoolVar(&syncRepoMetadata, "copy-repo-metadata", false, "Copy repository descriptions and readmes where both registries support them")
 
 	cmd.MarkFlagRequired("config")
 
@@ -95,12 +136,28 @@ func runSync(cmd *cobra.Command, args []string) error {
 	logger, ctx, cancel := setupCommand(ctx)
 	defer cancel()
 
-	// Load sync configuration using pkg/sync
-	syncConfig, err := sync.LoadConfig(syncConfigFile)
+	// Load sync configuration using pkg/sync; --profile replaces the file's profile
+	var profile string
+	if cmd.Flags().Changed("profile") {
+		profile = cfg.Profile
+	}
+	syncConfig, err := sync.LoadConfigWithProfile(syncConfigFile, profile)
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
 
+	// Replace registry aliases with the hosts of the named registries
+	for _, reg := range []*sync.RegistryConfig{&syncConfig.Source, &syncConfig.Destination} {
+		if err := resolveRegistryAlias(reg); err != nil {
+			return err
+		}
+	}
+
+	// Expand generators over the registry catalogs
+	if err := syncConfig.ExpandGenerators(ctx, sync.NewRepositoryLister(client.NewFactory(syncFactoryConfig(), logger))); err != nil {
+		return fmt.Errorf("failed to expand generators: %w", err)
+	}
+
 	// Validate configuration
 	if err := syncConfig.Validate(); err != nil {
 		return fmt.Errorf("invalid config: %w", err)
@@ -111,20 +168,105 @@ func runSync(cmd *cobra.Command, args []string) error {
 		syncConfig.Parallel = syncParallel
 	}
 
-	logger.WithFields(map[string]interface{}{
+	pairs := syncConfig.Pairs()
+	if len(pairs) == 0 {
+		fmt.Println("No images to sync")
+		return nil
+	}
+
+	// Every pair is synced even when an earlier one fails
+	var runErr error
+	for _, pair := range pairs {
+		if pair.Name != "" {
+			for _, reg := range []*sync.RegistryConfig{&pair.Config.Source, &pair.Config.Destination} {
+				if err := resolveRegistryAlias(reg); err != nil {
+					return fmt.Errorf("mirror %s: %w", pair.Name, err)
+				}
+			}
+		}
+		if len(pairs) > 1 {
+			fmt.Printf("== %s -> %s ==\n", pair.Config.Source.Registry, pair.Config.Destination.Registry)
+		}
+
+		if err := syncPair(ctx, logger, pair); err != nil {
+			if pair.Name != "" {
+				err = fmt.Errorf("mirror %s: %w", pair.Name, err)
+			}
+			runErr = errors.Join(runErr, err)
+		}
+	}
+	return runErr
+}
+
+// syncPair syncs the images of one source and destination pair
+func syncPair(ctx context.Context, logger log.Logger, pair sync.Pair) error {
+	syncConfig := pair.Config
+
+	statePath := syncStateFile
+	if statePath == "" {
+		statePath = sync.DefaultStatePath(syncConfigFile)
+	}
+	statePath = sync.MirrorStatePath(statePath, pair.Name)
+
+	// Load results of earlier runs to skip unchanged tags and rules not due
+	var state *sync.State
+	if syncSinceLastSuccess || syncDue || hasDigestChangeOnlyRules(syncConfig.Images) {
+		var err error
+		state, err = sync.LoadState(statePath)
+		if err != nil {
+			return err
+		}
+	}
+	startedAt := time.Now()
+
+	fields := map[string]interface{}{
 		"source":      syncConfig.Source.Registry,
 		"destination": syncConfig.Destination.Registry,
 		"parallel":    syncConfig.Parallel,
 		"dry-run":     syncDryRun,
-	}).Info("Starting sync operation")
+	}
+	if pair.Name != "" {
+		fields["mirror"] = pair.Name
+	}
+	logger.WithFields(fields).Info("Starting sync operation")
+
+	if syncDue {
+		due, err := dueImages(logger, syncConfig.Images, state, startedAt)
+		if err != nil {
+			return err
+		}
+		if len(due) == 0 {
+			fmt.Println("No image rules are due")
+			return nil
+		}
+		syncConfig.Images = due
+	}
+
+	// Rules another sync is still running are skipped or waited for
+	if !syncDryRun {
+		images, unlock, err := lockImageRules(ctx, logger, syncConfig.Images, statePath)
+		if err != nil {
+			return err
+		}
+		defer unlock()
+		if len(images) == 0 {
+			fmt.Println("No image rules to run; all are running in another sync")
+			return nil
+		}
+		syncConfig.Images = images
+	}
 
 	// Build list of sync tasks
-	syncTasks, err := buildSyncTasks(ctx, logger, syncConfig)
+	ruleCalls := apicalls.NewGroup()
+	syncTasks, unresolved, err := buildSyncTasks(ctx, logger, syncConfig, state, ruleCalls)
 	if err != nil {
 		return fmt.Errorf("failed to build sync tasks: %w", err)
 	}
 
 	if len(syncTasks) == 0 {
+		if state != nil && !syncDryRun {
+			saveSyncState(ctx, logger, state, syncConfig, nil, unresolved, startedAt)
+		}
 		fmt.Println("No images to sync")
 		return nil
 	}
@@ -134,49 +276,109 @@ func runSync(cmd *cobra.Command, args []string) error {
 	}).Info("Found images to sync")
 	fmt.Printf("Found %d images to sync\n\n", len(syncTasks))
 
-	// Display tasks if dry run
+	runReport := report.New("sync", syncConfig.Source.Registry, syncConfig.Destination.Registry)
+	runReport.DryRun = syncDryRun
+	for _, task := range syncTasks {
+		runReport.AddPlanned(syncTaskSource(task), syncTaskDestination(task))
+	}
+
+	// Display tasks if dry run, in the order their dependencies allow
 	if syncDryRun {
+		syncTasks, err = sync.OrderByDependencies(syncTasks)
+		if err != nil {
+			return err
+		}
+		runReport.RecordRuleAPICalls(ruleCalls)
 		fmt.Println("Dry run - would sync the following images:")
 		for _, task := range syncTasks {
-			fmt.Printf("  %s/%s:%s -> %s/%s:%s\n",
-				task.SourceRegistry, task.SourceRepository, task.SourceTag,
-				task.DestRegistry, task.DestRepository, task.DestTag)
+			if len(task.AliasTags) > 0 {
+				fmt.Printf("  %s -> %s (also %s)\n", syncTaskSource(task), syncTaskDestination(task), strings.Join(task.AliasTags, ", "))
+				continue
+			}
+			fmt.Printf("  %s -> %s\n", syncTaskSource(task), syncTaskDestination(task))
 		}
+		publishRunReport(ctx, logger, runReport, nil)
 		return nil
 	}
 
-	// Create client factory (use global cfg or create minimal one)
-	var factoryCfg *config.Config
-	if cfg != nil {
-		factoryCfg = cfg
-	} else {
-		// Create minimal config for factory if global config not loaded
-		factoryCfg = &config.Config{
-			Registries: config.RegistriesConfig{
-				Registries: []config.RegistryConfig{},
-			},
-		}
-	}
+	// Create client factory
+	factoryCfg := syncFactoryConfig()
 	factory := client.NewFactory(factoryCfg, logger)
 
 	// Execute sync tasks using batch executor with factory
-	executor := sync.NewBatchExecutorWithFactory(syncConfig, logger, factory)
+	retryBudget := resilience.NewRetryBudget(factoryCfg.Retry.Budget)
+	executor := sync.NewBatchExecutorWithFactory(syncConfig, logger, factory).
+		WithRetryBudget(retryBudget, factoryCfg.Retry.MaxRetries).
+		WithSignatureVerifier(syncSignatureVerifier(logger)).
+		WithRuleAPICalls(ruleCalls)
+	if cfg != nil {
+		executor.WithPullThroughCachePolicy(cfg.ECR.PullThroughCache)
+		executor.WithRunIDAnnotation(cfg.AnnotateRunID)
+		executor.WithRepositoryCreation(service.RepositoryCreationPolicy{
+			CreateMissingRepos: cfg.Replicate.CreateMissingRepos,
+			Template:           cfg.Replicate.RepositoryTemplate,
+		})
+		syncRepoMetadata = syncRepoMetadata || cfg.Replicate.CopyRepoMetadata
+	}
+	executor.WithRepoMetadata(syncRepoMetadata)
+	if cfg != nil && cfg.Attestation.Output != "" {
+		ledger := attestation.NewLedger()
+		executor.WithLedger(ledger)
+		runReport.AttachLedger(ledger)
+	}
+	if cfg != nil && cfg.Resign.KeyFile != "" {
+		signer, err := encryption.NewSigner(ctx, cfg.Resign.KeyFile, signerOptions(cfg))
+		if err != nil {
+			return fmt.Errorf("failed to load re-signing key: %w", err)
+		}
+		executor.WithResigner(signer)
+	}
+	evaluator, err := sync.NewPolicyEvaluator(syncConfig.Policy)
+	if err != nil {
+		return fmt.Errorf("failed to load policy: %w", err)
+	}
+	if evaluator != nil {
+		executor.WithPolicy(evaluator)
+	}
 	results, err := executor.Execute(ctx, syncTasks)
+	runReport.RecordRetryBudget(retryBudget)
+	runReport.RecordRuleAPICalls(ruleCalls)
 	if err != nil {
+		publishRunReport(ctx, logger, runReport, err)
 		return fmt.Errorf("batch execution failed: %w", err)
 	}
 
 	// Display results
 	displaySyncResults(results)
 
+	if state != nil {
+		saveSyncState(ctx, logger, state, syncConfig, results, unresolved, startedAt)
+	}
+
 	// Check for failures
 	failCount := 0
+	skipCount := 0
+	var totalBytes int64
 	for _, result := range results {
-		if !result.Success {
+		totalBytes += result.BytesCopied
+		if result.Skipped {
+			skipCount++
+			if result.SkipReason != "" {
+				runReport.AddSkip(syncTaskSource(result.Task), syncTaskDestination(result.Task), result.SkipReason)
+			}
+		} else if !result.Success {
 			failCount++
+			runReport.AddFailure(syncTaskSource(result.Task), syncTaskDestination(result.Task), result.Error)
 		}
 	}
 
+	runReport.SetSummary("images_total", int64(len(results)))
+	runReport.SetSummary("images_succeeded", int64(len(results)-failCount-skipCount))
+	runReport.SetSummary("images_failed", int64(failCount))
+	runReport.SetSummary("images_skipped", int64(skipCount))
+	runReport.SetSummary("bytes_copied", totalBytes)
+	publishRunReport(ctx, logger, runReport, nil)
+
 	if failCount > 0 {
 		return fmt.Errorf("sync failed for %d images", failCount)
 	}
@@ -184,49 +386,463 @@ func runSync(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
-// buildSyncTasks builds a list of sync tasks from the configuration
-func buildSyncTasks(ctx context.Context, logger log.Logger, config *sync.Config) ([]sync.SyncTask, error) {
+// syncFactoryConfig returns the global config for client factories, or a
+// minimal one when the global config is not loaded
+func syncFactoryConfig() *config.Config {
+	if cfg != nil {
+		return cfg
+	}
+	return &config.Config{
+		Registries: config.RegistriesConfig{
+			Registries: []config.RegistryConfig{},
+		},
+	}
+}
+
+// resolveRegistryAlias replaces a registry named in the registries config with
+// its host and fills in the type, region, project, account and media types
+// left unset. Media types also carry over when the registry is given by host.
+func resolveRegistryAlias(reg *sync.RegistryConfig) error {
+	named, ok := syncFactoryConfig().Registries.Lookup(reg.Registry)
+	if !ok {
+		return nil
+	}
+	if reg.MediaTypes == nil && (len(named.MediaTypes.Allow) > 0 || len(named.MediaTypes.Deny) > 0) {
+		reg.MediaTypes = &copyutil.MediaTypePolicy{Allow: named.MediaTypes.Allow, Deny: named.MediaTypes.Deny}
+	}
+	if named.Name != reg.Registry {
+		return nil
+	}
+
+	host, err := named.GetRegistryHost()
+	if err != nil {
+		return fmt.Errorf("registry %s: %w", named.Name, err)
+	}
+	reg.Registry = host
+	if reg.Type == "" {
+		reg.Type = string(named.Type)
+	}
+	if reg.Region == "" {
+		reg.Region = named.Region
+	}
+	if reg.Project == "" {
+		reg.Project = named.Project
+	}
+	if reg.Account == "" {
+		reg.Account = named.AccountID
+	}
+	reg.Insecure = reg.Insecure || named.Insecure
+	return nil
+}
+
+// syncTaskSource formats the source image reference of a sync task
+func syncTaskSource(task sync.SyncTask) string {
+	return fmt.Sprintf("%s/%s:%s", task.SourceRegistry, task.SourceRepository, task.SourceTag)
+}
+
+// syncTaskDestination formats the destination image reference of a sync task
+func syncTaskDestination(task sync.SyncTask) string {
+	return fmt.Sprintf("%s/%s:%s", task.DestRegistry, task.DestRepository, task.DestTag)
+}
+
+// buildSyncTasks builds a list of sync tasks from the configuration. With a
+// state, tags whose digest has not changed since they were last synced are
+// left out with --since-last-success and for digest_change_only rules, and
+// digest_change_only sources with an unchanged tag list are not read at all.
+// It also returns the rules whose tags could not all be resolved. The
+// registry API calls of each rule are counted in ruleCalls.
+func buildSyncTasks(ctx context.Context, logger log.Logger, config *sync.Config, state *sync.State, ruleCalls *apicalls.Group) ([]sync.SyncTask, map[string]bool, error) {
 	var tasks []sync.SyncTask
+	unresolved := make(map[string]bool)
 
 	for _, imageSync := range config.Images {
-		// Resolve tags using the appropriate filter
-		tags, err := resolveTags(ctx, logger, &config.Source, imageSync)
+		rule := sync.RuleKey(imageSync)
+		ctx := apicalls.WithCounter(ctx, ruleCalls.Counter(rule))
+
+		// Digests of earlier runs only skip tags with --since-last-success
+		// or for digest_change_only rules
+		changes := state
+		if !syncSinceLastSuccess && !imageSync.DigestChangeOnly {
+			changes = nil
+		}
+
+		sources, err := imageSources(ctx, logger, &config.Source, imageSync)
 		if err != nil {
 			logger.WithFields(map[string]interface{}{
 				"repository": imageSync.Repository,
-			}).Error("Failed to resolve tags", err)
+			}).Error("Failed to resolve source regions", err)
+			unresolved[rule] = true
 			continue
 		}
 
-		// Apply limit if specified
-		if imageSync.LatestN > 0 && len(tags) > imageSync.LatestN {
-			tags = tags[:imageSync.LatestN]
+		// A tag present in several regions is copied from the first one
+		seen := make(map[string]bool)
+		for i := range sources {
+			source := &sources[i]
+
+			if imageSync.DigestChangeOnly && state != nil && tagListUnchanged(ctx, logger, source, imageSync, state) {
+				continue
+			}
+
+			// Resolve tags using the appropriate filter
+			tags, err := resolveTags(ctx, logger, source, imageSync)
+			if err != nil {
+				logger.WithFields(map[string]interface{}{
+					"repository": imageSync.Repository,
+					"registry":   source.Registry,
+				}).Error("Failed to resolve tags", err)
+				unresolved[rule] = true
+				continue
+			}
+
+			// Apply limit if specified
+			if imageSync.LatestN > 0 && len(tags) > imageSync.LatestN {
+				tags = tags[:imageSync.LatestN]
+			}
+
+			// Aliases are worked out before unchanged tags are dropped, so a
+			// new release still takes over its line's aliases
+			aliases := sync.SemverAliases(tags, imageSync.AliasTags)
+
+			var digests map[string]string
+			if changes != nil {
+				tags, digests, err = changedTags(ctx, logger, source, imageSync, changes, tags)
+				if err != nil {
+					logger.WithFields(map[string]interface{}{
+						"repository": imageSync.Repository,
+						"registry":   source.Registry,
+					}).Error("Failed to look up tag digests", err)
+					unresolved[rule] = true
+					continue
+				}
+			}
+
+			// Create sync tasks
+			for _, tag := range tags {
+				if seen[tag] {
+					continue
+				}
+				seen[tag] = true
+
+				destRepo := imageSync.Repository
+				if imageSync.DestinationRepository != "" {
+					destRepo = imageSync.DestinationRepository
+				}
+
+				destTag := tag
+				if imageSync.DestinationPrefix != "" {
+					destTag = imageSync.DestinationPrefix + tag
+				}
+
+				var aliasTags []string
+				for _, alias := range aliases[tag] {
+					aliasTags = append(aliasTags, imageSync.DestinationPrefix+alias)
+				}
+
+				tasks = append(tasks, sync.SyncTask{
+					SourceRegistry:   source.Registry,
+					SourceRepository: imageSync.Repository,
+					SourceTag:        tag,
+					DestRegistry:     config.Destination.Registry,
+					DestRepository:   destRepo,
+					DestTag:          destTag,
+					SignVerification: imageSync.SignVerification,
+					Rule:             rule,
+					SourceDigest:     digests[tag],
+					AliasTags:        aliasTags,
+					DependsOn:        imageSync.DependsOn,
+					TagHistory:       imageSync.TagHistory,
+					Transforms:       imageSync.Transform,
+					Profile:          imageSync.Profile,
+
+					CreateMissingRepos: imageSync.CreateMissingRepos,
+					RepositoryTemplate: imageSync.RepositoryTemplate,
+				})
+			}
 		}
+	}
+
+	return tasks, unresolved, nil
+}
+
+// changedTags returns the tags whose source digest differs from the one
+// recorded in state, with the digests looked up. Tags whose digest cannot be
+// determined are kept.
+func changedTags(ctx context.Context, logger log.Logger, source *sync.RegistryConfig, imageSync sync.ImageSync, state *sync.State, tags []string) ([]string, map[string]string, error) {
+	repo, err := sourceRepository(ctx, logger, source, imageSync.Repository)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	digests := tagDigests(ctx, logger, repo, tags)
+
+	rule := sync.RuleKey(imageSync)
+	changed := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		if state.Changed(rule, tag, digests[tag]) {
+			changed = append(changed, tag)
+		}
+	}
+
+	fields := map[string]interface{}{
+		"repository": imageSync.Repository,
+		"registry":   source.Registry,
+		"changed":    len(changed),
+		"unchanged":  len(tags) - len(changed),
+	}
+	if last := state.LastSuccess(rule); !last.IsZero() {
+		fields["lastSuccess"] = last.Format(time.RFC3339)
+	}
+	logger.WithFields(fields).Info("Skipping tags unchanged since last success")
+
+	return changed, digests, nil
+}
 
-		// Create sync tasks
-		for _, tag := range tags {
-			destRepo := imageSync.Repository
-			if imageSync.DestinationRepository != "" {
-				destRepo = imageSync.DestinationRepository
+// tagListUnchanged reports whether source still reports the tag list ETag
+// recorded when the rule last synced, in which case none of its tags can need
+// syncing. A new ETag is recorded in state for the rule's next run; registries
+// without conditional tag listing are always treated as changed.
+func tagListUnchanged(ctx context.Context, logger log.Logger, source *sync.RegistryConfig, imageSync sync.ImageSync, state *sync.State) bool {
+	repo, err := sourceRepository(ctx, logger, source, imageSync.Repository)
+	if err != nil {
+		return false
+	}
+	versioner, ok := repo.(interfaces.TagListVersioner)
+	if !ok {
+		return false
+	}
+
+	rule := sync.RuleKey(imageSync)
+	etag, changed, err := versioner.TagListETag(ctx, state.TagListETag(rule, source.Registry))
+	if err != nil {
+		logger.WithFields(map[string]interface{}{
+			"repository": imageSync.Repository,
+			"registry":   source.Registry,
+			"error":      err.Error(),
+		}).Debug("Failed to check the tag list ETag, comparing digests instead")
+		return false
+	}
+	if changed {
+		state.RecordTagListETag(rule, source.Registry, etag)
+		return false
+	}
+
+	logger.WithFields(map[string]interface{}{
+		"repository": imageSync.Repository,
+		"registry":   source.Registry,
+	}).Info("Skipping repository whose tag list is unchanged since last sync")
+	return true
+}
+
+// hasDigestChangeOnlyRules reports whether any image rule is digest_change_only
+func hasDigestChangeOnlyRules(images []sync.ImageSync) bool {
+	for _, imageSync := range images {
+		if imageSync.DigestChangeOnly {
+			return true
+		}
+	}
+	return false
+}
+
+// tagDigests looks up the source digest of each tag, from a single metadata
+// listing when the registry offers one and with a manifest HEAD per tag
+// otherwise
+func tagDigests(ctx context.Context, logger log.Logger, repo interfaces.Repository, tags []string) map[string]string {
+	digests := make(map[string]string, len(tags))
+
+	if lister, ok := repo.(interfaces.TagDigestLister); ok {
+		listed, err := lister.ListTagDigests(ctx)
+		if err == nil {
+			for _, td := range listed {
+				digests[td.Tag] = td.Digest
 			}
+			return digests
+		}
+		logger.WithFields(map[string]interface{}{
+			"repository": repo.GetRepositoryName(),
+			"error":      err.Error(),
+		}).Warn("Failed to list tag digests, falling back to manifest requests")
+	}
+
+	opts, err := repo.GetRemoteOptions()
+	if err != nil {
+		return digests
+	}
+	for _, tag := range tags {
+		ref, err := repo.GetImageReference(tag)
+		if err != nil {
+			continue
+		}
+		desc, err := remote.Head(ref, append(opts, remote.WithContext(ctx))...)
+		if err != nil {
+			logger.WithFields(map[string]interface{}{
+				"repository": repo.GetRepositoryName(),
+				"tag":        tag,
+				"error":      err.Error(),
+			}).Debug("Failed to look up tag digest")
+			continue
+		}
+		digests[tag] = desc.Digest.String()
+	}
+	return digests
+}
 
-			destTag := tag
-			if imageSync.DestinationPrefix != "" {
-				destTag = imageSync.DestinationPrefix + tag
+// signerOptions reaches KMS-held signing keys with the registry credentials in cfg
+func signerOptions(cfg *config.Config) encryption.SignerOptions {
+	return encryption.SignerOptions{
+		AWS: encryption.AWSOpts{
+			Region:  cfg.ECR.Region,
+			Profile: cfg.ECR.Profile,
+			RoleARN: cfg.ECR.RoleARN,
+		},
+		GCPCredentialsFile: cfg.GCR.CredentialsFile,
+	}
+}
+
+// saveSyncState records the digests copied by successful tasks and the start
+// time of the run for every rule with no failures, then writes the state. The
+// tag list ETags of rules with failures are dropped so their next run retries.
+// Rules this run did not include keep whatever other syncs saved for them.
+func saveSyncState(ctx context.Context, logger log.Logger, state *sync.State, config *sync.Config, results []sync.SyncResult, unresolved map[string]bool, startedAt time.Time) {
+	failed := make(map[string]bool)
+	for rule := range unresolved {
+		failed[rule] = true
+	}
+
+	for _, result := range results {
+		if result.Skipped {
+			continue
+		}
+		if !result.Success {
+			failed[result.Task.Rule] = true
+			continue
+		}
+		state.RecordTag(result.Task.Rule, result.Task.SourceTag, result.Task.SourceDigest)
+	}
+
+	rules := make([]string, 0, len(config.Images))
+	for _, imageSync := range config.Images {
+		rule := sync.RuleKey(imageSync)
+		rules = append(rules, rule)
+		state.MarkRun(rule, startedAt)
+		if failed[rule] {
+			state.ClearTagListETags(rule)
+			continue
+		}
+		state.MarkSuccess(rule, startedAt)
+	}
+
+	if err := state.SaveRules(ctx, rules); err != nil {
+		logger.WithFields(map[string]interface{}{
+			"error": err.Error(),
+		}).Warn("Failed to save sync state; the next run will re-check all tags and run every scheduled rule")
+	}
+}
+
+// lockImageRules takes the lock of every guarded image rule, next to the
+// state file. Rules another sync holds are left out under the skip overlap
+// policy and waited for under queue. It returns the rules to run and a
+// function releasing the locks.
+func lockImageRules(ctx context.Context, logger log.Logger, images []sync.ImageSync, statePath string) ([]sync.ImageSync, func(), error) {
+	var locks []*sync.FileLock
+	unlock := func() {
+		for _, lock := range locks {
+			if err := lock.Unlock(); err != nil {
+				logger.WithFields(map[string]interface{}{
+					"error": err.Error(),
+				}).Warn("Failed to release image rule lock")
 			}
+		}
+	}
 
-			tasks = append(tasks, sync.SyncTask{
-				SourceRegistry:   config.Source.Registry,
-				SourceRepository: imageSync.Repository,
-				SourceTag:        tag,
-				DestRegistry:     config.Destination.Registry,
-				DestRepository:   destRepo,
-				DestTag:          destTag,
-			})
+	run := make([]sync.ImageSync, 0, len(images))
+	for _, imageSync := range images {
+		if !imageSync.Guarded() {
+			run = append(run, imageSync)
+			continue
+		}
+
+		rule := sync.RuleKey(imageSync)
+		lock, err := sync.LockFile(ctx, sync.RuleLockPath(statePath, rule), imageSync.Overlap)
+		if errors.Is(err, sync.ErrRuleLocked) {
+			logger.WithFields(map[string]interface{}{
+				"rule": rule,
+			}).Warn("Skipping image rule that another sync is still running")
+			continue
+		}
+		if err != nil {
+			unlock()
+			return nil, nil, fmt.Errorf("image rule %s: %w", rule, err)
 		}
+		locks = append(locks, lock)
+		run = append(run, imageSync)
 	}
+	return run, unlock, nil
+}
 
-	return tasks, nil
+// dueImages returns the image rules whose schedule has come due since they
+// last ran, logging when the others are next due
+func dueImages(logger log.Logger, images []sync.ImageSync, state *sync.State, now time.Time) ([]sync.ImageSync, error) {
+	due := make([]sync.ImageSync, 0, len(images))
+	for _, imageSync := range images {
+		rule := sync.RuleKey(imageSync)
+		ok, next, err := imageSync.Due(state.LastRun(rule), now)
+		if err != nil {
+			return nil, fmt.Errorf("image rule %s: %w", rule, err)
+		}
+		if ok {
+			due = append(due, imageSync)
+			continue
+		}
+		logger.WithFields(map[string]interface{}{
+			"rule":     rule,
+			"schedule": imageSync.Schedule,
+			"next_run": next.Format(time.RFC3339),
+		}).Info("Skipping image rule that is not due")
+	}
+	return due, nil
+}
+
+// imageSources returns the source registries an image is read from. ECR images
+// with regions or discover_regions fan out to one regional registry per region.
+func imageSources(ctx context.Context, logger log.Logger, source *sync.RegistryConfig, imageSync sync.ImageSync) ([]sync.RegistryConfig, error) {
+	if len(imageSync.Regions) == 0 && !imageSync.DiscoverRegions {
+		return []sync.RegistryConfig{*source}, nil
+	}
+
+	accountID := source.Account
+	if accountID == "" {
+		accountID, _, _ = ecr.ParseRegistryHost(source.Registry)
+	}
+	if accountID == "" {
+		return nil, fmt.Errorf("source.account or a regional ECR registry is required for multi-region images")
+	}
+
+	regions := imageSync.Regions
+	if imageSync.DiscoverRegions {
+		opts := ecr.ClientOptions{AccountID: accountID, Logger: logger}
+		if source.Auth != nil {
+			opts.Profile = source.Auth.AWSProfile
+		}
+
+		var err error
+		regions, err = ecr.DiscoverRegions(ctx, opts, imageSync.Repository, imageSync.Regions)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sources := make([]sync.RegistryConfig, 0, len(regions))
+	for _, region := range regions {
+		regional := *source
+		regional.Type = "ecr"
+		regional.Registry = ecr.RegistryHost(accountID, region)
+		regional.Region = region
+		regional.Account = accountID
+		sources = append(sources, regional)
+	}
+
+	return sources, nil
 }
 
 // resolveTags resolves the list of tags to sync based on the ImageSync configuration
@@ -235,29 +851,24 @@ func resolveTags(ctx context.Context, logger log.Logger, source *sync.RegistryCo
 		"repository": imageSync.Repository,
 	}).Info("Resolving tags")
 
+	explain := cfg != nil && cfg.ExplainFilters
+
 	// If specific tags are listed, return them
 	if len(imageSync.Tags) > 0 {
+		if explain {
+			for _, tag := range imageSync.Tags {
+				logFilterDecision(logger, imageSync.Repository, sync.FilterDecision{
+					Tag: tag, Included: true, Rule: "tags", Reason: "tag is listed",
+				})
+			}
+		}
 		return imageSync.Tags, nil
 	}
 
 	// Otherwise, we need to list tags from the registry
-	// Convert sync.RegistryConfig to config.RegistryConfig
-	registryConfig := convertToConfigRegistryConfig(source)
-
-	// Create a generic client
-	client, err := generic.NewClient(generic.ClientOptions{
-		RegistryConfig: registryConfig,
-		RegistryName:   source.Registry,
-		Logger:         logger,
-	})
-	if err != nil {
-		return nil, fmt.Errorf("failed to create registry client: %w", err)
-	}
-
-	// Get repository
-	repo, err := client.GetRepository(ctx, imageSync.Repository)
+	repo, err := sourceRepository(ctx, logger, source, imageSync.Repository)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get repository: %w", err)
+		return nil, err
 	}
 
 	// List all tags
@@ -277,6 +888,14 @@ func resolveTags(ctx context.Context, logger log.Logger, source *sync.RegistryCo
 		return nil, fmt.Errorf("failed to create tag filter: %w", err)
 	}
 
+	if explain {
+		for _, decision := range filter.Explain(allTags) {
+			if !decision.Included {
+				logFilterDecision(logger, imageSync.Repository, decision)
+			}
+		}
+	}
+
 	// Filter tags
 	filteredTags := filter.Filter(allTags)
 
@@ -288,6 +907,13 @@ func resolveTags(ctx context.Context, logger log.Logger, source *sync.RegistryCo
 
 	// Apply limit if specified
 	if imageSync.Limit > 0 {
+		if explain {
+			for _, decision := range sync.ExplainLimit(filteredTags, imageSync.Limit) {
+				if !decision.Included {
+					logFilterDecision(logger, imageSync.Repository, decision)
+				}
+			}
+		}
 		filteredTags = sync.ApplyLimit(filteredTags, imageSync.Limit)
 		logger.WithFields(map[string]interface{}{
 			"repository": imageSync.Repository,
@@ -296,9 +922,65 @@ func resolveTags(ctx context.Context, logger log.Logger, source *sync.RegistryCo
 		}).Info("Applied tag limit")
 	}
 
+	if explain {
+		for _, decision := range filter.Explain(filteredTags) {
+			logFilterDecision(logger, imageSync.Repository, decision)
+		}
+	}
+
 	return filteredTags, nil
 }
 
+// sourceRepository opens repository on the source registry
+func sourceRepository(ctx context.Context, logger log.Logger, source *sync.RegistryConfig, repository string) (interfaces.Repository, error) {
+	// Convert sync.RegistryConfig to config.RegistryConfig
+	registryConfig := convertToConfigRegistryConfig(source)
+
+	// ECR is listed through the AWS API so IAM credentials apply; other
+	// registries use a generic client
+	var registryClient interfaces.RegistryClient
+	var err error
+	if named, ok := syncFactoryConfig().Registries.Lookup(source.Registry); ok {
+		factory := client.NewFactory(syncFactoryConfig(), logger)
+		registryClient, err = factory.CreateClientFromConfig(*named, named.Name)
+	} else if source.Type == "ecr" && source.Region != "" {
+		factory := client.NewFactory(syncFactoryConfig(), logger)
+		registryClient, err = factory.CreateECRClientForRegion(source.Region, source.Account)
+	} else {
+		registryClient, err = generic.NewClient(generic.ClientOptions{
+			RegistryConfig: registryConfig,
+			RegistryName:   source.Registry,
+			Logger:         logger,
+		})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create registry client: %w", err)
+	}
+
+	// Get repository
+	repo, err := registryClient.GetRepository(ctx, repository)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get repository: %w", err)
+	}
+	return repo, nil
+}
+
+// logFilterDecision logs why a tag was included or excluded, for
+// --explain-filters
+func logFilterDecision(logger log.Logger, repository string, decision sync.FilterDecision) {
+	outcome := "excluded"
+	if decision.Included {
+		outcome = "included"
+	}
+	logger.WithFields(map[string]interface{}{
+		"repository": repository,
+		"tag":        decision.Tag,
+		"decision":   outcome,
+		"rule":       decision.Rule,
+		"reason":     decision.Reason,
+	}).Info("Filter decision")
+}
+
 // convertToConfigRegistryConfig converts sync.RegistryConfig to config.RegistryConfig
 func convertToConfigRegistryConfig(src *sync.RegistryConfig) config.RegistryConfig {
 	cfg := config.RegistryConfig{
@@ -376,15 +1058,19 @@ func mapRegistryType(typeStr string) config.RegistryType {
 func displaySyncResults(results []sync.SyncResult) {
 	successCount := 0
 	failCount := 0
+	skipCount := 0
 	var totalDuration int64
 	var totalBytes int64
 
 	for _, result := range results {
 		totalDuration += result.Duration
 		totalBytes += result.BytesCopied
-		if result.Success {
+		switch {
+		case result.Success:
 			successCount++
-		} else {
+		case result.Skipped:
+			skipCount++
+		default:
 			failCount++
 		}
 	}
@@ -393,15 +1079,18 @@ func displaySyncResults(results []sync.SyncResult) {
 	fmt.Printf("  Total: %d\n", len(results))
 	fmt.Printf("  Success: %d\n", successCount)
 	fmt.Printf("  Failed: %d\n", failCount)
+	if skipCount > 0 {
+		fmt.Printf("  Skipped: %d\n", skipCount)
+	}
 	fmt.Printf("  Total Duration: %s\n", time.Duration(totalDuration)*time.Millisecond)
 	fmt.Printf("  Total Bytes: %s\n", formatBytes(totalBytes))
 
 	if failCount > 0 {
 		fmt.Println("\nFailed syncs:")
 		for _, result := range results {
-			if !result.Success {
-				srcRef := fmt.Sprintf("%s/%s:%s", result.Task.SourceRegistry, result.Task.SourceRepository, result.Task.SourceTag)
-				dstRef := fmt.Sprintf("%s/%s:%s", result.Task.DestRegistry, result.Task.DestRepository, result.Task.DestTag)
+			if !result.Success && !result.Skipped {
+				srcRef := syncTaskSource(result.Task)
+				dstRef := syncTaskDestination(result.Task)
 				errMsg := "unknown error"
 				if result.Error != nil {
 					errMsg = result.Error.Error()
@@ -0,0 +1,193 @@
+package cmd
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	stdlog "log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"freightliner/pkg/config"
+	"freightliner/pkg/sync"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// etagRegistry serves tag lists with an ETag and answers If-None-Match,
+// counting the manifest requests made for the source repository
+type etagRegistry struct {
+	next      http.Handler
+	manifests atomic.Int32
+}
+
+func (e *etagRegistry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if strings.HasPrefix(r.URL.Path, "/v2/src/app/manifests/") {
+		e.manifests.Add(1)
+	}
+	if r.Method != http.MethodGet || !strings.HasSuffix(r.URL.Path, "/tags/list") {
+		e.next.ServeHTTP(w, r)
+		return
+	}
+
+	rec := httptest.NewRecorder()
+	e.next.ServeHTTP(rec, r)
+	etag := fmt.Sprintf(`"%x"`, sha256.Sum256(rec.Body.Bytes()))
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	for k, v := range rec.Header() {
+		w.Header()[k] = v
+	}
+	w.Header().Set("ETag", etag)
+	w.WriteHeader(rec.Code)
+	_, _ = w.Write(rec.Body.Bytes())
+}
+
+func TestRunSyncDigestChangeOnly(t *testing.T) {
+	handler := &etagRegistry{next: registry.New(registry.Logger(stdlog.New(io.Discard, "", 0)))}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	src, err := name.NewRepository(u.Host + "/src/app")
+	require.NoError(t, err)
+	dst, err := name.NewRepository(u.Host + "/mirror/app")
+	require.NoError(t, err)
+
+	push := func(tag string) {
+		img, err := random.Image(256, 1)
+		require.NoError(t, err)
+		require.NoError(t, remote.Write(src.Tag(tag), img))
+	}
+	push("v1")
+
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "sync.yaml")
+	require.NoError(t, os.WriteFile(configFile, []byte(fmt.Sprintf(`source:
+  registry: %[1]s
+  type: generic
+destination:
+  registry: %[1]s
+  type: generic
+images:
+  - repository: src/app
+    all_tags: true
+    destination_repository: mirror/app
+    digest_change_only: true
+`, u.Host)), 0o600))
+
+	originalCfg := cfg
+	cfg = config.NewDefaultConfig()
+	defer func() { cfg = originalCfg }()
+
+	run := func() {
+		cmd := newSyncCmd()
+		require.NoError(t, cmd.ParseFlags([]string{"--config", configFile}))
+		require.NoError(t, runSync(cmd, nil))
+	}
+	loadState := func() *sync.RuleState {
+		state, err := sync.LoadState(sync.DefaultStatePath(configFile))
+		require.NoError(t, err)
+		rule := state.Rules[sync.RuleKey(sync.ImageSync{Repository: "src/app", DestinationRepository: "mirror/app"})]
+		require.NotNil(t, rule)
+		return rule
+	}
+
+	// The first run copies the tag and records its digest and the tag list ETag
+	run()
+	_, err = remote.Head(dst.Tag("v1"))
+	require.NoError(t, err)
+	rule := loadState()
+	assert.Contains(t, rule.Digests, "v1")
+	assert.NotEmpty(t, rule.TagListETags[u.Host])
+
+	// With the tag list unchanged the source's manifests are not requested
+	handler.manifests.Store(0)
+	run()
+	assert.Zero(t, handler.manifests.Load())
+
+	// A new tag changes the ETag, and only the new tag is copied
+	push("v2")
+	previous := rule.TagListETags[u.Host]
+	run()
+	_, err = remote.Head(dst.Tag("v2"))
+	require.NoError(t, err)
+	rule = loadState()
+	assert.Contains(t, rule.Digests, "v2")
+	assert.NotEqual(t, previous, rule.TagListETags[u.Host])
+}
+
+func TestRunSyncSkipsRuleRunningElsewhere(t *testing.T) {
+	server := httptest.NewServer(registry.New(registry.Logger(stdlog.New(io.Discard, "", 0))))
+	defer server.Close()
+
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	src, err := name.NewRepository(u.Host + "/src/app")
+	require.NoError(t, err)
+	dst, err := name.NewRepository(u.Host + "/mirror/app")
+	require.NoError(t, err)
+
+	img, err := random.Image(256, 1)
+	require.NoError(t, err)
+	require.NoError(t, remote.Write(src.Tag("v1"), img))
+
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "sync.yaml")
+	require.NoError(t, os.WriteFile(configFile, []byte(fmt.Sprintf(`source:
+  registry: %[1]s
+  type: generic
+destination:
+  registry: %[1]s
+  type: generic
+images:
+  - repository: src/app
+    tags: ["v1"]
+    destination_repository: mirror/app
+    schedule: "0 */5 * * * *"
+`, u.Host)), 0o600))
+
+	originalCfg := cfg
+	cfg = config.NewDefaultConfig()
+	defer func() { cfg = originalCfg }()
+
+	run := func() {
+		cmd := newSyncCmd()
+		require.NoError(t, cmd.ParseFlags([]string{"--config", configFile, "--due"}))
+		require.NoError(t, runSync(cmd, nil))
+	}
+
+	// Another sync still running the rule holds its lock
+	statePath := sync.DefaultStatePath(configFile)
+	rule := sync.RuleKey(sync.ImageSync{Repository: "src/app", DestinationRepository: "mirror/app"})
+	lock, err := sync.LockFile(context.Background(), sync.RuleLockPath(statePath, rule), "")
+	require.NoError(t, err)
+
+	run()
+	_, err = remote.Head(dst.Tag("v1"))
+	assert.Error(t, err, "a rule running elsewhere is skipped")
+	state, err := sync.LoadState(statePath)
+	require.NoError(t, err)
+	assert.True(t, state.LastRun(rule).IsZero(), "a skipped rule stays due")
+
+	// Once the other sync is done the rule runs
+	require.NoError(t, lock.Unlock())
+	run()
+	_, err = remote.Head(dst.Tag("v1"))
+	assert.NoError(t, err)
+}
@@ -0,0 +1,64 @@
+//go:build cosign
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"freightliner/pkg/helper/log"
+	"freightliner/pkg/security/cosign"
+	"freightliner/pkg/sync"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// syncSignatureVerifier verifies source images with cosign, including Rekor
+// inclusion proofs when a rule requires them. Certificates checked at a
+// trusted time instead of the system clock are logged to logger.
+func syncSignatureVerifier(logger log.Logger) sync.SignatureVerifier {
+	return func(ctx context.Context, ref name.Reference, config *sync.SignatureConfig, opts []remote.Option) error {
+		var verifyTime time.Time
+		if config.VerifyTime != "" && config.VerifyTime != sync.VerifyTimeRekor {
+			t, err := time.Parse(time.RFC3339, config.VerifyTime)
+			if err != nil {
+				return fmt.Errorf("invalid verify_time: %w", err)
+			}
+			verifyTime = t
+		}
+
+		policy := cosign.NewPolicy()
+		if config.Issuer != "" {
+			policy.AllowedIssuers = []string{config.Issuer}
+		}
+		if config.CertificateIdentity != "" {
+			policy.AllowedSigners = []cosign.SignerIdentity{
+				{Email: config.CertificateIdentity},
+				{URI: config.CertificateIdentity},
+			}
+		}
+
+		verifier, err := cosign.NewVerifier(&cosign.VerifierConfig{
+			PublicKeyPath:          config.PublicKey,
+			EnableKeyless:          config.KeylessVerification,
+			RekorURL:               config.RekorURL,
+			RequireInclusionProof:  config.RequireRekorInclusion,
+			RekorBundlePath:        config.RekorBundle,
+			RekorPublicKeyPath:     config.RekorPublicKey,
+			VerifyTime:             verifyTime,
+			UseIntegratedTime:      config.VerifyTime == sync.VerifyTimeRekor,
+			InsecureIgnoreCertTime: config.InsecureIgnoreCertTime,
+			Logger:                 logger,
+			Policy:                 policy,
+			RemoteOpts:             opts,
+		})
+		if err != nil {
+			return err
+		}
+
+		_, err = verifier.Verify(ctx, ref)
+		return err
+	}
+}
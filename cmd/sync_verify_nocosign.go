@@ -0,0 +1,14 @@
+//go:build !cosign
+
+package cmd
+
+import (
+	"freightliner/pkg/helper/log"
+	"freightliner/pkg/sync"
+)
+
+// syncSignatureVerifier returns nil: signature verification needs a build
+// with -tags cosign, so rules with sign_verification enabled fail
+func syncSignatureVerifier(logger log.Logger) sync.SignatureVerifier {
+	return nil
+}
@@ -0,0 +1,163 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"freightliner/pkg/client"
+	"freightliner/pkg/helper/log"
+	"freightliner/pkg/helper/util"
+	"freightliner/pkg/interfaces"
+
+	"github.com/spf13/cobra"
+)
+
+var tagDryRun bool
+
+// validTagPattern matches tags accepted by the OCI distribution spec
+var validTagPattern = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.-]{0,127}$`)
+
+// newTagCmd creates the tag command
+func newTagCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "tag SOURCE NEW_TAG [NEW_TAG...]",
+		Short: "Add tags to an existing image without copying blobs",
+		Long: `Creates new tags pointing at an existing image by re-pushing its manifest
+under each new tag. No layers are transferred, so retagging is cheap even for
+large images.
+
+SOURCE format: REGISTRY/REPOSITORY:TAG or REGISTRY/REPOSITORY@DIGEST
+
+The "ecr" and "gcr" registry shorthands select the configured ECR account and
+GCR project.`,
+		Example: `  # Promote a release candidate to stable in ECR
+  freightliner tag ecr/team/app:rc-5 stable
+
+  # Add several tags to a digest in GCR
+  freightliner tag gcr.io/my-project/app@sha256:abc123... v1.4.0 latest
+
+  # Show what would be tagged
+  freightliner tag --dry-run ghcr.io/owner/app:rc-5 stable`,
+		Args: cobra.MinimumNArgs(2),
+		RunE: runTag,
+	}
+
+	cmd.Flags().BoolVar(&tagDryRun, "dry-run", false, "Show what would be tagged without pushing")
+
+	return cmd
+}
+
+// runTag executes the tag command
+func runTag(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+	logger, ctx, cancel := setupCommand(ctx)
+	defer cancel()
+
+	registry, repoName, reference, err := parseTagSource(args[0])
+	if err != nil {
+		return err
+	}
+
+	newTags := args[1:]
+	for _, tag := range newTags {
+		if !validTagPattern.MatchString(tag) {
+			return fmt.Errorf("invalid tag %q", tag)
+		}
+	}
+
+	factory := client.NewFactory(cfg, logger)
+	registryClient, err := factory.CreateClientForRegistry(ctx, registry)
+	if err != nil {
+		return fmt.Errorf("failed to create client for registry %s: %w", registry, err)
+	}
+
+	repo, err := registryClient.GetRepository(ctx, repoName)
+	if err != nil {
+		return fmt.Errorf("failed to get repository %s: %w", repoName, err)
+	}
+
+	digest, err := retagImage(ctx, logger, repo, reference, newTags, tagDryRun)
+	if err != nil {
+		return err
+	}
+
+	for _, tag := range newTags {
+		if tagDryRun {
+			fmt.Printf("Would tag %s/%s:%s -> %s\n", registry, repoName, tag, digest)
+		} else {
+			fmt.Printf("Tagged %s/%s:%s -> %s\n", registry, repoName, tag, digest)
+		}
+	}
+
+	return nil
+}
+
+// retagImage points each new tag at the manifest identified by reference and
+// returns the manifest digest
+func retagImage(
+	ctx context.Context,
+	logger log.Logger,
+	repo interfaces.ManifestManager,
+	reference string,
+	newTags []string,
+	dryRun bool,
+) (string, error) {
+	manifest, err := repo.GetManifest(ctx, reference)
+	if err != nil {
+		return "", fmt.Errorf("failed to get manifest for %s: %w", reference, err)
+	}
+
+	for _, tag := range newTags {
+		logger.WithFields(map[string]interface{}{
+			"source":  reference,
+			"tag":     tag,
+			"digest":  manifest.Digest,
+			"dry_run": dryRun,
+		}).Info("Tagging image")
+
+		if dryRun {
+			continue
+		}
+
+		if err := repo.PutManifest(ctx, tag, manifest); err != nil {
+			return manifest.Digest, fmt.Errorf("failed to tag %s: %w", tag, err)
+		}
+	}
+
+	return manifest.Digest, nil
+}
+
+// parseTagSource splits REGISTRY/REPOSITORY:TAG or REGISTRY/REPOSITORY@DIGEST
+func parseTagSource(source string) (registry, repoName, reference string, err error) {
+	parts := strings.SplitN(source, "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", "", fmt.Errorf("invalid source %q: use REGISTRY/REPOSITORY:TAG or REGISTRY/REPOSITORY@DIGEST", source)
+	}
+	registry, repoName = parts[0], parts[1]
+
+	if idx := strings.Index(repoName, "@"); idx >= 0 {
+		reference = repoName[idx+1:]
+		repoName = repoName[:idx]
+		if !util.IsDigest(reference) {
+			return "", "", "", fmt.Errorf("invalid digest %q", reference)
+		}
+		// A tag alongside the digest is informational only
+		if idx := strings.LastIndex(repoName, ":"); idx >= 0 {
+			repoName = repoName[:idx]
+		}
+	} else if idx := strings.LastIndex(repoName, ":"); idx >= 0 {
+		reference = repoName[idx+1:]
+		repoName = repoName[:idx]
+	}
+
+	if reference == "" {
+		return "", "", "", fmt.Errorf("source %q must include a tag or digest", source)
+	}
+	if repoName == "" {
+		return "", "", "", fmt.Errorf("source %q has no repository", source)
+	}
+
+	return registry, repoName, reference, nil
+}
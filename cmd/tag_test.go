@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"freightliner/pkg/helper/log"
+	"freightliner/pkg/interfaces"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeManifestRepo struct {
+	manifests map[string]*interfaces.Manifest
+	putErr    error
+}
+
+func (f *fakeManifestRepo) GetManifest(_ context.Context, tag string) (*interfaces.Manifest, error) {
+	m, ok := f.manifests[tag]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	return m, nil
+}
+
+func (f *fakeManifestRepo) PutManifest(_ context.Context, tag string, manifest *interfaces.Manifest) error {
+	if f.putErr != nil {
+		return f.putErr
+	}
+	f.manifests[tag] = manifest
+	return nil
+}
+
+func (f *fakeManifestRepo) DeleteManifest(_ context.Context, tag string) error {
+	delete(f.manifests, tag)
+	return nil
+}
+
+func TestParseTagSource(t *testing.T) {
+	const dgst = "sha256:b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9"
+	tests := []struct {
+		source    string
+		registry  string
+		repo      string
+		reference string
+		wantErr   bool
+	}{
+		{source: "ecr/team/app:rc-5", registry: "ecr", repo: "team/app", reference: "rc-5"},
+		{source: "gcr.io/proj/app@" + dgst, registry: "gcr.io", repo: "proj/app", reference: dgst},
+		{source: "gcr.io/proj/app:v1@" + dgst, registry: "gcr.io", repo: "proj/app", reference: dgst},
+		{source: "localhost:5000/app:v1", registry: "localhost:5000", repo: "app", reference: "v1"},
+		{source: "ecr/team/app", wantErr: true},
+		{source: "app:v1", wantErr: true},
+		{source: "ecr/app@md5:abc", wantErr: true},
+		{source: "ecr/app@sha256:abc", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			registry, repo, reference, err := parseTagSource(tt.source)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.registry, registry)
+			assert.Equal(t, tt.repo, repo)
+			assert.Equal(t, tt.reference, reference)
+		})
+	}
+}
+
+func TestRetagImage(t *testing.T) {
+	logger := log.NewBasicLogger(log.ErrorLevel)
+	manifest := &interfaces.Manifest{Content: []byte(`{}`), Digest: "sha256:abc"}
+
+	repo := &fakeManifestRepo{manifests: map[string]*interfaces.Manifest{"rc-5": manifest}}
+	digest, err := retagImage(context.Background(), logger, repo, "rc-5", []string{"stable", "v1"}, false)
+	require.NoError(t, err)
+	assert.Equal(t, "sha256:abc", digest)
+	assert.Same(t, manifest, repo.manifests["stable"])
+	assert.Same(t, manifest, repo.manifests["v1"])
+
+	repo = &fakeManifestRepo{manifests: map[string]*interfaces.Manifest{"rc-5": manifest}}
+	_, err = retagImage(context.Background(), logger, repo, "rc-5", []string{"stable"}, true)
+	require.NoError(t, err)
+	assert.NotContains(t, repo.manifests, "stable")
+
+	_, err = retagImage(context.Background(), logger, repo, "missing", []string{"stable"}, false)
+	assert.Error(t, err)
+
+	repo.putErr = errors.New("denied")
+	_, err = retagImage(context.Background(), logger, repo, "rc-5", []string{"stable"}, false)
+	assert.Error(t, err)
+}
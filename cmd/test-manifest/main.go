@@ -4,9 +4,12 @@ package main
 import (
 	"flag"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
+	"sort"
 	"strings"
+	"time"
 
 	"freightliner/pkg/testing"
 )
@@ -23,6 +26,9 @@ Commands:
   validate             Validate the test manifest file
   list-categories      List all test categories
   list-packages        List all packages in manifest
+  record               Record a 'go test -json' stream from stdin in the test history
+  history [package]    Show recent pass rates and durations, marking quarantined tests
+  affected             List packages whose tests build packages changed since -since
 
 Options:
   -manifest string     Path to test manifest file (default: test-manifest.yaml)
@@ -30,6 +36,9 @@ Options:
   -categories string   Comma-separated list of categories to filter by
   -dry-run            Show what would be executed without running tests
   -verbose            Show detailed output
+  -history string      Path to the test history file (default: history.path of the manifest)
+  -record             Record the results of the test command in the test history
+  -since string        Git revision to compare with for affected (default: origin/main)
 
 Examples:
   test-manifest summary
@@ -37,6 +46,9 @@ Examples:
   test-manifest test -categories unit,integration
   test-manifest generate-args freightliner/pkg/replication
   test-manifest validate -manifest custom-manifest.yaml
+  test-manifest test -record freightliner/pkg/replication
+  go test -json ./... | test-manifest record
+  go test $(test-manifest affected -since origin/main)
 `
 
 func main() {
@@ -54,6 +66,9 @@ func main() {
 		categories   = flag.String("categories", "", "Comma-separated list of categories to filter by")
 		dryRun       = flag.Bool("dry-run", false, "Show what would be executed without running")
 		verbose      = flag.Bool("verbose", false, "Show detailed output")
+		historyPath  = flag.String("history", "", "Path to the test history file")
+		record       = flag.Bool("record", false, "Record test results in the test history")
+		since        = flag.String("since", "origin/main", "Git revision to compare with for affected")
 	)
 
 	// Parse flags starting from the command
@@ -69,8 +84,17 @@ func main() {
 		os.Exit(1)
 	}
 
+	if *historyPath == "" {
+		*historyPath = manifest.HistoryPath(*manifestPath)
+	}
+	history, err := testing.LoadTestHistory(*historyPath, manifest.History)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading test history: %v\n", err)
+		os.Exit(1)
+	}
+
 	// Create filter
-	filter := testing.NewTestFilter(manifest)
+	filter := testing.NewTestFilter(manifest).WithHistory(history)
 
 	if *environment != "" {
 		filter = filter.WithEnvironment(*environment)
@@ -95,6 +119,10 @@ func main() {
 			os.Exit(1)
 		}
 		packageName := flag.Arg(0)
+		if *record {
+			runAndRecordTests(filter, history, *historyPath, packageName, *dryRun)
+			return
+		}
 		runTests(filter, packageName, *dryRun, *verbose)
 
 	case "generate-args":
@@ -114,6 +142,15 @@ func main() {
 	case "list-packages":
 		listPackages(manifest)
 
+	case "record":
+		recordResults(history, *historyPath, os.Stdin, os.Stdout)
+
+	case "history":
+		showHistory(history, flag.Arg(0))
+
+	case "affected":
+		listAffected(*since, flag.Args())
+
 	default:
 		fmt.Fprintf(os.Stderr, "Unknown command: %s\n", command)
 		fmt.Print(usage)
@@ -238,3 +275,115 @@ func listPackages(manifest *testing.TestManifest) {
 		fmt.Printf("  Tests: %d total, %d enabled\n", len(pkgConfig.Tests), enabledCount)
 	}
 }
+
+// runAndRecordTests runs the tests of a package with -json, printing their
+// output as usual and recording the results in the history
+func runAndRecordTests(filter *testing.TestFilter, history *testing.TestHistory, historyPath, packageName string, dryRun bool) {
+	cmd := []string{"go", "test", "-json"}
+	cmd = append(cmd, filter.GenerateTestArgs(packageName)...)
+	cmd = append(cmd, packageName)
+
+	if dryRun {
+		fmt.Printf("Would execute: %s\n", strings.Join(cmd, " "))
+		return
+	}
+
+	fmt.Printf("Running: %s\n", strings.Join(cmd, " "))
+
+	execCmd := exec.Command(cmd[0], cmd[1:]...)
+	execCmd.Stderr = os.Stderr
+	stdout, err := execCmd.StdoutPipe()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error running tests: %v\n", err)
+		os.Exit(1)
+	}
+	if err := execCmd.Start(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error running tests: %v\n", err)
+		os.Exit(1)
+	}
+
+	recordResults(history, historyPath, stdout, os.Stdout)
+
+	if err := execCmd.Wait(); err != nil {
+		if exitError, ok := err.(*exec.ExitError); ok {
+			os.Exit(exitError.ExitCode())
+		}
+		fmt.Fprintf(os.Stderr, "Error running tests: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// recordResults records a 'go test -json' stream in the history and saves it
+func recordResults(history *testing.TestHistory, historyPath string, events io.Reader, output io.Writer) {
+	recorded, err := history.RecordEvents(events, output)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error recording test results: %v\n", err)
+		os.Exit(1)
+	}
+	if err := history.Save(historyPath); err != nil {
+		fmt.Fprintf(os.Stderr, "Error saving test history: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Fprintf(os.Stderr, "Recorded %d test results in %s\n", recorded, historyPath)
+}
+
+// showHistory lists the recent pass rate and average duration of every test
+// in the history, or only those of packageName
+func showHistory(history *testing.TestHistory, packageName string) {
+	fmt.Println("Test History:")
+	fmt.Println(strings.Repeat("=", 50))
+
+	packages := make([]string, 0, len(history.Packages))
+	for name := range history.Packages {
+		if packageName == "" || name == packageName {
+			packages = append(packages, name)
+		}
+	}
+	sort.Strings(packages)
+
+	for _, name := range packages {
+		fmt.Printf("\n%s\n", name)
+
+		tests := make([]string, 0, len(history.Packages[name]))
+		for testName := range history.Packages[name] {
+			tests = append(tests, testName)
+		}
+		sort.Strings(tests)
+
+		for _, testName := range tests {
+			record := history.Packages[name][testName]
+			status := "✓"
+			if history.IsQuarantined(name, testName) {
+				status = "⚠"
+			} else if record.PassRate() < 1 {
+				status = "✗"
+			}
+			fmt.Printf("  %s %s: passed %.0f%% of %d runs, avg %s\n", status, testName,
+				record.PassRate()*100, len(record.Outcomes), record.AverageDuration().Round(time.Millisecond))
+		}
+	}
+}
+
+// listAffected prints the packages whose tests build a package changed since
+// the git revision, one per line for use as go test arguments
+func listAffected(since string, patterns []string) {
+	graph, err := testing.LoadPackageGraph(".", patterns...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error listing packages: %v\n", err)
+		os.Exit(1)
+	}
+	files, err := testing.ChangedFiles(".", since)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error listing changed files: %v\n", err)
+		os.Exit(1)
+	}
+
+	changed, all := graph.ChangedPackages(files)
+	affected := graph.Affected(changed)
+	if all {
+		affected = graph.All()
+	}
+	for _, pkg := range affected {
+		fmt.Println(pkg)
+	}
+}
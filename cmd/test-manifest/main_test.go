@@ -8,6 +8,7 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	testmanifest "freightliner/pkg/testing"
 
@@ -39,6 +40,9 @@ func TestMainUsage(t *testing.T) {
 	assert.Contains(t, usage, "validate")
 	assert.Contains(t, usage, "list-categories")
 	assert.Contains(t, usage, "list-packages")
+	assert.Contains(t, usage, "record")
+	assert.Contains(t, usage, "history")
+	assert.Contains(t, usage, "affected")
 
 	w.Close()
 	os.Stdout = old
@@ -744,3 +748,141 @@ func TestPrintSummary(t *testing.T) {
 	assert.NotEmpty(t, output)
 	assert.Contains(t, output, "pkg/example")
 }
+
+// recordRuns records outcomes of pkg/example.TestFlaky, one second each
+func recordRuns(history *testmanifest.TestHistory, outcomes ...string) {
+	for i, outcome := range outcomes {
+		history.Record("pkg/example", "TestFlaky", outcome, time.Second, time.Unix(int64(i), 0))
+	}
+}
+
+// TestHistoryQuarantine tests that only tests failing intermittently are quarantined
+func TestHistoryQuarantine(t *testing.T) {
+	config := testmanifest.HistoryConfig{Window: 4, MinRuns: 3, FlakyThreshold: 0.9}
+
+	tests := []struct {
+		name        string
+		outcomes    []string
+		quarantined bool
+		passRate    float64
+	}{
+		{"always passes", []string{"pass", "pass", "pass"}, false, 1},
+		{"flaky", []string{"pass", "fail", "pass"}, true, 2.0 / 3},
+		{"too few runs", []string{"pass", "fail"}, false, 0.5},
+		{"always fails", []string{"fail", "fail", "fail"}, false, 0},
+		{"recovered within window", []string{"fail", "pass", "pass", "pass", "pass"}, false, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			history := testmanifest.NewTestHistory(config)
+			recordRuns(history, tt.outcomes...)
+
+			record := history.Packages["pkg/example"]["TestFlaky"]
+			assert.Equal(t, tt.quarantined, history.IsQuarantined("pkg/example", "TestFlaky"))
+			assert.InDelta(t, tt.passRate, record.PassRate(), 0.001)
+			assert.Equal(t, time.Second, record.AverageDuration())
+			assert.Equal(t, len(tt.outcomes), record.Runs)
+			assert.LessOrEqual(t, len(record.Outcomes), config.Window)
+		})
+	}
+}
+
+// TestHistoryRecordEvents tests recording a go test -json stream
+func TestHistoryRecordEvents(t *testing.T) {
+	events := `{"Action":"run","Package":"pkg/example","Test":"TestA"}
+{"Action":"output","Package":"pkg/example","Test":"TestA","Output":"=== RUN   TestA\n"}
+{"Action":"run","Package":"pkg/example","Test":"TestA/sub"}
+{"Action":"fail","Package":"pkg/example","Test":"TestA/sub","Elapsed":0.1}
+{"Action":"fail","Package":"pkg/example","Test":"TestA","Elapsed":0.5}
+{"Action":"pass","Package":"pkg/example","Test":"TestB","Elapsed":1.5}
+# pkg/broken
+{"Action":"fail","Package":"pkg/example","Elapsed":2}
+`
+	history := testmanifest.NewTestHistory(testmanifest.HistoryConfig{})
+
+	var output bytes.Buffer
+	recorded, err := history.RecordEvents(strings.NewReader(events), &output)
+	require.NoError(t, err)
+
+	assert.Equal(t, 2, recorded, "subtests and package results are not recorded")
+	assert.Equal(t, []string{"fail"}, history.Packages["pkg/example"]["TestA"].Outcomes)
+	assert.Equal(t, 1500*time.Millisecond, history.Packages["pkg/example"]["TestB"].AverageDuration())
+	assert.Contains(t, output.String(), "=== RUN   TestA")
+	assert.Contains(t, output.String(), "# pkg/broken")
+
+	// The history survives a save and load
+	path := filepath.Join(t.TempDir(), "history.json")
+	require.NoError(t, history.Save(path))
+	loaded, err := testmanifest.LoadTestHistory(path, testmanifest.HistoryConfig{})
+	require.NoError(t, err)
+	assert.Equal(t, history.Packages["pkg/example"]["TestA"].Outcomes, loaded.Packages["pkg/example"]["TestA"].Outcomes)
+
+	missing, err := testmanifest.LoadTestHistory(filepath.Join(t.TempDir(), "missing.json"), testmanifest.HistoryConfig{})
+	require.NoError(t, err)
+	assert.Empty(t, missing.Packages)
+}
+
+// TestFilterQuarantine tests that quarantined tests are skipped unless their category runs
+func TestFilterQuarantine(t *testing.T) {
+	manifest := &testmanifest.TestManifest{
+		Version: "1.0",
+		Packages: map[string]testmanifest.PackageConfig{
+			"pkg/example": {Enabled: true},
+		},
+		Categories: map[string]testmanifest.CategoryConfig{
+			"quarantined": {
+				EnabledIn:  []string{"integration"},
+				DisabledIn: []string{"ci"},
+			},
+		},
+	}
+	history := testmanifest.NewTestHistory(testmanifest.HistoryConfig{MinRuns: 3})
+	recordRuns(history, "pass", "fail", "pass", "pass")
+
+	filter := testmanifest.NewTestFilter(manifest).WithEnvironment("ci").WithHistory(history)
+	shouldRun, reason := filter.ShouldRunTest("pkg/example", "TestFlaky")
+	assert.False(t, shouldRun)
+	assert.Contains(t, reason, "passed 75% of the last 4 runs")
+	assert.Contains(t, strings.Join(filter.GenerateTestArgs("pkg/example"), " "), "-skip ^TestFlaky$")
+
+	shouldRun, _ = filter.ShouldRunTest("pkg/example", "TestStable")
+	assert.True(t, shouldRun)
+
+	integration := testmanifest.NewTestFilter(manifest).WithEnvironment("integration").WithHistory(history)
+	shouldRun, _ = integration.ShouldRunTest("pkg/example", "TestFlaky")
+	assert.True(t, shouldRun, "the quarantine category is enabled in integration")
+
+	selected := testmanifest.NewTestFilter(manifest).WithEnvironment("ci").
+		WithCategories([]string{"quarantined"}).WithHistory(history)
+	shouldRun, _ = selected.ShouldRunTest("pkg/example", "TestFlaky")
+	assert.True(t, shouldRun, "selecting the quarantine category runs its tests")
+}
+
+// TestAffectedPackages tests selecting tests from changed files with a go list graph
+func TestAffectedPackages(t *testing.T) {
+	root := filepath.FromSlash("/src/app")
+	listing := `{"ImportPath":"app/a","Dir":"` + filepath.ToSlash(filepath.Join(root, "a")) + `"}
+{"ImportPath":"app/b","Dir":"` + filepath.ToSlash(filepath.Join(root, "b")) + `","Deps":["app/a"]}
+{"ImportPath":"app/b [app/b.test]","Dir":"` + filepath.ToSlash(filepath.Join(root, "b")) + `","ForTest":"app/b","Deps":["app/a"]}
+{"ImportPath":"app/a.test","Deps":["app/a","testing"]}
+{"ImportPath":"app/b.test","Deps":["app/a","app/b [app/b.test]","testing"]}
+{"ImportPath":"app/c","Dir":"` + filepath.ToSlash(filepath.Join(root, "c")) + `"}
+{"ImportPath":"app/c.test","Deps":["app/c","testing"]}
+`
+	graph, err := testmanifest.ParsePackageGraph(strings.NewReader(listing))
+	require.NoError(t, err)
+
+	changed, all := graph.ChangedPackages([]string{
+		filepath.Join(root, "a", "a.go"),
+		filepath.Join(root, "a", "testdata", "fixture.json"),
+		filepath.Join(root, "README.md"),
+	})
+	assert.False(t, all)
+	assert.Equal(t, []string{"app/a"}, changed)
+	assert.Equal(t, []string{"app/a", "app/b"}, graph.Affected(changed))
+
+	_, all = graph.ChangedPackages([]string{filepath.Join(root, "go.mod")})
+	assert.True(t, all)
+	assert.Equal(t, []string{"app/a", "app/b", "app/c"}, graph.All())
+}
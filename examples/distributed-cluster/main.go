@@ -4,6 +4,7 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
@@ -19,11 +20,14 @@ import (
 func main() {
 	// Parse command line flags
 	nodeID := flag.String("node-id", "node-1", "Node ID")
+	adminAddr := flag.String("admin-addr", ":7070", "Admin endpoint address for cluster status and drain")
 	bindAddr := flag.String("bind-addr", "127.0.0.1:7000", "Raft bind address")
 	grpcAddr := flag.String("grpc-addr", "127.0.0.1:7001", "gRPC bind address")
 	dataDir := flag.String("data-dir", "/tmp/freightliner", "Data directory")
 	bootstrap := flag.Bool("bootstrap", false, "Bootstrap cluster")
 	joinAddr := flag.String("join", "", "Existing node address to join")
+	cacheDiskBytes := flag.Int64("cache-disk-bytes", 10*1024*1024*1024, "Disk tier size of the distributed cache, kept across restarts (0 = memory only)")
+	dockerHubRPS := flag.Float64("docker-hub-rps", 1, "Requests per second the whole cluster may send to Docker Hub (0 = unlimited)")
 	flag.Parse()
 
 	logger := log.NewBasicLogger(log.InfoLevel)
@@ -85,6 +89,14 @@ func main() {
 	})
 	defer cas.Stop()
 
+	// Re-hash stored blobs in the background and evict corrupted ones
+	scrubber := storage.NewScrubber(cas, storage.ScrubberConfig{
+		Interval: 6 * time.Hour,
+		Logger:   logger,
+	})
+	scrubber.Start()
+	defer scrubber.Stop()
+
 	// Create distributed cache
 	cache := distributed.NewDistributedCache(distributed.CacheConfig{
 		Logger:       logger,
@@ -99,11 +111,51 @@ func main() {
 		10*1024*1024*1024, // 10GB capacity
 		nil,               // Local node, no client needed
 	)
+	if *cacheDiskBytes > 0 {
+		diskTier, err := distributed.OpenDiskTier(distributed.DiskTierConfig{
+			Dir:       fmt.Sprintf("%s/%s/cache", *dataDir, *nodeID),
+			MaxBytes:  *cacheDiskBytes,
+			WarmBytes: 256 * 1024 * 1024, // Reload the hottest 256MB after a restart
+			Logger:    logger,
+		})
+		if err != nil {
+			logger.Error("Failed to open cache disk tier", err)
+			os.Exit(1)
+		}
+		defer diskTier.Close()
+		cacheNode.AttachDiskTier(context.Background(), diskTier)
+	}
 	if err := cache.AddNode(cacheNode); err != nil {
 		logger.Error("Failed to add cache node", err)
 		os.Exit(1)
 	}
 
+	// Publish the blobs this node's CAS holds and place jobs on the node
+	// whose CAS already holds most of their blobs
+	if err := scheduler.EnableBlobLocality(context.Background(), cache, cas, distributed.DefaultLocalityThreshold); err != nil {
+		logger.Error("Failed to enable blob locality", err)
+		os.Exit(1)
+	}
+
+	// Share one Docker Hub budget across the cluster
+	var budgets []distributed.HostBudget
+	if *dockerHubRPS > 0 {
+		budgets = append(budgets, distributed.HostBudget{Host: "registry-1.docker.io", RequestsPerSecond: *dockerHubRPS})
+	}
+	limiter, err := distributed.NewClusterRateLimiter(distributed.ClusterRateLimiterConfig{
+		NodeID:  *nodeID,
+		Budgets: budgets,
+		Shares:  coordinator,
+		Demand:  cache,
+		Logger:  logger,
+	})
+	if err != nil {
+		logger.Error("Failed to create cluster rate limiter", err)
+		os.Exit(1)
+	}
+	limiter.Start()
+	defer limiter.Stop()
+
 	// Create gRPC mesh
 	meshConfig := distributed.MeshConfig{
 		NodeID:  *nodeID,
@@ -141,11 +193,24 @@ func main() {
 		}
 	}
 
+	// Serve cluster status and maintenance endpoints
+	adminServer := &http.Server{
+		Addr:              *adminAddr,
+		Handler:           distributed.NewAdminHandler(scheduler, coordinator),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		if err := adminServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logger.Error("Admin endpoint failed", err)
+		}
+	}()
+	defer adminServer.Close()
+
 	// Start example workload
-	go runExampleWorkload(coordinator, scheduler, cas, cache, logger)
+	go runExampleWorkload(coordinator, scheduler, limiter, cas, cache, logger)
 
 	// Print cluster status periodically
-	go printClusterStatus(coordinator, scheduler, cas, cache, logger)
+	go printClusterStatus(coordinator, scheduler, limiter, cas, cache, logger)
 
 	// Wait for interrupt signal
 	sigCh := make(chan os.Signal, 1)
@@ -158,6 +223,7 @@ func main() {
 func runExampleWorkload(
 	coordinator *distributed.RaftCoordinator,
 	scheduler *distributed.WorkStealingScheduler,
+	limiter *distributed.ClusterRateLimiter,
 	cas *storage.ContentAddressableStore,
 	cache *distributed.DistributedCache,
 	logger log.Logger,
@@ -171,7 +237,7 @@ func runExampleWorkload(
 
 	// Example 1: Store blob in CAS
 	blobData := []byte("Example container layer data")
-	digest, err := cas.Store(ctx, blobData)
+	digest, err := cas.StoreForRepository(ctx, "docker.io/library/example", blobData)
 	if err != nil {
 		logger.Error("Failed to store blob", err)
 		return
@@ -216,11 +282,18 @@ func runExampleWorkload(
 		job := &distributed.Job{
 			ID:       fmt.Sprintf("replication-%d", i),
 			Priority: 10,
+			Blobs:    []string{digest.String()},
 			Task: func(ctx context.Context) error {
 				logger.WithFields(map[string]interface{}{
 					"job_id": fmt.Sprintf("replication-%d", i),
 				}).Info("Executing replication task")
 
+				// Requests to Docker Hub count against the cluster budget;
+				// a real client would use limiter.Transport instead
+				if err := limiter.Wait(ctx, "registry-1.docker.io"); err != nil {
+					return err
+				}
+
 				// Simulate work
 				time.Sleep(2 * time.Second)
 				return nil
@@ -263,6 +336,7 @@ func runExampleWorkload(
 func printClusterStatus(
 	coordinator *distributed.RaftCoordinator,
 	scheduler *distributed.WorkStealingScheduler,
+	limiter *distributed.ClusterRateLimiter,
 	cas *storage.ContentAddressableStore,
 	cache *distributed.DistributedCache,
 	logger log.Logger,
@@ -278,7 +352,7 @@ func printClusterStatus(
 
 		// Scheduler metrics
 		schedMetrics := scheduler.GetMetrics()
-		queueDepth := scheduler.GetQueueDepth()
+		schedStatus := scheduler.Status()
 
 		// CAS stats
 		casStats := cas.GetStats()
@@ -292,11 +366,18 @@ func printClusterStatus(
 			"leader":         leaderAddr,
 			"jobs_scheduled": schedMetrics.JobsScheduled.Load(),
 			"jobs_stolen":    schedMetrics.JobsStolen.Load(),
-			"queue_depth":    queueDepth,
+			"rebalanced":     schedMetrics.JobsRebalanced.Load(),
+			"queue_depth":    schedStatus.QueueDepth,
+			"in_flight":      schedStatus.InFlight,
+			"mode":           schedStatus.Mode,
 			"cas_blobs":      casStats["blob_count"],
 			"cas_dedup_rate": casStats["dedup_rate"],
+			"cas_repos":      casStats["repositories"],
 			"cache_hit_rate": cacheStats["hit_rate"],
 			"cache_nodes":    cacheStats["nodes"],
+			"cache_memory":   cacheStats["memory_bytes"],
+			"cache_disk":     cacheStats["disk_bytes"],
+			"rate_shares":    limiter.Shares(),
 		}).Info("Cluster status")
 	}
 }
@@ -0,0 +1,64 @@
+// Package attestation records what a run pushed and emits it as a signed
+// in-toto statement, so auditors can check that a mirror matched its source
+// at a point in time.
+package attestation
+
+import (
+	"sort"
+	"sync"
+)
+
+// Blob is a blob referenced by a pushed manifest
+type Blob struct {
+	Digest string `json:"digest"`
+	Size   int64  `json:"size"`
+}
+
+// Transfer is one image pushed to the destination
+type Transfer struct {
+	Source            string `json:"source"`
+	Destination       string `json:"destination"`
+	SourceDigest      string `json:"sourceDigest"`
+	DestinationDigest string `json:"destinationDigest"`
+	Blobs             []Blob `json:"blobs"`
+}
+
+// Ledger collects the transfers of one run. It is safe for concurrent use
+// and a nil Ledger records nothing.
+type Ledger struct {
+	mu        sync.Mutex
+	transfers []Transfer
+}
+
+// NewLedger creates an empty ledger for one run
+func NewLedger() *Ledger {
+	return &Ledger{}
+}
+
+// Record adds a completed transfer
+func (l *Ledger) Record(t Transfer) {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.transfers = append(l.transfers, t)
+}
+
+// Transfers returns the recorded transfers ordered by destination
+func (l *Ledger) Transfers() []Transfer {
+	if l == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	transfers := make([]Transfer, len(l.transfers))
+	copy(transfers, l.transfers)
+	l.mu.Unlock()
+
+	sort.SliceStable(transfers, func(i, j int) bool {
+		return transfers[i].Destination < transfers[j].Destination
+	})
+	return transfers
+}
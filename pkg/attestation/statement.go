@@ -0,0 +1,245 @@
+package attestation
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"freightliner/pkg/helper/errors"
+)
+
+// In-toto and DSSE identifiers used by emitted attestations
+const (
+	StatementType = "https://in-toto.io/Statement/v1"
+	PredicateType = "https://github.com/hemzaz/freightliner/attestation/transfer/v1"
+	PayloadType   = "application/vnd.in-toto+json"
+)
+
+// Statement is an in-toto v1 statement whose subjects are the pushed images
+type Statement struct {
+	Type          string            `json:"_type"`
+	Subject       []Subject         `json:"subject"`
+	PredicateType string            `json:"predicateType"`
+	Predicate     TransferPredicate `json:"predicate"`
+}
+
+// Subject is a destination image and its manifest digest
+type Subject struct {
+	Name   string            `json:"name"`
+	Digest map[string]string `json:"digest"`
+}
+
+// RunInfo identifies the run that produced an attestation
+type RunInfo struct {
+	JobID       string    `json:"jobId"`
+	Command     string    `json:"command"`
+	Source      string    `json:"source"`
+	Destination string    `json:"destination"`
+	Status      string    `json:"status"`
+	StartedAt   time.Time `json:"startedAt"`
+	FinishedAt  time.Time `json:"finishedAt"`
+}
+
+// TransferPredicate lists every transfer with its source digest and blobs
+type TransferPredicate struct {
+	Run RunInfo `json:"run"`
+
+	// Channel is set when the run mirrored a release channel, and the
+	// statement then attests that every image of the channel was mirrored
+	Channel *Channel `json:"channel,omitempty"`
+
+	Transfers []Transfer `json:"transfers"`
+}
+
+// Channel identifies a mirrored release channel by the digest of its
+// definition file
+type Channel struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+	Digest  string `json:"digest"`
+	Images  int    `json:"images"`
+}
+
+// NewStatement builds the statement for the transfers recorded in ledger
+func NewStatement(run RunInfo, ledger *Ledger) *Statement {
+	transfers := ledger.Transfers()
+	if transfers == nil {
+		transfers = []Transfer{}
+	}
+
+	subjects := make([]Subject, 0, len(transfers))
+	for _, t := range transfers {
+		algorithm, value, found := strings.Cut(t.DestinationDigest, ":")
+		if !found {
+			continue
+		}
+		subjects = append(subjects, Subject{
+			Name:   t.Destination,
+			Digest: map[string]string{algorithm: value},
+		})
+	}
+
+	return &Statement{
+		Type:          StatementType,
+		Subject:       subjects,
+		PredicateType: PredicateType,
+		Predicate: TransferPredicate{
+			Run:       run,
+			Transfers: transfers,
+		},
+	}
+}
+
+// Envelope is a DSSE envelope carrying a signed statement
+type Envelope struct {
+	PayloadType string      `json:"payloadType"`
+	Payload     string      `json:"payload"`
+	Signatures  []Signature `json:"signatures"`
+}
+
+// Signature is one DSSE signature
+type Signature struct {
+	KeyID string `json:"keyid"`
+	Sig   string `json:"sig"`
+}
+
+// Sign serializes statement and signs it as a DSSE envelope. ECDSA and RSA
+// keys sign the SHA-256 of the pre-authentication encoding; Ed25519 signs it directly.
+func Sign(statement *Statement, signer crypto.Signer) (*Envelope, error) {
+	payload, err := json.Marshal(statement)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to encode attestation statement")
+	}
+
+	message := pae(PayloadType, payload)
+	var sig []byte
+	if _, ok := signer.Public().(ed25519.PublicKey); ok {
+		sig, err = signer.Sign(rand.Reader, message, crypto.Hash(0))
+	} else {
+		digest := sha256.Sum256(message)
+		sig, err = signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to sign attestation")
+	}
+
+	keyID, err := KeyID(signer.Public())
+	if err != nil {
+		return nil, err
+	}
+
+	return &Envelope{
+		PayloadType: PayloadType,
+		Payload:     base64.StdEncoding.EncodeToString(payload),
+		Signatures:  []Signature{{KeyID: keyID, Sig: base64.StdEncoding.EncodeToString(sig)}},
+	}, nil
+}
+
+// Verify checks that one of the envelope's signatures was made by publicKey
+// and returns the signed statement
+func Verify(envelope *Envelope, publicKey crypto.PublicKey) (*Statement, error) {
+	if envelope.PayloadType != PayloadType {
+		return nil, errors.InvalidInputf("unexpected payload type %q", envelope.PayloadType)
+	}
+
+	payload, err := base64.StdEncoding.DecodeString(envelope.Payload)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decode attestation payload")
+	}
+
+	message := pae(envelope.PayloadType, payload)
+	digest := sha256.Sum256(message)
+
+	verified := false
+	for _, s := range envelope.Signatures {
+		sig, err := base64.StdEncoding.DecodeString(s.Sig)
+		if err != nil {
+			continue
+		}
+
+		switch key := publicKey.(type) {
+		case ed25519.PublicKey:
+			verified = ed25519.Verify(key, message, sig)
+		case *ecdsa.PublicKey:
+			verified = ecdsa.VerifyASN1(key, digest[:], sig)
+		case *rsa.PublicKey:
+			verified = rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig) == nil
+		default:
+			return nil, errors.InvalidInputf("unsupported public key type %T", publicKey)
+		}
+		if verified {
+			break
+		}
+	}
+	if !verified {
+		return nil, errors.New("attestation signature does not match the public key")
+	}
+
+	var statement Statement
+	if err := json.Unmarshal(payload, &statement); err != nil {
+		return nil, errors.Wrap(err, "failed to parse attestation statement")
+	}
+	return &statement, nil
+}
+
+// pae is the DSSE pre-authentication encoding
+func pae(payloadType string, payload []byte) []byte {
+	return []byte(fmt.Sprintf("DSSEv1 %d %s %d %s", len(payloadType), payloadType, len(payload), payload))
+}
+
+// KeyID returns the hex SHA-256 of the key's PKIX encoding
+func KeyID(publicKey crypto.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to encode public key")
+	}
+	sum := sha256.Sum256(der)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// LoadSigner reads an unencrypted PEM private key (PKCS#8, SEC 1 or PKCS#1)
+func LoadSigner(path string) (crypto.Signer, error) {
+	data, err := os.ReadFile(path) // #nosec G304 - key path comes from the operator
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read attestation key")
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.InvalidInputf("attestation key %s is not PEM encoded", path)
+	}
+	if strings.Contains(block.Type, "ENCRYPTED") {
+		return nil, errors.InvalidInputf("attestation key %s is encrypted; provide an unencrypted key", path)
+	}
+
+	var key interface{}
+	switch block.Type {
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse attestation key %s", path)
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, errors.InvalidInputf("attestation key %s cannot sign", path)
+	}
+	return signer, nil
+}
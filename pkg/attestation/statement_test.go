@@ -0,0 +1,158 @@
+package attestation
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testLedger() *Ledger {
+	ledger := NewLedger()
+	ledger.Record(Transfer{
+		Source:            "src.example.com/team/app:v2",
+		Destination:       "mirror.example.com/team/app:v2",
+		SourceDigest:      "sha256:bbb",
+		DestinationDigest: "sha256:bbb",
+		Blobs:             []Blob{{Digest: "sha256:layer", Size: 10}},
+	})
+	ledger.Record(Transfer{
+		Source:            "src.example.com/team/app:v1",
+		Destination:       "mirror.example.com/team/app:v1",
+		SourceDigest:      "sha256:aaa",
+		DestinationDigest: "sha256:aaa",
+	})
+	return ledger
+}
+
+func TestLedger(t *testing.T) {
+	var nilLedger *Ledger
+	nilLedger.Record(Transfer{Destination: "ignored"})
+	assert.Nil(t, nilLedger.Transfers())
+
+	ledger := NewLedger()
+	var wg sync.WaitGroup
+	for _, dest := range []string{"c", "a", "b"} {
+		wg.Add(1)
+		go func(dest string) {
+			defer wg.Done()
+			ledger.Record(Transfer{Destination: dest})
+		}(dest)
+	}
+	wg.Wait()
+
+	transfers := ledger.Transfers()
+	require.Len(t, transfers, 3)
+	assert.Equal(t, []string{"a", "b", "c"}, []string{transfers[0].Destination, transfers[1].Destination, transfers[2].Destination})
+}
+
+func TestNewStatement(t *testing.T) {
+	run := RunInfo{JobID: "job-1", Command: "replicate", Status: "succeeded", StartedAt: time.Unix(0, 0).UTC()}
+	statement := NewStatement(run, testLedger())
+
+	assert.Equal(t, StatementType, statement.Type)
+	assert.Equal(t, PredicateType, statement.PredicateType)
+	require.Len(t, statement.Subject, 2)
+	assert.Equal(t, "mirror.example.com/team/app:v1", statement.Subject[0].Name)
+	assert.Equal(t, map[string]string{"sha256": "aaa"}, statement.Subject[0].Digest)
+	assert.Equal(t, "sha256:bbb", statement.Predicate.Transfers[1].SourceDigest)
+	assert.Equal(t, "job-1", statement.Predicate.Run.JobID)
+
+	empty := NewStatement(run, nil)
+	assert.NotNil(t, empty.Predicate.Transfers)
+	assert.Empty(t, empty.Subject)
+}
+
+func TestSignAndVerify(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	for name, signer := range map[string]crypto.Signer{"ecdsa": ecKey, "ed25519": edKey} {
+		t.Run(name, func(t *testing.T) {
+			statement := NewStatement(RunInfo{JobID: "job-1"}, testLedger())
+			envelope, err := Sign(statement, signer)
+			require.NoError(t, err)
+			assert.Equal(t, PayloadType, envelope.PayloadType)
+			require.Len(t, envelope.Signatures, 1)
+
+			keyID, err := KeyID(signer.Public())
+			require.NoError(t, err)
+			assert.Equal(t, keyID, envelope.Signatures[0].KeyID)
+
+			verified, err := Verify(envelope, signer.Public())
+			require.NoError(t, err)
+			assert.Equal(t, statement.Subject, verified.Subject)
+
+			// A tampered payload no longer verifies
+			statement.Subject[0].Digest["sha256"] = "forged"
+			forged, err := Sign(statement, signer)
+			require.NoError(t, err)
+			envelope.Payload = forged.Payload
+			_, err = Verify(envelope, signer.Public())
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestVerifyRejectsOtherKey(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	envelope, err := Sign(NewStatement(RunInfo{}, testLedger()), key)
+	require.NoError(t, err)
+
+	_, err = Verify(envelope, other.Public())
+	assert.Error(t, err)
+
+	envelope.Payload = base64.StdEncoding.EncodeToString([]byte("{}"))
+	envelope.PayloadType = "text/plain"
+	_, err = Verify(envelope, key.Public())
+	assert.Error(t, err)
+}
+
+func TestLoadSigner(t *testing.T) {
+	dir := t.TempDir()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	sec1, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+
+	files := map[string]*pem.Block{
+		"sec1.pem":      {Type: "EC PRIVATE KEY", Bytes: sec1},
+		"pkcs8.pem":     {Type: "PRIVATE KEY", Bytes: pkcs8},
+		"encrypted.pem": {Type: "ENCRYPTED PRIVATE KEY", Bytes: pkcs8},
+	}
+	for name, block := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), pem.EncodeToMemory(block), 0o600))
+	}
+
+	for _, name := range []string{"sec1.pem", "pkcs8.pem"} {
+		signer, err := LoadSigner(filepath.Join(dir, name))
+		require.NoError(t, err, name)
+		assert.True(t, key.PublicKey.Equal(signer.Public()), name)
+	}
+
+	_, err = LoadSigner(filepath.Join(dir, "encrypted.pem"))
+	assert.Error(t, err)
+	_, err = LoadSigner(filepath.Join(dir, "missing.pem"))
+	assert.Error(t, err)
+}
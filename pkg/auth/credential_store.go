@@ -219,7 +219,11 @@ func (cs *CredentialStore) Delete(registry string) error {
 
 	// Check for registry-specific helper
 	if helper, ok := config.CredHelpers[registry]; ok {
-		return cs.deleteFromHelper(helper, registry)
+		if err := cs.deleteFromHelper(helper, registry); err != nil {
+			return err
+		}
+		delete(config.CredHelpers, registry)
+		return cs.saveConfig(config)
 	}
 
 	// Delete from auths
@@ -239,10 +243,15 @@ func (cs *CredentialStore) List() ([]string, error) {
 		return nil, fmt.Errorf("failed to load config: %w", err)
 	}
 
-	registries := make([]string, 0, len(config.Auths))
+	registries := make([]string, 0, len(config.Auths)+len(config.CredHelpers))
 	for registry := range config.Auths {
 		registries = append(registries, registry)
 	}
+	for registry := range config.CredHelpers {
+		if _, ok := config.Auths[registry]; !ok {
+			registries = append(registries, registry)
+		}
+	}
 
 	return registries, nil
 }
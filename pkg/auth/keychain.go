@@ -0,0 +1,62 @@
+package auth
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// NativeKeychainHelper returns the credential helper backed by the operating
+// system keychain: macOS Keychain, Windows Credential Manager or the Secret
+// Service (GNOME Keyring, KWallet) on Linux. It returns "" on other systems.
+func NativeKeychainHelper() string {
+	switch runtime.GOOS {
+	case "darwin":
+		return "osxkeychain"
+	case "windows":
+		return "wincred"
+	case "linux", "freebsd", "openbsd":
+		return "secretservice"
+	default:
+		return ""
+	}
+}
+
+// IsKeychainAvailable reports whether the native keychain helper is installed
+func IsKeychainAvailable() bool {
+	helper := NativeKeychainHelper()
+	return helper != "" && IsHelperAvailable(helper)
+}
+
+// StoreInKeychain saves credentials for a registry in the OS keychain and
+// records the helper under credHelpers in the Docker config, so Docker and
+// other tools find the same credentials. Any plaintext entry for the registry
+// is removed from the config.
+func (cs *CredentialStore) StoreInKeychain(registry, username, password string) error {
+	helper := NativeKeychainHelper()
+	if helper == "" {
+		return fmt.Errorf("no OS keychain support on %s", runtime.GOOS)
+	}
+
+	if err := cs.storeWithHelper(helper, registry, username, password); err != nil {
+		return err
+	}
+
+	config, err := cs.loadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	if config.CredHelpers == nil {
+		config.CredHelpers = make(map[string]string)
+	}
+	config.CredHelpers[registry] = helper
+	delete(config.Auths, registry)
+
+	if err := os.MkdirAll(filepath.Dir(cs.configPath), 0700); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+
+	return cs.saveConfig(config)
+}
@@ -0,0 +1,65 @@
+package auth
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// installFakeKeychainHelper puts a docker-credential helper for the native
+// keychain on PATH that keeps a single credential in a file
+func installFakeKeychainHelper(t *testing.T) {
+	t.Helper()
+
+	helper := NativeKeychainHelper()
+	if helper == "" || runtime.GOOS == "windows" {
+		t.Skip("fake credential helper needs a POSIX shell")
+	}
+
+	binDir := t.TempDir()
+	secret := filepath.Join(binDir, "secret.json")
+	script := `#!/bin/sh
+case "$1" in
+  store) cat > "` + secret + `" ;;
+  get) cat "` + secret + `" 2>/dev/null || { echo "credentials not found"; exit 1; } ;;
+  erase) rm -f "` + secret + `" ;;
+esac
+`
+	require.NoError(t, os.WriteFile(filepath.Join(binDir, "docker-credential-"+helper), []byte(script), 0700))
+	t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestCredentialStore_StoreInKeychain(t *testing.T) {
+	installFakeKeychainHelper(t)
+	require.True(t, IsKeychainAvailable())
+
+	configPath := filepath.Join(t.TempDir(), "docker", "config.json")
+	store := NewCredentialStoreWithPath(configPath)
+
+	// A plaintext entry is replaced by the keychain entry
+	require.NoError(t, store.Store("registry.io", "old", "old-pass"))
+	require.NoError(t, store.StoreInKeychain("registry.io", "user", "token"))
+
+	config, err := store.loadConfig()
+	require.NoError(t, err)
+	assert.Equal(t, NativeKeychainHelper(), config.CredHelpers["registry.io"])
+	assert.NotContains(t, config.Auths, "registry.io")
+
+	username, password, err := store.Get("registry.io")
+	require.NoError(t, err)
+	assert.Equal(t, "user", username)
+	assert.Equal(t, "token", password)
+
+	registries, err := store.List()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"registry.io"}, registries)
+
+	require.NoError(t, store.Delete("registry.io"))
+	config, err = store.loadConfig()
+	require.NoError(t, err)
+	assert.NotContains(t, config.CredHelpers, "registry.io")
+}
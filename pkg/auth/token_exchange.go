@@ -0,0 +1,227 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Grant types a TokenExchanger can request
+const (
+	// GrantTypeTokenExchange sends the assertion as the subject token of an
+	// RFC 8693 token exchange
+	GrantTypeTokenExchange = "urn:ietf:params:oauth:grant-type:token-exchange"
+
+	// GrantTypeClientCredentials sends the assertion as an RFC 7523 client
+	// assertion in a client credentials grant
+	GrantTypeClientCredentials = "client_credentials"
+)
+
+const (
+	jwtTokenType          = "urn:ietf:params:oauth:token-type:jwt"
+	jwtBearerAssertion    = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+	defaultTokenLifetime  = 5 * time.Minute
+	defaultRefreshBefore  = time.Minute
+	tokenExchangeTimeout  = 30 * time.Second
+	maxTokenResponseBytes = 1 << 20
+)
+
+// TokenExchangeOptions configures a TokenExchanger
+type TokenExchangeOptions struct {
+	// TokenURL is the token endpoint of the exchange service
+	TokenURL string
+
+	// Audience is the registry the exchanged token is for
+	Audience string
+
+	// Scope is requested with the token, if set
+	Scope string
+
+	// ClientID identifies freightliner to the token endpoint, if required
+	ClientID string
+
+	// ClientAssertion is a signed JWT, such as a CI provider's OIDC token
+	ClientAssertion string
+
+	// ClientAssertionFile is read before every exchange, so rotated tokens
+	// such as Kubernetes projected service account tokens are picked up
+	ClientAssertionFile string
+
+	// GrantType is GrantTypeTokenExchange (default) or
+	// GrantTypeClientCredentials
+	GrantType string
+
+	// RefreshBefore is how long before expiry a token is replaced
+	// (default: 1 minute)
+	RefreshBefore time.Duration
+
+	// HTTPClient sends the exchange requests (default: 30s timeout)
+	HTTPClient *http.Client
+}
+
+// TokenExchanger trades an OIDC assertion for a short-lived registry token
+// and caches it until shortly before it expires
+type TokenExchanger struct {
+	opts TokenExchangeOptions
+	now  func() time.Time
+
+	mu     sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+// tokenResponse is the token endpoint's reply, success or error
+type tokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	ExpiresIn        int64  `json:"expires_in"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// NewTokenExchanger validates opts and returns an exchanger. No request is
+// made until the first token is needed.
+func NewTokenExchanger(opts TokenExchangeOptions) (*TokenExchanger, error) {
+	if opts.TokenURL == "" {
+		return nil, fmt.Errorf("token exchange requires a token URL")
+	}
+	u, err := url.Parse(opts.TokenURL)
+	if err != nil || u.Host == "" || (u.Scheme != "https" && u.Scheme != "http") {
+		return nil, fmt.Errorf("invalid token URL: %s", opts.TokenURL)
+	}
+	if opts.ClientAssertion == "" && opts.ClientAssertionFile == "" {
+		return nil, fmt.Errorf("token exchange requires a client assertion or client assertion file")
+	}
+
+	switch opts.GrantType {
+	case "":
+		opts.GrantType = GrantTypeTokenExchange
+	case GrantTypeTokenExchange, GrantTypeClientCredentials:
+	default:
+		return nil, fmt.Errorf("unsupported token exchange grant type: %s", opts.GrantType)
+	}
+
+	if opts.RefreshBefore <= 0 {
+		opts.RefreshBefore = defaultRefreshBefore
+	}
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = &http.Client{Timeout: tokenExchangeTimeout}
+	}
+
+	return &TokenExchanger{opts: opts, now: time.Now}, nil
+}
+
+// Token returns the cached token, exchanging the assertion for a new one
+// when there is none or it is about to expire
+func (e *TokenExchanger) Token(ctx context.Context) (string, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.token != "" && e.now().Add(e.opts.RefreshBefore).Before(e.expiry) {
+		return e.token, nil
+	}
+
+	token, lifetime, err := e.exchange(ctx)
+	if err != nil {
+		return "", err
+	}
+	e.token = token
+	e.expiry = e.now().Add(lifetime)
+	return token, nil
+}
+
+// Invalidate drops the cached token, so the next call to Token exchanges
+// again. Use it when the registry rejects a token before its expiry.
+func (e *TokenExchanger) Invalidate() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.token = ""
+}
+
+// exchange requests a new token and returns it with its lifetime
+func (e *TokenExchanger) exchange(ctx context.Context) (string, time.Duration, error) {
+	assertion, err := e.assertion()
+	if err != nil {
+		return "", 0, err
+	}
+
+	form := url.Values{"grant_type": {e.opts.GrantType}}
+	if e.opts.GrantType == GrantTypeTokenExchange {
+		form.Set("subject_token", assertion)
+		form.Set("subject_token_type", jwtTokenType)
+	} else {
+		form.Set("client_assertion", assertion)
+		form.Set("client_assertion_type", jwtBearerAssertion)
+	}
+	if e.opts.Audience != "" {
+		form.Set("audience", e.opts.Audience)
+	}
+	if e.opts.Scope != "" {
+		form.Set("scope", e.opts.Scope)
+	}
+	if e.opts.ClientID != "" {
+		form.Set("client_id", e.opts.ClientID)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.opts.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to create token exchange request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := e.opts.HTTPClient.Do(req)
+	if err != nil {
+		return "", 0, fmt.Errorf("token exchange with %s failed: %w", e.opts.TokenURL, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxTokenResponseBytes))
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to read token exchange response: %w", err)
+	}
+
+	var result tokenResponse
+	decodeErr := json.Unmarshal(body, &result)
+	if resp.StatusCode != http.StatusOK {
+		if decodeErr == nil && result.Error != "" {
+			return "", 0, fmt.Errorf("token exchange rejected (%d): %s %s", resp.StatusCode, result.Error, result.ErrorDescription)
+		}
+		return "", 0, fmt.Errorf("token exchange rejected: %s", resp.Status)
+	}
+	if decodeErr != nil {
+		return "", 0, fmt.Errorf("failed to parse token exchange response: %w", decodeErr)
+	}
+	if result.AccessToken == "" {
+		return "", 0, fmt.Errorf("token exchange response has no access_token")
+	}
+
+	lifetime := defaultTokenLifetime
+	if result.ExpiresIn > 0 {
+		lifetime = time.Duration(result.ExpiresIn) * time.Second
+	}
+	return result.AccessToken, lifetime, nil
+}
+
+// assertion returns the configured assertion, reading the file if one is set
+func (e *TokenExchanger) assertion() (string, error) {
+	if e.opts.ClientAssertionFile == "" {
+		return e.opts.ClientAssertion, nil
+	}
+
+	data, err := os.ReadFile(e.opts.ClientAssertionFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to read client assertion: %w", err)
+	}
+	assertion := strings.TrimSpace(string(data))
+	if assertion == "" {
+		return "", fmt.Errorf("client assertion file %s is empty", e.opts.ClientAssertionFile)
+	}
+	return assertion, nil
+}
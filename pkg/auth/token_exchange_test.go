@@ -0,0 +1,153 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewTokenExchanger(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    TokenExchangeOptions
+		wantErr string
+	}{
+		{
+			name:    "missing token URL",
+			opts:    TokenExchangeOptions{ClientAssertion: "jwt"},
+			wantErr: "token URL",
+		},
+		{
+			name:    "relative token URL",
+			opts:    TokenExchangeOptions{TokenURL: "/token", ClientAssertion: "jwt"},
+			wantErr: "invalid token URL",
+		},
+		{
+			name:    "missing assertion",
+			opts:    TokenExchangeOptions{TokenURL: "https://sts.example.com/token"},
+			wantErr: "client assertion",
+		},
+		{
+			name:    "unknown grant type",
+			opts:    TokenExchangeOptions{TokenURL: "https://sts.example.com/token", ClientAssertion: "jwt", GrantType: "password"},
+			wantErr: "grant type",
+		},
+		{
+			name: "valid",
+			opts: TokenExchangeOptions{TokenURL: "https://sts.example.com/token", ClientAssertion: "jwt"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewTokenExchanger(tt.opts)
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestTokenExchanger_Token(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		assert.Equal(t, GrantTypeTokenExchange, r.PostForm.Get("grant_type"))
+		assert.Equal(t, "ci-jwt", r.PostForm.Get("subject_token"))
+		assert.Equal(t, jwtTokenType, r.PostForm.Get("subject_token_type"))
+		assert.Equal(t, "registry.internal", r.PostForm.Get("audience"))
+
+		n := calls.Add(1)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": fmt.Sprintf("token-%d", n),
+			"expires_in":   300,
+		})
+	}))
+	defer server.Close()
+
+	exchanger, err := NewTokenExchanger(TokenExchangeOptions{
+		TokenURL:        server.URL,
+		Audience:        "registry.internal",
+		ClientAssertion: "ci-jwt",
+	})
+	require.NoError(t, err)
+
+	now := time.Now()
+	exchanger.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	token, err := exchanger.Token(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "token-1", token)
+
+	// Cached until a minute before expiry
+	now = now.Add(3 * time.Minute)
+	token, err = exchanger.Token(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "token-1", token)
+
+	now = now.Add(90 * time.Second)
+	token, err = exchanger.Token(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "token-2", token)
+
+	exchanger.Invalidate()
+	token, err = exchanger.Token(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "token-3", token)
+}
+
+func TestTokenExchanger_ClientCredentialsFromFile(t *testing.T) {
+	assertionFile := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(assertionFile, []byte("projected-jwt\n"), 0600))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		assert.Equal(t, GrantTypeClientCredentials, r.PostForm.Get("grant_type"))
+		assert.Equal(t, "projected-jwt", r.PostForm.Get("client_assertion"))
+		assert.Equal(t, jwtBearerAssertion, r.PostForm.Get("client_assertion_type"))
+		assert.Equal(t, "freightliner", r.PostForm.Get("client_id"))
+		_, _ = w.Write([]byte(`{"access_token":"registry-token"}`))
+	}))
+	defer server.Close()
+
+	exchanger, err := NewTokenExchanger(TokenExchangeOptions{
+		TokenURL:            server.URL,
+		ClientID:            "freightliner",
+		ClientAssertionFile: assertionFile,
+		GrantType:           GrantTypeClientCredentials,
+	})
+	require.NoError(t, err)
+
+	token, err := exchanger.Token(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "registry-token", token)
+}
+
+func TestTokenExchanger_Rejected(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"error":"invalid_grant","error_description":"assertion expired"}`))
+	}))
+	defer server.Close()
+
+	exchanger, err := NewTokenExchanger(TokenExchangeOptions{TokenURL: server.URL, ClientAssertion: "stale"})
+	require.NoError(t, err)
+
+	_, err = exchanger.Token(context.Background())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid_grant assertion expired")
+}
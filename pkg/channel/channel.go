@@ -0,0 +1,125 @@
+// Package channel mirrors release channels: fixed sets of images pinned by
+// digest that together make up a product release. A channel is copied
+// byte-exact, every pushed manifest is checked against its pinned digest, and
+// the run's attestation records that the whole channel reached the mirror.
+package channel
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"freightliner/pkg/helper/errors"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"gopkg.in/yaml.v3"
+)
+
+// validTag matches tags accepted by the OCI distribution spec
+var validTag = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.-]{0,127}$`)
+
+// Channel is a release channel definition
+type Channel struct {
+	// Name and Version identify the release, e.g. "platform" and "2024.10"
+	Name    string `json:"name" yaml:"name"`
+	Version string `json:"version,omitempty" yaml:"version,omitempty"`
+
+	// Images are the images of the release
+	Images []Image `json:"images" yaml:"images"`
+
+	// Digest is the sha256 digest of the definition file, set by Load
+	Digest string `json:"-" yaml:"-"`
+}
+
+// Image is one image of a channel
+type Image struct {
+	// Image is the source image as REGISTRY/REPOSITORY@DIGEST; a tag before
+	// the digest is ignored
+	Image string `json:"image" yaml:"image"`
+
+	// Tag is also pointed at the image in the destination (optional)
+	Tag string `json:"tag,omitempty" yaml:"tag,omitempty"`
+}
+
+// Load reads and validates the channel file at path. Files ending in .json
+// are read as JSON, anything else as YAML; unknown fields are rejected in
+// both so typos do not silently drop images.
+func Load(path string) (*Channel, error) {
+	data, err := os.ReadFile(path) // #nosec G304 - channel file is chosen by the operator
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read channel file")
+	}
+
+	var ch Channel
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(&ch)
+	} else {
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		err = decoder.Decode(&ch)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse channel file %s", path)
+	}
+
+	if err := ch.Validate(); err != nil {
+		return nil, err
+	}
+	ch.Digest = fmt.Sprintf("sha256:%x", sha256.Sum256(data))
+	return &ch, nil
+}
+
+// Validate checks that the channel is named and that every image is pinned
+// by digest and listed once
+func (c *Channel) Validate() error {
+	if c.Name == "" {
+		return errors.InvalidInputf("channel name is required")
+	}
+	if len(c.Images) == 0 {
+		return errors.InvalidInputf("channel %s lists no images", c.Name)
+	}
+
+	seen := make(map[string]bool, len(c.Images))
+	for i, image := range c.Images {
+		ref, err := image.Reference()
+		if err != nil {
+			return errors.InvalidInputf("channel image %d: %s", i+1, err)
+		}
+		if seen[ref.String()] {
+			return errors.InvalidInputf("channel image %s is listed more than once", ref)
+		}
+		seen[ref.String()] = true
+
+		if image.Tag != "" {
+			if !validTag.MatchString(image.Tag) {
+				return errors.InvalidInputf("channel image %s: invalid tag %q", ref, image.Tag)
+			}
+		}
+	}
+	return nil
+}
+
+// Reference parses the image as a digest reference
+func (i Image) Reference() (name.Digest, error) {
+	repository, digest, found := strings.Cut(i.Image, "@")
+	if !found {
+		return name.Digest{}, errors.InvalidInputf("image %q is not pinned by digest", i.Image)
+	}
+	// Drop a tag kept next to the digest for readability
+	if slash, colon := strings.LastIndex(repository, "/"), strings.LastIndex(repository, ":"); colon > slash {
+		repository = repository[:colon]
+	}
+
+	ref, err := name.NewDigest(repository + "@" + digest)
+	if err != nil {
+		return name.Digest{}, errors.InvalidInputf("invalid image %q: %s", i.Image, err)
+	}
+	return ref, nil
+}
@@ -0,0 +1,70 @@
+package channel
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testDigest = "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+
+func writeChannelFile(t *testing.T, filename, content string) string {
+	path := filepath.Join(t.TempDir(), filename)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	yamlPath := writeChannelFile(t, "platform.yaml", `
+name: platform
+version: "2024.10"
+images:
+  - image: quay.io/acme/api@`+testDigest+`
+    tag: "2024.10"
+  - image: quay.io/acme/worker:2024.10@`+testDigest+`
+`)
+	ch, err := Load(yamlPath)
+	require.NoError(t, err)
+	assert.Equal(t, "platform", ch.Name)
+	assert.Equal(t, "2024.10", ch.Version)
+	require.Len(t, ch.Images, 2)
+	assert.Equal(t, "2024.10", ch.Images[0].Tag)
+	assert.True(t, strings.HasPrefix(ch.Digest, "sha256:"))
+
+	ref, err := ch.Images[1].Reference()
+	require.NoError(t, err)
+	assert.Equal(t, "quay.io/acme/worker@"+testDigest, ref.String())
+
+	jsonPath := writeChannelFile(t, "platform.json",
+		`{"name": "platform", "images": [{"image": "quay.io/acme/api@`+testDigest+`"}]}`)
+	ch, err = Load(jsonPath)
+	require.NoError(t, err)
+	assert.Len(t, ch.Images, 1)
+}
+
+func TestLoadRejectsInvalidChannels(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"unknown field", "name: platform\nimage:\n  - image: quay.io/acme/api@" + testDigest, "field image not found"},
+		{"no name", "images:\n  - image: quay.io/acme/api@" + testDigest, "name is required"},
+		{"no images", "name: platform", "lists no images"},
+		{"tag only", "name: platform\nimages:\n  - image: quay.io/acme/api:v1", "not pinned by digest"},
+		{"bad digest", "name: platform\nimages:\n  - image: quay.io/acme/api@sha256:abc", "invalid image"},
+		{"duplicate", "name: platform\nimages:\n  - image: quay.io/acme/api@" + testDigest + "\n  - image: quay.io/acme/api:v1@" + testDigest, "more than once"},
+		{"bad tag", "name: platform\nimages:\n  - image: quay.io/acme/api@" + testDigest + "\n    tag: -bad", "invalid tag"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(writeChannelFile(t, "channel.yaml", tt.content))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.want)
+		})
+	}
+}
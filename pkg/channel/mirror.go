@@ -0,0 +1,269 @@
+package channel
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strings"
+
+	"freightliner/pkg/attestation"
+	"freightliner/pkg/helper/errors"
+	"freightliner/pkg/helper/log"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+)
+
+// Image statuses in a mirror result
+const (
+	StatusMirrored    = "mirrored"
+	StatusPresent     = "present"
+	StatusWouldMirror = "would_mirror"
+	StatusFailed      = "failed"
+)
+
+// RemoteOptionsFunc returns the registry options for the repository of ref;
+// push is set for destination repositories, which may have to be created
+type RemoteOptionsFunc func(ctx context.Context, ref name.Reference, push bool) ([]remote.Option, error)
+
+// MirrorOptions configure Mirror
+type MirrorOptions struct {
+	// Destination is the registry and optional path prefix the images are
+	// mirrored under, keeping their source repository path
+	Destination string
+
+	// RemoteOptions returns the registry options for a repository; without it
+	// registries are accessed anonymously
+	RemoteOptions RemoteOptionsFunc
+
+	// Ledger records every image of the channel once it is verified in the
+	// destination (optional)
+	Ledger *attestation.Ledger
+
+	// DryRun only checks the sources and reports what would be mirrored
+	DryRun bool
+
+	Logger log.Logger
+}
+
+// Result is the outcome of mirroring one channel image
+type Result struct {
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+	Digest      string `json:"digest"`
+	Tag         string `json:"tag,omitempty"`
+	Status      string `json:"status"`
+	Error       string `json:"error,omitempty"`
+}
+
+// Mirror copies every image of ch to the destination without changing a
+// byte of any manifest, so the pinned digests resolve in the mirror. Images
+// already in the destination are verified instead of pushed again. Every
+// image is attempted; the returned error reports the images that failed.
+func Mirror(ctx context.Context, ch *Channel, opts MirrorOptions) ([]Result, error) {
+	if opts.Logger == nil {
+		opts.Logger = log.GetGlobalLogger()
+	}
+	if opts.RemoteOptions == nil {
+		opts.RemoteOptions = func(ctx context.Context, _ name.Reference, _ bool) ([]remote.Option, error) {
+			return []remote.Option{remote.WithContext(ctx)}, nil
+		}
+	}
+
+	results := make([]Result, 0, len(ch.Images))
+	failed := 0
+	for _, image := range ch.Images {
+		if err := ctx.Err(); err != nil {
+			return results, err
+		}
+
+		result, err := mirrorImage(ctx, image, opts)
+		if err != nil {
+			failed++
+			result.Status = StatusFailed
+			result.Error = err.Error()
+			opts.Logger.WithFields(map[string]interface{}{
+				"channel": ch.Name,
+				"source":  result.Source,
+				"error":   err.Error(),
+			}).Warn("Failed to mirror channel image")
+		} else {
+			opts.Logger.WithFields(map[string]interface{}{
+				"channel":     ch.Name,
+				"source":      result.Source,
+				"destination": result.Destination,
+				"status":      result.Status,
+			}).Info("Mirrored channel image")
+		}
+		results = append(results, result)
+	}
+
+	if failed > 0 {
+		return results, errors.Newf("failed to mirror %d of %d images of channel %s", failed, len(ch.Images), ch.Name)
+	}
+	return results, nil
+}
+
+// DestinationReference returns where ref is mirrored under destination
+func DestinationReference(destination string, ref name.Digest) (name.Digest, error) {
+	repo, err := name.NewRepository(strings.TrimSuffix(destination, "/") + "/" + ref.Context().RepositoryStr())
+	if err != nil {
+		return name.Digest{}, errors.InvalidInputf("invalid destination %q: %s", destination, err)
+	}
+	return repo.Digest(ref.DigestStr()), nil
+}
+
+// mirrorImage copies one image and verifies it in the destination
+func mirrorImage(ctx context.Context, image Image, opts MirrorOptions) (Result, error) {
+	result := Result{Source: image.Image, Tag: image.Tag}
+
+	src, err := image.Reference()
+	if err != nil {
+		return result, err
+	}
+	result.Source = src.String()
+	result.Digest = src.DigestStr()
+
+	dest, err := DestinationReference(opts.Destination, src)
+	if err != nil {
+		return result, err
+	}
+	result.Destination = dest.String()
+
+	srcOpts, err := opts.RemoteOptions(ctx, src, false)
+	if err != nil {
+		return result, errors.Wrapf(err, "failed to access %s", src.Context())
+	}
+	destOpts, err := opts.RemoteOptions(ctx, dest, !opts.DryRun)
+	if err != nil {
+		return result, errors.Wrapf(err, "failed to access %s", dest.Context())
+	}
+
+	// Fetching by digest verifies the manifest against the pinned digest
+	desc, err := remote.Get(src, srcOpts...)
+	if err != nil {
+		return result, errors.Wrap(err, "failed to fetch source manifest")
+	}
+	if desc.Digest.String() != src.DigestStr() {
+		return result, errors.Newf("source manifest digest %s does not match pinned digest %s", desc.Digest, src.DigestStr())
+	}
+
+	present, err := verifyDestination(dest, desc, destOpts)
+	switch {
+	case err != nil:
+		return result, err
+	case opts.DryRun && present:
+		result.Status = StatusPresent
+		return result, nil
+	case opts.DryRun:
+		result.Status = StatusWouldMirror
+		return result, nil
+	}
+
+	result.Status = StatusPresent
+	if !present {
+		if err := push(dest, desc, destOpts); err != nil {
+			return result, err
+		}
+		if present, err = verifyDestination(dest, desc, destOpts); err != nil {
+			return result, err
+		}
+		if !present {
+			return result, errors.Newf("pushed manifest %s is missing from the destination", dest)
+		}
+		result.Status = StatusMirrored
+	}
+
+	if image.Tag != "" {
+		tag := dest.Context().Tag(image.Tag)
+		if err := remote.Tag(tag, desc, destOpts...); err != nil {
+			return result, errors.Wrapf(err, "failed to tag %s", tag)
+		}
+		tagged, err := remote.Head(tag, destOpts...)
+		if err != nil {
+			return result, errors.Wrapf(err, "failed to verify tag %s", tag)
+		}
+		if tagged.Digest != desc.Digest {
+			return result, errors.Newf("tag %s points to %s instead of %s", tag, tagged.Digest, desc.Digest)
+		}
+	}
+
+	opts.Ledger.Record(attestation.Transfer{
+		Source:            src.String(),
+		Destination:       dest.String(),
+		SourceDigest:      desc.Digest.String(),
+		DestinationDigest: desc.Digest.String(),
+		Blobs:             referencedBlobs(desc),
+	})
+	return result, nil
+}
+
+// verifyDestination reports whether dest holds the manifest of desc byte for
+// byte. A manifest stored under the digest with other content is an error.
+func verifyDestination(dest name.Digest, desc *remote.Descriptor, destOpts []remote.Option) (bool, error) {
+	got, err := remote.Get(dest, destOpts...)
+	if err != nil {
+		if isNotFound(err) {
+			return false, nil
+		}
+		return false, errors.Wrap(err, "failed to fetch destination manifest")
+	}
+	if !bytes.Equal(got.Manifest, desc.Manifest) {
+		return false, errors.Newf("destination manifest %s differs from the source manifest", dest)
+	}
+	return true, nil
+}
+
+// push writes desc and everything it references to dest, keeping the raw
+// source manifests
+func push(dest name.Digest, desc *remote.Descriptor, destOpts []remote.Option) error {
+	if desc.MediaType.IsIndex() {
+		index, err := desc.ImageIndex()
+		if err != nil {
+			return errors.Wrap(err, "failed to read source index")
+		}
+		if err := remote.WriteIndex(dest, index, destOpts...); err != nil {
+			return errors.Wrap(err, "failed to push index")
+		}
+		return nil
+	}
+
+	img, err := desc.Image()
+	if err != nil {
+		return errors.Wrap(err, "failed to read source image")
+	}
+	if err := remote.Write(dest, img, destOpts...); err != nil {
+		return errors.Wrap(err, "failed to push image")
+	}
+	return nil
+}
+
+// referencedBlobs lists the blobs of an image manifest, or the manifests of
+// an index
+func referencedBlobs(desc *remote.Descriptor) []attestation.Blob {
+	blobs := []attestation.Blob{}
+	if desc.MediaType.IsIndex() {
+		if index, err := v1.ParseIndexManifest(bytes.NewReader(desc.Manifest)); err == nil {
+			for _, m := range index.Manifests {
+				blobs = append(blobs, attestation.Blob{Digest: m.Digest.String(), Size: m.Size})
+			}
+		}
+		return blobs
+	}
+
+	if manifest, err := v1.ParseManifest(bytes.NewReader(desc.Manifest)); err == nil {
+		blobs = append(blobs, attestation.Blob{Digest: manifest.Config.Digest.String(), Size: manifest.Config.Size})
+		for _, layer := range manifest.Layers {
+			blobs = append(blobs, attestation.Blob{Digest: layer.Digest.String(), Size: layer.Size})
+		}
+	}
+	return blobs
+}
+
+// isNotFound reports whether err is a registry 404
+func isNotFound(err error) bool {
+	var terr *transport.Error
+	return errors.As(err, &terr) && terr.StatusCode == http.StatusNotFound
+}
@@ -0,0 +1,143 @@
+package channel
+
+import (
+	"context"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"freightliner/pkg/attestation"
+	"freightliner/pkg/helper/log"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testRegistry starts an in-memory registry and returns its host
+func testRegistry(t *testing.T) string {
+	server := httptest.NewServer(registry.New())
+	t.Cleanup(server.Close)
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	return u.Host
+}
+
+func parseReference(t *testing.T, s string) name.Reference {
+	ref, err := name.ParseReference(s)
+	require.NoError(t, err)
+	return ref
+}
+
+// testChannel pushes an image and an index to source and returns a channel
+// listing both
+func testChannel(t *testing.T, source string) *Channel {
+	img, err := random.Image(128, 2)
+	require.NoError(t, err)
+	imgRef, err := name.NewTag(source + "/acme/api:v1")
+	require.NoError(t, err)
+	require.NoError(t, remote.Write(imgRef, img))
+	imgDigest, err := img.Digest()
+	require.NoError(t, err)
+
+	index, err := random.Index(64, 1, 2)
+	require.NoError(t, err)
+	indexRef, err := name.NewTag(source + "/acme/worker:v1")
+	require.NoError(t, err)
+	require.NoError(t, remote.WriteIndex(indexRef, index))
+	indexDigest, err := index.Digest()
+	require.NoError(t, err)
+
+	return &Channel{
+		Name:    "platform",
+		Version: "2024.10",
+		Images: []Image{
+			{Image: source + "/acme/api@" + imgDigest.String(), Tag: "2024.10"},
+			{Image: source + "/acme/worker:v1@" + indexDigest.String()},
+		},
+	}
+}
+
+func TestMirror(t *testing.T) {
+	source, dest := testRegistry(t), testRegistry(t)
+	ch := testChannel(t, source)
+	logger := log.NewBasicLogger(log.ErrorLevel)
+	ctx := context.Background()
+
+	ledger := attestation.NewLedger()
+	results, err := Mirror(ctx, ch, MirrorOptions{Destination: dest + "/releases", Ledger: ledger, Logger: logger})
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	for i, result := range results {
+		assert.Equal(t, StatusMirrored, result.Status, result.Source)
+
+		src, err := ch.Images[i].Reference()
+		require.NoError(t, err)
+		assert.Equal(t, dest+"/releases/"+src.Context().RepositoryStr()+"@"+src.DigestStr(), result.Destination)
+
+		want, err := remote.Get(src)
+		require.NoError(t, err)
+		got, err := remote.Get(parseReference(t, result.Destination))
+		require.NoError(t, err)
+		assert.Equal(t, want.Manifest, got.Manifest, "manifest is mirrored byte for byte")
+	}
+
+	tagged, err := remote.Head(parseReference(t, dest+"/releases/acme/api:2024.10"))
+	require.NoError(t, err)
+	assert.Equal(t, results[0].Digest, tagged.Digest.String())
+
+	transfers := ledger.Transfers()
+	require.Len(t, transfers, 2)
+	assert.Equal(t, transfers[0].SourceDigest, transfers[0].DestinationDigest)
+	assert.Len(t, transfers[0].Blobs, 3, "config and two layers")
+	assert.Len(t, transfers[1].Blobs, 2, "two platform manifests")
+
+	// A second run verifies the mirrored images without pushing them again
+	results, err = Mirror(ctx, ch, MirrorOptions{Destination: dest + "/releases", Logger: logger})
+	require.NoError(t, err)
+	assert.Equal(t, StatusPresent, results[0].Status)
+	assert.Equal(t, StatusPresent, results[1].Status)
+}
+
+func TestMirrorDryRun(t *testing.T) {
+	source, dest := testRegistry(t), testRegistry(t)
+	ch := testChannel(t, source)
+
+	ledger := attestation.NewLedger()
+	results, err := Mirror(context.Background(), ch, MirrorOptions{
+		Destination: dest,
+		Ledger:      ledger,
+		DryRun:      true,
+		Logger:      log.NewBasicLogger(log.ErrorLevel),
+	})
+	require.NoError(t, err)
+	for _, result := range results {
+		assert.Equal(t, StatusWouldMirror, result.Status)
+		_, err := remote.Head(parseReference(t, result.Destination))
+		assert.Error(t, err, "dry run pushes nothing")
+	}
+	assert.Empty(t, ledger.Transfers())
+}
+
+func TestMirrorReportsFailedImages(t *testing.T) {
+	source, dest := testRegistry(t), testRegistry(t)
+	ch := testChannel(t, source)
+	ch.Images = append([]Image{{Image: source + "/acme/missing@" + testDigest}}, ch.Images...)
+
+	ledger := attestation.NewLedger()
+	results, err := Mirror(context.Background(), ch, MirrorOptions{
+		Destination: dest,
+		Ledger:      ledger,
+		Logger:      log.NewBasicLogger(log.ErrorLevel),
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to mirror 1 of 3 images")
+	require.Len(t, results, 3)
+	assert.Equal(t, StatusFailed, results[0].Status)
+	assert.NotEmpty(t, results[0].Error)
+	assert.Equal(t, StatusMirrored, results[1].Status, "later images are still mirrored")
+	assert.Len(t, ledger.Transfers(), 2)
+}
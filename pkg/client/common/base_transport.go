@@ -2,11 +2,11 @@ package common
 
 import (
 	"context"
-	"net"
 	"net/http"
 	"time"
 
 	"freightliner/pkg/helper/log"
+	"freightliner/pkg/network"
 )
 
 // BaseTransport provides common HTTP transport functionality
@@ -29,10 +29,8 @@ func NewBaseTransport(logger log.Logger) *BaseTransport {
 func (t *BaseTransport) CreateDefaultTransport() *http.Transport {
 	return &http.Transport{
 		Proxy: http.ProxyFromEnvironment,
-		DialContext: (&net.Dialer{
-			Timeout:   30 * time.Second,
-			KeepAlive: 60 * time.Second, // Increased for better connection reuse
-		}).DialContext,
+		// Dual-stack dialing with Happy Eyeballs, honoring --resolve overrides
+		DialContext:           network.DialContext(network.NewDialer(30*time.Second, 60*time.Second)),
 		ForceAttemptHTTP2:     true,
 		MaxIdleConns:          200,               // Increased for high-throughput scenarios
 		MaxIdleConnsPerHost:   20,                // Optimize per-host connection pooling
@@ -8,6 +8,7 @@ import (
 
 	"freightliner/pkg/helper/errors"
 	"freightliner/pkg/helper/log"
+	"freightliner/pkg/helper/roundtrip"
 
 	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
@@ -162,9 +163,9 @@ func (c *EnhancedClient) GetTransport(repositoryName string) (http.RoundTripper,
 	}
 
 	// Create authentication if needed
-	var authTransport http.RoundTripper = baseTransport
+	var authTransport http.RoundTripper = roundtrip.Wrap(baseTransport)
 	if c.authenticator != nil {
-		authTransport = TransportWithAuth(baseTransport, c.authenticator, repository)
+		authTransport = TransportWithAuth(authTransport, c.authenticator, repository)
 	}
 
 	// Add logging if enabled
@@ -29,6 +29,9 @@ const (
 	// DockerHubIndexServer is the Docker Hub index server
 	DockerHubIndexServer = "index.docker.io"
 
+	// DockerHubAPIEndpoint is the Docker Hub web API serving repository descriptions
+	DockerHubAPIEndpoint = "https://hub.docker.com/v2"
+
 	// DefaultDockerHubLibrary is the library namespace for official images
 	DefaultDockerHubLibrary = "library"
 
@@ -49,6 +52,7 @@ type Client struct {
 	authenticator authn.Authenticator
 	httpClient    *http.Client
 	retryConfig   RetryConfig
+	hubAPIURL     string
 }
 
 // ClientOptions provides configuration for connecting to Docker Hub
@@ -146,6 +150,7 @@ func NewClient(opts ClientOptions) (*Client, error) {
 		authenticator: auth,
 		httpClient:    httpClient,
 		retryConfig:   retryConfig,
+		hubAPIURL:     DockerHubAPIEndpoint,
 	}, nil
 }
 
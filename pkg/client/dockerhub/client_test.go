@@ -2,6 +2,8 @@ package dockerhub
 
 import (
 	"context"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"freightliner/pkg/helper/log"
@@ -213,3 +215,37 @@ type testError struct {
 func (e *testError) Error() string {
 	return e.msg
 }
+
+func TestGetRepositoryMetadata(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repositories/library/nginx/" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"description":"Official build of Nginx.","full_description":"# Nginx"}`))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(ClientOptions{Logger: log.NewBasicLogger(log.ErrorLevel)})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	client.hubAPIURL = server.URL
+
+	repo, err := client.GetRepository(context.Background(), "nginx")
+	if err != nil {
+		t.Fatalf("Failed to get repository: %v", err)
+	}
+	metadata, err := repo.(*Repository).GetRepositoryMetadata(context.Background())
+	if err != nil {
+		t.Fatalf("GetRepositoryMetadata() error = %v", err)
+	}
+	if metadata.Description != "Official build of Nginx." || metadata.Readme != "# Nginx" {
+		t.Errorf("GetRepositoryMetadata() = %+v", metadata)
+	}
+
+	repo, _ = client.GetRepository(context.Background(), "someone/missing")
+	if _, err := repo.(*Repository).GetRepositoryMetadata(context.Background()); err == nil {
+		t.Error("GetRepositoryMetadata() for a missing repository returned no error")
+	}
+}
@@ -0,0 +1,59 @@
+package dockerhub
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"freightliner/pkg/helper/errors"
+	"freightliner/pkg/interfaces"
+)
+
+// GetRepositoryMetadata returns the repository's short description and full
+// description from the Docker Hub API - implements interfaces.RepositoryMetadataReader.
+// Docker Hub only accepts description updates from its web session tokens, so
+// repositories are not metadata writers.
+func (r *Repository) GetRepositoryMetadata(ctx context.Context) (*interfaces.RepositoryMetadata, error) {
+	apiURL := fmt.Sprintf("%s/repositories/%s/", r.client.hubAPIURL, r.name)
+
+	var metadata *interfaces.RepositoryMetadata
+	err := r.client.executeWithRetry(ctx, "GetRepositoryMetadata", func() error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, apiURL, nil)
+		if err != nil {
+			return errors.Wrap(err, "failed to create repository request")
+		}
+
+		resp, err := r.client.httpClient.Do(req)
+		if err != nil {
+			return errors.Wrap(err, "failed to get repository")
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode == http.StatusNotFound {
+			return errors.NotFoundf("repository %s not found", r.name)
+		}
+		if resp.StatusCode != http.StatusOK {
+			body, _ := io.ReadAll(resp.Body)
+			return errors.InvalidInputf("get repository failed: %s - %s", resp.Status, string(body))
+		}
+
+		var result struct {
+			Description     string `json:"description"`
+			FullDescription string `json:"full_description"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			return errors.Wrap(err, "failed to parse repository response")
+		}
+		metadata = &interfaces.RepositoryMetadata{
+			Description: result.Description,
+			Readme:      result.FullDescription,
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return metadata, nil
+}
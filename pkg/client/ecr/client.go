@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
+	"sync"
 
 	"freightliner/pkg/helper/errors"
 	"freightliner/pkg/helper/log"
@@ -13,6 +14,7 @@ import (
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
 	awsecr "github.com/aws/aws-sdk-go-v2/service/ecr"
 	ecrtypes "github.com/aws/aws-sdk-go-v2/service/ecr/types"
@@ -45,6 +47,10 @@ type Client struct {
 	accountID    string
 	logger       log.Logger
 	transportOpt remote.Option
+
+	// pullThroughRules caches the registry's pull through cache rules
+	pullThroughRules []PullThroughCacheRule
+	pullThroughMu    sync.Mutex
 }
 
 // ClientOptions provides configuration for connecting to ECR
@@ -64,6 +70,12 @@ type ClientOptions struct {
 	// CredentialsFile is the path to AWS credentials file (optional)
 	CredentialsFile string
 
+	// AccessKeyID, SecretAccessKey and SessionToken are static credentials
+	// used instead of the default chain (optional)
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+
 	// Logger is the logger to use
 	Logger log.Logger
 }
@@ -124,6 +136,16 @@ func createAWSConfig(ctx context.Context, opts *ClientOptions) (aws.Config, erro
 	if opts.Profile != "" {
 		configOpts = append(configOpts, config.WithSharedConfigProfile(opts.Profile))
 	}
+	if opts.CredentialsFile != "" {
+		configOpts = append(configOpts, config.WithSharedCredentialsFiles([]string{opts.CredentialsFile}))
+	}
+
+	// Static credentials take precedence over anything in the environment
+	if opts.AccessKeyID != "" && opts.SecretAccessKey != "" {
+		configOpts = append(configOpts, config.WithCredentialsProvider(
+			credentials.NewStaticCredentialsProvider(opts.AccessKeyID, opts.SecretAccessKey, opts.SessionToken),
+		))
+	}
 
 	cfg, err := config.LoadDefaultConfig(ctx, configOpts...)
 	if err != nil {
@@ -305,13 +327,19 @@ func (c *Client) GetRepository(ctx context.Context, repoName string) (interfaces
 
 // CreateRepository creates a new repository in ECR
 func (c *Client) CreateRepository(ctx context.Context, repoName string, tags map[string]string) (interfaces.Repository, error) {
+	return c.CreateRepositoryWithSettings(ctx, repoName, interfaces.RepositorySettings{Tags: tags})
+}
+
+// CreateRepositoryWithSettings creates a new repository in ECR with tag
+// immutability, scan-on-push and encryption applied at creation time
+func (c *Client) CreateRepositoryWithSettings(ctx context.Context, repoName string, settings interfaces.RepositorySettings) (interfaces.Repository, error) {
 	if repoName == "" {
 		return nil, errors.InvalidInputf("repository name cannot be empty")
 	}
 
 	// Convert tags to ECR tag format
-	ecrTags := make([]ecrtypes.Tag, 0, len(tags))
-	for k, v := range tags {
+	ecrTags := make([]ecrtypes.Tag, 0, len(settings.Tags))
+	for k, v := range settings.Tags {
 		key, value := k, v
 		ecrTags = append(ecrTags, ecrtypes.Tag{
 			Key:   &key,
@@ -325,6 +353,23 @@ func (c *Client) CreateRepository(ctx context.Context, repoName string, tags map
 		Tags:           ecrTags,
 	}
 
+	if settings.ImmutableTags {
+		input.ImageTagMutability = ecrtypes.ImageTagMutabilityImmutable
+	}
+
+	if settings.ScanOnPush {
+		input.ImageScanningConfiguration = &ecrtypes.ImageScanningConfiguration{ScanOnPush: true}
+	}
+
+	if settings.EncryptionType != "" {
+		input.EncryptionConfiguration = &ecrtypes.EncryptionConfiguration{
+			EncryptionType: ecrtypes.EncryptionType(settings.EncryptionType),
+		}
+		if settings.KMSKey != "" {
+			input.EncryptionConfiguration.KmsKey = aws.String(settings.KMSKey)
+		}
+	}
+
 	if c.accountID != "" {
 		input.RegistryId = aws.String(c.accountID)
 	}
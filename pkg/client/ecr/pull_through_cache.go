@@ -0,0 +1,152 @@
+package ecr
+
+import (
+	"context"
+	"strings"
+
+	"freightliner/pkg/helper/errors"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsecr "github.com/aws/aws-sdk-go-v2/service/ecr"
+)
+
+// PullThroughCacheRootPrefix is the repository prefix of rules caching
+// upstream repositories under their own names
+const PullThroughCacheRootPrefix = "ROOT"
+
+// PullThroughCacheDescriberAPI is implemented by ECR clients that can read pull
+// through cache rules. It is kept separate from ECRServiceAPI so existing mocks
+// do not need to grow a method.
+type PullThroughCacheDescriberAPI interface {
+	DescribePullThroughCacheRules(ctx context.Context, params *awsecr.DescribePullThroughCacheRulesInput, optFns ...func(*awsecr.Options)) (*awsecr.DescribePullThroughCacheRulesOutput, error)
+}
+
+// PullThroughCacheRule caches an upstream registry under a repository prefix
+type PullThroughCacheRule struct {
+	// EcrRepositoryPrefix is the prefix of the caching repositories, or
+	// PullThroughCacheRootPrefix for repositories without one
+	EcrRepositoryPrefix string
+
+	// UpstreamRegistryURL is the registry whose images are cached
+	UpstreamRegistryURL string
+
+	// UpstreamRepositoryPrefix is prepended to the upstream repository
+	// name; empty caches upstream repositories by their own names
+	UpstreamRepositoryPrefix string
+}
+
+// UpstreamRepository returns the upstream repository cached as repoName, if
+// the rule covers it
+func (r PullThroughCacheRule) UpstreamRepository(repoName string) (string, bool) {
+	rest := repoName
+	if r.EcrRepositoryPrefix != PullThroughCacheRootPrefix {
+		var ok bool
+		rest, ok = strings.CutPrefix(repoName, r.EcrRepositoryPrefix+"/")
+		if !ok || rest == "" {
+			return "", false
+		}
+	}
+
+	if r.UpstreamRepositoryPrefix != "" && r.UpstreamRepositoryPrefix != PullThroughCacheRootPrefix {
+		return r.UpstreamRepositoryPrefix + "/" + rest, true
+	}
+	return rest, true
+}
+
+// Caches reports whether repoName caches sourceRepo of sourceRegistry
+func (r PullThroughCacheRule) Caches(repoName, sourceRegistry, sourceRepo string) bool {
+	upstreamRepo, ok := r.UpstreamRepository(repoName)
+	if !ok {
+		return false
+	}
+	upstream := canonicalUpstreamRegistry(r.UpstreamRegistryURL)
+	if upstream != canonicalUpstreamRegistry(sourceRegistry) {
+		return false
+	}
+	return canonicalUpstreamRepository(upstream, upstreamRepo) == canonicalUpstreamRepository(upstream, sourceRepo)
+}
+
+// FindPullThroughCache returns the rule whose cache of sourceRepo from
+// sourceRegistry is repoName
+func FindPullThroughCache(rules []PullThroughCacheRule, repoName, sourceRegistry, sourceRepo string) (PullThroughCacheRule, bool) {
+	for _, rule := range rules {
+		if rule.Caches(repoName, sourceRegistry, sourceRepo) {
+			return rule, true
+		}
+	}
+	return PullThroughCacheRule{}, false
+}
+
+// canonicalUpstreamRegistry normalizes registry URLs and Docker Hub's aliases
+func canonicalUpstreamRegistry(registry string) string {
+	registry = strings.ToLower(registry)
+	registry = strings.TrimPrefix(registry, "https://")
+	registry = strings.TrimPrefix(registry, "http://")
+	registry = strings.TrimSuffix(registry, "/")
+	switch registry {
+	case "docker.io", "index.docker.io", "registry-1.docker.io", "registry.hub.docker.com":
+		return "docker.io"
+	}
+	return registry
+}
+
+// canonicalUpstreamRepository adds the implicit "library/" of Docker Hub's
+// official images
+func canonicalUpstreamRepository(registry, repo string) string {
+	if registry == "docker.io" && !strings.Contains(repo, "/") {
+		return "library/" + repo
+	}
+	return repo
+}
+
+// GetPullThroughCacheRules returns the registry's pull through cache rules.
+// They are read once per client.
+func (c *Client) GetPullThroughCacheRules(ctx context.Context) ([]PullThroughCacheRule, error) {
+	c.pullThroughMu.Lock()
+	defer c.pullThroughMu.Unlock()
+	if c.pullThroughRules != nil {
+		return c.pullThroughRules, nil
+	}
+
+	describer, ok := c.ecr.(PullThroughCacheDescriberAPI)
+	if !ok {
+		return nil, errors.NotImplementedf("ECR client does not support DescribePullThroughCacheRules")
+	}
+
+	rules := []PullThroughCacheRule{}
+	input := &awsecr.DescribePullThroughCacheRulesInput{}
+	for {
+		resp, err := describer.DescribePullThroughCacheRules(ctx, input)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to describe ECR pull through cache rules")
+		}
+		for _, rule := range resp.PullThroughCacheRules {
+			rules = append(rules, PullThroughCacheRule{
+				EcrRepositoryPrefix:      aws.ToString(rule.EcrRepositoryPrefix),
+				UpstreamRegistryURL:      aws.ToString(rule.UpstreamRegistryUrl),
+				UpstreamRepositoryPrefix: aws.ToString(rule.UpstreamRepositoryPrefix),
+			})
+		}
+		if resp.NextToken == nil {
+			break
+		}
+		input.NextToken = resp.NextToken
+	}
+
+	c.pullThroughRules = rules
+	return rules, nil
+}
+
+// PullThroughCacheFor returns the pull through cache rule that already
+// mirrors sourceRepo of sourceRegistry into repoName, if there is one
+func (c *Client) PullThroughCacheFor(ctx context.Context, repoName, sourceRegistry, sourceRepo string) (*PullThroughCacheRule, error) {
+	rules, err := c.GetPullThroughCacheRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rule, ok := FindPullThroughCache(rules, repoName, sourceRegistry, sourceRepo)
+	if !ok {
+		return nil, nil
+	}
+	return &rule, nil
+}
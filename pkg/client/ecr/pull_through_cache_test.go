@@ -0,0 +1,104 @@
+package ecr
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"freightliner/pkg/helper/log"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsecr "github.com/aws/aws-sdk-go-v2/service/ecr"
+	ecrtypes "github.com/aws/aws-sdk-go-v2/service/ecr/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakePullThroughCacheDescriber implements ECRServiceAPI and
+// PullThroughCacheDescriberAPI, serving one rule per page
+type fakePullThroughCacheDescriber struct {
+	ECRServiceAPI
+	rules []ecrtypes.PullThroughCacheRule
+	calls int
+	err   error
+}
+
+func (f *fakePullThroughCacheDescriber) DescribePullThroughCacheRules(ctx context.Context, params *awsecr.DescribePullThroughCacheRulesInput, optFns ...func(*awsecr.Options)) (*awsecr.DescribePullThroughCacheRulesOutput, error) {
+	f.calls++
+	if f.err != nil {
+		return nil, f.err
+	}
+	page := 0
+	if params.NextToken != nil {
+		fmt.Sscan(*params.NextToken, &page)
+	}
+	out := &awsecr.DescribePullThroughCacheRulesOutput{}
+	if page < len(f.rules) {
+		out.PullThroughCacheRules = f.rules[page : page+1]
+	}
+	if page+1 < len(f.rules) {
+		out.NextToken = aws.String(fmt.Sprint(page + 1))
+	}
+	return out, nil
+}
+
+func TestPullThroughCacheRuleCaches(t *testing.T) {
+	dockerHub := PullThroughCacheRule{EcrRepositoryPrefix: "docker-hub", UpstreamRegistryURL: "registry-1.docker.io"}
+	quay := PullThroughCacheRule{EcrRepositoryPrefix: "quay", UpstreamRegistryURL: "quay.io", UpstreamRepositoryPrefix: "team"}
+	root := PullThroughCacheRule{EcrRepositoryPrefix: PullThroughCacheRootPrefix, UpstreamRegistryURL: "ghcr.io"}
+
+	tests := []struct {
+		name           string
+		rule           PullThroughCacheRule
+		repo           string
+		sourceRegistry string
+		sourceRepo     string
+		want           bool
+	}{
+		{"docker hub alias", dockerHub, "docker-hub/library/nginx", "docker.io", "library/nginx", true},
+		{"docker hub official image", dockerHub, "docker-hub/library/nginx", "index.docker.io", "nginx", true},
+		{"other repository", dockerHub, "docker-hub/library/redis", "docker.io", "library/nginx", false},
+		{"outside prefix", dockerHub, "mirror/library/nginx", "docker.io", "library/nginx", false},
+		{"other upstream", dockerHub, "docker-hub/library/nginx", "quay.io", "library/nginx", false},
+		{"upstream repository prefix", quay, "quay/app", "quay.io", "team/app", true},
+		{"root prefix", root, "org/app", "ghcr.io", "org/app", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.rule.Caches(tt.repo, tt.sourceRegistry, tt.sourceRepo))
+		})
+	}
+}
+
+func TestPullThroughCacheFor(t *testing.T) {
+	describer := &fakePullThroughCacheDescriber{
+		rules: []ecrtypes.PullThroughCacheRule{
+			{EcrRepositoryPrefix: aws.String("quay"), UpstreamRegistryUrl: aws.String("quay.io")},
+			{EcrRepositoryPrefix: aws.String("docker-hub"), UpstreamRegistryUrl: aws.String("registry-1.docker.io")},
+		},
+	}
+	client := &Client{ecr: describer, region: "us-east-1", accountID: "123456789012", logger: log.NewBasicLogger(log.ErrorLevel)}
+
+	rule, err := client.PullThroughCacheFor(context.Background(), "docker-hub/library/nginx", "docker.io", "library/nginx")
+	require.NoError(t, err)
+	require.NotNil(t, rule)
+	assert.Equal(t, "docker-hub", rule.EcrRepositoryPrefix)
+
+	rule, err = client.PullThroughCacheFor(context.Background(), "team/nginx", "docker.io", "library/nginx")
+	require.NoError(t, err)
+	assert.Nil(t, rule)
+
+	assert.Equal(t, 2, describer.calls, "rules are read across pages once per client")
+}
+
+func TestPullThroughCacheForErrors(t *testing.T) {
+	describer := &fakePullThroughCacheDescriber{err: fmt.Errorf("access denied")}
+	client := &Client{ecr: describer, logger: log.NewBasicLogger(log.ErrorLevel)}
+	_, err := client.PullThroughCacheFor(context.Background(), "docker-hub/library/nginx", "docker.io", "library/nginx")
+	assert.Error(t, err)
+
+	client = &Client{ecr: &fakeECRServiceOnly{}, logger: log.NewBasicLogger(log.ErrorLevel)}
+	_, err = client.GetPullThroughCacheRules(context.Background())
+	assert.Error(t, err)
+}
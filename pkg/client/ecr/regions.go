@@ -0,0 +1,118 @@
+package ecr
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"freightliner/pkg/helper/errors"
+	"freightliner/pkg/helper/log"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsecr "github.com/aws/aws-sdk-go-v2/service/ecr"
+	ecrtypes "github.com/aws/aws-sdk-go-v2/service/ecr/types"
+)
+
+// DefaultRegions are the commercial AWS regions probed by region discovery
+// when no candidate regions are given. Opt-in regions that are not enabled
+// for the account fail to authenticate and are skipped.
+var DefaultRegions = []string{
+	"us-east-1", "us-east-2", "us-west-1", "us-west-2",
+	"ca-central-1", "sa-east-1",
+	"eu-west-1", "eu-west-2", "eu-west-3", "eu-central-1", "eu-north-1",
+	"ap-south-1", "ap-northeast-1", "ap-northeast-2", "ap-northeast-3",
+	"ap-southeast-1", "ap-southeast-2",
+}
+
+// RegistryHost returns the ECR registry hostname for an account and region
+func RegistryHost(accountID, region string) string {
+	return fmt.Sprintf("%s.dkr.ecr.%s.amazonaws.com", accountID, region)
+}
+
+// HasRepository reports whether the repository exists in the client's registry
+func (c *Client) HasRepository(ctx context.Context, repoName string) (bool, error) {
+	input := &awsecr.DescribeRepositoriesInput{
+		RepositoryNames: []string{repoName},
+	}
+	if c.accountID != "" {
+		input.RegistryId = aws.String(c.accountID)
+	}
+
+	resp, err := c.ecr.DescribeRepositories(ctx, input)
+	if err != nil {
+		var notFound *ecrtypes.RepositoryNotFoundException
+		if errors.As(err, &notFound) {
+			return false, nil
+		}
+		return false, errors.Wrap(err, "failed to describe ECR repository")
+	}
+
+	return len(resp.Repositories) > 0, nil
+}
+
+// DiscoverRegions returns the regions, in candidate order, whose registry for
+// opts.AccountID contains repository. DefaultRegions are probed when
+// candidates is empty; opts.Region is ignored.
+func DiscoverRegions(ctx context.Context, opts ClientOptions, repository string, candidates []string) ([]string, error) {
+	return discoverRegions(ctx, opts.Logger, repository, candidates, func(region string) (*Client, error) {
+		regional := opts
+		regional.Region = region
+		return NewClient(regional)
+	})
+}
+
+// discoverRegions probes candidate regions concurrently with clients from newClient
+func discoverRegions(
+	ctx context.Context,
+	logger log.Logger,
+	repository string,
+	candidates []string,
+	newClient func(region string) (*Client, error),
+) ([]string, error) {
+	if logger == nil {
+		logger = log.NewBasicLogger(log.InfoLevel)
+	}
+	if len(candidates) == 0 {
+		candidates = DefaultRegions
+	}
+
+	found := make([]bool, len(candidates))
+	var wg sync.WaitGroup
+	for i, region := range candidates {
+		wg.Add(1)
+		go func(i int, region string) {
+			defer wg.Done()
+
+			client, err := newClient(region)
+			if err == nil {
+				found[i], err = client.HasRepository(ctx, repository)
+			}
+			if err != nil {
+				logger.WithFields(map[string]interface{}{
+					"region":     region,
+					"repository": repository,
+					"error":      err.Error(),
+				}).Debug("Skipping region during discovery")
+			}
+		}(i, region)
+	}
+	wg.Wait()
+
+	var regions []string
+	for i, region := range candidates {
+		if found[i] {
+			regions = append(regions, region)
+		}
+	}
+
+	if len(regions) == 0 {
+		return nil, errors.NotFoundf("repository %s not found in any of %d regions", repository, len(candidates))
+	}
+
+	logger.WithFields(map[string]interface{}{
+		"repository": repository,
+		"regions":    regions,
+	}).Info("Discovered ECR regions containing repository")
+
+	return regions, nil
+}
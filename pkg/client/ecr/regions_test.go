@@ -0,0 +1,88 @@
+package ecr
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"freightliner/pkg/helper/log"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsecr "github.com/aws/aws-sdk-go-v2/service/ecr"
+	ecrtypes "github.com/aws/aws-sdk-go-v2/service/ecr/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRepositoryDescriber answers DescribeRepositories for a fixed set of repositories
+type fakeRepositoryDescriber struct {
+	ECRServiceAPI
+	repositories map[string]bool
+	err          error
+}
+
+func (f *fakeRepositoryDescriber) DescribeRepositories(ctx context.Context, params *awsecr.DescribeRepositoriesInput, optFns ...func(*awsecr.Options)) (*awsecr.DescribeRepositoriesOutput, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	name := params.RepositoryNames[0]
+	if !f.repositories[name] {
+		return nil, &ecrtypes.RepositoryNotFoundException{Message: aws.String("not found")}
+	}
+	return &awsecr.DescribeRepositoriesOutput{
+		Repositories: []ecrtypes.Repository{{RepositoryName: aws.String(name)}},
+	}, nil
+}
+
+func TestRegistryHost(t *testing.T) {
+	host := RegistryHost("123456789012", "eu-west-1")
+	assert.Equal(t, "123456789012.dkr.ecr.eu-west-1.amazonaws.com", host)
+
+	account, region, ok := ParseRegistryHost(host)
+	assert.True(t, ok)
+	assert.Equal(t, "123456789012", account)
+	assert.Equal(t, "eu-west-1", region)
+}
+
+func TestHasRepository(t *testing.T) {
+	logger := log.NewBasicLogger(log.ErrorLevel)
+	client := &Client{ecr: &fakeRepositoryDescriber{repositories: map[string]bool{"team/app": true}}, logger: logger}
+
+	exists, err := client.HasRepository(context.Background(), "team/app")
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	exists, err = client.HasRepository(context.Background(), "team/other")
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	client.ecr = &fakeRepositoryDescriber{err: fmt.Errorf("access denied")}
+	_, err = client.HasRepository(context.Background(), "team/app")
+	assert.Error(t, err)
+}
+
+func TestDiscoverRegions(t *testing.T) {
+	logger := log.NewBasicLogger(log.ErrorLevel)
+	registries := map[string]ECRServiceAPI{
+		"us-west-2":    &fakeRepositoryDescriber{repositories: map[string]bool{"team/app": true}},
+		"eu-west-1":    &fakeRepositoryDescriber{repositories: map[string]bool{"team/app": true}},
+		"us-east-1":    &fakeRepositoryDescriber{repositories: map[string]bool{}},
+		"ap-east-1":    &fakeRepositoryDescriber{err: fmt.Errorf("UnrecognizedClientException")},
+		"eu-central-1": nil,
+	}
+	newClient := func(region string) (*Client, error) {
+		api := registries[region]
+		if api == nil {
+			return nil, fmt.Errorf("no credentials for %s", region)
+		}
+		return &Client{ecr: api, region: region, logger: logger}, nil
+	}
+
+	candidates := []string{"eu-west-1", "us-east-1", "ap-east-1", "eu-central-1", "us-west-2"}
+	regions, err := discoverRegions(context.Background(), logger, "team/app", candidates, newClient)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"eu-west-1", "us-west-2"}, regions)
+
+	_, err = discoverRegions(context.Background(), logger, "team/missing", candidates, newClient)
+	assert.Error(t, err)
+}
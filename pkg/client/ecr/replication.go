@@ -0,0 +1,237 @@
+package ecr
+
+import (
+	"context"
+	"strings"
+
+	"freightliner/pkg/helper/errors"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsecr "github.com/aws/aws-sdk-go-v2/service/ecr"
+	ecrtypes "github.com/aws/aws-sdk-go-v2/service/ecr/types"
+)
+
+// Limits enforced by ECR on registry replication configurations
+const (
+	MaxReplicationRules        = 10
+	MaxReplicationDestinations = 25
+	MaxRepositoryFilters       = 100
+)
+
+// RepositoryFilterTypePrefixMatch is the only filter type ECR replication supports
+const RepositoryFilterTypePrefixMatch = "PREFIX_MATCH"
+
+// RegistryDescriberAPI is implemented by ECR clients that can read registry-level settings.
+// It is kept separate from ECRServiceAPI so existing mocks do not need to grow a method.
+type RegistryDescriberAPI interface {
+	DescribeRegistry(ctx context.Context, params *awsecr.DescribeRegistryInput, optFns ...func(*awsecr.Options)) (*awsecr.DescribeRegistryOutput, error)
+}
+
+// ReplicationConfiguration mirrors the document accepted by
+// `aws ecr put-replication-configuration --replication-configuration`
+type ReplicationConfiguration struct {
+	Rules []ReplicationRule `json:"rules"`
+}
+
+// ReplicationRule is a single native ECR replication rule
+type ReplicationRule struct {
+	Destinations      []ReplicationDestination `json:"destinations"`
+	RepositoryFilters []RepositoryFilter       `json:"repositoryFilters,omitempty"`
+}
+
+// ReplicationDestination is a target region and registry for native replication
+type ReplicationDestination struct {
+	Region     string `json:"region"`
+	RegistryID string `json:"registryId"`
+}
+
+// RepositoryFilter limits a replication rule to repositories with a name prefix
+type RepositoryFilter struct {
+	Filter     string `json:"filter"`
+	FilterType string `json:"filterType"`
+}
+
+// Covers reports whether any rule replicates the repository to the given region and registry
+func (c *ReplicationConfiguration) Covers(repoName, region, registryID string) bool {
+	if c == nil {
+		return false
+	}
+
+	for _, rule := range c.Rules {
+		if !rule.matchesRepository(repoName) {
+			continue
+		}
+		for _, dest := range rule.Destinations {
+			if dest.Region == region && (registryID == "" || dest.RegistryID == registryID) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// matchesRepository applies the rule's prefix filters; a rule without filters matches everything
+func (r ReplicationRule) matchesRepository(repoName string) bool {
+	if len(r.RepositoryFilters) == 0 {
+		return true
+	}
+
+	for _, filter := range r.RepositoryFilters {
+		if filter.FilterType == RepositoryFilterTypePrefixMatch && strings.HasPrefix(repoName, filter.Filter) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Validate checks the configuration against the limits ECR enforces
+func (c *ReplicationConfiguration) Validate() error {
+	if len(c.Rules) > MaxReplicationRules {
+		return errors.InvalidInputf("ECR supports at most %d replication rules, got %d", MaxReplicationRules, len(c.Rules))
+	}
+
+	destinations := make(map[ReplicationDestination]struct{})
+	for i, rule := range c.Rules {
+		if len(rule.Destinations) == 0 {
+			return errors.InvalidInputf("replication rule %d has no destinations", i)
+		}
+		if len(rule.RepositoryFilters) > MaxRepositoryFilters {
+			return errors.InvalidInputf("replication rule %d has %d repository filters, ECR supports at most %d",
+				i, len(rule.RepositoryFilters), MaxRepositoryFilters)
+		}
+		for _, dest := range rule.Destinations {
+			destinations[dest] = struct{}{}
+		}
+	}
+
+	if len(destinations) > MaxReplicationDestinations {
+		return errors.InvalidInputf("ECR supports at most %d replication destinations, got %d",
+			MaxReplicationDestinations, len(destinations))
+	}
+
+	return nil
+}
+
+// GetReplicationConfiguration returns the registry's native replication configuration.
+// A registry without replication configured yields an empty configuration.
+func (c *Client) GetReplicationConfiguration(ctx context.Context) (*ReplicationConfiguration, error) {
+	describer, ok := c.ecr.(RegistryDescriberAPI)
+	if !ok {
+		return nil, errors.NotImplementedf("ECR client does not support DescribeRegistry")
+	}
+
+	resp, err := describer.DescribeRegistry(ctx, &awsecr.DescribeRegistryInput{})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to describe ECR registry")
+	}
+
+	return convertReplicationConfiguration(resp.ReplicationConfiguration), nil
+}
+
+// IsNativelyReplicated reports whether native ECR replication already copies the
+// repository to the given destination region and registry
+func (c *Client) IsNativelyReplicated(ctx context.Context, repoName, destRegion, destRegistryID string) (bool, error) {
+	replicationConfig, err := c.GetReplicationConfiguration(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	return replicationConfig.Covers(repoName, destRegion, destRegistryID), nil
+}
+
+// GetRegion returns the AWS region the client talks to
+func (c *Client) GetRegion() string {
+	return c.region
+}
+
+// GetAccountID returns the AWS account ID of the registry
+func (c *Client) GetAccountID() string {
+	return c.accountID
+}
+
+// ParseRegistryHost extracts the account ID and region from an ECR registry hostname
+// such as 123456789012.dkr.ecr.us-east-1.amazonaws.com
+func ParseRegistryHost(host string) (accountID, region string, ok bool) {
+	parts := strings.Split(strings.ToLower(host), ".")
+	if len(parts) < 6 || parts[1] != "dkr" || parts[2] != "ecr" || parts[4] != "amazonaws" {
+		return "", "", false
+	}
+
+	return parts[0], parts[3], true
+}
+
+// convertReplicationConfiguration converts the SDK representation into ReplicationConfiguration
+func convertReplicationConfiguration(in *ecrtypes.ReplicationConfiguration) *ReplicationConfiguration {
+	out := &ReplicationConfiguration{Rules: []ReplicationRule{}}
+	if in == nil {
+		return out
+	}
+
+	for _, rule := range in.Rules {
+		converted := ReplicationRule{}
+		for _, dest := range rule.Destinations {
+			converted.Destinations = append(converted.Destinations, ReplicationDestination{
+				Region:     aws.ToString(dest.Region),
+				RegistryID: aws.ToString(dest.RegistryId),
+			})
+		}
+		for _, filter := range rule.RepositoryFilters {
+			converted.RepositoryFilters = append(converted.RepositoryFilters, RepositoryFilter{
+				Filter:     aws.ToString(filter.Filter),
+				FilterType: string(filter.FilterType),
+			})
+		}
+		out.Rules = append(out.Rules, converted)
+	}
+
+	return out
+}
+
+// BuildReplicationConfiguration creates a native replication configuration that copies
+// the given repositories to dest. Repositories become prefix filters, split across as
+// many rules as the per-rule filter limit requires.
+func BuildReplicationConfiguration(repositories []string, dest ReplicationDestination) (*ReplicationConfiguration, error) {
+	if dest.Region == "" {
+		return nil, errors.InvalidInputf("destination region is required")
+	}
+
+	seen := make(map[string]struct{}, len(repositories))
+	filters := make([]RepositoryFilter, 0, len(repositories))
+	for _, repo := range repositories {
+		if repo == "" {
+			continue
+		}
+		if _, ok := seen[repo]; ok {
+			continue
+		}
+		seen[repo] = struct{}{}
+		filters = append(filters, RepositoryFilter{
+			Filter:     repo,
+			FilterType: RepositoryFilterTypePrefixMatch,
+		})
+	}
+
+	if len(filters) == 0 {
+		return nil, errors.InvalidInputf("no repositories to replicate")
+	}
+
+	replicationConfig := &ReplicationConfiguration{Rules: []ReplicationRule{}}
+	for start := 0; start < len(filters); start += MaxRepositoryFilters {
+		end := start + MaxRepositoryFilters
+		if end > len(filters) {
+			end = len(filters)
+		}
+		replicationConfig.Rules = append(replicationConfig.Rules, ReplicationRule{
+			Destinations:      []ReplicationDestination{dest},
+			RepositoryFilters: filters[start:end],
+		})
+	}
+
+	if err := replicationConfig.Validate(); err != nil {
+		return nil, err
+	}
+
+	return replicationConfig, nil
+}
@@ -0,0 +1,149 @@
+package ecr
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"freightliner/pkg/helper/log"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsecr "github.com/aws/aws-sdk-go-v2/service/ecr"
+	ecrtypes "github.com/aws/aws-sdk-go-v2/service/ecr/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRegistryDescriber implements ECRServiceAPI and RegistryDescriberAPI
+type fakeRegistryDescriber struct {
+	ECRServiceAPI
+	output *awsecr.DescribeRegistryOutput
+	err    error
+}
+
+func (f *fakeRegistryDescriber) DescribeRegistry(ctx context.Context, params *awsecr.DescribeRegistryInput, optFns ...func(*awsecr.Options)) (*awsecr.DescribeRegistryOutput, error) {
+	return f.output, f.err
+}
+
+func TestReplicationConfigurationCovers(t *testing.T) {
+	cfg := &ReplicationConfiguration{
+		Rules: []ReplicationRule{
+			{
+				Destinations: []ReplicationDestination{{Region: "eu-west-1", RegistryID: "123456789012"}},
+				RepositoryFilters: []RepositoryFilter{
+					{Filter: "team-a/", FilterType: RepositoryFilterTypePrefixMatch},
+				},
+			},
+			{
+				// No filters: everything goes to ap-southeast-2
+				Destinations: []ReplicationDestination{{Region: "ap-southeast-2", RegistryID: "123456789012"}},
+			},
+		},
+	}
+
+	assert.True(t, cfg.Covers("team-a/app", "eu-west-1", "123456789012"))
+	assert.False(t, cfg.Covers("team-b/app", "eu-west-1", "123456789012"))
+	assert.False(t, cfg.Covers("team-a/app", "eu-west-1", "999999999999"))
+	assert.True(t, cfg.Covers("team-a/app", "eu-west-1", ""))
+	assert.True(t, cfg.Covers("anything", "ap-southeast-2", "123456789012"))
+	assert.False(t, cfg.Covers("anything", "us-east-1", "123456789012"))
+
+	var empty *ReplicationConfiguration
+	assert.False(t, empty.Covers("team-a/app", "eu-west-1", ""))
+}
+
+func TestIsNativelyReplicated(t *testing.T) {
+	describer := &fakeRegistryDescriber{
+		output: &awsecr.DescribeRegistryOutput{
+			RegistryId: aws.String("123456789012"),
+			ReplicationConfiguration: &ecrtypes.ReplicationConfiguration{
+				Rules: []ecrtypes.ReplicationRule{
+					{
+						Destinations: []ecrtypes.ReplicationDestination{
+							{Region: aws.String("eu-west-1"), RegistryId: aws.String("123456789012")},
+						},
+						RepositoryFilters: []ecrtypes.RepositoryFilter{
+							{Filter: aws.String("prod/"), FilterType: ecrtypes.RepositoryFilterTypePrefixMatch},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	client := &Client{ecr: describer, region: "us-east-1", accountID: "123456789012", logger: log.NewBasicLogger(log.ErrorLevel)}
+
+	covered, err := client.IsNativelyReplicated(context.Background(), "prod/api", "eu-west-1", "123456789012")
+	require.NoError(t, err)
+	assert.True(t, covered)
+
+	covered, err = client.IsNativelyReplicated(context.Background(), "dev/api", "eu-west-1", "123456789012")
+	require.NoError(t, err)
+	assert.False(t, covered)
+
+	describer.err = fmt.Errorf("access denied")
+	_, err = client.IsNativelyReplicated(context.Background(), "prod/api", "eu-west-1", "123456789012")
+	assert.Error(t, err)
+}
+
+func TestGetReplicationConfigurationUnsupportedClient(t *testing.T) {
+	client := &Client{ecr: &fakeECRServiceOnly{}, logger: log.NewBasicLogger(log.ErrorLevel)}
+
+	_, err := client.GetReplicationConfiguration(context.Background())
+	assert.Error(t, err)
+}
+
+// fakeECRServiceOnly implements ECRServiceAPI without DescribeRegistry
+type fakeECRServiceOnly struct {
+	ECRServiceAPI
+}
+
+func TestParseRegistryHost(t *testing.T) {
+	account, region, ok := ParseRegistryHost("123456789012.dkr.ecr.us-east-1.amazonaws.com")
+	assert.True(t, ok)
+	assert.Equal(t, "123456789012", account)
+	assert.Equal(t, "us-east-1", region)
+
+	_, _, ok = ParseRegistryHost("gcr.io")
+	assert.False(t, ok)
+
+	_, _, ok = ParseRegistryHost("public.ecr.aws")
+	assert.False(t, ok)
+}
+
+func TestBuildReplicationConfiguration(t *testing.T) {
+	dest := ReplicationDestination{Region: "eu-west-1", RegistryID: "123456789012"}
+
+	cfg, err := BuildReplicationConfiguration([]string{"team/app", "team/app", "team/api", ""}, dest)
+	require.NoError(t, err)
+	require.Len(t, cfg.Rules, 1)
+	assert.Len(t, cfg.Rules[0].RepositoryFilters, 2)
+	assert.True(t, cfg.Covers("team/api", "eu-west-1", "123456789012"))
+
+	repos := make([]string, MaxRepositoryFilters+1)
+	for i := range repos {
+		repos[i] = fmt.Sprintf("repo-%03d", i)
+	}
+	cfg, err = BuildReplicationConfiguration(repos, dest)
+	require.NoError(t, err)
+	assert.Len(t, cfg.Rules, 2)
+
+	_, err = BuildReplicationConfiguration(nil, dest)
+	assert.Error(t, err)
+
+	_, err = BuildReplicationConfiguration([]string{"app"}, ReplicationDestination{})
+	assert.Error(t, err)
+}
+
+func TestReplicationConfigurationValidateLimits(t *testing.T) {
+	cfg := &ReplicationConfiguration{}
+	for i := 0; i <= MaxReplicationRules; i++ {
+		cfg.Rules = append(cfg.Rules, ReplicationRule{
+			Destinations: []ReplicationDestination{{Region: "eu-west-1", RegistryID: "123456789012"}},
+		})
+	}
+	assert.Error(t, cfg.Validate())
+
+	cfg = &ReplicationConfiguration{Rules: []ReplicationRule{{}}}
+	assert.Error(t, cfg.Validate())
+}
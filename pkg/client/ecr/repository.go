@@ -9,6 +9,8 @@ import (
 	"strings"
 
 	"freightliner/pkg/helper/errors"
+	"freightliner/pkg/helper/readonly"
+	"freightliner/pkg/helper/util"
 	"freightliner/pkg/interfaces"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -87,6 +89,46 @@ func (repo *Repository) ListTags(ctx context.Context) ([]string, error) {
 	return tags, nil
 }
 
+// ListTagDigests returns the digest and push time of every tag from the ECR
+// DescribeImages API - implements interfaces.TagDigestLister
+func (repo *Repository) ListTagDigests(ctx context.Context) ([]interfaces.TagDigest, error) {
+	var digests []interfaces.TagDigest
+	var nextToken *string
+
+	for {
+		input := &awsecr.DescribeImagesInput{
+			RepositoryName: aws.String(repo.name),
+			NextToken:      nextToken,
+		}
+		if repo.client.accountID != "" {
+			input.RegistryId = aws.String(repo.client.accountID)
+		}
+
+		resp, err := repo.client.ecr.DescribeImages(ctx, input)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to describe images")
+		}
+
+		for _, detail := range resp.ImageDetails {
+			digest := aws.ToString(detail.ImageDigest)
+			for _, tag := range detail.ImageTags {
+				td := interfaces.TagDigest{Tag: tag, Digest: digest}
+				if detail.ImagePushedAt != nil {
+					td.PushedAt = *detail.ImagePushedAt
+				}
+				digests = append(digests, td)
+			}
+		}
+
+		nextToken = resp.NextToken
+		if nextToken == nil {
+			break
+		}
+	}
+
+	return digests, nil
+}
+
 // GetImage retrieves an image by tag - implements common.Repository
 func (repo *Repository) GetImage(ctx context.Context, tag string) (v1.Image, error) {
 	if tag == "" {
@@ -108,16 +150,22 @@ func (repo *Repository) GetImage(ctx context.Context, tag string) (v1.Image, err
 	return img, nil
 }
 
-// GetManifest returns the manifest for the given tag - implements interfaces.Repository
+// GetManifest returns the manifest for the given tag or digest - implements interfaces.Repository
 func (repo *Repository) GetManifest(ctx context.Context, tag string) (*interfaces.Manifest, error) {
 	if tag == "" {
 		return nil, errors.InvalidInputf("tag cannot be empty")
 	}
 
-	// Create a reference for the tag
-	ref, err := name.NewTag(fmt.Sprintf("%s:%s", repo.repository.String(), tag))
+	// Create a reference for the tag, or for the digest when one is given
+	var ref name.Reference
+	var err error
+	if util.IsDigest(tag) {
+		ref = repo.repository.Digest(tag)
+	} else {
+		ref, err = name.NewTag(fmt.Sprintf("%s:%s", repo.repository.String(), tag))
+	}
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to create tag reference")
+		return nil, errors.Wrap(err, "failed to create image reference")
 	}
 
 	// Get the image from the registry
@@ -226,6 +274,9 @@ func (repo *Repository) DeleteManifest(ctx context.Context, tag string) error {
 	}
 
 	// Delete the image
+	if err := readonly.Check(fmt.Sprintf("delete %s@%s", repo.repository, aws.ToString(imageDigest))); err != nil {
+		return err
+	}
 	_, err = repo.client.ecr.BatchDeleteImage(ctx, deleteInput)
 	if err != nil {
 		return errors.Wrap(err, "failed to delete image")
@@ -234,6 +285,47 @@ func (repo *Repository) DeleteManifest(ctx context.Context, tag string) error {
 	return nil
 }
 
+// DeleteReference removes a tag or, given a digest, the image it names -
+// implements interfaces.ReferenceDeleter. ECR untags an image without deleting
+// it while other tags still reference it.
+func (repo *Repository) DeleteReference(ctx context.Context, reference string) error {
+	if reference == "" {
+		return errors.InvalidInputf("reference cannot be empty")
+	}
+
+	imageID := ecrtypes.ImageIdentifier{ImageTag: aws.String(reference)}
+	if util.IsDigest(reference) {
+		imageID = ecrtypes.ImageIdentifier{ImageDigest: aws.String(reference)}
+	}
+
+	input := &awsecr.BatchDeleteImageInput{
+		RepositoryName: aws.String(repo.name),
+		ImageIds:       []ecrtypes.ImageIdentifier{imageID},
+	}
+	if repo.client.accountID != "" {
+		input.RegistryId = aws.String(repo.client.accountID)
+	}
+
+	if err := readonly.Check(fmt.Sprintf("delete %s:%s", repo.repository, reference)); err != nil {
+		return err
+	}
+	resp, err := repo.client.ecr.BatchDeleteImage(ctx, input)
+	if err != nil {
+		return errors.Wrap(err, "failed to delete image")
+	}
+
+	for _, failure := range resp.Failures {
+		if failure.FailureCode == ecrtypes.ImageFailureCodeImageNotFound ||
+			failure.FailureCode == ecrtypes.ImageFailureCodeImageTagDoesNotMatchDigest {
+			return errors.NotFoundf("image %s:%s not found", repo.name, reference)
+		}
+		return errors.Newf("failed to delete %s:%s: %s %s", repo.name, reference,
+			failure.FailureCode, aws.ToString(failure.FailureReason))
+	}
+
+	return nil
+}
+
 // GetLayerReader returns a reader for a layer with the given digest - implements common.Repository
 func (repo *Repository) GetLayerReader(ctx context.Context, digest string) (io.ReadCloser, error) {
 	if digest == "" {
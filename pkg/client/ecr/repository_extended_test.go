@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"io"
 	"testing"
+	"time"
 
 	"freightliner/pkg/helper/log"
 	"freightliner/pkg/interfaces"
@@ -80,6 +81,48 @@ func TestRepositoryExtended_ListTagsWithPagination(t *testing.T) {
 	mockService.AssertExpectations(t)
 }
 
+func TestRepositoryExtended_ListTagDigests(t *testing.T) {
+	client, mockService := createExtendedTestClient()
+
+	// Mock DescribeRepositories (called by GetRepository)
+	repoArn := "arn:aws:ecr:us-west-2:123456789012:repository/test-repo"
+	mockService.On("DescribeRepositories", mock.Anything, mock.Anything, mock.Anything).
+		Return(&awsecr.DescribeRepositoriesOutput{
+			Repositories: []ecrtypes.Repository{
+				{
+					RepositoryArn:  &repoArn,
+					RepositoryName: aws.String("test-repo"),
+				},
+			},
+		}, nil).Once()
+
+	pushedAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	mockService.On("DescribeImages", mock.Anything, mock.MatchedBy(func(input *awsecr.DescribeImagesInput) bool {
+		return aws.ToString(input.RepositoryName) == "test-repo"
+	}), mock.Anything).
+		Return(&awsecr.DescribeImagesOutput{
+			ImageDetails: []ecrtypes.ImageDetail{
+				{ImageDigest: aws.String("sha256:aaa"), ImageTags: []string{"v1.0.0", "stable"}, ImagePushedAt: &pushedAt},
+				{ImageDigest: aws.String("sha256:bbb")},
+			},
+		}, nil).Once()
+
+	ctx := context.Background()
+	repo, err := client.GetRepository(ctx, "test-repo")
+	assert.NoError(t, err)
+
+	lister, ok := repo.(interfaces.TagDigestLister)
+	assert.True(t, ok)
+
+	digests, err := lister.ListTagDigests(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []interfaces.TagDigest{
+		{Tag: "v1.0.0", Digest: "sha256:aaa", PushedAt: pushedAt},
+		{Tag: "stable", Digest: "sha256:aaa", PushedAt: pushedAt},
+	}, digests)
+	mockService.AssertExpectations(t)
+}
+
 func TestRepositoryExtended_DeleteManifestSuccess(t *testing.T) {
 	client, mockService := createExtendedTestClient()
 
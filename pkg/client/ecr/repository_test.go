@@ -296,3 +296,53 @@ func TestRepositoryDeleteManifest(t *testing.T) {
 		})
 	}
 }
+
+func TestRepositoryDeleteReference(t *testing.T) {
+	tests := []struct {
+		name        string
+		reference   string
+		expectedID  types.ImageIdentifier
+		failures    []types.ImageFailure
+		expectedErr bool
+	}{
+		{
+			name:       "Untag",
+			reference:  "old-tag",
+			expectedID: types.ImageIdentifier{ImageTag: aws.String("old-tag")},
+		},
+		{
+			name:       "Delete digest",
+			reference:  "sha256:b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9",
+			expectedID: types.ImageIdentifier{ImageDigest: aws.String("sha256:b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9")},
+		},
+		{
+			name:        "Missing tag",
+			reference:   "gone",
+			expectedID:  types.ImageIdentifier{ImageTag: aws.String("gone")},
+			failures:    []types.ImageFailure{{FailureCode: types.ImageFailureCodeImageNotFound}},
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			mockECR := &mockRepositoryECRAPI{}
+			mockECR.On("BatchDeleteImage", mock.Anything, mock.MatchedBy(func(input *ecr.BatchDeleteImageInput) bool {
+				return len(input.ImageIds) == 1 &&
+					aws.ToString(input.ImageIds[0].ImageTag) == aws.ToString(tc.expectedID.ImageTag) &&
+					aws.ToString(input.ImageIds[0].ImageDigest) == aws.ToString(tc.expectedID.ImageDigest)
+			}), mock.Anything).Return(&ecr.BatchDeleteImageOutput{Failures: tc.failures}, nil)
+
+			repo := setupTestRepository(mockECR)
+
+			err := repo.DeleteReference(context.Background(), tc.reference)
+			if tc.expectedErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			mockECR.AssertExpectations(t)
+		})
+	}
+}
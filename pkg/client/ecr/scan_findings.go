@@ -0,0 +1,76 @@
+package ecr
+
+import (
+	"context"
+
+	"freightliner/pkg/helper/errors"
+	"freightliner/pkg/helper/util"
+	"freightliner/pkg/interfaces"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsecr "github.com/aws/aws-sdk-go-v2/service/ecr"
+	ecrtypes "github.com/aws/aws-sdk-go-v2/service/ecr/types"
+)
+
+// ScanFindingsAPI is implemented by ECR clients that can read image scan results.
+// It is kept separate from ECRServiceAPI so existing mocks do not need to grow a method.
+type ScanFindingsAPI interface {
+	DescribeImageScanFindings(ctx context.Context, params *awsecr.DescribeImageScanFindingsInput, optFns ...func(*awsecr.Options)) (*awsecr.DescribeImageScanFindingsOutput, error)
+}
+
+// GetScanFindings returns the basic or enhanced scan summary for a tag or
+// digest - implements interfaces.ScanFindingsProvider. Individual findings are
+// not fetched; the severity counts are what admission policies act on.
+func (repo *Repository) GetScanFindings(ctx context.Context, reference string) (*interfaces.ScanFindingsSummary, error) {
+	if reference == "" {
+		return nil, errors.InvalidInputf("reference cannot be empty")
+	}
+
+	api, ok := repo.client.ecr.(ScanFindingsAPI)
+	if !ok {
+		return nil, errors.NotImplementedf("ECR client does not support reading scan findings")
+	}
+
+	imageID := &ecrtypes.ImageIdentifier{ImageTag: aws.String(reference)}
+	if util.IsDigest(reference) {
+		imageID = &ecrtypes.ImageIdentifier{ImageDigest: aws.String(reference)}
+	}
+
+	input := &awsecr.DescribeImageScanFindingsInput{
+		RepositoryName: aws.String(repo.name),
+		ImageId:        imageID,
+		MaxResults:     aws.Int32(1),
+	}
+	if repo.client.accountID != "" {
+		input.RegistryId = aws.String(repo.client.accountID)
+	}
+
+	resp, err := api.DescribeImageScanFindings(ctx, input)
+	if err != nil {
+		var notScanned *ecrtypes.ScanNotFoundException
+		if errors.As(err, &notScanned) {
+			return nil, errors.NotFoundf("no scan findings for %s:%s", repo.name, reference)
+		}
+		return nil, errors.Wrap(err, "failed to describe image scan findings")
+	}
+
+	summary := &interfaces.ScanFindingsSummary{
+		Scanner:        "ecr",
+		SeverityCounts: make(map[string]int),
+	}
+	if resp.ImageId != nil {
+		summary.ImageDigest = aws.ToString(resp.ImageId.ImageDigest)
+	}
+	if resp.ImageScanStatus != nil {
+		summary.Status = string(resp.ImageScanStatus.Status)
+	}
+	if findings := resp.ImageScanFindings; findings != nil {
+		for severity, count := range findings.FindingSeverityCounts {
+			summary.SeverityCounts[severity] = int(count)
+		}
+		summary.ScanCompletedAt = aws.ToTime(findings.ImageScanCompletedAt)
+		summary.VulnerabilitySourceUpdatedAt = aws.ToTime(findings.VulnerabilitySourceUpdatedAt)
+	}
+
+	return summary, nil
+}
@@ -0,0 +1,65 @@
+package ecr
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"freightliner/pkg/helper/errors"
+	"freightliner/pkg/helper/log"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsecr "github.com/aws/aws-sdk-go-v2/service/ecr"
+	ecrtypes "github.com/aws/aws-sdk-go-v2/service/ecr/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeScanFindings answers DescribeImageScanFindings for tags that have been scanned
+type fakeScanFindings struct {
+	ECRServiceAPI
+	scanned map[string]*awsecr.DescribeImageScanFindingsOutput
+	input   *awsecr.DescribeImageScanFindingsInput
+}
+
+func (f *fakeScanFindings) DescribeImageScanFindings(ctx context.Context, params *awsecr.DescribeImageScanFindingsInput, optFns ...func(*awsecr.Options)) (*awsecr.DescribeImageScanFindingsOutput, error) {
+	f.input = params
+	resp, ok := f.scanned[aws.ToString(params.ImageId.ImageTag)]
+	if !ok {
+		return nil, &ecrtypes.ScanNotFoundException{Message: aws.String("not scanned")}
+	}
+	return resp, nil
+}
+
+func TestGetScanFindings(t *testing.T) {
+	completed := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	api := &fakeScanFindings{scanned: map[string]*awsecr.DescribeImageScanFindingsOutput{
+		"v1": {
+			ImageId:         &ecrtypes.ImageIdentifier{ImageDigest: aws.String("sha256:abc")},
+			ImageScanStatus: &ecrtypes.ImageScanStatus{Status: ecrtypes.ScanStatusComplete},
+			ImageScanFindings: &ecrtypes.ImageScanFindings{
+				FindingSeverityCounts: map[string]int32{"CRITICAL": 1, "HIGH": 4},
+				ImageScanCompletedAt:  aws.Time(completed),
+			},
+		},
+	}}
+	client := &Client{ecr: api, accountID: "123456789012", logger: log.NewBasicLogger(log.ErrorLevel)}
+	repo := &Repository{client: client, name: "team/app"}
+
+	summary, err := repo.GetScanFindings(context.Background(), "v1")
+	require.NoError(t, err)
+	assert.Equal(t, "ecr", summary.Scanner)
+	assert.Equal(t, "sha256:abc", summary.ImageDigest)
+	assert.Equal(t, "COMPLETE", summary.Status)
+	assert.Equal(t, map[string]int{"CRITICAL": 1, "HIGH": 4}, summary.SeverityCounts)
+	assert.Equal(t, completed, summary.ScanCompletedAt)
+	assert.Equal(t, "123456789012", aws.ToString(api.input.RegistryId))
+
+	_, err = repo.GetScanFindings(context.Background(), "v2")
+	assert.True(t, errors.Is(err, errors.ErrNotFound))
+
+	const dgst = "sha256:b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9"
+	_, err = repo.GetScanFindings(context.Background(), dgst)
+	assert.Error(t, err)
+	assert.Equal(t, dgst, aws.ToString(api.input.ImageId.ImageDigest))
+}
@@ -5,6 +5,7 @@ import (
 	"context"
 	"strings"
 
+	"freightliner/pkg/auth"
 	"freightliner/pkg/client/acr"
 	"freightliner/pkg/client/dockerhub"
 	"freightliner/pkg/client/ecr"
@@ -23,6 +24,10 @@ import (
 type Factory struct {
 	config *config.Config
 	logger log.Logger
+
+	// credentials holds logins saved by "freightliner login", including those
+	// kept in the OS keychain
+	credentials *auth.CredentialStore
 }
 
 // NewFactory creates a new registry client factory
@@ -32,26 +37,93 @@ func NewFactory(cfg *config.Config, logger log.Logger) *Factory {
 	}
 
 	return &Factory{
-		config: cfg,
-		logger: logger,
+		config:      cfg,
+		logger:      logger,
+		credentials: auth.NewCredentialStore(),
 	}
 }
 
+// storedCredentials returns credentials saved by "freightliner login" for a
+// registry. They are consulted for registries without explicit configuration,
+// ahead of the anonymous fallback.
+func (f *Factory) storedCredentials(registry string) (username, password string, ok bool) {
+	// Tenants never borrow the server operator's logins
+	if f.credentials == nil || (f.config != nil && f.config.Tenant != nil) {
+		return "", "", false
+	}
+
+	username, password, err := f.credentials.Get(registry)
+	if err != nil || (username == "" && password == "") {
+		return "", "", false
+	}
+
+	f.logger.WithFields(map[string]interface{}{
+		"registry": registry,
+	}).Debug("Using stored login credentials")
+
+	return username, password, true
+}
+
+// insecureRule returns the --registry-insecure rule for a registry host, or
+// nil when the host must be verified normally
+func (f *Factory) insecureRule(registry string) *config.InsecureRule {
+	if f.config == nil {
+		return nil
+	}
+	rule, ok := f.config.Registries.InsecureRuleFor(registry)
+	if !ok {
+		return nil
+	}
+	return &rule
+}
+
+// requireTenantCredentials refuses a cloud registry client for a tenant that
+// configured no credentials of its own for it. Without them the client would
+// run as the server's own identity: the default AWS chain or instance role,
+// GCP application default credentials, or an Azure managed identity.
+func (f *Factory) requireTenantCredentials(registry string, explicit bool) error {
+	if explicit || f.config == nil || f.config.Tenant == nil {
+		return nil
+	}
+	return errors.Forbiddenf("tenant %s has no %s credentials of its own", f.config.Tenant.Name, registry)
+}
+
 // CreateECRClient creates an ECR client using the factory's configuration
 func (f *Factory) CreateECRClient() (interfaces.RegistryClient, error) {
+	return f.CreateECRClientForRegion(f.config.ECR.Region, f.config.ECR.AccountID)
+}
+
+// CreateECRClientForRegion creates an ECR client for a specific region. An
+// empty accountID uses the configured ECR account.
+func (f *Factory) CreateECRClientForRegion(region, accountID string) (interfaces.RegistryClient, error) {
+	if accountID == "" {
+		accountID = f.config.ECR.AccountID
+	}
+	if err := f.requireTenantCredentials("ECR", f.config.ECR.HasExplicitCredentials()); err != nil {
+		return nil, err
+	}
 	return ecr.NewClient(ecr.ClientOptions{
-		Region:    f.config.ECR.Region,
-		AccountID: f.config.ECR.AccountID,
-		Logger:    f.logger,
+		Region:          region,
+		AccountID:       accountID,
+		Profile:         f.config.ECR.Profile,
+		RoleARN:         f.config.ECR.RoleARN,
+		AccessKeyID:     f.config.ECR.AccessKeyID,
+		SecretAccessKey: f.config.ECR.SecretAccessKey,
+		SessionToken:    f.config.ECR.SessionToken,
+		Logger:          f.logger,
 	})
 }
 
 // CreateGCRClient creates a GCR client using the factory's configuration
 func (f *Factory) CreateGCRClient() (interfaces.RegistryClient, error) {
+	if err := f.requireTenantCredentials("GCR", f.config.GCR.CredentialsFile != ""); err != nil {
+		return nil, err
+	}
 	return gcr.NewClient(gcr.ClientOptions{
-		Project:  f.config.GCR.Project,
-		Location: f.config.GCR.Location,
-		Logger:   f.logger,
+		Project:         f.config.GCR.Project,
+		Location:        f.config.GCR.Location,
+		CredentialsFile: f.config.GCR.CredentialsFile,
+		Logger:          f.logger,
 	})
 }
 
@@ -81,6 +153,9 @@ func (f *Factory) CreateACRClient(registryName string, opts acr.ClientOptions) (
 	if opts.Logger == nil {
 		opts.Logger = f.logger
 	}
+	if err := f.requireTenantCredentials("ACR", acrHasExplicitCredentials(opts)); err != nil {
+		return nil, err
+	}
 	return acr.NewClient(opts)
 }
 
@@ -128,19 +203,30 @@ func (f *Factory) CreateClientFromConfig(regConfig config.RegistryConfig, name s
 
 	switch regType {
 	case "ecr":
+		auth := regConfig.Auth
+		if err := f.requireTenantCredentials("ECR", auth.Profile != "" || auth.RoleARN != "" || auth.CredentialsFile != ""); err != nil {
+			return nil, err
+		}
 		// Create ECR client with configuration from registry config
 		return ecr.NewClient(ecr.ClientOptions{
-			Region:    f.getRegionFromConfig(regConfig),
-			AccountID: f.getAccountIDFromConfig(regConfig),
-			Logger:    f.logger,
+			Region:          f.getRegionFromConfig(regConfig),
+			AccountID:       f.getAccountIDFromConfig(regConfig),
+			Profile:         regConfig.Auth.Profile,
+			RoleARN:         regConfig.Auth.RoleARN,
+			CredentialsFile: regConfig.Auth.CredentialsFile,
+			Logger:          f.logger,
 		})
 
 	case "gcr":
+		if err := f.requireTenantCredentials("GCR", regConfig.Auth.CredentialsFile != ""); err != nil {
+			return nil, err
+		}
 		// Create GCR client with configuration from registry config
 		return gcr.NewClient(gcr.ClientOptions{
-			Project:  f.getProjectFromConfig(regConfig),
-			Location: f.getLocationFromConfig(regConfig),
-			Logger:   f.logger,
+			Project:         f.getProjectFromConfig(regConfig),
+			Location:        f.getLocationFromConfig(regConfig),
+			CredentialsFile: regConfig.Auth.CredentialsFile,
+			Logger:          f.logger,
 		})
 
 	case "dockerhub":
@@ -159,14 +245,18 @@ func (f *Factory) CreateClientFromConfig(regConfig config.RegistryConfig, name s
 
 	case "acr", "azure":
 		// Create ACR client with configuration from registry config
-		return acr.NewClient(acr.ClientOptions{
+		opts := acr.ClientOptions{
 			RegistryName:       f.getRegistryNameFromConfig(regConfig),
 			TenantID:           f.getMetadata(regConfig, "tenantId", "tenant_id"),
 			ClientID:           f.getMetadata(regConfig, "clientId", "client_id"),
 			ClientSecret:       f.getMetadata(regConfig, "clientSecret", "client_secret"),
 			UseManagedIdentity: f.getMetadata(regConfig, "useManagedIdentity") == "true",
 			Logger:             f.logger,
-		})
+		}
+		if err := f.requireTenantCredentials("ACR", acrHasExplicitCredentials(opts)); err != nil {
+			return nil, err
+		}
+		return acr.NewClient(opts)
 
 	case "harbor":
 		// Create Harbor client with configuration from registry config
@@ -201,6 +291,7 @@ func (f *Factory) CreateClientFromConfig(regConfig config.RegistryConfig, name s
 			RegistryConfig: regConfig,
 			RegistryName:   name,
 			Logger:         f.logger,
+			InsecureRule:   f.insecureRule(regConfig.Endpoint),
 		})
 
 	default:
@@ -211,6 +302,16 @@ func (f *Factory) CreateClientFromConfig(regConfig config.RegistryConfig, name s
 // CreateClientForRegistry creates a client for the specified registry endpoint
 // This is a convenience method that tries to auto-detect the registry type
 func (f *Factory) CreateClientForRegistry(ctx context.Context, registryURL string) (interfaces.RegistryClient, error) {
+	// Named registries from config win over auto-detection, so an alias or the
+	// host of a configured registry uses its own credentials and TLS settings
+	if reg, ok := f.config.Registries.Lookup(registryURL); ok {
+		f.logger.WithFields(map[string]interface{}{
+			"registryName": reg.Name,
+			"registryType": reg.Type,
+		}).Info("Matched configured registry")
+		return f.CreateClientFromConfig(*reg, reg.Name)
+	}
+
 	// Normalize URL for comparison
 	normalizedURL := strings.ToLower(registryURL)
 
@@ -219,23 +320,29 @@ func (f *Factory) CreateClientForRegistry(ctx context.Context, registryURL strin
 		strings.Contains(normalizedURL, "registry-1.docker.io") ||
 		strings.Contains(normalizedURL, "index.docker.io") {
 		f.logger.Info("Auto-detected Docker Hub registry")
-		return f.CreateDockerHubClient("", "")
+		username, password, _ := f.storedCredentials(registryURL)
+		return f.CreateDockerHubClient(username, password)
 	}
 
 	// Check for GitHub Container Registry
 	if strings.Contains(normalizedURL, "ghcr.io") {
 		f.logger.Info("Auto-detected GitHub Container Registry")
-		return f.CreateGHCRClient("", "")
+		username, token, _ := f.storedCredentials(registryURL)
+		return f.CreateGHCRClient(token, username)
 	}
 
-	// Check for AWS ECR
-	if strings.Contains(normalizedURL, ".dkr.ecr.") && strings.Contains(normalizedURL, ".amazonaws.com") {
+	// Check for AWS ECR (full endpoint or the "ecr" shorthand for the configured account)
+	if normalizedURL == "ecr" || (strings.Contains(normalizedURL, ".dkr.ecr.") && strings.Contains(normalizedURL, ".amazonaws.com")) {
 		f.logger.Info("Auto-detected AWS ECR registry")
+		host := strings.TrimPrefix(strings.TrimPrefix(normalizedURL, "https://"), "http://")
+		if accountID, region, ok := ecr.ParseRegistryHost(host); ok {
+			return f.CreateECRClientForRegion(region, accountID)
+		}
 		return f.CreateECRClient()
 	}
 
-	// Check for Google Container Registry
-	if strings.Contains(normalizedURL, "gcr.io") || strings.Contains(normalizedURL, "pkg.dev") {
+	// Check for Google Container Registry (full endpoint or the "gcr" shorthand)
+	if normalizedURL == "gcr" || strings.Contains(normalizedURL, "gcr.io") || strings.Contains(normalizedURL, "pkg.dev") {
 		f.logger.Info("Auto-detected Google Container Registry")
 		return f.CreateGCRClient()
 	}
@@ -254,36 +361,46 @@ func (f *Factory) CreateClientForRegistry(ctx context.Context, registryURL strin
 		}
 	}
 
-	// Check if it's a configured custom registry
-	for _, reg := range f.config.Registries.Registries {
-		if strings.Contains(normalizedURL, reg.Endpoint) {
-			f.logger.WithFields(map[string]interface{}{
-				"registryName": reg.Name,
-				"registryType": reg.Type,
-			}).Info("Matched configured registry")
-			return f.CreateCustomClient(reg.Name)
-		}
-	}
-
-	// Fall back to generic client with anonymous auth
+	// Fall back to generic client with stored login credentials or anonymous auth
 	f.logger.WithFields(map[string]interface{}{
 		"registryURL": registryURL,
 	}).Info("Using generic OCI registry client")
 
+	authConfig := config.AuthConfig{Type: config.AuthTypeAnonymous}
+	if username, password, ok := f.storedCredentials(registryURL); ok {
+		authConfig = config.AuthConfig{
+			Type:     config.AuthTypeBasic,
+			Username: username,
+			Password: password,
+		}
+	}
+
 	return generic.NewClient(generic.ClientOptions{
 		RegistryConfig: config.RegistryConfig{
 			Name:     registryURL,
 			Type:     config.RegistryTypeGeneric,
 			Endpoint: registryURL,
-			Auth: config.AuthConfig{
-				Type: config.AuthTypeAnonymous,
-			},
+			Auth:     authConfig,
 		},
 		RegistryName: registryURL,
 		Logger:       f.logger,
+		InsecureRule: f.insecureRule(registryURL),
 	})
 }
 
+// acrHasExplicitCredentials reports whether opts name a service principal
+// rather than relying on a managed identity
+func acrHasExplicitCredentials(opts acr.ClientOptions) bool {
+	if opts.UseManagedIdentity {
+		return false
+	}
+	if opts.AuthConfig != nil && !opts.AuthConfig.UseManagedIdentity &&
+		opts.AuthConfig.ClientID != "" && opts.AuthConfig.ClientSecret != "" {
+		return true
+	}
+	return opts.ClientID != "" && opts.ClientSecret != ""
+}
+
 // GetDefaultSourceRegistry returns the default registry for pulling images
 func (f *Factory) GetDefaultSourceRegistry() string {
 	if f.config.Registries.DefaultSource != "" {
@@ -0,0 +1,47 @@
+package client
+
+import (
+	"context"
+	"testing"
+
+	"freightliner/pkg/client/acr"
+	"freightliner/pkg/config"
+	"freightliner/pkg/helper/errors"
+	"freightliner/pkg/helper/log"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestFactoryTenantCloudCredentials tests that tenants never get cloud
+// registry clients running on the server's own identity
+func TestFactoryTenantCloudCredentials(t *testing.T) {
+	cfg := &config.Config{
+		ECR:    config.ECRConfig{Region: "us-east-1", NativeReplication: config.NativeReplicationWarn},
+		GCR:    config.GCRConfig{Project: "shared"},
+		Tenant: &config.TenantConfig{Name: "team-a"},
+	}
+	factory := NewFactory(cfg, log.NewBasicLogger(log.ErrorLevel))
+	ctx := context.Background()
+
+	for _, registry := range []string{"ecr", "123456789012.dkr.ecr.eu-west-1.amazonaws.com", "gcr.io", "team.azurecr.io"} {
+		_, err := factory.CreateClientForRegistry(ctx, registry)
+		assert.True(t, errors.Is(err, errors.ErrForbidden), "%s: %v", registry, err)
+	}
+
+	_, err := factory.CreateClientFromConfig(config.RegistryConfig{Name: "prod", Type: config.RegistryTypeECR, Region: "us-east-1"}, "prod")
+	assert.True(t, errors.Is(err, errors.ErrForbidden))
+	_, err = factory.CreateACRClient("team", acr.ClientOptions{ClientID: "id", ClientSecret: "secret", UseManagedIdentity: true})
+	assert.True(t, errors.Is(err, errors.ErrForbidden))
+
+	// The tenant's own credentials are used
+	cfg.GCR.CredentialsFile = "/var/run/team-a/gcp.json"
+	_, err = factory.CreateGCRClient()
+	assert.False(t, errors.Is(err, errors.ErrForbidden))
+	_, err = factory.CreateACRClient("team", acr.ClientOptions{TenantID: "tenant", ClientID: "id", ClientSecret: "secret"})
+	assert.False(t, errors.Is(err, errors.ErrForbidden))
+
+	// The server itself keeps using ambient credentials
+	cfg.Tenant = nil
+	_, err = factory.CreateACRClient("team", acr.ClientOptions{UseManagedIdentity: true})
+	assert.False(t, errors.Is(err, errors.ErrForbidden))
+}
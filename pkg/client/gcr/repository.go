@@ -10,6 +10,7 @@ import (
 	"strings"
 
 	"freightliner/pkg/helper/errors"
+	"freightliner/pkg/helper/util"
 	"freightliner/pkg/interfaces"
 
 	"github.com/google/go-containerregistry/pkg/authn"
@@ -101,20 +102,26 @@ func (repo *Repository) GetImage(ctx context.Context, tag string) (v1.Image, err
 	return img, nil
 }
 
-// GetManifest retrieves a manifest by tag - implements interfaces.Repository
+// GetManifest retrieves a manifest by tag or digest - implements interfaces.Repository
 func (repo *Repository) GetManifest(ctx context.Context, tag string) (*interfaces.Manifest, error) {
 	if tag == "" {
 		return nil, errors.InvalidInputf("tag cannot be empty")
 	}
 
-	// Create a tagged reference
-	taggedRef, err := name.NewTag(fmt.Sprintf("%s:%s", repo.repository.String(), tag))
+	// Create a tagged reference, or a digest reference when one is given
+	var ref name.Reference
+	var err error
+	if util.IsDigest(tag) {
+		ref = repo.repository.Digest(tag)
+	} else {
+		ref, err = name.NewTag(fmt.Sprintf("%s:%s", repo.repository.String(), tag))
+	}
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to create tag reference")
+		return nil, errors.Wrap(err, "failed to create image reference")
 	}
 
 	// Get the descriptor
-	desc, err := remote.Get(taggedRef, repo.client.transportOpt)
+	desc, err := remote.Get(ref, repo.client.transportOpt)
 	if err != nil {
 		if strings.Contains(err.Error(), "not found") || strings.Contains(err.Error(), "404") {
 			return nil, errors.NotFoundf("image %s:%s not found", repo.name, tag)
@@ -334,6 +341,29 @@ func (repo *Repository) DeleteImage(ctx context.Context, tag string) error {
 	return nil
 }
 
+// DeleteReference removes a tag, or the manifest named by a digest - implements
+// interfaces.ReferenceDeleter. GCR and Artifact Registry both untag through the
+// registry API, and refuse to delete a digest that is still tagged.
+func (repo *Repository) DeleteReference(ctx context.Context, reference string) error {
+	if reference == "" {
+		return errors.InvalidInputf("reference cannot be empty")
+	}
+
+	var ref name.Reference = repo.repository.Tag(reference)
+	if util.IsDigest(reference) {
+		ref = repo.repository.Digest(reference)
+	}
+
+	if err := remote.Delete(ref, repo.client.transportOpt, remote.WithContext(ctx)); err != nil {
+		if strings.Contains(err.Error(), "404") || strings.Contains(err.Error(), "MANIFEST_UNKNOWN") {
+			return errors.NotFoundf("image %s:%s not found", repo.name, reference)
+		}
+		return errors.Wrap(err, "failed to delete image")
+	}
+
+	return nil
+}
+
 // DeleteManifest deletes the manifest for the given tag - implements common.Repository
 func (repo *Repository) DeleteManifest(ctx context.Context, tag string) error {
 	// This is a wrapper around DeleteImage to match the common.Repository interface
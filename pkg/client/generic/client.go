@@ -15,6 +15,7 @@ import (
 	"freightliner/pkg/config"
 	"freightliner/pkg/helper/errors"
 	"freightliner/pkg/helper/log"
+	"freightliner/pkg/helper/roundtrip"
 	"freightliner/pkg/helper/util"
 	"freightliner/pkg/interfaces"
 
@@ -32,6 +33,9 @@ type Client struct {
 	authenticator authn.Authenticator
 	transportOpt  remote.Option
 	httpTransport *http.Transport // Reusable HTTP transport with connection pooling
+	insecure      bool            // Whether httpTransport skips verification or allows plain HTTP
+	customTLS     bool            // Whether httpTransport carries a configured CA or client certificate
+	nameOpts      []name.Option   // Reference parsing options, e.g. name.Insecure for plain HTTP
 }
 
 // ClientOptions provides configuration for connecting to a generic registry
@@ -44,6 +48,11 @@ type ClientOptions struct {
 
 	// Logger is the logger to use
 	Logger log.Logger
+
+	// InsecureRule is a validated --registry-insecure rule for this host.
+	// Unlike RegistryConfig.Insecure it is not gated by
+	// FREIGHTLINER_ALLOW_INSECURE_TLS because it already names a single host.
+	InsecureRule *config.InsecureRule
 }
 
 // NewClient creates a new generic registry client
@@ -87,11 +96,30 @@ func NewClient(opts ClientOptions) (*Client, error) {
 
 	// Create and store HTTP transport for connection pooling
 	httpTransport := createHTTPTransport(insecure)
+	customTLS, err := applyTLSFiles(httpTransport.TLSClientConfig, opts.RegistryConfig.TLS)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid TLS settings for registry %s", registry)
+	}
+
+	// Apply the per-host rule, if any
+	var nameOpts []name.Option
+	if rule := opts.InsecureRule; rule != nil {
+		httpTransport.TLSClientConfig.InsecureSkipVerify = true
+		if rule.PlainHTTP {
+			nameOpts = append(nameOpts, name.Insecure)
+		}
+		insecure = true
+
+		opts.Logger.WithFields(map[string]interface{}{
+			"registry":   registry,
+			"plain_http": rule.PlainHTTP,
+		}).Warn("SECURITY WARNING: Registry matched an insecure rule - certificate verification disabled")
+	}
 
 	// Create transport option
 	transportOpt := remote.WithAuth(auth)
-	if insecure {
-		transportOpt = remote.WithTransport(httpTransport)
+	if insecure || customTLS {
+		transportOpt = remote.WithTransport(roundtrip.Wrap(httpTransport))
 	}
 
 	return &Client{
@@ -101,6 +129,9 @@ func NewClient(opts ClientOptions) (*Client, error) {
 		authenticator: auth,
 		transportOpt:  transportOpt,
 		httpTransport: httpTransport, // Store for reuse in GetTransport/GetRemoteOptions
+		insecure:      insecure,
+		customTLS:     customTLS,
+		nameOpts:      nameOpts,
 	}, nil
 }
 
@@ -112,7 +143,7 @@ func (c *Client) GetRegistryName() string {
 // ListRepositories lists all repositories in the registry
 func (c *Client) ListRepositories(ctx context.Context, prefix string) ([]string, error) {
 	// Parse registry
-	reg, err := name.NewRegistry(c.registry)
+	reg, err := name.NewRegistry(c.registry, c.nameOpts...)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to parse registry")
 	}
@@ -146,7 +177,7 @@ func (c *Client) GetRepository(ctx context.Context, repoName string) (interfaces
 
 	// Create a proper repository reference
 	fullRepoName := fmt.Sprintf("%s/%s", c.registry, repoName)
-	repository, err := name.NewRepository(fullRepoName)
+	repository, err := name.NewRepository(fullRepoName, c.nameOpts...)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to create repository reference")
 	}
@@ -170,7 +201,7 @@ func (c *Client) GetRepository(ctx context.Context, repoName string) (interfaces
 func (c *Client) GetTransport(repositoryName string) (http.RoundTripper, error) {
 	// Create a proper repository reference
 	fullRepoName := fmt.Sprintf("%s/%s", c.registry, repositoryName)
-	repository, err := name.NewRepository(fullRepoName)
+	repository, err := name.NewRepository(fullRepoName, c.nameOpts...)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to create repository reference")
 	}
@@ -194,7 +225,7 @@ func (c *Client) GetTransport(repositoryName string) (http.RoundTripper, error)
 		context.Background(),
 		repository.Registry,
 		c.authenticator,
-		c.httpTransport,
+		roundtrip.Wrap(c.httpTransport),
 		[]string{repository.Scope(transport.PullScope)},
 	)
 	if err != nil {
@@ -210,8 +241,7 @@ func (c *Client) GetRemoteOptions() []remote.Option {
 		remote.WithAuth(c.authenticator),
 	}
 
-	insecure := c.registryConf.Insecure || c.registryConf.TLS.InsecureSkipVerify
-	if insecure {
+	if c.insecure {
 		// Log security warning if insecure mode is enabled
 		allowInsecure := os.Getenv("FREIGHTLINER_ALLOW_INSECURE_TLS")
 		if allowInsecure == "true" || allowInsecure == "1" {
@@ -220,8 +250,11 @@ func (c *Client) GetRemoteOptions() []remote.Option {
 			}).Warn("SECURITY WARNING: Using insecure remote options - certificate verification disabled")
 		}
 
+	}
+
+	if c.insecure || c.customTLS {
 		// Reuse stored HTTP transport for connection pooling
-		opts = append(opts, remote.WithTransport(c.httpTransport))
+		opts = append(opts, remote.WithTransport(roundtrip.Wrap(c.httpTransport)))
 	}
 
 	return opts
@@ -330,6 +363,9 @@ func createAuthenticator(conf config.RegistryConfig) (authn.Authenticator, error
 			Token: token,
 		}, nil
 
+	case "oidc":
+		return newTokenExchangeAuthenticator(conf.Auth)
+
 	default:
 		return nil, errors.InvalidInputf("unsupported auth type: %s", conf.Auth.Type)
 	}
@@ -338,7 +374,12 @@ func createAuthenticator(conf config.RegistryConfig) (authn.Authenticator, error
 // createHTTPTransport creates an HTTP transport with secure TLS configuration
 // insecureSkipVerify should only be used for testing/development
 func createHTTPTransport(insecureSkipVerify bool) *http.Transport {
-	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// Start from the default transport's proxy and pooling settings, unless
+	// something other than the registry chain replaced it
+	transport, ok := roundtrip.BaseTransport(http.DefaultTransport)
+	if !ok {
+		transport = &http.Transport{Proxy: http.ProxyFromEnvironment}
+	}
 
 	// Create TLS config with system cert pool
 	tlsConfig := &tls.Config{
@@ -373,6 +414,40 @@ func createHTTPTransport(insecureSkipVerify bool) *http.Transport {
 	return transport
 }
 
+// applyTLSFiles adds the configured CA bundle and client certificate to
+// tlsConfig, reporting whether either was set
+func applyTLSFiles(tlsConfig *tls.Config, conf config.TLSConfig) (bool, error) {
+	applied := false
+
+	if conf.CAFile != "" {
+		pem, err := os.ReadFile(conf.CAFile)
+		if err != nil {
+			return false, errors.Wrap(err, "failed to read CA file")
+		}
+		if tlsConfig.RootCAs == nil {
+			tlsConfig.RootCAs = x509.NewCertPool()
+		}
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return false, errors.InvalidInputf("no certificates found in CA file %s", conf.CAFile)
+		}
+		applied = true
+	}
+
+	if conf.CertFile != "" || conf.KeyFile != "" {
+		if conf.CertFile == "" || conf.KeyFile == "" {
+			return false, errors.InvalidInputf("TLS client certificate needs both cert_file and key_file")
+		}
+		cert, err := tls.LoadX509KeyPair(conf.CertFile, conf.KeyFile)
+		if err != nil {
+			return false, errors.Wrap(err, "failed to load TLS client certificate")
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+		applied = true
+	}
+
+	return applied, nil
+}
+
 // expandEnvVars expands environment variable references in the format ${VAR_NAME}
 func expandEnvVars(s string) string {
 	if !strings.Contains(s, "${") {
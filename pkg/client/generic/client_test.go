@@ -2,7 +2,11 @@ package generic
 
 import (
 	"context"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"freightliner/pkg/config"
@@ -251,6 +255,84 @@ func TestGetRepository(t *testing.T) {
 	}
 }
 
+func TestNewClientInsecureRule(t *testing.T) {
+	client, err := NewClient(ClientOptions{
+		RegistryConfig: config.RegistryConfig{
+			Endpoint: "registry.lab.local:5000",
+			Auth: config.AuthConfig{
+				Type: config.AuthTypeAnonymous,
+			},
+		},
+		Logger:       log.NewBasicLogger(log.ErrorLevel),
+		InsecureRule: &config.InsecureRule{Host: "registry.lab.local:5000", PlainHTTP: true},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	if !client.httpTransport.TLSClientConfig.InsecureSkipVerify {
+		t.Error("expected TLS verification to be skipped for the matched host")
+	}
+
+	repo, err := client.GetRepository(context.Background(), "team/app")
+	if err != nil {
+		t.Fatalf("GetRepository() error = %v", err)
+	}
+	ref, err := repo.GetImageReference("v1")
+	if err != nil {
+		t.Fatalf("GetImageReference() error = %v", err)
+	}
+	if scheme := ref.Context().Registry.Scheme(); scheme != "http" {
+		t.Errorf("scheme = %q, want http", scheme)
+	}
+}
+
+func TestNewClientCAFile(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(caFile, caPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	client, err := NewClient(ClientOptions{
+		RegistryConfig: config.RegistryConfig{
+			Endpoint: server.URL,
+			Auth:     config.AuthConfig{Type: config.AuthTypeAnonymous},
+			TLS:      config.TLSConfig{CAFile: caFile},
+		},
+		Logger: log.NewBasicLogger(log.ErrorLevel),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	if len(client.GetRemoteOptions()) != 2 {
+		t.Error("expected the CA-aware transport in remote options")
+	}
+
+	resp, err := (&http.Client{Transport: client.httpTransport}).Get(server.URL)
+	if err != nil {
+		t.Fatalf("request with configured CA failed: %v", err)
+	}
+	resp.Body.Close()
+
+	_, err = NewClient(ClientOptions{
+		RegistryConfig: config.RegistryConfig{
+			Endpoint: server.URL,
+			Auth:     config.AuthConfig{Type: config.AuthTypeAnonymous},
+			TLS:      config.TLSConfig{CertFile: caFile},
+		},
+		Logger: log.NewBasicLogger(log.ErrorLevel),
+	})
+	if err == nil {
+		t.Error("expected an error for a client certificate without a key")
+	}
+}
+
 func TestCreateAuthenticator(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -306,6 +388,29 @@ func TestCreateAuthenticator(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "oidc auth",
+			config: config.RegistryConfig{
+				Auth: config.AuthConfig{
+					Type: config.AuthTypeOIDC,
+					TokenExchange: config.TokenExchangeConfig{
+						TokenURL:        "https://sts.example.com/token",
+						ClientAssertion: "jwt",
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "oidc auth missing token URL",
+			config: config.RegistryConfig{
+				Auth: config.AuthConfig{
+					Type:          config.AuthTypeOIDC,
+					TokenExchange: config.TokenExchangeConfig{ClientAssertion: "jwt"},
+				},
+			},
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -321,3 +426,43 @@ func TestCreateAuthenticator(t *testing.T) {
 		})
 	}
 }
+
+func TestTokenExchangeAuthenticator(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"access_token":"registry-token","expires_in":300}`))
+	}))
+	defer server.Close()
+
+	conf := config.AuthConfig{
+		Type: config.AuthTypeOIDC,
+		TokenExchange: config.TokenExchangeConfig{
+			TokenURL:        server.URL,
+			ClientAssertion: "jwt",
+		},
+	}
+
+	authenticator, err := newTokenExchangeAuthenticator(conf)
+	if err != nil {
+		t.Fatalf("newTokenExchangeAuthenticator() error = %v", err)
+	}
+	cfg, err := authenticator.Authorization()
+	if err != nil {
+		t.Fatalf("Authorization() error = %v", err)
+	}
+	if cfg.RegistryToken != "registry-token" {
+		t.Errorf("RegistryToken = %q, want registry-token", cfg.RegistryToken)
+	}
+
+	conf.Username = "robot"
+	authenticator, err = newTokenExchangeAuthenticator(conf)
+	if err != nil {
+		t.Fatalf("newTokenExchangeAuthenticator() error = %v", err)
+	}
+	cfg, err = authenticator.Authorization()
+	if err != nil {
+		t.Fatalf("Authorization() error = %v", err)
+	}
+	if cfg.Username != "robot" || cfg.Password != "registry-token" {
+		t.Errorf("Authorization() = %q/%q, want robot/registry-token", cfg.Username, cfg.Password)
+	}
+}
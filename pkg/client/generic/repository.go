@@ -6,15 +6,19 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"net/http"
 
 	"freightliner/pkg/client/common"
+	"freightliner/pkg/config"
 	"freightliner/pkg/helper/errors"
+	"freightliner/pkg/helper/roundtrip"
 	"freightliner/pkg/helper/util"
 	"freightliner/pkg/interfaces"
 
 	"github.com/google/go-containerregistry/pkg/name"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
 	"github.com/google/go-containerregistry/pkg/v1/types"
 )
 
@@ -50,9 +54,50 @@ func (r *Repository) DeleteManifest(ctx context.Context, digest string) error {
 	return errors.NotImplementedf("manifest deletion not supported for generic registries")
 }
 
+// DeleteReference removes a tag or digest - implements interfaces.ReferenceDeleter.
+// Registries implementing only the original distribution API delete by digest;
+// deleting a tag needs OCI distribution 1.1 tag deletion support.
+func (r *Repository) DeleteReference(ctx context.Context, reference string) error {
+	if reference == "" {
+		return errors.InvalidInputf("reference cannot be empty")
+	}
+
+	isDigest := util.IsDigest(reference)
+	var ref name.Reference = r.repository.Tag(reference)
+	if isDigest {
+		ref = r.repository.Digest(reference)
+	}
+
+	opts := append(r.client.GetRemoteOptions(), remote.WithContext(ctx))
+	if err := remote.Delete(ref, opts...); err != nil {
+		var terr *transport.Error
+		if errors.As(err, &terr) {
+			switch terr.StatusCode {
+			case http.StatusNotFound:
+				return errors.NotFoundf("image %s:%s not found", r.name, reference)
+			case http.StatusMethodNotAllowed, http.StatusBadRequest:
+				if !isDigest {
+					return errors.NotSupportedf("registry %s does not support deleting tags, delete by digest instead", r.repository.RegistryStr())
+				}
+				return errors.NotSupportedf("registry %s does not allow manifest deletion", r.repository.RegistryStr())
+			}
+		}
+		return errors.Wrap(err, "failed to delete image")
+	}
+
+	return nil
+}
+
+// isNotFound reports whether err is a registry response saying the
+// repository or manifest does not exist
+func isNotFound(err error) bool {
+	var terr *transport.Error
+	return errors.As(err, &terr) && terr.StatusCode == http.StatusNotFound
+}
+
 // GetImageReference returns a name.Reference for the given tag
 func (r *Repository) GetImageReference(tag string) (name.Reference, error) {
-	return name.ParseReference(fmt.Sprintf("%s:%s", r.repository.Name(), tag))
+	return name.ParseReference(fmt.Sprintf("%s:%s", r.repository.Name(), tag), r.client.nameOpts...)
 }
 
 // GetLayerReader returns a layer reader for a specific digest
@@ -62,7 +107,7 @@ func (r *Repository) GetLayerReader(ctx context.Context, digest string) (io.Read
 	}
 
 	// Parse digest as a name.Digest for the go-containerregistry API
-	digestRef, err := name.NewDigest(fmt.Sprintf("%s@%s", r.repository.Name(), digest))
+	digestRef, err := name.NewDigest(fmt.Sprintf("%s@%s", r.repository.Name(), digest), r.client.nameOpts...)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to parse digest")
 	}
@@ -89,14 +134,23 @@ func (r *Repository) GetManifest(ctx context.Context, ref string) (*interfaces.M
 	}
 
 	// Create reference (can be tag or digest)
-	reference, err := name.ParseReference(fmt.Sprintf("%s:%s", r.repository.Name(), ref))
-	if err != nil {
-		return nil, errors.Wrap(err, "failed to parse reference")
+	var reference name.Reference
+	if util.IsDigest(ref) {
+		reference = r.repository.Digest(ref)
+	} else {
+		tag, err := name.ParseReference(fmt.Sprintf("%s:%s", r.repository.Name(), ref), r.client.nameOpts...)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to parse reference")
+		}
+		reference = tag
 	}
 
 	// Get the descriptor using go-containerregistry
 	desc, err := remote.Get(reference, r.client.GetRemoteOptions()...)
 	if err != nil {
+		if isNotFound(err) {
+			return nil, errors.NotFoundf("image %s:%s not found", r.name, ref)
+		}
 		return nil, errors.Wrap(err, "failed to get manifest from registry")
 	}
 
@@ -174,7 +228,7 @@ func (r *Repository) GetConfigBlob(ctx context.Context, digest string) ([]byte,
 	}
 
 	// Parse digest as a name.Digest for the go-containerregistry API
-	digestRef, err := name.NewDigest(fmt.Sprintf("%s@%s", r.repository.Name(), digest))
+	digestRef, err := name.NewDigest(fmt.Sprintf("%s@%s", r.repository.Name(), digest), r.client.nameOpts...)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to parse digest")
 	}
@@ -246,7 +300,7 @@ func (r *Repository) PutManifest(ctx context.Context, ref string, manifest *inte
 	}
 
 	// Create a tag reference
-	tagRef, err := name.NewTag(fmt.Sprintf("%s:%s", r.repository.Name(), ref))
+	tagRef, err := name.NewTag(fmt.Sprintf("%s:%s", r.repository.Name(), ref), r.client.nameOpts...)
 	if err != nil {
 		return errors.Wrap(err, "failed to create tag reference")
 	}
@@ -290,14 +344,26 @@ func (r *Repository) PushLayer(ctx context.Context, digest string, data io.Reade
 
 // ListTags lists all tags for this repository
 func (r *Repository) ListTags(ctx context.Context) ([]string, error) {
+	// Registries with broken pagination use the configured fallback strategy
+	if listing := r.client.registryConf.TagListing; listing.Strategy != "" {
+		return r.listTagsWithStrategy(ctx, listing)
+	}
+
 	// Delegate to base repository if available
 	if r.BaseRepository != nil {
-		return r.BaseRepository.ListTags(ctx)
+		tags, err := r.BaseRepository.ListTags(ctx)
+		if isNotFound(err) {
+			return nil, errors.NotFoundf("repository %s not found", r.name)
+		}
+		return tags, err
 	}
 
 	// Fallback implementation
 	tags, err := remote.List(r.repository, r.client.GetRemoteOptions()...)
 	if err != nil {
+		if isNotFound(err) {
+			return nil, errors.NotFoundf("repository %s not found", r.name)
+		}
 		return nil, errors.Wrap(err, "failed to list tags from registry")
 	}
 
@@ -309,6 +375,88 @@ func (r *Repository) ListTags(ctx context.Context) ([]string, error) {
 	return tags, nil
 }
 
+// listTagsWithStrategy lists tags with a tag_listing strategy
+func (r *Repository) listTagsWithStrategy(ctx context.Context, listing config.TagListingConfig) ([]string, error) {
+	httpClient, err := r.tagListClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	lister := &tagLister{
+		client:     httpClient,
+		listURL:    r.tagListURL(),
+		repository: r.name,
+		strategy:   listing.Strategy,
+		pageSize:   listing.PageSize,
+		logger:     r.client.logger,
+	}
+	tags, err := lister.list(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	r.client.logger.WithFields(map[string]interface{}{
+		"repository": r.name,
+		"strategy":   listing.Strategy,
+		"tagCount":   len(tags),
+	}).Debug("Successfully listed tags from generic registry")
+
+	return tags, nil
+}
+
+// tagListClient returns an HTTP client authorized to pull from the repository
+func (r *Repository) tagListClient(ctx context.Context) (*http.Client, error) {
+	rt, err := transport.NewWithContext(
+		ctx,
+		r.repository.Registry,
+		r.client.authenticator,
+		roundtrip.Wrap(r.client.httpTransport),
+		[]string{r.repository.Scope(transport.PullScope)},
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create registry transport")
+	}
+	return &http.Client{Transport: rt}, nil
+}
+
+// tagListURL returns the repository's /tags/list URL
+func (r *Repository) tagListURL() string {
+	return fmt.Sprintf("%s://%s/v2/%s/tags/list", r.repository.Registry.Scheme(), r.repository.RegistryStr(), r.repository.RepositoryStr())
+}
+
+// TagListETag issues a conditional tag list request and reports whether the
+// list changed since previous - implements interfaces.TagListVersioner
+func (r *Repository) TagListETag(ctx context.Context, previous string) (string, bool, error) {
+	httpClient, err := r.tagListClient(ctx)
+	if err != nil {
+		return "", false, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.tagListURL(), nil)
+	if err != nil {
+		return "", false, errors.Wrap(err, "failed to create tag list request")
+	}
+	if previous != "" {
+		req.Header.Set("If-None-Match", previous)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", false, errors.Wrap(err, "tag list request failed")
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified:
+		return previous, false, nil
+	case resp.StatusCode != http.StatusOK:
+		return "", false, errors.Newf("tag list request returned %s", resp.Status)
+	}
+
+	etag := resp.Header.Get("ETag")
+	return etag, etag == "" || etag != previous, nil
+}
+
 // manifestImage is a minimal implementation of v1.Image interface for pushing manifests
 // It only implements the bare minimum required by remote.Put
 type manifestImage struct {
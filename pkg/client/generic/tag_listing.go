@@ -0,0 +1,290 @@
+package generic
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"freightliner/pkg/config"
+	"freightliner/pkg/helper/errors"
+	"freightliner/pkg/helper/log"
+)
+
+// defaultTagPageSize is the n requested per page when none is configured
+const defaultTagPageSize = 100
+
+// maxTagPages stops pagination that never ends
+const maxTagPages = 10000
+
+// commonTagPageLimits are page sizes registries cap /tags/list at. A result
+// of exactly one of these sizes without a next page is probably truncated.
+var commonTagPageLimits = []int{50, 100, 1000, 10000}
+
+// tagLister lists tags with a configured strategy, working around
+// registries whose /tags/list pagination is broken
+type tagLister struct {
+	client     *http.Client
+	listURL    string // scheme://host/v2/<repository>/tags/list
+	repository string
+	strategy   config.TagListStrategy
+	pageSize   int
+	logger     log.Logger
+}
+
+// tagPage is one /tags/list response
+type tagPage struct {
+	Tags    []string `json:"tags"`
+	next    string   // Next page URL from the Link header
+	linkErr error    // Set when the Link header can't be followed
+}
+
+// errBrokenLink is returned when a Link header can't be followed
+var errBrokenLink = errors.New("broken Link header")
+
+// list returns the repository's tags
+func (l *tagLister) list(ctx context.Context) ([]string, error) {
+	if l.pageSize <= 0 {
+		l.pageSize = defaultTagPageSize
+	}
+
+	switch l.strategy {
+	case config.TagListPage:
+		return l.listPages(ctx, nil)
+	case config.TagListFull:
+		return l.listFull(ctx)
+	case config.TagListAuto:
+		return l.listAuto(ctx)
+	default:
+		return l.listLinks(ctx)
+	}
+}
+
+// listLinks follows Link headers and fails when one is broken
+func (l *tagLister) listLinks(ctx context.Context) ([]string, error) {
+	tags, err := l.followLinks(ctx)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list tags of %s; try tag_listing.strategy page or auto", l.repository)
+	}
+	l.warnIfTruncated(tags, l.pageSize)
+	return tags, nil
+}
+
+// listAuto follows Link headers and switches to n/last pagination when they
+// are broken or the result looks truncated
+func (l *tagLister) listAuto(ctx context.Context) ([]string, error) {
+	tags, err := l.followLinks(ctx)
+	switch {
+	case errors.Is(err, errBrokenLink):
+		l.logger.WithFields(map[string]interface{}{
+			"repository": l.repository,
+			"error":      err.Error(),
+		}).Warn("Registry returned a broken Link header, falling back to n/last pagination")
+	case err != nil:
+		return nil, errors.Wrapf(err, "failed to list tags of %s", l.repository)
+	case !looksTruncated(len(tags), l.pageSize):
+		return tags, nil
+	default:
+		l.logger.WithFields(map[string]interface{}{
+			"repository": l.repository,
+			"tag_count":  len(tags),
+		}).Warn("Tag list looks truncated, falling back to n/last pagination")
+	}
+
+	return l.listPages(ctx, tags)
+}
+
+// listFull requests every tag in one response
+func (l *tagLister) listFull(ctx context.Context) ([]string, error) {
+	page, err := l.fetch(ctx, l.listURL)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list tags of %s", l.repository)
+	}
+	if page.next != "" || page.linkErr != nil {
+		l.logger.WithFields(map[string]interface{}{
+			"repository": l.repository,
+			"tag_count":  len(page.Tags),
+		}).Warn("Registry paginated the full tag list; use tag_listing.strategy page or auto")
+	}
+	l.warnIfTruncated(page.Tags, 0)
+	return page.Tags, nil
+}
+
+// followLinks requests pages of pageSize tags and follows rel="next" links
+func (l *tagLister) followLinks(ctx context.Context) ([]string, error) {
+	var tags []string
+	seen := make(map[string]bool)
+	next := l.pageURL("")
+
+	for pages := 0; next != ""; pages++ {
+		if pages >= maxTagPages {
+			return nil, errors.Wrapf(errBrokenLink, "more than %d pages", maxTagPages)
+		}
+		if seen[next] {
+			return nil, errors.Wrapf(errBrokenLink, "Link header repeats %s", next)
+		}
+		seen[next] = true
+
+		page, err := l.fetch(ctx, next)
+		if err != nil {
+			return nil, err
+		}
+		if page.linkErr != nil {
+			return nil, page.linkErr
+		}
+		tags = append(tags, page.Tags...)
+
+		if page.next != "" && len(page.Tags) == 0 {
+			return nil, errors.Wrapf(errBrokenLink, "Link header on an empty page")
+		}
+		next = page.next
+	}
+
+	return tags, nil
+}
+
+// listPages pages with n and last until a short page, ignoring Link headers.
+// Tags already listed are kept and paging continues after the last of them.
+func (l *tagLister) listPages(ctx context.Context, listed []string) ([]string, error) {
+	tags := append([]string(nil), listed...)
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		seen[tag] = true
+	}
+
+	last := ""
+	if len(tags) > 0 {
+		last = tags[len(tags)-1]
+	}
+
+	for pages := 0; ; pages++ {
+		if pages >= maxTagPages {
+			return nil, errors.Newf("tag listing of %s exceeded %d pages", l.repository, maxTagPages)
+		}
+
+		page, err := l.fetch(ctx, l.pageURL(last))
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to list tags of %s", l.repository)
+		}
+
+		added := 0
+		for _, tag := range page.Tags {
+			if !seen[tag] {
+				seen[tag] = true
+				tags = append(tags, tag)
+				added++
+			}
+		}
+
+		if len(page.Tags) < l.pageSize {
+			return tags, nil
+		}
+		if added == 0 {
+			// The registry ignores last; every page repeats the first one
+			l.logger.WithFields(map[string]interface{}{
+				"repository": l.repository,
+				"tag_count":  len(tags),
+			}).Warn("Registry ignores the last parameter, tag list may be truncated")
+			return tags, nil
+		}
+		last = page.Tags[len(page.Tags)-1]
+	}
+}
+
+// pageURL returns the list URL for a page of pageSize tags after last
+func (l *tagLister) pageURL(last string) string {
+	query := url.Values{}
+	query.Set("n", strconv.Itoa(l.pageSize))
+	if last != "" {
+		query.Set("last", last)
+	}
+	return l.listURL + "?" + query.Encode()
+}
+
+// fetch requests one page and resolves its next link. A broken Link header
+// is recorded on the page rather than failing strategies that ignore it.
+func (l *tagLister) fetch(ctx context.Context, pageURL string) (*tagPage, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, pageURL, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create tag list request")
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "tag list request failed")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Newf("tag list request returned %s", resp.Status)
+	}
+
+	var page tagPage
+	if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+		return nil, errors.Wrap(err, "failed to decode tag list")
+	}
+
+	if link := resp.Header.Get("Link"); link != "" {
+		page.next, page.linkErr = nextLink(resp.Request.URL, link)
+	}
+
+	return &page, nil
+}
+
+// warnIfTruncated logs a warning when a tag count matches a page limit
+func (l *tagLister) warnIfTruncated(tags []string, pageSize int) {
+	if looksTruncated(len(tags), pageSize) {
+		l.logger.WithFields(map[string]interface{}{
+			"repository": l.repository,
+			"tag_count":  len(tags),
+		}).Warn("Tag list may be truncated by the registry; try tag_listing.strategy page or auto")
+	}
+}
+
+// nextLink returns the rel="next" URL of a Link header, resolved against the
+// request URL. Links to another host are treated as broken.
+func nextLink(base *url.URL, header string) (string, error) {
+	for _, part := range strings.Split(header, ",") {
+		part = strings.TrimSpace(part)
+		if !strings.Contains(part, `rel="next"`) && !strings.Contains(part, "rel=next") {
+			continue
+		}
+
+		start := strings.Index(part, "<")
+		end := strings.Index(part, ">")
+		if start != 0 || end < start {
+			return "", errors.Wrapf(errBrokenLink, "%q", header)
+		}
+
+		ref, err := url.Parse(part[start+1 : end])
+		if err != nil {
+			return "", errors.Wrapf(errBrokenLink, "%q", header)
+		}
+		next := base.ResolveReference(ref)
+		if next.Host != base.Host {
+			return "", errors.Wrapf(errBrokenLink, "next page on another host %s", next.Host)
+		}
+		return next.String(), nil
+	}
+	return "", nil
+}
+
+// looksTruncated reports whether a tag count equals the requested page size
+// or a page limit registries commonly enforce
+func looksTruncated(count, pageSize int) bool {
+	if count == 0 {
+		return false
+	}
+	if count == pageSize {
+		return true
+	}
+	for _, limit := range commonTagPageLimits {
+		if count == limit {
+			return true
+		}
+	}
+	return false
+}
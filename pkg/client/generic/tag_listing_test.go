@@ -0,0 +1,186 @@
+package generic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"freightliner/pkg/config"
+	"freightliner/pkg/helper/log"
+)
+
+// fakeTagRegistry serves /tags/list for tags with optional broken behavior
+type fakeTagRegistry struct {
+	tags        []string
+	maxPage     int    // Caps n like registries that ignore larger page sizes
+	link        string // "good", "broken", "loop" or "" for no Link header
+	ignoresLast bool
+}
+
+func (f *fakeTagRegistry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	tags := append([]string(nil), f.tags...)
+	sort.Strings(tags)
+
+	query := r.URL.Query()
+	if last := query.Get("last"); last != "" && !f.ignoresLast {
+		start := sort.SearchStrings(tags, last)
+		if start < len(tags) && tags[start] == last {
+			start++
+		}
+		tags = tags[start:]
+	}
+
+	n := len(tags)
+	if value := query.Get("n"); value != "" {
+		n, _ = strconv.Atoi(value)
+	}
+	if f.maxPage > 0 && n > f.maxPage {
+		n = f.maxPage
+	}
+	page := tags
+	if n < len(tags) {
+		page = tags[:n]
+	}
+
+	if len(page) > 0 && len(page) < len(tags) {
+		switch f.link {
+		case "good":
+			next := url.Values{"n": {strconv.Itoa(n)}, "last": {page[len(page)-1]}}
+			w.Header().Set("Link", fmt.Sprintf(`</v2/app/tags/list?%s>; rel="next"`, next.Encode()))
+		case "broken":
+			w.Header().Set("Link", `/v2/app/tags/list?last=oops; rel="next"`)
+		case "loop":
+			w.Header().Set("Link", `</v2/app/tags/list?n=`+strconv.Itoa(n)+`>; rel="next"`)
+		}
+	}
+
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"name": "app", "tags": page})
+}
+
+func numberedTags(count int) []string {
+	tags := make([]string, count)
+	for i := range tags {
+		tags[i] = fmt.Sprintf("v%03d", i)
+	}
+	return tags
+}
+
+func listWith(t *testing.T, registry *fakeTagRegistry, strategy config.TagListStrategy, pageSize int) ([]string, error) {
+	t.Helper()
+	server := httptest.NewServer(registry)
+	defer server.Close()
+
+	lister := &tagLister{
+		client:     server.Client(),
+		listURL:    server.URL + "/v2/app/tags/list",
+		repository: "app",
+		strategy:   strategy,
+		pageSize:   pageSize,
+		logger:     log.NewBasicLogger(log.ErrorLevel),
+	}
+	return lister.list(context.Background())
+}
+
+func TestTagLister(t *testing.T) {
+	all := numberedTags(25)
+
+	tests := []struct {
+		name        string
+		registry    fakeTagRegistry
+		strategy    config.TagListStrategy
+		want        int
+		errContains string
+	}{
+		{name: "link follows pages", registry: fakeTagRegistry{tags: all, link: "good"}, strategy: config.TagListLink, want: 25},
+		{name: "link fails on broken header", registry: fakeTagRegistry{tags: all, link: "broken"}, strategy: config.TagListLink, errContains: "broken Link header"},
+		{name: "link fails on loop", registry: fakeTagRegistry{tags: all, link: "loop"}, strategy: config.TagListLink, errContains: "repeats"},
+		{name: "page ignores Link headers", registry: fakeTagRegistry{tags: all, link: "broken"}, strategy: config.TagListPage, want: 25},
+		{name: "page stops when last is ignored", registry: fakeTagRegistry{tags: all, ignoresLast: true}, strategy: config.TagListPage, want: 10},
+		{name: "full single request", registry: fakeTagRegistry{tags: all}, strategy: config.TagListFull, want: 25},
+		{name: "auto falls back on broken header", registry: fakeTagRegistry{tags: all, link: "broken"}, strategy: config.TagListAuto, want: 25},
+		{name: "auto falls back on missing header", registry: fakeTagRegistry{tags: all}, strategy: config.TagListAuto, want: 25},
+		{name: "auto with working links", registry: fakeTagRegistry{tags: all, link: "good"}, strategy: config.TagListAuto, want: 25},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registry := tt.registry
+			tags, err := listWith(t, &registry, tt.strategy, 10)
+			if tt.errContains != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errContains) {
+					t.Fatalf("expected error containing %q, got %v", tt.errContains, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(tags) != tt.want {
+				t.Fatalf("expected %d tags, got %d: %v", tt.want, len(tags), tags)
+			}
+			if tt.want == len(all) && !reflect.DeepEqual(tags, all) {
+				t.Errorf("expected tags %v, got %v", all, tags)
+			}
+		})
+	}
+}
+
+func TestTagListerAutoCappedPageSize(t *testing.T) {
+	// The registry caps pages at 5 and sends no Link header, so a request for
+	// 10 tags silently returns 5
+	registry := &fakeTagRegistry{tags: numberedTags(12), maxPage: 5}
+
+	tags, err := listWith(t, registry, config.TagListAuto, 5)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(tags) != 12 {
+		t.Fatalf("expected 12 tags, got %d: %v", len(tags), tags)
+	}
+}
+
+func TestNextLink(t *testing.T) {
+	base, _ := url.Parse("https://registry.example.com/v2/app/tags/list?n=10")
+
+	tests := []struct {
+		name    string
+		header  string
+		want    string
+		wantErr bool
+	}{
+		{name: "relative", header: `</v2/app/tags/list?n=10&last=b>; rel="next"`, want: "https://registry.example.com/v2/app/tags/list?n=10&last=b"},
+		{name: "absolute", header: `<https://registry.example.com/v2/app/tags/list?last=b>; rel="next"`, want: "https://registry.example.com/v2/app/tags/list?last=b"},
+		{name: "no next", header: `</v2/app/tags/list>; rel="prev"`, want: ""},
+		{name: "missing brackets", header: `/v2/app/tags/list?last=b; rel="next"`, wantErr: true},
+		{name: "other host", header: `<https://evil.example.com/v2/app/tags/list>; rel="next"`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := nextLink(base, tt.header)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("nextLink() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("nextLink() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLooksTruncated(t *testing.T) {
+	if !looksTruncated(100, 0) || !looksTruncated(7, 7) {
+		t.Error("expected page-sized counts to look truncated")
+	}
+	if looksTruncated(0, 0) || looksTruncated(42, 10) {
+		t.Error("expected other counts not to look truncated")
+	}
+}
@@ -0,0 +1,63 @@
+package generic
+
+import (
+	"context"
+
+	"freightliner/pkg/auth"
+	"freightliner/pkg/config"
+	"freightliner/pkg/helper/errors"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+)
+
+// tokenExchangeAuthenticator authenticates with registry tokens obtained by
+// OIDC token exchange. The registry transport asks for credentials again when
+// its token is rejected, which exchanges a fresh token once the cached one
+// is near expiry.
+type tokenExchangeAuthenticator struct {
+	exchanger *auth.TokenExchanger
+	username  string
+}
+
+// newTokenExchangeAuthenticator creates an authenticator for oidc auth. With
+// a username the exchanged token is sent as a password; without one it is
+// sent to the registry as a bearer token.
+func newTokenExchangeAuthenticator(conf config.AuthConfig) (authn.Authenticator, error) {
+	exchange := conf.TokenExchange
+
+	grantType := auth.GrantTypeTokenExchange
+	if exchange.GrantType == "client-credentials" {
+		grantType = auth.GrantTypeClientCredentials
+	}
+
+	exchanger, err := auth.NewTokenExchanger(auth.TokenExchangeOptions{
+		TokenURL:            expandEnvVars(exchange.TokenURL),
+		Audience:            exchange.Audience,
+		Scope:               exchange.Scope,
+		ClientID:            exchange.ClientID,
+		ClientAssertion:     expandEnvVars(exchange.ClientAssertion),
+		ClientAssertionFile: expandEnvVars(exchange.ClientAssertionFile),
+		GrantType:           grantType,
+	})
+	if err != nil {
+		return nil, errors.InvalidInputf("invalid oidc auth: %v", err)
+	}
+
+	return &tokenExchangeAuthenticator{
+		exchanger: exchanger,
+		username:  expandEnvVars(conf.Username),
+	}, nil
+}
+
+// Authorization returns credentials built from the current registry token
+func (a *tokenExchangeAuthenticator) Authorization() (*authn.AuthConfig, error) {
+	token, err := a.exchanger.Token(context.Background())
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to obtain registry token")
+	}
+
+	if a.username != "" {
+		return &authn.AuthConfig{Username: a.username, Password: token}, nil
+	}
+	return &authn.AuthConfig{RegistryToken: token}, nil
+}
@@ -11,6 +11,7 @@ import (
 	"sync"
 	"time"
 
+	"freightliner/pkg/helper/clockskew"
 	"freightliner/pkg/helper/errors"
 
 	"github.com/google/go-containerregistry/pkg/authn"
@@ -234,12 +235,13 @@ func (a *HarborAuthenticator) fetchToken() (string, time.Time, error) {
 		token = tokenResp.AccessToken
 	}
 
-	// Calculate expiration
-	expiresAt := time.Now().Add(time.Duration(tokenResp.ExpiresIn) * time.Second)
+	// Calculate expiration, from issued_at when correcting for clock skew
+	lifetime := time.Duration(tokenResp.ExpiresIn) * time.Second
 	if tokenResp.ExpiresIn == 0 {
 		// Default to 1 hour if not specified
-		expiresAt = time.Now().Add(1 * time.Hour)
+		lifetime = 1 * time.Hour
 	}
+	expiresAt := clockskew.Expiry(tokenResp.IssuedAt, lifetime)
 
 	return token, expiresAt, nil
 }
@@ -0,0 +1,76 @@
+package harbor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+
+	"freightliner/pkg/helper/errors"
+	"freightliner/pkg/helper/readonly"
+)
+
+// gcScheduleRequest starts a manual garbage collection through the Harbor API
+type gcScheduleRequest struct {
+	Schedule   gcSchedule             `json:"schedule"`
+	Parameters map[string]interface{} `json:"parameters"`
+}
+
+type gcSchedule struct {
+	Type string `json:"type"`
+}
+
+// StartGarbageCollection starts a manual garbage collection, which needs a
+// Harbor administrator - implements interfaces.GarbageCollector
+func (c *Client) StartGarbageCollection(ctx context.Context, deleteUntagged bool) (string, error) {
+	if err := readonly.Check(fmt.Sprintf("start garbage collection on %s", c.registryURL)); err != nil {
+		return "", err
+	}
+
+	body, err := json.Marshal(gcScheduleRequest{
+		Schedule:   gcSchedule{Type: "Manual"},
+		Parameters: map[string]interface{}{"delete_untagged": deleteUntagged},
+	})
+	if err != nil {
+		return "", errors.Wrap(err, "failed to marshal garbage collection request")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.apiURL+"/system/gc/schedule", bytes.NewReader(body))
+	if err != nil {
+		return "", errors.Wrap(err, "failed to create garbage collection request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	authConfig, err := c.auth.Authorization()
+	if err != nil {
+		return "", errors.Wrap(err, "failed to get authorization")
+	}
+	if authConfig.Username != "" && authConfig.Password != "" {
+		req.SetBasicAuth(authConfig.Username, authConfig.Password)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to start garbage collection")
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusCreated, http.StatusOK:
+	case http.StatusConflict:
+		// A collection is already running and will reclaim the space too
+		return "", nil
+	default:
+		respBody, _ := io.ReadAll(resp.Body)
+		return "", errors.InvalidInputf("garbage collection request failed: %s - %s", resp.Status, string(respBody))
+	}
+
+	// Location names the new run, e.g. /api/v2.0/system/gc/42
+	if location := resp.Header.Get("Location"); location != "" {
+		return path.Base(location), nil
+	}
+	return "", nil
+}
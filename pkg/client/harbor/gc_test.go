@@ -0,0 +1,30 @@
+package harbor
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"freightliner/pkg/helper/log"
+	"freightliner/pkg/helper/readonly"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStartGarbageCollectionReadOnly(t *testing.T) {
+	client, err := NewClient(ClientOptions{
+		RegistryURL: "harbor.invalid",
+		Username:    "admin",
+		Password:    "secret",
+		Logger:      log.NewBasicLogger(log.ErrorLevel),
+	})
+	require.NoError(t, err)
+
+	// Read-only mode stays on for the rest of this test binary
+	readonly.Enable()
+
+	_, err = client.StartGarbageCollection(context.Background(), true)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, readonly.ErrReadOnly), "%v", err)
+}
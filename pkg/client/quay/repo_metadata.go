@@ -0,0 +1,93 @@
+package quay
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"freightliner/pkg/helper/errors"
+	"freightliner/pkg/interfaces"
+)
+
+// GetRepositoryMetadata returns the repository's markdown description as its
+// readme - implements interfaces.RepositoryMetadataReader
+func (r *Repository) GetRepositoryMetadata(ctx context.Context) (*interfaces.RepositoryMetadata, error) {
+	resp, err := r.repositoryRequest(ctx, http.MethodGet, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Description string `json:"description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, errors.Wrap(err, "failed to parse repository response")
+	}
+
+	// Quay has a single markdown description, shown as the repository's readme
+	return &interfaces.RepositoryMetadata{Readme: result.Description}, nil
+}
+
+// SetRepositoryMetadata replaces the repository's markdown description -
+// implements interfaces.RepositoryMetadataWriter
+func (r *Repository) SetRepositoryMetadata(ctx context.Context, metadata interfaces.RepositoryMetadata) error {
+	description := metadata.Readme
+	if description == "" {
+		description = metadata.Description
+	}
+
+	body, err := json.Marshal(map[string]string{"description": description})
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal repository description")
+	}
+
+	resp, err := r.repositoryRequest(ctx, http.MethodPut, body)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// repositoryRequest calls the Quay repository API for this repository and
+// returns the successful response
+func (r *Repository) repositoryRequest(ctx context.Context, method string, body []byte) (*http.Response, error) {
+	apiURL := fmt.Sprintf("%s/repository/%s", r.client.apiURL, r.name)
+
+	req, err := http.NewRequestWithContext(ctx, method, apiURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create repository request")
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	authConfig, err := r.client.auth.Authorization()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get authorization")
+	}
+	if authConfig.IdentityToken != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", authConfig.IdentityToken))
+	} else if authConfig.Username != "" && authConfig.Password != "" {
+		req.SetBasicAuth(authConfig.Username, authConfig.Password)
+	}
+
+	resp, err := r.client.httpClient.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to call repository API")
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, errors.NotFoundf("repository %s not found", r.name)
+	}
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		return nil, errors.InvalidInputf("repository request failed: %s - %s", resp.Status, string(respBody))
+	}
+	return resp, nil
+}
@@ -0,0 +1,107 @@
+package quay
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+
+	"freightliner/pkg/helper/errors"
+	"freightliner/pkg/interfaces"
+)
+
+// tagHistoryPageLimit is the page size requested from the Quay tag API
+const tagHistoryPageLimit = 100
+
+// quayTag is one entry of the Quay tag API. Entries with an end_ts are past
+// positions of the tag.
+type quayTag struct {
+	Name           string `json:"name"`
+	ManifestDigest string `json:"manifest_digest"`
+	StartTS        int64  `json:"start_ts"`
+	EndTS          int64  `json:"end_ts,omitempty"`
+}
+
+// ListTagHistory returns the digests tag has pointed to, newest first, from
+// the Quay tag API - implements interfaces.TagHistoryLister
+func (r *Repository) ListTagHistory(ctx context.Context, tag string) ([]interfaces.TagDigest, error) {
+	if tag == "" {
+		return nil, errors.InvalidInputf("tag cannot be empty")
+	}
+
+	var entries []quayTag
+	for page := 1; ; page++ {
+		params := url.Values{}
+		params.Set("specificTag", tag)
+		params.Set("onlyActiveTags", "false")
+		params.Set("limit", fmt.Sprintf("%d", tagHistoryPageLimit))
+		params.Set("page", fmt.Sprintf("%d", page))
+		apiURL := fmt.Sprintf("%s/repository/%s/tag/?%s", r.client.apiURL, r.name, params.Encode())
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, apiURL, nil)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create tag history request")
+		}
+
+		authConfig, err := r.client.auth.Authorization()
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get authorization")
+		}
+		if authConfig.IdentityToken != "" {
+			req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", authConfig.IdentityToken))
+		} else if authConfig.Username != "" && authConfig.Password != "" {
+			req.SetBasicAuth(authConfig.Username, authConfig.Password)
+		}
+
+		resp, err := r.client.httpClient.Do(req)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to list tag history")
+		}
+
+		var result struct {
+			Tags          []quayTag `json:"tags"`
+			HasAdditional bool      `json:"has_additional"`
+		}
+		if resp.StatusCode != http.StatusOK {
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			return nil, errors.InvalidInputf("list tag history failed: %s - %s", resp.Status, string(body))
+		}
+		err = json.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to parse tag history response")
+		}
+
+		entries = append(entries, result.Tags...)
+		if !result.HasAdditional || len(result.Tags) == 0 {
+			break
+		}
+	}
+
+	return tagHistory(tag, entries), nil
+}
+
+// tagHistory orders the entries of tag newest first
+func tagHistory(tag string, entries []quayTag) []interfaces.TagDigest {
+	var history []interfaces.TagDigest
+	for _, entry := range entries {
+		if entry.Name != tag || entry.ManifestDigest == "" {
+			continue
+		}
+		history = append(history, interfaces.TagDigest{
+			Tag:      tag,
+			Digest:   entry.ManifestDigest,
+			PushedAt: time.Unix(entry.StartTS, 0).UTC(),
+		})
+	}
+
+	sort.SliceStable(history, func(i, j int) bool {
+		return history[i].PushedAt.After(history[j].PushedAt)
+	})
+	return history
+}
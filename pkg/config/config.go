@@ -1,12 +1,12 @@
 package config
 
 import (
-	"os"
-	"path/filepath"
+	"fmt"
 	"runtime"
-	"strings"
 	"time"
 
+	"freightliner/pkg/helper/util"
+
 	"github.com/spf13/cobra"
 )
 
@@ -15,6 +15,16 @@ type Config struct {
 	// General configuration
 	LogLevel string `yaml:"log_level" json:"log_level"`
 
+	// LogSampling limits repeated debug lines, such as per-tag progress
+	LogSampling LogSamplingConfig `yaml:"log_sampling" json:"log_sampling"`
+
+	// Timeout bounds a whole command; zero uses the command's own default
+	Timeout time.Duration `yaml:"timeout" json:"timeout"`
+
+	// Profile names a concurrency preset (conservative, balanced,
+	// aggressive) supplying defaults for workers, retries and rate limits
+	Profile string `yaml:"profile,omitempty" json:"profile,omitempty"`
+
 	// Registry configuration
 	ECR        ECRConfig        `yaml:"ecr" json:"ecr"`
 	GCR        GCRConfig        `yaml:"gcr" json:"gcr"`
@@ -43,18 +53,122 @@ type Config struct {
 
 	// Replicate configuration
 	Replicate ReplicateConfig `yaml:"replicate" json:"replicate"`
+
+	// Run report upload configuration
+	Reports ReportsConfig `yaml:"reports" json:"reports"`
+
+	// End-of-run transfer attestation
+	Attestation AttestationConfig `yaml:"attestation" json:"attestation"`
+
+	// Re-signing of images whose digest changes on copy
+	Resign ResignConfig `yaml:"resign" json:"resign"`
+
+	// Registry request retry configuration
+	Retry RetryConfig `yaml:"retry" json:"retry"`
+
+	// Network dialing configuration
+	Network NetworkConfig `yaml:"network" json:"network"`
+
+	// Scheduled pruning in server mode
+	Prune PruneConfig `yaml:"prune" json:"prune"`
+
+	// Runtime diagnostics endpoints
+	Debug DebugConfig `yaml:"debug" json:"debug"`
+
+	// Signing of the webhooks the server sends and receives
+	Webhooks WebhookConfig `yaml:"webhooks" json:"webhooks"`
+
+	// WorkDir stages partially streamed blobs; empty uses a "freightliner"
+	// directory under the system temporary directory
+	WorkDir string `yaml:"work_dir" json:"work_dir"`
+
+	// ExplainFilters logs which filter rule included or excluded each
+	// repository and tag
+	ExplainFilters bool `yaml:"explain_filters" json:"explain_filters"`
+
+	// ReadOnly refuses every registry write (push, tag, repository creation,
+	// delete), so read-only commands can run with write-capable credentials
+	ReadOnly bool `yaml:"read_only" json:"read_only"`
+
+	// RunID identifies the run in logs, metrics, checkpoints and reports;
+	// empty generates a new one for every command
+	RunID string `yaml:"run_id" json:"run_id"`
+
+	// AnnotateRunID records the run ID on every manifest pushed to a
+	// destination
+	AnnotateRunID bool `yaml:"annotate_run_id" json:"annotate_run_id"`
+
+	// ClockSkewThreshold is how far the host clock may differ from the Date
+	// headers of registry responses before a warning is logged; zero turns
+	// detection off
+	ClockSkewThreshold time.Duration `yaml:"clock_skew_threshold" json:"clock_skew_threshold"`
+
+	// CompensateClockSkew corrects token expiry calculations by the detected
+	// skew
+	CompensateClockSkew bool `yaml:"compensate_clock_skew" json:"compensate_clock_skew"`
+
+	// LogHTTP logs every request to these registry hosts, with credentials
+	// redacted; "*" logs all hosts
+	LogHTTP []string `yaml:"log_http" json:"log_http"`
+
+	// Tenants served by one server-mode deployment
+	Tenants []TenantConfig `yaml:"tenants,omitempty" json:"tenants,omitempty"`
+
+	// Tenant is set on configs derived with ForTenant and scopes every
+	// service built from them to that tenant
+	Tenant *TenantConfig `yaml:"-" json:"-"`
 }
 
 // ECRConfig contains AWS ECR specific configuration
 type ECRConfig struct {
 	Region    string `yaml:"region" json:"region"`
 	AccountID string `yaml:"account_id" json:"account_id"`
+
+	// NativeReplication controls what happens when native ECR replication already
+	// covers a copy: "warn" (default), "skip" or "ignore"
+	NativeReplication string `yaml:"native_replication" json:"native_replication"`
+
+	// PullThroughCache controls what happens when the destination repository
+	// is an ECR pull through cache of the source repository: "warn"
+	// (default), "skip" or "ignore"
+	PullThroughCache string `yaml:"pull_through_cache" json:"pull_through_cache"`
+
+	// Profile, RoleARN and the static keys select credentials explicitly
+	// instead of the default AWS chain
+	Profile         string `yaml:"profile,omitempty" json:"profile,omitempty"`
+	RoleARN         string `yaml:"role_arn,omitempty" json:"role_arn,omitempty"`
+	AccessKeyID     string `yaml:"access_key_id,omitempty" json:"access_key_id,omitempty"`
+	SecretAccessKey string `yaml:"secret_access_key,omitempty" json:"secret_access_key,omitempty"`
+	SessionToken    string `yaml:"session_token,omitempty" json:"session_token,omitempty"`
+}
+
+// HasExplicitCredentials reports whether the config names its own AWS
+// credentials rather than relying on the process environment
+func (c ECRConfig) HasExplicitCredentials() bool {
+	return c.Profile != "" || c.RoleARN != "" || (c.AccessKeyID != "" && c.SecretAccessKey != "")
 }
 
+// Policies for repositories already covered by native ECR replication
+const (
+	NativeReplicationWarn   = "warn"
+	NativeReplicationSkip   = "skip"
+	NativeReplicationIgnore = "ignore"
+)
+
+// Policies for destination repositories that are pull through caches of the source
+const (
+	PullThroughCacheWarn   = "warn"
+	PullThroughCacheSkip   = "skip"
+	PullThroughCacheIgnore = "ignore"
+)
+
 // GCRConfig contains Google Container Registry specific configuration
 type GCRConfig struct {
 	Project  string `yaml:"project" json:"project"`
 	Location string `yaml:"location" json:"location"`
+
+	// CredentialsFile is a service account key used instead of application default credentials
+	CredentialsFile string `yaml:"credentials_file,omitempty" json:"credentials_file,omitempty"`
 }
 
 // WorkerConfig contains worker pool configuration
@@ -106,6 +220,39 @@ type ServerConfig struct {
 	ReplicatePath     string        `yaml:"replicate_path" json:"replicate_path"`
 	TreeReplicatePath string        `yaml:"tree_replicate_path" json:"tree_replicate_path"`
 	StatusPath        string        `yaml:"status_path" json:"status_path"`
+	Dashboard         bool          `yaml:"dashboard" json:"dashboard"`             // Serve the web dashboard at /ui/
+	ClusterNodes      []string      `yaml:"cluster_nodes" json:"cluster_nodes"`     // Admin addresses of distributed nodes shown on the dashboard
+	IdempotencyTTL    time.Duration `yaml:"idempotency_ttl" json:"idempotency_ttl"` // How long copy request idempotency keys are remembered
+}
+
+// BaseURL returns the URL clients use to reach the server
+func (c ServerConfig) BaseURL() string {
+	// Use external URL if configured
+	if c.ExternalURL != "" {
+		return c.ExternalURL
+	}
+
+	// Construct from host and port
+	protocol := "http"
+	if c.TLSEnabled {
+		protocol = "https"
+	}
+
+	host := c.Host
+	port := c.Port
+
+	// Handle special cases
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		// Binding to all interfaces - use localhost for URL
+		host = "localhost"
+	}
+
+	// Standard ports don't need to be in URL
+	if (protocol == "http" && port == 80) || (protocol == "https" && port == 443) {
+		return fmt.Sprintf("%s://%s", protocol, host)
+	}
+
+	return fmt.Sprintf("%s://%s:%d", protocol, host, port)
 }
 
 // CheckpointConfig contains checkpoint related configuration
@@ -117,6 +264,8 @@ type CheckpointConfig struct {
 // TreeReplicateConfig contains tree replication options
 type TreeReplicateConfig struct {
 	Workers          int      `yaml:"workers" json:"workers"`
+	TagWorkers       int      `yaml:"tag_workers" json:"tag_workers"`
+	MaxTransfers     int      `yaml:"max_transfers" json:"max_transfers"`
 	ExcludeRepos     []string `yaml:"exclude_repos" json:"exclude_repos"`
 	ExcludeTags      []string `yaml:"exclude_tags" json:"exclude_tags"`
 	IncludeTags      []string `yaml:"include_tags" json:"include_tags"`
@@ -127,6 +276,32 @@ type TreeReplicateConfig struct {
 	ResumeID         string   `yaml:"resume_id" json:"resume_id"`
 	SkipCompleted    bool     `yaml:"skip_completed" json:"skip_completed"`
 	RetryFailed      bool     `yaml:"retry_failed" json:"retry_failed"`
+
+	// CheckpointWriteTimeout bounds each background checkpoint write
+	CheckpointWriteTimeout time.Duration `yaml:"checkpoint_write_timeout" json:"checkpoint_write_timeout"`
+
+	// CheckpointSlowStore is wait (default) to keep checkpointing after a
+	// write times out, or disable to stop checkpointing for the run
+	CheckpointSlowStore string `yaml:"checkpoint_slow_store" json:"checkpoint_slow_store"`
+
+	// NameMapping fits destination repository names to registries with
+	// other path depth and length limits than the source
+	NameMapping NameMappingConfig `yaml:"name_mapping" json:"name_mapping"`
+}
+
+// NameMappingConfig maps source repositories to destination names
+type NameMappingConfig struct {
+	// Strategy is preserve (default), flatten, hash or truncate
+	Strategy string `yaml:"strategy" json:"strategy"`
+
+	// MaxDepth is the most path components of a destination name (0 = no limit)
+	MaxDepth int `yaml:"max_depth" json:"max_depth"`
+
+	// MaxLength is the most characters of a destination name (0 = no limit)
+	MaxLength int `yaml:"max_length" json:"max_length"`
+
+	// Separator joins flattened path components: -, --, _, __ or . (default -)
+	Separator string `yaml:"separator" json:"separator"`
 }
 
 // ReplicateConfig contains single repository replication options
@@ -134,15 +309,260 @@ type ReplicateConfig struct {
 	Force  bool     `yaml:"force" json:"force"`
 	DryRun bool     `yaml:"dry_run" json:"dry_run"`
 	Tags   []string `yaml:"tags" json:"tags"`
+
+	// CreateMissingRepos controls whether a missing destination repository is
+	// created (true), reported as an error (false) or created after confirmation (prompt)
+	CreateMissingRepos string `yaml:"create_missing_repos" json:"create_missing_repos"`
+
+	// RepositoryTemplate holds the settings applied to repositories created during replication
+	RepositoryTemplate RepositoryTemplateConfig `yaml:"repository_template" json:"repository_template"`
+
+	// ScanFindings attaches the source registry's vulnerability scan summary to
+	// each copied image as an OCI referrer at the destination
+	ScanFindings bool `yaml:"scan_findings" json:"scan_findings"`
+
+	// CopyRepoMetadata copies repository descriptions and readmes to the
+	// destination where both registries keep them (Quay, Harbor, Docker Hub sources)
+	CopyRepoMetadata bool `yaml:"copy_repo_metadata" json:"copy_repo_metadata"`
+
+	// Referrers copies signatures, attestations and other referrers of each
+	// copied image, whether stored as OCI referrers or under cosign's tags
+	Referrers bool `yaml:"referrers" json:"referrers"`
+
+	// ReferrersScheme stores copied referrers as auto (default), oci or tags
+	ReferrersScheme string `yaml:"referrers_scheme" json:"referrers_scheme"`
+
+	// Checkpoint records each copied tag in CheckpointDir so an interrupted
+	// run can be resumed
+	Checkpoint    bool   `yaml:"checkpoint" json:"checkpoint"`
+	CheckpointDir string `yaml:"checkpoint_dir" json:"checkpoint_dir"`
+
+	// ResumeID resumes a checkpoint, skipping the tags it completed; "latest"
+	// picks the newest unfinished checkpoint of the same repositories
+	ResumeID string `yaml:"resume_id" json:"resume_id"`
+}
+
+// Repository auto-creation policies
+const (
+	CreateMissingReposTrue   = "true"
+	CreateMissingReposFalse  = "false"
+	CreateMissingReposPrompt = "prompt"
+)
+
+// RepositoryTemplateConfig contains the settings applied to newly created
+// repositories. Registries that cannot apply a setting ignore it with a warning.
+type RepositoryTemplateConfig struct {
+	Tags          map[string]string `yaml:"tags" json:"tags"`
+	ImmutableTags bool              `yaml:"immutable_tags" json:"immutable_tags"`
+	ScanOnPush    bool              `yaml:"scan_on_push" json:"scan_on_push"`
+
+	// EncryptionType is AES256 or KMS (empty keeps the registry default)
+	EncryptionType string `yaml:"encryption_type" json:"encryption_type"`
+
+	// KMSKey is the key used when EncryptionType is KMS
+	KMSKey string `yaml:"kms_key" json:"kms_key"`
+}
+
+// ReportsConfig controls uploading run reports to object storage
+type ReportsConfig struct {
+	// UploadURL is the destination bucket, e.g. s3://bucket/prefix or gs://bucket/prefix.
+	// Reports are not uploaded when empty.
+	UploadURL string `yaml:"upload_url" json:"upload_url"`
+
+	// KeyTemplate is a text/template for object keys; available fields are
+	// .Date, .Time, .RunID, .JobID, .Command and .Name
+	KeyTemplate string `yaml:"key_template" json:"key_template"`
+
+	// Region is the AWS region of an S3 bucket (defaults to the AWS credential chain)
+	Region string `yaml:"region" json:"region"`
+
+	// LayerReuseOutput is the file the layer reuse report is written to: per
+	// image, the layers uploaded, mounted or already present, and the
+	// repositories mounts came from. No report is produced when empty.
+	LayerReuseOutput string `yaml:"layer_reuse_output" json:"layer_reuse_output"`
+}
+
+// AttestationConfig controls the in-toto attestation of what a run pushed
+type AttestationConfig struct {
+	// Output is the file the attestation is written to; no attestation is
+	// produced when empty
+	Output string `yaml:"output" json:"output"`
+
+	// KeyFile is an unencrypted PEM private key (ECDSA, Ed25519 or RSA) used to
+	// sign the attestation as a DSSE envelope. The statement is written
+	// unsigned when empty.
+	KeyFile string `yaml:"key_file" json:"key_file"`
+}
+
+// ResignConfig controls re-signing of images whose destination digest differs
+// from the source, e.g. a platform image copied out of a multi-arch index
+type ResignConfig struct {
+	// KeyFile is an unencrypted PEM private key (ECDSA, Ed25519 or RSA) used to
+	// push a cosign signature for the destination digest, or an awskms:// or
+	// gcpkms:// reference to a key that signs in KMS. Mutated images are not
+	// re-signed when empty.
+	KeyFile string `yaml:"key_file" json:"key_file"`
+}
+
+// LogSamplingConfig samples repeated debug messages each second
+type LogSamplingConfig struct {
+	// First is the number of identical debug messages logged each second
+	// before sampling starts; 0 disables sampling
+	First int `yaml:"first" json:"first"`
+
+	// Thereafter logs every Nth further message; 0 drops them
+	Thereafter int `yaml:"thereafter" json:"thereafter"`
+}
+
+// RetryConfig bounds retries of throttled or failed registry requests
+type RetryConfig struct {
+	// Budget is the total number of retries allowed per run across all
+	// requests; 0 allows unlimited retries
+	Budget int `yaml:"budget" json:"budget"`
+
+	// MaxRetries is the number of retries for a single request
+	MaxRetries int `yaml:"max_retries" json:"max_retries"`
+}
+
+// NetworkConfig controls how registry connections are dialed
+type NetworkConfig struct {
+	// Resolve pins host:port to fixed addresses instead of DNS, in curl's
+	// "host:port:address[,address]" format
+	Resolve []string `yaml:"resolve,omitempty" json:"resolve,omitempty"`
+
+	// DNS caches registry host lookups in-process
+	DNS DNSConfig `yaml:"dns" json:"dns"`
+
+	// MaxRequestsPerSecond caps the API calls made to each registry; 0 is
+	// unlimited
+	MaxRequestsPerSecond float64 `yaml:"max_requests_per_second" json:"max_requests_per_second"`
+}
+
+// DNSConfig controls the in-process DNS cache, which keeps registries
+// reachable while their DNS flaps
+type DNSConfig struct {
+	// Cache enables the cache; hosts resolve through the system on every
+	// dial when false
+	Cache bool `yaml:"cache" json:"cache"`
+
+	// MinTTL and MaxTTL bound how long an answer is cached; within them the
+	// record's own TTL is used
+	MinTTL time.Duration `yaml:"min_ttl" json:"min_ttl"`
+	MaxTTL time.Duration `yaml:"max_ttl" json:"max_ttl"`
+
+	// NegativeTTL is how long a host that does not exist is remembered;
+	// 0 disables negative caching
+	NegativeTTL time.Duration `yaml:"negative_ttl" json:"negative_ttl"`
+
+	// Retries is how often a lookup answered with SERVFAIL or timing out is
+	// retried
+	Retries int `yaml:"retries" json:"retries"`
+
+	// ServeStale is how long past its TTL an answer is still used when
+	// refreshing it fails; 0 disables stale answers
+	ServeStale time.Duration `yaml:"serve_stale" json:"serve_stale"`
+}
+
+// PruneConfig controls the prune rules the server runs on their schedules
+type PruneConfig struct {
+	// SyncConfig is a sync configuration file, or an appconfig:// or
+	// runtimeconfig:// location, whose prune rules are scheduled; nothing is
+	// pruned when empty
+	SyncConfig string `yaml:"sync_config" json:"sync_config"`
+
+	// PollInterval is how often SyncConfig is checked for changes, which
+	// replace the scheduled rules without a restart; zero loads it once
+	PollInterval time.Duration `yaml:"poll_interval" json:"poll_interval"`
+
+	// ReportDir receives a JSON report of every scheduled prune run
+	ReportDir string `yaml:"report_dir" json:"report_dir"`
+
+	// StateFile keeps the last run, counters and next run of every rule
+	// across restarts; statuses start over on restart when empty
+	StateFile string `yaml:"state_file" json:"state_file"`
+
+	// PauseWebhook receives a JSON notification when a rule is paused for
+	// exceeding its error budget
+	PauseWebhook string `yaml:"pause_webhook" json:"pause_webhook"`
+}
+
+// WebhookConfig signs the notifications the server sends and verifies the
+// signed webhooks it receives
+type WebhookConfig struct {
+	// Secret is a shared secret that signs and verifies with HMAC-SHA256
+	Secret string `yaml:"secret" json:"secret"`
+
+	// SigningKeyFile is an unencrypted PEM private key (ECDSA, Ed25519 or
+	// RSA) that signs outgoing notifications, alongside Secret if both are set
+	SigningKeyFile string `yaml:"signing_key_file" json:"signing_key_file"`
+
+	// VerifyKeyFile is a PEM public key whose signatures are accepted on
+	// incoming webhooks, alongside Secret if both are set
+	VerifyKeyFile string `yaml:"verify_key_file" json:"verify_key_file"`
+
+	// RequireSignature rejects API requests that change state, such as job
+	// submissions, unless they are signed with Secret or the key of
+	// VerifyKeyFile
+	RequireSignature bool `yaml:"require_signature" json:"require_signature"`
+
+	// MaxAge is how old a signature may be before it is rejected as a
+	// replay; zero uses 5 minutes
+	MaxAge time.Duration `yaml:"max_age" json:"max_age"`
+
+	// Triggers replicate tags as soon as a registry reports their push
+	Triggers []WebhookTrigger `yaml:"triggers,omitempty" json:"triggers,omitempty"`
+}
+
+// WebhookTrigger replicates each tag a source registry reports pushed, as
+// received at /api/v1/webhooks/<name>, to a destination registry
+type WebhookTrigger struct {
+	// Name identifies the trigger in its URL
+	Name string `yaml:"name" json:"name"`
+
+	// Format is the payload the registry sends: ecr (EventBridge image
+	// actions), gcr (Pub/Sub push of gcr notifications) or registry (Docker
+	// Distribution notifications)
+	Format string `yaml:"format" json:"format"`
+
+	// SourceRegistry and DestRegistry name the registries as replicate
+	// requests do, e.g. ecr, gcr or a configured registry
+	SourceRegistry string `yaml:"source_registry" json:"source_registry"`
+	DestRegistry   string `yaml:"dest_registry" json:"dest_registry"`
+
+	// Repositories are path.Match globs of the repositories whose pushes
+	// are replicated; every repository when empty
+	Repositories []string `yaml:"repositories,omitempty" json:"repositories,omitempty"`
+
+	// DestPrefix is prepended to the repository name at the destination
+	DestPrefix string `yaml:"dest_prefix,omitempty" json:"dest_prefix,omitempty"`
+}
+
+// DebugConfig exposes pprof, expvar and state dumps for debugging a running
+// process
+type DebugConfig struct {
+	// Addr serves the unauthenticated diagnostics endpoints, e.g.
+	// "localhost:6060", and enables SIGQUIT bundles; empty disables both
+	Addr string `yaml:"addr" json:"addr"`
+
+	// BundleDir receives the bundles written on SIGQUIT; empty uses the
+	// system temporary directory
+	BundleDir string `yaml:"bundle_dir" json:"bundle_dir"`
 }
 
 // NewDefaultConfig creates a new configuration with default values
 func NewDefaultConfig() *Config {
 	return &Config{
 		LogLevel: "info",
+		LogSampling: LogSamplingConfig{
+			First:      100,
+			Thereafter: 100,
+		},
+		ClockSkewThreshold: 30 * time.Second,
 		ECR: ECRConfig{
-			Region:    "us-west-2",
-			AccountID: "",
+			Region:            "us-west-2",
+			AccountID:         "",
+			NativeReplication: NativeReplicationWarn,
+			PullThroughCache:  PullThroughCacheWarn,
 		},
 		GCR: GCRConfig{
 			Project:  "",
@@ -190,11 +610,13 @@ func NewDefaultConfig() *Config {
 			ReadTimeout:       30 * time.Second,
 			WriteTimeout:      60 * time.Second,
 			ShutdownTimeout:   15 * time.Second,
+			IdempotencyTTL:    24 * time.Hour,
 			HealthCheckPath:   "/health",
 			MetricsPath:       "/metrics",
 			ReplicatePath:     "/api/v1/replicate",
 			TreeReplicatePath: "/api/v1/replicate-tree",
 			StatusPath:        "/api/v1/status",
+			Dashboard:         true,
 		},
 		Metrics: MetricsConfig{
 			Enabled:   true,
@@ -208,6 +630,8 @@ func NewDefaultConfig() *Config {
 		},
 		TreeReplicate: TreeReplicateConfig{
 			Workers:          0,
+			TagWorkers:       0,
+			MaxTransfers:     0,
 			ExcludeRepos:     []string{},
 			ExcludeTags:      []string{},
 			IncludeTags:      []string{},
@@ -220,9 +644,29 @@ func NewDefaultConfig() *Config {
 			RetryFailed:      true,
 		},
 		Replicate: ReplicateConfig{
-			Force:  false,
-			DryRun: false,
-			Tags:   []string{},
+			Force:              false,
+			DryRun:             false,
+			Tags:               []string{},
+			CreateMissingRepos: CreateMissingReposTrue,
+			CheckpointDir:      "${HOME}/.freightliner/checkpoints",
+		},
+		Reports: ReportsConfig{
+			UploadURL:   "",
+			KeyTemplate: "{{.Date}}/{{.RunID}}/{{.Name}}.json",
+			Region:      "",
+		},
+		Retry: RetryConfig{
+			Budget:     1000,
+			MaxRetries: 5,
+		},
+		Network: NetworkConfig{
+			DNS: DNSConfig{
+				MinTTL:      5 * time.Second,
+				MaxTTL:      5 * time.Minute,
+				NegativeTTL: 5 * time.Second,
+				Retries:     2,
+				ServeStale:  time.Hour,
+			},
 		},
 	}
 }
@@ -230,9 +674,15 @@ func NewDefaultConfig() *Config {
 // AddFlagsToCommand adds configuration flags to a cobra command
 func (c *Config) AddFlagsToCommand(cmd *cobra.Command) {
 	// Add global flags
-	cmd.PersistentFlags().StringVar(&c.LogLevel, "log-level", c.LogLevel, "Log level (debug, info, warn, error, fatal)")
+	cmd.PersistentFlags().StringVar(&c.LogLevel, "log-level", c.LogLevel, "Log level (debug, info, warn, error, fatal), with optional per-package overrides such as info,copy=debug,tree=warn")
+	cmd.PersistentFlags().IntVar(&c.LogSampling.First, "log-sample-first", c.LogSampling.First, "Identical debug messages logged each second before sampling (0 disables sampling)")
+	cmd.PersistentFlags().IntVar(&c.LogSampling.Thereafter, "log-sample-thereafter", c.LogSampling.Thereafter, "Log every Nth identical debug message once sampling starts (0 drops them)")
+	cmd.PersistentFlags().DurationVar(&c.Timeout, "timeout", c.Timeout, "Maximum time for the whole command, e.g. 10m (0 uses the command's default)")
+	cmd.PersistentFlags().StringVar(&c.Profile, "profile", c.Profile, "Concurrency preset for workers, retries and rate limits (conservative, balanced, aggressive); explicit flags still win")
 	cmd.PersistentFlags().StringVar(&c.ECR.Region, "ecr-region", c.ECR.Region, "AWS region for ECR")
 	cmd.PersistentFlags().StringVar(&c.ECR.AccountID, "ecr-account", c.ECR.AccountID, "AWS account ID for ECR (empty uses default from credentials)")
+	cmd.PersistentFlags().StringVar(&c.ECR.NativeReplication, "ecr-native-replication", c.ECR.NativeReplication, "Action when native ECR replication already covers a copy (warn, skip, ignore)")
+	cmd.PersistentFlags().StringVar(&c.ECR.PullThroughCache, "ecr-pull-through-cache", c.ECR.PullThroughCache, "Action when the destination ECR repository is a pull through cache of the source (warn, skip, ignore)")
 	cmd.PersistentFlags().StringVar(&c.GCR.Project, "gcr-project", c.GCR.Project, "GCP project for GCR")
 	cmd.PersistentFlags().StringVar(&c.GCR.Location, "gcr-location", c.GCR.Location, "GCR location (us, eu, asia)")
 
@@ -258,6 +708,58 @@ func (c *Config) AddFlagsToCommand(cmd *cobra.Command) {
 	cmd.PersistentFlags().StringVar(&c.Secrets.GCPCredentialsFile, "gcp-credentials-file", c.Secrets.GCPCredentialsFile, "GCP credentials file path for Secret Manager")
 	cmd.PersistentFlags().StringVar(&c.Secrets.RegistryCredsSecret, "registry-creds-secret", c.Secrets.RegistryCredsSecret, "Secret name for registry credentials")
 	cmd.PersistentFlags().StringVar(&c.Secrets.EncryptionKeysSecret, "encryption-keys-secret", c.Secrets.EncryptionKeysSecret, "Secret name for encryption keys")
+
+	// Add run report upload flags
+	cmd.PersistentFlags().StringVar(&c.Reports.UploadURL, "report-upload-url", c.Reports.UploadURL, "Upload run reports to this bucket (s3://bucket/prefix or gs://bucket/prefix)")
+	cmd.PersistentFlags().StringVar(&c.Reports.KeyTemplate, "report-key-template", c.Reports.KeyTemplate, "Object key template for uploaded reports (.Date, .Time, .RunID, .JobID, .Command, .Name)")
+	cmd.PersistentFlags().StringVar(&c.Reports.Region, "report-region", c.Reports.Region, "AWS region of the S3 report bucket")
+	cmd.PersistentFlags().StringVar(&c.Reports.LayerReuseOutput, "layer-reuse-report", c.Reports.LayerReuseOutput, "Write a report of the layers each image uploaded, mounted or already had, and where mounts came from, to this file")
+
+	// Add attestation flags
+	cmd.PersistentFlags().StringVar(&c.Attestation.Output, "attestation-output", c.Attestation.Output, "Write an in-toto attestation of every pushed manifest and blob to this file")
+	cmd.PersistentFlags().StringVar(&c.Attestation.KeyFile, "attestation-key", c.Attestation.KeyFile, "PEM private key used to sign the attestation (DSSE)")
+
+	// Add re-signing flags
+	cmd.PersistentFlags().StringVar(&c.Resign.KeyFile, "resign-key", c.Resign.KeyFile, "PEM private key or awskms:// / gcpkms:// key used to re-sign images whose digest changes on copy")
+
+	// Add retry flags
+	cmd.PersistentFlags().IntVar(&c.Retry.Budget, "retry-budget", c.Retry.Budget, "Total registry request retries allowed per run (0 = unlimited)")
+	cmd.PersistentFlags().IntVar(&c.Retry.MaxRetries, "max-retries", c.Retry.MaxRetries, "Retries for a single throttled or failed registry request")
+
+	// Add per-host insecure registry flag
+	cmd.PersistentFlags().StringSliceVar(&c.Registries.InsecureRegistries, "registry-insecure", c.Registries.InsecureRegistries, "Skip TLS verification for this host[:port]; prefix with http:// to allow plain HTTP (repeatable)")
+
+	// Add DNS resolution override flag
+	cmd.PersistentFlags().BoolVar(&c.Network.DNS.Cache, "dns-cache", c.Network.DNS.Cache, "Cache registry DNS lookups for their record TTLs, retrying SERVFAIL and serving recent answers while DNS fails")
+	cmd.PersistentFlags().DurationVar(&c.Network.DNS.NegativeTTL, "dns-negative-ttl", c.Network.DNS.NegativeTTL, "How long the DNS cache remembers hosts that do not exist (0 disables negative caching)")
+	cmd.PersistentFlags().IntVar(&c.Network.DNS.Retries, "dns-retries", c.Network.DNS.Retries, "Retries of DNS lookups answered with SERVFAIL or timing out")
+	cmd.PersistentFlags().Float64Var(&c.Network.MaxRequestsPerSecond, "max-requests-per-second", c.Network.MaxRequestsPerSecond, "Maximum API calls per second to each registry (0 = unlimited)")
+	cmd.PersistentFlags().StringArrayVar(&c.Network.Resolve, "resolve", c.Network.Resolve, "Connect to host:port at the given address instead of resolving it, e.g. registry.internal:443:10.0.0.5 (repeatable)")
+
+	// Add diagnostics flags
+	cmd.PersistentFlags().StringVar(&c.Debug.Addr, "debug-addr", c.Debug.Addr, "Serve pprof, expvar and state dumps on this address, e.g. localhost:6060, and write a diagnostics bundle on SIGQUIT")
+	cmd.PersistentFlags().StringVar(&c.Debug.BundleDir, "debug-bundle-dir", c.Debug.BundleDir, "Directory for diagnostics bundles written on SIGQUIT (default: system temp directory)")
+
+	// Add temp storage flag
+	cmd.PersistentFlags().StringVar(&c.WorkDir, "work-dir", c.WorkDir, "Directory for partially streamed blobs; leftovers from interrupted runs are removed at startup (default: system temp directory)")
+
+	// Add filter tracing flag
+	cmd.PersistentFlags().BoolVar(&c.ExplainFilters, "explain-filters", c.ExplainFilters, "Log which include/exclude rule matched each repository and tag, and why")
+
+	// Add destination protection flag
+	cmd.PersistentFlags().BoolVar(&c.ReadOnly, "read-only", c.ReadOnly, "Fail any push, tag, repository creation or delete, so plans, diffs and inventories can run safely with write-capable credentials")
+
+	// Add run correlation flags
+	cmd.PersistentFlags().StringVar(&c.RunID, "run-id", c.RunID, "ID of this run in logs, metrics, checkpoints and reports, e.g. a CI job ID (default: a new time-ordered UUID)")
+	cmd.PersistentFlags().BoolVar(&c.AnnotateRunID, "annotate-run-id", c.AnnotateRunID, "Annotate every manifest pushed to a destination with the run ID (changes the pushed digests)")
+
+	// Add clock skew flags
+	cmd.PersistentFlags().DurationVar(&c.ClockSkewThreshold, "clock-skew-threshold", c.ClockSkewThreshold, "Warn when the host clock differs from registry Date headers by this much (0 disables detection)")
+	cmd.PersistentFlags().BoolVar(&c.CompensateClockSkew, "compensate-clock-skew", c.CompensateClockSkew, "Correct token expiry calculations by the clock skew detected from registry Date headers")
+
+	// Add HTTP tracing flag; given without hosts it logs every host
+	cmd.PersistentFlags().StringSliceVar(&c.LogHTTP, "log-http", c.LogHTTP, "Log every request to these registry hosts (host[:port], wildcards allowed) with status, latency and request IDs, credentials redacted; without a value, log all hosts")
+	cmd.PersistentFlags().Lookup("log-http").NoOptDefVal = "*"
 }
 
 // AddCheckpointFlagsToCommand adds checkpoint-specific flags to a command
@@ -268,7 +770,9 @@ func (c *Config) AddCheckpointFlagsToCommand(cmd *cobra.Command) {
 
 // AddTreeReplicateFlags adds tree replication-specific flags to a command
 func (c *Config) AddTreeReplicateFlags(cmd *cobra.Command) {
-	cmd.Flags().IntVar(&c.TreeReplicate.Workers, "workers", c.TreeReplicate.Workers, "Number of concurrent worker threads (0 = auto-detect)")
+	cmd.Flags().IntVar(&c.TreeReplicate.Workers, "workers", c.TreeReplicate.Workers, "Number of repositories replicated concurrently (0 = auto-detect)")
+	cmd.Flags().IntVar(&c.TreeReplicate.TagWorkers, "tag-workers", c.TreeReplicate.TagWorkers, "Number of tags replicated concurrently per repository (0 = auto-detect)")
+	cmd.Flags().IntVar(&c.TreeReplicate.MaxTransfers, "max-transfers", c.TreeReplicate.MaxTransfers, "Maximum image copies in flight across all repositories (0 = unlimited)")
 	cmd.Flags().StringSliceVar(&c.TreeReplicate.ExcludeRepos, "exclude-repo", c.TreeReplicate.ExcludeRepos, "Repository patterns to exclude (e.g. 'helper-*')")
 	cmd.Flags().StringSliceVar(&c.TreeReplicate.ExcludeTags, "exclude-tag", c.TreeReplicate.ExcludeTags, "Tag patterns to exclude (e.g. 'dev-*')")
 	cmd.Flags().StringSliceVar(&c.TreeReplicate.IncludeTags, "include-tag", c.TreeReplicate.IncludeTags, "Tag patterns to include (e.g. 'v*')")
@@ -276,9 +780,20 @@ func (c *Config) AddTreeReplicateFlags(cmd *cobra.Command) {
 	cmd.Flags().BoolVar(&c.TreeReplicate.Force, "force", c.TreeReplicate.Force, "Force overwrite of existing images")
 	cmd.Flags().BoolVar(&c.TreeReplicate.EnableCheckpoint, "checkpoint", c.TreeReplicate.EnableCheckpoint, "Enable checkpointing for interrupted replications")
 	cmd.Flags().StringVar(&c.TreeReplicate.CheckpointDir, "checkpoint-dir", c.TreeReplicate.CheckpointDir, "Directory for storing checkpoint files")
-	cmd.Flags().StringVar(&c.TreeReplicate.ResumeID, "resume", c.TreeReplicate.ResumeID, "Resume replication from a checkpoint ID")
+	cmd.Flags().DurationVar(&c.TreeReplicate.CheckpointWriteTimeout, "checkpoint-write-timeout", c.TreeReplicate.CheckpointWriteTimeout, "Timeout for each checkpoint write (0 = 30s)")
+	cmd.Flags().StringVar(&c.TreeReplicate.CheckpointSlowStore, "checkpoint-slow-store", c.TreeReplicate.CheckpointSlowStore, "After a checkpoint write times out: wait (keep checkpointing) or disable (stop checkpointing)")
+	cmd.Flags().StringVar(&c.TreeReplicate.ResumeID, "resume", c.TreeReplicate.ResumeID, "Resume replication from a checkpoint ID, or \"latest\" for the newest unfinished checkpoint of the same trees")
 	cmd.Flags().BoolVar(&c.TreeReplicate.SkipCompleted, "skip-completed", c.TreeReplicate.SkipCompleted, "Skip completed repositories when resuming")
 	cmd.Flags().BoolVar(&c.TreeReplicate.RetryFailed, "retry-failed", c.TreeReplicate.RetryFailed, "Retry failed repositories when resuming")
+	c.AddNameMappingFlags(cmd)
+}
+
+// AddNameMappingFlags adds destination repository name mapping flags to a command
+func (c *Config) AddNameMappingFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&c.TreeReplicate.NameMapping.Strategy, "name-mapping", c.TreeReplicate.NameMapping.Strategy, "Destination repository naming: preserve, flatten, hash or truncate")
+	cmd.Flags().IntVar(&c.TreeReplicate.NameMapping.MaxDepth, "name-max-depth", c.TreeReplicate.NameMapping.MaxDepth, "Maximum path components of a destination repository name (0 = no limit)")
+	cmd.Flags().IntVar(&c.TreeReplicate.NameMapping.MaxLength, "name-max-length", c.TreeReplicate.NameMapping.MaxLength, "Maximum length of a destination repository name (0 = no limit)")
+	cmd.Flags().StringVar(&c.TreeReplicate.NameMapping.Separator, "name-separator", c.TreeReplicate.NameMapping.Separator, "Separator joining flattened path components: -, --, _, __ or . (default -)")
 }
 
 // AddServerFlagsToCommand adds server-specific flags to a command
@@ -295,7 +810,19 @@ func (c *Config) AddServerFlags(cmd *cobra.Command) {
 	cmd.Flags().StringSliceVar(&c.Server.AllowedOrigins, "allowed-origins", c.Server.AllowedOrigins, "Allowed CORS origins")
 	cmd.Flags().DurationVar(&c.Server.ReadTimeout, "read-timeout", c.Server.ReadTimeout, "HTTP server read timeout")
 	cmd.Flags().DurationVar(&c.Server.WriteTimeout, "write-timeout", c.Server.WriteTimeout, "HTTP server write timeout")
+	cmd.Flags().DurationVar(&c.Server.IdempotencyTTL, "idempotency-ttl", c.Server.IdempotencyTTL, "How long idempotency keys of copy requests are remembered")
 	cmd.Flags().DurationVar(&c.Server.ShutdownTimeout, "shutdown-timeout", c.Server.ShutdownTimeout, "HTTP server shutdown timeout")
+	cmd.Flags().BoolVar(&c.Server.Dashboard, "dashboard", c.Server.Dashboard, "Serve the web dashboard at /ui/")
+	cmd.Flags().StringSliceVar(&c.Server.ClusterNodes, "cluster-nodes", c.Server.ClusterNodes, "Admin addresses of distributed nodes to show on the dashboard")
+	cmd.Flags().StringVar(&c.Prune.SyncConfig, "prune-config", c.Prune.SyncConfig, "Sync configuration file, appconfig://APP/ENV/PROFILE or runtimeconfig://PROJECT/CONFIG/VARIABLE whose prune rules run on their schedules")
+	cmd.Flags().DurationVar(&c.Prune.PollInterval, "prune-config-poll-interval", c.Prune.PollInterval, "How often to reload the prune config when it changes (0 loads it once)")
+	cmd.Flags().StringVar(&c.Prune.ReportDir, "prune-report-dir", c.Prune.ReportDir, "Directory for JSON reports of scheduled prune runs")
+	cmd.Flags().StringVar(&c.Prune.StateFile, "prune-state-file", c.Prune.StateFile, "File keeping the last run status of every scheduled rule across restarts")
+	cmd.Flags().StringVar(&c.Prune.PauseWebhook, "prune-pause-webhook", c.Prune.PauseWebhook, "URL notified with a JSON POST when a scheduled rule is paused for exceeding its error budget")
+	cmd.Flags().StringVar(&c.Webhooks.Secret, "webhook-secret", c.Webhooks.Secret, "Shared secret that signs outgoing webhooks and verifies incoming ones with HMAC-SHA256")
+	cmd.Flags().StringVar(&c.Webhooks.SigningKeyFile, "webhook-signing-key", c.Webhooks.SigningKeyFile, "PEM private key (ECDSA, Ed25519 or RSA) that signs outgoing webhooks")
+	cmd.Flags().StringVar(&c.Webhooks.VerifyKeyFile, "webhook-verify-key", c.Webhooks.VerifyKeyFile, "PEM public key whose signatures are accepted on incoming webhooks")
+	cmd.Flags().BoolVar(&c.Webhooks.RequireSignature, "require-webhook-signature", c.Webhooks.RequireSignature, "Reject API requests other than GET that are not signed with the webhook secret or verify key")
 }
 
 // AddReplicateFlags adds single repository replication-specific flags to a command
@@ -303,31 +830,24 @@ func (c *Config) AddReplicateFlags(cmd *cobra.Command) {
 	cmd.Flags().BoolVar(&c.Replicate.Force, "force", c.Replicate.Force, "Force overwrite of existing images")
 	cmd.Flags().BoolVar(&c.Replicate.DryRun, "dry-run", c.Replicate.DryRun, "Perform a dry run without actually copying images")
 	cmd.Flags().StringSliceVar(&c.Replicate.Tags, "tags", c.Replicate.Tags, "Specific tags to replicate (if empty, all tags will be replicated)")
+	cmd.Flags().StringVar(&c.Replicate.CreateMissingRepos, "create-missing-repos", c.Replicate.CreateMissingRepos, "Create missing destination repositories (true, false, prompt)")
+	cmd.Flags().BoolVar(&c.Replicate.ScanFindings, "copy-scan-findings", c.Replicate.ScanFindings, "Attach source scan findings (ECR) to copied images as OCI referrers")
+	cmd.Flags().BoolVar(&c.Replicate.CopyRepoMetadata, "copy-repo-metadata", c.Replicate.CopyRepoMetadata, "Copy repository descriptions and readmes where both registries support them")
+	cmd.Flags().BoolVar(&c.Replicate.Referrers, "copy-referrers", c.Replicate.Referrers, "Copy signatures, attestations and other referrers of copied images")
+	cmd.Flags().StringVar(&c.Replicate.ReferrersScheme, "referrers-scheme", c.Replicate.ReferrersScheme, "Store copied referrers as auto, oci (OCI 1.1 referrers) or tags (cosign tag scheme)")
+	cmd.Flags().BoolVar(&c.Replicate.Checkpoint, "checkpoint", c.Replicate.Checkpoint, "Record copied tags so an interrupted replication can be resumed")
+	cmd.Flags().StringVar(&c.Replicate.CheckpointDir, "checkpoint-dir", c.Replicate.CheckpointDir, "Directory for storing checkpoint files")
+	cmd.Flags().StringVar(&c.Replicate.ResumeID, "resume", c.Replicate.ResumeID, "Resume replication from a checkpoint ID, or \"latest\" for the newest unfinished checkpoint of the same repositories")
 }
 
-// ExpandHomeDir expands the ~ or $HOME at the beginning of a directory path
+// ExpandHomeDir expands the ~, $HOME or ${HOME} at the beginning of a
+// directory path, leaving the path unchanged if the home directory is unknown
 func ExpandHomeDir(path string) string {
-	if path == "" {
+	expanded, err := util.ExpandHome(path)
+	if err != nil {
 		return path
 	}
-
-	// Replace ${HOME} with actual home directory
-	if strings.Contains(path, "${HOME}") {
-		homeDir, err := os.UserHomeDir()
-		if err == nil {
-			path = strings.ReplaceAll(path, "${HOME}", homeDir)
-		}
-	}
-
-	// Replace ~ with actual home directory
-	if strings.HasPrefix(path, "~") {
-		homeDir, err := os.UserHomeDir()
-		if err == nil {
-			path = filepath.Join(homeDir, path[1:])
-		}
-	}
-
-	return path
+	return expanded
 }
 
 // GetOptimalWorkerCount determines the optimal number of worker threads
@@ -149,6 +149,7 @@ func TestAddFlagsToCommand(t *testing.T) {
 	// Check if flags were added
 	flags := []string{
 		"log-level",
+		"timeout",
 		"ecr-region",
 		"ecr-account",
 		"gcr-project",
@@ -258,6 +259,7 @@ func TestAddReplicateFlags(t *testing.T) {
 		"force",
 		"dry-run",
 		"tags",
+		"create-missing-repos",
 	}
 
 	for _, flagName := range flags {
@@ -287,6 +289,115 @@ func TestValidate(t *testing.T) {
 			},
 			wantError: true,
 		},
+		{
+			name: "per-package log levels",
+			modifyFn: func(c *Config) {
+				c.LogLevel = "info,copy=debug,tree=warn"
+			},
+			wantError: false,
+		},
+		{
+			name: "invalid per-package log level",
+			modifyFn: func(c *Config) {
+				c.LogLevel = "info,copy=loud"
+			},
+			wantError: true,
+		},
+		{
+			name: "debug address",
+			modifyFn: func(c *Config) {
+				c.Debug.Addr = "localhost:6060"
+				c.Debug.BundleDir = "/var/tmp"
+			},
+			wantError: false,
+		},
+		{
+			name: "debug address without port",
+			modifyFn: func(c *Config) {
+				c.Debug.Addr = "localhost"
+			},
+			wantError: true,
+		},
+		{
+			name: "debug bundle directory without address",
+			modifyFn: func(c *Config) {
+				c.Debug.BundleDir = "/var/tmp"
+			},
+			wantError: true,
+		},
+		{
+			name: "required webhook signature with secret",
+			modifyFn: func(c *Config) {
+				c.Webhooks.RequireSignature = true
+				c.Webhooks.Secret = "s3cret"
+			},
+			wantError: false,
+		},
+		{
+			name: "required webhook signature without secret or key",
+			modifyFn: func(c *Config) {
+				c.Webhooks.RequireSignature = true
+				c.Webhooks.SigningKeyFile = "/etc/freightliner/webhook.key"
+			},
+			wantError: true,
+		},
+		{
+			name: "negative log sampling",
+			modifyFn: func(c *Config) {
+				c.LogSampling.First = -1
+			},
+			wantError: true,
+		},
+		{
+			name: "negative timeout",
+			modifyFn: func(c *Config) {
+				c.Timeout = -time.Second
+			},
+			wantError: true,
+		},
+		{
+			name: "invalid create-missing-repos policy",
+			modifyFn: func(c *Config) {
+				c.Replicate.CreateMissingRepos = "sometimes"
+			},
+			wantError: true,
+		},
+		{
+			name: "prompt create-missing-repos policy",
+			modifyFn: func(c *Config) {
+				c.Replicate.CreateMissingRepos = CreateMissingReposPrompt
+			},
+			wantError: false,
+		},
+		{
+			name: "invalid referrers scheme",
+			modifyFn: func(c *Config) {
+				c.Replicate.ReferrersScheme = "notation"
+			},
+			wantError: true,
+		},
+		{
+			name: "cosign tag referrers scheme",
+			modifyFn: func(c *Config) {
+				c.Replicate.ReferrersScheme = "tags"
+			},
+			wantError: false,
+		},
+		{
+			name: "invalid repository template encryption",
+			modifyFn: func(c *Config) {
+				c.Replicate.RepositoryTemplate.EncryptionType = "DES"
+			},
+			wantError: true,
+		},
+		{
+			name: "repository template KMS key without KMS encryption",
+			modifyFn: func(c *Config) {
+				c.Replicate.RepositoryTemplate.EncryptionType = "AES256"
+				c.Replicate.RepositoryTemplate.KMSKey = "alias/registry"
+			},
+			wantError: true,
+		},
 		{
 			name: "negative replicate workers",
 			modifyFn: func(c *Config) {
@@ -301,6 +412,55 @@ func TestValidate(t *testing.T) {
 			},
 			wantError: true,
 		},
+		{
+			name: "negative retry budget",
+			modifyFn: func(c *Config) {
+				c.Retry.Budget = -1
+			},
+			wantError: true,
+		},
+		{
+			name: "unlimited retry budget",
+			modifyFn: func(c *Config) {
+				c.Retry.Budget = 0
+			},
+			wantError: false,
+		},
+		{
+			name: "insecure lab registry",
+			modifyFn: func(c *Config) {
+				c.Registries.InsecureRegistries = []string{"http://registry.lab.local:5000"}
+			},
+			wantError: false,
+		},
+		{
+			name: "insecure public registry",
+			modifyFn: func(c *Config) {
+				c.Registries.InsecureRegistries = []string{"registry.lab.local:5000", "ghcr.io"}
+			},
+			wantError: true,
+		},
+		{
+			name: "invalid resolve override",
+			modifyFn: func(c *Config) {
+				c.Network.Resolve = []string{"registry.internal:443"}
+			},
+			wantError: true,
+		},
+		{
+			name: "negative tree tag workers",
+			modifyFn: func(c *Config) {
+				c.TreeReplicate.TagWorkers = -1
+			},
+			wantError: true,
+		},
+		{
+			name: "negative tree max transfers",
+			modifyFn: func(c *Config) {
+				c.TreeReplicate.MaxTransfers = -1
+			},
+			wantError: true,
+		},
 		{
 			name: "invalid server port - negative",
 			modifyFn: func(c *Config) {
@@ -407,6 +567,35 @@ func TestValidate(t *testing.T) {
 			},
 			wantError: false,
 		},
+		{
+			name: "valid report upload URL",
+			modifyFn: func(c *Config) {
+				c.Reports.UploadURL = "s3://audit-bucket/freightliner"
+			},
+			wantError: false,
+		},
+		{
+			name: "invalid report upload URL",
+			modifyFn: func(c *Config) {
+				c.Reports.UploadURL = "https://audit-bucket/freightliner"
+			},
+			wantError: true,
+		},
+		{
+			name: "signed attestation",
+			modifyFn: func(c *Config) {
+				c.Attestation.Output = "attestation.json"
+				c.Attestation.KeyFile = "attestation.key"
+			},
+			wantError: false,
+		},
+		{
+			name: "attestation key without output",
+			modifyFn: func(c *Config) {
+				c.Attestation.KeyFile = "attestation.key"
+			},
+			wantError: true,
+		},
 	}
 
 	for _, tt := range tests {
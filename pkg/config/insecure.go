@@ -0,0 +1,143 @@
+package config
+
+import (
+	"net"
+	"strconv"
+	"strings"
+
+	"freightliner/pkg/helper/errors"
+)
+
+// InsecureRule relaxes transport security for exactly one registry host.
+// Rules are written as "host[:port]" to skip TLS verification, or as
+// "http://host[:port]" to also allow plain HTTP.
+type InsecureRule struct {
+	// Host is the lower-cased registry host, including the port if one was given
+	Host string
+	// PlainHTTP allows falling back to unencrypted HTTP
+	PlainHTTP bool
+}
+
+// productionRegistryDomains are public registries that must never be matched
+// by an insecure rule. A rule naming one of them, or a subdomain of one, is
+// rejected.
+var productionRegistryDomains = []string{
+	"docker.io",
+	"docker.com",
+	"ghcr.io",
+	"github.com",
+	"quay.io",
+	"gcr.io",
+	"pkg.dev",
+	"amazonaws.com",
+	"amazonaws.com.cn",
+	"public.ecr.aws",
+	"azurecr.io",
+	"mcr.microsoft.com",
+	"gitlab.com",
+	"nvcr.io",
+	"registry.k8s.io",
+	"icr.io",
+	"ocir.io",
+}
+
+// ParseInsecureRule parses and validates a single --registry-insecure value.
+// Rules must name a single host; wildcards, paths and public registries are
+// rejected so a rule can never match a production registry by accident.
+func ParseInsecureRule(value string) (InsecureRule, error) {
+	rule := InsecureRule{}
+	host := strings.ToLower(strings.TrimSpace(value))
+
+	switch {
+	case strings.HasPrefix(host, "http://"):
+		rule.PlainHTTP = true
+		host = strings.TrimPrefix(host, "http://")
+	case strings.HasPrefix(host, "https://"):
+		host = strings.TrimPrefix(host, "https://")
+	case strings.Contains(host, "://"):
+		return rule, errors.InvalidInputf("insecure registry %q: only http:// and https:// are supported", value)
+	}
+	host = strings.TrimSuffix(host, "/")
+
+	if host == "" {
+		return rule, errors.InvalidInputf("insecure registry must not be empty")
+	}
+	if strings.ContainsAny(host, "*?") {
+		return rule, errors.InvalidInputf("insecure registry %q: wildcards are not allowed, list each host", value)
+	}
+	if strings.Contains(host, "/") {
+		return rule, errors.InvalidInputf("insecure registry %q: must be a host[:port] without a path", value)
+	}
+
+	hostname := host
+	if h, port, err := net.SplitHostPort(host); err == nil {
+		if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+			return rule, errors.InvalidInputf("insecure registry %q: invalid port %q", value, port)
+		}
+		hostname = h
+	} else if strings.Contains(host, ":") && net.ParseIP(host) == nil {
+		return rule, errors.InvalidInputf("insecure registry %q: invalid host", value)
+	}
+	hostname = strings.TrimSuffix(hostname, ".")
+	if hostname == "" {
+		return rule, errors.InvalidInputf("insecure registry %q: missing host", value)
+	}
+
+	if isProductionRegistry(hostname) {
+		return rule, errors.InvalidInputf("insecure registry %q: public registries cannot be marked insecure", value)
+	}
+
+	rule.Host = host
+	return rule, nil
+}
+
+// ParseInsecureRules parses and validates a list of --registry-insecure values
+func ParseInsecureRules(values []string) ([]InsecureRule, error) {
+	rules := make([]InsecureRule, 0, len(values))
+	for _, value := range values {
+		rule, err := ParseInsecureRule(value)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// InsecureRuleFor returns the rule matching registry, which may be given as a
+// host, host:port or URL. Matching is exact on host and port, so a rule for
+// "registry.lab.local:5000" does not cover "registry.lab.local". Invalid rules
+// never match.
+func (rc *RegistriesConfig) InsecureRuleFor(registry string) (InsecureRule, bool) {
+	host := strings.ToLower(strings.TrimSpace(registry))
+	host = strings.TrimPrefix(host, "https://")
+	host = strings.TrimPrefix(host, "http://")
+	if i := strings.Index(host, "/"); i >= 0 {
+		host = host[:i]
+	}
+	if host == "" {
+		return InsecureRule{}, false
+	}
+
+	for _, value := range rc.InsecureRegistries {
+		rule, err := ParseInsecureRule(value)
+		if err != nil {
+			continue
+		}
+		if rule.Host == host {
+			return rule, true
+		}
+	}
+	return InsecureRule{}, false
+}
+
+// isProductionRegistry reports whether hostname is, or is a subdomain of, a
+// known public registry
+func isProductionRegistry(hostname string) bool {
+	for _, domain := range productionRegistryDomains {
+		if hostname == domain || strings.HasSuffix(hostname, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,86 @@
+package config
+
+import (
+	"testing"
+)
+
+func TestParseInsecureRule(t *testing.T) {
+	tests := []struct {
+		name      string
+		value     string
+		want      InsecureRule
+		wantErr   bool
+		errSubstr string
+	}{
+		{name: "host with port", value: "registry.lab.local:5000", want: InsecureRule{Host: "registry.lab.local:5000"}},
+		{name: "plain HTTP", value: "http://registry.lab.local:5000", want: InsecureRule{Host: "registry.lab.local:5000", PlainHTTP: true}},
+		{name: "https prefix", value: "https://Harbor.Lab.Local/", want: InsecureRule{Host: "harbor.lab.local"}},
+		{name: "IP address", value: "10.0.0.5:5000", want: InsecureRule{Host: "10.0.0.5:5000"}},
+		{name: "empty", value: " ", wantErr: true, errSubstr: "empty"},
+		{name: "wildcard", value: "*.lab.local", wantErr: true, errSubstr: "wildcards"},
+		{name: "path", value: "registry.lab.local/team", wantErr: true, errSubstr: "path"},
+		{name: "bad scheme", value: "ftp://registry.lab.local", wantErr: true, errSubstr: "http://"},
+		{name: "bad port", value: "registry.lab.local:99999", wantErr: true, errSubstr: "port"},
+		{name: "docker hub", value: "docker.io", wantErr: true, errSubstr: "public"},
+		{name: "registry subdomain", value: "registry-1.docker.io", wantErr: true, errSubstr: "public"},
+		{name: "ECR", value: "123456789012.dkr.ecr.us-east-1.amazonaws.com", wantErr: true, errSubstr: "public"},
+		{name: "artifact registry over HTTP", value: "http://us-docker.pkg.dev", wantErr: true, errSubstr: "public"},
+		{name: "public registry with port", value: "ghcr.io:443", wantErr: true, errSubstr: "public"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseInsecureRule(tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseInsecureRule(%q) expected error", tt.value)
+				}
+				if tt.errSubstr != "" && !contains(err.Error(), tt.errSubstr) {
+					t.Errorf("error = %v, want substring %q", err, tt.errSubstr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseInsecureRule(%q) error = %v", tt.value, err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseInsecureRule(%q) = %+v, want %+v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegistriesConfig_InsecureRuleFor(t *testing.T) {
+	rc := RegistriesConfig{
+		InsecureRegistries: []string{"http://registry.lab.local:5000", "harbor.lab.local", "docker.io"},
+	}
+
+	tests := []struct {
+		registry  string
+		wantMatch bool
+		wantHTTP  bool
+	}{
+		{registry: "registry.lab.local:5000", wantMatch: true, wantHTTP: true},
+		{registry: "http://registry.lab.local:5000/", wantMatch: true, wantHTTP: true},
+		{registry: "REGISTRY.lab.local:5000", wantMatch: true, wantHTTP: true},
+		{registry: "registry.lab.local", wantMatch: false},
+		{registry: "registry.lab.local:5001", wantMatch: false},
+		{registry: "harbor.lab.local", wantMatch: true},
+		{registry: "harbor.lab.local:443", wantMatch: false},
+		{registry: "evil-harbor.lab.local", wantMatch: false},
+		{registry: "docker.io", wantMatch: false},
+		{registry: "", wantMatch: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.registry, func(t *testing.T) {
+			rule, ok := rc.InsecureRuleFor(tt.registry)
+			if ok != tt.wantMatch {
+				t.Fatalf("InsecureRuleFor(%q) matched = %v, want %v", tt.registry, ok, tt.wantMatch)
+			}
+			if ok && rule.PlainHTTP != tt.wantHTTP {
+				t.Errorf("InsecureRuleFor(%q) PlainHTTP = %v, want %v", tt.registry, rule.PlainHTTP, tt.wantHTTP)
+			}
+		})
+	}
+}
@@ -2,14 +2,17 @@ package config
 
 import (
 	"io"
+	"net"
 	"net/http"
 	"os"
+	"path"
 	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
 
 	"freightliner/pkg/helper/errors"
+	"freightliner/pkg/helper/log"
 
 	"gopkg.in/yaml.v3"
 )
@@ -51,6 +54,20 @@ func LoadFromFile(configPath string) (*Config, error) {
 		if err := yaml.Unmarshal(data, config); err != nil {
 			return nil, errors.Wrap(err, "failed to parse configuration")
 		}
+
+		// A profile fills in the settings the file leaves out, so parse the
+		// file again over the profile's values
+		if config.Profile != "" {
+			profile, err := LookupProfile(config.Profile)
+			if err != nil {
+				return nil, err
+			}
+			config = NewDefaultConfig()
+			profile.Apply(config, nil)
+			if err := yaml.Unmarshal(data, config); err != nil {
+				return nil, errors.Wrap(err, "failed to parse configuration")
+			}
+		}
 	}
 
 	// Load from environment variables - these override file settings
@@ -120,10 +137,17 @@ func processStringEnvVars(config *Config) {
 	envVars := map[string]*string{
 		// General configuration
 		"FREIGHTLINER_LOG_LEVEL": &config.LogLevel,
+		"FREIGHTLINER_RUN_ID":    &config.RunID,
 
 		// ECR configuration
-		"FREIGHTLINER_ECR_REGION":     &config.ECR.Region,
-		"FREIGHTLINER_ECR_ACCOUNT_ID": &config.ECR.AccountID,
+		"FREIGHTLINER_ECR_REGION":             &config.ECR.Region,
+		"FREIGHTLINER_ECR_ACCOUNT_ID":         &config.ECR.AccountID,
+		"FREIGHTLINER_ECR_NATIVE_REPLICATION": &config.ECR.NativeReplication,
+		"FREIGHTLINER_ECR_PULL_THROUGH_CACHE": &config.ECR.PullThroughCache,
+
+		// Replication configuration
+		"FREIGHTLINER_CREATE_MISSING_REPOS": &config.Replicate.CreateMissingRepos,
+		"FREIGHTLINER_REFERRERS_SCHEME":     &config.Replicate.ReferrersScheme,
 
 		// GCR configuration
 		"FREIGHTLINER_GCR_PROJECT":  &config.GCR.Project,
@@ -158,8 +182,44 @@ func processStringEnvVars(config *Config) {
 		"FREIGHTLINER_CHECKPOINT_ID":        &config.Checkpoint.ID,
 
 		// Tree replication configuration
-		"FREIGHTLINER_TREE_CHECKPOINT_DIR": &config.TreeReplicate.CheckpointDir,
-		"FREIGHTLINER_TREE_RESUME_ID":      &config.TreeReplicate.ResumeID,
+		"FREIGHTLINER_TREE_CHECKPOINT_DIR":        &config.TreeReplicate.CheckpointDir,
+		"FREIGHTLINER_TREE_CHECKPOINT_SLOW_STORE": &config.TreeReplicate.CheckpointSlowStore,
+		"FREIGHTLINER_TREE_RESUME_ID":             &config.TreeReplicate.ResumeID,
+		"FREIGHTLINER_REPLICATE_CHECKPOINT_DIR":   &config.Replicate.CheckpointDir,
+		"FREIGHTLINER_REPLICATE_RESUME_ID":        &config.Replicate.ResumeID,
+		"FREIGHTLINER_TREE_NAME_MAPPING":          &config.TreeReplicate.NameMapping.Strategy,
+		"FREIGHTLINER_TREE_NAME_SEPARATOR":        &config.TreeReplicate.NameMapping.Separator,
+
+		// Report upload configuration
+		"FREIGHTLINER_REPORT_UPLOAD_URL":   &config.Reports.UploadURL,
+		"FREIGHTLINER_REPORT_KEY_TEMPLATE": &config.Reports.KeyTemplate,
+		"FREIGHTLINER_REPORT_REGION":       &config.Reports.Region,
+		"FREIGHTLINER_LAYER_REUSE_REPORT":  &config.Reports.LayerReuseOutput,
+
+		// Attestation configuration
+		"FREIGHTLINER_ATTESTATION_OUTPUT": &config.Attestation.Output,
+		"FREIGHTLINER_ATTESTATION_KEY":    &config.Attestation.KeyFile,
+
+		// Re-signing configuration
+		"FREIGHTLINER_RESIGN_KEY": &config.Resign.KeyFile,
+
+		// Scheduled prune configuration
+		"FREIGHTLINER_PRUNE_CONFIG":        &config.Prune.SyncConfig,
+		"FREIGHTLINER_PRUNE_REPORT_DIR":    &config.Prune.ReportDir,
+		"FREIGHTLINER_PRUNE_STATE_FILE":    &config.Prune.StateFile,
+		"FREIGHTLINER_PRUNE_PAUSE_WEBHOOK": &config.Prune.PauseWebhook,
+
+		// Webhook signing configuration
+		"FREIGHTLINER_WEBHOOK_SECRET":      &config.Webhooks.Secret,
+		"FREIGHTLINER_WEBHOOK_SIGNING_KEY": &config.Webhooks.SigningKeyFile,
+		"FREIGHTLINER_WEBHOOK_VERIFY_KEY":  &config.Webhooks.VerifyKeyFile,
+
+		// Diagnostics configuration
+		"FREIGHTLINER_DEBUG_ADDR":       &config.Debug.Addr,
+		"FREIGHTLINER_DEBUG_BUNDLE_DIR": &config.Debug.BundleDir,
+
+		// Temp storage configuration
+		"FREIGHTLINER_WORK_DIR": &config.WorkDir,
 	}
 
 	// Load environment variables
@@ -189,6 +249,9 @@ func processBooleanEnvVars(config *Config) {
 		"FREIGHTLINER_TLS_ENABLED":  &config.Server.TLSEnabled,
 		"FREIGHTLINER_API_KEY_AUTH": &config.Server.APIKeyAuth,
 
+		// Webhook signing configuration
+		"FREIGHTLINER_REQUIRE_WEBHOOK_SIGNATURE": &config.Webhooks.RequireSignature,
+
 		// Tree replication configuration
 		"FREIGHTLINER_TREE_DRY_RUN":           &config.TreeReplicate.DryRun,
 		"FREIGHTLINER_TREE_FORCE":             &config.TreeReplicate.Force,
@@ -197,8 +260,27 @@ func processBooleanEnvVars(config *Config) {
 		"FREIGHTLINER_TREE_RETRY_FAILED":      &config.TreeReplicate.RetryFailed,
 
 		// Replication configuration
-		"FREIGHTLINER_REPLICATE_FORCE":   &config.Replicate.Force,
-		"FREIGHTLINER_REPLICATE_DRY_RUN": &config.Replicate.DryRun,
+		"FREIGHTLINER_REPLICATE_FORCE":      &config.Replicate.Force,
+		"FREIGHTLINER_REPLICATE_DRY_RUN":    &config.Replicate.DryRun,
+		"FREIGHTLINER_REPLICATE_CHECKPOINT": &config.Replicate.Checkpoint,
+		"FREIGHTLINER_COPY_SCAN_FINDINGS":   &config.Replicate.ScanFindings,
+		"FREIGHTLINER_COPY_REFERRERS":       &config.Replicate.Referrers,
+		"FREIGHTLINER_COPY_REPO_METADATA":   &config.Replicate.CopyRepoMetadata,
+
+		// Filter tracing
+		"FREIGHTLINER_EXPLAIN_FILTERS": &config.ExplainFilters,
+
+		// Destination protection
+		"FREIGHTLINER_READ_ONLY": &config.ReadOnly,
+
+		// Run correlation
+		"FREIGHTLINER_ANNOTATE_RUN_ID": &config.AnnotateRunID,
+
+		// Clock skew compensation
+		"FREIGHTLINER_COMPENSATE_CLOCK_SKEW": &config.CompensateClockSkew,
+
+		// Network configuration
+		"FREIGHTLINER_DNS_CACHE": &config.Network.DNS.Cache,
 	}
 
 	// Load environment variables
@@ -220,8 +302,20 @@ func processIntEnvVars(config *Config) {
 		// Server configuration
 		"FREIGHTLINER_SERVER_PORT": &config.Server.Port,
 
+		// Logging configuration
+		"FREIGHTLINER_LOG_SAMPLE_FIRST":      &config.LogSampling.First,
+		"FREIGHTLINER_LOG_SAMPLE_THEREAFTER": &config.LogSampling.Thereafter,
+
+		// Retry configuration
+		"FREIGHTLINER_RETRY_BUDGET": &config.Retry.Budget,
+		"FREIGHTLINER_MAX_RETRIES":  &config.Retry.MaxRetries,
+
 		// Tree replication configuration
-		"FREIGHTLINER_TREE_WORKERS": &config.TreeReplicate.Workers,
+		"FREIGHTLINER_TREE_WORKERS":         &config.TreeReplicate.Workers,
+		"FREIGHTLINER_TREE_TAG_WORKERS":     &config.TreeReplicate.TagWorkers,
+		"FREIGHTLINER_TREE_MAX_TRANSFERS":   &config.TreeReplicate.MaxTransfers,
+		"FREIGHTLINER_TREE_NAME_MAX_DEPTH":  &config.TreeReplicate.NameMapping.MaxDepth,
+		"FREIGHTLINER_TREE_NAME_MAX_LENGTH": &config.TreeReplicate.NameMapping.MaxLength,
 	}
 
 	// Load environment variables
@@ -243,6 +337,8 @@ func processSliceEnvVars(config *Config) {
 		"FREIGHTLINER_TREE_EXCLUDE_TAGS":      &config.TreeReplicate.ExcludeTags,
 		"FREIGHTLINER_TREE_INCLUDE_TAGS":      &config.TreeReplicate.IncludeTags,
 		"FREIGHTLINER_REPLICATE_TAGS":         &config.Replicate.Tags,
+		"FREIGHTLINER_REGISTRY_INSECURE":      &config.Registries.InsecureRegistries,
+		"FREIGHTLINER_LOG_HTTP":               &config.LogHTTP,
 	}
 
 	for env, field := range stringSliceEnvs {
@@ -269,9 +365,16 @@ func processSliceEnvVars(config *Config) {
 func processDurationEnvVars(config *Config) {
 	// Map of environment variables to configuration fields
 	envVars := map[string]*time.Duration{
-		"FREIGHTLINER_SERVER_READ_TIMEOUT":     &config.Server.ReadTimeout,
-		"FREIGHTLINER_SERVER_WRITE_TIMEOUT":    &config.Server.WriteTimeout,
-		"FREIGHTLINER_SERVER_SHUTDOWN_TIMEOUT": &config.Server.ShutdownTimeout,
+		"FREIGHTLINER_TIMEOUT":                       &config.Timeout,
+		"FREIGHTLINER_SERVER_READ_TIMEOUT":           &config.Server.ReadTimeout,
+		"FREIGHTLINER_SERVER_WRITE_TIMEOUT":          &config.Server.WriteTimeout,
+		"FREIGHTLINER_SERVER_SHUTDOWN_TIMEOUT":       &config.Server.ShutdownTimeout,
+		"FREIGHTLINER_SERVER_IDEMPOTENCY_TTL":        &config.Server.IdempotencyTTL,
+		"FREIGHTLINER_DNS_NEGATIVE_TTL":              &config.Network.DNS.NegativeTTL,
+		"FREIGHTLINER_TREE_CHECKPOINT_WRITE_TIMEOUT": &config.TreeReplicate.CheckpointWriteTimeout,
+		"FREIGHTLINER_PRUNE_CONFIG_POLL_INTERVAL":    &config.Prune.PollInterval,
+		"FREIGHTLINER_CLOCK_SKEW_THRESHOLD":          &config.ClockSkewThreshold,
+		"FREIGHTLINER_WEBHOOK_MAX_AGE":               &config.Webhooks.MaxAge,
 	}
 
 	// Load environment variables
@@ -325,10 +428,142 @@ func (c *Config) Validate() error {
 		return errors.InvalidInputf("GCP project must be specified when using GCP KMS for encryption")
 	}
 
-	// Validate log level
-	logLevel := strings.ToLower(c.LogLevel)
-	if logLevel != "debug" && logLevel != "info" && logLevel != "warn" && logLevel != "error" && logLevel != "fatal" {
-		return errors.InvalidInputf("invalid log level: %s (must be one of: debug, info, warn, error, fatal)", c.LogLevel)
+	// Validate log level and per-package overrides
+	if strings.TrimSpace(c.LogLevel) == "" {
+		return errors.InvalidInputf("log level must be specified")
+	}
+	if _, err := log.ParseLevelSpec(c.LogLevel); err != nil {
+		return errors.InvalidInputf("%v", err)
+	}
+	if c.LogSampling.First < 0 || c.LogSampling.Thereafter < 0 {
+		return errors.InvalidInputf("log sampling counts must be non-negative")
+	}
+
+	// Validate native ECR replication policy
+	switch c.ECR.NativeReplication {
+	case "", NativeReplicationWarn, NativeReplicationSkip, NativeReplicationIgnore:
+	default:
+		return errors.InvalidInputf("invalid ECR native replication policy: %s (must be one of: warn, skip, ignore)", c.ECR.NativeReplication)
+	}
+	switch c.ECR.PullThroughCache {
+	case "", PullThroughCacheWarn, PullThroughCacheSkip, PullThroughCacheIgnore:
+	default:
+		return errors.InvalidInputf("invalid ECR pull through cache policy: %s (must be one of: warn, skip, ignore)", c.ECR.PullThroughCache)
+	}
+
+	// Validate repository auto-creation policy and template
+	switch c.Replicate.CreateMissingRepos {
+	case "", CreateMissingReposTrue, CreateMissingReposFalse, CreateMissingReposPrompt:
+	default:
+		return errors.InvalidInputf("invalid create-missing-repos policy: %s (must be one of: true, false, prompt)", c.Replicate.CreateMissingRepos)
+	}
+	switch c.TreeReplicate.CheckpointSlowStore {
+	case "", "wait", "disable":
+	default:
+		return errors.InvalidInputf("invalid checkpoint slow store policy: %s (must be wait or disable)", c.TreeReplicate.CheckpointSlowStore)
+	}
+	if c.TreeReplicate.CheckpointWriteTimeout < 0 {
+		return errors.InvalidInputf("checkpoint write timeout must be non-negative")
+	}
+	switch c.Replicate.ReferrersScheme {
+	case "", "auto", "oci", "tags":
+	default:
+		return errors.InvalidInputf("invalid referrers scheme: %s (must be one of: auto, oci, tags)", c.Replicate.ReferrersScheme)
+	}
+	switch c.Replicate.RepositoryTemplate.EncryptionType {
+	case "", "AES256", "KMS":
+	default:
+		return errors.InvalidInputf("invalid repository template encryption type: %s (must be AES256 or KMS)", c.Replicate.RepositoryTemplate.EncryptionType)
+	}
+	if c.Replicate.RepositoryTemplate.KMSKey != "" && c.Replicate.RepositoryTemplate.EncryptionType != "KMS" {
+		return errors.InvalidInputf("repository template kms_key requires encryption_type KMS")
+	}
+
+	// Validate per-host insecure registry rules
+	if _, err := ParseInsecureRules(c.Registries.InsecureRegistries); err != nil {
+		return err
+	}
+
+	// Validate DNS resolution overrides
+	if _, err := ParseResolveOverrides(c.Network.Resolve); err != nil {
+		return err
+	}
+
+	// Validate DNS cache settings
+	dns := c.Network.DNS
+	if dns.MinTTL < 0 || dns.MaxTTL < 0 || dns.NegativeTTL < 0 || dns.ServeStale < 0 {
+		return errors.InvalidInputf("DNS cache durations must not be negative")
+	}
+	if dns.MaxTTL > 0 && dns.MaxTTL < dns.MinTTL {
+		return errors.InvalidInputf("DNS max_ttl %s is below min_ttl %s", dns.MaxTTL, dns.MinTTL)
+	}
+	if dns.Retries < 0 {
+		return errors.InvalidInputf("DNS retries must not be negative")
+	}
+	if c.Network.MaxRequestsPerSecond < 0 {
+		return errors.InvalidInputf("max requests per second must not be negative")
+	}
+
+	// Validate concurrency profile
+	if c.Profile != "" {
+		if _, err := LookupProfile(c.Profile); err != nil {
+			return err
+		}
+	}
+
+	// Validate report upload destination
+	if c.Reports.UploadURL != "" && !strings.HasPrefix(c.Reports.UploadURL, "s3://") && !strings.HasPrefix(c.Reports.UploadURL, "gs://") {
+		return errors.InvalidInputf("invalid report upload URL: %s (must start with s3:// or gs://)", c.Reports.UploadURL)
+	}
+
+	// Validate attestation settings
+	if c.Attestation.KeyFile != "" && c.Attestation.Output == "" {
+		return errors.InvalidInputf("attestation key requires an attestation output file")
+	}
+
+	// Validate clock skew settings
+	if c.ClockSkewThreshold < 0 {
+		return errors.InvalidInputf("clock skew threshold must not be negative")
+	}
+	if c.CompensateClockSkew && c.ClockSkewThreshold == 0 {
+		return errors.InvalidInputf("clock skew compensation requires a clock skew threshold")
+	}
+
+	// Validate scheduled prune settings
+	if c.Prune.ReportDir != "" && c.Prune.SyncConfig == "" {
+		return errors.InvalidInputf("prune report directory requires a prune sync config")
+	}
+	if c.Prune.StateFile != "" && c.Prune.SyncConfig == "" {
+		return errors.InvalidInputf("prune state file requires a prune sync config")
+	}
+	if c.Prune.PauseWebhook != "" && c.Prune.SyncConfig == "" {
+		return errors.InvalidInputf("prune pause webhook requires a prune sync config")
+	}
+	if c.Prune.PollInterval < 0 {
+		return errors.InvalidInputf("prune config poll interval must not be negative")
+	}
+	if c.Prune.PollInterval > 0 && c.Prune.SyncConfig == "" {
+		return errors.InvalidInputf("prune config poll interval requires a prune sync config")
+	}
+
+	// Validate diagnostics settings
+	if c.Debug.Addr != "" {
+		if _, _, err := net.SplitHostPort(c.Debug.Addr); err != nil {
+			return errors.InvalidInputf("invalid debug address: %s (must be host:port)", c.Debug.Addr)
+		}
+	}
+	if c.Debug.BundleDir != "" && c.Debug.Addr == "" {
+		return errors.InvalidInputf("debug bundle directory requires a debug address")
+	}
+
+	// Validate server-mode tenants
+	if err := c.validateTenants(); err != nil {
+		return err
+	}
+
+	// Validate command timeout
+	if c.Timeout < 0 {
+		return errors.InvalidInputf("timeout must be non-negative")
 	}
 
 	// Validate worker counts
@@ -338,6 +573,24 @@ func (c *Config) Validate() error {
 	if c.Workers.ServeWorkers < 0 {
 		return errors.InvalidInputf("serve workers must be non-negative")
 	}
+	if c.Retry.Budget < 0 {
+		return errors.InvalidInputf("retry budget must be non-negative")
+	}
+	if c.Retry.MaxRetries < 0 {
+		return errors.InvalidInputf("max retries must be non-negative")
+	}
+	if c.TreeReplicate.Workers < 0 {
+		return errors.InvalidInputf("tree replicate workers must be non-negative")
+	}
+	if c.TreeReplicate.TagWorkers < 0 {
+		return errors.InvalidInputf("tree replicate tag workers must be non-negative")
+	}
+	if c.TreeReplicate.MaxTransfers < 0 {
+		return errors.InvalidInputf("tree replicate max transfers must be non-negative")
+	}
+	if err := c.TreeReplicate.NameMapping.Validate(); err != nil {
+		return err
+	}
 
 	// Validate server configuration
 	if c.Server.Port < 0 || c.Server.Port > 65535 {
@@ -350,6 +603,35 @@ func (c *Config) Validate() error {
 		return errors.InvalidInputf("API key must be provided when API key authentication is enabled")
 	}
 
+	// Validate webhook signing
+	if c.Webhooks.RequireSignature && c.Webhooks.Secret == "" && c.Webhooks.VerifyKeyFile == "" {
+		return errors.InvalidInputf("requiring webhook signatures needs a webhook secret or verify key")
+	}
+	if c.Webhooks.MaxAge < 0 {
+		return errors.InvalidInputf("webhook max age must not be negative")
+	}
+	triggers := make(map[string]bool, len(c.Webhooks.Triggers))
+	for _, trigger := range c.Webhooks.Triggers {
+		if trigger.Name == "" {
+			return errors.InvalidInputf("webhook trigger name must not be empty")
+		}
+		if triggers[trigger.Name] {
+			return errors.InvalidInputf("duplicate webhook trigger %s", trigger.Name)
+		}
+		triggers[trigger.Name] = true
+		if trigger.Format != "ecr" && trigger.Format != "gcr" && trigger.Format != "registry" {
+			return errors.InvalidInputf("invalid format %q of webhook trigger %s (must be one of: ecr, gcr, registry)", trigger.Format, trigger.Name)
+		}
+		if trigger.SourceRegistry == "" || trigger.DestRegistry == "" {
+			return errors.InvalidInputf("webhook trigger %s needs a source and destination registry", trigger.Name)
+		}
+		for _, pattern := range trigger.Repositories {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return errors.InvalidInputf("invalid repository pattern %q of webhook trigger %s", pattern, trigger.Name)
+			}
+		}
+	}
+
 	// Validate secrets configuration
 	if c.Secrets.UseSecretsManager {
 		if c.Secrets.SecretsManagerType != "aws" && c.Secrets.SecretsManagerType != "gcp" {
@@ -365,3 +647,29 @@ func (c *Config) Validate() error {
 
 	return nil
 }
+
+// Validate checks the name mapping strategy and its limits
+func (n NameMappingConfig) Validate() error {
+	switch n.Strategy {
+	case "", "preserve":
+	case "flatten":
+		if n.MaxDepth <= 0 {
+			return errors.InvalidInputf("name mapping flatten requires --name-max-depth")
+		}
+	case "hash", "truncate":
+		if n.MaxLength <= 0 {
+			return errors.InvalidInputf("name mapping %s requires --name-max-length", n.Strategy)
+		}
+	default:
+		return errors.InvalidInputf("invalid name mapping: %s (must be one of: preserve, flatten, hash, truncate)", n.Strategy)
+	}
+	if n.MaxDepth < 0 || n.MaxLength < 0 {
+		return errors.InvalidInputf("name mapping limits must be non-negative")
+	}
+	switch n.Separator {
+	case "", "-", "--", "_", "__", ".":
+	default:
+		return errors.InvalidInputf("invalid name mapping separator: %s (must be one of: -, --, _, __, .)", n.Separator)
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package config
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"freightliner/pkg/helper/errors"
+)
+
+// Profile names
+const (
+	ProfileConservative = "conservative"
+	ProfileBalanced     = "balanced"
+	ProfileAggressive   = "aggressive"
+)
+
+// Profile bundles the concurrency, retry, rate limit and timeout settings of
+// a run, tuned together so they need not be set one by one
+type Profile struct {
+	Name string
+
+	// Workers is the number of repositories, or sync images, copied at once
+	Workers int
+
+	// TagWorkers is the number of tags of one repository copied at once
+	TagWorkers int
+
+	// MaxTransfers caps the image copies in flight across a tree replication
+	MaxTransfers int
+
+	// MaxRetries is the number of retries of one registry request, and
+	// RetryBudget the number of request retries allowed per run
+	MaxRetries  int
+	RetryBudget int
+
+	// TaskRetries is the number of retries of a failed sync image, waiting
+	// TaskRetryBackoff before the first and doubling it after each
+	TaskRetries      int
+	TaskRetryBackoff time.Duration
+
+	// TaskTimeout bounds one sync image, including its retries
+	TaskTimeout time.Duration
+
+	// RequestsPerSecond caps the API calls to each registry; 0 is unlimited
+	RequestsPerSecond float64
+}
+
+// profiles holds the built-in profiles. Conservative suits small or shared
+// registries that throttle early; aggressive suits large registries such as
+// ECR and GCR on fast links, where retries are cheap but waiting is not.
+var profiles = map[string]Profile{
+	ProfileConservative: {
+		Name:              ProfileConservative,
+		Workers:           2,
+		TagWorkers:        2,
+		MaxTransfers:      4,
+		MaxRetries:        8,
+		RetryBudget:       500,
+		TaskRetries:       5,
+		TaskRetryBackoff:  10 * time.Second,
+		TaskTimeout:       30 * time.Minute,
+		RequestsPerSecond: 10,
+	},
+	ProfileBalanced: {
+		Name:              ProfileBalanced,
+		Workers:           8,
+		TagWorkers:        4,
+		MaxTransfers:      16,
+		MaxRetries:        5,
+		RetryBudget:       1000,
+		TaskRetries:       3,
+		TaskRetryBackoff:  5 * time.Second,
+		TaskTimeout:       15 * time.Minute,
+		RequestsPerSecond: 50,
+	},
+	ProfileAggressive: {
+		Name:             ProfileAggressive,
+		Workers:          24,
+		TagWorkers:       8,
+		MaxTransfers:     64,
+		MaxRetries:       3,
+		RetryBudget:      2000,
+		TaskRetries:      2,
+		TaskRetryBackoff: 2 * time.Second,
+		TaskTimeout:      10 * time.Minute,
+	},
+}
+
+// ProfileNames returns the names of the built-in profiles, sorted
+func ProfileNames() []string {
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LookupProfile returns the built-in profile called name
+func LookupProfile(name string) (Profile, error) {
+	profile, ok := profiles[name]
+	if !ok {
+		return Profile{}, errors.InvalidInputf("unknown profile %q (must be one of: %s)", name, strings.Join(ProfileNames(), ", "))
+	}
+	return profile, nil
+}
+
+// Apply sets the profile's values on c. Settings whose command line flag
+// explicit reports as given are left alone; explicit may be nil.
+func (p Profile) Apply(c *Config, explicit func(flag string) bool) {
+	settings := []struct {
+		flag  string
+		apply func()
+	}{
+		{"workers", func() { c.TreeReplicate.Workers = p.Workers }},
+		{"tag-workers", func() { c.TreeReplicate.TagWorkers = p.TagWorkers }},
+		{"max-transfers", func() { c.TreeReplicate.MaxTransfers = p.MaxTransfers }},
+		{"replicate-workers", func() { c.Workers.ReplicateWorkers = p.Workers }},
+		{"max-retries", func() { c.Retry.MaxRetries = p.MaxRetries }},
+		{"retry-budget", func() { c.Retry.Budget = p.RetryBudget }},
+		{"max-requests-per-second", func() { c.Network.MaxRequestsPerSecond = p.RequestsPerSecond }},
+	}
+	for _, setting := range settings {
+		if explicit != nil && explicit(setting.flag) {
+			continue
+		}
+		setting.apply()
+	}
+}
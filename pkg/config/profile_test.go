@@ -0,0 +1,75 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLookupProfile(t *testing.T) {
+	for _, name := range ProfileNames() {
+		profile, err := LookupProfile(name)
+		if err != nil {
+			t.Fatalf("LookupProfile(%q) error = %v", name, err)
+		}
+		if profile.Name != name || profile.Workers <= 0 || profile.TaskTimeout <= 0 {
+			t.Errorf("LookupProfile(%q) = %+v, want a complete profile", name, profile)
+		}
+	}
+
+	if _, err := LookupProfile("turbo"); err == nil {
+		t.Error("LookupProfile(turbo) should fail")
+	}
+}
+
+func TestProfileApplySkipsExplicitFlags(t *testing.T) {
+	cfg := NewDefaultConfig()
+	cfg.TreeReplicate.Workers = 3
+
+	profile, _ := LookupProfile(ProfileConservative)
+	profile.Apply(cfg, func(flag string) bool { return flag == "workers" })
+
+	if cfg.TreeReplicate.Workers != 3 {
+		t.Errorf("workers = %d, want explicit 3 kept", cfg.TreeReplicate.Workers)
+	}
+	if cfg.TreeReplicate.TagWorkers != profile.TagWorkers {
+		t.Errorf("tag workers = %d, want %d", cfg.TreeReplicate.TagWorkers, profile.TagWorkers)
+	}
+	if cfg.Retry.MaxRetries != profile.MaxRetries || cfg.Network.MaxRequestsPerSecond != profile.RequestsPerSecond {
+		t.Errorf("retry %d and rate %v not taken from profile", cfg.Retry.MaxRetries, cfg.Network.MaxRequestsPerSecond)
+	}
+}
+
+func TestLoadFromFileProfile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	content := `
+log_level: info
+profile: aggressive
+tree_replicate:
+  workers: 4
+`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadFromFile(path)
+	if err != nil {
+		t.Fatalf("LoadFromFile() error = %v", err)
+	}
+	profile, _ := LookupProfile(ProfileAggressive)
+
+	// Settings in the file win over the profile
+	if cfg.TreeReplicate.Workers != 4 {
+		t.Errorf("workers = %d, want 4 from the file", cfg.TreeReplicate.Workers)
+	}
+	if cfg.TreeReplicate.MaxTransfers != profile.MaxTransfers || cfg.Retry.Budget != profile.RetryBudget {
+		t.Errorf("max transfers %d and retry budget %d not taken from profile", cfg.TreeReplicate.MaxTransfers, cfg.Retry.Budget)
+	}
+
+	if err := os.WriteFile(path, []byte("log_level: info\nprofile: turbo\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadFromFile(path); err == nil {
+		t.Error("LoadFromFile() should reject an unknown profile")
+	}
+}
@@ -3,6 +3,7 @@ package config
 import (
 	"fmt"
 	"net/url"
+	"path"
 	"strings"
 )
 
@@ -46,6 +47,8 @@ const (
 	AuthTypeOAuth AuthType = "oauth"
 	// AuthTypeAnonymous represents anonymous (no authentication)
 	AuthTypeAnonymous AuthType = "anonymous"
+	// AuthTypeOIDC represents short-lived tokens obtained by OIDC token exchange
+	AuthTypeOIDC AuthType = "oidc"
 )
 
 // RegistryConfig represents configuration for a single container registry
@@ -84,13 +87,23 @@ type RegistryConfig struct {
 	// RetryAttempts is the number of retry attempts for failed operations
 	RetryAttempts int `yaml:"retry_attempts,omitempty" json:"retry_attempts,omitempty"`
 
+	// TagListing configures how tags are listed, for registries whose
+	// /tags/list pagination is broken
+	TagListing TagListingConfig `yaml:"tag_listing,omitempty" json:"tag_listing,omitempty"`
+
+	// MediaTypes restricts the manifests pushed when this registry is the destination
+	MediaTypes MediaTypesConfig `yaml:"media_types,omitempty" json:"media_types,omitempty"`
+
+	// Upload configures how blobs are uploaded when this registry is the destination
+	Upload UploadConfig `yaml:"upload,omitempty" json:"upload,omitempty"`
+
 	// Metadata contains additional registry-specific metadata
 	Metadata map[string]string `yaml:"metadata,omitempty" json:"metadata,omitempty"`
 }
 
 // AuthConfig represents authentication configuration for a registry
 type AuthConfig struct {
-	// Type is the authentication type (basic, token, aws, gcp, oauth, anonymous)
+	// Type is the authentication type (basic, token, aws, gcp, oauth, oidc, anonymous)
 	Type AuthType `yaml:"type" json:"type"`
 
 	// Username for basic authentication
@@ -116,6 +129,36 @@ type AuthConfig struct {
 
 	// RoleARN is the AWS IAM role ARN to assume (for AWS authentication)
 	RoleARN string `yaml:"role_arn,omitempty" json:"role_arn,omitempty"`
+
+	// TokenExchange configures OIDC token exchange (for oidc authentication)
+	TokenExchange TokenExchangeConfig `yaml:"token_exchange,omitempty" json:"token_exchange,omitempty"`
+}
+
+// TokenExchangeConfig trades an OIDC assertion, such as a CI job or
+// Kubernetes service account token, for a short-lived registry token
+type TokenExchangeConfig struct {
+	// TokenURL is the token endpoint of the exchange service
+	TokenURL string `yaml:"token_url" json:"token_url"`
+
+	// Audience is the audience requested for the registry token
+	Audience string `yaml:"audience,omitempty" json:"audience,omitempty"`
+
+	// Scope is requested with the registry token, if set
+	Scope string `yaml:"scope,omitempty" json:"scope,omitempty"`
+
+	// ClientID identifies freightliner to the token endpoint, if required
+	ClientID string `yaml:"client_id,omitempty" json:"client_id,omitempty"`
+
+	// ClientAssertion is the OIDC token to exchange; ${VAR} is expanded
+	ClientAssertion string `yaml:"client_assertion,omitempty" json:"client_assertion,omitempty"`
+
+	// ClientAssertionFile is read before every exchange, so rotated tokens
+	// are picked up
+	ClientAssertionFile string `yaml:"client_assertion_file,omitempty" json:"client_assertion_file,omitempty"`
+
+	// GrantType is "token-exchange" (RFC 8693, default) or
+	// "client-credentials" (RFC 7523 client assertion)
+	GrantType string `yaml:"grant_type,omitempty" json:"grant_type,omitempty"`
 }
 
 // TLSConfig represents TLS configuration for registry connections
@@ -133,6 +176,119 @@ type TLSConfig struct {
 	InsecureSkipVerify bool `yaml:"insecure_skip_verify,omitempty" json:"insecure_skip_verify,omitempty"`
 }
 
+// TagListStrategy selects how tags are listed from a registry
+type TagListStrategy string
+
+const (
+	// TagListLink follows the Link headers of /tags/list
+	TagListLink TagListStrategy = "link"
+
+	// TagListPage pages with n and last, ignoring Link headers
+	TagListPage TagListStrategy = "page"
+
+	// TagListFull requests the whole list in one response
+	TagListFull TagListStrategy = "full"
+
+	// TagListAuto follows Link headers and falls back to n/last pagination
+	// when they are broken or the result looks truncated
+	TagListAuto TagListStrategy = "auto"
+)
+
+// TagListingConfig configures tag listing for a registry
+type TagListingConfig struct {
+	// Strategy is link, page, full or auto. Empty keeps the default listing.
+	Strategy TagListStrategy `yaml:"strategy,omitempty" json:"strategy,omitempty"`
+
+	// PageSize is the n requested per page (default: 100)
+	PageSize int `yaml:"page_size,omitempty" json:"page_size,omitempty"`
+}
+
+// Validate checks the strategy and page size
+func (t TagListingConfig) Validate() error {
+	switch t.Strategy {
+	case "", TagListLink, TagListPage, TagListFull, TagListAuto:
+	default:
+		return fmt.Errorf("unsupported tag_listing.strategy: %s", t.Strategy)
+	}
+	if t.PageSize < 0 {
+		return fmt.Errorf("tag_listing.page_size must not be negative")
+	}
+	return nil
+}
+
+// MediaTypesConfig allows or denies manifest media types and OCI artifact
+// types by exact value or path.Match glob, e.g. application/vnd.docker.distribution.manifest.v1+*
+type MediaTypesConfig struct {
+	// Allow, when set, is the only media types that may be pushed
+	Allow []string `yaml:"allow,omitempty" json:"allow,omitempty"`
+
+	// Deny lists media types that are never pushed
+	Deny []string `yaml:"deny,omitempty" json:"deny,omitempty"`
+}
+
+// Validate checks that every pattern is a valid glob
+func (m MediaTypesConfig) Validate() error {
+	for _, pattern := range append(append([]string(nil), m.Allow...), m.Deny...) {
+		if pattern == "" {
+			return fmt.Errorf("media_types patterns must not be empty")
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid media_types pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// UploadStrategy is how blobs are uploaded to a destination registry
+type UploadStrategy string
+
+const (
+	// UploadMonolithic sends each blob in one PATCH, chunking only blobs
+	// larger than max_chunk_size_mb
+	UploadMonolithic UploadStrategy = "monolithic"
+
+	// UploadChunked sends blobs in PATCHes of chunk_size_mb
+	UploadChunked UploadStrategy = "chunked"
+
+	// UploadAuto sizes chunks by the bandwidth measured to the registry
+	UploadAuto UploadStrategy = "auto"
+)
+
+// UploadConfig configures blob uploads to a registry, e.g. large chunks for
+// GCR or a chunk cap for a Harbor behind a proxy limiting request bodies
+type UploadConfig struct {
+	// Strategy is monolithic, chunked or auto. Empty keeps the default single
+	// PATCH per blob.
+	Strategy UploadStrategy `yaml:"strategy,omitempty" json:"strategy,omitempty"`
+
+	// ChunkSizeMB is the chunk size of chunked uploads and the starting size
+	// of auto uploads (default: 16)
+	ChunkSizeMB int `yaml:"chunk_size_mb,omitempty" json:"chunk_size_mb,omitempty"`
+
+	// MaxChunkSizeMB caps the size of every upload request (default: no cap,
+	// 512 for auto)
+	MaxChunkSizeMB int `yaml:"max_chunk_size_mb,omitempty" json:"max_chunk_size_mb,omitempty"`
+}
+
+// Validate checks the strategy and chunk sizes
+func (u UploadConfig) Validate() error {
+	switch u.Strategy {
+	case "", UploadMonolithic, UploadChunked, UploadAuto:
+	default:
+		return fmt.Errorf("unsupported upload.strategy: %s", u.Strategy)
+	}
+	if u.ChunkSizeMB < 0 || u.MaxChunkSizeMB < 0 {
+		return fmt.Errorf("upload chunk sizes must not be negative")
+	}
+	if u.Strategy == "" && (u.ChunkSizeMB > 0 || u.MaxChunkSizeMB > 0) {
+		return fmt.Errorf("upload chunk sizes require upload.strategy")
+	}
+	if u.MaxChunkSizeMB > 0 && u.ChunkSizeMB > u.MaxChunkSizeMB {
+		return fmt.Errorf("upload.chunk_size_mb must not exceed upload.max_chunk_size_mb")
+	}
+	return nil
+}
+
 // RegistriesConfig represents configuration for multiple registries
 type RegistriesConfig struct {
 	// DefaultSource is the default source registry name
@@ -143,6 +299,10 @@ type RegistriesConfig struct {
 
 	// Registries is a list of configured registries
 	Registries []RegistryConfig `yaml:"registries" json:"registries"`
+
+	// InsecureRegistries lists single hosts allowed to use self-signed TLS
+	// ("host:port") or plain HTTP ("http://host:port")
+	InsecureRegistries []string `yaml:"insecure_registries,omitempty" json:"insecure_registries,omitempty"`
 }
 
 // Validate validates the registry configuration
@@ -150,6 +310,9 @@ func (r *RegistryConfig) Validate() error {
 	if r.Name == "" {
 		return fmt.Errorf("registry name is required")
 	}
+	if strings.ContainsAny(r.Name, "/ ") {
+		return fmt.Errorf("registry name %q must not contain '/' or spaces", r.Name)
+	}
 
 	if r.Type == "" {
 		return fmt.Errorf("registry type is required for registry %s", r.Name)
@@ -177,6 +340,18 @@ func (r *RegistryConfig) Validate() error {
 		return fmt.Errorf("invalid auth config for registry %s: %w", r.Name, err)
 	}
 
+	if err := r.TagListing.Validate(); err != nil {
+		return fmt.Errorf("invalid tag listing config for registry %s: %w", r.Name, err)
+	}
+
+	if err := r.MediaTypes.Validate(); err != nil {
+		return fmt.Errorf("invalid media types config for registry %s: %w", r.Name, err)
+	}
+
+	if err := r.Upload.Validate(); err != nil {
+		return fmt.Errorf("invalid upload config for registry %s: %w", r.Name, err)
+	}
+
 	return nil
 }
 
@@ -210,6 +385,18 @@ func (a *AuthConfig) Validate(registryType RegistryType) error {
 		if !a.UseSecretsManager && a.Token == "" {
 			return fmt.Errorf("token is required for token authentication")
 		}
+	case AuthTypeOIDC:
+		if a.TokenExchange.TokenURL == "" {
+			return fmt.Errorf("token_exchange.token_url is required for oidc authentication")
+		}
+		if a.TokenExchange.ClientAssertion == "" && a.TokenExchange.ClientAssertionFile == "" {
+			return fmt.Errorf("token_exchange.client_assertion or client_assertion_file is required for oidc authentication")
+		}
+		switch a.TokenExchange.GrantType {
+		case "", "token-exchange", "client-credentials":
+		default:
+			return fmt.Errorf("unsupported token_exchange.grant_type: %s", a.TokenExchange.GrantType)
+		}
 	case AuthTypeAWS:
 		// AWS credentials are typically from environment or IAM role
 		// No validation needed
@@ -254,6 +441,10 @@ func (r *RegistryConfig) GetRegistryHost() (string, error) {
 	if endpoint == "" {
 		endpoint = r.GetDefaultEndpoint()
 	}
+	// Endpoints are often written as a bare host[:port]
+	if !strings.Contains(endpoint, "://") && endpoint != "" {
+		endpoint = "https://" + endpoint
+	}
 
 	// Parse the endpoint URL
 	u, err := url.Parse(endpoint)
@@ -291,6 +482,30 @@ func (rc *RegistriesConfig) GetByName(name string) (*RegistryConfig, error) {
 	return nil, fmt.Errorf("registry %s not found in configuration", name)
 }
 
+// Lookup finds the registry whose name is ref, or whose host matches ref.
+// ref may be an alias such as "prod-ecr", a host or an endpoint URL.
+func (rc *RegistriesConfig) Lookup(ref string) (*RegistryConfig, bool) {
+	if ref == "" {
+		return nil, false
+	}
+
+	for i := range rc.Registries {
+		if rc.Registries[i].Name == ref {
+			return &rc.Registries[i], true
+		}
+	}
+
+	host := strings.ToLower(strings.TrimSuffix(strings.TrimPrefix(strings.TrimPrefix(ref, "https://"), "http://"), "/"))
+	for i := range rc.Registries {
+		regHost, err := rc.Registries[i].GetRegistryHost()
+		if err == nil && regHost != "" && strings.ToLower(regHost) == host {
+			return &rc.Registries[i], true
+		}
+	}
+
+	return nil, false
+}
+
 // GetByType returns all registries of a specific type
 func (rc *RegistriesConfig) GetByType(registryType RegistryType) []RegistryConfig {
 	var registries []RegistryConfig
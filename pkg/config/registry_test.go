@@ -49,6 +49,62 @@ func TestRegistryConfig_Validate(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "name with slash",
+			config: RegistryConfig{
+				Name:   "prod/ecr",
+				Type:   RegistryTypeECR,
+				Region: "us-east-1",
+			},
+			wantErr: true,
+			errMsg:  "must not contain '/'",
+		},
+		{
+			name: "tag listing fallback",
+			config: RegistryConfig{
+				Name:       "nexus",
+				Type:       RegistryTypeGeneric,
+				Endpoint:   "https://nexus.example.com",
+				Auth:       AuthConfig{Type: AuthTypeAnonymous},
+				TagListing: TagListingConfig{Strategy: TagListAuto, PageSize: 50},
+			},
+			wantErr: false,
+		},
+		{
+			name: "unknown tag listing strategy",
+			config: RegistryConfig{
+				Name:       "nexus",
+				Type:       RegistryTypeGeneric,
+				Endpoint:   "https://nexus.example.com",
+				Auth:       AuthConfig{Type: AuthTypeAnonymous},
+				TagListing: TagListingConfig{Strategy: "catalog"},
+			},
+			wantErr: true,
+			errMsg:  "unsupported tag_listing.strategy",
+		},
+		{
+			name: "media type denylist",
+			config: RegistryConfig{
+				Name:       "lab",
+				Type:       RegistryTypeGeneric,
+				Endpoint:   "https://registry.lab.local",
+				Auth:       AuthConfig{Type: AuthTypeAnonymous},
+				MediaTypes: MediaTypesConfig{Deny: []string{"application/vnd.docker.distribution.manifest.v1+*"}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid media type pattern",
+			config: RegistryConfig{
+				Name:       "lab",
+				Type:       RegistryTypeGeneric,
+				Endpoint:   "https://registry.lab.local",
+				Auth:       AuthConfig{Type: AuthTypeAnonymous},
+				MediaTypes: MediaTypesConfig{Allow: []string{"application/[oci"}},
+			},
+			wantErr: true,
+			errMsg:  "invalid media_types pattern",
+		},
 		{
 			name: "missing name",
 			config: RegistryConfig{
@@ -84,6 +140,41 @@ func TestRegistryConfig_Validate(t *testing.T) {
 			wantErr: true,
 			errMsg:  "project is required for GCR",
 		},
+		{
+			name: "chunked uploads",
+			config: RegistryConfig{
+				Name:     "harbor",
+				Type:     RegistryTypeHarbor,
+				Endpoint: "https://harbor.example.com",
+				Auth:     AuthConfig{Type: AuthTypeAnonymous},
+				Upload:   UploadConfig{Strategy: UploadChunked, ChunkSizeMB: 8, MaxChunkSizeMB: 32},
+			},
+			wantErr: false,
+		},
+		{
+			name: "unknown upload strategy",
+			config: RegistryConfig{
+				Name:     "harbor",
+				Type:     RegistryTypeHarbor,
+				Endpoint: "https://harbor.example.com",
+				Auth:     AuthConfig{Type: AuthTypeAnonymous},
+				Upload:   UploadConfig{Strategy: "parallel"},
+			},
+			wantErr: true,
+			errMsg:  "unsupported upload.strategy",
+		},
+		{
+			name: "chunk size above cap",
+			config: RegistryConfig{
+				Name:     "harbor",
+				Type:     RegistryTypeHarbor,
+				Endpoint: "https://harbor.example.com",
+				Auth:     AuthConfig{Type: AuthTypeAnonymous},
+				Upload:   UploadConfig{Strategy: UploadAuto, ChunkSizeMB: 64, MaxChunkSizeMB: 32},
+			},
+			wantErr: true,
+			errMsg:  "must not exceed",
+		},
 	}
 
 	for _, tt := range tests {
@@ -165,6 +256,27 @@ func TestAuthConfig_Validate(t *testing.T) {
 			registryType: RegistryTypeQuay,
 			wantErr:      true,
 		},
+		{
+			name: "oidc auth with assertion file",
+			authConfig: AuthConfig{
+				Type: AuthTypeOIDC,
+				TokenExchange: TokenExchangeConfig{
+					TokenURL:            "https://sts.example.com/token",
+					ClientAssertionFile: "/var/run/secrets/tokens/registry",
+				},
+			},
+			registryType: RegistryTypeGeneric,
+			wantAuthType: AuthTypeOIDC,
+		},
+		{
+			name: "oidc auth without assertion",
+			authConfig: AuthConfig{
+				Type:          AuthTypeOIDC,
+				TokenExchange: TokenExchangeConfig{TokenURL: "https://sts.example.com/token"},
+			},
+			registryType: RegistryTypeGeneric,
+			wantErr:      true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -288,6 +400,15 @@ func TestRegistryConfig_GetRegistryHost(t *testing.T) {
 			expected: "123456789012.dkr.ecr.us-east-1.amazonaws.com",
 			wantErr:  false,
 		},
+		{
+			name: "endpoint without scheme",
+			config: RegistryConfig{
+				Type:     RegistryTypeGeneric,
+				Endpoint: "registry.lab.internal:5000",
+			},
+			expected: "registry.lab.internal:5000",
+			wantErr:  false,
+		},
 		{
 			name: "invalid endpoint",
 			config: RegistryConfig{
@@ -414,6 +535,46 @@ func TestRegistriesConfig_GetByName(t *testing.T) {
 	}
 }
 
+func TestRegistriesConfig_Lookup(t *testing.T) {
+	config := &RegistriesConfig{
+		Registries: []RegistryConfig{
+			{Name: "prod-ecr", Type: RegistryTypeECR, Region: "us-east-1", AccountID: "123456789012"},
+			{Name: "dr-gcr", Type: RegistryTypeGCR, Project: "dr-project", Region: "eu"},
+			{Name: "lab", Type: RegistryTypeGeneric, Endpoint: "registry.lab.internal:5000"},
+		},
+	}
+
+	tests := []struct {
+		ref      string
+		wantName string
+	}{
+		{ref: "prod-ecr", wantName: "prod-ecr"},
+		{ref: "dr-gcr", wantName: "dr-gcr"},
+		{ref: "123456789012.dkr.ecr.us-east-1.amazonaws.com", wantName: "prod-ecr"},
+		{ref: "eu.gcr.io", wantName: "dr-gcr"},
+		{ref: "registry.lab.internal:5000", wantName: "lab"},
+		{ref: "https://registry.lab.internal:5000/", wantName: "lab"},
+		{ref: "registry.lab.internal", wantName: ""},
+		{ref: "docker.io", wantName: ""},
+		{ref: "", wantName: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			reg, ok := config.Lookup(tt.ref)
+			if tt.wantName == "" {
+				if ok {
+					t.Errorf("Lookup(%q) = %s, want no match", tt.ref, reg.Name)
+				}
+				return
+			}
+			if !ok || reg.Name != tt.wantName {
+				t.Errorf("Lookup(%q) = %v, %v, want %s", tt.ref, reg, ok, tt.wantName)
+			}
+		})
+	}
+}
+
 func TestRegistriesConfig_GetByType(t *testing.T) {
 	config := &RegistriesConfig{
 		Registries: []RegistryConfig{
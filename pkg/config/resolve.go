@@ -0,0 +1,69 @@
+package config
+
+import (
+	"net"
+	"strconv"
+	"strings"
+
+	"freightliner/pkg/helper/errors"
+)
+
+// ResolveOverride pins connections to host:port to fixed addresses, like
+// curl's --resolve. It helps with split-horizon DNS where the registry name
+// resolves differently, or not at all, from where freightliner runs.
+type ResolveOverride struct {
+	// Host is the lower-cased registry host name
+	Host string
+	// Port is the port the override applies to
+	Port int
+	// Addresses are IPv4 or IPv6 addresses to dial, in order of preference
+	Addresses []string
+}
+
+// HostPort returns the "host:port" key the override applies to
+func (o ResolveOverride) HostPort() string {
+	return net.JoinHostPort(o.Host, strconv.Itoa(o.Port))
+}
+
+// ParseResolveOverride parses a "host:port:address[,address]" value. IPv6
+// addresses may be wrapped in brackets, e.g. registry.internal:443:[fd00::5].
+func ParseResolveOverride(value string) (ResolveOverride, error) {
+	parts := strings.SplitN(strings.TrimSpace(value), ":", 3)
+	if len(parts) != 3 {
+		return ResolveOverride{}, errors.InvalidInputf("invalid resolve override %q: expected host:port:address", value)
+	}
+
+	host := strings.ToLower(parts[0])
+	if host == "" {
+		return ResolveOverride{}, errors.InvalidInputf("invalid resolve override %q: missing host", value)
+	}
+
+	port, err := strconv.Atoi(parts[1])
+	if err != nil || port < 1 || port > 65535 {
+		return ResolveOverride{}, errors.InvalidInputf("invalid resolve override %q: invalid port %q", value, parts[1])
+	}
+
+	override := ResolveOverride{Host: host, Port: port}
+	for _, addr := range strings.Split(parts[2], ",") {
+		addr = strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(addr), "["), "]")
+		if net.ParseIP(addr) == nil {
+			return ResolveOverride{}, errors.InvalidInputf("invalid resolve override %q: %q is not an IP address", value, addr)
+		}
+		override.Addresses = append(override.Addresses, addr)
+	}
+
+	return override, nil
+}
+
+// ParseResolveOverrides parses a list of --resolve values
+func ParseResolveOverrides(values []string) ([]ResolveOverride, error) {
+	overrides := make([]ResolveOverride, 0, len(values))
+	for _, value := range values {
+		override, err := ParseResolveOverride(value)
+		if err != nil {
+			return nil, err
+		}
+		overrides = append(overrides, override)
+	}
+	return overrides, nil
+}
@@ -0,0 +1,54 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseResolveOverride(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    ResolveOverride
+		wantErr bool
+	}{
+		{
+			name:  "IPv4",
+			value: "registry.internal:443:10.0.0.5",
+			want:  ResolveOverride{Host: "registry.internal", Port: 443, Addresses: []string{"10.0.0.5"}},
+		},
+		{
+			name:  "bracketed IPv6 and IPv4",
+			value: "Registry.Internal:5000:[fd00::5],10.0.0.5",
+			want:  ResolveOverride{Host: "registry.internal", Port: 5000, Addresses: []string{"fd00::5", "10.0.0.5"}},
+		},
+		{
+			name:  "bare IPv6",
+			value: "registry.internal:443:fd00::5",
+			want:  ResolveOverride{Host: "registry.internal", Port: 443, Addresses: []string{"fd00::5"}},
+		},
+		{name: "missing address", value: "registry.internal:443", wantErr: true},
+		{name: "missing host", value: ":443:10.0.0.5", wantErr: true},
+		{name: "bad port", value: "registry.internal:https:10.0.0.5", wantErr: true},
+		{name: "host name address", value: "registry.internal:443:other.internal", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseResolveOverride(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseResolveOverride(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseResolveOverride(%q) = %+v, want %+v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveOverride_HostPort(t *testing.T) {
+	override := ResolveOverride{Host: "registry.internal", Port: 443}
+	if got := override.HostPort(); got != "registry.internal:443" {
+		t.Errorf("HostPort() = %q", got)
+	}
+}
@@ -0,0 +1,223 @@
+package config
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"freightliner/pkg/helper/errors"
+)
+
+// TenantConfig describes one team served by a shared server-mode deployment.
+// Each tenant brings its own registry credentials, rules, quota and API tokens;
+// nothing is inherited from the server's own credentials.
+type TenantConfig struct {
+	Name string `yaml:"name" json:"name"`
+
+	// APITokens authenticate the tenant's API requests
+	APITokens []string `yaml:"api_tokens" json:"api_tokens"`
+
+	// Namespaces limits source and destination repositories to these prefixes;
+	// empty allows any repository the tenant's credentials reach
+	Namespaces []string `yaml:"namespaces,omitempty" json:"namespaces,omitempty"`
+
+	// Registry credentials used for every job the tenant submits
+	ECR        ECRConfig        `yaml:"ecr" json:"ecr"`
+	GCR        GCRConfig        `yaml:"gcr" json:"gcr"`
+	Registries []RegistryConfig `yaml:"registries,omitempty" json:"registries,omitempty"`
+
+	// Rules applied to the tenant's replications
+	Rules TenantRules `yaml:"rules" json:"rules"`
+
+	// Quota bounds the jobs the tenant may run
+	Quota TenantQuota `yaml:"quota" json:"quota"`
+}
+
+// TenantRules are replication rules applied to every job of a tenant
+type TenantRules struct {
+	Tags         []string `yaml:"tags,omitempty" json:"tags,omitempty"`
+	IncludeTags  []string `yaml:"include_tags,omitempty" json:"include_tags,omitempty"`
+	ExcludeTags  []string `yaml:"exclude_tags,omitempty" json:"exclude_tags,omitempty"`
+	ExcludeRepos []string `yaml:"exclude_repos,omitempty" json:"exclude_repos,omitempty"`
+}
+
+// TenantQuota limits a tenant's jobs; zero means unlimited
+type TenantQuota struct {
+	// MaxActiveJobs caps pending and running jobs
+	MaxActiveJobs int `yaml:"max_active_jobs" json:"max_active_jobs"`
+
+	// MaxJobsPerHour caps jobs submitted within any hour
+	MaxJobsPerHour int `yaml:"max_jobs_per_hour" json:"max_jobs_per_hour"`
+}
+
+// tenantNamePattern keeps tenant names safe for use in paths and logs
+var tenantNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// AllowsRepository reports whether repo lies within one of the tenant's namespaces
+func (t *TenantConfig) AllowsRepository(repo string) bool {
+	if t == nil || len(t.Namespaces) == 0 {
+		return true
+	}
+
+	repo = strings.Trim(repo, "/")
+	for _, ns := range t.Namespaces {
+		ns = strings.Trim(ns, "/")
+		if repo == ns || strings.HasPrefix(repo, ns+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks that the tenant is usable on its own credentials
+func (t *TenantConfig) Validate() error {
+	if !tenantNamePattern.MatchString(t.Name) {
+		return errors.InvalidInputf("invalid tenant name %q (use lower-case letters, digits, '-' and '_')", t.Name)
+	}
+	if len(t.APITokens) == 0 {
+		return errors.InvalidInputf("tenant %s needs at least one API token", t.Name)
+	}
+	for _, token := range t.APITokens {
+		if token == "" {
+			return errors.InvalidInputf("tenant %s has an empty API token", t.Name)
+		}
+	}
+	for _, ns := range t.Namespaces {
+		if strings.Trim(ns, "/") == "" {
+			return errors.InvalidInputf("tenant %s has an empty namespace", t.Name)
+		}
+	}
+	// Any ECR or GCR setting needs the tenant's own credentials; the server's
+	// would otherwise be used
+	if t.usesECR() && !t.ECR.HasExplicitCredentials() {
+		return errors.InvalidInputf("tenant %s must set an ECR profile, role_arn or access keys", t.Name)
+	}
+	if (t.GCR.Project != "" || t.GCR.Location != "") && t.GCR.CredentialsFile == "" {
+		return errors.InvalidInputf("tenant %s must set a GCR credentials_file", t.Name)
+	}
+	for i := range t.Registries {
+		reg := &t.Registries[i]
+		if err := reg.Validate(); err != nil {
+			return errors.Wrapf(err, "tenant %s", t.Name)
+		}
+		if !tenantRegistryHasCredentials(reg) {
+			return errors.InvalidInputf("tenant %s registry %s must set its own credentials", t.Name, reg.Name)
+		}
+	}
+	if t.Quota.MaxActiveJobs < 0 || t.Quota.MaxJobsPerHour < 0 {
+		return errors.InvalidInputf("tenant %s quota must be non-negative", t.Name)
+	}
+	return nil
+}
+
+// usesECR reports whether the tenant configures ECR at all; the replication
+// policies alone do not count
+func (t *TenantConfig) usesECR() bool {
+	ecr := t.ECR
+	ecr.NativeReplication = ""
+	ecr.PullThroughCache = ""
+	return ecr != ECRConfig{}
+}
+
+// tenantRegistryHasCredentials reports whether a cloud registry of a tenant
+// names its own credentials. Other registry types authenticate with what
+// they are given, or anonymously, never as the server.
+func tenantRegistryHasCredentials(reg *RegistryConfig) bool {
+	auth := reg.Auth
+	switch RegistryType(strings.ToLower(string(reg.Type))) {
+	case RegistryTypeECR:
+		return auth.Profile != "" || auth.RoleARN != "" || auth.CredentialsFile != ""
+	case RegistryTypeGCR:
+		return auth.CredentialsFile != ""
+	case RegistryTypeAzure, "acr":
+		return reg.Metadata["useManagedIdentity"] != "true" &&
+			(reg.Metadata["clientSecret"] != "" || reg.Metadata["client_secret"] != "")
+	}
+	return true
+}
+
+// validateTenants checks every tenant and that names and tokens are unique
+func (c *Config) validateTenants() error {
+	names := make(map[string]bool, len(c.Tenants))
+	tokens := make(map[string]string)
+	for i := range c.Tenants {
+		tenant := &c.Tenants[i]
+		if err := tenant.Validate(); err != nil {
+			return err
+		}
+		if names[tenant.Name] {
+			return errors.InvalidInputf("duplicate tenant name %s", tenant.Name)
+		}
+		names[tenant.Name] = true
+
+		for _, token := range tenant.APITokens {
+			if owner, ok := tokens[token]; ok {
+				return errors.InvalidInputf("tenants %s and %s share an API token", owner, tenant.Name)
+			}
+			if token == c.Server.APIKey {
+				return errors.InvalidInputf("tenant %s reuses the server API key", tenant.Name)
+			}
+			tokens[token] = tenant.Name
+		}
+	}
+	return nil
+}
+
+// ForTenant derives the configuration services use for one tenant. Registry
+// credentials come only from the tenant, the secrets manager (which exports
+// credentials to the process environment) is disabled, and checkpoints are
+// kept in a per-tenant directory.
+func (c *Config) ForTenant(name string) (*Config, error) {
+	var tenant *TenantConfig
+	for i := range c.Tenants {
+		if c.Tenants[i].Name == name {
+			t := c.Tenants[i]
+			tenant = &t
+			break
+		}
+	}
+	if tenant == nil {
+		return nil, errors.NotFoundf("tenant %s not found", name)
+	}
+
+	derived := *c
+	derived.Tenants = nil
+	derived.Tenant = tenant
+
+	derived.ECR = tenant.ECR
+	if derived.ECR.NativeReplication == "" {
+		derived.ECR.NativeReplication = c.ECR.NativeReplication
+	}
+	if derived.ECR.PullThroughCache == "" {
+		derived.ECR.PullThroughCache = c.ECR.PullThroughCache
+	}
+	derived.GCR = tenant.GCR
+	derived.Registries = RegistriesConfig{
+		Registries:         tenant.Registries,
+		InsecureRegistries: c.Registries.InsecureRegistries,
+	}
+	derived.Secrets = SecretsConfig{}
+	derived.Attestation = AttestationConfig{}
+	derived.Checkpoint.Directory = filepath.Join(c.Checkpoint.Directory, "tenants", tenant.Name)
+
+	if len(tenant.Rules.Tags) > 0 {
+		derived.Replicate.Tags = tenant.Rules.Tags
+	}
+	if len(tenant.Rules.IncludeTags) > 0 {
+		derived.TreeReplicate.IncludeTags = tenant.Rules.IncludeTags
+	}
+	derived.TreeReplicate.ExcludeTags = appendRules(c.TreeReplicate.ExcludeTags, tenant.Rules.ExcludeTags)
+	derived.TreeReplicate.ExcludeRepos = appendRules(c.TreeReplicate.ExcludeRepos, tenant.Rules.ExcludeRepos)
+
+	return &derived, nil
+}
+
+// appendRules joins server-wide and tenant rules without sharing backing arrays
+func appendRules(base, extra []string) []string {
+	if len(extra) == 0 {
+		return base
+	}
+	rules := make([]string, 0, len(base)+len(extra))
+	rules = append(rules, base...)
+	return append(rules, extra...)
+}
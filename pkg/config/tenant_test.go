@@ -0,0 +1,152 @@
+package config
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func testTenantConfig() *Config {
+	cfg := NewDefaultConfig()
+	cfg.ECR = ECRConfig{Region: "us-east-1", AccountID: "111111111111", AccessKeyID: "server", SecretAccessKey: "server"}
+	cfg.Secrets.UseSecretsManager = true
+	cfg.Checkpoint.Directory = "/var/lib/freightliner"
+	cfg.TreeReplicate.ExcludeRepos = []string{"internal/*"}
+	cfg.Tenants = []TenantConfig{
+		{
+			Name:       "team-a",
+			APITokens:  []string{"token-a"},
+			Namespaces: []string{"team-a/", "shared/base"},
+			ECR:        ECRConfig{Region: "eu-west-1", AccountID: "222222222222", RoleARN: "arn:aws:iam::222222222222:role/mirror"},
+			Rules:      TenantRules{Tags: []string{"v*"}, ExcludeRepos: []string{"team-a/scratch"}},
+		},
+		{
+			Name:      "team-b",
+			APITokens: []string{"token-b"},
+			GCR:       GCRConfig{Project: "team-b", Location: "us", CredentialsFile: "/secrets/team-b.json"},
+		},
+	}
+	return cfg
+}
+
+func TestTenantConfig_AllowsRepository(t *testing.T) {
+	tenant := testTenantConfig().Tenants[0]
+
+	tests := []struct {
+		repo string
+		want bool
+	}{
+		{"team-a", true},
+		{"team-a/app", true},
+		{"/team-a/app/", true},
+		{"shared/base", true},
+		{"shared/base/alpine", true},
+		{"team-ab/app", false},
+		{"shared", false},
+		{"team-b/app", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := tenant.AllowsRepository(tt.repo); got != tt.want {
+			t.Errorf("AllowsRepository(%q) = %v, want %v", tt.repo, got, tt.want)
+		}
+	}
+
+	unrestricted := TenantConfig{Name: "ops"}
+	if !unrestricted.AllowsRepository("anything/at/all") {
+		t.Error("tenant without namespaces should allow every repository")
+	}
+	var none *TenantConfig
+	if !none.AllowsRepository("anything") {
+		t.Error("nil tenant should allow every repository")
+	}
+}
+
+func TestConfig_ValidateTenants(t *testing.T) {
+	tests := []struct {
+		name      string
+		mutate    func(cfg *Config)
+		errSubstr string
+	}{
+		{name: "valid", mutate: func(cfg *Config) {}},
+		{name: "bad name", mutate: func(cfg *Config) { cfg.Tenants[0].Name = "Team A" }, errSubstr: "invalid tenant name"},
+		{name: "no token", mutate: func(cfg *Config) { cfg.Tenants[0].APITokens = nil }, errSubstr: "API token"},
+		{name: "duplicate name", mutate: func(cfg *Config) { cfg.Tenants[1].Name = "team-a" }, errSubstr: "duplicate tenant"},
+		{name: "shared token", mutate: func(cfg *Config) { cfg.Tenants[1].APITokens = []string{"token-a"} }, errSubstr: "share an API token"},
+		{name: "server key reused", mutate: func(cfg *Config) { cfg.Server.APIKey = "token-b" }, errSubstr: "server API key"},
+		{name: "ambient ECR credentials", mutate: func(cfg *Config) { cfg.Tenants[0].ECR.RoleARN = "" }, errSubstr: "ECR profile"},
+		{name: "ambient GCR credentials", mutate: func(cfg *Config) { cfg.Tenants[1].GCR.CredentialsFile = "" }, errSubstr: "credentials_file"},
+		{name: "ECR account without region", mutate: func(cfg *Config) {
+			cfg.Tenants[0].ECR = ECRConfig{AccountID: "123456789012"}
+		}, errSubstr: "ECR profile"},
+		{name: "GCR location without project", mutate: func(cfg *Config) {
+			cfg.Tenants[1].GCR = GCRConfig{Location: "us"}
+		}, errSubstr: "credentials_file"},
+		{name: "replication policy alone", mutate: func(cfg *Config) {
+			cfg.Tenants[0].ECR = ECRConfig{NativeReplication: NativeReplicationSkip}
+		}},
+		{name: "registry on ambient credentials", mutate: func(cfg *Config) {
+			cfg.Tenants[0].Registries = []RegistryConfig{{Name: "prod-ecr", Type: RegistryTypeECR, Region: "us-east-1", AccountID: "123456789012"}}
+		}, errSubstr: "own credentials"},
+		{name: "registry on managed identity", mutate: func(cfg *Config) {
+			cfg.Tenants[0].Registries = []RegistryConfig{{Name: "acr", Type: RegistryTypeAzure, Endpoint: "team.azurecr.io",
+				Auth: AuthConfig{Type: AuthTypeAnonymous}, Metadata: map[string]string{"useManagedIdentity": "true"}}}
+		}, errSubstr: "own credentials"},
+		{name: "empty namespace", mutate: func(cfg *Config) { cfg.Tenants[0].Namespaces = []string{"/"} }, errSubstr: "empty namespace"},
+		{name: "negative quota", mutate: func(cfg *Config) { cfg.Tenants[0].Quota.MaxActiveJobs = -1 }, errSubstr: "quota"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testTenantConfig()
+			tt.mutate(cfg)
+			err := cfg.Validate()
+			if tt.errSubstr == "" {
+				if err != nil {
+					t.Fatalf("Validate() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !contains(err.Error(), tt.errSubstr) {
+				t.Fatalf("Validate() error = %v, want substring %q", err, tt.errSubstr)
+			}
+		})
+	}
+}
+
+func TestConfig_ForTenant(t *testing.T) {
+	cfg := testTenantConfig()
+
+	derived, err := cfg.ForTenant("team-a")
+	if err != nil {
+		t.Fatalf("ForTenant() error = %v", err)
+	}
+
+	if derived.Tenant == nil || derived.Tenant.Name != "team-a" {
+		t.Fatalf("derived config is not scoped to team-a: %+v", derived.Tenant)
+	}
+	if derived.Tenants != nil {
+		t.Error("derived config should not carry other tenants")
+	}
+	if derived.ECR.AccessKeyID != "" || derived.ECR.RoleARN != cfg.Tenants[0].ECR.RoleARN {
+		t.Errorf("derived ECR config leaks server credentials: %+v", derived.ECR)
+	}
+	if derived.Secrets.UseSecretsManager {
+		t.Error("tenants must not load credentials through the secrets manager")
+	}
+	if want := filepath.Join("/var/lib/freightliner", "tenants", "team-a"); derived.Checkpoint.Directory != want {
+		t.Errorf("checkpoint directory = %s, want %s", derived.Checkpoint.Directory, want)
+	}
+	if len(derived.Replicate.Tags) != 1 || derived.Replicate.Tags[0] != "v*" {
+		t.Errorf("tenant tag rules not applied: %v", derived.Replicate.Tags)
+	}
+	if len(derived.TreeReplicate.ExcludeRepos) != 2 {
+		t.Errorf("exclude rules = %v, want server and tenant rules", derived.TreeReplicate.ExcludeRepos)
+	}
+	if len(cfg.TreeReplicate.ExcludeRepos) != 1 || cfg.ECR.AccessKeyID != "server" {
+		t.Error("ForTenant modified the server config")
+	}
+
+	if _, err := cfg.ForTenant("missing"); err == nil {
+		t.Error("ForTenant() expected error for an unknown tenant")
+	}
+}
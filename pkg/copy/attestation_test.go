@@ -0,0 +1,59 @@
+package copy
+
+import (
+	"context"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"freightliner/pkg/attestation"
+	"freightliner/pkg/helper/log"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCopyImage_RecordsLedger(t *testing.T) {
+	source := httptest.NewServer(registry.New())
+	defer source.Close()
+	dest := httptest.NewServer(registry.New())
+	defer dest.Close()
+
+	srcURL, err := url.Parse(source.URL)
+	require.NoError(t, err)
+	destURL, err := url.Parse(dest.URL)
+	require.NoError(t, err)
+
+	img, err := random.Image(512, 2)
+	require.NoError(t, err)
+	srcRef, err := name.NewTag(srcURL.Host + "/team/app:v1")
+	require.NoError(t, err)
+	require.NoError(t, remote.Write(srcRef, img))
+	destRef, err := name.NewTag(destURL.Host + "/team/app:v1")
+	require.NoError(t, err)
+
+	ledger := attestation.NewLedger()
+	copier := NewCopier(log.NewBasicLogger(log.ErrorLevel), CopierOptions{Ledger: ledger})
+
+	// Dry runs push nothing and record nothing
+	_, err = copier.CopyImage(context.Background(), srcRef, destRef, nil, nil, CopyOptions{DryRun: true})
+	require.NoError(t, err)
+	assert.Empty(t, ledger.Transfers())
+
+	_, err = copier.CopyImage(context.Background(), srcRef, destRef, nil, nil, CopyOptions{})
+	require.NoError(t, err)
+
+	transfers := ledger.Transfers()
+	require.Len(t, transfers, 1)
+
+	digest, err := img.Digest()
+	require.NoError(t, err)
+	assert.Equal(t, digest.String(), transfers[0].SourceDigest)
+	assert.Equal(t, digest.String(), transfers[0].DestinationDigest)
+	assert.Equal(t, destRef.String(), transfers[0].Destination)
+	assert.Len(t, transfers[0].Blobs, 3) // config and two layers
+}
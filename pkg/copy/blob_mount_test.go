@@ -0,0 +1,208 @@
+package copy
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"freightliner/pkg/helper/log"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsSameRegistry(t *testing.T) {
+	tests := []struct {
+		name     string
+		source   string
+		dest     string
+		expected bool
+	}{
+		{"same ECR account", "123456789012.dkr.ecr.us-east-1.amazonaws.com/team-a/app:v1", "123456789012.dkr.ecr.us-east-1.amazonaws.com/team-b/app:v1", true},
+		{"different region", "123456789012.dkr.ecr.us-east-1.amazonaws.com/app:v1", "123456789012.dkr.ecr.eu-west-1.amazonaws.com/app:v1", false},
+		{"docker hub aliases", "index.docker.io/library/alpine:3", "docker.io/mirror/alpine:3", true},
+		{"different registries", "gcr.io/project/app:v1", "ghcr.io/owner/app:v1", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src, err := name.ParseReference(tt.source)
+			require.NoError(t, err)
+			dst, err := name.ParseReference(tt.dest)
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.expected, isSameRegistry(src, dst))
+		})
+	}
+}
+
+func TestCopyImage_SameRegistryMountsBlobs(t *testing.T) {
+	server := httptest.NewServer(newMountRegistry(false))
+	defer server.Close()
+
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	img, err := random.Image(1024, 3)
+	require.NoError(t, err)
+
+	srcRef, err := name.NewTag(u.Host + "/team-a/app:v1")
+	require.NoError(t, err)
+	require.NoError(t, remote.Write(srcRef, img))
+
+	destRef, err := name.NewTag(u.Host + "/team-b/app:v1")
+	require.NoError(t, err)
+
+	copier := NewCopier(log.NewBasicLogger(log.ErrorLevel), CopierOptions{})
+	result, err := copier.CopyImage(context.Background(), srcRef, destRef, nil, nil, CopyOptions{
+		Source:      srcRef,
+		Destination: destRef,
+	})
+	require.NoError(t, err)
+	assert.True(t, result.Success)
+
+	// Three layers plus the config blob are mounted, nothing is streamed
+	assert.Equal(t, 4, result.Stats.BlobsMounted)
+	assert.Equal(t, int64(0), result.Stats.BytesTransferred)
+
+	copied, err := remote.Image(destRef)
+	require.NoError(t, err)
+
+	wantDigest, err := img.Digest()
+	require.NoError(t, err)
+	gotDigest, err := copied.Digest()
+	require.NoError(t, err)
+	assert.Equal(t, wantDigest, gotDigest)
+}
+
+// mountRegistry scopes blobs to the repositories they were pushed or
+// mounted to, which the in-memory registry does not, and serves
+// cross-repository mounts unless refuse is set
+type mountRegistry struct {
+	next   http.Handler
+	refuse bool
+
+	mu    sync.Mutex
+	blobs map[string]bool // repository@digest
+}
+
+func newMountRegistry(refuse bool) *mountRegistry {
+	return &mountRegistry{next: registry.New(), refuse: refuse, blobs: make(map[string]bool)}
+}
+
+func (m *mountRegistry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	repo, rest, ok := strings.Cut(strings.TrimPrefix(r.URL.Path, "/v2/"), "/blobs/")
+	if !ok {
+		m.next.ServeHTTP(w, r)
+		return
+	}
+
+	query := r.URL.Query()
+	switch {
+	case r.Method == http.MethodPost && query.Has("mount"):
+		digest, from := query.Get("mount"), query.Get("from")
+		m.mu.Lock()
+		mounted := !m.refuse && m.blobs[from+"@"+digest]
+		if mounted {
+			m.blobs[repo+"@"+digest] = true
+		}
+		m.mu.Unlock()
+		if mounted {
+			w.Header().Set("Location", "/v2/"+repo+"/blobs/"+digest)
+			w.Header().Set("Docker-Content-Digest", digest)
+			w.WriteHeader(http.StatusCreated)
+			return
+		}
+		// Refused: answer as a plain upload session
+		query.Del("mount")
+		query.Del("from")
+		r.URL.RawQuery = query.Encode()
+		m.next.ServeHTTP(w, r)
+
+	case r.Method == http.MethodPut && query.Has("digest"):
+		rec := httptest.NewRecorder()
+		m.next.ServeHTTP(rec, r)
+		if rec.Code == http.StatusCreated {
+			m.mu.Lock()
+			m.blobs[repo+"@"+query.Get("digest")] = true
+			m.mu.Unlock()
+		}
+		for k, v := range rec.Header() {
+			w.Header()[k] = v
+		}
+		w.WriteHeader(rec.Code)
+		_, _ = w.Write(rec.Body.Bytes())
+
+	case (r.Method == http.MethodHead || r.Method == http.MethodGet) && !strings.HasPrefix(rest, "uploads"):
+		m.mu.Lock()
+		found := m.blobs[repo+"@"+rest]
+		m.mu.Unlock()
+		if !found {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		m.next.ServeHTTP(w, r)
+
+	default:
+		m.next.ServeHTTP(w, r)
+	}
+}
+
+func TestCopyImage_RefusedMountsCountAsUploads(t *testing.T) {
+	server := httptest.NewServer(newMountRegistry(true))
+	defer server.Close()
+
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	img, err := random.Image(1024, 3)
+	require.NoError(t, err)
+
+	srcRef, err := name.NewTag(u.Host + "/team-a/app:v1")
+	require.NoError(t, err)
+	require.NoError(t, remote.Write(srcRef, img))
+
+	destRef, err := name.NewTag(u.Host + "/team-b/app:v1")
+	require.NoError(t, err)
+
+	copier := NewCopier(log.NewBasicLogger(log.ErrorLevel), CopierOptions{})
+	result, err := copier.CopyImage(context.Background(), srcRef, destRef, nil, nil, CopyOptions{
+		Source:      srcRef,
+		Destination: destRef,
+	})
+	require.NoError(t, err)
+	assert.True(t, result.Success)
+
+	layers, err := img.Layers()
+	require.NoError(t, err)
+	var layerBytes int64
+	for _, layer := range layers {
+		size, err := layer.Size()
+		require.NoError(t, err)
+		layerBytes += size
+	}
+
+	// Every blob was uploaded in full, so none counts as mounted
+	assert.Equal(t, 0, result.Stats.BlobsMounted)
+	assert.Empty(t, result.Stats.MountedFrom)
+	assert.Equal(t, 3, result.Stats.LayersUploaded)
+	assert.Equal(t, layerBytes, result.Stats.BytesTransferred)
+	assert.Equal(t, int64(0), result.Stats.BytesReused)
+
+	copied, err := remote.Image(destRef)
+	require.NoError(t, err)
+
+	wantDigest, err := img.Digest()
+	require.NoError(t, err)
+	gotDigest, err := copied.Digest()
+	require.NoError(t, err)
+	assert.Equal(t, wantDigest, gotDigest)
+}
@@ -3,19 +3,28 @@ package copy
 import (
 	"bytes"
 	"context"
+	"crypto"
 	"crypto/sha256"
 	"fmt"
 	"io"
+	"net/http"
+	"sync/atomic"
 	"time"
 
+	"freightliner/pkg/attestation"
 	"freightliner/pkg/helper/errors"
 	"freightliner/pkg/helper/log"
+	"freightliner/pkg/helper/readonly"
+	"freightliner/pkg/helper/runid"
 	"freightliner/pkg/helper/util"
 	"freightliner/pkg/network"
+	"freightliner/pkg/resilience"
 	"freightliner/pkg/security/encryption"
+	"freightliner/pkg/transform"
 
 	"github.com/google/go-containerregistry/pkg/name"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/partial"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
 	"github.com/google/go-containerregistry/pkg/v1/types"
 )
@@ -28,6 +37,20 @@ type CopyStats struct {
 	PushDuration     time.Duration
 	Layers           int
 	ManifestSize     int64
+	BlobsMounted     int
+
+	// BytesDeduplicated is the size of layers not uploaded because an earlier
+	// copy in the same run already pushed them
+	BytesDeduplicated int64
+
+	// LayersUploaded and LayersSkipped count the layers uploaded and those the
+	// destination repository already had; MountedFrom holds the layers
+	// mounted from other repositories instead, and BytesReused the size of
+	// every layer that was not uploaded
+	LayersUploaded int
+	LayersSkipped  int
+	MountedFrom    []MountSource
+	BytesReused    int64
 }
 
 // BlobTransferFunc is a function that transfers a blob from source to destination
@@ -39,6 +62,21 @@ type CopyOptions struct {
 	ForceOverwrite bool
 	Source         name.Reference
 	Destination    name.Reference
+
+	// MediaTypes restricts the manifests pushed to the destination (optional)
+	MediaTypes *MediaTypePolicy
+
+	// Transforms rewrites the image's layers and config before it is pushed
+	// (optional)
+	Transforms *transform.Pipeline
+
+	// Loops annotates pushed manifests with their mirror lineage and skips
+	// images that already passed through the destination (optional)
+	Loops *LoopPolicy
+
+	// Annotations are set on the pushed manifest, which then has a digest of
+	// its own (optional)
+	Annotations map[string]string
 }
 
 // CopyResult represents the result of a copy operation
@@ -48,14 +86,59 @@ type CopyResult struct {
 	Error   error
 }
 
+// CopierOptions configures a Copier. Options are fixed when the Copier is
+// built, so one Copier can be shared by concurrent copies.
+type CopierOptions struct {
+	// EncryptionManager encrypts blobs before upload (nil when disabled)
+	EncryptionManager *encryption.Manager
+
+	// BlobTransferFunc replaces the default blob transfer (optional)
+	BlobTransferFunc BlobTransferFunc
+
+	// Metrics collects replication metrics (optional)
+	Metrics Metrics
+
+	// Dedup shares a run-wide record of pushed blobs so layers already pushed
+	// by another copy are skipped or mounted instead of uploaded
+	Dedup *BlobDedup
+
+	// Ledger records every pushed manifest and its blobs for the run's attestation
+	Ledger *attestation.Ledger
+
+	// RetryBudget and MaxRetries retry throttled registry requests, honoring
+	// Retry-After, and draw every retry from a budget shared by the whole run.
+	// They apply to registries whose remote options do not bring their own
+	// transport.
+	RetryBudget *resilience.RetryBudget
+	MaxRetries  int
+
+	// Resigner signs the destination manifest whenever the pushed digest
+	// differs from the source digest, e.g. when a platform image is copied out
+	// of an index, so signature policies at the destination still pass
+	Resigner crypto.Signer
+
+	// LayerReuse records how the layers of every pushed image reached the
+	// destination, for the run's layer reuse report (optional)
+	LayerReuse *LayerReuse
+
+	// AnnotateRunID records the run ID of the copy's context on every
+	// pushed manifest, which gives the pushed image a digest of its own
+	AnnotateRunID bool
+}
+
 // Copier handles container image copying between registries
 type Copier struct {
-	logger        log.Logger
-	encryptionMgr *encryption.Manager
-	transferFunc  BlobTransferFunc
-	stats         *CopyStats
-	metrics       Metrics
-	bufferMgr     *util.BufferManager
+	opts           CopierOptions
+	logger         log.Logger
+	encryptionMgr  *encryption.Manager
+	transferFunc   BlobTransferFunc
+	stats          *CopyStats
+	metrics        Metrics
+	bufferMgr      *util.BufferManager
+	dedup          *BlobDedup
+	retryTransport http.RoundTripper
+	ledger         *attestation.Ledger
+	resigner       crypto.Signer
 }
 
 // Metrics interface for tracking copy operations
@@ -65,37 +148,52 @@ type Metrics interface {
 	ReplicationFailed()
 }
 
-// NewCopier creates a new copier
-func NewCopier(logger log.Logger) *Copier {
-	return &Copier{
-		logger:    logger,
-		stats:     &CopyStats{},
-		bufferMgr: util.NewBufferManager(),
-		transferFunc: func(ctx context.Context, srcBlobURL, destBlobURL string) error {
+// NewCopier creates a copier configured by opts
+func NewCopier(logger log.Logger, opts CopierOptions) *Copier {
+	c := &Copier{
+		opts:          opts,
+		logger:        logger,
+		encryptionMgr: opts.EncryptionManager,
+		transferFunc:  opts.BlobTransferFunc,
+		stats:         &CopyStats{},
+		metrics:       opts.Metrics,
+		bufferMgr:     util.NewBufferManager(),
+		dedup:         opts.Dedup,
+		ledger:        opts.Ledger,
+		resigner:      opts.Resigner,
+	}
+
+	if c.transferFunc == nil {
+		c.transferFunc = func(ctx context.Context, srcBlobURL, destBlobURL string) error {
 			// Default implementation - in real code, this would handle blob transfers
 			return nil
-		},
+		}
+	}
+
+	if opts.RetryBudget != nil || opts.MaxRetries > 0 {
+		retryOpts := resilience.DefaultRetryTransportOptions()
+		retryOpts.MaxRetries = opts.MaxRetries
+		retryOpts.Budget = opts.RetryBudget
+		retryOpts.Logger = logger
+		c.retryTransport = resilience.NewRetryTransport(remote.DefaultTransport, retryOpts)
 	}
-}
 
-// WithEncryptionManager sets the encryption manager
-func (c *Copier) WithEncryptionManager(manager *encryption.Manager) *Copier {
-	c.encryptionMgr = manager
 	return c
 }
 
-// WithBlobTransferFunc sets a custom blob transfer function
-func (c *Copier) WithBlobTransferFunc(transferFunc BlobTransferFunc) *Copier {
-	if transferFunc != nil {
-		c.transferFunc = transferFunc
-	}
-	return c
+// Options returns the options the copier was built with, e.g. to build a
+// copier that differs in one setting
+func (c *Copier) Options() CopierOptions {
+	return c.opts
 }
 
-// WithMetrics sets the metrics collector
-func (c *Copier) WithMetrics(metrics Metrics) *Copier {
-	c.metrics = metrics
-	return c
+// withRetryTransport puts the retry transport ahead of the caller's options
+// so an explicit transport from the registry client still takes precedence
+func (c *Copier) withRetryTransport(opts []remote.Option) []remote.Option {
+	if c.retryTransport == nil {
+		return opts
+	}
+	return append([]remote.Option{remote.WithTransport(c.retryTransport)}, opts...)
 }
 
 // CopyImage copies an image from source to destination
@@ -123,27 +221,84 @@ func (c *Copier) CopyImage(
 		"dry_run":     options.DryRun,
 	}).Info("Copying image")
 
+	// Fail before reading anything when the destination must not be written
+	if !options.DryRun {
+		if err := readonly.Check(fmt.Sprintf("push %s", destRef)); err != nil {
+			return result, err
+		}
+	}
+
+	srcOpts = c.withRetryTransport(srcOpts)
+	destOpts = c.withRetryTransport(destOpts)
+
 	// 1. Fetch the source image descriptor
 	srcDesc, err := c.getSourceImageDescriptor(ctx, sourceRef, srcOpts)
 	if err != nil {
 		return result, errors.Wrap(err, "failed to get source image descriptor")
 	}
 
+	// Refuse media types the destination does not accept before copying anything
+	if err := options.MediaTypes.Check(srcDesc.MediaType, srcDesc.Manifest); err != nil {
+		c.logger.WithFields(map[string]interface{}{
+			"source":      sourceRef.String(),
+			"destination": destRef.String(),
+			"reason":      err.Error(),
+		}).Info("Skipping image denied by destination media type policy")
+		return result, err
+	}
+
+	// Refuse images mirrored from the destination, which would loop forever
+	if err := options.Loops.Check(srcDesc.Manifest, destRef); err != nil {
+		c.logger.WithFields(map[string]interface{}{
+			"source":      sourceRef.String(),
+			"destination": destRef.String(),
+			"reason":      err.Error(),
+		}).Info("Skipping image that would loop between mirrors")
+		return result, err
+	}
+
 	// 2. Check if destination exists and handle overwrite policy
 	if checkErr := c.checkDestinationExists(ctx, destRef, destOpts, options.ForceOverwrite); checkErr != nil {
 		return result, checkErr
 	}
 
-	// 3. Process the manifest and copy layers
-	manifest, err := c.copyImageContents(ctx, sourceRef, destRef, srcDesc, srcOpts, destOpts, options.DryRun, stats)
-	if err != nil {
-		return result, errors.Wrap(err, "failed to copy image contents")
+	// 3. Process the manifest and copy layers, or push the transformed image
+	var manifest []byte
+	if options.Transforms.Empty() {
+		manifest, err = c.copyImageContents(ctx, sourceRef, destRef, srcDesc, srcOpts, destOpts, options.DryRun, stats)
+		if err != nil {
+			return result, errors.Wrap(err, "failed to copy image contents")
+		}
+		if annotations := options.pushAnnotations(srcDesc.Manifest, sourceRef, destRef, c.annotatedRunID(ctx)); annotations != nil && !options.DryRun {
+			manifest, err = AnnotateManifest(manifest, annotations)
+			if err != nil {
+				return result, errors.Wrap(err, "failed to annotate manifest")
+			}
+		}
+	} else {
+		manifest, err = c.copyTransformed(ctx, sourceRef, destRef, srcDesc, destOpts, options, stats)
+		if err != nil {
+			return result, errors.Wrap(err, "failed to copy transformed image")
+		}
 	}
 
 	// 4. Push the manifest if not dry run
 	if !options.DryRun {
-		if err := c.pushManifest(ctx, manifest, destRef, destOpts); err != nil {
-			return result, errors.Wrap(err, "failed to push manifest")
+		if options.Transforms.Empty() {
+			if err := c.pushManifestOnce(ctx, manifest, destRef, destOpts); err != nil {
+				return result, errors.Wrap(err, "failed to push manifest")
+			}
+		}
+		c.recordTransfer(sourceRef, destRef, srcDesc.Digest, manifest)
+		c.opts.LayerReuse.Record(sourceRef, destRef, *stats)
+
+		if c.resigner != nil {
+			destDigest := v1.Hash{Algorithm: "sha256", Hex: fmt.Sprintf("%x", sha256.Sum256(manifest))}
+			if destDigest != srcDesc.Digest {
+				if _, err := c.Resign(ctx, destRef, srcDesc.Digest, destDigest, destOpts); err != nil {
+					return result, errors.Wrap(err, "failed to re-sign destination image")
+				}
+			}
 		}
 	}
 
@@ -175,6 +330,15 @@ func (c *Copier) getSourceImageDescriptor(
 	return desc, nil
 }
 
+// annotatedRunID returns the run ID to annotate pushed manifests with, or ""
+// when the copier does not annotate them or ctx carries no run ID
+func (c *Copier) annotatedRunID(ctx context.Context) string {
+	if !c.opts.AnnotateRunID {
+		return ""
+	}
+	return runid.FromContext(ctx)
+}
+
 // checkDestinationExists checks if the destination image exists already
 func (c *Copier) checkDestinationExists(
 	ctx context.Context,
@@ -247,8 +411,31 @@ func (c *Copier) copyImageContents(
 	// Record the start time for pull duration
 	pullStartTime := time.Now()
 
+	// When source and destination share a registry, blobs are mounted server-side
+	// so no layer bytes have to flow through this process
+	sameRegistry := isSameRegistry(sourceRef, destRef)
+	if sameRegistry {
+		c.logger.WithFields(map[string]interface{}{
+			"registry": destRef.Context().RegistryStr(),
+		}).Debug("Source and destination share a registry, using cross-repository blob mounts")
+	}
+
 	// Only process layers if not dry run
 	if !dryRun {
+		if sameRegistry {
+			configLayer, err := partial.ConfigLayer(img)
+			if err != nil {
+				return nil, errors.Wrap(err, "failed to get config blob")
+			}
+			result, err := c.mountBlob(ctx, configLayer, sourceRef, destRef, destOpts)
+			if err != nil {
+				return nil, errors.Wrap(err, "failed to mount config blob")
+			}
+			if result == blobMounted {
+				stats.BlobsMounted++
+			}
+		}
+
 		// Process each layer
 		for i, layer := range layers {
 			// Get the digest
@@ -276,11 +463,40 @@ func (c *Copier) copyImageContents(
 				"dest_url":   destBlobURL,
 			}).Debug("Copying layer")
 
+			if sameRegistry {
+				result, err := c.mountBlob(ctx, layer, sourceRef, destRef, destOpts)
+				if err != nil {
+					return nil, errors.Wrap(err, "failed to mount blob")
+				}
+				stats.recordMount(result, sourceRef.Context().Name(), size)
+				continue
+			}
+
+			if c.dedup != nil {
+				deduplicated, err := c.dedupBlob(ctx, layer, digest, size, destRef, destOpts, stats)
+				if err != nil {
+					return nil, err
+				}
+				if deduplicated {
+					continue
+				}
+			}
+
 			// Transfer the blob with proper implementation
-			transferred, err := c.transferBlob(ctx, layer, sourceRef, destRef, srcOpts, destOpts)
+			transferred, existed, err := c.transferBlob(ctx, layer, sourceRef, destRef, srcOpts, destOpts)
 			if err != nil {
 				return nil, errors.Wrap(err, "failed to transfer blob")
 			}
+			if existed {
+				stats.LayersSkipped++
+				stats.BytesReused += size
+			} else {
+				stats.LayersUploaded++
+			}
+
+			if c.dedup != nil {
+				c.dedup.record(destRef.Context(), digest.String())
+			}
 
 			// Update transfer statistics
 			stats.BytesTransferred += transferred
@@ -302,6 +518,30 @@ func (c *Copier) copyImageContents(
 	return manifest, nil
 }
 
+// recordTransfer adds a pushed manifest and the blobs it references to the ledger
+func (c *Copier) recordTransfer(sourceRef, destRef name.Reference, sourceDigest v1.Hash, manifest []byte) {
+	if c.ledger == nil {
+		return
+	}
+
+	transfer := attestation.Transfer{
+		Source:            sourceRef.String(),
+		Destination:       destRef.String(),
+		SourceDigest:      sourceDigest.String(),
+		DestinationDigest: fmt.Sprintf("sha256:%x", sha256.Sum256(manifest)),
+		Blobs:             []attestation.Blob{},
+	}
+
+	if parsed, err := v1.ParseManifest(bytes.NewReader(manifest)); err == nil {
+		transfer.Blobs = append(transfer.Blobs, attestation.Blob{Digest: parsed.Config.Digest.String(), Size: parsed.Config.Size})
+		for _, layer := range parsed.Layers {
+			transfer.Blobs = append(transfer.Blobs, attestation.Blob{Digest: layer.Digest.String(), Size: layer.Size})
+		}
+	}
+
+	c.ledger.Record(transfer)
+}
+
 // pushManifest uploads the final manifest to the destination
 func (c *Copier) pushManifest(
 	ctx context.Context,
@@ -356,6 +596,37 @@ func (c *Copier) pushManifest(
 	return nil
 }
 
+// pushManifestOnce pushes manifest to destRef unless an earlier copy in the
+// run already pushed the same manifest there. Its blobs were then skipped as
+// already in the repository, so the image is complete without a push.
+func (c *Copier) pushManifestOnce(
+	ctx context.Context,
+	manifest []byte,
+	destRef name.Reference,
+	destOpts []remote.Option,
+) error {
+	if c.dedup == nil {
+		return c.pushManifest(ctx, manifest, destRef, destOpts)
+	}
+
+	digest := fmt.Sprintf("sha256:%x", sha256.Sum256(manifest))
+	if c.dedup.manifestPushed(destRef, digest) {
+		c.dedup.manifestsSkipped.Add(1)
+		c.dedup.saved(destRef.Context(), int64(len(manifest)))
+		c.logger.WithFields(map[string]interface{}{
+			"destination": destRef.String(),
+			"digest":      digest,
+		}).Debug("Manifest already pushed in this run, not pushing again")
+		return nil
+	}
+
+	if err := c.pushManifest(ctx, manifest, destRef, destOpts); err != nil {
+		return err
+	}
+	c.dedup.recordManifest(destRef, digest)
+	return nil
+}
+
 // manifestDescriptor implements the remote.Taggable interface for manifest uploads
 type manifestDescriptor struct {
 	mediaType types.MediaType
@@ -375,7 +646,8 @@ func (m *manifestDescriptor) Digest() (v1.Hash, error) {
 	return m.hash, nil
 }
 
-// transferBlob handles the actual blob transfer between registries
+// transferBlob handles the actual blob transfer between registries. It reports
+// whether the destination already had the blob, in which case nothing is sent.
 func (c *Copier) transferBlob(
 	ctx context.Context,
 	layer v1.Layer,
@@ -383,16 +655,16 @@ func (c *Copier) transferBlob(
 	destRef name.Reference,
 	srcOpts []remote.Option,
 	destOpts []remote.Option,
-) (int64, error) {
+) (int64, bool, error) {
 	// Get layer properties
 	digest, err := layer.Digest()
 	if err != nil {
-		return 0, errors.Wrap(err, "failed to get layer digest")
+		return 0, false, errors.Wrap(err, "failed to get layer digest")
 	}
 
 	size, err := layer.Size()
 	if err != nil {
-		return 0, errors.Wrap(err, "failed to get layer size")
+		return 0, false, errors.Wrap(err, "failed to get layer size")
 	}
 
 	c.logger.WithFields(map[string]interface{}{
@@ -407,13 +679,13 @@ func (c *Copier) transferBlob(
 		c.logger.WithFields(map[string]interface{}{
 			"digest": digest.String(),
 		}).Debug("Blob already exists at destination, skipping")
-		return 0, nil // Already exists, no bytes transferred
+		return 0, true, nil // Already exists, no bytes transferred
 	}
 
 	// Get layer reader from source
 	reader, err := layer.Compressed()
 	if err != nil {
-		return 0, errors.Wrap(err, "failed to get layer reader")
+		return 0, false, errors.Wrap(err, "failed to get layer reader")
 	}
 	defer func() {
 		_ = reader.Close()
@@ -424,7 +696,7 @@ func (c *Copier) transferBlob(
 	if c.shouldCompress(size) {
 		processedReader, err = c.compressStream(reader)
 		if err != nil {
-			return 0, errors.Wrap(err, "failed to compress stream")
+			return 0, false, errors.Wrap(err, "failed to compress stream")
 		}
 		defer func() {
 			_ = processedReader.Close()
@@ -435,17 +707,21 @@ func (c *Copier) transferBlob(
 	if c.encryptionMgr != nil {
 		processedReader, err = c.encryptBlob(ctx, processedReader, destRef.Context().RegistryStr())
 		if err != nil {
-			return 0, errors.Wrap(err, "failed to encrypt blob")
+			return 0, false, errors.Wrap(err, "failed to encrypt blob")
 		}
 		defer func() {
 			_ = processedReader.Close()
 		}()
 	}
 
-	// Upload blob to destination
-	err = c.uploadBlob(ctx, destRef, digest, processedReader, destOpts)
+	// Upload blob to destination, with its length unless it was rewritten
+	uploadSize := size
+	if processedReader != reader {
+		uploadSize = -1
+	}
+	err = c.uploadBlob(ctx, destRef, digest, processedReader, uploadSize, destOpts)
 	if err != nil {
-		return 0, errors.Wrap(err, "failed to upload blob")
+		return 0, false, errors.Wrap(err, "failed to upload blob")
 	}
 
 	c.logger.WithFields(map[string]interface{}{
@@ -453,7 +729,156 @@ func (c *Copier) transferBlob(
 		"size":   size,
 	}).Debug("Successfully transferred blob")
 
-	return size, nil
+	return size, false, nil
+}
+
+// isSameRegistry reports whether both references point at the same registry host
+func isSameRegistry(sourceRef, destRef name.Reference) bool {
+	return sourceRef.Context().RegistryStr() == destRef.Context().RegistryStr()
+}
+
+// mountResult says how a blob reached the destination when it was mounted
+type mountResult int
+
+const (
+	// blobMounted means the registry mounted the blob server-side
+	blobMounted mountResult = iota
+
+	// blobExisted means the destination repository already had the blob
+	blobExisted
+
+	// blobUploaded means the registry refused the mount and the blob was
+	// streamed to it instead
+	blobUploaded
+)
+
+// recordMount counts a layer of size bytes that was mounted from repository,
+// or that ended up skipped or uploaded when the mount did not happen
+func (s *CopyStats) recordMount(result mountResult, repository string, size int64) {
+	switch result {
+	case blobMounted:
+		s.BlobsMounted++
+		s.MountedFrom = addMountSource(s.MountedFrom, repository, 1, size)
+		s.BytesReused += size
+	case blobExisted:
+		s.LayersSkipped++
+		s.BytesReused += size
+	case blobUploaded:
+		s.LayersUploaded++
+		s.BytesTransferred += size
+	}
+}
+
+// readProbeLayer notes whether a layer's content was read, which only
+// happens when a mount falls back to uploading it
+type readProbeLayer struct {
+	v1.Layer
+	read atomic.Bool
+}
+
+func (l *readProbeLayer) Compressed() (io.ReadCloser, error) {
+	l.read.Store(true)
+	return l.Layer.Compressed()
+}
+
+func (l *readProbeLayer) Uncompressed() (io.ReadCloser, error) {
+	l.read.Store(true)
+	return l.Layer.Uncompressed()
+}
+
+// mountBlob asks the destination registry to mount a blob from the source repository.
+// The registry performs the copy server-side; go-containerregistry only falls back to
+// streaming the blob if the registry refuses the mount request, which the result
+// reports so callers don't count the upload as a mount.
+func (c *Copier) mountBlob(
+	ctx context.Context,
+	layer v1.Layer,
+	sourceRef name.Reference,
+	destRef name.Reference,
+	destOpts []remote.Option,
+) (mountResult, error) {
+	digest, err := layer.Digest()
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to get blob digest")
+	}
+
+	if exists, checkErr := c.checkBlobExists(ctx, destRef, digest, destOpts); checkErr == nil && exists {
+		return blobExisted, nil
+	}
+
+	c.logger.WithFields(map[string]interface{}{
+		"digest": digest.String(),
+		"from":   sourceRef.Context().RepositoryStr(),
+		"to":     destRef.Context().RepositoryStr(),
+	}).Debug("Mounting blob")
+
+	probe := &readProbeLayer{Layer: layer}
+	mountable := &remote.MountableLayer{
+		Layer:     probe,
+		Reference: sourceRef,
+	}
+
+	opts := append([]remote.Option{remote.WithContext(ctx)}, destOpts...)
+	if err := remote.WriteLayer(destRef.Context(), mountable, opts...); err != nil {
+		return 0, errors.Wrap(err, "failed to mount blob at destination")
+	}
+
+	if probe.read.Load() {
+		c.logger.WithFields(map[string]interface{}{
+			"digest": digest.String(),
+			"to":     destRef.Context().RepositoryStr(),
+		}).Debug("Registry refused the blob mount, blob was uploaded")
+		return blobUploaded, nil
+	}
+	return blobMounted, nil
+}
+
+// dedupBlob skips a layer an earlier copy in the run already pushed to the
+// destination repository, or mounts it from another destination repository that
+// received it. It reports whether the layer was handled.
+func (c *Copier) dedupBlob(
+	ctx context.Context,
+	layer v1.Layer,
+	digest v1.Hash,
+	size int64,
+	destRef name.Reference,
+	destOpts []remote.Option,
+	stats *CopyStats,
+) (bool, error) {
+	from, inRepo, found := c.dedup.lookup(destRef.Context(), digest.String())
+	if !found {
+		return false, nil
+	}
+
+	if inRepo {
+		c.dedup.blobsSkipped.Add(1)
+		stats.LayersSkipped++
+		stats.BytesReused += size
+	} else {
+		result, err := c.mountBlob(ctx, layer, from.Digest(digest.String()), destRef, destOpts)
+		if err != nil {
+			return false, errors.Wrap(err, "failed to mount deduplicated blob")
+		}
+		c.dedup.record(destRef.Context(), digest.String())
+		stats.recordMount(result, from.Name(), size)
+		if result != blobMounted {
+			return true, nil
+		}
+		c.dedup.blobsMounted.Add(1)
+	}
+
+	c.dedup.saved(destRef.Context(), size)
+	stats.BytesDeduplicated += size
+
+	c.logger.WithFields(map[string]interface{}{
+		"digest":   digest.String(),
+		"size":     size,
+		"mounted":  !inRepo,
+		"from":     from.RepositoryStr(),
+		"dest_url": destRef.Context().String(),
+	}).Debug("Layer already pushed in this run, not uploading again")
+
+	return true, nil
 }
 
 // checkBlobExists checks if a blob already exists at the destination
@@ -466,8 +891,13 @@ func (c *Copier) checkBlobExists(
 	// Create blob reference
 	blobRef := destRef.Context().Digest(digest.String())
 
-	// Try to get the blob descriptor
-	_, err := remote.Get(blobRef, destOpts...)
+	// HEAD the blob; remote.Get would ask the manifests endpoint, which never
+	// has it
+	opts := append([]remote.Option{remote.WithContext(ctx)}, destOpts...)
+	layer, err := remote.Layer(blobRef, opts...)
+	if err == nil {
+		_, err = layer.Size()
+	}
 	if err != nil {
 		// If error contains "not found" or similar, blob doesn't exist
 		return false, nil
@@ -537,12 +967,14 @@ func (c *Copier) compressStream(reader io.ReadCloser) (io.ReadCloser, error) {
 	return pr, nil
 }
 
-// uploadBlob uploads a blob to the destination registry
+// uploadBlob uploads a blob of size bytes to the destination registry. A
+// negative size streams the blob without a length.
 func (c *Copier) uploadBlob(
 	ctx context.Context,
 	destRef name.Reference,
 	digest v1.Hash,
 	reader io.Reader,
+	size int64,
 	destOpts []remote.Option,
 ) error {
 	// For production implementation, we would use the registry's blob upload API
@@ -565,6 +997,7 @@ func (c *Copier) uploadBlob(
 		digestHash: digest,
 		reader:     reader,
 		bufferMgr:  c.bufferMgr,
+		cachedSize: size,
 	}
 
 	// Upload using remote.WriteLayer
@@ -641,6 +1074,10 @@ func (s *streamingBlobLayer) Size() (int64, error) {
 	if s.cachedSize > 0 {
 		return s.cachedSize, nil
 	}
+	// A zero length lets the HTTP client stream a body of unknown length
+	if s.cachedSize < 0 {
+		return 0, nil
+	}
 	// Return a reasonable default - in production this would need better handling
 	return 1024 * 1024, nil // 1MB default
 }
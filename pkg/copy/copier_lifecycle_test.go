@@ -22,56 +22,32 @@ import (
 // TestNewCopierLifecycle tests the copier creation and initialization
 func TestNewCopierLifecycle(t *testing.T) {
 	logger := log.NewBasicLogger(log.InfoLevel)
-	copier := copy.NewCopier(logger)
+	copier := copy.NewCopier(logger, copy.CopierOptions{})
 
 	assert.NotNil(t, copier, "copier should not be nil")
 	// Note: We can't access private fields directly, but we can test the behavior
 }
 
-// TestCopierBuilderPattern tests the builder pattern methods
-func TestCopierBuilderPattern(t *testing.T) {
-	logger := log.NewBasicLogger(log.InfoLevel)
-	copier := copy.NewCopier(logger)
-
-	t.Run("WithEncryptionManager chaining", func(t *testing.T) {
-		result := copier.WithEncryptionManager(nil)
-		assert.Equal(t, copier, result, "should return same instance for chaining")
-	})
-
-	t.Run("WithBlobTransferFunc chaining", func(t *testing.T) {
-		transferFunc := func(ctx context.Context, src, dest string) error {
-			return nil
-		}
-		result := copier.WithBlobTransferFunc(transferFunc)
-		assert.Equal(t, copier, result, "should return same instance for chaining")
-	})
-
-	t.Run("WithBlobTransferFunc nil safety", func(t *testing.T) {
-		result := copier.WithBlobTransferFunc(nil)
-		assert.Equal(t, copier, result, "should handle nil transfer func gracefully")
-	})
-
-	t.Run("WithMetrics chaining", func(t *testing.T) {
-		metrics := &mockMetrics{}
-		result := copier.WithMetrics(metrics)
-		assert.Equal(t, copier, result, "should return same instance for chaining")
-	})
-}
-
-// TestCopierChainedBuilder tests multiple builder calls
-func TestCopierChainedBuilder(t *testing.T) {
+// TestCopierOptions tests that a copier keeps the options it was built with
+func TestCopierOptions(t *testing.T) {
 	logger := log.NewBasicLogger(log.InfoLevel)
 	metrics := &mockMetrics{}
 	transferFunc := func(ctx context.Context, src, dest string) error {
 		return nil
 	}
 
-	copier := copy.NewCopier(logger).
-		WithEncryptionManager(nil).
-		WithBlobTransferFunc(transferFunc).
-		WithMetrics(metrics)
+	copier := copy.NewCopier(logger, copy.CopierOptions{
+		BlobTransferFunc: transferFunc,
+		Metrics:          metrics,
+		MaxRetries:       3,
+	})
 
-	assert.NotNil(t, copier, "chained builder should return valid copier")
+	assert.NotNil(t, copier, "copier should not be nil")
+	opts := copier.Options()
+	assert.Same(t, metrics, opts.Metrics)
+	assert.NotNil(t, opts.BlobTransferFunc)
+	assert.Equal(t, 3, opts.MaxRetries)
+	assert.Nil(t, opts.EncryptionManager)
 }
 
 // TestCopyImageDryRun tests dry run mode (no actual registry operations)
@@ -81,7 +57,7 @@ func TestCopyImageDryRun(t *testing.T) {
 	}
 
 	logger := log.NewBasicLogger(log.InfoLevel)
-	copier := copy.NewCopier(logger)
+	copier := copy.NewCopier(logger, copy.CopierOptions{})
 
 	srcRef, err := name.ParseReference("source.registry.io/repo:tag")
 	require.NoError(t, err)
@@ -114,7 +90,7 @@ func TestCopyImageForceOverwrite(t *testing.T) {
 	}
 
 	logger := log.NewBasicLogger(log.InfoLevel)
-	copier := copy.NewCopier(logger)
+	copier := copy.NewCopier(logger, copy.CopierOptions{})
 
 	srcRef, _ := name.ParseReference("source.io/repo:tag")
 	destRef, _ := name.ParseReference("dest.io/repo:tag")
@@ -157,7 +133,7 @@ func TestCopyImageWithMetrics(t *testing.T) {
 
 	logger := log.NewBasicLogger(log.InfoLevel)
 	metrics := &mockMetrics{}
-	copier := copy.NewCopier(logger).WithMetrics(metrics)
+	copier := copy.NewCopier(logger, copy.CopierOptions{Metrics: metrics})
 
 	srcRef, _ := name.ParseReference("source.io/repo:tag")
 	destRef, _ := name.ParseReference("dest.io/repo:tag")
@@ -319,7 +295,7 @@ func TestCopierWithCustomTransferFunc(t *testing.T) {
 		return nil
 	}
 
-	copier := copy.NewCopier(logger).WithBlobTransferFunc(transferFunc)
+	copier := copy.NewCopier(logger, copy.CopierOptions{BlobTransferFunc: transferFunc})
 	assert.NotNil(t, copier)
 
 	// The transfer function is set, though we can't call it directly in this test
@@ -74,7 +74,7 @@ func TestNewCopier(t *testing.T) {
 	logger := log.NewBasicLogger(log.InfoLevel)
 
 	// Create a copier
-	copier := NewCopier(logger)
+	copier := NewCopier(logger, CopierOptions{})
 
 	// Ensure the copier was created properly
 	if copier.logger == nil {
@@ -90,61 +90,32 @@ func TestNewCopier(t *testing.T) {
 	}
 }
 
-func TestWithEncryptionManager(t *testing.T) {
+func TestNewCopierOptions(t *testing.T) {
 	// Create a logger
 	logger := log.NewBasicLogger(log.InfoLevel)
 
-	// Create a copier
-	copier := NewCopier(logger)
-
-	// Set an encryption manager (nil for test)
-	returnedCopier := copier.WithEncryptionManager(nil)
-
-	// Test method chaining
-	if returnedCopier != copier {
-		t.Error("Method should return the same copier for chaining")
-	}
-}
-
-func TestWithMetrics(t *testing.T) {
-	// Create a logger
-	logger := log.NewBasicLogger(log.InfoLevel)
-
-	// Create a copier
-	copier := NewCopier(logger)
-
-	// Set metrics
+	// Create a copier with metrics, a transfer function and retries
 	mockMetrics := &MockMetrics{}
-	returnedCopier := copier.WithMetrics(mockMetrics)
-
-	// Test method chaining
-	if returnedCopier != copier {
-		t.Error("Method should return the same copier for chaining")
+	testFunc := func(ctx context.Context, srcBlob, destBlob string) error {
+		return nil
 	}
+	copier := NewCopier(logger, CopierOptions{
+		Metrics:          mockMetrics,
+		BlobTransferFunc: testFunc,
+		MaxRetries:       2,
+	})
 
-	// Check the metrics was set
+	// Check the options were applied
 	if copier.metrics != mockMetrics {
 		t.Error("Metrics were not set properly")
 	}
-}
-
-func TestWithBlobTransferFunc(t *testing.T) {
-	// Create a logger
-	logger := log.NewBasicLogger(log.InfoLevel)
-
-	// Create a copier
-	copier := NewCopier(logger)
-
-	// Create a test transfer function
-	testFunc := func(ctx context.Context, srcBlob, destBlob string) error {
-		return nil
+	if copier.transferFunc == nil {
+		t.Error("Transfer function was not set")
 	}
-
-	// Set the transfer function
-	returnedCopier := copier.WithBlobTransferFunc(testFunc)
-
-	// Test method chaining
-	if returnedCopier != copier {
-		t.Error("Method should return the same copier for chaining")
+	if copier.retryTransport == nil {
+		t.Error("Retry transport was not set")
+	}
+	if copier.Options().Metrics != mockMetrics {
+		t.Error("Options() should return the options the copier was built with")
 	}
 }
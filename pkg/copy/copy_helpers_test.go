@@ -21,7 +21,7 @@ func TestCheckBlobExists(t *testing.T) {
 	}
 
 	logger := log.NewBasicLogger(log.InfoLevel)
-	copier := NewCopier(logger)
+	copier := NewCopier(logger, CopierOptions{})
 	ctx := context.Background()
 
 	ref, _ := name.ParseReference("gcr.io/test/repo:tag")
@@ -40,7 +40,7 @@ func TestUploadBlob(t *testing.T) {
 	}
 
 	logger := log.NewBasicLogger(log.InfoLevel)
-	copier := NewCopier(logger)
+	copier := NewCopier(logger, CopierOptions{})
 	ctx := context.Background()
 
 	ref, _ := name.ParseReference("gcr.io/test/repo:tag")
@@ -49,7 +49,7 @@ func TestUploadBlob(t *testing.T) {
 
 	// This will fail because we're not connected to a real registry
 	// But it exercises the code path
-	_ = copier.uploadBlob(ctx, ref, hash, reader, nil)
+	_ = copier.uploadBlob(ctx, ref, hash, reader, int64(reader.Len()), nil)
 }
 
 // TestCheckDestinationExists tests destination checking
@@ -59,7 +59,7 @@ func TestCheckDestinationExists(t *testing.T) {
 	}
 
 	logger := log.NewBasicLogger(log.InfoLevel)
-	copier := NewCopier(logger)
+	copier := NewCopier(logger, CopierOptions{})
 	ctx := context.Background()
 
 	ref, _ := name.ParseReference("gcr.io/test/repo:tag")
@@ -77,7 +77,7 @@ func TestCheckDestinationExists(t *testing.T) {
 // TestCopierStructure tests copier structure is properly initialized
 func TestCopierStructure(t *testing.T) {
 	logger := log.NewBasicLogger(log.InfoLevel)
-	copier := NewCopier(logger)
+	copier := NewCopier(logger, CopierOptions{})
 
 	// Verify all fields are initialized
 	if copier.stats == nil {
@@ -101,7 +101,7 @@ func TestCopierStructure(t *testing.T) {
 // TestBufferManagerIntegration tests buffer manager usage
 func TestBufferManagerIntegration(t *testing.T) {
 	logger := log.NewBasicLogger(log.InfoLevel)
-	copier := NewCopier(logger)
+	copier := NewCopier(logger, CopierOptions{})
 
 	if copier.bufferMgr == nil {
 		t.Fatal("Expected buffer manager to be initialized")
@@ -359,7 +359,7 @@ func TestManifestMediaTypeDetection(t *testing.T) {
 	}
 
 	logger := log.NewBasicLogger(log.InfoLevel)
-	copier := NewCopier(logger)
+	copier := NewCopier(logger, CopierOptions{})
 	ctx := context.Background()
 
 	ref, _ := name.ParseReference("gcr.io/test/repo:tag")
@@ -398,7 +398,7 @@ func TestManifestMediaTypeDetection(t *testing.T) {
 // TestProcessManifest tests process manifest stub
 func TestProcessManifest(t *testing.T) {
 	logger := log.NewBasicLogger(log.InfoLevel)
-	copier := NewCopier(logger)
+	copier := NewCopier(logger, CopierOptions{})
 	ctx := context.Background()
 
 	srcRef, _ := name.ParseReference("source:tag")
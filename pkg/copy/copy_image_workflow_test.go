@@ -93,11 +93,9 @@ func (m *MockImage) LayerByDiffID(v1.Hash) (v1.Layer, error) {
 // TestCopyImage_SuccessfulCopy tests the complete CopyImage workflow
 func TestCopyImage_SuccessfulCopy(t *testing.T) {
 	logger := log.NewBasicLogger(log.InfoLevel)
-	copier := NewCopier(logger)
-
 	// Create mock metrics
 	mockMetrics := &MockMetricsImpl{}
-	copier = copier.WithMetrics(mockMetrics)
+	copier := NewCopier(logger, CopierOptions{Metrics: mockMetrics})
 
 	// Create mock image with layers
 	layers := []v1.Layer{
@@ -151,7 +149,7 @@ func TestCopyImage_SuccessfulCopy(t *testing.T) {
 // TestCopyImage_DryRun tests the dry run functionality
 func TestCopyImage_DryRun(t *testing.T) {
 	logger := log.NewBasicLogger(log.InfoLevel)
-	copier := NewCopier(logger)
+	copier := NewCopier(logger, CopierOptions{})
 
 	// Test with dry run enabled
 	t.Run("dry run skips layer processing", func(t *testing.T) {
@@ -164,7 +162,7 @@ func TestCopyImage_DryRun(t *testing.T) {
 // TestTransferBlob_SuccessfulTransfer tests blob transfer logic
 func TestTransferBlob_SuccessfulTransfer(t *testing.T) {
 	logger := log.NewBasicLogger(log.InfoLevel)
-	copier := NewCopier(logger)
+	copier := NewCopier(logger, CopierOptions{})
 
 	layer := &MockLayer{
 		digest:     v1.Hash{Algorithm: "sha256", Hex: "test123"},
@@ -178,7 +176,7 @@ func TestTransferBlob_SuccessfulTransfer(t *testing.T) {
 	ctx := context.Background()
 
 	// Test with mock layer (will fail on actual remote operations, but tests the logic)
-	_, err := copier.transferBlob(ctx, layer, sourceRef, destRef, nil, nil)
+	_, _, err := copier.transferBlob(ctx, layer, sourceRef, destRef, nil, nil)
 	// We expect an error because we're not mocking the full remote stack
 	// But this tests the method is properly wired
 	assert.Error(t, err) // Expected to fail on remote operations
@@ -187,7 +185,7 @@ func TestTransferBlob_SuccessfulTransfer(t *testing.T) {
 // TestCheckBlobExists_Workflow tests blob existence checking in workflow
 func TestCheckBlobExists_Workflow(t *testing.T) {
 	logger := log.NewBasicLogger(log.InfoLevel)
-	copier := NewCopier(logger)
+	copier := NewCopier(logger, CopierOptions{})
 
 	destRef, _ := name.NewTag("registry.example.com/repo:tag")
 	digest := v1.Hash{Algorithm: "sha256", Hex: "abc123"}
@@ -204,7 +202,7 @@ func TestCheckBlobExists_Workflow(t *testing.T) {
 // TestCompressStream_Workflow tests stream compression in workflow
 func TestCompressStream_Workflow(t *testing.T) {
 	logger := log.NewBasicLogger(log.InfoLevel)
-	copier := NewCopier(logger)
+	copier := NewCopier(logger, CopierOptions{})
 
 	testData := []byte("This is test data that should be compressed. " +
 		"It needs to be long enough to show compression benefits. " +
@@ -276,7 +274,7 @@ func TestStreamingBlobLayer_Interface(t *testing.T) {
 
 	reader := bytes.NewReader(data)
 	logger := log.NewBasicLogger(log.InfoLevel)
-	copier := NewCopier(logger)
+	copier := NewCopier(logger, CopierOptions{})
 
 	layer := &streamingBlobLayer{
 		digestHash: hash,
@@ -330,7 +328,7 @@ func TestStreamingBlobLayer_Interface(t *testing.T) {
 // TestOptimizedReadCloser_Workflow tests the optimized read closer in workflow
 func TestOptimizedReadCloser_Workflow(t *testing.T) {
 	logger := log.NewBasicLogger(log.InfoLevel)
-	copier := NewCopier(logger)
+	copier := NewCopier(logger, CopierOptions{})
 
 	data := []byte("test data for read closer")
 	reader := bytes.NewReader(data)
@@ -366,7 +364,7 @@ func TestOptimizedReadCloser_Workflow(t *testing.T) {
 // TestEncryptBlob_Workflow tests blob encryption logic in workflow
 func TestEncryptBlob_Workflow(t *testing.T) {
 	logger := log.NewBasicLogger(log.InfoLevel)
-	copier := NewCopier(logger)
+	copier := NewCopier(logger, CopierOptions{})
 
 	data := []byte("test data to encrypt")
 	reader := io.NopCloser(bytes.NewReader(data))
@@ -401,29 +399,29 @@ func TestCopyResult_Workflow(t *testing.T) {
 	assert.NoError(t, result.Error)
 }
 
-// TestCopierBuilderPattern tests the builder pattern methods
-func TestCopierBuilderPattern(t *testing.T) {
+// TestCopierOptions tests building copiers from options
+func TestCopierOptions(t *testing.T) {
 	logger := log.NewBasicLogger(log.InfoLevel)
-	copier := NewCopier(logger)
 
-	t.Run("WithMetrics", func(t *testing.T) {
+	t.Run("Metrics", func(t *testing.T) {
 		metrics := &MockMetricsImpl{}
-		result := copier.WithMetrics(metrics)
+		result := NewCopier(logger, CopierOptions{Metrics: metrics})
 		assert.NotNil(t, result)
 		assert.Equal(t, metrics, result.metrics)
 	})
 
-	t.Run("WithBlobTransferFunc with nil", func(t *testing.T) {
-		result := copier.WithBlobTransferFunc(nil)
+	t.Run("BlobTransferFunc with nil", func(t *testing.T) {
+		result := NewCopier(logger, CopierOptions{})
 		assert.NotNil(t, result)
-		// Transfer func should remain unchanged
+		// The default transfer func is used
+		assert.NotNil(t, result.transferFunc)
 	})
 
-	t.Run("WithBlobTransferFunc with custom func", func(t *testing.T) {
+	t.Run("BlobTransferFunc with custom func", func(t *testing.T) {
 		customFunc := func(ctx context.Context, src, dst string) error {
 			return nil
 		}
-		result := copier.WithBlobTransferFunc(customFunc)
+		result := NewCopier(logger, CopierOptions{BlobTransferFunc: customFunc})
 		assert.NotNil(t, result)
 	})
 }
@@ -431,7 +429,7 @@ func TestCopierBuilderPattern(t *testing.T) {
 // TestPushManifest_MediaTypeDetection tests manifest media type detection
 func TestPushManifest_MediaTypeDetection(t *testing.T) {
 	logger := log.NewBasicLogger(log.InfoLevel)
-	copier := NewCopier(logger)
+	copier := NewCopier(logger, CopierOptions{})
 
 	destRef, _ := name.NewTag("registry.example.com/repo:tag")
 	ctx := context.Background()
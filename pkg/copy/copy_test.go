@@ -193,7 +193,7 @@ func TestStreamingBlobLayer(t *testing.T) {
 	reader := bytes.NewReader(data)
 
 	logger := log.NewBasicLogger(log.InfoLevel)
-	copier := NewCopier(logger)
+	copier := NewCopier(logger, CopierOptions{})
 
 	layer := &streamingBlobLayer{
 		digestHash: hash,
@@ -259,7 +259,7 @@ func TestOptimizedReadCloser(t *testing.T) {
 	reader := bytes.NewReader(data)
 
 	logger := log.NewBasicLogger(log.InfoLevel)
-	copier := NewCopier(logger)
+	copier := NewCopier(logger, CopierOptions{})
 
 	orc := &optimizedReadCloser{
 		reader:    reader,
@@ -292,7 +292,7 @@ func TestOptimizedReadCloser(t *testing.T) {
 // TestShouldCompress tests compression decision logic
 func TestShouldCompress(t *testing.T) {
 	logger := log.NewBasicLogger(log.InfoLevel)
-	copier := NewCopier(logger)
+	copier := NewCopier(logger, CopierOptions{})
 
 	tests := []struct {
 		name     string
@@ -322,7 +322,7 @@ func TestCompressStream(t *testing.T) {
 	}
 
 	logger := log.NewBasicLogger(log.InfoLevel)
-	copier := NewCopier(logger)
+	copier := NewCopier(logger, CopierOptions{})
 
 	data := []byte("test data for compression")
 	reader := io.NopCloser(bytes.NewReader(data))
@@ -348,7 +348,7 @@ func TestCompressStream(t *testing.T) {
 // TestEncryptBlob tests blob encryption passthrough
 func TestEncryptBlob(t *testing.T) {
 	logger := log.NewBasicLogger(log.InfoLevel)
-	copier := NewCopier(logger)
+	copier := NewCopier(logger, CopierOptions{})
 
 	ctx := context.Background()
 	data := []byte("test data")
@@ -368,7 +368,7 @@ func TestEncryptBlob(t *testing.T) {
 // TestCopyBlobDeprecated tests the deprecated copyBlob method
 func TestCopyBlobDeprecated(t *testing.T) {
 	logger := log.NewBasicLogger(log.InfoLevel)
-	copier := NewCopier(logger)
+	copier := NewCopier(logger, CopierOptions{})
 
 	ctx := context.Background()
 	_, err := copier.copyBlob(ctx, "src", "dest", "gzip", false)
@@ -101,7 +101,7 @@ func TestStreamingBlobLayerComprehensive(t *testing.T) {
 	hash, _ := v1.NewHash("sha256:streaming456")
 
 	logger := log.NewBasicLogger(log.InfoLevel)
-	copier := NewCopier(logger)
+	copier := NewCopier(logger, CopierOptions{})
 
 	layer := &streamingBlobLayer{
 		digestHash: hash,
@@ -257,7 +257,7 @@ func TestManifestDescriptorComprehensive(t *testing.T) {
 // TestOptimizedReadCloserComprehensive tests optimized read closer
 func TestOptimizedReadCloserComprehensive(t *testing.T) {
 	logger := log.NewBasicLogger(log.InfoLevel)
-	copier := NewCopier(logger)
+	copier := NewCopier(logger, CopierOptions{})
 
 	testData := []byte("comprehensive read closer test data")
 
@@ -346,7 +346,7 @@ func TestOptimizedReadCloserComprehensive(t *testing.T) {
 // TestShouldCompressComprehensive tests compression decision logic
 func TestShouldCompressComprehensive(t *testing.T) {
 	logger := log.NewBasicLogger(log.InfoLevel)
-	copier := NewCopier(logger)
+	copier := NewCopier(logger, CopierOptions{})
 
 	tests := []struct {
 		name     string
@@ -377,7 +377,7 @@ func TestShouldCompressComprehensive(t *testing.T) {
 // TestCopyBlobDeprecated tests the deprecated method returns error
 func TestCopyBlobDeprecatedComprehensive(t *testing.T) {
 	logger := log.NewBasicLogger(log.InfoLevel)
-	copier := NewCopier(logger)
+	copier := NewCopier(logger, CopierOptions{})
 	ctx := context.Background()
 
 	_, err := copier.copyBlob(ctx, "src", "dest", "gzip", false)
@@ -394,7 +394,7 @@ func TestCopyBlobDeprecatedComprehensive(t *testing.T) {
 // TestEncryptBlobPassthrough tests encryption passthrough
 func TestEncryptBlobPassthrough(t *testing.T) {
 	logger := log.NewBasicLogger(log.InfoLevel)
-	copier := NewCopier(logger)
+	copier := NewCopier(logger, CopierOptions{})
 	ctx := context.Background()
 
 	testData := []byte("encryption test data")
@@ -424,7 +424,7 @@ func TestEncryptBlobPassthrough(t *testing.T) {
 // TestProcessManifestReturnsEmpty tests process manifest stub
 func TestProcessManifestReturnsEmpty(t *testing.T) {
 	logger := log.NewBasicLogger(log.InfoLevel)
-	copier := NewCopier(logger)
+	copier := NewCopier(logger, CopierOptions{})
 	ctx := context.Background()
 
 	result, err := copier.processManifest(ctx, nil, nil, nil, nil, nil, false, nil)
@@ -0,0 +1,124 @@
+package copy
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+// DedupStats summarizes the uploads a BlobDedup avoided
+type DedupStats struct {
+	// BlobsSkipped counts blobs already pushed to the same destination repository
+	BlobsSkipped int64
+
+	// BlobsMounted counts blobs mounted from another destination repository
+	BlobsMounted int64
+
+	// ManifestsSkipped counts manifests not pushed again because the
+	// destination reference already had them
+	ManifestsSkipped int64
+
+	// BytesSaved is the total size of blobs and manifests that were not
+	// uploaded again
+	BytesSaved int64
+}
+
+// DedupMetrics receives the bytes deduplication saved per destination
+// registry. *metrics.Registry satisfies it.
+type DedupMetrics interface {
+	RecordDedupBytesSaved(destRegistry string, bytes int64)
+}
+
+// BlobDedup remembers which blobs have been pushed to each destination registry
+// during one run. Repositories that share layers, which is common for images
+// built from a monorepo, then mount a blob from the repository that already
+// received it instead of uploading it again. It is safe for concurrent use by
+// the copiers of every worker in the run.
+type BlobDedup struct {
+	// blobs maps registry@digest to the first destination repository that received it
+	blobs sync.Map
+
+	// repoBlobs records registry/repository@digest for every blob pushed
+	repoBlobs sync.Map
+
+	// manifests maps every reference a manifest was pushed to, by tag or
+	// digest, to the manifest digest
+	manifests sync.Map
+
+	blobsSkipped     atomic.Int64
+	blobsMounted     atomic.Int64
+	manifestsSkipped atomic.Int64
+	bytesSaved       atomic.Int64
+
+	metrics DedupMetrics
+}
+
+// NewBlobDedup creates an empty dedup tracker for one run
+func NewBlobDedup() *BlobDedup {
+	return &BlobDedup{}
+}
+
+// SetMetrics reports the bytes saved to metrics as uploads are avoided. It
+// must be called before the dedup is shared with copiers.
+func (d *BlobDedup) SetMetrics(metrics DedupMetrics) {
+	d.metrics = metrics
+}
+
+// saved counts size bytes not uploaded to repo's registry
+func (d *BlobDedup) saved(repo name.Repository, size int64) {
+	d.bytesSaved.Add(size)
+	if d.metrics != nil {
+		d.metrics.RecordDedupBytesSaved(repo.RegistryStr(), size)
+	}
+}
+
+// manifestPushed reports whether ref already points at the manifest with
+// digest, because an earlier copy in the run pushed it to the same tag or
+// pushed it by digest to the same repository
+func (d *BlobDedup) manifestPushed(ref name.Reference, digest string) bool {
+	if v, ok := d.manifests.Load(ref.Name()); ok && v.(string) == digest {
+		return true
+	}
+	if _, isDigest := ref.(name.Digest); isDigest {
+		_, ok := d.manifests.Load(ref.Context().Name() + "@" + digest)
+		return ok
+	}
+	return false
+}
+
+// recordManifest notes that ref now points at the manifest with digest
+func (d *BlobDedup) recordManifest(ref name.Reference, digest string) {
+	d.manifests.Store(ref.Name(), digest)
+	d.manifests.Store(ref.Context().Name()+"@"+digest, digest)
+}
+
+// lookup reports whether digest is already in repo, or otherwise which repository
+// on the same registry it can be mounted from
+func (d *BlobDedup) lookup(repo name.Repository, digest string) (from name.Repository, inRepo bool, found bool) {
+	if _, ok := d.repoBlobs.Load(repo.Name() + "@" + digest); ok {
+		return repo, true, true
+	}
+
+	if v, ok := d.blobs.Load(repo.RegistryStr() + "@" + digest); ok {
+		return v.(name.Repository), false, true
+	}
+
+	return name.Repository{}, false, false
+}
+
+// record notes that digest now exists in repo
+func (d *BlobDedup) record(repo name.Repository, digest string) {
+	d.repoBlobs.Store(repo.Name()+"@"+digest, struct{}{})
+	d.blobs.LoadOrStore(repo.RegistryStr()+"@"+digest, repo)
+}
+
+// Stats returns the uploads avoided so far
+func (d *BlobDedup) Stats() DedupStats {
+	return DedupStats{
+		BlobsSkipped:     d.blobsSkipped.Load(),
+		BlobsMounted:     d.blobsMounted.Load(),
+		ManifestsSkipped: d.manifestsSkipped.Load(),
+		BytesSaved:       d.bytesSaved.Load(),
+	}
+}
@@ -0,0 +1,182 @@
+package copy
+
+import (
+	"context"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+
+	"freightliner/pkg/helper/log"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlobDedupLookup(t *testing.T) {
+	dedup := NewBlobDedup()
+
+	teamA, err := name.NewRepository("registry.example.com/team-a/app")
+	require.NoError(t, err)
+	teamB, err := name.NewRepository("registry.example.com/team-b/app")
+	require.NoError(t, err)
+	other, err := name.NewRepository("other.example.com/team-a/app")
+	require.NoError(t, err)
+
+	_, _, found := dedup.lookup(teamA, "sha256:abc")
+	assert.False(t, found)
+
+	dedup.record(teamA, "sha256:abc")
+
+	from, inRepo, found := dedup.lookup(teamA, "sha256:abc")
+	assert.True(t, found)
+	assert.True(t, inRepo)
+	assert.Equal(t, teamA.Name(), from.Name())
+
+	from, inRepo, found = dedup.lookup(teamB, "sha256:abc")
+	assert.True(t, found)
+	assert.False(t, inRepo)
+	assert.Equal(t, teamA.Name(), from.Name())
+
+	// Blobs are only shared within a registry
+	_, _, found = dedup.lookup(other, "sha256:abc")
+	assert.False(t, found)
+}
+
+func TestCopyImage_DeduplicatesAcrossRepositories(t *testing.T) {
+	source := httptest.NewServer(registry.New())
+	defer source.Close()
+	dest := httptest.NewServer(newMountRegistry(false))
+	defer dest.Close()
+
+	srcURL, err := url.Parse(source.URL)
+	require.NoError(t, err)
+	destURL, err := url.Parse(dest.URL)
+	require.NoError(t, err)
+
+	img, err := random.Image(512, 3)
+	require.NoError(t, err)
+
+	srcRef, err := name.NewTag(srcURL.Host + "/mono/app:v1")
+	require.NoError(t, err)
+	require.NoError(t, remote.Write(srcRef, img))
+
+	layers, err := img.Layers()
+	require.NoError(t, err)
+	var layerBytes int64
+	for _, layer := range layers {
+		size, err := layer.Size()
+		require.NoError(t, err)
+		layerBytes += size
+	}
+
+	dedup := NewBlobDedup()
+	saved := &recordingDedupMetrics{}
+	dedup.SetMetrics(saved)
+	logger := log.NewBasicLogger(log.ErrorLevel)
+
+	copyTo := func(ref string) *CopyResult {
+		destRef, err := name.NewTag(destURL.Host + "/" + ref)
+		require.NoError(t, err)
+
+		result, err := NewCopier(logger, CopierOptions{Dedup: dedup}).CopyImage(
+			context.Background(), srcRef, destRef, nil, nil,
+			CopyOptions{Source: srcRef, Destination: destRef, ForceOverwrite: true})
+		require.NoError(t, err)
+		require.True(t, result.Success)
+		return result
+	}
+
+	first := copyTo("team-a/app:v1")
+	assert.Equal(t, int64(0), first.Stats.BytesDeduplicated)
+
+	// The second repository mounts every layer from the first
+	second := copyTo("team-b/app:v1")
+	assert.Equal(t, layerBytes, second.Stats.BytesDeduplicated)
+	assert.Equal(t, 3, second.Stats.BlobsMounted)
+
+	// Another tag in the same repository skips the layers outright
+	third := copyTo("team-b/app:v2")
+	assert.Equal(t, layerBytes, third.Stats.BytesDeduplicated)
+	assert.Equal(t, 0, third.Stats.BlobsMounted)
+
+	// Copying a tag again skips the manifest push as well
+	manifest, err := img.RawManifest()
+	require.NoError(t, err)
+	copyTo("team-b/app:v2")
+
+	stats := dedup.Stats()
+	assert.Equal(t, int64(3), stats.BlobsMounted)
+	assert.Equal(t, int64(6), stats.BlobsSkipped)
+	assert.Equal(t, int64(1), stats.ManifestsSkipped)
+	assert.Equal(t, 3*layerBytes+int64(len(manifest)), stats.BytesSaved)
+	assert.Equal(t, map[string]int64{destURL.Host: stats.BytesSaved}, saved.bytes)
+
+	// Every tag was pushed, including those in the other repository
+	for _, ref := range []string{"team-a/app:v1", "team-b/app:v1", "team-b/app:v2"} {
+		tag, err := name.NewTag(destURL.Host + "/" + ref)
+		require.NoError(t, err)
+		_, err = remote.Image(tag)
+		assert.NoError(t, err, ref)
+	}
+}
+
+func TestCopyImage_DedupRefusedMountsSaveNothing(t *testing.T) {
+	source := httptest.NewServer(registry.New())
+	defer source.Close()
+	dest := httptest.NewServer(newMountRegistry(true))
+	defer dest.Close()
+
+	srcURL, err := url.Parse(source.URL)
+	require.NoError(t, err)
+	destURL, err := url.Parse(dest.URL)
+	require.NoError(t, err)
+
+	img, err := random.Image(512, 2)
+	require.NoError(t, err)
+	srcRef, err := name.NewTag(srcURL.Host + "/mono/app:v1")
+	require.NoError(t, err)
+	require.NoError(t, remote.Write(srcRef, img))
+
+	dedup := NewBlobDedup()
+	saved := &recordingDedupMetrics{}
+	dedup.SetMetrics(saved)
+	logger := log.NewBasicLogger(log.ErrorLevel)
+
+	for _, ref := range []string{"team-a/app:v1", "team-b/app:v1"} {
+		destRef, err := name.NewTag(destURL.Host + "/" + ref)
+		require.NoError(t, err)
+		result, err := NewCopier(logger, CopierOptions{Dedup: dedup}).CopyImage(
+			context.Background(), srcRef, destRef, nil, nil,
+			CopyOptions{Source: srcRef, Destination: destRef})
+		require.NoError(t, err)
+		require.True(t, result.Success)
+		assert.Equal(t, int64(0), result.Stats.BytesDeduplicated)
+		assert.Equal(t, 2, result.Stats.LayersUploaded)
+	}
+
+	// The registry refused every mount, so the layers were uploaded twice
+	stats := dedup.Stats()
+	assert.Equal(t, int64(0), stats.BlobsMounted)
+	assert.Equal(t, int64(0), stats.BytesSaved)
+	assert.Empty(t, saved.bytes)
+}
+
+// recordingDedupMetrics totals the bytes saved per destination registry
+type recordingDedupMetrics struct {
+	mu    sync.Mutex
+	bytes map[string]int64
+}
+
+func (m *recordingDedupMetrics) RecordDedupBytesSaved(destRegistry string, bytes int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.bytes == nil {
+		m.bytes = make(map[string]int64)
+	}
+	m.bytes[destRegistry] += bytes
+}
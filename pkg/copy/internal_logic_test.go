@@ -19,7 +19,7 @@ import (
 // TestShouldCompressInternal tests the shouldCompress logic
 func TestShouldCompressInternal(t *testing.T) {
 	logger := log.NewBasicLogger(log.InfoLevel)
-	copier := NewCopier(logger)
+	copier := NewCopier(logger, CopierOptions{})
 
 	tests := []struct {
 		size     int64
@@ -42,7 +42,7 @@ func TestShouldCompressInternal(t *testing.T) {
 // TestEncryptBlobNoManager tests encrypt blob passthrough
 func TestEncryptBlobNoManager(t *testing.T) {
 	logger := log.NewBasicLogger(log.InfoLevel)
-	copier := NewCopier(logger)
+	copier := NewCopier(logger, CopierOptions{})
 	ctx := context.Background()
 
 	data := []byte("test data")
@@ -56,7 +56,7 @@ func TestEncryptBlobNoManager(t *testing.T) {
 // TestProcessManifestStub tests the stub method
 func TestProcessManifestStub(t *testing.T) {
 	logger := log.NewBasicLogger(log.InfoLevel)
-	copier := NewCopier(logger)
+	copier := NewCopier(logger, CopierOptions{})
 	ctx := context.Background()
 
 	result, err := copier.processManifest(ctx, nil, nil, nil, nil, nil, false, nil)
@@ -375,23 +375,22 @@ func TestBuilderPattern(t *testing.T) {
 	logger := log.NewBasicLogger(log.InfoLevel)
 
 	t.Run("Single builder call", func(t *testing.T) {
-		copier := NewCopier(logger).WithEncryptionManager(nil)
+		copier := NewCopier(logger, CopierOptions{EncryptionManager: nil})
 		assert.NotNil(t, copier)
 	})
 
-	t.Run("Chained builder calls", func(t *testing.T) {
-		copier := NewCopier(logger).
-			WithEncryptionManager(nil).
-			WithBlobTransferFunc(nil).
-			WithMetrics(nil)
+	t.Run("Nil options keep defaults", func(t *testing.T) {
+		copier := NewCopier(logger, CopierOptions{BlobTransferFunc: nil, Metrics: nil})
 		assert.NotNil(t, copier)
+		assert.NotNil(t, copier.transferFunc)
+		assert.Nil(t, copier.retryTransport)
 	})
 
 	t.Run("WithBlobTransferFunc non-nil", func(t *testing.T) {
 		transferFunc := func(ctx context.Context, src, dest string) error {
 			return nil
 		}
-		copier := NewCopier(logger).WithBlobTransferFunc(transferFunc)
+		copier := NewCopier(logger, CopierOptions{BlobTransferFunc: transferFunc})
 		assert.NotNil(t, copier)
 	})
 }
@@ -451,7 +450,7 @@ func TestCompressStreamLogic(t *testing.T) {
 	}
 
 	logger := log.NewBasicLogger(log.InfoLevel)
-	copier := NewCopier(logger)
+	copier := NewCopier(logger, CopierOptions{})
 
 	data := []byte("test data for compression")
 	reader := io.NopCloser(bytes.NewReader(data))
@@ -469,7 +468,7 @@ func TestCompressStreamLogic(t *testing.T) {
 // TestCopyBlobDeprecatedMethod tests the deprecated method
 func TestCopyBlobDeprecatedMethod(t *testing.T) {
 	logger := log.NewBasicLogger(log.InfoLevel)
-	copier := NewCopier(logger)
+	copier := NewCopier(logger, CopierOptions{})
 	ctx := context.Background()
 
 	_, err := copier.copyBlob(ctx, "src", "dest", "gzip", false)
@@ -25,7 +25,7 @@ func TestBlobLayerImplementation(t *testing.T) {
 		// We can't directly create blobLayer, but we can test the behavior
 		// through the copier's internal usage
 		logger := log.NewBasicLogger(log.InfoLevel)
-		copier := copy.NewCopier(logger)
+		copier := copy.NewCopier(logger, copy.CopierOptions{})
 		assert.NotNil(t, copier)
 	})
 }
@@ -220,7 +220,7 @@ func TestOptimizedReadCloserWithClosableReader(t *testing.T) {
 // TestShouldCompress tests compression decision logic
 func TestShouldCompress(t *testing.T) {
 	logger := log.NewBasicLogger(log.InfoLevel)
-	copier := copy.NewCopier(logger)
+	copier := copy.NewCopier(logger, copy.CopierOptions{})
 
 	// We can't call shouldCompress directly, but we can test the behavior
 	// through the copier's public API
@@ -234,7 +234,7 @@ func TestCompressStream(t *testing.T) {
 	}
 
 	logger := log.NewBasicLogger(log.InfoLevel)
-	copier := copy.NewCopier(logger)
+	copier := copy.NewCopier(logger, copy.CopierOptions{})
 
 	// We test compression through the copier's behavior
 	assert.NotNil(t, copier)
@@ -467,7 +467,7 @@ func (m *mockCompressibleLayer) MediaType() (types.MediaType, error) {
 // TestEncryptBlobPassthrough tests encryption passthrough when no manager
 func TestEncryptBlobPassthrough(t *testing.T) {
 	logger := log.NewBasicLogger(log.InfoLevel)
-	copier := copy.NewCopier(logger)
+	copier := copy.NewCopier(logger, copy.CopierOptions{})
 	ctx := context.Background()
 
 	testData := []byte("encryption test data")
@@ -0,0 +1,210 @@
+package copy
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"freightliner/pkg/helper/errors"
+
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+// Lineage annotations record where a mirrored image came from, so mirrors
+// chained or meshed together can tell an image they pushed from a new one
+const (
+	// OriginAnnotation names the registry the image was first mirrored from
+	OriginAnnotation = "vnd.freightliner.origin"
+
+	// HopsAnnotation counts the mirrors the image passed through
+	HopsAnnotation = "vnd.freightliner.hops"
+
+	// MirrorPathAnnotation lists those mirrors, comma separated, oldest first
+	MirrorPathAnnotation = "vnd.freightliner.mirror-path"
+)
+
+// RunIDAnnotation names the run that pushed the image, when the copier
+// annotates run IDs
+const RunIDAnnotation = "vnd.freightliner.run-id"
+
+// LoopPolicy annotates pushed manifests with their mirror lineage and refuses
+// images whose lineage shows they already passed through the destination
+type LoopPolicy struct {
+	// Instance names this mirror in the lineage (default: the destination
+	// registry host)
+	Instance string `yaml:"instance,omitempty" json:"instance,omitempty"`
+
+	// MaxHops refuses images mirrored this many times already (0: no limit)
+	MaxHops int `yaml:"max_hops,omitempty" json:"max_hops,omitempty"`
+}
+
+// MirrorLoopSkipError reports an image a loop policy kept from being pushed
+type MirrorLoopSkipError struct {
+	Origin string
+	Hops   int
+	Reason string
+}
+
+func (e *MirrorLoopSkipError) Error() string {
+	return e.Reason
+}
+
+// IsMirrorLoopSkip reports whether err is a mirror loop skip
+func IsMirrorLoopSkip(err error) bool {
+	var skip *MirrorLoopSkipError
+	return errors.As(err, &skip)
+}
+
+// Validate checks the instance name and hop limit
+func (p *LoopPolicy) Validate() error {
+	if p == nil {
+		return nil
+	}
+	if strings.ContainsAny(p.Instance, ", ") {
+		return errors.InvalidInputf("loop prevention instance %q must not contain commas or spaces", p.Instance)
+	}
+	if p.MaxHops < 0 {
+		return errors.InvalidInputf("loop prevention max_hops must not be negative")
+	}
+	return nil
+}
+
+// lineage is the mirror history read from a manifest's annotations
+type lineage struct {
+	origin string
+	hops   int
+	path   []string
+}
+
+// instance returns the name this mirror records for pushes to destRef
+func (p *LoopPolicy) instance(destRef name.Reference) string {
+	if p.Instance != "" {
+		return p.Instance
+	}
+	return destRef.Context().RegistryStr()
+}
+
+// Check returns a *MirrorLoopSkipError when the source manifest came from,
+// or already passed through, the mirror pushing to destRef, or has been
+// mirrored MaxHops times. A nil policy allows everything.
+func (p *LoopPolicy) Check(manifest []byte, destRef name.Reference) error {
+	if p == nil {
+		return nil
+	}
+
+	l := readLineage(manifest)
+	self := []string{p.instance(destRef), destRef.Context().RegistryStr()}
+	for _, host := range self {
+		if l.origin == host {
+			return &MirrorLoopSkipError{
+				Origin: l.origin,
+				Hops:   l.hops,
+				Reason: fmt.Sprintf("image originates from %s, the destination", host),
+			}
+		}
+		for _, hop := range l.path {
+			if hop == host {
+				return &MirrorLoopSkipError{
+					Origin: l.origin,
+					Hops:   l.hops,
+					Reason: fmt.Sprintf("image was already mirrored by %s (path %s)", host, strings.Join(l.path, ",")),
+				}
+			}
+		}
+	}
+	if p.MaxHops > 0 && l.hops >= p.MaxHops {
+		return &MirrorLoopSkipError{
+			Origin: l.origin,
+			Hops:   l.hops,
+			Reason: fmt.Sprintf("image was mirrored %d times, the limit is %d", l.hops, p.MaxHops),
+		}
+	}
+	return nil
+}
+
+// Annotations returns the lineage annotations to push with a copy of the
+// source manifest from sourceRef to destRef: the origin is kept, or set to
+// the source registry on the first hop, and this mirror is added to the path
+func (p *LoopPolicy) Annotations(manifest []byte, sourceRef, destRef name.Reference) map[string]string {
+	l := readLineage(manifest)
+	if l.origin == "" {
+		l.origin = sourceRef.Context().RegistryStr()
+	}
+	path := append(l.path, p.instance(destRef))
+	return map[string]string{
+		OriginAnnotation:     l.origin,
+		HopsAnnotation:       strconv.Itoa(l.hops + 1),
+		MirrorPathAnnotation: strings.Join(path, ","),
+	}
+}
+
+// readLineage reads the lineage annotations of a raw manifest. Manifests
+// without them, or that cannot be parsed, have an empty lineage.
+func readLineage(manifest []byte) lineage {
+	var parsed struct {
+		Annotations map[string]string `json:"annotations"`
+	}
+	if err := json.Unmarshal(manifest, &parsed); err != nil {
+		return lineage{}
+	}
+
+	l := lineage{origin: parsed.Annotations[OriginAnnotation]}
+	if raw := parsed.Annotations[MirrorPathAnnotation]; raw != "" {
+		l.path = strings.Split(raw, ",")
+	}
+	l.hops, _ = strconv.Atoi(parsed.Annotations[HopsAnnotation])
+	if l.hops < len(l.path) {
+		l.hops = len(l.path)
+	}
+	return l
+}
+
+// pushAnnotations returns the annotations to set on the manifest copied from
+// sourceRef to destRef: the lineage when a loop policy is set, the run ID
+// unless it is empty, and the options' own annotations
+func (o CopyOptions) pushAnnotations(manifest []byte, sourceRef, destRef name.Reference, runID string) map[string]string {
+	if o.Loops == nil && len(o.Annotations) == 0 && runID == "" {
+		return nil
+	}
+	annotations := make(map[string]string, len(o.Annotations)+4)
+	if runID != "" {
+		annotations[RunIDAnnotation] = runID
+	}
+	if o.Loops != nil {
+		for key, value := range o.Loops.Annotations(manifest, sourceRef, destRef) {
+			annotations[key] = value
+		}
+	}
+	for key, value := range o.Annotations {
+		annotations[key] = value
+	}
+	return annotations
+}
+
+// AnnotateManifest sets annotations on a raw manifest, keeping every other
+// field as it was. The result has a new digest, which is the same for every
+// copy of the same manifest and annotations.
+func AnnotateManifest(manifest []byte, annotations map[string]string) ([]byte, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(manifest, &fields); err != nil {
+		return nil, errors.Wrap(err, "failed to parse manifest")
+	}
+
+	existing := map[string]string{}
+	if raw, ok := fields["annotations"]; ok {
+		if err := json.Unmarshal(raw, &existing); err != nil {
+			return nil, errors.Wrap(err, "failed to parse manifest annotations")
+		}
+	}
+	for key, value := range annotations {
+		existing[key] = value
+	}
+
+	raw, err := json.Marshal(existing)
+	if err != nil {
+		return nil, err
+	}
+	fields["annotations"] = raw
+	return json.Marshal(fields)
+}
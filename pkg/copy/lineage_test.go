@@ -0,0 +1,217 @@
+package copy
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"freightliner/pkg/helper/log"
+	"freightliner/pkg/helper/runid"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoopPolicy_Check(t *testing.T) {
+	destRef, err := name.NewTag("mirror-b.example.com/app:v1")
+	require.NoError(t, err)
+
+	manifest := func(annotations map[string]string) []byte {
+		data, err := json.Marshal(map[string]interface{}{"schemaVersion": 2, "annotations": annotations})
+		require.NoError(t, err)
+		return data
+	}
+
+	tests := []struct {
+		name       string
+		policy     *LoopPolicy
+		manifest   []byte
+		wantReason string
+	}{
+		{name: "nil policy", manifest: manifest(map[string]string{OriginAnnotation: "mirror-b.example.com"})},
+		{name: "no lineage", policy: &LoopPolicy{}, manifest: manifest(nil)},
+		{name: "other origin", policy: &LoopPolicy{}, manifest: manifest(map[string]string{OriginAnnotation: "docker.io", HopsAnnotation: "1", MirrorPathAnnotation: "mirror-a.example.com"})},
+		{name: "origin is destination", policy: &LoopPolicy{}, manifest: manifest(map[string]string{OriginAnnotation: "mirror-b.example.com", HopsAnnotation: "1", MirrorPathAnnotation: "mirror-a.example.com"}), wantReason: "originates from mirror-b.example.com"},
+		{name: "destination on path", policy: &LoopPolicy{}, manifest: manifest(map[string]string{OriginAnnotation: "docker.io", HopsAnnotation: "2", MirrorPathAnnotation: "mirror-b.example.com,mirror-a.example.com"}), wantReason: "already mirrored by mirror-b.example.com"},
+		{name: "instance on path", policy: &LoopPolicy{Instance: "eu-mirror"}, manifest: manifest(map[string]string{OriginAnnotation: "docker.io", HopsAnnotation: "1", MirrorPathAnnotation: "eu-mirror"}), wantReason: "already mirrored by eu-mirror"},
+		{name: "hop limit", policy: &LoopPolicy{MaxHops: 2}, manifest: manifest(map[string]string{OriginAnnotation: "docker.io", HopsAnnotation: "2", MirrorPathAnnotation: "a,c"}), wantReason: "mirrored 2 times, the limit is 2"},
+		{name: "below hop limit", policy: &LoopPolicy{MaxHops: 3}, manifest: manifest(map[string]string{OriginAnnotation: "docker.io", HopsAnnotation: "2", MirrorPathAnnotation: "a,c"})},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.Check(tt.manifest, destRef)
+			if tt.wantReason == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.True(t, IsMirrorLoopSkip(err))
+			assert.Contains(t, err.Error(), tt.wantReason)
+		})
+	}
+}
+
+func TestLoopPolicy_Validate(t *testing.T) {
+	assert.NoError(t, (*LoopPolicy)(nil).Validate())
+	assert.NoError(t, (&LoopPolicy{Instance: "eu-mirror", MaxHops: 3}).Validate())
+	assert.Error(t, (&LoopPolicy{Instance: "eu,us"}).Validate())
+	assert.Error(t, (&LoopPolicy{MaxHops: -1}).Validate())
+}
+
+func TestAnnotateManifest(t *testing.T) {
+	manifest := []byte(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json","annotations":{"org.opencontainers.image.version":"1.0"}}`)
+
+	annotated, err := AnnotateManifest(manifest, map[string]string{OriginAnnotation: "docker.io"})
+	require.NoError(t, err)
+
+	var parsed struct {
+		SchemaVersion int               `json:"schemaVersion"`
+		MediaType     string            `json:"mediaType"`
+		Annotations   map[string]string `json:"annotations"`
+	}
+	require.NoError(t, json.Unmarshal(annotated, &parsed))
+	assert.Equal(t, 2, parsed.SchemaVersion)
+	assert.Equal(t, "application/vnd.oci.image.manifest.v1+json", parsed.MediaType)
+	assert.Equal(t, map[string]string{
+		"org.opencontainers.image.version": "1.0",
+		OriginAnnotation:                   "docker.io",
+	}, parsed.Annotations)
+}
+
+func TestCopyImage_LoopPreventionStopsMirrorChain(t *testing.T) {
+	mirrorA := httptest.NewServer(registry.New())
+	defer mirrorA.Close()
+	mirrorB := httptest.NewServer(registry.New())
+	defer mirrorB.Close()
+	hostA := mustHost(t, mirrorA.URL)
+	hostB := mustHost(t, mirrorB.URL)
+
+	img, err := random.Image(128, 1)
+	require.NoError(t, err)
+	refA, err := name.NewTag(hostA + "/app:v1")
+	require.NoError(t, err)
+	refB, err := name.NewTag(hostB + "/app:v1")
+	require.NoError(t, err)
+	require.NoError(t, remote.Write(refA, img))
+
+	copier := NewCopier(log.NewBasicLogger(log.ErrorLevel), CopierOptions{})
+	options := CopyOptions{ForceOverwrite: true, Loops: &LoopPolicy{}}
+
+	// A -> B pushes the image with its lineage
+	_, err = copier.CopyImage(context.Background(), refA, refB, nil, nil, options)
+	require.NoError(t, err)
+
+	pushed, err := remote.Image(refB)
+	require.NoError(t, err)
+	manifest, err := pushed.Manifest()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		OriginAnnotation:     hostA,
+		HopsAnnotation:       "1",
+		MirrorPathAnnotation: hostB,
+	}, manifest.Annotations)
+
+	layers, err := pushed.Layers()
+	require.NoError(t, err)
+	assert.Len(t, layers, 1)
+
+	// B -> A would push the image back to where it came from
+	_, err = copier.CopyImage(context.Background(), refB, refA, nil, nil, options)
+	require.Error(t, err)
+	assert.True(t, IsMirrorLoopSkip(err))
+
+	srcDigest, err := img.Digest()
+	require.NoError(t, err)
+	desc, err := remote.Head(refA)
+	require.NoError(t, err)
+	assert.Equal(t, srcDigest, desc.Digest, "the origin must keep its image")
+}
+
+func TestCopyImage_Annotations(t *testing.T) {
+	source := httptest.NewServer(registry.New())
+	defer source.Close()
+	dest := httptest.NewServer(registry.New())
+	defer dest.Close()
+
+	img, err := random.Image(128, 1)
+	require.NoError(t, err)
+	srcRef, err := name.NewTag(mustHost(t, source.URL) + "/team/platform/api:v1")
+	require.NoError(t, err)
+	destRef, err := name.NewTag(mustHost(t, dest.URL) + "/platform-ap:v1")
+	require.NoError(t, err)
+	require.NoError(t, remote.Write(srcRef, img))
+
+	annotations := map[string]string{"vnd.example.source": "team/platform/api"}
+	copier := NewCopier(log.NewBasicLogger(log.ErrorLevel), CopierOptions{})
+	_, err = copier.CopyImage(context.Background(), srcRef, destRef, nil, nil, CopyOptions{Annotations: annotations})
+	require.NoError(t, err)
+
+	pushed, err := remote.Image(destRef)
+	require.NoError(t, err)
+	manifest, err := pushed.Manifest()
+	require.NoError(t, err)
+	assert.Equal(t, annotations, manifest.Annotations)
+
+	// The pushed digest is the source manifest's once annotated, so it can
+	// be predicted without reading the destination
+	raw, err := img.RawManifest()
+	require.NoError(t, err)
+	annotated, err := AnnotateManifest(raw, annotations)
+	require.NoError(t, err)
+	digest, err := pushed.Digest()
+	require.NoError(t, err)
+	assert.Equal(t, fmt.Sprintf("%x", sha256.Sum256(annotated)), digest.Hex)
+}
+
+func TestCopyImage_RunIDAnnotation(t *testing.T) {
+	source := httptest.NewServer(registry.New())
+	defer source.Close()
+	dest := httptest.NewServer(registry.New())
+	defer dest.Close()
+
+	img, err := random.Image(128, 1)
+	require.NoError(t, err)
+	srcRef, err := name.NewTag(mustHost(t, source.URL) + "/team/api:v1")
+	require.NoError(t, err)
+	require.NoError(t, remote.Write(srcRef, img))
+	ctx := runid.WithRunID(context.Background(), "0192f2c4-run")
+
+	// Copiers annotate only when asked to
+	plainRef, err := name.NewTag(mustHost(t, dest.URL) + "/plain/api:v1")
+	require.NoError(t, err)
+	_, err = NewCopier(log.NewBasicLogger(log.ErrorLevel), CopierOptions{}).CopyImage(ctx, srcRef, plainRef, nil, nil, CopyOptions{})
+	require.NoError(t, err)
+	pushed, err := remote.Image(plainRef)
+	require.NoError(t, err)
+	manifest, err := pushed.Manifest()
+	require.NoError(t, err)
+	assert.Empty(t, manifest.Annotations)
+
+	destRef, err := name.NewTag(mustHost(t, dest.URL) + "/annotated/api:v1")
+	require.NoError(t, err)
+	copier := NewCopier(log.NewBasicLogger(log.ErrorLevel), CopierOptions{AnnotateRunID: true})
+	_, err = copier.CopyImage(ctx, srcRef, destRef, nil, nil, CopyOptions{})
+	require.NoError(t, err)
+	pushed, err = remote.Image(destRef)
+	require.NoError(t, err)
+	manifest, err = pushed.Manifest()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{RunIDAnnotation: "0192f2c4-run"}, manifest.Annotations)
+}
+
+// mustHost returns the host of a test server URL
+func mustHost(t *testing.T, serverURL string) string {
+	t.Helper()
+	u, err := url.Parse(serverURL)
+	require.NoError(t, err)
+	return u.Host
+}
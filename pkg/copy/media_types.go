@@ -0,0 +1,121 @@
+package copy
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+
+	"freightliner/pkg/helper/errors"
+
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// MediaTypePolicy restricts the manifests a copy may push to a destination.
+// Patterns are media types or path.Match globs such as
+// application/vnd.docker.distribution.manifest.v1+*. The manifest media type
+// and, for OCI artifacts, the artifact type must not match Deny and, when
+// Allow is set, must each match Allow.
+type MediaTypePolicy struct {
+	Allow []string `yaml:"allow,omitempty" json:"allow,omitempty"`
+	Deny  []string `yaml:"deny,omitempty" json:"deny,omitempty"`
+}
+
+// MediaTypeSkipError reports an image a media type policy kept from being pushed
+type MediaTypeSkipError struct {
+	MediaType string
+	Reason    string
+}
+
+func (e *MediaTypeSkipError) Error() string {
+	return e.Reason
+}
+
+// IsMediaTypeSkip reports whether err is a media type policy skip
+func IsMediaTypeSkip(err error) bool {
+	var skip *MediaTypeSkipError
+	return errors.As(err, &skip)
+}
+
+// Validate checks that every pattern is a valid glob
+func (p *MediaTypePolicy) Validate() error {
+	if p == nil {
+		return nil
+	}
+	for _, pattern := range append(append([]string(nil), p.Allow...), p.Deny...) {
+		if pattern == "" {
+			return errors.InvalidInputf("media type pattern must not be empty")
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return errors.InvalidInputf("invalid media type pattern %q: %v", pattern, err)
+		}
+	}
+	return nil
+}
+
+// Check returns a *MediaTypeSkipError when the manifest with mediaType and
+// raw content may not be pushed. A nil policy allows everything.
+func (p *MediaTypePolicy) Check(mediaType types.MediaType, manifest []byte) error {
+	if p == nil || (len(p.Allow) == 0 && len(p.Deny) == 0) {
+		return nil
+	}
+
+	checked := []string{string(mediaType)}
+	if artifactType := manifestArtifactType(mediaType, manifest); artifactType != "" {
+		checked = append(checked, artifactType)
+	}
+
+	for _, value := range checked {
+		if pattern, ok := matchMediaType(p.Deny, value); ok {
+			return &MediaTypeSkipError{
+				MediaType: value,
+				Reason:    fmt.Sprintf("media type %s is denied by %s", value, pattern),
+			}
+		}
+		if _, ok := matchMediaType(p.Allow, value); len(p.Allow) > 0 && !ok {
+			return &MediaTypeSkipError{
+				MediaType: value,
+				Reason:    fmt.Sprintf("media type %s is not allowed at the destination", value),
+			}
+		}
+	}
+	return nil
+}
+
+// manifestArtifactType returns the artifact type of an OCI manifest: its
+// artifactType, or its config media type when that is not an image config.
+// Images and indexes without one return "".
+func manifestArtifactType(mediaType types.MediaType, manifest []byte) string {
+	if mediaType != types.OCIManifestSchema1 && mediaType != types.OCIImageIndex {
+		return ""
+	}
+
+	var parsed struct {
+		ArtifactType string `json:"artifactType"`
+		Config       struct {
+			MediaType types.MediaType `json:"mediaType"`
+		} `json:"config"`
+	}
+	if err := json.Unmarshal(manifest, &parsed); err != nil {
+		return ""
+	}
+	if parsed.ArtifactType != "" {
+		return parsed.ArtifactType
+	}
+
+	switch parsed.Config.MediaType {
+	case "", types.OCIConfigJSON, types.DockerConfigJSON:
+		return ""
+	default:
+		return string(parsed.Config.MediaType)
+	}
+}
+
+// matchMediaType returns the first pattern that matches value
+func matchMediaType(patterns []string, value string) (string, bool) {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, value); ok {
+			return pattern, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,89 @@
+package copy
+
+import (
+	"context"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"freightliner/pkg/helper/log"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMediaTypePolicy_Check(t *testing.T) {
+	image := []byte(`{"config":{"mediaType":"application/vnd.oci.image.config.v1+json"}}`)
+	helmChart := []byte(`{"config":{"mediaType":"application/vnd.cncf.helm.config.v1+json"}}`)
+	sbom := []byte(`{"artifactType":"application/spdx+json","config":{"mediaType":"application/vnd.oci.empty.v1+json"}}`)
+
+	ociImages := []string{"application/vnd.oci.image.manifest.v1+json", "application/vnd.oci.image.index.v1+json"}
+
+	tests := []struct {
+		name       string
+		policy     *MediaTypePolicy
+		mediaType  types.MediaType
+		manifest   []byte
+		wantReason string
+	}{
+		{name: "nil policy", mediaType: types.DockerManifestSchema1},
+		{name: "deny schema1", policy: &MediaTypePolicy{Deny: []string{"application/vnd.docker.distribution.manifest.v1+*"}}, mediaType: types.DockerManifestSchema1Signed, wantReason: "denied by application/vnd.docker.distribution.manifest.v1+*"},
+		{name: "deny schema1 keeps schema2", policy: &MediaTypePolicy{Deny: []string{"application/vnd.docker.distribution.manifest.v1+*"}}, mediaType: types.DockerManifestSchema2},
+		{name: "allowed image", policy: &MediaTypePolicy{Allow: ociImages}, mediaType: types.OCIManifestSchema1, manifest: image},
+		{name: "type outside allowlist", policy: &MediaTypePolicy{Allow: ociImages}, mediaType: types.DockerManifestSchema2, wantReason: "is not allowed"},
+		{name: "unknown artifact type", policy: &MediaTypePolicy{Allow: ociImages}, mediaType: types.OCIManifestSchema1, manifest: helmChart, wantReason: "application/vnd.cncf.helm.config.v1+json is not allowed"},
+		{name: "allowed artifact type", policy: &MediaTypePolicy{Allow: append([]string{"application/spdx+json"}, ociImages...)}, mediaType: types.OCIManifestSchema1, manifest: sbom},
+		{name: "denied artifact type", policy: &MediaTypePolicy{Deny: []string{"application/vnd.cncf.helm.*"}}, mediaType: types.OCIManifestSchema1, manifest: helmChart, wantReason: "denied by application/vnd.cncf.helm.*"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.Check(tt.mediaType, tt.manifest)
+			if tt.wantReason == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.True(t, IsMediaTypeSkip(err))
+			assert.Contains(t, err.Error(), tt.wantReason)
+		})
+	}
+}
+
+func TestMediaTypePolicy_Validate(t *testing.T) {
+	assert.NoError(t, (*MediaTypePolicy)(nil).Validate())
+	assert.NoError(t, (&MediaTypePolicy{Deny: []string{"application/vnd.docker.*"}}).Validate())
+	assert.Error(t, (&MediaTypePolicy{Allow: []string{"application/[oci"}}).Validate())
+	assert.Error(t, (&MediaTypePolicy{Deny: []string{""}}).Validate())
+}
+
+func TestCopyImage_MediaTypePolicySkipsBeforePush(t *testing.T) {
+	server := httptest.NewServer(registry.New())
+	defer server.Close()
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	img, err := random.Image(128, 1)
+	require.NoError(t, err)
+	srcRef, err := name.NewTag(u.Host + "/src/app:v1")
+	require.NoError(t, err)
+	destRef, err := name.NewTag(u.Host + "/dest/app:v1")
+	require.NoError(t, err)
+	require.NoError(t, remote.Write(srcRef, img))
+
+	copier := NewCopier(log.NewBasicLogger(log.ErrorLevel), CopierOptions{})
+	_, err = copier.CopyImage(context.Background(), srcRef, destRef, nil, nil, CopyOptions{
+		ForceOverwrite: true,
+		MediaTypes:     &MediaTypePolicy{Deny: []string{"application/vnd.docker.distribution.manifest.*"}},
+	})
+	require.Error(t, err)
+	assert.True(t, IsMediaTypeSkip(err))
+
+	_, err = remote.Head(destRef)
+	assert.Error(t, err, "nothing should be pushed to the destination")
+}
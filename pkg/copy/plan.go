@@ -0,0 +1,239 @@
+package copy
+
+import (
+	"context"
+	"sync"
+
+	"freightliner/pkg/helper/errors"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// PlanAction says what executing a plan item does
+type PlanAction string
+
+const (
+	// PlanCopy items are copied when the plan is executed
+	PlanCopy PlanAction = "copy"
+	// PlanSkip items are left alone, e.g. because the destination already
+	// has the source digest
+	PlanSkip PlanAction = "skip"
+)
+
+// PlanItem is one image of a Plan. Plans are JSON so they can be reviewed and
+// approved before execution; remote options are not serialized and fall back
+// to ExecuteOptions when missing.
+type PlanItem struct {
+	// Source and Destination are the image references copied from and to
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+
+	// SourceRepository, DestRepository and Tag are kept for filtering
+	SourceRepository string `json:"source_repository,omitempty"`
+	DestRepository   string `json:"dest_repository,omitempty"`
+	Tag              string `json:"tag,omitempty"`
+
+	// SourceDigest and DestDigest are the digests found while planning, empty
+	// when not looked up or when the destination does not exist
+	SourceDigest string `json:"source_digest,omitempty"`
+	DestDigest   string `json:"dest_digest,omitempty"`
+
+	Action PlanAction `json:"action"`
+	Reason string     `json:"reason,omitempty"`
+
+	// Annotations are set on the pushed manifest, e.g. the source repository
+	// of a destination whose name was truncated
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// SourceOptions and DestOptions reach the source and destination registries
+	SourceOptions []remote.Option `json:"-"`
+	DestOptions   []remote.Option `json:"-"`
+}
+
+// Plan is the list of images a run would copy, built before anything is copied
+// so callers can filter or edit it and execute only what they select
+type Plan struct {
+	Items []PlanItem `json:"items"`
+}
+
+// Add appends items to the plan
+func (p *Plan) Add(items ...PlanItem) {
+	p.Items = append(p.Items, items...)
+}
+
+// Filter returns a plan of the items keep accepts
+func (p *Plan) Filter(keep func(PlanItem) bool) *Plan {
+	filtered := &Plan{Items: make([]PlanItem, 0, len(p.Items))}
+	for _, item := range p.Items {
+		if keep(item) {
+			filtered.Items = append(filtered.Items, item)
+		}
+	}
+	return filtered
+}
+
+// Copies returns the items that executing the plan copies
+func (p *Plan) Copies() []PlanItem {
+	return p.Filter(func(item PlanItem) bool { return item.Action == PlanCopy }).Items
+}
+
+// PlanImage plans the copy of sourceRef to destRef without copying anything.
+// It looks up both digests; an existing destination is skipped unless
+// forceOverwrite is set, and one with the source digest is always skipped.
+func (c *Copier) PlanImage(
+	ctx context.Context,
+	sourceRef name.Reference,
+	destRef name.Reference,
+	srcOpts []remote.Option,
+	destOpts []remote.Option,
+	forceOverwrite bool,
+) (PlanItem, error) {
+	item := PlanItem{
+		Source:        sourceRef.String(),
+		Destination:   destRef.String(),
+		Action:        PlanCopy,
+		SourceOptions: srcOpts,
+		DestOptions:   destOpts,
+	}
+
+	srcDesc, err := remote.Head(sourceRef, append(c.withRetryTransport(srcOpts), remote.WithContext(ctx))...)
+	if err != nil {
+		return item, errors.Wrap(err, "failed to look up source digest")
+	}
+	item.SourceDigest = srcDesc.Digest.String()
+
+	destDesc, err := remote.Head(destRef, append(c.withRetryTransport(destOpts), remote.WithContext(ctx))...)
+	if err != nil {
+		if isNotFound(err) {
+			return item, nil
+		}
+		return item, errors.Wrap(err, "failed to look up destination digest")
+	}
+	item.DestDigest = destDesc.Digest.String()
+
+	switch {
+	case item.DestDigest == item.SourceDigest:
+		item.Action = PlanSkip
+		item.Reason = "destination has the source digest"
+	case !forceOverwrite:
+		item.Action = PlanSkip
+		item.Reason = "destination exists"
+	}
+	return item, nil
+}
+
+// ExecuteOptions configures ExecutePlan
+type ExecuteOptions struct {
+	// CopyOptions applies to every copy; Source and Destination are set per item
+	CopyOptions CopyOptions
+
+	// SourceOptions and DestOptions are used for items planned without them,
+	// e.g. plans read back from JSON
+	SourceOptions []remote.Option
+	DestOptions   []remote.Option
+
+	// Concurrency is the number of items copied at once (default 1)
+	Concurrency int
+}
+
+// PlanItemResult is the outcome of one executed plan item
+type PlanItemResult struct {
+	Item   PlanItem
+	Result *CopyResult
+	Err    error
+}
+
+// PlanResult is the outcome of ExecutePlan, in plan order
+type PlanResult struct {
+	Items   []PlanItemResult
+	Copied  int
+	Skipped int
+	Failed  int
+}
+
+// ExecutePlan copies the plan's PlanCopy items and skips the rest. A failed
+// item does not stop the others; the context does.
+func (c *Copier) ExecutePlan(ctx context.Context, plan *Plan, options ExecuteOptions) *PlanResult {
+	result := &PlanResult{Items: make([]PlanItemResult, len(plan.Items))}
+
+	concurrency := options.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	slots := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+
+	for i, item := range plan.Items {
+		result.Items[i].Item = item
+		if item.Action != PlanCopy {
+			continue
+		}
+
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			result.Items[i].Err = ctx.Err()
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, item PlanItem) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			result.Items[i].Result, result.Items[i].Err = c.executeItem(ctx, item, options)
+		}(i, item)
+	}
+	wg.Wait()
+
+	for _, itemResult := range result.Items {
+		switch {
+		case itemResult.Err != nil:
+			result.Failed++
+		case itemResult.Item.Action != PlanCopy:
+			result.Skipped++
+		default:
+			result.Copied++
+		}
+	}
+	return result
+}
+
+// executeItem copies one plan item
+func (c *Copier) executeItem(ctx context.Context, item PlanItem, options ExecuteOptions) (*CopyResult, error) {
+	sourceRef, err := name.ParseReference(item.Source)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid source reference")
+	}
+	destRef, err := name.ParseReference(item.Destination)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid destination reference")
+	}
+
+	srcOpts := item.SourceOptions
+	if srcOpts == nil {
+		srcOpts = options.SourceOptions
+	}
+	destOpts := item.DestOptions
+	if destOpts == nil {
+		destOpts = options.DestOptions
+	}
+
+	// An approved item whose destination existed at planning time replaces it
+	copyOptions := options.CopyOptions
+	copyOptions.Source = sourceRef
+	copyOptions.Destination = destRef
+	copyOptions.ForceOverwrite = copyOptions.ForceOverwrite || item.DestDigest != ""
+	if len(item.Annotations) > 0 {
+		copyOptions.Annotations = item.Annotations
+	}
+
+	result, err := c.CopyImage(ctx, sourceRef, destRef, srcOpts, destOpts, copyOptions)
+	if err != nil {
+		return result, err
+	}
+	if !result.Success {
+		return result, errors.InvalidInputf("image copy reported failure for %s", item.Source)
+	}
+	return result, nil
+}
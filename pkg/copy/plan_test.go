@@ -0,0 +1,76 @@
+package copy
+
+import (
+	"context"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"freightliner/pkg/helper/log"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlanAndExecute(t *testing.T) {
+	server := httptest.NewServer(registry.New())
+	defer server.Close()
+	serverURL, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	host := serverURL.Host
+
+	img, err := random.Image(256, 1)
+	require.NoError(t, err)
+	other, err := random.Image(256, 1)
+	require.NoError(t, err)
+
+	ref := func(s string) name.Reference {
+		r, err := name.ParseReference(host + "/" + s)
+		require.NoError(t, err)
+		return r
+	}
+	for _, tag := range []string{"src:v1", "src:v2", "src:v3", "dest:v2"} {
+		require.NoError(t, remote.Write(ref(tag), img))
+	}
+	require.NoError(t, remote.Write(ref("dest:v3"), other))
+
+	copier := NewCopier(log.NewBasicLogger(log.ErrorLevel), CopierOptions{})
+	ctx := context.Background()
+
+	plan := &Plan{}
+	for _, tag := range []string{"v1", "v2", "v3"} {
+		item, err := copier.PlanImage(ctx, ref("src:"+tag), ref("dest:"+tag), nil, nil, false)
+		require.NoError(t, err)
+		item.Tag = tag
+		plan.Add(item)
+	}
+
+	require.Len(t, plan.Items, 3)
+	assert.Equal(t, PlanCopy, plan.Items[0].Action)
+	assert.Empty(t, plan.Items[0].DestDigest)
+	assert.Equal(t, PlanSkip, plan.Items[1].Action)
+	assert.Equal(t, "destination has the source digest", plan.Items[1].Reason)
+	assert.Equal(t, PlanSkip, plan.Items[2].Action)
+	assert.Equal(t, "destination exists", plan.Items[2].Reason)
+
+	// Approve the changed tag as well, then execute only approved items
+	plan.Items[2].Action = PlanCopy
+	approved := plan.Filter(func(item PlanItem) bool { return item.Tag != "v2" })
+	require.Len(t, approved.Copies(), 2)
+
+	result := copier.ExecutePlan(ctx, approved, ExecuteOptions{Concurrency: 2})
+	assert.Equal(t, 2, result.Copied)
+	assert.Equal(t, 0, result.Failed)
+
+	imgDigest, err := img.Digest()
+	require.NoError(t, err)
+	for _, tag := range []string{"dest:v1", "dest:v3"} {
+		desc, err := remote.Head(ref(tag))
+		require.NoError(t, err)
+		assert.Equal(t, imgDigest, desc.Digest, tag)
+	}
+}
@@ -0,0 +1,380 @@
+package copy
+
+import (
+	"context"
+	"net/http"
+
+	"freightliner/pkg/helper/errors"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// ReferrersScheme selects how copied referrers are stored at the destination
+type ReferrersScheme string
+
+const (
+	// ReferrersAuto keeps each artifact in its source scheme and adds the
+	// other scheme where the destination would otherwise hide it from cosign
+	// or from referrers API clients
+	ReferrersAuto ReferrersScheme = "auto"
+
+	// ReferrersOCI stores every artifact as an OCI 1.1 referrer
+	ReferrersOCI ReferrersScheme = "oci"
+
+	// ReferrersTags stores signatures, attestations and SBOMs under cosign's
+	// sha256-<hex>.sig, .att and .sbom tags. Other referrers stay OCI referrers.
+	ReferrersTags ReferrersScheme = "tags"
+)
+
+// cosignTagSuffixes are the cosign tag scheme suffixes in the order they are copied
+var cosignTagSuffixes = []string{"sig", "att", "sbom"}
+
+// cosignArtifactTypes maps cosign tag suffixes to the artifact types cosign
+// gives the same artifacts when it stores them as OCI referrers
+var cosignArtifactTypes = map[string]string{
+	"sig":  "application/vnd.dev.cosign.artifact.sig.v1+json",
+	"att":  "application/vnd.dev.cosign.artifact.att.v1+json",
+	"sbom": "application/vnd.dev.cosign.artifact.sbom.v1+json",
+}
+
+// ReferrersResult reports what CopyReferrers pushed
+type ReferrersResult struct {
+	// Referrers and Tags count artifacts pushed as OCI referrers and under
+	// cosign tags. An artifact stored in both schemes counts in both.
+	Referrers int
+	Tags      int
+
+	// Converted counts artifacts pushed in a scheme they did not use at the source
+	Converted int
+
+	// ReferrersAPI reports whether the destination serves the referrers API.
+	// It is only known once a referrer has been pushed.
+	ReferrersAPI *bool
+}
+
+// CopyReferrers copies the referrers of the image at sourceRef to the image at
+// destRef: OCI 1.1 referrers (listed through the referrers API or its
+// sha256-<hex> fallback tag) and artifacts under cosign's tag scheme. The
+// scheme decides how each is stored at the destination. Referrers are only
+// copied when both images have the same digest, since they would not match a
+// mutated destination image.
+func (c *Copier) CopyReferrers(
+	ctx context.Context,
+	sourceRef name.Reference,
+	destRef name.Reference,
+	srcOpts []remote.Option,
+	destOpts []remote.Option,
+	scheme ReferrersScheme,
+) (*ReferrersResult, error) {
+	switch scheme {
+	case "":
+		scheme = ReferrersAuto
+	case ReferrersAuto, ReferrersOCI, ReferrersTags:
+	default:
+		return nil, errors.InvalidInputf("unsupported referrers scheme: %s", scheme)
+	}
+
+	srcOpts = append(c.withRetryTransport(srcOpts), remote.WithContext(ctx))
+	destOpts = append(c.withRetryTransport(destOpts), remote.WithContext(ctx))
+
+	source, err := remote.Head(sourceRef, srcOpts...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to resolve source image %s", sourceRef.String())
+	}
+	subject, err := remote.Head(destRef, destOpts...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to resolve destination image %s", destRef.String())
+	}
+
+	result := &ReferrersResult{}
+	if source.Digest != subject.Digest {
+		c.logger.WithFields(map[string]interface{}{
+			"source":             sourceRef.String(),
+			"source_digest":      source.Digest.String(),
+			"destination_digest": subject.Digest.String(),
+		}).Debug("Destination digest differs from source, referrers not copied")
+		return result, nil
+	}
+
+	rc := &referrerCopy{
+		copier:   c,
+		srcRepo:  sourceRef.Context(),
+		destRepo: destRef.Context(),
+		subject:  *subject,
+		srcOpts:  srcOpts,
+		destOpts: destOpts,
+		scheme:   scheme,
+		result:   result,
+	}
+
+	index, err := remote.Referrers(rc.srcRepo.Digest(source.Digest.String()), srcOpts...)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list source referrers")
+	}
+	manifest, err := index.IndexManifest()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read source referrers")
+	}
+	for _, desc := range manifest.Manifests {
+		if err := rc.copyReferrer(desc); err != nil {
+			return result, errors.Wrapf(err, "failed to copy referrer %s", desc.Digest)
+		}
+	}
+
+	for _, suffix := range cosignTagSuffixes {
+		if err := rc.copyCosignTag(suffix); err != nil {
+			return result, errors.Wrapf(err, "failed to copy cosign %s artifact", suffix)
+		}
+	}
+
+	return result, nil
+}
+
+// referrerCopy holds the state of one CopyReferrers call
+type referrerCopy struct {
+	copier   *Copier
+	srcRepo  name.Repository
+	destRepo name.Repository
+	subject  v1.Descriptor
+	srcOpts  []remote.Option
+	destOpts []remote.Option
+	scheme   ReferrersScheme
+	result   *ReferrersResult
+}
+
+// copyReferrer copies an OCI referrer of the source image
+func (rc *referrerCopy) copyReferrer(desc v1.Descriptor) error {
+	src := rc.srcRepo.Digest(desc.Digest.String())
+	dest := rc.destRepo.Digest(desc.Digest.String())
+
+	if desc.MediaType.IsIndex() {
+		idx, err := remote.Index(src, rc.srcOpts...)
+		if err != nil {
+			return errors.Wrap(err, "failed to read referrer")
+		}
+		if err := remote.WriteIndex(dest, idx, rc.destOpts...); err != nil {
+			return errors.Wrap(err, "failed to push referrer")
+		}
+		rc.result.Referrers++
+		return nil
+	}
+
+	img, err := remote.Image(src, rc.srcOpts...)
+	if err != nil {
+		return errors.Wrap(err, "failed to read referrer")
+	}
+
+	suffix := cosignSuffix(desc.ArtifactType)
+	if suffix == "" || rc.scheme != ReferrersTags {
+		if err := rc.pushReferrer(dest, img); err != nil {
+			return err
+		}
+	}
+
+	// cosign looks signatures up under tags unless told to use referrers, so
+	// they also go under a tag when the destination only has the fallback index
+	if suffix != "" && (rc.scheme == ReferrersTags || !rc.referrersAPI()) {
+		pushed, err := rc.pushCosignTag(suffix, img)
+		if err != nil {
+			return err
+		}
+		if pushed {
+			rc.result.Converted++
+		}
+	}
+	return nil
+}
+
+// copyCosignTag copies the artifact under the source image's cosign tag for
+// suffix, if there is one
+func (rc *referrerCopy) copyCosignTag(suffix string) error {
+	img, err := remote.Image(cosignTag(rc.srcRepo, rc.subject.Digest, suffix), rc.srcOpts...)
+	if err != nil {
+		if isNotFound(err) {
+			return nil
+		}
+		return errors.Wrap(err, "failed to read cosign artifact")
+	}
+
+	if rc.scheme != ReferrersOCI {
+		if _, err := rc.pushCosignTag(suffix, img); err != nil {
+			return err
+		}
+	}
+
+	// Registries without the referrers API would only list the converted
+	// artifact in the fallback index, which cosign reads no better than the tag
+	if rc.scheme == ReferrersTags || (rc.scheme == ReferrersAuto && rc.result.ReferrersAPI != nil && !*rc.result.ReferrersAPI) {
+		return nil
+	}
+
+	referrer, err := cosignReferrer(img, cosignArtifactTypes[suffix], rc.subject)
+	if err != nil {
+		return err
+	}
+	digest, err := referrer.Digest()
+	if err != nil {
+		return errors.Wrap(err, "failed to compute referrer digest")
+	}
+	if err := rc.pushReferrer(rc.destRepo.Digest(digest.String()), referrer); err != nil {
+		return err
+	}
+	rc.result.Converted++
+	return nil
+}
+
+// pushReferrer pushes img, which has the destination image as its subject.
+// go-containerregistry updates the sha256-<hex> fallback index when the
+// registry does not take the subject, so the referrer stays listed either way.
+func (rc *referrerCopy) pushReferrer(ref name.Digest, img v1.Image) error {
+	if err := remote.Write(ref, img, rc.destOpts...); err != nil {
+		return errors.Wrap(err, "failed to push referrer")
+	}
+	rc.result.Referrers++
+
+	if rc.result.ReferrersAPI == nil {
+		supported := rc.detectReferrersAPI()
+		rc.result.ReferrersAPI = &supported
+		rc.copier.logger.WithFields(map[string]interface{}{
+			"repository":    rc.destRepo.String(),
+			"referrers_api": supported,
+		}).Debug("Detected destination referrers support")
+	}
+	return nil
+}
+
+// referrersAPI reports whether the destination is known to serve the
+// referrers API, assuming it does until a push shows otherwise
+func (rc *referrerCopy) referrersAPI() bool {
+	return rc.result.ReferrersAPI == nil || *rc.result.ReferrersAPI
+}
+
+// detectReferrersAPI runs after a referrer was pushed. Registries that take
+// the subject of a pushed manifest serve the referrers API; for the others
+// go-containerregistry keeps the fallback index tag, so its presence means
+// the API is missing.
+func (rc *referrerCopy) detectReferrersAPI() bool {
+	_, err := remote.Head(rc.destRepo.Tag(digestTag(rc.subject.Digest)), rc.destOpts...)
+	return err != nil
+}
+
+// pushCosignTag adds img's layers to the destination's cosign tag for
+// suffix and reports whether the tag changed. Layers already under the tag are
+// kept, so other signers are not dropped.
+func (rc *referrerCopy) pushCosignTag(suffix string, img v1.Image) (bool, error) {
+	tag := cosignTag(rc.destRepo, rc.subject.Digest, suffix)
+
+	existing, err := remote.Image(tag, rc.destOpts...)
+	switch {
+	case err == nil:
+		merged, changed, err := appendMissingLayers(existing, img)
+		if err != nil {
+			return false, err
+		}
+		if !changed {
+			return false, nil
+		}
+		img = merged
+	case !isNotFound(err):
+		return false, errors.Wrapf(err, "failed to read %s", tag.String())
+	}
+
+	if err := remote.Write(tag, img, rc.destOpts...); err != nil {
+		return false, errors.Wrapf(err, "failed to push %s", tag.String())
+	}
+	rc.result.Tags++
+	return true, nil
+}
+
+// cosignReferrer turns a cosign tag scheme artifact into an OCI referrer of
+// subject with the artifact type cosign uses for it
+func cosignReferrer(img v1.Image, artifactType string, subject v1.Descriptor) (v1.Image, error) {
+	img = mutate.MediaType(img, types.OCIManifestSchema1)
+	img = mutate.ConfigMediaType(img, types.MediaType(artifactType))
+	referrer, ok := mutate.Subject(img, subject).(v1.Image)
+	if !ok {
+		return nil, errors.Internalf("failed to set referrer subject")
+	}
+	return referrer, nil
+}
+
+// appendMissingLayers appends the layers of img that base lacks, keeping
+// their annotations, and reports whether any were appended. Signers of the
+// same payload share a layer digest, so layers also differ by signature.
+func appendMissingLayers(base, img v1.Image) (v1.Image, bool, error) {
+	baseManifest, err := base.Manifest()
+	if err != nil {
+		return nil, false, errors.Wrap(err, "failed to read existing manifest")
+	}
+	have := make(map[string]bool, len(baseManifest.Layers))
+	for _, layer := range baseManifest.Layers {
+		have[layerKey(layer)] = true
+	}
+
+	manifest, err := img.Manifest()
+	if err != nil {
+		return nil, false, errors.Wrap(err, "failed to read manifest")
+	}
+	layers, err := img.Layers()
+	if err != nil {
+		return nil, false, errors.Wrap(err, "failed to read layers")
+	}
+
+	changed := false
+	for i, layer := range layers {
+		desc := manifest.Layers[i]
+		if have[layerKey(desc)] {
+			continue
+		}
+		base, err = mutate.Append(base, mutate.Addendum{
+			Layer:       layer,
+			Annotations: desc.Annotations,
+			MediaType:   desc.MediaType,
+		})
+		if err != nil {
+			return nil, false, errors.Wrap(err, "failed to append layer")
+		}
+		have[layerKey(desc)] = true
+		changed = true
+	}
+	return base, changed, nil
+}
+
+// layerKey identifies a cosign layer by its digest and signature
+func layerKey(desc v1.Descriptor) string {
+	return desc.Digest.String() + " " + desc.Annotations[cosignSignatureAnnotation]
+}
+
+// cosignSuffix returns the cosign tag suffix for an artifact type, or "" if
+// cosign does not store that type under a tag
+func cosignSuffix(artifactType string) string {
+	for suffix, t := range cosignArtifactTypes {
+		if t == artifactType {
+			return suffix
+		}
+	}
+	return ""
+}
+
+// cosignTag returns cosign's tag for the artifact of digest with suffix, e.g.
+// sha256-<hex>.sig
+func cosignTag(repo name.Repository, digest v1.Hash, suffix string) name.Tag {
+	return repo.Tag(digestTag(digest) + "." + suffix)
+}
+
+// digestTag returns digest as a tag, which is also the referrers fallback tag
+func digestTag(digest v1.Hash) string {
+	return digest.Algorithm + "-" + digest.Hex
+}
+
+// isNotFound reports whether a registry request failed because the manifest
+// does not exist
+func isNotFound(err error) bool {
+	var terr *transport.Error
+	return errors.As(err, &terr) && terr.StatusCode == http.StatusNotFound
+}
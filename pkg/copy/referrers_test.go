@@ -0,0 +1,164 @@
+package copy
+
+import (
+	"context"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"freightliner/pkg/helper/log"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// referrersTestImage pushes the same random image to a source and a
+// destination registry and returns both tags and the image descriptor
+func referrersTestImage(t *testing.T, sourceAPI, destAPI bool) (name.Tag, name.Tag, v1.Descriptor) {
+	t.Helper()
+
+	src := httptest.NewServer(registry.New(registry.WithReferrersSupport(sourceAPI)))
+	t.Cleanup(src.Close)
+	dest := httptest.NewServer(registry.New(registry.WithReferrersSupport(destAPI)))
+	t.Cleanup(dest.Close)
+
+	srcURL, err := url.Parse(src.URL)
+	require.NoError(t, err)
+	destURL, err := url.Parse(dest.URL)
+	require.NoError(t, err)
+
+	img, err := random.Image(256, 1)
+	require.NoError(t, err)
+	srcRef, err := name.NewTag(srcURL.Host + "/team/app:v1")
+	require.NoError(t, err)
+	destRef, err := name.NewTag(destURL.Host + "/team/app:v1")
+	require.NoError(t, err)
+	require.NoError(t, remote.Write(srcRef, img))
+	require.NoError(t, remote.Write(destRef, img))
+
+	desc, err := remote.Head(srcRef)
+	require.NoError(t, err)
+	return srcRef, destRef, *desc
+}
+
+// cosignSignatureImage builds a cosign signature image with one signature
+func cosignSignatureImage(t *testing.T, signature string) v1.Image {
+	t.Helper()
+	img, err := mutate.Append(empty.Image, mutate.Addendum{
+		Layer:       static.NewLayer([]byte(`{"critical":{}}`), simpleSigningMediaType),
+		Annotations: map[string]string{cosignSignatureAnnotation: signature},
+	})
+	require.NoError(t, err)
+	return mutate.MediaType(img, types.OCIManifestSchema1)
+}
+
+func listReferrers(t *testing.T, ref name.Tag, digest v1.Hash) []v1.Descriptor {
+	t.Helper()
+	index, err := remote.Referrers(ref.Context().Digest(digest.String()))
+	require.NoError(t, err)
+	manifest, err := index.IndexManifest()
+	require.NoError(t, err)
+	return manifest.Manifests
+}
+
+func TestCopyReferrers_CosignTagToReferrersAPI(t *testing.T) {
+	srcRef, destRef, desc := referrersTestImage(t, false, true)
+
+	sigTag := cosignTag(srcRef.Context(), desc.Digest, "sig")
+	require.NoError(t, remote.Write(sigTag, cosignSignatureImage(t, "c2ln")))
+
+	copier := NewCopier(log.NewBasicLogger(log.ErrorLevel), CopierOptions{})
+	result, err := copier.CopyReferrers(context.Background(), srcRef, destRef, nil, nil, ReferrersAuto)
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, result.Tags)
+	assert.Equal(t, 1, result.Referrers)
+	assert.Equal(t, 1, result.Converted)
+	require.NotNil(t, result.ReferrersAPI)
+	assert.True(t, *result.ReferrersAPI)
+
+	// The signature is still found by cosign's tag lookup...
+	_, err = remote.Head(cosignTag(destRef.Context(), desc.Digest, "sig"))
+	require.NoError(t, err)
+
+	// ...and by referrers API clients
+	referrers := listReferrers(t, destRef, desc.Digest)
+	require.Len(t, referrers, 1)
+	assert.Equal(t, cosignArtifactTypes["sig"], referrers[0].ArtifactType)
+}
+
+func TestCopyReferrers_ReferrerToCosignTag(t *testing.T) {
+	srcRef, destRef, desc := referrersTestImage(t, true, false)
+
+	sig, err := cosignReferrer(cosignSignatureImage(t, "c2ln"), cosignArtifactTypes["sig"], desc)
+	require.NoError(t, err)
+	sigDigest, err := sig.Digest()
+	require.NoError(t, err)
+	require.NoError(t, remote.Write(srcRef.Context().Digest(sigDigest.String()), sig))
+
+	// Another signer already signed the destination under the cosign tag
+	destSig := cosignTag(destRef.Context(), desc.Digest, "sig")
+	require.NoError(t, remote.Write(destSig, cosignSignatureImage(t, "b3RoZXI=")))
+
+	copier := NewCopier(log.NewBasicLogger(log.ErrorLevel), CopierOptions{})
+	result, err := copier.CopyReferrers(context.Background(), srcRef, destRef, nil, nil, ReferrersAuto)
+	require.NoError(t, err)
+
+	require.NotNil(t, result.ReferrersAPI)
+	assert.False(t, *result.ReferrersAPI)
+	assert.Equal(t, 1, result.Referrers)
+	assert.Equal(t, 1, result.Tags)
+	assert.Equal(t, 1, result.Converted)
+
+	// The referrer is listed through the fallback index
+	referrers := listReferrers(t, destRef, desc.Digest)
+	require.Len(t, referrers, 1)
+	assert.Equal(t, sigDigest, referrers[0].Digest)
+
+	// and both signatures are under the cosign tag
+	merged, err := remote.Image(destSig)
+	require.NoError(t, err)
+	manifest, err := merged.Manifest()
+	require.NoError(t, err)
+	require.Len(t, manifest.Layers, 2)
+	assert.Equal(t, "b3RoZXI=", manifest.Layers[0].Annotations[cosignSignatureAnnotation])
+	assert.Equal(t, "c2ln", manifest.Layers[1].Annotations[cosignSignatureAnnotation])
+
+	// Copying again changes nothing under the tag
+	again, err := copier.CopyReferrers(context.Background(), srcRef, destRef, nil, nil, ReferrersAuto)
+	require.NoError(t, err)
+	assert.Equal(t, 0, again.Tags)
+}
+
+func TestCopyReferrers_OCISchemeSkipsTags(t *testing.T) {
+	srcRef, destRef, desc := referrersTestImage(t, false, true)
+	require.NoError(t, remote.Write(cosignTag(srcRef.Context(), desc.Digest, "sig"), cosignSignatureImage(t, "c2ln")))
+
+	copier := NewCopier(log.NewBasicLogger(log.ErrorLevel), CopierOptions{})
+	result, err := copier.CopyReferrers(context.Background(), srcRef, destRef, nil, nil, ReferrersOCI)
+	require.NoError(t, err)
+
+	assert.Equal(t, 0, result.Tags)
+	assert.Equal(t, 1, result.Referrers)
+	_, err = remote.Head(cosignTag(destRef.Context(), desc.Digest, "sig"))
+	assert.Error(t, err)
+}
+
+func TestCopyReferrers_InvalidScheme(t *testing.T) {
+	copier := NewCopier(log.NewBasicLogger(log.ErrorLevel), CopierOptions{})
+	ref, err := name.NewTag("registry.example.com/team/app:v1")
+	require.NoError(t, err)
+
+	_, err = copier.CopyReferrers(context.Background(), ref, ref, nil, nil, "notation")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unsupported referrers scheme")
+}
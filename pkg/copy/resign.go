@@ -0,0 +1,140 @@
+package copy
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+
+	"freightliner/pkg/helper/errors"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// SourceDigestAnnotation links a re-signed destination manifest to the digest
+// it was copied from
+const SourceDigestAnnotation = "vnd.freightliner.source-digest"
+
+const (
+	// simpleSigningMediaType is the media type of cosign signature payloads
+	simpleSigningMediaType = "application/vnd.dev.cosign.simplesigning.v1+json"
+
+	// cosignSignatureAnnotation holds the base64 signature of a payload layer
+	cosignSignatureAnnotation = "dev.cosignproject.cosign/signature"
+)
+
+// simpleSigning is the cosign "simple signing" payload
+type simpleSigning struct {
+	Critical simpleSigningCritical `json:"critical"`
+	Optional map[string]string     `json:"optional,omitempty"`
+}
+
+type simpleSigningCritical struct {
+	Identity struct {
+		DockerReference string `json:"docker-reference"`
+	} `json:"identity"`
+	Image struct {
+		DockerManifestDigest string `json:"docker-manifest-digest"`
+	} `json:"image"`
+	Type string `json:"type"`
+}
+
+// Resign pushes a cosign-compatible signature for the destination manifest
+// destDigest and records sourceDigest in its payload and annotations. Signatures
+// already stored for destDigest are kept. It returns the signature tag.
+func (c *Copier) Resign(
+	ctx context.Context,
+	destRef name.Reference,
+	sourceDigest, destDigest v1.Hash,
+	destOpts []remote.Option,
+) (name.Tag, error) {
+	if c.resigner == nil {
+		return name.Tag{}, errors.InvalidInputf("no re-signing key configured")
+	}
+
+	opts := append(c.withRetryTransport(destOpts), remote.WithContext(ctx))
+	sigTag := destRef.Context().Tag(strings.Replace(destDigest.String(), ":", "-", 1) + ".sig")
+
+	payload, err := resignPayload(destRef.Context(), sourceDigest, destDigest)
+	if err != nil {
+		return name.Tag{}, err
+	}
+	signature, err := signPayload(c.resigner, payload)
+	if err != nil {
+		return name.Tag{}, err
+	}
+
+	// Append to an existing signature image so other signers are not dropped
+	base := empty.Image
+	if existing, err := remote.Image(sigTag, opts...); err == nil {
+		base = existing
+	}
+
+	sigImage, err := mutate.Append(base, mutate.Addendum{
+		Layer: static.NewLayer(payload, simpleSigningMediaType),
+		Annotations: map[string]string{
+			cosignSignatureAnnotation: base64.StdEncoding.EncodeToString(signature),
+			SourceDigestAnnotation:    sourceDigest.String(),
+		},
+	})
+	if err != nil {
+		return name.Tag{}, errors.Wrap(err, "failed to build signature image")
+	}
+	sigImage = mutate.MediaType(sigImage, types.OCIManifestSchema1)
+	sigImage = mutate.ConfigMediaType(sigImage, types.OCIConfigJSON)
+
+	if err := remote.Write(sigTag, sigImage, opts...); err != nil {
+		return name.Tag{}, errors.Wrap(err, "failed to push signature")
+	}
+
+	c.logger.WithFields(map[string]interface{}{
+		"destination":   destRef.String(),
+		"source_digest": sourceDigest.String(),
+		"digest":        destDigest.String(),
+		"signature":     sigTag.String(),
+	}).Info("Re-signed mutated image at destination")
+
+	return sigTag, nil
+}
+
+// resignPayload builds the simple signing payload for digest in repo
+func resignPayload(repo name.Repository, sourceDigest, digest v1.Hash) ([]byte, error) {
+	var payload simpleSigning
+	payload.Critical.Identity.DockerReference = repo.String()
+	payload.Critical.Image.DockerManifestDigest = digest.String()
+	payload.Critical.Type = "cosign container image signature"
+	payload.Optional = map[string]string{SourceDigestAnnotation: sourceDigest.String()}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to encode signature payload")
+	}
+	return data, nil
+}
+
+// signPayload signs payload the way cosign verifies it: ECDSA and RSA keys
+// sign its SHA-256, Ed25519 signs it directly
+func signPayload(signer crypto.Signer, payload []byte) ([]byte, error) {
+	var sig []byte
+	var err error
+	if _, ok := signer.Public().(ed25519.PublicKey); ok {
+		sig, err = signer.Sign(rand.Reader, payload, crypto.Hash(0))
+	} else {
+		digest := sha256.Sum256(payload)
+		sig, err = signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to sign payload")
+	}
+	return sig, nil
+}
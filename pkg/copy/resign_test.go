@@ -0,0 +1,125 @@
+package copy
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"freightliner/pkg/helper/log"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCopyImage_ResignsMutatedImage(t *testing.T) {
+	source := httptest.NewServer(registry.New())
+	defer source.Close()
+	dest := httptest.NewServer(registry.New())
+	defer dest.Close()
+
+	srcURL, err := url.Parse(source.URL)
+	require.NoError(t, err)
+	destURL, err := url.Parse(dest.URL)
+	require.NoError(t, err)
+
+	// Copying a multi-arch index pushes only the platform image, so the
+	// destination digest differs from the source digest
+	img, err := random.Image(512, 1)
+	require.NoError(t, err)
+	index := mutate.AppendManifests(empty.Index, mutate.IndexAddendum{
+		Add:        img,
+		Descriptor: v1.Descriptor{Platform: &v1.Platform{OS: "linux", Architecture: "amd64"}},
+	})
+	srcRef, err := name.NewTag(srcURL.Host + "/team/app:v1")
+	require.NoError(t, err)
+	require.NoError(t, remote.WriteIndex(srcRef, index))
+	destRef, err := name.NewTag(destURL.Host + "/team/app:v1")
+	require.NoError(t, err)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	copier := NewCopier(log.NewBasicLogger(log.ErrorLevel), CopierOptions{Resigner: key})
+
+	_, err = copier.CopyImage(context.Background(), srcRef, destRef, nil, nil, CopyOptions{})
+	require.NoError(t, err)
+
+	sourceDigest, err := index.Digest()
+	require.NoError(t, err)
+	destDigest, err := img.Digest()
+	require.NoError(t, err)
+
+	sigTag := destRef.Context().Tag(strings.Replace(destDigest.String(), ":", "-", 1) + ".sig")
+	sigImage, err := remote.Image(sigTag)
+	require.NoError(t, err)
+
+	manifest, err := sigImage.Manifest()
+	require.NoError(t, err)
+	require.Len(t, manifest.Layers, 1)
+	annotations := manifest.Layers[0].Annotations
+	assert.Equal(t, sourceDigest.String(), annotations[SourceDigestAnnotation])
+
+	layers, err := sigImage.Layers()
+	require.NoError(t, err)
+	rc, err := layers[0].Uncompressed()
+	require.NoError(t, err)
+	payload, err := io.ReadAll(rc)
+	require.NoError(t, err)
+	require.NoError(t, rc.Close())
+
+	var signed simpleSigning
+	require.NoError(t, json.Unmarshal(payload, &signed))
+	assert.Equal(t, destDigest.String(), signed.Critical.Image.DockerManifestDigest)
+	assert.Equal(t, sourceDigest.String(), signed.Optional[SourceDigestAnnotation])
+
+	signature, err := base64.StdEncoding.DecodeString(annotations[cosignSignatureAnnotation])
+	require.NoError(t, err)
+	sum := sha256.Sum256(payload)
+	assert.True(t, ecdsa.VerifyASN1(&key.PublicKey, sum[:], signature))
+}
+
+func TestCopyImage_SkipsResignWhenDigestUnchanged(t *testing.T) {
+	source := httptest.NewServer(registry.New())
+	defer source.Close()
+	dest := httptest.NewServer(registry.New())
+	defer dest.Close()
+
+	srcURL, err := url.Parse(source.URL)
+	require.NoError(t, err)
+	destURL, err := url.Parse(dest.URL)
+	require.NoError(t, err)
+
+	img, err := random.Image(512, 1)
+	require.NoError(t, err)
+	srcRef, err := name.NewTag(srcURL.Host + "/team/app:v1")
+	require.NoError(t, err)
+	require.NoError(t, remote.Write(srcRef, img))
+	destRef, err := name.NewTag(destURL.Host + "/team/app:v1")
+	require.NoError(t, err)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	copier := NewCopier(log.NewBasicLogger(log.ErrorLevel), CopierOptions{Resigner: key})
+
+	_, err = copier.CopyImage(context.Background(), srcRef, destRef, nil, nil, CopyOptions{})
+	require.NoError(t, err)
+
+	tags, err := remote.List(destRef.Context())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"v1"}, tags)
+}
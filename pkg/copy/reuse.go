@@ -0,0 +1,131 @@
+package copy
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+// MountSource is a repository that layers were mounted from instead of
+// uploaded
+type MountSource struct {
+	Repository string `json:"repository"`
+	Layers     int    `json:"layers"`
+	Bytes      int64  `json:"bytes"`
+}
+
+// addMountSource adds a mounted layer of size bytes from repository to sources
+func addMountSource(sources []MountSource, repository string, layers int, size int64) []MountSource {
+	for i := range sources {
+		if sources[i].Repository == repository {
+			sources[i].Layers += layers
+			sources[i].Bytes += size
+			return sources
+		}
+	}
+	return append(sources, MountSource{Repository: repository, Layers: layers, Bytes: size})
+}
+
+// ImageLayerReuse describes how the layers of one copied image reached the
+// destination
+type ImageLayerReuse struct {
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+
+	// Layers is split into layers uploaded, mounted from another repository
+	// and skipped because the destination repository already had them
+	Layers   int `json:"layers"`
+	Uploaded int `json:"uploaded"`
+	Mounted  int `json:"mounted"`
+	Skipped  int `json:"skipped"`
+
+	BytesUploaded int64 `json:"bytes_uploaded"`
+	BytesReused   int64 `json:"bytes_reused"`
+
+	MountedFrom []MountSource `json:"mounted_from,omitempty"`
+}
+
+// LayerReuseReport summarizes the layer reuse of a run. MountSources lists
+// the repositories layers were mounted from, most used first; repositories
+// that serve many mounts are good homes for shared base layers.
+type LayerReuseReport struct {
+	Images        []ImageLayerReuse `json:"images"`
+	MountSources  []MountSource     `json:"mount_sources"`
+	Layers        int               `json:"layers"`
+	Uploaded      int               `json:"uploaded"`
+	Mounted       int               `json:"mounted"`
+	Skipped       int               `json:"skipped"`
+	BytesUploaded int64             `json:"bytes_uploaded"`
+	BytesReused   int64             `json:"bytes_reused"`
+}
+
+// LayerReuse collects the layer reuse of every image copied in a run. It is
+// safe for concurrent use by the copiers of every worker in the run; a nil
+// LayerReuse records nothing.
+type LayerReuse struct {
+	mu     sync.Mutex
+	images []ImageLayerReuse
+}
+
+// NewLayerReuse creates an empty layer reuse collector for one run
+func NewLayerReuse() *LayerReuse {
+	return &LayerReuse{}
+}
+
+// Record adds the layer reuse of a copy from source to destination
+func (r *LayerReuse) Record(source, destination name.Reference, stats CopyStats) {
+	if r == nil {
+		return
+	}
+
+	image := ImageLayerReuse{
+		Source:        source.String(),
+		Destination:   destination.String(),
+		Layers:        stats.Layers,
+		Uploaded:      stats.LayersUploaded,
+		Skipped:       stats.LayersSkipped,
+		BytesUploaded: stats.BytesTransferred,
+		BytesReused:   stats.BytesReused,
+		MountedFrom:   append([]MountSource(nil), stats.MountedFrom...),
+	}
+	for _, from := range stats.MountedFrom {
+		image.Mounted += from.Layers
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.images = append(r.images, image)
+}
+
+// Report returns the layer reuse recorded so far, images in copy order
+func (r *LayerReuse) Report() LayerReuseReport {
+	report := LayerReuseReport{Images: []ImageLayerReuse{}, MountSources: []MountSource{}}
+	if r == nil {
+		return report
+	}
+
+	r.mu.Lock()
+	report.Images = append(report.Images, r.images...)
+	r.mu.Unlock()
+
+	for _, image := range report.Images {
+		report.Layers += image.Layers
+		report.Uploaded += image.Uploaded
+		report.Mounted += image.Mounted
+		report.Skipped += image.Skipped
+		report.BytesUploaded += image.BytesUploaded
+		report.BytesReused += image.BytesReused
+		for _, from := range image.MountedFrom {
+			report.MountSources = addMountSource(report.MountSources, from.Repository, from.Layers, from.Bytes)
+		}
+	}
+	sort.SliceStable(report.MountSources, func(i, j int) bool {
+		a, b := report.MountSources[i], report.MountSources[j]
+		if a.Layers != b.Layers {
+			return a.Layers > b.Layers
+		}
+		return a.Repository < b.Repository
+	})
+	return report
+}
@@ -0,0 +1,96 @@
+package copy
+
+import (
+	"context"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"freightliner/pkg/helper/log"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLayerReuseReport(t *testing.T) {
+	source := httptest.NewServer(registry.New())
+	defer source.Close()
+	dest := httptest.NewServer(newMountRegistry(false))
+	defer dest.Close()
+
+	srcURL, err := url.Parse(source.URL)
+	require.NoError(t, err)
+	destURL, err := url.Parse(dest.URL)
+	require.NoError(t, err)
+
+	img, err := random.Image(256, 2)
+	require.NoError(t, err)
+	srcRef, err := name.NewTag(srcURL.Host + "/mono/app:v1")
+	require.NoError(t, err)
+	require.NoError(t, remote.Write(srcRef, img))
+
+	layers, err := img.Layers()
+	require.NoError(t, err)
+	var layerBytes int64
+	for _, layer := range layers {
+		size, err := layer.Size()
+		require.NoError(t, err)
+		layerBytes += size
+	}
+
+	reuse := NewLayerReuse()
+	logger := log.NewBasicLogger(log.ErrorLevel)
+	copyTo := func(ref string, dedup *BlobDedup) {
+		destRef, err := name.NewTag(destURL.Host + "/" + ref)
+		require.NoError(t, err)
+		result, err := NewCopier(logger, CopierOptions{Dedup: dedup, LayerReuse: reuse}).CopyImage(
+			context.Background(), srcRef, destRef, nil, nil,
+			CopyOptions{Source: srcRef, Destination: destRef, ForceOverwrite: true})
+		require.NoError(t, err)
+		require.True(t, result.Success)
+	}
+
+	dedup := NewBlobDedup()
+	copyTo("team-a/app:v1", dedup)
+	copyTo("team-b/app:v1", dedup)
+	// Without the run's dedup record the registry is asked whether it has
+	// the layers
+	copyTo("team-a/app:v2", nil)
+
+	report := reuse.Report()
+	require.Len(t, report.Images, 3)
+
+	first, second, third := report.Images[0], report.Images[1], report.Images[2]
+	assert.Equal(t, 2, first.Uploaded)
+	assert.Equal(t, layerBytes, first.BytesUploaded)
+	assert.Zero(t, first.BytesReused)
+
+	assert.Equal(t, 2, second.Mounted)
+	assert.Equal(t, layerBytes, second.BytesReused)
+	assert.Equal(t, []MountSource{{Repository: destURL.Host + "/team-a/app", Layers: 2, Bytes: layerBytes}}, second.MountedFrom)
+
+	assert.Equal(t, 2, third.Skipped)
+	assert.Zero(t, third.Uploaded)
+
+	assert.Equal(t, 6, report.Layers)
+	assert.Equal(t, 2, report.Uploaded)
+	assert.Equal(t, 2, report.Mounted)
+	assert.Equal(t, 2, report.Skipped)
+	assert.Equal(t, 2*layerBytes, report.BytesReused)
+	assert.Equal(t, second.MountedFrom, report.MountSources)
+}
+
+func TestLayerReuseNil(t *testing.T) {
+	var reuse *LayerReuse
+	ref, err := name.NewTag("registry.example.com/app:v1")
+	require.NoError(t, err)
+
+	reuse.Record(ref, ref, CopyStats{Layers: 1})
+	report := reuse.Report()
+	assert.Empty(t, report.Images)
+	assert.Empty(t, report.MountSources)
+}
@@ -0,0 +1,110 @@
+package copy
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"freightliner/pkg/helper/errors"
+	"freightliner/pkg/interfaces"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// ScanFindingsArtifactType is the artifact type of scan findings referrers
+const ScanFindingsArtifactType = "application/vnd.freightliner.scan-findings.v1+json"
+
+// ScanAnnotationPrefix prefixes the scan summary annotations on findings referrers,
+// e.g. vnd.freightliner.scan.status and vnd.freightliner.scan.severity.critical
+const ScanAnnotationPrefix = "vnd.freightliner.scan."
+
+// AttachScanFindings pushes summary to the destination as an OCI referrer of
+// the image at destRef and returns the referrer's digest. The image manifest is
+// left untouched so its digest still matches the source. The summary is also
+// set as annotations, which the referrers API returns without fetching the
+// artifact, so admission controllers can read it in a single request.
+func (c *Copier) AttachScanFindings(
+	ctx context.Context,
+	destRef name.Reference,
+	summary *interfaces.ScanFindingsSummary,
+	destOpts []remote.Option,
+) (name.Digest, error) {
+	if summary == nil {
+		return name.Digest{}, errors.InvalidInputf("scan findings summary is nil")
+	}
+
+	opts := append(c.withRetryTransport(destOpts), remote.WithContext(ctx))
+
+	subject, err := remote.Head(destRef, opts...)
+	if err != nil {
+		return name.Digest{}, errors.Wrapf(err, "failed to resolve destination image %s", destRef.String())
+	}
+
+	artifact, err := scanFindingsArtifact(summary, *subject)
+	if err != nil {
+		return name.Digest{}, err
+	}
+
+	digest, err := artifact.Digest()
+	if err != nil {
+		return name.Digest{}, errors.Wrap(err, "failed to compute scan findings digest")
+	}
+	ref := destRef.Context().Digest(digest.String())
+
+	if err := remote.Write(ref, artifact, opts...); err != nil {
+		return name.Digest{}, errors.Wrap(err, "failed to push scan findings")
+	}
+
+	c.logger.WithFields(map[string]interface{}{
+		"destination": destRef.String(),
+		"subject":     subject.Digest.String(),
+		"referrer":    digest.String(),
+		"status":      summary.Status,
+	}).Debug("Attached scan findings to destination image")
+
+	return ref, nil
+}
+
+// scanFindingsArtifact builds the referrer manifest for summary. It is
+// deterministic, so replicating an unchanged summary rewrites the same artifact.
+func scanFindingsArtifact(summary *interfaces.ScanFindingsSummary, subject v1.Descriptor) (v1.Image, error) {
+	data, err := json.Marshal(summary)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to encode scan findings")
+	}
+
+	img, err := mutate.Append(empty.Image, mutate.Addendum{
+		Layer: static.NewLayer(data, types.MediaType(ScanFindingsArtifactType)),
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to build scan findings artifact")
+	}
+	img = mutate.MediaType(img, types.OCIManifestSchema1)
+	img = mutate.ConfigMediaType(img, types.MediaType(ScanFindingsArtifactType))
+	img = mutate.Annotations(img, scanAnnotations(summary)).(v1.Image)
+
+	return mutate.Subject(img, subject).(v1.Image), nil
+}
+
+// scanAnnotations flattens summary into referrer annotations
+func scanAnnotations(summary *interfaces.ScanFindingsSummary) map[string]string {
+	annotations := map[string]string{
+		ScanAnnotationPrefix + "scanner": summary.Scanner,
+		ScanAnnotationPrefix + "status":  summary.Status,
+	}
+	for severity, count := range summary.SeverityCounts {
+		annotations[ScanAnnotationPrefix+"severity."+strings.ToLower(severity)] = strconv.Itoa(count)
+	}
+	if !summary.ScanCompletedAt.IsZero() {
+		annotations["org.opencontainers.image.created"] = summary.ScanCompletedAt.UTC().Format(time.RFC3339)
+	}
+	return annotations
+}
@@ -0,0 +1,71 @@
+package copy
+
+import (
+	"context"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"freightliner/pkg/helper/log"
+	"freightliner/pkg/interfaces"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAttachScanFindings(t *testing.T) {
+	dest := httptest.NewServer(registry.New(registry.WithReferrersSupport(true)))
+	defer dest.Close()
+
+	destURL, err := url.Parse(dest.URL)
+	require.NoError(t, err)
+
+	img, err := random.Image(512, 1)
+	require.NoError(t, err)
+	destRef, err := name.NewTag(destURL.Host + "/team/app:v1")
+	require.NoError(t, err)
+	require.NoError(t, remote.Write(destRef, img))
+
+	summary := &interfaces.ScanFindingsSummary{
+		Scanner:         "ecr",
+		Status:          "COMPLETE",
+		SeverityCounts:  map[string]int{"CRITICAL": 2},
+		ScanCompletedAt: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+
+	copier := NewCopier(log.NewBasicLogger(log.ErrorLevel), CopierOptions{})
+	ref, err := copier.AttachScanFindings(context.Background(), destRef, summary, nil)
+	require.NoError(t, err)
+
+	// Attaching the same summary again produces the same artifact
+	again, err := copier.AttachScanFindings(context.Background(), destRef, summary, nil)
+	require.NoError(t, err)
+	assert.Equal(t, ref.DigestStr(), again.DigestStr())
+
+	imgDigest, err := img.Digest()
+	require.NoError(t, err)
+	index, err := remote.Referrers(destRef.Context().Digest(imgDigest.String()))
+	require.NoError(t, err)
+	manifest, err := index.IndexManifest()
+	require.NoError(t, err)
+
+	require.Len(t, manifest.Manifests, 1)
+	referrer := manifest.Manifests[0]
+	assert.Equal(t, ref.DigestStr(), referrer.Digest.String())
+	assert.Equal(t, ScanFindingsArtifactType, referrer.ArtifactType)
+
+	// The test registry leaves annotations out of the referrers index
+	artifact, err := remote.Image(ref)
+	require.NoError(t, err)
+	artifactManifest, err := artifact.Manifest()
+	require.NoError(t, err)
+	annotations := artifactManifest.Annotations
+	assert.Equal(t, "COMPLETE", annotations[ScanAnnotationPrefix+"status"])
+	assert.Equal(t, "2", annotations[ScanAnnotationPrefix+"severity.critical"])
+	assert.Equal(t, "2026-01-02T03:04:05Z", annotations["org.opencontainers.image.created"])
+}
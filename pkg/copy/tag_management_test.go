@@ -99,7 +99,7 @@ func TestDigestReference(t *testing.T) {
 // TestMultipleTagsCopy tests copying image with multiple tags
 func TestMultipleTagsCopy(t *testing.T) {
 	logger := log.NewBasicLogger(log.InfoLevel)
-	copier := copy.NewCopier(logger)
+	copier := copy.NewCopier(logger, copy.CopierOptions{})
 
 	srcRef, err := name.ParseReference("source.io/repo:v1.0.0")
 	require.NoError(t, err)
@@ -131,7 +131,7 @@ func TestTagOverwrite(t *testing.T) {
 	}
 
 	logger := log.NewBasicLogger(log.InfoLevel)
-	copier := copy.NewCopier(logger)
+	copier := copy.NewCopier(logger, copy.CopierOptions{})
 
 	srcRef, _ := name.ParseReference("source.io/repo:tag")
 	destRef, _ := name.ParseReference("dest.io/repo:tag")
@@ -173,7 +173,7 @@ func TestDestinationExistsCheck(t *testing.T) {
 	}
 
 	logger := log.NewBasicLogger(log.InfoLevel)
-	copier := copy.NewCopier(logger)
+	copier := copy.NewCopier(logger, copy.CopierOptions{})
 	ctx := context.Background()
 
 	ref, err := name.ParseReference("registry.io/repo:testtag")
@@ -260,7 +260,7 @@ func TestTagNormalization(t *testing.T) {
 // TestCrossRegistryTag tests copying between different registries
 func TestCrossRegistryTag(t *testing.T) {
 	logger := log.NewBasicLogger(log.InfoLevel)
-	copier := copy.NewCopier(logger)
+	copier := copy.NewCopier(logger, copy.CopierOptions{})
 
 	tests := []struct {
 		name   string
@@ -320,7 +320,7 @@ func TestBlobURLConstruction(t *testing.T) {
 // TestTagConcurrentOperations tests concurrent tag operations
 func TestTagConcurrentOperations(t *testing.T) {
 	logger := log.NewBasicLogger(log.InfoLevel)
-	copier := copy.NewCopier(logger)
+	copier := copy.NewCopier(logger, copy.CopierOptions{})
 
 	tags := []string{"v1.0.0", "v1.0.1", "v1.0.2", "latest", "stable"}
 	done := make(chan bool, len(tags))
@@ -413,7 +413,7 @@ func TestCopyRequestWithTags(t *testing.T) {
 // TestTagManagementErrors tests error scenarios in tag management
 func TestTagManagementErrors(t *testing.T) {
 	logger := log.NewBasicLogger(log.InfoLevel)
-	copier := copy.NewCopier(logger)
+	copier := copy.NewCopier(logger, copy.CopierOptions{})
 
 	t.Run("invalid source reference", func(t *testing.T) {
 		_, err := name.ParseReference("invalid::tag")
@@ -0,0 +1,85 @@
+package copy
+
+import (
+	"context"
+
+	"freightliner/pkg/helper/errors"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// copyTransformed pushes the source image with options.Transforms applied,
+// recomputing its layer, config and manifest digests, and returns the pushed
+// manifest. A dry run only reports the transformers and returns the source
+// manifest, so no layer is downloaded.
+func (c *Copier) copyTransformed(
+	ctx context.Context,
+	sourceRef name.Reference,
+	destRef name.Reference,
+	srcDesc *remote.Descriptor,
+	destOpts []remote.Option,
+	options CopyOptions,
+	stats *CopyStats,
+) ([]byte, error) {
+	c.logger.WithFields(map[string]interface{}{
+		"source":       sourceRef.String(),
+		"destination":  destRef.String(),
+		"transformers": options.Transforms.Names(),
+		"dry_run":      options.DryRun,
+	}).Info("Transforming image during copy")
+
+	if c.metrics != nil {
+		c.metrics.ReplicationStarted(sourceRef.String(), destRef.String())
+	}
+
+	img, err := srcDesc.Image()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get image from descriptor")
+	}
+	if options.DryRun {
+		return img.RawManifest()
+	}
+
+	transformed, cleanup, err := options.Transforms.Apply(img)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	if annotations := options.pushAnnotations(srcDesc.Manifest, sourceRef, destRef, c.annotatedRunID(ctx)); annotations != nil {
+		transformed = mutate.Annotations(transformed, annotations).(v1.Image)
+	}
+
+	manifest, err := transformed.RawManifest()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get transformed manifest")
+	}
+	parsed, err := transformed.Manifest()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse transformed manifest")
+	}
+
+	if err := remote.Write(destRef, transformed, append(destOpts, remote.WithContext(ctx))...); err != nil {
+		return nil, errors.Wrap(err, "failed to push transformed image")
+	}
+
+	digest, err := transformed.Digest()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to compute transformed digest")
+	}
+	c.logger.WithFields(map[string]interface{}{
+		"destination":   destRef.String(),
+		"source_digest": srcDesc.Digest.String(),
+		"digest":        digest.String(),
+	}).Info("Pushed transformed image")
+
+	stats.Layers = len(parsed.Layers)
+	stats.ManifestSize = int64(len(manifest))
+	for _, layer := range parsed.Layers {
+		stats.BytesTransferred += layer.Size
+	}
+	return manifest, nil
+}
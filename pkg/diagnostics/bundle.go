@@ -0,0 +1,146 @@
+package diagnostics
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime/pprof"
+	"time"
+
+	"freightliner/pkg/helper/errors"
+	"freightliner/pkg/helper/log"
+)
+
+// WriteBundle writes a tar.gz bundle of the process state to w:
+//
+//	dump.json       the Dump, including registered components
+//	goroutines.txt  every goroutine's stack, with pprof labels
+//	heap.pprof      a heap profile
+//	vars.json       the expvar variables
+func WriteBundle(w io.Writer) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	now := time.Now()
+
+	files := []struct {
+		name  string
+		write func(io.Writer) error
+	}{
+		{"dump.json", func(w io.Writer) error {
+			enc := json.NewEncoder(w)
+			enc.SetIndent("", "  ")
+			return enc.Encode(TakeDump())
+		}},
+		{"goroutines.txt", func(w io.Writer) error {
+			return pprof.Lookup("goroutine").WriteTo(w, 2)
+		}},
+		{"heap.pprof", func(w io.Writer) error {
+			return pprof.Lookup("heap").WriteTo(w, 0)
+		}},
+		{"vars.json", writeVars},
+	}
+
+	for _, file := range files {
+		var buf bytes.Buffer
+		if err := file.write(&buf); err != nil {
+			return errors.Wrapf(err, "failed to collect %s", file.name)
+		}
+
+		header := &tar.Header{
+			Name:    file.name,
+			Mode:    0644,
+			Size:    int64(buf.Len()),
+			ModTime: now,
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return errors.Wrap(err, "failed to write bundle")
+		}
+		if _, err := tw.Write(buf.Bytes()); err != nil {
+			return errors.Wrap(err, "failed to write bundle")
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return errors.Wrap(err, "failed to write bundle")
+	}
+	return gz.Close()
+}
+
+// writeVars writes the expvar variables as one JSON object
+func writeVars(w io.Writer) error {
+	vars := make(map[string]json.RawMessage)
+	expvar.Do(func(kv expvar.KeyValue) {
+		vars[kv.Key] = json.RawMessage(kv.Value.String())
+	})
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(vars)
+}
+
+// WriteBundleFile writes a bundle to a timestamped file in dir, or in the
+// system temporary directory when dir is empty, and returns its path
+func WriteBundleFile(dir string) (string, error) {
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", errors.Wrap(err, "failed to create diagnostics directory")
+	}
+
+	name := fmt.Sprintf("freightliner-diagnostics-%d-%s.tar.gz", os.Getpid(), time.Now().UTC().Format("20060102T150405Z"))
+	path := filepath.Join(dir, name)
+
+	file, err := os.Create(path)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to create diagnostics bundle")
+	}
+	if err := WriteBundle(file); err != nil {
+		_ = file.Close()
+		return "", err
+	}
+	if err := file.Close(); err != nil {
+		return "", errors.Wrap(err, "failed to write diagnostics bundle")
+	}
+	return path, nil
+}
+
+// HandleSignals writes a bundle to dir on every SIGQUIT until ctx is done.
+// This replaces the runtime's default SIGQUIT behavior of dumping
+// goroutines and exiting: the process keeps running. Windows has no SIGQUIT;
+// there bundles come from /debug/bundle only.
+func HandleSignals(ctx context.Context, dir string, logger log.Logger) {
+	if len(bundleSignals) == 0 {
+		logger.Debug("No bundle signal on this platform, use /debug/bundle")
+		return
+	}
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, bundleSignals...)
+
+	go func() {
+		defer signal.Stop(sigCh)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-sigCh:
+				path, err := WriteBundleFile(dir)
+				if err != nil {
+					logger.Error("Failed to write diagnostics bundle", err)
+					continue
+				}
+				logger.WithFields(map[string]interface{}{
+					"path": path,
+				}).Info("Wrote diagnostics bundle")
+			}
+		}
+	}()
+}
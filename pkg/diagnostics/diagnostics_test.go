@@ -0,0 +1,126 @@
+package diagnostics
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"freightliner/pkg/helper/log"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegister(t *testing.T) {
+	unregisterA := Register("pool", func() interface{} { return "a" })
+	unregisterB := Register("pool", func() interface{} { return "b" })
+
+	dump := TakeDump()
+	assert.Equal(t, "a", dump.Components["pool"])
+	assert.Equal(t, "b", dump.Components["pool-2"])
+	assert.Positive(t, dump.Goroutines)
+
+	unregisterA()
+	unregisterA()
+	dump = TakeDump()
+	assert.NotContains(t, dump.Components, "pool")
+	assert.Equal(t, "b", dump.Components["pool-2"])
+
+	unregisterB()
+	assert.Empty(t, TakeDump().Components)
+}
+
+// bundleFiles reads the files of a tar.gz bundle
+func bundleFiles(t *testing.T, r io.Reader) map[string][]byte {
+	gz, err := gzip.NewReader(r)
+	require.NoError(t, err)
+	tr := tar.NewReader(gz)
+
+	files := make(map[string][]byte)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return files
+		}
+		require.NoError(t, err)
+		data, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		files[header.Name] = data
+	}
+}
+
+func TestWriteBundle(t *testing.T) {
+	unregister := Register("jobs", func() interface{} { return map[string]int{"running": 2} })
+	defer unregister()
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteBundle(&buf))
+
+	files := bundleFiles(t, &buf)
+	require.Contains(t, files, "dump.json")
+	assert.Contains(t, files, "heap.pprof")
+	assert.Contains(t, files, "vars.json")
+	assert.Contains(t, string(files["goroutines.txt"]), "goroutine ")
+
+	var dump Dump
+	require.NoError(t, json.Unmarshal(files["dump.json"], &dump))
+	assert.Equal(t, map[string]interface{}{"running": float64(2)}, dump.Components["jobs"])
+}
+
+func TestWriteBundleFile(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "bundles")
+
+	path, err := WriteBundleFile(dir)
+	require.NoError(t, err)
+	assert.Equal(t, dir, filepath.Dir(path))
+	assert.True(t, strings.HasSuffix(path, ".tar.gz"))
+
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+	assert.Contains(t, bundleFiles(t, file), "dump.json")
+}
+
+func TestHandler(t *testing.T) {
+	server := httptest.NewServer(NewHandler())
+	defer server.Close()
+
+	for _, path := range []string{"/debug/pprof/", "/debug/pprof/goroutine?debug=1", "/debug/vars", "/debug/dump"} {
+		resp, err := http.Get(server.URL + path)
+		require.NoError(t, err, path)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode, path)
+	}
+
+	resp, err := http.Get(server.URL + "/debug/bundle")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, "application/gzip", resp.Header.Get("Content-Type"))
+	assert.Contains(t, bundleFiles(t, resp.Body), "goroutines.txt")
+}
+
+func TestServe(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	logger := log.NewBasicLogger(log.ErrorLevel)
+
+	addr, err := Serve(ctx, "127.0.0.1:0", logger)
+	require.NoError(t, err)
+
+	resp, err := http.Get("http://" + addr.String() + "/debug/dump")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	_, err = Serve(ctx, addr.String(), logger)
+	assert.Error(t, err, "address already in use")
+}
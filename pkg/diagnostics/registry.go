@@ -0,0 +1,114 @@
+// Package diagnostics serves runtime state for debugging a running process:
+// pprof profiles, expvar variables, a JSON dump of goroutine, memory and
+// component state, and tar.gz bundles of all of these.
+package diagnostics
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"time"
+)
+
+// SourceFunc returns a component's state for a dump. It must be safe to call
+// from any goroutine and return a JSON-encodable value.
+type SourceFunc func() interface{}
+
+var (
+	sourcesMu sync.RWMutex
+	sources   = make(map[string]SourceFunc)
+	startTime = time.Now()
+)
+
+// Register adds a component's state to dumps under name, adding a numeric
+// suffix when name is taken. The returned function removes it again.
+func Register(name string, fn SourceFunc) (unregister func()) {
+	sourcesMu.Lock()
+	defer sourcesMu.Unlock()
+
+	key := name
+	for i := 2; sources[key] != nil; i++ {
+		key = fmt.Sprintf("%s-%d", name, i)
+	}
+	sources[key] = fn
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			sourcesMu.Lock()
+			defer sourcesMu.Unlock()
+			delete(sources, key)
+		})
+	}
+}
+
+// Dump is a point-in-time view of the process
+type Dump struct {
+	Time       time.Time              `json:"time"`
+	Uptime     string                 `json:"uptime"`
+	GoVersion  string                 `json:"go_version"`
+	Version    string                 `json:"version,omitempty"`
+	GOMAXPROCS int                    `json:"gomaxprocs"`
+	NumCPU     int                    `json:"num_cpu"`
+	Goroutines int                    `json:"goroutines"`
+	Memory     MemoryStats            `json:"memory"`
+	Components map[string]interface{} `json:"components"`
+}
+
+// MemoryStats is the part of runtime.MemStats useful for spotting leaks
+type MemoryStats struct {
+	HeapAllocBytes uint64 `json:"heap_alloc_bytes"`
+	HeapInuseBytes uint64 `json:"heap_inuse_bytes"`
+	HeapObjects    uint64 `json:"heap_objects"`
+	SysBytes       uint64 `json:"sys_bytes"`
+	NumGC          uint32 `json:"num_gc"`
+	PauseTotal     string `json:"gc_pause_total"`
+}
+
+// TakeDump collects the process state and every registered component's state
+func TakeDump() Dump {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	dump := Dump{
+		Time:       time.Now().UTC(),
+		Uptime:     time.Since(startTime).Round(time.Second).String(),
+		GoVersion:  runtime.Version(),
+		GOMAXPROCS: runtime.GOMAXPROCS(0),
+		NumCPU:     runtime.NumCPU(),
+		Goroutines: runtime.NumGoroutine(),
+		Memory: MemoryStats{
+			HeapAllocBytes: mem.HeapAlloc,
+			HeapInuseBytes: mem.HeapInuse,
+			HeapObjects:    mem.HeapObjects,
+			SysBytes:       mem.Sys,
+			NumGC:          mem.NumGC,
+			PauseTotal:     time.Duration(mem.PauseTotalNs).String(),
+		},
+		Components: make(map[string]interface{}),
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		dump.Version = info.Main.Version
+	}
+
+	sourcesMu.RLock()
+	names := make([]string, 0, len(sources))
+	for name := range sources {
+		names = append(names, name)
+	}
+	fns := make([]SourceFunc, len(names))
+	sort.Strings(names)
+	for i, name := range names {
+		fns[i] = sources[name]
+	}
+	sourcesMu.RUnlock()
+
+	// Sources run outside the lock so they may take their own locks freely
+	for i, name := range names {
+		dump.Components[name] = fns[i]()
+	}
+
+	return dump
+}
@@ -0,0 +1,96 @@
+package diagnostics
+
+import (
+	"context"
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"time"
+
+	"freightliner/pkg/helper/errors"
+	"freightliner/pkg/helper/log"
+)
+
+// NewHandler returns the diagnostics endpoints:
+//
+//	/debug/pprof/   pprof profiles (goroutine, heap, profile, trace, ...)
+//	/debug/vars     expvar variables
+//	/debug/dump     the Dump as JSON
+//	/debug/bundle   a tar.gz bundle, as written by WriteBundle
+func NewHandler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+
+	mux.HandleFunc("/debug/dump", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(TakeDump())
+	})
+
+	mux.HandleFunc("/debug/bundle", func(w http.ResponseWriter, r *http.Request) {
+		name := fmt.Sprintf("freightliner-diagnostics-%s.tar.gz", time.Now().UTC().Format("20060102T150405Z"))
+		w.Header().Set("Content-Type", "application/gzip")
+		w.Header().Set("Content-Disposition", "attachment; filename="+name)
+		if err := WriteBundle(w); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+
+	return mux
+}
+
+// Serve listens on addr and serves NewHandler until ctx is done. It returns
+// once the listener is bound, so a bad address is reported immediately.
+// The endpoints are unauthenticated; a warning is logged unless addr is a
+// loopback address.
+func Serve(ctx context.Context, addr string, logger log.Logger) (net.Addr, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to listen on debug address %s", addr)
+	}
+
+	fields := map[string]interface{}{
+		"address": listener.Addr().String(),
+	}
+	if !isLoopback(listener.Addr()) {
+		logger.WithFields(fields).Warn("Debug endpoints are unauthenticated and reachable beyond localhost")
+	}
+	logger.WithFields(fields).Info("Serving diagnostics")
+
+	// No write timeout: CPU profiles and traces stream for as long as requested
+	server := &http.Server{
+		Handler:           NewHandler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			logger.Error("Diagnostics server error", err)
+		}
+	}()
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = server.Shutdown(shutdownCtx)
+	}()
+
+	return listener.Addr(), nil
+}
+
+// isLoopback reports whether addr only accepts local connections
+func isLoopback(addr net.Addr) bool {
+	tcpAddr, ok := addr.(*net.TCPAddr)
+	return ok && tcpAddr.IP.IsLoopback()
+}
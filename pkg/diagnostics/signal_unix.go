@@ -0,0 +1,11 @@
+//go:build !windows
+
+package diagnostics
+
+import (
+	"os"
+	"syscall"
+)
+
+// bundleSignals request a diagnostics bundle
+var bundleSignals = []os.Signal{syscall.SIGQUIT}
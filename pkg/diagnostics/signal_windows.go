@@ -0,0 +1,8 @@
+//go:build windows
+
+package diagnostics
+
+import "os"
+
+// bundleSignals is empty: Windows delivers no SIGQUIT
+var bundleSignals []os.Signal
@@ -0,0 +1,191 @@
+package distributed
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ClusterStatus is a node's view of the cluster served by the admin handler
+type ClusterStatus struct {
+	Node     SchedulerStatus `json:"node"`
+	IsLeader bool            `json:"is_leader"`
+	Leader   string          `json:"leader,omitempty"`
+	Jobs     int             `json:"jobs"`
+	Error    string          `json:"error,omitempty"`
+}
+
+// AdminHandler serves a node's maintenance endpoints:
+//
+//	GET  /cluster/status  the node's ClusterStatus
+//	POST /cluster/drain   drain the node; ?wait=<duration> waits for it
+//	POST /cluster/resume  take the node out of maintenance mode
+//	GET  /cluster/workload    the node's WorkloadSnapshot
+//	POST /cluster/rebalance   move queued jobs to peers with spare capacity
+type AdminHandler struct {
+	scheduler   *WorkStealingScheduler
+	coordinator *RaftCoordinator
+	mux         *http.ServeMux
+
+	mu          sync.Mutex
+	drainCancel context.CancelFunc
+	drainDone   chan struct{}
+	drainErr    error
+}
+
+// NewAdminHandler creates the admin handler of a node. coordinator may be nil
+// for nodes without Raft.
+func NewAdminHandler(scheduler *WorkStealingScheduler, coordinator *RaftCoordinator) *AdminHandler {
+	h := &AdminHandler{
+		scheduler:   scheduler,
+		coordinator: coordinator,
+		mux:         http.NewServeMux(),
+	}
+	h.mux.HandleFunc("/cluster/status", h.handleStatus)
+	h.mux.HandleFunc("/cluster/drain", h.handleDrain)
+	h.mux.HandleFunc("/cluster/resume", h.handleResume)
+	h.mux.HandleFunc("/cluster/workload", h.handleWorkload)
+	h.mux.HandleFunc("/cluster/rebalance", h.handleRebalance)
+	return h
+}
+
+// ServeHTTP implements http.Handler
+func (h *AdminHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+// Status returns the node's view of the cluster
+func (h *AdminHandler) Status() ClusterStatus {
+	status := ClusterStatus{Node: h.scheduler.Status()}
+	if h.coordinator != nil {
+		status.IsLeader = h.coordinator.IsLeader()
+		status.Leader = h.coordinator.GetLeader()
+		status.Jobs = len(h.coordinator.ListJobs())
+	}
+
+	h.mu.Lock()
+	if h.drainErr != nil {
+		status.Error = h.drainErr.Error()
+	}
+	h.mu.Unlock()
+	return status
+}
+
+func (h *AdminHandler) handleStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	h.writeStatus(w, http.StatusOK)
+}
+
+func (h *AdminHandler) handleDrain(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var wait time.Duration
+	if value := r.URL.Query().Get("wait"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			http.Error(w, "invalid wait duration", http.StatusBadRequest)
+			return
+		}
+		wait = parsed
+	}
+
+	done := h.startDrain()
+	if wait > 0 {
+		select {
+		case <-done:
+		case <-time.After(wait):
+		case <-r.Context().Done():
+		}
+	}
+
+	code := http.StatusAccepted
+	if h.scheduler.Mode() == NodeModeDrained {
+		code = http.StatusOK
+	}
+	h.writeStatus(w, code)
+}
+
+func (h *AdminHandler) handleResume(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	h.mu.Lock()
+	if h.drainCancel != nil {
+		h.drainCancel()
+		h.drainCancel = nil
+	}
+	h.drainErr = nil
+	h.mu.Unlock()
+
+	h.scheduler.Resume()
+	h.writeStatus(w, http.StatusOK)
+}
+
+func (h *AdminHandler) handleWorkload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, http.StatusOK, h.scheduler.Snapshot())
+}
+
+func (h *AdminHandler) handleRebalance(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	result, err := h.scheduler.Rebalance(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// startDrain starts draining the node unless a drain is already running and
+// returns a channel closed when it ends
+func (h *AdminHandler) startDrain() <-chan struct{} {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.drainCancel != nil {
+		return h.drainDone
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	h.drainCancel, h.drainDone, h.drainErr = cancel, done, nil
+
+	go func() {
+		defer close(done)
+		err := h.scheduler.Drain(ctx)
+
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if err != nil && ctx.Err() == nil {
+			h.drainErr = err
+		}
+	}()
+	return done
+}
+
+func (h *AdminHandler) writeStatus(w http.ResponseWriter, code int) {
+	writeJSON(w, code, h.Status())
+}
+
+func writeJSON(w http.ResponseWriter, code int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(value)
+}
@@ -20,6 +20,7 @@ type DistributedCache struct {
 	mu          sync.RWMutex
 	replication int // Number of replicas for fault tolerance
 	metrics     *CacheMetrics
+	locationMu  sync.Mutex // Serializes blob location updates from this node
 }
 
 // ConsistentHashRing implements consistent hashing
@@ -41,6 +42,10 @@ type CacheNode struct {
 	client   CacheClient
 	mu       sync.RWMutex
 	healthy  atomic.Bool
+
+	// Optional disk tier, which survives restarts
+	disk      *DiskTier
+	evictions atomic.Uint64
 }
 
 // CacheEntry represents a cached value
@@ -344,6 +349,20 @@ func (dc *DistributedCache) GetMetrics() *CacheMetrics {
 func (dc *DistributedCache) GetStats() map[string]interface{} {
 	dc.mu.RLock()
 	nodeCount := len(dc.nodes)
+	var memoryBytes, diskBytes, diskEntries int64
+	var memoryEvictions, diskHits, diskEvictions uint64
+	diskTiers := 0
+	for _, node := range dc.nodes {
+		memoryBytes += node.GetSize()
+		memoryEvictions += node.evictions.Load()
+		if disk := node.diskTier(); disk != nil {
+			diskTiers++
+			diskBytes += disk.Size()
+			diskEntries += int64(disk.Len())
+			diskHits += disk.metrics.Hits.Load()
+			diskEvictions += disk.metrics.Evictions.Load()
+		}
+	}
 	dc.mu.RUnlock()
 
 	hits := dc.metrics.Hits.Load()
@@ -353,18 +372,26 @@ func (dc *DistributedCache) GetStats() map[string]interface{} {
 		hitRate = float64(hits) / float64(total) * 100
 	}
 
-	return map[string]interface{}{
+	stats := map[string]interface{}{
 		"nodes":         nodeCount,
 		"gets":          total,
 		"sets":          dc.metrics.Sets.Load(),
 		"hits":          hits,
 		"misses":        dc.metrics.Misses.Load(),
 		"hit_rate":      hitRate,
-		"evictions":     dc.metrics.Evictions.Load(),
+		"evictions":     dc.metrics.Evictions.Load() + memoryEvictions,
 		"relocations":   dc.metrics.Relocations.Load(),
 		"replication":   dc.replication,
 		"virtual_nodes": dc.ring.vnodes,
+		"memory_bytes":  memoryBytes,
 	}
+	if diskTiers > 0 {
+		stats["disk_bytes"] = diskBytes
+		stats["disk_entries"] = diskEntries
+		stats["disk_hits"] = diskHits
+		stats["disk_evictions"] = diskEvictions
+	}
+	return stats
 }
 
 // redistributeKeys redistributes keys when nodes change
@@ -526,14 +553,29 @@ func (cn *CacheNode) Get(ctx context.Context, key string) ([]byte, error) {
 	// Check local cache first
 	cn.mu.RLock()
 	entry, exists := cn.cache[key]
+	disk := cn.disk
 	cn.mu.RUnlock()
 
+	now := time.Now().Unix()
+	if exists && entry.expired(now) {
+		cn.removeEntry(key)
+		exists = false
+	}
 	if exists {
 		entry.HitCount.Add(1)
-		entry.AccessTime.Store(time.Now().Unix())
+		entry.AccessTime.Store(now)
 		return entry.Value, nil
 	}
 
+	// Fall back to the disk tier and keep the value in memory again
+	if disk != nil {
+		value, expiresAt, err := disk.Get(ctx, key)
+		if err == nil {
+			cn.storeEntry(newCacheEntry(key, value, now, expiresAt))
+			return value, nil
+		}
+	}
+
 	// Fetch from remote node if client is available
 	if cn.client != nil {
 		return cn.client.Get(ctx, key)
@@ -545,19 +587,17 @@ func (cn *CacheNode) Get(ctx context.Context, key string) ([]byte, error) {
 // Set stores a value in the node
 func (cn *CacheNode) Set(ctx context.Context, key string, value []byte, ttl int64) error {
 	now := time.Now().Unix()
-	entry := &CacheEntry{
-		Key:       key,
-		Value:     value,
-		Size:      int64(len(value)),
-		CreatedAt: now,
-		ExpiresAt: now + ttl,
+	var expiresAt int64
+	if ttl > 0 {
+		expiresAt = now + ttl
 	}
-	entry.AccessTime.Store(now)
+	cn.storeEntry(newCacheEntry(key, value, now, expiresAt))
 
-	cn.mu.Lock()
-	cn.cache[key] = entry
-	cn.size.Add(entry.Size)
-	cn.mu.Unlock()
+	if disk := cn.diskTier(); disk != nil {
+		if err := disk.Set(ctx, key, value, expiresAt); err != nil {
+			return err
+		}
+	}
 
 	// Store to remote node if client is available
 	if cn.client != nil {
@@ -569,12 +609,13 @@ func (cn *CacheNode) Set(ctx context.Context, key string, value []byte, ttl int6
 
 // Delete removes a value from the node
 func (cn *CacheNode) Delete(ctx context.Context, key string) error {
-	cn.mu.Lock()
-	if entry, exists := cn.cache[key]; exists {
-		delete(cn.cache, key)
-		cn.size.Add(-entry.Size)
+	cn.removeEntry(key)
+
+	if disk := cn.diskTier(); disk != nil {
+		if err := disk.Delete(ctx, key); err != nil {
+			return err
+		}
 	}
-	cn.mu.Unlock()
 
 	// Delete from remote node if client is available
 	if cn.client != nil {
@@ -593,3 +634,92 @@ func (cn *CacheNode) GetSize() int64 {
 func (cn *CacheNode) IsHealthy() bool {
 	return cn.healthy.Load()
 }
+
+// AttachDiskTier keeps the node's entries in tier as well, so they survive
+// a restart, and loads the tier's most recently used entries into memory
+func (cn *CacheNode) AttachDiskTier(ctx context.Context, tier *DiskTier) {
+	cn.mu.Lock()
+	cn.disk = tier
+	cn.mu.Unlock()
+
+	now := time.Now().Unix()
+	warmBytes := tier.warmBytes
+	if warmBytes > cn.capacity {
+		warmBytes = cn.capacity
+	}
+	tier.Recent(ctx, warmBytes, func(key string, value []byte, expiresAt int64) {
+		cn.storeEntry(newCacheEntry(key, value, now, expiresAt))
+	})
+}
+
+// diskTier returns the node's disk tier, or nil
+func (cn *CacheNode) diskTier() *DiskTier {
+	cn.mu.RLock()
+	defer cn.mu.RUnlock()
+	return cn.disk
+}
+
+// newCacheEntry creates an entry expiring at expiresAt, in Unix seconds (0 =
+// never)
+func newCacheEntry(key string, value []byte, now, expiresAt int64) *CacheEntry {
+	entry := &CacheEntry{
+		Key:       key,
+		Value:     value,
+		Size:      int64(len(value)),
+		CreatedAt: now,
+		ExpiresAt: expiresAt,
+	}
+	entry.AccessTime.Store(now)
+	return entry
+}
+
+// storeEntry keeps entry in memory, evicting the least recently used
+// entries when the node is over capacity
+func (cn *CacheNode) storeEntry(entry *CacheEntry) {
+	cn.mu.Lock()
+	defer cn.mu.Unlock()
+
+	if old, exists := cn.cache[entry.Key]; exists {
+		cn.size.Add(-old.Size)
+	}
+	cn.cache[entry.Key] = entry
+	cn.size.Add(entry.Size)
+
+	if cn.capacity <= 0 || cn.size.Load() <= cn.capacity {
+		return
+	}
+
+	entries := make([]*CacheEntry, 0, len(cn.cache))
+	for _, cached := range cn.cache {
+		if cached != entry {
+			entries = append(entries, cached)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].AccessTime.Load() < entries[j].AccessTime.Load()
+	})
+	for _, victim := range entries {
+		if cn.size.Load() <= cn.capacity {
+			break
+		}
+		delete(cn.cache, victim.Key)
+		cn.size.Add(-victim.Size)
+		cn.evictions.Add(1)
+	}
+}
+
+// removeEntry drops key from memory
+func (cn *CacheNode) removeEntry(key string) {
+	cn.mu.Lock()
+	defer cn.mu.Unlock()
+
+	if entry, exists := cn.cache[key]; exists {
+		delete(cn.cache, key)
+		cn.size.Add(-entry.Size)
+	}
+}
+
+// expired reports whether the entry expired by now
+func (e *CacheEntry) expired(now int64) bool {
+	return e.ExpiresAt > 0 && now >= e.ExpiresAt
+}
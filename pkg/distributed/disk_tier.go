@@ -0,0 +1,546 @@
+package distributed
+
+import (
+	"bufio"
+	"container/list"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"freightliner/pkg/helper/errors"
+	"freightliner/pkg/helper/log"
+	"freightliner/pkg/storage"
+
+	"github.com/opencontainers/go-digest"
+)
+
+const (
+	// diskIndexFile is the journal of a disk tier's index
+	diskIndexFile = "index.journal"
+
+	// diskCompactSlack is how many journal records beyond twice the live
+	// entries are tolerated before the journal is rewritten
+	diskCompactSlack = 1000
+)
+
+// DiskTierConfig configures a cache node's disk tier
+type DiskTierConfig struct {
+	// Dir holds the tier's blobs and index
+	Dir string
+	// MaxBytes bounds the stored values; least recently used are evicted first
+	MaxBytes int64
+	// WarmBytes is how much of the most recently used data is loaded back
+	// into memory when the tier is attached to a node (0 = none)
+	WarmBytes int64
+	Logger    log.Logger
+}
+
+// DiskTierMetrics tracks disk tier usage
+type DiskTierMetrics struct {
+	Hits      atomic.Uint64
+	Misses    atomic.Uint64
+	Writes    atomic.Uint64
+	Evictions atomic.Uint64
+	Expired   atomic.Uint64
+}
+
+// DiskTier keeps cache entries on disk so they survive a node restart.
+// Values are stored content-addressed in a storage.FileBackend, the CAS's
+// on-disk backend, so equal values are stored once. An LRU index bounds the
+// tier's size and is journaled next to the blobs, to be replayed on open.
+type DiskTier struct {
+	backend   storage.StorageBackend
+	dir       string
+	maxBytes  int64
+	warmBytes int64
+	logger    log.Logger
+	metrics   *DiskTierMetrics
+
+	mu      sync.Mutex
+	lru     *list.List // of *diskEntry, most recently used first
+	entries map[string]*list.Element
+	refs    map[digest.Digest]int
+	size    int64
+
+	journal      *os.File
+	journalW     *bufio.Writer
+	journalLines int
+}
+
+// diskEntry is a key's index entry
+type diskEntry struct {
+	Key        string        `json:"key"`
+	Digest     digest.Digest `json:"digest"`
+	Size       int64         `json:"size"`
+	ExpiresAt  int64         `json:"expires_at,omitempty"`
+	AccessTime int64         `json:"access_time"`
+}
+
+// diskJournalRecord is one line of the index journal
+type diskJournalRecord struct {
+	Op    string     `json:"op"` // set, touch or delete
+	Entry *diskEntry `json:"entry,omitempty"`
+	Key   string     `json:"key,omitempty"`
+	Time  int64      `json:"time,omitempty"`
+}
+
+// OpenDiskTier opens or creates a disk tier. Its index is rebuilt from the
+// journal; entries that expired or lost their blob are dropped, and blobs no
+// entry refers to are removed.
+func OpenDiskTier(config DiskTierConfig) (*DiskTier, error) {
+	if config.Dir == "" {
+		return nil, errors.InvalidInputf("disk tier directory is required")
+	}
+	if config.MaxBytes <= 0 {
+		return nil, errors.InvalidInputf("disk tier size must be positive")
+	}
+	if config.Logger == nil {
+		config.Logger = log.NewBasicLogger(log.InfoLevel)
+	}
+
+	backend, err := storage.NewFileBackend(filepath.Join(config.Dir, "blobs"), config.Logger)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &DiskTier{
+		backend:   backend,
+		dir:       config.Dir,
+		maxBytes:  config.MaxBytes,
+		warmBytes: config.WarmBytes,
+		logger:    config.Logger,
+		metrics:   &DiskTierMetrics{},
+		lru:       list.New(),
+		entries:   make(map[string]*list.Element),
+		refs:      make(map[digest.Digest]int),
+	}
+
+	if err := t.load(context.Background()); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Get returns the value of key and its expiry time, in Unix seconds (0 =
+// never)
+func (t *DiskTier) Get(ctx context.Context, key string) ([]byte, int64, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	elem, exists := t.entries[key]
+	if !exists {
+		t.metrics.Misses.Add(1)
+		return nil, 0, errors.NotFoundf("key not found: %s", key)
+	}
+
+	entry := elem.Value.(*diskEntry)
+	now := time.Now().Unix()
+	if entry.expired(now) {
+		t.metrics.Misses.Add(1)
+		t.metrics.Expired.Add(1)
+		t.remove(ctx, elem)
+		t.writeJournal(diskJournalRecord{Op: "delete", Key: key}, false)
+		return nil, 0, errors.NotFoundf("key not found: %s", key)
+	}
+
+	value, err := t.backend.Get(ctx, entry.Digest)
+	if err == nil && digest.FromBytes(value) != entry.Digest {
+		err = errors.New("digest mismatch: data corruption detected")
+	}
+	if err != nil {
+		t.metrics.Misses.Add(1)
+		t.remove(ctx, elem)
+		t.writeJournal(diskJournalRecord{Op: "delete", Key: key}, false)
+		return nil, 0, errors.Wrapf(err, "failed to read cached value of %s", key)
+	}
+
+	entry.AccessTime = now
+	t.lru.MoveToFront(elem)
+	t.writeJournal(diskJournalRecord{Op: "touch", Key: key, Time: now}, false)
+	t.metrics.Hits.Add(1)
+	return value, entry.ExpiresAt, nil
+}
+
+// Set stores value under key until expiresAt, in Unix seconds (0 = never),
+// evicting least recently used entries to stay within the tier's size
+func (t *DiskTier) Set(ctx context.Context, key string, value []byte, expiresAt int64) error {
+	d := digest.FromBytes(value)
+	if int64(len(value)) > t.maxBytes {
+		return errors.InvalidInputf("value of %s is larger than the disk tier", key)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.refs[d] == 0 {
+		if err := t.backend.Put(ctx, d, value); err != nil {
+			return errors.Wrapf(err, "failed to store cached value of %s", key)
+		}
+	}
+
+	entry := &diskEntry{
+		Key:        key,
+		Digest:     d,
+		Size:       int64(len(value)),
+		ExpiresAt:  expiresAt,
+		AccessTime: time.Now().Unix(),
+	}
+	// Reference the new blob before dropping the old entry, which may share it
+	t.add(entry)
+	t.writeJournal(diskJournalRecord{Op: "set", Entry: entry}, true)
+	t.metrics.Writes.Add(1)
+
+	t.evict(ctx)
+	return nil
+}
+
+// Delete removes key from the tier
+func (t *DiskTier) Delete(ctx context.Context, key string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	elem, exists := t.entries[key]
+	if !exists {
+		return nil
+	}
+	t.remove(ctx, elem)
+	t.writeJournal(diskJournalRecord{Op: "delete", Key: key}, true)
+	return nil
+}
+
+// Recent calls fn with the most recently used unexpired entries, newest
+// first, until their values add up to maxBytes
+func (t *DiskTier) Recent(ctx context.Context, maxBytes int64, fn func(key string, value []byte, expiresAt int64)) {
+	t.mu.Lock()
+	var warm []diskEntry
+	var total int64
+	now := time.Now().Unix()
+	for elem := t.lru.Front(); elem != nil; elem = elem.Next() {
+		entry := elem.Value.(*diskEntry)
+		if entry.expired(now) {
+			continue
+		}
+		if total+entry.Size > maxBytes {
+			break
+		}
+		total += entry.Size
+		warm = append(warm, *entry)
+	}
+	t.mu.Unlock()
+
+	for _, entry := range warm {
+		value, err := t.backend.Get(ctx, entry.Digest)
+		if err != nil {
+			continue
+		}
+		fn(entry.Key, value, entry.ExpiresAt)
+	}
+}
+
+// Len returns the number of entries
+func (t *DiskTier) Len() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.entries)
+}
+
+// Size returns the bytes stored, counting shared values once
+func (t *DiskTier) Size() int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.size
+}
+
+// GetMetrics returns the tier's metrics
+func (t *DiskTier) GetMetrics() *DiskTierMetrics {
+	return t.metrics
+}
+
+// Close flushes and closes the index journal
+func (t *DiskTier) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.journal == nil {
+		return nil
+	}
+	err := t.journalW.Flush()
+	if closeErr := t.journal.Close(); err == nil {
+		err = closeErr
+	}
+	t.journal = nil
+	return err
+}
+
+// add indexes entry as the most recently used, replacing any entry of its key
+func (t *DiskTier) add(entry *diskEntry) {
+	if t.refs[entry.Digest] == 0 {
+		t.size += entry.Size
+	}
+	t.refs[entry.Digest]++
+
+	if elem, exists := t.entries[entry.Key]; exists {
+		t.release(context.Background(), elem.Value.(*diskEntry))
+		elem.Value = entry
+		t.lru.MoveToFront(elem)
+		return
+	}
+	t.entries[entry.Key] = t.lru.PushFront(entry)
+}
+
+// remove drops elem from the index and its blob once no entry refers to it
+func (t *DiskTier) remove(ctx context.Context, elem *list.Element) {
+	entry := elem.Value.(*diskEntry)
+	t.lru.Remove(elem)
+	delete(t.entries, entry.Key)
+	t.release(ctx, entry)
+}
+
+// release drops entry's reference to its blob
+func (t *DiskTier) release(ctx context.Context, entry *diskEntry) {
+	t.refs[entry.Digest]--
+	if t.refs[entry.Digest] > 0 {
+		return
+	}
+	delete(t.refs, entry.Digest)
+	t.size -= entry.Size
+
+	if err := t.backend.Delete(ctx, entry.Digest); err != nil && !errors.Is(err, errors.ErrNotFound) {
+		t.logger.WithFields(map[string]interface{}{
+			"digest": entry.Digest.String(),
+			"error":  err.Error(),
+		}).Warn("Failed to delete cached value from disk")
+	}
+}
+
+// evict removes expired entries, then least recently used ones, until the
+// tier fits its size
+func (t *DiskTier) evict(ctx context.Context) {
+	if t.size <= t.maxBytes {
+		return
+	}
+
+	now := time.Now().Unix()
+	for elem := t.lru.Back(); elem != nil && t.size > t.maxBytes; {
+		prev := elem.Prev()
+		entry := elem.Value.(*diskEntry)
+		if entry.expired(now) {
+			t.remove(ctx, elem)
+			t.writeJournal(diskJournalRecord{Op: "delete", Key: entry.Key}, false)
+			t.metrics.Expired.Add(1)
+		}
+		elem = prev
+	}
+
+	for t.size > t.maxBytes && t.lru.Len() > 1 {
+		elem := t.lru.Back()
+		entry := elem.Value.(*diskEntry)
+		t.remove(ctx, elem)
+		t.writeJournal(diskJournalRecord{Op: "delete", Key: entry.Key}, false)
+		t.metrics.Evictions.Add(1)
+	}
+	_ = t.flushJournal()
+}
+
+// load replays the index journal, drops entries that expired or lost their
+// blob, removes unreferenced blobs and rewrites the journal
+func (t *DiskTier) load(ctx context.Context) error {
+	path := filepath.Join(t.dir, diskIndexFile)
+	entries := make(map[string]*diskEntry)
+
+	file, err := os.Open(path)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return errors.Wrapf(err, "failed to open disk tier index %s", path)
+	default:
+		scanner := bufio.NewScanner(file)
+		scanner.Buffer(make([]byte, 64*1024), 16<<20)
+		for scanner.Scan() {
+			var record diskJournalRecord
+			if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+				// A torn last line from a crash
+				continue
+			}
+			switch record.Op {
+			case "set":
+				if record.Entry != nil {
+					entries[record.Entry.Key] = record.Entry
+				}
+			case "touch":
+				if entry, exists := entries[record.Key]; exists {
+					entry.AccessTime = record.Time
+				}
+			case "delete":
+				delete(entries, record.Key)
+			}
+		}
+		err := scanner.Err()
+		file.Close()
+		if err != nil {
+			return errors.Wrapf(err, "failed to read disk tier index %s", path)
+		}
+	}
+
+	now := time.Now().Unix()
+	ordered := make([]*diskEntry, 0, len(entries))
+	dropped := 0
+	for _, entry := range entries {
+		if entry.expired(now) || !t.backend.Exists(ctx, entry.Digest) {
+			dropped++
+			continue
+		}
+		ordered = append(ordered, entry)
+	}
+	sortByAccessTime(ordered)
+	for _, entry := range ordered {
+		if t.refs[entry.Digest] == 0 {
+			t.size += entry.Size
+		}
+		t.refs[entry.Digest]++
+		t.entries[entry.Key] = t.lru.PushBack(entry)
+	}
+
+	orphans := t.removeOrphans(ctx)
+
+	if err := t.rewriteJournal(); err != nil {
+		return err
+	}
+	t.evict(ctx)
+
+	t.logger.WithFields(map[string]interface{}{
+		"directory": t.dir,
+		"entries":   len(t.entries),
+		"bytes":     t.size,
+		"dropped":   dropped,
+		"orphans":   orphans,
+	}).Info("Loaded distributed cache disk tier")
+	return nil
+}
+
+// removeOrphans deletes blobs no entry refers to and returns how many
+func (t *DiskTier) removeOrphans(ctx context.Context) int {
+	digests, err := t.backend.List(ctx)
+	if err != nil {
+		t.logger.WithFields(map[string]interface{}{
+			"error": err.Error(),
+		}).Warn("Failed to list disk tier blobs")
+		return 0
+	}
+
+	removed := 0
+	for _, d := range digests {
+		if t.refs[d] > 0 {
+			continue
+		}
+		if err := t.backend.Delete(ctx, d); err == nil {
+			removed++
+		}
+	}
+	return removed
+}
+
+// writeJournal appends record to the index journal. Sets and deletes are
+// flushed at once; touches are buffered, losing only recency on a crash.
+func (t *DiskTier) writeJournal(record diskJournalRecord, flush bool) {
+	if t.journal == nil {
+		return
+	}
+
+	line, err := json.Marshal(record)
+	if err == nil {
+		_, err = t.journalW.Write(append(line, '\n'))
+	}
+	if err == nil && flush {
+		err = t.flushJournal()
+	}
+	if err != nil {
+		t.logger.WithFields(map[string]interface{}{
+			"error": err.Error(),
+		}).Warn("Failed to write disk tier index")
+		return
+	}
+
+	t.journalLines++
+	if t.journalLines > 2*len(t.entries)+diskCompactSlack {
+		if err := t.rewriteJournal(); err != nil {
+			t.logger.WithFields(map[string]interface{}{
+				"error": err.Error(),
+			}).Warn("Failed to compact disk tier index")
+		}
+	}
+}
+
+func (t *DiskTier) flushJournal() error {
+	if t.journal == nil {
+		return nil
+	}
+	return t.journalW.Flush()
+}
+
+// rewriteJournal replaces the journal with one set record per live entry,
+// least recently used first, and reopens it for appending
+func (t *DiskTier) rewriteJournal() error {
+	if t.journal != nil {
+		_ = t.journalW.Flush()
+		_ = t.journal.Close()
+		t.journal = nil
+	}
+
+	path := filepath.Join(t.dir, diskIndexFile)
+	tmp := path + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create disk tier index %s", tmp)
+	}
+
+	w := bufio.NewWriter(file)
+	encoder := json.NewEncoder(w)
+	for elem := t.lru.Back(); elem != nil && err == nil; elem = elem.Prev() {
+		err = encoder.Encode(diskJournalRecord{Op: "set", Entry: elem.Value.(*diskEntry)})
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return errors.Wrapf(err, "failed to write disk tier index %s", path)
+	}
+
+	t.journal, err = os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return errors.Wrapf(err, "failed to open disk tier index %s", path)
+	}
+	t.journalW = bufio.NewWriter(t.journal)
+	t.journalLines = len(t.entries)
+	return nil
+}
+
+// expired reports whether the entry expired by now
+func (e *diskEntry) expired(now int64) bool {
+	return e.ExpiresAt > 0 && now >= e.ExpiresAt
+}
+
+// sortByAccessTime orders entries most recently used first
+func sortByAccessTime(entries []*diskEntry) {
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].AccessTime != entries[j].AccessTime {
+			return entries[i].AccessTime > entries[j].AccessTime
+		}
+		return entries[i].Key < entries[j].Key
+	})
+}
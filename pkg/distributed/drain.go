@@ -0,0 +1,182 @@
+package distributed
+
+import (
+	"context"
+	"time"
+
+	"freightliner/pkg/helper/errors"
+)
+
+// Node modes reported in cluster status
+const (
+	NodeModeActive   = "active"
+	NodeModeDraining = "draining"
+	NodeModeDrained  = "drained"
+)
+
+// drainPollInterval is how often Drain checks for in-flight jobs
+const drainPollInterval = 200 * time.Millisecond
+
+// SchedulerStatus describes a node's scheduler for cluster status output
+type SchedulerStatus struct {
+	NodeID     string `json:"node_id"`
+	Mode       string `json:"mode"`
+	QueueDepth int64  `json:"queue_depth"`
+	InFlight   int64  `json:"in_flight"`
+	Peers      int    `json:"peers"`
+}
+
+// NewPeer creates a peer reached through client
+func NewPeer(id, address string, client PeerClient) *Peer {
+	return &Peer{ID: id, Address: address, client: client}
+}
+
+// Execute runs job on this node, counting it as in flight until it returns
+func (ws *WorkStealingScheduler) Execute(ctx context.Context, job *Job) error {
+	ws.inFlight.Add(1)
+	defer ws.inFlight.Add(-1)
+
+	if job.Task == nil {
+		return nil
+	}
+	return job.Task(ctx)
+}
+
+// Accept queues a job submitted by a peer. A draining node refuses it so the
+// peer schedules it elsewhere.
+func (ws *WorkStealingScheduler) Accept(job *Job) error {
+	if ws.draining.Load() {
+		return errors.NotSupportedf("node %s is draining and accepts no new jobs", ws.nodeID)
+	}
+	if !ws.localQueue.PushBack(job) {
+		return errors.New("local queue is full")
+	}
+	return nil
+}
+
+// Drain puts the node in maintenance mode: it stops taking new jobs, hands
+// its queued jobs to peers or the global queue, and waits until the jobs it
+// is running finish. The node is safe to shut down once Drain returns nil.
+// When ctx ends first the node keeps draining and the error is returned.
+func (ws *WorkStealingScheduler) Drain(ctx context.Context) error {
+	ws.draining.Store(true)
+	ws.logger.WithFields(map[string]interface{}{
+		"node_id":     ws.nodeID,
+		"queue_depth": ws.localQueue.Len(),
+		"in_flight":   ws.inFlight.Load(),
+	}).Info("Draining node")
+
+	transferred, requeued := 0, 0
+	for job := ws.localQueue.PopFront(); job != nil; job = ws.localQueue.PopFront() {
+		if ws.transferToPeer(ctx, job) {
+			transferred++
+			continue
+		}
+		if err := ws.globalQueue.Push(job); err != nil {
+			// Nowhere to put it: keep it and run it before shutting down
+			ws.localQueue.PushBack(job)
+			break
+		}
+		requeued++
+	}
+
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+	for ws.inFlight.Load() > 0 || ws.localQueue.Len() > 0 {
+		select {
+		case <-ctx.Done():
+			return errors.Wrapf(ctx.Err(), "node %s still has %d jobs in flight", ws.nodeID, ws.inFlight.Load())
+		case <-ticker.C:
+		}
+	}
+
+	ws.drained.Store(true)
+	ws.logger.WithFields(map[string]interface{}{
+		"node_id":     ws.nodeID,
+		"transferred": transferred,
+		"requeued":    requeued,
+	}).Info("Node drained, safe to shut down")
+	return nil
+}
+
+// Resume takes the node out of maintenance mode
+func (ws *WorkStealingScheduler) Resume() {
+	ws.draining.Store(false)
+	ws.drained.Store(false)
+	ws.logger.WithFields(map[string]interface{}{
+		"node_id": ws.nodeID,
+	}).Info("Node resumed")
+}
+
+// Mode returns the node's mode: active, draining or drained
+func (ws *WorkStealingScheduler) Mode() string {
+	switch {
+	case ws.drained.Load():
+		return NodeModeDrained
+	case ws.draining.Load():
+		return NodeModeDraining
+	default:
+		return NodeModeActive
+	}
+}
+
+// Status returns the scheduler's state for cluster status output
+func (ws *WorkStealingScheduler) Status() SchedulerStatus {
+	ws.mu.RLock()
+	peers := len(ws.peers)
+	ws.mu.RUnlock()
+
+	return SchedulerStatus{
+		NodeID:     ws.nodeID,
+		Mode:       ws.Mode(),
+		QueueDepth: ws.localQueue.Len(),
+		InFlight:   ws.inFlight.Load(),
+		Peers:      peers,
+	}
+}
+
+// transferToPeer submits a queued job to the least loaded peer with room
+func (ws *WorkStealingScheduler) transferToPeer(ctx context.Context, job *Job) bool {
+	ws.mu.RLock()
+	peers := ws.peers
+	ws.mu.RUnlock()
+
+	var target *Peer
+	lowest := -1
+	for _, peer := range peers {
+		if peer.client == nil {
+			continue
+		}
+		size, err := peer.GetQueueSize()
+		if err != nil {
+			continue
+		}
+		capacity, err := peer.GetCapacity()
+		if err != nil || size >= capacity {
+			continue
+		}
+		if lowest < 0 || size < lowest {
+			target, lowest = peer, size
+		}
+	}
+	if target == nil {
+		return false
+	}
+
+	submitCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if err := target.client.SubmitJob(submitCtx, job); err != nil {
+		ws.logger.WithFields(map[string]interface{}{
+			"job_id":  job.ID,
+			"peer_id": target.ID,
+			"error":   err.Error(),
+		}).Warn("Failed to transfer job to peer while draining")
+		return false
+	}
+
+	ws.logger.WithFields(map[string]interface{}{
+		"job_id":  job.ID,
+		"peer_id": target.ID,
+	}).Debug("Transferred job to peer while draining")
+	return true
+}
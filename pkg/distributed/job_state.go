@@ -0,0 +1,112 @@
+package distributed
+
+import (
+	"time"
+
+	"freightliner/pkg/helper/errors"
+)
+
+// Job statuses. A job moves pending → running → done or failed; done and
+// failed are recorded once as the job's CompletionRecord.
+const (
+	JobStatusPending = "pending"
+	JobStatusRunning = "running"
+	JobStatusDone    = "done"
+	JobStatusFailed  = "failed"
+)
+
+// completionRetention is how long completion records are kept to dedupe late
+// reports, measured against the newest completion so replays agree
+const completionRetention = 24 * time.Hour
+
+// CompletionRecord is the single recorded outcome of a job
+type CompletionRecord struct {
+	JobID       string    `json:"job_id"`
+	Status      string    `json:"status"`
+	NodeID      string    `json:"node_id"`
+	RetryCount  int       `json:"retry_count"`
+	Error       string    `json:"error,omitempty"`
+	CompletedAt time.Time `json:"completed_at"`
+}
+
+// jobStatusRank orders the statuses of one attempt
+func jobStatusRank(status string) (int, bool) {
+	switch status {
+	case JobStatusPending:
+		return 0, true
+	case JobStatusRunning:
+		return 1, true
+	case JobStatusDone, JobStatusFailed:
+		return 2, true
+	default:
+		return 0, false
+	}
+}
+
+// isTerminalJobStatus reports whether status ends a job
+func isTerminalJobStatus(status string) bool {
+	return status == JobStatusDone || status == JobStatusFailed
+}
+
+// acceptJobUpdate decides whether update replaces current, the job's state
+// in the FSM, or nil when the job is not active. Reports from an older
+// attempt or older than the current state are dropped without error, so
+// retried and reordered commands are no-ops. A completed job only takes a
+// new attempt after it failed.
+func acceptJobUpdate(current *JobState, completion *CompletionRecord, update *JobState) (bool, error) {
+	rank, ok := jobStatusRank(update.Status)
+	if !ok {
+		return false, errors.InvalidInputf("job %s has unknown status %q", update.ID, update.Status)
+	}
+
+	if completion != nil {
+		retry := completion.Status == JobStatusFailed && update.RetryCount > completion.RetryCount
+		return retry && !isTerminalJobStatus(update.Status), nil
+	}
+	if current == nil {
+		return true, nil
+	}
+
+	switch {
+	case update.RetryCount < current.RetryCount:
+		return false, nil
+	case update.RetryCount > current.RetryCount:
+		return true, nil
+	case update.UpdateTime.Before(current.UpdateTime):
+		return false, nil
+	}
+
+	currentRank, _ := jobStatusRank(current.Status)
+	if rank < currentRank {
+		return false, errors.InvalidInputf("job %s cannot move from %s back to %s without a retry",
+			update.ID, current.Status, update.Status)
+	}
+	return true, nil
+}
+
+// acceptCompletion decides whether record becomes the job's outcome. The
+// first completion of the current attempt wins; duplicates, e.g. from both
+// the node a job was stolen from and the thief, are dropped.
+func acceptCompletion(current *JobState, existing *CompletionRecord, record *CompletionRecord) (bool, error) {
+	if !isTerminalJobStatus(record.Status) {
+		return false, errors.InvalidInputf("job %s cannot complete with status %q", record.JobID, record.Status)
+	}
+	if existing != nil {
+		return false, nil
+	}
+	if current != nil && record.RetryCount < current.RetryCount {
+		return false, nil
+	}
+	return true, nil
+}
+
+// pruneCompletions drops completion records older than completionRetention
+// before now, the completion time of the newest record
+func pruneCompletions(completions map[string]*CompletionRecord, now time.Time) {
+	cutoff := now.Add(-completionRetention)
+	for jobID, record := range completions {
+		if record.CompletedAt.Before(cutoff) {
+			delete(completions, jobID)
+		}
+	}
+}
@@ -0,0 +1,258 @@
+package distributed
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"freightliner/pkg/helper/errors"
+	"freightliner/pkg/helper/log"
+	"freightliner/pkg/storage"
+
+	"github.com/opencontainers/go-digest"
+)
+
+const (
+	// blobLocationPrefix namespaces blob locations in the distributed cache index
+	blobLocationPrefix = "cas-location:"
+
+	// blobLocationTTL is how long, in seconds, a recorded blob location is kept
+	blobLocationTTL = int64(24 * time.Hour / time.Second)
+
+	// DefaultLocalityThreshold is the share of a job's blobs a node must hold
+	// before the job is placed on it rather than load balanced
+	DefaultLocalityThreshold = 0.5
+)
+
+// BlobLocator reports which cluster nodes hold a blob in their
+// content-addressable store
+type BlobLocator interface {
+	BlobLocations(ctx context.Context, digest string) ([]string, error)
+}
+
+// RecordBlobLocation adds nodeID to the nodes known to hold digest, typically
+// after the node stores the blob in its CAS
+func (dc *DistributedCache) RecordBlobLocation(ctx context.Context, digest, nodeID string) error {
+	dc.locationMu.Lock()
+	defer dc.locationMu.Unlock()
+
+	nodes, err := dc.BlobLocations(ctx, digest)
+	if err != nil {
+		return err
+	}
+	for _, existing := range nodes {
+		if existing == nodeID {
+			return nil
+		}
+	}
+	nodes = append(nodes, nodeID)
+	sort.Strings(nodes)
+
+	return dc.Set(ctx, blobLocationPrefix+digest, []byte(strings.Join(nodes, "\n")), blobLocationTTL)
+}
+
+// ForgetBlobLocation removes nodeID from the nodes known to hold digest, e.g.
+// when the blob is garbage collected from its CAS
+func (dc *DistributedCache) ForgetBlobLocation(ctx context.Context, digest, nodeID string) error {
+	dc.locationMu.Lock()
+	defer dc.locationMu.Unlock()
+
+	nodes, err := dc.BlobLocations(ctx, digest)
+	if err != nil {
+		return err
+	}
+	remaining := make([]string, 0, len(nodes))
+	for _, existing := range nodes {
+		if existing != nodeID {
+			remaining = append(remaining, existing)
+		}
+	}
+	if len(remaining) == len(nodes) {
+		return nil
+	}
+	if len(remaining) == 0 {
+		return dc.Delete(ctx, blobLocationPrefix+digest)
+	}
+
+	return dc.Set(ctx, blobLocationPrefix+digest, []byte(strings.Join(remaining, "\n")), blobLocationTTL)
+}
+
+// BlobLocations returns the nodes known to hold digest, or none when no
+// node has recorded it
+func (dc *DistributedCache) BlobLocations(ctx context.Context, digest string) ([]string, error) {
+	value, err := dc.Get(ctx, blobLocationPrefix+digest)
+	if err != nil {
+		if errors.Is(err, errors.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, errors.Wrap(err, "failed to look up blob location")
+	}
+	if len(value) == 0 {
+		return nil, nil
+	}
+	return strings.Split(string(value), "\n"), nil
+}
+
+// casLocations publishes the blobs a node's CAS stores and drops as held by
+// that node
+type casLocations struct {
+	cache  *DistributedCache
+	nodeID string
+	logger log.Logger
+}
+
+// BlobStored records d as held by the node
+func (l *casLocations) BlobStored(ctx context.Context, d digest.Digest) {
+	if err := l.cache.RecordBlobLocation(ctx, d.String(), l.nodeID); err != nil {
+		l.logger.WithFields(map[string]interface{}{
+			"digest":  d.String(),
+			"node_id": l.nodeID,
+			"error":   err.Error(),
+		}).Warn("Failed to record blob location")
+	}
+}
+
+// BlobRemoved forgets d as held by the node
+func (l *casLocations) BlobRemoved(ctx context.Context, d digest.Digest) {
+	if err := l.cache.ForgetBlobLocation(ctx, d.String(), l.nodeID); err != nil {
+		l.logger.WithFields(map[string]interface{}{
+			"digest":  d.String(),
+			"node_id": l.nodeID,
+			"error":   err.Error(),
+		}).Warn("Failed to forget blob location")
+	}
+}
+
+// EnableBlobLocality turns on locality-aware placement for this node: blobs
+// cas stores or drops are published to cache as held by this node, blobs it
+// already holds are published now, and jobs are placed using the locations
+// every node publishes (see SetBlobLocator).
+func (ws *WorkStealingScheduler) EnableBlobLocality(ctx context.Context, cache *DistributedCache, cas *storage.ContentAddressableStore, threshold float64) error {
+	locations := &casLocations{cache: cache, nodeID: ws.nodeID, logger: ws.logger}
+	cas.SetObserver(locations)
+
+	held, err := cas.List(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to list stored blobs")
+	}
+	for _, d := range held {
+		locations.BlobStored(ctx, d)
+	}
+
+	ws.SetBlobLocator(cache, threshold)
+	return nil
+}
+
+// SetBlobLocator enables locality-aware placement: a job listing its blobs
+// goes to the node holding the largest share of them, when that share
+// reaches threshold. A threshold of 0 uses DefaultLocalityThreshold.
+func (ws *WorkStealingScheduler) SetBlobLocator(locator BlobLocator, threshold float64) {
+	if threshold <= 0 {
+		threshold = DefaultLocalityThreshold
+	}
+
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	ws.locator = locator
+	ws.localityThreshold = threshold
+}
+
+// scheduleByLocality places job on the node holding most of its blobs and
+// reports whether it did. Jobs fall back to normal scheduling when no node
+// holds enough of them or the node cannot take the job.
+func (ws *WorkStealingScheduler) scheduleByLocality(job *Job) bool {
+	ws.mu.RLock()
+	locator := ws.locator
+	threshold := ws.localityThreshold
+	peers := ws.peers
+	ws.mu.RUnlock()
+
+	if locator == nil || len(job.Blobs) == 0 {
+		return false
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	nodeID, share := ws.bestLocalityNode(ctx, locator, job.Blobs)
+	if nodeID == "" || share < threshold {
+		return false
+	}
+
+	fields := map[string]interface{}{
+		"job_id":      job.ID,
+		"node_id":     nodeID,
+		"blob_share":  share,
+		"blobs_total": len(job.Blobs),
+	}
+
+	if nodeID == ws.nodeID {
+		if ws.draining.Load() || !ws.localQueue.PushBack(job) {
+			return false
+		}
+		ws.metrics.LocalHits.Add(1)
+		ws.metrics.LocalityPlacements.Add(1)
+		ws.logger.WithFields(fields).Debug("Job scheduled to local queue for blob locality")
+		return true
+	}
+
+	for _, peer := range peers {
+		if peer.ID != nodeID || peer.client == nil {
+			continue
+		}
+
+		size, err := peer.GetQueueSize()
+		if err != nil {
+			return false
+		}
+		capacity, err := peer.GetCapacity()
+		if err != nil || size >= capacity {
+			return false
+		}
+
+		if err := peer.client.SubmitJob(ctx, job); err != nil {
+			fields["error"] = err.Error()
+			ws.logger.WithFields(fields).Debug("Failed to submit job to node holding its blobs")
+			return false
+		}
+		ws.metrics.LocalityPlacements.Add(1)
+		ws.logger.WithFields(fields).Debug("Job scheduled to peer for blob locality")
+		return true
+	}
+
+	return false
+}
+
+// bestLocalityNode returns the node holding the largest share of blobs, and
+// that share. Ties go to this node, then to the lowest node ID.
+func (ws *WorkStealingScheduler) bestLocalityNode(ctx context.Context, locator BlobLocator, blobs []string) (string, float64) {
+	held := make(map[string]int)
+	for _, digest := range blobs {
+		nodes, err := locator.BlobLocations(ctx, digest)
+		if err != nil {
+			// An unknown location counts as held by no node
+			continue
+		}
+		for _, node := range nodes {
+			held[node]++
+		}
+	}
+
+	best, bestCount := "", 0
+	for node, count := range held {
+		switch {
+		case count > bestCount:
+		case count == bestCount && node == ws.nodeID:
+		case count == bestCount && best != ws.nodeID && node < best:
+		default:
+			continue
+		}
+		best, bestCount = node, count
+	}
+
+	if best == "" {
+		return "", 0
+	}
+	return best, float64(bestCount) / float64(len(blobs))
+}
@@ -8,6 +8,7 @@ import (
 	"net"
 	"os"
 	"path/filepath"
+	"sort"
 	"sync"
 	"time"
 
@@ -36,6 +37,8 @@ type RaftCoordinator struct {
 type ReplicationFSM struct {
 	jobs        map[string]*JobState
 	checkpoints map[string]*CheckpointState
+	completions map[string]*CompletionRecord
+	rateShares  map[string]map[string]float64 // host -> node -> requests/s
 	mu          sync.RWMutex
 	logger      log.Logger
 }
@@ -61,11 +64,18 @@ type CheckpointState struct {
 
 // Command represents a state change command
 type Command struct {
-	Type       string           `json:"type"`
-	JobID      string           `json:"job_id"`
-	Job        *JobState        `json:"job,omitempty"`
-	Checkpoint *CheckpointState `json:"checkpoint,omitempty"`
-	Data       json.RawMessage  `json:"data,omitempty"`
+	Type       string            `json:"type"`
+	JobID      string            `json:"job_id"`
+	Job        *JobState         `json:"job,omitempty"`
+	Checkpoint *CheckpointState  `json:"checkpoint,omitempty"`
+	Completion *CompletionRecord `json:"completion,omitempty"`
+	Data       json.RawMessage   `json:"data,omitempty"`
+}
+
+// rateSharesUpdate is the payload of a set_rate_shares command
+type rateSharesUpdate struct {
+	Host   string             `json:"host"`
+	Shares map[string]float64 `json:"shares"`
 }
 
 // RaftConfig holds configuration for Raft coordinator
@@ -95,6 +105,8 @@ func NewRaftCoordinator(config RaftConfig) (*RaftCoordinator, error) {
 	fsm := &ReplicationFSM{
 		jobs:        make(map[string]*JobState),
 		checkpoints: make(map[string]*CheckpointState),
+		completions: make(map[string]*CompletionRecord),
+		rateShares:  make(map[string]map[string]float64),
 		logger:      config.Logger,
 	}
 
@@ -200,6 +212,12 @@ func (f *ReplicationFSM) Apply(l *raft.Log) interface{} {
 	switch cmd.Type {
 	case "create_job":
 		if cmd.Job != nil {
+			if _, exists := f.jobs[cmd.JobID]; exists || f.completions[cmd.JobID] != nil {
+				f.logger.WithFields(map[string]interface{}{
+					"job_id": cmd.JobID,
+				}).Debug("Duplicate job creation ignored")
+				return nil
+			}
 			f.jobs[cmd.JobID] = cmd.Job
 			f.logger.WithFields(map[string]interface{}{
 				"job_id": cmd.JobID,
@@ -209,6 +227,31 @@ func (f *ReplicationFSM) Apply(l *raft.Log) interface{} {
 
 	case "update_job":
 		if cmd.Job != nil {
+			if isTerminalJobStatus(cmd.Job.Status) {
+				return f.applyCompletion(&CompletionRecord{
+					JobID:       cmd.JobID,
+					Status:      cmd.Job.Status,
+					NodeID:      cmd.Job.NodeID,
+					RetryCount:  cmd.Job.RetryCount,
+					CompletedAt: cmd.Job.UpdateTime,
+				})
+			}
+
+			accept, err := acceptJobUpdate(f.jobs[cmd.JobID], f.completions[cmd.JobID], cmd.Job)
+			if err != nil {
+				return err
+			}
+			if !accept {
+				f.logger.WithFields(map[string]interface{}{
+					"job_id":      cmd.JobID,
+					"status":      cmd.Job.Status,
+					"retry_count": cmd.Job.RetryCount,
+				}).Debug("Stale job update ignored")
+				return nil
+			}
+
+			// A retry reopens a failed job
+			delete(f.completions, cmd.JobID)
 			f.jobs[cmd.JobID] = cmd.Job
 			f.logger.WithFields(map[string]interface{}{
 				"job_id": cmd.JobID,
@@ -217,10 +260,16 @@ func (f *ReplicationFSM) Apply(l *raft.Log) interface{} {
 		}
 
 	case "complete_job":
-		delete(f.jobs, cmd.JobID)
-		f.logger.WithFields(map[string]interface{}{
-			"job_id": cmd.JobID,
-		}).Info("Job completed and removed from FSM")
+		record := cmd.Completion
+		if record == nil {
+			// Logged before completion records were kept
+			record = &CompletionRecord{JobID: cmd.JobID, Status: JobStatusDone}
+			if job, exists := f.jobs[cmd.JobID]; exists {
+				record.NodeID = job.NodeID
+				record.RetryCount = job.RetryCount
+			}
+		}
+		return f.applyCompletion(record)
 
 	case "update_checkpoint":
 		if cmd.Checkpoint != nil {
@@ -237,6 +286,18 @@ func (f *ReplicationFSM) Apply(l *raft.Log) interface{} {
 			"job_id": cmd.JobID,
 		}).Debug("Checkpoint deleted from FSM")
 
+	case "set_rate_shares":
+		var update rateSharesUpdate
+		if err := json.Unmarshal(cmd.Data, &update); err != nil {
+			f.logger.Error("Failed to unmarshal rate shares", err)
+			return err
+		}
+		f.rateShares[update.Host] = update.Shares
+		f.logger.WithFields(map[string]interface{}{
+			"host":  update.Host,
+			"nodes": len(update.Shares),
+		}).Debug("Rate shares updated in FSM")
+
 	default:
 		f.logger.WithFields(map[string]interface{}{
 			"type": cmd.Type,
@@ -246,6 +307,33 @@ func (f *ReplicationFSM) Apply(l *raft.Log) interface{} {
 	return nil
 }
 
+// applyCompletion records a job's outcome once and removes it from the
+// active jobs. Callers hold f.mu.
+func (f *ReplicationFSM) applyCompletion(record *CompletionRecord) interface{} {
+	accept, err := acceptCompletion(f.jobs[record.JobID], f.completions[record.JobID], record)
+	if err != nil {
+		return err
+	}
+	if !accept {
+		f.logger.WithFields(map[string]interface{}{
+			"job_id":  record.JobID,
+			"status":  record.Status,
+			"node_id": record.NodeID,
+		}).Debug("Duplicate job completion ignored")
+		return nil
+	}
+
+	delete(f.jobs, record.JobID)
+	f.completions[record.JobID] = record
+	pruneCompletions(f.completions, record.CompletedAt)
+
+	f.logger.WithFields(map[string]interface{}{
+		"job_id": record.JobID,
+		"status": record.Status,
+	}).Info("Job completed and removed from FSM")
+	return nil
+}
+
 // Snapshot implements the FSM interface
 func (f *ReplicationFSM) Snapshot() (raft.FSMSnapshot, error) {
 	f.mu.RLock()
@@ -264,9 +352,26 @@ func (f *ReplicationFSM) Snapshot() (raft.FSMSnapshot, error) {
 		checkpoints[k] = &cpCopy
 	}
 
+	completions := make(map[string]*CompletionRecord, len(f.completions))
+	for k, v := range f.completions {
+		recordCopy := *v
+		completions[k] = &recordCopy
+	}
+
+	rateShares := make(map[string]map[string]float64, len(f.rateShares))
+	for host, shares := range f.rateShares {
+		sharesCopy := make(map[string]float64, len(shares))
+		for node, share := range shares {
+			sharesCopy[node] = share
+		}
+		rateShares[host] = sharesCopy
+	}
+
 	return &fsmSnapshot{
 		jobs:        jobs,
 		checkpoints: checkpoints,
+		completions: completions,
+		rateShares:  rateShares,
 	}, nil
 }
 
@@ -275,8 +380,10 @@ func (f *ReplicationFSM) Restore(rc io.ReadCloser) error {
 	defer rc.Close()
 
 	var state struct {
-		Jobs        map[string]*JobState        `json:"jobs"`
-		Checkpoints map[string]*CheckpointState `json:"checkpoints"`
+		Jobs        map[string]*JobState          `json:"jobs"`
+		Checkpoints map[string]*CheckpointState   `json:"checkpoints"`
+		Completions map[string]*CompletionRecord  `json:"completions"`
+		RateShares  map[string]map[string]float64 `json:"rate_shares"`
 	}
 
 	if err := json.NewDecoder(rc).Decode(&state); err != nil {
@@ -288,6 +395,16 @@ func (f *ReplicationFSM) Restore(rc io.ReadCloser) error {
 
 	f.jobs = state.Jobs
 	f.checkpoints = state.Checkpoints
+	f.completions = state.Completions
+	f.rateShares = state.RateShares
+
+	// Snapshots taken before completions and rate shares existed
+	if f.completions == nil {
+		f.completions = make(map[string]*CompletionRecord)
+	}
+	if f.rateShares == nil {
+		f.rateShares = make(map[string]map[string]float64)
+	}
 
 	f.logger.WithFields(map[string]interface{}{
 		"jobs":        len(f.jobs),
@@ -301,16 +418,22 @@ func (f *ReplicationFSM) Restore(rc io.ReadCloser) error {
 type fsmSnapshot struct {
 	jobs        map[string]*JobState
 	checkpoints map[string]*CheckpointState
+	completions map[string]*CompletionRecord
+	rateShares  map[string]map[string]float64
 }
 
 func (s *fsmSnapshot) Persist(sink raft.SnapshotSink) error {
 	err := func() error {
 		state := struct {
-			Jobs        map[string]*JobState        `json:"jobs"`
-			Checkpoints map[string]*CheckpointState `json:"checkpoints"`
+			Jobs        map[string]*JobState          `json:"jobs"`
+			Checkpoints map[string]*CheckpointState   `json:"checkpoints"`
+			Completions map[string]*CompletionRecord  `json:"completions"`
+			RateShares  map[string]map[string]float64 `json:"rate_shares"`
 		}{
 			Jobs:        s.jobs,
 			Checkpoints: s.checkpoints,
+			Completions: s.completions,
+			RateShares:  s.rateShares,
 		}
 
 		b, err := json.Marshal(state)
@@ -365,15 +488,31 @@ func (rc *RaftCoordinator) UpdateJob(ctx context.Context, job *JobState) error {
 	return rc.applyCommand(ctx, cmd)
 }
 
-// CompleteJob marks a job as complete
+// CompleteJob marks a job as done
 func (rc *RaftCoordinator) CompleteJob(ctx context.Context, jobID string) error {
+	record := &CompletionRecord{JobID: jobID, Status: JobStatusDone}
+	if job, exists := rc.GetJob(jobID); exists {
+		record.NodeID = job.NodeID
+		record.RetryCount = job.RetryCount
+	}
+	return rc.ReportCompletion(ctx, record)
+}
+
+// ReportCompletion records a job's outcome, done or failed. Only the first
+// report for the job's current attempt is kept; duplicates, e.g. from a
+// stolen or retried job, succeed without changing it.
+func (rc *RaftCoordinator) ReportCompletion(ctx context.Context, record *CompletionRecord) error {
 	if rc.raft.State() != raft.Leader {
 		return errors.New("not the leader")
 	}
+	if record.CompletedAt.IsZero() {
+		record.CompletedAt = time.Now()
+	}
 
 	cmd := Command{
-		Type:  "complete_job",
-		JobID: jobID,
+		Type:       "complete_job",
+		JobID:      record.JobID,
+		Completion: record,
 	}
 
 	return rc.applyCommand(ctx, cmd)
@@ -417,6 +556,19 @@ func (rc *RaftCoordinator) ListJobs() []*JobState {
 	return jobs
 }
 
+// GetCompletion returns the recorded outcome of a completed job
+func (rc *RaftCoordinator) GetCompletion(jobID string) (*CompletionRecord, bool) {
+	rc.fsm.mu.RLock()
+	defer rc.fsm.mu.RUnlock()
+
+	record, exists := rc.fsm.completions[jobID]
+	if !exists {
+		return nil, false
+	}
+	recordCopy := *record
+	return &recordCopy, true
+}
+
 // GetCheckpoint retrieves checkpoint state
 func (rc *RaftCoordinator) GetCheckpoint(jobID string) (*CheckpointState, bool) {
 	rc.fsm.mu.RLock()
@@ -426,6 +578,54 @@ func (rc *RaftCoordinator) GetCheckpoint(jobID string) (*CheckpointState, bool)
 	return cp, exists
 }
 
+// SetRateShares stores each node's share of the rate limit budget for host
+func (rc *RaftCoordinator) SetRateShares(ctx context.Context, host string, shares map[string]float64) error {
+	if rc.raft.State() != raft.Leader {
+		return errors.New("not the leader")
+	}
+
+	data, err := json.Marshal(rateSharesUpdate{Host: host, Shares: shares})
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal rate shares")
+	}
+
+	cmd := Command{
+		Type: "set_rate_shares",
+		Data: data,
+	}
+
+	return rc.applyCommand(ctx, cmd)
+}
+
+// RateShares returns each node's share of the rate limit budget for host
+func (rc *RaftCoordinator) RateShares(host string) map[string]float64 {
+	rc.fsm.mu.RLock()
+	defer rc.fsm.mu.RUnlock()
+
+	shares := make(map[string]float64, len(rc.fsm.rateShares[host]))
+	for node, share := range rc.fsm.rateShares[host] {
+		shares[node] = share
+	}
+	return shares
+}
+
+// Members returns the IDs of the cluster's voting nodes
+func (rc *RaftCoordinator) Members() ([]string, error) {
+	future := rc.raft.GetConfiguration()
+	if err := future.Error(); err != nil {
+		return nil, errors.Wrap(err, "failed to get raft configuration")
+	}
+
+	members := make([]string, 0, len(future.Configuration().Servers))
+	for _, server := range future.Configuration().Servers {
+		if server.Suffrage == raft.Voter {
+			members = append(members, string(server.ID))
+		}
+	}
+	sort.Strings(members)
+	return members, nil
+}
+
 // IsLeader returns true if this node is the leader
 func (rc *RaftCoordinator) IsLeader() bool {
 	return rc.raft.State() == raft.Leader
@@ -479,6 +679,11 @@ func (rc *RaftCoordinator) applyCommand(ctx context.Context, cmd Command) error
 		return errors.Wrap(err, "failed to apply command")
 	}
 
+	// The FSM rejects invalid state transitions by returning an error
+	if err, ok := future.Response().(error); ok && err != nil {
+		return err
+	}
+
 	return nil
 }
 
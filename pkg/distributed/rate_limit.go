@@ -0,0 +1,363 @@
+package distributed
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"freightliner/pkg/helper/errors"
+	"freightliner/pkg/helper/log"
+)
+
+const (
+	// rateDemandPrefix namespaces per-node request demand in the distributed cache
+	rateDemandPrefix = "rate-demand:"
+
+	// rateReservedShare is the part of a host budget split evenly across nodes,
+	// so an idle node can start sending before the next rebalance
+	rateReservedShare = 0.2
+
+	// rateShareTolerance is how far, as a fraction of the budget, a share may
+	// drift before the leader writes a new allocation
+	rateShareTolerance = 0.01
+
+	// DefaultRebalanceInterval is how often budgets are reallocated by demand
+	DefaultRebalanceInterval = 5 * time.Second
+)
+
+// HostBudget is the request budget the whole cluster shares for one upstream
+// registry host
+type HostBudget struct {
+	Host              string  `yaml:"host" json:"host"`
+	RequestsPerSecond float64 `yaml:"requests_per_second" json:"requests_per_second"`
+	Burst             int     `yaml:"burst" json:"burst"`
+}
+
+// RateShareStore holds each node's share of the host budgets, in requests per
+// second. Only the leader writes shares; every node reads its own.
+type RateShareStore interface {
+	IsLeader() bool
+	Members() ([]string, error)
+	RateShares(host string) map[string]float64
+	SetRateShares(ctx context.Context, host string, shares map[string]float64) error
+}
+
+// DemandStore shares the nodes' recent request rates with the leader
+type DemandStore interface {
+	Get(ctx context.Context, key string) ([]byte, error)
+	Set(ctx context.Context, key string, value []byte, ttl int64) error
+}
+
+// ClusterRateLimiterConfig configures a ClusterRateLimiter
+type ClusterRateLimiterConfig struct {
+	NodeID  string
+	Budgets []HostBudget
+	Shares  RateShareStore
+	// Demand is optional; without it budgets are split evenly
+	Demand            DemandStore
+	RebalanceInterval time.Duration
+	Logger            log.Logger
+}
+
+// ClusterRateLimiter rate limits requests to upstream registry hosts against
+// budgets shared by the whole cluster. The leader splits each budget across
+// the nodes by their recent demand and stores the shares through Raft; each
+// node enforces its own share with a local token bucket.
+type ClusterRateLimiter struct {
+	nodeID   string
+	budgets  map[string]HostBudget
+	shares   RateShareStore
+	demand   DemandStore
+	interval time.Duration
+	logger   log.Logger
+
+	limiters map[string]*rate.Limiter
+	requests map[string]*atomic.Int64
+
+	stopCh   chan struct{}
+	stopOnce sync.Once
+}
+
+// NewClusterRateLimiter creates a cluster rate limiter. Until the leader has
+// allocated shares each node takes an even share of every budget.
+func NewClusterRateLimiter(config ClusterRateLimiterConfig) (*ClusterRateLimiter, error) {
+	if config.NodeID == "" {
+		return nil, errors.InvalidInputf("node ID is required")
+	}
+	if config.Shares == nil {
+		return nil, errors.InvalidInputf("rate share store is required")
+	}
+	if config.Logger == nil {
+		config.Logger = log.NewBasicLogger(log.InfoLevel)
+	}
+	if config.RebalanceInterval <= 0 {
+		config.RebalanceInterval = DefaultRebalanceInterval
+	}
+
+	l := &ClusterRateLimiter{
+		nodeID:   config.NodeID,
+		budgets:  make(map[string]HostBudget, len(config.Budgets)),
+		shares:   config.Shares,
+		demand:   config.Demand,
+		interval: config.RebalanceInterval,
+		logger:   config.Logger,
+		limiters: make(map[string]*rate.Limiter, len(config.Budgets)),
+		requests: make(map[string]*atomic.Int64, len(config.Budgets)),
+		stopCh:   make(chan struct{}),
+	}
+
+	for _, budget := range config.Budgets {
+		host := strings.ToLower(budget.Host)
+		if host == "" {
+			return nil, errors.InvalidInputf("rate limit budget needs a host")
+		}
+		if budget.RequestsPerSecond <= 0 {
+			return nil, errors.InvalidInputf("rate limit budget for %s must be positive", budget.Host)
+		}
+		if _, exists := l.budgets[host]; exists {
+			return nil, errors.InvalidInputf("duplicate rate limit budget for %s", budget.Host)
+		}
+		if budget.Burst <= 0 {
+			budget.Burst = int(math.Max(1, math.Ceil(budget.RequestsPerSecond)))
+		}
+		budget.Host = host
+		l.budgets[host] = budget
+		l.limiters[host] = rate.NewLimiter(0, 1)
+		l.requests[host] = &atomic.Int64{}
+	}
+
+	l.refresh()
+	return l, nil
+}
+
+// Wait blocks until a request to host fits this node's share of the host's
+// budget. Hosts without a budget are not limited.
+func (l *ClusterRateLimiter) Wait(ctx context.Context, host string) error {
+	host = strings.ToLower(host)
+	limiter, ok := l.limiters[host]
+	if !ok {
+		return nil
+	}
+
+	l.requests[host].Add(1)
+	if err := limiter.Wait(ctx); err != nil {
+		return errors.Wrapf(err, "rate limit wait for %s failed", host)
+	}
+	return nil
+}
+
+// Transport wraps base so every request waits for the cluster budget of its host
+func (l *ClusterRateLimiter) Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &rateLimitedTransport{limiter: l, base: base}
+}
+
+// Shares returns this node's current share of each host budget, in requests
+// per second
+func (l *ClusterRateLimiter) Shares() map[string]float64 {
+	shares := make(map[string]float64, len(l.limiters))
+	for host, limiter := range l.limiters {
+		shares[host] = float64(limiter.Limit())
+	}
+	return shares
+}
+
+// Start rebalances the budgets every rebalance interval until Stop is called
+func (l *ClusterRateLimiter) Start() {
+	go func() {
+		ticker := time.NewTicker(l.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-l.stopCh:
+				return
+			case <-ticker.C:
+				ctx, cancel := context.WithTimeout(context.Background(), l.interval)
+				if err := l.Sync(ctx); err != nil {
+					l.logger.WithFields(map[string]interface{}{
+						"node_id": l.nodeID,
+						"error":   err.Error(),
+					}).Warn("Failed to rebalance cluster rate limits")
+				}
+				cancel()
+			}
+		}
+	}()
+}
+
+// Stop stops rebalancing
+func (l *ClusterRateLimiter) Stop() {
+	l.stopOnce.Do(func() { close(l.stopCh) })
+}
+
+// Sync publishes this node's demand since the last sync, reallocates the
+// budgets when this node is the leader, and applies this node's shares
+func (l *ClusterRateLimiter) Sync(ctx context.Context) error {
+	var syncErr error
+	if err := l.publishDemand(ctx); err != nil {
+		syncErr = err
+	}
+	if l.shares.IsLeader() {
+		if err := l.rebalance(ctx); err != nil {
+			syncErr = err
+		}
+	}
+	l.refresh()
+	return syncErr
+}
+
+// publishDemand stores this node's request rate per host since the last call
+func (l *ClusterRateLimiter) publishDemand(ctx context.Context) error {
+	if l.demand == nil {
+		return nil
+	}
+
+	ttl := int64(math.Max(1, math.Ceil(3*l.interval.Seconds())))
+	for host, counter := range l.requests {
+		perSecond := float64(counter.Swap(0)) / l.interval.Seconds()
+		value := []byte(strconv.FormatFloat(perSecond, 'f', -1, 64))
+		if err := l.demand.Set(ctx, rateDemandKey(host, l.nodeID), value, ttl); err != nil {
+			return errors.Wrapf(err, "failed to publish rate demand for %s", host)
+		}
+	}
+	return nil
+}
+
+// rebalance splits every host budget across the cluster members by demand
+func (l *ClusterRateLimiter) rebalance(ctx context.Context) error {
+	members, err := l.shares.Members()
+	if err != nil {
+		return errors.Wrap(err, "failed to list cluster members")
+	}
+	if len(members) == 0 {
+		return nil
+	}
+
+	for host, budget := range l.budgets {
+		demand := make(map[string]float64, len(members))
+		for _, member := range members {
+			demand[member] = l.memberDemand(ctx, host, member)
+		}
+
+		shares := allocateShares(budget.RequestsPerSecond, members, demand)
+		if sharesClose(l.shares.RateShares(host), shares, budget.RequestsPerSecond*rateShareTolerance) {
+			continue
+		}
+		if err := l.shares.SetRateShares(ctx, host, shares); err != nil {
+			return errors.Wrapf(err, "failed to store rate shares for %s", host)
+		}
+
+		l.logger.WithFields(map[string]interface{}{
+			"host":   host,
+			"budget": budget.RequestsPerSecond,
+			"shares": shares,
+		}).Debug("Rebalanced cluster rate limit")
+	}
+	return nil
+}
+
+// memberDemand returns a member's published request rate for host, or 0 when
+// it has published none
+func (l *ClusterRateLimiter) memberDemand(ctx context.Context, host, member string) float64 {
+	if l.demand == nil {
+		return 0
+	}
+	value, err := l.demand.Get(ctx, rateDemandKey(host, member))
+	if err != nil {
+		return 0
+	}
+	perSecond, err := strconv.ParseFloat(string(value), 64)
+	if err != nil || perSecond < 0 {
+		return 0
+	}
+	return perSecond
+}
+
+// refresh applies this node's stored shares to its local limiters. A node
+// with no stored share takes an even split of the budget.
+func (l *ClusterRateLimiter) refresh() {
+	nodes := 1
+	if members, err := l.shares.Members(); err == nil && len(members) > 0 {
+		nodes = len(members)
+	}
+
+	for host, budget := range l.budgets {
+		share, ok := l.shares.RateShares(host)[l.nodeID]
+		if !ok {
+			share = budget.RequestsPerSecond / float64(nodes)
+		}
+		burst := int(math.Max(1, math.Floor(float64(budget.Burst)*share/budget.RequestsPerSecond)))
+
+		limiter := l.limiters[host]
+		if float64(limiter.Limit()) == share && limiter.Burst() == burst {
+			continue
+		}
+		limiter.SetLimit(rate.Limit(share))
+		limiter.SetBurst(burst)
+	}
+}
+
+// allocateShares splits total across members: rateReservedShare of it evenly,
+// the rest in proportion to demand, or evenly when there is no demand
+func allocateShares(total float64, members []string, demand map[string]float64) map[string]float64 {
+	reserved := total * rateReservedShare / float64(len(members))
+	pool := total - reserved*float64(len(members))
+
+	var totalDemand float64
+	for _, member := range members {
+		totalDemand += demand[member]
+	}
+
+	shares := make(map[string]float64, len(members))
+	for _, member := range members {
+		if totalDemand == 0 {
+			shares[member] = reserved + pool/float64(len(members))
+			continue
+		}
+		shares[member] = reserved + pool*demand[member]/totalDemand
+	}
+	return shares
+}
+
+// sharesClose reports whether two allocations cover the same members with
+// shares within tolerance of each other
+func sharesClose(current, next map[string]float64, tolerance float64) bool {
+	if len(current) != len(next) {
+		return false
+	}
+	for member, share := range next {
+		existing, ok := current[member]
+		if !ok || math.Abs(existing-share) > tolerance {
+			return false
+		}
+	}
+	return true
+}
+
+func rateDemandKey(host, nodeID string) string {
+	return rateDemandPrefix + host + ":" + nodeID
+}
+
+// rateLimitedTransport waits for the cluster budget before each request
+type rateLimitedTransport struct {
+	limiter *ClusterRateLimiter
+	base    http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *rateLimitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.limiter.Wait(req.Context(), req.URL.Host); err != nil {
+		return nil, err
+	}
+	return t.base.RoundTrip(req)
+}
@@ -0,0 +1,203 @@
+package distributed
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// rebalanceTimeout bounds the rebalance started when a peer joins
+const rebalanceTimeout = 30 * time.Second
+
+// NodeLoad is the queued work and queue capacity of one node
+type NodeLoad struct {
+	NodeID     string `json:"node_id"`
+	QueueDepth int    `json:"queue_depth"`
+	Capacity   int    `json:"capacity"`
+	// Error is set when the node could not be asked for its load
+	Error string `json:"error,omitempty"`
+}
+
+// WorkloadSnapshot is the queued work of this node and its peers
+type WorkloadSnapshot struct {
+	NodeID  string     `json:"node_id"`
+	Nodes   []NodeLoad `json:"nodes"`
+	TakenAt time.Time  `json:"taken_at"`
+}
+
+// RebalanceResult describes the jobs a rebalance moved to peers
+type RebalanceResult struct {
+	Moved    map[string]int   `json:"moved"`
+	Total    int              `json:"total"`
+	Snapshot WorkloadSnapshot `json:"snapshot"`
+}
+
+// Snapshot returns the queue depth and capacity of this node and its peers
+func (ws *WorkStealingScheduler) Snapshot() WorkloadSnapshot {
+	ws.mu.RLock()
+	peers := ws.peers
+	ws.mu.RUnlock()
+
+	snapshot := WorkloadSnapshot{
+		NodeID:  ws.nodeID,
+		TakenAt: time.Now(),
+		Nodes: []NodeLoad{{
+			NodeID:     ws.nodeID,
+			QueueDepth: int(ws.localQueue.Len()),
+			Capacity:   int(ws.localQueue.Cap()),
+		}},
+	}
+
+	for _, peer := range peers {
+		load := NodeLoad{NodeID: peer.ID}
+		if peer.client == nil && peer.queue == nil {
+			load.Error = "no connection to peer"
+			snapshot.Nodes = append(snapshot.Nodes, load)
+			continue
+		}
+
+		var err error
+		if load.QueueDepth, err = peer.GetQueueSize(); err == nil {
+			load.Capacity, err = peer.GetCapacity()
+		}
+		if err != nil {
+			load.Error = err.Error()
+		}
+		snapshot.Nodes = append(snapshot.Nodes, load)
+	}
+	return snapshot
+}
+
+// Rebalance moves queued jobs from this node to peers until every reachable
+// node holds a share of the cluster's queued jobs proportional to its queue
+// capacity. Each node only gives away its own surplus, so peers rebalance
+// their queues the same way. A draining node does not rebalance.
+func (ws *WorkStealingScheduler) Rebalance(ctx context.Context) (*RebalanceResult, error) {
+	snapshot := ws.Snapshot()
+	result := &RebalanceResult{Moved: make(map[string]int), Snapshot: snapshot}
+	if ws.draining.Load() {
+		return result, nil
+	}
+
+	targets := rebalanceTargets(snapshot.Nodes)
+	surplus := snapshot.Nodes[0].QueueDepth - targets[ws.nodeID]
+	if surplus <= 0 {
+		return result, nil
+	}
+
+	ws.mu.RLock()
+	peers := make(map[string]*Peer, len(ws.peers))
+	for _, peer := range ws.peers {
+		peers[peer.ID] = peer
+	}
+	ws.mu.RUnlock()
+
+	for _, load := range snapshot.Nodes[1:] {
+		peer := peers[load.NodeID]
+		deficit := targets[load.NodeID] - load.QueueDepth
+		if peer == nil || peer.client == nil || deficit <= 0 {
+			continue
+		}
+
+		for ; deficit > 0 && surplus > 0; deficit-- {
+			if err := ctx.Err(); err != nil {
+				return result, err
+			}
+			job := ws.localQueue.PopBack()
+			if job == nil {
+				surplus = 0
+				break
+			}
+
+			submitCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+			err := peer.client.SubmitJob(submitCtx, job)
+			cancel()
+			if err != nil {
+				ws.localQueue.PushBack(job)
+				ws.logger.WithFields(map[string]interface{}{
+					"job_id":  job.ID,
+					"peer_id": peer.ID,
+					"error":   err.Error(),
+				}).Warn("Failed to move job to peer while rebalancing")
+				break
+			}
+
+			result.Moved[peer.ID]++
+			result.Total++
+			surplus--
+		}
+	}
+
+	ws.metrics.Rebalances.Add(1)
+	ws.metrics.JobsRebalanced.Add(uint64(result.Total))
+	if result.Total > 0 {
+		ws.logger.WithFields(map[string]interface{}{
+			"node_id": ws.nodeID,
+			"moved":   result.Total,
+			"peers":   result.Moved,
+		}).Info("Rebalanced queued jobs across the cluster")
+	}
+	return result, nil
+}
+
+// rebalanceOnJoin rebalances in the background after peer joined, if this
+// node has queued jobs to share
+func (ws *WorkStealingScheduler) rebalanceOnJoin(peer *Peer) {
+	if peer.client == nil || ws.draining.Load() || ws.localQueue.Len() == 0 {
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), rebalanceTimeout)
+		defer cancel()
+
+		if _, err := ws.Rebalance(ctx); err != nil {
+			ws.logger.WithFields(map[string]interface{}{
+				"peer_id": peer.ID,
+				"error":   err.Error(),
+			}).Warn("Failed to rebalance after peer joined")
+		}
+	}()
+}
+
+// rebalanceTargets splits the queued jobs of the reachable nodes in
+// proportion to their capacity, giving remainders to the largest fractions
+func rebalanceTargets(nodes []NodeLoad) map[string]int {
+	total, capacity := 0, 0
+	for _, node := range nodes {
+		if node.Error == "" && node.Capacity > 0 {
+			total += node.QueueDepth
+			capacity += node.Capacity
+		}
+	}
+
+	targets := make(map[string]int, len(nodes))
+	if capacity == 0 {
+		return targets
+	}
+
+	type remainder struct {
+		nodeID   string
+		fraction float64
+	}
+	remainders := make([]remainder, 0, len(nodes))
+	assigned := 0
+	for _, node := range nodes {
+		if node.Error != "" || node.Capacity <= 0 {
+			continue
+		}
+		exact := float64(total) * float64(node.Capacity) / float64(capacity)
+		targets[node.NodeID] = int(exact)
+		assigned += int(exact)
+		remainders = append(remainders, remainder{node.NodeID, exact - float64(int(exact))})
+	}
+
+	sort.SliceStable(remainders, func(i, j int) bool {
+		return remainders[i].fraction > remainders[j].fraction
+	})
+	for i := 0; assigned < total; i++ {
+		targets[remainders[i%len(remainders)].nodeID]++
+		assigned++
+	}
+	return targets
+}
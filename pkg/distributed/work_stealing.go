@@ -22,6 +22,15 @@ type WorkStealingScheduler struct {
 	metrics     *SchedulerMetrics
 	stopped     atomic.Bool
 	stopCh      chan struct{}
+
+	// Locality-aware placement, disabled while locator is nil
+	locator           BlobLocator
+	localityThreshold float64
+
+	// Maintenance mode: a draining node takes no new jobs
+	draining atomic.Bool
+	drained  atomic.Bool
+	inFlight atomic.Int64
 }
 
 // SchedulerMetrics tracks scheduler performance
@@ -33,6 +42,12 @@ type SchedulerMetrics struct {
 	StealAttempts  atomic.Uint64
 	StealSuccesses atomic.Uint64
 	AvgQueueDepth  atomic.Uint64
+	// Jobs placed on the node holding most of their blobs
+	LocalityPlacements atomic.Uint64
+
+	// Rebalance runs and the queued jobs they moved to peers
+	Rebalances     atomic.Uint64
+	JobsRebalanced atomic.Uint64
 }
 
 // Peer represents a remote scheduler node
@@ -63,6 +78,8 @@ type Job struct {
 	Metadata   map[string]string
 	RetryCount int
 	MaxRetries int
+	// Blobs lists the digests the job reads, for locality-aware placement
+	Blobs []string
 }
 
 // lockFreeDeque implements a lock-free double-ended queue
@@ -263,8 +280,13 @@ func (q *ConcurrentQueue) Len() int {
 func (ws *WorkStealingScheduler) Schedule(job *Job) error {
 	ws.metrics.JobsScheduled.Add(1)
 
-	// Try local queue first (fast path)
-	if ws.localQueue.PushBack(job) {
+	// Prefer the node that already holds most of the job's blobs
+	if ws.scheduleByLocality(job) {
+		return nil
+	}
+
+	// Try local queue first (fast path), unless the node is draining
+	if !ws.draining.Load() && ws.localQueue.PushBack(job) {
 		ws.metrics.LocalHits.Add(1)
 		ws.logger.WithFields(map[string]interface{}{
 			"job_id": job.ID,
@@ -328,6 +350,11 @@ func (ws *WorkStealingScheduler) StealWork(ctx context.Context) *Job {
 		return job
 	}
 
+	// A draining node only finishes the jobs it already holds
+	if ws.draining.Load() {
+		return nil
+	}
+
 	// Try to steal from busiest peer
 	ws.mu.RLock()
 	peers := ws.peers
@@ -377,17 +404,20 @@ func (ws *WorkStealingScheduler) StealWork(ctx context.Context) *Job {
 	return nil
 }
 
-// AddPeer adds a peer to the scheduler
+// AddPeer adds a peer to the scheduler and, when this node has queued jobs,
+// rebalances them onto the new peer in the background
 func (ws *WorkStealingScheduler) AddPeer(peer *Peer) {
 	ws.mu.Lock()
-	defer ws.mu.Unlock()
-
 	ws.peers = append(ws.peers, peer)
+	ws.mu.Unlock()
+
 	ws.logger.WithFields(map[string]interface{}{
 		"peer_id":  peer.ID,
 		"address":  peer.Address,
 		"capacity": peer.capacity,
 	}).Info("Added peer to scheduler")
+
+	ws.rebalanceOnJoin(peer)
 }
 
 // RemovePeer removes a peer from the scheduler
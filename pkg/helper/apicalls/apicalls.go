@@ -0,0 +1,310 @@
+// Package apicalls counts the registry API calls the process makes, by
+// registry and call type, so their cost can be attributed to runs and rules,
+// and optionally caps their rate.
+package apicalls
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Call types counted for distribution API requests
+const (
+	CallPing           = "ping"
+	CallToken          = "token"
+	CallCatalog        = "catalog"
+	CallTagList        = "tag_list"
+	CallManifestGet    = "manifest_get"
+	CallManifestHead   = "manifest_head"
+	CallManifestPut    = "manifest_put"
+	CallManifestDelete = "manifest_delete"
+	CallBlobGet        = "blob_get"
+	CallBlobHead       = "blob_head"
+	CallBlobDelete     = "blob_delete"
+	CallBlobMount      = "blob_mount"
+	CallBlobUpload     = "blob_upload_start"
+	CallBlobPatch      = "blob_upload_chunk"
+	CallBlobPut        = "blob_put"
+	CallReferrers      = "referrers"
+	CallOther          = "other"
+)
+
+var registryAPICalls = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "freightliner_registry_api_calls_total",
+		Help: "Registry API calls made, by registry and call type",
+	},
+	[]string{"registry", "call"},
+)
+
+func init() {
+	prometheus.MustRegister(registryAPICalls)
+}
+
+var installOnce sync.Once
+
+// Install counts the calls made through the shared default transports. It
+// must run before registry clients are created so their transports count.
+func Install() {
+	installOnce.Do(func() {
+		http.DefaultTransport = Transport(http.DefaultTransport)
+		remote.DefaultTransport = Transport(remote.DefaultTransport)
+	})
+}
+
+// Transport counts the requests made through inner. Requests that already
+// passed a counting transport are not counted again.
+func Transport(inner http.RoundTripper) http.RoundTripper {
+	if _, ok := inner.(*transport); ok {
+		return inner
+	}
+	return &transport{inner: inner}
+}
+
+// Unwrap returns the transport wrapped by Transport, or rt itself
+func Unwrap(rt http.RoundTripper) http.RoundTripper {
+	if t, ok := rt.(*transport); ok {
+		return t.inner
+	}
+	return rt
+}
+
+// countedKey marks requests a counting transport has already counted
+type countedKey struct{}
+
+// transport counts registry API calls before passing them on
+type transport struct {
+	inner http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	if ctx.Value(countedKey{}) != nil {
+		return t.inner.RoundTrip(req)
+	}
+
+	registry, call := req.URL.Host, Classify(req)
+	registryAPICalls.WithLabelValues(registry, call).Inc()
+	for _, counter := range countersFrom(ctx) {
+		counter.Add(registry, call)
+	}
+	if err := wait(ctx, registry); err != nil {
+		return nil, err
+	}
+	return t.inner.RoundTrip(req.WithContext(context.WithValue(ctx, countedKey{}, true)))
+}
+
+// Classify returns the call type of a registry request
+func Classify(req *http.Request) string {
+	path := req.URL.Path
+	// Token endpoints live outside /v2/ on most registries, but under it on
+	// some, e.g. /v2/token on GCR and /v2/auth on Quay
+	if strings.HasSuffix(path, "/token") || path == "/v2/auth" {
+		return CallToken
+	}
+
+	switch {
+	case !strings.HasPrefix(path, "/v2/") && path != "/v2":
+		return CallOther
+	case path == "/v2" || path == "/v2/":
+		return CallPing
+	case path == "/v2/_catalog":
+		return CallCatalog
+	case strings.HasSuffix(path, "/tags/list"):
+		return CallTagList
+	case strings.Contains(path, "/referrers/"):
+		return CallReferrers
+	case strings.Contains(path, "/manifests/"):
+		switch req.Method {
+		case http.MethodGet:
+			return CallManifestGet
+		case http.MethodHead:
+			return CallManifestHead
+		case http.MethodPut:
+			return CallManifestPut
+		case http.MethodDelete:
+			return CallManifestDelete
+		}
+	case strings.Contains(path, "/blobs/uploads/"):
+		switch req.Method {
+		case http.MethodPost:
+			if req.URL.Query().Get("mount") != "" {
+				return CallBlobMount
+			}
+			return CallBlobUpload
+		case http.MethodPatch:
+			return CallBlobPatch
+		case http.MethodPut:
+			return CallBlobPut
+		}
+	case strings.Contains(path, "/blobs/"):
+		switch req.Method {
+		case http.MethodGet:
+			return CallBlobGet
+		case http.MethodHead:
+			return CallBlobHead
+		case http.MethodDelete:
+			return CallBlobDelete
+		}
+	}
+	return CallOther
+}
+
+// Counts holds call counts by registry and call type
+type Counts map[string]map[string]int64
+
+// Total returns the number of calls counted
+func (c Counts) Total() int64 {
+	var total int64
+	for _, calls := range c {
+		for _, n := range calls {
+			total += n
+		}
+	}
+	return total
+}
+
+// Registries returns the registries called, sorted
+func (c Counts) Registries() []string {
+	registries := make([]string, 0, len(c))
+	for registry := range c {
+		registries = append(registries, registry)
+	}
+	sort.Strings(registries)
+	return registries
+}
+
+// Counter counts the calls made under the contexts it is attached to. A nil
+// Counter counts nothing.
+type Counter struct {
+	mu    sync.Mutex
+	calls Counts
+}
+
+// NewCounter creates an empty counter
+func NewCounter() *Counter {
+	return &Counter{calls: make(Counts)}
+}
+
+// Add counts one call of type call to registry
+func (c *Counter) Add(registry, call string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	calls, ok := c.calls[registry]
+	if !ok {
+		calls = make(map[string]int64)
+		c.calls[registry] = calls
+	}
+	calls[call]++
+}
+
+// Counts returns a copy of the counts so far
+func (c *Counter) Counts() Counts {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	counts := make(Counts, len(c.calls))
+	for registry, calls := range c.calls {
+		copied := make(map[string]int64, len(calls))
+		for call, n := range calls {
+			copied[call] = n
+		}
+		counts[registry] = copied
+	}
+	return counts
+}
+
+// MarshalJSON renders the counts so far
+func (c *Counter) MarshalJSON() ([]byte, error) {
+	return json.Marshal(c.Counts())
+}
+
+// Group keeps a counter per key, such as a rule name
+type Group struct {
+	mu       sync.Mutex
+	counters map[string]*Counter
+}
+
+// NewGroup creates an empty group
+func NewGroup() *Group {
+	return &Group{counters: make(map[string]*Counter)}
+}
+
+// Counter returns the counter for key, creating it on first use. A nil Group
+// returns a nil Counter.
+func (g *Group) Counter(key string) *Counter {
+	if g == nil {
+		return nil
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	counter, ok := g.counters[key]
+	if !ok {
+		counter = NewCounter()
+		g.counters[key] = counter
+	}
+	return counter
+}
+
+// Counts returns a copy of the counts of every key that made calls
+func (g *Group) Counts() map[string]Counts {
+	if g == nil {
+		return nil
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	counts := make(map[string]Counts, len(g.counters))
+	for key, counter := range g.counters {
+		if c := counter.Counts(); len(c) > 0 {
+			counts[key] = c
+		}
+	}
+	return counts
+}
+
+// countersKey holds the counters attached to a context
+type countersKey struct{}
+
+// WithCounter returns a context whose calls count towards counter as well as
+// any counters ctx already carries
+func WithCounter(ctx context.Context, counter *Counter) context.Context {
+	if counter == nil {
+		return ctx
+	}
+	parents := countersFrom(ctx)
+	counters := make([]*Counter, 0, len(parents)+1)
+	counters = append(append(counters, parents...), counter)
+	return context.WithValue(ctx, countersKey{}, counters)
+}
+
+// FromContext returns the counter most recently attached to ctx, or nil
+func FromContext(ctx context.Context) *Counter {
+	counters := countersFrom(ctx)
+	if len(counters) == 0 {
+		return nil
+	}
+	return counters[len(counters)-1]
+}
+
+// countersFrom returns the counters attached to ctx
+func countersFrom(ctx context.Context) []*Counter {
+	counters, _ := ctx.Value(countersKey{}).([]*Counter)
+	return counters
+}
@@ -0,0 +1,120 @@
+package apicalls
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		method string
+		path   string
+		want   string
+	}{
+		{http.MethodGet, "/v2/", CallPing},
+		{http.MethodGet, "/token?scope=repository:app:pull", CallToken},
+		{http.MethodGet, "/v2/token", CallToken},
+		{http.MethodPost, "/oauth2/token", CallToken},
+		{http.MethodGet, "/v2/_catalog?n=100", CallCatalog},
+		{http.MethodGet, "/v2/team/app/tags/list", CallTagList},
+		{http.MethodGet, "/v2/app/manifests/latest", CallManifestGet},
+		{http.MethodHead, "/v2/app/manifests/latest", CallManifestHead},
+		{http.MethodPut, "/v2/app/manifests/latest", CallManifestPut},
+		{http.MethodDelete, "/v2/app/manifests/sha256:abc", CallManifestDelete},
+		{http.MethodGet, "/v2/app/blobs/sha256:abc", CallBlobGet},
+		{http.MethodHead, "/v2/app/blobs/sha256:abc", CallBlobHead},
+		{http.MethodPost, "/v2/app/blobs/uploads/", CallBlobUpload},
+		{http.MethodPost, "/v2/app/blobs/uploads/?mount=sha256:abc&from=base", CallBlobMount},
+		{http.MethodPatch, "/v2/app/blobs/uploads/123", CallBlobPatch},
+		{http.MethodPut, "/v2/app/blobs/uploads/123?digest=sha256:abc", CallBlobPut},
+		{http.MethodGet, "/v2/app/referrers/sha256:abc", CallReferrers},
+		{http.MethodPost, "/api/v1/repository", CallOther},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "https://registry.example.com"+tt.path, nil)
+			assert.Equal(t, tt.want, Classify(req))
+		})
+	}
+}
+
+func TestTransportCountsIntoContextCounters(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	host, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	run, rule := NewCounter(), NewCounter()
+	ctx := WithCounter(WithCounter(context.Background(), run), rule)
+	assert.Same(t, rule, FromContext(ctx))
+
+	// Nested counting transports count each request once
+	client := &http.Client{Transport: &transport{inner: &transport{inner: http.DefaultTransport}}}
+	for _, path := range []string{"/v2/app/manifests/v1", "/v2/app/manifests/v2", "/v2/app/tags/list"} {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+path, nil)
+		require.NoError(t, err)
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+	}
+
+	want := Counts{host.Host: {CallManifestGet: 2, CallTagList: 1}}
+	assert.Equal(t, want, run.Counts())
+	assert.Equal(t, want, rule.Counts())
+	assert.Equal(t, int64(3), run.Counts().Total())
+
+	data, err := json.Marshal(run)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"`+host.Host+`":{"manifest_get":2,"tag_list":1}}`, string(data))
+}
+
+func TestGroup(t *testing.T) {
+	group := NewGroup()
+	group.Counter("nginx").Add("registry.example.com", CallManifestHead)
+	group.Counter("nginx").Add("registry.example.com", CallManifestHead)
+	group.Counter("redis")
+
+	assert.Equal(t, map[string]Counts{
+		"nginx": {"registry.example.com": {CallManifestHead: 2}},
+	}, group.Counts(), "keys without calls are left out")
+
+	var nilGroup *Group
+	nilGroup.Counter("nginx").Add("registry.example.com", CallBlobGet)
+	assert.Nil(t, nilGroup.Counts())
+}
+
+func TestLimiterCapsRatePerRegistry(t *testing.T) {
+	limiter := NewLimiter(20)
+	ctx := context.Background()
+
+	// The first second's calls pass at once; later ones wait their turn
+	start := time.Now()
+	for i := 0; i < 25; i++ {
+		require.NoError(t, limiter.Wait(ctx, "registry.example.com"))
+	}
+	assert.GreaterOrEqual(t, time.Since(start), 200*time.Millisecond)
+
+	// Other registries have a budget of their own
+	start = time.Now()
+	require.NoError(t, limiter.Wait(ctx, "other.example.com"))
+	assert.Less(t, time.Since(start), 50*time.Millisecond)
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	assert.Error(t, limiter.Wait(canceled, "registry.example.com"))
+
+	var unlimited *Limiter
+	assert.Nil(t, NewLimiter(0))
+	assert.NoError(t, unlimited.Wait(canceled, "registry.example.com"))
+}
@@ -0,0 +1,77 @@
+package apicalls
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"golang.org/x/time/rate"
+)
+
+// Limiter caps the API calls made to each registry. A nil Limiter does not
+// limit.
+type Limiter struct {
+	rps float64
+
+	mu       sync.Mutex
+	registry map[string]*rate.Limiter
+}
+
+// NewLimiter creates a limiter allowing rps calls per second to each
+// registry, or nil when rps is not positive
+func NewLimiter(rps float64) *Limiter {
+	if rps <= 0 {
+		return nil
+	}
+	return &Limiter{rps: rps, registry: make(map[string]*rate.Limiter)}
+}
+
+// Wait blocks until a call to registry is allowed or ctx is done
+func (l *Limiter) Wait(ctx context.Context, registry string) error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	limiter, ok := l.registry[registry]
+	if !ok {
+		// A burst of one second's calls lets short runs start at full speed
+		burst := int(l.rps)
+		if burst < 1 {
+			burst = 1
+		}
+		limiter = rate.NewLimiter(rate.Limit(l.rps), burst)
+		l.registry[registry] = limiter
+	}
+	l.mu.Unlock()
+	return limiter.Wait(ctx)
+}
+
+// processLimiter caps the calls of the whole process, see SetLimit
+var processLimiter atomic.Pointer[Limiter]
+
+// SetLimit caps the calls made through counting transports to rps per second
+// per registry. Zero removes the cap.
+func SetLimit(rps float64) {
+	processLimiter.Store(NewLimiter(rps))
+}
+
+// limiterKey holds the limiter attached to a context
+type limiterKey struct{}
+
+// WithLimiter returns a context whose calls also wait for limiter, such as
+// one shared by the tasks of a rule
+func WithLimiter(ctx context.Context, limiter *Limiter) context.Context {
+	if limiter == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, limiterKey{}, limiter)
+}
+
+// wait blocks until both the process and the context limiter allow a call
+func wait(ctx context.Context, registry string) error {
+	if err := processLimiter.Load().Wait(ctx, registry); err != nil {
+		return err
+	}
+	limiter, _ := ctx.Value(limiterKey{}).(*Limiter)
+	return limiter.Wait(ctx, registry)
+}
@@ -0,0 +1,171 @@
+// Package clockskew detects a host clock that disagrees with the registries
+// the process talks to. A skewed clock makes registry auth fail confusingly,
+// e.g. with tokens that look expired or certificates that are not yet valid,
+// so the Date header of every registry response is compared with the local
+// clock and a clear warning is logged once the two drift apart.
+package clockskew
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"freightliner/pkg/helper/log"
+
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// DefaultThreshold is how far the clocks may differ before a warning; Date
+// headers only have second precision and responses take time to arrive
+const DefaultThreshold = 30 * time.Second
+
+// Options configure Install
+type Options struct {
+	// Threshold is the skew that is warned about (default DefaultThreshold)
+	Threshold time.Duration
+
+	// Compensate corrects token expiry calculations by the detected skew
+	Compensate bool
+
+	// Logger receives the warnings
+	Logger log.Logger
+}
+
+var (
+	installOnce sync.Once
+
+	mu      sync.RWMutex
+	options Options
+
+	// offset is the latest registry clock minus local clock beyond the
+	// threshold, in nanoseconds; zero while the clocks agree
+	offset atomic.Int64
+
+	// warned holds the hosts whose skew has been logged
+	warned sync.Map
+)
+
+// Install watches the responses made through the shared default transports.
+// It must run before registry clients are created so their transports are
+// watched.
+func Install(opts Options) {
+	if opts.Threshold <= 0 {
+		opts.Threshold = DefaultThreshold
+	}
+	if opts.Logger == nil {
+		opts.Logger = log.GetGlobalLogger()
+	}
+	mu.Lock()
+	options = opts
+	mu.Unlock()
+
+	installOnce.Do(func() {
+		http.DefaultTransport = Transport(http.DefaultTransport)
+		remote.DefaultTransport = Transport(remote.DefaultTransport)
+	})
+}
+
+// Offset returns how far the registries' clock is ahead of the local clock,
+// negative when it is behind, or zero while the clocks agree
+func Offset() time.Duration {
+	return time.Duration(offset.Load())
+}
+
+// Expiry returns when a token issued at issuedAt, an RFC 3339 time on the
+// registry clock, expires on the local clock. Without compensation, or when
+// issuedAt is missing, the lifetime counts from now.
+func Expiry(issuedAt string, lifetime time.Duration) time.Time {
+	mu.RLock()
+	compensate := options.Compensate
+	mu.RUnlock()
+
+	if compensate && issuedAt != "" {
+		if issued, err := time.Parse(time.RFC3339, issuedAt); err == nil {
+			return issued.Add(-Offset()).Add(lifetime)
+		}
+	}
+	return time.Now().Add(lifetime)
+}
+
+// Observe compares the Date header of resp, from host, with received on the
+// local clock and warns the first time host's clock is skewed
+func Observe(host string, resp *http.Response, received time.Time) {
+	date := resp.Header.Get("Date")
+	if date == "" {
+		return
+	}
+	serverTime, err := http.ParseTime(date)
+	if err != nil {
+		return
+	}
+
+	mu.RLock()
+	opts := options
+	mu.RUnlock()
+	if opts.Logger == nil {
+		return
+	}
+
+	// Date is truncated to the second, so its midpoint is the best estimate
+	skew := serverTime.Add(500 * time.Millisecond).Sub(received)
+	if skew.Abs() < opts.Threshold {
+		offset.Store(0)
+		return
+	}
+	offset.Store(int64(skew.Round(time.Second)))
+
+	fields := map[string]interface{}{
+		"registry":   host,
+		"skew":       skew.Round(time.Second).String(),
+		"compensate": opts.Compensate,
+	}
+	if _, seen := warned.LoadOrStore(host, true); !seen {
+		opts.Logger.WithFields(fields).Warn("Host clock differs from the registry clock; " +
+			"token and certificate validity checks may fail. Sync the host clock (e.g. NTP) " +
+			"or enable clock skew compensation")
+	}
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		opts.Logger.WithFields(fields).Warn("Registry rejected credentials while the host clock is skewed")
+	}
+}
+
+// Transport watches the Date headers of the responses to requests made
+// through inner
+func Transport(inner http.RoundTripper) http.RoundTripper {
+	if _, ok := inner.(*transport); ok {
+		return inner
+	}
+	return &transport{inner: inner}
+}
+
+// Unwrap returns the transport wrapped by Transport, or rt itself
+func Unwrap(rt http.RoundTripper) http.RoundTripper {
+	if t, ok := rt.(*transport); ok {
+		return t.inner
+	}
+	return rt
+}
+
+// observedKey marks requests a watching transport already observes
+type observedKey struct{}
+
+// transport observes the clock of every registry it talks to
+type transport struct {
+	inner http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	if ctx.Value(observedKey{}) != nil {
+		return t.inner.RoundTrip(req)
+	}
+
+	resp, err := t.inner.RoundTrip(req.WithContext(context.WithValue(ctx, observedKey{}, true)))
+	if err == nil {
+		Observe(req.URL.Host, resp, time.Now())
+	}
+	return resp, err
+}
@@ -0,0 +1,100 @@
+package clockskew
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"freightliner/pkg/helper/log"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// useOptions installs opts for the test without wrapping the default
+// transports, resetting the package state afterwards
+func useOptions(t *testing.T, opts Options) {
+	mu.Lock()
+	options = opts
+	mu.Unlock()
+	t.Cleanup(func() {
+		mu.Lock()
+		options = Options{}
+		mu.Unlock()
+		offset.Store(0)
+		warned = sync.Map{}
+	})
+}
+
+func TestTransportWarnsOnSkew(t *testing.T) {
+	var buf bytes.Buffer
+	useOptions(t, Options{
+		Threshold: DefaultThreshold,
+		Logger:    log.NewBasicLoggerWithWriter(log.InfoLevel, &buf),
+	})
+
+	registryTime := time.Now().Add(-5 * time.Minute)
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Date", registryTime.UTC().Format(http.TimeFormat))
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: Transport(http.DefaultTransport)}
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(server.URL + "/v2/")
+		require.NoError(t, err)
+		resp.Body.Close()
+	}
+
+	assert.Equal(t, 1, strings.Count(buf.String(), "Host clock differs from the registry clock"),
+		"the skew is logged once per registry")
+	assert.InDelta(t, -5*time.Minute, Offset(), float64(2*time.Second))
+
+	status = http.StatusUnauthorized
+	resp, err := client.Get(server.URL + "/v2/")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Contains(t, buf.String(), "Registry rejected credentials while the host clock is skewed")
+
+	registryTime = time.Now()
+	status = http.StatusOK
+	resp, err = client.Get(server.URL + "/v2/")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Zero(t, Offset(), "the offset clears once the clocks agree")
+}
+
+func TestExpiry(t *testing.T) {
+	useOptions(t, Options{Threshold: DefaultThreshold})
+	offset.Store(int64(-10 * time.Minute))
+
+	issuedAt := time.Now().Add(-10 * time.Minute).UTC().Format(time.RFC3339)
+
+	expires := Expiry(issuedAt, time.Hour)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), expires, 2*time.Second,
+		"without compensation the lifetime counts from now")
+
+	mu.Lock()
+	options.Compensate = true
+	mu.Unlock()
+
+	expires = Expiry(issuedAt, time.Hour)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), expires, 2*time.Second,
+		"issued_at is converted to the local clock")
+
+	expires = Expiry("", time.Hour)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), expires, 2*time.Second)
+}
+
+func TestUnwrap(t *testing.T) {
+	wrapped := Transport(http.DefaultTransport)
+	assert.Same(t, wrapped, Transport(wrapped), "wrapping is idempotent")
+	assert.Same(t, http.DefaultTransport, Unwrap(wrapped))
+	assert.Same(t, http.DefaultTransport, Unwrap(http.DefaultTransport))
+}
@@ -0,0 +1,241 @@
+// Package httplog implements --log-http: every registry request the process
+// makes to the selected hosts is logged with its response line, latency,
+// headers and the request IDs registries return, so vendor-specific registry
+// misbehavior can be debugged and reported without a packet capture.
+// Credentials in headers and in signed URL parameters are redacted.
+package httplog
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"freightliner/pkg/helper/log"
+
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// AllHosts selects every registry host
+const AllHosts = "*"
+
+// redacted replaces secret values in the log
+const redacted = "REDACTED"
+
+// Options configure Install
+type Options struct {
+	// Hosts are the registry hosts whose requests are logged, as host or
+	// host:port, with path.Match wildcards; empty or AllHosts logs all
+	Hosts []string
+
+	// Logger receives the request lines
+	Logger log.Logger
+}
+
+// sensitiveHeaders hold credentials and are never logged in full
+var sensitiveHeaders = map[string]bool{
+	"Authorization":        true,
+	"Proxy-Authorization":  true,
+	"Cookie":               true,
+	"Set-Cookie":           true,
+	"X-Amz-Security-Token": true,
+	"X-Registry-Auth":      true,
+}
+
+// sensitiveParams hold credentials in the query of token and signed blob
+// URLs
+var sensitiveParams = map[string]bool{
+	"access_token":         true,
+	"refresh_token":        true,
+	"password":             true,
+	"token":                true,
+	"sig":                  true,
+	"signature":            true,
+	"x-amz-credential":     true,
+	"x-amz-security-token": true,
+	"x-amz-signature":      true,
+	"x-goog-credential":    true,
+	"x-goog-signature":     true,
+}
+
+// requestIDHeaders are the response headers registries and their CDNs use to
+// identify a request in support cases
+var requestIDHeaders = []string{
+	"X-Request-Id",
+	"Docker-Request-Id",
+	"X-Amzn-Requestid",
+	"X-Amz-Request-Id",
+	"X-Amz-Cf-Id",
+	"X-Cloud-Trace-Context",
+	"X-Guploader-Uploadid",
+	"X-Ms-Request-Id",
+	"X-Ms-Correlation-Request-Id",
+	"Cf-Ray",
+}
+
+var (
+	installOnce sync.Once
+
+	mu      sync.RWMutex
+	options Options
+)
+
+// Install logs the requests made through the shared default transports. It
+// must run before registry clients are created so their transports log.
+func Install(opts Options) {
+	if opts.Logger == nil {
+		opts.Logger = log.GetGlobalLogger()
+	}
+	mu.Lock()
+	options = opts
+	mu.Unlock()
+
+	installOnce.Do(func() {
+		http.DefaultTransport = Transport(http.DefaultTransport)
+		remote.DefaultTransport = Transport(remote.DefaultTransport)
+	})
+}
+
+// Transport logs the requests made through inner to the installed hosts
+func Transport(inner http.RoundTripper) http.RoundTripper {
+	if _, ok := inner.(*transport); ok {
+		return inner
+	}
+	return &transport{inner: inner}
+}
+
+// Unwrap returns the transport wrapped by Transport, or rt itself
+func Unwrap(rt http.RoundTripper) http.RoundTripper {
+	if t, ok := rt.(*transport); ok {
+		return t.inner
+	}
+	return rt
+}
+
+// loggedKey marks requests a logging transport already logs
+type loggedKey struct{}
+
+// transport logs each request and its response
+type transport struct {
+	inner http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	mu.RLock()
+	opts := options
+	mu.RUnlock()
+	if opts.Logger == nil || ctx.Value(loggedKey{}) != nil || !MatchHost(opts.Hosts, req.URL.Host) {
+		return t.inner.RoundTrip(req)
+	}
+
+	start := time.Now()
+	resp, err := t.inner.RoundTrip(req.WithContext(context.WithValue(ctx, loggedKey{}, true)))
+	fields := map[string]interface{}{
+		"registry":        req.URL.Host,
+		"method":          req.Method,
+		"url":             RedactURL(req.URL),
+		"latency":         time.Since(start).Round(time.Microsecond).String(),
+		"request_headers": RedactHeaders(req.Header),
+	}
+	if err != nil {
+		fields["error"] = err.Error()
+		opts.Logger.WithFields(fields).Info("HTTP request failed")
+		return resp, err
+	}
+
+	fields["status"] = resp.StatusCode
+	fields["proto"] = resp.Proto
+	fields["response_headers"] = RedactHeaders(resp.Header)
+	if ids := RequestIDs(resp.Header); len(ids) > 0 {
+		fields["request_ids"] = ids
+	}
+	opts.Logger.WithFields(fields).Info("HTTP request")
+	return resp, nil
+}
+
+// MatchHost reports whether requests to host are logged for hosts. A
+// pattern without a port matches the host on any port.
+func MatchHost(hosts []string, host string) bool {
+	if len(hosts) == 0 {
+		return true
+	}
+	hostname := host
+	if i := strings.LastIndex(host, ":"); i > strings.LastIndex(host, "]") {
+		hostname = host[:i]
+	}
+	for _, pattern := range hosts {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if pattern == AllHosts {
+			return true
+		}
+		for _, candidate := range []string{strings.ToLower(host), strings.ToLower(hostname)} {
+			if ok, _ := path.Match(pattern, candidate); ok || pattern == candidate {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// RedactURL renders u without its user info and with credential query
+// parameters replaced
+func RedactURL(u *url.URL) string {
+	c := *u
+	c.User = nil
+	if c.RawQuery != "" {
+		query := c.Query()
+		for key := range query {
+			if sensitiveParams[strings.ToLower(key)] {
+				query[key] = []string{redacted}
+			}
+		}
+		c.RawQuery = query.Encode()
+	}
+	return c.String()
+}
+
+// RedactHeaders flattens header into one value per name, keeping the scheme
+// of credential headers, e.g. "Bearer REDACTED", and dropping their values.
+// Signed redirect locations lose their credential parameters.
+func RedactHeaders(header http.Header) map[string]string {
+	flat := make(map[string]string, len(header))
+	for name, values := range header {
+		value := strings.Join(values, ", ")
+		switch canonical := http.CanonicalHeaderKey(name); {
+		case sensitiveHeaders[canonical]:
+			value = redactCredential(value)
+		case canonical == "Location":
+			// Blob redirects point at signed storage URLs
+			if u, err := url.Parse(value); err == nil {
+				value = RedactURL(u)
+			}
+		}
+		flat[name] = value
+	}
+	return flat
+}
+
+// redactCredential replaces a credential, keeping its authorization scheme
+func redactCredential(value string) string {
+	if scheme, _, ok := strings.Cut(value, " "); ok && !strings.Contains(scheme, "=") {
+		return scheme + " " + redacted
+	}
+	return redacted
+}
+
+// RequestIDs returns the request identifiers in a response's headers, by
+// header name
+func RequestIDs(header http.Header) map[string]string {
+	ids := make(map[string]string)
+	for _, name := range requestIDHeaders {
+		if value := header.Get(name); value != "" {
+			ids[name] = value
+		}
+	}
+	return ids
+}
@@ -0,0 +1,81 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestSlogLogger(t *testing.T) {
+	var buf bytes.Buffer
+	handler := slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelInfo})
+	logger := NewSlogLogger(handler).WithFields(map[string]interface{}{"repository": "app"})
+
+	logger.Debug("hidden")
+	logger.Error("Copy failed", errors.New("boom"), map[string]interface{}{"tag": "v1"})
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("expected 1 line, got %d: %q", len(lines), buf.String())
+	}
+
+	var record map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &record); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"level":      "ERROR",
+		"msg":        "Copy failed",
+		"error":      "boom",
+		"tag":        "v1",
+		"repository": "app",
+	}
+	for k, v := range want {
+		if record[k] != v {
+			t.Errorf("record[%q] = %v, want %v", k, record[k], v)
+		}
+	}
+}
+
+func TestZapLogger(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	logger := NewZapLogger(core).WithField("repository", "app")
+
+	logger.Debug("hidden")
+	logger.Warn("Slow registry", map[string]interface{}{"latency_ms": 1200})
+	logger.Error("Copy failed", errors.New("boom"))
+
+	entries := logs.AllUntimed()
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(entries))
+	}
+	if entries[0].Level != zapcore.WarnLevel || entries[0].Message != "Slow registry" {
+		t.Errorf("unexpected first entry: %+v", entries[0].Entry)
+	}
+	fields := entries[0].ContextMap()
+	if fields["repository"] != "app" || fields["latency_ms"] != int64(1200) {
+		t.Errorf("unexpected fields: %v", fields)
+	}
+	if entries[1].ContextMap()["error"] != "boom" {
+		t.Errorf("expected error field, got %v", entries[1].ContextMap())
+	}
+}
+
+func TestZapLoggerWithSamplingCore(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	logger := NewZapLogger(zapcore.NewSamplerWithOptions(core, time.Second, 1, 0))
+
+	for i := 0; i < 5; i++ {
+		logger.Info("Copied tag")
+	}
+	if got := logs.Len(); got != 1 {
+		t.Errorf("expected the zap sampler to keep 1 entry, got %d", got)
+	}
+}
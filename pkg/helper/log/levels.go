@@ -0,0 +1,253 @@
+package log
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// LevelSpec is a default level with per-package overrides, written as
+// "info" or "info,copy=debug,tree=warn". A package key matches the last
+// elements of a package path, so "copy" matches freightliner/pkg/copy and
+// "tree/checkpoint" matches freightliner/pkg/tree/checkpoint. Subpackages
+// inherit their parent's level unless they have a key of their own.
+type LevelSpec struct {
+	Default  Level
+	Packages map[string]Level
+}
+
+// ParseLevelSpec parses a level specification. An empty spec is info.
+func ParseLevelSpec(spec string) (LevelSpec, error) {
+	result := LevelSpec{Default: InfoLevel}
+	defaultSet := false
+
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		key, value, hasKey := strings.Cut(entry, "=")
+		level, err := parseLevelStrict(strings.TrimSpace(value))
+		if !hasKey {
+			level, err = parseLevelStrict(key)
+		}
+		if err != nil {
+			return LevelSpec{}, err
+		}
+
+		if !hasKey {
+			if defaultSet {
+				return LevelSpec{}, fmt.Errorf("log level %q sets the default level twice", spec)
+			}
+			result.Default = level
+			defaultSet = true
+			continue
+		}
+
+		key = strings.Trim(strings.TrimSpace(key), "/")
+		if key == "" {
+			return LevelSpec{}, fmt.Errorf("log level %q has an override without a package", entry)
+		}
+		if result.Packages == nil {
+			result.Packages = make(map[string]Level)
+		}
+		result.Packages[key] = level
+	}
+
+	return result, nil
+}
+
+// parseLevelStrict is ParseLevel without the fallback to info
+func parseLevelStrict(level string) (Level, error) {
+	switch strings.ToLower(level) {
+	case "debug", "info", "warn", "warning", "error", "fatal", "panic":
+		return ParseLevel(level), nil
+	default:
+		return InfoLevel, fmt.Errorf("invalid log level: %s (must be one of: debug, info, warn, error, fatal)", level)
+	}
+}
+
+// Min returns the most verbose level of the spec
+func (s LevelSpec) Min() Level {
+	lowest := s.Default
+	for _, level := range s.Packages {
+		if level < lowest {
+			lowest = level
+		}
+	}
+	return lowest
+}
+
+// LevelFor returns the level for a package path. The override with the most
+// path elements wins.
+func (s LevelSpec) LevelFor(pkgPath string) Level {
+	level := s.Default
+	best := 0
+	for key, keyLevel := range s.Packages {
+		if n := strings.Count(key, "/") + 1; n > best && packageMatches(pkgPath, key) {
+			level, best = keyLevel, n
+		}
+	}
+	return level
+}
+
+// String formats the spec so that ParseLevelSpec reads it back
+func (s LevelSpec) String() string {
+	parts := []string{strings.ToLower(s.Default.String())}
+	keys := make([]string, 0, len(s.Packages))
+	for key := range s.Packages {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		parts = append(parts, key+"="+strings.ToLower(s.Packages[key].String()))
+	}
+	return strings.Join(parts, ",")
+}
+
+// packageMatches reports whether key's elements appear as whole elements of
+// pkgPath, either at its end or followed by a subpackage
+func packageMatches(pkgPath, key string) bool {
+	path := "/" + pkgPath + "/"
+	return strings.Contains(path, "/"+key+"/")
+}
+
+// packageLevelLogger drops messages below the level of the package that
+// logged them
+type packageLevelLogger struct {
+	next          Logger
+	spec          LevelSpec
+	highest       Level
+	callerPackage func() string
+}
+
+// NewPackageLevelLogger filters next by spec, applying package overrides by
+// the package of the code that logs. next must let through spec.Min(). When
+// spec has no overrides it returns next unchanged.
+func NewPackageLevelLogger(next Logger, spec LevelSpec) Logger {
+	if len(spec.Packages) == 0 {
+		return next
+	}
+
+	highest := spec.Default
+	for _, level := range spec.Packages {
+		if level > highest {
+			highest = level
+		}
+	}
+	return &packageLevelLogger{next: next, spec: spec, highest: highest, callerPackage: callerPackage}
+}
+
+// enabled reports whether level is logged for the calling package. Levels
+// that every package logs skip the caller lookup.
+func (l *packageLevelLogger) enabled(level Level) bool {
+	if level >= l.highest {
+		return true
+	}
+	return level >= l.spec.LevelFor(l.callerPackage())
+}
+
+func (l *packageLevelLogger) with(next Logger) Logger {
+	return &packageLevelLogger{next: next, spec: l.spec, highest: l.highest, callerPackage: l.callerPackage}
+}
+
+// Debug logs a debug message
+func (l *packageLevelLogger) Debug(message string, fields ...map[string]interface{}) {
+	if l.enabled(DebugLevel) {
+		l.next.Debug(message, fields...)
+	}
+}
+
+// Info logs an info message
+func (l *packageLevelLogger) Info(message string, fields ...map[string]interface{}) {
+	if l.enabled(InfoLevel) {
+		l.next.Info(message, fields...)
+	}
+}
+
+// Warn logs a warning message
+func (l *packageLevelLogger) Warn(message string, fields ...map[string]interface{}) {
+	if l.enabled(WarnLevel) {
+		l.next.Warn(message, fields...)
+	}
+}
+
+// Error logs an error message
+func (l *packageLevelLogger) Error(message string, err error, fields ...map[string]interface{}) {
+	if l.enabled(ErrorLevel) {
+		l.next.Error(message, err, fields...)
+	}
+}
+
+// Fatal logs a fatal message and exits
+func (l *packageLevelLogger) Fatal(message string, err error, fields ...map[string]interface{}) {
+	l.next.Fatal(message, err, fields...)
+}
+
+// Panic logs a panic message and panics
+func (l *packageLevelLogger) Panic(message string, err error, fields ...map[string]interface{}) {
+	l.next.Panic(message, err, fields...)
+}
+
+// WithField adds a field to the logger
+func (l *packageLevelLogger) WithField(key string, value interface{}) Logger {
+	return l.with(l.next.WithField(key, value))
+}
+
+// WithFields adds multiple fields to the logger
+func (l *packageLevelLogger) WithFields(fields map[string]interface{}) Logger {
+	return l.with(l.next.WithFields(fields))
+}
+
+// WithError adds an error to the logger
+func (l *packageLevelLogger) WithError(err error) Logger {
+	return l.with(l.next.WithError(err))
+}
+
+// WithContext adds context information to the logger
+func (l *packageLevelLogger) WithContext(ctx context.Context) Logger {
+	return l.with(l.next.WithContext(ctx))
+}
+
+// logPackage is the path of this package, whose frames callerPackage skips
+var logPackage = reflect.TypeOf(LevelSpec{}).PkgPath()
+
+// callerPackages caches the package path of each program counter
+var callerPackages sync.Map
+
+// callerPackage returns the package path of the first caller outside this
+// package
+func callerPackage() string {
+	var pcs [16]uintptr
+	n := runtime.Callers(3, pcs[:])
+	for _, pc := range pcs[:n] {
+		var pkg string
+		if cached, ok := callerPackages.Load(pc); ok {
+			pkg = cached.(string)
+		} else {
+			if fn := runtime.FuncForPC(pc - 1); fn != nil {
+				pkg = functionPackage(fn.Name())
+			}
+			callerPackages.Store(pc, pkg)
+		}
+		if pkg != "" && pkg != logPackage {
+			return pkg
+		}
+	}
+	return ""
+}
+
+// functionPackage returns the package path of a qualified function name such
+// as "freightliner/pkg/copy.(*Copier).Copy"
+func functionPackage(funcName string) string {
+	lastSlash := strings.LastIndex(funcName, "/")
+	if dot := strings.Index(funcName[lastSlash+1:], "."); dot >= 0 {
+		return funcName[:lastSlash+1+dot]
+	}
+	return funcName
+}
@@ -0,0 +1,149 @@
+package log
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseLevelSpec(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{input: "", want: "info"},
+		{input: "debug", want: "debug"},
+		{input: "copy=debug,tree=info", want: "info,copy=debug,tree=info"},
+		{input: "warn, copy=debug , tree/checkpoint=error", want: "warn,copy=debug,tree/checkpoint=error"},
+		{input: "verbose", wantErr: true},
+		{input: "copy=loud", wantErr: true},
+		{input: "=debug", wantErr: true},
+		{input: "info,debug", wantErr: true},
+	}
+	for _, tt := range tests {
+		spec, err := ParseLevelSpec(tt.input)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseLevelSpec(%q) expected error", tt.input)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseLevelSpec(%q) unexpected error: %v", tt.input, err)
+			continue
+		}
+		if got := spec.String(); got != tt.want {
+			t.Errorf("ParseLevelSpec(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestLevelSpecLevelFor(t *testing.T) {
+	spec, err := ParseLevelSpec("warn,copy=debug,tree=info,tree/checkpoint=error")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		pkg  string
+		want Level
+	}{
+		{"freightliner/pkg/copy", DebugLevel},
+		{"freightliner/pkg/tree", InfoLevel},
+		{"freightliner/pkg/tree/checkpoint", ErrorLevel},
+		{"freightliner/pkg/tree/worker", InfoLevel},
+		{"freightliner/pkg/copyutil", WarnLevel},
+		{"freightliner/cmd", WarnLevel},
+	}
+	for _, tt := range tests {
+		if got := spec.LevelFor(tt.pkg); got != tt.want {
+			t.Errorf("LevelFor(%q) = %v, want %v", tt.pkg, got, tt.want)
+		}
+	}
+	if spec.Min() != DebugLevel {
+		t.Errorf("Min() = %v, want DEBUG", spec.Min())
+	}
+}
+
+func TestPackageLevelLogger(t *testing.T) {
+	spec, _ := ParseLevelSpec("warn,copy=debug")
+	var buf bytes.Buffer
+	logger := NewPackageLevelLogger(NewBasicLoggerWithWriter(spec.Min(), &buf), spec).(*packageLevelLogger)
+
+	caller := "freightliner/pkg/copy"
+	logger.callerPackage = func() string { return caller }
+	derived := logger.WithFields(map[string]interface{}{"tag": "v1"})
+
+	derived.Debug("copy debug")
+	caller = "freightliner/pkg/tree"
+	derived.Debug("tree debug")
+	derived.Info("tree info")
+	derived.Warn("tree warn")
+
+	output := buf.String()
+	for _, want := range []string{"copy debug", "tree warn", "tag=v1"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("expected output to contain %q, got %q", want, output)
+		}
+	}
+	for _, unwanted := range []string{"tree debug", "tree info"} {
+		if strings.Contains(output, unwanted) {
+			t.Errorf("expected output not to contain %q, got %q", unwanted, output)
+		}
+	}
+}
+
+func TestNewPackageLevelLoggerWithoutOverrides(t *testing.T) {
+	next := NewBasicLogger(InfoLevel)
+	if got := NewPackageLevelLogger(next, LevelSpec{Default: InfoLevel}); got != next {
+		t.Error("expected logger without overrides to be returned unchanged")
+	}
+}
+
+func TestFunctionPackage(t *testing.T) {
+	tests := map[string]string{
+		"freightliner/pkg/copy.(*Copier).Copy":      "freightliner/pkg/copy",
+		"freightliner/pkg/tree/checkpoint.Load":     "freightliner/pkg/tree/checkpoint",
+		"main.main":                                 "main",
+		"freightliner/cmd.runSync.func1":            "freightliner/cmd",
+		"github.com/spf13/cobra.(*Command).execute": "github.com/spf13/cobra",
+	}
+	for name, want := range tests {
+		if got := functionPackage(name); got != want {
+			t.Errorf("functionPackage(%q) = %q, want %q", name, got, want)
+		}
+	}
+	if got := callerPackage(); got != "testing" {
+		t.Errorf("callerPackage() = %q, want testing", got)
+	}
+}
+
+func TestSampledLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewSampledLogger(NewBasicLoggerWithWriter(DebugLevel, &buf), SamplingConfig{First: 2, Thereafter: 3}).(*sampledLogger)
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	logger.sampler.now = func() time.Time { return now }
+
+	derived := logger.WithField("repository", "app")
+	for i := 0; i < 8; i++ {
+		derived.Debug("Copied tag")
+	}
+	derived.Info("Replicated repository")
+
+	// First two, then every third: occurrences 1, 2, 5 and 8
+	if got := strings.Count(buf.String(), "Copied tag"); got != 4 {
+		t.Errorf("expected 4 sampled debug lines, got %d", got)
+	}
+	if !strings.Contains(buf.String(), "Replicated repository") {
+		t.Error("expected info lines not to be sampled")
+	}
+
+	buf.Reset()
+	now = now.Add(time.Second)
+	logger.Debug("Copied tag")
+	if !strings.Contains(buf.String(), "Copied tag") {
+		t.Error("expected a new tick to reset the counts")
+	}
+}
@@ -0,0 +1,122 @@
+package log
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// SamplingConfig limits how often the same debug message is logged. Within
+// each Tick the first First occurrences of a message are logged, then every
+// Thereafter-th; a zero Thereafter drops the rest of the tick.
+type SamplingConfig struct {
+	First      int
+	Thereafter int
+	Tick       time.Duration
+}
+
+// sampler counts debug messages per tick; loggers derived with WithFields
+// share it
+type sampler struct {
+	cfg         SamplingConfig
+	now         func() time.Time
+	mu          sync.Mutex
+	windowStart time.Time
+	counts      map[string]int
+}
+
+// allow reports whether this occurrence of message is logged
+func (s *sampler) allow(message string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now := s.now(); now.Sub(s.windowStart) >= s.cfg.Tick {
+		s.windowStart = now
+		s.counts = make(map[string]int)
+	}
+
+	s.counts[message]++
+	n := s.counts[message]
+	if n <= s.cfg.First {
+		return true
+	}
+	return s.cfg.Thereafter > 0 && (n-s.cfg.First)%s.cfg.Thereafter == 0
+}
+
+// sampledLogger samples debug messages, such as per-tag progress lines, and
+// passes every other level through
+type sampledLogger struct {
+	next    Logger
+	sampler *sampler
+}
+
+// NewSampledLogger samples next's debug messages by cfg. A zero Tick means one
+// second. When cfg.First is zero it returns next unchanged.
+func NewSampledLogger(next Logger, cfg SamplingConfig) Logger {
+	if cfg.First <= 0 {
+		return next
+	}
+	if cfg.Tick <= 0 {
+		cfg.Tick = time.Second
+	}
+	return &sampledLogger{
+		next:    next,
+		sampler: &sampler{cfg: cfg, now: time.Now, counts: make(map[string]int)},
+	}
+}
+
+func (l *sampledLogger) with(next Logger) Logger {
+	return &sampledLogger{next: next, sampler: l.sampler}
+}
+
+// Debug logs a debug message unless it is sampled out
+func (l *sampledLogger) Debug(message string, fields ...map[string]interface{}) {
+	if l.sampler.allow(message) {
+		l.next.Debug(message, fields...)
+	}
+}
+
+// Info logs an info message
+func (l *sampledLogger) Info(message string, fields ...map[string]interface{}) {
+	l.next.Info(message, fields...)
+}
+
+// Warn logs a warning message
+func (l *sampledLogger) Warn(message string, fields ...map[string]interface{}) {
+	l.next.Warn(message, fields...)
+}
+
+// Error logs an error message
+func (l *sampledLogger) Error(message string, err error, fields ...map[string]interface{}) {
+	l.next.Error(message, err, fields...)
+}
+
+// Fatal logs a fatal message and exits
+func (l *sampledLogger) Fatal(message string, err error, fields ...map[string]interface{}) {
+	l.next.Fatal(message, err, fields...)
+}
+
+// Panic logs a panic message and panics
+func (l *sampledLogger) Panic(message string, err error, fields ...map[string]interface{}) {
+	l.next.Panic(message, err, fields...)
+}
+
+// WithField adds a field to the logger
+func (l *sampledLogger) WithField(key string, value interface{}) Logger {
+	return l.with(l.next.WithField(key, value))
+}
+
+// WithFields adds multiple fields to the logger
+func (l *sampledLogger) WithFields(fields map[string]interface{}) Logger {
+	return l.with(l.next.WithFields(fields))
+}
+
+// WithError adds an error to the logger
+func (l *sampledLogger) WithError(err error) Logger {
+	return l.with(l.next.WithError(err))
+}
+
+// WithContext adds context information to the logger
+func (l *sampledLogger) WithContext(ctx context.Context) Logger {
+	return l.with(l.next.WithContext(ctx))
+}
@@ -0,0 +1,137 @@
+package log
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"runtime"
+	"sort"
+	"time"
+)
+
+// SlogLevel maps a level to its slog level. Fatal and panic sit above error.
+func SlogLevel(level Level) slog.Level {
+	switch level {
+	case DebugLevel:
+		return slog.LevelDebug
+	case InfoLevel:
+		return slog.LevelInfo
+	case WarnLevel:
+		return slog.LevelWarn
+	case ErrorLevel:
+		return slog.LevelError
+	case FatalLevel:
+		return slog.LevelError + 4
+	default:
+		return slog.LevelError + 8
+	}
+}
+
+// SlogLogger writes to a slog.Handler, so any handler, including bridges to
+// other logging libraries, can back the application's logging. The handler
+// decides which levels are enabled.
+type SlogLogger struct {
+	handler slog.Handler
+	ctx     context.Context
+}
+
+// NewSlogLogger creates a logger writing to handler
+func NewSlogLogger(handler slog.Handler) Logger {
+	return &SlogLogger{handler: handler, ctx: context.Background()}
+}
+
+// WithField adds a field to the logger
+func (l *SlogLogger) WithField(key string, value interface{}) Logger {
+	return &SlogLogger{handler: l.handler.WithAttrs([]slog.Attr{slog.Any(key, value)}), ctx: l.ctx}
+}
+
+// WithFields adds multiple fields to the logger
+func (l *SlogLogger) WithFields(fields map[string]interface{}) Logger {
+	if len(fields) == 0 {
+		return l
+	}
+	return &SlogLogger{handler: l.handler.WithAttrs(slogAttrs(fields)), ctx: l.ctx}
+}
+
+// WithError adds an error to the logger
+func (l *SlogLogger) WithError(err error) Logger {
+	if err == nil {
+		return l
+	}
+	return l.WithField("error", err.Error())
+}
+
+// WithContext passes ctx to the handler with every record
+func (l *SlogLogger) WithContext(ctx context.Context) Logger {
+	if ctx == nil {
+		return l
+	}
+	return &SlogLogger{handler: l.handler, ctx: ctx}
+}
+
+// Debug logs a debug message
+func (l *SlogLogger) Debug(message string, fields ...map[string]interface{}) {
+	l.log(DebugLevel, message, nil, fields)
+}
+
+// Info logs an info message
+func (l *SlogLogger) Info(message string, fields ...map[string]interface{}) {
+	l.log(InfoLevel, message, nil, fields)
+}
+
+// Warn logs a warning message
+func (l *SlogLogger) Warn(message string, fields ...map[string]interface{}) {
+	l.log(WarnLevel, message, nil, fields)
+}
+
+// Error logs an error message
+func (l *SlogLogger) Error(message string, err error, fields ...map[string]interface{}) {
+	l.log(ErrorLevel, message, err, fields)
+}
+
+// Fatal logs a fatal message and exits
+func (l *SlogLogger) Fatal(message string, err error, fields ...map[string]interface{}) {
+	l.log(FatalLevel, message, err, fields)
+	os.Exit(1)
+}
+
+// Panic logs a panic message and panics
+func (l *SlogLogger) Panic(message string, err error, fields ...map[string]interface{}) {
+	l.log(PanicLevel, message, err, fields)
+	panic(message)
+}
+
+// log builds a record attributed to the caller of the logging method
+func (l *SlogLogger) log(level Level, message string, err error, fields []map[string]interface{}) {
+	slogLevel := SlogLevel(level)
+	if !l.handler.Enabled(l.ctx, slogLevel) {
+		return
+	}
+
+	var pcs [1]uintptr
+	runtime.Callers(3, pcs[:])
+	record := slog.NewRecord(time.Now(), slogLevel, message, pcs[0])
+	if err != nil {
+		record.AddAttrs(slog.String("error", err.Error()))
+	}
+	for _, fieldMap := range fields {
+		record.AddAttrs(slogAttrs(fieldMap)...)
+	}
+
+	_ = l.handler.Handle(l.ctx, record)
+}
+
+// slogAttrs converts fields to attributes in key order
+func slogAttrs(fields map[string]interface{}) []slog.Attr {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	attrs := make([]slog.Attr, 0, len(keys))
+	for _, k := range keys {
+		attrs = append(attrs, slog.Any(k, fields[k]))
+	}
+	return attrs
+}
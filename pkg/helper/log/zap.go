@@ -0,0 +1,137 @@
+package log
+
+import (
+	"context"
+	"os"
+	"sort"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// ZapLevel maps a level to its zap level
+func ZapLevel(level Level) zapcore.Level {
+	switch level {
+	case DebugLevel:
+		return zapcore.DebugLevel
+	case InfoLevel:
+		return zapcore.InfoLevel
+	case WarnLevel:
+		return zapcore.WarnLevel
+	case ErrorLevel:
+		return zapcore.ErrorLevel
+	case FatalLevel:
+		return zapcore.FatalLevel
+	default:
+		return zapcore.PanicLevel
+	}
+}
+
+// ZapLogger writes to a zap core, so zap encoders, sinks and samplers can
+// back the application's logging. The core decides which levels are enabled.
+type ZapLogger struct {
+	core zapcore.Core
+}
+
+// NewZapLogger creates a logger writing to core
+func NewZapLogger(core zapcore.Core) Logger {
+	return &ZapLogger{core: core}
+}
+
+// WithField adds a field to the logger
+func (l *ZapLogger) WithField(key string, value interface{}) Logger {
+	return &ZapLogger{core: l.core.With([]zapcore.Field{zap.Any(key, value)})}
+}
+
+// WithFields adds multiple fields to the logger
+func (l *ZapLogger) WithFields(fields map[string]interface{}) Logger {
+	if len(fields) == 0 {
+		return l
+	}
+	return &ZapLogger{core: l.core.With(zapFields(fields))}
+}
+
+// WithError adds an error to the logger
+func (l *ZapLogger) WithError(err error) Logger {
+	if err == nil {
+		return l
+	}
+	return l.WithField("error", err.Error())
+}
+
+// WithContext adds context information to the logger
+func (l *ZapLogger) WithContext(ctx context.Context) Logger {
+	return l // zap cores do not take a context
+}
+
+// Debug logs a debug message
+func (l *ZapLogger) Debug(message string, fields ...map[string]interface{}) {
+	l.log(DebugLevel, message, nil, fields)
+}
+
+// Info logs an info message
+func (l *ZapLogger) Info(message string, fields ...map[string]interface{}) {
+	l.log(InfoLevel, message, nil, fields)
+}
+
+// Warn logs a warning message
+func (l *ZapLogger) Warn(message string, fields ...map[string]interface{}) {
+	l.log(WarnLevel, message, nil, fields)
+}
+
+// Error logs an error message
+func (l *ZapLogger) Error(message string, err error, fields ...map[string]interface{}) {
+	l.log(ErrorLevel, message, err, fields)
+}
+
+// Fatal logs a fatal message and exits
+func (l *ZapLogger) Fatal(message string, err error, fields ...map[string]interface{}) {
+	l.log(FatalLevel, message, err, fields)
+	_ = l.core.Sync()
+	os.Exit(1)
+}
+
+// Panic logs a panic message and panics
+func (l *ZapLogger) Panic(message string, err error, fields ...map[string]interface{}) {
+	l.log(PanicLevel, message, err, fields)
+	_ = l.core.Sync()
+	panic(message)
+}
+
+// log writes an entry if the core accepts its level
+func (l *ZapLogger) log(level Level, message string, err error, fields []map[string]interface{}) {
+	entry := zapcore.Entry{
+		Level:   ZapLevel(level),
+		Time:    time.Now(),
+		Message: message,
+	}
+	checked := l.core.Check(entry, nil)
+	if checked == nil {
+		return
+	}
+
+	var zfs []zapcore.Field
+	if err != nil {
+		zfs = append(zfs, zap.String("error", err.Error()))
+	}
+	for _, fieldMap := range fields {
+		zfs = append(zfs, zapFields(fieldMap)...)
+	}
+	checked.Write(zfs...)
+}
+
+// zapFields converts fields to zap fields in key order
+func zapFields(fields map[string]interface{}) []zapcore.Field {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	zfs := make([]zapcore.Field, 0, len(keys))
+	for _, k := range keys {
+		zfs = append(zfs, zap.Any(k, fields[k]))
+	}
+	return zfs
+}
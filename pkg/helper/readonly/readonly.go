@@ -0,0 +1,86 @@
+// Package readonly enforces the --read-only guard: while it is enabled, every
+// registry write made by the process fails instead of reaching the registry.
+package readonly
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// ErrReadOnly is returned for registry writes attempted in read-only mode
+var ErrReadOnly = errors.New("read-only mode")
+
+var enabled atomic.Bool
+
+// Enable turns the guard on for the rest of the process. It must run before
+// registry clients are created so their transports are guarded.
+func Enable() {
+	enabled.Store(true)
+	http.DefaultTransport = Transport(http.DefaultTransport)
+	remote.DefaultTransport = Transport(remote.DefaultTransport)
+}
+
+// Enabled reports whether read-only mode is on
+func Enabled() bool {
+	return enabled.Load()
+}
+
+// Check returns an ErrReadOnly error describing operation when read-only
+// mode is on, and nil otherwise
+func Check(operation string) error {
+	if !Enabled() {
+		return nil
+	}
+	return fmt.Errorf("%w: refusing to %s", ErrReadOnly, operation)
+}
+
+// Transport guards inner so that registry API writes fail while read-only
+// mode is on. Reads and token requests pass through.
+func Transport(inner http.RoundTripper) http.RoundTripper {
+	if _, ok := inner.(*transport); ok {
+		return inner
+	}
+	return &transport{inner: inner}
+}
+
+// Unwrap returns the transport guarded by Transport, or rt itself, so callers
+// that clone the default transport keep working in read-only mode
+func Unwrap(rt http.RoundTripper) http.RoundTripper {
+	if t, ok := rt.(*transport); ok {
+		return t.inner
+	}
+	return rt
+}
+
+// transport refuses registry API writes in read-only mode
+type transport struct {
+	inner http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if Enabled() && IsRegistryWrite(req) {
+		return nil, Check(fmt.Sprintf("%s %s", req.Method, req.URL.Redacted()))
+	}
+	return t.inner.RoundTrip(req)
+}
+
+// IsRegistryWrite reports whether req changes a registry through the
+// distribution API: blob uploads, manifest pushes and deletes. Token
+// exchanges, which some registries serve under /v2/, are not writes.
+func IsRegistryWrite(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	}
+	path := req.URL.Path
+	if !strings.HasPrefix(path, "/v2/") || strings.HasSuffix(path, "/token") {
+		return false
+	}
+	return strings.Contains(path, "/blobs/") || strings.Contains(path, "/manifests/")
+}
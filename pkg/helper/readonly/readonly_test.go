@@ -0,0 +1,68 @@
+package readonly
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsRegistryWrite(t *testing.T) {
+	tests := []struct {
+		method string
+		path   string
+		want   bool
+	}{
+		{http.MethodGet, "/v2/app/manifests/latest", false},
+		{http.MethodHead, "/v2/app/blobs/sha256:abc", false},
+		{http.MethodPut, "/v2/app/manifests/latest", true},
+		{http.MethodDelete, "/v2/app/manifests/sha256:abc", true},
+		{http.MethodPost, "/v2/app/blobs/uploads/", true},
+		{http.MethodPatch, "/v2/app/blobs/uploads/123", true},
+		{http.MethodPost, "/v2/token", false},
+		{http.MethodPost, "/oauth2/token", false},
+		{http.MethodPost, "/api/v1/repository", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "https://registry.example.com"+tt.path, nil)
+			assert.Equal(t, tt.want, IsRegistryWrite(req))
+		})
+	}
+}
+
+func TestTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: Transport(http.DefaultTransport)}
+	put := func() (*http.Response, error) {
+		req, err := http.NewRequest(http.MethodPut, server.URL+"/v2/app/manifests/latest", nil)
+		require.NoError(t, err)
+		return client.Do(req)
+	}
+
+	resp, err := put()
+	require.NoError(t, err, "writes pass while read-only mode is off")
+	resp.Body.Close()
+
+	enabled.Store(true)
+	t.Cleanup(func() { enabled.Store(false) })
+
+	_, err = put()
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, ErrReadOnly))
+
+	resp, err = client.Get(server.URL + "/v2/app/manifests/latest")
+	require.NoError(t, err, "reads pass in read-only mode")
+	resp.Body.Close()
+
+	assert.ErrorIs(t, Check("delete app:latest"), ErrReadOnly)
+	assert.Same(t, http.DefaultTransport, Unwrap(Transport(http.DefaultTransport)))
+}
@@ -0,0 +1,47 @@
+// Package roundtrip builds the HTTP transport chain every registry client
+// uses, so API call counting, read-only mode, clock skew detection, request
+// logging and upload chunking apply to all of them alike.
+package roundtrip
+
+import (
+	"net/http"
+	"reflect"
+
+	"freightliner/pkg/helper/apicalls"
+	"freightliner/pkg/helper/clockskew"
+	"freightliner/pkg/helper/httplog"
+	"freightliner/pkg/helper/readonly"
+	"freightliner/pkg/helper/upload"
+)
+
+// Wrap returns base wrapped in the registry transport chain
+func Wrap(base http.RoundTripper) http.RoundTripper {
+	return apicalls.Transport(readonly.Transport(clockskew.Transport(httplog.Transport(upload.Transport(base)))))
+}
+
+// Unwrap removes every layer of the chain from rt, whatever order the layers
+// were added in, e.g. by each helper installing itself on
+// http.DefaultTransport
+func Unwrap(rt http.RoundTripper) http.RoundTripper {
+	for {
+		// Chain layers are pointers; anything uncomparable is not one
+		if rt == nil || !reflect.TypeOf(rt).Comparable() {
+			return rt
+		}
+		inner := upload.Unwrap(httplog.Unwrap(clockskew.Unwrap(readonly.Unwrap(apicalls.Unwrap(rt)))))
+		if inner == rt {
+			return rt
+		}
+		rt = inner
+	}
+}
+
+// BaseTransport returns a clone of the *http.Transport under rt's chain, or
+// false when rt is not built on one
+func BaseTransport(rt http.RoundTripper) (*http.Transport, bool) {
+	base, ok := Unwrap(rt).(*http.Transport)
+	if !ok {
+		return nil, false
+	}
+	return base.Clone(), true
+}
@@ -0,0 +1,44 @@
+package roundtrip
+
+import (
+	"net/http"
+	"testing"
+
+	"freightliner/pkg/helper/apicalls"
+	"freightliner/pkg/helper/clockskew"
+	"freightliner/pkg/helper/httplog"
+	"freightliner/pkg/helper/readonly"
+	"freightliner/pkg/helper/upload"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+func TestUnwrap(t *testing.T) {
+	base := &http.Transport{MaxIdleConns: 7}
+
+	assert.Same(t, base, Unwrap(Wrap(base)))
+
+	// Helpers install themselves on the default transport in any order
+	installed := readonly.Transport(upload.Transport(apicalls.Transport(httplog.Transport(clockskew.Transport(base)))))
+	assert.Same(t, base, Unwrap(installed))
+
+	assert.Same(t, base, Unwrap(base))
+}
+
+func TestBaseTransport(t *testing.T) {
+	base := &http.Transport{MaxIdleConns: 7}
+
+	clone, ok := BaseTransport(Wrap(base))
+	require.True(t, ok)
+	assert.NotSame(t, base, clone)
+	assert.Equal(t, 7, clone.MaxIdleConns)
+
+	custom := roundTripFunc(func(*http.Request) (*http.Response, error) { return nil, nil })
+	_, ok = BaseTransport(Wrap(custom))
+	assert.False(t, ok)
+}
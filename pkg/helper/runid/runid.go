@@ -0,0 +1,59 @@
+// Package runid identifies a run of a command, so its logs, metrics,
+// checkpoints, reports and pushed images can be correlated across systems.
+package runid
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// runInfo is 1 for the run of this process. Joining on its run_id label
+// ties the process's other series to the run without giving each of them a
+// label that changes every run.
+var runInfo = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "freightliner_run_info",
+		Help: "Identifies the run of this process by its run ID",
+	},
+	[]string{"run_id"},
+)
+
+func init() {
+	prometheus.MustRegister(runInfo)
+}
+
+// New returns a new run ID: a time-ordered UUID, so IDs sort by start time
+func New() string {
+	id, err := uuid.NewV7()
+	if err != nil {
+		return uuid.New().String()
+	}
+	return id.String()
+}
+
+type runIDKey struct{}
+
+// WithRunID returns a context carrying the run ID id
+func WithRunID(ctx context.Context, id string) context.Context {
+	if id == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, runIDKey{}, id)
+}
+
+// FromContext returns the run ID ctx carries, or ""
+func FromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(runIDKey{}).(string)
+	return id
+}
+
+// Publish exports id as the run of this process in the run info metric
+func Publish(id string) {
+	runInfo.Reset()
+	runInfo.WithLabelValues(id).Set(1)
+}
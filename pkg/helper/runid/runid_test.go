@@ -0,0 +1,37 @@
+package runid
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	first, second := New(), New()
+	assert.NotEqual(t, first, second)
+
+	parsed, err := uuid.Parse(first)
+	require.NoError(t, err)
+	assert.Equal(t, uuid.Version(7), parsed.Version())
+	assert.Less(t, first, second, "run IDs sort by start time")
+}
+
+func TestContext(t *testing.T) {
+	assert.Empty(t, FromContext(context.Background()))
+
+	ctx := WithRunID(context.Background(), "ci-4711")
+	assert.Equal(t, "ci-4711", FromContext(ctx))
+	assert.Equal(t, "ci-4711", FromContext(WithRunID(ctx, "")), "an empty ID keeps the run's")
+}
+
+func TestPublish(t *testing.T) {
+	Publish("first")
+	Publish("second")
+
+	assert.Equal(t, 1, testutil.CollectAndCount(runInfo), "only the current run is exported")
+	assert.Equal(t, float64(1), testutil.ToFloat64(runInfo.WithLabelValues("second")))
+}
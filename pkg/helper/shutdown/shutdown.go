@@ -0,0 +1,47 @@
+// Package shutdown delivers the platform's requests to stop the process:
+// SIGINT and SIGTERM on Unix; Ctrl+C, console close, logoff, system shutdown
+// and service control stop requests on Windows.
+package shutdown
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+)
+
+var (
+	mu          sync.Mutex
+	subscribers = make(map[chan os.Signal]struct{})
+	released    = make(chan struct{}, 1)
+)
+
+// Notify returns a channel that receives a signal when the process is asked
+// to stop, and a function that releases it. On Windows a service stop is
+// reported as done once every channel has been released, so release it after
+// shutdown work has finished.
+func Notify() (<-chan os.Signal, func()) {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, signals...)
+
+	mu.Lock()
+	subscribers[ch] = struct{}{}
+	mu.Unlock()
+
+	startService()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			signal.Stop(ch)
+
+			mu.Lock()
+			delete(subscribers, ch)
+			mu.Unlock()
+
+			select {
+			case released <- struct{}{}:
+			default:
+			}
+		})
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !windows
+
+package shutdown
+
+import (
+	"os"
+	"syscall"
+)
+
+var signals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+
+// startService is a no-op: Unix service managers stop processes with SIGTERM
+func startService() {}
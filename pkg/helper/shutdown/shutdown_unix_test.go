@@ -0,0 +1,30 @@
+//go:build !windows
+
+package shutdown
+
+import (
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNotify(t *testing.T) {
+	ch, stop := Notify()
+	defer stop()
+
+	assert.NoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGTERM))
+
+	select {
+	case sig := <-ch:
+		assert.Equal(t, syscall.SIGTERM, sig)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no signal delivered")
+	}
+
+	stop()
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Empty(t, subscribers)
+}
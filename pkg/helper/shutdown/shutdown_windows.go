@@ -0,0 +1,85 @@
+//go:build windows
+
+package shutdown
+
+import (
+	"os"
+	"sync"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/windows/svc"
+)
+
+// The runtime delivers Ctrl+C and Ctrl+Break as os.Interrupt, and console
+// close, logoff and system shutdown as syscall.SIGTERM
+var signals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+
+// stopWaitHint is how long the service control manager is told a stop may take
+const stopWaitHint = 30 * time.Second
+
+var serviceOnce sync.Once
+
+// startService answers the service control manager when running as a
+// Windows service, turning stop and shutdown requests into os.Interrupt
+func startService() {
+	serviceOnce.Do(func() {
+		isService, err := svc.IsWindowsService()
+		if err != nil || !isService {
+			return
+		}
+		// The name is ignored for services that run in their own process
+		go func() { _ = svc.Run("freightliner", serviceHandler{}) }()
+	})
+}
+
+type serviceHandler struct{}
+
+func (serviceHandler) Execute(_ []string, requests <-chan svc.ChangeRequest, status chan<- svc.Status) (bool, uint32) {
+	const accepts = svc.AcceptStop | svc.AcceptShutdown
+	status <- svc.Status{State: svc.Running, Accepts: accepts}
+
+	for req := range requests {
+		switch req.Cmd {
+		case svc.Interrogate:
+			status <- req.CurrentStatus
+		case svc.Stop, svc.Shutdown:
+			status <- svc.Status{State: svc.StopPending, WaitHint: uint32(stopWaitHint / time.Millisecond)}
+			requestStop()
+			waitReleased(stopWaitHint)
+			return false, 0
+		}
+	}
+	return false, 0
+}
+
+// requestStop delivers os.Interrupt to every channel from Notify
+func requestStop() {
+	mu.Lock()
+	defer mu.Unlock()
+	for ch := range subscribers {
+		select {
+		case ch <- os.Interrupt:
+		default:
+		}
+	}
+}
+
+// waitReleased waits up to timeout for every channel from Notify to be released
+func waitReleased(timeout time.Duration) {
+	deadline := time.After(timeout)
+	for {
+		mu.Lock()
+		remaining := len(subscribers)
+		mu.Unlock()
+		if remaining == 0 {
+			return
+		}
+
+		select {
+		case <-released:
+		case <-deadline:
+			return
+		}
+	}
+}
@@ -0,0 +1,332 @@
+// Package upload controls how blob bytes are sent to destination registries.
+// go-containerregistry streams each blob in a single PATCH, which suits most
+// registries, but some reject requests beyond a size (e.g. Harbor behind a
+// proxy with a body limit) and others are fastest with large chunks (GCR).
+// The transport here splits those PATCHes into ranged chunks per host.
+package upload
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// Strategy is how blobs are uploaded to a registry
+type Strategy string
+
+const (
+	// Monolithic sends each blob in one PATCH
+	Monolithic Strategy = "monolithic"
+
+	// Chunked sends each blob in PATCHes of a fixed size
+	Chunked Strategy = "chunked"
+
+	// Auto sizes chunks by the bandwidth measured to the registry, sending
+	// blobs that fit in one chunk monolithically
+	Auto Strategy = "auto"
+)
+
+// Chunk size bounds
+const (
+	// DefaultChunkSize is used when a chunked policy sets no size, and is
+	// the first guess of an auto policy before bandwidth is measured
+	DefaultChunkSize = 16 << 20
+
+	// MinChunkSize keeps auto-tuned chunks worth a request on slow links
+	MinChunkSize = 1 << 20
+
+	// DefaultMaxChunkSize caps auto-tuned chunks when a policy sets no limit
+	DefaultMaxChunkSize = 512 << 20
+)
+
+// TargetChunkDuration is how long an auto-tuned chunk should take to send:
+// long enough to amortize the request, short enough that a failed chunk
+// wastes little
+const TargetChunkDuration = 10 * time.Second
+
+// Policy is how blobs are uploaded to one registry host
+type Policy struct {
+	Strategy Strategy
+
+	// ChunkSize is the bytes per PATCH (default DefaultChunkSize)
+	ChunkSize int64
+
+	// MaxChunkSize caps chunks for registries that limit request bodies;
+	// larger blobs are always chunked
+	MaxChunkSize int64
+}
+
+var (
+	installOnce sync.Once
+
+	// policies maps lowercase hosts to their Policy
+	policies atomic.Pointer[map[string]Policy]
+
+	// bandwidths maps hosts to their measured upload bandwidth
+	bandwidths sync.Map
+)
+
+// Configure sets the upload policies by registry host[:port], replacing any
+// earlier ones
+func Configure(byHost map[string]Policy) {
+	normalized := make(map[string]Policy, len(byHost))
+	for host, policy := range byHost {
+		normalized[strings.ToLower(host)] = policy
+	}
+	policies.Store(&normalized)
+}
+
+// Install applies the policies to requests made through the shared default
+// transports. It must run before registry clients are created.
+func Install() {
+	installOnce.Do(func() {
+		http.DefaultTransport = Transport(http.DefaultTransport)
+		remote.DefaultTransport = Transport(remote.DefaultTransport)
+	})
+}
+
+// PolicyFor returns the policy of host, if one is configured
+func PolicyFor(host string) (Policy, bool) {
+	byHost := policies.Load()
+	if byHost == nil {
+		return Policy{}, false
+	}
+	policy, ok := (*byHost)[strings.ToLower(host)]
+	return policy, ok
+}
+
+// Bandwidth returns the upload bandwidth measured to host in bytes per
+// second, or zero before any upload finished
+func Bandwidth(host string) float64 {
+	if m, ok := bandwidths.Load(strings.ToLower(host)); ok {
+		return m.(*meter).rate()
+	}
+	return 0
+}
+
+// ChunkSizeFor returns the bytes per PATCH of a blob of size bytes to host
+// under policy, or zero to send it in one request. size is -1 when unknown.
+func (p Policy) ChunkSizeFor(host string, size int64) int64 {
+	var chunk int64
+	switch p.Strategy {
+	case Chunked:
+		chunk = p.ChunkSize
+		if chunk <= 0 {
+			chunk = DefaultChunkSize
+		}
+	case Auto:
+		chunk = p.tunedChunkSize(Bandwidth(host))
+	}
+	if p.MaxChunkSize > 0 && (chunk == 0 || chunk > p.MaxChunkSize) {
+		chunk = p.MaxChunkSize
+	}
+	if chunk == 0 || (size >= 0 && size <= chunk) {
+		return 0
+	}
+	return chunk
+}
+
+// tunedChunkSize sizes chunks to take TargetChunkDuration at bandwidth
+func (p Policy) tunedChunkSize(bandwidth float64) int64 {
+	if bandwidth <= 0 {
+		if p.ChunkSize > 0 {
+			return p.ChunkSize
+		}
+		return DefaultChunkSize
+	}
+	limit := p.MaxChunkSize
+	if limit <= 0 {
+		limit = DefaultMaxChunkSize
+	}
+
+	chunk := int64(bandwidth * TargetChunkDuration.Seconds())
+	chunk = chunk / MinChunkSize * MinChunkSize
+	if chunk < MinChunkSize {
+		chunk = MinChunkSize
+	}
+	if chunk > limit {
+		chunk = limit
+	}
+	return chunk
+}
+
+// meter keeps a moving average of the bandwidth to one host
+type meter struct {
+	mu          sync.Mutex
+	bytesPerSec float64
+}
+
+// observe folds an upload of n bytes taking elapsed into the average
+func (m *meter) observe(n int64, elapsed time.Duration) {
+	if n <= 0 || elapsed <= 0 {
+		return
+	}
+	sample := float64(n) / elapsed.Seconds()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.bytesPerSec == 0 {
+		m.bytesPerSec = sample
+		return
+	}
+	m.bytesPerSec = 0.7*m.bytesPerSec + 0.3*sample
+}
+
+func (m *meter) rate() float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.bytesPerSec
+}
+
+// observe records an upload of n bytes to host taking elapsed
+func observe(host string, n int64, elapsed time.Duration) {
+	m, _ := bandwidths.LoadOrStore(strings.ToLower(host), &meter{})
+	m.(*meter).observe(n, elapsed)
+}
+
+// isBlobUpload reports whether req sends blob bytes to an upload session
+func isBlobUpload(req *http.Request) bool {
+	return req.Method == http.MethodPatch && strings.Contains(req.URL.Path, "/blobs/uploads/")
+}
+
+// Transport applies the configured policies to blob uploads made through
+// inner. Other requests pass unchanged.
+func Transport(inner http.RoundTripper) http.RoundTripper {
+	if _, ok := inner.(*transport); ok {
+		return inner
+	}
+	return &transport{inner: inner}
+}
+
+// Unwrap returns the transport wrapped by Transport, or rt itself
+func Unwrap(rt http.RoundTripper) http.RoundTripper {
+	if t, ok := rt.(*transport); ok {
+		return t.inner
+	}
+	return rt
+}
+
+// transport splits blob uploads into chunks
+type transport struct {
+	inner http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !isBlobUpload(req) || req.Body == nil || req.Header.Get("Content-Range") != "" {
+		return t.inner.RoundTrip(req)
+	}
+	policy, ok := PolicyFor(req.URL.Host)
+	if !ok {
+		return t.inner.RoundTrip(req)
+	}
+
+	// A client request with a body but no length streams an unknown size
+	size := req.ContentLength
+	if size == 0 && req.Body != http.NoBody {
+		size = -1
+	}
+	chunk := policy.ChunkSizeFor(req.URL.Host, size)
+	if chunk == 0 {
+		return t.measured(req)
+	}
+	return t.chunked(req, chunk)
+}
+
+// measured sends req in one request, recording the bandwidth achieved
+func (t *transport) measured(req *http.Request) (*http.Response, error) {
+	body := &countingReader{ReadCloser: req.Body}
+	req = req.Clone(req.Context())
+	req.Body = body
+
+	start := time.Now()
+	resp, err := t.inner.RoundTrip(req)
+	if err == nil && resp.StatusCode == http.StatusAccepted {
+		observe(req.URL.Host, body.n, time.Since(start))
+	}
+	return resp, err
+}
+
+// chunked sends the body of req in PATCHes of up to size bytes with their
+// Content-Range, each to the location the previous one returned. The last
+// response is returned so the caller commits the upload as usual.
+func (t *transport) chunked(req *http.Request, size int64) (*http.Response, error) {
+	defer req.Body.Close()
+
+	buf := make([]byte, size)
+	location := req.URL
+	var (
+		offset int64
+		last   *http.Response
+	)
+	for {
+		n, readErr := io.ReadFull(req.Body, buf)
+		if n == 0 && last != nil {
+			if readErr == io.EOF {
+				return last, nil
+			}
+			last.Body.Close()
+			return nil, readErr
+		}
+		if readErr != nil && readErr != io.EOF && readErr != io.ErrUnexpectedEOF {
+			if last != nil {
+				last.Body.Close()
+			}
+			return nil, readErr
+		}
+		if last != nil {
+			_, _ = io.Copy(io.Discard, last.Body)
+			last.Body.Close()
+		}
+
+		chunkReq := req.Clone(req.Context())
+		chunkReq.URL = location
+		chunkReq.Host = location.Host
+		data := buf[:n]
+		chunkReq.Body = io.NopCloser(bytes.NewReader(data))
+		chunkReq.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(data)), nil }
+		chunkReq.ContentLength = int64(n)
+		if n > 0 {
+			chunkReq.Header.Set("Content-Range", fmt.Sprintf("%d-%d", offset, offset+int64(n)-1))
+		}
+
+		start := time.Now()
+		resp, err := t.inner.RoundTrip(chunkReq)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusAccepted {
+			return resp, nil
+		}
+		observe(location.Host, int64(n), time.Since(start))
+
+		offset += int64(n)
+		last = resp
+		if next, err := resp.Location(); err == nil {
+			location = next
+		}
+		if readErr != nil {
+			// The body ended within this chunk
+			return last, nil
+		}
+	}
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	io.ReadCloser
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,139 @@
+package upload
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// uploadServer records the PATCHes of a blob upload session, moving the
+// session to a new location after each one like registries do
+type uploadServer struct {
+	mu      sync.Mutex
+	ranges  []string
+	paths   []string
+	content bytes.Buffer
+}
+
+func (s *uploadServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	data, _ := io.ReadAll(r.Body)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ranges = append(s.ranges, r.Header.Get("Content-Range"))
+	s.paths = append(s.paths, r.URL.Path)
+	s.content.Write(data)
+
+	w.Header().Set("Location", fmt.Sprintf("/v2/app/blobs/uploads/session-%d", len(s.paths)))
+	w.Header().Set("Range", fmt.Sprintf("0-%d", s.content.Len()-1))
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// useServer configures policy for the server's host for the test
+func useServer(t *testing.T, policy Policy) (*uploadServer, *httptest.Server) {
+	recorder := &uploadServer{}
+	server := httptest.NewServer(recorder)
+	t.Cleanup(server.Close)
+
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	Configure(map[string]Policy{u.Host: policy})
+	t.Cleanup(func() {
+		Configure(nil)
+		bandwidths.Delete(u.Host)
+	})
+	return recorder, server
+}
+
+func patch(t *testing.T, serverURL string, body io.Reader) *http.Response {
+	req, err := http.NewRequest(http.MethodPatch, serverURL+"/v2/app/blobs/uploads/session-0", body)
+	require.NoError(t, err)
+	resp, err := (&http.Client{Transport: Transport(http.DefaultTransport)}).Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestTransportChunksUploads(t *testing.T) {
+	recorder, server := useServer(t, Policy{Strategy: Chunked, ChunkSize: 4})
+
+	// A reader without a known length, like a streamed layer
+	resp := patch(t, server.URL, io.NopCloser(strings.NewReader("0123456789")))
+
+	assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+	assert.Equal(t, "/v2/app/blobs/uploads/session-3", resp.Header.Get("Location"),
+		"the last chunk's response is returned for the commit")
+	assert.Equal(t, []string{"0-3", "4-7", "8-9"}, recorder.ranges)
+	assert.Equal(t, []string{
+		"/v2/app/blobs/uploads/session-0",
+		"/v2/app/blobs/uploads/session-1",
+		"/v2/app/blobs/uploads/session-2",
+	}, recorder.paths, "each chunk goes to the location the previous one returned")
+	assert.Equal(t, "0123456789", recorder.content.String())
+	assert.Positive(t, Bandwidth(strings.TrimPrefix(server.URL, "http://")))
+}
+
+func TestTransportSendsSmallBlobsWhole(t *testing.T) {
+	recorder, server := useServer(t, Policy{Strategy: Chunked, ChunkSize: 16})
+
+	patch(t, server.URL, bytes.NewReader([]byte("0123456789")))
+
+	assert.Equal(t, []string{""}, recorder.ranges)
+	assert.Equal(t, "0123456789", recorder.content.String())
+}
+
+func TestTransportWithoutPolicy(t *testing.T) {
+	recorder := &uploadServer{}
+	server := httptest.NewServer(recorder)
+	defer server.Close()
+
+	patch(t, server.URL, io.NopCloser(strings.NewReader("0123456789")))
+
+	assert.Equal(t, []string{""}, recorder.ranges, "hosts without a policy are untouched")
+}
+
+func TestPolicyChunkSizeFor(t *testing.T) {
+	const host = "registry.example.com"
+	t.Cleanup(func() { bandwidths.Delete(host) })
+
+	tests := []struct {
+		name   string
+		policy Policy
+		size   int64
+		want   int64
+	}{
+		{"monolithic", Policy{Strategy: Monolithic}, -1, 0},
+		{"monolithic over the cap", Policy{Strategy: Monolithic, MaxChunkSize: 8 << 20}, 100 << 20, 8 << 20},
+		{"monolithic under the cap", Policy{Strategy: Monolithic, MaxChunkSize: 8 << 20}, 4 << 20, 0},
+		{"chunked default", Policy{Strategy: Chunked}, -1, DefaultChunkSize},
+		{"chunked capped", Policy{Strategy: Chunked, ChunkSize: 64 << 20, MaxChunkSize: 32 << 20}, -1, 32 << 20},
+		{"auto before measuring", Policy{Strategy: Auto}, -1, DefaultChunkSize},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.policy.ChunkSizeFor(host, tt.size))
+		})
+	}
+
+	// 10 MiB/s sends 100 MiB in the target duration
+	observe(host, 10<<20, time.Second)
+	assert.Equal(t, int64(100<<20), Policy{Strategy: Auto}.ChunkSizeFor(host, -1))
+	assert.Equal(t, int64(32<<20), Policy{Strategy: Auto, MaxChunkSize: 32 << 20}.ChunkSizeFor(host, -1))
+	assert.Zero(t, Policy{Strategy: Auto}.ChunkSizeFor(host, 50<<20), "blobs that fit one chunk go whole")
+}
+
+func TestUnwrap(t *testing.T) {
+	wrapped := Transport(http.DefaultTransport)
+	assert.Same(t, wrapped, Transport(wrapped), "wrapping is idempotent")
+	assert.Same(t, http.DefaultTransport, Unwrap(wrapped))
+}
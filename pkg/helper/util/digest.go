@@ -1,29 +1,54 @@
 package util
 
 import (
-	"crypto/sha256"
-	"fmt"
+	_ "crypto/sha256" // registers sha256 with go-digest
+	_ "crypto/sha512" // registers sha384 and sha512 with go-digest
 
 	"freightliner/pkg/helper/errors"
+
+	"github.com/opencontainers/go-digest"
 )
 
 // CalculateDigest calculates a SHA256 digest of the given data
 // Returns a digest string in the format "sha256:<hex-digest>"
 func CalculateDigest(data []byte) (string, error) {
+	return CalculateDigestWith(string(digest.Canonical), data)
+}
+
+// CalculateDigestWith calculates the digest of data with the named algorithm
+// (sha256, sha384 or sha512), in the format "<algorithm>:<hex-digest>"
+func CalculateDigestWith(algorithm string, data []byte) (string, error) {
 	if data == nil {
 		return "", errors.InvalidInputf("data cannot be nil")
 	}
 
-	h := sha256.New()
-	if _, err := h.Write(data); err != nil {
-		return "", errors.Wrap(err, "failed to calculate digest")
+	alg := digest.Algorithm(algorithm)
+	if !alg.Available() {
+		return "", errors.InvalidInputf("unsupported digest algorithm: %s", algorithm)
 	}
 
-	digest := fmt.Sprintf("sha256:%x", h.Sum(nil))
-	return digest, nil
+	return alg.FromBytes(data).String(), nil
+}
+
+// ParseDigest parses an "<algorithm>:<encoded>" digest, checking that the
+// algorithm is supported and the encoded part has its length
+func ParseDigest(s string) (digest.Digest, error) {
+	d, err := digest.Parse(s)
+	if err != nil {
+		return "", errors.InvalidInputf("invalid digest %q: %v", s, err)
+	}
+	return d, nil
+}
+
+// IsDigest reports whether reference is a valid digest of a supported
+// algorithm rather than a tag
+func IsDigest(reference string) bool {
+	_, err := digest.Parse(reference)
+	return err == nil
 }
 
-// ValidateDigest validates that the provided digest matches the given data
+// ValidateDigest validates that the provided digest matches the given data,
+// using the digest's own algorithm
 func ValidateDigest(data []byte, expectedDigest string) (bool, error) {
 	if data == nil {
 		return false, errors.InvalidInputf("data cannot be nil")
@@ -33,10 +58,10 @@ func ValidateDigest(data []byte, expectedDigest string) (bool, error) {
 		return false, errors.InvalidInputf("expected digest cannot be empty")
 	}
 
-	actualDigest, err := CalculateDigest(data)
+	expected, err := ParseDigest(expectedDigest)
 	if err != nil {
-		return false, errors.Wrap(err, "failed to calculate actual digest")
+		return false, err
 	}
 
-	return actualDigest == expectedDigest, nil
+	return expected.Algorithm().FromBytes(data) == expected, nil
 }
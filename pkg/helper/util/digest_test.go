@@ -104,3 +104,46 @@ func TestValidateDigest(t *testing.T) {
 		})
 	}
 }
+
+func TestCalculateDigestWith(t *testing.T) {
+	digest, err := CalculateDigestWith("sha512", []byte("hello world"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := "sha512:309ecc489c12d6eb4cc40f50c902f2b4d0ed77ee511a7c7a9bcd3ca86d4cd86f989dd35bc5ff499670da34255b45b0cfd830e81f605dcf7dc5542e93ae9cd76f"
+	if digest != expected {
+		t.Errorf("Expected digest %s, got %s", expected, digest)
+	}
+
+	matched, err := ValidateDigest([]byte("hello world"), digest)
+	if err != nil || !matched {
+		t.Errorf("Expected sha512 digest to validate, got matched=%v err=%v", matched, err)
+	}
+
+	if _, err := CalculateDigestWith("md5", []byte("hello world")); err == nil {
+		t.Error("Expected an error for an unsupported algorithm")
+	}
+}
+
+func TestIsDigest(t *testing.T) {
+	tests := []struct {
+		reference string
+		want      bool
+	}{
+		{"sha256:b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9", true},
+		{"sha512:309ecc489c12d6eb4cc40f50c902f2b4d0ed77ee511a7c7a9bcd3ca86d4cd86f989dd35bc5ff499670da34255b45b0cfd830e81f605dcf7dc5542e93ae9cd76f", true},
+		{"sha512:b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9", false},
+		{"sha512:309ECC489C12D6EB4CC40F50C902F2B4D0ED77EE511A7C7A9BCD3CA86D4CD86F989DD35BC5FF499670DA34255B45B0CFD830E81F605DCF7DC5542E93AE9CD76F", false},
+		{"sha512:", false},
+		{"sha384:abc", false},
+		{"md5:5eb63bbbe01eeed093cb22bb8f5acdc3", false},
+		{"latest", false},
+		{"v1.2.3", false},
+	}
+
+	for _, tc := range tests {
+		if got := IsDigest(tc.reference); got != tc.want {
+			t.Errorf("IsDigest(%q) = %v, want %v", tc.reference, got, tc.want)
+		}
+	}
+}
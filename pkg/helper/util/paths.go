@@ -0,0 +1,36 @@
+package util
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"freightliner/pkg/helper/errors"
+)
+
+// ExpandHome replaces a leading ~, $HOME or ${HOME} in path with the user's
+// home directory (%USERPROFILE% on Windows) and the platform's path separator,
+// so defaults like "${HOME}/.freightliner" work on every OS. Other paths are
+// returned unchanged.
+func ExpandHome(path string) (string, error) {
+	rest := ""
+	matched := false
+	for _, prefix := range []string{"${HOME}", "$HOME", "~"} {
+		if !strings.HasPrefix(path, prefix) {
+			continue
+		}
+		rest = path[len(prefix):]
+		// "$HOMEDIR" or "~user" are not the home directory
+		matched = rest == "" || rest[0] == '/' || rest[0] == '\\'
+		break
+	}
+	if !matched {
+		return path, nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", errors.Wrap(err, "failed to get user home directory")
+	}
+	return filepath.Join(home, filepath.FromSlash(strings.ReplaceAll(rest, `\`, "/"))), nil
+}
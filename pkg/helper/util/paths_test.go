@@ -0,0 +1,39 @@
+package util
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestExpandHome(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{"${HOME}/.freightliner/checkpoints", filepath.Join(home, ".freightliner", "checkpoints")},
+		{"$HOME/.freightliner", filepath.Join(home, ".freightliner")},
+		{"~/checkpoints", filepath.Join(home, "checkpoints")},
+		{`~\checkpoints`, filepath.Join(home, "checkpoints")},
+		{"~", home},
+		{"~other/checkpoints", "~other/checkpoints"},
+		{"$HOMEDIR/checkpoints", "$HOMEDIR/checkpoints"},
+		{"/var/lib/freightliner", "/var/lib/freightliner"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			got, err := ExpandHome(tt.path)
+			if err != nil {
+				t.Fatalf("ExpandHome(%q) error = %v", tt.path, err)
+			}
+			if got != tt.expected {
+				t.Errorf("ExpandHome(%q) = %q, want %q", tt.path, got, tt.expected)
+			}
+		})
+	}
+}
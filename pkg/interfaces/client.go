@@ -121,6 +121,14 @@ const (
 	HealthStatusUnknown   HealthStatus = "unknown"
 )
 
+// GarbageCollector is implemented by clients whose registry can be asked to
+// reclaim the storage of untagged manifests and unreferenced blobs
+type GarbageCollector interface {
+	// StartGarbageCollection starts a garbage collection run and returns its
+	// ID, or "" when the registry does not report one
+	StartGarbageCollection(ctx context.Context, deleteUntagged bool) (string, error)
+}
+
 // ===== CLIENT COMPOSITION INTERFACES =====
 
 // BasicClient provides basic client functionality
@@ -224,3 +232,36 @@ type FederatedClient interface {
 	// GetRepositoryFromAnyRegistry finds a repository in any registered registry
 	GetRepositoryFromAnyRegistry(ctx context.Context, name string) (Repository, string, error)
 }
+
+// ===== REPOSITORY CREATION INTERFACES =====
+
+// RepositoryCreator is implemented by clients that can create repositories
+type RepositoryCreator interface {
+	// CreateRepository creates a new repository with the given name and tags
+	CreateRepository(ctx context.Context, name string, tags map[string]string) (Repository, error)
+}
+
+// RepositorySettings are applied to a repository when it is created
+type RepositorySettings struct {
+	// Tags are resource tags or labels attached to the repository
+	Tags map[string]string
+
+	// ImmutableTags prevents existing tags from being overwritten
+	ImmutableTags bool
+
+	// ScanOnPush enables vulnerability scanning of pushed images
+	ScanOnPush bool
+
+	// EncryptionType selects at-rest encryption, e.g. "AES256" or "KMS"
+	EncryptionType string
+
+	// KMSKey is the key used when EncryptionType is "KMS"
+	KMSKey string
+}
+
+// ConfigurableRepositoryCreator is implemented by clients that can apply
+// settings beyond tags when creating a repository
+type ConfigurableRepositoryCreator interface {
+	// CreateRepositoryWithSettings creates a new repository with the given settings
+	CreateRepositoryWithSettings(ctx context.Context, name string, settings RepositorySettings) (Repository, error)
+}
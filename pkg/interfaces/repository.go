@@ -23,6 +23,7 @@ package interfaces
 import (
 	"context"
 	"io"
+	"time"
 
 	"github.com/google/go-containerregistry/pkg/name"
 	v1 "github.com/google/go-containerregistry/pkg/v1"
@@ -82,6 +83,29 @@ type Manifest struct {
 	Config *LayerDescriptor `json:"config,omitempty"`
 }
 
+// TagDigest is a tag with the digest it points to, as reported by registry
+// metadata
+type TagDigest struct {
+	Tag      string
+	Digest   string
+	PushedAt time.Time
+}
+
+// TagDigestLister is implemented by repositories that can report the digest
+// of every tag in one listing instead of a request per tag
+type TagDigestLister interface {
+	// ListTagDigests returns the digest of every tag in the repository
+	ListTagDigests(ctx context.Context) ([]TagDigest, error)
+}
+
+// TagHistoryLister is implemented by repositories whose registry records the
+// digests a tag pointed to before it was moved
+type TagHistoryLister interface {
+	// ListTagHistory returns the digests tag has pointed to, newest first,
+	// starting with the one it points to now
+	ListTagHistory(ctx context.Context, tag string) ([]TagDigest, error)
+}
+
 // ManifestAccessor provides access to manifests
 type ManifestAccessor interface {
 	// GetManifest returns the manifest for the given tag
@@ -254,6 +278,76 @@ type ContextualTagLister interface {
 	CountTags(ctx context.Context) (int, error)
 }
 
+// TagListVersioner is implemented by repositories whose registry returns an ETag for the tag list
+type TagListVersioner interface {
+	// TagListETag returns the current tag list ETag and whether it differs from previous.
+	// An empty ETag means the registry did not report one.
+	TagListETag(ctx context.Context, previous string) (etag string, changed bool, err error)
+}
+
+// ReferenceDeleter is implemented by repositories that can delete a single tag or digest
+type ReferenceDeleter interface {
+	// DeleteReference removes a tag, or the manifest it names when reference is a
+	// digest. Deleting a tag leaves the manifest and its other tags in place where
+	// the registry supports untagging.
+	DeleteReference(ctx context.Context, reference string) error
+}
+
+// ScanFindingsSummary is a registry's vulnerability scan result for one image
+type ScanFindingsSummary struct {
+	// Scanner names the registry scanner, e.g. "ecr"
+	Scanner string `json:"scanner"`
+
+	// ImageDigest is the digest of the scanned image manifest
+	ImageDigest string `json:"imageDigest,omitempty"`
+
+	// Status is the scanner's status, e.g. COMPLETE or FAILED
+	Status string `json:"status"`
+
+	// SeverityCounts maps a severity such as CRITICAL or HIGH to its number of findings
+	SeverityCounts map[string]int `json:"severityCounts,omitempty"`
+
+	ScanCompletedAt              time.Time `json:"scanCompletedAt,omitempty"`
+	VulnerabilitySourceUpdatedAt time.Time `json:"vulnerabilitySourceUpdatedAt,omitempty"`
+}
+
+// ScanFindingsProvider is implemented by repositories whose registry scans images on push
+type ScanFindingsProvider interface {
+	// GetScanFindings returns the scan summary for a tag or digest, or a
+	// not-found error when the image has not been scanned
+	GetScanFindings(ctx context.Context, reference string) (*ScanFindingsSummary, error)
+}
+
+// RepositoryMetadata is the browsable description a registry keeps for a repository
+type RepositoryMetadata struct {
+	// Description is a short, single-line summary of the repository
+	Description string `json:"description,omitempty"`
+
+	// Readme is the repository's long-form, usually markdown, documentation
+	Readme string `json:"readme,omitempty"`
+}
+
+// IsEmpty reports whether the metadata has nothing to copy
+func (m RepositoryMetadata) IsEmpty() bool {
+	return m.Description == "" && m.Readme == ""
+}
+
+// RepositoryMetadataReader is implemented by repositories whose registry keeps a
+// description or readme for them
+type RepositoryMetadataReader interface {
+	// GetRepositoryMetadata returns the repository's description and readme
+	GetRepositoryMetadata(ctx context.Context) (*RepositoryMetadata, error)
+}
+
+// RepositoryMetadataWriter is implemented by repositories whose registry allows
+// setting their description or readme
+type RepositoryMetadataWriter interface {
+	// SetRepositoryMetadata replaces the repository's description and readme.
+	// Registries with a single text field store the readme, or the description
+	// when there is no readme.
+	SetRepositoryMetadata(ctx context.Context, metadata RepositoryMetadata) error
+}
+
 // ContextualManifestManager extends ManifestManager with batch operations
 type ContextualManifestManager interface {
 	ManifestManager
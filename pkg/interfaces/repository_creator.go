@@ -0,0 +1,34 @@
+package interfaces
+
+import (
+	"context"
+	"fmt"
+
+	"freightliner/pkg/helper/errors"
+	"freightliner/pkg/helper/readonly"
+)
+
+// CreateRepository creates a repository with whichever creation interface the
+// client implements. Clients that only implement RepositoryCreator receive the
+// tags; the remaining settings are not applied.
+func CreateRepository(ctx context.Context, client RegistryClient, name string, settings RepositorySettings) (Repository, error) {
+	if err := readonly.Check(fmt.Sprintf("create repository %s/%s", client.GetRegistryName(), name)); err != nil {
+		return nil, err
+	}
+
+	if creator, ok := client.(ConfigurableRepositoryCreator); ok {
+		return creator.CreateRepositoryWithSettings(ctx, name, settings)
+	}
+
+	if creator, ok := client.(RepositoryCreator); ok {
+		return creator.CreateRepository(ctx, name, settings.Tags)
+	}
+
+	return nil, errors.NotImplementedf("registry %s does not support repository creation", client.GetRegistryName())
+}
+
+// SupportsRepositorySettings reports whether client applies settings beyond tags
+func SupportsRepositorySettings(client RegistryClient) bool {
+	_, ok := client.(ConfigurableRepositoryCreator)
+	return ok
+}
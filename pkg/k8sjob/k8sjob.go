@@ -0,0 +1,406 @@
+// Package k8sjob renders the Kubernetes manifests that run one Freightliner
+// invocation as a Job, so large migrations run on cluster compute with their
+// progress kept on a persistent volume or in S3.
+package k8sjob
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"freightliner/pkg/helper/errors"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// DefaultImage is the Freightliner image run by the Job
+	DefaultImage = "ghcr.io/hemzaz/freightliner:latest"
+
+	// DefaultSyncImage copies checkpoints to and from S3
+	DefaultSyncImage = "amazon/aws-cli:2.17.0"
+
+	// DefaultCheckpointSize is the size of the volume claim created for
+	// checkpoints
+	DefaultCheckpointSize = "1Gi"
+
+	// CheckpointDir is where the Job keeps checkpoints and sync state
+	CheckpointDir = "/var/lib/freightliner/checkpoints"
+
+	// FilesDir is where files added to the ConfigMap are mounted
+	FilesDir = "/etc/freightliner/files"
+
+	// DockerConfigDir is where the Docker config from the Secret is mounted
+	DockerConfigDir = "/etc/freightliner/docker"
+
+	// s3SyncInterval is how often checkpoints are uploaded to S3
+	s3SyncInterval = 30 * time.Second
+
+	// runAsUser is the user of the Freightliner image
+	runAsUser = 1001
+)
+
+// Options describe the Job and the invocation it runs
+type Options struct {
+	// Name names the Job and the objects created with it
+	Name      string
+	Namespace string
+	Image     string
+
+	// Args is the Freightliner invocation, without the binary, e.g.
+	// ["replicate-tree", "ecr/prod", "gcr/backup"]
+	Args []string
+
+	// Files are local files, by path, put in the ConfigMap. Arguments naming
+	// one of them are rewritten to its path in the container.
+	Files map[string][]byte
+
+	// SecretEnv are environment variables put in the Secret, e.g. registry
+	// or cloud credentials
+	SecretEnv map[string]string
+
+	// DockerConfig is a Docker config.json put in the Secret
+	DockerConfig []byte
+
+	// CheckpointPVC names an existing volume claim for checkpoints. When it
+	// and CheckpointS3 are empty, a claim of CheckpointSize is created.
+	CheckpointPVC  string
+	CheckpointSize string
+
+	// CheckpointS3 is an s3://bucket/prefix URL checkpoints are restored
+	// from before the run and uploaded to while it runs
+	CheckpointS3 string
+	S3Region     string
+	SyncImage    string
+
+	// Resources of the Freightliner container (optional)
+	CPU    string
+	Memory string
+
+	BackoffLimit     int
+	ActiveDeadline   time.Duration
+	TTLAfterFinished time.Duration
+	ServiceAccount   string
+}
+
+var (
+	namePattern = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+	keyPattern  = regexp.MustCompile(`^[-._a-zA-Z0-9]+$`)
+	s3Pattern   = regexp.MustCompile(`^s3://[a-z0-9][-.a-z0-9]*[a-z0-9](/[-._a-zA-Z0-9/]*)?$`)
+)
+
+// Render returns the ConfigMap, Secret, PersistentVolumeClaim and Job that
+// run opts.Args, as one multi-document YAML stream
+func Render(opts Options) ([]byte, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	if opts.Image == "" {
+		opts.Image = DefaultImage
+	}
+	if opts.SyncImage == "" {
+		opts.SyncImage = DefaultSyncImage
+	}
+	if opts.CheckpointSize == "" {
+		opts.CheckpointSize = DefaultCheckpointSize
+	}
+
+	labels := map[string]string{
+		"app.kubernetes.io/name":      "freightliner",
+		"app.kubernetes.io/instance":  opts.Name,
+		"app.kubernetes.io/component": "migration",
+	}
+	meta := func(suffix string) objectMeta {
+		return objectMeta{Name: opts.Name + suffix, Namespace: opts.Namespace, Labels: labels}
+	}
+
+	var docs []interface{}
+	args, files := opts.rewriteFiles()
+	args = checkpointArgs(args)
+
+	pod := podSpec{
+		RestartPolicy:      "Never",
+		ServiceAccountName: opts.ServiceAccount,
+		SecurityContext: &podSecurityContext{
+			RunAsNonRoot: true,
+			RunAsUser:    runAsUser,
+			RunAsGroup:   runAsUser,
+			FSGroup:      runAsUser,
+		},
+		Volumes: []volume{{Name: "tmp", EmptyDir: &struct{}{}}},
+	}
+	main := container{
+		Name:            "freightliner",
+		Image:           opts.Image,
+		Args:            args,
+		SecurityContext: restrictedContainer(),
+		VolumeMounts: []volumeMount{
+			{Name: "checkpoints", MountPath: CheckpointDir},
+			{Name: "tmp", MountPath: "/tmp"},
+		},
+	}
+	if opts.CPU != "" || opts.Memory != "" {
+		quantities := map[string]string{}
+		if opts.CPU != "" {
+			quantities["cpu"] = opts.CPU
+		}
+		if opts.Memory != "" {
+			quantities["memory"] = opts.Memory
+		}
+		main.Resources = &resources{Requests: quantities}
+		if opts.Memory != "" {
+			main.Resources.Limits = map[string]string{"memory": opts.Memory}
+		}
+	}
+
+	if len(files) > 0 {
+		docs = append(docs, configMap{APIVersion: "v1", Kind: "ConfigMap", Metadata: meta("-files"), Data: files})
+		pod.Volumes = append(pod.Volumes, volume{Name: "files", ConfigMap: &configMapSource{Name: opts.Name + "-files"}})
+		main.VolumeMounts = append(main.VolumeMounts, volumeMount{Name: "files", MountPath: FilesDir, ReadOnly: true})
+	}
+
+	if len(opts.SecretEnv) > 0 || opts.DockerConfig != nil {
+		secretData := map[string]string{}
+		for name, value := range opts.SecretEnv {
+			secretData[name] = value
+		}
+		if opts.DockerConfig != nil {
+			secretData["config.json"] = string(opts.DockerConfig)
+		}
+		docs = append(docs, secret{APIVersion: "v1", Kind: "Secret", Metadata: meta("-credentials"), Type: "Opaque", StringData: secretData})
+
+		if len(opts.SecretEnv) > 0 {
+			// Variables are listed one by one, so config.json is not exported
+			names := make([]string, 0, len(opts.SecretEnv))
+			for name := range opts.SecretEnv {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				main.Env = append(main.Env, envVar{Name: name, ValueFrom: &envVarSource{
+					SecretKeyRef: &keySelector{Name: opts.Name + "-credentials", Key: name},
+				}})
+			}
+		}
+		if opts.DockerConfig != nil {
+			main.Env = append(main.Env, envVar{Name: "DOCKER_CONFIG", Value: DockerConfigDir})
+			pod.Volumes = append(pod.Volumes, volume{Name: "docker-config", Secret: &secretSource{
+				SecretName: opts.Name + "-credentials",
+				Items:      []keyToPath{{Key: "config.json", Path: "config.json"}},
+			}})
+			main.VolumeMounts = append(main.VolumeMounts, volumeMount{Name: "docker-config", MountPath: DockerConfigDir, ReadOnly: true})
+		}
+	}
+
+	switch {
+	case opts.CheckpointS3 != "":
+		pod.Volumes = append(pod.Volumes, volume{Name: "checkpoints", EmptyDir: &struct{}{}})
+		pod.InitContainers = opts.s3Containers(main.Env)
+	case opts.CheckpointPVC != "":
+		pod.Volumes = append(pod.Volumes, volume{Name: "checkpoints", PersistentVolumeClaim: &claimSource{ClaimName: opts.CheckpointPVC}})
+	default:
+		docs = append(docs, persistentVolumeClaim{
+			APIVersion: "v1",
+			Kind:       "PersistentVolumeClaim",
+			Metadata:   meta("-checkpoints"),
+			Spec: claimSpec{
+				AccessModes: []string{"ReadWriteOnce"},
+				Resources:   resources{Requests: map[string]string{"storage": opts.CheckpointSize}},
+			},
+		})
+		pod.Volumes = append(pod.Volumes, volume{Name: "checkpoints", PersistentVolumeClaim: &claimSource{ClaimName: opts.Name + "-checkpoints"}})
+	}
+	pod.Containers = []container{main}
+
+	j := job{
+		APIVersion: "batch/v1",
+		Kind:       "Job",
+		Metadata:   meta(""),
+		Spec: jobSpec{
+			BackoffLimit: &opts.BackoffLimit,
+			Template:     podTemplate{Metadata: objectMeta{Labels: labels}, Spec: pod},
+		},
+	}
+	if opts.ActiveDeadline > 0 {
+		seconds := int64(opts.ActiveDeadline.Seconds())
+		j.Spec.ActiveDeadlineSeconds = &seconds
+	}
+	if opts.TTLAfterFinished > 0 {
+		seconds := int64(opts.TTLAfterFinished.Seconds())
+		j.Spec.TTLSecondsAfterFinished = &seconds
+	}
+	docs = append(docs, j)
+
+	var buf bytes.Buffer
+	for i, doc := range docs {
+		if i > 0 {
+			buf.WriteString("---\n")
+		}
+		encoder := yaml.NewEncoder(&buf)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(doc); err != nil {
+			return nil, errors.Wrap(err, "failed to encode manifest")
+		}
+		if err := encoder.Close(); err != nil {
+			return nil, errors.Wrap(err, "failed to encode manifest")
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// validate checks the names and settings that would make invalid manifests
+func (opts Options) validate() error {
+	if !namePattern.MatchString(opts.Name) || len(opts.Name) > 52 {
+		return errors.InvalidInputf("job name %q must be a DNS label of at most 52 characters", opts.Name)
+	}
+	if len(opts.Args) == 0 {
+		return errors.InvalidInputf("a freightliner command to run is required")
+	}
+	if opts.CheckpointPVC != "" && opts.CheckpointS3 != "" {
+		return errors.InvalidInputf("checkpoints go to a volume claim or S3, not both")
+	}
+	if opts.CheckpointS3 != "" && !s3Pattern.MatchString(opts.CheckpointS3) {
+		return errors.InvalidInputf("invalid S3 checkpoint URL %q (expected s3://bucket/prefix)", opts.CheckpointS3)
+	}
+	if opts.BackoffLimit < 0 {
+		return errors.InvalidInputf("backoff limit must not be negative")
+	}
+	for name := range opts.SecretEnv {
+		if name == "config.json" || !keyPattern.MatchString(name) {
+			return errors.InvalidInputf("invalid secret environment variable %q", name)
+		}
+	}
+
+	seen := map[string]string{}
+	for local := range opts.Files {
+		key := filepath.Base(local)
+		if !keyPattern.MatchString(key) {
+			return errors.InvalidInputf("file name %q cannot be a ConfigMap key", key)
+		}
+		if other, ok := seen[key]; ok {
+			return errors.InvalidInputf("files %s and %s share the name %s", other, local, key)
+		}
+		seen[key] = local
+	}
+	return nil
+}
+
+// rewriteFiles returns the arguments with every local file path, alone or as
+// a --flag=path value, replaced by its path in the container, and the
+// ConfigMap data holding the files
+func (opts Options) rewriteFiles() ([]string, map[string]string) {
+	files := map[string]string{}
+	mounted := map[string]string{}
+	for local, content := range opts.Files {
+		key := filepath.Base(local)
+		files[key] = string(content)
+		mounted[local] = path.Join(FilesDir, key)
+	}
+
+	args := make([]string, len(opts.Args))
+	for i, arg := range opts.Args {
+		args[i] = arg
+		if target, ok := mounted[arg]; ok {
+			args[i] = target
+			continue
+		}
+		if flag, value, ok := strings.Cut(arg, "="); ok && strings.HasPrefix(flag, "-") {
+			if target, ok := mounted[value]; ok {
+				args[i] = flag + "=" + target
+			}
+		}
+	}
+	return args, files
+}
+
+// checkpointArgs adds the flags that make a restarted pod continue the run:
+// replicate-tree resumes its latest checkpoint and sync skips the tags it
+// already copied. Flags the invocation sets itself are left alone.
+func checkpointArgs(args []string) []string {
+	has := func(flag string) bool {
+		for _, arg := range args {
+			if arg == flag || strings.HasPrefix(arg, flag+"=") {
+				return true
+			}
+		}
+		return false
+	}
+
+	var extra []string
+	switch args[0] {
+	case "replicate-tree":
+		if !has("--checkpoint-dir") && !has("--resume") {
+			extra = []string{"--checkpoint", "--checkpoint-dir", CheckpointDir, "--resume", "latest", "--skip-completed"}
+		}
+	case "sync":
+		if !has("--state-file") {
+			extra = []string{"--since-last-success", "--state-file", path.Join(CheckpointDir, "sync-state.json")}
+		}
+	}
+	if len(extra) == 0 {
+		return args
+	}
+
+	// Flags after a "--" terminator would be read as arguments
+	end := len(args)
+	for i, arg := range args {
+		if arg == "--" {
+			end = i
+			break
+		}
+	}
+	return append(append(append([]string{}, args[:end]...), extra...), args[end:]...)
+}
+
+// s3Containers returns the init container restoring checkpoints from S3 and
+// the sidecar uploading them while the Job runs and once more when it stops
+func (opts Options) s3Containers(env []envVar) []container {
+	env = append([]envVar{{Name: "HOME", Value: "/tmp"}}, env...)
+	if opts.S3Region != "" {
+		env = append(env, envVar{Name: "AWS_DEFAULT_REGION", Value: opts.S3Region})
+	}
+	mounts := []volumeMount{
+		{Name: "checkpoints", MountPath: CheckpointDir},
+		{Name: "tmp", MountPath: "/tmp"},
+	}
+	upload := fmt.Sprintf("aws s3 sync %s '%s' --only-show-errors", CheckpointDir, opts.CheckpointS3)
+	always := "Always"
+
+	return []container{
+		{
+			Name:            "restore-checkpoints",
+			Image:           opts.SyncImage,
+			Command:         []string{"/bin/sh", "-c"},
+			Args:            []string{fmt.Sprintf("aws s3 sync '%s' %s --only-show-errors", opts.CheckpointS3, CheckpointDir)},
+			Env:             env,
+			SecurityContext: restrictedContainer(),
+			VolumeMounts:    mounts,
+		},
+		{
+			// A sidecar: an init container that keeps running beside the Job
+			Name:          "upload-checkpoints",
+			Image:         opts.SyncImage,
+			RestartPolicy: &always,
+			Command:       []string{"/bin/sh", "-c"},
+			Args: []string{fmt.Sprintf("trap '%s; exit 0' TERM\nwhile true; do\n  sleep %d & wait $!\n  %s\ndone\n",
+				strings.ReplaceAll(upload, "'", `'\''`), int(s3SyncInterval.Seconds()), upload)},
+			Env:             env,
+			SecurityContext: restrictedContainer(),
+			VolumeMounts:    mounts,
+		},
+	}
+}
+
+// restrictedContainer is the security context of every container
+func restrictedContainer() *containerSecurityContext {
+	return &containerSecurityContext{
+		AllowPrivilegeEscalation: false,
+		ReadOnlyRootFilesystem:   true,
+		Capabilities:             &capabilities{Drop: []string{"ALL"}},
+	}
+}
@@ -0,0 +1,165 @@
+package k8sjob
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+// decode splits a rendered stream into its documents by kind
+func decode(t *testing.T, data []byte) map[string]map[string]interface{} {
+	t.Helper()
+
+	docs := map[string]map[string]interface{}{}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var doc map[string]interface{}
+		err := decoder.Decode(&doc)
+		if err == io.EOF {
+			return docs
+		}
+		require.NoError(t, err)
+		docs[doc["kind"].(string)] = doc
+	}
+}
+
+// podSpecOf returns the pod spec of a decoded Job
+func podSpecOf(t *testing.T, j map[string]interface{}) map[string]interface{} {
+	t.Helper()
+	spec := j["spec"].(map[string]interface{})
+	return spec["template"].(map[string]interface{})["spec"].(map[string]interface{})
+}
+
+func TestRenderReplicateTree(t *testing.T) {
+	data, err := Render(Options{
+		Name:             "prod-migration",
+		Namespace:        "platform",
+		Args:             []string{"replicate-tree", "ecr/prod", "gcr/backup", "--workers", "16"},
+		SecretEnv:        map[string]string{"AWS_ACCESS_KEY_ID": "AKIA", "AWS_SECRET_ACCESS_KEY": "secret"},
+		DockerConfig:     []byte(`{"auths":{}}`),
+		CPU:              "2",
+		Memory:           "4Gi",
+		BackoffLimit:     4,
+		TTLAfterFinished: 24 * time.Hour,
+	})
+	require.NoError(t, err)
+
+	docs := decode(t, data)
+	require.Contains(t, docs, "Job")
+	require.Contains(t, docs, "Secret")
+	require.Contains(t, docs, "PersistentVolumeClaim")
+	assert.NotContains(t, docs, "ConfigMap", "no files were added")
+
+	secretData := docs["Secret"]["stringData"].(map[string]interface{})
+	assert.Equal(t, "AKIA", secretData["AWS_ACCESS_KEY_ID"])
+	assert.Equal(t, `{"auths":{}}`, secretData["config.json"])
+
+	claim := docs["PersistentVolumeClaim"]
+	assert.Equal(t, "prod-migration-checkpoints", claim["metadata"].(map[string]interface{})["name"])
+
+	j := docs["Job"]
+	assert.Equal(t, "platform", j["metadata"].(map[string]interface{})["namespace"])
+	spec := j["spec"].(map[string]interface{})
+	assert.Equal(t, 4, spec["backoffLimit"])
+	assert.Equal(t, 86400, spec["ttlSecondsAfterFinished"])
+
+	pod := podSpecOf(t, j)
+	assert.Equal(t, "Never", pod["restartPolicy"])
+	main := pod["containers"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, DefaultImage, main["image"])
+	assert.Equal(t, []interface{}{
+		"replicate-tree", "ecr/prod", "gcr/backup", "--workers", "16",
+		"--checkpoint", "--checkpoint-dir", CheckpointDir, "--resume", "latest", "--skip-completed",
+	}, main["args"])
+
+	env := main["env"].([]interface{})
+	require.Len(t, env, 3)
+	assert.Equal(t, "AWS_ACCESS_KEY_ID", env[0].(map[string]interface{})["name"])
+	assert.Equal(t, map[string]interface{}{"name": "DOCKER_CONFIG", "value": DockerConfigDir}, env[2])
+	assert.Equal(t, map[string]interface{}{
+		"requests": map[string]interface{}{"cpu": "2", "memory": "4Gi"},
+		"limits":   map[string]interface{}{"memory": "4Gi"},
+	}, main["resources"])
+}
+
+func TestRenderSyncWithFilesAndS3(t *testing.T) {
+	data, err := Render(Options{
+		Name:         "mirror",
+		Args:         []string{"sync", "--config", "configs/sync.yaml", "--policy=./policy.rego"},
+		Files:        map[string][]byte{"configs/sync.yaml": []byte("images: []\n"), "./policy.rego": []byte("package p\n")},
+		CheckpointS3: "s3://migrations/prod",
+		S3Region:     "eu-west-1",
+	})
+	require.NoError(t, err)
+
+	docs := decode(t, data)
+	assert.NotContains(t, docs, "PersistentVolumeClaim")
+	assert.Equal(t, map[string]interface{}{"sync.yaml": "images: []\n", "policy.rego": "package p\n"}, docs["ConfigMap"]["data"])
+
+	pod := podSpecOf(t, docs["Job"])
+	main := pod["containers"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, []interface{}{
+		"sync", "--config", FilesDir + "/sync.yaml", "--policy=" + FilesDir + "/policy.rego",
+		"--since-last-success", "--state-file", CheckpointDir + "/sync-state.json",
+	}, main["args"])
+
+	initContainers := pod["initContainers"].([]interface{})
+	require.Len(t, initContainers, 2)
+	restore := initContainers[0].(map[string]interface{})
+	assert.Contains(t, restore["args"].([]interface{})[0], "aws s3 sync 's3://migrations/prod' "+CheckpointDir)
+	upload := initContainers[1].(map[string]interface{})
+	assert.Equal(t, "Always", upload["restartPolicy"], "the uploader is a sidecar")
+	assert.Contains(t, upload["args"].([]interface{})[0], "trap")
+	assert.Contains(t, upload["env"], map[string]interface{}{"name": "AWS_DEFAULT_REGION", "value": "eu-west-1"})
+}
+
+func TestRenderKeepsExplicitCheckpointFlags(t *testing.T) {
+	data, err := Render(Options{
+		Name:          "resume",
+		Args:          []string{"replicate-tree", "--resume", "abc", "ecr/prod", "gcr/backup", "--", "extra"},
+		CheckpointPVC: "shared-checkpoints",
+	})
+	require.NoError(t, err)
+
+	docs := decode(t, data)
+	assert.NotContains(t, docs, "PersistentVolumeClaim", "the existing claim is used")
+	main := podSpecOf(t, docs["Job"])["containers"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, []interface{}{"replicate-tree", "--resume", "abc", "ecr/prod", "gcr/backup", "--", "extra"}, main["args"])
+
+	assert.Equal(t,
+		[]string{"replicate-tree", "a", "b", "--checkpoint", "--checkpoint-dir", CheckpointDir, "--resume", "latest", "--skip-completed", "--", "c"},
+		checkpointArgs([]string{"replicate-tree", "a", "b", "--", "c"}))
+}
+
+func TestRenderValidation(t *testing.T) {
+	valid := Options{Name: "migration", Args: []string{"replicate", "a", "b"}}
+	_, err := Render(valid)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		modify func(*Options)
+	}{
+		{"invalid name", func(o *Options) { o.Name = "Big_Migration" }},
+		{"no command", func(o *Options) { o.Args = nil }},
+		{"claim and S3", func(o *Options) { o.CheckpointPVC, o.CheckpointS3 = "claim", "s3://bucket/prefix" }},
+		{"S3 URL with quotes", func(o *Options) { o.CheckpointS3 = "s3://bucket/'; rm -rf /" }},
+		{"file names collide", func(o *Options) {
+			o.Files = map[string][]byte{"a/sync.yaml": nil, "b/sync.yaml": nil}
+		}},
+		{"secret key clashes with docker config", func(o *Options) { o.SecretEnv = map[string]string{"config.json": "x"} }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := valid
+			tt.modify(&opts)
+			_, err := Render(opts)
+			assert.Error(t, err)
+		})
+	}
+}
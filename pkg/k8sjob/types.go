@@ -0,0 +1,146 @@
+package k8sjob
+
+// The subset of the Kubernetes API the rendered manifests use, with fields in
+// the order kubectl prints them
+
+type objectMeta struct {
+	Name      string            `yaml:"name,omitempty"`
+	Namespace string            `yaml:"namespace,omitempty"`
+	Labels    map[string]string `yaml:"labels,omitempty"`
+}
+
+type configMap struct {
+	APIVersion string            `yaml:"apiVersion"`
+	Kind       string            `yaml:"kind"`
+	Metadata   objectMeta        `yaml:"metadata"`
+	Data       map[string]string `yaml:"data"`
+}
+
+type secret struct {
+	APIVersion string            `yaml:"apiVersion"`
+	Kind       string            `yaml:"kind"`
+	Metadata   objectMeta        `yaml:"metadata"`
+	Type       string            `yaml:"type"`
+	StringData map[string]string `yaml:"stringData"`
+}
+
+type persistentVolumeClaim struct {
+	APIVersion string     `yaml:"apiVersion"`
+	Kind       string     `yaml:"kind"`
+	Metadata   objectMeta `yaml:"metadata"`
+	Spec       claimSpec  `yaml:"spec"`
+}
+
+type claimSpec struct {
+	AccessModes []string  `yaml:"accessModes"`
+	Resources   resources `yaml:"resources"`
+}
+
+type job struct {
+	APIVersion string     `yaml:"apiVersion"`
+	Kind       string     `yaml:"kind"`
+	Metadata   objectMeta `yaml:"metadata"`
+	Spec       jobSpec    `yaml:"spec"`
+}
+
+type jobSpec struct {
+	BackoffLimit            *int        `yaml:"backoffLimit,omitempty"`
+	ActiveDeadlineSeconds   *int64      `yaml:"activeDeadlineSeconds,omitempty"`
+	TTLSecondsAfterFinished *int64      `yaml:"ttlSecondsAfterFinished,omitempty"`
+	Template                podTemplate `yaml:"template"`
+}
+
+type podTemplate struct {
+	Metadata objectMeta `yaml:"metadata"`
+	Spec     podSpec    `yaml:"spec"`
+}
+
+type podSpec struct {
+	RestartPolicy      string              `yaml:"restartPolicy"`
+	ServiceAccountName string              `yaml:"serviceAccountName,omitempty"`
+	SecurityContext    *podSecurityContext `yaml:"securityContext,omitempty"`
+	InitContainers     []container         `yaml:"initContainers,omitempty"`
+	Containers         []container         `yaml:"containers"`
+	Volumes            []volume            `yaml:"volumes,omitempty"`
+}
+
+type podSecurityContext struct {
+	RunAsNonRoot bool  `yaml:"runAsNonRoot"`
+	RunAsUser    int64 `yaml:"runAsUser"`
+	RunAsGroup   int64 `yaml:"runAsGroup"`
+	FSGroup      int64 `yaml:"fsGroup"`
+}
+
+type container struct {
+	Name            string                    `yaml:"name"`
+	Image           string                    `yaml:"image"`
+	RestartPolicy   *string                   `yaml:"restartPolicy,omitempty"`
+	Command         []string                  `yaml:"command,omitempty"`
+	Args            []string                  `yaml:"args,omitempty"`
+	Env             []envVar                  `yaml:"env,omitempty"`
+	Resources       *resources                `yaml:"resources,omitempty"`
+	SecurityContext *containerSecurityContext `yaml:"securityContext,omitempty"`
+	VolumeMounts    []volumeMount             `yaml:"volumeMounts,omitempty"`
+}
+
+type envVar struct {
+	Name      string        `yaml:"name"`
+	Value     string        `yaml:"value,omitempty"`
+	ValueFrom *envVarSource `yaml:"valueFrom,omitempty"`
+}
+
+type envVarSource struct {
+	SecretKeyRef *keySelector `yaml:"secretKeyRef,omitempty"`
+}
+
+type keySelector struct {
+	Name string `yaml:"name"`
+	Key  string `yaml:"key"`
+}
+
+type resources struct {
+	Requests map[string]string `yaml:"requests,omitempty"`
+	Limits   map[string]string `yaml:"limits,omitempty"`
+}
+
+type containerSecurityContext struct {
+	AllowPrivilegeEscalation bool          `yaml:"allowPrivilegeEscalation"`
+	ReadOnlyRootFilesystem   bool          `yaml:"readOnlyRootFilesystem"`
+	Capabilities             *capabilities `yaml:"capabilities,omitempty"`
+}
+
+type capabilities struct {
+	Drop []string `yaml:"drop"`
+}
+
+type volumeMount struct {
+	Name      string `yaml:"name"`
+	MountPath string `yaml:"mountPath"`
+	ReadOnly  bool   `yaml:"readOnly,omitempty"`
+}
+
+type volume struct {
+	Name                  string           `yaml:"name"`
+	EmptyDir              *struct{}        `yaml:"emptyDir,omitempty"`
+	ConfigMap             *configMapSource `yaml:"configMap,omitempty"`
+	Secret                *secretSource    `yaml:"secret,omitempty"`
+	PersistentVolumeClaim *claimSource     `yaml:"persistentVolumeClaim,omitempty"`
+}
+
+type configMapSource struct {
+	Name string `yaml:"name"`
+}
+
+type secretSource struct {
+	SecretName string      `yaml:"secretName"`
+	Items      []keyToPath `yaml:"items,omitempty"`
+}
+
+type keyToPath struct {
+	Key  string `yaml:"key"`
+	Path string `yaml:"path"`
+}
+
+type claimSource struct {
+	ClaimName string `yaml:"claimName"`
+}
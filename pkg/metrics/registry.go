@@ -26,6 +26,7 @@ type Registry struct {
 	tagCopyTotal      *prometheus.CounterVec
 	tagCopyDuration   *prometheus.HistogramVec
 	tagCopyBytesTotal *prometheus.CounterVec
+	dedupBytesSaved   *prometheus.CounterVec
 
 	// Job metrics
 	jobsTotal   *prometheus.CounterVec
@@ -138,6 +139,13 @@ func NewRegistry() *Registry {
 			},
 			[]string{"source_repo", "dest_repo"},
 		),
+		dedupBytesSaved: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "freightliner_copy_dedup_bytes_saved_total",
+				Help: "Total bytes not uploaded because an earlier copy in the run already pushed them",
+			},
+			[]string{"dest_registry"},
+		),
 
 		// Job metrics
 		jobsTotal: prometheus.NewCounterVec(
@@ -233,6 +241,7 @@ func (r *Registry) registerMetrics() {
 		r.tagCopyTotal,
 		r.tagCopyDuration,
 		r.tagCopyBytesTotal,
+		r.dedupBytesSaved,
 		r.jobsTotal,
 		r.jobDuration,
 		r.jobsActive,
@@ -294,6 +303,14 @@ func (r *Registry) RecordTagCopy(sourceRepo, destRepo, status string, duration t
 	}
 }
 
+// RecordDedupBytesSaved counts bytes deduplication kept from being uploaded
+// to destRegistry again
+func (r *Registry) RecordDedupBytesSaved(destRegistry string, bytes int64) {
+	if bytes > 0 {
+		r.dedupBytesSaved.WithLabelValues(destRegistry).Add(float64(bytes))
+	}
+}
+
 // Job metrics methods
 func (r *Registry) RecordJob(jobType, status string, duration time.Duration) {
 	r.jobsTotal.WithLabelValues(jobType, status).Inc()
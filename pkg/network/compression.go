@@ -7,6 +7,8 @@ import (
 	"io"
 
 	"freightliner/pkg/helper/errors"
+
+	"github.com/klauspost/compress/zstd"
 )
 
 // CompressionType represents the type of compression to use
@@ -21,6 +23,9 @@ const (
 
 	// ZlibCompression indicates zlib compression should be used
 	ZlibCompression CompressionType = "zlib"
+
+	// ZstdCompression indicates zstd compression should be used
+	ZstdCompression CompressionType = "zstd"
 )
 
 // CompressionLevel controls the tradeoff between speed and compression ratio
@@ -89,6 +94,8 @@ func NewCompressingWriter(w io.Writer, opts CompressorOptions) (io.WriteCloser,
 		return gzip.NewWriterLevel(w, int(opts.Level))
 	case ZlibCompression:
 		return zlib.NewWriterLevel(w, int(opts.Level))
+	case ZstdCompression:
+		return zstd.NewWriter(w, zstd.WithEncoderLevel(zstdLevel(opts.Level)))
 	default:
 		return nil, errors.InvalidInputf("unsupported compression type: %s", opts.Type)
 	}
@@ -108,6 +115,12 @@ func NewDecompressingReader(r io.Reader, compType CompressionType) (io.ReadClose
 		return gzip.NewReader(r)
 	case ZlibCompression:
 		return zlib.NewReader(r)
+	case ZstdCompression:
+		d, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return d.IOReadCloser(), nil
 	default:
 		return nil, errors.InvalidInputf("unsupported compression type: %s", compType)
 	}
@@ -182,11 +195,22 @@ func ParseCompressionType(s string) (CompressionType, error) {
 		return GzipCompression, nil
 	case "zlib":
 		return ZlibCompression, nil
+	case "zstd":
+		return ZstdCompression, nil
 	default:
 		return "", errors.InvalidInputf("unsupported compression type: %s", s)
 	}
 }
 
+// zstdLevel maps a gzip-style level onto the zstd encoder levels:
+// BestSpeed is fastest and BestCompression is the "better" level
+func zstdLevel(level CompressionLevel) zstd.EncoderLevel {
+	if level == DefaultCompression {
+		return zstd.SpeedDefault
+	}
+	return zstd.EncoderLevelFromZstd(int(level))
+}
+
 // String implements the Stringer interface for CompressionType
 func (c CompressionType) String() string {
 	return string(c)
@@ -52,6 +52,16 @@ func TestCompression(t *testing.T) {
 			},
 			shouldCompress: true,
 		},
+		{
+			name: "Zstd compression",
+			data: bytes.Repeat([]byte("z"), 1000), // Highly compressible data
+			opts: CompressionOptions{
+				Type:    ZstdCompression,
+				Level:   BestSpeed,
+				MinSize: 10,
+			},
+			shouldCompress: true,
+		},
 		{
 			name: "Best compression level",
 			data: bytes.Repeat([]byte("c"), 1000),
@@ -6,13 +6,15 @@ import (
 	"crypto/sha256"
 	"encoding/binary"
 	"encoding/json"
-	"fmt"
 	"hash"
 	"math"
+	"sort"
+	"strconv"
 	"time"
 
 	"freightliner/pkg/helper/errors"
 	"freightliner/pkg/helper/log"
+	"freightliner/pkg/helper/util"
 	"freightliner/pkg/interfaces"
 )
 
@@ -313,7 +315,11 @@ type ChunkInfo struct {
 	Checksum string `json:"checksum"`
 }
 
-// DeltaManifest describes the differences between source and destination
+// DeltaManifest describes the differences between source and destination.
+// Generated manifests hold one entry per tag to transfer: Path is the tag,
+// Checksum the source manifest digest, Size the manifest plus every blob it
+// references, and Chunks the blobs the destination does not have yet.
+// TotalDeltaSize is the number of bytes that actually need to move.
 type DeltaManifest struct {
 	SourceRepo      string          `json:"source_repo"`
 	DestRepo        string          `json:"dest_repo"`
@@ -346,20 +352,18 @@ func (g *DeltaGenerator) GenerateManifest(ctx context.Context, sourceClient, des
 		return nil, errors.Wrap(err, "failed to get source repository")
 	}
 
-	_, err = destClient.GetRepository(ctx, destRepo)
+	dstRepo, err := destClient.GetRepository(ctx, destRepo)
 	if err != nil {
-		// If the destination doesn't exist yet, that's okay - empty manifest
-		if errors.Is(err, errors.ErrNotFound) {
-			g.logger.WithFields(map[string]interface{}{
-				"destination": destRepo,
-			}).Info("Destination repository doesn't exist yet")
-			// Create empty manifest with just the source items
-			return g.createEmptyManifest(srcRepo, destRepo)
-		}
-		return nil, errors.Wrap(err, "failed to get destination repository")
+		// If the destination doesn't exist yet, that's okay - every tag is a full copy
+		if !errors.Is(err, errors.ErrNotFound) {
+			return nil, errors.Wrap(err, "failed to get destination repository")
+		}
+		g.logger.WithFields(map[string]interface{}{
+			"destination": destRepo,
+		}).Info("Destination repository doesn't exist yet")
+		dstRepo = nil
 	}
 
-	// Create manifest
 	manifest := &DeltaManifest{
 		SourceRepo:    sourceRepo,
 		DestRepo:      destRepo,
@@ -368,33 +372,179 @@ func (g *DeltaGenerator) GenerateManifest(ctx context.Context, sourceClient, des
 		Entries:       []ManifestEntry{},
 	}
 
-	// This is just a sample implementation - real code would:
-	// 1. List tags in source and destination
-	// 2. For each tag in source that needs to be copied:
-	//    a. Check if it exists in destination
-	//    b. If not, mark for full copy
-	//    c. If yes, compare layers and mark changed ones for delta copy
-	// 3. Generate the manifest with this information
+	if err := g.addEntries(ctx, manifest, srcRepo, dstRepo); err != nil {
+		return nil, err
+	}
+
+	g.logger.WithFields(map[string]interface{}{
+		"source":           sourceRepo,
+		"destination":      destRepo,
+		"entries":          len(manifest.Entries),
+		"total_size":       manifest.TotalSize,
+		"total_delta_size": manifest.TotalDeltaSize,
+	}).Info("Generated delta manifest")
 
-	// Return the manifest
 	return manifest, nil
 }
 
-// createEmptyManifest creates a manifest with no existing destination content
-func (g *DeltaGenerator) createEmptyManifest(srcRepo interfaces.Repository, destRepo string) (*DeltaManifest, error) {
-	manifest := &DeltaManifest{
-		SourceRepo:    srcRepo.GetRepositoryName(),
-		DestRepo:      destRepo,
-		CreatedAt:     time.Now(),
-		FormatVersion: "1.0",
-		Entries:       []ManifestEntry{},
+// addEntries compares every source tag with destRepo and adds an entry for
+// each tag whose manifest differs. destRepo is nil when the destination
+// repository does not exist yet.
+func (g *DeltaGenerator) addEntries(ctx context.Context, manifest *DeltaManifest, srcRepo, destRepo interfaces.Repository) error {
+	srcTags, err := srcRepo.ListTags(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to list source tags")
 	}
+	sort.Strings(srcTags)
 
-	// In a real implementation, we would:
-	// 1. List all tags in srcRepo
-	// 2. Add entries for each tag that needs to be copied (full copy)
+	destTags := make(map[string]bool)
+	if destRepo != nil {
+		tags, err := destRepo.ListTags(ctx)
+		if err != nil {
+			return errors.Wrap(err, "failed to list destination tags")
+		}
+		for _, tag := range tags {
+			destTags[tag] = true
+		}
+	}
 
-	return manifest, nil
+	type changedTag struct {
+		tag  string
+		src  *interfaces.Manifest
+		dest *interfaces.Manifest
+	}
+
+	// First pass: find changed tags and the blobs their destination manifests
+	// already hold, so updates only transfer the layers that differ
+	var changed []changedTag
+	present := make(map[string]bool)
+	for _, tag := range srcTags {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		src, err := srcRepo.GetManifest(ctx, tag)
+		if err != nil {
+			return errors.Wrapf(err, "failed to get source manifest for tag %s", tag)
+		}
+		if src == nil {
+			continue
+		}
+
+		var dest *interfaces.Manifest
+		if destTags[tag] {
+			dest, err = destRepo.GetManifest(ctx, tag)
+			if err != nil && !errors.Is(err, errors.ErrNotFound) {
+				return errors.Wrapf(err, "failed to get destination manifest for tag %s", tag)
+			}
+		}
+
+		if dest != nil {
+			if manifestDigest(dest) == manifestDigest(src) {
+				continue
+			}
+			blobs, _ := manifestBlobs(dest.Content)
+			for _, blob := range blobs {
+				present[blob.Digest] = true
+			}
+		}
+
+		changed = append(changed, changedTag{tag: tag, src: src, dest: dest})
+	}
+
+	// Second pass: a blob shared by several tags is transferred once, by the first
+	for _, c := range changed {
+		entry := ManifestEntry{
+			Path:      c.tag,
+			Size:      int64(len(c.src.Content)),
+			Checksum:  manifestDigest(c.src),
+			Timestamp: manifest.CreatedAt,
+			Metadata: map[string]string{
+				"action":     DeltaActionCopy,
+				"media_type": c.src.MediaType,
+			},
+		}
+		if c.dest != nil {
+			entry.Metadata["action"] = DeltaActionUpdate
+			entry.Metadata["dest_digest"] = manifestDigest(c.dest)
+		}
+
+		blobs, err := manifestBlobs(c.src.Content)
+		if err != nil {
+			g.logger.WithFields(map[string]interface{}{
+				"tag":   c.tag,
+				"error": err.Error(),
+			}).Debug("Cannot read blobs from manifest, planning manifest transfer only")
+		}
+
+		deltaSize := entry.Size
+		reused := 0
+		var offset int64
+		for _, blob := range blobs {
+			entry.Size += blob.Size
+			if present[blob.Digest] {
+				reused++
+				continue
+			}
+			present[blob.Digest] = true
+			entry.Chunks = append(entry.Chunks, ChunkInfo{
+				Offset:   offset,
+				Size:     int(blob.Size),
+				Checksum: blob.Digest,
+			})
+			offset += blob.Size
+			deltaSize += blob.Size
+		}
+		entry.Metadata["blobs_reused"] = strconv.Itoa(reused)
+
+		manifest.Entries = append(manifest.Entries, entry)
+		manifest.TotalSize += entry.Size
+		manifest.TotalDeltaSize += deltaSize
+	}
+
+	return nil
+}
+
+// Delta manifest entry actions, recorded under the "action" metadata key
+const (
+	DeltaActionCopy   = "copy"   // tag is missing at the destination
+	DeltaActionUpdate = "update" // tag exists at the destination with another digest
+)
+
+// blobDescriptor is a digest and size referenced by a manifest
+type blobDescriptor struct {
+	Digest string `json:"digest"`
+	Size   int64  `json:"size"`
+}
+
+// manifestBlobs returns the content a manifest references: config and layers
+// for image manifests, child manifests for indexes
+func manifestBlobs(content []byte) ([]blobDescriptor, error) {
+	var parsed struct {
+		Config    *blobDescriptor  `json:"config"`
+		Layers    []blobDescriptor `json:"layers"`
+		Manifests []blobDescriptor `json:"manifests"`
+	}
+	if err := json.Unmarshal(content, &parsed); err != nil {
+		return nil, errors.Wrap(err, "failed to parse manifest")
+	}
+
+	var blobs []blobDescriptor
+	if parsed.Config != nil && parsed.Config.Digest != "" {
+		blobs = append(blobs, *parsed.Config)
+	}
+	blobs = append(blobs, parsed.Layers...)
+	blobs = append(blobs, parsed.Manifests...)
+	return blobs, nil
+}
+
+// manifestDigest returns the manifest's digest, computing it when the registry did not report one
+func manifestDigest(m *interfaces.Manifest) string {
+	if m.Digest != "" {
+		return m.Digest
+	}
+	digest, _ := CalculateDigest(m.Content)
+	return digest
 }
 
 // Serialize converts a delta manifest to JSON
@@ -826,14 +976,8 @@ func ApplyDelta(delta, source []byte, format string) ([]byte, error) {
 
 	// Verify source digest if available
 	if header.SourceDigest != "" {
-		sourceDigest, err := CalculateDigest(source)
-		if err != nil {
-			return nil, errors.Wrap(err, "failed to calculate source digest")
-		}
-
-		if sourceDigest != header.SourceDigest {
-			return nil, errors.InvalidInputf("source digest mismatch: expected %s, got %s",
-				header.SourceDigest, sourceDigest)
+		if err := VerifyDigest(source, header.SourceDigest); err != nil {
+			return nil, errors.Wrap(err, "source digest mismatch")
 		}
 	}
 
@@ -898,15 +1042,8 @@ func ApplyDelta(delta, source []byte, format string) ([]byte, error) {
 
 		// Verify target digest if available
 		if header.TargetDigest != "" {
-			resultDigest, err := CalculateDigest(result)
-			if err != nil {
-				return nil, errors.Wrap(err, "failed to calculate result digest")
-			}
-
-			if resultDigest != header.TargetDigest {
-				return nil, errors.InvalidInputf(
-					"target digest mismatch after bsdiff: expected %s, got %s",
-					header.TargetDigest, resultDigest)
+			if err := VerifyDigest(result, header.TargetDigest); err != nil {
+				return nil, errors.Wrap(err, "target digest mismatch after bsdiff")
 			}
 		}
 
@@ -1081,22 +1218,21 @@ func ApplyDelta(delta, source []byte, format string) ([]byte, error) {
 	}
 }
 
-// CalculateDigest calculates a digest for the given data
+// CalculateDigest calculates the canonical (sha256) digest of the given data
 func CalculateDigest(data []byte) (string, error) {
 	if len(data) == 0 {
 		return "", errors.InvalidInputf("data cannot be empty")
 	}
 
-	h := sha256.New()
-	_, err := h.Write(data)
+	d, err := util.CalculateDigest(data)
 	if err != nil {
 		return "", errors.Wrap(err, "failed to calculate digest")
 	}
-
-	return fmt.Sprintf("sha256:%x", h.Sum(nil)), nil
+	return d, nil
 }
 
-// VerifyDigest checks if the given data matches the expected digest
+// VerifyDigest checks if the given data matches the expected digest, which
+// may use any supported algorithm (sha256, sha384, sha512)
 func VerifyDigest(data []byte, expectedDigest string) error {
 	if len(data) == 0 {
 		return errors.InvalidInputf("data cannot be empty")
@@ -1105,7 +1241,12 @@ func VerifyDigest(data []byte, expectedDigest string) error {
 		return errors.InvalidInputf("expected digest cannot be empty")
 	}
 
-	actualDigest, err := CalculateDigest(data)
+	expected, err := util.ParseDigest(expectedDigest)
+	if err != nil {
+		return err
+	}
+
+	actualDigest, err := util.CalculateDigestWith(string(expected.Algorithm()), data)
 	if err != nil {
 		return err
 	}
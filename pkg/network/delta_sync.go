@@ -496,16 +496,20 @@ func adler32Hash(data []byte) uint64 {
 
 // VerifyDelta verifies a delta by reconstructing and comparing with source
 func (d *DeltaSync) VerifyDelta(ctx context.Context, base io.ReadSeeker, delta io.Reader, expectedDigest digest.Digest) error {
-	// Create a hash writer
-	h := sha256.New()
+	if err := expectedDigest.Validate(); err != nil {
+		return fmt.Errorf("invalid expected digest: %w", err)
+	}
+
+	// Hash the reconstruction with the expected digest's algorithm
+	digester := expectedDigest.Algorithm().Digester()
 
 	// Apply delta to reconstruction writer
-	if err := d.ApplyDelta(ctx, base, delta, h); err != nil {
+	if err := d.ApplyDelta(ctx, base, delta, digester.Hash()); err != nil {
 		return fmt.Errorf("failed to apply delta: %w", err)
 	}
 
 	// Verify digest
-	actualDigest := digest.NewDigest(digest.SHA256, h)
+	actualDigest := digester.Digest()
 	if actualDigest != expectedDigest {
 		return fmt.Errorf("digest mismatch: expected %s, got %s", expectedDigest, actualDigest)
 	}
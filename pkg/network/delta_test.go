@@ -5,6 +5,7 @@ import (
 	"context"
 	"encoding/binary"
 	"encoding/json"
+	"fmt"
 	"io"
 	"math"
 	"strings"
@@ -391,6 +392,27 @@ func TestDigestValidation(t *testing.T) {
 	}
 }
 
+func TestVerifyDigestAlgorithms(t *testing.T) {
+	data := []byte("hello world")
+
+	if err := VerifyDigest(data, "sha512:309ecc489c12d6eb4cc40f50c902f2b4d0ed77ee511a7c7a9bcd3ca86d4cd86f989dd35bc5ff499670da34255b45b0cfd830e81f605dcf7dc5542e93ae9cd76f"); err != nil {
+		t.Errorf("Expected sha512 digest to verify, got: %v", err)
+	}
+
+	sha256Digest, _ := CalculateDigest(data)
+	if err := VerifyDigest(data, sha256Digest); err != nil {
+		t.Errorf("Expected sha256 digest to verify, got: %v", err)
+	}
+
+	if err := VerifyDigest([]byte("other"), sha256Digest); err == nil {
+		t.Error("Expected digest mismatch for different data")
+	}
+
+	if err := VerifyDigest(data, "sha1:2aae6c35c94fcfb415dbe95f408b9ce91ee846ed"); err == nil {
+		t.Error("Expected an error for an unsupported algorithm")
+	}
+}
+
 func TestDeltaHeader(t *testing.T) {
 	source := []byte("Original content for testing delta headers")
 	target := []byte("Modified content for testing delta headers")
@@ -748,6 +770,135 @@ func TestDeltaManifest(t *testing.T) {
 	}
 }
 
+// deltaRegistryClient serves MockRepositories by name
+type deltaRegistryClient struct {
+	repos map[string]*MockRepository
+}
+
+func (c *deltaRegistryClient) ListRepositories(ctx context.Context, prefix string) ([]string, error) {
+	return nil, nil
+}
+
+func (c *deltaRegistryClient) GetRepository(ctx context.Context, name string) (interfaces.Repository, error) {
+	repo, ok := c.repos[name]
+	if !ok {
+		return nil, errors.NotFoundf("repository %s not found", name)
+	}
+	return repo, nil
+}
+
+func (c *deltaRegistryClient) GetRegistryName() string {
+	return "mock"
+}
+
+// putImage stores an image manifest with the given config and layer sizes under tag
+func putImage(repo *MockRepository, tag, digest string, blobs map[string]int64, layers ...string) {
+	content := fmt.Sprintf(`{"schemaVersion":2,"config":{"digest":"sha256:cfg-%s","size":%d},"layers":[`, digest, blobs["cfg-"+digest])
+	for i, layer := range layers {
+		if i > 0 {
+			content += ","
+		}
+		content += fmt.Sprintf(`{"digest":"sha256:%s","size":%d}`, layer, blobs[layer])
+	}
+	content += "]}"
+
+	repo.Tags[tag] = nil
+	_ = repo.PutManifest(context.Background(), tag, &interfaces.Manifest{
+		Content:   []byte(content),
+		MediaType: "application/vnd.oci.image.manifest.v1+json",
+		Digest:    "sha256:" + digest,
+	})
+}
+
+func TestGenerateManifest(t *testing.T) {
+	blobs := map[string]int64{"base": 1000, "app1": 200, "app2": 300, "old": 50, "cfg-m1": 10, "cfg-m2": 20, "cfg-m3": 30, "cfg-d1": 40}
+
+	source := NewMockRepository()
+	putImage(source, "v1", "m1", blobs, "base", "app1")
+	putImage(source, "v2", "m2", blobs, "base", "app2")
+	putImage(source, "v3", "m3", blobs, "base")
+
+	dest := NewMockRepository()
+	putImage(dest, "v1", "d1", blobs, "base", "old")
+	putImage(dest, "v3", "m3", blobs, "base")
+
+	gen := NewDeltaGenerator(DefaultDeltaOptions(), log.NewBasicLogger(log.ErrorLevel))
+	srcClient := &deltaRegistryClient{repos: map[string]*MockRepository{"team/app": source}}
+	destClient := &deltaRegistryClient{repos: map[string]*MockRepository{"mirror/app": dest}}
+
+	manifest, err := gen.GenerateManifest(context.Background(), srcClient, destClient, "team/app", "mirror/app")
+	if err != nil {
+		t.Fatalf("GenerateManifest failed: %v", err)
+	}
+
+	// v3 is identical at the destination and is left out
+	if len(manifest.Entries) != 2 {
+		t.Fatalf("Expected 2 entries, got %d: %+v", len(manifest.Entries), manifest.Entries)
+	}
+
+	v1 := manifest.Entries[0]
+	if v1.Path != "v1" || v1.Checksum != "sha256:m1" || v1.Metadata["action"] != DeltaActionUpdate {
+		t.Errorf("Unexpected v1 entry: %+v", v1)
+	}
+	if v1.Metadata["dest_digest"] != "sha256:d1" || v1.Metadata["blobs_reused"] != "1" {
+		t.Errorf("Unexpected v1 metadata: %v", v1.Metadata)
+	}
+	if len(v1.Chunks) != 2 || v1.Chunks[0].Checksum != "sha256:cfg-m1" || v1.Chunks[1].Checksum != "sha256:app1" || v1.Chunks[1].Offset != 10 {
+		t.Errorf("Expected v1 to transfer its config and app1 only, got %+v", v1.Chunks)
+	}
+
+	v2 := manifest.Entries[1]
+	if v2.Path != "v2" || v2.Metadata["action"] != DeltaActionCopy {
+		t.Errorf("Unexpected v2 entry: %+v", v2)
+	}
+	if len(v2.Chunks) != 2 || v2.Chunks[1].Checksum != "sha256:app2" {
+		t.Errorf("Expected v2 to reuse the base layer, got %+v", v2.Chunks)
+	}
+
+	var manifestBytes int64
+	for _, entry := range manifest.Entries {
+		manifestBytes += entry.Size
+	}
+	if manifest.TotalSize != manifestBytes {
+		t.Errorf("TotalSize %d does not match entry sizes %d", manifest.TotalSize, manifestBytes)
+	}
+	wantDelta := int64(len(source.manifests["v1"].Content)+len(source.manifests["v2"].Content)) + 10 + 200 + 20 + 300
+	if manifest.TotalDeltaSize != wantDelta {
+		t.Errorf("Expected TotalDeltaSize %d, got %d", wantDelta, manifest.TotalDeltaSize)
+	}
+}
+
+func TestGenerateManifest_MissingDestination(t *testing.T) {
+	blobs := map[string]int64{"base": 1000, "app1": 200, "app2": 300}
+
+	source := NewMockRepository()
+	putImage(source, "v1", "m1", blobs, "base", "app1")
+	putImage(source, "v2", "m2", blobs, "base", "app2")
+
+	gen := NewDeltaGenerator(DefaultDeltaOptions(), log.NewBasicLogger(log.ErrorLevel))
+	srcClient := &deltaRegistryClient{repos: map[string]*MockRepository{"team/app": source}}
+	destClient := &deltaRegistryClient{repos: map[string]*MockRepository{}}
+
+	manifest, err := gen.GenerateManifest(context.Background(), srcClient, destClient, "team/app", "mirror/app")
+	if err != nil {
+		t.Fatalf("GenerateManifest failed: %v", err)
+	}
+
+	if len(manifest.Entries) != 2 {
+		t.Fatalf("Expected 2 entries, got %d", len(manifest.Entries))
+	}
+	for _, entry := range manifest.Entries {
+		if entry.Metadata["action"] != DeltaActionCopy {
+			t.Errorf("Expected %s to be a full copy, got %s", entry.Path, entry.Metadata["action"])
+		}
+	}
+
+	// The shared base layer is planned once
+	if got := len(manifest.Entries[1].Chunks); got != 2 {
+		t.Errorf("Expected v2 to transfer config and app2 only, got %d chunks", got)
+	}
+}
+
 // Helper for tests that need non-zero time values
 func NoZeroTime() time.Time {
 	return time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
@@ -0,0 +1,201 @@
+package network
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"freightliner/pkg/helper/errors"
+
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// HappyEyeballsDelay is how long a dial waits on the first address family
+// before racing the other one (RFC 8305). IPv6-only and IPv4-only hosts are
+// unaffected; dual-stack hosts fall back quickly when one family is broken.
+const HappyEyeballsDelay = 300 * time.Millisecond
+
+// resolveOverrides maps "host:port" to the addresses dialed instead of DNS
+var resolveOverrides atomic.Pointer[map[string][]string]
+
+// NewDialer returns a dual-stack dialer using Happy Eyeballs
+func NewDialer(timeout, keepAlive time.Duration) *net.Dialer {
+	return &net.Dialer{
+		Timeout:       timeout,
+		KeepAlive:     keepAlive,
+		FallbackDelay: HappyEyeballsDelay,
+	}
+}
+
+// SetResolveOverrides pins "host:port" keys to fixed addresses for every dial
+// made through DialContext, like curl's --resolve
+func SetResolveOverrides(overrides map[string][]string) {
+	normalized := make(map[string][]string, len(overrides))
+	for hostPort, addrs := range overrides {
+		normalized[strings.ToLower(hostPort)] = addrs
+	}
+	resolveOverrides.Store(&normalized)
+}
+
+// DialContext wraps dialer so connections to overridden hosts go to their
+// pinned addresses, tried in order. Other hosts resolve through the DNS
+// cache if one is set, and normally otherwise.
+func DialContext(dialer *net.Dialer) func(ctx context.Context, network, address string) (net.Conn, error) {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		addrs := lookupOverride(address)
+		if len(addrs) == 0 {
+			cache := dnsCache.Load()
+			if cache == nil {
+				return dialer.DialContext(ctx, network, address)
+			}
+			return dialCached(ctx, dialer, cache, network, address)
+		}
+
+		_, port, err := net.SplitHostPort(address)
+		if err != nil {
+			return nil, err
+		}
+
+		var lastErr error
+		for _, addr := range addrs {
+			conn, err := dialer.DialContext(ctx, network, net.JoinHostPort(addr, port))
+			if err == nil {
+				return conn, nil
+			}
+			lastErr = err
+		}
+		return nil, errors.Wrapf(lastErr, "failed to dial %s via resolve override", address)
+	}
+}
+
+// ConfigureDefaultTransports installs the dual-stack dialer with resolve
+// overrides on the shared transports used by registry clients. It must run
+// before any requests are made.
+func ConfigureDefaultTransports() {
+	dial := DialContext(NewDialer(30*time.Second, 30*time.Second))
+	for _, rt := range []http.RoundTripper{http.DefaultTransport, remote.DefaultTransport} {
+		if t, ok := rt.(*http.Transport); ok {
+			t.DialContext = dial
+		}
+	}
+}
+
+// lookupOverride returns the pinned addresses for address, if any
+func lookupOverride(address string) []string {
+	overrides := resolveOverrides.Load()
+	if overrides == nil {
+		return nil
+	}
+	return (*overrides)[strings.ToLower(address)]
+}
+
+// dialCached resolves the host of address through cache and races its
+// addresses, alternating families and starting the next attempt once the
+// previous one fails or has not connected within the fallback delay
+func dialCached(ctx context.Context, dialer *net.Dialer, cache *DNSCache, network, address string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	ipAddrs, err := cache.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	addrs := dialOrder(ipAddrs, network)
+	if len(addrs) == 0 {
+		return nil, &net.DNSError{Err: "no suitable address found", Name: host, IsNotFound: true}
+	}
+
+	delay := dialer.FallbackDelay
+	if delay <= 0 {
+		delay = HappyEyeballsDelay
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type dialResult struct {
+		conn net.Conn
+		err  error
+	}
+	results := make(chan dialResult, len(addrs))
+	next, pending := 0, 0
+	start := func() {
+		addr := net.JoinHostPort(addrs[next], port)
+		next++
+		pending++
+		go func() {
+			conn, err := dialer.DialContext(ctx, network, addr)
+			results <- dialResult{conn, err}
+		}()
+	}
+
+	start()
+	var lastErr error
+	for pending > 0 {
+		var fallback <-chan time.Time
+		var timer *time.Timer
+		if next < len(addrs) {
+			timer = time.NewTimer(delay)
+			fallback = timer.C
+		}
+
+		select {
+		case result := <-results:
+			pending--
+			if result.err == nil {
+				// Close the connections of attempts still racing
+				go func(remaining int) {
+					for ; remaining > 0; remaining-- {
+						if late := <-results; late.conn != nil {
+							late.conn.Close()
+						}
+					}
+				}(pending)
+				if timer != nil {
+					timer.Stop()
+				}
+				return result.conn, nil
+			}
+			lastErr = result.err
+			if pending == 0 && next < len(addrs) {
+				start()
+			}
+		case <-fallback:
+			start()
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+	return nil, errors.Wrapf(lastErr, "failed to dial %s via DNS cache", address)
+}
+
+// dialOrder returns the addresses network can dial, alternating IPv6 and
+// IPv4 starting with IPv6 (RFC 8305)
+func dialOrder(ipAddrs []net.IPAddr, network string) []string {
+	var v6, v4 []string
+	for _, ipAddr := range ipAddrs {
+		if ipAddr.IP.To4() != nil {
+			if !strings.HasSuffix(network, "6") {
+				v4 = append(v4, ipAddr.String())
+			}
+		} else if !strings.HasSuffix(network, "4") {
+			v6 = append(v6, ipAddr.String())
+		}
+	}
+
+	addrs := make([]string, 0, len(v6)+len(v4))
+	for i := 0; i < len(v6) || i < len(v4); i++ {
+		if i < len(v6) {
+			addrs = append(addrs, v6[i])
+		}
+		if i < len(v4) {
+			addrs = append(addrs, v4[i])
+		}
+	}
+	return addrs
+}
@@ -0,0 +1,121 @@
+package network
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestDialContextResolveOverride(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+	SetResolveOverrides(map[string][]string{
+		"Registry.Internal:" + port: {"192.0.2.1", "127.0.0.1"},
+	})
+	defer SetResolveOverrides(nil)
+
+	dialer := NewDialer(500*time.Millisecond, 0)
+	if dialer.FallbackDelay != HappyEyeballsDelay {
+		t.Errorf("FallbackDelay = %v, want %v", dialer.FallbackDelay, HappyEyeballsDelay)
+	}
+
+	conn, err := DialContext(dialer)(context.Background(), "tcp", "registry.internal:"+port)
+	if err != nil {
+		t.Fatalf("dial with override: %v", err)
+	}
+	defer conn.Close()
+
+	if got := conn.RemoteAddr().String(); got != listener.Addr().String() {
+		t.Errorf("connected to %s, want %s", got, listener.Addr())
+	}
+}
+
+func TestDialContextWithoutOverride(t *testing.T) {
+	SetResolveOverrides(map[string][]string{"other.internal:443": {"127.0.0.1"}})
+	defer SetResolveOverrides(nil)
+
+	if addrs := lookupOverride("registry.internal:443"); addrs != nil {
+		t.Errorf("lookupOverride() = %v, want nil", addrs)
+	}
+}
+
+func TestDialOrder(t *testing.T) {
+	addrs := []net.IPAddr{
+		{IP: net.ParseIP("192.0.2.1")},
+		{IP: net.ParseIP("192.0.2.2")},
+		{IP: net.ParseIP("2001:db8::1")},
+	}
+
+	got := dialOrder(addrs, "tcp")
+	want := []string{"2001:db8::1", "192.0.2.1", "192.0.2.2"}
+	if len(got) != len(want) {
+		t.Fatalf("dialOrder() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("dialOrder()[%d] = %s, want %s", i, got[i], want[i])
+		}
+	}
+
+	if got := dialOrder(addrs, "tcp4"); len(got) != 2 {
+		t.Errorf("dialOrder(tcp4) = %v, want only IPv4 addresses", got)
+	}
+}
+
+func TestDialContextDNSCache(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	// The first address never answers, so the dial falls back to the second
+	cache := NewDNSCache(DNSCacheOptions{MinTTL: time.Minute})
+	lookup := &fakeLookup{results: []fakeResult{{addrs: []net.IPAddr{
+		{IP: net.ParseIP("192.0.2.1")},
+		{IP: net.ParseIP("127.0.0.1")},
+	}}}}
+	cache.lookup = lookup.lookup
+	SetDNSCache(cache)
+	defer SetDNSCache(nil)
+
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+	dial := DialContext(NewDialer(5*time.Second, 0))
+	for i := 0; i < 2; i++ {
+		conn, err := dial(context.Background(), "tcp", "registry.internal:"+port)
+		if err != nil {
+			t.Fatalf("dial through DNS cache: %v", err)
+		}
+		if got := conn.RemoteAddr().String(); got != listener.Addr().String() {
+			t.Errorf("connected to %s, want %s", got, listener.Addr())
+		}
+		conn.Close()
+	}
+	if lookup.calls != 1 {
+		t.Errorf("resolved %d times, want 1", lookup.calls)
+	}
+}
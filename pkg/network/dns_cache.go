@@ -0,0 +1,355 @@
+package network
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/net/dns/dnsmessage"
+	"golang.org/x/sync/singleflight"
+)
+
+// DefaultDNSRetryBackoff is the wait before the first retry of a lookup that
+// failed temporarily
+const DefaultDNSRetryBackoff = 200 * time.Millisecond
+
+var (
+	dnsResolutionFailures = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "freightliner_dns_resolution_failures_total",
+			Help: "Failed DNS lookups of registry hosts, including those retried",
+		},
+		[]string{"host", "reason"},
+	)
+	dnsCacheLookups = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "freightliner_dns_cache_lookups_total",
+			Help: "DNS cache lookups by result: hit, miss, negative or stale",
+		},
+		[]string{"result"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(dnsResolutionFailures, dnsCacheLookups)
+}
+
+// dnsCache is the cache DialContext resolves hosts through, if any
+var dnsCache atomic.Pointer[DNSCache]
+
+// SetDNSCache routes the host lookups of every dial made through DialContext
+// through cache; nil restores the system resolver
+func SetDNSCache(cache *DNSCache) {
+	dnsCache.Store(cache)
+}
+
+// DNSCacheOptions controls how long lookups are cached and how failures are
+// handled
+type DNSCacheOptions struct {
+	// MinTTL and MaxTTL bound the record TTLs answers are cached for
+	MinTTL time.Duration
+	MaxTTL time.Duration
+
+	// NegativeTTL is how long a host that does not exist is remembered;
+	// zero disables negative caching
+	NegativeTTL time.Duration
+
+	// Retries is how often a lookup that failed temporarily, with SERVFAIL
+	// or a timeout, is retried
+	Retries int
+
+	// RetryBackoff is the wait before the first retry, doubling after each
+	RetryBackoff time.Duration
+
+	// ServeStale is how long past its TTL an answer is still used when the
+	// lookup refreshing it fails; zero disables stale answers
+	ServeStale time.Duration
+}
+
+// dnsLookupFunc resolves host, returning its addresses and the smallest TTL
+// of the answer, or zero when the TTL is unknown
+type dnsLookupFunc func(ctx context.Context, host string) ([]net.IPAddr, time.Duration, error)
+
+// dnsEntry is a cached answer or failure
+type dnsEntry struct {
+	addrs   []net.IPAddr
+	err     error
+	expires time.Time
+}
+
+// DNSCache caches host lookups for the record TTLs of their answers, so
+// long-running processes keep dialing registries while DNS flaps
+type DNSCache struct {
+	opts   DNSCacheOptions
+	lookup dnsLookupFunc
+	now    func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]dnsEntry
+	group   singleflight.Group
+}
+
+// NewDNSCache creates a DNS cache resolving through the pure Go resolver,
+// which reports the TTLs of its answers
+func NewDNSCache(opts DNSCacheOptions) *DNSCache {
+	if opts.MaxTTL < opts.MinTTL {
+		opts.MaxTTL = opts.MinTTL
+	}
+	return &DNSCache{
+		opts:    opts,
+		lookup:  lookupWithTTL((&net.Dialer{}).DialContext),
+		now:     time.Now,
+		entries: make(map[string]dnsEntry),
+	}
+}
+
+// LookupIPAddr returns the addresses of host, from the cache while its
+// answer is fresh
+func (c *DNSCache) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if ip := net.ParseIP(host); ip != nil {
+		return []net.IPAddr{{IP: ip}}, nil
+	}
+
+	c.mu.Lock()
+	entry, ok := c.entries[host]
+	c.mu.Unlock()
+	if ok && c.now().Before(entry.expires) {
+		if entry.err != nil {
+			dnsCacheLookups.WithLabelValues("negative").Inc()
+			return nil, entry.err
+		}
+		dnsCacheLookups.WithLabelValues("hit").Inc()
+		return entry.addrs, nil
+	}
+	dnsCacheLookups.WithLabelValues("miss").Inc()
+
+	// Concurrent dials to one host share a single lookup
+	result, err, _ := c.group.Do(host, func() (interface{}, error) {
+		return c.resolve(ctx, host)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.([]net.IPAddr), nil
+}
+
+// resolve looks host up, retrying temporary failures, and caches the result.
+// When every attempt fails, a recently expired answer is returned instead.
+func (c *DNSCache) resolve(ctx context.Context, host string) ([]net.IPAddr, error) {
+	addrs, ttl, err := c.lookupWithRetries(ctx, host)
+	now := c.now()
+
+	if err == nil {
+		c.store(host, dnsEntry{addrs: addrs, expires: now.Add(c.clampTTL(ttl))})
+		return addrs, nil
+	}
+
+	if isNotFound(err) {
+		if c.opts.NegativeTTL > 0 {
+			c.store(host, dnsEntry{err: err, expires: now.Add(c.opts.NegativeTTL)})
+		}
+		return nil, err
+	}
+
+	c.mu.Lock()
+	stale, ok := c.entries[host]
+	c.mu.Unlock()
+	if ok && stale.err == nil && now.Before(stale.expires.Add(c.opts.ServeStale)) {
+		dnsCacheLookups.WithLabelValues("stale").Inc()
+		return stale.addrs, nil
+	}
+	return nil, err
+}
+
+// lookupWithRetries looks host up, retrying SERVFAIL answers and timeouts
+func (c *DNSCache) lookupWithRetries(ctx context.Context, host string) ([]net.IPAddr, time.Duration, error) {
+	backoff := c.opts.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		addrs, ttl, err := c.lookup(ctx, host)
+		if err == nil {
+			return addrs, ttl, nil
+		}
+		dnsResolutionFailures.WithLabelValues(host, failureReason(err)).Inc()
+
+		if attempt >= c.opts.Retries || !isTemporary(err) {
+			return nil, 0, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, 0, err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// store caches entry for host
+func (c *DNSCache) store(host string, entry dnsEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[host] = entry
+}
+
+// clampTTL bounds a record TTL by the configured minimum and maximum. An
+// unknown TTL is cached for the minimum.
+func (c *DNSCache) clampTTL(ttl time.Duration) time.Duration {
+	if ttl < c.opts.MinTTL {
+		return c.opts.MinTTL
+	}
+	if c.opts.MaxTTL > 0 && ttl > c.opts.MaxTTL {
+		return c.opts.MaxTTL
+	}
+	return ttl
+}
+
+// isNotFound reports whether err means the host does not exist
+func isNotFound(err error) bool {
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr) && dnsErr.IsNotFound
+}
+
+// isTemporary reports whether err is a SERVFAIL answer or timeout that a
+// retry may not repeat
+func isTemporary(err error) bool {
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr) && (dnsErr.IsTemporary || dnsErr.IsTimeout)
+}
+
+// failureReason labels err for the resolution failure metric
+func failureReason(err error) string {
+	var dnsErr *net.DNSError
+	switch {
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case !errors.As(err, &dnsErr):
+		return "error"
+	case dnsErr.IsNotFound:
+		return "not_found"
+	case dnsErr.IsTimeout:
+		return "timeout"
+	case dnsErr.IsTemporary:
+		return "servfail"
+	default:
+		return "error"
+	}
+}
+
+// ttlRecorder collects the smallest TTL of the answers a lookup receives
+type ttlRecorder struct {
+	mu  sync.Mutex
+	ttl time.Duration
+}
+
+// observe records the answer TTLs of the DNS message msg
+func (r *ttlRecorder) observe(msg []byte) {
+	var parser dnsmessage.Parser
+	if _, err := parser.Start(msg); err != nil {
+		return
+	}
+	if err := parser.SkipAllQuestions(); err != nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for {
+		header, err := parser.AnswerHeader()
+		if err != nil {
+			return
+		}
+		ttl := time.Duration(header.TTL) * time.Second
+		if r.ttl == 0 || ttl < r.ttl {
+			r.ttl = ttl
+		}
+		if err := parser.SkipAnswer(); err != nil {
+			return
+		}
+	}
+}
+
+type ttlRecorderKey struct{}
+
+// lookupWithTTL returns a lookup that resolves through the pure Go resolver,
+// reaching name servers with dial, and reads record TTLs off its answers
+func lookupWithTTL(dial func(ctx context.Context, network, address string) (net.Conn, error)) dnsLookupFunc {
+	resolver := &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			conn, err := dial(ctx, network, address)
+			if err != nil {
+				return nil, err
+			}
+			recorder, ok := ctx.Value(ttlRecorderKey{}).(*ttlRecorder)
+			if !ok {
+				return conn, nil
+			}
+			// The resolver frames messages by whether the connection is a
+			// net.PacketConn, so UDP connections must stay one
+			switch c := conn.(type) {
+			case *net.UDPConn:
+				return &ttlPacketConn{UDPConn: c, recorder: recorder}, nil
+			case *net.TCPConn:
+				return &ttlStreamConn{Conn: c, recorder: recorder}, nil
+			default:
+				return conn, nil
+			}
+		},
+	}
+
+	return func(ctx context.Context, host string) ([]net.IPAddr, time.Duration, error) {
+		recorder := &ttlRecorder{}
+		addrs, err := resolver.LookupIPAddr(context.WithValue(ctx, ttlRecorderKey{}, recorder), host)
+		if err != nil {
+			return nil, 0, err
+		}
+		recorder.mu.Lock()
+		defer recorder.mu.Unlock()
+		return addrs, recorder.ttl, nil
+	}
+}
+
+// ttlPacketConn passes the DNS messages read from a UDP name server
+// connection to a ttlRecorder
+type ttlPacketConn struct {
+	*net.UDPConn
+	recorder *ttlRecorder
+}
+
+// Read implements net.Conn
+func (c *ttlPacketConn) Read(p []byte) (int, error) {
+	n, err := c.UDPConn.Read(p)
+	if n > 0 {
+		c.recorder.observe(p[:n])
+	}
+	return n, err
+}
+
+// ttlStreamConn passes the DNS messages read from a TCP name server
+// connection, each with a two-byte length prefix, to a ttlRecorder
+type ttlStreamConn struct {
+	net.Conn
+	recorder *ttlRecorder
+	buf      []byte
+}
+
+// Read implements net.Conn
+func (c *ttlStreamConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	c.buf = append(c.buf, p[:n]...)
+	for len(c.buf) >= 2 {
+		size := int(binary.BigEndian.Uint16(c.buf))
+		if len(c.buf) < 2+size {
+			break
+		}
+		c.recorder.observe(c.buf[2 : 2+size])
+		c.buf = c.buf[2+size:]
+	}
+	return n, err
+}
@@ -0,0 +1,190 @@
+package network
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// fakeLookup answers lookups from a script of results and counts the calls
+type fakeLookup struct {
+	calls   int
+	results []fakeResult
+}
+
+type fakeResult struct {
+	addrs []net.IPAddr
+	ttl   time.Duration
+	err   error
+}
+
+func (f *fakeLookup) lookup(ctx context.Context, host string) ([]net.IPAddr, time.Duration, error) {
+	result := f.results[len(f.results)-1]
+	if f.calls < len(f.results) {
+		result = f.results[f.calls]
+	}
+	f.calls++
+	return result.addrs, result.ttl, result.err
+}
+
+func newTestDNSCache(opts DNSCacheOptions, lookup *fakeLookup, now *time.Time) *DNSCache {
+	cache := NewDNSCache(opts)
+	cache.lookup = lookup.lookup
+	cache.now = func() time.Time { return *now }
+	return cache
+}
+
+func TestDNSCacheRespectsTTL(t *testing.T) {
+	addrs := []net.IPAddr{{IP: net.ParseIP("192.0.2.10")}}
+	lookup := &fakeLookup{results: []fakeResult{{addrs: addrs, ttl: time.Minute}}}
+	now := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	cache := newTestDNSCache(DNSCacheOptions{MinTTL: time.Second, MaxTTL: time.Hour}, lookup, &now)
+
+	for i := 0; i < 3; i++ {
+		got, err := cache.LookupIPAddr(context.Background(), "Registry.Example.com.")
+		if err != nil {
+			t.Fatalf("lookup: %v", err)
+		}
+		if len(got) != 1 || !got[0].IP.Equal(addrs[0].IP) {
+			t.Errorf("lookup = %v, want %v", got, addrs)
+		}
+	}
+	if lookup.calls != 1 {
+		t.Errorf("resolved %d times within the TTL, want 1", lookup.calls)
+	}
+
+	now = now.Add(time.Minute + time.Second)
+	if _, err := cache.LookupIPAddr(context.Background(), "registry.example.com"); err != nil {
+		t.Fatalf("lookup: %v", err)
+	}
+	if lookup.calls != 2 {
+		t.Errorf("resolved %d times after the TTL expired, want 2", lookup.calls)
+	}
+
+	if got, err := cache.LookupIPAddr(context.Background(), "10.0.0.5"); err != nil || !got[0].IP.Equal(net.ParseIP("10.0.0.5")) {
+		t.Errorf("lookup of an address = %v, %v", got, err)
+	}
+}
+
+func TestDNSCacheFailures(t *testing.T) {
+	addrs := []net.IPAddr{{IP: net.ParseIP("192.0.2.10")}}
+	notFound := &net.DNSError{Err: "no such host", Name: "gone.example.com", IsNotFound: true}
+	servfail := &net.DNSError{Err: "server misbehaving", Name: "registry.example.com", IsTemporary: true}
+	now := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	opts := DNSCacheOptions{MinTTL: time.Minute, NegativeTTL: 10 * time.Second, Retries: 2, ServeStale: time.Hour}
+
+	t.Run("negative caching", func(t *testing.T) {
+		lookup := &fakeLookup{results: []fakeResult{{err: notFound}}}
+		cache := newTestDNSCache(opts, lookup, &now)
+		for i := 0; i < 2; i++ {
+			if _, err := cache.LookupIPAddr(context.Background(), "gone.example.com"); err == nil {
+				t.Fatal("expected lookup of a missing host to fail")
+			}
+		}
+		if lookup.calls != 1 {
+			t.Errorf("resolved %d times, want 1 with negative caching", lookup.calls)
+		}
+
+		noNegative := opts
+		noNegative.NegativeTTL = 0
+		lookup = &fakeLookup{results: []fakeResult{{err: notFound}}}
+		cache = newTestDNSCache(noNegative, lookup, &now)
+		_, _ = cache.LookupIPAddr(context.Background(), "gone.example.com")
+		_, _ = cache.LookupIPAddr(context.Background(), "gone.example.com")
+		if lookup.calls != 2 {
+			t.Errorf("resolved %d times, want 2 without negative caching", lookup.calls)
+		}
+	})
+
+	t.Run("retry on SERVFAIL", func(t *testing.T) {
+		lookup := &fakeLookup{results: []fakeResult{{err: servfail}, {err: servfail}, {addrs: addrs}}}
+		cache := newTestDNSCache(opts, lookup, &now)
+		got, err := cache.LookupIPAddr(context.Background(), "registry.example.com")
+		if err != nil {
+			t.Fatalf("lookup: %v", err)
+		}
+		if len(got) != 1 || lookup.calls != 3 {
+			t.Errorf("lookup = %v after %d calls, want an answer after 3", got, lookup.calls)
+		}
+	})
+
+	t.Run("serve stale", func(t *testing.T) {
+		lookup := &fakeLookup{results: []fakeResult{{addrs: addrs}, {err: servfail}}}
+		start := now
+		cache := newTestDNSCache(opts, lookup, &start)
+		if _, err := cache.LookupIPAddr(context.Background(), "registry.example.com"); err != nil {
+			t.Fatalf("lookup: %v", err)
+		}
+
+		start = start.Add(10 * time.Minute)
+		got, err := cache.LookupIPAddr(context.Background(), "registry.example.com")
+		if err != nil || len(got) != 1 {
+			t.Errorf("stale lookup = %v, %v, want the expired answer", got, err)
+		}
+
+		start = start.Add(2 * time.Hour)
+		if _, err := cache.LookupIPAddr(context.Background(), "registry.example.com"); err == nil {
+			t.Error("expected lookup to fail once the answer is too stale")
+		}
+	})
+}
+
+func TestLookupWithTTL(t *testing.T) {
+	server, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer server.Close()
+	go serveFakeDNS(server, net.IPv4(192, 0, 2, 10), 120)
+
+	lookup := lookupWithTTL(func(ctx context.Context, network, address string) (net.Conn, error) {
+		var d net.Dialer
+		return d.DialContext(ctx, "udp", server.LocalAddr().String())
+	})
+
+	addrs, ttl, err := lookup(context.Background(), "registry.example.com")
+	if err != nil {
+		t.Fatalf("lookup: %v", err)
+	}
+	if len(addrs) != 1 || !addrs[0].IP.Equal(net.IPv4(192, 0, 2, 10)) {
+		t.Errorf("addrs = %v, want 192.0.2.10", addrs)
+	}
+	if ttl != 120*time.Second {
+		t.Errorf("ttl = %v, want 2m0s", ttl)
+	}
+}
+
+// serveFakeDNS answers A queries with ip and other queries with no records
+func serveFakeDNS(conn net.PacketConn, ip net.IP, ttl uint32) {
+	buf := make([]byte, 512)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+
+		var query dnsmessage.Message
+		if err := query.Unpack(buf[:n]); err != nil || len(query.Questions) == 0 {
+			continue
+		}
+		question := query.Questions[0]
+
+		builder := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: query.ID, Response: true, Authoritative: true})
+		_ = builder.StartQuestions()
+		_ = builder.Question(question)
+		_ = builder.StartAnswers()
+		if question.Type == dnsmessage.TypeA {
+			var a dnsmessage.AResource
+			copy(a.A[:], ip.To4())
+			_ = builder.AResource(dnsmessage.ResourceHeader{Name: question.Name, Class: dnsmessage.ClassINET, TTL: ttl}, a)
+		}
+		msg, err := builder.Finish()
+		if err != nil {
+			continue
+		}
+		_, _ = conn.WriteTo(msg, addr)
+	}
+}
@@ -0,0 +1,272 @@
+package network
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"freightliner/pkg/helper/errors"
+)
+
+// CapabilitiesVersion is the version of the capability exchange format
+const CapabilitiesVersion = "1"
+
+// Probe sizes for link speed measurement
+const (
+	DefaultProbeBytes = 4 * 1024 * 1024
+	MaxProbeBytes     = 16 * 1024 * 1024
+)
+
+// Capabilities describes what one Freightliner endpoint can encode and how
+// fast its CPUs compress right now. Peers exchange them to agree on transfer
+// settings instead of relying on static DeltaOptions.
+type Capabilities struct {
+	Version      string            `json:"version"`
+	Compression  []CompressionType `json:"compression"`
+	DeltaFormats []string          `json:"delta_formats"`
+
+	// CPUs is the number of CPUs available to the process
+	CPUs int `json:"cpus"`
+
+	// CompressMBps is the measured single-stream zstd throughput at BestSpeed
+	CompressMBps float64 `json:"compress_mbps"`
+}
+
+// NegotiatedSettings are the transfer settings both peers agreed on
+type NegotiatedSettings struct {
+	Compression      CompressionType  `json:"compression"`
+	CompressionLevel CompressionLevel `json:"compression_level"`
+	DeltaFormat      string           `json:"delta_format"`
+
+	// LinkMbps is the measured link speed, 0 when unknown
+	LinkMbps float64 `json:"link_mbps"`
+}
+
+var (
+	throughputOnce sync.Once
+	throughputMBps float64
+)
+
+// LocalCapabilities returns this process's capabilities. Compression
+// throughput is measured on first use, so it reflects CPU contention at the
+// time the process started negotiating.
+func LocalCapabilities() Capabilities {
+	throughputOnce.Do(func() {
+		throughputMBps = measureCompressThroughput()
+	})
+
+	return Capabilities{
+		Version:      CapabilitiesVersion,
+		Compression:  []CompressionType{ZstdCompression, GzipCompression, ZlibCompression, NoCompression},
+		DeltaFormats: []string{BSDiffFormat, ChunkBasedFormat, SimpleDeltaFormat, NoDeltaFormat},
+		CPUs:         runtime.GOMAXPROCS(0),
+		CompressMBps: throughputMBps,
+	}
+}
+
+// measureCompressThroughput compresses a layer-like sample and returns MB/s
+func measureCompressThroughput() float64 {
+	const block = 4096
+	sample := make([]byte, 4*1024*1024)
+	text := []byte(strings.Repeat("freightliner ", block/13+1))
+
+	// Mix repetitive and random blocks so the sample compresses like a typical layer
+	rng := rand.New(rand.NewSource(1)) // #nosec G404 - benchmark data, not security sensitive
+	for i := 0; i < len(sample); i += block {
+		if (i/block)%2 == 0 {
+			_, _ = rng.Read(sample[i : i+block])
+		} else {
+			copy(sample[i:i+block], text)
+		}
+	}
+
+	start := time.Now()
+	w, err := NewCompressingWriter(io.Discard, CompressorOptions{Type: ZstdCompression, Level: BestSpeed})
+	if err != nil {
+		return 0
+	}
+	_, _ = w.Write(sample)
+	_ = w.Close()
+
+	elapsed := time.Since(start).Seconds()
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(len(sample)) / elapsed / 1e6
+}
+
+// Negotiate picks compression and delta settings both peers support. Heavier
+// compression is chosen only while the slower CPU can still keep the link
+// busy; when it cannot, compression is skipped so it does not become the
+// bottleneck. linkMbps of 0 means the link speed is unknown.
+func Negotiate(local, peer Capabilities, linkMbps float64) NegotiatedSettings {
+	settings := NegotiatedSettings{
+		Compression:      NoCompression,
+		CompressionLevel: DefaultCompression,
+		DeltaFormat:      ChunkBasedFormat,
+		LinkMbps:         linkMbps,
+	}
+
+	compression := firstCommon(local.Compression, peer.Compression)
+	deltaFormats := commonSet(local.DeltaFormats, peer.DeltaFormats)
+
+	cpuMBps := local.CompressMBps
+	if peer.CompressMBps > 0 && (cpuMBps <= 0 || peer.CompressMBps < cpuMBps) {
+		cpuMBps = peer.CompressMBps
+	}
+	linkMBps := linkMbps / 8
+
+	// Headroom is how many times faster the CPU compresses than the link carries.
+	// Each step up in level costs roughly half the throughput.
+	headroom := 2.0 // unknown link or CPU speed: use the default level
+	if linkMBps > 0 && cpuMBps > 0 {
+		headroom = cpuMBps / linkMBps
+	}
+
+	if compression != NoCompression && compression != "" {
+		switch {
+		case headroom >= 4:
+			settings.Compression, settings.CompressionLevel = compression, BestCompression
+		case headroom >= 2:
+			settings.Compression, settings.CompressionLevel = compression, DefaultCompression
+		case headroom >= 1:
+			settings.Compression, settings.CompressionLevel = compression, BestSpeed
+		}
+	}
+
+	// bsdiff finds the smallest deltas but is CPU heavy; only worth it on slow links
+	switch {
+	case headroom >= 4 && deltaFormats[BSDiffFormat]:
+		settings.DeltaFormat = BSDiffFormat
+	case deltaFormats[ChunkBasedFormat]:
+		settings.DeltaFormat = ChunkBasedFormat
+	case deltaFormats[SimpleDeltaFormat]:
+		settings.DeltaFormat = SimpleDeltaFormat
+	default:
+		settings.DeltaFormat = NoDeltaFormat
+	}
+
+	return settings
+}
+
+// Apply returns opts with the negotiated compression and delta format
+func (s NegotiatedSettings) Apply(opts TransferOptions) TransferOptions {
+	opts.EnableCompression = s.Compression != NoCompression && s.Compression != ""
+	if opts.EnableCompression {
+		opts.CompressionType = s.Compression
+		opts.CompressionLevel = s.CompressionLevel
+	}
+	opts.EnableDelta = s.DeltaFormat != NoDeltaFormat && s.DeltaFormat != ""
+	if opts.EnableDelta {
+		opts.DeltaOptions.DeltaFormat = s.DeltaFormat
+	}
+	return opts
+}
+
+// NegotiateWithPeer fetches the capabilities of the Freightliner server at
+// baseURL, measures the link with a probe download and negotiates settings.
+// apiKey is sent as X-API-Key when set.
+func NegotiateWithPeer(ctx context.Context, client *http.Client, baseURL, apiKey string) (NegotiatedSettings, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	baseURL = strings.TrimSuffix(baseURL, "/")
+
+	var peer Capabilities
+	resp, err := peerGet(ctx, client, baseURL+"/api/v1/capabilities", apiKey)
+	if err != nil {
+		return NegotiatedSettings{}, err
+	}
+	err = json.NewDecoder(resp.Body).Decode(&peer)
+	_ = resp.Body.Close()
+	if err != nil {
+		return NegotiatedSettings{}, errors.Wrap(err, "failed to decode peer capabilities")
+	}
+
+	linkMbps, err := MeasureLinkSpeed(ctx, client, fmt.Sprintf("%s/api/v1/capabilities/probe?bytes=%d", baseURL, DefaultProbeBytes), apiKey)
+	if err != nil {
+		return NegotiatedSettings{}, err
+	}
+
+	return Negotiate(LocalCapabilities(), peer, linkMbps), nil
+}
+
+// MeasureLinkSpeed downloads probeURL and returns the throughput in megabits per second
+func MeasureLinkSpeed(ctx context.Context, client *http.Client, probeURL, apiKey string) (float64, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	start := time.Now()
+	resp, err := peerGet(ctx, client, probeURL, apiKey)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	n, err := io.Copy(io.Discard, resp.Body)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to read link probe")
+	}
+
+	elapsed := time.Since(start).Seconds()
+	if n == 0 || elapsed <= 0 {
+		return 0, nil
+	}
+	return float64(n) * 8 / elapsed / 1e6, nil
+}
+
+// peerGet issues an authenticated GET and fails on non-200 responses
+func peerGet(ctx context.Context, client *http.Client, url, apiKey string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create peer request")
+	}
+	if apiKey != "" {
+		req.Header.Set("X-API-Key", apiKey)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to reach peer %s", url)
+	}
+	if resp.StatusCode != http.StatusOK {
+		_ = resp.Body.Close()
+		return nil, errors.Newf("peer %s returned %s", url, resp.Status)
+	}
+	return resp, nil
+}
+
+// firstCommon returns the first entry of preferred that others also lists
+func firstCommon(preferred, others []CompressionType) CompressionType {
+	for _, p := range preferred {
+		for _, o := range others {
+			if p == o {
+				return p
+			}
+		}
+	}
+	return NoCompression
+}
+
+// commonSet returns the entries present in both a and b
+func commonSet(a, b []string) map[string]bool {
+	inB := make(map[string]bool, len(b))
+	for _, v := range b {
+		inB[v] = true
+	}
+	common := make(map[string]bool)
+	for _, v := range a {
+		if inB[v] {
+			common[v] = true
+		}
+	}
+	return common
+}
@@ -0,0 +1,106 @@
+package network
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNegotiate(t *testing.T) {
+	all := Capabilities{
+		Compression:  []CompressionType{ZstdCompression, GzipCompression, NoCompression},
+		DeltaFormats: []string{BSDiffFormat, ChunkBasedFormat, NoDeltaFormat},
+		CompressMBps: 400,
+	}
+	gzipOnly := Capabilities{
+		Compression:  []CompressionType{GzipCompression, NoCompression},
+		DeltaFormats: []string{ChunkBasedFormat},
+		CompressMBps: 400,
+	}
+	slowCPU := all
+	slowCPU.CompressMBps = 50
+
+	tests := []struct {
+		name        string
+		peer        Capabilities
+		linkMbps    float64
+		compression CompressionType
+		level       CompressionLevel
+		deltaFormat string
+	}{
+		{name: "slow link", peer: all, linkMbps: 100, compression: ZstdCompression, level: BestCompression, deltaFormat: BSDiffFormat},
+		{name: "gigabit link", peer: all, linkMbps: 1000, compression: ZstdCompression, level: DefaultCompression, deltaFormat: ChunkBasedFormat},
+		{name: "multi-gigabit link", peer: all, linkMbps: 2500, compression: ZstdCompression, level: BestSpeed, deltaFormat: ChunkBasedFormat},
+		{name: "link faster than CPU", peer: all, linkMbps: 10000, compression: NoCompression, level: DefaultCompression, deltaFormat: ChunkBasedFormat},
+		{name: "slow peer CPU", peer: slowCPU, linkMbps: 1000, compression: NoCompression, level: DefaultCompression, deltaFormat: ChunkBasedFormat},
+		{name: "unknown link", peer: all, linkMbps: 0, compression: ZstdCompression, level: DefaultCompression, deltaFormat: ChunkBasedFormat},
+		{name: "peer without zstd", peer: gzipOnly, linkMbps: 100, compression: GzipCompression, level: BestCompression, deltaFormat: ChunkBasedFormat},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Negotiate(all, tt.peer, tt.linkMbps)
+			if got.Compression != tt.compression || got.CompressionLevel != tt.level || got.DeltaFormat != tt.deltaFormat {
+				t.Errorf("Negotiate() = %+v, want %s level %d delta %s", got, tt.compression, tt.level, tt.deltaFormat)
+			}
+		})
+	}
+}
+
+func TestNegotiatedSettingsApply(t *testing.T) {
+	opts := NegotiatedSettings{Compression: ZstdCompression, CompressionLevel: BestSpeed, DeltaFormat: ChunkBasedFormat}.Apply(DefaultTransferOptions())
+	if !opts.EnableCompression || opts.CompressionType != ZstdCompression || opts.CompressionLevel != BestSpeed {
+		t.Errorf("Apply() compression = %v %s %d", opts.EnableCompression, opts.CompressionType, opts.CompressionLevel)
+	}
+	if !opts.EnableDelta || opts.DeltaOptions.DeltaFormat != ChunkBasedFormat {
+		t.Errorf("Apply() delta = %v %s", opts.EnableDelta, opts.DeltaOptions.DeltaFormat)
+	}
+
+	opts = NegotiatedSettings{Compression: NoCompression, DeltaFormat: NoDeltaFormat}.Apply(DefaultTransferOptions())
+	if opts.EnableCompression || opts.EnableDelta {
+		t.Errorf("Apply() should disable compression and delta, got %+v", opts)
+	}
+}
+
+func TestNegotiateWithPeer(t *testing.T) {
+	peer := Capabilities{
+		Version:      CapabilitiesVersion,
+		Compression:  []CompressionType{GzipCompression, NoCompression},
+		DeltaFormats: []string{ChunkBasedFormat, NoDeltaFormat},
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-API-Key") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/api/v1/capabilities":
+			_ = json.NewEncoder(w).Encode(peer)
+		case "/api/v1/capabilities/probe":
+			_, _ = io.Copy(w, strings.NewReader(strings.Repeat("x", 64*1024)))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	settings, err := NegotiateWithPeer(context.Background(), server.Client(), server.URL+"/", "secret")
+	if err != nil {
+		t.Fatalf("NegotiateWithPeer() error = %v", err)
+	}
+	if settings.LinkMbps <= 0 {
+		t.Errorf("Expected a measured link speed, got %f", settings.LinkMbps)
+	}
+	if settings.Compression == ZstdCompression || settings.DeltaFormat == BSDiffFormat {
+		t.Errorf("Negotiated a format the peer does not support: %+v", settings)
+	}
+
+	if _, err := NegotiateWithPeer(context.Background(), server.Client(), server.URL, "wrong"); err == nil {
+		t.Error("Expected an error when the peer rejects the API key")
+	}
+}
@@ -0,0 +1,239 @@
+// Package policy evaluates Rego policies that decide whether each image is
+// copied, skipped or quarantined during replication.
+package policy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os/exec"
+	"strings"
+	"time"
+
+	"freightliner/pkg/helper/errors"
+	"freightliner/pkg/interfaces"
+)
+
+// Actions a policy can decide on
+const (
+	ActionCopy       = "copy"
+	ActionSkip       = "skip"
+	ActionQuarantine = "quarantine"
+)
+
+// DefaultQuery is the Rego rule evaluated when no query is configured
+const DefaultQuery = "data.freightliner.replication.decision"
+
+// Input is the document a policy sees for each image as input
+type Input struct {
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+	Registry    string `json:"registry"`
+	Repository  string `json:"repository"`
+	Tag         string `json:"tag"`
+	Digest      string `json:"digest,omitempty"`
+
+	// Labels are the image config labels of the default platform image
+	Labels map[string]string `json:"labels"`
+
+	// Platforms lists os/arch[/variant] of every image in an index, or of the
+	// single image otherwise
+	Platforms []string `json:"platforms"`
+
+	// Scan is the source registry's scan summary, when it has one
+	Scan *interfaces.ScanFindingsSummary `json:"scan,omitempty"`
+
+	Signature SignatureStatus `json:"signature"`
+}
+
+// SignatureStatus describes the image's cosign signatures
+type SignatureStatus struct {
+	// Signed is true when a cosign signature is stored for the digest
+	Signed bool `json:"signed"`
+
+	// Verified is true when the rule's sign_verification passed
+	Verified bool `json:"verified"`
+}
+
+// Decision is a policy's verdict for one image
+type Decision struct {
+	Action string `json:"action"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// Evaluator decides what to do with an image
+type Evaluator interface {
+	Evaluate(ctx context.Context, input Input) (Decision, error)
+}
+
+// Options selects how policies are evaluated
+type Options struct {
+	// URL is an OPA server data API endpoint, e.g.
+	// http://opa:8181/v1/data/freightliner/replication/decision
+	URL string
+
+	// Files are Rego policy and data files evaluated locally with the opa CLI
+	Files []string
+
+	// Query is the rule evaluated locally (default DefaultQuery)
+	Query string
+
+	// Binary is the opa CLI used for local evaluation (default "opa")
+	Binary string
+
+	// HTTPClient is used to reach URL (default: a client with a 30s timeout)
+	HTTPClient *http.Client
+}
+
+// NewEvaluator returns an evaluator that queries an OPA server when URL is
+// set, or evaluates Files with the opa CLI otherwise
+func NewEvaluator(opts Options) (Evaluator, error) {
+	switch {
+	case opts.URL != "" && len(opts.Files) > 0:
+		return nil, errors.InvalidInputf("policy url and files are mutually exclusive")
+	case opts.URL != "":
+		client := opts.HTTPClient
+		if client == nil {
+			client = &http.Client{Timeout: 30 * time.Second}
+		}
+		return &serverEvaluator{url: opts.URL, client: client}, nil
+	case len(opts.Files) > 0:
+		query := opts.Query
+		if query == "" {
+			query = DefaultQuery
+		}
+		binary := opts.Binary
+		if binary == "" {
+			binary = "opa"
+		}
+		if _, err := exec.LookPath(binary); err != nil {
+			return nil, errors.Wrapf(err, "policy files need the opa CLI (%s)", binary)
+		}
+		return &cliEvaluator{binary: binary, files: opts.Files, query: query}, nil
+	default:
+		return nil, errors.InvalidInputf("policy needs a url or files")
+	}
+}
+
+// serverEvaluator queries the OPA data API
+type serverEvaluator struct {
+	url    string
+	client *http.Client
+}
+
+func (e *serverEvaluator) Evaluate(ctx context.Context, input Input) (Decision, error) {
+	body, err := json.Marshal(map[string]interface{}{"input": input})
+	if err != nil {
+		return Decision{}, errors.Wrap(err, "failed to encode policy input")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return Decision{}, errors.Wrap(err, "failed to create policy request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return Decision{}, errors.Wrap(err, "failed to query policy server")
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return Decision{}, errors.Wrap(err, "failed to read policy response")
+	}
+	if resp.StatusCode != http.StatusOK {
+		return Decision{}, errors.Unavailablef("policy server returned %s: %s", resp.Status, strings.TrimSpace(string(data)))
+	}
+
+	var result struct {
+		Result json.RawMessage `json:"result"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return Decision{}, errors.Wrap(err, "failed to parse policy response")
+	}
+	return parseDecision(result.Result)
+}
+
+// cliEvaluator evaluates local policy files with `opa eval`
+type cliEvaluator struct {
+	binary string
+	files  []string
+	query  string
+}
+
+func (e *cliEvaluator) Evaluate(ctx context.Context, input Input) (Decision, error) {
+	data, err := json.Marshal(input)
+	if err != nil {
+		return Decision{}, errors.Wrap(err, "failed to encode policy input")
+	}
+
+	args := []string{"eval", "--format", "json", "--stdin-input"}
+	for _, file := range e.files {
+		args = append(args, "--data", file)
+	}
+	args = append(args, e.query)
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, e.binary, args...) // #nosec G204 - policy files and query come from the operator
+	cmd.Stdin = bytes.NewReader(data)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return Decision{}, errors.Wrapf(err, "opa eval failed: %s", strings.TrimSpace(stderr.String()))
+	}
+
+	return parseEvalOutput(stdout.Bytes())
+}
+
+// parseEvalOutput extracts the decision from `opa eval --format json` output
+func parseEvalOutput(data []byte) (Decision, error) {
+	var out struct {
+		Result []struct {
+			Expressions []struct {
+				Value json.RawMessage `json:"value"`
+			} `json:"expressions"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return Decision{}, errors.Wrap(err, "failed to parse opa eval output")
+	}
+	if len(out.Result) == 0 || len(out.Result[0].Expressions) == 0 {
+		return Decision{}, errors.NotFoundf("policy query is undefined for this image")
+	}
+	return parseDecision(out.Result[0].Expressions[0].Value)
+}
+
+// parseDecision accepts a bare action string or an {action, reason} object
+func parseDecision(raw json.RawMessage) (Decision, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return Decision{}, errors.NotFoundf("policy query is undefined for this image")
+	}
+
+	var decision Decision
+	var action string
+	if err := json.Unmarshal(raw, &action); err == nil {
+		decision.Action = action
+	} else if err := json.Unmarshal(raw, &decision); err != nil {
+		return Decision{}, errors.InvalidInputf("policy decision must be an action or {action, reason}, got %s", string(raw))
+	}
+
+	switch decision.Action {
+	case ActionCopy, ActionSkip, ActionQuarantine:
+		return decision, nil
+	default:
+		return Decision{}, errors.InvalidInputf("unknown policy action %q", decision.Action)
+	}
+}
+
+// String formats the decision for logs and skip reasons
+func (d Decision) String() string {
+	if d.Reason == "" {
+		return d.Action
+	}
+	return fmt.Sprintf("%s: %s", d.Action, d.Reason)
+}
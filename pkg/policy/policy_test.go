@@ -0,0 +1,136 @@
+package policy
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDecision(t *testing.T) {
+	tests := []struct {
+		raw       string
+		want      Decision
+		wantError bool
+	}{
+		{raw: `"copy"`, want: Decision{Action: ActionCopy}},
+		{raw: `{"action":"quarantine","reason":"critical findings"}`, want: Decision{Action: ActionQuarantine, Reason: "critical findings"}},
+		{raw: `{"action":"skip"}`, want: Decision{Action: ActionSkip}},
+		{raw: `"delete"`, wantError: true},
+		{raw: `42`, wantError: true},
+		{raw: `null`, wantError: true},
+		{raw: ``, wantError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			got, err := parseDecision(json.RawMessage(tt.raw))
+			if tt.wantError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParseEvalOutput(t *testing.T) {
+	decision, err := parseEvalOutput([]byte(`{"result":[{"expressions":[{"value":{"action":"skip","reason":"arm only"},"text":"data.x","location":{"row":1,"col":1}}]}]}`))
+	require.NoError(t, err)
+	assert.Equal(t, Decision{Action: ActionSkip, Reason: "arm only"}, decision)
+
+	// An undefined query yields no result
+	_, err = parseEvalOutput([]byte(`{}`))
+	assert.Error(t, err)
+}
+
+func TestServerEvaluator(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+
+		var body struct {
+			Input Input `json:"input"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+
+		if body.Input.Labels["team"] == "" {
+			_, _ = w.Write([]byte(`{"result":{"action":"quarantine","reason":"missing team label"}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"result":"copy"}`))
+	}))
+	defer server.Close()
+
+	evaluator, err := NewEvaluator(Options{URL: server.URL})
+	require.NoError(t, err)
+
+	decision, err := evaluator.Evaluate(context.Background(), Input{Repository: "app", Tag: "v1", Labels: map[string]string{"team": "core"}})
+	require.NoError(t, err)
+	assert.Equal(t, ActionCopy, decision.Action)
+
+	decision, err = evaluator.Evaluate(context.Background(), Input{Repository: "app", Tag: "v1"})
+	require.NoError(t, err)
+	assert.Equal(t, Decision{Action: ActionQuarantine, Reason: "missing team label"}, decision)
+}
+
+func TestServerEvaluator_Errors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/undefined":
+			_, _ = w.Write([]byte(`{}`))
+		default:
+			http.Error(w, "policy error", http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	for _, path := range []string{"/undefined", "/broken"} {
+		evaluator, err := NewEvaluator(Options{URL: server.URL + path})
+		require.NoError(t, err)
+		_, err = evaluator.Evaluate(context.Background(), Input{})
+		assert.Error(t, err, path)
+	}
+}
+
+func TestCLIEvaluator(t *testing.T) {
+	dir := t.TempDir()
+	binary := filepath.Join(dir, "opa")
+	script := "#!/bin/sh\ncat > " + filepath.Join(dir, "input.json") + "\necho \"$@\" > " + filepath.Join(dir, "args") +
+		"\necho '{\"result\":[{\"expressions\":[{\"value\":\"skip\"}]}]}'\n"
+	require.NoError(t, os.WriteFile(binary, []byte(script), 0o700))
+
+	evaluator, err := NewEvaluator(Options{Files: []string{"policy.rego", "data.json"}, Binary: binary})
+	require.NoError(t, err)
+
+	decision, err := evaluator.Evaluate(context.Background(), Input{Repository: "app", Tag: "v1"})
+	require.NoError(t, err)
+	assert.Equal(t, ActionSkip, decision.Action)
+
+	args, err := os.ReadFile(filepath.Join(dir, "args"))
+	require.NoError(t, err)
+	assert.Equal(t, "eval --format json --stdin-input --data policy.rego --data data.json "+DefaultQuery+"\n", string(args))
+
+	var input Input
+	data, err := os.ReadFile(filepath.Join(dir, "input.json"))
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, &input))
+	assert.Equal(t, "app", input.Repository)
+}
+
+func TestNewEvaluator_Validation(t *testing.T) {
+	_, err := NewEvaluator(Options{})
+	assert.Error(t, err)
+
+	_, err = NewEvaluator(Options{URL: "http://opa:8181/v1/data/x", Files: []string{"policy.rego"}})
+	assert.Error(t, err)
+
+	_, err = NewEvaluator(Options{Files: []string{"policy.rego"}, Binary: filepath.Join(t.TempDir(), "missing-opa")})
+	assert.Error(t, err)
+}
@@ -20,6 +20,19 @@ type ReplicationRule struct {
 	// Schedule is a cron expression for scheduled replication (empty for manual only)
 	Schedule string
 
+	// OverlapPolicy decides whether a scheduled run that fires while the
+	// previous run is still going is skipped or queued. Empty uses the
+	// scheduler's default.
+	OverlapPolicy OverlapPolicy
+
+	// ErrorBudget pauses the rule's schedule when too many of its runs fail.
+	// Nil uses the scheduler's default.
+	ErrorBudget *ErrorBudget
+
+	// Freeze forbids the rule's runs during maintenance windows and blackout
+	// dates. Nil uses the scheduler's default.
+	Freeze *FreezePolicy
+
 	// IncludeTags is a list of tag patterns to include (supports wildcards)
 	IncludeTags []string
 
@@ -28,6 +41,26 @@ type ReplicationRule struct {
 
 	// ForceOverwrite controls whether existing images should be overwritten
 	ForceOverwrite bool
+
+	// DigestChangeOnly copies a tag only when its source digest differs from the
+	// digest seen on the previous run, and skips the run entirely when the source
+	// registry reports an unchanged tag list ETag. Requires a reconciler DigestCache.
+	DigestChangeOnly bool
+
+	// CreateMissingRepos overrides the reconciler's auto-creation policy for the
+	// destination repository: "true", "false", or empty to inherit. Rules run
+	// unattended, so "prompt" never creates the repository.
+	CreateMissingRepos string
+
+	// RepositoryTags are added to the reconciler's repository template tags when
+	// the destination repository is created
+	RepositoryTags map[string]string
+}
+
+// RuleKey returns the identifier used for a rule by the scheduler and digest cache
+func RuleKey(rule ReplicationRule) string {
+	return rule.SourceRegistry + "/" + rule.SourceRepository + " -> " +
+		rule.DestinationRegistry + "/" + rule.DestinationRepository
 }
 
 // ReplicationConfig holds the configuration for replication
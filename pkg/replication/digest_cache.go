@@ -0,0 +1,112 @@
+package replication
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"freightliner/pkg/helper/errors"
+)
+
+// RuleDigestState is what a DigestChangeOnly rule remembers between runs
+type RuleDigestState struct {
+	// TagListETag is the source tag list ETag seen on the last complete run
+	TagListETag string `json:"tag_list_etag,omitempty"`
+
+	// Digests maps each source tag to the digest last copied
+	Digests map[string]string `json:"digests"`
+
+	// UpdatedAt is when the state was last written
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// DigestCache persists per-rule source digests between scheduled runs.
+// An empty path keeps the cache in memory only.
+type DigestCache struct {
+	path  string
+	mutex sync.Mutex
+	rules map[string]*RuleDigestState
+}
+
+// NewDigestCache loads the cache stored at path, starting empty if the file does not exist
+func NewDigestCache(path string) (*DigestCache, error) {
+	c := &DigestCache{
+		path:  path,
+		rules: make(map[string]*RuleDigestState),
+	}
+
+	if path == "" {
+		return c, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read digest cache")
+	}
+
+	if err := json.Unmarshal(data, &c.rules); err != nil {
+		return nil, errors.Wrap(err, "failed to parse digest cache %s", path)
+	}
+
+	return c, nil
+}
+
+// Get returns a copy of the state stored for a rule
+func (c *DigestCache) Get(key string) RuleDigestState {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	state := RuleDigestState{Digests: make(map[string]string)}
+	if stored, ok := c.rules[key]; ok {
+		state.TagListETag = stored.TagListETag
+		state.UpdatedAt = stored.UpdatedAt
+		for tag, digest := range stored.Digests {
+			state.Digests[tag] = digest
+		}
+	}
+
+	return state
+}
+
+// Put replaces the state for a rule and writes the cache to disk
+func (c *DigestCache) Put(key string, state RuleDigestState) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	state.UpdatedAt = time.Now().UTC()
+	c.rules[key] = &state
+
+	return c.save()
+}
+
+// save writes the cache atomically; callers must hold the mutex
+func (c *DigestCache) save() error {
+	if c.path == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(c.rules, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "failed to encode digest cache")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(c.path), 0700); err != nil {
+		return errors.Wrap(err, "failed to create digest cache directory")
+	}
+
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return errors.Wrap(err, "failed to write digest cache")
+	}
+
+	if err := os.Rename(tmp, c.path); err != nil {
+		return errors.Wrap(err, "failed to replace digest cache")
+	}
+
+	return nil
+}
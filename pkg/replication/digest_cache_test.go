@@ -0,0 +1,141 @@
+package replication
+
+import (
+	"context"
+	stderrors "errors"
+	"path/filepath"
+	"testing"
+
+	"freightliner/pkg/copy"
+	"freightliner/pkg/helper/log"
+	"freightliner/pkg/interfaces"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// versionedRepository reports a fixed tag list ETag
+type versionedRepository struct {
+	mockRepository
+	etag string
+}
+
+func (v *versionedRepository) TagListETag(ctx context.Context, previous string) (string, bool, error) {
+	return v.etag, v.etag != previous, nil
+}
+
+func TestDigestCachePersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "digests.json")
+
+	cache, err := NewDigestCache(path)
+	require.NoError(t, err)
+	assert.Empty(t, cache.Get("rule").Digests)
+
+	require.NoError(t, cache.Put("rule", RuleDigestState{
+		TagListETag: `"abc"`,
+		Digests:     map[string]string{"v1": "sha256:1"},
+	}))
+
+	reloaded, err := NewDigestCache(path)
+	require.NoError(t, err)
+	state := reloaded.Get("rule")
+	assert.Equal(t, `"abc"`, state.TagListETag)
+	assert.Equal(t, "sha256:1", state.Digests["v1"])
+	assert.False(t, state.UpdatedAt.IsZero())
+
+	// Returned state is a copy
+	state.Digests["v2"] = "sha256:2"
+	assert.NotContains(t, reloaded.Get("rule").Digests, "v2")
+}
+
+func TestDigestCacheInMemory(t *testing.T) {
+	cache, err := NewDigestCache("")
+	require.NoError(t, err)
+	require.NoError(t, cache.Put("rule", RuleDigestState{Digests: map[string]string{"v1": "sha256:1"}}))
+	assert.Equal(t, "sha256:1", cache.Get("rule").Digests["v1"])
+}
+
+func newDigestTestReconciler(cache *DigestCache) (*Reconciler, *mockMetrics) {
+	logger := log.NewBasicLogger(log.ErrorLevel)
+	metrics := &mockMetrics{}
+	return NewReconciler(ReconcilerOptions{
+		Logger:      logger,
+		Metrics:     metrics,
+		Copier:      copy.NewCopier(logger, copy.CopierOptions{}),
+		DryRun:      true,
+		DigestCache: cache,
+	}), metrics
+}
+
+func TestReconcileDigestChangeOnly(t *testing.T) {
+	rule := ReplicationRule{
+		SourceRegistry:        "src",
+		SourceRepository:      "test/repo",
+		DestinationRegistry:   "dst",
+		DestinationRepository: "test/repo",
+		DigestChangeOnly:      true,
+	}
+
+	sourceRepo := &mockRepository{
+		name: "test/repo",
+		tags: []string{"v1", "v2"},
+		manifests: map[string]*interfaces.Manifest{
+			"v1": {Digest: "sha256:1"},
+			"v2": {Digest: "sha256:2-new"},
+		},
+	}
+	// The destination is never consulted in this mode
+	destRepo := &mockRepository{name: "test/repo", listError: stderrors.New("unexpected list")}
+
+	cache, err := NewDigestCache("")
+	require.NoError(t, err)
+	require.NoError(t, cache.Put(RuleKey(rule), RuleDigestState{
+		Digests: map[string]string{"v1": "sha256:1", "v2": "sha256:2-old"},
+	}))
+
+	reconciler, metrics := newDigestTestReconciler(cache)
+	err = reconciler.ReconcileRepository(context.Background(), rule,
+		&mockRegistryClient{repositories: map[string]interfaces.Repository{"test/repo": sourceRepo}},
+		&mockRegistryClient{repositories: map[string]interfaces.Repository{"test/repo": destRepo}})
+	require.NoError(t, err)
+
+	// Only v2 changed digest
+	assert.Equal(t, int64(1), metrics.tagCopyStart.Load())
+}
+
+func TestReconcileDigestChangeOnlyUnchangedETag(t *testing.T) {
+	rule := ReplicationRule{
+		SourceRegistry:        "src",
+		SourceRepository:      "test/repo",
+		DestinationRegistry:   "dst",
+		DestinationRepository: "test/repo",
+		DigestChangeOnly:      true,
+	}
+
+	// Listing tags would fail, proving the run stops at the ETag check
+	sourceRepo := &versionedRepository{
+		mockRepository: mockRepository{name: "test/repo", listError: stderrors.New("unexpected list")},
+		etag:           `"etag-1"`,
+	}
+
+	cache, err := NewDigestCache("")
+	require.NoError(t, err)
+	require.NoError(t, cache.Put(RuleKey(rule), RuleDigestState{
+		TagListETag: `"etag-1"`,
+		Digests:     map[string]string{"v1": "sha256:1"},
+	}))
+
+	reconciler, metrics := newDigestTestReconciler(cache)
+	err = reconciler.ReconcileRepository(context.Background(), rule,
+		&mockRegistryClient{repositories: map[string]interfaces.Repository{"test/repo": sourceRepo}},
+		&mockRegistryClient{repositories: map[string]interfaces.Repository{"test/repo": &mockRepository{name: "test/repo"}}})
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), metrics.tagCopyStart.Load())
+
+	// A changed ETag falls through to listing
+	sourceRepo.etag = `"etag-2"`
+	err = reconciler.ReconcileRepository(context.Background(), rule,
+		&mockRegistryClient{repositories: map[string]interfaces.Repository{"test/repo": sourceRepo}},
+		&mockRegistryClient{repositories: map[string]interfaces.Repository{"test/repo": &mockRepository{name: "test/repo"}}})
+	assert.Error(t, err)
+}
@@ -0,0 +1,163 @@
+package replication
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"freightliner/pkg/helper/errors"
+)
+
+// ErrorBudget pauses a scheduled rule whose runs fail too often, so a broken
+// destination is not retried on every tick until someone intervenes
+type ErrorBudget struct {
+	// MaxFailureRate is the share of failed runs, between 0 and 1, above
+	// which the rule is paused
+	MaxFailureRate float64 `yaml:"max_failure_rate"`
+
+	// Window is how far back runs are counted (default: 24h)
+	Window time.Duration `yaml:"window,omitempty"`
+
+	// MinRuns is how many runs the window must hold before the rule can be
+	// paused (default: 3)
+	MinRuns int `yaml:"min_runs,omitempty"`
+}
+
+// Default error budget settings
+const (
+	DefaultErrorBudgetWindow  = 24 * time.Hour
+	DefaultErrorBudgetMinRuns = 3
+)
+
+// Validate checks that the budget's settings are usable
+func (b *ErrorBudget) Validate() error {
+	if b == nil {
+		return nil
+	}
+	if b.MaxFailureRate <= 0 || b.MaxFailureRate >= 1 {
+		return errors.InvalidInputf("error budget max_failure_rate must be between 0 and 1, got %v", b.MaxFailureRate)
+	}
+	if b.Window < 0 {
+		return errors.InvalidInputf("error budget window must not be negative")
+	}
+	if b.MinRuns < 0 {
+		return errors.InvalidInputf("error budget min_runs must not be negative")
+	}
+	return nil
+}
+
+// RulePause describes why a scheduled rule stopped running
+type RulePause struct {
+	// Rule identifies the paused rule
+	Rule string `json:"rule"`
+
+	// Reason explains which budget the rule's runs exceeded
+	Reason string `json:"reason"`
+
+	// PausedAt is when the rule was paused
+	PausedAt time.Time `json:"paused_at"`
+
+	// LastError is the error of the run that exhausted the budget
+	LastError string `json:"last_error,omitempty"`
+
+	// RunID identifies the server run that sent the pause notification
+	RunID string `json:"run_id,omitempty"`
+}
+
+// runOutcome is the result of one scheduled run
+type runOutcome struct {
+	at     time.Time
+	failed bool
+}
+
+// RunHistory records the outcomes of a rule's scheduled runs and pauses the
+// rule once their failure rate exceeds its error budget. It is safe for
+// concurrent use.
+type RunHistory struct {
+	rule   string
+	budget ErrorBudget
+
+	mu    sync.Mutex
+	runs  []runOutcome
+	pause *RulePause
+}
+
+// NewRunHistory tracks the runs of rule against budget, filling in defaults
+func NewRunHistory(rule string, budget ErrorBudget) *RunHistory {
+	if budget.Window <= 0 {
+		budget.Window = DefaultErrorBudgetWindow
+	}
+	if budget.MinRuns <= 0 {
+		budget.MinRuns = DefaultErrorBudgetMinRuns
+	}
+	return &RunHistory{rule: rule, budget: budget}
+}
+
+// Record adds the outcome of a run that finished at with err. It returns the
+// pause when this run exhausted the budget, and nil otherwise, including for
+// runs recorded while the rule is already paused.
+func (h *RunHistory) Record(at time.Time, err error) *RulePause {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.runs = append(h.runs, runOutcome{at: at, failed: err != nil})
+
+	// Drop runs that have left the window
+	cutoff := at.Add(-h.budget.Window)
+	kept := h.runs[:0]
+	for _, run := range h.runs {
+		if run.at.After(cutoff) {
+			kept = append(kept, run)
+		}
+	}
+	h.runs = kept
+
+	if h.pause != nil || err == nil || len(h.runs) < h.budget.MinRuns {
+		return nil
+	}
+
+	failed := 0
+	for _, run := range h.runs {
+		if run.failed {
+			failed++
+		}
+	}
+	rate := float64(failed) / float64(len(h.runs))
+	if rate <= h.budget.MaxFailureRate {
+		return nil
+	}
+
+	h.pause = &RulePause{
+		Rule: h.rule,
+		Reason: fmt.Sprintf("%d of %d runs failed in the last %s (%.0f%%, budget %.0f%%)",
+			failed, len(h.runs), h.budget.Window, rate*100, h.budget.MaxFailureRate*100),
+		PausedAt:  at,
+		LastError: err.Error(),
+	}
+	pause := *h.pause
+	return &pause
+}
+
+// Paused returns the rule's pause, or nil while it runs on schedule
+func (h *RunHistory) Paused() *RulePause {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.pause == nil {
+		return nil
+	}
+	pause := *h.pause
+	return &pause
+}
+
+// Resume lifts the pause and forgets earlier runs, so the rule gets a full
+// budget again. It reports whether the rule was paused.
+func (h *RunHistory) Resume() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	paused := h.pause != nil
+	h.pause = nil
+	h.runs = nil
+	return paused
+}
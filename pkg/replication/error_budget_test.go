@@ -0,0 +1,52 @@
+package replication
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrorBudgetValidate(t *testing.T) {
+	var none *ErrorBudget
+	assert.NoError(t, none.Validate())
+	assert.NoError(t, (&ErrorBudget{MaxFailureRate: 0.5}).Validate())
+	assert.Error(t, (&ErrorBudget{MaxFailureRate: 0}).Validate())
+	assert.Error(t, (&ErrorBudget{MaxFailureRate: 1.5}).Validate())
+	assert.Error(t, (&ErrorBudget{MaxFailureRate: 0.5, Window: -time.Hour}).Validate())
+	assert.Error(t, (&ErrorBudget{MaxFailureRate: 0.5, MinRuns: -1}).Validate())
+}
+
+func TestRunHistory(t *testing.T) {
+	history := NewRunHistory("mirror/app", ErrorBudget{MaxFailureRate: 0.5, Window: time.Hour, MinRuns: 3})
+	start := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	failure := errors.New("registry unavailable")
+
+	// Too few runs to judge the rule
+	assert.Nil(t, history.Record(start, failure))
+	assert.Nil(t, history.Record(start.Add(time.Minute), nil))
+	assert.Nil(t, history.Paused())
+
+	// Runs that left the window no longer count
+	assert.Nil(t, history.Record(start.Add(2*time.Hour), failure))
+	assert.Nil(t, history.Record(start.Add(2*time.Hour+time.Minute), nil))
+	assert.Nil(t, history.Paused())
+
+	// Two of three runs in the window failed
+	pause := history.Record(start.Add(2*time.Hour+2*time.Minute), failure)
+	require.NotNil(t, pause)
+	assert.Equal(t, "mirror/app", pause.Rule)
+	assert.Equal(t, "registry unavailable", pause.LastError)
+	assert.Contains(t, pause.Reason, "2 of 3 runs failed")
+	assert.Equal(t, pause, history.Paused())
+
+	// The pause is reported once
+	assert.Nil(t, history.Record(start.Add(2*time.Hour+3*time.Minute), failure))
+
+	assert.True(t, history.Resume())
+	assert.Nil(t, history.Paused())
+	assert.False(t, history.Resume())
+	assert.Nil(t, history.Record(start.Add(3*time.Hour), failure), "resumed rule starts with a full budget")
+}
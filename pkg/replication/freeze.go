@@ -0,0 +1,273 @@
+package replication
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"freightliner/pkg/helper/errors"
+)
+
+// FreezePolicy forbids a rule's changes during recurring windows and blackout
+// dates, e.g. nightly maintenance or a holiday change freeze. Times are read
+// in Timezone.
+type FreezePolicy struct {
+	// Timezone is the IANA name the windows and dates are in (default: UTC)
+	Timezone string `yaml:"timezone,omitempty"`
+
+	// Windows recur every week
+	Windows []FreezeWindow `yaml:"windows,omitempty"`
+
+	// Blackouts are one-off periods
+	Blackouts []Blackout `yaml:"blackouts,omitempty"`
+}
+
+// FreezeWindow is a weekly period without changes
+type FreezeWindow struct {
+	// Name appears in errors and logs
+	Name string `yaml:"name,omitempty"`
+
+	// Days the window starts on, e.g. "sat" or "saturday" (default: every day)
+	Days []string `yaml:"days,omitempty"`
+
+	// Start and End are "15:04" times. An End at or before Start ends the
+	// next day; leaving both empty freezes the whole day.
+	Start string `yaml:"start,omitempty"`
+	End   string `yaml:"end,omitempty"`
+}
+
+// Blackout is a one-off period without changes
+type Blackout struct {
+	// Name appears in errors and logs
+	Name string `yaml:"name,omitempty"`
+
+	// Start and End are dates ("2006-01-02") or times ("2006-01-02T15:04").
+	// An End date includes the whole day, and an empty End blacks out the
+	// Start date only.
+	Start string `yaml:"start"`
+	End   string `yaml:"end,omitempty"`
+}
+
+// Date and time layouts of freeze windows and blackouts
+const (
+	freezeTimeLayout     = "15:04"
+	blackoutDateLayout   = "2006-01-02"
+	blackoutMinuteLayout = "2006-01-02T15:04"
+)
+
+// FrozenError is returned for a change attempted while its rule is frozen
+type FrozenError struct {
+	// Rule identifies the frozen rule
+	Rule string
+
+	// Freeze names the window or blackout in effect
+	Freeze string
+
+	// Until is when the freeze ends
+	Until time.Time
+}
+
+func (e *FrozenError) Error() string {
+	return fmt.Sprintf("rule %s is frozen by %s until %s; no changes are allowed",
+		e.Rule, e.Freeze, e.Until.Format(time.RFC3339))
+}
+
+// Validate checks the policy's timezone, windows and blackouts
+func (p *FreezePolicy) Validate() error {
+	if p == nil {
+		return nil
+	}
+	if _, err := p.location(); err != nil {
+		return err
+	}
+	for _, window := range p.Windows {
+		if _, err := parseFreezeDays(window.Days); err != nil {
+			return err
+		}
+		if _, _, err := window.clock(); err != nil {
+			return err
+		}
+	}
+	for _, blackout := range p.Blackouts {
+		if _, _, err := blackout.period(time.UTC); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Check returns a *FrozenError when rule is frozen at at, or nil when changes
+// are allowed. A nil or invalid policy never freezes.
+func (p *FreezePolicy) Check(rule string, at time.Time) error {
+	if p == nil {
+		return nil
+	}
+	loc, err := p.location()
+	if err != nil {
+		return nil
+	}
+	at = at.In(loc)
+
+	for _, blackout := range p.Blackouts {
+		start, end, err := blackout.period(loc)
+		if err != nil {
+			continue
+		}
+		if !at.Before(start) && at.Before(end) {
+			return &FrozenError{Rule: rule, Freeze: describeFreeze("blackout", blackout.Name), Until: end}
+		}
+	}
+
+	for _, window := range p.Windows {
+		if end, ok := window.activeAt(at); ok {
+			return &FrozenError{Rule: rule, Freeze: describeFreeze("freeze window", window.Name), Until: end}
+		}
+	}
+	return nil
+}
+
+// location loads the policy's timezone
+func (p *FreezePolicy) location() (*time.Location, error) {
+	if p.Timezone == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(p.Timezone)
+	if err != nil {
+		return nil, errors.InvalidInputf("invalid freeze timezone %q", p.Timezone)
+	}
+	return loc, nil
+}
+
+// activeAt returns the end of the window occurrence containing at, if any.
+// Occurrences starting the day before are checked too, as they may run past
+// midnight.
+func (w FreezeWindow) activeAt(at time.Time) (time.Time, bool) {
+	days, err := parseFreezeDays(w.Days)
+	if err != nil {
+		return time.Time{}, false
+	}
+	start, end, err := w.clock()
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	for offset := -1; offset <= 0; offset++ {
+		day := time.Date(at.Year(), at.Month(), at.Day()+offset, 0, 0, 0, 0, at.Location())
+		if len(days) > 0 && !days[day.Weekday()] {
+			continue
+		}
+
+		from := onDay(day, 0, start)
+		to := onDay(day, 0, end)
+		if end <= start {
+			to = onDay(day, 1, end)
+		}
+		if !at.Before(from) && at.Before(to) {
+			return to, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// clock returns the window's start and end as offsets into the day
+func (w FreezeWindow) clock() (time.Duration, time.Duration, error) {
+	if w.Start == "" && w.End == "" {
+		return 0, 0, nil
+	}
+	start, err := parseFreezeClock(w.Start)
+	if err != nil {
+		return 0, 0, err
+	}
+	end, err := parseFreezeClock(w.End)
+	if err != nil {
+		return 0, 0, err
+	}
+	return start, end, nil
+}
+
+// onDay returns the time of day clock, days after day, in day's location.
+// Building it from the date keeps windows on the wall clock across DST changes.
+func onDay(day time.Time, days int, clock time.Duration) time.Time {
+	return time.Date(day.Year(), day.Month(), day.Day()+days,
+		int(clock/time.Hour), int(clock%time.Hour/time.Minute), 0, 0, day.Location())
+}
+
+// period returns when the blackout starts and ends in loc
+func (b Blackout) period(loc *time.Location) (time.Time, time.Time, error) {
+	start, startIsDate, err := parseBlackoutTime(b.Start, loc)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+
+	endValue := b.End
+	if endValue == "" {
+		if !startIsDate {
+			return time.Time{}, time.Time{}, errors.InvalidInputf("blackout starting %q needs an end", b.Start)
+		}
+		endValue = b.Start
+	}
+	end, endIsDate, err := parseBlackoutTime(endValue, loc)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	if endIsDate {
+		end = end.AddDate(0, 0, 1)
+	}
+
+	if !end.After(start) {
+		return time.Time{}, time.Time{}, errors.InvalidInputf("blackout %q must end after it starts", b.Start)
+	}
+	return start, end, nil
+}
+
+// parseBlackoutTime parses a blackout date or time, reporting which it was
+func parseBlackoutTime(value string, loc *time.Location) (time.Time, bool, error) {
+	if t, err := time.ParseInLocation(blackoutDateLayout, value, loc); err == nil {
+		return t, true, nil
+	}
+	if t, err := time.ParseInLocation(blackoutMinuteLayout, value, loc); err == nil {
+		return t, false, nil
+	}
+	return time.Time{}, false, errors.InvalidInputf("invalid blackout time %q, must be 2006-01-02 or 2006-01-02T15:04", value)
+}
+
+// parseFreezeClock parses a "15:04" time of day
+func parseFreezeClock(value string) (time.Duration, error) {
+	t, err := time.Parse(freezeTimeLayout, value)
+	if err != nil {
+		return 0, errors.InvalidInputf("invalid freeze window time %q, must be 15:04", value)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// parseFreezeDays parses weekday names into a set; empty means every day
+func parseFreezeDays(names []string) (map[time.Weekday]bool, error) {
+	days := make(map[time.Weekday]bool, len(names))
+	for _, name := range names {
+		day, ok := freezeWeekdays[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			return nil, errors.InvalidInputf("invalid freeze window day %q", name)
+		}
+		days[day] = true
+	}
+	return days, nil
+}
+
+// freezeWeekdays maps the short and long day names a window accepts
+var freezeWeekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "sunday": time.Sunday,
+	"mon": time.Monday, "monday": time.Monday,
+	"tue": time.Tuesday, "tuesday": time.Tuesday,
+	"wed": time.Wednesday, "wednesday": time.Wednesday,
+	"thu": time.Thursday, "thursday": time.Thursday,
+	"fri": time.Friday, "friday": time.Friday,
+	"sat": time.Saturday, "saturday": time.Saturday,
+}
+
+// describeFreeze names a window or blackout for FrozenError
+func describeFreeze(kind, name string) string {
+	if name == "" {
+		return kind
+	}
+	return fmt.Sprintf("%s %q", kind, name)
+}
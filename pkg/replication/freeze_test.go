@@ -0,0 +1,91 @@
+package replication
+
+import (
+	"testing"
+	"time"
+
+	"freightliner/pkg/helper/errors"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFreezePolicyCheck(t *testing.T) {
+	policy := &FreezePolicy{
+		Timezone: "America/New_York",
+		Windows: []FreezeWindow{
+			{Name: "nightly", Start: "22:00", End: "06:00"},
+			{Name: "weekend", Days: []string{"sat", "Sunday"}},
+		},
+		Blackouts: []Blackout{
+			{Name: "holidays", Start: "2026-12-24", End: "2027-01-01"},
+			{Start: "2026-11-05T12:00", End: "2026-11-05T13:30"},
+		},
+	}
+	require.NoError(t, policy.Validate())
+
+	ny, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+	at := func(value string) time.Time {
+		parsed, err := time.ParseInLocation("2006-01-02 15:04", value, ny)
+		require.NoError(t, err)
+		return parsed
+	}
+
+	tests := []struct {
+		name   string
+		at     time.Time
+		freeze string
+		until  time.Time
+	}{
+		{name: "weekday afternoon", at: at("2026-11-04 15:00")},
+		{name: "before midnight", at: at("2026-11-04 23:00"), freeze: `freeze window "nightly"`, until: at("2026-11-05 06:00")},
+		{name: "after midnight", at: at("2026-11-05 05:59"), freeze: `freeze window "nightly"`, until: at("2026-11-05 06:00")},
+		{name: "window end", at: at("2026-11-05 06:00")},
+		{name: "whole day", at: at("2026-11-07 12:00"), freeze: `freeze window "weekend"`, until: at("2026-11-08 00:00")},
+		{name: "blackout time", at: at("2026-11-05 12:30"), freeze: "blackout", until: at("2026-11-05 13:30")},
+		{name: "blackout end date is inclusive", at: at("2027-01-01 12:00"), freeze: `blackout "holidays"`, until: at("2027-01-02 00:00")},
+		{name: "timezone", at: at("2026-11-04 15:00").UTC()},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := policy.Check("mirror/app", tc.at)
+			if tc.freeze == "" {
+				assert.NoError(t, err)
+				return
+			}
+
+			var frozen *FrozenError
+			require.True(t, errors.As(err, &frozen))
+			assert.Equal(t, "mirror/app", frozen.Rule)
+			assert.Equal(t, tc.freeze, frozen.Freeze)
+			assert.True(t, tc.until.Equal(frozen.Until), "until %s", frozen.Until)
+			assert.Contains(t, err.Error(), "no changes are allowed")
+		})
+	}
+
+	var nilPolicy *FreezePolicy
+	assert.NoError(t, nilPolicy.Check("mirror/app", time.Now()))
+}
+
+func TestFreezePolicyValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy FreezePolicy
+	}{
+		{name: "timezone", policy: FreezePolicy{Timezone: "Mars/Olympus"}},
+		{name: "day", policy: FreezePolicy{Windows: []FreezeWindow{{Days: []string{"someday"}}}}},
+		{name: "window time", policy: FreezePolicy{Windows: []FreezeWindow{{Start: "25:00", End: "06:00"}}}},
+		{name: "missing window end", policy: FreezePolicy{Windows: []FreezeWindow{{Start: "22:00"}}}},
+		{name: "blackout date", policy: FreezePolicy{Blackouts: []Blackout{{Start: "24/12/2026"}}}},
+		{name: "blackout without end", policy: FreezePolicy{Blackouts: []Blackout{{Start: "2026-12-24T18:00"}}}},
+		{name: "blackout ends before start", policy: FreezePolicy{Blackouts: []Blackout{{Start: "2026-12-24", End: "2026-12-20"}}}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Error(t, tc.policy.Validate())
+		})
+	}
+}
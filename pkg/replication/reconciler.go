@@ -2,6 +2,8 @@ package replication
 
 import (
 	"context"
+	"strconv"
+	"sync"
 	"time"
 
 	"freightliner/pkg/copy"
@@ -26,6 +28,10 @@ type Reconciler struct {
 	dryRun      bool
 	forceUpdate bool
 	workerCount int
+	digestCache *DigestCache
+
+	createMissingRepos bool
+	repoTemplate       interfaces.RepositorySettings
 }
 
 // ReconcilerOptions configures the reconciler behavior
@@ -36,6 +42,16 @@ type ReconcilerOptions struct {
 	Metrics     metrics.MetricsCollector
 	DryRun      bool
 	ForceUpdate bool
+
+	// DigestCache stores source digests for rules with DigestChangeOnly set
+	DigestCache *DigestCache
+
+	// CreateMissingRepos creates missing destination repositories unless a
+	// rule overrides it
+	CreateMissingRepos bool
+
+	// RepositoryTemplate is applied to destination repositories created by the reconciler
+	RepositoryTemplate interfaces.RepositorySettings
 }
 
 // NewReconciler creates a new reconciler
@@ -53,6 +69,10 @@ func NewReconciler(opts ReconcilerOptions) *Reconciler {
 		dryRun:      opts.DryRun,
 		forceUpdate: opts.ForceUpdate,
 		workerCount: workerCount,
+		digestCache: opts.DigestCache,
+
+		createMissingRepos: opts.CreateMissingRepos,
+		repoTemplate:       opts.RepositoryTemplate,
 	}
 }
 
@@ -68,6 +88,10 @@ func (r *Reconciler) ReconcileRepository(
 		return err
 	}
 
+	if rule.DigestChangeOnly && r.digestCache != nil {
+		return r.reconcileChangedDigests(ctx, rule, sourceClient, destClient)
+	}
+
 	// Get repositories and tags
 	sourceRepo, destRepo, sourceTags, destTagMap, err := r.getRepositoriesAndTags(ctx, rule, sourceClient, destClient)
 	if err != nil {
@@ -78,6 +102,123 @@ func (r *Reconciler) ReconcileRepository(
 	return r.processAndReplicateTags(ctx, rule, sourceRepo, destRepo, sourceTags, destTagMap)
 }
 
+// reconcileChangedDigests copies only tags whose source digest changed since the
+// previous run. The destination is never queried, and nothing at all is listed when
+// the source registry reports an unchanged tag list ETag.
+func (r *Reconciler) reconcileChangedDigests(
+	ctx context.Context,
+	rule ReplicationRule,
+	sourceClient interfaces.RegistryClient,
+	destClient interfaces.RegistryClient) error {
+
+	key := RuleKey(rule)
+	state := r.digestCache.Get(key)
+
+	sourceRepo, err := sourceClient.GetRepository(ctx, rule.SourceRepository)
+	if err != nil {
+		return errors.Wrap(err, "failed to get source repository")
+	}
+
+	// The ETag is only trusted once a previous run has recorded digests
+	etag := ""
+	if versioner, ok := sourceRepo.(interfaces.TagListVersioner); ok {
+		current, changed, err := versioner.TagListETag(ctx, state.TagListETag)
+		if err != nil {
+			r.logger.WithFields(map[string]interface{}{
+				"source_repository": rule.SourceRepository,
+				"error":             err.Error(),
+			}).Debug("Tag list ETag unavailable, comparing digests")
+		} else if !changed && state.TagListETag != "" && len(state.Digests) > 0 {
+			r.logger.WithFields(map[string]interface{}{
+				"source_repository": rule.SourceRepository,
+				"etag":              current,
+			}).Debug("Source tag list unchanged, skipping run")
+			r.logCompletionAndUpdateMetrics(rule, 0, 0, 0, 0)
+			return nil
+		} else {
+			etag = current
+		}
+	}
+
+	sourceTags, err := sourceRepo.ListTags(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to list source tags")
+	}
+
+	destRepo, err := r.getOrCreateDestRepository(ctx, rule, destClient)
+	if err != nil {
+		return err
+	}
+
+	results := util.NewResults()
+	digests := make(map[string]string, len(sourceTags))
+	var digestsMu sync.Mutex
+	g := util.NewLimitedErrGroup(ctx, r.workerCount)
+
+	for _, tag := range sourceTags {
+		if !ShouldReplicate(rule, rule.SourceRepository, tag) {
+			results.AddMetric("skippedTags", 1)
+			continue
+		}
+		results.AddMetric("totalTags", 1)
+
+		manifest, err := sourceRepo.GetManifest(ctx, tag)
+		if err != nil {
+			r.logger.WithFields(map[string]interface{}{
+				"tag":   tag,
+				"error": err.Error(),
+			}).Warn("Failed to get source manifest, skipping tag")
+			results.AddMetric("failedTags", 1)
+			continue
+		}
+
+		if !r.forceUpdate && state.Digests[tag] == manifest.Digest {
+			digestsMu.Lock()
+			digests[tag] = manifest.Digest
+			digestsMu.Unlock()
+			results.AddMetric("skippedTags", 1)
+			continue
+		}
+
+		currentTag, digest := tag, manifest.Digest
+		g.Go(func() error {
+			if err := r.processTagTask(ctx, rule, sourceRepo, destRepo, currentTag, results); err != nil {
+				return err
+			}
+			digestsMu.Lock()
+			digests[currentTag] = digest
+			digestsMu.Unlock()
+			return nil
+		})
+	}
+
+	copyErr := g.Wait()
+
+	r.logCompletionAndUpdateMetrics(rule,
+		int(results.GetMetric("totalTags")),
+		int(results.GetMetric("copiedTags")),
+		int(results.GetMetric("skippedTags")),
+		int(results.GetMetric("failedTags")))
+
+	if r.dryRun {
+		return copyErr
+	}
+
+	// Keep the previous ETag after failures so the next run retries the failed tags
+	if copyErr != nil || results.GetMetric("failedTags") > 0 {
+		etag = ""
+	}
+
+	if err := r.digestCache.Put(key, RuleDigestState{TagListETag: etag, Digests: digests}); err != nil {
+		r.logger.WithFields(map[string]interface{}{
+			"rule":  key,
+			"error": err.Error(),
+		}).Warn("Failed to persist digest cache")
+	}
+
+	return copyErr
+}
+
 // validateReconcileParams validates the input parameters for reconciliation
 func (r *Reconciler) validateReconcileParams(
 	rule ReplicationRule,
@@ -103,6 +244,69 @@ func (r *Reconciler) validateReconcileParams(
 	return nil
 }
 
+// getOrCreateDestRepository returns the destination repository of a rule,
+// creating it from the repository template when the policy allows
+func (r *Reconciler) getOrCreateDestRepository(
+	ctx context.Context,
+	rule ReplicationRule,
+	destClient interfaces.RegistryClient) (interfaces.Repository, error) {
+
+	destRepo, err := destClient.GetRepository(ctx, rule.DestinationRepository)
+	if err == nil {
+		return destRepo, nil
+	}
+
+	if !r.shouldCreateMissingRepo(rule) {
+		return nil, errors.Wrap(err, "failed to get destination repository")
+	}
+
+	settings := r.repoTemplate
+	settings.Tags = make(map[string]string, len(r.repoTemplate.Tags)+len(rule.RepositoryTags)+1)
+	for k, v := range r.repoTemplate.Tags {
+		settings.Tags[k] = v
+	}
+	for k, v := range rule.RepositoryTags {
+		settings.Tags[k] = v
+	}
+	settings.Tags["CreatedBy"] = "Freightliner"
+
+	r.logger.WithFields(map[string]interface{}{
+		"repository": rule.DestinationRepository,
+		"registry":   destClient.GetRegistryName(),
+	}).Info("Creating missing destination repository")
+
+	destRepo, err = interfaces.CreateRepository(ctx, destClient, rule.DestinationRepository, settings)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create destination repository")
+	}
+
+	return destRepo, nil
+}
+
+// shouldCreateMissingRepo applies a rule's auto-creation override to the reconciler default
+func (r *Reconciler) shouldCreateMissingRepo(rule ReplicationRule) bool {
+	switch rule.CreateMissingRepos {
+	case "":
+		return r.createMissingRepos
+	case "prompt":
+		r.logger.WithFields(map[string]interface{}{
+			"repository": rule.DestinationRepository,
+		}).Warn("Cannot prompt for repository creation in an unattended rule, not creating")
+		return false
+	}
+
+	create, err := strconv.ParseBool(rule.CreateMissingRepos)
+	if err != nil {
+		r.logger.WithFields(map[string]interface{}{
+			"repository": rule.DestinationRepository,
+			"policy":     rule.CreateMissingRepos,
+		}).Warn("Invalid create-missing-repos policy on rule, not creating")
+		return false
+	}
+
+	return create
+}
+
 // getRepositoriesAndTags retrieves the source and destination repositories and tags
 func (r *Reconciler) getRepositoriesAndTags(
 	ctx context.Context,
@@ -117,9 +321,9 @@ func (r *Reconciler) getRepositoriesAndTags(
 	}
 
 	// Get the destination repository
-	destRepo, err := destClient.GetRepository(ctx, rule.DestinationRepository)
+	destRepo, err := r.getOrCreateDestRepository(ctx, rule, destClient)
 	if err != nil {
-		return nil, nil, nil, nil, errors.Wrap(err, "failed to get destination repository")
+		return nil, nil, nil, nil, err
 	}
 
 	// List all tags in the source repository
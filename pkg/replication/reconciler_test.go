@@ -436,21 +436,21 @@ func TestReconcileRepository(t *testing.T) {
 
 			// Create a real copier with logging capability
 			logger := log.NewBasicLogger(log.InfoLevel)
-			copier := copy.NewCopier(logger)
-
 			// Create custom blob transfer function to track operation and simulate errors
 			copiedTags := []string{}
 			copyError := tt.copyError
 
 			// Override the transfer function to track copies and return the configured error
-			copier.WithBlobTransferFunc(func(ctx context.Context, srcBlobURL, destBlobURL string) error {
-				// Extract tag from blob URL for tracking (simplified)
-				parts := strings.Split(srcBlobURL, "/")
-				if len(parts) > 0 {
-					tag := parts[len(parts)-1]
-					copiedTags = append(copiedTags, tag)
-				}
-				return copyError
+			copier := copy.NewCopier(logger, copy.CopierOptions{
+				BlobTransferFunc: func(ctx context.Context, srcBlobURL, destBlobURL string) error {
+					// Extract tag from blob URL for tracking (simplified)
+					parts := strings.Split(srcBlobURL, "/")
+					if len(parts) > 0 {
+						tag := parts[len(parts)-1]
+						copiedTags = append(copiedTags, tag)
+					}
+					return copyError
+				},
 			})
 
 			// Create reconciler with DryRun mode to prevent real network calls
@@ -542,7 +542,7 @@ func TestReconcile(t *testing.T) {
 
 	// Create a real copier with logging capability
 	logger := log.NewBasicLogger(log.InfoLevel)
-	copier := copy.NewCopier(logger)
+	copier := copy.NewCopier(logger, copy.CopierOptions{})
 
 	// Create mock metrics
 	metrics := &mockMetrics{}
@@ -605,3 +605,91 @@ func TestReconcile(t *testing.T) {
 		t.Errorf("Expected tagCopyComplete to be 2, got %d", metrics.tagCopyComplete.Load())
 	}
 }
+
+// mockCreatingClient records the settings repositories are created with
+type mockCreatingClient struct {
+	mockRegistryClient
+	created map[string]interfaces.RepositorySettings
+}
+
+func (m *mockCreatingClient) CreateRepositoryWithSettings(ctx context.Context, name string, settings interfaces.RepositorySettings) (interfaces.Repository, error) {
+	if m.created == nil {
+		m.created = make(map[string]interfaces.RepositorySettings)
+	}
+	m.created[name] = settings
+	repo := &mockRepository{name: name, manifests: map[string]*interfaces.Manifest{}}
+	m.repositories[name] = repo
+	return repo, nil
+}
+
+func TestGetOrCreateDestRepository(t *testing.T) {
+	template := interfaces.RepositorySettings{
+		Tags:           map[string]string{"team": "platform"},
+		ImmutableTags:  true,
+		EncryptionType: "KMS",
+		KMSKey:         "alias/registry",
+	}
+
+	tests := []struct {
+		name          string
+		defaultCreate bool
+		rulePolicy    string
+		expectCreated bool
+	}{
+		{name: "default does not create", defaultCreate: false, expectCreated: false},
+		{name: "default creates", defaultCreate: true, expectCreated: true},
+		{name: "rule enables creation", defaultCreate: false, rulePolicy: "true", expectCreated: true},
+		{name: "rule disables creation", defaultCreate: true, rulePolicy: "false", expectCreated: false},
+		{name: "rule prompt never creates", defaultCreate: true, rulePolicy: "prompt", expectCreated: false},
+		{name: "invalid rule policy never creates", defaultCreate: true, rulePolicy: "maybe", expectCreated: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &mockCreatingClient{
+				mockRegistryClient: mockRegistryClient{repositories: map[string]interfaces.Repository{}},
+			}
+			reconciler := NewReconciler(ReconcilerOptions{
+				Logger:             log.NewBasicLogger(log.InfoLevel),
+				CreateMissingRepos: tt.defaultCreate,
+				RepositoryTemplate: template,
+			})
+			rule := ReplicationRule{
+				DestinationRepository: "team/app",
+				CreateMissingRepos:    tt.rulePolicy,
+				RepositoryTags:        map[string]string{"env": "prod"},
+			}
+
+			repo, err := reconciler.getOrCreateDestRepository(context.Background(), rule, client)
+			if !tt.expectCreated {
+				if err == nil {
+					t.Fatal("Expected an error for a missing repository")
+				}
+				if len(client.created) != 0 {
+					t.Errorf("Expected no repository to be created, got %v", client.created)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if repo.GetRepositoryName() != "team/app" {
+				t.Errorf("Expected team/app, got %s", repo.GetRepositoryName())
+			}
+
+			settings := client.created["team/app"]
+			if !settings.ImmutableTags || settings.EncryptionType != "KMS" || settings.KMSKey != "alias/registry" {
+				t.Errorf("Template settings not applied: %+v", settings)
+			}
+			for key, want := range map[string]string{"team": "platform", "env": "prod", "CreatedBy": "Freightliner"} {
+				if settings.Tags[key] != want {
+					t.Errorf("Expected tag %s=%s, got %q", key, want, settings.Tags[key])
+				}
+			}
+			if _, ok := template.Tags["env"]; ok {
+				t.Error("Rule tags must not modify the reconciler template")
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package replication
 import (
 	"context"
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 
@@ -24,6 +25,37 @@ type Job struct {
 
 	// Running indicates if the job is currently running
 	Running bool
+
+	// Queued indicates a run that fired while the job was running and starts
+	// once it finishes
+	Queued bool
+
+	// Pause is set while the job is paused for exceeding its error budget;
+	// paused jobs do not run until resumed
+	Pause *RulePause
+}
+
+// OverlapPolicy decides what happens when a rule's schedule fires while its
+// previous run is still going
+type OverlapPolicy string
+
+const (
+	// OverlapSkip drops the run that fired during a previous run
+	OverlapSkip OverlapPolicy = "skip"
+
+	// OverlapQueue starts one more run as soon as the previous run finishes.
+	// Further ticks during the same run are folded into that queued run.
+	OverlapQueue OverlapPolicy = "queue"
+)
+
+// ValidateOverlapPolicy checks that policy is empty or a known policy
+func ValidateOverlapPolicy(policy OverlapPolicy) error {
+	switch policy {
+	case "", OverlapSkip, OverlapQueue:
+		return nil
+	default:
+		return errors.InvalidInputf("invalid overlap policy %q, must be %q or %q", policy, OverlapSkip, OverlapQueue)
+	}
 }
 
 // ReplicationService handles the actual replication operations
@@ -44,6 +76,22 @@ type Scheduler struct {
 	registryProviders map[string]interfaces.RegistryProvider
 	cronParser        cron.Parser
 	encryptionMgr     *encryption.Manager
+	overlapPolicy     OverlapPolicy
+
+	// running holds the per-rule run locks. It is keyed by rule ID rather
+	// than stored on the Job so replacing a job mid-run keeps the lock.
+	running map[string]bool
+
+	// errorBudget applies to rules without their own (nil disables pausing)
+	errorBudget *ErrorBudget
+	onPause     func(RulePause)
+
+	// freeze applies to rules without their own (nil never freezes)
+	freeze *FreezePolicy
+
+	// histories hold each rule's recent run outcomes, keyed by rule ID so a
+	// replaced job stays paused
+	histories map[string]*RunHistory
 }
 
 // SchedulerOptions provides configuration for the scheduler
@@ -62,6 +110,22 @@ type SchedulerOptions struct {
 
 	// EncryptionManager is the manager for encryption operations (optional)
 	EncryptionManager *encryption.Manager
+
+	// OverlapPolicy is used for rules that don't set their own. Defaults to
+	// OverlapSkip.
+	OverlapPolicy OverlapPolicy
+
+	// ErrorBudget is used for rules that don't set their own. Rules are
+	// never paused when both are nil.
+	ErrorBudget *ErrorBudget
+
+	// OnPause is called when a rule is paused for exceeding its error
+	// budget (optional)
+	OnPause func(RulePause)
+
+	// Freeze is used for rules that don't set their own, e.g. a holiday
+	// change freeze for every rule (optional)
+	Freeze *FreezePolicy
 }
 
 // NewScheduler creates a new replication scheduler
@@ -89,6 +153,15 @@ func NewScheduler(opts SchedulerOptions) *Scheduler {
 		registryProviders: opts.RegistryProviders,
 		cronParser:        cronParser,
 		encryptionMgr:     opts.EncryptionManager,
+		overlapPolicy:     opts.OverlapPolicy,
+		running:           make(map[string]bool),
+		errorBudget:       opts.ErrorBudget,
+		onPause:           opts.OnPause,
+		freeze:            opts.Freeze,
+		histories:         make(map[string]*RunHistory),
+	}
+	if scheduler.overlapPolicy == "" {
+		scheduler.overlapPolicy = OverlapSkip
 	}
 
 	// Start the scheduler loop
@@ -116,9 +189,20 @@ func (s *Scheduler) AddJob(rule ReplicationRule) error {
 		return errors.InvalidInputf("destination repository cannot be empty")
 	}
 
+	if err := ValidateOverlapPolicy(rule.OverlapPolicy); err != nil {
+		return err
+	}
+
+	if err := rule.ErrorBudget.Validate(); err != nil {
+		return err
+	}
+
+	if err := rule.Freeze.Validate(); err != nil {
+		return err
+	}
+
 	// Create a unique ID for the job
-	id := rule.SourceRegistry + "/" + rule.SourceRepository + " -> " +
-		rule.DestinationRegistry + "/" + rule.DestinationRepository
+	id := RuleKey(rule)
 
 	// Skip jobs without a schedule
 	if rule.Schedule == "" {
@@ -163,11 +247,15 @@ func (s *Scheduler) AddJob(rule ReplicationRule) error {
 		}).Debug("Updating existing job")
 	}
 
-	s.jobs[id] = &Job{
+	job := &Job{
 		Rule:    rule,
 		NextRun: nextRun,
-		Running: false,
+		Running: s.running[id],
+	}
+	if history := s.histories[id]; history != nil {
+		job.Pause = history.Paused()
 	}
+	s.jobs[id] = job
 
 	s.logger.WithFields(map[string]interface{}{
 		"id":       id,
@@ -200,14 +288,14 @@ func (s *Scheduler) RemoveJob(rule ReplicationRule) error {
 	}
 
 	// Create a unique ID for the job
-	id := rule.SourceRegistry + "/" + rule.SourceRepository + " -> " +
-		rule.DestinationRegistry + "/" + rule.DestinationRepository
+	id := RuleKey(rule)
 
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
 	if _, exists := s.jobs[id]; exists {
 		delete(s.jobs, id)
+		delete(s.histories, id)
 
 		s.logger.WithFields(map[string]interface{}{
 			"id": id,
@@ -236,45 +324,157 @@ func (s *Scheduler) run() {
 // checkJobs checks for jobs that need to run
 func (s *Scheduler) checkJobs() {
 	s.mutex.Lock()
-	defer s.mutex.Unlock()
 
 	now := time.Now()
+	due := make(map[string]*Job)
 
 	for id, job := range s.jobs {
-		// Check if job should run (not running and next run time has passed or is now)
-		if !job.Running && (now.After(job.NextRun) || now.Equal(job.NextRun)) {
-			// Mark the job as running
-			job.Running = true
-
-			// Calculate the next run time based on the cron expression
-			if job.Rule.Schedule != "@once" && job.Rule.Schedule != "@now" {
-				schedule, err := s.cronParser.Parse(job.Rule.Schedule)
-				if err != nil {
-					s.logger.WithFields(map[string]interface{}{
-						"id":       id,
-						"schedule": job.Rule.Schedule,
-						"error":    err.Error(),
-						"next_run": now.Add(1 * time.Hour),
-					}).Warn("Invalid cron expression, using default schedule")
-					job.NextRun = now.Add(1 * time.Hour)
-				} else {
-					job.NextRun = schedule.Next(now)
-					s.logger.WithFields(map[string]interface{}{
-						"id":       id,
-						"next_run": job.NextRun,
-					}).Debug("Scheduled next run")
-				}
-			} else {
-				// For @once and @now schedules, don't reschedule
-				s.logger.WithFields(map[string]interface{}{
-					"id": id,
-				}).Debug("One-time job, not rescheduling")
+		// Check if the next run time has passed or is now
+		if now.Before(job.NextRun) || job.Pause != nil {
+			continue
+		}
+
+		if s.skipFrozen(id, job, now) {
+			continue
+		}
+
+		if s.running[id] {
+			// One-time jobs never fire again, so there is nothing to skip or queue
+			if !isOneTime(job.Rule) {
+				s.handleOverlap(id, job, now)
 			}
+			continue
+		}
+
+		// Take the rule's run lock and mark the job as running
+		s.running[id] = true
+		job.Running = true
+		s.scheduleNextRun(id, job, now)
+		due[id] = job
+	}
+
+	s.mutex.Unlock()
+
+	// Submit outside the lock; submission failures release the run lock
+	for id, job := range due {
+		s.submitJob(id, job)
+	}
+}
+
+// skipFrozen reports whether the job's rule is frozen at now. Recurring jobs
+// skip to their next scheduled run and one-time jobs wait for the freeze to end.
+func (s *Scheduler) skipFrozen(id string, job *Job, now time.Time) bool {
+	freeze := job.Rule.Freeze
+	if freeze == nil {
+		freeze = s.freeze
+	}
+
+	var frozen *FrozenError
+	if !errors.As(freeze.Check(id, now), &frozen) {
+		return false
+	}
+
+	job.Queued = false
+	if isOneTime(job.Rule) {
+		job.NextRun = frozen.Until
+	} else {
+		s.scheduleNextRun(id, job, now)
+	}
+
+	s.logger.WithFields(map[string]interface{}{
+		"id":       id,
+		"freeze":   frozen.Freeze,
+		"until":    frozen.Until,
+		"next_run": job.NextRun,
+	}).Info("Rule is frozen, skipping scheduled run")
+	return true
+}
+
+// handleOverlap applies the rule's overlap policy to a run that fired while
+// the previous run is still going
+func (s *Scheduler) handleOverlap(id string, job *Job, now time.Time) {
+	s.scheduleNextRun(id, job, now)
+
+	policy := job.Rule.OverlapPolicy
+	if policy == "" {
+		policy = s.overlapPolicy
+	}
+
+	if policy == OverlapQueue {
+		if !job.Queued {
+			job.Queued = true
+			s.logger.WithFields(map[string]interface{}{
+				"id": id,
+			}).Info("Previous run still in progress, queueing scheduled run")
+		}
+		return
+	}
+
+	s.logger.WithFields(map[string]interface{}{
+		"id":       id,
+		"next_run": job.NextRun,
+	}).Warn("Previous run still in progress, skipping scheduled run")
+}
+
+// scheduleNextRun moves a recurring job's next run past now
+func (s *Scheduler) scheduleNextRun(id string, job *Job, now time.Time) {
+	if isOneTime(job.Rule) {
+		// For @once and @now schedules, don't reschedule
+		s.logger.WithFields(map[string]interface{}{
+			"id": id,
+		}).Debug("One-time job, not rescheduling")
+		return
+	}
+
+	// Calculate the next run time based on the cron expression
+	schedule, err := s.cronParser.Parse(job.Rule.Schedule)
+	if err != nil {
+		s.logger.WithFields(map[string]interface{}{
+			"id":       id,
+			"schedule": job.Rule.Schedule,
+			"error":    err.Error(),
+			"next_run": now.Add(1 * time.Hour),
+		}).Warn("Invalid cron expression, using default schedule")
+		job.NextRun = now.Add(1 * time.Hour)
+		return
+	}
+
+	job.NextRun = schedule.Next(now)
+	s.logger.WithFields(map[string]interface{}{
+		"id":       id,
+		"next_run": job.NextRun,
+	}).Debug("Scheduled next run")
+}
 
-			// Submit the job to the worker pool
-			s.submitJob(id, job)
+// finishRun releases a rule's run lock and starts its queued run, if any
+func (s *Scheduler) finishRun(id string) {
+	s.mutex.Lock()
+	delete(s.running, id)
+
+	queued := false
+	if j, exists := s.jobs[id]; exists {
+		j.Running = false
+		if j.Queued {
+			j.Queued = false
+			if j.Pause == nil {
+				j.NextRun = time.Now()
+				queued = true
+			}
 		}
 	}
+	s.mutex.Unlock()
+
+	if queued && s.ctx.Err() == nil {
+		s.logger.WithFields(map[string]interface{}{
+			"id": id,
+		}).Info("Starting queued scheduled run")
+		go s.checkJobs()
+	}
+}
+
+// isOneTime reports whether a rule runs once instead of on a cron schedule
+func isOneTime(rule ReplicationRule) bool {
+	return rule.Schedule == "@once" || rule.Schedule == "@now"
 }
 
 // submitJob submits a job to the worker pool
@@ -296,12 +496,8 @@ func (s *Scheduler) submitJob(id string, job *Job) {
 			"id":    id,
 		}).Error("Invalid worker pool", err)
 
-		// Mark the job as not running since submission will fail
-		s.mutex.Lock()
-		if j, exists := s.jobs[id]; exists {
-			j.Running = false
-		}
-		s.mutex.Unlock()
+		// Release the run lock since submission will fail
+		s.finishRun(id)
 		return
 	}
 
@@ -321,12 +517,8 @@ func (s *Scheduler) submitJob(id string, job *Job) {
 				}).Error("Job panic recovered", panicErr)
 			}
 
-			// Mark the job as not running when done
-			s.mutex.Lock()
-			if j, exists := s.jobs[id]; exists {
-				j.Running = false
-			}
-			s.mutex.Unlock()
+			// Release the run lock when done
+			s.finishRun(id)
 		}()
 
 		// Check for context cancellation
@@ -355,6 +547,7 @@ func (s *Scheduler) submitJob(id string, job *Job) {
 		startTime := time.Now()
 		err := s.replicationSvc.ReplicateRepository(ctx, job.Rule)
 		duration := time.Since(startTime)
+		s.recordRun(id, job.Rule, err)
 
 		if err != nil {
 			replicationErr := errors.Wrap(err, "replication failed")
@@ -382,13 +575,96 @@ func (s *Scheduler) submitJob(id string, job *Job) {
 			"error": err.Error(),
 		}).Error("Failed to submit job", submitErr)
 
-		// Mark the job as not running since submission failed
-		s.mutex.Lock()
-		if j, exists := s.jobs[id]; exists {
-			j.Running = false
+		// Release the run lock since submission failed
+		s.finishRun(id)
+	}
+}
+
+// recordRun counts a finished run against the rule's error budget and pauses
+// the rule when the budget is exceeded
+func (s *Scheduler) recordRun(id string, rule ReplicationRule, runErr error) {
+	budget := rule.ErrorBudget
+	if budget == nil {
+		budget = s.errorBudget
+	}
+	if budget == nil {
+		return
+	}
+
+	s.mutex.Lock()
+	history := s.histories[id]
+	if history == nil {
+		history = NewRunHistory(id, *budget)
+		s.histories[id] = history
+	}
+	pause := history.Record(time.Now(), runErr)
+	if pause != nil {
+		if job, exists := s.jobs[id]; exists {
+			job.Pause = pause
+		}
+	}
+	s.mutex.Unlock()
+
+	if pause == nil {
+		return
+	}
+	s.logger.WithFields(map[string]interface{}{
+		"id":     id,
+		"reason": pause.Reason,
+	}).Warn("Pausing scheduled rule that exceeded its error budget")
+	if s.onPause != nil {
+		s.onPause(*pause)
+	}
+}
+
+// ResumeJob lifts the pause of a rule's job and schedules its next run
+func (s *Scheduler) ResumeJob(rule ReplicationRule) error {
+	id := RuleKey(rule)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	job, exists := s.jobs[id]
+	if !exists {
+		return errors.NotFoundf("job not found with ID: %s", id)
+	}
+	if history := s.histories[id]; history != nil {
+		history.Resume()
+	}
+	if job.Pause == nil {
+		return nil
+	}
+
+	job.Pause = nil
+	s.scheduleNextRun(id, job, time.Now())
+	s.logger.WithFields(map[string]interface{}{
+		"id":       id,
+		"next_run": job.NextRun,
+	}).Info("Resumed scheduled rule")
+	return nil
+}
+
+// Jobs returns a copy of every scheduled job, ordered by rule ID
+func (s *Scheduler) Jobs() []Job {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	ids := make([]string, 0, len(s.jobs))
+	for id := range s.jobs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	jobs := make([]Job, 0, len(ids))
+	for _, id := range ids {
+		job := *s.jobs[id]
+		if job.Pause != nil {
+			pause := *job.Pause
+			job.Pause = &pause
 		}
-		s.mutex.Unlock()
+		jobs = append(jobs, job)
 	}
+	return jobs
 }
 
 // Stop stops the scheduler
@@ -593,3 +593,187 @@ func TestScheduler_JobExecution_WithError(t *testing.T) {
 		t.Error("Expected at least 1 replication attempt")
 	}
 }
+
+// blockingReplicationService blocks its first run until release is closed
+type blockingReplicationService struct {
+	calls   atomic.Int32
+	started chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingReplicationService) ReplicateRepository(ctx context.Context, rule ReplicationRule) error {
+	if b.calls.Add(1) == 1 {
+		close(b.started)
+		<-b.release
+	}
+	return nil
+}
+
+func runOverlappingSchedule(t *testing.T, policy OverlapPolicy) (*Scheduler, *blockingReplicationService, string) {
+	t.Helper()
+
+	logger := log.NewBasicLogger(log.InfoLevel)
+	pool := NewWorkerPool(5, logger)
+	pool.Start()
+	t.Cleanup(pool.Stop)
+
+	svc := &blockingReplicationService{started: make(chan struct{}), release: make(chan struct{})}
+	scheduler := NewScheduler(SchedulerOptions{
+		Logger:             logger,
+		WorkerPool:         pool,
+		ReplicationService: svc,
+		OverlapPolicy:      policy,
+	})
+	t.Cleanup(func() { _ = scheduler.Stop() })
+
+	rule := ReplicationRule{
+		SourceRegistry:        "source-registry",
+		SourceRepository:      "source/repo",
+		DestinationRegistry:   "dest-registry",
+		DestinationRepository: "dest/repo",
+		Schedule:              "* * * * * *",
+	}
+	if err := scheduler.AddJob(rule); err != nil {
+		t.Fatalf("Failed to add job: %v", err)
+	}
+	id := RuleKey(rule)
+
+	// First tick starts a run that stays blocked
+	time.Sleep(1100 * time.Millisecond)
+	scheduler.checkJobs()
+	select {
+	case <-svc.started:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the first run to start")
+	}
+
+	// Second tick fires while the first run is still going
+	time.Sleep(1100 * time.Millisecond)
+	scheduler.checkJobs()
+
+	return scheduler, svc, id
+}
+
+func TestScheduler_OverlapSkip(t *testing.T) {
+	scheduler, svc, id := runOverlappingSchedule(t, "")
+
+	if calls := svc.calls.Load(); calls != 1 {
+		t.Errorf("Expected 1 run while the first is in progress, got %d", calls)
+	}
+
+	scheduler.mutex.RLock()
+	queued := scheduler.jobs[id].Queued
+	scheduler.mutex.RUnlock()
+	if queued {
+		t.Error("Expected the overlapping run to be skipped, not queued")
+	}
+
+	close(svc.release)
+	time.Sleep(200 * time.Millisecond)
+
+	if calls := svc.calls.Load(); calls != 1 {
+		t.Errorf("Expected the skipped run not to start later, got %d runs", calls)
+	}
+
+	scheduler.mutex.RLock()
+	running := scheduler.running[id]
+	scheduler.mutex.RUnlock()
+	if running {
+		t.Error("Expected the run lock to be released")
+	}
+}
+
+func TestScheduler_OverlapQueue(t *testing.T) {
+	scheduler, svc, _ := runOverlappingSchedule(t, OverlapQueue)
+
+	// A third tick during the same run folds into the queued run
+	time.Sleep(1100 * time.Millisecond)
+	scheduler.checkJobs()
+
+	if calls := svc.calls.Load(); calls != 1 {
+		t.Errorf("Expected 1 run while the first is in progress, got %d", calls)
+	}
+
+	close(svc.release)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for svc.calls.Load() < 2 && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	if calls := svc.calls.Load(); calls != 2 {
+		t.Errorf("Expected exactly one queued run after the first finished, got %d runs", calls)
+	}
+}
+
+func TestScheduler_AddJob_InvalidOverlapPolicy(t *testing.T) {
+	scheduler := NewScheduler(SchedulerOptions{Logger: log.NewBasicLogger(log.InfoLevel)})
+	defer scheduler.Stop()
+
+	err := scheduler.AddJob(ReplicationRule{
+		SourceRegistry:        "source-registry",
+		SourceRepository:      "source/repo",
+		DestinationRegistry:   "dest-registry",
+		DestinationRepository: "dest/repo",
+		Schedule:              "@once",
+		OverlapPolicy:         "parallel",
+	})
+	if err == nil {
+		t.Fatal("Expected an error for an unknown overlap policy")
+	}
+}
+
+func TestScheduler_SkipsFrozenRule(t *testing.T) {
+	logger := log.NewBasicLogger(log.InfoLevel)
+	pool := NewWorkerPool(5, logger)
+	pool.Start()
+	t.Cleanup(pool.Stop)
+
+	today := time.Now().UTC().Format("2006-01-02")
+	svc := &blockingReplicationService{started: make(chan struct{}), release: make(chan struct{})}
+	scheduler := NewScheduler(SchedulerOptions{
+		Logger:             logger,
+		WorkerPool:         pool,
+		ReplicationService: svc,
+		Freeze:             &FreezePolicy{Blackouts: []Blackout{{Name: "today", Start: today}}},
+	})
+	t.Cleanup(func() { _ = scheduler.Stop() })
+
+	recurring := ReplicationRule{
+		SourceRegistry:        "source-registry",
+		SourceRepository:      "source/repo",
+		DestinationRegistry:   "dest-registry",
+		DestinationRepository: "dest/repo",
+		Schedule:              "* * * * * *",
+	}
+	once := recurring
+	once.SourceRepository = "source/once"
+	once.Schedule = "@once"
+	for _, rule := range []ReplicationRule{recurring, once} {
+		if err := scheduler.AddJob(rule); err != nil {
+			t.Fatalf("Failed to add job: %v", err)
+		}
+	}
+
+	time.Sleep(1100 * time.Millisecond)
+	scheduler.checkJobs()
+
+	if calls := svc.calls.Load(); calls != 0 {
+		t.Errorf("Expected no runs while frozen, got %d", calls)
+	}
+
+	// One-time runs wait for the blackout to end
+	scheduler.mutex.RLock()
+	nextRun := scheduler.jobs[RuleKey(once)].NextRun
+	scheduler.mutex.RUnlock()
+	if !nextRun.After(time.Now()) || nextRun.UTC().Hour() != 0 {
+		t.Errorf("Expected the one-time run to wait until midnight, got %s", nextRun)
+	}
+
+	// Invalid freezes are refused when the rule is added
+	invalid := recurring
+	invalid.Freeze = &FreezePolicy{Timezone: "Mars/Olympus"}
+	if err := scheduler.AddJob(invalid); err == nil {
+		t.Error("Expected an invalid freeze to be refused")
+	}
+}
@@ -2,18 +2,34 @@ package replication
 
 import (
 	"context"
+	"runtime/debug"
+	"runtime/pprof"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"freightliner/pkg/diagnostics"
 	"freightliner/pkg/helper/errors"
 	"freightliner/pkg/helper/log"
 )
 
-// WorkerPool manages a pool of workers for parallel processing
+// WorkerPool runs tasks on a fixed number of goroutines. It is shared by the
+// server, the scheduler and the replication service.
+//
+// Lifecycle: NewWorkerPool, Start, then Submit (or SubmitWithContext /
+// SubmitWithLabels) any number of tasks. Results are delivered on GetResults.
+// Finish with Wait to run every queued task, Drain to do the same within a
+// deadline, or Stop to cancel running tasks and discard queued ones.
+//
+// A task that panics does not take down the process: the panic is recovered,
+// logged with its stack, counted in GetStats and reported as the task's error.
+//
+// While started, the pool's stats and running jobs appear in diagnostics
+// dumps, and task goroutines carry the job ID and labels as pprof labels.
 type WorkerPool struct {
 	workers       int
 	jobQueue      chan WorkerJob
+	blockWhenFull bool
 	results       chan JobResult
 	waitGroup     sync.WaitGroup
 	stopContext   context.Context
@@ -22,7 +38,28 @@ type WorkerPool struct {
 	closed        atomic.Bool
 	jobsClosed    atomic.Bool
 	resultsClosed atomic.Bool
+
+	// sendMu is held for reading by every send on jobQueue and for writing
+	// when it is closed, so a submit never sends on a closed queue. draining
+	// is closed first, so submits waiting for queue space release it.
+	sendMu       sync.RWMutex
+	draining     chan struct{}
+	drainingOnce sync.Once
+
 	stats         *statsCollector
+	activeWorkers atomic.Int32
+	metrics       PoolMetrics
+	running       sync.Map // worker ID -> RunningJob
+	unregister    func()
+}
+
+// PoolMetrics receives worker pool gauges and panic counts.
+// *metrics.Registry satisfies it.
+type PoolMetrics interface {
+	SetWorkerPoolSize(size int)
+	SetWorkerPoolActive(active int)
+	SetWorkerPoolQueued(queued int)
+	RecordPanic(component string)
 }
 
 // WorkerJob represents a unit of work to be processed by a worker
@@ -31,23 +68,68 @@ type WorkerJob struct {
 	Task     TaskFunc
 	Priority int
 	Context  context.Context
+
+	// Labels describe the task in logs and results, e.g. {"repository": "app"}
+	Labels map[string]string
+
+	enqueuedAt time.Time
 }
 
 // JobResult represents the result of a job
 type JobResult struct {
-	JobID string
-	Error error
+	JobID  string
+	Error  error
+	Labels map[string]string
+
+	// Duration is how long the task ran
+	Duration time.Duration
+
+	// QueueLatency is how long the task waited before a worker picked it up
+	QueueLatency time.Duration
+
+	// Panicked is true when the error was recovered from a panic
+	Panicked bool
 }
 
 // TaskFunc is a function that performs a task
 type TaskFunc func(ctx context.Context) error
 
+// WorkerPoolOptions configures NewWorkerPoolWithOptions
+type WorkerPoolOptions struct {
+	// Workers is the number of goroutines running tasks
+	Workers int
+
+	// QueueSize bounds the number of pending jobs; zero sizes the queue from Workers
+	QueueSize int
+
+	// BlockWhenFull makes submits wait for queue space until their context
+	// is done, pacing producers to the workers, instead of failing after 30
+	// seconds. Time spent waiting is reported in GetStats.
+	BlockWhenFull bool
+
+	// Logger is the logger to use
+	Logger log.Logger
+
+	// Metrics receives pool gauges and panic counts (optional)
+	Metrics PoolMetrics
+}
+
 // NewWorkerPool creates a new worker pool with the specified number of workers
 func NewWorkerPool(workerCount int, logger log.Logger) *WorkerPool {
+	return NewWorkerPoolWithOptions(WorkerPoolOptions{
+		Workers: workerCount,
+		Logger:  logger,
+	})
+}
+
+// NewWorkerPoolWithOptions creates a new worker pool from opts
+func NewWorkerPoolWithOptions(opts WorkerPoolOptions) *WorkerPool {
+	workerCount := opts.Workers
 	if workerCount <= 0 {
 		workerCount = 1
 	}
 
+	logger := opts.Logger
 	if logger == nil {
 		logger = log.NewBasicLogger(log.InfoLevel)
 	}
@@ -56,34 +138,53 @@ func NewWorkerPool(workerCount int, logger log.Logger) *WorkerPool {
 
 	// Calculate optimal buffer sizes based on worker count
 	// Use a minimum buffer size and scale with worker count for better performance
-	minBufferSize := 10
-	maxBufferSize := 1000
-	bufferSize := workerCount * 20 // Allow for more jobs per worker
-	if bufferSize < minBufferSize {
-		bufferSize = minBufferSize
-	}
-	if bufferSize > maxBufferSize {
-		bufferSize = maxBufferSize
+	bufferSize := opts.QueueSize
+	if bufferSize <= 0 {
+		minBufferSize := 10
+		maxBufferSize := 1000
+		bufferSize = workerCount * 20 // Allow for more jobs per worker
+		if bufferSize < minBufferSize {
+			bufferSize = minBufferSize
+		}
+		if bufferSize > maxBufferSize {
+			bufferSize = maxBufferSize
+		}
 	}
 
 	pool := &WorkerPool{
-		workers:     workerCount,
-		jobQueue:    make(chan WorkerJob, bufferSize),
-		results:     make(chan JobResult, bufferSize),
-		stopContext: ctx,
-		stopFunc:    cancel,
-		logger:      logger,
-		stats:       newStatsCollector(),
+		workers:       workerCount,
+		jobQueue:      make(chan WorkerJob, bufferSize),
+		blockWhenFull: opts.BlockWhenFull,
+		results:       make(chan JobResult, bufferSize),
+		stopContext:   ctx,
+		stopFunc:      cancel,
+		logger:        logger,
+		stats:         newStatsCollector(),
+		draining:      make(chan struct{}),
 	}
+	pool.SetMetrics(opts.Metrics)
+
 	return pool
 }
 
+// SetMetrics reports pool gauges and recovered panics to m
+func (p *WorkerPool) SetMetrics(m PoolMetrics) {
+	p.metrics = m
+	if m != nil {
+		m.SetWorkerPoolSize(p.workers)
+	}
+}
+
 // Start starts the worker pool
 func (p *WorkerPool) Start() {
 	p.logger.WithFields(map[string]interface{}{
 		"workers": p.workers,
 	}).Info("Starting worker pool")
 
+	p.unregister = diagnostics.Register("worker_pool", func() interface{} {
+		return p.Diagnostics()
+	})
+
 	for i := 0; i < p.workers; i++ {
 		workerID := i
 		p.waitGroup.Add(1)
@@ -131,39 +232,71 @@ func (p *WorkerPool) worker(id int) {
 }
 
 // setupJobContext creates a job context that will be canceled if either the job's context
-// or the pool's context is canceled. Avoids goroutine leaks by using simple context hierarchy.
+// or the pool's context is canceled
 func (p *WorkerPool) setupJobContext(job WorkerJob) (context.Context, context.CancelFunc) {
-	// Create a child context from the job's context
-	// This will be canceled when either the job context or pool's stop context is done
 	jobCtx, jobCancel := context.WithCancel(job.Context)
+	stop := context.AfterFunc(p.stopContext, jobCancel)
 
-	// Note: The job execution monitors both jobCtx and p.stopContext in the worker
-	// to handle cancellation from either source
-	return jobCtx, jobCancel
+	return jobCtx, func() {
+		stop()
+		jobCancel()
+	}
 }
 
-// executeJob runs the job's task and measures execution time
-func (p *WorkerPool) executeJob(ctx context.Context, job WorkerJob) (time.Duration, error) {
+// executeJob runs the job's task, recovering panics, and measures execution time
+func (p *WorkerPool) executeJob(ctx context.Context, job WorkerJob) (duration time.Duration, panicked bool, err error) {
 	startTime := time.Now()
-	err := job.Task(ctx)
-	duration := time.Since(startTime)
-	return duration, err
+	defer func() {
+		duration = time.Since(startTime)
+		if r := recover(); r != nil {
+			panicked = true
+			err = errors.Newf("task %s panicked: %v", job.ID, r)
+
+			fields := map[string]interface{}{
+				"job_id": job.ID,
+				"panic":  r,
+				"stack":  string(debug.Stack()),
+			}
+			for k, v := range job.Labels {
+				fields[k] = v
+			}
+			p.logger.WithFields(fields).Error("Recovered panic in worker pool task", err)
+
+			if p.stats != nil {
+				p.stats.recordPanic()
+			}
+			if p.metrics != nil {
+				p.metrics.RecordPanic("worker_pool")
+			}
+		}
+	}()
+
+	labels := []string{"job_id", job.ID}
+	for k, v := range job.Labels {
+		labels = append(labels, k, v)
+	}
+	pprof.Do(ctx, pprof.Labels(labels...), func(ctx context.Context) {
+		err = job.Task(ctx)
+	})
+	return duration, false, err
 }
 
 // logJobResult logs the outcome of a job execution
-func (p *WorkerPool) logJobResult(workerID int, jobID string, duration time.Duration, err error) {
-	if err != nil {
-		p.logger.WithFields(map[string]interface{}{
-			"worker_id": workerID,
-			"job_id":    jobID,
-			"duration":  duration.String(),
-		}).Error("Job failed", err)
+func (p *WorkerPool) logJobResult(workerID int, result JobResult) {
+	fields := map[string]interface{}{
+		"worker_id":     workerID,
+		"job_id":        result.JobID,
+		"duration":      result.Duration.String(),
+		"queue_latency": result.QueueLatency.String(),
+	}
+	for k, v := range result.Labels {
+		fields[k] = v
+	}
+
+	if result.Error != nil {
+		p.logger.WithFields(fields).Error("Job failed", result.Error)
 	} else {
-		p.logger.WithFields(map[string]interface{}{
-			"worker_id": workerID,
-			"job_id":    jobID,
-			"duration":  duration.String(),
-		}).Debug("Job completed successfully")
+		p.logger.WithFields(fields).Debug("Job completed successfully")
 	}
 }
 
@@ -193,15 +326,32 @@ func (p *WorkerPool) processJob(workerID int, job WorkerJob) {
 		"priority":  job.Priority,
 	}).Debug("Processing job")
 
+	var queueLatency time.Duration
+	if !job.enqueuedAt.IsZero() {
+		queueLatency = time.Since(job.enqueuedAt)
+	}
+
+	p.setActive(p.activeWorkers.Add(1))
+	defer func() { p.setActive(p.activeWorkers.Add(-1)) }()
+
+	p.running.Store(workerID, RunningJob{
+		WorkerID:  workerID,
+		JobID:     job.ID,
+		Labels:    job.Labels,
+		StartedAt: time.Now(),
+	})
+	defer p.running.Delete(workerID)
+
 	// Set up job context with cancellation
 	jobCtx, cancel := p.setupJobContext(job)
 	defer cancel()
 
 	// Execute the job and measure duration
-	duration, err := p.executeJob(jobCtx, job)
+	duration, panicked, err := p.executeJob(jobCtx, job)
 
 	// Record stats
 	if p.stats != nil {
+		p.stats.recordQueueLatency(queueLatency)
 		if err != nil {
 			p.stats.recordJobFailure(duration)
 		} else {
@@ -211,17 +361,36 @@ func (p *WorkerPool) processJob(workerID int, job WorkerJob) {
 
 	// Create job result
 	result := JobResult{
-		JobID: job.ID,
-		Error: err,
+		JobID:        job.ID,
+		Error:        err,
+		Labels:       job.Labels,
+		Duration:     duration,
+		QueueLatency: queueLatency,
+		Panicked:     panicked,
 	}
 
 	// Log the result
-	p.logJobResult(workerID, job.ID, duration, err)
+	p.logJobResult(workerID, result)
 
 	// Send the result
 	p.sendJobResult(result)
 }
 
+// setActive publishes the active worker and queue depth gauges
+func (p *WorkerPool) setActive(active int32) {
+	if p.metrics != nil {
+		p.metrics.SetWorkerPoolActive(int(active))
+		p.metrics.SetWorkerPoolQueued(len(p.jobQueue))
+	}
+}
+
+// setQueued publishes the queue depth gauge
+func (p *WorkerPool) setQueued() {
+	if p.metrics != nil {
+		p.metrics.SetWorkerPoolQueued(len(p.jobQueue))
+	}
+}
+
 // createJob creates a new job with the given parameters
 func (p *WorkerPool) createJob(id string, task TaskFunc, priority int, ctx context.Context) WorkerJob {
 	if ctx == nil {
@@ -238,16 +407,56 @@ func (p *WorkerPool) createJob(id string, task TaskFunc, priority int, ctx conte
 
 // enqueueJob adds a job to the job queue with timeout to prevent deadlocks
 func (p *WorkerPool) enqueueJob(job WorkerJob) error {
+	p.sendMu.RLock()
+	defer p.sendMu.RUnlock()
+	if p.jobsClosed.Load() {
+		return errors.New("worker pool is draining")
+	}
+
+	job.enqueuedAt = time.Now()
+	select {
+	case p.jobQueue <- job:
+		p.setQueued()
+		return nil
+	default:
+	}
+
+	if p.blockWhenFull {
+		return p.enqueueJobWhenFree(job)
+	}
+
 	select {
 	case <-p.stopContext.Done():
 		return errors.New("worker pool is stopped")
+	case <-p.draining:
+		return errors.New("worker pool is draining")
 	case p.jobQueue <- job:
+		p.setQueued()
 		return nil
 	case <-time.After(30 * time.Second): // Prevent indefinite blocking
 		return errors.New("job queue is full, timeout after 30 seconds")
 	}
 }
 
+// enqueueJobWhenFree waits for queue space, holding back the producer while
+// workers catch up. The caller holds sendMu for reading.
+func (p *WorkerPool) enqueueJobWhenFree(job WorkerJob) error {
+	start := time.Now()
+	defer func() { p.stats.recordBlockedSubmit(time.Since(start)) }()
+
+	select {
+	case <-p.stopContext.Done():
+		return errors.New("worker pool is stopped")
+	case <-p.draining:
+		return errors.New("worker pool is draining")
+	case <-job.Context.Done():
+		return job.Context.Err()
+	case p.jobQueue <- job:
+		p.setQueued()
+		return nil
+	}
+}
+
 // Submit adds a job to the pool
 func (p *WorkerPool) Submit(id string, task TaskFunc) error {
 	return p.SubmitWithPriority(id, task, 0)
@@ -273,6 +482,18 @@ func (p *WorkerPool) SubmitWithContext(ctx context.Context, id string, task Task
 	return p.enqueueJob(job)
 }
 
+// SubmitWithLabels adds a job to the pool with labels that are attached to its
+// log lines and result
+func (p *WorkerPool) SubmitWithLabels(ctx context.Context, id string, labels map[string]string, task TaskFunc) error {
+	if task == nil {
+		return errors.InvalidInputf("task cannot be nil")
+	}
+
+	job := p.createJob(id, task, 0, ctx)
+	job.Labels = labels
+	return p.enqueueJob(job)
+}
+
 // GetResults returns the results channel
 func (p *WorkerPool) GetResults() <-chan JobResult {
 	return p.results
@@ -280,12 +501,10 @@ func (p *WorkerPool) GetResults() <-chan JobResult {
 
 // Wait waits for all submitted jobs to complete
 func (p *WorkerPool) Wait() {
-	// Close job queue only once
-	if p.jobsClosed.CompareAndSwap(false, true) {
-		close(p.jobQueue)
-	}
+	p.closeJobQueue()
 
 	p.waitGroup.Wait()
+	p.unregisterDiagnostics()
 
 	// Close results channel only once, and only if pool isn't stopped
 	if !p.closed.Load() && p.resultsClosed.CompareAndSwap(false, true) {
@@ -297,18 +516,82 @@ func (p *WorkerPool) Wait() {
 func (p *WorkerPool) Stop() {
 	if p.closed.CompareAndSwap(false, true) {
 		p.stopFunc()
+		p.closeJobQueue()
 
-		// Close job queue if not already closed
-		if p.jobsClosed.CompareAndSwap(false, true) {
-			close(p.jobQueue)
+		p.waitGroup.Wait()
+		p.unregisterDiagnostics()
+
+		// Close results channel only once
+		if p.resultsClosed.CompareAndSwap(false, true) {
+			close(p.results)
 		}
+	}
+}
+
+// Drain stops accepting new jobs and waits for queued and running jobs to
+// finish. If they have not finished within timeout, running jobs are canceled
+// and Drain returns a timeout error without waiting for them further.
+func (p *WorkerPool) Drain(timeout time.Duration) error {
+	p.closeJobQueue()
 
+	done := make(chan struct{})
+	go func() {
 		p.waitGroup.Wait()
+		p.unregisterDiagnostics()
+		close(done)
+	}()
 
-		// Close results channel only once
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-done:
+		p.closed.Store(true)
+		p.stopFunc()
 		if p.resultsClosed.CompareAndSwap(false, true) {
 			close(p.results)
 		}
+		return nil
+
+	case <-timer.C:
+		p.closed.Store(true)
+		p.stopFunc()
+
+		p.logger.WithFields(map[string]interface{}{
+			"timeout": timeout.String(),
+			"active":  p.activeWorkers.Load(),
+			"queued":  len(p.jobQueue),
+		}).Warn("Worker pool drain deadline exceeded, canceling running jobs")
+
+		// Results can only be closed once every worker has returned
+		go func() {
+			<-done
+			if p.resultsClosed.CompareAndSwap(false, true) {
+				close(p.results)
+			}
+		}()
+
+		return errors.Timeoutf("worker pool did not drain within %s", timeout)
+	}
+}
+
+// closeJobQueue stops accepting jobs and closes the job queue once no submit
+// is sending on it. Submits waiting for queue space give up with an error.
+func (p *WorkerPool) closeJobQueue() {
+	p.drainingOnce.Do(func() { close(p.draining) })
+
+	p.sendMu.Lock()
+	defer p.sendMu.Unlock()
+	if p.jobsClosed.CompareAndSwap(false, true) {
+		close(p.jobQueue)
+	}
+}
+
+// unregisterDiagnostics removes the pool from diagnostics dumps once its
+// workers have exited
+func (p *WorkerPool) unregisterDiagnostics() {
+	if p.unregister != nil {
+		p.unregister()
 	}
 }
 
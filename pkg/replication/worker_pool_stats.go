@@ -1,28 +1,44 @@
 package replication
 
 import (
+	"sort"
 	"sync/atomic"
 	"time"
 )
 
 // WorkerPoolStats represents statistics about the worker pool
 type WorkerPoolStats struct {
-	TotalWorkers   int
-	ActiveWorkers  int
-	IdleWorkers    int
-	QueuedJobs     int
-	RunningJobs    int
-	CompletedJobs  int64
-	FailedJobs     int64
-	AvgJobDuration time.Duration
-	Throughput     float64 // Jobs per minute
+	TotalWorkers    int
+	ActiveWorkers   int
+	IdleWorkers     int
+	QueuedJobs      int
+	QueueCapacity   int
+	RunningJobs     int
+	CompletedJobs   int64
+	FailedJobs      int64
+	PanickedJobs    int64
+	AvgJobDuration  time.Duration
+	AvgQueueLatency time.Duration
+	MaxQueueLatency time.Duration
+	Throughput      float64 // Jobs per minute
+
+	// BlockedSubmits counts submits that waited for queue space, and
+	// SubmitWaitTime is their total wait, with BlockWhenFull
+	BlockedSubmits int64
+	SubmitWaitTime time.Duration
 }
 
 // statsCollector collects statistics about worker pool operations
 type statsCollector struct {
 	completedJobs atomic.Int64
 	failedJobs    atomic.Int64
+	panickedJobs  atomic.Int64
 	totalDuration atomic.Int64 // Sum of all job durations in nanoseconds
+	totalLatency  atomic.Int64 // Sum of all queue latencies in nanoseconds
+	maxLatency    atomic.Int64
+	dequeued      atomic.Int64
+	blocked       atomic.Int64
+	blockedTime   atomic.Int64 // Sum of submit waits in nanoseconds
 	startTime     time.Time
 }
 
@@ -45,6 +61,38 @@ func (s *statsCollector) recordJobFailure(duration time.Duration) {
 	s.totalDuration.Add(int64(duration))
 }
 
+// recordPanic records a task that panicked
+func (s *statsCollector) recordPanic() {
+	s.panickedJobs.Add(1)
+}
+
+// recordQueueLatency records how long a job waited in the queue
+func (s *statsCollector) recordQueueLatency(latency time.Duration) {
+	s.dequeued.Add(1)
+	s.totalLatency.Add(int64(latency))
+	for {
+		current := s.maxLatency.Load()
+		if int64(latency) <= current || s.maxLatency.CompareAndSwap(current, int64(latency)) {
+			return
+		}
+	}
+}
+
+// recordBlockedSubmit records a submit that waited for queue space
+func (s *statsCollector) recordBlockedSubmit(wait time.Duration) {
+	s.blocked.Add(1)
+	s.blockedTime.Add(int64(wait))
+}
+
+// getAvgLatency returns the average queue latency
+func (s *statsCollector) getAvgLatency() time.Duration {
+	count := s.dequeued.Load()
+	if count == 0 {
+		return 0
+	}
+	return time.Duration(s.totalLatency.Load() / count)
+}
+
 // getAvgDuration returns the average job duration
 func (s *statsCollector) getAvgDuration() time.Duration {
 	completed := s.completedJobs.Load()
@@ -72,30 +120,24 @@ func (s *statsCollector) getThroughput() float64 {
 
 // GetStats returns current worker pool statistics
 func (p *WorkerPool) GetStats() WorkerPoolStats {
-	// Count active workers
-	activeWorkers := 0
-	// This is a simplified implementation
-	// In production, we would track this more accurately
-
-	// Get queue sizes
-	queuedJobs := len(p.jobQueue)
-
-	// Get stats from collector
-	completed := p.stats.completedJobs.Load()
-	failed := p.stats.failedJobs.Load()
-	avgDuration := p.stats.getAvgDuration()
-	throughput := p.stats.getThroughput()
+	activeWorkers := int(p.activeWorkers.Load())
 
 	return WorkerPoolStats{
-		TotalWorkers:   p.workers,
-		ActiveWorkers:  activeWorkers,
-		IdleWorkers:    p.workers - activeWorkers,
-		QueuedJobs:     queuedJobs,
-		RunningJobs:    activeWorkers,
-		CompletedJobs:  completed,
-		FailedJobs:     failed,
-		AvgJobDuration: avgDuration,
-		Throughput:     throughput,
+		TotalWorkers:    p.workers,
+		ActiveWorkers:   activeWorkers,
+		IdleWorkers:     p.workers - activeWorkers,
+		QueuedJobs:      len(p.jobQueue),
+		QueueCapacity:   cap(p.jobQueue),
+		RunningJobs:     activeWorkers,
+		CompletedJobs:   p.stats.completedJobs.Load(),
+		FailedJobs:      p.stats.failedJobs.Load(),
+		PanickedJobs:    p.stats.panickedJobs.Load(),
+		AvgJobDuration:  p.stats.getAvgDuration(),
+		AvgQueueLatency: p.stats.getAvgLatency(),
+		MaxQueueLatency: time.Duration(p.stats.maxLatency.Load()),
+		Throughput:      p.stats.getThroughput(),
+		BlockedSubmits:  p.stats.blocked.Load(),
+		SubmitWaitTime:  time.Duration(p.stats.blockedTime.Load()),
 	}
 }
 
@@ -108,3 +150,51 @@ func (p *WorkerPool) initStats() {
 
 // Add stats field to WorkerPool struct (needs to be added to worker_pool.go)
 // stats *statsCollector
+
+// RunningJob is a job a worker is executing
+type RunningJob struct {
+	WorkerID  int               `json:"worker_id"`
+	JobID     string            `json:"job_id"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	StartedAt time.Time         `json:"started_at"`
+}
+
+// RunningJobs returns the jobs workers are executing, by worker ID
+func (p *WorkerPool) RunningJobs() []RunningJob {
+	jobs := []RunningJob{}
+	p.running.Range(func(_, value interface{}) bool {
+		jobs = append(jobs, value.(RunningJob))
+		return true
+	})
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].WorkerID < jobs[j].WorkerID })
+	return jobs
+}
+
+// WorkerPoolDiagnostics is a worker pool's entry in diagnostics dumps
+type WorkerPoolDiagnostics struct {
+	Workers         int          `json:"workers"`
+	Active          int          `json:"active"`
+	Queued          int          `json:"queued"`
+	QueueCapacity   int          `json:"queue_capacity"`
+	Completed       int64        `json:"completed"`
+	Failed          int64        `json:"failed"`
+	Panicked        int64        `json:"panicked"`
+	MaxQueueLatency string       `json:"max_queue_latency"`
+	Running         []RunningJob `json:"running"`
+}
+
+// Diagnostics returns the pool's state for diagnostics dumps
+func (p *WorkerPool) Diagnostics() WorkerPoolDiagnostics {
+	stats := p.GetStats()
+	return WorkerPoolDiagnostics{
+		Workers:         stats.TotalWorkers,
+		Active:          stats.ActiveWorkers,
+		Queued:          stats.QueuedJobs,
+		QueueCapacity:   stats.QueueCapacity,
+		Completed:       stats.CompletedJobs,
+		Failed:          stats.FailedJobs,
+		Panicked:        stats.PanickedJobs,
+		MaxQueueLatency: stats.MaxQueueLatency.String(),
+		Running:         p.RunningJobs(),
+	}
+}
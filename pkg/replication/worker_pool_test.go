@@ -3,11 +3,13 @@ package replication
 import (
 	"context"
 	"errors"
+	"runtime/pprof"
 	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
 
+	"freightliner/pkg/diagnostics"
 	"freightliner/pkg/helper/log"
 )
 
@@ -403,3 +405,292 @@ func TestWorkerPool_PriorityOrdering(t *testing.T) {
 	}
 	mu.Unlock()
 }
+
+// TestWorkerPool_PanicRecovery tests that a panicking task is reported as an error
+func TestWorkerPool_PanicRecovery(t *testing.T) {
+	logger := log.NewBasicLogger(log.ErrorLevel)
+	pool := NewWorkerPool(2, logger)
+	pool.Start()
+
+	labels := map[string]string{"repository": "app"}
+	if err := pool.SubmitWithLabels(context.Background(), "panics", labels, func(ctx context.Context) error {
+		panic("boom")
+	}); err != nil {
+		t.Fatalf("Failed to submit task: %v", err)
+	}
+	if err := pool.Submit("succeeds", func(ctx context.Context) error {
+		return nil
+	}); err != nil {
+		t.Fatalf("Failed to submit task: %v", err)
+	}
+
+	results := make(map[string]JobResult)
+	for i := 0; i < 2; i++ {
+		select {
+		case result := <-pool.GetResults():
+			results[result.JobID] = result
+		case <-time.After(2 * time.Second):
+			t.Fatal("Timed out waiting for results")
+		}
+	}
+	pool.Wait()
+
+	panicked := results["panics"]
+	if !panicked.Panicked || panicked.Error == nil {
+		t.Errorf("Expected panic to be reported as an error, got %+v", panicked)
+	}
+	if panicked.Labels["repository"] != "app" {
+		t.Errorf("Expected labels on result, got %v", panicked.Labels)
+	}
+	if results["succeeds"].Error != nil {
+		t.Errorf("Expected other task to succeed, got %v", results["succeeds"].Error)
+	}
+
+	stats := pool.GetStats()
+	if stats.PanickedJobs != 1 || stats.FailedJobs != 1 || stats.CompletedJobs != 1 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
+
+// TestWorkerPool_Drain tests graceful drain with and without hitting the deadline
+func TestWorkerPool_Drain(t *testing.T) {
+	logger := log.NewBasicLogger(log.ErrorLevel)
+
+	pool := NewWorkerPool(2, logger)
+	pool.Start()
+
+	var completed atomic.Int32
+	for i := 0; i < 4; i++ {
+		if err := pool.Submit("quick-"+string(rune('A'+i)), func(ctx context.Context) error {
+			time.Sleep(10 * time.Millisecond)
+			completed.Add(1)
+			return nil
+		}); err != nil {
+			t.Fatalf("Failed to submit task: %v", err)
+		}
+	}
+	go func() {
+		for range pool.GetResults() {
+		}
+	}()
+
+	if err := pool.Drain(2 * time.Second); err != nil {
+		t.Fatalf("Expected drain to finish, got %v", err)
+	}
+	if completed.Load() != 4 {
+		t.Errorf("Expected 4 completed tasks, got %d", completed.Load())
+	}
+	if err := pool.Submit("late", func(ctx context.Context) error { return nil }); err == nil {
+		t.Error("Expected submit after drain to fail")
+	}
+
+	// A task that outlives the deadline is canceled
+	slowPool := NewWorkerPool(1, logger)
+	slowPool.Start()
+
+	canceled := make(chan struct{})
+	if err := slowPool.Submit("slow", func(ctx context.Context) error {
+		<-ctx.Done()
+		close(canceled)
+		return ctx.Err()
+	}); err != nil {
+		t.Fatalf("Failed to submit task: %v", err)
+	}
+
+	if err := slowPool.Drain(50 * time.Millisecond); err == nil {
+		t.Error("Expected drain deadline error")
+	}
+	select {
+	case <-canceled:
+	case <-time.After(2 * time.Second):
+		t.Error("Expected running task to be canceled")
+	}
+}
+
+// TestWorkerPool_QueueStats tests active worker and queue latency tracking
+func TestWorkerPool_QueueStats(t *testing.T) {
+	logger := log.NewBasicLogger(log.ErrorLevel)
+	pool := NewWorkerPoolWithOptions(WorkerPoolOptions{Workers: 1, QueueSize: 5, Logger: logger})
+	pool.Start()
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	_ = pool.Submit("blocker", func(ctx context.Context) error {
+		close(started)
+		<-release
+		return nil
+	})
+	_ = pool.Submit("waiting", func(ctx context.Context) error { return nil })
+
+	<-started
+	time.Sleep(20 * time.Millisecond)
+
+	stats := pool.GetStats()
+	if stats.ActiveWorkers != 1 || stats.QueuedJobs != 1 || stats.QueueCapacity != 5 {
+		t.Errorf("Unexpected stats while busy: %+v", stats)
+	}
+
+	close(release)
+	pool.Wait()
+
+	stats = pool.GetStats()
+	if stats.ActiveWorkers != 0 {
+		t.Errorf("Expected no active workers, got %d", stats.ActiveWorkers)
+	}
+	if stats.MaxQueueLatency < 20*time.Millisecond {
+		t.Errorf("Expected queued job latency to be recorded, got %s", stats.MaxQueueLatency)
+	}
+}
+
+// TestWorkerPool_BlockWhenFull tests that submits wait for queue space
+func TestWorkerPool_BlockWhenFull(t *testing.T) {
+	logger := log.NewBasicLogger(log.ErrorLevel)
+	pool := NewWorkerPoolWithOptions(WorkerPoolOptions{Workers: 1, QueueSize: 1, BlockWhenFull: true, Logger: logger})
+	pool.Start()
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	_ = pool.Submit("blocker", func(ctx context.Context) error {
+		close(started)
+		<-release
+		return nil
+	})
+	<-started
+	_ = pool.Submit("queued", func(ctx context.Context) error { return nil })
+
+	// The queue is full, so this submit waits until the blocker finishes
+	submitted := make(chan error, 1)
+	go func() {
+		submitted <- pool.SubmitWithContext(context.Background(), "waiting", func(ctx context.Context) error { return nil })
+	}()
+	select {
+	case err := <-submitted:
+		t.Fatalf("Expected submit to wait for queue space, got %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// A submit whose context ends gives up
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := pool.SubmitWithContext(ctx, "canceled", func(ctx context.Context) error { return nil }); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected deadline exceeded, got %v", err)
+	}
+
+	close(release)
+	if err := <-submitted; err != nil {
+		t.Fatalf("Expected waiting submit to be queued, got %v", err)
+	}
+	pool.Wait()
+
+	stats := pool.GetStats()
+	if stats.CompletedJobs != 3 {
+		t.Errorf("Expected 3 completed jobs, got %d", stats.CompletedJobs)
+	}
+	if stats.BlockedSubmits != 2 || stats.SubmitWaitTime < 50*time.Millisecond {
+		t.Errorf("Expected blocked submits to be recorded, got %+v", stats)
+	}
+}
+
+// TestWorkerPool_SubmitDuringStop tests that submits racing Stop, Drain and
+// Wait fail cleanly instead of sending on the closed job queue
+func TestWorkerPool_SubmitDuringStop(t *testing.T) {
+	logger := log.NewBasicLogger(log.ErrorLevel)
+	closers := map[string]func(*WorkerPool){
+		"stop":  func(p *WorkerPool) { p.Stop() },
+		"drain": func(p *WorkerPool) { _ = p.Drain(time.Second) },
+		"wait":  func(p *WorkerPool) { p.Wait() },
+	}
+
+	for name, closePool := range closers {
+		t.Run(name, func(t *testing.T) {
+			for i := 0; i < 20; i++ {
+				pool := NewWorkerPoolWithOptions(WorkerPoolOptions{Workers: 1, QueueSize: 1, BlockWhenFull: true, Logger: logger})
+				pool.Start()
+				go func() {
+					for range pool.GetResults() {
+					}
+				}()
+
+				var wg sync.WaitGroup
+				for j := 0; j < 8; j++ {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						for k := 0; k < 20; k++ {
+							_ = pool.Submit("job", func(ctx context.Context) error {
+								time.Sleep(time.Millisecond)
+								return nil
+							})
+						}
+					}()
+				}
+
+				time.Sleep(time.Millisecond)
+				closePool(pool)
+				wg.Wait()
+
+				if err := pool.Submit("late", func(ctx context.Context) error { return nil }); err == nil {
+					t.Error("Expected submit after close to fail")
+				}
+			}
+		})
+	}
+}
+
+// TestWorkerPool_Diagnostics tests running job tracking for diagnostics dumps
+func TestWorkerPool_Diagnostics(t *testing.T) {
+	countPools := func() int {
+		n := 0
+		for _, component := range diagnostics.TakeDump().Components {
+			if _, ok := component.(WorkerPoolDiagnostics); ok {
+				n++
+			}
+		}
+		return n
+	}
+	before := countPools()
+
+	logger := log.NewBasicLogger(log.ErrorLevel)
+	pool := NewWorkerPool(2, logger)
+	pool.Start()
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	var repoLabel string
+	_ = pool.SubmitWithLabels(context.Background(), "stuck", map[string]string{"repository": "app"}, func(ctx context.Context) error {
+		repoLabel, _ = pprof.Label(ctx, "repository")
+		close(started)
+		<-release
+		return nil
+	})
+	<-started
+
+	running := pool.RunningJobs()
+	if len(running) != 1 || running[0].JobID != "stuck" || running[0].Labels["repository"] != "app" {
+		t.Errorf("Unexpected running jobs: %+v", running)
+	}
+	if repoLabel != "app" {
+		t.Errorf("Expected task to carry pprof label repository=app, got %q", repoLabel)
+	}
+
+	dump := diagnostics.TakeDump()
+	found := false
+	for _, component := range dump.Components {
+		if d, ok := component.(WorkerPoolDiagnostics); ok && len(d.Running) == 1 && d.Running[0].JobID == "stuck" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expected the pool in diagnostics dumps, got %+v", dump.Components)
+	}
+
+	close(release)
+	pool.Wait()
+
+	if running := pool.RunningJobs(); len(running) != 0 {
+		t.Errorf("Expected no running jobs after Wait, got %+v", running)
+	}
+	if after := countPools(); after != before {
+		t.Errorf("Expected the pool to leave diagnostics dumps after Wait, %d pools before and %d after", before, after)
+	}
+}
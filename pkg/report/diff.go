@@ -0,0 +1,330 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"freightliner/pkg/helper/errors"
+)
+
+// Outcomes of a copy when comparing reports; an empty outcome means the run
+// did not plan the copy at all
+const (
+	OutcomeCopied  = "copied"
+	OutcomeFailed  = "failed"
+	OutcomeSkipped = "skipped"
+)
+
+// regressiveCounters are summary counters whose increase is a regression
+var regressiveCounters = []string{"_failed", "errors", "retries_denied"}
+
+// progressCounters are summary counters whose decrease is a regression
+var progressCounters = []string{"tags_copied", "layers_copied", "images_succeeded", "repositories_replicated"}
+
+// Diff compares two run reports, typically the same run before and after a
+// configuration or version change. Every list is sorted, so comparing the same
+// reports always gives the same diff.
+type Diff struct {
+	Base RunSummary `json:"base"`
+	Head RunSummary `json:"head"`
+
+	// Copies lists every copy whose outcome differs between the runs
+	Copies []CopyChange `json:"copies"`
+
+	// Counters lists every summary counter that differs
+	Counters []CounterChange `json:"counters"`
+
+	// APICalls lists every registry API call count that differs, named
+	// "<registry> <call>"
+	APICalls []CounterChange `json:"api_calls"`
+
+	// Regressions describes every change for the worse, in the order above
+	Regressions []string `json:"regressions"`
+}
+
+// RunSummary identifies one side of a diff
+type RunSummary struct {
+	JobID   string `json:"job_id"`
+	Command string `json:"command"`
+	Status  string `json:"status"`
+	Error   string `json:"error,omitempty"`
+}
+
+// CopyChange is a copy whose outcome differs between the runs
+type CopyChange struct {
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+	Base        string `json:"base"`
+	Head        string `json:"head"`
+
+	// Detail is the head run's error or skip reason, else the base run's
+	Detail     string `json:"detail,omitempty"`
+	Regression bool   `json:"regression,omitempty"`
+}
+
+// CounterChange is a counter that differs between the runs
+type CounterChange struct {
+	Name       string `json:"name"`
+	Base       int64  `json:"base"`
+	Head       int64  `json:"head"`
+	Regression bool   `json:"regression,omitempty"`
+}
+
+// Delta returns how much the counter changed from base to head
+func (c CounterChange) Delta() int64 {
+	return c.Head - c.Base
+}
+
+// Load reads a report written as report.json by a run
+func Load(path string) (*Report, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read report")
+	}
+	var r Report
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse report %s", path)
+	}
+	return &r, nil
+}
+
+// Compare returns the differences from the base run to the head run
+func Compare(base, head *Report) *Diff {
+	d := &Diff{
+		Base:     runSummary(base),
+		Head:     runSummary(head),
+		Copies:   compareCopies(base, head),
+		Counters: compareCounters(base.Summary, head.Summary, isCounterRegression),
+		APICalls: compareCounters(flattenAPICalls(base), flattenAPICalls(head), nil),
+	}
+
+	if head.Status == StatusFailed && base.Status != StatusFailed {
+		regression := "run failed"
+		if head.Error != "" {
+			regression += ": " + head.Error
+		}
+		d.Regressions = append(d.Regressions, regression)
+	}
+	for _, c := range d.Copies {
+		if c.Regression {
+			d.Regressions = append(d.Regressions, fmt.Sprintf("%s: %s -> %s", c.name(), outcomeName(c.Base), outcomeName(c.Head)))
+		}
+	}
+	for _, c := range d.Counters {
+		if c.Regression {
+			d.Regressions = append(d.Regressions, fmt.Sprintf("%s: %d -> %d", c.Name, c.Base, c.Head))
+		}
+	}
+	return d
+}
+
+// HasRegressions reports whether the head run did worse than the base run
+func (d *Diff) HasRegressions() bool {
+	return len(d.Regressions) > 0
+}
+
+// WriteText writes the diff for people to review
+func (d *Diff) WriteText(out io.Writer) error {
+	fmt.Fprintf(out, "Base: %s\nHead: %s\n", d.Base, d.Head)
+
+	if len(d.Regressions) > 0 {
+		fmt.Fprintf(out, "\nRegressions (%d):\n", len(d.Regressions))
+		for _, regression := range d.Regressions {
+			fmt.Fprintf(out, "  ! %s\n", regression)
+		}
+	}
+
+	if len(d.Copies) > 0 {
+		fmt.Fprintf(out, "\nCopies (%d changed):\n", len(d.Copies))
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "  COPY\tBASE\tHEAD\tDETAIL")
+		for _, c := range d.Copies {
+			marker := " "
+			if c.Regression {
+				marker = "!"
+			}
+			fmt.Fprintf(w, "%s %s\t%s\t%s\t%s\n", marker, c.name(), outcomeName(c.Base), outcomeName(c.Head), c.Detail)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+
+	for _, section := range []struct {
+		title    string
+		counters []CounterChange
+	}{{"Summary", d.Counters}, {"Registry API calls", d.APICalls}} {
+		if len(section.counters) == 0 {
+			continue
+		}
+		fmt.Fprintf(out, "\n%s:\n", section.title)
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', tabwriter.AlignRight)
+		for _, c := range section.counters {
+			marker := " "
+			if c.Regression {
+				marker = "!"
+			}
+			fmt.Fprintf(w, "%s %s\t%d\t%d\t%+d\t\n", marker, c.Name, c.Base, c.Head, c.Delta())
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+
+	if len(d.Regressions) == 0 && len(d.Copies) == 0 && len(d.Counters) == 0 && len(d.APICalls) == 0 {
+		fmt.Fprintln(out, "\nNo differences")
+	}
+	return nil
+}
+
+// String describes one side of a diff
+func (s RunSummary) String() string {
+	return fmt.Sprintf("%s %s (%s)", s.Command, s.JobID, s.Status)
+}
+
+// name returns the copy as "source -> destination", or the source alone for
+// runs like prune that have no destination
+func (c CopyChange) name() string {
+	if c.Destination == "" {
+		return c.Source
+	}
+	return c.Source + " -> " + c.Destination
+}
+
+// outcomeName names an outcome for display
+func outcomeName(outcome string) string {
+	if outcome == "" {
+		return "not planned"
+	}
+	return outcome
+}
+
+// runSummary returns the identity of r
+func runSummary(r *Report) RunSummary {
+	return RunSummary{JobID: r.JobID, Command: r.Command, Status: r.Status, Error: r.Error}
+}
+
+// copyKey identifies a copy across runs
+type copyKey struct {
+	source      string
+	destination string
+}
+
+// copyOutcome is what a run did with a copy
+type copyOutcome struct {
+	outcome string
+	detail  string
+}
+
+// outcomes returns what r did with every copy it planned, failed or skipped
+func outcomes(r *Report) map[copyKey]copyOutcome {
+	result := make(map[copyKey]copyOutcome, len(r.Plan))
+	for _, item := range r.Plan {
+		result[copyKey{item.Source, item.Destination}] = copyOutcome{outcome: OutcomeCopied}
+	}
+	for _, skip := range r.Skipped {
+		result[copyKey{skip.Source, skip.Destination}] = copyOutcome{outcome: OutcomeSkipped, detail: skip.Reason}
+	}
+	for _, failure := range r.Failures {
+		result[copyKey{failure.Source, failure.Destination}] = copyOutcome{outcome: OutcomeFailed, detail: failure.Error}
+	}
+	return result
+}
+
+// compareCopies returns the copies whose outcome differs. Copies that start
+// failing and copies the head run no longer makes are regressions.
+func compareCopies(base, head *Report) []CopyChange {
+	baseOutcomes, headOutcomes := outcomes(base), outcomes(head)
+	keys := make(map[copyKey]bool, len(baseOutcomes)+len(headOutcomes))
+	for key := range baseOutcomes {
+		keys[key] = true
+	}
+	for key := range headOutcomes {
+		keys[key] = true
+	}
+
+	var changes []CopyChange
+	for key := range keys {
+		before, after := baseOutcomes[key], headOutcomes[key]
+		if before.outcome == after.outcome {
+			continue
+		}
+		detail := after.detail
+		if detail == "" {
+			detail = before.detail
+		}
+		changes = append(changes, CopyChange{
+			Source:      key.source,
+			Destination: key.destination,
+			Base:        before.outcome,
+			Head:        after.outcome,
+			Detail:      detail,
+			Regression:  after.outcome == OutcomeFailed || (before.outcome == OutcomeCopied && after.outcome == ""),
+		})
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Source != changes[j].Source {
+			return changes[i].Source < changes[j].Source
+		}
+		return changes[i].Destination < changes[j].Destination
+	})
+	return changes
+}
+
+// compareCounters returns the counters that differ, by name, marking those
+// regression reports as regressions
+func compareCounters(base, head map[string]int64, regression func(name string, before, after int64) bool) []CounterChange {
+	names := make(map[string]bool, len(base)+len(head))
+	for name := range base {
+		names[name] = true
+	}
+	for name := range head {
+		names[name] = true
+	}
+
+	var changes []CounterChange
+	for name := range names {
+		if base[name] == head[name] {
+			continue
+		}
+		change := CounterChange{Name: name, Base: base[name], Head: head[name]}
+		if regression != nil {
+			change.Regression = regression(name, change.Base, change.Head)
+		}
+		changes = append(changes, change)
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Name < changes[j].Name })
+	return changes
+}
+
+// isCounterRegression reports whether a summary counter changed for the worse
+func isCounterRegression(name string, before, after int64) bool {
+	for _, suffix := range regressiveCounters {
+		if strings.HasSuffix(name, suffix) {
+			return after > before
+		}
+	}
+	for _, counter := range progressCounters {
+		if name == counter {
+			return after < before
+		}
+	}
+	return false
+}
+
+// flattenAPICalls returns the API call counts of r keyed "<registry> <call>"
+func flattenAPICalls(r *Report) map[string]int64 {
+	flat := make(map[string]int64)
+	for registry, calls := range r.APICalls {
+		for call, n := range calls {
+			flat[registry+" "+call] = n
+		}
+	}
+	return flat
+}
@@ -0,0 +1,137 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"freightliner/pkg/helper/apicalls"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stagingRuns returns reports of a sync before and after an upgrade
+func stagingRuns() (*Report, *Report) {
+	base := New("sync", "docker.io", "mirror.example.com")
+	for _, tag := range []string{"1.24", "1.25", "1.26"} {
+		base.AddPlanned("docker.io/library/nginx:"+tag, "mirror.example.com/library/nginx:"+tag)
+	}
+	base.AddPlanned("docker.io/library/redis:7", "mirror.example.com/library/redis:7")
+	base.AddFailure("docker.io/library/redis:7", "mirror.example.com/library/redis:7", errors.New("manifest unknown"))
+	base.SetSummary("images_failed", 1)
+	base.SetSummary("bytes_copied", 3000)
+	base.APICalls = apicalls.Counts{"docker.io": {apicalls.CallManifestGet: 4}}
+	base.Finish(nil)
+
+	head := New("sync", "docker.io", "mirror.example.com")
+	head.AddPlanned("docker.io/library/nginx:1.24", "mirror.example.com/library/nginx:1.24")
+	head.AddPlanned("docker.io/library/nginx:1.25", "mirror.example.com/library/nginx:1.25")
+	head.AddFailure("docker.io/library/nginx:1.25", "mirror.example.com/library/nginx:1.25", errors.New("denied"))
+	head.AddPlanned("docker.io/library/redis:7", "mirror.example.com/library/redis:7")
+	head.SetSummary("images_failed", 1)
+	head.SetSummary("bytes_copied", 2500)
+	head.APICalls = apicalls.Counts{"docker.io": {apicalls.CallManifestGet: 3}}
+	head.Finish(nil)
+	return base, head
+}
+
+func TestCompare(t *testing.T) {
+	base, head := stagingRuns()
+	diff := Compare(base, head)
+
+	require.Len(t, diff.Copies, 3)
+	assert.Equal(t, CopyChange{
+		Source:      "docker.io/library/nginx:1.25",
+		Destination: "mirror.example.com/library/nginx:1.25",
+		Base:        OutcomeCopied,
+		Head:        OutcomeFailed,
+		Detail:      "denied",
+		Regression:  true,
+	}, diff.Copies[0])
+	assert.Equal(t, "docker.io/library/nginx:1.26", diff.Copies[1].Source)
+	assert.Equal(t, "", diff.Copies[1].Head, "a copy the head run no longer plans")
+	assert.True(t, diff.Copies[1].Regression)
+	assert.Equal(t, OutcomeFailed, diff.Copies[2].Base)
+	assert.Equal(t, OutcomeCopied, diff.Copies[2].Head)
+	assert.False(t, diff.Copies[2].Regression, "a fixed copy is not a regression")
+
+	assert.Equal(t, []CounterChange{{Name: "bytes_copied", Base: 3000, Head: 2500}}, diff.Counters,
+		"unchanged counters are left out")
+	assert.Equal(t, []CounterChange{{Name: "docker.io manifest_get", Base: 4, Head: 3}}, diff.APICalls)
+
+	assert.Equal(t, []string{
+		"docker.io/library/nginx:1.25 -> mirror.example.com/library/nginx:1.25: copied -> failed",
+		"docker.io/library/nginx:1.26 -> mirror.example.com/library/nginx:1.26: copied -> not planned",
+	}, diff.Regressions)
+	assert.True(t, diff.HasRegressions())
+}
+
+func TestCompareRegressiveCounters(t *testing.T) {
+	base := New("replicate-tree", "ecr", "gcr")
+	base.SetSummary("tags_copied", 10)
+	base.SetSummary("repositories_failed", 0)
+	base.Finish(nil)
+	head := New("replicate-tree", "ecr", "gcr")
+	head.SetSummary("tags_copied", 8)
+	head.SetSummary("repositories_failed", 2)
+	head.Finish(errors.New("2 repositories failed"))
+
+	diff := Compare(base, head)
+	assert.Equal(t, []string{
+		"run failed: 2 repositories failed",
+		"repositories_failed: 0 -> 2",
+		"tags_copied: 10 -> 8",
+	}, diff.Regressions)
+
+	assert.False(t, Compare(head, base).HasRegressions(), "the reverse comparison is an improvement")
+	assert.False(t, Compare(base, base).HasRegressions())
+}
+
+func TestCompareIsDeterministic(t *testing.T) {
+	base, head := stagingRuns()
+	first, err := json.Marshal(Compare(base, head))
+	require.NoError(t, err)
+	for i := 0; i < 10; i++ {
+		again, err := json.Marshal(Compare(base, head))
+		require.NoError(t, err)
+		assert.Equal(t, string(first), string(again))
+	}
+}
+
+func TestDiffWriteText(t *testing.T) {
+	base, head := stagingRuns()
+	var out bytes.Buffer
+	require.NoError(t, Compare(base, head).WriteText(&out))
+
+	text := out.String()
+	assert.Contains(t, text, "Regressions (2):")
+	assert.Contains(t, text, "! docker.io/library/nginx:1.25 -> mirror.example.com/library/nginx:1.25")
+	assert.Contains(t, text, "Summary:")
+	assert.Contains(t, text, "-500")
+
+	out.Reset()
+	require.NoError(t, Compare(base, base).WriteText(&out))
+	assert.Contains(t, out.String(), "No differences")
+}
+
+func TestLoad(t *testing.T) {
+	base, _ := stagingRuns()
+	artifacts, err := base.Artifacts()
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "report.json")
+	require.NoError(t, os.WriteFile(path, artifacts[ArtifactReport], 0600))
+
+	loaded, err := Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, base.JobID, loaded.JobID)
+	assert.Len(t, loaded.Failures, 1)
+	assert.False(t, Compare(base, loaded).HasRegressions())
+
+	require.NoError(t, os.WriteFile(path, []byte("not json"), 0600))
+	_, err = Load(path)
+	assert.Error(t, err)
+}
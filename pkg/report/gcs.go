@@ -0,0 +1,75 @@
+package report
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"freightliner/pkg/helper/errors"
+
+	"golang.org/x/oauth2/google"
+)
+
+// gcsUploadEndpoint is the JSON API media upload endpoint
+const gcsUploadEndpoint = "https://storage.googleapis.com/upload/storage/v1"
+
+// GCSUploader writes objects to a Google Cloud Storage bucket
+type GCSUploader struct {
+	bucket     string
+	endpoint   string
+	httpClient *http.Client
+}
+
+// NewGCSUploader creates an uploader using application default credentials
+func NewGCSUploader(ctx context.Context, bucket string) (*GCSUploader, error) {
+	client, err := google.DefaultClient(ctx, "https://www.googleapis.com/auth/devstorage.read_write")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load Google credentials")
+	}
+
+	return NewGCSUploaderWithClient(bucket, "", client), nil
+}
+
+// NewGCSUploaderWithClient creates an uploader with an already authenticated HTTP client.
+// An empty endpoint selects the public GCS upload endpoint.
+func NewGCSUploaderWithClient(bucket, endpoint string, client *http.Client) *GCSUploader {
+	if endpoint == "" {
+		endpoint = gcsUploadEndpoint
+	}
+
+	return &GCSUploader{
+		bucket:     bucket,
+		endpoint:   strings.TrimRight(endpoint, "/"),
+		httpClient: client,
+	}
+}
+
+// Upload stores data under key
+func (u *GCSUploader) Upload(ctx context.Context, key string, data []byte, contentType string) error {
+	query := url.Values{}
+	query.Set("uploadType", "media")
+	query.Set("name", key)
+	uploadURL := u.endpoint + "/b/" + url.PathEscape(u.bucket) + "/o?" + query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, uploadURL, bytes.NewReader(data))
+	if err != nil {
+		return errors.Wrap(err, "failed to create GCS request")
+	}
+	req.Header.Set("Content-Type", contentType)
+
+	resp, err := u.httpClient.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "GCS upload failed")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return errors.Newf("GCS upload of gs://%s/%s returned %s: %s", u.bucket, key, resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	return nil
+}
@@ -0,0 +1,298 @@
+// Package report builds machine-readable run reports and publishes them to object storage.
+package report
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"freightliner/pkg/attestation"
+	"freightliner/pkg/copy"
+	"freightliner/pkg/helper/apicalls"
+	"freightliner/pkg/resilience"
+
+	"github.com/google/uuid"
+)
+
+// Run statuses recorded in a report
+const (
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+// Artifact names produced for every report
+const (
+	ArtifactReport   = "report"
+	ArtifactPlan     = "plan"
+	ArtifactFailures = "failures"
+)
+
+// ArtifactAttestation is produced only when a run attestation was attached
+const ArtifactAttestation = "attestation"
+
+// ArtifactLayerReuse is produced only when layer reuse was recorded
+const ArtifactLayerReuse = "layer_reuse"
+
+// Failure categories recorded in a report
+const (
+	FailureCategoryError                = "error"
+	FailureCategoryRetryBudgetExhausted = "retry_budget_exhausted"
+)
+
+// Report describes a single replication run
+type Report struct {
+	JobID       string           `json:"job_id"`
+	RunID       string           `json:"run_id,omitempty"`
+	Command     string           `json:"command"`
+	Source      string           `json:"source"`
+	Destination string           `json:"destination"`
+	DryRun      bool             `json:"dry_run"`
+	Status      string           `json:"status"`
+	Error       string           `json:"error,omitempty"`
+	StartedAt   time.Time        `json:"started_at"`
+	FinishedAt  time.Time        `json:"finished_at"`
+	Summary     map[string]int64 `json:"summary"`
+	Plan        []PlanItem       `json:"plan"`
+	Failures    []Failure        `json:"failures"`
+	Skipped     []Skip           `json:"skipped"`
+
+	// APICalls counts the registry API calls of the run by registry and
+	// call type, and RuleAPICalls those of each sync rule
+	APICalls     apicalls.Counts            `json:"api_calls,omitempty"`
+	RuleAPICalls map[string]apicalls.Counts `json:"rule_api_calls,omitempty"`
+
+	mu          sync.Mutex
+	ledger      *attestation.Ledger
+	attestation []byte
+	layerReuse  *copy.LayerReuse
+	channel     *attestation.Channel
+}
+
+// PlanItem is a single copy the run intended to perform
+type PlanItem struct {
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+}
+
+// Failure is a copy that did not complete
+type Failure struct {
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+	Category    string `json:"category"`
+	Error       string `json:"error"`
+}
+
+// Skip is a copy the run deliberately did not perform
+type Skip struct {
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+	Reason      string `json:"reason"`
+}
+
+// New creates a report for a run that is starting now
+func New(command, source, destination string) *Report {
+	return &Report{
+		JobID:       uuid.New().String(),
+		Command:     command,
+		Source:      source,
+		Destination: destination,
+		Status:      StatusRunning,
+		StartedAt:   time.Now().UTC(),
+		Summary:     make(map[string]int64),
+		Plan:        []PlanItem{},
+		Failures:    []Failure{},
+		Skipped:     []Skip{},
+	}
+}
+
+// AddPlanned records a copy the run intends to perform
+func (r *Report) AddPlanned(source, destination string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Plan = append(r.Plan, PlanItem{Source: source, Destination: destination})
+}
+
+// AddFailure records a copy that failed
+func (r *Report) AddFailure(source, destination string, err error) {
+	msg := "unknown error"
+	if err != nil {
+		msg = err.Error()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Failures = append(r.Failures, Failure{
+		Source:      source,
+		Destination: destination,
+		Category:    failureCategory(err),
+		Error:       msg,
+	})
+}
+
+// AddSkip records a copy that was skipped on purpose, e.g. by a policy
+func (r *Report) AddSkip(source, destination, reason string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Skipped = append(r.Skipped, Skip{Source: source, Destination: destination, Reason: reason})
+}
+
+// failureCategory classifies a failure so retry budget exhaustion can be told
+// apart from ordinary copy errors
+func failureCategory(err error) string {
+	if resilience.IsRetryBudgetExhausted(err) {
+		return FailureCategoryRetryBudgetExhausted
+	}
+	return FailureCategoryError
+}
+
+// RecordRetryBudget adds retry budget usage to the summary
+func (r *Report) RecordRetryBudget(budget *resilience.RetryBudget) {
+	if budget == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Summary["retries"] = budget.Used()
+	r.Summary["retries_denied"] = budget.Denied()
+}
+
+// RecordAPICalls adds the registry API calls counted for the run
+func (r *Report) RecordAPICalls(calls *apicalls.Counter) {
+	if calls == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.APICalls = calls.Counts()
+	r.Summary["api_calls"] = r.APICalls.Total()
+}
+
+// RecordRuleAPICalls adds the registry API calls counted for each rule
+func (r *Report) RecordRuleAPICalls(rules *apicalls.Group) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.RuleAPICalls = rules.Counts()
+}
+
+// AttachLedger associates the run's transfer ledger with the report
+func (r *Report) AttachLedger(ledger *attestation.Ledger) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.ledger = ledger
+}
+
+// Ledger returns the attached transfer ledger, or nil when attestation is disabled
+func (r *Report) Ledger() *attestation.Ledger {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.ledger
+}
+
+// AttachLayerReuse associates the run's layer reuse collector with the report
+func (r *Report) AttachLayerReuse(reuse *copy.LayerReuse) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.layerReuse = reuse
+}
+
+// LayerReuse returns the attached layer reuse collector, or nil when the
+// layer reuse report is disabled
+func (r *Report) LayerReuse() *copy.LayerReuse {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.layerReuse
+}
+
+// AttachChannel marks the run as the mirror of a release channel, so its
+// attestation attests the channel
+func (r *Report) AttachChannel(channel *attestation.Channel) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.channel = channel
+}
+
+// Channel returns the mirrored release channel, or nil for other runs
+func (r *Report) Channel() *attestation.Channel {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.channel
+}
+
+// RunInfo describes the run for its attestation
+func (r *Report) RunInfo() attestation.RunInfo {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return attestation.RunInfo{
+		JobID:       r.JobID,
+		Command:     r.Command,
+		Source:      r.Source,
+		Destination: r.Destination,
+		Status:      r.Status,
+		StartedAt:   r.StartedAt,
+		FinishedAt:  r.FinishedAt,
+	}
+}
+
+// SetAttestation stores the rendered attestation so it is published with the report
+func (r *Report) SetAttestation(data []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.attestation = data
+}
+
+// SetSummary sets a summary counter
+func (r *Report) SetSummary(name string, value int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Summary[name] = value
+}
+
+// Finish marks the run as complete; a nil error with recorded failures still fails the run
+func (r *Report) Finish(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.FinishedAt = time.Now().UTC()
+	switch {
+	case err != nil:
+		r.Status = StatusFailed
+		r.Error = err.Error()
+	case len(r.Failures) > 0:
+		r.Status = StatusFailed
+	default:
+		r.Status = StatusSucceeded
+	}
+}
+
+// Artifacts renders the report, plan and failure list as JSON documents keyed by
+// artifact name, plus the attestation and layer reuse when they were recorded
+func (r *Report) Artifacts() (map[string][]byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	documents := map[string]interface{}{
+		ArtifactReport:   r,
+		ArtifactPlan:     r.Plan,
+		ArtifactFailures: r.Failures,
+	}
+	if r.layerReuse != nil {
+		documents[ArtifactLayerReuse] = r.layerReuse.Report()
+	}
+
+	artifacts := make(map[string][]byte, len(documents))
+	for name, doc := range documents {
+		data, err := json.MarshalIndent(doc, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		artifacts[name] = data
+	}
+	if r.attestation != nil {
+		artifacts[ArtifactAttestation] = r.attestation
+	}
+
+	return artifacts, nil
+}
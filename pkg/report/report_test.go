@@ -0,0 +1,295 @@
+package report
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"freightliner/pkg/attestation"
+	"freightliner/pkg/copy"
+	"freightliner/pkg/helper/apicalls"
+	"freightliner/pkg/resilience"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeUploader struct {
+	objects map[string][]byte
+	err     error
+}
+
+func (f *fakeUploader) Upload(_ context.Context, key string, data []byte, _ string) error {
+	if f.err != nil {
+		return f.err
+	}
+	if f.objects == nil {
+		f.objects = make(map[string][]byte)
+	}
+	f.objects[key] = data
+	return nil
+}
+
+func TestReportFinish(t *testing.T) {
+	r := New("sync", "ecr", "gcr")
+	r.AddPlanned("ecr/app:v1", "gcr/app:v1")
+	r.Finish(nil)
+	assert.Equal(t, StatusSucceeded, r.Status)
+
+	r = New("sync", "ecr", "gcr")
+	r.AddFailure("ecr/app:v1", "gcr/app:v1", errors.New("boom"))
+	r.Finish(nil)
+	assert.Equal(t, StatusFailed, r.Status)
+
+	r = New("replicate", "a", "b")
+	r.Finish(errors.New("auth failed"))
+	assert.Equal(t, StatusFailed, r.Status)
+	assert.Equal(t, "auth failed", r.Error)
+}
+
+func TestReportArtifacts(t *testing.T) {
+	r := New("sync", "ecr", "gcr")
+	r.AddPlanned("ecr/app:v1", "gcr/app:v1")
+	r.AddFailure("ecr/app:v2", "gcr/app:v2", errors.New("boom"))
+	r.SetSummary("copied", 1)
+	r.Finish(nil)
+
+	artifacts, err := r.Artifacts()
+	require.NoError(t, err)
+	require.Len(t, artifacts, 3)
+
+	var failures []Failure
+	require.NoError(t, json.Unmarshal(artifacts[ArtifactFailures], &failures))
+	require.Len(t, failures, 1)
+	assert.Equal(t, "boom", failures[0].Error)
+	assert.Equal(t, FailureCategoryError, failures[0].Category)
+
+	var decoded map[string]interface{}
+	require.NoError(t, json.Unmarshal(artifacts[ArtifactReport], &decoded))
+	assert.Equal(t, r.JobID, decoded["job_id"])
+	assert.Equal(t, StatusFailed, decoded["status"])
+}
+
+func TestReportSkips(t *testing.T) {
+	r := New("sync", "ecr", "harbor")
+	r.AddSkip("ecr/app:old", "harbor/app:old", "media type application/vnd.docker.distribution.manifest.v1+prettyjws is denied by application/vnd.docker.distribution.manifest.v1+*")
+	r.Finish(nil)
+	assert.Equal(t, StatusSucceeded, r.Status)
+
+	artifacts, err := r.Artifacts()
+	require.NoError(t, err)
+
+	var decoded Report
+	require.NoError(t, json.Unmarshal(artifacts[ArtifactReport], &decoded))
+	require.Len(t, decoded.Skipped, 1)
+	assert.Equal(t, "harbor/app:old", decoded.Skipped[0].Destination)
+	assert.Contains(t, decoded.Skipped[0].Reason, "is denied by")
+}
+
+func TestReportRetryBudget(t *testing.T) {
+	budget := resilience.NewRetryBudget(1)
+	budget.Allow()
+	budget.Allow()
+
+	r := New("sync", "ecr", "gcr")
+	r.AddFailure("ecr/app:v1", "gcr/app:v1", fmt.Errorf("failed to copy image: %w", resilience.ErrRetryBudgetExhausted))
+	r.AddFailure("ecr/app:v2", "gcr/app:v2", errors.New("manifest unknown"))
+	r.RecordRetryBudget(budget)
+
+	assert.Equal(t, FailureCategoryRetryBudgetExhausted, r.Failures[0].Category)
+	assert.Equal(t, FailureCategoryError, r.Failures[1].Category)
+	assert.Equal(t, int64(1), r.Summary["retries"])
+	assert.Equal(t, int64(1), r.Summary["retries_denied"])
+}
+
+func TestReportAPICalls(t *testing.T) {
+	run := apicalls.NewCounter()
+	rules := apicalls.NewGroup()
+	for _, counter := range []*apicalls.Counter{run, rules.Counter("library/nginx")} {
+		counter.Add("123.dkr.ecr.us-east-1.amazonaws.com", apicalls.CallManifestHead)
+		counter.Add("123.dkr.ecr.us-east-1.amazonaws.com", apicalls.CallBlobPut)
+	}
+	run.Add("123.dkr.ecr.us-east-1.amazonaws.com", apicalls.CallTagList)
+
+	r := New("sync", "docker.io", "123.dkr.ecr.us-east-1.amazonaws.com")
+	r.RecordAPICalls(run)
+	r.RecordRuleAPICalls(rules)
+
+	assert.Equal(t, int64(3), r.Summary["api_calls"])
+	assert.Equal(t, int64(2), r.RuleAPICalls["library/nginx"].Total())
+
+	artifacts, err := r.Artifacts()
+	require.NoError(t, err)
+	var doc map[string]interface{}
+	require.NoError(t, json.Unmarshal(artifacts[ArtifactReport], &doc))
+	assert.Equal(t, map[string]interface{}{
+		"123.dkr.ecr.us-east-1.amazonaws.com": map[string]interface{}{"manifest_head": 1.0, "blob_put": 1.0, "tag_list": 1.0},
+	}, doc["api_calls"])
+}
+
+func TestReportAttestation(t *testing.T) {
+	r := New("replicate", "ecr", "gcr")
+	assert.Nil(t, r.Ledger())
+
+	ledger := attestation.NewLedger()
+	r.AttachLedger(ledger)
+	assert.Same(t, ledger, r.Ledger())
+
+	r.Finish(nil)
+	run := r.RunInfo()
+	assert.Equal(t, r.JobID, run.JobID)
+	assert.Equal(t, StatusSucceeded, run.Status)
+
+	r.SetAttestation([]byte(`{"payloadType":"application/vnd.in-toto+json"}`))
+	artifacts, err := r.Artifacts()
+	require.NoError(t, err)
+	assert.Len(t, artifacts, 4)
+
+	uploader := &fakeUploader{}
+	p, err := NewPublisherWithUploader(uploader, "", "")
+	require.NoError(t, err)
+	keys, err := p.Publish(context.Background(), r)
+	require.NoError(t, err)
+	assert.Len(t, keys, 4)
+}
+
+func TestReportLayerReuse(t *testing.T) {
+	r := New("replicate-tree", "ecr/prod", "gcr.io/prod")
+	artifacts, err := r.Artifacts()
+	require.NoError(t, err)
+	assert.NotContains(t, artifacts, ArtifactLayerReuse)
+
+	reuse := copy.NewLayerReuse()
+	src, err := name.NewTag("ecr.example.com/prod/app:v1")
+	require.NoError(t, err)
+	dst, err := name.NewTag("gcr.io/prod/app:v1")
+	require.NoError(t, err)
+	reuse.Record(src, dst, copy.CopyStats{
+		Layers:         3,
+		LayersUploaded: 1,
+		MountedFrom:    []copy.MountSource{{Repository: "gcr.io/prod/base", Layers: 2, Bytes: 2048}},
+		BytesReused:    2048,
+	})
+	r.AttachLayerReuse(reuse)
+	assert.Same(t, reuse, r.LayerReuse())
+
+	artifacts, err = r.Artifacts()
+	require.NoError(t, err)
+	var decoded copy.LayerReuseReport
+	require.NoError(t, json.Unmarshal(artifacts[ArtifactLayerReuse], &decoded))
+	require.Len(t, decoded.Images, 1)
+	assert.Equal(t, 2, decoded.Mounted)
+	assert.Equal(t, "gcr.io/prod/base", decoded.MountSources[0].Repository)
+}
+
+func TestParseBucketURL(t *testing.T) {
+	scheme, bucket, prefix, err := ParseBucketURL("s3://audit-bucket/freightliner/reports/")
+	require.NoError(t, err)
+	assert.Equal(t, "s3", scheme)
+	assert.Equal(t, "audit-bucket", bucket)
+	assert.Equal(t, "freightliner/reports", prefix)
+
+	_, bucket, prefix, err = ParseBucketURL("gs://audit-bucket")
+	require.NoError(t, err)
+	assert.Equal(t, "audit-bucket", bucket)
+	assert.Empty(t, prefix)
+
+	for _, bad := range []string{"https://bucket/x", "s3:///prefix", "bucket/prefix"} {
+		_, _, _, err := ParseBucketURL(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestPublisherObjectKey(t *testing.T) {
+	r := New("replicate-tree", "a", "b")
+	r.StartedAt = time.Date(2024, 3, 5, 14, 7, 9, 0, time.UTC)
+
+	p, err := NewPublisherWithUploader(&fakeUploader{}, "reports", "")
+	require.NoError(t, err)
+	key, err := p.ObjectKey(r, ArtifactPlan)
+	require.NoError(t, err)
+	assert.Equal(t, "reports/2024-03-05/"+r.JobID+"/plan.json", key)
+
+	// Reports of a run are filed under its run ID
+	r.RunID = "0192f2c4-run"
+	key, err = p.ObjectKey(r, ArtifactPlan)
+	require.NoError(t, err)
+	assert.Equal(t, "reports/2024-03-05/0192f2c4-run/plan.json", key)
+
+	p, err = NewPublisherWithUploader(&fakeUploader{}, "", "{{.Command}}/{{.Date}}-{{.Time}}-{{.Name}}.json")
+	require.NoError(t, err)
+	key, err = p.ObjectKey(r, ArtifactReport)
+	require.NoError(t, err)
+	assert.Equal(t, "replicate-tree/2024-03-05-140709-report.json", key)
+
+	_, err = NewPublisherWithUploader(&fakeUploader{}, "", "{{.Date")
+	assert.Error(t, err)
+}
+
+func TestPublisherPublish(t *testing.T) {
+	r := New("sync", "ecr", "gcr")
+	r.Finish(nil)
+
+	uploader := &fakeUploader{}
+	p, err := NewPublisherWithUploader(uploader, "audit", "")
+	require.NoError(t, err)
+
+	keys, err := p.Publish(context.Background(), r)
+	require.NoError(t, err)
+	assert.Len(t, keys, 3)
+	for _, key := range keys {
+		assert.Contains(t, uploader.objects, key)
+		assert.True(t, strings.HasPrefix(key, "audit/"))
+	}
+
+	p, err = NewPublisherWithUploader(&fakeUploader{err: errors.New("denied")}, "", "")
+	require.NoError(t, err)
+	_, err = p.Publish(context.Background(), r)
+	assert.Error(t, err)
+}
+
+func TestS3UploaderSignsRequest(t *testing.T) {
+	var gotPath, gotAuth, gotBody string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		gotPath = req.URL.Path
+		gotAuth = req.Header.Get("Authorization")
+		body, _ := io.ReadAll(req.Body)
+		gotBody = string(body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	creds := aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+		return aws.Credentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "secret"}, nil
+	})
+	uploader := NewS3UploaderWithCredentials("bucket", "us-east-1", server.URL, creds)
+
+	err := uploader.Upload(context.Background(), "reports/run.json", []byte(`{}`), "application/json")
+	require.NoError(t, err)
+	assert.Equal(t, "/reports/run.json", gotPath)
+	assert.Equal(t, "{}", gotBody)
+	assert.True(t, strings.HasPrefix(gotAuth, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/"))
+	assert.Contains(t, gotAuth, "/us-east-1/s3/aws4_request")
+}
+
+func TestGCSUploaderError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "/b/bucket/o", req.URL.Path)
+		assert.Equal(t, "reports/run.json", req.URL.Query().Get("name"))
+		http.Error(w, "forbidden", http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	uploader := NewGCSUploaderWithClient("bucket", server.URL, server.Client())
+	err := uploader.Upload(context.Background(), "reports/run.json", []byte(`{}`), "application/json")
+	assert.Error(t, err)
+}
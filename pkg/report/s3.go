@@ -0,0 +1,101 @@
+package report
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"freightliner/pkg/helper/errors"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+)
+
+// S3Uploader writes objects to an S3 bucket with SigV4-signed PUT requests
+type S3Uploader struct {
+	bucket      string
+	region      string
+	endpoint    string
+	credentials aws.CredentialsProvider
+	signer      *v4.Signer
+	httpClient  *http.Client
+}
+
+// NewS3Uploader creates an uploader using the default AWS credential chain
+func NewS3Uploader(ctx context.Context, bucket, region string) (*S3Uploader, error) {
+	var configOpts []func(*awsconfig.LoadOptions) error
+	if region != "" {
+		configOpts = append(configOpts, awsconfig.WithRegion(region))
+	}
+
+	cfg, err := awsconfig.LoadDefaultConfig(ctx, configOpts...)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load AWS configuration")
+	}
+	if cfg.Region == "" {
+		return nil, errors.InvalidInputf("AWS region is required to upload reports to S3")
+	}
+
+	return NewS3UploaderWithCredentials(bucket, cfg.Region, "", cfg.Credentials), nil
+}
+
+// NewS3UploaderWithCredentials creates an uploader with explicit credentials.
+// An empty endpoint selects the regional virtual-hosted S3 endpoint.
+func NewS3UploaderWithCredentials(bucket, region, endpoint string, credentials aws.CredentialsProvider) *S3Uploader {
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://%s.s3.%s.amazonaws.com", bucket, region)
+	}
+
+	return &S3Uploader{
+		bucket:      bucket,
+		region:      region,
+		endpoint:    strings.TrimRight(endpoint, "/"),
+		credentials: credentials,
+		signer:      v4.NewSigner(),
+		httpClient:  &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+// Upload stores data under key
+func (u *S3Uploader) Upload(ctx context.Context, key string, data []byte, contentType string) error {
+	creds, err := u.credentials.Retrieve(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to retrieve AWS credentials")
+	}
+
+	objectURL := u.endpoint + "/" + (&url.URL{Path: key}).EscapedPath()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, objectURL, bytes.NewReader(data))
+	if err != nil {
+		return errors.Wrap(err, "failed to create S3 request")
+	}
+
+	sum := sha256.Sum256(data)
+	payloadHash := hex.EncodeToString(sum[:])
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	if err := u.signer.SignHTTP(ctx, creds, req, payloadHash, "s3", u.region, time.Now().UTC()); err != nil {
+		return errors.Wrap(err, "failed to sign S3 request")
+	}
+
+	resp, err := u.httpClient.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "S3 upload failed")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return errors.Newf("S3 upload of s3://%s/%s returned %s: %s", u.bucket, key, resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	return nil
+}
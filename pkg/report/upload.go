@@ -0,0 +1,172 @@
+package report
+
+import (
+	"bytes"
+	"context"
+	"net/url"
+	"path"
+	"strings"
+	"text/template"
+	"time"
+
+	"freightliner/pkg/helper/errors"
+)
+
+// DefaultKeyTemplate is the object key used when none is configured
+const DefaultKeyTemplate = "{{.Date}}/{{.RunID}}/{{.Name}}.json"
+
+// Uploader stores a single object in a bucket
+type Uploader interface {
+	Upload(ctx context.Context, key string, data []byte, contentType string) error
+}
+
+// KeyData holds the values available to key templates
+type KeyData struct {
+	Date string
+	Time string
+
+	// RunID is the run ID of the command, or the job ID of reports without one
+	RunID   string
+	JobID   string
+	Command string
+	Name    string
+}
+
+// PublisherOptions configures a Publisher
+type PublisherOptions struct {
+	// URL is the destination bucket, e.g. s3://bucket/prefix or gs://bucket/prefix
+	URL string
+
+	// KeyTemplate is a text/template for object keys below the prefix
+	KeyTemplate string
+
+	// Region is the AWS region of an S3 bucket
+	Region string
+}
+
+// Publisher uploads report artifacts to object storage
+type Publisher struct {
+	uploader Uploader
+	prefix   string
+	keyTmpl  *template.Template
+}
+
+// NewPublisher creates a publisher for an s3:// or gs:// destination
+func NewPublisher(ctx context.Context, opts PublisherOptions) (*Publisher, error) {
+	scheme, bucket, prefix, err := ParseBucketURL(opts.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	var uploader Uploader
+	switch scheme {
+	case "s3":
+		uploader, err = NewS3Uploader(ctx, bucket, opts.Region)
+	case "gs":
+		uploader, err = NewGCSUploader(ctx, bucket)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return NewPublisherWithUploader(uploader, prefix, opts.KeyTemplate)
+}
+
+// NewPublisherWithUploader creates a publisher that writes through an existing uploader
+func NewPublisherWithUploader(uploader Uploader, prefix, keyTemplate string) (*Publisher, error) {
+	if uploader == nil {
+		return nil, errors.InvalidInputf("uploader is required")
+	}
+	if keyTemplate == "" {
+		keyTemplate = DefaultKeyTemplate
+	}
+
+	tmpl, err := template.New("key").Option("missingkey=error").Parse(keyTemplate)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid report key template")
+	}
+
+	return &Publisher{
+		uploader: uploader,
+		prefix:   strings.Trim(prefix, "/"),
+		keyTmpl:  tmpl,
+	}, nil
+}
+
+// ObjectKey renders the key for an artifact of the report
+func (p *Publisher) ObjectKey(r *Report, name string) (string, error) {
+	started := r.StartedAt
+	if started.IsZero() {
+		started = time.Now().UTC()
+	}
+
+	runID := r.RunID
+	if runID == "" {
+		runID = r.JobID
+	}
+
+	var buf bytes.Buffer
+	err := p.keyTmpl.Execute(&buf, KeyData{
+		Date:    started.Format("2006-01-02"),
+		Time:    started.Format("150405"),
+		RunID:   runID,
+		JobID:   r.JobID,
+		Command: r.Command,
+		Name:    name,
+	})
+	if err != nil {
+		return "", errors.Wrap(err, "failed to render report key")
+	}
+
+	key := strings.TrimLeft(buf.String(), "/")
+	if key == "" {
+		return "", errors.InvalidInputf("report key template rendered an empty key")
+	}
+	if p.prefix != "" {
+		key = path.Join(p.prefix, key)
+	}
+
+	return key, nil
+}
+
+// Publish uploads every artifact of the report and returns the keys written
+func (p *Publisher) Publish(ctx context.Context, r *Report) ([]string, error) {
+	artifacts, err := r.Artifacts()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to render report")
+	}
+
+	keys := make([]string, 0, len(artifacts))
+	for _, name := range []string{ArtifactReport, ArtifactPlan, ArtifactFailures, ArtifactAttestation} {
+		if _, ok := artifacts[name]; !ok {
+			continue
+		}
+		key, err := p.ObjectKey(r, name)
+		if err != nil {
+			return keys, err
+		}
+		if err := p.uploader.Upload(ctx, key, artifacts[name], "application/json"); err != nil {
+			return keys, errors.Wrapf(err, "failed to upload %s", key)
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+// ParseBucketURL splits s3://bucket/prefix or gs://bucket/prefix into its parts
+func ParseBucketURL(raw string) (scheme, bucket, prefix string, err error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", "", "", errors.Wrap(err, "invalid report upload URL")
+	}
+
+	if u.Scheme != "s3" && u.Scheme != "gs" {
+		return "", "", "", errors.InvalidInputf("report upload URL must start with s3:// or gs://, got %q", raw)
+	}
+	if u.Host == "" {
+		return "", "", "", errors.InvalidInputf("report upload URL %q has no bucket", raw)
+	}
+
+	return u.Scheme, u.Host, strings.Trim(u.Path, "/"), nil
+}
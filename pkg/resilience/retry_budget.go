@@ -0,0 +1,115 @@
+package resilience
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// ErrRetryBudgetExhausted is returned when a run has used up its retry budget
+var ErrRetryBudgetExhausted = errors.New("retry budget exhausted")
+
+// RetryBudget caps the total number of retries across a whole run so a
+// misbehaving registry cannot cause an unbounded retry storm.
+// A nil budget or a limit of zero allows unlimited retries.
+type RetryBudget struct {
+	limit  int64
+	used   atomic.Int64
+	denied atomic.Int64
+}
+
+// NewRetryBudget creates a budget allowing limit retries (0 = unlimited)
+func NewRetryBudget(limit int) *RetryBudget {
+	if limit < 0 {
+		limit = 0
+	}
+	return &RetryBudget{limit: int64(limit)}
+}
+
+// Allow consumes one retry and reports whether it may proceed
+func (b *RetryBudget) Allow() bool {
+	if b == nil {
+		return true
+	}
+
+	for {
+		used := b.used.Load()
+		if b.limit > 0 && used >= b.limit {
+			b.denied.Add(1)
+			return false
+		}
+		if b.used.CompareAndSwap(used, used+1) {
+			return true
+		}
+	}
+}
+
+// Limit returns the number of retries allowed (0 = unlimited)
+func (b *RetryBudget) Limit() int64 {
+	if b == nil {
+		return 0
+	}
+	return b.limit
+}
+
+// Used returns the number of retries performed so far
+func (b *RetryBudget) Used() int64 {
+	if b == nil {
+		return 0
+	}
+	return b.used.Load()
+}
+
+// Denied returns the number of retries refused because the budget ran out
+func (b *RetryBudget) Denied() int64 {
+	if b == nil {
+		return 0
+	}
+	return b.denied.Load()
+}
+
+// Exhausted reports whether any retry has been refused
+func (b *RetryBudget) Exhausted() bool {
+	return b.Denied() > 0
+}
+
+// IsRetryBudgetExhausted reports whether err was caused by an exhausted retry
+// budget. The message is also matched because some callers flatten errors
+// into strings before they reach the report.
+func IsRetryBudgetExhausted(err error) bool {
+	if err == nil {
+		return false
+	}
+	return errors.Is(err, ErrRetryBudgetExhausted) ||
+		strings.Contains(err.Error(), ErrRetryBudgetExhausted.Error())
+}
+
+// ParseRetryAfter converts a Retry-After header value, either delay seconds
+// or an HTTP date, into the time to wait from now
+func ParseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	at, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+
+	wait := at.Sub(now)
+	if wait < 0 {
+		wait = 0
+	}
+	return wait, true
+}
@@ -0,0 +1,149 @@
+package resilience
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"time"
+
+	"freightliner/pkg/helper/log"
+)
+
+// retryableStatusCodes are registry responses worth retrying
+var retryableStatusCodes = map[int]bool{
+	http.StatusRequestTimeout:      true,
+	http.StatusTooManyRequests:     true,
+	http.StatusInternalServerError: true,
+	http.StatusBadGateway:          true,
+	http.StatusServiceUnavailable:  true,
+	http.StatusGatewayTimeout:      true,
+}
+
+// RetryTransportOptions configures a RetryTransport
+type RetryTransportOptions struct {
+	// MaxRetries is the maximum number of retries per request
+	MaxRetries int
+	// InitialWait is the first backoff when the server sends no Retry-After
+	InitialWait time.Duration
+	// MaxWait caps the computed backoff; Retry-After values are honored as sent
+	MaxWait time.Duration
+	// Budget is shared by every request of a run; nil means unlimited
+	Budget *RetryBudget
+	// Logger receives retry and budget messages
+	Logger log.Logger
+}
+
+// DefaultRetryTransportOptions returns sensible defaults for registry traffic
+func DefaultRetryTransportOptions() RetryTransportOptions {
+	return RetryTransportOptions{
+		MaxRetries:  5,
+		InitialWait: 500 * time.Millisecond,
+		MaxWait:     30 * time.Second,
+	}
+}
+
+// RetryTransport retries throttled and failed registry responses, waiting
+// exactly as long as a Retry-After header asks and drawing every retry from
+// a shared budget
+type RetryTransport struct {
+	inner   http.RoundTripper
+	options RetryTransportOptions
+	now     func() time.Time
+}
+
+// NewRetryTransport wraps inner with budgeted retries
+func NewRetryTransport(inner http.RoundTripper, options RetryTransportOptions) *RetryTransport {
+	if inner == nil {
+		inner = http.DefaultTransport
+	}
+	if options.Logger == nil {
+		options.Logger = log.NewBasicLogger(log.InfoLevel)
+	}
+
+	return &RetryTransport{
+		inner:   inner,
+		options: options,
+		now:     time.Now,
+	}
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *RetryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		resp, err := t.inner.RoundTrip(req)
+		if err != nil || !retryableStatusCodes[resp.StatusCode] ||
+			attempt >= t.options.MaxRetries || !canReplay(req) {
+			return resp, err
+		}
+
+		wait := t.retryDelay(resp, attempt)
+		status := resp.StatusCode
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+
+		if !t.options.Budget.Allow() {
+			t.options.Logger.WithFields(map[string]interface{}{
+				"method": req.Method,
+				"url":    req.URL.Redacted(),
+				"status": status,
+				"limit":  t.options.Budget.Limit(),
+			}).Warn("Retry budget exhausted, giving up on request")
+			return nil, fmt.Errorf("%s %s returned %d: %w", req.Method, req.URL.Redacted(), status, ErrRetryBudgetExhausted)
+		}
+
+		t.options.Logger.WithFields(map[string]interface{}{
+			"method":  req.Method,
+			"url":     req.URL.Redacted(),
+			"status":  status,
+			"attempt": attempt + 1,
+			"wait":    wait.String(),
+		}).Debug("Retrying registry request")
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+
+		if req, err = rewind(req); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// retryDelay honors Retry-After when present and otherwise backs off exponentially
+func (t *RetryTransport) retryDelay(resp *http.Response, attempt int) time.Duration {
+	if wait, ok := ParseRetryAfter(resp.Header.Get("Retry-After"), t.now()); ok {
+		return wait
+	}
+
+	wait := float64(t.options.InitialWait) * math.Pow(2, float64(attempt))
+	if t.options.MaxWait > 0 && wait > float64(t.options.MaxWait) {
+		wait = float64(t.options.MaxWait)
+	}
+	return time.Duration(wait)
+}
+
+// canReplay reports whether the request body can be sent again
+func canReplay(req *http.Request) bool {
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// rewind returns a copy of req with a fresh body for the next attempt
+func rewind(req *http.Request) (*http.Request, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return req, nil
+	}
+
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, fmt.Errorf("failed to rewind request body: %w", err)
+	}
+
+	next := req.Clone(req.Context())
+	next.Body = body
+	return next, nil
+}
@@ -0,0 +1,150 @@
+package resilience
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"freightliner/pkg/helper/log"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	wait, ok := ParseRetryAfter("7", now)
+	assert.True(t, ok)
+	assert.Equal(t, 7*time.Second, wait)
+
+	wait, ok = ParseRetryAfter(now.Add(90*time.Second).Format(http.TimeFormat), now)
+	assert.True(t, ok)
+	assert.Equal(t, 90*time.Second, wait)
+
+	wait, ok = ParseRetryAfter(now.Add(-time.Minute).Format(http.TimeFormat), now)
+	assert.True(t, ok)
+	assert.Equal(t, time.Duration(0), wait)
+
+	for _, value := range []string{"", "-1", "soon"} {
+		_, ok = ParseRetryAfter(value, now)
+		assert.False(t, ok, value)
+	}
+}
+
+func TestRetryBudget(t *testing.T) {
+	budget := NewRetryBudget(2)
+	assert.True(t, budget.Allow())
+	assert.True(t, budget.Allow())
+	assert.False(t, budget.Allow())
+	assert.Equal(t, int64(2), budget.Used())
+	assert.Equal(t, int64(1), budget.Denied())
+	assert.True(t, budget.Exhausted())
+
+	unlimited := NewRetryBudget(0)
+	for i := 0; i < 100; i++ {
+		assert.True(t, unlimited.Allow())
+	}
+	assert.False(t, unlimited.Exhausted())
+
+	var none *RetryBudget
+	assert.True(t, none.Allow())
+	assert.Equal(t, int64(0), none.Used())
+}
+
+func TestRetryTransport_HonorsRetryAfter(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	budget := NewRetryBudget(10)
+	rt := NewRetryTransport(http.DefaultTransport, RetryTransportOptions{
+		MaxRetries:  3,
+		InitialWait: time.Millisecond,
+		Budget:      budget,
+		Logger:      log.NewBasicLogger(log.ErrorLevel),
+	})
+
+	start := time.Now()
+	resp, err := (&http.Client{Transport: rt}).Get(server.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, int32(2), calls.Load())
+	assert.Equal(t, int64(1), budget.Used())
+	// The one second Retry-After wins over the millisecond backoff
+	assert.GreaterOrEqual(t, time.Since(start), time.Second)
+}
+
+func TestRetryTransport_BudgetExhausted(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	budget := NewRetryBudget(2)
+	rt := NewRetryTransport(http.DefaultTransport, RetryTransportOptions{
+		MaxRetries:  10,
+		InitialWait: time.Millisecond,
+		Budget:      budget,
+		Logger:      log.NewBasicLogger(log.ErrorLevel),
+	})
+
+	_, err := (&http.Client{Transport: rt}).Get(server.URL)
+	require.Error(t, err)
+	assert.ErrorIs(t, err, ErrRetryBudgetExhausted)
+	assert.True(t, IsRetryBudgetExhausted(err))
+	assert.Equal(t, int32(3), calls.Load())
+	assert.True(t, budget.Exhausted())
+
+	// Later requests fail after their first attempt
+	_, err = (&http.Client{Transport: rt}).Get(server.URL)
+	assert.ErrorIs(t, err, ErrRetryBudgetExhausted)
+	assert.Equal(t, int32(4), calls.Load())
+}
+
+func TestRetryTransport_ReplaysBody(t *testing.T) {
+	var calls atomic.Int32
+	var lastBody string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		lastBody = string(body)
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	rt := NewRetryTransport(http.DefaultTransport, RetryTransportOptions{
+		MaxRetries:  1,
+		InitialWait: time.Millisecond,
+		Logger:      log.NewBasicLogger(log.ErrorLevel),
+	})
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPut, server.URL, strings.NewReader("manifest"))
+	require.NoError(t, err)
+
+	resp, err := (&http.Client{Transport: rt}).Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	assert.Equal(t, "manifest", lastBody)
+}
@@ -0,0 +1,196 @@
+// Package audit checks a deployment of freightliner for security problems:
+// hardcoded secrets, weak cryptography and injection payloads in the loaded
+// configuration, the configuration file and the process environment. It
+// shares its rules with the source scanner of pkg/testing.
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"freightliner/pkg/config"
+)
+
+// Severity ranks findings
+type Severity int
+
+// Severity levels, lowest first
+const (
+	SeverityLow Severity = iota
+	SeverityMedium
+	SeverityHigh
+	SeverityCritical
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityLow:
+		return "LOW"
+	case SeverityMedium:
+		return "MEDIUM"
+	case SeverityHigh:
+		return "HIGH"
+	case SeverityCritical:
+		return "CRITICAL"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// MarshalJSON encodes the severity by name
+func (s Severity) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+// ParseSeverity parses a severity name, in any case
+func ParseSeverity(name string) (Severity, error) {
+	for s := SeverityLow; s <= SeverityCritical; s++ {
+		if strings.EqualFold(name, s.String()) {
+			return s, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown severity %q (want low, medium, high or critical)", name)
+}
+
+// Finding is one problem found by the audit
+type Finding struct {
+	// Check is the area that found it: config, config-file, environment or certificate
+	Check    string   `json:"check"`
+	Severity Severity `json:"severity"`
+	Title    string   `json:"title"`
+
+	// Location points at the setting, file line or variable, never at the
+	// secret value itself
+	Location    string `json:"location"`
+	Remediation string `json:"remediation"`
+}
+
+// Report is the result of an audit, with findings ranked by severity
+type Report struct {
+	Time       time.Time      `json:"time"`
+	ConfigFile string         `json:"config_file,omitempty"`
+	Findings   []Finding      `json:"findings"`
+	Summary    map[string]int `json:"summary"`
+}
+
+// Options says what to audit besides the loaded configuration
+type Options struct {
+	// ConfigFile is the file the configuration was loaded from, if any
+	ConfigFile string
+
+	// Environ is the environment to check, as from os.Environ
+	Environ []string
+
+	// Now is used to judge certificate expiry (default: time.Now)
+	Now time.Time
+}
+
+// Run audits cfg and the environment and file it came from
+func Run(cfg *config.Config, opts Options) *Report {
+	if opts.Now.IsZero() {
+		opts.Now = time.Now()
+	}
+
+	a := &auditor{now: opts.Now}
+	a.checkConfig(cfg)
+	if opts.ConfigFile != "" {
+		a.checkConfigFile(opts.ConfigFile)
+	}
+	a.checkEnvironment(opts.Environ)
+
+	report := &Report{
+		Time:       opts.Now.UTC(),
+		ConfigFile: opts.ConfigFile,
+		Findings:   a.findings,
+		Summary:    make(map[string]int),
+	}
+	if report.Findings == nil {
+		report.Findings = []Finding{}
+	}
+	sort.SliceStable(report.Findings, func(i, j int) bool {
+		if report.Findings[i].Severity != report.Findings[j].Severity {
+			return report.Findings[i].Severity > report.Findings[j].Severity
+		}
+		return report.Findings[i].Location < report.Findings[j].Location
+	})
+	for _, finding := range report.Findings {
+		report.Summary[finding.Severity.String()]++
+	}
+	return report
+}
+
+// Count returns how many findings are at or above severity
+func (r *Report) Count(severity Severity) int {
+	count := 0
+	for _, finding := range r.Findings {
+		if finding.Severity >= severity {
+			count++
+		}
+	}
+	return count
+}
+
+// Filter returns a copy of the report keeping only findings at or above severity
+func (r *Report) Filter(severity Severity) *Report {
+	filtered := &Report{
+		Time:       r.Time,
+		ConfigFile: r.ConfigFile,
+		Findings:   []Finding{},
+		Summary:    make(map[string]int),
+	}
+	for _, finding := range r.Findings {
+		if finding.Severity >= severity {
+			filtered.Findings = append(filtered.Findings, finding)
+			filtered.Summary[finding.Severity.String()]++
+		}
+	}
+	return filtered
+}
+
+// ExportJSON encodes the report as indented JSON
+func (r *Report) ExportJSON() ([]byte, error) {
+	return json.MarshalIndent(r, "", "  ")
+}
+
+// ExportTable renders the report for a terminal
+func (r *Report) ExportTable() string {
+	var b strings.Builder
+	b.WriteString("Self-Audit Report\n")
+	b.WriteString(strings.Repeat("=", 50) + "\n")
+	if r.ConfigFile != "" {
+		fmt.Fprintf(&b, "Config file: %s\n", r.ConfigFile)
+	}
+	fmt.Fprintf(&b, "Findings: %d (critical: %d, high: %d, medium: %d, low: %d)\n",
+		len(r.Findings), r.Summary["CRITICAL"], r.Summary["HIGH"], r.Summary["MEDIUM"], r.Summary["LOW"])
+
+	if len(r.Findings) == 0 {
+		b.WriteString("\nNo problems found\n")
+		return b.String()
+	}
+
+	for _, finding := range r.Findings {
+		fmt.Fprintf(&b, "\n[%s] %s\n", finding.Severity, finding.Title)
+		fmt.Fprintf(&b, "  Location:    %s (%s)\n", finding.Location, finding.Check)
+		fmt.Fprintf(&b, "  Remediation: %s\n", finding.Remediation)
+	}
+	return b.String()
+}
+
+// auditor collects the findings of one run
+type auditor struct {
+	now      time.Time
+	findings []Finding
+}
+
+func (a *auditor) add(check string, severity Severity, location, title, remediation string) {
+	a.findings = append(a.findings, Finding{
+		Check:       check,
+		Severity:    severity,
+		Title:       title,
+		Location:    location,
+		Remediation: remediation,
+	})
+}
@@ -0,0 +1,366 @@
+package audit
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"net"
+	"os"
+	"reflect"
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
+
+	"freightliner/pkg/config"
+)
+
+// Checks, as reported in Finding.Check
+const (
+	CheckConfig      = "config"
+	CheckConfigFile  = "config-file"
+	CheckEnvironment = "environment"
+	CheckCertificate = "certificate"
+)
+
+// MinAPIKeyLength is the shortest server API key not reported as weak
+const MinAPIKeyLength = 32
+
+// CertificateExpiryWarning is how long before expiry certificates are reported
+const CertificateExpiryWarning = 30 * 24 * time.Hour
+
+// secretName matches setting and variable names holding credentials, whose
+// values are never inspected or reported
+var secretName = regexp.MustCompile(`(?i)(password|passwd|secret|token|api_?key|private_?key|credential|assertion)`)
+
+// pathName matches settings holding file system paths, where ".." is legitimate
+var pathName = regexp.MustCompile(`(?i)(file|dir|directory|path)$`)
+
+// checkConfig audits the loaded configuration
+func (a *auditor) checkConfig(cfg *config.Config) {
+	if cfg == nil {
+		return
+	}
+
+	a.checkServer(cfg.Server)
+
+	if cfg.Debug.Addr != "" && !isLoopbackAddr(cfg.Debug.Addr) {
+		a.add(CheckConfig, SeverityHigh, "debug.addr",
+			"Unauthenticated debug endpoints (pprof, state dumps) are reachable beyond localhost",
+			"Bind debug.addr to localhost and reach it through a port forward")
+	}
+
+	switch strings.ToLower(cfg.LogLevel) {
+	case "debug", "trace":
+		a.add(CheckConfig, SeverityLow, "log_level",
+			"Debug logging records registry URLs and request details",
+			"Use info logging outside of troubleshooting")
+	}
+
+	for i, registry := range cfg.Registries.Registries {
+		location := fmt.Sprintf("registries.registries[%d]", i)
+		if registry.Name != "" {
+			location = fmt.Sprintf("registries.registries[%s]", registry.Name)
+		}
+		if registry.Insecure || registry.TLS.InsecureSkipVerify {
+			a.add(CheckConfig, SeverityHigh, location+".tls",
+				"TLS certificate verification is disabled for the registry",
+				"Trust the registry's CA with tls.ca_file instead of skipping verification")
+		}
+		if strings.HasPrefix(strings.ToLower(registry.Endpoint), "http://") {
+			a.add(CheckConfig, SeverityMedium, location+".endpoint",
+				"Registry credentials and images travel over plain HTTP",
+				"Use an https:// endpoint")
+		}
+		a.checkCertificate(location+".tls.cert_file", registry.TLS.CertFile)
+		a.checkCertificate(location+".tls.ca_file", registry.TLS.CAFile)
+		a.checkKeyFile(location+".tls.key_file", registry.TLS.KeyFile)
+	}
+
+	walkStrings(reflect.ValueOf(cfg).Elem(), "", a.checkInjection)
+}
+
+// checkServer audits the server-mode settings
+func (a *auditor) checkServer(server config.ServerConfig) {
+	exposed := !isLoopbackHost(server.Host)
+
+	switch {
+	case server.APIKeyAuth && server.APIKey == "":
+		a.add(CheckConfig, SeverityHigh, "server.api_key",
+			"API key authentication is enabled without a key",
+			"Set server.api_key to a long random value")
+	case server.APIKeyAuth && len(server.APIKey) < MinAPIKeyLength:
+		a.add(CheckConfig, SeverityMedium, "server.api_key",
+			fmt.Sprintf("The server API key is shorter than %d characters", MinAPIKeyLength),
+			"Generate a longer key, e.g. with: openssl rand -hex 32")
+	case !server.APIKeyAuth && exposed:
+		a.add(CheckConfig, SeverityHigh, "server.api_key_auth",
+			"The server API accepts unauthenticated requests beyond localhost",
+			"Enable server.api_key_auth or bind server.host to localhost")
+	}
+
+	if !server.TLSEnabled && exposed && !strings.HasPrefix(strings.ToLower(server.ExternalURL), "https://") {
+		a.add(CheckConfig, SeverityMedium, "server.tls_enabled",
+			"The server API is served over plain HTTP beyond localhost",
+			"Enable server.tls_enabled or terminate TLS in front of the server")
+	}
+
+	if server.EnableCORS {
+		for _, origin := range server.AllowedOrigins {
+			if origin != "*" {
+				continue
+			}
+			severity := SeverityLow
+			if exposed {
+				severity = SeverityMedium
+			}
+			a.add(CheckConfig, severity, "server.allowed_origins",
+				"CORS allows requests from any origin",
+				"List the dashboard origins in server.allowed_origins")
+			break
+		}
+	}
+
+	if server.TLSEnabled {
+		a.checkCertificate("server.tls_cert_file", server.TLSCertFile)
+		a.checkKeyFile("server.tls_key_file", server.TLSKeyFile)
+	}
+}
+
+// checkInjection reports injection payloads in a configuration value
+func (a *auditor) checkInjection(path, value string) {
+	if value == "" || secretName.MatchString(path) {
+		return
+	}
+	for _, pattern := range InjectionPatterns {
+		if pattern.Type == "Path" && pathName.MatchString(path) {
+			continue
+		}
+		if pattern.Detect.MatchString(value) {
+			a.add(CheckConfig, pattern.Severity, path,
+				fmt.Sprintf("Value looks like a %s payload", pattern.Name),
+				"Check where the value came from and restrict it to the characters it needs")
+		}
+	}
+}
+
+// checkConfigFile audits the permissions and content of the configuration file
+func (a *auditor) checkConfigFile(path string) {
+	info, err := os.Stat(path)
+	if err != nil {
+		a.add(CheckConfigFile, SeverityLow, path,
+			fmt.Sprintf("Configuration file could not be read: %v", err),
+			"Run the audit where the configuration file is readable")
+		return
+	}
+
+	if runtime.GOOS != "windows" {
+		mode := info.Mode().Perm()
+		if mode&0o022 != 0 {
+			a.add(CheckConfigFile, SeverityHigh, path,
+				fmt.Sprintf("Configuration file is writable by other users (mode %04o)", mode),
+				"chmod go-w the file")
+		}
+		if mode&0o004 != 0 {
+			a.add(CheckConfigFile, SeverityMedium, path,
+				fmt.Sprintf("Configuration file is readable by every user (mode %04o)", mode),
+				"chmod o-r the file")
+		}
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(text, "#") {
+			continue
+		}
+		for _, pattern := range SecretPatterns {
+			if pattern.Pattern.MatchString(text) {
+				a.add(CheckConfigFile, pattern.Severity, fmt.Sprintf("%s:%d", path, line),
+					pattern.Description+" in the configuration file", pattern.Remediation)
+			}
+		}
+	}
+}
+
+// checkEnvironment audits the environment variables of the process
+func (a *auditor) checkEnvironment(environ []string) {
+	for _, entry := range environ {
+		name, value, ok := strings.Cut(entry, "=")
+		if !ok || value == "" {
+			continue
+		}
+		location := "$" + name
+
+		if secretName.MatchString(name) {
+			if name == "AWS_SECRET_ACCESS_KEY" {
+				a.add(CheckEnvironment, SeverityMedium, location,
+					"Long-lived AWS access keys are set in the environment",
+					"Use an IAM role (instance profile, IRSA or ECS task role) instead of access keys")
+			}
+			continue
+		}
+
+		for _, pattern := range SecretPatterns {
+			if pattern.Pattern.MatchString(value) {
+				a.add(CheckEnvironment, SeverityHigh, location,
+					pattern.Description+" in a variable not meant for secrets",
+					"Move the secret to a dedicated variable or a secrets manager")
+				break
+			}
+		}
+	}
+}
+
+// checkCertificate audits the certificates in a PEM file for weak
+// signatures, short keys and expiry
+func (a *auditor) checkCertificate(location, path string) {
+	if path == "" {
+		return
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		a.add(CheckCertificate, SeverityMedium, location,
+			fmt.Sprintf("Certificate could not be read: %v", err),
+			"Fix the path or permissions of the certificate")
+		return
+	}
+
+	for block, rest := pem.Decode(data); block != nil; block, rest = pem.Decode(rest) {
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			a.add(CheckCertificate, SeverityMedium, location,
+				fmt.Sprintf("Certificate could not be parsed: %v", err),
+				"Replace the certificate")
+			continue
+		}
+		subject := cert.Subject.CommonName
+		if subject == "" {
+			subject = cert.Subject.String()
+		}
+
+		if algorithm, weak := weakSignatures[cert.SignatureAlgorithm]; weak {
+			a.add(CheckCertificate, SeverityHigh, location,
+				fmt.Sprintf("Certificate %q is signed with weak algorithm %s", subject, algorithm),
+				"Reissue the certificate with a SHA-256 or stronger signature")
+		}
+		if algorithm, bits := keySize(cert); bits > 0 && bits < MinKeySize[algorithm] {
+			a.add(CheckCertificate, SeverityHigh, location,
+				fmt.Sprintf("Certificate %q has a %d-bit %s key", subject, bits, algorithm),
+				fmt.Sprintf("Reissue the certificate with a key of at least %d bits", MinKeySize[algorithm]))
+		}
+
+		switch {
+		case a.now.After(cert.NotAfter):
+			a.add(CheckCertificate, SeverityHigh, location,
+				fmt.Sprintf("Certificate %q expired on %s", subject, cert.NotAfter.Format("2006-01-02")),
+				"Renew the certificate")
+		case cert.NotAfter.Sub(a.now) < CertificateExpiryWarning:
+			a.add(CheckCertificate, SeverityMedium, location,
+				fmt.Sprintf("Certificate %q expires on %s", subject, cert.NotAfter.Format("2006-01-02")),
+				"Renew the certificate")
+		}
+	}
+}
+
+// checkKeyFile reports private keys readable by other users
+func (a *auditor) checkKeyFile(location, path string) {
+	if path == "" || runtime.GOOS == "windows" {
+		return
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return
+	}
+	if mode := info.Mode().Perm(); mode&0o077 != 0 {
+		a.add(CheckCertificate, SeverityHigh, location,
+			fmt.Sprintf("Private key is accessible by other users (mode %04o)", mode),
+			"chmod 600 the key file")
+	}
+}
+
+// keySize returns the algorithm and size in bits of a certificate's key
+func keySize(cert *x509.Certificate) (string, int) {
+	switch key := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		return "RSA", key.N.BitLen()
+	case *ecdsa.PublicKey:
+		return "ECDSA", key.Curve.Params().BitSize
+	default:
+		return "", 0
+	}
+}
+
+// isLoopbackHost reports whether a bind host only accepts local connections
+func isLoopbackHost(host string) bool {
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(strings.Trim(host, "[]"))
+	return ip != nil && ip.IsLoopback()
+}
+
+// isLoopbackAddr reports whether a host:port address only accepts local connections
+func isLoopbackAddr(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	return isLoopbackHost(host)
+}
+
+// walkStrings calls fn with the yaml path of every string in v
+func walkStrings(v reflect.Value, path string, fn func(path, value string)) {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if !v.IsNil() {
+			walkStrings(v.Elem(), path, fn)
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = strings.ToLower(field.Name)
+			}
+			walkStrings(v.Field(i), joinPath(path, name), fn)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			walkStrings(v.Index(i), fmt.Sprintf("%s[%d]", path, i), fn)
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			walkStrings(iter.Value(), joinPath(path, fmt.Sprint(iter.Key().Interface())), fn)
+		}
+	case reflect.String:
+		fn(path, v.String())
+	}
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
@@ -0,0 +1,131 @@
+package audit
+
+import (
+	"crypto/x509"
+	"regexp"
+)
+
+// SecretPattern recognizes a credential written out in plain text
+type SecretPattern struct {
+	Name        string
+	Pattern     *regexp.Regexp
+	Severity    Severity
+	Description string
+	Remediation string
+}
+
+// SecretPatterns are the hardcoded secrets looked for in configuration
+// files, environment values and, through pkg/testing, source code
+var SecretPatterns = []SecretPattern{
+	{
+		Name:        "Hardcoded Password",
+		Pattern:     regexp.MustCompile(`(?i)\b(password|passwd)\s*[:=]\s*["']?[^"'\s$]`),
+		Severity:    SeverityCritical,
+		Description: "Hardcoded password",
+		Remediation: "Use environment variables or secure credential storage",
+	},
+	{
+		Name:        "Hardcoded API Key",
+		Pattern:     regexp.MustCompile(`(?i)\b(api_key|apikey|api-key|token)\s*[:=]\s*["']?[^"'\s$]`),
+		Severity:    SeverityHigh,
+		Description: "Hardcoded API key or token",
+		Remediation: "Use environment variables or secure credential storage",
+	},
+	{
+		Name:        "AWS Access Key",
+		Pattern:     regexp.MustCompile(`\b(AKIA|ASIA)[0-9A-Z]{16}\b`),
+		Severity:    SeverityCritical,
+		Description: "AWS access key ID",
+		Remediation: "Use an IAM role or profile instead of access keys, and rotate the exposed key",
+	},
+	{
+		Name:        "Private Key",
+		Pattern:     regexp.MustCompile(`-----BEGIN ([A-Z]+ )?PRIVATE KEY-----`),
+		Severity:    SeverityCritical,
+		Description: "PEM private key",
+		Remediation: "Reference the key by file path with restrictive permissions",
+	},
+	{
+		Name:        "GitHub Token",
+		Pattern:     regexp.MustCompile(`\bgh[pousr]_[A-Za-z0-9]{36,}\b`),
+		Severity:    SeverityHigh,
+		Description: "GitHub token",
+		Remediation: "Use a secrets manager, and revoke the exposed token",
+	},
+	{
+		Name:        "Google API Key",
+		Pattern:     regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}\b`),
+		Severity:    SeverityHigh,
+		Description: "Google API key",
+		Remediation: "Use a service account through a secrets manager, and rotate the exposed key",
+	},
+}
+
+// WeakAlgorithms are the cryptographic algorithms no longer considered safe
+var WeakAlgorithms = []string{"MD5", "SHA1", "DES", "3DES", "RC4"}
+
+// MinKeySize is the smallest acceptable key size in bits, by algorithm
+var MinKeySize = map[string]int{
+	"RSA":   2048,
+	"ECDSA": 256,
+	"DSA":   2048,
+}
+
+// weakSignatures are the certificate signature algorithms built on WeakAlgorithms
+var weakSignatures = map[x509.SignatureAlgorithm]string{
+	x509.MD2WithRSA:    "MD2",
+	x509.MD5WithRSA:    "MD5",
+	x509.SHA1WithRSA:   "SHA1",
+	x509.DSAWithSHA1:   "SHA1",
+	x509.ECDSAWithSHA1: "SHA1",
+}
+
+// InjectionPattern is an injection attack with a sample payload and a
+// pattern recognizing it
+type InjectionPattern struct {
+	Name     string
+	Type     string // SQL, XSS, Command, LDAP, Path
+	Payload  string
+	Detect   *regexp.Regexp
+	Severity Severity
+}
+
+// InjectionPatterns are the injection payloads looked for in configuration
+// values, which end up in registry API paths, file names and shell hooks
+var InjectionPatterns = []InjectionPattern{
+	{
+		Name:     "SQL Injection",
+		Type:     "SQL",
+		Payload:  "'; DROP TABLE users; --",
+		Detect:   regexp.MustCompile(`(?i)'\s*;|;\s*--|\b(DROP|DELETE|INSERT|UPDATE)\s+(TABLE|FROM|INTO)\b|\bUNION\s+SELECT\b`),
+		Severity: SeverityHigh,
+	},
+	{
+		Name:     "XSS Script",
+		Type:     "XSS",
+		Payload:  "<script>alert('xss')</script>",
+		Detect:   regexp.MustCompile(`(?i)<\s*script|javascript:|\bon(error|load)\s*=`),
+		Severity: SeverityHigh,
+	},
+	{
+		Name:     "Command Injection",
+		Type:     "Command",
+		Payload:  "; rm -rf /",
+		Detect:   regexp.MustCompile("[;&|]\\s*(rm|curl|wget|sh|bash|nc)\\b|\\$\\(|`"),
+		Severity: SeverityHigh,
+	},
+	{
+		Name:     "LDAP Injection",
+		Type:     "LDAP",
+		Payload:  "*)(&",
+		Detect:   regexp.MustCompile(`\*\)\(`),
+		Severity: SeverityMedium,
+	},
+	{
+		Name:     "Path Traversal",
+		Type:     "Path",
+		Payload:  "../../../etc/passwd",
+		Detect:   regexp.MustCompile(`(^|[/\\])\.\.([/\\]|$)`),
+		Severity: SeverityMedium,
+	},
+}
@@ -0,0 +1,60 @@
+package cosign
+
+import (
+	"crypto/x509"
+	"fmt"
+	"time"
+)
+
+// certTimePolicy decides the time keyless certificates are checked against
+type certTimePolicy struct {
+	// pinned is a fixed verification time; zero uses the system clock
+	pinned time.Time
+
+	// integrated checks certificates at their signature's Rekor integrated time
+	integrated bool
+
+	// ignore accepts certificates whatever their validity window
+	ignore bool
+}
+
+// relaxed reports whether certificates are not checked against the system clock
+func (p certTimePolicy) relaxed() bool {
+	return p.ignore || p.integrated || !p.pinned.IsZero()
+}
+
+// checkTime returns the time cert is verified at, given the integrated time
+// of its signature's log entry in Unix seconds (0 when unknown). The zero
+// time means the system clock.
+func (p certTimePolicy) checkTime(cert *x509.Certificate, integratedTime int64) (time.Time, error) {
+	switch {
+	case p.ignore:
+		// Any time within the window passes the validity check
+		return cert.NotBefore, nil
+	case p.integrated:
+		if integratedTime == 0 {
+			return time.Time{}, fmt.Errorf("signature has no Rekor integrated time to verify its certificate at")
+		}
+		return time.Unix(integratedTime, 0).UTC(), nil
+	default:
+		return p.pinned, nil
+	}
+}
+
+// confirmIntegratedTime checks that the integrated time a certificate was
+// verified at is the one recorded by the verified log entry
+func confirmIntegratedTime(checked int64, entry *VerifiedEntry) error {
+	if entry == nil {
+		return fmt.Errorf("no verified Rekor entry confirms the signature's integrated time")
+	}
+	if entry.IntegratedTime.Unix() != checked {
+		return fmt.Errorf("bundle integrated time %s does not match Rekor entry time %s",
+			time.Unix(checked, 0).UTC().Format(time.RFC3339), entry.IntegratedTime.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// validAt reports whether t falls within cert's validity window
+func validAt(cert *x509.Certificate, t time.Time) bool {
+	return !t.Before(cert.NotBefore) && !t.After(cert.NotAfter)
+}
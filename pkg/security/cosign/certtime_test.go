@@ -0,0 +1,49 @@
+package cosign
+
+import (
+	"crypto/x509"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCertTimePolicyCheckTime(t *testing.T) {
+	issued := time.Date(2026, 1, 2, 3, 4, 0, 0, time.UTC)
+	cert := &x509.Certificate{NotBefore: issued, NotAfter: issued.Add(10 * time.Minute)}
+	logged := issued.Add(time.Minute)
+
+	// The system clock is used unless a time is pinned
+	at, err := certTimePolicy{}.checkTime(cert, logged.Unix())
+	require.NoError(t, err)
+	assert.True(t, at.IsZero())
+	assert.False(t, certTimePolicy{}.relaxed())
+
+	pinned := issued.Add(5 * time.Minute)
+	at, err = certTimePolicy{pinned: pinned}.checkTime(cert, 0)
+	require.NoError(t, err)
+	assert.Equal(t, pinned, at)
+
+	at, err = certTimePolicy{integrated: true}.checkTime(cert, logged.Unix())
+	require.NoError(t, err)
+	assert.Equal(t, logged, at)
+	assert.True(t, validAt(cert, at))
+
+	_, err = certTimePolicy{integrated: true}.checkTime(cert, 0)
+	assert.Error(t, err, "no integrated time to check against")
+
+	// Ignoring the window checks at a time inside it
+	at, err = certTimePolicy{ignore: true}.checkTime(cert, 0)
+	require.NoError(t, err)
+	assert.True(t, validAt(cert, at))
+	assert.False(t, validAt(cert, issued.Add(time.Hour)))
+}
+
+func TestConfirmIntegratedTime(t *testing.T) {
+	logged := time.Date(2026, 1, 2, 3, 5, 0, 0, time.UTC)
+
+	assert.NoError(t, confirmIntegratedTime(logged.Unix(), &VerifiedEntry{IntegratedTime: logged}))
+	assert.ErrorContains(t, confirmIntegratedTime(logged.Unix(), &VerifiedEntry{IntegratedTime: logged.Add(time.Hour)}), "does not match")
+	assert.ErrorContains(t, confirmIntegratedTime(logged.Unix(), nil), "no verified Rekor entry")
+}
@@ -0,0 +1,477 @@
+package cosign
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultRekorURL is the public Sigstore transparency log
+const DefaultRekorURL = "https://rekor.sigstore.dev"
+
+// InclusionOptions configures an InclusionVerifier
+type InclusionOptions struct {
+	// RekorURL is queried for log entries and, when PublicKeyPath is not
+	// set, for the log's public key (default: DefaultRekorURL)
+	RekorURL string
+
+	// BundlePath is a JSON file of Rekor log entries keyed by UUID, as
+	// returned by GET /api/v1/log/entries. When set, entries are looked up
+	// in the file and Rekor is never contacted.
+	BundlePath string
+
+	// PublicKeyPath is the PEM public key that signs the log's checkpoints.
+	// Required with BundlePath.
+	PublicKeyPath string
+
+	// HTTPClient sends Rekor requests (default: 30s timeout)
+	HTTPClient *http.Client
+}
+
+// InclusionVerifier checks that signatures are recorded in a Rekor log by
+// verifying the entry's Merkle inclusion proof against a checkpoint signed
+// by the log
+type InclusionVerifier struct {
+	opts    InclusionOptions
+	offline map[string]rekorLogEntry
+
+	// keyMu guards publicKey, which concurrent verifications fetch lazily
+	keyMu     sync.Mutex
+	publicKey interface{}
+}
+
+// VerifiedEntry describes the log entry that proved a signature's inclusion
+type VerifiedEntry struct {
+	UUID           string
+	LogIndex       int64
+	IntegratedTime time.Time
+	TreeSize       int64
+	RootHash       string
+}
+
+// rekorLogEntry is one entry of a Rekor API response
+type rekorLogEntry struct {
+	Body           string `json:"body"`
+	IntegratedTime int64  `json:"integratedTime"`
+	LogID          string `json:"logID"`
+	LogIndex       int64  `json:"logIndex"`
+	Verification   *struct {
+		InclusionProof *rekorInclusionProof `json:"inclusionProof"`
+	} `json:"verification"`
+}
+
+// rekorInclusionProof is the inclusion proof of a Rekor entry. LogIndex is
+// the entry's index in the shard's tree, which differs from the global log
+// index once the log has been sharded.
+type rekorInclusionProof struct {
+	Checkpoint string   `json:"checkpoint"`
+	Hashes     []string `json:"hashes"`
+	LogIndex   int64    `json:"logIndex"`
+	RootHash   string   `json:"rootHash"`
+	TreeSize   int64    `json:"treeSize"`
+}
+
+// NewInclusionVerifier loads the offline bundle and public key, if
+// configured. Without PublicKeyPath the key is fetched from Rekor on first
+// use.
+func NewInclusionVerifier(opts InclusionOptions) (*InclusionVerifier, error) {
+	if opts.RekorURL == "" {
+		opts.RekorURL = DefaultRekorURL
+	}
+	opts.RekorURL = strings.TrimSuffix(opts.RekorURL, "/")
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = &http.Client{Timeout: 30 * time.Second}
+	}
+
+	v := &InclusionVerifier{opts: opts}
+
+	if opts.PublicKeyPath != "" {
+		data, err := os.ReadFile(opts.PublicKeyPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read Rekor public key: %w", err)
+		}
+		if v.publicKey, err = parseRekorPublicKey(data); err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.BundlePath != "" {
+		if opts.PublicKeyPath == "" {
+			return nil, fmt.Errorf("offline Rekor verification requires the Rekor public key")
+		}
+		data, err := os.ReadFile(opts.BundlePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read Rekor bundle: %w", err)
+		}
+		if err := json.Unmarshal(data, &v.offline); err != nil {
+			return nil, fmt.Errorf("failed to parse Rekor bundle %s: %w", opts.BundlePath, err)
+		}
+	}
+
+	return v, nil
+}
+
+// Verify finds the log entry for a signature over payload and verifies its
+// inclusion proof. logIndex is the global log index from the signature's
+// Rekor bundle, or 0 to search the log by payload hash.
+func (v *InclusionVerifier) Verify(ctx context.Context, payload []byte, base64Signature string, logIndex int64) (*VerifiedEntry, error) {
+	candidates, err := v.findEntries(ctx, payload, logIndex)
+	if err != nil {
+		return nil, err
+	}
+
+	var lastErr error
+	for uuid, entry := range candidates {
+		if err := entryMatchesSignature(entry, payload, base64Signature); err != nil {
+			lastErr = err
+			continue
+		}
+		verified, err := v.verifyEntry(ctx, uuid, entry)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		return verified, nil
+	}
+
+	if lastErr != nil {
+		return nil, lastErr
+	}
+	return nil, fmt.Errorf("no Rekor entry found for signature")
+}
+
+// verifyEntry checks an entry's inclusion proof and the checkpoint that
+// commits to its root hash
+func (v *InclusionVerifier) verifyEntry(ctx context.Context, uuid string, entry rekorLogEntry) (*VerifiedEntry, error) {
+	if entry.Verification == nil || entry.Verification.InclusionProof == nil {
+		return nil, fmt.Errorf("rekor entry %s has no inclusion proof", uuid)
+	}
+	proof := entry.Verification.InclusionProof
+
+	body, err := base64.StdEncoding.DecodeString(entry.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode rekor entry body: %w", err)
+	}
+	root, err := hex.DecodeString(proof.RootHash)
+	if err != nil {
+		return nil, fmt.Errorf("invalid inclusion proof root hash: %w", err)
+	}
+	hashes := make([][]byte, len(proof.Hashes))
+	for i, h := range proof.Hashes {
+		if hashes[i], err = hex.DecodeString(h); err != nil {
+			return nil, fmt.Errorf("invalid inclusion proof hash: %w", err)
+		}
+	}
+
+	if err := verifyMerkleInclusion(proof.LogIndex, proof.TreeSize, merkleLeafHash(body), hashes, root); err != nil {
+		return nil, fmt.Errorf("rekor entry %s: %w", uuid, err)
+	}
+
+	key, err := v.rekorPublicKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyCheckpoint(proof.Checkpoint, proof.TreeSize, root, key); err != nil {
+		return nil, fmt.Errorf("rekor entry %s: %w", uuid, err)
+	}
+
+	return &VerifiedEntry{
+		UUID:           uuid,
+		LogIndex:       entry.LogIndex,
+		IntegratedTime: time.Unix(entry.IntegratedTime, 0).UTC(),
+		TreeSize:       proof.TreeSize,
+		RootHash:       proof.RootHash,
+	}, nil
+}
+
+// findEntries returns the log entries that may record the signature
+func (v *InclusionVerifier) findEntries(ctx context.Context, payload []byte, logIndex int64) (map[string]rekorLogEntry, error) {
+	if v.offline != nil {
+		return v.offline, nil
+	}
+
+	if logIndex > 0 {
+		return v.getEntries(ctx, fmt.Sprintf("%s/api/v1/log/entries?logIndex=%d", v.opts.RekorURL, logIndex))
+	}
+
+	// Search the index by the hash of the signed payload
+	sum := sha256.Sum256(payload)
+	query, _ := json.Marshal(map[string]string{"hash": "sha256:" + hex.EncodeToString(sum[:])})
+	var uuids []string
+	if err := v.doJSON(ctx, http.MethodPost, v.opts.RekorURL+"/api/v1/index/retrieve", query, &uuids); err != nil {
+		return nil, fmt.Errorf("rekor search failed: %w", err)
+	}
+
+	entries := make(map[string]rekorLogEntry)
+	for _, uuid := range uuids {
+		found, err := v.getEntries(ctx, fmt.Sprintf("%s/api/v1/log/entries/%s", v.opts.RekorURL, uuid))
+		if err != nil {
+			return nil, err
+		}
+		for k, e := range found {
+			entries[k] = e
+		}
+	}
+	return entries, nil
+}
+
+// getEntries fetches log entries from url
+func (v *InclusionVerifier) getEntries(ctx context.Context, url string) (map[string]rekorLogEntry, error) {
+	var entries map[string]rekorLogEntry
+	if err := v.doJSON(ctx, http.MethodGet, url, nil, &entries); err != nil {
+		return nil, fmt.Errorf("failed to get rekor entry: %w", err)
+	}
+	return entries, nil
+}
+
+// rekorPublicKey returns the configured key, fetching it from Rekor once
+// when none was configured. A failed fetch is retried by the next call.
+func (v *InclusionVerifier) rekorPublicKey(ctx context.Context) (interface{}, error) {
+	v.keyMu.Lock()
+	defer v.keyMu.Unlock()
+	if v.publicKey != nil {
+		return v.publicKey, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.opts.RekorURL+"/api/v1/log/publicKey", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, err := v.opts.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rekor public key: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get rekor public key: %s", resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rekor public key: %w", err)
+	}
+
+	key, err := parseRekorPublicKey(data)
+	if err != nil {
+		return nil, err
+	}
+	v.publicKey = key
+	return key, nil
+}
+
+// doJSON sends a request to Rekor and decodes the JSON response into out
+func (v *InclusionVerifier) doJSON(ctx context.Context, method, url string, body []byte, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := v.opts.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("status %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 16<<20)).Decode(out)
+}
+
+// entryMatchesSignature checks that a hashedrekord or rekord entry records
+// this signature over this payload
+func entryMatchesSignature(entry rekorLogEntry, payload []byte, base64Signature string) error {
+	body, err := base64.StdEncoding.DecodeString(entry.Body)
+	if err != nil {
+		return fmt.Errorf("failed to decode rekor entry body: %w", err)
+	}
+
+	var record struct {
+		Kind string `json:"kind"`
+		Spec struct {
+			Data struct {
+				Hash struct {
+					Algorithm string `json:"algorithm"`
+					Value     string `json:"value"`
+				} `json:"hash"`
+			} `json:"data"`
+			Signature struct {
+				Content string `json:"content"`
+			} `json:"signature"`
+		} `json:"spec"`
+	}
+	if err := json.Unmarshal(body, &record); err != nil {
+		return fmt.Errorf("failed to parse rekor entry body: %w", err)
+	}
+	if record.Kind != "hashedrekord" && record.Kind != "rekord" {
+		return fmt.Errorf("unsupported rekor entry kind %q", record.Kind)
+	}
+
+	sum := sha256.Sum256(payload)
+	if record.Spec.Data.Hash.Algorithm != "sha256" || !strings.EqualFold(record.Spec.Data.Hash.Value, hex.EncodeToString(sum[:])) {
+		return fmt.Errorf("rekor entry does not record the signed payload")
+	}
+
+	recorded, err := base64.StdEncoding.DecodeString(record.Spec.Signature.Content)
+	if err != nil {
+		return fmt.Errorf("failed to decode rekor entry signature: %w", err)
+	}
+	signature, err := base64.StdEncoding.DecodeString(base64Signature)
+	if err != nil {
+		return fmt.Errorf("failed to decode signature: %w", err)
+	}
+	if !bytes.Equal(recorded, signature) {
+		return fmt.Errorf("rekor entry does not record the signature")
+	}
+	return nil
+}
+
+// merkleLeafHash returns the RFC 6962 hash of a log leaf
+func merkleLeafHash(leaf []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{0})
+	h.Write(leaf)
+	return h.Sum(nil)
+}
+
+// merkleNodeHash returns the RFC 6962 hash of an interior node
+func merkleNodeHash(left, right []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{1})
+	h.Write(left)
+	h.Write(right)
+	return h.Sum(nil)
+}
+
+// verifyMerkleInclusion verifies that leafHash is at index in a tree of
+// size treeSize with the given root, following RFC 9162 section 2.1.3.2
+func verifyMerkleInclusion(index, treeSize int64, leafHash []byte, proof [][]byte, root []byte) error {
+	if index < 0 || index >= treeSize {
+		return fmt.Errorf("inclusion proof index %d is outside tree of size %d", index, treeSize)
+	}
+
+	fn, sn := index, treeSize-1
+	hash := leafHash
+	for _, p := range proof {
+		if sn == 0 {
+			return fmt.Errorf("inclusion proof has too many hashes")
+		}
+		if fn&1 == 1 || fn == sn {
+			hash = merkleNodeHash(p, hash)
+			for fn&1 == 0 && fn != 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else {
+			hash = merkleNodeHash(hash, p)
+		}
+		fn >>= 1
+		sn >>= 1
+	}
+
+	if sn != 0 {
+		return fmt.Errorf("inclusion proof has too few hashes")
+	}
+	if !bytes.Equal(hash, root) {
+		return fmt.Errorf("inclusion proof does not match root hash")
+	}
+	return nil
+}
+
+// verifyCheckpoint checks that a signed checkpoint (a signed note of origin,
+// tree size and base64 root hash) commits to treeSize and root and carries a
+// valid signature by key
+func verifyCheckpoint(checkpoint string, treeSize int64, root []byte, key interface{}) error {
+	if checkpoint == "" {
+		return fmt.Errorf("inclusion proof has no checkpoint")
+	}
+
+	text, signatures, ok := strings.Cut(checkpoint, "\n\n")
+	if !ok {
+		return fmt.Errorf("malformed checkpoint")
+	}
+	text += "\n"
+
+	lines := strings.Split(text, "\n")
+	if len(lines) < 3 {
+		return fmt.Errorf("malformed checkpoint")
+	}
+	size, err := strconv.ParseInt(lines[1], 10, 64)
+	if err != nil || size != treeSize {
+		return fmt.Errorf("checkpoint tree size %q does not match inclusion proof size %d", lines[1], treeSize)
+	}
+	checkpointRoot, err := base64.StdEncoding.DecodeString(lines[2])
+	if err != nil || !bytes.Equal(checkpointRoot, root) {
+		return fmt.Errorf("checkpoint root hash does not match inclusion proof")
+	}
+
+	for _, line := range strings.Split(signatures, "\n") {
+		if !strings.HasPrefix(line, "— ") {
+			continue
+		}
+		fields := strings.Fields(strings.TrimPrefix(line, "— "))
+		if len(fields) != 2 {
+			continue
+		}
+		sig, err := base64.StdEncoding.DecodeString(fields[1])
+		if err != nil || len(sig) <= 4 {
+			continue
+		}
+		// The first four bytes are a key hint; try the signature regardless
+		if verifyNoteSignature(key, []byte(text), sig[4:]) {
+			return nil
+		}
+	}
+	return fmt.Errorf("checkpoint is not signed by the rekor public key")
+}
+
+// verifyNoteSignature verifies a checkpoint signature made by an ECDSA or
+// Ed25519 log key
+func verifyNoteSignature(key interface{}, msg, sig []byte) bool {
+	switch k := key.(type) {
+	case *ecdsa.PublicKey:
+		digest := sha256.Sum256(msg)
+		return ecdsa.VerifyASN1(k, digest[:], sig)
+	case ed25519.PublicKey:
+		return ed25519.Verify(k, msg, sig)
+	default:
+		return false
+	}
+}
+
+// parseRekorPublicKey parses a PEM encoded ECDSA or Ed25519 public key
+func parseRekorPublicKey(data []byte) (interface{}, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("rekor public key is not PEM encoded")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse rekor public key: %w", err)
+	}
+	switch key.(type) {
+	case *ecdsa.PublicKey, ed25519.PublicKey:
+		return key, nil
+	default:
+		return nil, fmt.Errorf("unsupported rekor public key type %T", key)
+	}
+}
@@ -0,0 +1,238 @@
+package cosign
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testLog is a small in-memory transparency log
+type testLog struct {
+	key    *ecdsa.PrivateKey
+	leaves [][]byte
+}
+
+func newTestLog(t *testing.T) *testLog {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	return &testLog{key: key}
+}
+
+// add records a hashedrekord entry for a signature over payload and returns
+// its index
+func (l *testLog) add(payload, signature []byte) int64 {
+	sum := sha256.Sum256(payload)
+	body, _ := json.Marshal(map[string]interface{}{
+		"apiVersion": "0.0.1",
+		"kind":       "hashedrekord",
+		"spec": map[string]interface{}{
+			"data":      map[string]interface{}{"hash": map[string]string{"algorithm": "sha256", "value": hex.EncodeToString(sum[:])}},
+			"signature": map[string]interface{}{"content": base64.StdEncoding.EncodeToString(signature)},
+		},
+	})
+	l.leaves = append(l.leaves, body)
+	return int64(len(l.leaves) - 1)
+}
+
+// entry returns the API representation of the leaf at index
+func (l *testLog) entry(t *testing.T, index int64) map[string]interface{} {
+	root := treeHash(l.leaves)
+	var hashes []string
+	for _, h := range auditPath(index, l.leaves) {
+		hashes = append(hashes, hex.EncodeToString(h))
+	}
+
+	note := fmt.Sprintf("test.rekor - 1\n%d\n%s\n", len(l.leaves), base64.StdEncoding.EncodeToString(root))
+	digest := sha256.Sum256([]byte(note))
+	sig, err := ecdsa.SignASN1(rand.Reader, l.key, digest[:])
+	require.NoError(t, err)
+	checkpoint := note + "\n— test.rekor " + base64.StdEncoding.EncodeToString(append([]byte{1, 2, 3, 4}, sig...)) + "\n"
+
+	return map[string]interface{}{
+		"body":           base64.StdEncoding.EncodeToString(l.leaves[index]),
+		"integratedTime": 1700000000,
+		"logIndex":       index + 1000,
+		"verification": map[string]interface{}{
+			"inclusionProof": map[string]interface{}{
+				"checkpoint": checkpoint,
+				"hashes":     hashes,
+				"logIndex":   index,
+				"rootHash":   hex.EncodeToString(root),
+				"treeSize":   len(l.leaves),
+			},
+		},
+	}
+}
+
+func (l *testLog) publicKeyPEM(t *testing.T) []byte {
+	der, err := x509.MarshalPKIXPublicKey(&l.key.PublicKey)
+	require.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+}
+
+// treeHash computes the RFC 6962 Merkle tree hash of leaves
+func treeHash(leaves [][]byte) []byte {
+	if len(leaves) == 1 {
+		return merkleLeafHash(leaves[0])
+	}
+	k := splitPoint(len(leaves))
+	return merkleNodeHash(treeHash(leaves[:k]), treeHash(leaves[k:]))
+}
+
+// auditPath computes the RFC 6962 inclusion proof for leaf m
+func auditPath(m int64, leaves [][]byte) [][]byte {
+	if len(leaves) == 1 {
+		return nil
+	}
+	k := splitPoint(len(leaves))
+	if m < int64(k) {
+		return append(auditPath(m, leaves[:k]), treeHash(leaves[k:]))
+	}
+	return append(auditPath(m-int64(k), leaves[k:]), treeHash(leaves[:k]))
+}
+
+// splitPoint returns the largest power of two smaller than n
+func splitPoint(n int) int {
+	k := 1
+	for k<<1 < n {
+		k <<= 1
+	}
+	return k
+}
+
+func TestVerifyMerkleInclusion(t *testing.T) {
+	for size := 1; size <= 9; size++ {
+		leaves := make([][]byte, size)
+		for i := range leaves {
+			leaves[i] = []byte(fmt.Sprintf("leaf-%d", i))
+		}
+		root := treeHash(leaves)
+
+		for i := range leaves {
+			proof := auditPath(int64(i), leaves)
+			assert.NoError(t, verifyMerkleInclusion(int64(i), int64(size), merkleLeafHash(leaves[i]), proof, root), "size %d index %d", size, i)
+
+			// A proof for one leaf never proves another
+			other := merkleLeafHash([]byte("forged"))
+			assert.Error(t, verifyMerkleInclusion(int64(i), int64(size), other, proof, root))
+		}
+	}
+
+	assert.Error(t, verifyMerkleInclusion(3, 3, merkleLeafHash([]byte("x")), nil, nil))
+}
+
+func TestInclusionVerifier_Offline(t *testing.T) {
+	log := newTestLog(t)
+	payload := []byte(`{"critical":{"image":{"docker-manifest-digest":"sha256:abc"}}}`)
+	signature := []byte("signature-bytes")
+	log.add([]byte("other payload"), []byte("other signature"))
+	index := log.add(payload, signature)
+	log.add([]byte("later payload"), []byte("later signature"))
+
+	dir := t.TempDir()
+	bundlePath := filepath.Join(dir, "rekor.json")
+	keyPath := filepath.Join(dir, "rekor.pub")
+	bundle, err := json.Marshal(map[string]interface{}{"uuid-1": log.entry(t, index)})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(bundlePath, bundle, 0600))
+	require.NoError(t, os.WriteFile(keyPath, log.publicKeyPEM(t), 0600))
+
+	verifier, err := NewInclusionVerifier(InclusionOptions{BundlePath: bundlePath, PublicKeyPath: keyPath})
+	require.NoError(t, err)
+
+	entry, err := verifier.Verify(context.Background(), payload, base64.StdEncoding.EncodeToString(signature), 0)
+	require.NoError(t, err)
+	assert.Equal(t, "uuid-1", entry.UUID)
+	assert.Equal(t, int64(3), entry.TreeSize)
+
+	_, err = verifier.Verify(context.Background(), []byte("tampered"), base64.StdEncoding.EncodeToString(signature), 0)
+	assert.Error(t, err)
+}
+
+func TestInclusionVerifier_Online(t *testing.T) {
+	log := newTestLog(t)
+	payload := []byte("payload")
+	signature := []byte("signature")
+	index := log.add(payload, signature)
+	log.add([]byte("next"), []byte("next"))
+
+	forged := newTestLog(t)
+	signer := log
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/log/publicKey":
+			_, _ = w.Write(log.publicKeyPEM(t))
+		case "/api/v1/log/entries":
+			assert.Equal(t, "1000", r.URL.Query().Get("logIndex"))
+			signer.leaves = log.leaves
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"uuid-1": signer.entry(t, index)})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	verifier, err := NewInclusionVerifier(InclusionOptions{RekorURL: server.URL})
+	require.NoError(t, err)
+
+	entry, err := verifier.Verify(context.Background(), payload, base64.StdEncoding.EncodeToString(signature), 1000)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1000), entry.LogIndex)
+
+	// A checkpoint signed by another key is rejected
+	signer = forged
+	_, err = verifier.Verify(context.Background(), payload, base64.StdEncoding.EncodeToString(signature), 1000)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "not signed")
+}
+
+func TestInclusionVerifier_FetchesPublicKeyOnce(t *testing.T) {
+	log := newTestLog(t)
+	var fetches atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		_, _ = w.Write(log.publicKeyPEM(t))
+	}))
+	defer server.Close()
+
+	verifier, err := NewInclusionVerifier(InclusionOptions{RekorURL: server.URL})
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			key, err := verifier.rekorPublicKey(context.Background())
+			assert.NoError(t, err)
+			assert.NotNil(t, key)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), fetches.Load())
+}
+
+func TestNewInclusionVerifier_OfflineRequiresKey(t *testing.T) {
+	_, err := NewInclusionVerifier(InclusionOptions{BundlePath: "rekor.json"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "public key")
+}
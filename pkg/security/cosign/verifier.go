@@ -13,6 +13,9 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"time"
+
+	"freightliner/pkg/helper/log"
 
 	"github.com/google/go-containerregistry/pkg/name"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
@@ -34,6 +37,10 @@ type Signature struct {
 	Signature   []byte
 	Bundle      *bundle.RekorBundle
 
+	// RekorEntry is the log entry whose inclusion proof was verified, when
+	// inclusion proofs are required
+	RekorEntry *VerifiedEntry
+
 	// OIDC identity (for keyless)
 	Issuer  string
 	Subject string
@@ -59,6 +66,33 @@ type VerifierConfig struct {
 	RekorURL      string
 	FulcioURL     string
 
+	// RequireInclusionProof rejects signatures without a Rekor entry whose
+	// inclusion proof verifies against a checkpoint signed by the log
+	RequireInclusionProof bool
+
+	// RekorBundlePath verifies inclusion offline from a file of Rekor
+	// entries instead of querying RekorURL
+	RekorBundlePath string
+
+	// RekorPublicKeyPath pins the key that signs Rekor checkpoints
+	RekorPublicKeyPath string
+
+	// VerifyTime is the time keyless certificates must be valid at, for
+	// hosts without a trusted clock; zero uses the system clock
+	VerifyTime time.Time
+
+	// UseIntegratedTime checks keyless certificates at the time their
+	// signature was logged in Rekor. Requires RequireInclusionProof, which
+	// confirms that time against the log.
+	UseIntegratedTime bool
+
+	// InsecureIgnoreCertTime accepts keyless certificates outside their
+	// validity window. Every signature accepted this way is logged.
+	InsecureIgnoreCertTime bool
+
+	// Logger receives audit entries for relaxed certificate time checks
+	Logger log.Logger
+
 	// Policy configuration
 	Policy *Policy
 
@@ -70,8 +104,10 @@ type VerifierConfig struct {
 type Verifier struct {
 	config      *VerifierConfig
 	rekorClient *RekorClient
+	inclusion   *InclusionVerifier
 	policy      *Policy
 	verifiers   []signature.Verifier
+	certTime    certTimePolicy
 }
 
 // NewVerifier creates a new Cosign verifier with the given configuration
@@ -80,9 +116,21 @@ func NewVerifier(config *VerifierConfig) (*Verifier, error) {
 		return nil, fmt.Errorf("verifier config is required")
 	}
 
+	if config.UseIntegratedTime && !config.RequireInclusionProof {
+		return nil, fmt.Errorf("verifying certificates at the Rekor integrated time requires inclusion proofs")
+	}
+	if config.InsecureIgnoreCertTime && (config.UseIntegratedTime || !config.VerifyTime.IsZero()) {
+		return nil, fmt.Errorf("ignoring certificate time conflicts with a trusted verification time")
+	}
+
 	v := &Verifier{
 		config: config,
 		policy: config.Policy,
+		certTime: certTimePolicy{
+			pinned:     config.VerifyTime,
+			integrated: config.UseIntegratedTime,
+			ignore:     config.InsecureIgnoreCertTime,
+		},
 	}
 
 	// Initialize Rekor client if transparency log verification is needed
@@ -94,6 +142,18 @@ func NewVerifier(config *VerifierConfig) (*Verifier, error) {
 		v.rekorClient = NewRekorClient(rekorURL)
 	}
 
+	if config.RequireInclusionProof {
+		inclusion, err := NewInclusionVerifier(InclusionOptions{
+			RekorURL:      config.RekorURL,
+			BundlePath:    config.RekorBundlePath,
+			PublicKeyPath: config.RekorPublicKeyPath,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to set up Rekor inclusion verification: %w", err)
+		}
+		v.inclusion = inclusion
+	}
+
 	// Load verifiers based on configuration
 	if err := v.loadVerifiers(); err != nil {
 		return nil, fmt.Errorf("failed to load verifiers: %w", err)
@@ -168,6 +228,17 @@ func (v *Verifier) Verify(ctx context.Context, ref name.Reference) ([]Signature,
 			fmt.Fprintf(os.Stderr, "Warning: signature verification failed: %v\n", err)
 			continue
 		}
+		if v.inclusion != nil {
+			if err := v.verifyInclusion(ctx, verifiedSig, sig); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: Rekor inclusion verification failed: %v\n", err)
+				continue
+			}
+		}
+		if err := v.confirmIntegratedTime(verifiedSig); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: certificate time verification failed: %v\n", err)
+			continue
+		}
+		v.auditCertTime(ref, verifiedSig)
 		verified = append(verified, *verifiedSig)
 	}
 
@@ -274,7 +345,11 @@ func (v *Verifier) verifyKeyless(ctx context.Context, sig *Signature, ociSig oci
 	sig.Bundle = bundle
 
 	// Verify certificate chain
-	if err := v.verifyCertificateChain(sig.Certificate, sig.Chain); err != nil {
+	at, err := v.certTime.checkTime(sig.Certificate, integratedTime(bundle))
+	if err != nil {
+		return err
+	}
+	if err := v.verifyCertificateChain(sig.Certificate, sig.Chain, at); err != nil {
 		return fmt.Errorf("certificate chain verification failed: %w", err)
 	}
 
@@ -299,6 +374,84 @@ func (v *Verifier) verifyKeyless(ctx context.Context, sig *Signature, ociSig oci
 	return nil
 }
 
+// verifyInclusion checks that a verified signature is recorded in Rekor,
+// using the log index from the signature's bundle when it has one
+func (v *Verifier) verifyInclusion(ctx context.Context, sig *Signature, ociSig oci.Signature) error {
+	var logIndex int64
+	if sig.Bundle == nil {
+		if b, err := ociSig.Bundle(); err == nil {
+			sig.Bundle = b
+		}
+	}
+	if sig.Bundle != nil {
+		logIndex = sig.Bundle.Payload.LogIndex
+	}
+
+	entry, err := v.inclusion.Verify(ctx, sig.Payload, string(sig.Signature), logIndex)
+	if err != nil {
+		return err
+	}
+	sig.RekorEntry = entry
+	return nil
+}
+
+// confirmIntegratedTime checks that a certificate verified at the Rekor
+// integrated time was verified at the time the log itself records
+func (v *Verifier) confirmIntegratedTime(sig *Signature) error {
+	if !v.certTime.integrated || sig.Certificate == nil {
+		return nil
+	}
+	return confirmIntegratedTime(integratedTime(sig.Bundle), sig.RekorEntry)
+}
+
+// auditCertTime logs keyless signatures whose certificate was not checked
+// against the system clock, warning when the clock would have rejected it
+func (v *Verifier) auditCertTime(ref name.Reference, sig *Signature) {
+	if !v.certTime.relaxed() || sig.Certificate == nil {
+		return
+	}
+
+	now := time.Now()
+	fields := map[string]interface{}{
+		"image":      ref.String(),
+		"subject":    sig.Subject,
+		"issuer":     sig.Issuer,
+		"not_before": sig.Certificate.NotBefore.Format(time.RFC3339),
+		"not_after":  sig.Certificate.NotAfter.Format(time.RFC3339),
+		"valid_now":  validAt(sig.Certificate, now),
+	}
+	msg := "Verified signature certificate at trusted time"
+	switch {
+	case v.certTime.ignore:
+		fields["time_check"] = "ignored"
+		msg = "Accepted signature certificate without checking its validity window"
+	case v.certTime.integrated:
+		fields["time_check"] = "rekor"
+		fields["verified_at"] = time.Unix(integratedTime(sig.Bundle), 0).UTC().Format(time.RFC3339)
+	default:
+		fields["time_check"] = "pinned"
+		fields["verified_at"] = v.certTime.pinned.UTC().Format(time.RFC3339)
+	}
+
+	if v.config.Logger == nil {
+		fmt.Fprintf(os.Stderr, "Warning: %s: %v\n", msg, fields)
+		return
+	}
+	if v.certTime.ignore {
+		v.config.Logger.WithFields(fields).Warn(msg)
+		return
+	}
+	v.config.Logger.WithFields(fields).Info(msg)
+}
+
+// integratedTime returns the Rekor integrated time of b, or 0 without one
+func integratedTime(b *bundle.RekorBundle) int64 {
+	if b == nil {
+		return 0
+	}
+	return b.Payload.IntegratedTime
+}
+
 // verifyWithPublicKey verifies signature using configured public keys
 func (v *Verifier) verifyWithPublicKey(ctx context.Context, sig *Signature) error {
 	var lastErr error
@@ -320,8 +473,9 @@ func (v *Verifier) verifyWithPublicKey(ctx context.Context, sig *Signature) erro
 	return fmt.Errorf("no verifiers available")
 }
 
-// verifyCertificateChain verifies the X.509 certificate chain
-func (v *Verifier) verifyCertificateChain(cert *x509.Certificate, chain []*x509.Certificate) error {
+// verifyCertificateChain verifies the X.509 certificate chain as of at, or
+// of the system clock when at is zero
+func (v *Verifier) verifyCertificateChain(cert *x509.Certificate, chain []*x509.Certificate, at time.Time) error {
 	// Build certificate pool with chain
 	roots := x509.NewCertPool()
 	intermediates := x509.NewCertPool()
@@ -339,6 +493,7 @@ func (v *Verifier) verifyCertificateChain(cert *x509.Certificate, chain []*x509.
 		Roots:         roots,
 		Intermediates: intermediates,
 		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+		CurrentTime:   at,
 	}
 
 	if _, err := cert.Verify(opts); err != nil {
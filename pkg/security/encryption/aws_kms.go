@@ -42,6 +42,21 @@ func NewAWSKMS(ctx context.Context, opts AWSOpts) (*AWSKMS, error) {
 		return nil, errors.InvalidInputf("AWS region is required")
 	}
 
+	kmsClient, err := newAWSKMSClient(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AWSKMS{
+		client: kmsClient,
+		region: opts.Region,
+		keyID:  opts.KeyID,
+	}, nil
+}
+
+// newAWSKMSClient creates a KMS client for opts.Region using the profile or
+// role in opts, or the default credential chain
+func newAWSKMSClient(ctx context.Context, opts AWSOpts) (*kms.Client, error) {
 	// Load AWS config
 	var configOpts []func(*config.LoadOptions) error
 	configOpts = append(configOpts, config.WithRegion(opts.Region))
@@ -77,11 +92,7 @@ func NewAWSKMS(ctx context.Context, opts AWSOpts) (*AWSKMS, error) {
 		kmsClient = kms.NewFromConfig(cfg)
 	}
 
-	return &AWSKMS{
-		client: kmsClient,
-		region: opts.Region,
-		keyID:  opts.KeyID,
-	}, nil
+	return kmsClient, nil
 }
 
 // Encrypt encrypts plaintext using AWS KMS
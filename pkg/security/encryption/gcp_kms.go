@@ -37,15 +37,9 @@ type GCPOpts struct {
 
 // NewGCPKMS creates a new Google Cloud KMS provider
 func NewGCPKMS(ctx context.Context, opts GCPOpts) (*GCPKMS, error) {
-	var clientOpts []option.ClientOption
-
-	if opts.CredentialsFile != "" {
-		clientOpts = append(clientOpts, option.WithCredentialsFile(opts.CredentialsFile))
-	}
-
-	client, err := kms.NewKeyManagementClient(ctx, clientOpts...)
+	client, err := newGCPKMSClient(ctx, opts.CredentialsFile)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to create KMS client")
+		return nil, err
 	}
 
 	// Validate required fields
@@ -86,6 +80,22 @@ func NewGCPKMS(ctx context.Context, opts GCPOpts) (*GCPKMS, error) {
 	}, nil
 }
 
+// newGCPKMSClient creates a KMS client using credentialsFile, or application
+// default credentials when empty
+func newGCPKMSClient(ctx context.Context, credentialsFile string) (*kms.KeyManagementClient, error) {
+	var clientOpts []option.ClientOption
+
+	if credentialsFile != "" {
+		clientOpts = append(clientOpts, option.WithCredentialsFile(credentialsFile))
+	}
+
+	client, err := kms.NewKeyManagementClient(ctx, clientOpts...)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create KMS client")
+	}
+	return client, nil
+}
+
 // Encrypt encrypts the plaintext using the GCP KMS key
 func (g *GCPKMS) Encrypt(ctx context.Context, plaintext []byte) ([]byte, error) {
 	if len(plaintext) == 0 {
@@ -0,0 +1,244 @@
+package encryption
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"io"
+	"strings"
+	"time"
+
+	"freightliner/pkg/attestation"
+	"freightliner/pkg/helper/errors"
+
+	"cloud.google.com/go/kms/apiv1/kmspb"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+)
+
+const (
+	// AWSKMSKeyScheme selects a signing key held in AWS KMS, e.g.
+	// awskms:///arn:aws:kms:us-east-1:123456789012:key/1234abcd or awskms:///alias/signing
+	AWSKMSKeyScheme = "awskms://"
+
+	// GCPKMSKeyScheme selects a signing key version held in Cloud KMS, e.g.
+	// gcpkms://projects/p/locations/global/keyRings/r/cryptoKeys/k/cryptoKeyVersions/1
+	GCPKMSKeyScheme = "gcpkms://"
+
+	// kmsSignTimeout bounds a single remote Sign call
+	kmsSignTimeout = 30 * time.Second
+)
+
+// SignerOptions holds the credentials used to reach KMS-held signing keys.
+// They are the same credentials the encryption providers use.
+type SignerOptions struct {
+	// AWS selects the region, profile and role for awskms:// keys. The region
+	// of a key ARN takes precedence over AWS.Region.
+	AWS AWSOpts
+
+	// GCPCredentialsFile is a service account key for gcpkms:// keys; application
+	// default credentials are used when empty
+	GCPCredentialsFile string
+}
+
+// IsKMSKeyRef reports whether ref names a KMS-held key rather than a key file
+func IsKMSKeyRef(ref string) bool {
+	return strings.HasPrefix(ref, AWSKMSKeyScheme) || strings.HasPrefix(ref, GCPKMSKeyScheme)
+}
+
+// NewSigner returns a signer for ref. awskms:// and gcpkms:// references sign
+// through the KMS Sign API so the private key never leaves KMS; anything else
+// is read as a PEM private key file.
+func NewSigner(ctx context.Context, ref string, opts SignerOptions) (crypto.Signer, error) {
+	switch {
+	case strings.HasPrefix(ref, AWSKMSKeyScheme):
+		return NewAWSKMSSigner(ctx, ref, opts.AWS)
+	case strings.HasPrefix(ref, GCPKMSKeyScheme):
+		return NewGCPKMSSigner(ctx, ref, opts.GCPCredentialsFile)
+	default:
+		return attestation.LoadSigner(ref)
+	}
+}
+
+// kmsSigner is a crypto.Signer whose private key is held by a KMS
+type kmsSigner struct {
+	ref    string
+	public crypto.PublicKey
+	sign   func(ctx context.Context, digest []byte, opts crypto.SignerOpts) ([]byte, error)
+}
+
+// Public returns the public half of the KMS key
+func (s *kmsSigner) Public() crypto.PublicKey {
+	return s.public
+}
+
+// Sign signs a SHA-256 digest with the KMS key. ECDSA signatures are ASN.1
+// encoded, as crypto.Signer requires.
+func (s *kmsSigner) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	if opts == nil || opts.HashFunc() != crypto.SHA256 || len(digest) != crypto.SHA256.Size() {
+		return nil, errors.InvalidInputf("KMS key %s only signs SHA-256 digests", s.ref)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), kmsSignTimeout)
+	defer cancel()
+
+	sig, err := s.sign(ctx, digest, opts)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to sign with KMS key %s", s.ref)
+	}
+	return sig, nil
+}
+
+// NewAWSKMSSigner returns a signer for an awskms:// key reference
+func NewAWSKMSSigner(ctx context.Context, ref string, opts AWSOpts) (crypto.Signer, error) {
+	keyID, region, err := parseAWSKMSRef(ref)
+	if err != nil {
+		return nil, err
+	}
+	if region != "" {
+		opts.Region = region
+	}
+	if opts.Region == "" {
+		return nil, errors.InvalidInputf("AWS region is required for KMS key %s", ref)
+	}
+
+	client, err := newAWSKMSClient(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := client.GetPublicKey(ctx, &kms.GetPublicKeyInput{KeyId: aws.String(keyID)})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get public key of KMS key %s", ref)
+	}
+	public, err := x509.ParsePKIXPublicKey(out.PublicKey)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse public key of KMS key %s", ref)
+	}
+
+	return &kmsSigner{
+		ref:    ref,
+		public: public,
+		sign: func(ctx context.Context, digest []byte, signerOpts crypto.SignerOpts) ([]byte, error) {
+			algorithm, err := awsSigningAlgorithm(public, signerOpts)
+			if err != nil {
+				return nil, err
+			}
+			out, err := client.Sign(ctx, &kms.SignInput{
+				KeyId:            aws.String(keyID),
+				Message:          digest,
+				MessageType:      types.MessageTypeDigest,
+				SigningAlgorithm: algorithm,
+			})
+			if err != nil {
+				return nil, err
+			}
+			return out.Signature, nil
+		},
+	}, nil
+}
+
+// NewGCPKMSSigner returns a signer for a gcpkms:// key version reference
+func NewGCPKMSSigner(ctx context.Context, ref string, credentialsFile string) (crypto.Signer, error) {
+	keyVersion, err := parseGCPKMSRef(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := newGCPKMSClient(ctx, credentialsFile)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.GetPublicKey(ctx, &kmspb.GetPublicKeyRequest{Name: keyVersion})
+	if err != nil {
+		_ = client.Close()
+		return nil, errors.Wrapf(err, "failed to get public key of KMS key %s", ref)
+	}
+	block, _ := pem.Decode([]byte(resp.Pem))
+	if block == nil {
+		_ = client.Close()
+		return nil, errors.InvalidInputf("public key of KMS key %s is not PEM encoded", ref)
+	}
+	public, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		_ = client.Close()
+		return nil, errors.Wrapf(err, "failed to parse public key of KMS key %s", ref)
+	}
+
+	return &kmsSigner{
+		ref:    ref,
+		public: public,
+		sign: func(ctx context.Context, digest []byte, _ crypto.SignerOpts) ([]byte, error) {
+			resp, err := client.AsymmetricSign(ctx, &kmspb.AsymmetricSignRequest{
+				Name:   keyVersion,
+				Digest: &kmspb.Digest{Digest: &kmspb.Digest_Sha256{Sha256: digest}},
+			})
+			if err != nil {
+				return nil, err
+			}
+			return resp.Signature, nil
+		},
+	}, nil
+}
+
+// awsSigningAlgorithm picks the KMS signing algorithm for a SHA-256 digest
+func awsSigningAlgorithm(public crypto.PublicKey, opts crypto.SignerOpts) (types.SigningAlgorithmSpec, error) {
+	switch key := public.(type) {
+	case *ecdsa.PublicKey:
+		if key.Curve != elliptic.P256() {
+			return "", errors.InvalidInputf("ECDSA KMS keys must use P-256 to sign SHA-256 digests")
+		}
+		return types.SigningAlgorithmSpecEcdsaSha256, nil
+	case *rsa.PublicKey:
+		if _, ok := opts.(*rsa.PSSOptions); ok {
+			return types.SigningAlgorithmSpecRsassaPssSha256, nil
+		}
+		return types.SigningAlgorithmSpecRsassaPkcs1V15Sha256, nil
+	default:
+		return "", errors.InvalidInputf("unsupported KMS public key type %T", public)
+	}
+}
+
+// parseAWSKMSRef splits awskms://[endpoint]/key into the key ID and, for key
+// ARNs, the key's region
+func parseAWSKMSRef(ref string) (keyID, region string, err error) {
+	rest := strings.TrimPrefix(ref, AWSKMSKeyScheme)
+	endpoint, keyID, found := strings.Cut(rest, "/")
+	if !found || keyID == "" {
+		return "", "", errors.InvalidInputf("invalid AWS KMS key reference %q, expected awskms:///<key id, alias or ARN>", ref)
+	}
+	if endpoint != "" {
+		return "", "", errors.InvalidInputf("custom KMS endpoints are not supported in %q", ref)
+	}
+
+	if strings.HasPrefix(keyID, "arn:") {
+		parts := strings.Split(keyID, ":")
+		if len(parts) < 6 || parts[2] != "kms" || parts[3] == "" {
+			return "", "", errors.InvalidInputf("invalid AWS KMS key ARN %q", keyID)
+		}
+		region = parts[3]
+	}
+	return keyID, region, nil
+}
+
+// parseGCPKMSRef returns the key version resource name of a gcpkms:// reference
+func parseGCPKMSRef(ref string) (string, error) {
+	keyVersion := strings.TrimPrefix(ref, GCPKMSKeyScheme)
+	parts := strings.Split(keyVersion, "/")
+	if len(parts) != 10 || parts[0] != "projects" || parts[2] != "locations" ||
+		parts[4] != "keyRings" || parts[6] != "cryptoKeys" || parts[8] != "cryptoKeyVersions" {
+		return "", errors.InvalidInputf("invalid GCP KMS key reference %q, expected gcpkms://projects/<p>/locations/<l>/keyRings/<r>/cryptoKeys/<k>/cryptoKeyVersions/<v>", ref)
+	}
+	for _, part := range parts {
+		if part == "" {
+			return "", errors.InvalidInputf("invalid GCP KMS key reference %q", ref)
+		}
+	}
+	return keyVersion, nil
+}
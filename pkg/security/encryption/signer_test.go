@@ -0,0 +1,126 @@
+package encryption
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAWSKMSRef(t *testing.T) {
+	tests := []struct {
+		ref       string
+		keyID     string
+		region    string
+		wantError bool
+	}{
+		{ref: "awskms:///arn:aws:kms:eu-west-1:123456789012:key/1234abcd", keyID: "arn:aws:kms:eu-west-1:123456789012:key/1234abcd", region: "eu-west-1"},
+		{ref: "awskms:///alias/signing", keyID: "alias/signing"},
+		{ref: "awskms:///1234abcd", keyID: "1234abcd"},
+		{ref: "awskms://localhost:4566/alias/signing", wantError: true},
+		{ref: "awskms://", wantError: true},
+		{ref: "awskms:///arn:aws:s3:::bucket", wantError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			keyID, region, err := parseAWSKMSRef(tt.ref)
+			if tt.wantError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.keyID, keyID)
+			assert.Equal(t, tt.region, region)
+		})
+	}
+}
+
+func TestParseGCPKMSRef(t *testing.T) {
+	name, err := parseGCPKMSRef("gcpkms://projects/p/locations/global/keyRings/r/cryptoKeys/k/cryptoKeyVersions/1")
+	require.NoError(t, err)
+	assert.Equal(t, "projects/p/locations/global/keyRings/r/cryptoKeys/k/cryptoKeyVersions/1", name)
+
+	_, err = parseGCPKMSRef("gcpkms://projects/p/locations/global/keyRings/r/cryptoKeys/k")
+	assert.Error(t, err)
+	_, err = parseGCPKMSRef("gcpkms://projects//locations/global/keyRings/r/cryptoKeys/k/cryptoKeyVersions/1")
+	assert.Error(t, err)
+}
+
+func TestAWSSigningAlgorithm(t *testing.T) {
+	p256, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	algorithm, err := awsSigningAlgorithm(&p256.PublicKey, crypto.SHA256)
+	require.NoError(t, err)
+	assert.Equal(t, types.SigningAlgorithmSpecEcdsaSha256, algorithm)
+
+	algorithm, err = awsSigningAlgorithm(&rsaKey.PublicKey, crypto.SHA256)
+	require.NoError(t, err)
+	assert.Equal(t, types.SigningAlgorithmSpecRsassaPkcs1V15Sha256, algorithm)
+
+	algorithm, err = awsSigningAlgorithm(&rsaKey.PublicKey, &rsa.PSSOptions{Hash: crypto.SHA256})
+	require.NoError(t, err)
+	assert.Equal(t, types.SigningAlgorithmSpecRsassaPssSha256, algorithm)
+
+	_, err = awsSigningAlgorithm(&p384.PublicKey, crypto.SHA256)
+	assert.Error(t, err)
+}
+
+func TestKMSSigner_Sign(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	signer := &kmsSigner{
+		ref:    "awskms:///alias/test",
+		public: &key.PublicKey,
+		sign: func(_ context.Context, digest []byte, _ crypto.SignerOpts) ([]byte, error) {
+			return ecdsa.SignASN1(rand.Reader, key, digest)
+		},
+	}
+
+	digest := sha256.Sum256([]byte("payload"))
+	sig, err := signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	require.NoError(t, err)
+	assert.True(t, ecdsa.VerifyASN1(&key.PublicKey, digest[:], sig))
+
+	// Only SHA-256 digests are sent to KMS
+	_, err = signer.Sign(rand.Reader, []byte("message"), crypto.Hash(0))
+	assert.Error(t, err)
+	_, err = signer.Sign(rand.Reader, digest[:16], crypto.SHA256)
+	assert.Error(t, err)
+}
+
+func TestNewSigner_KeyFile(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "key.pem")
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600))
+
+	assert.False(t, IsKMSKeyRef(path))
+	assert.True(t, IsKMSKeyRef("awskms:///alias/signing"))
+	assert.True(t, IsKMSKeyRef("gcpkms://projects/p/locations/l/keyRings/r/cryptoKeys/k/cryptoKeyVersions/1"))
+
+	signer, err := NewSigner(context.Background(), path, SignerOptions{})
+	require.NoError(t, err)
+	assert.True(t, key.PublicKey.Equal(signer.Public()))
+}
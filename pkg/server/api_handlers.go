@@ -22,34 +22,81 @@ func (s *Server) cancelJobHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get the job
-	job, exists := s.jobManager.GetJob(jobID)
+	job, exists := s.lookupJob(r, jobID)
 	if !exists {
 		s.writeErrorResponse(w, http.StatusNotFound, fmt.Sprintf("Job %s not found", jobID))
 		return
 	}
 
-	// Check if job can be cancelled
-	status := job.GetStatus()
-	if status == JobStatusCompleted || status == JobStatusFailed || status == JobStatusCancelled {
-		s.writeErrorResponse(w, http.StatusBadRequest,
-			fmt.Sprintf("Job %s cannot be cancelled (status: %s)", jobID, status))
+	// Cancel the job's context and mark it canceled
+	done, err := s.jobManager.CancelJob(jobID)
+	if err != nil {
+		s.writeErrorResponse(w, http.StatusConflict,
+			fmt.Sprintf("Job %s cannot be canceled (status: %s)", jobID, job.GetStatus()))
 		return
 	}
 
-	// Cancel the job
-	if err := s.cancelJob(job); err != nil {
-		s.writeErrorResponse(w, http.StatusInternalServerError,
-			fmt.Sprintf("Failed to cancel job: %s", err))
-		return
+	s.logger.WithFields(map[string]interface{}{
+		"job_id": jobID,
+		"type":   job.GetType(),
+		"tenant": job.GetTenant(),
+	}).Info("Canceling job")
+
+	// Wait for the job's workers to drain, which also finalizes its checkpoint
+	drained := true
+	timer := time.NewTimer(s.cfg.Server.ShutdownTimeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+	case <-timer.C:
+		drained = false
+	case <-r.Context().Done():
+		drained = false
 	}
 
-	s.writeResponse(w, http.StatusOK, map[string]interface{}{
+	status := http.StatusOK
+	message := "Job canceled"
+	if !drained {
+		status = http.StatusAccepted
+		message = "Job canceled, workers are still draining"
+	}
+	s.writeResponse(w, status, map[string]interface{}{
 		"job_id":  jobID,
-		"status":  string(JobStatusCancelled),
-		"message": "Job cancellation initiated",
+		"status":  string(JobStatusCanceled),
+		"drained": drained,
+		"message": message,
 	})
 }
 
+// jobResultHandler returns the result of a finished job. Jobs still pending
+// or running return 409, so clients can poll it instead of the full job.
+func (s *Server) jobResultHandler(w http.ResponseWriter, r *http.Request) {
+	jobID := mux.Vars(r)["id"]
+
+	job, exists := s.lookupJob(r, jobID)
+	if !exists {
+		s.writeErrorResponse(w, http.StatusNotFound, fmt.Sprintf("Job %s not found", jobID))
+		return
+	}
+
+	status := job.GetStatus()
+	if status == JobStatusPending || status == JobStatusRunning {
+		s.writeErrorResponse(w, http.StatusConflict,
+			fmt.Sprintf("Job %s has not finished (status: %s)", jobID, status))
+		return
+	}
+
+	response := map[string]interface{}{
+		"job_id": jobID,
+		"status": string(status),
+		"result": job.GetResult(),
+	}
+	if err := job.GetError(); err != nil {
+		response["error"] = err.Error()
+	}
+	s.writeResponse(w, http.StatusOK, response)
+}
+
 // retryJobHandler handles job retry requests
 func (s *Server) retryJobHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
@@ -61,7 +108,7 @@ func (s *Server) retryJobHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get the job
-	job, exists := s.jobManager.GetJob(jobID)
+	job, exists := s.lookupJob(r, jobID)
 	if !exists {
 		s.writeErrorResponse(w, http.StatusNotFound, fmt.Sprintf("Job %s not found", jobID))
 		return
@@ -69,7 +116,7 @@ func (s *Server) retryJobHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Check if job can be retried
 	status := job.GetStatus()
-	if status != JobStatusFailed && status != JobStatusCancelled {
+	if status != JobStatusFailed && status != JobStatusCanceled && status != JobStatusCancelled {
 		s.writeErrorResponse(w, http.StatusBadRequest,
 			fmt.Sprintf("Job %s cannot be retried (status: %s)", jobID, status))
 		return
@@ -83,15 +130,13 @@ func (s *Server) retryJobHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Add new job to manager
-	s.jobManager.AddJob(newJob)
+	// Add new job to manager, subject to the same tenant quota as new jobs
+	if !s.admitJob(w, tenantFromContext(r.Context()), newJob) {
+		return
+	}
 
 	// Submit to worker pool
-	err = s.workerPool.Submit(newJob.GetID(), func(ctx context.Context) error {
-		newJob.SetStatus(JobStatusRunning)
-		return newJob.Execute(ctx)
-	})
-
+	err = s.submitJob(newJob)
 	if err != nil {
 		newJob.SetStatus(JobStatusFailed)
 		newJob.SetError(fmt.Errorf("failed to submit retry job: %w", err))
@@ -200,6 +245,13 @@ func (s *Server) getWorkerPoolStatsHandler(w http.ResponseWriter, r *http.Reques
 			"running":  stats.RunningJobs,
 			"complete": stats.CompletedJobs,
 			"failed":   stats.FailedJobs,
+			"panicked": stats.PanickedJobs,
+		},
+		"queue": map[string]interface{}{
+			"depth":             stats.QueuedJobs,
+			"capacity":          stats.QueueCapacity,
+			"avg_queue_latency": stats.AvgQueueLatency,
+			"max_queue_latency": stats.MaxQueueLatency,
 		},
 		"performance": map[string]interface{}{
 			"avg_job_duration": stats.AvgJobDuration,
@@ -240,39 +292,23 @@ func (s *Server) getSystemHealthHandler(w http.ResponseWriter, r *http.Request)
 
 // Helper functions
 
-func (s *Server) cancelJob(job Job) error {
-	// Set job status to cancelled
-	job.SetStatus(JobStatusCancelled)
-
-	// The actual cancellation depends on the job type and implementation
-	// For now, we just mark it as cancelled
-	// In a production system, we would:
-	// 1. Cancel the job's context
-	// 2. Wait for graceful shutdown
-	// 3. Clean up resources
-
-	s.logger.WithFields(map[string]interface{}{
-		"job_id": job.GetID(),
-		"type":   job.GetType(),
-	}).Info("Job cancelled")
-
-	return nil
-}
-
 func (s *Server) cloneJob(originalJob Job) (Job, error) {
 	// Clone based on job type
 	switch originalJob.GetType() {
 	case JobTypeReplicate:
 		// Type assert to access specific fields
 		if replicateJob, ok := originalJob.(*ReplicateJob); ok {
-			return NewReplicateJob(
+			// The clone keeps the original tenant and its services
+			job := NewReplicateJob(
 				replicateJob.Source,
 				replicateJob.Destination,
 				replicateJob.Tags,
 				replicateJob.Force,
 				replicateJob.DryRun,
-				s.replicationSvc,
-			), nil
+				replicateJob.svc,
+			)
+			job.Tenant = replicateJob.Tenant
+			return job, nil
 		}
 
 	case JobTypeReplicateTree:
@@ -291,12 +327,14 @@ func (s *Server) cloneJob(originalJob Job) (Job, error) {
 				"skipCompleted":    treeJob.SkipCompleted,
 				"retryFailed":      treeJob.RetryFailed,
 			}
-			return NewReplicateTreeJob(
+			job := NewReplicateTreeJob(
 				treeJob.Source,
 				treeJob.Destination,
 				options,
-				s.treeReplicationSvc,
-			), nil
+				treeJob.svc,
+			)
+			job.Tenant = treeJob.Tenant
+			return job, nil
 		}
 	}
 
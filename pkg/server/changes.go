@@ -0,0 +1,186 @@
+package server
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// maxTagChanges bounds the changes a rule's feed keeps. Clients asking for
+// changes since an older revision get the whole tag list instead.
+const maxTagChanges = 1000
+
+// Tag change kinds
+const (
+	TagAdded   = "added"
+	TagRemoved = "removed"
+	TagChanged = "changed"
+)
+
+// TagChange is a tag that appeared, disappeared or moved to another digest
+// between two snapshots of a rule's tag list
+type TagChange struct {
+	Revision       int64     `json:"revision"`
+	Time           time.Time `json:"time"`
+	Tag            string    `json:"tag"`
+	Change         string    `json:"change"`
+	Digest         string    `json:"digest,omitempty"`
+	PreviousDigest string    `json:"previous_digest,omitempty"`
+}
+
+// ruleTags is the latest tag snapshot of one rule and the changes leading
+// to it, oldest first
+type ruleTags struct {
+	revision int64
+	tags     map[string]string
+	changes  []TagChange
+
+	// trimmed is the newest revision whose changes were dropped
+	trimmed int64
+}
+
+// tagFeed keeps a snapshot of the tag list of every rule the scheduler runs,
+// taken on each run, and the changes between snapshots. A snapshot that
+// changes something gets the next revision of its rule. Snapshots live in
+// memory, so revisions start over when the server restarts.
+type tagFeed struct {
+	mu    sync.Mutex
+	rules map[string]*ruleTags
+}
+
+// newTagFeed creates an empty tag feed
+func newTagFeed() *tagFeed {
+	return &tagFeed{rules: make(map[string]*ruleTags)}
+}
+
+// record stores tags, by tag name to digest, as the current snapshot of rule
+// and returns the changes it makes
+func (f *tagFeed) record(rule string, tags map[string]string, now time.Time) []TagChange {
+	if f == nil {
+		return nil
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	state, ok := f.rules[rule]
+	if !ok {
+		state = &ruleTags{tags: map[string]string{}}
+		f.rules[rule] = state
+	}
+
+	revision := state.revision + 1
+	var changes []TagChange
+	for tag, digest := range tags {
+		previous, existed := state.tags[tag]
+		// A tag that could not be inspected this time keeps its last digest
+		if digest == "" && existed {
+			tags[tag] = previous
+			continue
+		}
+		switch {
+		case !existed:
+			changes = append(changes, TagChange{Tag: tag, Change: TagAdded, Digest: digest})
+		case previous != digest:
+			changes = append(changes, TagChange{Tag: tag, Change: TagChanged, Digest: digest, PreviousDigest: previous})
+		}
+	}
+	for tag, digest := range state.tags {
+		if _, ok := tags[tag]; !ok {
+			changes = append(changes, TagChange{Tag: tag, Change: TagRemoved, PreviousDigest: digest})
+		}
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Tag < changes[j].Tag })
+	for i := range changes {
+		changes[i].Revision = revision
+		changes[i].Time = now
+	}
+
+	state.revision = revision
+	state.tags = tags
+	state.changes = append(state.changes, changes...)
+	if excess := len(state.changes) - maxTagChanges; excess > 0 {
+		// Drop whole revisions, so every revision kept is complete
+		state.trimmed = state.changes[excess-1].Revision
+		for excess < len(state.changes) && state.changes[excess].Revision == state.trimmed {
+			excess++
+		}
+		state.changes = append([]TagChange(nil), state.changes[excess:]...)
+	}
+	return changes
+}
+
+// since returns the changes of rule after revision and the current revision.
+// When the feed no longer holds every change after revision, or revision is
+// from before a restart, reset is true and changes lists every current tag as
+// added. ok is false when no snapshot of rule was taken yet.
+func (f *tagFeed) since(rule string, revision int64) (changes []TagChange, current int64, reset, ok bool) {
+	if f == nil {
+		return nil, 0, false, false
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	state, ok := f.rules[rule]
+	if !ok {
+		return nil, 0, false, false
+	}
+
+	if revision > state.revision || revision < state.trimmed {
+		changes = make([]TagChange, 0, len(state.tags))
+		for tag, digest := range state.tags {
+			changes = append(changes, TagChange{Revision: state.revision, Tag: tag, Change: TagAdded, Digest: digest})
+		}
+		sort.Slice(changes, func(i, j int) bool { return changes[i].Tag < changes[j].Tag })
+		return changes, state.revision, true, true
+	}
+
+	changes = []TagChange{}
+	for _, change := range state.changes {
+		if change.Revision > revision {
+			changes = append(changes, change)
+		}
+	}
+	return changes, state.revision, false, true
+}
+
+// ruleChangesHandler lists the tags added, removed or changed in a rule's
+// repository since the revision in the since query parameter
+func (s *Server) ruleChangesHandler(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	var revision int64
+	if value := r.URL.Query().Get("since"); value != "" {
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || n < 0 {
+			s.writeErrorResponse(w, http.StatusBadRequest, "since must be a revision number")
+			return
+		}
+		revision = n
+	}
+
+	if s.pruneScheduler == nil || !s.pruneScheduler.hasRule(id) {
+		s.writeErrorResponse(w, http.StatusNotFound, "No scheduled rule "+id)
+		return
+	}
+
+	changes, current, reset, ok := s.pruneScheduler.changes.since(id, revision)
+	if !ok {
+		changes = []TagChange{}
+	}
+	s.writeResponse(w, http.StatusOK, map[string]interface{}{
+		"rule":     id,
+		"since":    revision,
+		"revision": current,
+		"reset":    reset,
+		"changes":  changes,
+		"count":    len(changes),
+	})
+}
@@ -0,0 +1,129 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"freightliner/pkg/sync"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTagFeed(t *testing.T) {
+	feed := newTagFeed()
+	now := time.Date(2026, 6, 1, 3, 0, 0, 0, time.UTC)
+
+	changes := feed.record("mirror/app", map[string]string{"v1": "sha256:a", "v2": "sha256:b"}, now)
+	require.Len(t, changes, 2)
+	assert.Equal(t, TagChange{Revision: 1, Time: now, Tag: "v1", Change: TagAdded, Digest: "sha256:a"}, changes[0])
+
+	assert.Nil(t, feed.record("mirror/app", map[string]string{"v1": "sha256:a", "v2": "sha256:b"}, now), "an unchanged list is no new revision")
+
+	changes = feed.record("mirror/app", map[string]string{"v2": "sha256:c", "v3": "sha256:d"}, now)
+	assert.Equal(t, []TagChange{
+		{Revision: 2, Time: now, Tag: "v1", Change: TagRemoved, PreviousDigest: "sha256:a"},
+		{Revision: 2, Time: now, Tag: "v2", Change: TagChanged, Digest: "sha256:c", PreviousDigest: "sha256:b"},
+		{Revision: 2, Time: now, Tag: "v3", Change: TagAdded, Digest: "sha256:d"},
+	}, changes)
+
+	assert.Nil(t, feed.record("mirror/app", map[string]string{"v2": "", "v3": "sha256:d"}, now), "a tag that failed inspection keeps its digest")
+
+	since, revision, reset, ok := feed.since("mirror/app", 1)
+	require.True(t, ok)
+	assert.False(t, reset)
+	assert.Equal(t, int64(2), revision)
+	assert.Len(t, since, 3)
+
+	since, _, reset, _ = feed.since("mirror/app", 2)
+	assert.False(t, reset)
+	assert.Empty(t, since)
+
+	since, _, reset, _ = feed.since("mirror/app", 7)
+	assert.True(t, reset, "a revision from before a restart gets the whole list")
+	assert.Equal(t, []TagChange{
+		{Revision: 2, Tag: "v2", Change: TagAdded, Digest: "sha256:c"},
+		{Revision: 2, Tag: "v3", Change: TagAdded, Digest: "sha256:d"},
+	}, since)
+
+	_, _, _, ok = feed.since("mirror/other", 0)
+	assert.False(t, ok)
+}
+
+func TestTagFeedTrimsWholeRevisions(t *testing.T) {
+	feed := newTagFeed()
+	size := maxTagChanges * 3 / 5
+	first, second := map[string]string{}, map[string]string{}
+	for i := 0; i < size; i++ {
+		first[fmt.Sprintf("v%d", i)] = "sha256:a"
+		second[fmt.Sprintf("v%d", i)] = "sha256:b"
+	}
+	feed.record("mirror/app", first, time.Now())
+	feed.record("mirror/app", second, time.Now())
+
+	_, _, reset, _ := feed.since("mirror/app", 0)
+	assert.True(t, reset, "revision 1 was trimmed")
+
+	since, revision, reset, _ := feed.since("mirror/app", 1)
+	assert.False(t, reset)
+	assert.Equal(t, int64(2), revision)
+	assert.Len(t, since, size)
+}
+
+func TestRuleChangesHandler(t *testing.T) {
+	server := createTestServer(t)
+	rule := sync.PruneRule{Repository: "mirror/app", KeepLast: 3, Schedule: "@hourly"}
+	listed := []sync.PruneDecision{{Tag: "v1", Digest: "sha256:a"}}
+	server.pruneScheduler = &pruneScheduler{
+		server:  server,
+		syncCfg: &sync.Config{Prune: []sync.PruneRule{rule}},
+		prune: func(ctx context.Context, r sync.PruneRule) (*sync.PruneResult, error) {
+			return &sync.PruneResult{Repository: r.Repository, Kept: listed}, nil
+		},
+		changes: newTagFeed(),
+	}
+
+	get := func(path string) (int, map[string]interface{}) {
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		var body map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		return w.Code, body
+	}
+
+	code, body := get("/api/v1/rules/mirror/app/changes")
+	assert.Equal(t, http.StatusOK, code)
+	assert.EqualValues(t, 0, body["count"], "no run yet")
+
+	prune := server.pruneScheduler.recordRuns(rule.Repository)
+	_, err := prune(context.Background(), rule)
+	require.NoError(t, err)
+	listed = []sync.PruneDecision{{Tag: "v1", Digest: "sha256:b"}, {Tag: "v2", Digest: "sha256:c"}}
+	_, err = prune(context.Background(), rule)
+	require.NoError(t, err)
+
+	// A run that could not list tags takes no snapshot
+	server.pruneScheduler.prune = func(ctx context.Context, r sync.PruneRule) (*sync.PruneResult, error) {
+		return &sync.PruneResult{Repository: r.Repository}, errors.New("registry unavailable")
+	}
+	_, err = prune(context.Background(), rule)
+	require.Error(t, err)
+
+	code, body = get("/api/v1/rules/mirror/app/changes?since=1")
+	assert.Equal(t, http.StatusOK, code)
+	assert.EqualValues(t, 2, body["revision"])
+	assert.EqualValues(t, 2, body["count"])
+	assert.Equal(t, false, body["reset"])
+
+	code, _ = get("/api/v1/rules/mirror/other/changes")
+	assert.Equal(t, http.StatusNotFound, code)
+
+	code, _ = get("/api/v1/rules/mirror/app/changes?since=latest")
+	assert.Equal(t, http.StatusBadRequest, code)
+}
@@ -0,0 +1,194 @@
+package server
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"io"
+	"io/fs"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"freightliner/pkg/helper/apicalls"
+	"freightliner/pkg/helper/errors"
+	"freightliner/pkg/replication"
+)
+
+// dashboardFiles is the single-page dashboard served at /ui/
+//
+//go:embed dashboard
+var dashboardFiles embed.FS
+
+// clusterNodeTimeout bounds the status request to each cluster node
+const clusterNodeTimeout = 5 * time.Second
+
+// RuleSummary is a rule the server runs, as shown on the dashboard
+type RuleSummary struct {
+	// ID names the rule in API paths such as /rules/{id}/changes
+	ID         string `json:"id"`
+	Kind       string `json:"kind"`
+	Repository string `json:"repository"`
+	Schedule   string `json:"schedule,omitempty"`
+	DryRun     bool   `json:"dry_run"`
+
+	// Pause explains why the rule stopped running on schedule
+	Pause *replication.RulePause `json:"pause,omitempty"`
+
+	// Frozen says which maintenance window or blackout stops the rule's
+	// changes right now
+	Frozen *RuleFreeze `json:"frozen,omitempty"`
+
+	// APICalls counts the registry API calls of the rule's runs since the
+	// server started
+	APICalls apicalls.Counts `json:"api_calls,omitempty"`
+
+	// Health sums up the rule: healthy or failing by its last run, paused,
+	// or not_run
+	Health string `json:"health"`
+
+	// RuleStatus is the rule's last run, run counts and next scheduled run
+	RuleStatus
+}
+
+// RuleFreeze is the freeze a rule is in
+type RuleFreeze struct {
+	Freeze string    `json:"freeze"`
+	Until  time.Time `json:"until"`
+}
+
+// ClusterNodeStatus is the status a distributed node reports on its admin
+// endpoint, or the error reaching it
+type ClusterNodeStatus struct {
+	Address string          `json:"address"`
+	Status  json.RawMessage `json:"status,omitempty"`
+	Error   string          `json:"error,omitempty"`
+}
+
+// registerDashboard serves the dashboard's static files
+func (s *Server) registerDashboard() {
+	static, err := fs.Sub(dashboardFiles, "dashboard")
+	if err != nil {
+		s.logger.Error("Failed to load dashboard files", err)
+		return
+	}
+
+	s.router.Handle("/ui", http.RedirectHandler("/ui/", http.StatusMovedPermanently))
+	s.router.PathPrefix("/ui/").Handler(http.StripPrefix("/ui/", http.FileServer(http.FS(static)))).Methods("GET")
+}
+
+// listRulesHandler lists the rules the server runs on a schedule
+func (s *Server) listRulesHandler(w http.ResponseWriter, r *http.Request) {
+	rules := []RuleSummary{}
+	if s.pruneScheduler != nil {
+		calls := s.pruneScheduler.calls.Counts()
+		now := time.Now()
+		for _, rule := range s.pruneScheduler.rules() {
+			var frozen *RuleFreeze
+			var freeze *replication.FrozenError
+			if errors.As(rule.Freeze.Check(rule.Repository, now), &freeze) {
+				frozen = &RuleFreeze{Freeze: freeze.Freeze, Until: freeze.Until}
+			}
+			pause := s.pruneScheduler.paused(rule.Repository)
+			status := s.pruneScheduler.status(rule, now)
+			rules = append(rules, RuleSummary{
+				ID:         rule.Repository,
+				Kind:       "prune",
+				Repository: rule.Repository,
+				Schedule:   rule.Schedule,
+				DryRun:     !rule.Delete,
+				Pause:      pause,
+				Frozen:     frozen,
+				APICalls:   calls[rule.Repository],
+				Health:     ruleHealth(status, pause != nil),
+				RuleStatus: status,
+			})
+		}
+	}
+
+	s.writeResponse(w, http.StatusOK, map[string]interface{}{
+		"rules": rules,
+		"count": len(rules),
+	})
+}
+
+// resumeRuleHandler resumes a rule paused for exceeding its error budget
+func (s *Server) resumeRuleHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Repository string `json:"repository"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Repository == "" {
+		s.writeErrorResponse(w, http.StatusBadRequest, "Request must name the rule's repository")
+		return
+	}
+
+	if s.pruneScheduler == nil || !s.pruneScheduler.resume(req.Repository) {
+		s.writeErrorResponse(w, http.StatusNotFound, "No scheduled rule for repository "+req.Repository)
+		return
+	}
+
+	s.writeResponse(w, http.StatusOK, map[string]interface{}{
+		"repository": req.Repository,
+		"status":     "resumed",
+	})
+}
+
+// clusterStatusHandler asks each configured cluster node for its status
+func (s *Server) clusterStatusHandler(w http.ResponseWriter, r *http.Request) {
+	nodes := make([]ClusterNodeStatus, len(s.cfg.Server.ClusterNodes))
+
+	var wg sync.WaitGroup
+	for i, address := range s.cfg.Server.ClusterNodes {
+		wg.Add(1)
+		go func(i int, address string) {
+			defer wg.Done()
+			nodes[i] = ClusterNodeStatus{Address: address}
+
+			status, err := fetchClusterNodeStatus(r.Context(), address)
+			if err != nil {
+				nodes[i].Error = err.Error()
+				return
+			}
+			nodes[i].Status = status
+		}(i, address)
+	}
+	wg.Wait()
+
+	s.writeResponse(w, http.StatusOK, map[string]interface{}{
+		"nodes": nodes,
+		"count": len(nodes),
+	})
+}
+
+// fetchClusterNodeStatus reads /cluster/status from a node's admin address
+func fetchClusterNodeStatus(ctx context.Context, address string) (json.RawMessage, error) {
+	ctx, cancel := context.WithTimeout(ctx, clusterNodeTimeout)
+	defer cancel()
+
+	if !strings.Contains(address, "://") {
+		address = "http://" + address
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(address, "/")+"/cluster/status", nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid node address")
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "node unreachable")
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read node status")
+	}
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusAccepted {
+		return nil, errors.Newf("node returned %s", resp.Status)
+	}
+	if !json.Valid(body) {
+		return nil, errors.New("node returned invalid JSON")
+	}
+	return body, nil
+}
@@ -0,0 +1,173 @@
+// Freightliner dashboard: polls the REST API and renders its tables.
+(function () {
+  "use strict";
+
+  var REFRESH_MS = 5000;
+  var MAX_FAILURES = 20;
+  var ACTIVE = { pending: true, running: true };
+
+  function apiKey() {
+    return window.localStorage.getItem("freightliner-api-key") || "";
+  }
+
+  function fetchJSON(path) {
+    var headers = {};
+    if (apiKey()) {
+      headers["X-API-Key"] = apiKey();
+    }
+    return fetch("/api/v1" + path, { headers: headers }).then(function (resp) {
+      if (!resp.ok) {
+        throw new Error(path + " returned " + resp.status);
+      }
+      return resp.json();
+    });
+  }
+
+  function formatTime(value) {
+    if (!value || value.indexOf("0001-") === 0) {
+      return "";
+    }
+    return new Date(value).toLocaleString();
+  }
+
+  function cell(text, className) {
+    var td = document.createElement("td");
+    td.textContent = text === undefined || text === null ? "" : String(text);
+    if (className) {
+      td.className = className;
+    }
+    return td;
+  }
+
+  function render(tableId, countId, rows, columns, emptyText) {
+    var body = document.querySelector("#" + tableId + " tbody");
+    body.textContent = "";
+    document.getElementById(countId).textContent = "(" + rows.length + ")";
+
+    if (rows.length === 0) {
+      var tr = document.createElement("tr");
+      var td = cell(emptyText, "empty");
+      td.colSpan = columns.length;
+      tr.appendChild(td);
+      body.appendChild(tr);
+      return;
+    }
+
+    rows.forEach(function (row) {
+      var tr = document.createElement("tr");
+      columns.forEach(function (column) {
+        var value = column(row);
+        if (value && typeof value === "object") {
+          tr.appendChild(cell(value.text, value.className));
+        } else {
+          tr.appendChild(cell(value));
+        }
+      });
+      body.appendChild(tr);
+    });
+  }
+
+  function status(value) {
+    return { text: value, className: "status-" + value };
+  }
+
+  function renderJobs(data) {
+    var jobs = (data.jobs || []).filter(Boolean);
+    var active = jobs.filter(function (job) { return ACTIVE[job.status]; });
+    var failed = jobs
+      .filter(function (job) { return job.status === "failed"; })
+      .sort(function (a, b) { return (b.end_time || "").localeCompare(a.end_time || ""); })
+      .slice(0, MAX_FAILURES);
+
+    render("active-jobs", "active-count", active, [
+      function (job) { return job.id; },
+      function (job) { return job.type; },
+      function (job) { return status(job.status); },
+      function (job) { return job.source; },
+      function (job) { return job.destination; },
+      function (job) { return formatTime(job.start_time); }
+    ], "No jobs running");
+
+    render("failed-jobs", "failed-count", failed, [
+      function (job) { return job.id; },
+      function (job) { return job.type; },
+      function (job) { return job.source; },
+      function (job) { return job.destination; },
+      function (job) { return formatTime(job.end_time); },
+      function (job) { return { text: job.error, className: "status-failed" }; }
+    ], "No failed jobs");
+  }
+
+  function renderRules(data) {
+    render("rules", "rules-count", data.rules || [], [
+      function (rule) { return rule.kind; },
+      function (rule) { return rule.repository; },
+      function (rule) { return rule.schedule || "manual"; },
+      function (rule) { return rule.dry_run ? "dry run" : "enforced"; },
+      function (rule) {
+        if (rule.pause) {
+          return { text: "paused: " + rule.pause.reason, className: "status-failed" };
+        }
+        if (rule.health === "failing") {
+          return { text: "failing: " + rule.last_run.error, className: "status-failed" };
+        }
+        return rule.health === "healthy" ? status("healthy") : "not run";
+      },
+      function (rule) { return rule.last_run ? formatTime(rule.last_run.finished_at) : ""; },
+      function (rule) { return formatTime(rule.next_run); }
+    ], "No scheduled rules");
+  }
+
+  function renderCheckpoints(data) {
+    render("checkpoints", "checkpoints-count", data.checkpoints || [], [
+      function (cp) { return cp.id; },
+      function (cp) { return cp.source; },
+      function (cp) { return cp.destination; },
+      function (cp) { return status(cp.status); },
+      function (cp) {
+        return cp.completed_repositories + "/" + cp.total_repositories +
+          (cp.failed_repositories ? ", " + cp.failed_repositories + " failed" : "");
+      },
+      function (cp) { return formatTime(cp.created_at); }
+    ], "No checkpoints");
+  }
+
+  function renderNodes(data) {
+    render("nodes", "nodes-count", data.nodes || [], [
+      function (node) { return node.address; },
+      function (node) { return node.status ? node.status.node.node_id : ""; },
+      function (node) { return node.status ? status(node.status.node.mode) : status("unreachable"); },
+      function (node) { return node.status ? node.status.node.queue_depth : ""; },
+      function (node) { return node.status ? node.status.node.in_flight : ""; },
+      function (node) { return node.status && node.status.is_leader ? "yes" : ""; },
+      function (node) { return node.error || (node.status && node.status.error) || ""; }
+    ], "No cluster nodes configured");
+  }
+
+  function refresh() {
+    var errorBox = document.getElementById("error");
+    Promise.all([
+      fetchJSON("/jobs").then(renderJobs),
+      fetchJSON("/rules").then(renderRules),
+      fetchJSON("/checkpoints").then(renderCheckpoints),
+      fetchJSON("/cluster").then(renderNodes)
+    ]).then(function () {
+      errorBox.hidden = true;
+      document.getElementById("updated").textContent = "Updated " + new Date().toLocaleTimeString();
+    }).catch(function (err) {
+      errorBox.textContent = "Failed to refresh: " + err.message;
+      errorBox.hidden = false;
+    });
+  }
+
+  document.getElementById("api-key").addEventListener("click", function () {
+    var key = window.prompt("API key (stored in this browser)", apiKey());
+    if (key !== null) {
+      window.localStorage.setItem("freightliner-api-key", key);
+      refresh();
+    }
+  });
+
+  refresh();
+  window.setInterval(refresh, REFRESH_MS);
+})();
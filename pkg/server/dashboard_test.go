@@ -0,0 +1,47 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestDashboard tests the embedded dashboard and the endpoints it reads
+func TestDashboard(t *testing.T) {
+	server := createTestServer(t)
+	server.cfg.Server.ClusterNodes = []string{"127.0.0.1:1"}
+	server.registerDashboard()
+
+	w := httptest.NewRecorder()
+	server.router.ServeHTTP(w, httptest.NewRequest("GET", "/ui/", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "<title>Freightliner</title>")
+
+	w = httptest.NewRecorder()
+	server.router.ServeHTTP(w, httptest.NewRequest("GET", "/ui/app.js", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	server.listRulesHandler(w, httptest.NewRequest("GET", "/api/v1/rules", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	var rules struct {
+		Count int `json:"count"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &rules))
+	assert.Equal(t, 0, rules.Count)
+
+	w = httptest.NewRecorder()
+	server.clusterStatusHandler(w, httptest.NewRequest("GET", "/api/v1/cluster", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	var cluster struct {
+		Nodes []ClusterNodeStatus `json:"nodes"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &cluster))
+	require.Len(t, cluster.Nodes, 1)
+	assert.Equal(t, "127.0.0.1:1", cluster.Nodes[0].Address)
+	assert.Contains(t, cluster.Nodes[0].Error, "unreachable")
+}
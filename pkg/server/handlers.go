@@ -2,11 +2,17 @@ package server
 
 import (
 	"context"
+	"crypto/rand"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	"strconv"
 	"strings"
 
+	"freightliner/pkg/helper/apicalls"
+	"freightliner/pkg/network"
+
 	"github.com/gorilla/mux"
 )
 
@@ -25,29 +31,46 @@ func (s *Server) replicateHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Rules frozen for maintenance refuse changes to their repositories
+	if !req.DryRun && s.rejectFrozen(w, []string{req.DestRepo}, nil) {
+		return
+	}
+
+	// Retries are recognized by their key and request fingerprint
+	key := idempotencyKey(r, req.IdempotencyKey)
+	req.IdempotencyKey = ""
+	fingerprint, err := requestFingerprint(req)
+	if err != nil {
+		s.writeErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
 	// Create source and destination paths
 	source := fmt.Sprintf("%s/%s", req.SourceRegistry, req.SourceRepo)
 	destination := fmt.Sprintf("%s/%s", req.DestRegistry, req.DestRepo)
 
-	// Create replication job
-	job := NewReplicateJob(source, destination, req.Tags, req.Force, req.DryRun, s.replicationSvc)
+	// Create replication job with the caller's own services
+	t := tenantFromContext(r.Context())
+	job := NewReplicateJob(source, destination, req.Tags, req.Force, req.DryRun, s.replicationServiceFor(t))
+	job.Tenant = t.name()
 
-	// Add job to manager
-	s.jobManager.AddJob(job)
-
-	// Submit job to worker pool
-	err := s.workerPool.Submit(job.GetID(), func(ctx context.Context) error {
-		// Update job status
-		job.SetStatus(JobStatusRunning)
-
-		// Execute job
-		err := job.Execute(ctx)
+	// A resubmission with the same idempotency key returns the original job
+	release, ok := s.reserveIdempotencyKey(w, key, fingerprint, job)
+	if !ok {
+		return
+	}
 
-		// Job status and result are already updated by the Execute method
-		return err
-	})
+	// Add job to manager once namespaces and quota allow it
+	if !s.admitJob(w, t, job, req.SourceRepo, req.DestRepo) {
+		release()
+		return
+	}
 
+	// Submit job to worker pool
+	err = s.submitJob(job)
 	if err != nil {
+		release()
+
 		// Update job status if submission failed
 		job.SetStatus(JobStatusFailed)
 		job.SetError(fmt.Errorf("failed to submit job: %w", err))
@@ -78,6 +101,20 @@ func (s *Server) replicateTreeHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Rules frozen for maintenance refuse changes to their repositories
+	if !req.DryRun && s.rejectFrozen(w, nil, []string{req.DestRepo}) {
+		return
+	}
+
+	// Retries are recognized by their key and request fingerprint
+	key := idempotencyKey(r, req.IdempotencyKey)
+	req.IdempotencyKey = ""
+	fingerprint, err := requestFingerprint(req)
+	if err != nil {
+		s.writeErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
 	// Create source and destination paths
 	source := fmt.Sprintf("%s/%s", req.SourceRegistry, req.SourceRepo)
 	destination := fmt.Sprintf("%s/%s", req.DestRegistry, req.DestRepo)
@@ -95,25 +132,28 @@ func (s *Server) replicateTreeHandler(w http.ResponseWriter, r *http.Request) {
 		"retryFailed":      true, // Default value
 	}
 
-	// Create replication job
-	job := NewReplicateTreeJob(source, destination, options, s.treeReplicationSvc)
-
-	// Add job to manager
-	s.jobManager.AddJob(job)
-
-	// Submit job to worker pool
-	err := s.workerPool.Submit(job.GetID(), func(ctx context.Context) error {
-		// Update job status
-		job.SetStatus(JobStatusRunning)
+	// Create replication job with the caller's own services
+	t := tenantFromContext(r.Context())
+	job := NewReplicateTreeJob(source, destination, options, s.treeReplicationServiceFor(t))
+	job.Tenant = t.name()
 
-		// Execute job
-		err := job.Execute(ctx)
+	// A resubmission with the same idempotency key returns the original job
+	release, ok := s.reserveIdempotencyKey(w, key, fingerprint, job)
+	if !ok {
+		return
+	}
 
-		// Job status and result are already updated by the Execute method
-		return err
-	})
+	// Add job to manager once namespaces and quota allow it
+	if !s.admitJob(w, t, job, req.SourceRepo, req.DestRepo) {
+		release()
+		return
+	}
 
+	// Submit job to worker pool
+	err = s.submitJob(job)
 	if err != nil {
+		release()
+
 		// Update job status if submission failed
 		job.SetStatus(JobStatusFailed)
 		job.SetError(fmt.Errorf("failed to submit job: %w", err))
@@ -129,6 +169,31 @@ func (s *Server) replicateTreeHandler(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// submitJob runs job on the worker pool under a context that cancelJobHandler
+// can cancel
+func (s *Server) submitJob(job Job) error {
+	ctx, finish := s.jobManager.startJob(job.GetID())
+	err := s.workerPool.SubmitWithLabels(ctx, job.GetID(), jobLabels(job), func(ctx context.Context) error {
+		defer finish()
+		ctx = apicalls.WithCounter(ctx, job.GetAPICalls())
+
+		// A job canceled while queued never starts
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		// Update job status
+		job.SetStatus(JobStatusRunning)
+
+		// Job status and result are updated by the Execute method
+		return job.Execute(ctx)
+	})
+	if err != nil {
+		finish()
+	}
+	return err
+}
+
 // listJobsHandler handles listing jobs
 func (s *Server) listJobsHandler(w http.ResponseWriter, r *http.Request) {
 	// Parse query parameters
@@ -146,8 +211,17 @@ func (s *Server) listJobsHandler(w http.ResponseWriter, r *http.Request) {
 		jobStatus = JobStatus(statusStr)
 	}
 
-	// Get jobs
+	// Get jobs; tenants only see their own
 	jobs := s.jobManager.ListJobs(jobType, jobStatus)
+	if t := tenantFromContext(r.Context()); t != nil {
+		owned := jobs[:0]
+		for _, job := range jobs {
+			if job.GetTenant() == t.name() {
+				owned = append(owned, job)
+			}
+		}
+		jobs = owned
+	}
 
 	// Convert jobs to JSON-friendly format
 	result := make([]map[string]interface{}, len(jobs))
@@ -188,7 +262,7 @@ func (s *Server) getJobHandler(w http.ResponseWriter, r *http.Request) {
 	jobID := vars["id"]
 
 	// Get job
-	job, exists := s.jobManager.GetJob(jobID)
+	job, exists := s.lookupJob(r, jobID)
 	if !exists {
 		s.writeErrorResponse(w, http.StatusNotFound, "Job not found")
 		return
@@ -223,7 +297,7 @@ func (s *Server) getJobHandler(w http.ResponseWriter, r *http.Request) {
 // listCheckpointsHandler handles listing checkpoints
 func (s *Server) listCheckpointsHandler(w http.ResponseWriter, r *http.Request) {
 	// Get checkpoints
-	checkpoints, err := s.checkpointSvc.ListCheckpoints(r.Context())
+	checkpoints, err := s.checkpointServiceFor(tenantFromContext(r.Context())).ListCheckpoints(r.Context())
 	if err != nil {
 		s.logger.Error("Failed to list checkpoints", err)
 		s.writeErrorResponse(w, http.StatusInternalServerError, "Failed to list checkpoints")
@@ -244,7 +318,7 @@ func (s *Server) getCheckpointHandler(w http.ResponseWriter, r *http.Request) {
 	checkpointID := vars["id"]
 
 	// Get checkpoint
-	checkpoint, err := s.checkpointSvc.GetCheckpoint(r.Context(), checkpointID)
+	checkpoint, err := s.checkpointServiceFor(tenantFromContext(r.Context())).GetCheckpoint(r.Context(), checkpointID)
 	if err != nil {
 		if strings.Contains(err.Error(), "not found") {
 			s.writeErrorResponse(w, http.StatusNotFound, "Checkpoint not found")
@@ -269,7 +343,7 @@ func (s *Server) deleteCheckpointHandler(w http.ResponseWriter, r *http.Request)
 	checkpointID := vars["id"]
 
 	// Delete checkpoint
-	err := s.checkpointSvc.DeleteCheckpoint(r.Context(), checkpointID)
+	err := s.checkpointServiceFor(tenantFromContext(r.Context())).DeleteCheckpoint(r.Context(), checkpointID)
 	if err != nil {
 		if strings.Contains(err.Error(), "not found") {
 			s.writeErrorResponse(w, http.StatusNotFound, "Checkpoint not found")
@@ -290,3 +364,28 @@ func (s *Server) deleteCheckpointHandler(w http.ResponseWriter, r *http.Request)
 		"message": "Checkpoint deleted successfully",
 	})
 }
+
+// capabilitiesHandler returns the compression and delta formats this server
+// supports so peers can negotiate transfer settings
+func (s *Server) capabilitiesHandler(w http.ResponseWriter, r *http.Request) {
+	s.writeResponse(w, http.StatusOK, network.LocalCapabilities())
+}
+
+// capabilitiesProbeHandler streams incompressible bytes for peers measuring link speed
+func (s *Server) capabilitiesProbeHandler(w http.ResponseWriter, r *http.Request) {
+	size := int64(network.DefaultProbeBytes)
+	if value := r.URL.Query().Get("bytes"); value != "" {
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || n <= 0 || n > network.MaxProbeBytes {
+			s.writeErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("bytes must be between 1 and %d", network.MaxProbeBytes))
+			return
+		}
+		size = n
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	_, _ = io.CopyN(w, rand.Reader, size)
+}
@@ -0,0 +1,257 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"freightliner/pkg/helper/apicalls"
+	"freightliner/pkg/helper/errors"
+)
+
+// IdempotencyKeyHeader carries the caller's idempotency key for a copy request
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// DefaultIdempotencyTTL is how long idempotency keys are remembered when the
+// server config does not say
+const DefaultIdempotencyTTL = 24 * time.Hour
+
+// maxIdempotencyKeyLength bounds keys so they cannot be used to grow memory
+const maxIdempotencyKeyLength = 255
+
+// IdempotencyFile is the file, in the checkpoint directory, that keeps
+// idempotency keys across server restarts
+const IdempotencyFile = "idempotency.json"
+
+// idempotencyEntry is the job submitted with an idempotency key
+type idempotencyEntry struct {
+	job         Job
+	fingerprint string
+	expires     time.Time
+}
+
+// savedIdempotencyEntry is an idempotency entry as written to the key file
+type savedIdempotencyEntry struct {
+	Job         *BaseJob  `json:"job"`
+	Fingerprint string    `json:"fingerprint"`
+	Expires     time.Time `json:"expires"`
+}
+
+// restoredJob is a job read back from the key file. It only reports how the
+// job went; the job itself did not survive the restart.
+type restoredJob struct {
+	*BaseJob
+}
+
+// Execute refuses to run the job again
+func (j *restoredJob) Execute(ctx context.Context) error {
+	return errors.New("restored job cannot be executed")
+}
+
+// LoadIdempotencyKeys keeps idempotency keys in the file at path, reading
+// back the unexpired keys saved there, so a retry after a restart still
+// replays the original job. Jobs that had not finished when they were saved
+// did not survive the restart and are reported as failed.
+func (m *JobManager) LoadIdempotencyKeys(path string) error {
+	m.jobsMutex.Lock()
+	defer m.jobsMutex.Unlock()
+	m.idempotencyPath = path
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to read idempotency keys")
+	}
+	var saved map[string]savedIdempotencyEntry
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return errors.Wrapf(err, "failed to parse idempotency keys %s", path)
+	}
+
+	now := time.Now()
+	for scoped, entry := range saved {
+		if entry.Job == nil || now.After(entry.Expires) {
+			continue
+		}
+		job := entry.Job
+		job.APICalls = apicalls.NewCounter()
+		if job.Status == JobStatusPending || job.Status == JobStatusRunning {
+			job.Status = JobStatusFailed
+			job.ErrorMsg = "job was interrupted by a server restart"
+		}
+		m.idempotency[scoped] = idempotencyEntry{job: &restoredJob{BaseJob: job}, fingerprint: entry.Fingerprint, expires: entry.Expires}
+	}
+	return nil
+}
+
+// saveIdempotencyKeys writes the idempotency keys to the key file, replacing
+// it atomically. The caller holds jobsMutex.
+func (m *JobManager) saveIdempotencyKeys() error {
+	if m.idempotencyPath == "" {
+		return nil
+	}
+
+	saved := make(map[string]savedIdempotencyEntry, len(m.idempotency))
+	for scoped, entry := range m.idempotency {
+		job := &BaseJob{
+			ID:          entry.job.GetID(),
+			Type:        entry.job.GetType(),
+			Tenant:      entry.job.GetTenant(),
+			Source:      entry.job.GetSource(),
+			Destination: entry.job.GetDestination(),
+			StartTime:   entry.job.GetStartTime(),
+			EndTime:     entry.job.GetEndTime(),
+			Status:      entry.job.GetStatus(),
+		}
+		if err := entry.job.GetError(); err != nil {
+			job.ErrorMsg = err.Error()
+		}
+		saved[scoped] = savedIdempotencyEntry{Job: job, Fingerprint: entry.fingerprint, Expires: entry.expires}
+	}
+
+	data, err := json.Marshal(saved)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode idempotency keys")
+	}
+	if err := os.MkdirAll(filepath.Dir(m.idempotencyPath), 0755); err != nil {
+		return errors.Wrap(err, "failed to create idempotency key directory")
+	}
+	tmp := m.idempotencyPath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return errors.Wrap(err, "failed to write idempotency keys")
+	}
+	if err := os.Rename(tmp, m.idempotencyPath); err != nil {
+		return errors.Wrap(err, "failed to replace idempotency keys")
+	}
+	return nil
+}
+
+// idempotencyJob reports whether job was submitted with an idempotency key.
+// The caller holds jobsMutex.
+func (m *JobManager) idempotencyJob(id string) bool {
+	for _, entry := range m.idempotency {
+		if entry.job.GetID() == id {
+			return true
+		}
+	}
+	return false
+}
+
+// SetIdempotencyTTL sets how long idempotency keys are remembered
+func (m *JobManager) SetIdempotencyTTL(ttl time.Duration) {
+	m.jobsMutex.Lock()
+	defer m.jobsMutex.Unlock()
+	m.idempotencyTTL = ttl
+}
+
+// ReserveIdempotencyKey records job as the one submitted with the tenant's
+// key. When the key is already recorded it returns the earlier job instead,
+// or an error when that job came from a different request (fingerprint).
+// With a key file the reservation is saved before it is granted.
+func (m *JobManager) ReserveIdempotencyKey(tenant, key, fingerprint string, job Job) (Job, error) {
+	m.jobsMutex.Lock()
+	defer m.jobsMutex.Unlock()
+
+	now := time.Now()
+	for scoped, entry := range m.idempotency {
+		if now.After(entry.expires) {
+			delete(m.idempotency, scoped)
+		}
+	}
+
+	scoped := tenant + "\x00" + key
+	if entry, ok := m.idempotency[scoped]; ok {
+		if entry.fingerprint != fingerprint {
+			return nil, errors.InvalidInputf("idempotency key %q was already used for a different request", key)
+		}
+		return entry.job, nil
+	}
+
+	ttl := m.idempotencyTTL
+	if ttl <= 0 {
+		ttl = DefaultIdempotencyTTL
+	}
+	m.idempotency[scoped] = idempotencyEntry{job: job, fingerprint: fingerprint, expires: now.Add(ttl)}
+	if err := m.saveIdempotencyKeys(); err != nil {
+		delete(m.idempotency, scoped)
+		return nil, err
+	}
+	return nil, nil
+}
+
+// ReleaseIdempotencyKey forgets the tenant's key, e.g. after the job it was
+// reserved for could not be submitted
+func (m *JobManager) ReleaseIdempotencyKey(tenant, key string) {
+	m.jobsMutex.Lock()
+	defer m.jobsMutex.Unlock()
+	delete(m.idempotency, tenant+"\x00"+key)
+	// A key left in the file only makes a retry replay the unsubmitted job
+	_ = m.saveIdempotencyKeys()
+}
+
+// idempotencyKey returns the request's key from the Idempotency-Key header,
+// falling back to the key in the request body
+func idempotencyKey(r *http.Request, bodyKey string) string {
+	if key := strings.TrimSpace(r.Header.Get(IdempotencyKeyHeader)); key != "" {
+		return key
+	}
+	return strings.TrimSpace(bodyKey)
+}
+
+// requestFingerprint hashes a decoded request, without its idempotency key,
+// so a key reused for another request can be told apart from a retry
+func requestFingerprint(req interface{}) (string, error) {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to fingerprint request")
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// reserveIdempotencyKey reserves key for job. When the key was used before it
+// writes the earlier job's response, or an error for a key reused with a
+// different request, and returns false. Otherwise the caller submits job and
+// calls the returned release when that fails.
+func (s *Server) reserveIdempotencyKey(w http.ResponseWriter, key, fingerprint string, job Job) (func(), bool) {
+	if key == "" {
+		return func() {}, true
+	}
+	if len(key) > maxIdempotencyKeyLength {
+		s.writeErrorResponse(w, http.StatusBadRequest, "idempotency key is too long")
+		return nil, false
+	}
+
+	tenant := job.GetTenant()
+	original, err := s.jobManager.ReserveIdempotencyKey(tenant, key, fingerprint, job)
+	if errors.Is(err, errors.ErrInvalidInput) {
+		s.writeErrorResponse(w, http.StatusUnprocessableEntity, err.Error())
+		return nil, false
+	}
+	if err != nil {
+		s.writeErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return nil, false
+	}
+	if original != nil {
+		s.logger.WithFields(map[string]interface{}{
+			"job_id": original.GetID(),
+			"tenant": tenant,
+		}).Debug("Replaying idempotent job submission")
+
+		w.Header().Set("Idempotent-Replayed", "true")
+		s.writeResponse(w, http.StatusOK, map[string]string{
+			"job_id": original.GetID(),
+			"status": string(original.GetStatus()),
+		})
+		return nil, false
+	}
+
+	return func() { s.jobManager.ReleaseIdempotencyKey(tenant, key) }, true
+}
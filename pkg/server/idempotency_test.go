@@ -0,0 +1,119 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplicateHandlerIdempotencyKey(t *testing.T) {
+	server := createTestServer(t)
+
+	submit := func(key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/v1/replicate", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if key != "" {
+			req.Header.Set(IdempotencyKeyHeader, key)
+		}
+		w := httptest.NewRecorder()
+		server.replicateHandler(w, req)
+		return w
+	}
+	jobID := func(w *httptest.ResponseRecorder) string {
+		var response map[string]string
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		return response["job_id"]
+	}
+
+	body := `{"source_registry": "ecr", "source_repo": "app", "dest_registry": "gcr", "dest_repo": "app", "tags": ["v1"]}`
+	first := submit("promote-42", body)
+	require.Equal(t, http.StatusAccepted, first.Code)
+
+	// A retry returns the original job instead of submitting another
+	retry := submit("promote-42", body)
+	assert.Equal(t, http.StatusOK, retry.Code)
+	assert.Equal(t, "true", retry.Header().Get("Idempotent-Replayed"))
+	assert.Equal(t, jobID(first), jobID(retry))
+
+	// The key can also be given in the body
+	inBody := submit("", strings.Replace(body, `"tags"`, `"idempotency_key": "promote-42", "tags"`, 1))
+	assert.Equal(t, http.StatusOK, inBody.Code)
+	assert.Equal(t, jobID(first), jobID(inBody))
+
+	// Reusing the key for another request is refused
+	other := submit("promote-42", strings.Replace(body, `"v1"`, `"v2"`, 1))
+	assert.Equal(t, http.StatusUnprocessableEntity, other.Code)
+
+	// Without a key every submission is a new job
+	assert.NotEqual(t, jobID(submit("", body)), jobID(submit("", body)))
+}
+
+func TestJobManagerIdempotencyKeyExpires(t *testing.T) {
+	manager := NewJobManager()
+	manager.SetIdempotencyTTL(time.Millisecond)
+
+	first := NewReplicateJob("ecr/app", "gcr/app", nil, false, false, nil)
+	original, err := manager.ReserveIdempotencyKey("", "key", "fingerprint", first)
+	require.NoError(t, err)
+	assert.Nil(t, original)
+
+	// Keys are scoped to tenants
+	original, err = manager.ReserveIdempotencyKey("team-a", "key", "other", first)
+	require.NoError(t, err)
+	assert.Nil(t, original)
+
+	time.Sleep(5 * time.Millisecond)
+	second := NewReplicateJob("ecr/app", "gcr/app", nil, false, false, nil)
+	original, err = manager.ReserveIdempotencyKey("", "key", "changed", second)
+	require.NoError(t, err)
+	assert.Nil(t, original, "expired keys can be reused")
+}
+
+func TestJobManagerIdempotencyKeySurvivesReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), IdempotencyFile)
+
+	manager := NewJobManager()
+	require.NoError(t, manager.LoadIdempotencyKeys(path))
+	finished := NewReplicateJob("ecr/app", "gcr/app", nil, false, false, nil)
+	interrupted := NewReplicateJob("ecr/web", "gcr/web", nil, false, false, nil)
+	for key, job := range map[string]Job{"finished": finished, "interrupted": interrupted} {
+		manager.AddJob(job)
+		original, err := manager.ReserveIdempotencyKey("team-a", key, key+"-fingerprint", job)
+		require.NoError(t, err)
+		require.Nil(t, original)
+	}
+	manager.UpdateJob(finished.GetID(), JobStatusCompleted, nil, nil)
+
+	reloaded := NewJobManager()
+	require.NoError(t, reloaded.LoadIdempotencyKeys(path))
+
+	original, err := reloaded.ReserveIdempotencyKey("team-a", "finished", "finished-fingerprint", NewReplicateJob("ecr/app", "gcr/app", nil, false, false, nil))
+	require.NoError(t, err)
+	require.NotNil(t, original)
+	assert.Equal(t, finished.GetID(), original.GetID())
+	assert.Equal(t, JobStatusCompleted, original.GetStatus())
+
+	original, err = reloaded.ReserveIdempotencyKey("team-a", "interrupted", "interrupted-fingerprint", NewReplicateJob("ecr/web", "gcr/web", nil, false, false, nil))
+	require.NoError(t, err)
+	require.NotNil(t, original)
+	assert.Equal(t, interrupted.GetID(), original.GetID())
+	assert.Equal(t, JobStatusFailed, original.GetStatus(), "jobs cut short by the restart are reported as failed")
+
+	_, err = reloaded.ReserveIdempotencyKey("team-a", "finished", "other", NewReplicateJob("ecr/app", "gcr/app", nil, false, false, nil))
+	assert.Error(t, err, "a reused key is still refused after a reload")
+
+	// Released keys are forgotten on the next reload
+	reloaded.ReleaseIdempotencyKey("team-a", "interrupted")
+	again := NewJobManager()
+	require.NoError(t, again.LoadIdempotencyKeys(path))
+	original, err = again.ReserveIdempotencyKey("team-a", "interrupted", "new", NewReplicateJob("ecr/web", "gcr/web", nil, false, false, nil))
+	require.NoError(t, err)
+	assert.Nil(t, original)
+}
@@ -3,9 +3,14 @@ package server
 import (
 	"context"
 	"encoding/json"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
+	"freightliner/pkg/config"
+	"freightliner/pkg/helper/apicalls"
+	"freightliner/pkg/helper/errors"
 	"freightliner/pkg/service"
 
 	"github.com/google/uuid"
@@ -51,16 +56,87 @@ const (
 // JobManager manages job execution and tracking
 type JobManager struct {
 	jobs      map[string]Job
+	controls  map[string]*jobControl
 	jobsMutex sync.RWMutex
+
+	// idempotency maps tenant and idempotency key to the job submitted
+	// with them, until the entry expires after idempotencyTTL
+	idempotency    map[string]idempotencyEntry
+	idempotencyTTL time.Duration
+
+	// idempotencyPath is the file the idempotency keys are saved to, if any
+	idempotencyPath string
+}
+
+// jobControl cancels a submitted job and signals when its task has returned
+type jobControl struct {
+	cancel context.CancelFunc
+	done   chan struct{}
 }
 
 // NewJobManager creates a new job manager
 func NewJobManager() *JobManager {
 	return &JobManager{
-		jobs: make(map[string]Job),
+		jobs:           make(map[string]Job),
+		controls:       make(map[string]*jobControl),
+		idempotency:    make(map[string]idempotencyEntry),
+		idempotencyTTL: DefaultIdempotencyTTL,
+	}
+}
+
+// startJob returns the context a submitted job runs under and a finish
+// function to call once its task has returned
+func (m *JobManager) startJob(id string) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	control := &jobControl{cancel: cancel, done: make(chan struct{})}
+
+	m.jobsMutex.Lock()
+	m.controls[id] = control
+	m.jobsMutex.Unlock()
+
+	var once sync.Once
+	return ctx, func() {
+		once.Do(func() {
+			m.jobsMutex.Lock()
+			delete(m.controls, id)
+			m.jobsMutex.Unlock()
+
+			cancel()
+			close(control.done)
+		})
 	}
 }
 
+// CancelJob marks a pending or running job canceled and cancels its context.
+// The returned channel is closed once the job's task has returned.
+func (m *JobManager) CancelJob(id string) (<-chan struct{}, error) {
+	m.jobsMutex.Lock()
+	defer m.jobsMutex.Unlock()
+
+	job, exists := m.jobs[id]
+	if !exists {
+		return nil, errors.NotFoundf("job %s not found", id)
+	}
+
+	switch status := job.GetStatus(); status {
+	case JobStatusCompleted, JobStatusFailed, JobStatusCanceled, JobStatusCancelled:
+		return nil, errors.InvalidInputf("job %s is already %s", id, status)
+	}
+
+	job.SetStatus(JobStatusCanceled)
+	job.SetEndTime(time.Now())
+
+	control, running := m.controls[id]
+	if !running {
+		done := make(chan struct{})
+		close(done)
+		return done, nil
+	}
+
+	control.cancel()
+	return control.done, nil
+}
+
 // AddJob adds a job to the manager
 func (m *JobManager) AddJob(job Job) {
 	m.jobsMutex.Lock()
@@ -69,6 +145,36 @@ func (m *JobManager) AddJob(job Job) {
 	m.jobs[job.GetID()] = job
 }
 
+// AddJobWithinQuota adds a tenant's job unless it would exceed the tenant's quota
+func (m *JobManager) AddJobWithinQuota(job Job, quota config.TenantQuota) error {
+	m.jobsMutex.Lock()
+	defer m.jobsMutex.Unlock()
+
+	hourAgo := time.Now().Add(-time.Hour)
+	active, recent := 0, 0
+	for _, existing := range m.jobs {
+		if existing.GetTenant() != job.GetTenant() {
+			continue
+		}
+		if status := existing.GetStatus(); status == JobStatusPending || status == JobStatusRunning {
+			active++
+		}
+		if existing.GetStartTime().After(hourAgo) {
+			recent++
+		}
+	}
+
+	if quota.MaxActiveJobs > 0 && active >= quota.MaxActiveJobs {
+		return errors.Unavailablef("tenant %s already has %d active jobs (quota %d)", job.GetTenant(), active, quota.MaxActiveJobs)
+	}
+	if quota.MaxJobsPerHour > 0 && recent >= quota.MaxJobsPerHour {
+		return errors.Unavailablef("tenant %s submitted %d jobs in the last hour (quota %d)", job.GetTenant(), recent, quota.MaxJobsPerHour)
+	}
+
+	m.jobs[job.GetID()] = job
+	return nil
+}
+
 // GetJob returns a job by ID
 func (m *JobManager) GetJob(id string) (Job, bool) {
 	m.jobsMutex.RLock()
@@ -101,6 +207,42 @@ func (m *JobManager) ListJobs(jobType JobType, status JobStatus) []Job {
 	return result
 }
 
+// ActiveJob is a pending or running job in diagnostics dumps
+type ActiveJob struct {
+	ID          string    `json:"id"`
+	Type        JobType   `json:"type"`
+	Tenant      string    `json:"tenant,omitempty"`
+	Status      JobStatus `json:"status"`
+	Source      string    `json:"source,omitempty"`
+	Destination string    `json:"destination,omitempty"`
+	StartTime   time.Time `json:"start_time"`
+	Age         string    `json:"age"`
+}
+
+// ActiveJobs returns the pending and running jobs, oldest first
+func (m *JobManager) ActiveJobs() []ActiveJob {
+	active := []ActiveJob{}
+	for _, job := range m.ListJobs("", "") {
+		status := job.GetStatus()
+		if status != JobStatusPending && status != JobStatusRunning {
+			continue
+		}
+		active = append(active, ActiveJob{
+			ID:          job.GetID(),
+			Type:        job.GetType(),
+			Tenant:      job.GetTenant(),
+			Status:      status,
+			Source:      job.GetSource(),
+			Destination: job.GetDestination(),
+			StartTime:   job.GetStartTime(),
+			Age:         time.Since(job.GetStartTime()).Round(time.Second).String(),
+		})
+	}
+
+	sort.Slice(active, func(i, j int) bool { return active[i].StartTime.Before(active[j].StartTime) })
+	return active
+}
+
 // GetJobCount returns the total number of jobs
 func (m *JobManager) GetJobCount() int {
 	m.jobsMutex.RLock()
@@ -132,6 +274,12 @@ func (m *JobManager) UpdateJob(id string, status JobStatus, result interface{},
 	if status == JobStatusCompleted || status == JobStatusFailed || status == JobStatusCanceled {
 		job.SetEndTime(time.Now())
 	}
+
+	// Keep the saved status current so a replay after a restart reports it;
+	// a failed save leaves the job reported as interrupted
+	if m.idempotencyPath != "" && m.idempotencyJob(id) {
+		_ = m.saveIdempotencyKeys()
+	}
 }
 
 // Job represents a replication job
@@ -142,6 +290,9 @@ type Job interface {
 	// GetType returns the job type
 	GetType() JobType
 
+	// GetTenant returns the tenant that submitted the job, or "" for the operator
+	GetTenant() string
+
 	// GetStatus returns the job status
 	GetStatus() JobStatus
 
@@ -163,6 +314,9 @@ type Job interface {
 	// GetError returns the job error
 	GetError() error
 
+	// GetAPICalls returns the counter of the job's registry API calls
+	GetAPICalls() *apicalls.Counter
+
 	// Execute executes the job
 	Execute(ctx context.Context) error
 
@@ -186,6 +340,7 @@ type Job interface {
 type BaseJob struct {
 	ID          string      `json:"id"`
 	Type        JobType     `json:"type"`
+	Tenant      string      `json:"tenant,omitempty"`
 	Source      string      `json:"source"`
 	Destination string      `json:"destination"`
 	StartTime   time.Time   `json:"start_time"`
@@ -194,6 +349,9 @@ type BaseJob struct {
 	ErrorMsg    string      `json:"error,omitempty"`
 	ResultData  interface{} `json:"result,omitempty"`
 
+	// APICalls counts the registry API calls the job made
+	APICalls *apicalls.Counter `json:"api_calls"`
+
 	// Internal fields not serialized to JSON
 	error error `json:"-"`
 }
@@ -207,9 +365,23 @@ func NewBaseJob(jobType JobType, source, destination string) *BaseJob {
 		Destination: destination,
 		StartTime:   time.Now(),
 		Status:      JobStatusPending,
+		APICalls:    apicalls.NewCounter(),
 	}
 }
 
+// jobLabels describes a job in worker pool logs and results
+func jobLabels(job Job) map[string]string {
+	labels := map[string]string{
+		"job_type":    string(job.GetType()),
+		"source":      job.GetSource(),
+		"destination": job.GetDestination(),
+	}
+	if tenant := job.GetTenant(); tenant != "" {
+		labels["tenant"] = tenant
+	}
+	return labels
+}
+
 // GetID returns the job ID
 func (j *BaseJob) GetID() string {
 	return j.ID
@@ -220,6 +392,11 @@ func (j *BaseJob) GetType() JobType {
 	return j.Type
 }
 
+// GetTenant returns the tenant that submitted the job
+func (j *BaseJob) GetTenant() string {
+	return j.Tenant
+}
+
 // GetStatus returns the job status
 func (j *BaseJob) GetStatus() JobStatus {
 	return j.Status
@@ -250,6 +427,11 @@ func (j *BaseJob) GetResult() interface{} {
 	return j.ResultData
 }
 
+// GetAPICalls returns the counter of the job's registry API calls
+func (j *BaseJob) GetAPICalls() *apicalls.Counter {
+	return j.APICalls
+}
+
 // GetError returns the job error
 func (j *BaseJob) GetError() error {
 	return j.error
@@ -280,6 +462,18 @@ func (j *BaseJob) SetEndTime(time time.Time) {
 	j.EndTime = time
 }
 
+// fail records err, marking the job canceled rather than failed when its
+// context was canceled
+func (j *BaseJob) fail(ctx context.Context, err error) {
+	j.SetError(err)
+	if ctx.Err() != nil {
+		j.Status = JobStatusCanceled
+		j.EndTime = time.Now()
+		return
+	}
+	j.Status = JobStatusFailed
+}
+
 // ToJSON returns the job as JSON
 func (j *BaseJob) ToJSON() ([]byte, error) {
 	return json.Marshal(j)
@@ -311,12 +505,11 @@ func (j *ReplicateJob) Execute(ctx context.Context) error {
 	j.Status = JobStatusRunning
 
 	// Execute replication
-	result, err := j.svc.ReplicateRepository(ctx, j.Source, j.Destination)
+	result, err := j.replicate(ctx)
 
 	// Handle result and error
 	if err != nil {
-		j.Status = JobStatusFailed
-		j.SetError(err)
+		j.fail(ctx, err)
 		return err
 	}
 
@@ -328,6 +521,26 @@ func (j *ReplicateJob) Execute(ctx context.Context) error {
 	return nil
 }
 
+// replicate copies the repository, narrowed to the job's tags and options
+// when it has any
+func (j *ReplicateJob) replicate(ctx context.Context) (*service.ReplicationResult, error) {
+	if len(j.Tags) == 0 && !j.Force && !j.DryRun {
+		return j.svc.ReplicateRepository(ctx, j.Source, j.Destination)
+	}
+
+	sourceRegistry, sourceRepo, _ := strings.Cut(j.Source, "/")
+	destRegistry, destRepo, _ := strings.Cut(j.Destination, "/")
+	return j.svc.ReplicateImage(ctx, &service.ReplicationRequest{
+		SourceRegistry:        sourceRegistry,
+		SourceRepository:      sourceRepo,
+		SourceTags:            j.Tags,
+		DestinationRegistry:   destRegistry,
+		DestinationRepository: destRepo,
+		DestinationTags:       j.Tags,
+		Options:               &service.ReplicationOptions{DryRun: j.DryRun, ForceOverwrite: j.Force},
+	})
+}
+
 // ReplicateTreeJob represents a tree replication job
 type ReplicateTreeJob struct {
 	*BaseJob
@@ -403,10 +616,12 @@ func (j *ReplicateTreeJob) Execute(ctx context.Context) error {
 	// Execute replication
 	result, err := j.svc.ReplicateTree(ctx, j.Source, j.Destination)
 
-	// Handle result and error
+	// Handle result and error; a canceled tree job keeps its partial result
 	if err != nil {
-		j.Status = JobStatusFailed
-		j.SetError(err)
+		if result != nil {
+			j.ResultData = result
+		}
+		j.fail(ctx, err)
 		return err
 	}
 
@@ -81,6 +81,29 @@ func TestJobManagerListJobs(t *testing.T) {
 	assert.Len(t, pendingReplicateJobs, 2)
 }
 
+// TestJobManagerActiveJobs tests the jobs listed in diagnostics dumps
+func TestJobManagerActiveJobs(t *testing.T) {
+	manager := NewJobManager()
+
+	running := NewReplicateJob("ecr/repo1", "gcr/repo1", []string{"latest"}, false, false, &mockReplicationService{})
+	running.StartTime = time.Now().Add(-time.Hour)
+	running.SetStatus(JobStatusRunning)
+	pending := NewReplicateJob("ecr/repo2", "gcr/repo2", []string{"v1.0"}, false, false, &mockReplicationService{})
+	done := NewReplicateJob("ecr/repo3", "gcr/repo3", []string{"v1.0"}, false, false, &mockReplicationService{})
+	done.SetStatus(JobStatusCompleted)
+
+	manager.AddJob(pending)
+	manager.AddJob(done)
+	manager.AddJob(running)
+
+	active := manager.ActiveJobs()
+	require.Len(t, active, 2)
+	assert.Equal(t, running.GetID(), active[0].ID)
+	assert.Equal(t, JobStatusRunning, active[0].Status)
+	assert.Equal(t, "1h0m0s", active[0].Age)
+	assert.Equal(t, pending.GetID(), active[1].ID)
+}
+
 // TestJobManagerUpdateJob tests job updates
 func TestJobManagerUpdateJob(t *testing.T) {
 	manager := NewJobManager()
@@ -291,3 +314,56 @@ func TestJobWithMissingOptions(t *testing.T) {
 	assert.False(t, job.DryRun)
 	assert.False(t, job.EnableCheckpoint)
 }
+
+// blockingReplicationService blocks until its context is canceled
+type blockingReplicationService struct {
+	mockReplicationService
+	started chan struct{}
+}
+
+func (m *blockingReplicationService) ReplicateRepository(ctx context.Context, source, destination string) (*service.ReplicationResult, error) {
+	close(m.started)
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+// TestJobManagerCancelJob tests canceling pending, running and finished jobs
+func TestJobManagerCancelJob(t *testing.T) {
+	manager := NewJobManager()
+
+	_, err := manager.CancelJob("missing")
+	assert.Error(t, err)
+
+	// A pending job without a running task is marked canceled immediately
+	pending := NewReplicateJob("ecr/app", "gcr/app", nil, false, false, &mockReplicationService{})
+	manager.AddJob(pending)
+	done, err := manager.CancelJob(pending.GetID())
+	require.NoError(t, err)
+	<-done
+	assert.Equal(t, JobStatusCanceled, pending.GetStatus())
+
+	_, err = manager.CancelJob(pending.GetID())
+	assert.Error(t, err, "canceling twice should fail")
+
+	// A running job's context is canceled and done closes once its task returns
+	svc := &blockingReplicationService{started: make(chan struct{})}
+	running := NewReplicateJob("ecr/app", "gcr/app", nil, false, false, svc)
+	manager.AddJob(running)
+	ctx, finish := manager.startJob(running.GetID())
+	go func() {
+		defer finish()
+		_ = running.Execute(ctx)
+	}()
+	<-svc.started
+
+	done, err = manager.CancelJob(running.GetID())
+	require.NoError(t, err)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("canceled job did not drain")
+	}
+	assert.Equal(t, JobStatusCanceled, running.GetStatus())
+	assert.ErrorIs(t, running.GetError(), context.Canceled)
+	assert.Empty(t, manager.controls)
+}
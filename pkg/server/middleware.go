@@ -211,7 +211,7 @@ func (s *Server) authMiddleware(next http.Handler) http.Handler {
 		}
 
 		// Validate API key
-		if apiKey == "" || apiKey != s.cfg.Server.APIKey {
+		if !s.operatorKeyMatches(apiKey) {
 			s.logger.WithFields(map[string]interface{}{
 				"method":    r.Method,
 				"path":      r.URL.Path,
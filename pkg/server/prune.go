@@ -0,0 +1,672 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	stdsync "sync"
+	"time"
+
+	"freightliner/pkg/client"
+	"freightliner/pkg/helper/apicalls"
+	"freightliner/pkg/helper/errors"
+	"freightliner/pkg/helper/runid"
+	"freightliner/pkg/replication"
+	"freightliner/pkg/sync"
+	"freightliner/pkg/webhook"
+)
+
+// JobTypePrune is a scheduled prune of one destination repository
+const JobTypePrune JobType = "prune"
+
+// pruneFunc prunes one repository under a rule
+type pruneFunc func(ctx context.Context, rule sync.PruneRule) (*sync.PruneResult, error)
+
+// PruneJob applies one prune rule to its destination repository
+type PruneJob struct {
+	*BaseJob
+	Rule      sync.PruneRule `json:"rule"`
+	reportDir string
+	prune     pruneFunc
+}
+
+// NewPruneJob creates a new prune job
+func NewPruneJob(registry string, rule sync.PruneRule, reportDir string, prune pruneFunc) *PruneJob {
+	return &PruneJob{
+		BaseJob:   NewBaseJob(JobTypePrune, "", registry+"/"+rule.Repository),
+		Rule:      rule,
+		reportDir: reportDir,
+		prune:     prune,
+	}
+}
+
+// Execute executes the job
+func (j *PruneJob) Execute(ctx context.Context) error {
+	// Update status to running
+	j.Status = JobStatusRunning
+
+	result, err := j.prune(ctx, j.Rule)
+	if result != nil {
+		j.ResultData = result
+		if reportErr := j.writeReport(result); reportErr != nil && err == nil {
+			err = reportErr
+		}
+	}
+
+	// Handle result and error
+	if err != nil {
+		j.fail(ctx, err)
+		return err
+	}
+
+	j.Status = JobStatusCompleted
+	j.EndTime = time.Now()
+
+	return nil
+}
+
+// writeReport writes the prune result to the report directory, if configured
+func (j *PruneJob) writeReport(result *sync.PruneResult) error {
+	if j.reportDir == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "failed to encode prune report")
+	}
+	if err := os.MkdirAll(j.reportDir, 0755); err != nil {
+		return errors.Wrap(err, "failed to create prune report directory")
+	}
+
+	name := fmt.Sprintf("prune-%s-%s.json",
+		strings.ReplaceAll(j.Rule.Repository, "/", "_"), result.StartedAt.Format("20060102T150405Z"))
+	if err := os.WriteFile(filepath.Join(j.reportDir, name), data, 0644); err != nil {
+		return errors.Wrap(err, "failed to write prune report")
+	}
+	return nil
+}
+
+// pruneScheduler submits a prune job for every scheduled rule of a sync
+// configuration when the rule's schedule fires
+type pruneScheduler struct {
+	server       *Server
+	reportDir    string
+	pauseWebhook string
+	prune        pruneFunc
+
+	// source is polled every pollInterval for configuration changes, and
+	// lister expands the generators of each new configuration
+	source       sync.ConfigSource
+	version      string
+	pollInterval time.Duration
+	lister       sync.RepositoryLister
+
+	// mu guards the configuration, which a reload replaces
+	mu      stdsync.RWMutex
+	syncCfg *sync.Config
+
+	// histories tracks the runs of rules with an error budget, by repository
+	histories map[string]*replication.RunHistory
+
+	// stopRules stops the rule goroutines of the current configuration
+	stopRules context.CancelFunc
+
+	// changes holds the tag list snapshots of every rule, by repository
+	changes *tagFeed
+
+	// calls counts the registry API calls of every rule since the server
+	// started, by repository
+	calls *apicalls.Group
+
+	// state keeps the last run status of every rule, by repository
+	state *ruleStateStore
+}
+
+// newPruneScheduler loads the sync configuration named by the server config.
+// It returns nil when scheduled pruning is not configured.
+func newPruneScheduler(s *Server) (*pruneScheduler, error) {
+	if s.cfg.Prune.SyncConfig == "" {
+		return nil, nil
+	}
+
+	source, err := sync.NewConfigSource(s.cfg.Prune.SyncConfig)
+	if err != nil {
+		return nil, err
+	}
+	syncCfg, version, err := sync.LoadConfigFromSource(context.Background(), source, "")
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load prune config %s", s.cfg.Prune.SyncConfig)
+	}
+
+	state, err := loadRuleStateStore(s.cfg.Prune.StateFile)
+	if err != nil {
+		return nil, err
+	}
+
+	pruner := sync.NewPruner(s.logger)
+	factory := client.NewFactory(s.cfg, s.logger)
+	lister := sync.NewRepositoryLister(factory)
+	if err := syncCfg.ExpandGenerators(context.Background(), lister); err != nil {
+		return nil, errors.Wrapf(err, "failed to expand generators of %s", s.cfg.Prune.SyncConfig)
+	}
+
+	p := &pruneScheduler{
+		server:       s,
+		reportDir:    s.cfg.Prune.ReportDir,
+		pauseWebhook: s.cfg.Prune.PauseWebhook,
+		source:       source,
+		version:      version,
+		pollInterval: s.cfg.Prune.PollInterval,
+		lister:       lister,
+		syncCfg:      syncCfg,
+		histories:    pruneHistories(syncCfg, nil, nil),
+		changes:      newTagFeed(),
+		calls:        apicalls.NewGroup(),
+		state:        state,
+	}
+	p.prune = func(ctx context.Context, rule sync.PruneRule) (*sync.PruneResult, error) {
+		cfg := p.config()
+		result, err := pruner.PruneDestination(ctx, factory, cfg, rule, false)
+		results := []*sync.PruneResult{result}
+		// Tags whose trash retention is over are deleted on the same
+		// schedule. Failures to empty the trash are logged and, like garbage
+		// collection failures, do not fail the prune run or count against
+		// its error budget.
+		if rule.Trash != nil && err == nil && ctx.Err() == nil {
+			trash, trashErr := pruner.EmptyTrashDestination(ctx, factory, cfg, rule, false)
+			if trashErr != nil {
+				s.logger.WithFields(map[string]interface{}{
+					"repository": rule.Repository,
+					"error":      trashErr.Error(),
+				}).Warn("Failed to empty prune trash")
+			}
+			results = append(results, trash)
+		}
+		if result != nil && ctx.Err() == nil {
+			_, _ = pruner.CollectGarbage(ctx, factory, cfg, results)
+		}
+		return result, err
+	}
+	return p, nil
+}
+
+// pruneHistories returns the run histories of syncCfg's rules with an error
+// budget. Rules whose budget is unchanged from previousCfg keep their
+// history from previous.
+func pruneHistories(syncCfg, previousCfg *sync.Config, previous map[string]*replication.RunHistory) map[string]*replication.RunHistory {
+	budgets := make(map[string]replication.ErrorBudget)
+	if previousCfg != nil {
+		for _, rule := range previousCfg.Prune {
+			if rule.ErrorBudget != nil {
+				budgets[rule.Repository] = *rule.ErrorBudget
+			}
+		}
+	}
+
+	histories := make(map[string]*replication.RunHistory)
+	for _, rule := range syncCfg.Prune {
+		if rule.ErrorBudget == nil {
+			continue
+		}
+		if history, ok := previous[rule.Repository]; ok && budgets[rule.Repository] == *rule.ErrorBudget {
+			histories[rule.Repository] = history
+			continue
+		}
+		histories[rule.Repository] = replication.NewRunHistory(rule.Repository, *rule.ErrorBudget)
+	}
+	return histories
+}
+
+// start runs every scheduled rule until ctx is done. With a poll interval it
+// also watches the configuration's source and reloads the rules on changes.
+func (p *pruneScheduler) start(ctx context.Context) {
+	p.startRules(ctx)
+
+	if p.pollInterval > 0 && p.source != nil {
+		watcher := &sync.ConfigWatcher{
+			Source:   p.source,
+			Interval: p.pollInterval,
+			OnChange: func(syncCfg *sync.Config, version string) {
+				p.reload(ctx, syncCfg, version)
+			},
+			OnError: func(err error) {
+				p.server.logger.Error("Keeping previous prune config", err)
+			},
+		}
+		go watcher.Run(ctx, p.version)
+	}
+}
+
+// startRules runs the scheduled rules of the current configuration until ctx
+// is done or the configuration is reloaded
+func (p *pruneScheduler) startRules(ctx context.Context) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	ctx, p.stopRules = context.WithCancel(ctx)
+	for _, rule := range p.syncCfg.Prune {
+		if rule.Schedule == "" {
+			continue
+		}
+
+		p.server.logger.WithFields(map[string]interface{}{
+			"repository": rule.Repository,
+			"schedule":   rule.Schedule,
+			"delete":     rule.Delete,
+		}).Info("Scheduled prune rule")
+
+		go p.run(ctx, rule)
+	}
+}
+
+// reload replaces the configuration with syncCfg and restarts the rules.
+// Jobs already submitted finish under the rules they started with.
+func (p *pruneScheduler) reload(ctx context.Context, syncCfg *sync.Config, version string) {
+	if err := syncCfg.ExpandGenerators(ctx, p.lister); err != nil {
+		p.server.logger.Error("Keeping previous prune config", errors.Wrap(err, "failed to expand generators"))
+		return
+	}
+
+	p.mu.Lock()
+	if p.stopRules != nil {
+		p.stopRules()
+	}
+	p.histories = pruneHistories(syncCfg, p.syncCfg, p.histories)
+	p.syncCfg = syncCfg
+	p.version = version
+	p.mu.Unlock()
+
+	p.server.logger.WithFields(map[string]interface{}{
+		"source":  p.source.String(),
+		"version": version,
+		"rules":   len(syncCfg.Prune),
+	}).Info("Reloaded prune config")
+
+	p.startRules(ctx)
+}
+
+// config returns the current sync configuration
+func (p *pruneScheduler) config() *sync.Config {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.syncCfg
+}
+
+// rules returns the prune rules of the current configuration
+func (p *pruneScheduler) rules() []sync.PruneRule {
+	return p.config().Prune
+}
+
+// history returns the run history of the rule for repository, if it has an
+// error budget
+func (p *pruneScheduler) history(repository string) (*replication.RunHistory, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	history, ok := p.histories[repository]
+	return history, ok
+}
+
+// pruneQueuePoll is how often a queued prune run checks whether the previous
+// job has finished
+const pruneQueuePoll = time.Second
+
+// run submits a prune job each time rule's schedule fires. While the previous
+// job for the rule is still pending or running the run is skipped, or with
+// the queue overlap policy started once that job finishes.
+func (p *pruneScheduler) run(ctx context.Context, rule sync.PruneRule) {
+	var last Job
+	for {
+		next, err := rule.NextRun(time.Now())
+		if err != nil {
+			p.server.logger.Error("Invalid prune schedule", err)
+			return
+		}
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		if pause := p.paused(rule.Repository); pause != nil {
+			p.server.logger.WithFields(map[string]interface{}{
+				"repository": rule.Repository,
+				"reason":     pause.Reason,
+			}).Debug("Prune rule paused, skipping scheduled run")
+			continue
+		}
+
+		// Frozen runs are skipped rather than failed so they do not count
+		// against the error budget
+		if rule.Delete {
+			var frozen *replication.FrozenError
+			if errors.As(rule.Freeze.Check(rule.Repository, time.Now()), &frozen) {
+				p.server.logger.WithFields(map[string]interface{}{
+					"repository": rule.Repository,
+					"freeze":     frozen.Freeze,
+					"until":      frozen.Until,
+				}).Info("Prune rule frozen, skipping scheduled run")
+				continue
+			}
+		}
+
+		if jobActive(last) {
+			fields := map[string]interface{}{
+				"repository": rule.Repository,
+				"job_id":     last.GetID(),
+			}
+			if rule.Overlap != replication.OverlapQueue {
+				p.server.logger.WithFields(fields).Warn("Previous prune still running, skipping scheduled run")
+				continue
+			}
+
+			// Ticks while waiting fold into this one queued run
+			p.server.logger.WithFields(fields).Info("Previous prune still running, queueing scheduled run")
+			if !waitForJob(ctx, last, pruneQueuePoll) {
+				return
+			}
+		}
+
+		job := NewPruneJob(p.config().Destination.Registry, rule, p.reportDir, p.recordRuns(rule.Repository))
+		p.server.jobManager.AddJob(job)
+		if err := p.server.submitJob(job); err != nil {
+			job.SetStatus(JobStatusFailed)
+			job.SetError(errors.Wrap(err, "failed to submit prune job"))
+			p.server.logger.Error("Failed to submit prune job", err)
+			continue
+		}
+		last = job
+	}
+}
+
+// recordRuns returns the prune function for the rule of repository. It
+// counts the registry API calls of each run, snapshots the tags it lists,
+// records its status and counts it against the rule's error budget if it
+// has one.
+func (p *pruneScheduler) recordRuns(repository string) pruneFunc {
+	prune := func(ctx context.Context, rule sync.PruneRule) (*sync.PruneResult, error) {
+		started := time.Now()
+		result, err := p.prune(apicalls.WithCounter(ctx, p.calls.Counter(repository)), rule)
+		if tags, ok := pruneSnapshot(result, err); ok {
+			p.snapshot(repository, tags)
+		}
+		if ctx.Err() == nil {
+			p.recordStatus(rule, started, result, err)
+		}
+		return result, err
+	}
+
+	history, ok := p.history(repository)
+	if !ok {
+		return prune
+	}
+
+	return func(ctx context.Context, rule sync.PruneRule) (*sync.PruneResult, error) {
+		result, err := prune(ctx, rule)
+		// A run cut short by shutdown or cancellation says nothing about the rule
+		if ctx.Err() != nil {
+			return result, err
+		}
+		if pause := history.Record(time.Now(), err); pause != nil {
+			p.notifyPause(ctx, *pause)
+		}
+		return result, err
+	}
+}
+
+// recordStatus stores the outcome of a run of rule started at started
+func (p *pruneScheduler) recordStatus(rule sync.PruneRule, started time.Time, result *sync.PruneResult, err error) {
+	if p.state == nil {
+		return
+	}
+
+	run := RuleRun{StartedAt: started, FinishedAt: time.Now(), Outcome: RunSucceeded}
+	if err != nil {
+		run.Outcome = RunFailed
+		run.Error = err.Error()
+	}
+	if result != nil {
+		run.Counters = map[string]int64{
+			"tags_kept":         int64(len(result.Kept)),
+			"tags_removed":      int64(len(result.Removed)),
+			"tags_failed":       int64(len(result.Failed)),
+			"reclaimable_bytes": result.ReclaimableBytes,
+		}
+	}
+
+	var next time.Time
+	if rule.Schedule != "" {
+		next, _ = rule.NextRun(run.FinishedAt)
+	}
+	if saveErr := p.state.record(rule.Repository, run, next); saveErr != nil {
+		p.server.logger.Error("Failed to save rule state", saveErr)
+	}
+}
+
+// pruneSnapshot returns the tags, by name to digest, a prune run listed. It
+// returns false when the run failed before it had the whole tag list.
+func pruneSnapshot(result *sync.PruneResult, err error) (map[string]string, bool) {
+	if result == nil {
+		return nil, false
+	}
+	decisions := append(append(append([]sync.PruneDecision(nil), result.Kept...), result.Removed...), result.Failed...)
+	// Tags are only decided on once all of them were listed and inspected
+	if err != nil && len(decisions) == 0 {
+		return nil, false
+	}
+
+	tags := make(map[string]string, len(decisions))
+	for _, decision := range decisions {
+		tags[decision.Tag] = decision.Digest
+	}
+	return tags, true
+}
+
+// snapshot records tags as the current tag list of the rule for repository
+func (p *pruneScheduler) snapshot(repository string, tags map[string]string) {
+	changes := p.changes.record(repository, tags, time.Now().UTC())
+	if len(changes) == 0 {
+		return
+	}
+	p.server.logger.WithFields(map[string]interface{}{
+		"repository": repository,
+		"revision":   changes[0].Revision,
+		"changes":    len(changes),
+	}).Debug("Tag list of scheduled rule changed")
+}
+
+// hasRule reports whether the scheduler runs a rule for repository
+func (p *pruneScheduler) hasRule(repository string) bool {
+	for _, rule := range p.rules() {
+		if rule.Repository == repository {
+			return true
+		}
+	}
+	return false
+}
+
+// frozen returns the freeze, at at, of a rule for one of repositories or for
+// a repository under one of prefixes, or nil when they may be changed
+func (p *pruneScheduler) frozen(at time.Time, repositories, prefixes []string) *replication.FrozenError {
+	for _, rule := range p.rules() {
+		if !matchesRuleRepository(rule.Repository, repositories, prefixes) {
+			continue
+		}
+		var frozen *replication.FrozenError
+		if errors.As(rule.Freeze.Check(rule.Repository, at), &frozen) {
+			return frozen
+		}
+	}
+	return nil
+}
+
+// rejectFrozen writes a 423 response and returns true when a request would
+// change a repository whose rule is frozen, telling the caller when to retry
+func (s *Server) rejectFrozen(w http.ResponseWriter, repositories, prefixes []string) bool {
+	if s.pruneScheduler == nil {
+		return false
+	}
+	frozen := s.pruneScheduler.frozen(time.Now(), repositories, prefixes)
+	if frozen == nil {
+		return false
+	}
+
+	s.logger.WithFields(map[string]interface{}{
+		"rule":   frozen.Rule,
+		"freeze": frozen.Freeze,
+		"until":  frozen.Until,
+	}).Info("Rejected change to frozen rule")
+
+	if retry := time.Until(frozen.Until); retry > 0 {
+		w.Header().Set("Retry-After", fmt.Sprintf("%d", int(retry.Round(time.Second)/time.Second)))
+	}
+	s.writeErrorResponse(w, http.StatusLocked, frozen.Error())
+	return true
+}
+
+// matchesRuleRepository reports whether repository is one of repositories or
+// under one of prefixes; an empty prefix covers every repository
+func matchesRuleRepository(repository string, repositories, prefixes []string) bool {
+	for _, r := range repositories {
+		if r == repository {
+			return true
+		}
+	}
+	for _, prefix := range prefixes {
+		prefix = strings.Trim(prefix, "/")
+		if prefix == "" || repository == prefix || strings.HasPrefix(repository, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// status returns the recorded status of rule, with its next scheduled run
+// after now unless the rule is paused
+func (p *pruneScheduler) status(rule sync.PruneRule, now time.Time) RuleStatus {
+	var status RuleStatus
+	if p.state != nil {
+		status = p.state.status(rule.Repository)
+	}
+
+	status.NextRun = nil
+	if rule.Schedule != "" && p.paused(rule.Repository) == nil {
+		if next, err := rule.NextRun(now); err == nil {
+			status.NextRun = &next
+		}
+	}
+	return status
+}
+
+// paused returns the pause of the rule for repository, or nil if it runs on
+// schedule
+func (p *pruneScheduler) paused(repository string) *replication.RulePause {
+	history, ok := p.history(repository)
+	if !ok {
+		return nil
+	}
+	return history.Paused()
+}
+
+// resume lifts the pause of the rule for repository. It reports whether the
+// scheduler runs a rule for repository.
+func (p *pruneScheduler) resume(repository string) bool {
+	for _, rule := range p.rules() {
+		if rule.Repository != repository {
+			continue
+		}
+		if history, ok := p.history(repository); ok && history.Resume() {
+			p.server.logger.WithFields(map[string]interface{}{
+				"repository": repository,
+			}).Info("Resumed paused prune rule")
+		}
+		return true
+	}
+	return false
+}
+
+// pauseWebhookTimeout bounds the notification sent when a rule is paused
+const pauseWebhookTimeout = 10 * time.Second
+
+// notifyPause logs that a rule was paused and posts the pause to the
+// configured webhook
+func (p *pruneScheduler) notifyPause(ctx context.Context, pause replication.RulePause) {
+	pause.RunID = runid.FromContext(ctx)
+	p.server.logger.WithFields(map[string]interface{}{
+		"repository": pause.Rule,
+		"reason":     pause.Reason,
+		"last_error": pause.LastError,
+	}).Warn("Prune rule exceeded its error budget, pausing schedule")
+
+	if p.pauseWebhook == "" {
+		return
+	}
+	if err := postPause(ctx, p.pauseWebhook, p.server.webhookSigner, pause); err != nil {
+		p.server.logger.WithFields(map[string]interface{}{
+			"repository": pause.Rule,
+			"error":      err.Error(),
+		}).Warn("Failed to send rule pause notification")
+	}
+}
+
+// postPause sends pause as JSON to endpoint, signed by signer if set
+func postPause(ctx context.Context, endpoint string, signer *webhook.Signer, pause replication.RulePause) error {
+	body, err := json.Marshal(pause)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode pause notification")
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), pauseWebhookTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, "invalid pause webhook")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if err := signer.Sign(req, body); err != nil {
+		return err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "pause webhook unreachable")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return errors.Newf("pause webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// jobActive reports whether job is still pending or running
+func jobActive(job Job) bool {
+	if job == nil {
+		return false
+	}
+	status := job.GetStatus()
+	return status == JobStatusPending || status == JobStatusRunning
+}
+
+// waitForJob polls until job is no longer active; it returns false if ctx
+// is done first
+func waitForJob(ctx context.Context, job Job, poll time.Duration) bool {
+	ticker := time.NewTicker(poll)
+	defer ticker.Stop()
+
+	for jobActive(job) {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ticker.C:
+		}
+	}
+	return true
+}
@@ -0,0 +1,282 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"freightliner/pkg/config"
+	"freightliner/pkg/helper/apicalls"
+	"freightliner/pkg/helper/log"
+	"freightliner/pkg/replication"
+	"freightliner/pkg/service"
+	"freightliner/pkg/sync"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPruneJobExecute(t *testing.T) {
+	reportDir := t.TempDir()
+	rule := sync.PruneRule{Repository: "mirror/app", KeepLast: 3}
+
+	job := NewPruneJob("registry.example.com", rule, reportDir, func(ctx context.Context, r sync.PruneRule) (*sync.PruneResult, error) {
+		return &sync.PruneResult{
+			Registry:   "registry.example.com",
+			Repository: r.Repository,
+			DryRun:     true,
+			StartedAt:  time.Date(2026, 6, 1, 3, 0, 0, 0, time.UTC),
+			Removed:    []sync.PruneDecision{{Tag: "old", Action: sync.PruneActionRemove}},
+		}, nil
+	})
+	assert.Equal(t, JobTypePrune, job.GetType())
+	assert.Equal(t, "registry.example.com/mirror/app", job.GetDestination())
+
+	require.NoError(t, job.Execute(context.Background()))
+	assert.Equal(t, JobStatusCompleted, job.GetStatus())
+
+	data, err := os.ReadFile(filepath.Join(reportDir, "prune-mirror_app-20260601T030000Z.json"))
+	require.NoError(t, err)
+	var report sync.PruneResult
+	require.NoError(t, json.Unmarshal(data, &report))
+	assert.True(t, report.DryRun)
+	require.Len(t, report.Removed, 1)
+	assert.Equal(t, "old", report.Removed[0].Tag)
+}
+
+func TestNewServerLoadsPruneConfig(t *testing.T) {
+	dir := t.TempDir()
+	syncFile := filepath.Join(dir, "sync.yaml")
+	require.NoError(t, os.WriteFile(syncFile, []byte(`
+destination:
+  registry: "registry.example.com"
+prune:
+  - repository: "mirror/app"
+    keep_last: 5
+    schedule: "@daily"
+`), 0644))
+
+	cfg := config.NewDefaultConfig()
+	cfg.Checkpoint.Directory = dir
+	cfg.Prune.SyncConfig = syncFile
+	logger := log.NewBasicLogger(log.ErrorLevel)
+
+	server, err := NewServer(context.Background(), cfg, logger, &mockReplicationService{},
+		service.NewTreeReplicationService(cfg, logger), service.NewCheckpointService(cfg, logger))
+	require.NoError(t, err)
+	require.NotNil(t, server.pruneScheduler)
+	assert.Len(t, server.pruneScheduler.syncCfg.Prune, 1)
+
+	cfg.Prune.SyncConfig = filepath.Join(dir, "missing.yaml")
+	_, err = NewServer(context.Background(), cfg, logger, &mockReplicationService{},
+		service.NewTreeReplicationService(cfg, logger), service.NewCheckpointService(cfg, logger))
+	assert.Error(t, err)
+}
+
+func TestPruneSchedulerReloadsConfig(t *testing.T) {
+	dir := t.TempDir()
+	syncFile := filepath.Join(dir, "sync.yaml")
+	rules := `
+destination:
+  registry: "registry.example.com"
+prune:
+  - repository: "mirror/app"
+    keep_last: 5
+    error_budget:
+      max_failure_rate: 0.5
+`
+	require.NoError(t, os.WriteFile(syncFile, []byte(rules), 0644))
+
+	cfg := config.NewDefaultConfig()
+	cfg.Checkpoint.Directory = dir
+	cfg.Prune.SyncConfig = syncFile
+	cfg.Prune.PollInterval = 10 * time.Millisecond
+	logger := log.NewBasicLogger(log.ErrorLevel)
+
+	server, err := NewServer(context.Background(), cfg, logger, &mockReplicationService{},
+		service.NewTreeReplicationService(cfg, logger), service.NewCheckpointService(cfg, logger))
+	require.NoError(t, err)
+	scheduler := server.pruneScheduler
+	history, ok := scheduler.history("mirror/app")
+	require.True(t, ok)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	scheduler.start(ctx)
+
+	// Invalid rollouts keep the running rules
+	require.NoError(t, os.WriteFile(syncFile, []byte("prune: [{}]\n"), 0644))
+	time.Sleep(50 * time.Millisecond)
+	assert.Len(t, scheduler.rules(), 1)
+
+	require.NoError(t, os.WriteFile(syncFile, []byte(rules+`
+  - repository: "mirror/web"
+    keep_last: 3
+`), 0644))
+	require.Eventually(t, func() bool { return len(scheduler.rules()) == 2 }, 5*time.Second, 10*time.Millisecond)
+
+	// A rule with an unchanged budget keeps its run history
+	kept, ok := scheduler.history("mirror/app")
+	require.True(t, ok)
+	assert.Same(t, history, kept)
+}
+
+func TestPruneSchedulerErrorBudget(t *testing.T) {
+	notified := make(chan replication.RulePause, 1)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var pause replication.RulePause
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&pause))
+		notified <- pause
+	}))
+	defer webhook.Close()
+
+	server := createTestServer(t)
+	rule := sync.PruneRule{
+		Repository:  "mirror/app",
+		KeepLast:    3,
+		Schedule:    "@hourly",
+		ErrorBudget: &replication.ErrorBudget{MaxFailureRate: 0.5, MinRuns: 2},
+	}
+	scheduler := &pruneScheduler{
+		server:       server,
+		syncCfg:      &sync.Config{Prune: []sync.PruneRule{rule}},
+		pauseWebhook: webhook.URL,
+		prune: func(ctx context.Context, r sync.PruneRule) (*sync.PruneResult, error) {
+			return nil, errors.New("registry unavailable")
+		},
+		histories: map[string]*replication.RunHistory{
+			rule.Repository: replication.NewRunHistory(rule.Repository, *rule.ErrorBudget),
+		},
+	}
+	server.pruneScheduler = scheduler
+
+	prune := scheduler.recordRuns(rule.Repository)
+	for i := 0; i < 2; i++ {
+		_, err := prune(context.Background(), rule)
+		require.Error(t, err)
+	}
+
+	select {
+	case pause := <-notified:
+		assert.Equal(t, "mirror/app", pause.Rule)
+		assert.Equal(t, "registry unavailable", pause.LastError)
+	case <-time.After(5 * time.Second):
+		t.Fatal("pause notification not sent")
+	}
+	require.NotNil(t, scheduler.paused(rule.Repository))
+
+	w := httptest.NewRecorder()
+	server.listRulesHandler(w, httptest.NewRequest("GET", "/api/v1/rules", nil))
+	var rules struct {
+		Rules []RuleSummary `json:"rules"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &rules))
+	require.Len(t, rules.Rules, 1)
+	require.NotNil(t, rules.Rules[0].Pause)
+	assert.Contains(t, rules.Rules[0].Pause.Reason, "2 of 2 runs failed")
+
+	w = httptest.NewRecorder()
+	server.resumeRuleHandler(w, httptest.NewRequest("POST", "/api/v1/rules/resume", strings.NewReader(`{"repository":"mirror/other"}`)))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = httptest.NewRecorder()
+	server.resumeRuleHandler(w, httptest.NewRequest("POST", "/api/v1/rules/resume", strings.NewReader(`{"repository":"mirror/app"}`)))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Nil(t, scheduler.paused(rule.Repository))
+}
+
+func TestPruneSchedulerCountsAPICalls(t *testing.T) {
+	registry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer registry.Close()
+	client := &http.Client{Transport: apicalls.Transport(http.DefaultTransport)}
+	host := strings.TrimPrefix(registry.URL, "http://")
+
+	server := createTestServer(t)
+	rule := sync.PruneRule{Repository: "mirror/app", KeepLast: 3, Schedule: "@hourly"}
+	scheduler := &pruneScheduler{
+		server:  server,
+		syncCfg: &sync.Config{Prune: []sync.PruneRule{rule}},
+		prune: func(ctx context.Context, r sync.PruneRule) (*sync.PruneResult, error) {
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, registry.URL+"/v2/mirror/app/tags/list", nil)
+			if err != nil {
+				return nil, err
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				return nil, err
+			}
+			resp.Body.Close()
+			return &sync.PruneResult{}, nil
+		},
+		changes: newTagFeed(),
+		calls:   apicalls.NewGroup(),
+	}
+	server.pruneScheduler = scheduler
+
+	// Each run counts towards its job and towards the rule
+	for i := 0; i < 2; i++ {
+		job := NewPruneJob("registry.example.com", rule, "", scheduler.recordRuns(rule.Repository))
+		require.NoError(t, job.Execute(apicalls.WithCounter(context.Background(), job.GetAPICalls())))
+		assert.Equal(t, apicalls.Counts{host: {apicalls.CallTagList: 1}}, job.GetAPICalls().Counts())
+	}
+
+	w := httptest.NewRecorder()
+	server.listRulesHandler(w, httptest.NewRequest("GET", "/api/v1/rules", nil))
+	var rules struct {
+		Rules []RuleSummary `json:"rules"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &rules))
+	require.Len(t, rules.Rules, 1)
+	assert.Equal(t, int64(2), rules.Rules[0].APICalls.Total())
+}
+
+func TestReplicateHandlerRejectsFrozenRule(t *testing.T) {
+	server := createTestServer(t)
+	today := time.Now().UTC().Format("2006-01-02")
+	rule := sync.PruneRule{
+		Repository: "mirror/app",
+		KeepLast:   3,
+		Freeze:     &replication.FreezePolicy{Blackouts: []replication.Blackout{{Name: "release", Start: today}}},
+	}
+	server.pruneScheduler = &pruneScheduler{
+		server:  server,
+		syncCfg: &sync.Config{Prune: []sync.PruneRule{rule}},
+		changes: newTagFeed(),
+		calls:   apicalls.NewGroup(),
+	}
+
+	post := func(path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, httptest.NewRequest("POST", path, strings.NewReader(body)))
+		return w
+	}
+
+	w := post("/api/v1/replicate", `{"source_registry": "ecr", "source_repo": "app", "dest_registry": "gcr", "dest_repo": "mirror/app", "tags": ["v1"]}`)
+	assert.Equal(t, http.StatusLocked, w.Code)
+	assert.Contains(t, w.Body.String(), `blackout \"release\"`)
+	assert.NotEmpty(t, w.Header().Get("Retry-After"))
+
+	// Trees containing the repository are refused too
+	w = post("/api/v1/replicate-tree", `{"source_registry": "ecr", "source_repo": "apps", "dest_registry": "gcr", "dest_repo": "mirror"}`)
+	assert.Equal(t, http.StatusLocked, w.Code)
+
+	// Dry runs and other repositories are not changes to the rule
+	w = post("/api/v1/replicate", `{"source_registry": "ecr", "source_repo": "app", "dest_registry": "gcr", "dest_repo": "mirror/app", "tags": ["v1"], "dry_run": true}`)
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	w = post("/api/v1/replicate", `{"source_registry": "ecr", "source_repo": "app", "dest_registry": "gcr", "dest_repo": "mirror/other", "tags": ["v1"]}`)
+	assert.Equal(t, http.StatusAccepted, w.Code)
+
+	// The rules list shows the freeze
+	w = httptest.NewRecorder()
+	server.router.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/rules", nil))
+	assert.Contains(t, w.Body.String(), `"frozen":{"freeze":"blackout \"release\""`)
+}
@@ -0,0 +1,164 @@
+package server
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"freightliner/pkg/helper/errors"
+)
+
+// Rule run outcomes
+const (
+	RunSucceeded = "succeeded"
+	RunFailed    = "failed"
+)
+
+// Rule health, from the rule's last run and schedule
+const (
+	RuleHealthy = "healthy"
+	RuleFailing = "failing"
+	RulePaused  = "paused"
+	RuleNotRun  = "not_run"
+)
+
+// RuleRun is one finished run of a scheduled rule
+type RuleRun struct {
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	Outcome    string    `json:"outcome"`
+	Error      string    `json:"error,omitempty"`
+
+	// Counters summarizes what the run did, e.g. tags kept and removed
+	Counters map[string]int64 `json:"counters,omitempty"`
+}
+
+// RuleStatus is the run history summary of a scheduled rule
+type RuleStatus struct {
+	LastRun     *RuleRun   `json:"last_run,omitempty"`
+	LastSuccess *time.Time `json:"last_success,omitempty"`
+	NextRun     *time.Time `json:"next_run,omitempty"`
+
+	Runs                int `json:"runs"`
+	Failures            int `json:"failures"`
+	ConsecutiveFailures int `json:"consecutive_failures"`
+}
+
+// ruleStateStore keeps the status of every scheduled rule, by repository.
+// With a path the statuses are written to it after every run and read back
+// when the server starts, so they survive restarts.
+type ruleStateStore struct {
+	mu    sync.Mutex
+	path  string
+	rules map[string]*RuleStatus
+}
+
+// loadRuleStateStore reads the rule statuses at path. A missing file, or an
+// empty path, yields an empty store.
+func loadRuleStateStore(path string) (*ruleStateStore, error) {
+	store := &ruleStateStore{path: path, rules: make(map[string]*RuleStatus)}
+	if path == "" {
+		return store, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read rule state")
+	}
+	var saved struct {
+		Rules map[string]*RuleStatus `json:"rules"`
+	}
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse rule state %s", path)
+	}
+	for repository, status := range saved.Rules {
+		if status != nil {
+			store.rules[repository] = status
+		}
+	}
+	return store, nil
+}
+
+// record adds run to the status of the rule for repository, with next as its
+// next scheduled run, and saves the store
+func (s *ruleStateStore) record(repository string, run RuleRun, next time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	status := s.rules[repository]
+	if status == nil {
+		status = &RuleStatus{}
+		s.rules[repository] = status
+	}
+	status.LastRun = &run
+	status.Runs++
+	if run.Outcome == RunSucceeded {
+		finished := run.FinishedAt
+		status.LastSuccess = &finished
+		status.ConsecutiveFailures = 0
+	} else {
+		status.Failures++
+		status.ConsecutiveFailures++
+	}
+	status.NextRun = nil
+	if !next.IsZero() {
+		status.NextRun = &next
+	}
+
+	return s.save()
+}
+
+// status returns a copy of the status of the rule for repository
+func (s *ruleStateStore) status(repository string) RuleStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if status := s.rules[repository]; status != nil {
+		return *status
+	}
+	return RuleStatus{}
+}
+
+// save writes the store to its path, replacing the file atomically. The
+// caller holds mu.
+func (s *ruleStateStore) save() error {
+	if s.path == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(map[string]interface{}{"rules": s.rules}, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "failed to encode rule state")
+	}
+	if dir := filepath.Dir(s.path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return errors.Wrap(err, "failed to create rule state directory")
+		}
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return errors.Wrap(err, "failed to write rule state")
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return errors.Wrap(err, "failed to replace rule state")
+	}
+	return nil
+}
+
+// ruleHealth sums up a rule's status: paused rules first, then the outcome
+// of the last run
+func ruleHealth(status RuleStatus, paused bool) string {
+	switch {
+	case paused:
+		return RulePaused
+	case status.LastRun == nil:
+		return RuleNotRun
+	case status.LastRun.Outcome == RunSucceeded:
+		return RuleHealthy
+	}
+	return RuleFailing
+}
@@ -0,0 +1,119 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"freightliner/pkg/sync"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRuleStateStorePersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "rules.json")
+	store, err := loadRuleStateStore(path)
+	require.NoError(t, err)
+	assert.Equal(t, RuleNotRun, ruleHealth(store.status("mirror/app"), false))
+
+	started := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	next := started.Add(time.Hour)
+	require.NoError(t, store.record("mirror/app", RuleRun{
+		StartedAt: started, FinishedAt: started.Add(time.Minute), Outcome: RunSucceeded,
+		Counters: map[string]int64{"tags_removed": 3},
+	}, next))
+	require.NoError(t, store.record("mirror/app", RuleRun{
+		StartedAt: next, FinishedAt: next.Add(time.Minute), Outcome: RunFailed, Error: "registry unavailable",
+	}, next.Add(time.Hour)))
+
+	reloaded, err := loadRuleStateStore(path)
+	require.NoError(t, err)
+	status := reloaded.status("mirror/app")
+	assert.Equal(t, 2, status.Runs)
+	assert.Equal(t, 1, status.Failures)
+	assert.Equal(t, 1, status.ConsecutiveFailures)
+	require.NotNil(t, status.LastRun)
+	assert.Equal(t, "registry unavailable", status.LastRun.Error)
+	require.NotNil(t, status.LastSuccess)
+	assert.True(t, started.Add(time.Minute).Equal(*status.LastSuccess))
+	require.NotNil(t, status.NextRun)
+	assert.True(t, next.Add(time.Hour).Equal(*status.NextRun))
+
+	assert.Equal(t, RuleFailing, ruleHealth(status, false))
+	assert.Equal(t, RulePaused, ruleHealth(status, true))
+}
+
+func TestRuleStateStoreWithoutPath(t *testing.T) {
+	store, err := loadRuleStateStore("")
+	require.NoError(t, err)
+	require.NoError(t, store.record("mirror/app", RuleRun{Outcome: RunSucceeded}, time.Time{}))
+	assert.Equal(t, RuleHealthy, ruleHealth(store.status("mirror/app"), false))
+	assert.Nil(t, store.status("mirror/app").NextRun)
+}
+
+func TestPruneSchedulerRecordsRuleStatus(t *testing.T) {
+	server := createTestServer(t)
+	state, err := loadRuleStateStore(filepath.Join(t.TempDir(), "rules.json"))
+	require.NoError(t, err)
+
+	rule := sync.PruneRule{Repository: "mirror/app", KeepLast: 1, Schedule: "@hourly"}
+	fail := false
+	scheduler := &pruneScheduler{
+		server:  server,
+		syncCfg: &sync.Config{Prune: []sync.PruneRule{rule}},
+		prune: func(ctx context.Context, r sync.PruneRule) (*sync.PruneResult, error) {
+			if fail {
+				return nil, errors.New("registry unavailable")
+			}
+			return &sync.PruneResult{
+				Kept:    []sync.PruneDecision{{Tag: "v2"}},
+				Removed: []sync.PruneDecision{{Tag: "v1"}},
+			}, nil
+		},
+		state: state,
+	}
+	server.pruneScheduler = scheduler
+
+	prune := scheduler.recordRuns(rule.Repository)
+	_, err = prune(context.Background(), rule)
+	require.NoError(t, err)
+
+	rules := listRules(t, server)
+	require.Len(t, rules, 1)
+	assert.Equal(t, RuleHealthy, rules[0].Health)
+	require.NotNil(t, rules[0].LastRun)
+	assert.Equal(t, int64(1), rules[0].LastRun.Counters["tags_removed"])
+	require.NotNil(t, rules[0].NextRun)
+	assert.True(t, rules[0].NextRun.After(time.Now()))
+
+	fail = true
+	_, err = prune(context.Background(), rule)
+	require.Error(t, err)
+
+	rules = listRules(t, server)
+	assert.Equal(t, RuleFailing, rules[0].Health)
+	assert.Equal(t, 2, rules[0].Runs)
+	assert.Equal(t, "registry unavailable", rules[0].LastRun.Error)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, _ = prune(ctx, rule)
+	assert.Equal(t, 2, listRules(t, server)[0].Runs, "canceled runs are not recorded")
+}
+
+// listRules returns the rules GET /rules reports
+func listRules(t *testing.T, server *Server) []RuleSummary {
+	t.Helper()
+	w := httptest.NewRecorder()
+	server.listRulesHandler(w, httptest.NewRequest("GET", "/api/v1/rules", nil))
+	var response struct {
+		Rules []RuleSummary `json:"rules"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	return response.Rules
+}
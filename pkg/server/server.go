@@ -5,14 +5,15 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
-	"os"
-	"os/signal"
-	"syscall"
+	"path/filepath"
 
 	"freightliner/pkg/config"
+	"freightliner/pkg/diagnostics"
 	"freightliner/pkg/helper/log"
+	"freightliner/pkg/helper/shutdown"
 	"freightliner/pkg/replication"
 	"freightliner/pkg/service"
+	"freightliner/pkg/webhook"
 
 	"github.com/gorilla/mux"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -32,6 +33,10 @@ type Server struct {
 	checkpointSvc      *service.CheckpointService
 	jobManager         *JobManager
 	metricsRegistry    *MetricsRegistry
+	tenants            *tenantRegistry
+	pruneScheduler     *pruneScheduler
+	webhookSigner      *webhook.Signer
+	webhookVerifier    *webhook.Verifier
 }
 
 // NewServer creates a new server instance
@@ -60,6 +65,43 @@ func NewServer(ctx context.Context, cfg *config.Config,
 
 	// Create job manager
 	jobManager := NewJobManager()
+	if cfg.Server.IdempotencyTTL > 0 {
+		jobManager.SetIdempotencyTTL(cfg.Server.IdempotencyTTL)
+	}
+	if cfg.Checkpoint.Directory != "" {
+		keyFile := filepath.Join(config.ExpandHomeDir(cfg.Checkpoint.Directory), IdempotencyFile)
+		if err := jobManager.LoadIdempotencyKeys(keyFile); err != nil {
+			cancel()
+			return nil, err
+		}
+	}
+
+	// Build isolated services for each tenant
+	tenants, err := newTenantRegistry(cfg, logger)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	if tenants.enabled() {
+		logger.WithFields(map[string]interface{}{
+			"tenants": len(cfg.Tenants),
+		}).Info("Multi-tenant mode enabled")
+	}
+
+	// Load the keys that sign outgoing and verify incoming webhooks
+	webhookSigner, err := webhook.LoadSigner(cfg.Webhooks.Secret, cfg.Webhooks.SigningKeyFile)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	var webhookVerifier *webhook.Verifier
+	if cfg.Webhooks.RequireSignature {
+		webhookVerifier, err = webhook.LoadVerifier(cfg.Webhooks.Secret, cfg.Webhooks.VerifyKeyFile, cfg.Webhooks.MaxAge)
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+	}
 
 	// Create server
 	server := &Server{
@@ -74,6 +116,9 @@ func NewServer(ctx context.Context, cfg *config.Config,
 		checkpointSvc:      checkpointSvc,
 		jobManager:         jobManager,
 		metricsRegistry:    NewMetricsRegistry(),
+		tenants:            tenants,
+		webhookSigner:      webhookSigner,
+		webhookVerifier:    webhookVerifier,
 	}
 
 	// Build server address from host and port
@@ -87,6 +132,13 @@ func NewServer(ctx context.Context, cfg *config.Config,
 		WriteTimeout: cfg.Server.WriteTimeout,
 	}
 
+	// Load the prune rules run on a schedule
+	server.pruneScheduler, err = newPruneScheduler(server)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
 	// Register endpoints
 	server.registerEndpoints()
 
@@ -98,9 +150,21 @@ func (s *Server) Start() error {
 	// Start worker pool
 	s.workerPool.Start()
 
-	// Setup signal handling for graceful shutdown
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	// Start scheduled pruning
+	if s.pruneScheduler != nil {
+		s.pruneScheduler.start(s.ctx)
+	}
+
+	// Show pending and running jobs in diagnostics dumps
+	unregister := diagnostics.Register("server_jobs", func() interface{} {
+		return s.jobManager.ActiveJobs()
+	})
+	defer unregister()
+
+	// Setup signal handling for graceful shutdown; released once jobs have
+	// drained so a Windows service stop waits for them
+	sigChan, releaseSignals := shutdown.Notify()
+	defer releaseSignals()
 
 	// Get external URL for logging
 	externalURL := s.GetBaseURL()
@@ -156,8 +220,10 @@ func (s *Server) Start() error {
 		s.logger.Error("HTTP server shutdown error", err)
 	}
 
-	// Stop worker pool
-	s.workerPool.Stop()
+	// Let running jobs finish within the shutdown timeout
+	if err := s.workerPool.Drain(s.cfg.Server.ShutdownTimeout); err != nil {
+		s.logger.Error("Worker pool drain error", err)
+	}
 
 	s.logger.Info("Server shutdown complete")
 	return nil
@@ -179,19 +245,40 @@ func (s *Server) registerEndpoints() {
 		apiRouter.Use(s.corsMiddleware)
 	}
 
-	// Add API key authentication middleware if enabled
-	if s.cfg.Server.APIKeyAuth {
+	// Authenticate tenants when configured, otherwise the API key if enabled
+	if s.tenants.enabled() {
+		apiRouter.Use(s.tenantMiddleware)
+	} else if s.cfg.Server.APIKeyAuth {
 		apiRouter.Use(s.apiKeyMiddleware)
 	}
 
+	// Verify signed requests when signatures are required
+	if s.webhookVerifier != nil {
+		apiRouter.Use(s.signatureMiddleware)
+	}
+
 	// Register specific API endpoints
 	apiRouter.HandleFunc("/replicate", s.replicateHandler).Methods("POST")
 	apiRouter.HandleFunc("/replicate-tree", s.replicateTreeHandler).Methods("POST")
 	apiRouter.HandleFunc("/jobs", s.listJobsHandler).Methods("GET")
 	apiRouter.HandleFunc("/jobs/{id}", s.getJobHandler).Methods("GET")
+	apiRouter.HandleFunc("/jobs/{id}", s.cancelJobHandler).Methods("DELETE")
+	apiRouter.HandleFunc("/jobs/{id}/result", s.jobResultHandler).Methods("GET")
 	apiRouter.HandleFunc("/checkpoints", s.listCheckpointsHandler).Methods("GET")
 	apiRouter.HandleFunc("/checkpoints/{id}", s.getCheckpointHandler).Methods("GET")
 	apiRouter.HandleFunc("/checkpoints/{id}", s.deleteCheckpointHandler).Methods("DELETE")
+	apiRouter.HandleFunc("/capabilities", s.capabilitiesHandler).Methods("GET")
+	apiRouter.HandleFunc("/capabilities/probe", s.capabilitiesProbeHandler).Methods("GET")
+	apiRouter.HandleFunc("/rules", s.listRulesHandler).Methods("GET")
+	apiRouter.HandleFunc("/rules/resume", s.resumeRuleHandler).Methods("POST")
+	apiRouter.HandleFunc("/rules/{id:.+}/changes", s.ruleChangesHandler).Methods("GET")
+	apiRouter.HandleFunc("/cluster", s.clusterStatusHandler).Methods("GET")
+	apiRouter.HandleFunc("/webhooks/{name}", s.registryWebhookHandler).Methods("POST")
+
+	// Web dashboard, backed by the API above
+	if s.cfg.Server.Dashboard {
+		s.registerDashboard()
+	}
 }
 
 // healthCheckHandler handles health check requests
@@ -208,7 +295,7 @@ func (s *Server) apiKeyMiddleware(next http.Handler) http.Handler {
 		apiKey := r.Header.Get("X-API-Key")
 
 		// Validate API key
-		if apiKey != s.cfg.Server.APIKey {
+		if !s.operatorKeyMatches(apiKey) {
 			w.Header().Set("Content-Type", "application/json")
 			w.WriteHeader(http.StatusUnauthorized)
 			_, _ = w.Write([]byte(`{"error":"Invalid API key"}`))
@@ -268,32 +355,7 @@ func (s *Server) getServerAddr() string {
 
 // GetBaseURL returns the base URL for external access
 func (s *Server) GetBaseURL() string {
-	// Use external URL if configured
-	if s.cfg.Server.ExternalURL != "" {
-		return s.cfg.Server.ExternalURL
-	}
-
-	// Construct from host and port
-	protocol := "http"
-	if s.cfg.Server.TLSEnabled {
-		protocol = "https"
-	}
-
-	host := s.cfg.Server.Host
-	port := s.cfg.Server.Port
-
-	// Handle special cases
-	if host == "" || host == "0.0.0.0" || host == "::" {
-		// Binding to all interfaces - use localhost for URL
-		host = "localhost"
-	}
-
-	// Standard ports don't need to be in URL
-	if (protocol == "http" && port == 80) || (protocol == "https" && port == 443) {
-		return fmt.Sprintf("%s://%s", protocol, host)
-	}
-
-	return fmt.Sprintf("%s://%s:%d", protocol, host, port)
+	return s.cfg.Server.BaseURL()
 }
 
 // GetAPIBaseURL returns the full API base URL
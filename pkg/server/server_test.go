@@ -3,6 +3,7 @@ package server
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -414,6 +415,76 @@ func TestGetJobHandler(t *testing.T) {
 	}
 }
 
+// TestCancelJobHandler tests canceling jobs through DELETE /jobs/{id}
+func TestCancelJobHandler(t *testing.T) {
+	server := createTestServer(t)
+
+	svc := &blockingReplicationService{started: make(chan struct{})}
+	job := NewReplicateJob("ecr/repo", "gcr/repo", nil, false, false, svc)
+	server.jobManager.AddJob(job)
+	ctx, finish := server.jobManager.startJob(job.GetID())
+	go func() {
+		defer finish()
+		_ = job.Execute(ctx)
+	}()
+	<-svc.started
+
+	cancelJob := func(id string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("DELETE", "/api/v1/jobs/"+id, nil)
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := cancelJob(job.GetID())
+	require.Equal(t, http.StatusOK, w.Code)
+	var response map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "canceled", response["status"])
+	assert.Equal(t, true, response["drained"])
+	assert.Equal(t, JobStatusCanceled, job.GetStatus())
+
+	assert.Equal(t, http.StatusConflict, cancelJob(job.GetID()).Code)
+	assert.Equal(t, http.StatusNotFound, cancelJob("non-existent-id").Code)
+}
+
+// TestJobResultHandler tests fetching results through GET /jobs/{id}/result
+func TestJobResultHandler(t *testing.T) {
+	server := createTestServer(t)
+
+	getResult := func(id string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/v1/jobs/"+id+"/result", nil)
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, req)
+		return w
+	}
+
+	job := NewReplicateJob("ecr/repo", "gcr/repo", nil, false, false, &mockReplicationService{})
+	server.jobManager.AddJob(job)
+	assert.Equal(t, http.StatusConflict, getResult(job.GetID()).Code, "a pending job has no result yet")
+
+	require.NoError(t, job.Execute(context.Background()))
+	w := getResult(job.GetID())
+	require.Equal(t, http.StatusOK, w.Code)
+	var response map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, job.GetID(), response["job_id"])
+	assert.Equal(t, "completed", response["status"])
+	assert.Contains(t, response, "result")
+	assert.NotContains(t, response, "error")
+
+	failed := NewReplicateJob("ecr/repo", "gcr/repo", nil, false, false, &mockReplicationService{})
+	server.jobManager.AddJob(failed)
+	failed.SetStatus(JobStatusFailed)
+	failed.SetError(errors.New("manifest unknown"))
+	w = getResult(failed.GetID())
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "manifest unknown", response["error"])
+
+	assert.Equal(t, http.StatusNotFound, getResult("non-existent-id").Code)
+}
+
 // TestListCheckpointsHandler tests checkpoint listing
 func TestListCheckpointsHandler(t *testing.T) {
 	if testing.Short() {
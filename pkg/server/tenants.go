@@ -0,0 +1,235 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"freightliner/pkg/config"
+	"freightliner/pkg/helper/errors"
+	"freightliner/pkg/helper/log"
+	"freightliner/pkg/service"
+)
+
+// tenant is one team served by the server. Its services are built from a
+// configuration derived for the tenant alone, so jobs never see another
+// tenant's credentials or the server's own.
+type tenant struct {
+	cfg                *config.TenantConfig
+	replicationSvc     service.ReplicationService
+	treeReplicationSvc *service.TreeReplicationService
+	checkpointSvc      *service.CheckpointService
+}
+
+// tenantToken is the hash of one API token and the tenant owning it
+type tenantToken struct {
+	hash   [sha256.Size]byte
+	tenant *tenant
+}
+
+// tenantRegistry resolves API tokens to tenants
+type tenantRegistry struct {
+	byName map[string]*tenant
+	tokens []tenantToken
+}
+
+// newTenantRegistry builds isolated services for every configured tenant
+func newTenantRegistry(cfg *config.Config, logger log.Logger) (*tenantRegistry, error) {
+	registry := &tenantRegistry{
+		byName: make(map[string]*tenant, len(cfg.Tenants)),
+	}
+
+	for _, tc := range cfg.Tenants {
+		tenantCfg, err := cfg.ForTenant(tc.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		tenantLogger := logger.WithField("tenant", tc.Name)
+		t := &tenant{
+			cfg:                tenantCfg.Tenant,
+			replicationSvc:     service.NewReplicationService(tenantCfg, tenantLogger),
+			treeReplicationSvc: service.NewTreeReplicationService(tenantCfg, tenantLogger),
+			checkpointSvc:      service.NewCheckpointService(tenantCfg, tenantLogger),
+		}
+
+		registry.byName[tc.Name] = t
+		for _, token := range tc.APITokens {
+			registry.tokens = append(registry.tokens, tenantToken{
+				hash:   sha256.Sum256([]byte(token)),
+				tenant: t,
+			})
+		}
+	}
+
+	return registry, nil
+}
+
+// enabled reports whether any tenants are configured
+func (r *tenantRegistry) enabled() bool {
+	return r != nil && len(r.byName) > 0
+}
+
+// authenticate returns the tenant owning token. Every stored hash is
+// compared in constant time so lookups do not leak timing about stored tokens.
+func (r *tenantRegistry) authenticate(token string) (*tenant, bool) {
+	if r == nil || token == "" {
+		return nil, false
+	}
+	sum := sha256.Sum256([]byte(token))
+	var found *tenant
+	for _, tt := range r.tokens {
+		if subtle.ConstantTimeCompare(sum[:], tt.hash[:]) == 1 {
+			found = tt.tenant
+		}
+	}
+	return found, found != nil
+}
+
+// operatorKeyMatches reports whether token is the configured operator API
+// key, comparing in constant time.
+func (s *Server) operatorKeyMatches(token string) bool {
+	key := s.cfg.Server.APIKey
+	return key != "" && token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(key)) == 1
+}
+
+// name returns the tenant name, or "" for the server operator
+func (t *tenant) name() string {
+	if t == nil {
+		return ""
+	}
+	return t.cfg.Name
+}
+
+// admit checks a job's repositories against the tenant's namespaces
+func (t *tenant) admit(repos ...string) error {
+	for _, repo := range repos {
+		if !t.cfg.AllowsRepository(repo) {
+			return errors.Forbiddenf("repository %s is outside the namespaces of tenant %s", repo, t.cfg.Name)
+		}
+	}
+	return nil
+}
+
+type tenantContextKey struct{}
+
+// withTenant stores the authenticated tenant in ctx
+func withTenant(ctx context.Context, t *tenant) context.Context {
+	return context.WithValue(ctx, tenantContextKey{}, t)
+}
+
+// tenantFromContext returns the authenticated tenant, or nil for the operator
+func tenantFromContext(ctx context.Context) *tenant {
+	t, _ := ctx.Value(tenantContextKey{}).(*tenant)
+	return t
+}
+
+// requestToken reads the API token from X-API-Key or a bearer Authorization header
+func requestToken(r *http.Request) string {
+	if token := r.Header.Get("X-API-Key"); token != "" {
+		return token
+	}
+	return strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+}
+
+// tenantMiddleware authenticates every API request as a tenant or, when API
+// key auth is enabled, as the operator. Anonymous requests are rejected
+// because they would otherwise run with the server's own credentials.
+func (s *Server) tenantMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := requestToken(r)
+
+		if t, ok := s.tenants.authenticate(token); ok {
+			next.ServeHTTP(w, r.WithContext(withTenant(r.Context(), t)))
+			return
+		}
+
+		if s.cfg.Server.APIKeyAuth && s.operatorKeyMatches(token) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		s.logger.WithFields(map[string]interface{}{
+			"method":    r.Method,
+			"path":      r.URL.Path,
+			"remote_ip": s.getRealIP(r),
+		}).Warn("Unauthorized API request")
+		s.metricsRegistry.RecordAuthFailure("tenant_token")
+		s.writeErrorResponse(w, http.StatusUnauthorized, "Valid tenant token required")
+	})
+}
+
+// replicationServiceFor returns the replication service for the caller
+func (s *Server) replicationServiceFor(t *tenant) service.ReplicationService {
+	if t != nil {
+		return t.replicationSvc
+	}
+	return s.replicationSvc
+}
+
+// treeReplicationServiceFor returns the tree replication service for the caller
+func (s *Server) treeReplicationServiceFor(t *tenant) *service.TreeReplicationService {
+	if t != nil {
+		return t.treeReplicationSvc
+	}
+	return s.treeReplicationSvc
+}
+
+// checkpointServiceFor returns the checkpoint service for the caller
+func (s *Server) checkpointServiceFor(t *tenant) *service.CheckpointService {
+	if t != nil {
+		return t.checkpointSvc
+	}
+	return s.checkpointSvc
+}
+
+// lookupJob returns a job visible to the caller; tenants only see their own jobs
+func (s *Server) lookupJob(r *http.Request, id string) (Job, bool) {
+	job, exists := s.jobManager.GetJob(id)
+	if !exists {
+		return nil, false
+	}
+	if t := tenantFromContext(r.Context()); t != nil && job.GetTenant() != t.name() {
+		return nil, false
+	}
+	return job, true
+}
+
+// admitJob checks namespaces and quota, then registers the job. It writes the
+// error response and returns false when the job is refused.
+func (s *Server) admitJob(w http.ResponseWriter, t *tenant, job Job, repos ...string) bool {
+	err := s.addJob(t, job, repos...)
+	if err == nil {
+		return true
+	}
+
+	status := http.StatusTooManyRequests
+	if errors.Is(err, errors.ErrForbidden) {
+		status = http.StatusForbidden
+	}
+	s.writeErrorResponse(w, status, err.Error())
+	return false
+}
+
+// addJob checks namespaces and quota, then registers the job. Refusals wrap
+// errors.ErrForbidden for namespaces and are logged.
+func (s *Server) addJob(t *tenant, job Job, repos ...string) error {
+	if t == nil {
+		s.jobManager.AddJob(job)
+		return nil
+	}
+
+	err := t.admit(repos...)
+	if err == nil {
+		err = s.jobManager.AddJobWithinQuota(job, t.cfg.Quota)
+	}
+	if err != nil {
+		s.logger.WithFields(map[string]interface{}{
+			"tenant": t.name(),
+			"error":  err.Error(),
+		}).Warn("Refused tenant job")
+	}
+	return err
+}
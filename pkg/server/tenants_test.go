@@ -0,0 +1,145 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"freightliner/pkg/config"
+	"freightliner/pkg/helper/log"
+	"freightliner/pkg/service"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// createTenantTestServer creates a server with two tenants; team-a may run one job at a time
+func createTenantTestServer(t *testing.T) *Server {
+	cfg := config.NewDefaultConfig()
+	cfg.Server.APIKeyAuth = true
+	cfg.Server.APIKey = "operator-key"
+	cfg.Workers.ServeWorkers = 1
+	cfg.Checkpoint.Directory = t.TempDir()
+	cfg.Tenants = []config.TenantConfig{
+		{
+			Name:       "team-a",
+			APITokens:  []string{"token-a"},
+			Namespaces: []string{"team-a"},
+			Quota:      config.TenantQuota{MaxActiveJobs: 1},
+		},
+		{
+			Name:      "team-b",
+			APITokens: []string{"token-b"},
+		},
+	}
+	require.NoError(t, cfg.Validate())
+
+	logger := log.NewBasicLogger(log.ErrorLevel)
+	server, err := NewServer(context.Background(), cfg, logger, &mockReplicationService{},
+		service.NewTreeReplicationService(cfg, logger), service.NewCheckpointService(cfg, logger))
+	require.NoError(t, err)
+	return server
+}
+
+func tenantRequest(server *Server, method, path, token, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	server.router.ServeHTTP(w, req)
+	return w
+}
+
+func replicateBody(sourceRepo, destRepo string) string {
+	return `{"source_registry":"ecr","source_repo":"` + sourceRepo + `","dest_registry":"gcr","dest_repo":"` + destRepo + `"}`
+}
+
+func TestTenantAuthentication(t *testing.T) {
+	server := createTenantTestServer(t)
+
+	assert.Equal(t, http.StatusUnauthorized, tenantRequest(server, "GET", "/api/v1/jobs", "", "").Code)
+	assert.Equal(t, http.StatusUnauthorized, tenantRequest(server, "GET", "/api/v1/jobs", "wrong", "").Code)
+	assert.Equal(t, http.StatusOK, tenantRequest(server, "GET", "/api/v1/jobs", "token-a", "").Code)
+	assert.Equal(t, http.StatusOK, tenantRequest(server, "GET", "/api/v1/jobs", "operator-key", "").Code)
+}
+
+func TestTenantRegistryAuthenticate(t *testing.T) {
+	server := createTenantTestServer(t)
+
+	got, ok := server.tenants.authenticate("token-b")
+	require.True(t, ok)
+	assert.Equal(t, "team-b", got.name())
+
+	_, ok = server.tenants.authenticate("token-")
+	assert.False(t, ok)
+	assert.True(t, server.operatorKeyMatches("operator-key"))
+	assert.False(t, server.operatorKeyMatches("operator-ke"))
+	assert.False(t, server.operatorKeyMatches(""))
+}
+
+func TestTenantJobs(t *testing.T) {
+	server := createTenantTestServer(t)
+
+	// Repositories outside the tenant's namespaces are refused
+	w := tenantRequest(server, "POST", "/api/v1/replicate", "token-a", replicateBody("team-b/app", "team-a/app"))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = tenantRequest(server, "POST", "/api/v1/replicate", "token-a", replicateBody("team-a/app", "team-a/app"))
+	require.Equal(t, http.StatusAccepted, w.Code)
+	var accepted map[string]string
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &accepted))
+	jobID := accepted["job_id"]
+
+	job, ok := server.jobManager.GetJob(jobID)
+	require.True(t, ok)
+	assert.Equal(t, "team-a", job.GetTenant())
+	replicateJob, ok := job.(*ReplicateJob)
+	require.True(t, ok)
+	assert.Same(t, server.tenants.byName["team-a"].replicationSvc, replicateJob.svc)
+
+	// The second active job exceeds team-a's quota
+	w = tenantRequest(server, "POST", "/api/v1/replicate", "token-a", replicateBody("team-a/app", "team-a/app"))
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+
+	// Other tenants can neither see nor fetch the job; the operator sees everything
+	assert.Equal(t, http.StatusNotFound, tenantRequest(server, "GET", "/api/v1/jobs/"+jobID, "token-b", "").Code)
+	assert.Equal(t, http.StatusOK, tenantRequest(server, "GET", "/api/v1/jobs/"+jobID, "token-a", "").Code)
+	assert.Equal(t, http.StatusOK, tenantRequest(server, "GET", "/api/v1/jobs/"+jobID, "operator-key", "").Code)
+
+	var listed struct {
+		Count int `json:"count"`
+	}
+	require.NoError(t, json.Unmarshal(tenantRequest(server, "GET", "/api/v1/jobs", "token-b", "").Body.Bytes(), &listed))
+	assert.Equal(t, 0, listed.Count)
+	require.NoError(t, json.Unmarshal(tenantRequest(server, "GET", "/api/v1/jobs", "operator-key", "").Body.Bytes(), &listed))
+	assert.Equal(t, 1, listed.Count)
+}
+
+func TestJobManagerAddJobWithinQuota(t *testing.T) {
+	manager := NewJobManager()
+	quota := config.TenantQuota{MaxJobsPerHour: 2}
+
+	newJob := func(tenant string) *ReplicateJob {
+		job := NewReplicateJob("ecr/app", "gcr/app", nil, false, false, nil)
+		job.Tenant = tenant
+		return job
+	}
+
+	first := newJob("team-a")
+	first.SetStatus(JobStatusCompleted)
+	require.NoError(t, manager.AddJobWithinQuota(first, quota))
+	require.NoError(t, manager.AddJobWithinQuota(newJob("team-b"), quota))
+	require.NoError(t, manager.AddJobWithinQuota(newJob("team-a"), quota))
+	assert.Error(t, manager.AddJobWithinQuota(newJob("team-a"), quota))
+
+	// Jobs older than an hour no longer count
+	first.StartTime = time.Now().Add(-2 * time.Hour)
+	assert.NoError(t, manager.AddJobWithinQuota(newJob("team-a"), quota))
+	assert.Equal(t, 4, manager.GetJobCount())
+}
@@ -15,6 +15,10 @@ type ReplicateRequest struct {
 	Tags           []string `json:"tags,omitempty"`
 	Force          bool     `json:"force"`
 	DryRun         bool     `json:"dry_run"`
+
+	// IdempotencyKey makes resubmissions return the original job; the
+	// Idempotency-Key header takes precedence
+	IdempotencyKey string `json:"idempotency_key,omitempty"`
 }
 
 // ReplicateTreeRequest represents a request to replicate a tree of repositories
@@ -31,6 +35,10 @@ type ReplicateTreeRequest struct {
 	EnableCheckpoint bool     `json:"enable_checkpoint"`
 	CheckpointDir    string   `json:"checkpoint_dir,omitempty"`
 	ResumeID         string   `json:"resume_id,omitempty"`
+
+	// IdempotencyKey makes resubmissions return the original job; the
+	// Idempotency-Key header takes precedence
+	IdempotencyKey string `json:"idempotency_key,omitempty"`
 }
 
 // JobResponse represents a job response
@@ -39,6 +47,28 @@ type JobResponse struct {
 	Status string `json:"status"`
 }
 
+// WebhookResponse lists what a registry push webhook started
+type WebhookResponse struct {
+	Trigger string        `json:"trigger"`
+	Jobs    []WebhookJob  `json:"jobs"`
+	Skipped []WebhookSkip `json:"skipped,omitempty"`
+}
+
+// WebhookJob is a replication started by a pushed tag
+type WebhookJob struct {
+	JobID       string `json:"job_id"`
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+	Tag         string `json:"tag"`
+}
+
+// WebhookSkip is a pushed tag that was not replicated
+type WebhookSkip struct {
+	Repository string `json:"repository"`
+	Tag        string `json:"tag"`
+	Reason     string `json:"reason"`
+}
+
 // ErrorResponse represents an error response
 type ErrorResponse struct {
 	Error string `json:"error"`
@@ -0,0 +1,147 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"time"
+
+	"freightliner/pkg/config"
+	"freightliner/pkg/webhook"
+
+	"github.com/gorilla/mux"
+)
+
+// maxWebhookPayload bounds the registry webhook bodies read
+const maxWebhookPayload = 1 << 20
+
+// signatureMiddleware rejects API requests other than GET and HEAD unless
+// they carry a valid webhook signature, so registries and other integrations
+// can only submit jobs with the shared secret or a trusted key
+func (s *Server) signatureMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodOptions {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if err := s.webhookVerifier.VerifyRequest(r); err != nil {
+			s.logger.WithFields(map[string]interface{}{
+				"method":    r.Method,
+				"path":      r.URL.Path,
+				"remote_ip": s.getRealIP(r),
+				"error":     err.Error(),
+			}).Warn("Rejected unsigned API request")
+			s.metricsRegistry.RecordAuthFailure("webhook_signature")
+			s.writeErrorResponse(w, http.StatusUnauthorized, "Valid request signature required")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// registryWebhookHandler starts a replication of each tag a registry push
+// webhook reports, as configured by the trigger named in the URL
+func (s *Server) registryWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	trigger, ok := s.webhookTrigger(name)
+	if !ok {
+		s.writeErrorResponse(w, http.StatusNotFound, fmt.Sprintf("Webhook trigger %s not found", name))
+		return
+	}
+
+	payload, err := io.ReadAll(io.LimitReader(r.Body, maxWebhookPayload))
+	if err != nil {
+		s.writeErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("Invalid request: %s", err))
+		return
+	}
+	events, err := webhook.ParsePushEvents(trigger.Format, payload)
+	if err != nil {
+		s.writeErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	t := tenantFromContext(r.Context())
+	response := WebhookResponse{Trigger: name, Jobs: []WebhookJob{}}
+	for _, event := range events {
+		skip := func(reason string) {
+			response.Skipped = append(response.Skipped, WebhookSkip{Repository: event.Repository, Tag: event.Tag, Reason: reason})
+		}
+		if !matchesTrigger(trigger, event.Repository) {
+			skip("repository not matched by trigger")
+			continue
+		}
+
+		// Rules frozen for maintenance refuse changes to their repositories
+		destRepo := trigger.DestPrefix + event.Repository
+		if s.pruneScheduler != nil {
+			if frozen := s.pruneScheduler.frozen(time.Now(), []string{destRepo}, nil); frozen != nil {
+				skip(frozen.Error())
+				continue
+			}
+		}
+
+		source := fmt.Sprintf("%s/%s", trigger.SourceRegistry, event.Repository)
+		destination := fmt.Sprintf("%s/%s", trigger.DestRegistry, destRepo)
+		job := NewReplicateJob(source, destination, []string{event.Tag}, false, false, s.replicationServiceFor(t))
+		job.Tenant = t.name()
+		if err := s.addJob(t, job, event.Repository, destRepo); err != nil {
+			skip(err.Error())
+			continue
+		}
+		if err := s.submitJob(job); err != nil {
+			job.SetStatus(JobStatusFailed)
+			job.SetError(fmt.Errorf("failed to submit job: %w", err))
+			skip("failed to submit job")
+			continue
+		}
+
+		s.logger.WithFields(map[string]interface{}{
+			"trigger":     name,
+			"job_id":      job.GetID(),
+			"source":      source,
+			"destination": destination,
+			"tag":         event.Tag,
+		}).Info("Registry push triggered replication")
+		response.Jobs = append(response.Jobs, WebhookJob{
+			JobID:       job.GetID(),
+			Source:      source,
+			Destination: destination,
+			Tag:         event.Tag,
+		})
+	}
+
+	// Registries retry deliveries that fail, so a push that starts nothing
+	// is still acknowledged
+	status := http.StatusOK
+	if len(response.Jobs) > 0 {
+		status = http.StatusAccepted
+	}
+	s.writeResponse(w, status, response)
+}
+
+// webhookTrigger returns the configured trigger called name
+func (s *Server) webhookTrigger(name string) (config.WebhookTrigger, bool) {
+	for _, trigger := range s.cfg.Webhooks.Triggers {
+		if trigger.Name == name {
+			return trigger, true
+		}
+	}
+	return config.WebhookTrigger{}, false
+}
+
+// matchesTrigger reports whether pushes to repository are replicated by
+// trigger
+func matchesTrigger(trigger config.WebhookTrigger, repository string) bool {
+	if len(trigger.Repositories) == 0 {
+		return true
+	}
+	for _, pattern := range trigger.Repositories {
+		if ok, _ := path.Match(pattern, repository); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,144 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"freightliner/pkg/config"
+	"freightliner/pkg/helper/log"
+	"freightliner/pkg/replication"
+	"freightliner/pkg/service"
+	"freightliner/pkg/webhook"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignatureMiddleware(t *testing.T) {
+	cfg := config.NewDefaultConfig()
+	cfg.Workers.ServeWorkers = 1
+	cfg.Checkpoint.Directory = t.TempDir()
+	cfg.Webhooks.Secret = "s3cret"
+	cfg.Webhooks.RequireSignature = true
+	require.NoError(t, cfg.Validate())
+
+	logger := log.NewBasicLogger(log.ErrorLevel)
+	server, err := NewServer(context.Background(), cfg, logger, &mockReplicationService{},
+		service.NewTreeReplicationService(cfg, logger), service.NewCheckpointService(cfg, logger))
+	require.NoError(t, err)
+
+	submit := func(signer *webhook.Signer) *httptest.ResponseRecorder {
+		body := []byte(replicateBody("app", "app"))
+		req := httptest.NewRequest("POST", "/api/v1/replicate", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		require.NoError(t, signer.Sign(req, body))
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, submit(nil).Code, "unsigned submissions are rejected")
+	assert.Equal(t, http.StatusUnauthorized, submit(webhook.NewSigner("other", nil)).Code)
+	assert.Equal(t, http.StatusAccepted, submit(webhook.NewSigner("s3cret", nil)).Code,
+		"the handler reads the body the signature was checked against")
+
+	w := httptest.NewRecorder()
+	server.router.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/jobs", nil))
+	assert.Equal(t, http.StatusOK, w.Code, "reads need no signature")
+}
+
+func TestPostPauseSigned(t *testing.T) {
+	verifier := webhook.NewVerifier("s3cret", nil, time.Minute)
+	received := make(chan error, 1)
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		received <- verifier.Verify(r, body)
+	}))
+	defer endpoint.Close()
+
+	pause := replication.RulePause{Rule: "mirror/app", Reason: "2 of 2 runs failed"}
+	require.NoError(t, postPause(context.Background(), endpoint.URL, webhook.NewSigner("s3cret", nil), pause))
+	assert.NoError(t, <-received)
+
+	require.NoError(t, postPause(context.Background(), endpoint.URL, nil, pause))
+	err := <-received
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "not signed")
+}
+
+// recordingReplicationService records the image replications it is asked for
+type recordingReplicationService struct {
+	mockReplicationService
+	requests chan *service.ReplicationRequest
+}
+
+func (r *recordingReplicationService) ReplicateImage(ctx context.Context, request *service.ReplicationRequest) (*service.ReplicationResult, error) {
+	r.requests <- request
+	return &service.ReplicationResult{Success: true}, nil
+}
+
+func TestRegistryWebhookHandler(t *testing.T) {
+	cfg := config.NewDefaultConfig()
+	cfg.Workers.ServeWorkers = 1
+	cfg.Checkpoint.Directory = t.TempDir()
+	cfg.Webhooks.Triggers = []config.WebhookTrigger{{
+		Name:           "harbor",
+		Format:         "registry",
+		SourceRegistry: "harbor",
+		DestRegistry:   "gcr",
+		Repositories:   []string{"library/*"},
+		DestPrefix:     "mirror/",
+	}}
+	require.NoError(t, cfg.Validate())
+
+	logger := log.NewBasicLogger(log.ErrorLevel)
+	svc := &recordingReplicationService{requests: make(chan *service.ReplicationRequest, 4)}
+	server, err := NewServer(context.Background(), cfg, logger, svc,
+		service.NewTreeReplicationService(cfg, logger), service.NewCheckpointService(cfg, logger))
+	require.NoError(t, err)
+	server.workerPool.Start()
+	defer server.workerPool.Stop()
+
+	deliver := func(trigger, payload string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/v1/webhooks/"+trigger, bytes.NewReader([]byte(payload)))
+		req.Header.Set("Content-Type", "application/vnd.docker.distribution.events.v1+json")
+		w := httptest.NewRecorder()
+		server.router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := deliver("harbor", `{"events":[
+		{"action":"push","target":{"repository":"library/nginx","digest":"sha256:abc","tag":"1.27"}},
+		{"action":"push","target":{"repository":"team/app","digest":"sha256:def","tag":"v1"}}
+	]}`)
+	require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+	var response WebhookResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	require.Len(t, response.Jobs, 1)
+	assert.Equal(t, "harbor/library/nginx", response.Jobs[0].Source)
+	assert.Equal(t, "gcr/mirror/library/nginx", response.Jobs[0].Destination)
+	assert.Equal(t, "1.27", response.Jobs[0].Tag)
+	assert.Equal(t, []WebhookSkip{{Repository: "team/app", Tag: "v1", Reason: "repository not matched by trigger"}}, response.Skipped)
+
+	select {
+	case request := <-svc.requests:
+		assert.Equal(t, "harbor", request.SourceRegistry)
+		assert.Equal(t, "library/nginx", request.SourceRepository)
+		assert.Equal(t, "mirror/library/nginx", request.DestinationRepository)
+		assert.Equal(t, []string{"1.27"}, request.SourceTags, "only the pushed tag is replicated")
+	case <-time.After(5 * time.Second):
+		t.Fatal("triggered job did not run")
+	}
+
+	w = deliver("harbor", `{"events":[{"action":"pull","target":{"repository":"library/nginx","tag":"1.27"}}]}`)
+	assert.Equal(t, http.StatusOK, w.Code, "a delivery without pushes is acknowledged")
+	assert.Equal(t, http.StatusBadRequest, deliver("harbor", "not json").Code)
+	assert.Equal(t, http.StatusNotFound, deliver("quay", `{"events":[]}`).Code)
+}
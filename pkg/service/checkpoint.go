@@ -5,6 +5,8 @@ import (
 	"encoding/json"
 	"os"
 	"path/filepath"
+	"slices"
+	"sort"
 	"strings"
 	"time"
 
@@ -25,6 +27,8 @@ type CheckpointService struct {
 type CheckpointInfo struct {
 	ID                    string           `json:"id"`
 	CreatedAt             time.Time        `json:"created_at"`
+	UpdatedAt             time.Time        `json:"updated_at"`
+	RunID                 string           `json:"run_id,omitempty"`
 	Source                string           `json:"source"`
 	Destination           string           `json:"destination"`
 	Status                string           `json:"status"`
@@ -129,6 +133,11 @@ func (s *CheckpointService) ListCheckpoints(ctx context.Context) ([]CheckpointIn
 		result = append(result, info)
 	}
 
+	// Newest first
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].CreatedAt.After(result[j].CreatedAt)
+	})
+
 	return result, nil
 }
 
@@ -184,6 +193,61 @@ func (s *CheckpointService) DeleteCheckpoint(ctx context.Context, id string) err
 	return nil
 }
 
+// PruneCheckpoints deletes the checkpoints not updated for olderThan, limited
+// to the given statuses when any are set. With dryRun nothing is deleted. It
+// returns the checkpoints pruned, or that would be.
+func (s *CheckpointService) PruneCheckpoints(ctx context.Context, olderThan time.Duration, statuses []string, dryRun bool) ([]CheckpointInfo, error) {
+	if olderThan <= 0 {
+		return nil, errors.InvalidInputf("prune age must be positive")
+	}
+	for _, status := range statuses {
+		if !isCheckpointStatus(status) {
+			return nil, errors.InvalidInputf("unknown checkpoint status %q", status)
+		}
+	}
+
+	checkpoints, err := s.ListCheckpoints(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	cutoff := time.Now().Add(-olderThan)
+	pruned := make([]CheckpointInfo, 0)
+	for _, cp := range checkpoints {
+		if !cp.UpdatedAt.Before(cutoff) {
+			continue
+		}
+		if len(statuses) > 0 && !slices.Contains(statuses, cp.Status) {
+			continue
+		}
+		if !dryRun {
+			if err := s.store.DeleteCheckpoint(cp.ID); err != nil {
+				return pruned, errors.Wrapf(err, "failed to delete checkpoint %s", cp.ID)
+			}
+		}
+		pruned = append(pruned, cp)
+	}
+
+	s.logger.WithFields(map[string]interface{}{
+		"older_than": olderThan.String(),
+		"statuses":   statuses,
+		"pruned":     len(pruned),
+		"dry_run":    dryRun,
+	}).Info("Pruned checkpoints")
+
+	return pruned, nil
+}
+
+// isCheckpointStatus reports whether status is a tree checkpoint status
+func isCheckpointStatus(status string) bool {
+	switch checkpoint.Status(status) {
+	case checkpoint.StatusPending, checkpoint.StatusInProgress, checkpoint.StatusCompleted,
+		checkpoint.StatusFailed, checkpoint.StatusInterrupted, checkpoint.StatusSkipped:
+		return true
+	}
+	return false
+}
+
 // ExportCheckpoint exports a checkpoint to a file
 func (s *CheckpointService) ExportCheckpoint(ctx context.Context, id string, filePath string) error {
 	if err := s.initStore(ctx); err != nil {
@@ -303,6 +367,10 @@ func (s *CheckpointService) convertCheckpointToInfo(cp *checkpoint.TreeCheckpoin
 		}
 	}
 
+	sort.Slice(repositories, func(i, j int) bool {
+		return repositories[i].Name < repositories[j].Name
+	})
+
 	// Calculate completed and failed repositories
 	completedRepos := len(cp.CompletedRepositories)
 	failedRepos := 0
@@ -316,6 +384,8 @@ func (s *CheckpointService) convertCheckpointToInfo(cp *checkpoint.TreeCheckpoin
 	return CheckpointInfo{
 		ID:                    cp.ID,
 		CreatedAt:             cp.StartTime,
+		UpdatedAt:             cp.LastUpdated,
+		RunID:                 cp.RunID,
 		Source:                cp.SourceRegistry + "/" + cp.SourcePrefix,
 		Destination:           cp.DestRegistry + "/" + cp.DestPrefix,
 		Status:                string(cp.Status),
@@ -360,6 +430,7 @@ func (s *CheckpointService) convertInfoToCheckpoint(info CheckpointInfo) *checkp
 		ID:                    info.ID,
 		StartTime:             info.CreatedAt,
 		LastUpdated:           time.Now(),
+		RunID:                 info.RunID,
 		SourceRegistry:        sourceRegistry,
 		SourcePrefix:          sourcePrefix,
 		DestRegistry:          destRegistry,
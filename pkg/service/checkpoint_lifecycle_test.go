@@ -135,6 +135,61 @@ func TestCheckpointServiceDeleteCheckpoint(t *testing.T) {
 	assert.Error(t, err)
 }
 
+// TestCheckpointServicePruneCheckpoints tests pruning stale checkpoints
+func TestCheckpointServicePruneCheckpoints(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &config.Config{
+		Checkpoint: config.CheckpointConfig{
+			Directory: dir,
+		},
+	}
+	logger := log.NewBasicLogger(log.ErrorLevel)
+	svc := NewCheckpointService(cfg, logger)
+	ctx := context.Background()
+
+	// SaveCheckpoint stamps the current time, so the files are written
+	// directly to backdate them
+	now := time.Now()
+	write := func(id string, status checkpoint.Status, age time.Duration) {
+		data, err := json.Marshal(&checkpoint.TreeCheckpoint{
+			ID:            id,
+			StartTime:     now.Add(-age),
+			LastUpdated:   now.Add(-age),
+			Status:        status,
+			SchemaVersion: checkpoint.CurrentSchemaVersion,
+		})
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(filepath.Join(dir, id+".json"), data, 0600))
+	}
+	write("old-completed", checkpoint.StatusCompleted, 72*time.Hour)
+	write("old-failed", checkpoint.StatusFailed, 48*time.Hour)
+	write("recent", checkpoint.StatusCompleted, time.Minute)
+
+	_, err := svc.PruneCheckpoints(ctx, 0, nil, false)
+	assert.Error(t, err)
+	_, err = svc.PruneCheckpoints(ctx, time.Hour, []string{"done"}, false)
+	assert.Error(t, err)
+
+	pruned, err := svc.PruneCheckpoints(ctx, 24*time.Hour, []string{"completed"}, true)
+	require.NoError(t, err)
+	require.Len(t, pruned, 1)
+	assert.Equal(t, "old-completed", pruned[0].ID)
+
+	listed, err := svc.ListCheckpoints(ctx)
+	require.NoError(t, err)
+	require.Len(t, listed, 3, "dry run deletes nothing")
+	assert.Equal(t, "recent", listed[0].ID, "newest first")
+
+	pruned, err = svc.PruneCheckpoints(ctx, 24*time.Hour, nil, false)
+	require.NoError(t, err)
+	assert.Len(t, pruned, 2)
+
+	listed, err = svc.ListCheckpoints(ctx)
+	require.NoError(t, err)
+	require.Len(t, listed, 1)
+	assert.Equal(t, "recent", listed[0].ID)
+}
+
 // TestCheckpointServiceExportImport tests exporting and importing checkpoints
 func TestCheckpointServiceExportImport(t *testing.T) {
 	if testing.Short() {
@@ -0,0 +1,95 @@
+package service
+
+import (
+	"context"
+
+	"freightliner/pkg/client/ecr"
+	freightlinerConfig "freightliner/pkg/config"
+)
+
+// nativeReplicationCovers reports whether native ECR replication configured on the
+// source registry already copies sourceRepo to the destination registry. Native
+// replication keeps repository names, so renamed copies are never covered.
+func (s *replicationService) nativeReplicationCovers(
+	ctx context.Context,
+	sourceClient RegistryClient,
+	destRegistry, sourceRepo, destRepo string,
+) bool {
+	if s.cfg.ECR.NativeReplication == freightlinerConfig.NativeReplicationIgnore || sourceRepo != destRepo {
+		return false
+	}
+
+	ecrClient, ok := sourceClient.(*ecr.Client)
+	if !ok {
+		return false
+	}
+
+	destAccount, destRegion, ok := ecr.ParseRegistryHost(destRegistry)
+	if !ok {
+		return false
+	}
+
+	// Same account and region is a plain same-registry copy, not replication
+	srcAccount, srcRegion, _ := ecr.ParseRegistryHost(ecrClient.GetRegistryName())
+	if destRegion == srcRegion && destAccount == srcAccount {
+		return false
+	}
+
+	covered, err := ecrClient.IsNativelyReplicated(ctx, sourceRepo, destRegion, destAccount)
+	if err != nil {
+		s.logger.WithFields(map[string]interface{}{
+			"repository": sourceRepo,
+			"error":      err.Error(),
+		}).Debug("Unable to read native ECR replication configuration")
+		return false
+	}
+
+	if covered {
+		s.logger.WithFields(map[string]interface{}{
+			"repository":         sourceRepo,
+			"destination_region": destRegion,
+			"destination_id":     destAccount,
+			"policy":             s.cfg.ECR.NativeReplication,
+		}).Warn("Repository is already covered by native ECR replication")
+	}
+
+	return covered
+}
+
+// pullThroughCacheCovers reports whether destRepo is an ECR pull through cache
+// of sourceRepo on sourceRegistry, so copying into it would store the images
+// twice under digests the cache may later disagree with
+func (s *replicationService) pullThroughCacheCovers(
+	ctx context.Context,
+	destClient RegistryClient,
+	sourceRegistry, sourceRepo, destRepo string,
+) bool {
+	if s.cfg.ECR.PullThroughCache == freightlinerConfig.PullThroughCacheIgnore {
+		return false
+	}
+
+	ecrClient, ok := destClient.(*ecr.Client)
+	if !ok {
+		return false
+	}
+
+	rule, err := ecrClient.PullThroughCacheFor(ctx, destRepo, sourceRegistry, sourceRepo)
+	if err != nil {
+		s.logger.WithFields(map[string]interface{}{
+			"repository": destRepo,
+			"error":      err.Error(),
+		}).Debug("Unable to read ECR pull through cache rules")
+		return false
+	}
+
+	if rule != nil {
+		s.logger.WithFields(map[string]interface{}{
+			"repository":        destRepo,
+			"upstream_registry": rule.UpstreamRegistryURL,
+			"ecr_prefix":        rule.EcrRepositoryPrefix,
+			"policy":            s.cfg.ECR.PullThroughCache,
+		}).Warn("Destination repository is an ECR pull through cache of the source")
+	}
+
+	return rule != nil
+}
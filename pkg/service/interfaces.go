@@ -4,7 +4,10 @@ import (
 	"context"
 	"time"
 
+	"freightliner/pkg/attestation"
+	"freightliner/pkg/copy"
 	"freightliner/pkg/interfaces"
+	"freightliner/pkg/resilience"
 )
 
 // Import types from the shared interfaces package for compatibility
@@ -30,13 +33,19 @@ type (
 	ContentManager   = interfaces.ContentManager
 
 	// Client interfaces
-	RepositoryLister          = interfaces.RepositoryLister
-	RepositoryProvider        = interfaces.RepositoryProvider
-	RegistryInfo              = interfaces.RegistryInfo
-	PaginatedRepositoryLister = interfaces.PaginatedRepositoryLister
-	CachingRepositoryProvider = interfaces.CachingRepositoryProvider
-	BatchRepositoryProvider   = interfaces.BatchRepositoryProvider
-	HealthChecker             = interfaces.HealthChecker
+	RepositoryCreator             = interfaces.RepositoryCreator
+	ConfigurableRepositoryCreator = interfaces.ConfigurableRepositoryCreator
+	RepositorySettings            = interfaces.RepositorySettings
+	RepositoryLister              = interfaces.RepositoryLister
+	RepositoryProvider            = interfaces.RepositoryProvider
+	RegistryInfo                  = interfaces.RegistryInfo
+	PaginatedRepositoryLister     = interfaces.PaginatedRepositoryLister
+	CachingRepositoryProvider     = interfaces.CachingRepositoryProvider
+	BatchRepositoryProvider       = interfaces.BatchRepositoryProvider
+	HealthChecker                 = interfaces.HealthChecker
+	ScanFindingsProvider          = interfaces.ScanFindingsProvider
+	RepositoryMetadataReader      = interfaces.RepositoryMetadataReader
+	RepositoryMetadataWriter      = interfaces.RepositoryMetadataWriter
 
 	// Auth interfaces
 	TokenProvider         = interfaces.TokenProvider
@@ -48,12 +57,6 @@ type (
 
 // ===== SERVICE-SPECIFIC INTERFACES =====
 
-// RepositoryCreator is an interface for client types that can create repositories
-type RepositoryCreator interface {
-	// CreateRepository creates a new repository with the given name and tags
-	CreateRepository(ctx context.Context, name string, tags map[string]string) (Repository, error)
-}
-
 // ReplicationService provides image replication capabilities
 type ReplicationService interface {
 	// ReplicateRepository replicates a repository from source to destination
@@ -69,6 +72,24 @@ type ReplicationService interface {
 	StreamReplication(ctx context.Context, requests <-chan *ReplicationRequest) (<-chan *ReplicationResult, <-chan error)
 }
 
+// RetryBudgetReporter is implemented by services that draw registry retries
+// from a run-wide budget, so callers can report how much of it was used
+type RetryBudgetReporter interface {
+	RetryBudget() *resilience.RetryBudget
+}
+
+// LedgerReporter is implemented by services that record pushed images for
+// the end-of-run attestation
+type LedgerReporter interface {
+	Ledger() *attestation.Ledger
+}
+
+// LayerReuseReporter is implemented by services that record how pushed
+// layers reached the destination for the layer reuse report
+type LayerReuseReporter interface {
+	LayerReuse() *copy.LayerReuse
+}
+
 // ReplicationRequest represents a replication request
 type ReplicationRequest struct {
 	SourceRegistry        string
@@ -103,6 +124,22 @@ type ReplicationResult struct {
 	LayersCopied int
 	StartTime    time.Time
 	EndTime      time.Time
+
+	// Skipped lists images that were deliberately not copied
+	Skipped []SkippedImage
+
+	// CheckpointID identifies the checkpoint a resumed run can continue
+	CheckpointID string
+
+	// TagsResumed counts the tags an earlier run of the checkpoint copied
+	TagsResumed int
+}
+
+// SkippedImage is an image a replication chose not to copy, and why
+type SkippedImage struct {
+	Source      string
+	Destination string
+	Reason      string
 }
 
 // ReplicationProgress represents replication progress
@@ -2,12 +2,15 @@ package service
 
 import (
 	"context"
+	"crypto"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"os"
 	"strings"
+	"sync"
 
+	"freightliner/pkg/attestation"
 	"freightliner/pkg/client"
 	freightlinerConfig "freightliner/pkg/config"
 	"freightliner/pkg/copy"
@@ -16,6 +19,7 @@ import (
 	"freightliner/pkg/helper/util"
 	"freightliner/pkg/helper/validation"
 	"freightliner/pkg/replication"
+	"freightliner/pkg/resilience"
 	"freightliner/pkg/secrets"
 	"freightliner/pkg/security/encryption"
 
@@ -26,6 +30,7 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
 	"github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
 	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
 	"google.golang.org/api/option"
 )
 
@@ -33,14 +38,171 @@ import (
 type replicationService struct {
 	cfg    *freightlinerConfig.Config
 	logger log.Logger
+
+	// confirmCreate asks whether to create a missing destination repository
+	// under the prompt policy (nil prompts on the terminal)
+	confirmCreate func(repo string) (bool, error)
+
+	// retryBudget limits registry retries across everything this service copies
+	retryBudget *resilience.RetryBudget
+
+	// ledger records pushed images for the run attestation (nil when disabled)
+	ledger *attestation.Ledger
+
+	// layerReuse records how pushed layers reached the destination, for the
+	// layer reuse report (nil when disabled)
+	layerReuse *copy.LayerReuse
+
+	// resigner signs images whose digest changes on copy (nil when disabled)
+	resigner  crypto.Signer
+	resignErr error
 }
 
 // NewReplicationService creates a new replication service
 func NewReplicationService(cfg *freightlinerConfig.Config, logger log.Logger) ReplicationService {
-	return &replicationService{
+	s := &replicationService{
 		cfg:    cfg,
 		logger: logger,
 	}
+	if cfg != nil {
+		s.retryBudget = resilience.NewRetryBudget(cfg.Retry.Budget)
+		if cfg.Attestation.Output != "" {
+			s.ledger = attestation.NewLedger()
+		}
+		if cfg.Reports.LayerReuseOutput != "" {
+			s.layerReuse = copy.NewLayerReuse()
+		}
+		if cfg.Resign.KeyFile != "" {
+			s.resigner, s.resignErr = encryption.NewSigner(context.Background(), cfg.Resign.KeyFile, encryption.SignerOptions{
+				AWS: encryption.AWSOpts{
+					Region:  cfg.ECR.Region,
+					Profile: cfg.ECR.Profile,
+					RoleARN: cfg.ECR.RoleARN,
+				},
+				GCPCredentialsFile: cfg.GCR.CredentialsFile,
+			})
+			if s.resignErr != nil {
+				s.resignErr = errors.Wrap(s.resignErr, "failed to load re-signing key")
+			}
+		}
+	}
+	return s
+}
+
+// Ledger returns the transfer ledger shared by the service's copies
+func (s *replicationService) Ledger() *attestation.Ledger {
+	return s.ledger
+}
+
+// LayerReuse returns the layer reuse collector shared by the service's copies
+func (s *replicationService) LayerReuse() *copy.LayerReuse {
+	return s.layerReuse
+}
+
+// RetryBudget returns the retry budget shared by the service's copies
+func (s *replicationService) RetryBudget() *resilience.RetryBudget {
+	return s.retryBudget
+}
+
+// newCopier creates a copier that draws registry retries from the service
+// budget and encrypts with encManager (nil when encryption is disabled)
+func (s *replicationService) newCopier(encManager *encryption.Manager) *copy.Copier {
+	return copy.NewCopier(s.logger, copy.CopierOptions{
+		EncryptionManager: encManager,
+		Ledger:            s.ledger,
+		LayerReuse:        s.layerReuse,
+		RetryBudget:       s.retryBudget,
+		MaxRetries:        s.cfg.Retry.MaxRetries,
+		Resigner:          s.resigner,
+		AnnotateRunID:     s.cfg.AnnotateRunID,
+	})
+}
+
+// attachScanFindings copies the source registry's scan summary for tag to the
+// image at destRef when enabled. Failures are logged and never fail the copy.
+func (s *replicationService) attachScanFindings(
+	ctx context.Context,
+	copier *copy.Copier,
+	sourceRepository Repository,
+	tag string,
+	destRef name.Reference,
+	destOpts []remote.Option,
+) {
+	if !s.cfg.Replicate.ScanFindings {
+		return
+	}
+
+	provider, ok := sourceRepository.(ScanFindingsProvider)
+	if !ok {
+		s.logger.WithFields(map[string]interface{}{
+			"repository": sourceRepository.GetName(),
+		}).Debug("Source registry does not provide scan findings")
+		return
+	}
+
+	fields := map[string]interface{}{
+		"repository": sourceRepository.GetName(),
+		"tag":        tag,
+	}
+
+	summary, err := provider.GetScanFindings(ctx, tag)
+	if err != nil {
+		fields["error"] = err.Error()
+		if errors.Is(err, errors.ErrNotFound) {
+			s.logger.WithFields(fields).Debug("Source image has no scan findings")
+		} else {
+			s.logger.WithFields(fields).Warn("Failed to read source scan findings")
+		}
+		return
+	}
+
+	referrer, err := copier.AttachScanFindings(ctx, destRef, summary, destOpts)
+	if err != nil {
+		fields["error"] = err.Error()
+		s.logger.WithFields(fields).Warn("Failed to attach scan findings to destination image")
+		return
+	}
+
+	fields["referrer"] = referrer.String()
+	fields["status"] = summary.Status
+	s.logger.WithFields(fields).Info("Attached scan findings to destination image")
+}
+
+// copyReferrers copies the signatures, attestations and other referrers of
+// the image at srcRef to destRef when enabled. Failures are logged and never
+// fail the copy.
+func (s *replicationService) copyReferrers(
+	ctx context.Context,
+	copier *copy.Copier,
+	srcRef, destRef name.Reference,
+	srcOpts, destOpts []remote.Option,
+) {
+	if !s.cfg.Replicate.Referrers {
+		return
+	}
+
+	fields := map[string]interface{}{
+		"source":      srcRef.String(),
+		"destination": destRef.String(),
+	}
+
+	result, err := copier.CopyReferrers(ctx, srcRef, destRef, srcOpts, destOpts, copy.ReferrersScheme(s.cfg.Replicate.ReferrersScheme))
+	if err != nil {
+		fields["error"] = err.Error()
+		s.logger.WithFields(fields).Warn("Failed to copy referrers to destination image")
+		return
+	}
+	if result.Referrers == 0 && result.Tags == 0 {
+		return
+	}
+
+	fields["referrers"] = result.Referrers
+	fields["cosign_tags"] = result.Tags
+	fields["converted"] = result.Converted
+	if result.ReferrersAPI != nil {
+		fields["referrers_api"] = *result.ReferrersAPI
+	}
+	s.logger.WithFields(fields).Info("Copied referrers to destination image")
 }
 
 // RepositoryReplicationOptions holds configuration for repository replication
@@ -61,12 +223,23 @@ type RepositoryReplicationOptions struct {
 
 	// Encryption settings
 	EnableEncryption bool
+
+	// Checkpoint records copied tags in CheckpointDir so an interrupted run
+	// can be resumed with ResumeID, a checkpoint ID or "latest"
+	Checkpoint    bool
+	CheckpointDir string
+	ResumeID      string
 }
 
 // ReplicateRepository replicates a repository from source to destination
 func (s *replicationService) ReplicateRepository(ctx context.Context, source, destination string) (*ReplicationResult, error) {
-	// Create options from configuration
-	options := RepositoryReplicationOptions{
+	return s.replicateRepository(ctx, s.repositoryOptions(source, destination))
+}
+
+// repositoryOptions returns the options from configuration for replicating
+// source to destination
+func (s *replicationService) repositoryOptions(source, destination string) RepositoryReplicationOptions {
+	return RepositoryReplicationOptions{
 		Source:           source,
 		Destination:      destination,
 		Tags:             s.cfg.Replicate.Tags,
@@ -74,6 +247,16 @@ func (s *replicationService) ReplicateRepository(ctx context.Context, source, de
 		ForceOverwrite:   s.cfg.Replicate.Force,
 		WorkerCount:      s.cfg.Workers.ReplicateWorkers,
 		EnableEncryption: s.cfg.Encryption.Enabled,
+		Checkpoint:       s.cfg.Replicate.Checkpoint,
+		CheckpointDir:    s.cfg.Replicate.CheckpointDir,
+		ResumeID:         s.cfg.Replicate.ResumeID,
+	}
+}
+
+// replicateRepository replicates the repository described by options
+func (s *replicationService) replicateRepository(ctx context.Context, options RepositoryReplicationOptions) (result *ReplicationResult, err error) {
+	if s.resignErr != nil {
+		return nil, s.resignErr
 	}
 
 	// Parse source and destination
@@ -87,6 +270,10 @@ func (s *replicationService) ReplicateRepository(ctx context.Context, source, de
 		return nil, err
 	}
 
+	if err := s.checkTenantNamespaces(sourceRepo, destRepo); err != nil {
+		return nil, err
+	}
+
 	// Validate registry types (now supports ALL Docker v2 registries)
 	if !s.isValidRegistryType(sourceRegistry) {
 		return nil, errors.InvalidInputf("invalid source registry '%s'. Registry cannot be empty", sourceRegistry)
@@ -101,6 +288,16 @@ func (s *replicationService) ReplicateRepository(ctx context.Context, source, de
 		return nil, err
 	}
 
+	// Repositories on the same registry are copied with server-side blob mounts,
+	// the copier detects this per image so here we only surface it to the user
+	if sourceRegistry == destRegistry {
+		s.logger.WithFields(map[string]interface{}{
+			"registry":               sourceRegistry,
+			"source_repository":      sourceRepo,
+			"destination_repository": destRepo,
+		}).Info("Source and destination share a registry, blobs will be mounted instead of transferred")
+	}
+
 	// Initialize credentials if using secrets manager
 	if initErr := s.initializeCredentials(ctx); initErr != nil {
 		return nil, initErr
@@ -108,6 +305,31 @@ func (s *replicationService) ReplicateRepository(ctx context.Context, source, de
 
 	// Get source repository
 	sourceClient := clients[sourceRegistry]
+
+	// Avoid duplicating transfers that native ECR replication already performs
+	if s.nativeReplicationCovers(ctx, sourceClient, destRegistry, sourceRepo, destRepo) &&
+		s.cfg.ECR.NativeReplication == freightlinerConfig.NativeReplicationSkip {
+		s.logger.WithFields(map[string]interface{}{
+			"source":      options.Source,
+			"destination": options.Destination,
+		}).Info("Skipping replication handled by native ECR replication")
+		return &ReplicationResult{
+			Success: true,
+		}, nil
+	}
+
+	// Avoid storing images a pull through cache already mirrors on demand
+	if s.pullThroughCacheCovers(ctx, clients[destRegistry], sourceRegistry, sourceRepo, destRepo) &&
+		s.cfg.ECR.PullThroughCache == freightlinerConfig.PullThroughCacheSkip {
+		s.logger.WithFields(map[string]interface{}{
+			"source":      options.Source,
+			"destination": options.Destination,
+		}).Info("Skipping replication into an ECR pull through cache")
+		return &ReplicationResult{
+			Success: true,
+		}, nil
+	}
+
 	sourceRepository, err := sourceClient.GetRepository(ctx, sourceRepo)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to get source repository")
@@ -115,26 +337,18 @@ func (s *replicationService) ReplicateRepository(ctx context.Context, source, de
 
 	// Get or create destination repository
 	destClient := clients[destRegistry]
-	destRepository, err := destClient.GetRepository(ctx, destRepo)
+	destRepository, err := s.ensureDestinationRepository(ctx, destClient, destRepo, sourceClient.GetRegistryName()+"/"+sourceRepo)
 	if err != nil {
-		s.logger.WithFields(map[string]interface{}{
-			"repository": destRepo,
-		}).Info("Destination repository does not exist, attempting to create")
-
-		// If we have a type-specific client with creation capability, use it
-		creator, ok := destClient.(RepositoryCreator)
-		if !ok {
-			return nil, errors.NotImplementedf("destination registry does not support repository creation")
-		}
+		return nil, err
+	}
+	s.copyRepositoryMetadata(ctx, sourceRepository, destRepository, options.DryRun)
 
-		destRepository, err = creator.CreateRepository(ctx, destRepo, map[string]string{
-			"CreatedBy": "Freightliner",
-			"Source":    sourceClient.GetRegistryName() + "/" + sourceRepo,
-		})
-		if err != nil {
-			return nil, errors.Wrap(err, "failed to create destination repository")
-		}
+	// Record copied tags so an interrupted run can resume from them
+	cp, err := s.openRepositoryCheckpoint(ctx, options, sourceClient.GetRegistryName(), sourceRepo, destClient.GetRegistryName(), destRepo)
+	if err != nil {
+		return nil, err
 	}
+	defer func() { cp.Finish(ctx, result, err) }()
 
 	// Setup encryption manager if encryption is enabled
 	encManager, err := s.setupEncryptionManager(ctx, destRegistry)
@@ -173,19 +387,32 @@ func (s *replicationService) ReplicateRepository(ctx context.Context, source, de
 	}
 
 	// Create copier
-	copier := copy.NewCopier(s.logger)
-
-	// Configure the copier if encryption is enabled
-	if encManager != nil {
-		copier = copier.WithEncryptionManager(encManager)
-	}
+	copier := s.newCopier(encManager)
+	mediaTypes := s.destinationMediaTypes(destRegistry)
 
 	// If specific tags were provided, copy them individually
 	if len(options.Tags) > 0 {
 		var copyErrors []string
+		var skipped []SkippedImage
 		tagsCopied := 0
+		tagsResumed := 0
+
+		srcOpts, err := sourceRepository.GetRemoteOptions()
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get source remote options")
+		}
+
+		destOpts, err := destRepository.GetRemoteOptions()
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get destination remote options")
+		}
 
 		for _, tagName := range options.Tags {
+			if !options.ForceOverwrite && cp.Completed(tagName) {
+				tagsResumed++
+				continue
+			}
+
 			// Parse source and destination references
 			srcRef, srcErr := name.NewTag(sourceRepository.GetName() + ":" + tagName)
 			if srcErr != nil {
@@ -205,11 +432,14 @@ func (s *replicationService) ReplicateRepository(ctx context.Context, source, de
 				Destination:    destRef,
 				ForceOverwrite: options.ForceOverwrite,
 				DryRun:         options.DryRun,
+				MediaTypes:     mediaTypes,
 			}
 
 			// Execute the copy
-			result, copyErr := copier.CopyImage(ctx, srcRef, destRef, nil, nil, copyOpts)
-			if copyErr != nil {
+			result, copyErr := copier.CopyImage(ctx, srcRef, destRef, srcOpts, destOpts, copyOpts)
+			if copy.IsMediaTypeSkip(copyErr) {
+				skipped = append(skipped, SkippedImage{Source: srcRef.String(), Destination: destRef.String(), Reason: copyErr.Error()})
+			} else if copyErr != nil {
 				errorMsg := fmt.Sprintf("failed to copy tag %s: %s", tagName, copyErr)
 
 				// If error contains "MANIFEST_UNKNOWN" or "not found", suggest available tags
@@ -230,6 +460,11 @@ func (s *replicationService) ReplicateRepository(ctx context.Context, source, de
 				copyErrors = append(copyErrors, errorMsg)
 			} else if result.Success {
 				tagsCopied++
+				cp.MarkCompleted(tagName)
+				if !options.DryRun {
+					s.attachScanFindings(ctx, copier, sourceRepository, tagName, destRef, destOpts)
+					s.copyReferrers(ctx, copier, srcRef, destRef, srcOpts, destOpts)
+				}
 			}
 		}
 
@@ -239,6 +474,8 @@ func (s *replicationService) ReplicateRepository(ctx context.Context, source, de
 				Error:        fmt.Errorf("errors occurred during replication: %s", strings.Join(copyErrors, "; ")),
 				BytesCopied:  0,
 				LayersCopied: tagsCopied,
+				Skipped:      skipped,
+				TagsResumed:  tagsResumed,
 			}, fmt.Errorf("errors occurred during replication: %s", strings.Join(copyErrors, "; "))
 		}
 
@@ -247,6 +484,8 @@ func (s *replicationService) ReplicateRepository(ctx context.Context, source, de
 			Error:        nil,
 			BytesCopied:  0,
 			LayersCopied: tagsCopied,
+			Skipped:      skipped,
+			TagsResumed:  tagsResumed,
 		}, nil
 	}
 
@@ -283,6 +522,27 @@ func (s *replicationService) ReplicateRepository(ctx context.Context, source, de
 
 	// Create a results collector for metrics
 	results := util.NewResults()
+	var skipped []SkippedImage
+	var skippedMu sync.Mutex
+
+	// Tags an earlier run of the checkpoint copied are not checked again
+	if !options.ForceOverwrite && len(sourceTags) > 0 {
+		remaining := make([]string, 0, len(sourceTags))
+		for _, tag := range sourceTags {
+			if !cp.Completed(tag) {
+				remaining = append(remaining, tag)
+			}
+		}
+		if resumed := len(sourceTags) - len(remaining); resumed > 0 {
+			s.logger.WithFields(map[string]interface{}{
+				"checkpoint_id": cp.ID(),
+				"resumed_tags":  resumed,
+				"remaining":     len(remaining),
+			}).Info("Skipping tags completed by the resumed run")
+		}
+		results.AddMetric("tagsResumed", int64(len(sourceTags)-len(remaining)))
+		sourceTags = remaining
+	}
 
 	// Create a limited error group with the worker count as concurrency limit
 	g := util.NewLimitedErrGroup(ctx, options.WorkerCount)
@@ -320,6 +580,7 @@ func (s *replicationService) ReplicateRepository(ctx context.Context, source, de
 					}).Warn("Error checking if tag should be skipped, will attempt to copy")
 				} else if skipTag {
 					results.AddMetric("tagsSkipped", 1)
+					cp.MarkCompleted(currentTag)
 					return nil
 				}
 			}
@@ -330,6 +591,7 @@ func (s *replicationService) ReplicateRepository(ctx context.Context, source, de
 				Destination:    destRef,
 				ForceOverwrite: options.ForceOverwrite,
 				DryRun:         options.DryRun,
+				MediaTypes:     mediaTypes,
 			}
 
 			// Get remote options
@@ -345,6 +607,13 @@ func (s *replicationService) ReplicateRepository(ctx context.Context, source, de
 
 			// Execute copy
 			result, err := copier.CopyImage(ctx, srcRef, destRef, srcOpts, destOpts, copyOpts)
+			if copy.IsMediaTypeSkip(err) {
+				results.AddMetric("tagsSkipped", 1)
+				skippedMu.Lock()
+				skipped = append(skipped, SkippedImage{Source: srcRef.String(), Destination: destRef.String(), Reason: err.Error()})
+				skippedMu.Unlock()
+				return nil
+			}
 			if err != nil {
 				s.logger.WithFields(map[string]interface{}{
 					"tag": currentTag,
@@ -352,14 +621,22 @@ func (s *replicationService) ReplicateRepository(ctx context.Context, source, de
 				return err
 			}
 
+			if !options.DryRun {
+				s.attachScanFindings(ctx, copier, sourceRepository, currentTag, destRef, destOpts)
+				s.copyReferrers(ctx, copier, srcRef, destRef, srcOpts, destOpts)
+			}
+
 			// Update stats
+			cp.MarkCompleted(currentTag)
 			results.AddMetric("tagsCopied", 1)
 			results.AddMetric("bytesTransferred", result.Stats.BytesTransferred)
+			results.AddMetric("blobsMounted", int64(result.Stats.BlobsMounted))
 
 			s.logger.WithFields(map[string]interface{}{
-				"tag":    currentTag,
-				"bytes":  result.Stats.BytesTransferred,
-				"layers": result.Stats.Layers,
+				"tag":           currentTag,
+				"bytes":         result.Stats.BytesTransferred,
+				"layers":        result.Stats.Layers,
+				"blobs_mounted": result.Stats.BlobsMounted,
 			}).Info("Tag copied successfully")
 
 			return nil
@@ -384,6 +661,7 @@ func (s *replicationService) ReplicateRepository(ctx context.Context, source, de
 	tagsSkipped := int(results.GetMetric("tagsSkipped"))
 	errorCount := int(results.GetMetric("errorCount"))
 	bytesTransferred := results.GetMetric("bytesTransferred")
+	blobsMounted := results.GetMetric("blobsMounted")
 
 	s.logger.WithFields(map[string]interface{}{
 		"source_repository":      sourceRepo,
@@ -392,6 +670,7 @@ func (s *replicationService) ReplicateRepository(ctx context.Context, source, de
 		"tags_skipped":           tagsSkipped,
 		"errors":                 errorCount,
 		"bytes_transferred":      bytesTransferred,
+		"blobs_mounted":          blobsMounted,
 	}).Info("Repository replication completed")
 
 	return &ReplicationResult{
@@ -399,6 +678,8 @@ func (s *replicationService) ReplicateRepository(ctx context.Context, source, de
 		Error:        nil,
 		BytesCopied:  bytesTransferred,
 		LayersCopied: tagsCopied,
+		Skipped:      skipped,
+		TagsResumed:  int(results.GetMetric("tagsResumed")),
 	}, nil
 }
 
@@ -408,8 +689,16 @@ func (s *replicationService) ReplicateImage(ctx context.Context, request *Replic
 	sourcePath := request.SourceRegistry + "/" + request.SourceRepository
 	destPath := request.DestinationRegistry + "/" + request.DestinationRepository
 
-	// Use the existing ReplicateRepository method
-	return s.ReplicateRepository(ctx, sourcePath, destPath)
+	// Requested tags and options narrow the configured ones
+	options := s.repositoryOptions(sourcePath, destPath)
+	if len(request.SourceTags) > 0 {
+		options.Tags = request.SourceTags
+	}
+	if request.Options != nil {
+		options.DryRun = options.DryRun || request.Options.DryRun
+		options.ForceOverwrite = options.ForceOverwrite || request.Options.ForceOverwrite
+	}
+	return s.replicateRepository(ctx, options)
 }
 
 // ReplicateImagesBatch replicates multiple images in a batch (interface implementation)
@@ -514,6 +803,7 @@ func (s *replicationService) shouldSkipTag(
 // 4. ecr/my-repo (shorthand for ECR)
 // 5. gcr/my-repo (shorthand for GCR)
 // 6. registry.company.com/repo/image:tag (any Docker v2 registry)
+// 7. prod-ecr/my-repo (a registry named in the registries config)
 //
 // Returns: registry, repository (without tag), error
 func parseRegistryPath(path string) (string, string, error) {
@@ -574,6 +864,17 @@ func (s *replicationService) isValidRegistryType(registry string) bool {
 	return true
 }
 
+// checkTenantNamespaces rejects repositories outside the namespaces of the
+// tenant this service was built for
+func (s *replicationService) checkTenantNamespaces(repos ...string) error {
+	for _, repo := range repos {
+		if !s.cfg.Tenant.AllowsRepository(repo) {
+			return errors.Forbiddenf("repository %s is outside the namespaces of tenant %s", repo, s.cfg.Tenant.Name)
+		}
+	}
+	return nil
+}
+
 // createRegistryClients creates registry clients for the specified registry types
 // Now supports ALL Docker v2 compatible registries via auto-detection
 func (s *replicationService) createRegistryClients(ctx context.Context, registries ...string) (map[string]RegistryClient, error) {
@@ -598,6 +899,24 @@ func (s *replicationService) createRegistryClients(ctx context.Context, registri
 	return registryClients, nil
 }
 
+// destinationMediaTypes returns the media type policy of a named destination
+// registry, or nil when it has none
+func (s *replicationService) destinationMediaTypes(registry string) *copy.MediaTypePolicy {
+	reg, ok := s.cfg.Registries.Lookup(registry)
+	if !ok || (len(reg.MediaTypes.Allow) == 0 && len(reg.MediaTypes.Deny) == 0) {
+		return nil
+	}
+	return &copy.MediaTypePolicy{Allow: reg.MediaTypes.Allow, Deny: reg.MediaTypes.Deny}
+}
+
+// registryKind returns the configured type of a named registry, or registry itself
+func (s *replicationService) registryKind(registry string) string {
+	if reg, ok := s.cfg.Registries.Lookup(registry); ok {
+		return strings.ToLower(string(reg.Type))
+	}
+	return registry
+}
+
 // setupEncryptionManager creates an encryption manager if encryption is enabled
 func (s *replicationService) setupEncryptionManager(ctx context.Context, destRegistry string) (*encryption.Manager, error) {
 	if !s.cfg.Encryption.Enabled {
@@ -616,7 +935,8 @@ func (s *replicationService) setupEncryptionManager(ctx context.Context, destReg
 	}
 
 	// Check which KMS provider to use based on provided key IDs and destination registry
-	if s.cfg.Encryption.AWSKMSKeyID != "" || destRegistry == "ecr" {
+	destKind := s.registryKind(destRegistry)
+	if s.cfg.Encryption.AWSKMSKeyID != "" || destKind == "ecr" {
 		// Configure for AWS KMS
 		encConfig.Provider = "aws-kms"
 		encConfig.KeyID = s.cfg.Encryption.AWSKMSKeyID
@@ -638,7 +958,7 @@ func (s *replicationService) setupEncryptionManager(ctx context.Context, destReg
 			"key_id": s.cfg.Encryption.AWSKMSKeyID,
 			"cmk":    s.cfg.Encryption.CustomerManagedKeys,
 		}).Info("AWS KMS encryption enabled")
-	} else if s.cfg.Encryption.GCPKMSKeyID != "" || destRegistry == "gcr" {
+	} else if s.cfg.Encryption.GCPKMSKeyID != "" || destKind == "gcr" {
 		// Configure for GCP KMS
 		encConfig.Provider = "gcp-kms"
 		encConfig.KeyID = s.cfg.Encryption.GCPKMSKeyID
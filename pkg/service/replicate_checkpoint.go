@@ -0,0 +1,245 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"freightliner/pkg/helper/errors"
+	"freightliner/pkg/helper/log"
+	"freightliner/pkg/helper/runid"
+	"freightliner/pkg/tree"
+	"freightliner/pkg/tree/checkpoint"
+
+	"github.com/google/uuid"
+)
+
+// repositoryCheckpoint records the tags a single-repository replication has
+// copied, so an interrupted run can resume without checking them against the
+// destination again. It is stored like a tree checkpoint with one repository,
+// which the checkpoint commands list, show and prune. A nil
+// repositoryCheckpoint records nothing.
+type repositoryCheckpoint struct {
+	logger   log.Logger
+	store    *checkpoint.AsyncStore
+	repo     string
+	readOnly bool // Dry runs skip the completed tags without recording any
+
+	mu         sync.Mutex
+	checkpoint *checkpoint.TreeCheckpoint
+	resumed    map[string]bool
+}
+
+// openRepositoryCheckpoint starts a checkpoint for replicating sourceRepo to
+// destRepo, or loads the one named by options.ResumeID. It returns nil when
+// neither checkpointing nor resuming was asked for. Resuming "latest" picks
+// the newest unfinished checkpoint of the same repositories, starting a new
+// one when there is none.
+func (s *replicationService) openRepositoryCheckpoint(
+	ctx context.Context,
+	options RepositoryReplicationOptions,
+	sourceRegistry, sourceRepo, destRegistry, destRepo string,
+) (*repositoryCheckpoint, error) {
+	if !options.Checkpoint && options.ResumeID == "" {
+		return nil, nil
+	}
+
+	store, err := tree.InitCheckpointStore(options.CheckpointDir)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open checkpoint directory")
+	}
+
+	id := options.ResumeID
+	if id == ResumeLatest {
+		id, err = latestRepositoryCheckpoint(store, sourceRegistry, sourceRepo, destRegistry, destRepo)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var cp *checkpoint.TreeCheckpoint
+	if id != "" {
+		cp, err = checkpoint.GetCheckpointByID(store, id)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to load checkpoint to resume")
+		}
+		if cp.SourceRegistry != sourceRegistry || cp.SourcePrefix != sourceRepo ||
+			cp.DestRegistry != destRegistry || cp.DestPrefix != destRepo {
+			return nil, errors.InvalidInputf("checkpoint %s replicates %s/%s to %s/%s, not this repository",
+				id, cp.SourceRegistry, cp.SourcePrefix, cp.DestRegistry, cp.DestPrefix)
+		}
+		if _, ok := cp.Repositories[sourceRepo]; !ok || len(cp.Repositories) != 1 {
+			return nil, errors.InvalidInputf("checkpoint %s is a tree replication checkpoint, resume it with replicate-tree", id)
+		}
+	} else {
+		now := time.Now()
+		cp = &checkpoint.TreeCheckpoint{
+			ID:             uuid.New().String(),
+			SourceRegistry: sourceRegistry,
+			SourcePrefix:   sourceRepo,
+			DestRegistry:   destRegistry,
+			DestPrefix:     destRepo,
+			StartTime:      now,
+			LastUpdated:    now,
+			Repositories: map[string]checkpoint.RepoStatus{
+				sourceRepo: {SourceRepo: sourceRepo, DestRepo: destRepo, LastUpdated: now},
+			},
+		}
+	}
+
+	rc := &repositoryCheckpoint{
+		logger:     s.logger,
+		repo:       sourceRepo,
+		readOnly:   options.DryRun,
+		checkpoint: cp,
+		resumed:    make(map[string]bool),
+	}
+	for _, tag := range cp.Repositories[sourceRepo].CompletedTags {
+		rc.resumed[tag] = true
+	}
+	rc.store = checkpoint.NewAsyncStore(store, checkpoint.AsyncStoreOptions{
+		OnWriteError: func(id string, err error) {
+			s.logger.WithFields(map[string]interface{}{
+				"checkpoint_id": id,
+				"error":         err.Error(),
+			}).Warn("Failed to write checkpoint")
+		},
+	})
+
+	s.logger.WithFields(map[string]interface{}{
+		"checkpoint_id":  cp.ID,
+		"resumed":        id != "",
+		"completed_tags": len(rc.resumed),
+	}).Info("Checkpointing repository replication")
+
+	rc.update(func(repo *checkpoint.RepoStatus) {
+		if id := runid.FromContext(ctx); id != "" {
+			cp.RunID = id
+		}
+		cp.Status = checkpoint.StatusInProgress
+		cp.LastError = ""
+		repo.Status = checkpoint.StatusInProgress
+		repo.Error = ""
+	})
+	return rc, nil
+}
+
+// latestRepositoryCheckpoint returns the ID of the newest unfinished
+// checkpoint replicating sourceRepo to destRepo, or "" if there is none
+func latestRepositoryCheckpoint(store checkpoint.CheckpointStore, sourceRegistry, sourceRepo, destRegistry, destRepo string) (string, error) {
+	checkpoints, err := store.ListCheckpoints()
+	if err != nil {
+		return "", errors.Wrap(err, "failed to list checkpoints")
+	}
+
+	var id string
+	var updated time.Time
+	for _, cp := range checkpoints {
+		if cp.Status == checkpoint.StatusCompleted || cp.Status == checkpoint.StatusSkipped {
+			continue
+		}
+		if cp.SourceRegistry != sourceRegistry || cp.SourcePrefix != sourceRepo ||
+			cp.DestRegistry != destRegistry || cp.DestPrefix != destRepo {
+			continue
+		}
+		if _, ok := cp.Repositories[sourceRepo]; !ok || len(cp.Repositories) != 1 {
+			continue
+		}
+		if id == "" || cp.LastUpdated.After(updated) {
+			id, updated = cp.ID, cp.LastUpdated
+		}
+	}
+	return id, nil
+}
+
+// ID returns the checkpoint ID, or "" without a checkpoint
+func (c *repositoryCheckpoint) ID() string {
+	if c == nil {
+		return ""
+	}
+	return c.checkpoint.ID
+}
+
+// Completed reports whether an earlier run already copied tag
+func (c *repositoryCheckpoint) Completed(tag string) bool {
+	return c != nil && c.resumed[tag]
+}
+
+// MarkCompleted records that tag is in the destination
+func (c *repositoryCheckpoint) MarkCompleted(tag string) {
+	if c == nil || c.readOnly {
+		return
+	}
+	c.update(func(repo *checkpoint.RepoStatus) {
+		repo.CompletedTags = append(repo.CompletedTags, tag)
+	})
+}
+
+// Finish records how the replication ended and waits for the checkpoint to
+// be written. The result gets the checkpoint ID and the resumed tag count.
+func (c *repositoryCheckpoint) Finish(ctx context.Context, result *ReplicationResult, err error) {
+	if c == nil {
+		return
+	}
+	if result != nil {
+		result.CheckpointID = c.checkpoint.ID
+	}
+	if c.readOnly {
+		return
+	}
+
+	status := checkpoint.StatusCompleted
+	switch {
+	case ctx.Err() != nil:
+		status = checkpoint.StatusInterrupted
+	case err != nil:
+		status = checkpoint.StatusFailed
+	case result == nil || !result.Success:
+		status = checkpoint.StatusFailed
+		err = errors.New("some tags failed to replicate")
+		if result != nil && result.Error != nil {
+			err = result.Error
+		}
+	}
+
+	c.update(func(repo *checkpoint.RepoStatus) {
+		c.checkpoint.Status = status
+		repo.Status = status
+		if err != nil && status == checkpoint.StatusFailed {
+			c.checkpoint.LastError = err.Error()
+			repo.Error = err.Error()
+		}
+		if status == checkpoint.StatusCompleted {
+			c.checkpoint.Progress = 100
+			c.checkpoint.CompletedRepositories = []string{c.repo}
+		}
+	})
+	if flushErr := c.store.Flush(checkpoint.DefaultWriteTimeout); flushErr != nil {
+		c.logger.WithFields(map[string]interface{}{
+			"checkpoint_id": c.checkpoint.ID,
+			"error":         flushErr.Error(),
+		}).Warn("Failed to write final checkpoint")
+	}
+}
+
+// update changes the repository status with fn and saves the checkpoint
+func (c *repositoryCheckpoint) update(fn func(repo *checkpoint.RepoStatus)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	repo := c.checkpoint.Repositories[c.repo]
+	fn(&repo)
+	repo.LastUpdated = time.Now()
+	c.checkpoint.Repositories[c.repo] = repo
+	c.checkpoint.LastUpdated = repo.LastUpdated
+
+	if c.readOnly {
+		return
+	}
+	if err := c.store.SaveCheckpoint(c.checkpoint); err != nil {
+		c.logger.WithFields(map[string]interface{}{
+			"checkpoint_id": c.checkpoint.ID,
+			"error":         err.Error(),
+		}).Warn("Failed to save checkpoint")
+	}
+}
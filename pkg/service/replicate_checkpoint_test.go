@@ -0,0 +1,96 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"freightliner/pkg/config"
+	"freightliner/pkg/helper/log"
+	"freightliner/pkg/helper/runid"
+	"freightliner/pkg/tree"
+	"freightliner/pkg/tree/checkpoint"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepositoryCheckpointResume(t *testing.T) {
+	dir := t.TempDir()
+	svc := &replicationService{cfg: config.NewDefaultConfig(), logger: log.NewBasicLogger(log.ErrorLevel)}
+	ctx := runid.WithRunID(context.Background(), "run-1")
+	open := func(options RepositoryReplicationOptions) (*repositoryCheckpoint, error) {
+		options.CheckpointDir = dir
+		return svc.openRepositoryCheckpoint(ctx, options, "ecr", "prod/app", "gcr", "mirror/app")
+	}
+
+	cp, err := open(RepositoryReplicationOptions{})
+	require.NoError(t, err)
+	assert.Nil(t, cp, "no checkpoint unless asked for")
+
+	// An interrupted run records the tags it copied
+	cp, err = open(RepositoryReplicationOptions{Checkpoint: true})
+	require.NoError(t, err)
+	cp.MarkCompleted("v1")
+	cp.MarkCompleted("v2")
+	result := &ReplicationResult{Success: false}
+	cp.Finish(ctx, result, errors.New("copy failed"))
+	assert.Equal(t, cp.ID(), result.CheckpointID)
+
+	store, err := tree.InitCheckpointStore(dir)
+	require.NoError(t, err)
+	saved, err := store.LoadCheckpoint(cp.ID())
+	require.NoError(t, err)
+	assert.Equal(t, checkpoint.StatusFailed, saved.Status)
+	assert.Equal(t, "run-1", saved.RunID)
+	assert.Equal(t, []string{"v1", "v2"}, saved.Repositories["prod/app"].CompletedTags)
+
+	// Resuming the latest checkpoint skips them
+	resumed, err := open(RepositoryReplicationOptions{ResumeID: ResumeLatest})
+	require.NoError(t, err)
+	assert.Equal(t, cp.ID(), resumed.ID())
+	assert.True(t, resumed.Completed("v1"))
+	assert.False(t, resumed.Completed("v3"))
+	resumed.MarkCompleted("v3")
+	resumed.Finish(ctx, &ReplicationResult{Success: true}, nil)
+
+	saved, err = store.LoadCheckpoint(cp.ID())
+	require.NoError(t, err)
+	assert.Equal(t, checkpoint.StatusCompleted, saved.Status)
+	assert.Equal(t, []string{"v1", "v2", "v3"}, saved.Repositories["prod/app"].CompletedTags)
+
+	// A finished checkpoint is not resumed again
+	fresh, err := open(RepositoryReplicationOptions{ResumeID: ResumeLatest})
+	require.NoError(t, err)
+	assert.NotEqual(t, cp.ID(), fresh.ID())
+	assert.False(t, fresh.Completed("v1"))
+}
+
+func TestRepositoryCheckpointRejectsOtherRepositories(t *testing.T) {
+	dir := t.TempDir()
+	svc := &replicationService{cfg: config.NewDefaultConfig(), logger: log.NewBasicLogger(log.ErrorLevel)}
+
+	cp, err := svc.openRepositoryCheckpoint(context.Background(), RepositoryReplicationOptions{Checkpoint: true, CheckpointDir: dir}, "ecr", "prod/app", "gcr", "mirror/app")
+	require.NoError(t, err)
+	cp.Finish(context.Background(), nil, errors.New("interrupted"))
+
+	_, err = svc.openRepositoryCheckpoint(context.Background(), RepositoryReplicationOptions{ResumeID: cp.ID(), CheckpointDir: dir}, "ecr", "prod/api", "gcr", "mirror/api")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "not this repository")
+}
+
+func TestRepositoryCheckpointDryRunRecordsNothing(t *testing.T) {
+	dir := t.TempDir()
+	svc := &replicationService{cfg: config.NewDefaultConfig(), logger: log.NewBasicLogger(log.ErrorLevel)}
+
+	cp, err := svc.openRepositoryCheckpoint(context.Background(), RepositoryReplicationOptions{Checkpoint: true, DryRun: true, CheckpointDir: dir}, "ecr", "prod/app", "gcr", "mirror/app")
+	require.NoError(t, err)
+	cp.MarkCompleted("v1")
+	cp.Finish(context.Background(), &ReplicationResult{Success: true}, nil)
+
+	store, err := tree.InitCheckpointStore(dir)
+	require.NoError(t, err)
+	checkpoints, err := store.ListCheckpoints()
+	require.NoError(t, err)
+	assert.Empty(t, checkpoints)
+}
@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"freightliner/pkg/config"
+	"freightliner/pkg/helper/errors"
 	"freightliner/pkg/helper/log"
 
 	"github.com/stretchr/testify/assert"
@@ -307,6 +308,26 @@ func TestInitializeCredentialsDisabled(t *testing.T) {
 	assert.NoError(t, err) // Should be no-op when disabled
 }
 
+// TestReplicateRepositoryTenantNamespaces tests that tenant services refuse
+// repositories outside the tenant's namespaces before touching any registry
+func TestReplicateRepositoryTenantNamespaces(t *testing.T) {
+	cfg := &config.Config{
+		Tenant: &config.TenantConfig{Name: "team-a", Namespaces: []string{"team-a"}},
+	}
+	logger := log.NewBasicLogger(log.InfoLevel)
+	svc := NewReplicationService(cfg, logger)
+
+	_, err := svc.ReplicateRepository(context.Background(), "ecr/team-b/app", "gcr/team-a/app")
+	assert.True(t, errors.Is(err, errors.ErrForbidden))
+
+	_, err = svc.ReplicateRepository(context.Background(), "ecr/team-a/app", "gcr/mirror/app")
+	assert.True(t, errors.Is(err, errors.ErrForbidden))
+
+	treeSvc := NewTreeReplicationService(cfg, logger)
+	_, err = treeSvc.ReplicateTree(context.Background(), "ecr/shared", "gcr/team-a")
+	assert.True(t, errors.Is(err, errors.ErrForbidden))
+}
+
 // TestSetupEncryptionManagerDisabled tests when encryption is disabled
 func TestSetupEncryptionManagerDisabled(t *testing.T) {
 	cfg := &config.Config{
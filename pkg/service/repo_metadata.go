@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"freightliner/pkg/helper/readonly"
+	"freightliner/pkg/interfaces"
+)
+
+// CopyRepositoryMetadata copies the description and readme of source to dest.
+// It reports false without an error when either registry keeps no repository
+// metadata, the source has none, or dest already matches it.
+func CopyRepositoryMetadata(ctx context.Context, source, dest Repository) (bool, error) {
+	reader, ok := source.(RepositoryMetadataReader)
+	if !ok {
+		return false, nil
+	}
+	writer, ok := dest.(RepositoryMetadataWriter)
+	if !ok {
+		return false, nil
+	}
+
+	metadata, err := reader.GetRepositoryMetadata(ctx)
+	if err != nil {
+		return false, err
+	}
+	if metadata == nil || metadata.IsEmpty() {
+		return false, nil
+	}
+
+	// Avoid rewriting metadata on every run when the registry can report it
+	if destReader, ok := dest.(RepositoryMetadataReader); ok {
+		current, err := destReader.GetRepositoryMetadata(ctx)
+		if err == nil && current != nil && sameRepositoryMetadata(*current, *metadata) {
+			return false, nil
+		}
+	}
+
+	// Vendor metadata APIs bypass the registry transport guard
+	if readonly.Enabled() {
+		return false, readonly.Check(fmt.Sprintf("set metadata of repository %s", dest.GetName()))
+	}
+	if err := writer.SetRepositoryMetadata(ctx, *metadata); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// sameRepositoryMetadata compares metadata as a single-field registry stores
+// it, so a Docker Hub description copied to Quay is not rewritten each run
+func sameRepositoryMetadata(current, want interfaces.RepositoryMetadata) bool {
+	if current == want {
+		return true
+	}
+	stored := want.Readme
+	if stored == "" {
+		stored = want.Description
+	}
+	return current.Description == "" && current.Readme == stored
+}
+
+// copyRepositoryMetadata copies the source repository's description and
+// readme to the destination when enabled. Failures are logged and never fail
+// the replication.
+func (s *replicationService) copyRepositoryMetadata(ctx context.Context, sourceRepository, destRepository Repository, dryRun bool) {
+	if !s.cfg.Replicate.CopyRepoMetadata || dryRun {
+		return
+	}
+
+	fields := map[string]interface{}{
+		"source":      sourceRepository.GetName(),
+		"destination": destRepository.GetName(),
+	}
+
+	copied, err := CopyRepositoryMetadata(ctx, sourceRepository, destRepository)
+	if err != nil {
+		fields["error"] = err.Error()
+		s.logger.WithFields(fields).Warn("Failed to copy repository metadata")
+		return
+	}
+	if copied {
+		s.logger.WithFields(fields).Info("Copied repository metadata")
+	} else {
+		s.logger.WithFields(fields).Debug("No repository metadata to copy")
+	}
+}
@@ -0,0 +1,75 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"freightliner/pkg/interfaces"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// metadataRepository keeps repository metadata, optionally read-only
+type metadataRepository struct {
+	Repository
+	metadata interfaces.RepositoryMetadata
+	writes   int
+	err      error
+}
+
+func (m *metadataRepository) GetRepositoryMetadata(ctx context.Context) (*interfaces.RepositoryMetadata, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	metadata := m.metadata
+	return &metadata, nil
+}
+
+// writableMetadataRepository stores a single description, like Quay and Harbor
+type writableMetadataRepository struct {
+	metadataRepository
+}
+
+func (m *writableMetadataRepository) SetRepositoryMetadata(ctx context.Context, metadata interfaces.RepositoryMetadata) error {
+	m.writes++
+	m.metadata = interfaces.RepositoryMetadata{Readme: metadata.Readme}
+	if m.metadata.Readme == "" {
+		m.metadata.Readme = metadata.Description
+	}
+	return nil
+}
+
+func TestCopyRepositoryMetadata(t *testing.T) {
+	ctx := context.Background()
+	source := &metadataRepository{metadata: interfaces.RepositoryMetadata{Description: "Web server", Readme: "# Nginx"}}
+	dest := &writableMetadataRepository{}
+
+	copied, err := CopyRepositoryMetadata(ctx, source, dest)
+	require.NoError(t, err)
+	assert.True(t, copied)
+	assert.Equal(t, "# Nginx", dest.metadata.Readme)
+
+	// Unchanged metadata is not written again
+	copied, err = CopyRepositoryMetadata(ctx, source, dest)
+	require.NoError(t, err)
+	assert.False(t, copied)
+	assert.Equal(t, 1, dest.writes)
+
+	// A description alone is stored in the single field
+	source.metadata = interfaces.RepositoryMetadata{Description: "Web server"}
+	copied, err = CopyRepositoryMetadata(ctx, source, dest)
+	require.NoError(t, err)
+	assert.True(t, copied)
+	assert.Equal(t, "Web server", dest.metadata.Readme)
+
+	// Destinations without metadata support are left alone
+	copied, err = CopyRepositoryMetadata(ctx, source, &metadataRepository{})
+	require.NoError(t, err)
+	assert.False(t, copied)
+
+	source.err = fmt.Errorf("unauthorized")
+	_, err = CopyRepositoryMetadata(ctx, source, dest)
+	assert.Error(t, err)
+}
@@ -0,0 +1,143 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	freightlinerConfig "freightliner/pkg/config"
+	"freightliner/pkg/helper/errors"
+	"freightliner/pkg/helper/log"
+	"freightliner/pkg/interfaces"
+
+	"golang.org/x/term"
+)
+
+// RepositoryCreationPolicy decides whether and how missing destination
+// repositories are created
+type RepositoryCreationPolicy struct {
+	// CreateMissingRepos is the auto-creation policy: true, false or prompt
+	CreateMissingRepos string
+
+	// Template holds the settings applied to created repositories
+	Template freightlinerConfig.RepositoryTemplateConfig
+
+	// Confirm asks whether to create a repository under the prompt policy
+	// (default: ask on the terminal)
+	Confirm func(repo string) (bool, error)
+}
+
+// ensureDestinationRepository returns the destination repository, creating it
+// according to the configured auto-creation policy when it does not exist yet
+func (s *replicationService) ensureDestinationRepository(
+	ctx context.Context,
+	destClient RegistryClient,
+	destRepo, source string,
+) (interfaces.Repository, error) {
+	return EnsureRepository(ctx, s.logger, destClient, destRepo, source, RepositoryCreationPolicy{
+		CreateMissingRepos: s.cfg.Replicate.CreateMissingRepos,
+		Template:           s.cfg.Replicate.RepositoryTemplate,
+		Confirm:            s.confirmCreate,
+	})
+}
+
+// EnsureRepository returns destRepo from destClient, creating it as policy
+// allows when it does not exist yet. source is recorded on the created
+// repository.
+func EnsureRepository(
+	ctx context.Context,
+	logger log.Logger,
+	destClient RegistryClient,
+	destRepo, source string,
+	policy RepositoryCreationPolicy,
+) (interfaces.Repository, error) {
+	destRepository, err := destClient.GetRepository(ctx, destRepo)
+	if err == nil {
+		return destRepository, nil
+	}
+
+	switch policy.CreateMissingRepos {
+	case freightlinerConfig.CreateMissingReposFalse:
+		return nil, errors.NotFoundf("destination repository %s does not exist and --create-missing-repos=false", destRepo)
+	case freightlinerConfig.CreateMissingReposPrompt:
+		confirm := policy.Confirm
+		if confirm == nil {
+			confirm = promptCreateRepository
+		}
+		ok, promptErr := confirm(destRepo)
+		if promptErr != nil {
+			return nil, errors.Wrap(promptErr, "failed to confirm repository creation")
+		}
+		if !ok {
+			return nil, errors.NotFoundf("destination repository %s does not exist and creation was declined", destRepo)
+		}
+	}
+
+	logger.WithFields(map[string]interface{}{
+		"repository": destRepo,
+	}).Info("Destination repository does not exist, attempting to create")
+
+	settings := repositorySettings(policy.Template, source)
+	if !interfaces.SupportsRepositorySettings(destClient) && hasSettingsBeyondTags(settings) {
+		logger.WithFields(map[string]interface{}{
+			"repository": destRepo,
+			"registry":   destClient.GetRegistryName(),
+		}).Warn("Destination registry cannot apply repository template settings, only tags will be set")
+	}
+
+	destRepository, err = interfaces.CreateRepository(ctx, destClient, destRepo, settings)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create destination repository")
+	}
+
+	return destRepository, nil
+}
+
+// repositorySettings builds creation settings from the repository template,
+// always recording where the repository was replicated from
+func repositorySettings(template freightlinerConfig.RepositoryTemplateConfig, source string) interfaces.RepositorySettings {
+	tags := make(map[string]string, len(template.Tags)+2)
+	for k, v := range template.Tags {
+		tags[k] = v
+	}
+	tags["CreatedBy"] = "Freightliner"
+	tags["Source"] = source
+
+	return interfaces.RepositorySettings{
+		Tags:           tags,
+		ImmutableTags:  template.ImmutableTags,
+		ScanOnPush:     template.ScanOnPush,
+		EncryptionType: template.EncryptionType,
+		KMSKey:         template.KMSKey,
+	}
+}
+
+// hasSettingsBeyondTags reports whether settings need more than plain tag support
+func hasSettingsBeyondTags(settings interfaces.RepositorySettings) bool {
+	return settings.ImmutableTags || settings.ScanOnPush || settings.EncryptionType != ""
+}
+
+// promptMu keeps concurrent replication workers from prompting at once
+var promptMu sync.Mutex
+
+// promptCreateRepository asks on the terminal whether to create a missing repository
+func promptCreateRepository(repo string) (bool, error) {
+	promptMu.Lock()
+	defer promptMu.Unlock()
+
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return false, errors.InvalidInputf("cannot prompt to create repository %s: stdin is not a terminal", repo)
+	}
+
+	fmt.Printf("Destination repository %s does not exist. Create it? [y/N]: ", repo)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return false, err
+	}
+
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes", nil
+}
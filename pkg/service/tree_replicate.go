@@ -2,11 +2,14 @@ package service
 
 import (
 	"context"
+	"time"
 
+	"freightliner/pkg/attestation"
 	"freightliner/pkg/config"
 	"freightliner/pkg/copy"
 	"freightliner/pkg/helper/errors"
 	"freightliner/pkg/helper/log"
+	"freightliner/pkg/resilience"
 	"freightliner/pkg/tree"
 	"freightliner/pkg/tree/checkpoint"
 )
@@ -27,6 +30,31 @@ func NewTreeReplicationService(cfg *config.Config, logger log.Logger) *TreeRepli
 	}
 }
 
+// RetryBudget returns the retry budget shared by the tree replication's copies
+func (s *TreeReplicationService) RetryBudget() *resilience.RetryBudget {
+	if reporter, ok := s.replicationService.(RetryBudgetReporter); ok {
+		return reporter.RetryBudget()
+	}
+	return nil
+}
+
+// Ledger returns the transfer ledger shared by the tree replication's copies
+func (s *TreeReplicationService) Ledger() *attestation.Ledger {
+	if reporter, ok := s.replicationService.(LedgerReporter); ok {
+		return reporter.Ledger()
+	}
+	return nil
+}
+
+// LayerReuse returns the layer reuse collector shared by the tree
+// replication's copies
+func (s *TreeReplicationService) LayerReuse() *copy.LayerReuse {
+	if reporter, ok := s.replicationService.(LayerReuseReporter); ok {
+		return reporter.LayerReuse()
+	}
+	return nil
+}
+
 // TreeReplicationResult contains the results of a tree replication operation
 type TreeReplicationResult struct {
 	RepositoriesFound      int
@@ -37,6 +65,8 @@ type TreeReplicationResult struct {
 	TotalTagsSkipped       int
 	TotalErrors            int
 	TotalBytesTransferred  int64
+	BlobsDeduplicated      int64
+	BytesDeduplicated      int64
 	CheckpointID           string
 }
 
@@ -47,7 +77,9 @@ type TreeReplicationOptions struct {
 	Destination string
 
 	// Worker configuration
-	WorkerCount int
+	WorkerCount    int
+	TagWorkerCount int
+	MaxTransfers   int
 
 	// Filtering options
 	ExcludeRepos []string
@@ -68,6 +100,11 @@ type TreeReplicationOptions struct {
 	RetryFailed   bool
 }
 
+// ResumeLatest as the resume ID resumes the newest unfinished checkpoint of
+// the same source and destination, or starts a checkpointed run when there is
+// none, so a restarted run continues where the last one stopped
+const ResumeLatest = "latest"
+
 // ReplicateTree replicates a tree of repositories
 func (s *TreeReplicationService) ReplicateTree(ctx context.Context, source, destination string) (*TreeReplicationResult, error) {
 	// Create options struct with values from config
@@ -75,6 +112,8 @@ func (s *TreeReplicationService) ReplicateTree(ctx context.Context, source, dest
 		Source:           source,
 		Destination:      destination,
 		WorkerCount:      s.cfg.TreeReplicate.Workers,
+		TagWorkerCount:   s.cfg.TreeReplicate.TagWorkers,
+		MaxTransfers:     s.cfg.TreeReplicate.MaxTransfers,
 		ExcludeRepos:     s.cfg.TreeReplicate.ExcludeRepos,
 		ExcludeTags:      s.cfg.TreeReplicate.ExcludeTags,
 		IncludeTags:      s.cfg.TreeReplicate.IncludeTags,
@@ -87,45 +126,24 @@ func (s *TreeReplicationService) ReplicateTree(ctx context.Context, source, dest
 		RetryFailed:      s.cfg.TreeReplicate.RetryFailed,
 	}
 
-	// Parse source and destination
-	sourceRegistry, sourceRepo, err := parseRegistryPath(options.Source)
-	if err != nil {
-		return nil, err
-	}
-
-	destRegistry, destRepo, err := parseRegistryPath(options.Destination)
+	sourceClient, destClient, sourceRepo, destRepo, err := s.treeEndpoints(ctx, options.Source, options.Destination)
 	if err != nil {
 		return nil, err
 	}
 
-	// Create registry clients - need to access implementation methods
-	replicationSvc, ok := s.replicationService.(*replicationService)
-	if !ok {
-		return nil, errors.InvalidInputf("replication service must be concrete implementation for tree replication")
-	}
-
-	// Validate registry types (now supports ALL Docker v2 registries)
-	if !replicationSvc.isValidRegistryType(sourceRegistry) {
-		return nil, errors.InvalidInputf("invalid source registry '%s'. Registry cannot be empty", sourceRegistry)
-	}
-	if !replicationSvc.isValidRegistryType(destRegistry) {
-		return nil, errors.InvalidInputf("invalid destination registry '%s'. Registry cannot be empty", destRegistry)
-	}
-
-	clients, err := replicationSvc.createRegistryClients(ctx, sourceRegistry, destRegistry)
-	if err != nil {
-		return nil, err
-	}
-
-	// Initialize credentials if using secrets manager
-	if initErr := replicationSvc.initializeCredentials(ctx); initErr != nil {
-		return nil, initErr
+	if options.ResumeID == ResumeLatest {
+		options.EnableCheckpoint = true
+		options.ResumeID, err = latestCheckpoint(options.CheckpointDir,
+			sourceClient.GetRegistryName(), sourceRepo, destClient.GetRegistryName(), destRepo)
+		if err != nil {
+			return nil, err
+		}
+		s.logger.WithFields(map[string]interface{}{
+			"resumeID":      options.ResumeID,
+			"checkpointDir": options.CheckpointDir,
+		}).Info("Looked up latest checkpoint to resume")
 	}
 
-	// Get source and destination clients
-	sourceClient := clients[sourceRegistry]
-	destClient := clients[destRegistry]
-
 	// Auto-detect worker count if configured
 	if options.WorkerCount == 0 && s.cfg.Workers.AutoDetect {
 		options.WorkerCount = config.GetOptimalWorkerCount()
@@ -137,6 +155,8 @@ func (s *TreeReplicationService) ReplicateTree(ctx context.Context, source, dest
 	// Create options map for tree replicator (for backward compatibility)
 	optionsMap := map[string]interface{}{
 		"workers":          options.WorkerCount,
+		"tagWorkers":       options.TagWorkerCount,
+		"maxTransfers":     options.MaxTransfers,
 		"excludeRepos":     options.ExcludeRepos,
 		"excludeTags":      options.ExcludeTags,
 		"includeTags":      options.IncludeTags,
@@ -157,17 +177,33 @@ func (s *TreeReplicationService) ReplicateTree(ctx context.Context, source, dest
 
 	// Set up the replicate tree options
 	replicateOpts := tree.ReplicateTreeOptions{
-		SourceClient:              sourceClient,
-		DestClient:                destClient,
-		SourcePrefix:              sourceRepo,
-		DestPrefix:                destRepo,
-		ForceOverwrite:            options.Force,
-		ResumeFromCheckpoint:      options.ResumeID,
-		SkipCompletedRepositories: options.SkipCompleted,
+		SourceClient:   sourceClient,
+		DestClient:     destClient,
+		SourcePrefix:   sourceRepo,
+		DestPrefix:     destRepo,
+		ForceOverwrite: options.Force,
 	}
 
-	// Start replication with the options
-	result, err := replicator.ReplicateTree(ctx, replicateOpts)
+	// Start replication with the options, continuing the checkpoint's remaining
+	// repositories and tags when resuming
+	var result *tree.TreeReplicationResult
+	if options.ResumeID != "" {
+		s.logger.WithFields(map[string]interface{}{
+			"resumeID":      options.ResumeID,
+			"skipCompleted": options.SkipCompleted,
+			"retryFailed":   options.RetryFailed,
+			"checkpointDir": options.CheckpointDir,
+		}).Info("Resuming tree replication from checkpoint")
+
+		result, err = replicator.ResumeTreeReplication(ctx, sourceClient, destClient, tree.ResumeOptions{
+			CheckpointID:   options.ResumeID,
+			SkipCompleted:  options.SkipCompleted,
+			RetryFailed:    options.RetryFailed,
+			ForceOverwrite: options.Force,
+		})
+	} else {
+		result, err = replicator.ReplicateTree(ctx, replicateOpts)
+	}
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to replicate tree")
 	}
@@ -182,14 +218,132 @@ func (s *TreeReplicationService) ReplicateTree(ctx context.Context, source, dest
 		TotalTagsSkipped:       0, // Not provided in tree.TreeReplicationResult
 		TotalErrors:            0, // Not provided in tree.TreeReplicationResult
 		TotalBytesTransferred:  0, // Not provided in tree.TreeReplicationResult
+		BlobsDeduplicated:      result.BlobsDeduplicated,
+		BytesDeduplicated:      result.BytesDeduplicated,
 		CheckpointID:           result.CheckpointID,
 	}, nil
 }
 
+// latestCheckpoint returns the ID of the newest resumable checkpoint in dir
+// for the tree from sourcePrefix to destPrefix, or "" if there is none
+func latestCheckpoint(dir, sourceRegistry, sourcePrefix, destRegistry, destPrefix string) (string, error) {
+	store, err := tree.InitCheckpointStore(dir)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to open checkpoint directory")
+	}
+	resumable, err := tree.ListResumableCheckpoints(store)
+	if err != nil {
+		return "", err
+	}
+
+	var id string
+	var updated time.Time
+	for _, cp := range resumable {
+		if cp.SourceRegistry != sourceRegistry || cp.SourcePrefix != sourcePrefix ||
+			cp.DestRegistry != destRegistry || cp.DestPrefix != destPrefix {
+			continue
+		}
+		if id == "" || cp.LastUpdated.After(updated) {
+			id, updated = cp.ID, cp.LastUpdated
+		}
+	}
+	return id, nil
+}
+
+// DiffTree streams the tags that differ between a source tree and its
+// destination to emit, using the tree replication's workers and filters
+func (s *TreeReplicationService) DiffTree(ctx context.Context, source, destination string, includeUnchanged bool, emit func(tree.DiffEntry) error) (*tree.DiffSummary, error) {
+	sourceClient, destClient, sourceRepo, destRepo, err := s.treeEndpoints(ctx, source, destination)
+	if err != nil {
+		return nil, err
+	}
+
+	workers := s.cfg.TreeReplicate.Workers
+	if workers == 0 && s.cfg.Workers.AutoDetect {
+		workers = config.GetOptimalWorkerCount()
+	}
+	if workers <= 0 {
+		workers = DefaultTreeReplicatorCreationOptions().WorkerCount
+	}
+
+	// The diff never copies, so the replicator needs no copier
+	differ := tree.NewTreeReplicator(s.logger, nil, tree.TreeReplicatorOptions{
+		WorkerCount:         workers,
+		TagWorkerCount:      s.cfg.TreeReplicate.TagWorkers,
+		ExcludeRepositories: s.cfg.TreeReplicate.ExcludeRepos,
+		ExcludeTags:         s.cfg.TreeReplicate.ExcludeTags,
+		IncludeTags:         s.cfg.TreeReplicate.IncludeTags,
+		ExplainFilters:      s.cfg.ExplainFilters,
+		NameMapping:         treeNameMapping(s.cfg.TreeReplicate.NameMapping),
+	})
+
+	summary, err := differ.DiffTree(ctx, tree.DiffTreeOptions{
+		SourceClient:     sourceClient,
+		DestClient:       destClient,
+		SourcePrefix:     sourceRepo,
+		DestPrefix:       destRepo,
+		IncludeUnchanged: includeUnchanged,
+	}, emit)
+	if err != nil {
+		return summary, errors.Wrap(err, "failed to diff tree")
+	}
+	return summary, nil
+}
+
+// treeEndpoints creates the registry clients of a tree operation and returns
+// them with the source and destination repository prefixes
+func (s *TreeReplicationService) treeEndpoints(ctx context.Context, source, destination string) (RegistryClient, RegistryClient, string, string, error) {
+	// Parse source and destination
+	sourceRegistry, sourceRepo, err := parseRegistryPath(source)
+	if err != nil {
+		return nil, nil, "", "", err
+	}
+
+	destRegistry, destRepo, err := parseRegistryPath(destination)
+	if err != nil {
+		return nil, nil, "", "", err
+	}
+
+	// Create registry clients - need to access implementation methods
+	replicationSvc, ok := s.replicationService.(*replicationService)
+	if !ok {
+		return nil, nil, "", "", errors.InvalidInputf("replication service must be concrete implementation for tree replication")
+	}
+	if replicationSvc.resignErr != nil {
+		return nil, nil, "", "", replicationSvc.resignErr
+	}
+
+	if err := replicationSvc.checkTenantNamespaces(sourceRepo, destRepo); err != nil {
+		return nil, nil, "", "", err
+	}
+
+	// Validate registry types (now supports ALL Docker v2 registries)
+	if !replicationSvc.isValidRegistryType(sourceRegistry) {
+		return nil, nil, "", "", errors.InvalidInputf("invalid source registry '%s'. Registry cannot be empty", sourceRegistry)
+	}
+	if !replicationSvc.isValidRegistryType(destRegistry) {
+		return nil, nil, "", "", errors.InvalidInputf("invalid destination registry '%s'. Registry cannot be empty", destRegistry)
+	}
+
+	clients, err := replicationSvc.createRegistryClients(ctx, sourceRegistry, destRegistry)
+	if err != nil {
+		return nil, nil, "", "", err
+	}
+
+	// Initialize credentials if using secrets manager
+	if initErr := replicationSvc.initializeCredentials(ctx); initErr != nil {
+		return nil, nil, "", "", initErr
+	}
+
+	return clients[sourceRegistry], clients[destRegistry], sourceRepo, destRepo, nil
+}
+
 // TreeReplicatorCreationOptions holds all options for creating a tree replicator
 type TreeReplicatorCreationOptions struct {
 	// Worker configuration
-	WorkerCount int
+	WorkerCount    int
+	TagWorkerCount int
+	MaxTransfers   int
 
 	// Filtering options
 	ExcludeRepos []string
@@ -237,6 +391,14 @@ func (s *TreeReplicationService) createTreeReplicator(ctx context.Context, sourc
 		options.WorkerCount = workers
 	}
 
+	if tagWorkers, ok := opts["tagWorkers"].(int); ok && tagWorkers > 0 {
+		options.TagWorkerCount = tagWorkers
+	}
+
+	if maxTransfers, ok := opts["maxTransfers"].(int); ok && maxTransfers > 0 {
+		options.MaxTransfers = maxTransfers
+	}
+
 	if excludes, ok := opts["excludeRepos"].([]string); ok {
 		options.ExcludeRepos = excludes
 	}
@@ -290,70 +452,42 @@ func (s *TreeReplicationService) createTreeReplicator(ctx context.Context, sourc
 
 	// Set up tree replicator configuration
 	treeReplicatorOpts := tree.TreeReplicatorOptions{
-		WorkerCount:         options.WorkerCount,
-		ExcludeRepositories: options.ExcludeRepos,
-		ExcludeTags:         options.ExcludeTags,
-		IncludeTags:         options.IncludeTags,
-		EnableCheckpointing: options.EnableCheckpoint,
-		CheckpointDirectory: options.CheckpointDir,
-		DryRun:              options.DryRun,
+		WorkerCount:            options.WorkerCount,
+		TagWorkerCount:         options.TagWorkerCount,
+		MaxTransfers:           options.MaxTransfers,
+		ExcludeRepositories:    options.ExcludeRepos,
+		ExcludeTags:            options.ExcludeTags,
+		IncludeTags:            options.IncludeTags,
+		EnableCheckpointing:    options.EnableCheckpoint || options.ResumeID != "",
+		CheckpointDirectory:    options.CheckpointDir,
+		DryRun:                 options.DryRun,
+		ExplainFilters:         s.cfg.ExplainFilters,
+		CheckpointWriteTimeout: s.cfg.TreeReplicate.CheckpointWriteTimeout,
+		CheckpointSlowStore:    checkpoint.SlowStorePolicy(s.cfg.TreeReplicate.CheckpointSlowStore),
+		NameMapping:            treeNameMapping(s.cfg.TreeReplicate.NameMapping),
+	}
+
+	// Missing destination repositories are created as for replicate, but
+	// never during a dry run
+	if !options.DryRun {
+		treeReplicatorOpts.EnsureDestinationRepository = replicationSvc.ensureDestinationRepository
 	}
 
 	// Create copier instance for the tree replicator
-	copier := copy.NewCopier(s.logger).
-		WithEncryptionManager(encManager)
+	copier := replicationSvc.newCopier(encManager)
 
 	// Create the tree replicator
 	replicator := tree.NewTreeReplicator(s.logger, copier, treeReplicatorOpts)
 
-	// If resuming from a checkpoint, set up the resume operation
-	if options.ResumeID != "" {
-		s.logger.WithFields(map[string]interface{}{
-			"resumeID":      options.ResumeID,
-			"skipCompleted": options.SkipCompleted,
-			"retryFailed":   options.RetryFailed,
-			"checkpointDir": options.CheckpointDir,
-		}).Info("Setting up tree replication resume")
-
-		// Initialize the checkpoint store for resume
-		store, err := tree.InitCheckpointStore(options.CheckpointDir)
-		if err != nil {
-			return nil, errors.Wrap(err, "failed to initialize checkpoint store for resume")
-		}
-
-		// Load the checkpoint
-		cp, err := checkpoint.GetCheckpointByID(store, options.ResumeID)
-		if err != nil {
-			return nil, errors.Wrap(err, "failed to load checkpoint for resume")
-		}
-
-		// Note: We would set up resume options here if we had a method to use them
-		// Currently there is no SetupResume method available in the TreeReplicator
-
-		// Get repositories to process
-		repositories, err := checkpoint.GetRemainingRepositories(cp, checkpoint.ResumableOptions{
-			ID:            options.ResumeID,
-			SkipCompleted: options.SkipCompleted,
-			RetryFailed:   options.RetryFailed,
-			Force:         options.Force,
-		})
-		if err != nil {
-			return nil, errors.Wrap(err, "failed to get remaining repositories for resume")
-		}
+	return replicator, nil
+}
 
-		s.logger.WithFields(map[string]interface{}{
-			"repositories": len(repositories),
-		}).Info("Resume operation set up")
-
-		// For now, we don't have a method to set up a resume
-		// In a real implementation, you would use the checkpoint and resumeOpts to configure the replicator
-		// This is just a placeholder for now
-		if len(repositories) > 0 {
-			s.logger.WithFields(map[string]interface{}{
-				"count": len(repositories),
-			}).Info("Found repositories to resume")
-		}
+// treeNameMapping converts the configured name mapping for the tree replicator
+func treeNameMapping(cfg config.NameMappingConfig) tree.NameMapping {
+	return tree.NameMapping{
+		Strategy:  cfg.Strategy,
+		MaxDepth:  cfg.MaxDepth,
+		MaxLength: cfg.MaxLength,
+		Separator: cfg.Separator,
 	}
-
-	return replicator, nil
 }
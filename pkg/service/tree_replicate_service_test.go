@@ -3,11 +3,14 @@ package service
 import (
 	"context"
 	"testing"
+	"time"
 
 	"freightliner/pkg/config"
 	"freightliner/pkg/helper/log"
+	"freightliner/pkg/tree/checkpoint"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // TestTreeReplicationServiceCreation tests service creation
@@ -317,3 +320,28 @@ func TestTreeReplicationOptionsMapping(t *testing.T) {
 	assert.True(t, optionsMap["skipCompleted"].(bool))
 	assert.False(t, optionsMap["retryFailed"].(bool))
 }
+
+// TestLatestCheckpoint tests finding the checkpoint --resume latest continues
+func TestLatestCheckpoint(t *testing.T) {
+	dir := t.TempDir()
+
+	id, err := latestCheckpoint(dir, "ecr", "prod", "gcr", "backup")
+	require.NoError(t, err)
+	assert.Empty(t, id, "nothing to resume starts a new run")
+
+	store, err := checkpoint.NewFileStore(dir)
+	require.NoError(t, err)
+	for _, cp := range []*checkpoint.TreeCheckpoint{
+		{ID: "older", SourceRegistry: "ecr", SourcePrefix: "prod", DestRegistry: "gcr", DestPrefix: "backup", Status: checkpoint.StatusInterrupted},
+		{ID: "newer", SourceRegistry: "ecr", SourcePrefix: "prod", DestRegistry: "gcr", DestPrefix: "backup", Status: checkpoint.StatusInProgress},
+		{ID: "other-tree", SourceRegistry: "ecr", SourcePrefix: "dev", DestRegistry: "gcr", DestPrefix: "backup", Status: checkpoint.StatusInProgress},
+		{ID: "finished", SourceRegistry: "ecr", SourcePrefix: "prod", DestRegistry: "gcr", DestPrefix: "backup", Status: checkpoint.StatusCompleted},
+	} {
+		require.NoError(t, store.SaveCheckpoint(cp))
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	id, err = latestCheckpoint(dir, "ecr", "prod", "gcr", "backup")
+	require.NoError(t, err)
+	assert.Equal(t, "newer", id)
+}
@@ -0,0 +1,164 @@
+package storage
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/opencontainers/go-digest"
+)
+
+// RepositoryUsage is the space a repository, or a tree of repositories,
+// takes in the CAS
+type RepositoryUsage struct {
+	Repository string `json:"repository"`
+	Blobs      int    `json:"blobs"`
+	// LogicalBytes counts every store, as if nothing were deduplicated
+	LogicalBytes int64 `json:"logical_bytes"`
+	// StoredBytes counts each distinct blob once
+	StoredBytes int64 `json:"stored_bytes"`
+	// ExclusiveBytes is held by blobs no other repository references, the
+	// space freed by dropping the repository
+	ExclusiveBytes int64 `json:"exclusive_bytes"`
+	// DedupRatio is LogicalBytes / StoredBytes
+	DedupRatio float64 `json:"dedup_ratio"`
+}
+
+// repoUsage attributes stored blobs to the repositories they were stored for
+type repoUsage struct {
+	mu    sync.RWMutex
+	repos map[string]map[digest.Digest]int64 // repository → digest → stores
+	blobs map[digest.Digest]map[string]bool  // digest → repositories
+	sizes map[digest.Digest]int64
+}
+
+// newRepoUsage creates empty repository accounting
+func newRepoUsage() *repoUsage {
+	return &repoUsage{
+		repos: make(map[string]map[digest.Digest]int64),
+		blobs: make(map[digest.Digest]map[string]bool),
+		sizes: make(map[digest.Digest]int64),
+	}
+}
+
+// StoreForRepository stores data like Store and attributes it to repository
+func (cas *ContentAddressableStore) StoreForRepository(ctx context.Context, repository string, data []byte) (digest.Digest, error) {
+	d, err := cas.Store(ctx, data)
+	if err != nil {
+		return "", err
+	}
+	cas.usage.add(repository, d, int64(len(data)))
+	return d, nil
+}
+
+// RepositoryUsage returns the space each repository takes, the repositories
+// freeing the most space when dropped first
+func (cas *ContentAddressableStore) RepositoryUsage() []RepositoryUsage {
+	cas.usage.mu.RLock()
+	defer cas.usage.mu.RUnlock()
+
+	usage := make([]RepositoryUsage, 0, len(cas.usage.repos))
+	for repository := range cas.usage.repos {
+		usage = append(usage, cas.usage.summarize(repository, func(r string) bool { return r == repository }))
+	}
+
+	sort.Slice(usage, func(i, j int) bool {
+		if usage[i].ExclusiveBytes != usage[j].ExclusiveBytes {
+			return usage[i].ExclusiveBytes > usage[j].ExclusiveBytes
+		}
+		return usage[i].Repository < usage[j].Repository
+	})
+	return usage
+}
+
+// TreeUsage returns the space taken by the repositories under prefix, e.g.
+// "docker.io/library". Blobs shared only within the tree count as exclusive.
+func (cas *ContentAddressableStore) TreeUsage(prefix string) RepositoryUsage {
+	prefix = strings.TrimSuffix(prefix, "/")
+	inTree := func(repository string) bool {
+		return prefix == "" || repository == prefix || strings.HasPrefix(repository, prefix+"/")
+	}
+
+	cas.usage.mu.RLock()
+	defer cas.usage.mu.RUnlock()
+	return cas.usage.summarize(prefix, inTree)
+}
+
+// add records a store of d for repository
+func (u *repoUsage) add(repository string, d digest.Digest, size int64) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if u.repos[repository] == nil {
+		u.repos[repository] = make(map[digest.Digest]int64)
+	}
+	u.repos[repository][d]++
+
+	if u.blobs[d] == nil {
+		u.blobs[d] = make(map[string]bool)
+	}
+	u.blobs[d][repository] = true
+	u.sizes[d] = size
+}
+
+// remove forgets d once it is no longer stored
+func (u *repoUsage) remove(d digest.Digest) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	for repository := range u.blobs[d] {
+		delete(u.repos[repository], d)
+		if len(u.repos[repository]) == 0 {
+			delete(u.repos, repository)
+		}
+	}
+	delete(u.blobs, d)
+	delete(u.sizes, d)
+}
+
+// repositories returns the number of repositories with stored blobs
+func (u *repoUsage) repositories() int {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+	return len(u.repos)
+}
+
+// summarize adds up the blobs of the repositories matching member. The
+// caller holds u.mu.
+func (u *repoUsage) summarize(name string, member func(string) bool) RepositoryUsage {
+	usage := RepositoryUsage{Repository: name}
+	seen := make(map[digest.Digest]bool)
+
+	for repository, stores := range u.repos {
+		if !member(repository) {
+			continue
+		}
+		for d, count := range stores {
+			size := u.sizes[d]
+			usage.LogicalBytes += count * size
+			if seen[d] {
+				continue
+			}
+			seen[d] = true
+			usage.Blobs++
+			usage.StoredBytes += size
+
+			exclusive := true
+			for other := range u.blobs[d] {
+				if !member(other) {
+					exclusive = false
+					break
+				}
+			}
+			if exclusive {
+				usage.ExclusiveBytes += size
+			}
+		}
+	}
+
+	if usage.StoredBytes > 0 {
+		usage.DedupRatio = float64(usage.LogicalBytes) / float64(usage.StoredBytes)
+	}
+	return usage
+}
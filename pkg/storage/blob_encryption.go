@@ -0,0 +1,219 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"os"
+
+	"freightliner/pkg/helper/errors"
+	"freightliner/pkg/helper/log"
+
+	"github.com/opencontainers/go-digest"
+)
+
+const (
+	blobKeyFormat = "AES-256-GCM"
+	blobKeyLength = 32
+)
+
+// encryptedBlobMagic starts every encrypted blob file. It is followed by the
+// length of the blob key (4 bytes, big endian), the JSON blob key, then the
+// ciphertext, so key and ciphertext are always written together.
+var encryptedBlobMagic = []byte("FLENC1\n")
+
+// DataKeyProvider generates and unwraps data keys for envelope encryption.
+// Providers of the encryption manager, such as the AWS and GCP KMS ones,
+// implement it.
+type DataKeyProvider interface {
+	Name() string
+	// GenerateDataKey returns a plaintext key and the key wrapped by the KMS
+	GenerateDataKey(ctx context.Context, keyLength int) ([]byte, []byte, error)
+	// Decrypt unwraps a data key
+	Decrypt(ctx context.Context, ciphertext []byte) ([]byte, error)
+}
+
+// blobKey is the key wrapping metadata of an encrypted blob
+type blobKey struct {
+	Format       string        `json:"format"`
+	Provider     string        `json:"provider"`
+	EncryptedKey []byte        `json:"encrypted_key"`
+	Ciphertext   digest.Digest `json:"ciphertext_digest"`
+}
+
+// NewEncryptedFileBackend opens or creates a file backend in dir that
+// encrypts every blob it stores with its own data key from keys. The wrapped
+// key is kept in a header of the blob file. Plaintext never reaches the disk,
+// not even in the spool; blobs stored before encryption was enabled are still
+// read, once they match their digest.
+func NewEncryptedFileBackend(dir string, keys DataKeyProvider, logger log.Logger) (*FileBackend, error) {
+	if keys == nil {
+		return nil, errors.InvalidInputf("encrypted file backend needs a data key provider")
+	}
+
+	backend, err := NewFileBackend(dir, logger)
+	if err != nil {
+		return nil, err
+	}
+	backend.keys = keys
+	return backend, nil
+}
+
+// putEncrypted seals data with a new data key and stores the wrapped key and
+// the ciphertext at path as one file, committed with a single rename. Writers
+// racing on the same blob each commit a whole file, so the last one wins.
+func (b *FileBackend) putEncrypted(ctx context.Context, d digest.Digest, data []byte, path string) error {
+	if d.Algorithm().FromBytes(data) != d {
+		return errors.InvalidInputf("blob content does not match digest %s", d)
+	}
+
+	plainKey, wrappedKey, err := b.keys.GenerateDataKey(ctx, blobKeyLength)
+	if err != nil {
+		return errors.Wrap(err, "failed to generate data key")
+	}
+	gcm, err := newBlobCipher(plainKey)
+	if err != nil {
+		return err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return errors.Wrap(err, "failed to generate nonce")
+	}
+	// The digest is authenticated too, so a blob cannot be passed off as another
+	ciphertext := gcm.Seal(nonce, nonce, data, []byte(d))
+
+	key, err := json.Marshal(blobKey{
+		Format:       blobKeyFormat,
+		Provider:     b.keys.Name(),
+		EncryptedKey: wrappedKey,
+		Ciphertext:   digest.Canonical.FromBytes(ciphertext),
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to encode blob key")
+	}
+
+	file := make([]byte, 0, len(encryptedBlobMagic)+4+len(key)+len(ciphertext))
+	file = append(file, encryptedBlobMagic...)
+	file = binary.BigEndian.AppendUint32(file, uint32(len(key)))
+	file = append(file, key...)
+	file = append(file, ciphertext...)
+
+	_, err = b.spool.WriteBlob(ctx, bytes.NewReader(file), digest.Canonical.FromBytes(file), path)
+	return err
+}
+
+// splitEncryptedBlob returns the key and ciphertext of an encrypted blob
+// file, or a nil key for a plaintext blob
+func splitEncryptedBlob(data []byte) (*blobKey, []byte, error) {
+	if !bytes.HasPrefix(data, encryptedBlobMagic) {
+		return nil, data, nil
+	}
+	rest := data[len(encryptedBlobMagic):]
+	if len(rest) < 4 || uint64(binary.BigEndian.Uint32(rest)) > uint64(len(rest)-4) {
+		return nil, nil, errors.New("encrypted blob header is truncated")
+	}
+	keyLength := binary.BigEndian.Uint32(rest)
+	rest = rest[4:]
+
+	var key blobKey
+	if err := json.Unmarshal(rest[:keyLength], &key); err != nil {
+		return nil, nil, errors.Wrap(err, "failed to decode blob key")
+	}
+	if key.Format != blobKeyFormat {
+		return nil, nil, errors.NotSupportedf("unsupported blob encryption format %q", key.Format)
+	}
+	return &key, rest[keyLength:], nil
+}
+
+// openBlob returns the plaintext of the blob file data stored as d. A file
+// without an encryption header is plaintext; with a data key provider it is
+// returned only when it matches d, so a damaged encrypted blob is never
+// passed off as content.
+func (b *FileBackend) openBlob(ctx context.Context, d digest.Digest, data []byte) ([]byte, error) {
+	key, ciphertext, err := splitEncryptedBlob(data)
+	if err == nil && key == nil {
+		if b.keys != nil && d.Algorithm().FromBytes(data) != d {
+			return nil, errors.Newf("blob %s is corrupted", d)
+		}
+		return data, nil
+	}
+	if err == nil {
+		var plain []byte
+		if plain, err = b.openEncrypted(ctx, d, key, ciphertext); err == nil {
+			return plain, nil
+		}
+	}
+
+	// A plaintext blob may start like an encrypted one
+	if d.Algorithm().FromBytes(data) == d {
+		return data, nil
+	}
+	return nil, errors.Wrapf(err, "failed to read blob %s", d)
+}
+
+// isEncryptedBlob reports whether the blob file at path has an encryption
+// header
+func isEncryptedBlob(path string) (bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	header := make([]byte, len(encryptedBlobMagic))
+	if _, err := io.ReadFull(file, header); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return false, nil
+		}
+		return false, err
+	}
+	return bytes.Equal(header, encryptedBlobMagic), nil
+}
+
+// openEncrypted unwraps key and decrypts the blob d
+func (b *FileBackend) openEncrypted(ctx context.Context, d digest.Digest, key *blobKey, ciphertext []byte) ([]byte, error) {
+	if b.keys == nil {
+		return nil, errors.NotSupportedf("blob %s is encrypted but the backend has no data key provider", d)
+	}
+	if key.Ciphertext != "" && key.Ciphertext.Algorithm().FromBytes(ciphertext) != key.Ciphertext {
+		return nil, errors.Newf("encrypted blob %s is corrupted", d)
+	}
+
+	plainKey, err := b.keys.Decrypt(ctx, key.EncryptedKey)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to unwrap data key of blob %s", d)
+	}
+	gcm, err := newBlobCipher(plainKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < gcm.NonceSize() {
+		return nil, errors.Newf("encrypted blob %s is truncated", d)
+	}
+
+	nonce := ciphertext[:gcm.NonceSize()]
+	data, err := gcm.Open(nil, nonce, ciphertext[gcm.NonceSize():], []byte(d))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to decrypt blob %s", d)
+	}
+	return data, nil
+}
+
+// newBlobCipher creates the AES-GCM cipher for a data key
+func newBlobCipher(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create cipher")
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create GCM")
+	}
+	return gcm, nil
+}
@@ -3,6 +3,7 @@ package storage
 import (
 	"bytes"
 	"context"
+	_ "crypto/sha512" // registers sha384 and sha512 digests of blobs from other tooling
 	"fmt"
 	"io"
 	"sync"
@@ -25,6 +26,15 @@ type ContentAddressableStore struct {
 	metrics    *CASMetrics
 	gcInterval time.Duration
 	stopGC     chan struct{}
+	usage      *repoUsage
+	observer   BlobObserver
+}
+
+// BlobObserver is notified when blobs enter or leave the store, e.g. to
+// publish which cluster node holds them
+type BlobObserver interface {
+	BlobStored(ctx context.Context, d digest.Digest)
+	BlobRemoved(ctx context.Context, d digest.Digest)
 }
 
 // Blob represents a stored blob with metadata
@@ -99,6 +109,7 @@ func NewContentAddressableStore(config CASConfig) *ContentAddressableStore {
 		metrics:    &CASMetrics{},
 		gcInterval: config.GCInterval,
 		stopGC:     make(chan struct{}),
+		usage:      newRepoUsage(),
 	}
 
 	// Start garbage collection
@@ -116,16 +127,57 @@ func newBlobIndex() *BlobIndex {
 	}
 }
 
-// Store stores data with automatic deduplication
+// SetObserver registers observer to be notified of blobs stored in and
+// removed from the store; nil stops notifications
+func (cas *ContentAddressableStore) SetObserver(observer BlobObserver) {
+	cas.mu.Lock()
+	defer cas.mu.Unlock()
+	cas.observer = observer
+}
+
+// notifyStored tells the observer, if any, that d is now held
+func (cas *ContentAddressableStore) notifyStored(ctx context.Context, d digest.Digest) {
+	cas.mu.RLock()
+	observer := cas.observer
+	cas.mu.RUnlock()
+	if observer != nil {
+		observer.BlobStored(ctx, d)
+	}
+}
+
+// notifyRemoved tells the observer, if any, that digests are no longer held
+func (cas *ContentAddressableStore) notifyRemoved(ctx context.Context, digests ...digest.Digest) {
+	cas.mu.RLock()
+	observer := cas.observer
+	cas.mu.RUnlock()
+	if observer == nil {
+		return
+	}
+	for _, d := range digests {
+		observer.BlobRemoved(ctx, d)
+	}
+}
+
+// Store stores data with automatic deduplication, keyed by its sha256 digest
 func (cas *ContentAddressableStore) Store(ctx context.Context, data []byte) (digest.Digest, error) {
+	return cas.StoreAs(ctx, digest.Canonical, data)
+}
+
+// StoreAs stores data keyed by its digest with algorithm (sha256, sha384 or
+// sha512), for blobs whose producers address them by a non-sha256 digest
+func (cas *ContentAddressableStore) StoreAs(ctx context.Context, algorithm digest.Algorithm, data []byte) (digest.Digest, error) {
+	if !algorithm.Available() {
+		return "", errors.InvalidInputf("unsupported digest algorithm: %s", algorithm)
+	}
+
 	startTime := time.Now()
 	defer func() {
 		latency := time.Since(startTime).Microseconds()
 		cas.metrics.AvgPutLatency.Store(uint64(latency))
 	}()
 
-	// Calculate content hash (SHA256)
-	d := digest.SHA256.FromBytes(data)
+	// Calculate content hash
+	d := algorithm.FromBytes(data)
 
 	// Check if already exists (deduplication!)
 	if cas.Exists(ctx, d) {
@@ -186,6 +238,7 @@ func (cas *ContentAddressableStore) Store(ctx context.Context, data []byte) (dig
 		"size":   len(data),
 	}).Debug("Blob stored successfully")
 
+	cas.notifyStored(ctx, d)
 	return d, nil
 }
 
@@ -228,8 +281,8 @@ func (cas *ContentAddressableStore) Get(ctx context.Context, d digest.Digest) ([
 		return nil, errors.Wrap(err, "failed to retrieve blob from backend")
 	}
 
-	// Verify digest
-	if digest.SHA256.FromBytes(data) != d {
+	// Verify digest with its own algorithm
+	if d.Validate() != nil || d.Algorithm().FromBytes(data) != d {
 		return nil, errors.New("digest mismatch: data corruption detected")
 	}
 
@@ -259,6 +312,41 @@ func (cas *ContentAddressableStore) Get(ctx context.Context, d digest.Digest) ([
 	return data, nil
 }
 
+// GetRange returns length bytes of the blob d from offset, or the rest of
+// the blob for a negative length. Blobs not in memory are read with a range
+// read when the backend supports it, and are not cached.
+func (cas *ContentAddressableStore) GetRange(ctx context.Context, d digest.Digest, offset, length int64) ([]byte, error) {
+	if offset < 0 {
+		return nil, errors.InvalidInputf("invalid range offset %d", offset)
+	}
+
+	cas.mu.RLock()
+	blob, exists := cas.storage[d]
+	cas.mu.RUnlock()
+
+	if exists {
+		cas.metrics.CacheHits.Add(1)
+		blob.UpdateLastAccess()
+		return sliceRange(blob.Data, offset, length), nil
+	}
+
+	ranged, ok := cas.backend.(RangeReader)
+	if !ok {
+		data, err := cas.Get(ctx, d)
+		if err != nil {
+			return nil, err
+		}
+		return sliceRange(data, offset, length), nil
+	}
+
+	cas.metrics.CacheMisses.Add(1)
+	data, err := ranged.GetRange(ctx, d, offset, length)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to retrieve blob range from backend")
+	}
+	return data, nil
+}
+
 // GetReader returns a reader for the blob
 func (cas *ContentAddressableStore) GetReader(ctx context.Context, d digest.Digest) (io.ReadCloser, error) {
 	data, err := cas.Get(ctx, d)
@@ -290,13 +378,16 @@ func (cas *ContentAddressableStore) Exists(ctx context.Context, d digest.Digest)
 
 // Delete removes a blob (decrements ref count)
 func (cas *ContentAddressableStore) Delete(ctx context.Context, d digest.Digest) error {
+	removed := false
 	cas.mu.Lock()
 	blob, exists := cas.storage[d]
 	if exists {
 		refCount := blob.RefCount.Add(-1)
 		if refCount <= 0 {
+			removed = true
 			delete(cas.storage, d)
 			cas.index.Remove(d)
+			cas.usage.remove(d)
 			cas.metrics.BlobsDeleted.Add(1)
 
 			// Delete from backend
@@ -324,6 +415,9 @@ func (cas *ContentAddressableStore) Delete(ctx context.Context, d digest.Digest)
 	if !exists {
 		return errors.NotFoundf("blob not found: %s", d.String())
 	}
+	if removed {
+		cas.notifyRemoved(ctx, d)
+	}
 
 	return nil
 }
@@ -357,7 +451,7 @@ func (cas *ContentAddressableStore) GetStats() map[string]interface{} {
 		dedupRate = float64(cas.metrics.DedupHits.Load()) / float64(total) * 100
 	}
 
-	return map[string]interface{}{
+	stats := map[string]interface{}{
 		"blob_count":        blobCount,
 		"total_bytes":       cas.metrics.TotalBytes.Load(),
 		"dedup_saved_bytes": cas.metrics.DedupSavedBytes.Load(),
@@ -366,6 +460,10 @@ func (cas *ContentAddressableStore) GetStats() map[string]interface{} {
 		"avg_get_latency":   fmt.Sprintf("%dµs", cas.metrics.AvgGetLatency.Load()),
 		"avg_put_latency":   fmt.Sprintf("%dµs", cas.metrics.AvgPutLatency.Load()),
 	}
+
+	// Per-repository detail is available from RepositoryUsage
+	stats["repositories"] = cas.usage.repositories()
+	return stats
 }
 
 // getCacheHitRate calculates cache hit rate
@@ -400,25 +498,27 @@ func (cas *ContentAddressableStore) runGarbageCollection() {
 // garbageCollect removes unreferenced blobs
 func (cas *ContentAddressableStore) garbageCollect() {
 	cas.mu.Lock()
-	defer cas.mu.Unlock()
-
 	before := len(cas.storage)
-	collected := 0
+	var collected []digest.Digest
 
 	for d, blob := range cas.storage {
 		if blob.RefCount.Load() <= 0 {
 			delete(cas.storage, d)
 			cas.index.Remove(d)
-			collected++
+			cas.usage.remove(d)
+			collected = append(collected, d)
 		}
 	}
+	after := len(cas.storage)
+	cas.mu.Unlock()
 
-	if collected > 0 {
+	if len(collected) > 0 {
 		cas.logger.WithFields(map[string]interface{}{
 			"before":    before,
-			"after":     len(cas.storage),
-			"collected": collected,
+			"after":     after,
+			"collected": len(collected),
 		}).Info("Garbage collection completed")
+		cas.notifyRemoved(context.Background(), collected...)
 	}
 }
 
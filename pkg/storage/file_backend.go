@@ -0,0 +1,216 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"freightliner/pkg/helper/errors"
+	"freightliner/pkg/helper/log"
+
+	"github.com/opencontainers/go-digest"
+)
+
+// FileBackend is a StorageBackend keeping each blob in a file named by its
+// digest, dir/<algorithm>/<first two hex digits>/<hex>. Blobs are written
+// through a Spool in dir/tmp, so a crash never leaves a truncated blob.
+type FileBackend struct {
+	dir   string
+	spool *Spool
+	keys  DataKeyProvider // set when blobs are encrypted at rest
+}
+
+// NewFileBackend opens or creates a file backend in dir
+func NewFileBackend(dir string, logger log.Logger) (*FileBackend, error) {
+	spool, err := OpenSpool(filepath.Join(dir, "tmp"), logger)
+	if err != nil {
+		return nil, err
+	}
+	return &FileBackend{dir: dir, spool: spool}, nil
+}
+
+// Put stores data as the blob d unless it is already stored
+func (b *FileBackend) Put(ctx context.Context, d digest.Digest, data []byte) error {
+	path, err := b.path(d)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+
+	if b.keys != nil {
+		return b.putEncrypted(ctx, d, data, path)
+	}
+	_, err = b.spool.WriteBlob(ctx, bytes.NewReader(data), d, path)
+	return err
+}
+
+// Get returns the blob d
+func (b *FileBackend) Get(ctx context.Context, d digest.Digest) ([]byte, error) {
+	path, err := b.path(d)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, errors.NotFoundf("blob not found: %s", d)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read blob %s", d)
+	}
+
+	return b.openBlob(ctx, d, data)
+}
+
+// GetRange returns length bytes of the blob d from offset, or the rest of
+// the blob for a negative length. Encrypted blobs are decrypted whole, as are
+// all blobs when a data key provider is set, so their digest can be checked.
+func (b *FileBackend) GetRange(ctx context.Context, d digest.Digest, offset, length int64) ([]byte, error) {
+	if offset < 0 {
+		return nil, errors.InvalidInputf("invalid range offset %d", offset)
+	}
+	path, err := b.path(d)
+	if err != nil {
+		return nil, err
+	}
+
+	encrypted, err := isEncryptedBlob(path)
+	if os.IsNotExist(err) {
+		return nil, errors.NotFoundf("blob not found: %s", d)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read blob %s", d)
+	}
+	if encrypted || b.keys != nil {
+		data, err := b.Get(ctx, d)
+		if err != nil {
+			return nil, err
+		}
+		return sliceRange(data, offset, length), nil
+	}
+
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, errors.NotFoundf("blob not found: %s", d)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to open blob %s", d)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to stat blob %s", d)
+	}
+	if offset > info.Size() {
+		offset = info.Size()
+	}
+	if length < 0 || offset+length > info.Size() {
+		length = info.Size() - offset
+	}
+
+	data := make([]byte, length)
+	if _, err := file.ReadAt(data, offset); err != nil && err != io.EOF {
+		return nil, errors.Wrapf(err, "failed to read blob %s", d)
+	}
+	return data, nil
+}
+
+// Size returns the bytes the blob d takes on disk
+func (b *FileBackend) Size(d digest.Digest) (int64, error) {
+	path, err := b.path(d)
+	if err != nil {
+		return 0, err
+	}
+
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return 0, errors.NotFoundf("blob not found: %s", d)
+	}
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed to stat blob %s", d)
+	}
+	return info.Size(), nil
+}
+
+// Exists reports whether the blob d is stored
+func (b *FileBackend) Exists(ctx context.Context, d digest.Digest) bool {
+	path, err := b.path(d)
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(path)
+	return err == nil
+}
+
+// Delete removes the blob d
+func (b *FileBackend) Delete(ctx context.Context, d digest.Digest) error {
+	path, err := b.path(d)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(path); err != nil {
+		if os.IsNotExist(err) {
+			return errors.NotFoundf("blob not found: %s", d)
+		}
+		return errors.Wrapf(err, "failed to delete blob %s", d)
+	}
+	return nil
+}
+
+// List returns the digests of all stored blobs
+func (b *FileBackend) List(ctx context.Context) ([]digest.Digest, error) {
+	var digests []digest.Digest
+	err := filepath.WalkDir(b.dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if path == b.spool.Dir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		algorithm := filepath.Base(filepath.Dir(filepath.Dir(path)))
+		d := digest.NewDigestFromEncoded(digest.Algorithm(algorithm), entry.Name())
+		if d.Validate() == nil {
+			digests = append(digests, d)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list blobs in %s", b.dir)
+	}
+	return digests, nil
+}
+
+// sliceRange returns length bytes of data from offset, clamped to data
+func sliceRange(data []byte, offset, length int64) []byte {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	end := int64(len(data))
+	if length >= 0 && offset+length < end {
+		end = offset + length
+	}
+	return data[offset:end]
+}
+
+// path returns the file of the blob d
+func (b *FileBackend) path(d digest.Digest) (string, error) {
+	if err := d.Validate(); err != nil {
+		return "", errors.InvalidInputf("invalid blob digest %q: %v", d, err)
+	}
+	encoded := d.Encoded()
+	return filepath.Join(b.dir, string(d.Algorithm()), encoded[:2], encoded), nil
+}
@@ -0,0 +1,8 @@
+//go:build !unix
+
+package storage
+
+// FreeSpace is not supported on this platform
+func FreeSpace(dir string) (uint64, error) {
+	return 0, ErrFreeSpaceUnsupported
+}
@@ -0,0 +1,390 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"freightliner/pkg/helper/errors"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"golang.org/x/oauth2/google"
+)
+
+// emptyPayloadHash is the SHA-256 of an empty request body
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// ObjectStore is a bucket of an object storage service
+type ObjectStore interface {
+	PutObject(ctx context.Context, key string, data []byte) error
+	GetObject(ctx context.Context, key string) ([]byte, error)
+	// GetObjectRange returns length bytes from offset; a negative length
+	// reads to the end of the object
+	GetObjectRange(ctx context.Context, key string, offset, length int64) ([]byte, error)
+	ObjectExists(ctx context.Context, key string) (bool, error)
+	DeleteObject(ctx context.Context, key string) error
+	ListObjects(ctx context.Context, prefix string) ([]string, error)
+}
+
+// S3ObjectStore is an S3 bucket accessed with SigV4-signed requests
+type S3ObjectStore struct {
+	bucket      string
+	region      string
+	endpoint    string
+	credentials aws.CredentialsProvider
+	signer      *v4.Signer
+	httpClient  *http.Client
+}
+
+// NewS3ObjectStore creates an S3 object store. An empty endpoint selects the
+// regional virtual-hosted S3 endpoint; other endpoints, e.g. MinIO, are
+// addressed path-style.
+func NewS3ObjectStore(bucket, region, endpoint string, credentials aws.CredentialsProvider) *S3ObjectStore {
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://%s.s3.%s.amazonaws.com", bucket, region)
+	} else {
+		endpoint = strings.TrimRight(endpoint, "/") + "/" + bucket
+	}
+
+	return &S3ObjectStore{
+		bucket:      bucket,
+		region:      region,
+		endpoint:    strings.TrimRight(endpoint, "/"),
+		credentials: credentials,
+		signer:      v4.NewSigner(),
+		httpClient:  &http.Client{Timeout: 5 * time.Minute},
+	}
+}
+
+// PutObject stores data under key
+func (s *S3ObjectStore) PutObject(ctx context.Context, key string, data []byte) error {
+	resp, err := s.do(ctx, http.MethodPut, s.objectURL(key), data, nil)
+	if err != nil {
+		return err
+	}
+	return closeObjectResponse(resp, "s3", s.bucket, key)
+}
+
+// GetObject returns the object key
+func (s *S3ObjectStore) GetObject(ctx context.Context, key string) ([]byte, error) {
+	return s.GetObjectRange(ctx, key, 0, -1)
+}
+
+// GetObjectRange returns part of the object key with a Range request
+func (s *S3ObjectStore) GetObjectRange(ctx context.Context, key string, offset, length int64) ([]byte, error) {
+	header := http.Header{}
+	if offset > 0 || length >= 0 {
+		header.Set("Range", byteRange(offset, length))
+	}
+
+	resp, err := s.do(ctx, http.MethodGet, s.objectURL(key), nil, header)
+	if err != nil {
+		return nil, err
+	}
+	return readObjectResponse(resp, "s3", s.bucket, key)
+}
+
+// ObjectExists reports whether key exists
+func (s *S3ObjectStore) ObjectExists(ctx context.Context, key string) (bool, error) {
+	resp, err := s.do(ctx, http.MethodHead, s.objectURL(key), nil, nil)
+	if err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return false, nil
+	case resp.StatusCode/100 != 2:
+		return false, errors.Newf("S3 HEAD of s3://%s/%s returned %s", s.bucket, key, resp.Status)
+	}
+	return true, nil
+}
+
+// DeleteObject removes key
+func (s *S3ObjectStore) DeleteObject(ctx context.Context, key string) error {
+	resp, err := s.do(ctx, http.MethodDelete, s.objectURL(key), nil, nil)
+	if err != nil {
+		return err
+	}
+	return closeObjectResponse(resp, "s3", s.bucket, key)
+}
+
+// ListObjects returns the keys starting with prefix
+func (s *S3ObjectStore) ListObjects(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	token := ""
+	for {
+		query := url.Values{}
+		query.Set("list-type", "2")
+		query.Set("prefix", prefix)
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+
+		resp, err := s.do(ctx, http.MethodGet, s.endpoint+"/?"+query.Encode(), nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		body, err := readObjectResponse(resp, "s3", s.bucket, prefix)
+		if err != nil {
+			return nil, err
+		}
+
+		var page struct {
+			Contents []struct {
+				Key string `xml:"Key"`
+			} `xml:"Contents"`
+			IsTruncated           bool   `xml:"IsTruncated"`
+			NextContinuationToken string `xml:"NextContinuationToken"`
+		}
+		if err := xml.Unmarshal(body, &page); err != nil {
+			return nil, errors.Wrap(err, "failed to decode S3 object listing")
+		}
+		for _, object := range page.Contents {
+			keys = append(keys, object.Key)
+		}
+		if !page.IsTruncated || page.NextContinuationToken == "" {
+			return keys, nil
+		}
+		token = page.NextContinuationToken
+	}
+}
+
+// objectURL returns the URL of key
+func (s *S3ObjectStore) objectURL(key string) string {
+	return s.endpoint + "/" + (&url.URL{Path: key}).EscapedPath()
+}
+
+// do sends a signed request
+func (s *S3ObjectStore) do(ctx context.Context, method, target string, body []byte, header http.Header) (*http.Response, error) {
+	creds, err := s.credentials.Retrieve(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to retrieve AWS credentials")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create S3 request")
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+
+	payloadHash := emptyPayloadHash
+	if len(body) > 0 {
+		sum := sha256.Sum256(body)
+		payloadHash = hex.EncodeToString(sum[:])
+	}
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	if err := s.signer.SignHTTP(ctx, creds, req, payloadHash, "s3", s.region, time.Now().UTC()); err != nil {
+		return nil, errors.Wrap(err, "failed to sign S3 request")
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "S3 request failed")
+	}
+	return resp, nil
+}
+
+// gcsEndpoint is the Cloud Storage JSON API
+const gcsEndpoint = "https://storage.googleapis.com"
+
+// GCSObjectStore is a Google Cloud Storage bucket accessed with the JSON API
+type GCSObjectStore struct {
+	bucket     string
+	endpoint   string
+	httpClient *http.Client
+}
+
+// NewGCSObjectStoreWithDefaultCredentials creates a GCS object store using
+// application default credentials
+func NewGCSObjectStoreWithDefaultCredentials(ctx context.Context, bucket string) (*GCSObjectStore, error) {
+	client, err := google.DefaultClient(ctx, "https://www.googleapis.com/auth/devstorage.read_write")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load Google credentials")
+	}
+	return NewGCSObjectStore(bucket, "", client), nil
+}
+
+// NewGCSObjectStore creates a GCS object store with an already authenticated
+// HTTP client. An empty endpoint selects the public GCS endpoint.
+func NewGCSObjectStore(bucket, endpoint string, client *http.Client) *GCSObjectStore {
+	if endpoint == "" {
+		endpoint = gcsEndpoint
+	}
+	return &GCSObjectStore{
+		bucket:     bucket,
+		endpoint:   strings.TrimRight(endpoint, "/"),
+		httpClient: client,
+	}
+}
+
+// PutObject stores data under key
+func (g *GCSObjectStore) PutObject(ctx context.Context, key string, data []byte) error {
+	query := url.Values{}
+	query.Set("uploadType", "media")
+	query.Set("name", key)
+	target := g.endpoint + "/upload/storage/v1/b/" + url.PathEscape(g.bucket) + "/o?" + query.Encode()
+
+	header := http.Header{}
+	header.Set("Content-Type", "application/octet-stream")
+	resp, err := g.do(ctx, http.MethodPost, target, data, header)
+	if err != nil {
+		return err
+	}
+	return closeObjectResponse(resp, "gs", g.bucket, key)
+}
+
+// GetObject returns the object key
+func (g *GCSObjectStore) GetObject(ctx context.Context, key string) ([]byte, error) {
+	return g.GetObjectRange(ctx, key, 0, -1)
+}
+
+// GetObjectRange returns part of the object key with a Range request
+func (g *GCSObjectStore) GetObjectRange(ctx context.Context, key string, offset, length int64) ([]byte, error) {
+	header := http.Header{}
+	if offset > 0 || length >= 0 {
+		header.Set("Range", byteRange(offset, length))
+	}
+
+	resp, err := g.do(ctx, http.MethodGet, g.objectURL(key)+"?alt=media", nil, header)
+	if err != nil {
+		return nil, err
+	}
+	return readObjectResponse(resp, "gs", g.bucket, key)
+}
+
+// ObjectExists reports whether key exists
+func (g *GCSObjectStore) ObjectExists(ctx context.Context, key string) (bool, error) {
+	resp, err := g.do(ctx, http.MethodGet, g.objectURL(key)+"?fields=name", nil, nil)
+	if err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return false, nil
+	case resp.StatusCode/100 != 2:
+		return false, errors.Newf("GCS lookup of gs://%s/%s returned %s", g.bucket, key, resp.Status)
+	}
+	return true, nil
+}
+
+// DeleteObject removes key
+func (g *GCSObjectStore) DeleteObject(ctx context.Context, key string) error {
+	resp, err := g.do(ctx, http.MethodDelete, g.objectURL(key), nil, nil)
+	if err != nil {
+		return err
+	}
+	return closeObjectResponse(resp, "gs", g.bucket, key)
+}
+
+// ListObjects returns the keys starting with prefix
+func (g *GCSObjectStore) ListObjects(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	token := ""
+	for {
+		query := url.Values{}
+		query.Set("prefix", prefix)
+		query.Set("fields", "items(name),nextPageToken")
+		if token != "" {
+			query.Set("pageToken", token)
+		}
+
+		target := g.endpoint + "/storage/v1/b/" + url.PathEscape(g.bucket) + "/o?" + query.Encode()
+		resp, err := g.do(ctx, http.MethodGet, target, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		body, err := readObjectResponse(resp, "gs", g.bucket, prefix)
+		if err != nil {
+			return nil, err
+		}
+
+		var page struct {
+			Items []struct {
+				Name string `json:"name"`
+			} `json:"items"`
+			NextPageToken string `json:"nextPageToken"`
+		}
+		if err := json.Unmarshal(body, &page); err != nil {
+			return nil, errors.Wrap(err, "failed to decode GCS object listing")
+		}
+		for _, item := range page.Items {
+			keys = append(keys, item.Name)
+		}
+		if page.NextPageToken == "" {
+			return keys, nil
+		}
+		token = page.NextPageToken
+	}
+}
+
+// objectURL returns the JSON API URL of key
+func (g *GCSObjectStore) objectURL(key string) string {
+	return g.endpoint + "/storage/v1/b/" + url.PathEscape(g.bucket) + "/o/" + url.PathEscape(key)
+}
+
+// do sends a request with the authenticated client
+func (g *GCSObjectStore) do(ctx context.Context, method, target string, body []byte, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create GCS request")
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+
+	resp, err := g.httpClient.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "GCS request failed")
+	}
+	return resp, nil
+}
+
+// byteRange formats an HTTP Range header value
+func byteRange(offset, length int64) string {
+	if length < 0 {
+		return fmt.Sprintf("bytes=%d-", offset)
+	}
+	return fmt.Sprintf("bytes=%d-%d", offset, offset+length-1)
+}
+
+// readObjectResponse returns the body of a successful response
+func readObjectResponse(resp *http.Response, scheme, bucket, key string) ([]byte, error) {
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, errors.NotFoundf("object not found: %s://%s/%s", scheme, bucket, key)
+	}
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, errors.Newf("request for %s://%s/%s returned %s: %s", scheme, bucket, key, resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read %s://%s/%s", scheme, bucket, key)
+	}
+	return data, nil
+}
+
+// closeObjectResponse checks a response whose body is not needed
+func closeObjectResponse(resp *http.Response, scheme, bucket, key string) error {
+	_, err := readObjectResponse(resp, scheme, bucket, key)
+	return err
+}
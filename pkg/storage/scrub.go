@@ -0,0 +1,361 @@
+package storage
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"freightliner/pkg/helper/errors"
+	"freightliner/pkg/helper/log"
+
+	"github.com/opencontainers/go-digest"
+)
+
+// BlobSource is where a blob can be fetched again upstream
+type BlobSource struct {
+	Registry   string `json:"registry"`
+	Repository string `json:"repository"`
+}
+
+// BlobFetcher fetches a blob from its upstream source
+type BlobFetcher interface {
+	FetchBlob(ctx context.Context, source BlobSource, d digest.Digest) ([]byte, error)
+}
+
+// ScrubberConfig holds configuration for a Scrubber
+type ScrubberConfig struct {
+	// Interval between background scrubs, 24h by default
+	Interval time.Duration
+	// Fetcher re-fetches corrupted blobs with a known source; without it
+	// corrupted blobs are evicted unless an intact copy is left
+	Fetcher BlobFetcher
+	Logger  log.Logger
+}
+
+// ScrubMetrics tracks scrubber results across runs
+type ScrubMetrics struct {
+	Runs           atomic.Uint64
+	BlobsScrubbed  atomic.Uint64
+	BytesScrubbed  atomic.Uint64
+	Corrupted      atomic.Uint64
+	Repaired       atomic.Uint64
+	Evicted        atomic.Uint64
+	RepairFailures atomic.Uint64
+}
+
+// ScrubReport describes one scrub of the store
+type ScrubReport struct {
+	Scrubbed  int
+	Corrupted []digest.Digest
+	Repaired  []digest.Digest
+	Evicted   []digest.Digest
+	Duration  time.Duration
+}
+
+// Scrubber verifies the blobs of a ContentAddressableStore by re-hashing the
+// in-memory and backend copy of each blob. A corrupted copy is rewritten from
+// an intact copy or from upstream when the blob's source is known, and the
+// blob is evicted when neither is available.
+type Scrubber struct {
+	cas      *ContentAddressableStore
+	fetcher  BlobFetcher
+	interval time.Duration
+	logger   log.Logger
+	metrics  *ScrubMetrics
+
+	mu      sync.RWMutex
+	sources map[digest.Digest]BlobSource
+
+	runMu  sync.Mutex
+	stopCh chan struct{}
+	once   sync.Once
+}
+
+// NewScrubber creates a scrubber for cas
+func NewScrubber(cas *ContentAddressableStore, config ScrubberConfig) *Scrubber {
+	if config.Logger == nil {
+		config.Logger = log.NewBasicLogger(log.InfoLevel)
+	}
+	if config.Interval == 0 {
+		config.Interval = 24 * time.Hour
+	}
+
+	return &Scrubber{
+		cas:      cas,
+		fetcher:  config.Fetcher,
+		interval: config.Interval,
+		logger:   config.Logger,
+		metrics:  &ScrubMetrics{},
+		sources:  make(map[digest.Digest]BlobSource),
+		stopCh:   make(chan struct{}),
+	}
+}
+
+// RecordSource remembers where the blob d can be fetched again
+func (s *Scrubber) RecordSource(d digest.Digest, source BlobSource) {
+	s.mu.Lock()
+	s.sources[d] = source
+	s.mu.Unlock()
+}
+
+// Start scrubs the store every interval until Stop is called
+func (s *Scrubber) Start() {
+	go func() {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if _, err := s.Scrub(context.Background()); err != nil {
+					s.logger.WithFields(map[string]interface{}{
+						"error": err.Error(),
+					}).Warn("Blob scrub failed")
+				}
+			case <-s.stopCh:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops background scrubbing
+func (s *Scrubber) Stop() {
+	s.once.Do(func() { close(s.stopCh) })
+}
+
+// GetMetrics returns scrubber metrics
+func (s *Scrubber) GetMetrics() *ScrubMetrics {
+	return s.metrics
+}
+
+// Scrub verifies every blob in the store once and repairs or evicts the
+// corrupted ones. Only one scrub runs at a time.
+func (s *Scrubber) Scrub(ctx context.Context) (*ScrubReport, error) {
+	s.runMu.Lock()
+	defer s.runMu.Unlock()
+
+	start := time.Now()
+	report := &ScrubReport{}
+	defer func() {
+		report.Duration = time.Since(start)
+		s.metrics.Runs.Add(1)
+	}()
+
+	digests, err := s.digests(ctx)
+	if err != nil {
+		return report, err
+	}
+
+	for _, d := range digests {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+		s.scrubBlob(ctx, d, report)
+	}
+
+	s.logger.WithFields(map[string]interface{}{
+		"scrubbed":  report.Scrubbed,
+		"corrupted": len(report.Corrupted),
+		"repaired":  len(report.Repaired),
+		"evicted":   len(report.Evicted),
+		"duration":  report.Duration.String(),
+	}).Info("Blob scrub completed")
+	return report, nil
+}
+
+// digests returns the blobs held in memory or by the backend
+func (s *Scrubber) digests(ctx context.Context) ([]digest.Digest, error) {
+	seen := make(map[digest.Digest]bool)
+	digests, err := s.cas.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range digests {
+		seen[d] = true
+	}
+
+	if s.cas.backend != nil {
+		stored, err := s.cas.backend.List(ctx)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to list backend blobs")
+		}
+		for _, d := range stored {
+			if !seen[d] {
+				seen[d] = true
+				digests = append(digests, d)
+			}
+		}
+	}
+	return digests, nil
+}
+
+// scrubBlob verifies both copies of d and repairs or evicts a bad blob
+func (s *Scrubber) scrubBlob(ctx context.Context, d digest.Digest, report *ScrubReport) {
+	s.cas.mu.RLock()
+	blob := s.cas.storage[d]
+	s.cas.mu.RUnlock()
+
+	var good []byte
+	memoryBad, backendBad := false, false
+	if blob != nil {
+		s.metrics.BytesScrubbed.Add(uint64(len(blob.Data)))
+		if verifyBlob(d, blob.Data) {
+			good = blob.Data
+		} else {
+			memoryBad = true
+		}
+	}
+
+	if s.cas.backend != nil && s.cas.backend.Exists(ctx, d) {
+		data, err := s.cas.backend.Get(ctx, d)
+		switch {
+		case err != nil && !errors.Is(err, errors.ErrNotFound):
+			s.logger.WithFields(map[string]interface{}{
+				"digest": d.String(),
+				"error":  err.Error(),
+			}).Warn("Failed to read blob while scrubbing")
+		case err == nil && verifyBlob(d, data):
+			s.metrics.BytesScrubbed.Add(uint64(len(data)))
+			good = data
+		case err == nil:
+			s.metrics.BytesScrubbed.Add(uint64(len(data)))
+			backendBad = true
+		}
+	}
+
+	report.Scrubbed++
+	s.metrics.BlobsScrubbed.Add(1)
+	if !memoryBad && !backendBad {
+		return
+	}
+
+	report.Corrupted = append(report.Corrupted, d)
+	s.metrics.Corrupted.Add(1)
+	s.logger.WithFields(map[string]interface{}{
+		"digest":  d.String(),
+		"memory":  memoryBad,
+		"backend": backendBad,
+	}).Warn("Corrupted blob detected")
+
+	if good == nil {
+		good = s.refetch(ctx, d)
+	}
+	if good != nil && s.repair(ctx, d, good, memoryBad, backendBad) {
+		report.Repaired = append(report.Repaired, d)
+		s.metrics.Repaired.Add(1)
+		return
+	}
+
+	s.evict(ctx, d)
+	report.Evicted = append(report.Evicted, d)
+	s.metrics.Evicted.Add(1)
+}
+
+// refetch fetches d from its recorded source, or returns nil
+func (s *Scrubber) refetch(ctx context.Context, d digest.Digest) []byte {
+	s.mu.RLock()
+	source, known := s.sources[d]
+	s.mu.RUnlock()
+	if !known || s.fetcher == nil {
+		return nil
+	}
+
+	data, err := s.fetcher.FetchBlob(ctx, source, d)
+	if err == nil && !verifyBlob(d, data) {
+		err = errors.New("upstream returned different content")
+	}
+	if err != nil {
+		s.metrics.RepairFailures.Add(1)
+		s.logger.WithFields(map[string]interface{}{
+			"digest":     d.String(),
+			"registry":   source.Registry,
+			"repository": source.Repository,
+			"error":      err.Error(),
+		}).Warn("Failed to re-fetch corrupted blob")
+		return nil
+	}
+	return data
+}
+
+// repair replaces the corrupted copies of d with data
+func (s *Scrubber) repair(ctx context.Context, d digest.Digest, data []byte, memoryBad, backendBad bool) bool {
+	if backendBad {
+		// Backends skip blobs they already hold, so drop the bad file first
+		if err := s.cas.backend.Delete(ctx, d); err != nil && !errors.Is(err, errors.ErrNotFound) {
+			s.metrics.RepairFailures.Add(1)
+			return false
+		}
+		if err := s.cas.backend.Put(ctx, d, data); err != nil {
+			s.metrics.RepairFailures.Add(1)
+			s.logger.WithFields(map[string]interface{}{
+				"digest": d.String(),
+				"error":  err.Error(),
+			}).Warn("Failed to rewrite corrupted blob")
+			return false
+		}
+	}
+
+	if memoryBad {
+		s.cas.mu.Lock()
+		if old, exists := s.cas.storage[d]; exists {
+			blob := &Blob{
+				Digest:      d,
+				Data:        data,
+				Size:        int64(len(data)),
+				CreatedAt:   old.CreatedAt,
+				LastAccess:  time.Now(),
+				ContentType: old.ContentType,
+				Tags:        old.Tags,
+			}
+			blob.RefCount.Store(old.RefCount.Load())
+			s.cas.storage[d] = blob
+			s.cas.index.Remove(d)
+			s.cas.index.Add(blob)
+		}
+		s.cas.mu.Unlock()
+	}
+
+	s.logger.WithFields(map[string]interface{}{
+		"digest": d.String(),
+	}).Info("Repaired corrupted blob")
+	return true
+}
+
+// evict removes d from memory and the backend regardless of its references
+func (s *Scrubber) evict(ctx context.Context, d digest.Digest) {
+	s.cas.mu.Lock()
+	blob, exists := s.cas.storage[d]
+	delete(s.cas.storage, d)
+	s.cas.index.Remove(d)
+	s.cas.usage.remove(d)
+	s.cas.mu.Unlock()
+	s.cas.notifyRemoved(ctx, d)
+
+	if s.cas.backend != nil {
+		if err := s.cas.backend.Delete(ctx, d); err != nil && !errors.Is(err, errors.ErrNotFound) {
+			s.logger.WithFields(map[string]interface{}{
+				"digest": d.String(),
+				"error":  err.Error(),
+			}).Warn("Failed to delete corrupted blob from backend")
+		}
+	}
+
+	var refs int64
+	if exists {
+		refs = blob.RefCount.Load()
+	}
+	s.logger.WithFields(map[string]interface{}{
+		"digest":    d.String(),
+		"ref_count": refs,
+	}).Warn("Evicted corrupted blob")
+}
+
+// verifyBlob reports whether data hashes to d
+func verifyBlob(d digest.Digest, data []byte) bool {
+	if d.Validate() != nil {
+		return false
+	}
+	return d.Algorithm().FromBytes(data) == d
+}
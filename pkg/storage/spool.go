@@ -0,0 +1,289 @@
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"freightliner/pkg/helper/errors"
+	"freightliner/pkg/helper/log"
+
+	"github.com/opencontainers/go-digest"
+)
+
+const (
+	// partialPrefix and partialSuffix bracket the names of files a spool is
+	// still writing: "freightliner-<pid>-<random>.partial"
+	partialPrefix = "freightliner-"
+	partialSuffix = ".partial"
+
+	// orphanAge is how old a partial file must be before it is removed even
+	// though a process with its PID is running, in case the PID was reused
+	orphanAge = 24 * time.Hour
+
+	// SpoolHeadroom is the free space a spool leaves on its filesystem
+	SpoolHeadroom = 64 << 20
+)
+
+// ErrFreeSpaceUnsupported is returned by FreeSpace on platforms where free
+// space cannot be measured; free space checks are skipped there
+var ErrFreeSpaceUnsupported = errors.New("free space check not supported on this platform")
+
+// Spool is a temp area for blobs streamed to disk. Blobs are written to
+// partial files named after the writing process, verified against their
+// digest, and only then renamed to their final digest-named path, so a crash
+// never leaves a truncated blob where a reader would trust it. Partial files
+// left behind by processes that are no longer running are removed when the
+// spool is opened.
+type Spool struct {
+	dir    string
+	logger log.Logger
+}
+
+// SpooledBlob is a verified blob still in the spool's temp area
+type SpooledBlob struct {
+	Path   string
+	Digest digest.Digest
+	Size   int64
+}
+
+// OpenSpool creates dir if needed and removes orphaned partial files from it
+func OpenSpool(dir string, logger log.Logger) (*Spool, error) {
+	if logger == nil {
+		logger = log.NewBasicLogger(log.InfoLevel)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, errors.Wrapf(err, "failed to create spool directory %s", dir)
+	}
+
+	s := &Spool{dir: dir, logger: logger}
+	removed, err := s.CleanOrphans()
+	if err != nil {
+		return nil, err
+	}
+	if removed > 0 {
+		logger.WithFields(map[string]interface{}{
+			"directory": dir,
+			"removed":   removed,
+		}).Info("Removed partial files left by an interrupted run")
+	}
+	return s, nil
+}
+
+// Dir returns the spool's directory
+func (s *Spool) Dir() string {
+	return s.dir
+}
+
+// CleanOrphans removes partial files whose process is no longer running, or
+// that have not been written to for a day. It returns how many it removed.
+func (s *Spool) CleanOrphans() (int, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed to read spool directory %s", s.dir)
+	}
+
+	removed := 0
+	for _, entry := range entries {
+		pid, ok := partialPID(entry.Name())
+		if !ok || entry.IsDir() {
+			continue
+		}
+		if pid == os.Getpid() {
+			continue
+		}
+		if processRunning(pid) {
+			info, err := entry.Info()
+			if err != nil || time.Since(info.ModTime()) < orphanAge {
+				continue
+			}
+		}
+
+		if err := os.Remove(filepath.Join(s.dir, entry.Name())); err != nil && !os.IsNotExist(err) {
+			s.logger.WithFields(map[string]interface{}{
+				"file":  entry.Name(),
+				"error": err.Error(),
+			}).Warn("Failed to remove orphaned partial file")
+			continue
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// Create creates an empty partial file owned by this process
+func (s *Spool) Create() (*os.File, error) {
+	var suffix [8]byte
+	if _, err := rand.Read(suffix[:]); err != nil {
+		return nil, errors.Wrap(err, "failed to name partial file")
+	}
+
+	name := fmt.Sprintf("%s%d-%s%s", partialPrefix, os.Getpid(), hex.EncodeToString(suffix[:]), partialSuffix)
+	file, err := os.OpenFile(filepath.Join(s.dir, name), os.O_RDWR|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create partial file in %s", s.dir)
+	}
+	return file, nil
+}
+
+// EnsureFree returns an error unless size bytes, plus SpoolHeadroom, fit on
+// the spool's filesystem. Sizes of zero or less are not checked.
+func (s *Spool) EnsureFree(size int64) error {
+	return EnsureFree(s.dir, size)
+}
+
+// Write streams r into a partial file and verifies it against expected. An
+// empty expected digest is computed with the canonical algorithm instead.
+// The blob stays in the temp area until committed or discarded.
+func (s *Spool) Write(ctx context.Context, r io.Reader, expected digest.Digest) (*SpooledBlob, error) {
+	algorithm := digest.Canonical
+	if expected != "" {
+		if err := expected.Validate(); err != nil {
+			return nil, errors.InvalidInputf("invalid blob digest %q: %v", expected, err)
+		}
+		algorithm = expected.Algorithm()
+	}
+
+	file, err := s.Create()
+	if err != nil {
+		return nil, err
+	}
+	blob := &SpooledBlob{Path: file.Name()}
+
+	digester := algorithm.Digester()
+	blob.Size, err = io.Copy(io.MultiWriter(file, digester.Hash()), contextReader{ctx: ctx, r: r})
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = blob.Discard()
+		return nil, errors.Wrap(err, "failed to spool blob")
+	}
+
+	blob.Digest = digester.Digest()
+	if expected != "" && blob.Digest != expected {
+		_ = blob.Discard()
+		return nil, errors.InvalidInputf("blob digest mismatch: expected %s, got %s", expected, blob.Digest)
+	}
+	return blob, nil
+}
+
+// WriteBlob spools r, verifies it against expected and renames it to
+// finalPath, which must be on the spool's filesystem
+func (s *Spool) WriteBlob(ctx context.Context, r io.Reader, expected digest.Digest, finalPath string) (*SpooledBlob, error) {
+	blob, err := s.Write(ctx, r, expected)
+	if err != nil {
+		return nil, err
+	}
+	if err := blob.Commit(finalPath); err != nil {
+		return nil, err
+	}
+	return blob, nil
+}
+
+// Commit renames the blob to finalPath, creating its directory
+func (b *SpooledBlob) Commit(finalPath string) error {
+	if err := os.MkdirAll(filepath.Dir(finalPath), 0755); err != nil {
+		_ = b.Discard()
+		return errors.Wrap(err, "failed to create blob directory")
+	}
+	if err := os.Rename(b.Path, finalPath); err != nil {
+		_ = b.Discard()
+		return errors.Wrapf(err, "failed to move blob to %s", finalPath)
+	}
+	b.Path = finalPath
+	return nil
+}
+
+// Discard removes the blob's file
+func (b *SpooledBlob) Discard() error {
+	if err := os.Remove(b.Path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// EnsureFree returns an error unless size bytes, plus SpoolHeadroom, fit on
+// the filesystem holding dir. Sizes of zero or less are not checked.
+func EnsureFree(dir string, size int64) error {
+	if size <= 0 {
+		return nil
+	}
+
+	free, err := FreeSpace(dir)
+	if errors.Is(err, ErrFreeSpaceUnsupported) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed to check free space in %s", dir)
+	}
+
+	if need := uint64(size) + SpoolHeadroom; free < need {
+		return errors.Unavailablef("not enough disk space in %s: need %s, %s free",
+			dir, formatBytes(need), formatBytes(free))
+	}
+	return nil
+}
+
+// partialPID returns the PID in a partial file's name
+func partialPID(name string) (int, bool) {
+	if !strings.HasPrefix(name, partialPrefix) || !strings.HasSuffix(name, partialSuffix) {
+		return 0, false
+	}
+	rest := strings.TrimPrefix(name, partialPrefix)
+	pidStr, _, ok := strings.Cut(rest, "-")
+	if !ok {
+		return 0, false
+	}
+	pid, err := strconv.Atoi(pidStr)
+	return pid, err == nil && pid > 0
+}
+
+// processRunning reports whether a process with pid exists. Where that cannot
+// be determined the process is assumed to be running.
+func processRunning(pid int) bool {
+	proc, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	err = proc.Signal(syscall.Signal(0))
+	return err == nil || !(errors.Is(err, os.ErrProcessDone) || errors.Is(err, syscall.ESRCH))
+}
+
+// formatBytes formats a byte count with a binary unit
+func formatBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := uint64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// contextReader stops a copy once ctx is done
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c contextReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
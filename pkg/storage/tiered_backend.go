@@ -0,0 +1,349 @@
+package storage
+
+import (
+	"container/list"
+	"context"
+	"path"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"freightliner/pkg/helper/errors"
+	"freightliner/pkg/helper/log"
+
+	"github.com/opencontainers/go-digest"
+)
+
+// RangeReader is implemented by backends that can read part of a blob
+type RangeReader interface {
+	// GetRange returns length bytes of the blob d from offset; a negative
+	// length reads to the end of the blob
+	GetRange(ctx context.Context, d digest.Digest, offset, length int64) ([]byte, error)
+}
+
+// TieredBackendConfig holds configuration for a TieredBackend
+type TieredBackendConfig struct {
+	// Hot is the local disk tier
+	Hot *FileBackend
+	// Cold is the object storage tier
+	Cold ObjectStore
+	// Prefix is prepended to the object keys of blobs, e.g. "cas"
+	Prefix string
+	// MaxHotBytes bounds the hot tier; least recently used blobs beyond it
+	// are offloaded to the cold tier
+	MaxHotBytes int64
+	// PromoteOnRead copies blobs read from the cold tier back to the hot tier
+	PromoteOnRead bool
+	Logger        log.Logger
+}
+
+// TieredMetrics tracks where tiered reads were served from
+type TieredMetrics struct {
+	HotHits    atomic.Uint64
+	ColdHits   atomic.Uint64
+	RangeReads atomic.Uint64
+	Offloaded  atomic.Uint64
+	Promoted   atomic.Uint64
+}
+
+// TieredBackend is a StorageBackend keeping recently used blobs on local disk
+// and overflowing cold blobs to object storage, so a cache can outgrow the
+// local disk. Blobs are written to the hot tier and uploaded to the cold tier
+// only when they are evicted from it.
+type TieredBackend struct {
+	hot           *FileBackend
+	cold          ObjectStore
+	prefix        string
+	maxHotBytes   int64
+	promoteOnRead bool
+	logger        log.Logger
+	metrics       *TieredMetrics
+
+	mu       sync.Mutex
+	lru      *list.List // front is most recently used
+	entries  map[digest.Digest]*list.Element
+	hotBytes int64
+}
+
+// hotEntry is a blob in the hot tier
+type hotEntry struct {
+	digest digest.Digest
+	size   int64
+}
+
+// NewTieredBackend creates a tiered backend, indexing the blobs already in
+// the hot tier
+func NewTieredBackend(ctx context.Context, config TieredBackendConfig) (*TieredBackend, error) {
+	if config.Hot == nil || config.Cold == nil {
+		return nil, errors.InvalidInputf("tiered backend needs a hot and a cold tier")
+	}
+	if config.MaxHotBytes <= 0 {
+		return nil, errors.InvalidInputf("hot tier size must be positive")
+	}
+	if config.Logger == nil {
+		config.Logger = log.NewBasicLogger(log.InfoLevel)
+	}
+
+	b := &TieredBackend{
+		hot:           config.Hot,
+		cold:          config.Cold,
+		prefix:        strings.Trim(config.Prefix, "/"),
+		maxHotBytes:   config.MaxHotBytes,
+		promoteOnRead: config.PromoteOnRead,
+		logger:        config.Logger,
+		metrics:       &TieredMetrics{},
+		lru:           list.New(),
+		entries:       make(map[digest.Digest]*list.Element),
+	}
+
+	digests, err := b.hot.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range digests {
+		size, err := b.hot.Size(d)
+		if err != nil {
+			continue
+		}
+		b.track(d, size)
+	}
+	b.offload(ctx)
+	return b, nil
+}
+
+// Put stores data in the hot tier, offloading cold blobs if it overflows
+func (b *TieredBackend) Put(ctx context.Context, d digest.Digest, data []byte) error {
+	if err := b.hot.Put(ctx, d, data); err != nil {
+		return err
+	}
+	size, err := b.hot.Size(d)
+	if err != nil {
+		return err
+	}
+
+	b.track(d, size)
+	b.offload(ctx)
+	return nil
+}
+
+// Get returns the blob d from the hot tier, or else the cold tier
+func (b *TieredBackend) Get(ctx context.Context, d digest.Digest) ([]byte, error) {
+	if data, err := b.hot.Get(ctx, d); err == nil {
+		b.touch(d)
+		b.metrics.HotHits.Add(1)
+		return data, nil
+	} else if !errors.Is(err, errors.ErrNotFound) {
+		return nil, err
+	}
+
+	data, err := b.cold.GetObject(ctx, b.objectKey(d))
+	if err != nil {
+		if errors.Is(err, errors.ErrNotFound) {
+			return nil, errors.NotFoundf("blob not found: %s", d)
+		}
+		return nil, errors.Wrapf(err, "failed to read blob %s from object storage", d)
+	}
+	if d.Algorithm().FromBytes(data) != d {
+		return nil, errors.Newf("blob %s in object storage is corrupted", d)
+	}
+	b.metrics.ColdHits.Add(1)
+
+	if b.promoteOnRead {
+		if err := b.Put(ctx, d, data); err == nil {
+			b.metrics.Promoted.Add(1)
+		}
+	}
+	return data, nil
+}
+
+// GetRange reads part of the blob d. Cold blobs are read with a byte-range
+// request and are not promoted, so serving part of a large blob does not
+// download all of it.
+func (b *TieredBackend) GetRange(ctx context.Context, d digest.Digest, offset, length int64) ([]byte, error) {
+	b.metrics.RangeReads.Add(1)
+	if data, err := b.hot.GetRange(ctx, d, offset, length); err == nil {
+		b.touch(d)
+		b.metrics.HotHits.Add(1)
+		return data, nil
+	} else if !errors.Is(err, errors.ErrNotFound) {
+		return nil, err
+	}
+
+	data, err := b.cold.GetObjectRange(ctx, b.objectKey(d), offset, length)
+	if err != nil {
+		if errors.Is(err, errors.ErrNotFound) {
+			return nil, errors.NotFoundf("blob not found: %s", d)
+		}
+		return nil, errors.Wrapf(err, "failed to read blob %s from object storage", d)
+	}
+	b.metrics.ColdHits.Add(1)
+	return data, nil
+}
+
+// Exists reports whether either tier holds the blob d
+func (b *TieredBackend) Exists(ctx context.Context, d digest.Digest) bool {
+	if b.hot.Exists(ctx, d) {
+		return true
+	}
+	exists, err := b.cold.ObjectExists(ctx, b.objectKey(d))
+	return err == nil && exists
+}
+
+// Delete removes the blob d from both tiers
+func (b *TieredBackend) Delete(ctx context.Context, d digest.Digest) error {
+	b.untrack(d)
+
+	hotErr := b.hot.Delete(ctx, d)
+	if hotErr != nil && !errors.Is(hotErr, errors.ErrNotFound) {
+		return hotErr
+	}
+	coldErr := b.cold.DeleteObject(ctx, b.objectKey(d))
+	if coldErr != nil && !errors.Is(coldErr, errors.ErrNotFound) {
+		return errors.Wrapf(coldErr, "failed to delete blob %s from object storage", d)
+	}
+
+	if hotErr != nil && coldErr != nil {
+		return errors.NotFoundf("blob not found: %s", d)
+	}
+	return nil
+}
+
+// List returns the digests of the blobs in either tier
+func (b *TieredBackend) List(ctx context.Context) ([]digest.Digest, error) {
+	digests, err := b.hot.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[digest.Digest]bool, len(digests))
+	for _, d := range digests {
+		seen[d] = true
+	}
+
+	keys, err := b.cold.ListObjects(ctx, b.keyPrefix())
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list blobs in object storage")
+	}
+	for _, key := range keys {
+		rest := strings.TrimPrefix(key, b.keyPrefix())
+		algorithm, encoded, ok := strings.Cut(rest, "/")
+		if !ok {
+			continue
+		}
+		d := digest.NewDigestFromEncoded(digest.Algorithm(algorithm), encoded)
+		if d.Validate() == nil && !seen[d] {
+			seen[d] = true
+			digests = append(digests, d)
+		}
+	}
+	return digests, nil
+}
+
+// GetMetrics returns tiered backend metrics
+func (b *TieredBackend) GetMetrics() *TieredMetrics {
+	return b.metrics
+}
+
+// HotBytes returns the bytes held by the hot tier
+func (b *TieredBackend) HotBytes() int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.hotBytes
+}
+
+// offload moves least recently used blobs to the cold tier until the hot
+// tier fits. A blob whose upload fails stays in the hot tier.
+func (b *TieredBackend) offload(ctx context.Context) {
+	b.mu.Lock()
+	var victims []*hotEntry
+	excess := b.hotBytes - b.maxHotBytes
+	for elem := b.lru.Back(); elem != nil && excess > 0; elem = elem.Prev() {
+		entry := elem.Value.(*hotEntry)
+		victims = append(victims, entry)
+		excess -= entry.size
+	}
+	b.mu.Unlock()
+
+	for _, entry := range victims {
+		if err := b.offloadBlob(ctx, entry.digest); err != nil {
+			b.logger.WithFields(map[string]interface{}{
+				"digest": entry.digest.String(),
+				"error":  err.Error(),
+			}).Warn("Failed to offload blob to object storage")
+			continue
+		}
+		b.untrack(entry.digest)
+		b.metrics.Offloaded.Add(1)
+	}
+}
+
+// offloadBlob uploads d to the cold tier, unless it is there already, and
+// removes it from the hot tier
+func (b *TieredBackend) offloadBlob(ctx context.Context, d digest.Digest) error {
+	key := b.objectKey(d)
+	exists, err := b.cold.ObjectExists(ctx, key)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		data, err := b.hot.Get(ctx, d)
+		if err != nil {
+			return err
+		}
+		if err := b.cold.PutObject(ctx, key, data); err != nil {
+			return err
+		}
+	}
+
+	if err := b.hot.Delete(ctx, d); err != nil && !errors.Is(err, errors.ErrNotFound) {
+		return err
+	}
+	return nil
+}
+
+// track records d in the hot tier as most recently used
+func (b *TieredBackend) track(d digest.Digest, size int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if elem, exists := b.entries[d]; exists {
+		b.lru.MoveToFront(elem)
+		return
+	}
+	b.entries[d] = b.lru.PushFront(&hotEntry{digest: d, size: size})
+	b.hotBytes += size
+}
+
+// touch marks d as most recently used
+func (b *TieredBackend) touch(d digest.Digest) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if elem, exists := b.entries[d]; exists {
+		b.lru.MoveToFront(elem)
+	}
+}
+
+// untrack forgets d in the hot tier
+func (b *TieredBackend) untrack(d digest.Digest) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if elem, exists := b.entries[d]; exists {
+		b.hotBytes -= elem.Value.(*hotEntry).size
+		b.lru.Remove(elem)
+		delete(b.entries, d)
+	}
+}
+
+// keyPrefix returns the prefix of all blob keys
+func (b *TieredBackend) keyPrefix() string {
+	if b.prefix == "" {
+		return ""
+	}
+	return b.prefix + "/"
+}
+
+// objectKey returns the object key of d, <prefix>/<algorithm>/<hex>
+func (b *TieredBackend) objectKey(d digest.Digest) string {
+	return b.keyPrefix() + path.Join(string(d.Algorithm()), d.Encoded())
+}
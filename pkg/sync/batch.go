@@ -2,20 +2,34 @@ package sync
 
 import (
 	"context"
+	"crypto"
+	"errors"
 	"fmt"
 	"sort"
 	"sync"
 	"time"
 
+	"freightliner/pkg/attestation"
 	"freightliner/pkg/client"
 	copyutil "freightliner/pkg/copy"
+	"freightliner/pkg/helper/apicalls"
 	"freightliner/pkg/helper/log"
+	"freightliner/pkg/helper/readonly"
+	"freightliner/pkg/interfaces"
+	"freightliner/pkg/policy"
 	"freightliner/pkg/replication"
+	"freightliner/pkg/resilience"
 	"freightliner/pkg/service"
+	"freightliner/pkg/transform"
 
 	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
 )
 
+// SignatureVerifier verifies the signatures of a source image against a
+// rule's sign_verification settings before it is copied
+type SignatureVerifier func(ctx context.Context, ref name.Reference, config *SignatureConfig, opts []remote.Option) error
+
 // BatchExecutor executes sync tasks in optimized batches
 type BatchExecutor struct {
 	config      *Config
@@ -27,6 +41,54 @@ type BatchExecutor struct {
 	clientCache map[string]service.RegistryClient // Cache clients by registry URL
 	cacheMu     sync.RWMutex                      // Protect client cache
 
+	// Run-wide retry budget shared by task retries and registry requests
+	retryBudget *resilience.RetryBudget
+	maxRetries  int
+
+	// ledger records pushed images for the run attestation (nil when disabled)
+	ledger *attestation.Ledger
+
+	// resigner signs images whose digest changes on copy (nil when disabled)
+	resigner crypto.Signer
+
+	// annotateRunID records the run ID on every pushed manifest
+	annotateRunID bool
+
+	// policy decides whether each image is copied, skipped or quarantined
+	// (nil when no policy is configured)
+	policy policy.Evaluator
+
+	// verifySignatures checks tasks with sign_verification enabled (nil when
+	// the build has no signature support)
+	verifySignatures SignatureVerifier
+
+	// ruleCalls counts the registry API calls of each task's rule (nil when
+	// not counted)
+	ruleCalls *apicalls.Group
+
+	// throttles cap the concurrency and request rate of rules with their
+	// own profile, by rule
+	throttles  map[string]*ruleThrottle
+	throttleMu sync.Mutex
+
+	// pullThroughCache is the policy for destination repositories that are
+	// ECR pull through caches of their source, and pullThroughWarned the
+	// repositories already warned about
+	pullThroughCache  string
+	pullThroughWarned map[string]bool
+
+	// repoMetadata copies repository descriptions and readmes, and
+	// repoMetadataCopied holds the destination repositories already done
+	repoMetadata       bool
+	repoMetadataCopied map[string]bool
+
+	// repoCreation is the run's policy for missing destination
+	// repositories, which rules may override. repoCreateErrs holds the
+	// repositories that could not be created, so each is tried once.
+	repoCreation   service.RepositoryCreationPolicy
+	repoCreateErrs map[string]error
+	repoCreateMu   sync.Mutex
+
 	// Adaptive batching state
 	currentBatchSize int        // Current batch size (adjusted dynamically)
 	batchStats       batchStat  // Statistics from previous batches
@@ -63,6 +125,53 @@ func NewBatchExecutorWithFactory(config *Config, logger log.Logger, factory *cli
 	}
 }
 
+// WithRetryBudget draws task retries and registry request retries from a
+// budget shared by the whole run
+func (be *BatchExecutor) WithRetryBudget(budget *resilience.RetryBudget, maxRetries int) *BatchExecutor {
+	be.retryBudget = budget
+	be.maxRetries = maxRetries
+	return be
+}
+
+// WithLedger records every image the executor pushes for the run attestation
+func (be *BatchExecutor) WithLedger(ledger *attestation.Ledger) *BatchExecutor {
+	be.ledger = ledger
+	return be
+}
+
+// WithResigner signs images whose digest changes on copy with signer
+func (be *BatchExecutor) WithResigner(signer crypto.Signer) *BatchExecutor {
+	be.resigner = signer
+	return be
+}
+
+// WithRunIDAnnotation records the run ID of the executor's context on every
+// manifest it pushes when enabled
+func (be *BatchExecutor) WithRunIDAnnotation(enabled bool) *BatchExecutor {
+	be.annotateRunID = enabled
+	return be
+}
+
+// WithPolicy evaluates evaluator for every image before it is copied
+func (be *BatchExecutor) WithPolicy(evaluator policy.Evaluator) *BatchExecutor {
+	be.policy = evaluator
+	return be
+}
+
+// WithSignatureVerifier verifies source signatures of tasks whose rule
+// enables sign_verification
+func (be *BatchExecutor) WithSignatureVerifier(verifier SignatureVerifier) *BatchExecutor {
+	be.verifySignatures = verifier
+	return be
+}
+
+// WithRuleAPICalls counts the registry API calls of every task under its
+// rule in calls
+func (be *BatchExecutor) WithRuleAPICalls(calls *apicalls.Group) *BatchExecutor {
+	be.ruleCalls = calls
+	return be
+}
+
 // Execute executes sync tasks in parallel batches
 func (be *BatchExecutor) Execute(ctx context.Context, tasks []SyncTask) ([]SyncResult, error) {
 	if len(tasks) == 0 {
@@ -76,12 +185,59 @@ func (be *BatchExecutor) Execute(ctx context.Context, tasks []SyncTask) ([]SyncR
 		"adaptive":    be.config.EnableAdaptiveBatching,
 	}).Info("Starting batch execution")
 
+	// Base images are copied in earlier stages than the images built on them
+	tasks = append([]SyncTask(nil), tasks...)
+	if be.config.InferDependencies {
+		InferDependencies(ctx, tasks, be.BaseImage, be.logger)
+	}
+	tasks, err := OrderByDependencies(tasks)
+	if err != nil {
+		return nil, err
+	}
+
 	// Initialize results
 	be.results = make([]SyncResult, len(tasks))
 
 	// Adjust batch size based on previous performance (if adaptive batching enabled)
 	be.adjustBatchSize()
 
+	// Execute the stages in order; images whose base repository was not fully
+	// synced are skipped
+	var errs []error
+	failed := make(map[string]bool)
+	for start := 0; start < len(tasks); {
+		end := start
+		for end < len(tasks) && tasks[end].Stage == tasks[start].Stage {
+			end++
+		}
+
+		runnable := be.skipBlockedTasks(tasks[start:end], start, failed)
+		errs = append(errs, be.executeBatches(ctx, tasks[start:start+runnable], start)...)
+
+		be.mu.Lock()
+		for i := start; i < end; i++ {
+			if !be.results[i].Success {
+				failed[tasks[i].SourceRepository] = true
+			}
+		}
+		be.mu.Unlock()
+		start = end
+	}
+
+	if len(errs) > 0 && !be.config.ContinueOnError {
+		return be.results, fmt.Errorf("batch execution failed: %d errors", len(errs))
+	}
+
+	return be.results, nil
+}
+
+// executeBatches executes tasks in parallel batches, storing their results
+// from position offset
+func (be *BatchExecutor) executeBatches(ctx context.Context, tasks []SyncTask, offset int) []error {
+	if len(tasks) == 0 {
+		return nil
+	}
+
 	// Create batches
 	batches := be.createBatches(tasks)
 
@@ -95,7 +251,7 @@ func (be *BatchExecutor) Execute(ctx context.Context, tasks []SyncTask) ([]SyncR
 	errChan := make(chan error, len(batches))
 
 	// Track cumulative start index for adaptive batch sizes
-	cumulativeIdx := 0
+	cumulativeIdx := offset
 	for batchIdx, batch := range batches {
 		wg.Add(1)
 		startIdx := cumulativeIdx
@@ -127,12 +283,7 @@ func (be *BatchExecutor) Execute(ctx context.Context, tasks []SyncTask) ([]SyncR
 	for err := range errChan {
 		errs = append(errs, err)
 	}
-
-	if len(errs) > 0 && !be.config.ContinueOnError {
-		return be.results, fmt.Errorf("batch execution failed: %d errors", len(errs))
-	}
-
-	return be.results, nil
+	return errs
 }
 
 // createBatches groups tasks into batches using adaptive or fixed batch size
@@ -236,7 +387,8 @@ func (be *BatchExecutor) updateBatchStats(batchResults []SyncResult) {
 	successes := 0
 	var totalDuration int64
 	for _, result := range batchResults {
-		if result.Success {
+		// Policy skips say nothing about registry health
+		if result.Success || result.Skipped {
 			successes++
 		}
 		totalDuration += result.Duration
@@ -323,6 +475,7 @@ func (be *BatchExecutor) executeBatch(ctx context.Context, batchIdx int, startId
 func (be *BatchExecutor) executeTask(ctx context.Context, task SyncTask) SyncResult {
 	startTime := time.Now()
 	var lastErr error
+	var retries int
 
 	srcRef := fmt.Sprintf("%s/%s:%s", task.SourceRegistry, task.SourceRepository, task.SourceTag)
 	dstRef := fmt.Sprintf("%s/%s:%s", task.DestRegistry, task.DestRepository, task.DestTag)
@@ -332,10 +485,22 @@ func (be *BatchExecutor) executeTask(ctx context.Context, task SyncTask) SyncRes
 		"dest":   dstRef,
 	}).Debug("Starting sync task")
 
+	// Rules with their own profile run fewer tasks at once and at a capped rate
+	ctx, release, err := be.throttleFor(task).acquire(ctx)
+	if err != nil {
+		return SyncResult{
+			Task:     task,
+			Success:  false,
+			Error:    fmt.Errorf("task cancelled before execution: %w", err),
+			Duration: time.Since(startTime).Milliseconds(),
+		}
+	}
+	defer release()
+
 	// Create timeout context for the entire task (all retry attempts)
-	// Use configured timeout, default 5 minutes
-	timeout := time.Duration(be.config.Timeout) * time.Second
-	taskCtx, cancel := context.WithTimeout(ctx, timeout)
+	// Use the rule profile's or configured timeout, default 5 minutes
+	settings := be.settingsFor(task)
+	taskCtx, cancel := context.WithTimeout(apicalls.WithCounter(ctx, be.ruleCalls.Counter(task.Rule)), settings.timeout)
 	defer cancel()
 
 	// Check for cancellation before starting
@@ -351,7 +516,7 @@ func (be *BatchExecutor) executeTask(ctx context.Context, task SyncTask) SyncRes
 	}
 
 	// Retry loop
-	for attempt := 0; attempt <= be.config.RetryAttempts; attempt++ {
+	for attempt := 0; attempt <= settings.retryAttempts; attempt++ {
 		// Check for context cancellation/timeout at start of each attempt
 		select {
 		case <-taskCtx.Done():
@@ -374,7 +539,7 @@ func (be *BatchExecutor) executeTask(ctx context.Context, task SyncTask) SyncRes
 		}
 		if attempt > 0 {
 			// Exponential backoff with context-aware sleep
-			backoff := time.Duration(be.config.RetryBackoff*(1<<(attempt-1))) * time.Second
+			backoff := settings.retryBackoff * time.Duration(1<<(attempt-1))
 			be.logger.WithFields(map[string]interface{}{
 				"attempt": attempt,
 				"backoff": backoff,
@@ -419,13 +584,66 @@ func (be *BatchExecutor) executeTask(ctx context.Context, task SyncTask) SyncRes
 			}
 		}
 
+		// A policy skip is a decision, not a failure to retry
+		var skip *PolicySkipError
+		if errors.As(err, &skip) {
+			return SyncResult{
+				Task:       task,
+				Skipped:    true,
+				SkipReason: skip.Decision.String(),
+				Duration:   time.Since(startTime).Milliseconds(),
+				Retries:    attempt,
+			}
+		}
+		var mediaSkip *copyutil.MediaTypeSkipError
+		if errors.As(err, &mediaSkip) {
+			return SyncResult{
+				Task:       task,
+				Skipped:    true,
+				SkipReason: mediaSkip.Error(),
+				Duration:   time.Since(startTime).Milliseconds(),
+				Retries:    attempt,
+			}
+		}
+		var loopSkip *copyutil.MirrorLoopSkipError
+		if errors.As(err, &loopSkip) {
+			return SyncResult{
+				Task:       task,
+				Skipped:    true,
+				SkipReason: loopSkip.Error(),
+				Duration:   time.Since(startTime).Milliseconds(),
+				Retries:    attempt,
+			}
+		}
+		var cacheSkip *PullThroughCacheSkipError
+		if errors.As(err, &cacheSkip) {
+			return SyncResult{
+				Task:       task,
+				Skipped:    true,
+				SkipReason: cacheSkip.Error(),
+				Duration:   time.Since(startTime).Milliseconds(),
+				Retries:    attempt,
+			}
+		}
+
 		lastErr = err
+		retries = attempt
 		be.logger.WithFields(map[string]interface{}{
 			"source":  srcRef,
 			"dest":    dstRef,
 			"attempt": attempt + 1,
 			"error":   err.Error(),
 		}).Warn("Sync task failed")
+
+		// Retrying cannot help once the run's retry budget is spent or when
+		// writes are disabled
+		if resilience.IsRetryBudgetExhausted(err) || errors.Is(err, readonly.ErrReadOnly) {
+			break
+		}
+		if attempt < settings.retryAttempts && !be.retryBudget.Allow() {
+			lastErr = fmt.Errorf("%w after %d attempts: %v", resilience.ErrRetryBudgetExhausted, attempt+1, err)
+			break
+		}
 	}
 
 	// All retries failed
@@ -435,7 +653,7 @@ func (be *BatchExecutor) executeTask(ctx context.Context, task SyncTask) SyncRes
 		Success:  false,
 		Error:    lastErr,
 		Duration: duration,
-		Retries:  be.config.RetryAttempts,
+		Retries:  retries,
 	}
 }
 
@@ -479,17 +697,37 @@ func (be *BatchExecutor) getOrCreateClient(ctx context.Context, registryURL stri
 	return client, nil
 }
 
+// pushAliasTags points each alias tag at the manifest just pushed to destRef
+func (be *BatchExecutor) pushAliasTags(ctx context.Context, destRef name.Reference, aliases []string, destOpts []remote.Option) error {
+	opts := append(append([]remote.Option{}, destOpts...), remote.WithContext(ctx))
+	desc, err := remote.Get(destRef, opts...)
+	if err != nil {
+		return fmt.Errorf("failed to read pushed manifest for alias tags: %w", err)
+	}
+
+	for _, alias := range aliases {
+		tag := destRef.Context().Tag(alias)
+		if err := remote.Tag(tag, desc, opts...); err != nil {
+			return fmt.Errorf("failed to push alias tag %s: %w", tag, err)
+		}
+	}
+
+	be.logger.WithFields(map[string]interface{}{
+		"dest":    destRef.String(),
+		"digest":  desc.Digest.String(),
+		"aliases": aliases,
+	}).Debug("Pushed alias tags")
+	return nil
+}
+
 // syncImage performs the actual image synchronization using freightliner's copy infrastructure
 func (be *BatchExecutor) syncImage(ctx context.Context, task SyncTask) (int64, error) {
 	// Create source registry reference
 	srcImageRef := fmt.Sprintf("%s/%s:%s", task.SourceRegistry, task.SourceRepository, task.SourceTag)
 
-	// Create destination registry reference
-	dstImageRef := fmt.Sprintf("%s/%s:%s", task.DestRegistry, task.DestRepository, task.DestTag)
-
 	be.logger.WithFields(map[string]interface{}{
 		"source": srcImageRef,
-		"dest":   dstImageRef,
+		"dest":   fmt.Sprintf("%s/%s:%s", task.DestRegistry, task.DestRepository, task.DestTag),
 	}).Debug("Starting image synchronization")
 
 	// Parse source reference using go-containerregistry
@@ -498,49 +736,105 @@ func (be *BatchExecutor) syncImage(ctx context.Context, task SyncTask) (int64, e
 		return 0, fmt.Errorf("failed to parse source reference: %w", err)
 	}
 
-	// Parse destination reference
-	destRef, err := name.ParseReference(dstImageRef)
-	if err != nil {
-		return 0, fmt.Errorf("failed to parse destination reference: %w", err)
-	}
-
 	// Get or create source registry client (with caching)
 	srcClient, err := be.getOrCreateClient(ctx, task.SourceRegistry)
 	if err != nil {
 		return 0, fmt.Errorf("failed to get source registry client: %w", err)
 	}
 
-	// Get or create destination registry client (with caching)
-	destClient, err := be.getOrCreateClient(ctx, task.DestRegistry)
-	if err != nil {
-		return 0, fmt.Errorf("failed to get destination registry client: %w", err)
-	}
-
 	// Get source repository
 	sourceRepo, err := srcClient.GetRepository(ctx, task.SourceRepository)
 	if err != nil {
 		return 0, fmt.Errorf("failed to get source repository: %w", err)
 	}
 
-	// Get destination repository
-	destRepo, err := destClient.GetRepository(ctx, task.DestRepository)
-	if err != nil {
-		return 0, fmt.Errorf("failed to get destination repository: %w", err)
-	}
-
 	// Get remote options for authentication
 	srcOpts, err := sourceRepo.GetRemoteOptions()
 	if err != nil {
 		return 0, fmt.Errorf("failed to get source remote options: %w", err)
 	}
 
+	// Refuse to mirror images whose signatures do not verify
+	verified := false
+	if task.SignVerification != nil && task.SignVerification.Enabled {
+		if be.verifySignatures == nil {
+			return 0, fmt.Errorf("sign_verification is enabled but this build has no signature verification support")
+		}
+		if err := be.verifySignatures(ctx, sourceRef, task.SignVerification, srcOpts); err != nil {
+			return 0, fmt.Errorf("signature verification failed for %s: %w", srcImageRef, err)
+		}
+		verified = true
+	}
+
+	// Let the policy copy, skip or quarantine the image
+	if be.policy != nil {
+		input, err := be.policyInput(ctx, task, sourceRef, sourceRepo, srcOpts, verified)
+		if err != nil {
+			return 0, fmt.Errorf("failed to gather policy input for %s: %w", srcImageRef, err)
+		}
+		decision, err := be.policy.Evaluate(ctx, input)
+		if err != nil {
+			return 0, fmt.Errorf("policy evaluation failed for %s: %w", srcImageRef, err)
+		}
+
+		be.logger.WithFields(map[string]interface{}{
+			"source": srcImageRef,
+			"action": decision.Action,
+			"reason": decision.Reason,
+		}).Info("Policy decision")
+
+		switch decision.Action {
+		case policy.ActionSkip:
+			return 0, &PolicySkipError{Decision: decision}
+		case policy.ActionQuarantine:
+			task.DestRepository = be.config.Policy.QuarantinePrefix + task.DestRepository
+		}
+	}
+
+	// Create destination registry reference
+	dstImageRef := fmt.Sprintf("%s/%s:%s", task.DestRegistry, task.DestRepository, task.DestTag)
+
+	// Parse destination reference
+	destRef, err := name.ParseReference(dstImageRef)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse destination reference: %w", err)
+	}
+
+	// Get or create destination registry client (with caching)
+	destClient, err := be.getOrCreateClient(ctx, task.DestRegistry)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get destination registry client: %w", err)
+	}
+
+	// Leave images to a pull through cache that already mirrors them
+	if err := be.checkPullThroughCache(ctx, destClient, task); err != nil {
+		return 0, err
+	}
+
+	// Get destination repository, creating it if allowed
+	destRepo, err := be.destinationRepository(ctx, destClient, task)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get destination repository: %w", err)
+	}
+
 	destOpts, err := destRepo.GetRemoteOptions()
 	if err != nil {
 		return 0, fmt.Errorf("failed to get destination remote options: %w", err)
 	}
 
 	// Create copier instance
-	copier := copyutil.NewCopier(be.logger)
+	copier := copyutil.NewCopier(be.logger, copyutil.CopierOptions{
+		Ledger:        be.ledger,
+		RetryBudget:   be.retryBudget,
+		MaxRetries:    be.maxRetries,
+		Resigner:      be.resigner,
+		AnnotateRunID: be.annotateRunID,
+	})
+
+	transforms, err := transform.NewPipeline(task.Transforms)
+	if err != nil {
+		return 0, fmt.Errorf("invalid transform: %w", err)
+	}
 
 	// Prepare copy options
 	copyOptions := copyutil.CopyOptions{
@@ -548,6 +842,29 @@ func (be *BatchExecutor) syncImage(ctx context.Context, task SyncTask) (int64, e
 		ForceOverwrite: true,  // Sync should overwrite by default
 		Source:         sourceRef,
 		Destination:    destRef,
+		MediaTypes:     be.config.Destination.MediaTypes,
+		Loops:          be.config.Destination.LoopPrevention,
+		Transforms:     transforms,
+	}
+
+	// Keep the digests the tag pointed to before, from the source registry's
+	// tag history when it has one and from the destination tag otherwise
+	var history []interfaces.TagDigest
+	if task.TagHistory > 0 {
+		history = be.sourceTagHistory(ctx, task, sourceRepo)
+		if history == nil {
+			sourceDigest := task.SourceDigest
+			if sourceDigest == "" {
+				desc, err := remote.Head(sourceRef, append(srcOpts, remote.WithContext(ctx))...)
+				if err != nil {
+					return 0, fmt.Errorf("failed to look up source digest for tag history: %w", err)
+				}
+				sourceDigest = desc.Digest.String()
+			}
+			if err := rotateTagHistory(ctx, destRef, task.DestTag, sourceDigest, task.TagHistory, destOpts); err != nil {
+				return 0, err
+			}
+		}
 	}
 
 	// Execute the image copy operation
@@ -560,6 +877,20 @@ func (be *BatchExecutor) syncImage(ctx context.Context, task SyncTask) (int64, e
 		return 0, fmt.Errorf("image copy reported failure")
 	}
 
+	if len(history) > 0 {
+		if err := be.copyTagHistory(ctx, copier, task, history, sourceRef, destRef, srcOpts, destOpts, transforms); err != nil {
+			return 0, err
+		}
+	}
+
+	if len(task.AliasTags) > 0 {
+		if err := be.pushAliasTags(ctx, destRef, task.AliasTags, destOpts); err != nil {
+			return 0, err
+		}
+	}
+
+	be.copyRepoMetadata(ctx, task, sourceRepo, destRepo)
+
 	be.logger.WithFields(map[string]interface{}{
 		"source":            srcImageRef,
 		"dest":              dstImageRef,
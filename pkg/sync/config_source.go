@@ -0,0 +1,269 @@
+package sync
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	runtimeconfig "google.golang.org/api/runtimeconfig/v1beta1"
+	"gopkg.in/yaml.v3"
+)
+
+// Location schemes of sync configurations kept outside the filesystem
+const (
+	// AppConfigScheme reads appconfig://APPLICATION/ENVIRONMENT/PROFILE
+	// through the AWS AppConfig Agent
+	AppConfigScheme = "appconfig://"
+
+	// RuntimeConfigScheme reads the GCP Runtime Configurator variable
+	// runtimeconfig://PROJECT/CONFIG/VARIABLE
+	RuntimeConfigScheme = "runtimeconfig://"
+)
+
+// DefaultAppConfigAgent is the address the AWS AppConfig Agent and Lambda
+// extension listen on; FREIGHTLINER_APPCONFIG_AGENT overrides it
+const DefaultAppConfigAgent = "http://localhost:2772"
+
+// appConfigTimeout bounds each request to the AppConfig Agent
+const appConfigTimeout = 30 * time.Second
+
+// ConfigSource is where a sync configuration is read from
+type ConfigSource interface {
+	// Fetch returns the configuration's YAML
+	Fetch(ctx context.Context) ([]byte, error)
+
+	// String names the source in logs and errors
+	String() string
+}
+
+// NewConfigSource returns the source of the configuration at location: an
+// appconfig:// or runtimeconfig:// URI, or otherwise a file path
+func NewConfigSource(location string) (ConfigSource, error) {
+	switch {
+	case strings.HasPrefix(location, AppConfigScheme):
+		parts, err := splitConfigLocation(location, AppConfigScheme, "APPLICATION/ENVIRONMENT/PROFILE")
+		if err != nil {
+			return nil, err
+		}
+		agent := os.Getenv("FREIGHTLINER_APPCONFIG_AGENT")
+		if agent == "" {
+			agent = DefaultAppConfigAgent
+		}
+		return &AppConfigSource{
+			Agent:       strings.TrimRight(agent, "/"),
+			Application: parts[0],
+			Environment: parts[1],
+			Profile:     parts[2],
+		}, nil
+	case strings.HasPrefix(location, RuntimeConfigScheme):
+		parts, err := splitConfigLocation(location, RuntimeConfigScheme, "PROJECT/CONFIG/VARIABLE")
+		if err != nil {
+			return nil, err
+		}
+		return &RuntimeConfigSource{Project: parts[0], Config: parts[1], Variable: parts[2]}, nil
+	default:
+		return FileSource(location), nil
+	}
+}
+
+// splitConfigLocation splits a URI into its three path segments
+func splitConfigLocation(location, scheme, form string) ([]string, error) {
+	parts := strings.SplitN(strings.TrimPrefix(location, scheme), "/", 3)
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+		return nil, fmt.Errorf("invalid config location %q, must be %s%s", location, scheme, form)
+	}
+	return parts, nil
+}
+
+// FileSource reads the configuration from a YAML file
+type FileSource string
+
+// Fetch reads the file
+func (f FileSource) Fetch(ctx context.Context) ([]byte, error) {
+	data, err := os.ReadFile(string(f))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	return data, nil
+}
+
+func (f FileSource) String() string { return string(f) }
+
+// AppConfigSource reads the configuration from AWS AppConfig through the
+// AppConfig Agent, which holds the session, polls AppConfig and caches the
+// latest deployed version
+type AppConfigSource struct {
+	// Agent is the agent's base URL, e.g. DefaultAppConfigAgent
+	Agent string
+
+	Application string
+	Environment string
+	Profile     string
+
+	// Client sends the agent requests (default: a client with a timeout)
+	Client *http.Client
+}
+
+// Fetch asks the agent for the latest deployed configuration
+func (s *AppConfigSource) Fetch(ctx context.Context) ([]byte, error) {
+	endpoint := fmt.Sprintf("%s/applications/%s/environments/%s/configurations/%s", s.Agent,
+		url.PathEscape(s.Application), url.PathEscape(s.Environment), url.PathEscape(s.Profile))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create AppConfig request: %w", err)
+	}
+
+	client := s.Client
+	if client == nil {
+		client = &http.Client{Timeout: appConfigTimeout}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach AppConfig agent at %s: %w", s.Agent, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read AppConfig configuration %s: %w", s, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("AppConfig agent returned %s for %s: %s", resp.Status, s, strings.TrimSpace(string(data)))
+	}
+	return data, nil
+}
+
+func (s *AppConfigSource) String() string {
+	return AppConfigScheme + s.Application + "/" + s.Environment + "/" + s.Profile
+}
+
+// RuntimeConfigSource reads the configuration from a GCP Runtime
+// Configurator variable with Application Default Credentials
+type RuntimeConfigSource struct {
+	Project  string
+	Config   string
+	Variable string
+}
+
+// Fetch reads the variable's text, or its value when it holds bytes
+func (s *RuntimeConfigSource) Fetch(ctx context.Context) ([]byte, error) {
+	svc, err := runtimeconfig.NewService(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Runtime Configurator client: %w", err)
+	}
+
+	name := fmt.Sprintf("projects/%s/configs/%s/variables/%s", s.Project, s.Config, s.Variable)
+	variable, err := svc.Projects.Configs.Variables.Get(name).Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Runtime Configurator variable %s: %w", name, err)
+	}
+	if variable.Text != "" {
+		return []byte(variable.Text), nil
+	}
+	data, err := base64.StdEncoding.DecodeString(variable.Value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode Runtime Configurator variable %s: %w", name, err)
+	}
+	return data, nil
+}
+
+func (s *RuntimeConfigSource) String() string {
+	return RuntimeConfigScheme + s.Project + "/" + s.Config + "/" + s.Variable
+}
+
+// LoadConfigFromSource fetches, parses and validates a configuration like
+// LoadConfigWithProfile. The returned version changes whenever the fetched
+// YAML does.
+func LoadConfigFromSource(ctx context.Context, source ConfigSource, profile string) (*Config, string, error) {
+	data, err := source.Fetch(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	config, err := parseConfig(ctx, data, profile)
+	if err != nil {
+		return nil, "", err
+	}
+	return config, configVersion(data), nil
+}
+
+// parseConfig parses, expands and validates configuration YAML
+func parseConfig(ctx context.Context, data []byte, profile string) (*Config, error) {
+	var config Config
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse YAML: %w", err)
+	}
+	if profile != "" {
+		config.Profile = profile
+	}
+
+	// Expand generators that do not need a registry catalog
+	if err := config.ExpandGenerators(ctx, nil); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	// Validate configuration
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	// Set defaults
+	config.SetDefaults()
+
+	return &config, nil
+}
+
+// configVersion identifies configuration YAML by its hash
+func configVersion(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8])
+}
+
+// ConfigWatcher polls a source so configuration rollouts reach a running
+// process without a redeploy. Only configurations that changed and validate
+// are handed on; until then the previous one stays in effect.
+type ConfigWatcher struct {
+	Source   ConfigSource
+	Profile  string
+	Interval time.Duration
+
+	// OnChange receives each new valid configuration and its version
+	OnChange func(config *Config, version string)
+
+	// OnError receives fetch and validation errors (optional)
+	OnError func(err error)
+}
+
+// Run polls until ctx is done, starting from the configuration at version
+func (w *ConfigWatcher) Run(ctx context.Context, version string) {
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		config, next, err := LoadConfigFromSource(ctx, w.Source, w.Profile)
+		if err != nil {
+			if w.OnError != nil && ctx.Err() == nil {
+				w.OnError(fmt.Errorf("failed to reload %s: %w", w.Source, err))
+			}
+			continue
+		}
+		if next == version {
+			continue
+		}
+		version = next
+		w.OnChange(config, version)
+	}
+}
@@ -0,0 +1,112 @@
+package sync
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const sourceTestYAML = `
+source:
+  registry: "docker.io"
+destination:
+  registry: "my-registry.io"
+images:
+  - repository: "library/nginx"
+    tags: ["latest"]
+`
+
+func TestNewConfigSource(t *testing.T) {
+	t.Setenv("FREIGHTLINER_APPCONFIG_AGENT", "http://agent:2772/")
+
+	source, err := NewConfigSource("appconfig://freightliner/prod/sync")
+	require.NoError(t, err)
+	assert.Equal(t, &AppConfigSource{Agent: "http://agent:2772", Application: "freightliner", Environment: "prod", Profile: "sync"}, source)
+
+	source, err = NewConfigSource("runtimeconfig://my-project/freightliner/sync")
+	require.NoError(t, err)
+	assert.Equal(t, &RuntimeConfigSource{Project: "my-project", Config: "freightliner", Variable: "sync"}, source)
+
+	source, err = NewConfigSource("configs/sync.yaml")
+	require.NoError(t, err)
+	assert.Equal(t, FileSource("configs/sync.yaml"), source)
+
+	_, err = NewConfigSource("appconfig://freightliner/prod")
+	assert.ErrorContains(t, err, "APPLICATION/ENVIRONMENT/PROFILE")
+}
+
+func TestLoadConfigFromAppConfig(t *testing.T) {
+	agent := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/applications/freightliner/environments/prod/configurations/sync" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(sourceTestYAML))
+	}))
+	defer agent.Close()
+
+	source := &AppConfigSource{Agent: agent.URL, Application: "freightliner", Environment: "prod", Profile: "sync"}
+	config, version, err := LoadConfigFromSource(context.Background(), source, "")
+	require.NoError(t, err)
+	assert.Equal(t, "docker.io", config.Source.Registry)
+	assert.NotEmpty(t, version)
+
+	// The version only depends on the YAML
+	file := filepath.Join(t.TempDir(), "sync.yaml")
+	require.NoError(t, os.WriteFile(file, []byte(sourceTestYAML), 0644))
+	_, fileVersion, err := LoadConfigFromSource(context.Background(), FileSource(file), "")
+	require.NoError(t, err)
+	assert.Equal(t, version, fileVersion)
+
+	source.Profile = "missing"
+	_, _, err = LoadConfigFromSource(context.Background(), source, "")
+	assert.ErrorContains(t, err, "404")
+}
+
+func TestConfigWatcher(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "sync.yaml")
+	require.NoError(t, os.WriteFile(file, []byte(sourceTestYAML), 0644))
+	_, version, err := LoadConfigFromSource(context.Background(), FileSource(file), "")
+	require.NoError(t, err)
+
+	changes := make(chan *Config, 10)
+	errs := make(chan error, 10)
+	watcher := &ConfigWatcher{
+		Source:   FileSource(file),
+		Interval: 10 * time.Millisecond,
+		OnChange: func(config *Config, _ string) { changes <- config },
+		OnError:  func(err error) { errs <- err },
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go watcher.Run(ctx, version)
+
+	// An invalid rollout is reported and skipped
+	require.NoError(t, os.WriteFile(file, []byte("destination: {}\n"), 0644))
+	select {
+	case err := <-errs:
+		assert.ErrorContains(t, err, "invalid configuration")
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the invalid config to be reported")
+	}
+	assert.Empty(t, changes)
+
+	// A valid rollout is handed on once
+	require.NoError(t, os.WriteFile(file, []byte(strings.Replace(sourceTestYAML, "docker.io", "quay.io", 1)), 0644))
+	select {
+	case config := <-changes:
+		assert.Equal(t, "quay.io", config.Source.Registry)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the new config to be handed on")
+	}
+	time.Sleep(50 * time.Millisecond)
+	assert.Empty(t, changes)
+}
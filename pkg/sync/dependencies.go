@@ -0,0 +1,220 @@
+package sync
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"freightliner/pkg/helper/log"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// BaseImageAnnotation is the OCI annotation naming the image an image was
+// built from. It is read from the manifest annotations and the config labels.
+const BaseImageAnnotation = "org.opencontainers.image.base.name"
+
+// BaseImageLookup returns the base image reference the source image of task
+// records, or "" if it records none
+type BaseImageLookup func(ctx context.Context, task SyncTask) (string, error)
+
+// OrderByDependencies sets the Stage of every task so that the images of a
+// repository are copied after those of the repositories it depends on, and
+// returns the tasks sorted by stage. Dependencies on repositories without
+// tasks are ignored; a dependency cycle is an error.
+func OrderByDependencies(tasks []SyncTask) ([]SyncTask, error) {
+	deps := make(map[string]map[string]bool)
+	for _, task := range tasks {
+		if deps[task.SourceRepository] == nil {
+			deps[task.SourceRepository] = make(map[string]bool)
+		}
+	}
+	for _, task := range tasks {
+		for _, dep := range task.DependsOn {
+			if _, ok := deps[dep]; ok && dep != task.SourceRepository {
+				deps[task.SourceRepository][dep] = true
+			}
+		}
+	}
+
+	stages := make(map[string]int, len(deps))
+	visiting := make(map[string]bool)
+	var stageOf func(repository string, path []string) (int, error)
+	stageOf = func(repository string, path []string) (int, error) {
+		if stage, ok := stages[repository]; ok {
+			return stage, nil
+		}
+		path = append(path, repository)
+		if visiting[repository] {
+			return 0, fmt.Errorf("dependency cycle: %s", strings.Join(path, " -> "))
+		}
+		visiting[repository] = true
+
+		// Dependencies are visited in order so a cycle is reported the same way every time
+		names := make([]string, 0, len(deps[repository]))
+		for dep := range deps[repository] {
+			names = append(names, dep)
+		}
+		sort.Strings(names)
+
+		stage := 0
+		for _, dep := range names {
+			depStage, err := stageOf(dep, path)
+			if err != nil {
+				return 0, err
+			}
+			if depStage+1 > stage {
+				stage = depStage + 1
+			}
+		}
+		visiting[repository] = false
+		stages[repository] = stage
+		return stage, nil
+	}
+
+	ordered := make([]SyncTask, len(tasks))
+	for i, task := range tasks {
+		stage, err := stageOf(task.SourceRepository, nil)
+		if err != nil {
+			return nil, err
+		}
+		task.Stage = stage
+		ordered[i] = task
+	}
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return ordered[i].Stage < ordered[j].Stage
+	})
+	return ordered, nil
+}
+
+// InferDependencies adds the source repository of each task's base image, as
+// reported by lookup, to its dependencies. One image per repository is looked
+// up, and base images outside the source registry are ignored.
+func InferDependencies(ctx context.Context, tasks []SyncTask, lookup BaseImageLookup, logger log.Logger) {
+	inferred := make(map[string]string)
+	for _, task := range tasks {
+		if _, done := inferred[task.SourceRepository]; done {
+			continue
+		}
+		inferred[task.SourceRepository] = ""
+
+		base, err := lookup(ctx, task)
+		if err != nil {
+			logger.WithFields(map[string]interface{}{
+				"repository": task.SourceRepository,
+				"tag":        task.SourceTag,
+				"error":      err.Error(),
+			}).Warn("Failed to look up base image")
+			continue
+		}
+		if base == "" {
+			continue
+		}
+
+		ref, err := name.ParseReference(base)
+		if err != nil {
+			logger.WithFields(map[string]interface{}{
+				"repository": task.SourceRepository,
+				"base":       base,
+			}).Warn("Ignoring unparsable base image")
+			continue
+		}
+		registry, err := name.NewRegistry(task.SourceRegistry)
+		if err != nil || ref.Context().RegistryStr() != registry.RegistryStr() {
+			continue
+		}
+		if dep := ref.Context().RepositoryStr(); dep != task.SourceRepository {
+			inferred[task.SourceRepository] = dep
+			logger.WithFields(map[string]interface{}{
+				"repository": task.SourceRepository,
+				"base":       dep,
+			}).Debug("Inferred base image dependency")
+		}
+	}
+
+	for i := range tasks {
+		dep := inferred[tasks[i].SourceRepository]
+		if dep != "" && !slices.Contains(tasks[i].DependsOn, dep) {
+			tasks[i].DependsOn = append(tasks[i].DependsOn, dep)
+		}
+	}
+}
+
+// BaseImage returns the base image the source image of task records in its
+// BaseImageAnnotation, or "" if it records none. It is a BaseImageLookup.
+func (be *BatchExecutor) BaseImage(ctx context.Context, task SyncTask) (string, error) {
+	sourceRef, err := name.ParseReference(fmt.Sprintf("%s/%s:%s", task.SourceRegistry, task.SourceRepository, task.SourceTag))
+	if err != nil {
+		return "", fmt.Errorf("failed to parse source reference: %w", err)
+	}
+	srcClient, err := be.getOrCreateClient(ctx, task.SourceRegistry)
+	if err != nil {
+		return "", fmt.Errorf("failed to get source registry client: %w", err)
+	}
+	sourceRepo, err := srcClient.GetRepository(ctx, task.SourceRepository)
+	if err != nil {
+		return "", fmt.Errorf("failed to get source repository: %w", err)
+	}
+	srcOpts, err := sourceRepo.GetRemoteOptions()
+	if err != nil {
+		return "", fmt.Errorf("failed to get source remote options: %w", err)
+	}
+
+	desc, err := remote.Get(sourceRef, append(srcOpts, remote.WithContext(ctx))...)
+	if err != nil {
+		return "", err
+	}
+
+	var manifest struct {
+		Annotations map[string]string `json:"annotations"`
+	}
+	if err := json.Unmarshal(desc.Manifest, &manifest); err == nil && manifest.Annotations[BaseImageAnnotation] != "" {
+		return manifest.Annotations[BaseImageAnnotation], nil
+	}
+
+	// Labels come from the image copied for an index: the default platform
+	img, err := desc.Image()
+	if err != nil {
+		return "", nil
+	}
+	cfg, err := img.ConfigFile()
+	if err != nil {
+		return "", fmt.Errorf("failed to read image config: %w", err)
+	}
+	return cfg.Config.Labels[BaseImageAnnotation], nil
+}
+
+// skipBlockedTasks moves the tasks depending on a repository in failed to the
+// end of tasks and records them as skipped from position offset. It returns
+// the number of tasks left to run.
+func (be *BatchExecutor) skipBlockedTasks(tasks []SyncTask, offset int, failed map[string]bool) int {
+	runnable := make([]SyncTask, 0, len(tasks))
+	var blocked []SyncResult
+	for _, task := range tasks {
+		reason := ""
+		for _, dep := range task.DependsOn {
+			if failed[dep] {
+				reason = fmt.Sprintf("base repository %s was not fully synced", dep)
+				break
+			}
+		}
+		if reason == "" {
+			runnable = append(runnable, task)
+			continue
+		}
+		blocked = append(blocked, SyncResult{Task: task, Skipped: true, SkipReason: reason})
+	}
+
+	copy(tasks, runnable)
+	be.mu.Lock()
+	for i, result := range blocked {
+		tasks[len(runnable)+i] = result.Task
+		be.results[offset+len(runnable)+i] = result
+	}
+	be.mu.Unlock()
+	return len(runnable)
+}
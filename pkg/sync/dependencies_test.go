@@ -0,0 +1,77 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"freightliner/pkg/helper/log"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOrderByDependencies(t *testing.T) {
+	tasks := []SyncTask{
+		{SourceRepository: "apps/web", SourceTag: "v1", DependsOn: []string{"base/node"}},
+		{SourceRepository: "base/node", SourceTag: "20", DependsOn: []string{"base/debian", "missing/repo"}},
+		{SourceRepository: "tools/cli", SourceTag: "v2"},
+		{SourceRepository: "base/debian", SourceTag: "12"},
+		{SourceRepository: "apps/web", SourceTag: "v2"},
+	}
+
+	ordered, err := OrderByDependencies(tasks)
+	require.NoError(t, err)
+
+	var order []string
+	for _, task := range ordered {
+		order = append(order, fmt.Sprintf("%d %s:%s", task.Stage, task.SourceRepository, task.SourceTag))
+	}
+	assert.Equal(t, []string{
+		"0 tools/cli:v2",
+		"0 base/debian:12",
+		"1 base/node:20",
+		"2 apps/web:v1",
+		"2 apps/web:v2",
+	}, order)
+	assert.Zero(t, tasks[0].Stage, "input tasks are not modified")
+}
+
+func TestOrderByDependencies_Cycle(t *testing.T) {
+	_, err := OrderByDependencies([]SyncTask{
+		{SourceRepository: "a", DependsOn: []string{"b"}},
+		{SourceRepository: "b", DependsOn: []string{"c"}},
+		{SourceRepository: "c", DependsOn: []string{"a"}},
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "dependency cycle: a -> b -> c -> a")
+}
+
+func TestInferDependencies(t *testing.T) {
+	tasks := []SyncTask{
+		{SourceRegistry: "docker.io", SourceRepository: "apps/web", SourceTag: "v1"},
+		{SourceRegistry: "docker.io", SourceRepository: "apps/web", SourceTag: "v2"},
+		{SourceRegistry: "docker.io", SourceRepository: "apps/api", SourceTag: "v1"},
+		{SourceRegistry: "docker.io", SourceRepository: "apps/worker", SourceTag: "v1"},
+		{SourceRegistry: "docker.io", SourceRepository: "library/node", SourceTag: "20"},
+	}
+	bases := map[string]string{
+		"apps/web":    "docker.io/library/node:20",
+		"apps/api":    "ghcr.io/other/base:1",
+		"apps/worker": "node:20",
+	}
+
+	lookups := 0
+	lookup := func(ctx context.Context, task SyncTask) (string, error) {
+		lookups++
+		return bases[task.SourceRepository], nil
+	}
+	InferDependencies(context.Background(), tasks, lookup, log.NewBasicLogger(log.ErrorLevel))
+
+	assert.Equal(t, 4, lookups, "one lookup per repository")
+	assert.Equal(t, []string{"library/node"}, tasks[0].DependsOn)
+	assert.Equal(t, []string{"library/node"}, tasks[1].DependsOn)
+	assert.Empty(t, tasks[2].DependsOn, "base images of other registries are ignored")
+	assert.Equal(t, []string{"library/node"}, tasks[3].DependsOn)
+	assert.Empty(t, tasks[4].DependsOn)
+}
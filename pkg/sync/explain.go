@@ -0,0 +1,95 @@
+package sync
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FilterDecision records why a tag was included or excluded by a filter
+type FilterDecision struct {
+	Tag      string
+	Included bool
+
+	// Rule is the ImageSync field that decided, e.g. "tag_regex"
+	Rule   string
+	Reason string
+}
+
+// Explain evaluates tags like Filter and returns a decision for every tag.
+// Decisions follow the input order; semver matches are not re-sorted.
+func (f *TagFilter) Explain(tags []string) []FilterDecision {
+	decisions := make([]FilterDecision, 0, len(tags))
+	for i, tag := range tags {
+		decision := FilterDecision{Tag: tag}
+
+		switch {
+		case f.tags != nil:
+			decision.Rule = "tags"
+			decision.Included = f.tags[tag]
+			if decision.Included {
+				decision.Reason = "tag is listed"
+			} else {
+				decision.Reason = "tag is not listed"
+			}
+		case f.regex != nil:
+			decision.Rule = "tag_regex"
+			decision.Included = f.regex.MatchString(tag)
+			if decision.Included {
+				decision.Reason = fmt.Sprintf("tag matches %q", f.regex.String())
+			} else {
+				decision.Reason = fmt.Sprintf("tag does not match %q", f.regex.String())
+			}
+		case f.semver != nil:
+			decision.Rule = "semver_constraint"
+			decision.Included, decision.Reason = f.semver.explain(tag)
+		case f.allTags:
+			decision.Rule = "all_tags"
+			decision.Included = true
+			decision.Reason = "all tags are selected"
+		case f.latestN > 0:
+			decision.Rule = "latest_n"
+			decision.Included = i < f.latestN
+			if decision.Included {
+				decision.Reason = fmt.Sprintf("tag is within the first %d listed", f.latestN)
+			} else {
+				decision.Reason = fmt.Sprintf("tag is beyond the first %d listed", f.latestN)
+			}
+		}
+
+		decisions = append(decisions, decision)
+	}
+	return decisions
+}
+
+// ExplainLimit returns a decision for every tag as ApplyLimit would treat it
+func ExplainLimit(tags []string, limit int) []FilterDecision {
+	decisions := make([]FilterDecision, len(tags))
+	for i, tag := range tags {
+		decisions[i] = FilterDecision{Tag: tag, Rule: "limit", Included: limit <= 0 || i < limit}
+		if decisions[i].Included {
+			decisions[i].Reason = "tag is within the limit"
+		} else {
+			decisions[i].Reason = fmt.Sprintf("tag is beyond the limit of %d", limit)
+		}
+	}
+	return decisions
+}
+
+// explain reports whether tag satisfies the constraint and why
+func (f *SemverFilter) explain(tag string) (bool, string) {
+	v, err := f.parseVersion(tag)
+	if err != nil {
+		return false, "tag is not a semantic version"
+	}
+
+	ok, errs := f.constraint.Validate(v)
+	if ok {
+		return true, fmt.Sprintf("version %s satisfies %s", v, f.constraint)
+	}
+
+	reasons := make([]string, len(errs))
+	for i, err := range errs {
+		reasons[i] = err.Error()
+	}
+	return false, strings.Join(reasons, "; ")
+}
@@ -1266,3 +1266,68 @@ func TestApplyArchitectureFilter_AllMediaTypes(t *testing.T) {
 	require.NoError(t, err)
 	assert.ElementsMatch(t, []string{"docker-list", "docker-single"}, result)
 }
+
+func TestTagFilterExplain(t *testing.T) {
+	tags := []string{"v1.2.0", "v2.0.0", "latest"}
+
+	tests := []struct {
+		name     string
+		image    ImageSync
+		rule     string
+		included []bool
+	}{
+		{
+			name:     "specific tags",
+			image:    ImageSync{Tags: []string{"latest"}},
+			rule:     "tags",
+			included: []bool{false, false, true},
+		},
+		{
+			name:     "regex",
+			image:    ImageSync{TagRegex: `^v1\.`},
+			rule:     "tag_regex",
+			included: []bool{true, false, false},
+		},
+		{
+			name:     "semver",
+			image:    ImageSync{SemverConstraint: ">=1.0.0 <2.0.0"},
+			rule:     "semver_constraint",
+			included: []bool{true, false, false},
+		},
+		{
+			name:     "latest n",
+			image:    ImageSync{LatestN: 2},
+			rule:     "latest_n",
+			included: []bool{true, true, false},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := NewTagFilter(tt.image)
+			require.NoError(t, err)
+
+			decisions := filter.Explain(tags)
+			require.Len(t, decisions, len(tags))
+			for i, decision := range decisions {
+				assert.Equal(t, tags[i], decision.Tag)
+				assert.Equal(t, tt.rule, decision.Rule)
+				assert.Equal(t, tt.included[i], decision.Included, decision.Reason)
+				assert.NotEmpty(t, decision.Reason)
+			}
+		})
+	}
+}
+
+func TestSemverFilterExplainReasons(t *testing.T) {
+	filter, err := NewSemverFilter("^1.0.0")
+	require.NoError(t, err)
+
+	ok, reason := filter.explain("latest")
+	assert.False(t, ok)
+	assert.Contains(t, reason, "not a semantic version")
+
+	ok, reason = filter.explain("2.1.0")
+	assert.False(t, ok)
+	assert.Contains(t, reason, "2.1.0")
+}
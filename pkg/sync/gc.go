@@ -0,0 +1,142 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"freightliner/pkg/client"
+	"freightliner/pkg/interfaces"
+)
+
+// DefaultRegistryConfigPath is where the registry:2 image reads its config
+const DefaultRegistryConfigPath = "/etc/docker/registry/config.yml"
+
+// GarbageCollectionConfig decides what happens after prunes remove tags.
+// Deleting tags only unlinks manifests; registries such as Harbor and
+// registry:2 keep the blobs until their garbage collection runs.
+type GarbageCollectionConfig struct {
+	// Trigger starts the collection on registries with an API for it
+	// (Harbor, which needs administrator credentials). Otherwise, and on
+	// registry:2, the exact command to run is logged and reported.
+	Trigger bool `yaml:"trigger,omitempty"`
+
+	// DeleteUntagged also removes manifests left without tags
+	DeleteUntagged bool `yaml:"delete_untagged,omitempty"`
+
+	// RegistryConfig is the registry:2 config path used in the reminder
+	// (default: DefaultRegistryConfigPath)
+	RegistryConfig string `yaml:"registry_config,omitempty"`
+}
+
+// GarbageCollectionResult records the garbage collection step of a prune
+type GarbageCollectionResult struct {
+	Registry string `json:"registry"`
+
+	// Triggered is set once the registry accepted a collection run
+	Triggered bool   `json:"triggered"`
+	RunID     string `json:"run_id,omitempty"`
+
+	// Reminder is the command to run when the collection was not triggered
+	Reminder string `json:"reminder,omitempty"`
+
+	// ReclaimableBytes sums the estimates of the prune results
+	ReclaimableBytes int64 `json:"reclaimable_bytes"`
+
+	Error string `json:"error,omitempty"`
+}
+
+// CollectGarbage starts or reminds about the destination registry's garbage
+// collection when results removed tags. It returns nil when there is nothing
+// to do: no configuration, only dry runs, or a registry that reclaims space
+// by itself (ECR, GCR, Docker Hub and other hosted registries).
+func (p *Pruner) CollectGarbage(ctx context.Context, factory *client.Factory, cfg *Config, results []*PruneResult) (*GarbageCollectionResult, error) {
+	gc := cfg.GarbageCollection
+	if gc == nil {
+		return nil, nil
+	}
+
+	var removed int
+	var reclaimable int64
+	for _, result := range results {
+		if result == nil || result.DryRun {
+			continue
+		}
+		for _, decision := range result.Removed {
+			// Tags moved to a trash still hold their blobs
+			if decision.Trash == "" {
+				removed++
+			}
+		}
+		reclaimable += result.ReclaimableBytes
+	}
+	if removed == 0 {
+		return nil, nil
+	}
+
+	registryClient, err := factory.CreateClientForRegistry(ctx, cfg.Destination.Registry)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create client for registry %s: %w", cfg.Destination.Registry, err)
+	}
+
+	result := &GarbageCollectionResult{Registry: cfg.Destination.Registry, ReclaimableBytes: reclaimable}
+	collector, ok := registryClient.(interfaces.GarbageCollector)
+	switch {
+	case ok:
+		result.Reminder = harborGCCommand(registryClient.GetRegistryName(), gc.DeleteUntagged)
+	case cfg.Destination.Type == "generic":
+		result.Reminder = distributionGCCommand(gc)
+	default:
+		return nil, nil
+	}
+
+	fields := map[string]interface{}{
+		"registry":          result.Registry,
+		"removed":           removed,
+		"reclaimable_bytes": reclaimable,
+	}
+	if ok && gc.Trigger {
+		runID, err := collector.StartGarbageCollection(ctx, gc.DeleteUntagged)
+		if err == nil {
+			result.Triggered = true
+			result.RunID = runID
+			result.Reminder = ""
+			fields["run_id"] = runID
+			p.logger.WithFields(fields).Info("Started registry garbage collection")
+			return result, nil
+		}
+		result.Error = err.Error()
+		fields["error"] = err.Error()
+		p.logger.WithFields(fields).Warn("Failed to start registry garbage collection")
+	}
+
+	fields["command"] = result.Reminder
+	p.logger.WithFields(fields).Info("Pruned tags keep their storage until the registry collects garbage")
+	if result.Error != "" {
+		return result, fmt.Errorf("failed to start garbage collection on %s: %s", result.Registry, result.Error)
+	}
+	return result, nil
+}
+
+// harborGCCommand is the API call starting a Harbor garbage collection, also
+// available under Administration > Clean Up
+func harborGCCommand(registry string, deleteUntagged bool) string {
+	host := strings.TrimSuffix(strings.TrimPrefix(strings.TrimPrefix(registry, "https://"), "http://"), "/")
+	return fmt.Sprintf(`curl -u ADMIN_USER -X POST -H "Content-Type: application/json" `+
+		`-d '{"schedule":{"type":"Manual"},"parameters":{"delete_untagged":%t}}' `+
+		`https://%s/api/v2.0/system/gc/schedule`, deleteUntagged, host)
+}
+
+// distributionGCCommand is the registry:2 garbage collection command, run in
+// the registry's container, ideally while it is read-only
+func distributionGCCommand(gc *GarbageCollectionConfig) string {
+	configPath := gc.RegistryConfig
+	if configPath == "" {
+		configPath = DefaultRegistryConfigPath
+	}
+	command := "registry garbage-collect " + configPath
+	if gc.DeleteUntagged {
+		command += " --delete-untagged"
+	}
+	return command
+}
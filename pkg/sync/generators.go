@@ -0,0 +1,299 @@
+package sync
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+
+	"freightliner/pkg/client"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Catalogs a generator can list repositories from
+const (
+	CatalogSource      = "source"
+	CatalogDestination = "destination"
+)
+
+// Generator expands into one image rule, prune rule or both for every
+// repository matching a pattern, so many similar repositories share one entry.
+// String fields of the templates may use the GeneratorVars, e.g.
+// destination_repository: "mirror/{{.Repository}}".
+type Generator struct {
+	// ForEachRepoMatching selects repositories: "*" matches within a path
+	// segment, "**" across segments, and a trailing "/" matches every
+	// repository below, e.g. "team-*/"
+	ForEachRepoMatching string `yaml:"for_each_repo_matching"`
+
+	// Repositories are matched instead of listing the registry's catalog
+	Repositories []string `yaml:"repositories,omitempty"`
+
+	// Catalog is the registry listed for repositories, "source" (default) or
+	// "destination"
+	Catalog string `yaml:"catalog,omitempty"`
+
+	// Image is the template of the generated image rules. Repository
+	// defaults to "{{.Repository}}".
+	Image *ImageSync `yaml:"image,omitempty"`
+
+	// Prune is the template of the generated prune rules. Repository
+	// defaults to "{{.Repository}}".
+	Prune *PruneRule `yaml:"prune,omitempty"`
+}
+
+// GeneratorVars are the template variables of a generated rule
+type GeneratorVars struct {
+	// Repository is the matched repository, e.g. "team-a/api"
+	Repository string
+
+	// Namespace is the repository path before its last segment, e.g. "team-a"
+	Namespace string
+
+	// Name is the last segment of the repository path, e.g. "api"
+	Name string
+
+	// Match holds the text matched by each wildcard of the pattern, e.g.
+	// {{index .Match 0}} is "a" for "team-*/"
+	Match []string
+}
+
+// RepositoryLister lists the repositories of registry starting with prefix
+type RepositoryLister func(ctx context.Context, registry *RegistryConfig, prefix string) ([]string, error)
+
+// Validate checks the generator's pattern and templates
+func (g Generator) Validate() error {
+	if g.ForEachRepoMatching == "" {
+		return fmt.Errorf("for_each_repo_matching is required")
+	}
+	if _, err := g.pattern(); err != nil {
+		return err
+	}
+	if g.Catalog != "" && g.Catalog != CatalogSource && g.Catalog != CatalogDestination {
+		return fmt.Errorf("catalog must be %q or %q, got %q", CatalogSource, CatalogDestination, g.Catalog)
+	}
+	if g.Image == nil && g.Prune == nil {
+		return fmt.Errorf("an image or prune template is required")
+	}
+	for _, tmpl := range []interface{}{g.Image, g.Prune} {
+		data, err := yaml.Marshal(tmpl)
+		if err != nil {
+			return fmt.Errorf("failed to encode template: %w", err)
+		}
+		if _, err := parseRuleTemplate(string(data)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// NeedsCatalog reports whether expanding the generator lists a registry
+func (g Generator) NeedsCatalog() bool {
+	return len(g.Repositories) == 0
+}
+
+// ExpandGenerators replaces the generators with the rules they produce. Rules
+// of generators with fixed repositories are produced without list; the
+// others need it to list their catalog, or are left in place if it is nil.
+// A generated rule is dropped when an explicit rule names the same
+// repository, so single repositories can still be configured differently.
+func (c *Config) ExpandGenerators(ctx context.Context, list RepositoryLister) error {
+	explicitImages := make(map[string]bool, len(c.Images))
+	for _, img := range c.Images {
+		explicitImages[img.Repository] = true
+	}
+	explicitPrune := make(map[string]bool, len(c.Prune))
+	for _, rule := range c.Prune {
+		explicitPrune[rule.Repository] = true
+	}
+
+	var pending []Generator
+	generated := false
+	for i, g := range c.Generators {
+		repositories := g.Repositories
+		if g.NeedsCatalog() {
+			if list == nil {
+				pending = append(pending, g)
+				continue
+			}
+
+			registry := &c.Source
+			if g.Catalog == CatalogDestination {
+				registry = &c.Destination
+			}
+			var err error
+			repositories, err = list(ctx, registry, g.literalPrefix())
+			if err != nil {
+				return fmt.Errorf("generators[%d]: failed to list repositories of %s: %w", i, registry.Registry, err)
+			}
+		}
+
+		images, prune, err := g.expand(repositories)
+		if err != nil {
+			return fmt.Errorf("generators[%d]: %w", i, err)
+		}
+		for _, img := range images {
+			if !explicitImages[img.Repository] {
+				c.Images = append(c.Images, img)
+				generated = true
+			}
+		}
+		for _, rule := range prune {
+			if !explicitPrune[rule.Repository] {
+				c.Prune = append(c.Prune, rule)
+				generated = true
+			}
+		}
+	}
+	c.Generators = pending
+
+	if generated {
+		if err := c.Validate(); err != nil {
+			return fmt.Errorf("generated rules: %w", err)
+		}
+	}
+	return nil
+}
+
+// NewRepositoryLister lists repositories with clients from factory
+func NewRepositoryLister(factory *client.Factory) RepositoryLister {
+	return func(ctx context.Context, registry *RegistryConfig, prefix string) ([]string, error) {
+		registryClient, err := factory.CreateClientForRegistry(ctx, registry.Registry)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create registry client: %w", err)
+		}
+		return registryClient.ListRepositories(ctx, prefix)
+	}
+}
+
+// expand renders the templates for each matching repository, in order
+func (g Generator) expand(repositories []string) ([]ImageSync, []PruneRule, error) {
+	pattern, err := g.pattern()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sorted := append([]string(nil), repositories...)
+	sort.Strings(sorted)
+
+	var images []ImageSync
+	var prune []PruneRule
+	for i, repository := range sorted {
+		if i > 0 && repository == sorted[i-1] {
+			continue
+		}
+		match := pattern.FindStringSubmatch(repository)
+		if match == nil {
+			continue
+		}
+
+		vars := GeneratorVars{
+			Repository: repository,
+			Namespace:  path.Dir(repository),
+			Name:       path.Base(repository),
+			Match:      match[1:],
+		}
+		if vars.Namespace == "." {
+			vars.Namespace = ""
+		}
+
+		if g.Image != nil {
+			tmpl := *g.Image
+			if tmpl.Repository == "" {
+				tmpl.Repository = "{{.Repository}}"
+			}
+			img, err := renderRule(tmpl, vars)
+			if err != nil {
+				return nil, nil, fmt.Errorf("image template for %s: %w", repository, err)
+			}
+			images = append(images, img)
+		}
+		if g.Prune != nil {
+			tmpl := *g.Prune
+			if tmpl.Repository == "" {
+				tmpl.Repository = "{{.Repository}}"
+			}
+			rule, err := renderRule(tmpl, vars)
+			if err != nil {
+				return nil, nil, fmt.Errorf("prune template for %s: %w", repository, err)
+			}
+			prune = append(prune, rule)
+		}
+	}
+	return images, prune, nil
+}
+
+// pattern compiles ForEachRepoMatching into a regular expression with a group
+// per wildcard
+func (g Generator) pattern() (*regexp.Regexp, error) {
+	glob := g.ForEachRepoMatching
+	var expr strings.Builder
+	expr.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		switch {
+		case strings.HasPrefix(glob[i:], "**"):
+			expr.WriteString("(.*)")
+			i++
+		case glob[i] == '*':
+			expr.WriteString("([^/]*)")
+		case glob[i] == '/' && i == len(glob)-1:
+			expr.WriteString("/(.+)")
+		default:
+			expr.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		}
+	}
+	expr.WriteString("$")
+
+	pattern, err := regexp.Compile(expr.String())
+	if err != nil {
+		return nil, fmt.Errorf("invalid for_each_repo_matching %q: %w", glob, err)
+	}
+	return pattern, nil
+}
+
+// literalPrefix returns the pattern up to its first wildcard, used to narrow
+// the catalog listing
+func (g Generator) literalPrefix() string {
+	if i := strings.Index(g.ForEachRepoMatching, "*"); i >= 0 {
+		return g.ForEachRepoMatching[:i]
+	}
+	return g.ForEachRepoMatching
+}
+
+// renderRule executes the templates in the string fields of rule. The rule is
+// rendered as YAML, so every field takes templates without listing them, and
+// the result shares nothing with the template.
+func renderRule[T any](rule T, vars GeneratorVars) (T, error) {
+	var rendered T
+	data, err := yaml.Marshal(rule)
+	if err != nil {
+		return rendered, fmt.Errorf("failed to encode template: %w", err)
+	}
+	tmpl, err := parseRuleTemplate(string(data))
+	if err != nil {
+		return rendered, err
+	}
+
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, vars); err != nil {
+		return rendered, fmt.Errorf("failed to render template: %w", err)
+	}
+	if err := yaml.Unmarshal(out.Bytes(), &rendered); err != nil {
+		return rendered, fmt.Errorf("rendered rule is not valid: %w", err)
+	}
+	return rendered, nil
+}
+
+// parseRuleTemplate parses a rule rendered as YAML as a template
+func parseRuleTemplate(text string) (*template.Template, error) {
+	tmpl, err := template.New("rule").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid template: %w", err)
+	}
+	return tmpl, nil
+}
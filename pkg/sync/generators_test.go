@@ -0,0 +1,126 @@
+package sync
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadConfig_ExpandsGenerators(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "sync.yaml")
+	require.NoError(t, os.WriteFile(configPath, []byte(`
+source:
+  registry: docker.io
+destination:
+  registry: gcr.io
+images:
+  - repository: team-b/api
+    tags: ["stable"]
+generators:
+  - for_each_repo_matching: "team-*/"
+    repositories: [team-a/api, team-a/web, team-b/api, other/api]
+    image:
+      latest_n: 5
+      destination_repository: "mirror/{{index .Match 0}}/{{.Name}}"
+    prune:
+      repository: "mirror/{{.Namespace}}/{{.Name}}"
+      keep_last: 10
+`), 0644))
+
+	config, err := LoadConfig(configPath)
+	require.NoError(t, err)
+	assert.Empty(t, config.Generators)
+
+	// The explicit rule for team-b/api wins over the generated one
+	require.Len(t, config.Images, 3)
+	assert.Equal(t, []string{"stable"}, config.Images[0].Tags)
+	assert.Equal(t, "team-a/api", config.Images[1].Repository)
+	assert.Equal(t, "mirror/a/api", config.Images[1].DestinationRepository)
+	assert.Equal(t, 5, config.Images[1].LatestN)
+	assert.Equal(t, "mirror/a/web", config.Images[2].DestinationRepository)
+
+	require.Len(t, config.Prune, 3)
+	assert.Equal(t, "mirror/team-b/api", config.Prune[2].Repository)
+	assert.Equal(t, 10, config.Prune[2].KeepLast)
+}
+
+func TestConfig_ExpandGenerators_Catalog(t *testing.T) {
+	config := &Config{
+		Source:      RegistryConfig{Registry: "docker.io"},
+		Destination: RegistryConfig{Registry: "gcr.io"},
+		Generators: []Generator{{
+			ForEachRepoMatching: "team-*/**-svc",
+			Image:               &ImageSync{AllTags: true},
+		}},
+	}
+
+	// Catalog generators wait for a lister
+	require.NoError(t, config.ExpandGenerators(context.Background(), nil))
+	assert.Len(t, config.Generators, 1)
+	assert.Empty(t, config.Images)
+
+	var listed []string
+	lister := func(ctx context.Context, registry *RegistryConfig, prefix string) ([]string, error) {
+		listed = append(listed, registry.Registry+" "+prefix)
+		return []string{"team-a/billing-svc", "team-a/deep/auth-svc", "team-a/web", "team/x-svc"}, nil
+	}
+	require.NoError(t, config.ExpandGenerators(context.Background(), lister))
+	assert.Equal(t, []string{"docker.io team-"}, listed)
+	assert.Empty(t, config.Generators)
+
+	var repositories []string
+	for _, img := range config.Images {
+		repositories = append(repositories, img.Repository)
+		assert.True(t, img.AllTags)
+	}
+	assert.Equal(t, []string{"team-a/billing-svc", "team-a/deep/auth-svc"}, repositories)
+}
+
+func TestGenerator_Validate(t *testing.T) {
+	tests := []struct {
+		name      string
+		generator Generator
+		wantErr   string
+	}{
+		{
+			name:      "missing pattern",
+			generator: Generator{Image: &ImageSync{AllTags: true}},
+			wantErr:   "for_each_repo_matching is required",
+		},
+		{
+			name:      "missing template",
+			generator: Generator{ForEachRepoMatching: "team-*/"},
+			wantErr:   "an image or prune template is required",
+		},
+		{
+			name:      "unknown catalog",
+			generator: Generator{ForEachRepoMatching: "team-*/", Catalog: "mirror", Image: &ImageSync{AllTags: true}},
+			wantErr:   "catalog must be",
+		},
+		{
+			name:      "broken template",
+			generator: Generator{ForEachRepoMatching: "team-*/", Image: &ImageSync{AllTags: true, DestinationRepository: "{{.Name"}},
+			wantErr:   "invalid template",
+		},
+		{
+			name:      "valid",
+			generator: Generator{ForEachRepoMatching: "team-*/", Prune: &PruneRule{KeepLast: 3}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.generator.Validate()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
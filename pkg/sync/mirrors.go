@@ -0,0 +1,116 @@
+package sync
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Mirror is a source and destination pair of a sync configuration beyond the
+// top-level one, so one file can hold every mirror rule of an organization
+type Mirror struct {
+	// Name identifies the pair in logs and names its state file
+	Name string `yaml:"name"`
+
+	// Source registry configuration
+	Source RegistryConfig `yaml:"source"`
+
+	// Destination registry configuration
+	Destination RegistryConfig `yaml:"destination"`
+
+	// Images to sync with filtering rules
+	Images []ImageSync `yaml:"images"`
+}
+
+// Pair is one source and destination pair of a configuration, ready to sync
+type Pair struct {
+	// Name is empty for the top-level pair and the mirror's name otherwise
+	Name string
+
+	// Config holds the pair's registries and images with the shared settings
+	Config *Config
+}
+
+// Pairs returns the pairs with image rules: the top-level pair, then every
+// mirror in order. Prune rules, generators and garbage collection stay with
+// the top-level pair.
+func (c *Config) Pairs() []Pair {
+	var pairs []Pair
+	if len(c.Images) > 0 {
+		top := *c
+		top.Mirrors = nil
+		pairs = append(pairs, Pair{Config: &top})
+	}
+	for _, mirror := range c.Mirrors {
+		pairs = append(pairs, Pair{Name: mirror.Name, Config: c.mirrorConfig(mirror)})
+	}
+	return pairs
+}
+
+// mirrorConfig returns the configuration syncing mirror with the shared settings of c
+func (c *Config) mirrorConfig(mirror Mirror) *Config {
+	pair := *c
+	pair.Source = mirror.Source
+	pair.Destination = mirror.Destination
+	pair.Images = mirror.Images
+	pair.Mirrors = nil
+	pair.Prune = nil
+	pair.Generators = nil
+	pair.GarbageCollection = nil
+	return &pair
+}
+
+// topLevelEmpty reports whether the top-level pair has no rules of any kind
+func (c *Config) topLevelEmpty() bool {
+	return len(c.Images) == 0 && len(c.Prune) == 0 && len(c.Generators) == 0
+}
+
+// validateMirrors validates every mirror as a configuration of its own
+func (c *Config) validateMirrors() error {
+	names := make(map[string]bool, len(c.Mirrors))
+	for i, mirror := range c.Mirrors {
+		if mirror.Name == "" {
+			return fmt.Errorf("mirrors[%d].name is required", i)
+		}
+		if strings.ContainsAny(mirror.Name, `/\ `) {
+			return fmt.Errorf("mirrors[%d].name must not contain slashes or spaces", i)
+		}
+		if names[mirror.Name] {
+			return fmt.Errorf("mirrors[%d]: duplicate name %q", i, mirror.Name)
+		}
+		names[mirror.Name] = true
+
+		if len(mirror.Images) == 0 {
+			return fmt.Errorf("mirrors[%d]: at least one image must be specified", i)
+		}
+		if err := c.mirrorConfig(mirror).Validate(); err != nil {
+			return fmt.Errorf("mirrors[%d]: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// MirrorStatePath returns the state file of a mirror, kept next to the state
+// file of the top-level pair so rules of different pairs never share history:
+// sync.state.json uses sync.state.<mirror>.json
+func MirrorStatePath(statePath, mirror string) string {
+	if mirror == "" {
+		return statePath
+	}
+	return strings.TrimSuffix(statePath, ".json") + "." + mirror + ".json"
+}
+
+// Due reports whether a rule that last ran at lastRun should run at now, and
+// when it comes due otherwise. Rules without a schedule, and rules that never
+// ran, are always due.
+func (img ImageSync) Due(lastRun, now time.Time) (bool, time.Time, error) {
+	if img.Schedule == "" || lastRun.IsZero() {
+		return true, time.Time{}, nil
+	}
+	schedule, err := scheduleParser.Parse(img.Schedule)
+	if err != nil {
+		return false, time.Time{}, fmt.Errorf("invalid schedule %q: %w", img.Schedule, err)
+	}
+	next := schedule.Next(lastRun)
+	return !next.After(now), next, nil
+}
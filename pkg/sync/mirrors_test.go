@@ -0,0 +1,133 @@
+package sync
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadConfig_Mirrors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sync.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+parallel: 4
+mirrors:
+  - name: hub
+    source:
+      registry: docker.io
+    destination:
+      registry: mirror.example.com
+    images:
+      - repository: library/nginx
+        tags: ["1.25"]
+  - name: quay
+    source:
+      registry: quay.io
+    destination:
+      registry: mirror.example.com
+    images:
+      - repository: prometheus/prometheus
+        latest_n: 3
+        schedule: "0 0 */6 * * *"
+`), 0600))
+
+	config, err := LoadConfig(path)
+	require.NoError(t, err)
+
+	pairs := config.Pairs()
+	require.Len(t, pairs, 2, "the empty top-level pair is left out")
+	assert.Equal(t, "hub", pairs[0].Name)
+	assert.Equal(t, "docker", pairs[0].Config.Source.Type)
+	assert.Equal(t, "quay", pairs[1].Name)
+	assert.Equal(t, "quay", pairs[1].Config.Source.Type)
+	assert.Equal(t, 4, pairs[1].Config.Parallel, "mirrors share the top-level settings")
+	assert.Equal(t, "prometheus/prometheus", pairs[1].Config.Images[0].Repository)
+	assert.Empty(t, pairs[1].Config.Mirrors)
+}
+
+func TestConfig_ValidateMirrors(t *testing.T) {
+	mirror := Mirror{
+		Name:        "hub",
+		Source:      RegistryConfig{Registry: "docker.io"},
+		Destination: RegistryConfig{Registry: "mirror.example.com"},
+		Images:      []ImageSync{{Repository: "library/nginx", Tags: []string{"1.25"}}},
+	}
+
+	tests := []struct {
+		name    string
+		mutate  func(c *Config)
+		wantErr string
+	}{
+		{"valid", func(c *Config) {}, ""},
+		{"missing name", func(c *Config) { c.Mirrors[0].Name = "" }, "mirrors[0].name is required"},
+		{"duplicate name", func(c *Config) { c.Mirrors = append(c.Mirrors, c.Mirrors[0]) }, `mirrors[1]: duplicate name "hub"`},
+		{"no images", func(c *Config) { c.Mirrors[0].Images = nil }, "mirrors[0]: at least one image must be specified"},
+		{"no destination", func(c *Config) { c.Mirrors[0].Destination.Registry = "" }, "mirrors[0]: destination.registry is required"},
+		{"bad schedule", func(c *Config) { c.Mirrors[0].Images[0].Schedule = "every hour" }, "mirrors[0]: images[0]: invalid schedule"},
+		{"top-level prune needs a destination", func(c *Config) {
+			c.Prune = []PruneRule{{Repository: "library/nginx", KeepLast: 5}}
+		}, "destination.registry is required"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := mirror
+			m.Images = append([]ImageSync(nil), mirror.Images...)
+			c := &Config{Mirrors: []Mirror{m}}
+			tt.mutate(c)
+
+			err := c.Validate()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestMirrorStatePath(t *testing.T) {
+	assert.Equal(t, "sync.state.json", MirrorStatePath("sync.state.json", ""))
+	assert.Equal(t, "sync.state.quay.json", MirrorStatePath("sync.state.json", "quay"))
+}
+
+func TestImageSync_Due(t *testing.T) {
+	lastRun := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	img := ImageSync{Repository: "library/nginx", Schedule: "0 0 */6 * * *"}
+
+	due, _, err := img.Due(time.Time{}, lastRun)
+	require.NoError(t, err)
+	assert.True(t, due, "rules that never ran are due")
+
+	due, next, err := img.Due(lastRun, lastRun.Add(time.Hour))
+	require.NoError(t, err)
+	assert.False(t, due)
+	assert.Equal(t, lastRun.Add(6*time.Hour), next)
+
+	due, _, err = img.Due(lastRun, lastRun.Add(6*time.Hour))
+	require.NoError(t, err)
+	assert.True(t, due)
+
+	due, _, err = ImageSync{Repository: "library/nginx"}.Due(lastRun, lastRun)
+	require.NoError(t, err)
+	assert.True(t, due, "rules without a schedule are always due")
+}
+
+func TestState_LastRun(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sync.state.json")
+	state, err := LoadState(path)
+	require.NoError(t, err)
+	assert.True(t, state.LastRun("nginx").IsZero())
+
+	ran := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	state.MarkRun("nginx", ran)
+	require.NoError(t, state.Save())
+
+	reloaded, err := LoadState(path)
+	require.NoError(t, err)
+	assert.True(t, reloaded.LastRun("nginx").Equal(ran))
+	assert.True(t, reloaded.LastSuccess("nginx").IsZero(), "a run is not a success")
+}
@@ -0,0 +1,112 @@
+package sync
+
+import (
+	"context"
+	"strings"
+
+	"freightliner/pkg/policy"
+	"freightliner/pkg/service"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// PolicySkipError reports a task the policy decided not to copy
+type PolicySkipError struct {
+	Decision policy.Decision
+}
+
+func (e *PolicySkipError) Error() string {
+	return "skipped by policy: " + e.Decision.String()
+}
+
+// NewPolicyEvaluator returns the evaluator for config, or nil when no policy is set
+func NewPolicyEvaluator(config *PolicyConfig) (policy.Evaluator, error) {
+	if config == nil {
+		return nil, nil
+	}
+	return policy.NewEvaluator(policy.Options{
+		URL:    config.URL,
+		Files:  config.Files,
+		Query:  config.Query,
+		Binary: config.OPABinary,
+	})
+}
+
+// policyInput gathers what the policy sees about the source image of task
+func (be *BatchExecutor) policyInput(
+	ctx context.Context,
+	task SyncTask,
+	sourceRef name.Reference,
+	sourceRepo service.Repository,
+	srcOpts []remote.Option,
+	verified bool,
+) (policy.Input, error) {
+	input := policy.Input{
+		Source:      sourceRef.String(),
+		Destination: task.DestRegistry + "/" + task.DestRepository + ":" + task.DestTag,
+		Registry:    task.SourceRegistry,
+		Repository:  task.SourceRepository,
+		Tag:         task.SourceTag,
+		Labels:      map[string]string{},
+		Platforms:   []string{},
+		Signature:   policy.SignatureStatus{Verified: verified},
+	}
+
+	opts := append(srcOpts, remote.WithContext(ctx))
+	desc, err := remote.Get(sourceRef, opts...)
+	if err != nil {
+		return input, err
+	}
+	input.Digest = desc.Digest.String()
+
+	if desc.MediaType.IsIndex() {
+		index, err := desc.ImageIndex()
+		if err != nil {
+			return input, err
+		}
+		manifest, err := index.IndexManifest()
+		if err != nil {
+			return input, err
+		}
+		for _, m := range manifest.Manifests {
+			// Attestation manifests are stored with an unknown platform
+			if m.Platform != nil && m.Platform.OS != "unknown" {
+				input.Platforms = append(input.Platforms, m.Platform.String())
+			}
+		}
+	}
+
+	// Labels come from the image copied for an index: the default platform
+	if img, err := desc.Image(); err == nil {
+		if cfg, err := img.ConfigFile(); err == nil {
+			for k, v := range cfg.Config.Labels {
+				input.Labels[k] = v
+			}
+			if !desc.MediaType.IsIndex() {
+				if platform := cfg.Platform(); platform != nil {
+					input.Platforms = append(input.Platforms, platform.String())
+				}
+			}
+		}
+	}
+
+	if provider, ok := sourceRepo.(service.ScanFindingsProvider); ok {
+		summary, err := provider.GetScanFindings(ctx, task.SourceTag)
+		if err != nil {
+			be.logger.WithFields(map[string]interface{}{
+				"source": sourceRef.String(),
+				"error":  err.Error(),
+			}).Debug("No scan findings for policy input")
+		} else {
+			input.Scan = summary
+		}
+	}
+
+	sigTag := sourceRef.Context().Tag(strings.Replace(desc.Digest.String(), ":", "-", 1) + ".sig")
+	if _, err := remote.Head(sigTag, opts...); err == nil {
+		input.Signature.Signed = true
+	}
+
+	return input, nil
+}
@@ -0,0 +1,108 @@
+package sync
+
+import (
+	"context"
+	"time"
+
+	freightconfig "freightliner/pkg/config"
+	"freightliner/pkg/helper/apicalls"
+)
+
+// applyProfile fills the settings c leaves unset from its profile
+func (c *Config) applyProfile() {
+	if c.Profile == "" {
+		return
+	}
+	profile, err := freightconfig.LookupProfile(c.Profile)
+	if err != nil {
+		return
+	}
+	if c.Parallel <= 0 {
+		c.Parallel = profile.Workers
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = int(profile.TaskTimeout / time.Second)
+	}
+	if c.RetryAttempts <= 0 {
+		c.RetryAttempts = profile.TaskRetries
+	}
+	if c.RetryBackoff <= 0 {
+		c.RetryBackoff = int(profile.TaskRetryBackoff / time.Second)
+	}
+}
+
+// taskSettings are the timeout and retry policy one task runs with
+type taskSettings struct {
+	timeout       time.Duration
+	retryAttempts int
+	retryBackoff  time.Duration
+}
+
+// settingsFor returns the run's task settings, replaced by those of the
+// task's rule profile when it has one
+func (be *BatchExecutor) settingsFor(task SyncTask) taskSettings {
+	settings := taskSettings{
+		timeout:       time.Duration(be.config.Timeout) * time.Second,
+		retryAttempts: be.config.RetryAttempts,
+		retryBackoff:  time.Duration(be.config.RetryBackoff) * time.Second,
+	}
+	if task.Profile == "" {
+		return settings
+	}
+	profile, err := freightconfig.LookupProfile(task.Profile)
+	if err != nil {
+		return settings
+	}
+	return taskSettings{
+		timeout:       profile.TaskTimeout,
+		retryAttempts: profile.TaskRetries,
+		retryBackoff:  profile.TaskRetryBackoff,
+	}
+}
+
+// ruleThrottle caps the concurrency and request rate of one rule's tasks
+type ruleThrottle struct {
+	slots   chan struct{}
+	limiter *apicalls.Limiter
+}
+
+// throttleFor returns the throttle shared by the tasks of the task's rule,
+// or nil when the rule has no profile
+func (be *BatchExecutor) throttleFor(task SyncTask) *ruleThrottle {
+	if task.Profile == "" {
+		return nil
+	}
+	profile, err := freightconfig.LookupProfile(task.Profile)
+	if err != nil {
+		return nil
+	}
+
+	be.throttleMu.Lock()
+	defer be.throttleMu.Unlock()
+	if be.throttles == nil {
+		be.throttles = make(map[string]*ruleThrottle)
+	}
+	throttle, ok := be.throttles[task.Rule]
+	if !ok {
+		throttle = &ruleThrottle{
+			slots:   make(chan struct{}, profile.Workers),
+			limiter: apicalls.NewLimiter(profile.RequestsPerSecond),
+		}
+		be.throttles[task.Rule] = throttle
+	}
+	return throttle
+}
+
+// acquire waits for a free slot of the rule, returning the context the task
+// runs under and the function releasing the slot
+func (t *ruleThrottle) acquire(ctx context.Context) (context.Context, func(), error) {
+	if t == nil {
+		return ctx, func() {}, nil
+	}
+	select {
+	case t.slots <- struct{}{}:
+	case <-ctx.Done():
+		return ctx, nil, ctx.Err()
+	}
+	return apicalls.WithLimiter(ctx, t.limiter), func() { <-t.slots }, nil
+}
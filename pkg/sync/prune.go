@@ -0,0 +1,492 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"freightliner/pkg/client"
+	"freightliner/pkg/helper/log"
+	"freightliner/pkg/interfaces"
+	"freightliner/pkg/replication"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/robfig/cron/v3"
+)
+
+// PruneRule removes old tags from a destination repository. A tag is removed
+// only when it is not protected, is not among the keep_last newest tags and is
+// older than max_age; a limit that is not set holds no tags back.
+type PruneRule struct {
+	// Repository is the destination repository to prune
+	Repository string `yaml:"repository"`
+
+	// KeepLast keeps this many of the newest unprotected tags
+	KeepLast int `yaml:"keep_last,omitempty"`
+
+	// MaxAge keeps tags younger than this duration (e.g. "720h")
+	MaxAge string `yaml:"max_age,omitempty"`
+
+	// ProtectedTags are never removed and may use wildcards (e.g. "v*")
+	ProtectedTags []string `yaml:"protected_tags,omitempty"`
+
+	// Schedule is the cron expression, with seconds, the server prunes on
+	Schedule string `yaml:"schedule,omitempty"`
+
+	// Delete enables removal; until it is set every run is a dry run
+	Delete bool `yaml:"delete,omitempty"`
+
+	// Overlap is "skip" (default) or "queue" and decides what happens when the
+	// schedule fires while the previous run is still going
+	Overlap replication.OverlapPolicy `yaml:"overlap,omitempty"`
+
+	// ErrorBudget pauses the schedule when too many runs fail
+	ErrorBudget *replication.ErrorBudget `yaml:"error_budget,omitempty"`
+
+	// Freeze forbids removals, and server copies into the repository, during
+	// maintenance windows and blackout dates
+	Freeze *replication.FreezePolicy `yaml:"freeze,omitempty"`
+
+	// Trash moves removed tags into a trash repository instead of deleting
+	// them, until empty-trash deletes them after the retention
+	Trash *TrashPolicy `yaml:"trash,omitempty"`
+}
+
+// scheduleParser parses prune and image rule schedules the same way as
+// replication schedules
+var scheduleParser = cron.NewParser(
+	cron.Second | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor,
+)
+
+// Validate checks the rule's limits and schedule
+func (r PruneRule) Validate() error {
+	if r.Repository == "" {
+		return fmt.Errorf("repository is required")
+	}
+	if r.KeepLast < 0 {
+		return fmt.Errorf("keep_last must not be negative")
+	}
+	if _, err := r.maxAge(); err != nil {
+		return err
+	}
+	if r.KeepLast == 0 && r.MaxAge == "" {
+		return fmt.Errorf("keep_last or max_age is required")
+	}
+	if r.Schedule != "" {
+		if _, err := scheduleParser.Parse(r.Schedule); err != nil {
+			return fmt.Errorf("invalid schedule %q: %w", r.Schedule, err)
+		}
+	}
+	if err := r.ErrorBudget.Validate(); err != nil {
+		return err
+	}
+	if err := r.Freeze.Validate(); err != nil {
+		return err
+	}
+	if err := r.Trash.Validate(); err != nil {
+		return err
+	}
+	return replication.ValidateOverlapPolicy(r.Overlap)
+}
+
+// NextRun returns the first scheduled run after t
+func (r PruneRule) NextRun(t time.Time) (time.Time, error) {
+	schedule, err := scheduleParser.Parse(r.Schedule)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid schedule %q: %w", r.Schedule, err)
+	}
+	return schedule.Next(t), nil
+}
+
+// maxAge parses MaxAge; zero means no age limit
+func (r PruneRule) maxAge() (time.Duration, error) {
+	if r.MaxAge == "" {
+		return 0, nil
+	}
+	age, err := time.ParseDuration(r.MaxAge)
+	if err != nil || age <= 0 {
+		return 0, fmt.Errorf("invalid max_age %q", r.MaxAge)
+	}
+	return age, nil
+}
+
+// protects reports whether tag matches one of the protected tag patterns
+func (r PruneRule) protects(tag string) bool {
+	for _, pattern := range r.ProtectedTags {
+		if replication.MatchPattern(pattern, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// Prune actions recorded for each tag
+const (
+	PruneActionKeep   = "keep"
+	PruneActionRemove = "remove"
+)
+
+// PruneDecision records what a prune run does with one tag and why
+type PruneDecision struct {
+	Tag       string    `json:"tag"`
+	Digest    string    `json:"digest,omitempty"`
+	CreatedAt time.Time `json:"created_at,omitempty"`
+	Action    string    `json:"action"`
+	Reason    string    `json:"reason"`
+	Error     string    `json:"error,omitempty"`
+
+	// Trash is the reference a removed tag was moved to
+	Trash string `json:"trash,omitempty"`
+}
+
+// PruneResult is the outcome of pruning one repository. In dry runs Removed
+// lists the tags that would have been removed.
+type PruneResult struct {
+	Registry   string          `json:"registry"`
+	Repository string          `json:"repository"`
+	DryRun     bool            `json:"dry_run"`
+	StartedAt  time.Time       `json:"started_at"`
+	FinishedAt time.Time       `json:"finished_at"`
+	Kept       []PruneDecision `json:"kept"`
+	Removed    []PruneDecision `json:"removed"`
+	Failed     []PruneDecision `json:"failed"`
+
+	// ReclaimableBytes estimates the storage freed once the registry collects
+	// garbage: the blobs of removed tags that no kept tag uses. Blobs shared
+	// with other repositories are counted too, so it is an upper bound. Tags
+	// moved to a trash free nothing until the trash is emptied.
+	ReclaimableBytes int64 `json:"reclaimable_bytes"`
+}
+
+// PlanPrune decides which tags rule keeps and which it removes at now.
+// Protected tags do not count towards keep_last, tags of unknown age are
+// always kept, and a tag sharing its digest with a kept tag is kept so that
+// registries without untagging cannot delete the kept tag's image.
+func PlanPrune(rule PruneRule, tags []TagMetadata, now time.Time) (keep, remove []PruneDecision) {
+	maxAge, _ := rule.maxAge()
+
+	sorted := make([]TagMetadata, len(tags))
+	copy(sorted, tags)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if !sorted[i].CreatedAt.Equal(sorted[j].CreatedAt) {
+			return sorted[i].CreatedAt.After(sorted[j].CreatedAt)
+		}
+		return sorted[i].Tag < sorted[j].Tag
+	})
+
+	keptDigests := make(map[string]string)
+	newest := 0
+	for _, tm := range sorted {
+		decision := PruneDecision{Tag: tm.Tag, Digest: tm.Digest, CreatedAt: tm.CreatedAt, Action: PruneActionKeep}
+
+		switch {
+		case rule.protects(tm.Tag):
+			decision.Reason = "protected"
+		case tm.CreatedAt.IsZero() || tm.CreatedAt.Unix() <= 0:
+			decision.Reason = "creation time unknown"
+		case rule.KeepLast > 0 && newest < rule.KeepLast:
+			newest++
+			decision.Reason = fmt.Sprintf("among the %d newest tags", rule.KeepLast)
+		case maxAge > 0 && now.Sub(tm.CreatedAt) < maxAge:
+			decision.Reason = fmt.Sprintf("younger than %s", rule.MaxAge)
+		default:
+			decision.Action = PruneActionRemove
+			if maxAge > 0 {
+				decision.Reason = fmt.Sprintf("older than %s", rule.MaxAge)
+			} else {
+				decision.Reason = fmt.Sprintf("beyond the %d newest tags", rule.KeepLast)
+			}
+		}
+
+		if decision.Action == PruneActionKeep {
+			if tm.Digest != "" {
+				keptDigests[tm.Digest] = tm.Tag
+			}
+			keep = append(keep, decision)
+		} else {
+			remove = append(remove, decision)
+		}
+	}
+
+	removable := remove[:0]
+	for _, decision := range remove {
+		if tag, ok := keptDigests[decision.Digest]; ok && decision.Digest != "" {
+			decision.Action = PruneActionKeep
+			decision.Reason = fmt.Sprintf("shares its image with kept tag %s", tag)
+			keep = append(keep, decision)
+			continue
+		}
+		removable = append(removable, decision)
+	}
+
+	return keep, removable
+}
+
+// PruneRepository is the repository access pruning needs. Removal also needs
+// interfaces.ReferenceDeleter; dry runs work on any repository.
+type PruneRepository interface {
+	interfaces.RepositoryInfo
+	interfaces.TagLister
+	interfaces.ImageReferencer
+	interfaces.RemoteOptionsProvider
+}
+
+// Pruner applies prune rules to destination repositories. The prune command
+// and the server's scheduled prunes share it.
+type Pruner struct {
+	logger log.Logger
+	now    func() time.Time
+}
+
+// NewPruner creates a new pruner
+func NewPruner(logger log.Logger) *Pruner {
+	return &Pruner{
+		logger: logger,
+		now:    time.Now,
+	}
+}
+
+// PruneDestination applies rule to its repository in the destination registry of cfg
+func (p *Pruner) PruneDestination(ctx context.Context, factory *client.Factory, cfg *Config, rule PruneRule, dryRun bool) (*PruneResult, error) {
+	registryClient, err := factory.CreateClientForRegistry(ctx, cfg.Destination.Registry)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create client for registry %s: %w", cfg.Destination.Registry, err)
+	}
+
+	repo, err := registryClient.GetRepository(ctx, rule.Repository)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get repository %s: %w", rule.Repository, err)
+	}
+
+	if rule.Trash != nil && rule.Delete && !dryRun {
+		if err := p.ensureTrashRepository(ctx, registryClient, rule); err != nil {
+			return nil, err
+		}
+	}
+
+	result, err := p.Prune(ctx, repo, rule, dryRun)
+	if result != nil {
+		result.Registry = cfg.Destination.Registry
+	}
+	return result, err
+}
+
+// Prune applies rule to repo. A run is a dry run unless the rule enables
+// deletion and dryRun is false. With a trash, each removed tag is first
+// moved to the trash repository; a tag that cannot be moved is not deleted. The result is returned with any error,
+// including when ctx is canceled part way through.
+func (p *Pruner) Prune(ctx context.Context, repo PruneRepository, rule PruneRule, dryRun bool) (*PruneResult, error) {
+	result := &PruneResult{
+		Repository: repo.GetRepositoryName(),
+		DryRun:     dryRun || !rule.Delete,
+		StartedAt:  p.now().UTC(),
+		Kept:       []PruneDecision{},
+		Removed:    []PruneDecision{},
+		Failed:     []PruneDecision{},
+	}
+	defer func() { result.FinishedAt = p.now().UTC() }()
+
+	if !result.DryRun {
+		if err := rule.Freeze.Check(rule.Repository, p.now()); err != nil {
+			return result, err
+		}
+	}
+
+	tags, err := repo.ListTags(ctx)
+	if err != nil {
+		return result, fmt.Errorf("failed to list tags: %w", err)
+	}
+
+	metadata := make([]TagMetadata, 0, len(tags))
+	blobs := make(map[string]map[string]int64, len(tags))
+	for _, tag := range tags {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		tm, tagBlobs, err := inspectTag(ctx, repo, tag)
+		blobs[tag] = tagBlobs
+		if err != nil {
+			// Without a digest or age the tag cannot be judged safely, so keep it
+			p.logger.WithFields(map[string]interface{}{
+				"repository": result.Repository,
+				"tag":        tag,
+				"error":      err.Error(),
+			}).Warn("Failed to inspect tag, keeping it")
+		}
+		metadata = append(metadata, tm)
+	}
+
+	keep, remove := PlanPrune(rule, metadata, p.now())
+	result.Kept = append(result.Kept, keep...)
+
+	var deleter interfaces.ReferenceDeleter
+	if !result.DryRun {
+		var ok bool
+		if deleter, ok = repo.(interfaces.ReferenceDeleter); !ok {
+			return result, fmt.Errorf("registry does not support deleting tags")
+		}
+	}
+
+	for _, decision := range remove {
+		if result.DryRun {
+			result.Removed = append(result.Removed, decision)
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		if rule.Trash != nil {
+			trashRef, err := moveToTrash(ctx, repo, rule.Trash, decision.Tag, p.now())
+			if err != nil {
+				decision.Error = err.Error()
+				result.Failed = append(result.Failed, decision)
+				continue
+			}
+			decision.Trash = trashRef
+		}
+		if err := deleter.DeleteReference(ctx, decision.Tag); err != nil {
+			decision.Error = err.Error()
+			result.Failed = append(result.Failed, decision)
+			continue
+		}
+		result.Removed = append(result.Removed, decision)
+	}
+	if rule.Trash == nil {
+		// Trashed tags keep their blobs until the trash is emptied
+		result.ReclaimableBytes = reclaimableBytes(result.Kept, result.Removed, blobs)
+	}
+
+	p.logger.WithFields(map[string]interface{}{
+		"repository":        result.Repository,
+		"dry_run":           result.DryRun,
+		"kept":              len(result.Kept),
+		"removed":           len(result.Removed),
+		"failed":            len(result.Failed),
+		"reclaimable_bytes": result.ReclaimableBytes,
+	}).Info("Pruned repository")
+
+	if len(result.Failed) > 0 {
+		return result, fmt.Errorf("failed to remove %d of %d tags from %s", len(result.Failed), len(remove), result.Repository)
+	}
+	return result, nil
+}
+
+// inspectTag reads a tag's digest and creation time, and the manifests and
+// blobs it references with their sizes. Image indexes take the creation time
+// of their first image.
+func inspectTag(ctx context.Context, repo PruneRepository, tag string) (TagMetadata, map[string]int64, error) {
+	tm := TagMetadata{Tag: tag}
+	blobs := make(map[string]int64)
+
+	ref, err := repo.GetImageReference(tag)
+	if err != nil {
+		return tm, blobs, err
+	}
+	opts, err := repo.GetRemoteOptions()
+	if err != nil {
+		return tm, blobs, err
+	}
+	opts = append(opts, remote.WithContext(ctx))
+
+	desc, err := remote.Get(ref, opts...)
+	if err != nil {
+		return tm, blobs, err
+	}
+	tm.Digest = desc.Digest.String()
+	blobs[tm.Digest] = desc.Size
+
+	var img v1.Image
+	if desc.MediaType.IsIndex() {
+		index, err := desc.ImageIndex()
+		if err != nil {
+			return tm, blobs, err
+		}
+		manifest, err := index.IndexManifest()
+		if err != nil {
+			return tm, blobs, err
+		}
+		if len(manifest.Manifests) == 0 {
+			return tm, blobs, fmt.Errorf("image index %s is empty", tm.Digest)
+		}
+		for _, child := range manifest.Manifests {
+			blobs[child.Digest.String()] = child.Size
+			if !child.MediaType.IsImage() {
+				continue
+			}
+			childImg, err := index.Image(child.Digest)
+			if err != nil {
+				if img == nil {
+					return tm, blobs, err
+				}
+				continue
+			}
+			if img == nil {
+				img = childImg
+			}
+			addImageBlobs(blobs, childImg)
+		}
+		if img == nil {
+			return tm, blobs, fmt.Errorf("image index %s has no images", tm.Digest)
+		}
+	} else {
+		if img, err = desc.Image(); err != nil {
+			return tm, blobs, err
+		}
+		addImageBlobs(blobs, img)
+	}
+
+	configFile, err := img.ConfigFile()
+	if err != nil {
+		return tm, blobs, err
+	}
+	tm.CreatedAt = configFile.Created.Time
+	for _, size := range blobs {
+		tm.Size += size
+	}
+	return tm, blobs, nil
+}
+
+// addImageBlobs records the config and layers of img. A manifest that cannot
+// be read only leaves the reclaimable estimate short.
+func addImageBlobs(blobs map[string]int64, img v1.Image) {
+	manifest, err := img.Manifest()
+	if err != nil {
+		return
+	}
+	blobs[manifest.Config.Digest.String()] = manifest.Config.Size
+	for _, layer := range manifest.Layers {
+		blobs[layer.Digest.String()] = layer.Size
+	}
+}
+
+// reclaimableBytes sums the blobs of the removed tags that no kept tag uses,
+// counting each blob once
+func reclaimableBytes(kept, removed []PruneDecision, blobs map[string]map[string]int64) int64 {
+	inUse := make(map[string]bool)
+	for _, decision := range kept {
+		for digest := range blobs[decision.Tag] {
+			inUse[digest] = true
+		}
+	}
+
+	var total int64
+	for _, decision := range removed {
+		for digest, size := range blobs[decision.Tag] {
+			if inUse[digest] {
+				continue
+			}
+			inUse[digest] = true
+			total += size
+		}
+	}
+	return total
+}
+
+// Reference returns the image reference of a tag in the pruned repository
+func (r *PruneResult) Reference(tag string) string {
+	return strings.TrimSuffix(r.Registry, "/") + "/" + r.Repository + ":" + tag
+}
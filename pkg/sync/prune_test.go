@@ -0,0 +1,210 @@
+package sync
+
+import (
+	"context"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"freightliner/pkg/helper/log"
+	"freightliner/pkg/replication"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func decisionTags(decisions []PruneDecision) []string {
+	tags := make([]string, len(decisions))
+	for i, decision := range decisions {
+		tags[i] = decision.Tag
+	}
+	return tags
+}
+
+func TestPlanPrune(t *testing.T) {
+	now := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	tags := []TagMetadata{
+		{Tag: "v1", Digest: "sha256:1", CreatedAt: now.Add(-90 * day)},
+		{Tag: "build-1", Digest: "sha256:2", CreatedAt: now.Add(-60 * day)},
+		{Tag: "build-2", Digest: "sha256:3", CreatedAt: now.Add(-40 * day)},
+		{Tag: "build-3", Digest: "sha256:4", CreatedAt: now.Add(-20 * day)},
+		{Tag: "build-4", Digest: "sha256:5", CreatedAt: now.Add(-1 * day)},
+		{Tag: "latest", Digest: "sha256:5", CreatedAt: now.Add(-1 * day)},
+		{Tag: "reproducible", Digest: "sha256:6", CreatedAt: time.Unix(0, 0)},
+	}
+
+	tests := []struct {
+		name       string
+		rule       PruneRule
+		wantRemove []string
+	}{
+		{
+			name:       "keep last",
+			rule:       PruneRule{KeepLast: 2, ProtectedTags: []string{"v*"}},
+			wantRemove: []string{"build-3", "build-2", "build-1"},
+		},
+		{
+			name:       "max age",
+			rule:       PruneRule{MaxAge: "720h"},
+			wantRemove: []string{"build-2", "build-1", "v1"},
+		},
+		{
+			name:       "keep last and max age",
+			rule:       PruneRule{KeepLast: 4, MaxAge: "720h"},
+			wantRemove: []string{"build-1", "v1"},
+		},
+		{
+			// latest is beyond keep_last but shares its image with build-4
+			name:       "shared digest",
+			rule:       PruneRule{KeepLast: 1},
+			wantRemove: []string{"build-3", "build-2", "build-1", "v1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keep, remove := PlanPrune(tt.rule, tags, now)
+			assert.Equal(t, tt.wantRemove, decisionTags(remove))
+			assert.Len(t, keep, len(tags)-len(tt.wantRemove))
+			assert.Contains(t, decisionTags(keep), "reproducible", "tags of unknown age are kept")
+		})
+	}
+}
+
+func TestPruneRule_Validate(t *testing.T) {
+	tests := []struct {
+		name      string
+		rule      PruneRule
+		errSubstr string
+	}{
+		{name: "valid", rule: PruneRule{Repository: "app", KeepLast: 5, MaxAge: "72h", Schedule: "@daily"}},
+		{name: "no repository", rule: PruneRule{KeepLast: 5}, errSubstr: "repository is required"},
+		{name: "no limits", rule: PruneRule{Repository: "app"}, errSubstr: "keep_last or max_age"},
+		{name: "negative keep", rule: PruneRule{Repository: "app", KeepLast: -1}, errSubstr: "keep_last"},
+		{name: "bad age", rule: PruneRule{Repository: "app", MaxAge: "-1h"}, errSubstr: "invalid max_age"},
+		{name: "bad schedule", rule: PruneRule{Repository: "app", KeepLast: 1, Schedule: "daily"}, errSubstr: "invalid schedule"},
+		{name: "queue overlap", rule: PruneRule{Repository: "app", KeepLast: 1, Overlap: "queue"}},
+		{name: "bad overlap", rule: PruneRule{Repository: "app", KeepLast: 1, Overlap: "parallel"}, errSubstr: "invalid overlap policy"},
+		{name: "bad freeze", rule: PruneRule{Repository: "app", KeepLast: 1, Freeze: &replication.FreezePolicy{Timezone: "Nowhere/City"}}, errSubstr: "invalid freeze timezone"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.rule.Validate()
+			if tt.errSubstr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.errSubstr)
+		})
+	}
+}
+
+// registryRepository is a PruneRepository backed by a test registry
+type registryRepository struct {
+	repo    name.Repository
+	deleted []string
+}
+
+func (r *registryRepository) GetName() string           { return r.repo.RepositoryStr() }
+func (r *registryRepository) GetRepositoryName() string { return r.repo.RepositoryStr() }
+
+func (r *registryRepository) ListTags(ctx context.Context) ([]string, error) {
+	return remote.List(r.repo, remote.WithContext(ctx))
+}
+
+func (r *registryRepository) GetImageReference(tag string) (name.Reference, error) {
+	return r.repo.Tag(tag), nil
+}
+
+func (r *registryRepository) GetRemoteOptions() ([]remote.Option, error) {
+	return nil, nil
+}
+
+func (r *registryRepository) DeleteReference(ctx context.Context, reference string) error {
+	r.deleted = append(r.deleted, reference)
+	return nil
+}
+
+func TestPruner_Prune(t *testing.T) {
+	server := httptest.NewServer(registry.New())
+	defer server.Close()
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	repoRef, err := name.NewRepository(u.Host + "/mirror/app")
+	require.NoError(t, err)
+
+	now := time.Now()
+	for tag, age := range map[string]time.Duration{"old": 60 * 24 * time.Hour, "new": time.Hour, "stable": 90 * 24 * time.Hour} {
+		img, err := random.Image(256, 1)
+		require.NoError(t, err)
+		img, err = mutate.CreatedAt(img, v1.Time{Time: now.Add(-age)})
+		require.NoError(t, err)
+		require.NoError(t, remote.Write(repoRef.Tag(tag), img))
+	}
+
+	repo := &registryRepository{repo: repoRef}
+	pruner := NewPruner(log.NewBasicLogger(log.ErrorLevel))
+	rule := PruneRule{Repository: "mirror/app", MaxAge: "720h", ProtectedTags: []string{"stable"}}
+
+	// Rules without delete only report
+	result, err := pruner.Prune(context.Background(), repo, rule, false)
+	require.NoError(t, err)
+	assert.True(t, result.DryRun)
+	assert.Equal(t, []string{"old"}, decisionTags(result.Removed))
+	assert.Len(t, result.Kept, 2)
+	assert.Empty(t, repo.deleted)
+
+	// The dry-run flag wins over delete
+	rule.Delete = true
+	result, err = pruner.Prune(context.Background(), repo, rule, true)
+	require.NoError(t, err)
+	assert.True(t, result.DryRun)
+	assert.Empty(t, repo.deleted)
+
+	// A frozen rule refuses to remove anything
+	frozen := rule
+	frozen.Freeze = &replication.FreezePolicy{Blackouts: []replication.Blackout{{Start: now.UTC().Format("2006-01-02")}}}
+	_, err = pruner.Prune(context.Background(), repo, frozen, false)
+	var frozenErr *replication.FrozenError
+	require.ErrorAs(t, err, &frozenErr)
+	assert.Empty(t, repo.deleted)
+
+	result, err = pruner.Prune(context.Background(), repo, rule, false)
+	require.NoError(t, err)
+	assert.False(t, result.DryRun)
+	assert.Equal(t, []string{"old"}, repo.deleted)
+	assert.Greater(t, result.ReclaimableBytes, int64(256), "the removed image's layer, config and manifest")
+}
+
+func TestReclaimableBytes(t *testing.T) {
+	blobs := map[string]map[string]int64{
+		"v1":  {"sha256:m1": 10, "sha256:base": 100, "sha256:app1": 50},
+		"v2":  {"sha256:m2": 10, "sha256:base": 100, "sha256:app2": 60},
+		"v3":  {"sha256:m3": 10, "sha256:base": 100, "sha256:app2": 60},
+		"old": {"sha256:m4": 10, "sha256:legacy": 200},
+	}
+	kept := []PruneDecision{{Tag: "v3"}}
+	removed := []PruneDecision{{Tag: "v1"}, {Tag: "v2"}, {Tag: "old"}}
+
+	// The base layer and app2 stay in use by v3
+	assert.Equal(t, int64(10+50+10+10+200), reclaimableBytes(kept, removed, blobs))
+}
+
+func TestDistributionGCCommand(t *testing.T) {
+	assert.Equal(t, "registry garbage-collect /etc/docker/registry/config.yml",
+		distributionGCCommand(&GarbageCollectionConfig{}))
+	assert.Equal(t, "registry garbage-collect /srv/registry.yml --delete-untagged",
+		distributionGCCommand(&GarbageCollectionConfig{RegistryConfig: "/srv/registry.yml", DeleteUntagged: true}))
+	assert.Contains(t, harborGCCommand("https://harbor.example.com/", true),
+		`"delete_untagged":true}}' https://harbor.example.com/api/v2.0/system/gc/schedule`)
+}
@@ -0,0 +1,75 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+
+	"freightliner/pkg/client/ecr"
+	freightconfig "freightliner/pkg/config"
+	"freightliner/pkg/service"
+)
+
+// PullThroughCacheSkipError reports a task whose destination repository is an
+// ECR pull through cache of its source, skipped by policy
+type PullThroughCacheSkipError struct {
+	Repository string
+	Rule       ecr.PullThroughCacheRule
+}
+
+func (e *PullThroughCacheSkipError) Error() string {
+	return fmt.Sprintf("destination repository %s is an ECR pull through cache of %s", e.Repository, e.Rule.UpstreamRegistryURL)
+}
+
+// WithPullThroughCachePolicy sets what happens to tasks whose destination
+// repository is an ECR pull through cache of their source: warn (default),
+// skip or ignore
+func (be *BatchExecutor) WithPullThroughCachePolicy(policy string) *BatchExecutor {
+	be.pullThroughCache = policy
+	return be
+}
+
+// checkPullThroughCache warns about, or with the skip policy refuses, copies
+// into an ECR pull through cache of the task's own source repository
+func (be *BatchExecutor) checkPullThroughCache(ctx context.Context, destClient service.RegistryClient, task SyncTask) error {
+	if be.pullThroughCache == freightconfig.PullThroughCacheIgnore {
+		return nil
+	}
+	ecrClient, ok := destClient.(*ecr.Client)
+	if !ok {
+		return nil
+	}
+
+	rule, err := ecrClient.PullThroughCacheFor(ctx, task.DestRepository, task.SourceRegistry, task.SourceRepository)
+	if err != nil {
+		be.logger.WithFields(map[string]interface{}{
+			"repository": task.DestRepository,
+			"error":      err.Error(),
+		}).Debug("Unable to read ECR pull through cache rules")
+		return nil
+	}
+	if rule == nil {
+		return nil
+	}
+
+	// Warn once per repository rather than for every tag
+	be.mu.Lock()
+	if be.pullThroughWarned == nil {
+		be.pullThroughWarned = make(map[string]bool)
+	}
+	warned := be.pullThroughWarned[task.DestRepository]
+	be.pullThroughWarned[task.DestRepository] = true
+	be.mu.Unlock()
+	if !warned {
+		be.logger.WithFields(map[string]interface{}{
+			"repository":        task.DestRepository,
+			"upstream_registry": rule.UpstreamRegistryURL,
+			"ecr_prefix":        rule.EcrRepositoryPrefix,
+			"policy":            be.pullThroughCache,
+		}).Warn("Destination repository is an ECR pull through cache of the source")
+	}
+
+	if be.pullThroughCache == freightconfig.PullThroughCacheSkip {
+		return &PullThroughCacheSkipError{Repository: task.DestRepository, Rule: *rule}
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package sync
+
+import (
+	"context"
+
+	"freightliner/pkg/interfaces"
+	"freightliner/pkg/service"
+)
+
+// WithRepositoryCreation creates missing destination repositories under
+// policy, unless a task's rule sets its own create_missing_repos or
+// repository_template
+func (be *BatchExecutor) WithRepositoryCreation(policy service.RepositoryCreationPolicy) *BatchExecutor {
+	be.repoCreation = policy
+	return be
+}
+
+// destinationRepository returns the destination repository of task. A missing
+// repository is created under the rule's policy and template, falling back
+// to the run's; with neither set it is left to the registry client. Tasks
+// sharing a repository create it once.
+func (be *BatchExecutor) destinationRepository(ctx context.Context, destClient service.RegistryClient, task SyncTask) (interfaces.Repository, error) {
+	policy := be.repoCreation
+	if task.CreateMissingRepos != "" {
+		policy.CreateMissingRepos = task.CreateMissingRepos
+	}
+	if task.RepositoryTemplate != nil {
+		policy.Template = *task.RepositoryTemplate
+	}
+
+	repo, err := destClient.GetRepository(ctx, task.DestRepository)
+	if err == nil || policy.CreateMissingRepos == "" {
+		return repo, err
+	}
+
+	be.repoCreateMu.Lock()
+	defer be.repoCreateMu.Unlock()
+
+	key := task.DestRegistry + "/" + task.DestRepository
+	if err := be.repoCreateErrs[key]; err != nil {
+		return nil, err
+	}
+
+	repo, err = service.EnsureRepository(ctx, be.logger, destClient, task.DestRepository, task.SourceRegistry+"/"+task.SourceRepository, policy)
+	if err != nil {
+		if be.repoCreateErrs == nil {
+			be.repoCreateErrs = make(map[string]error)
+		}
+		be.repoCreateErrs[key] = err
+		return nil, err
+	}
+	return repo, nil
+}
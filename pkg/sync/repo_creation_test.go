@@ -0,0 +1,82 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	freightconfig "freightliner/pkg/config"
+	"freightliner/pkg/helper/log"
+	"freightliner/pkg/interfaces"
+	"freightliner/pkg/service"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// creatingClient is a registry without repositories that records the
+// repositories created in it
+type creatingClient struct {
+	created map[string]map[string]string
+}
+
+func (c *creatingClient) ListRepositories(context.Context, string) ([]string, error) {
+	return nil, nil
+}
+
+func (c *creatingClient) GetRepository(_ context.Context, name string) (interfaces.Repository, error) {
+	if _, ok := c.created[name]; ok {
+		return nil, nil
+	}
+	return nil, errors.New("repository not found")
+}
+
+func (c *creatingClient) GetRegistryName() string {
+	return "dest.example.com"
+}
+
+func (c *creatingClient) CreateRepository(_ context.Context, name string, tags map[string]string) (interfaces.Repository, error) {
+	c.created[name] = tags
+	return nil, nil
+}
+
+func TestBatchExecutor_DestinationRepository(t *testing.T) {
+	logger := log.NewBasicLogger(log.ErrorLevel)
+	task := SyncTask{SourceRegistry: "docker.io", SourceRepository: "library/nginx", DestRegistry: "dest.example.com", DestRepository: "mirror/nginx"}
+
+	// Without a policy a missing repository is an error, as before
+	client := &creatingClient{created: map[string]map[string]string{}}
+	be := NewBatchExecutor(&Config{}, logger)
+	_, err := be.destinationRepository(context.Background(), client, task)
+	assert.Error(t, err)
+	assert.Empty(t, client.created)
+
+	// The rule's policy and template override the run's
+	be.WithRepositoryCreation(service.RepositoryCreationPolicy{CreateMissingRepos: freightconfig.CreateMissingReposFalse})
+	ruleTask := task
+	ruleTask.CreateMissingRepos = freightconfig.CreateMissingReposTrue
+	ruleTask.RepositoryTemplate = &freightconfig.RepositoryTemplateConfig{Tags: map[string]string{"team": "platform"}}
+	_, err = be.destinationRepository(context.Background(), client, ruleTask)
+	require.NoError(t, err)
+	require.Contains(t, client.created, "mirror/nginx")
+	assert.Equal(t, "platform", client.created["mirror/nginx"]["team"])
+	assert.Equal(t, "docker.io/library/nginx", client.created["mirror/nginx"]["Source"])
+
+	// A declined repository is asked about once
+	prompts := 0
+	be.WithRepositoryCreation(service.RepositoryCreationPolicy{
+		CreateMissingRepos: freightconfig.CreateMissingReposPrompt,
+		Confirm: func(string) (bool, error) {
+			prompts++
+			return false, nil
+		},
+	})
+	declined := task
+	declined.DestRepository = "mirror/redis"
+	for i := 0; i < 3; i++ {
+		_, err = be.destinationRepository(context.Background(), client, declined)
+		assert.Error(t, err)
+	}
+	assert.Equal(t, 1, prompts)
+	assert.NotContains(t, client.created, "mirror/redis")
+}
@@ -0,0 +1,49 @@
+package sync
+
+import (
+	"context"
+
+	"freightliner/pkg/service"
+)
+
+// WithRepoMetadata copies repository descriptions and readmes to each
+// destination repository, once per run, where both registries keep them
+func (be *BatchExecutor) WithRepoMetadata(enabled bool) *BatchExecutor {
+	be.repoMetadata = enabled
+	return be
+}
+
+// copyRepoMetadata copies the description and readme of the task's source
+// repository to its destination the first time the destination is synced.
+// Failures are logged and never fail the task.
+func (be *BatchExecutor) copyRepoMetadata(ctx context.Context, task SyncTask, sourceRepo, destRepo service.Repository) {
+	if !be.repoMetadata {
+		return
+	}
+
+	key := task.DestRegistry + "/" + task.DestRepository
+	be.mu.Lock()
+	if be.repoMetadataCopied == nil {
+		be.repoMetadataCopied = make(map[string]bool)
+	}
+	done := be.repoMetadataCopied[key]
+	be.repoMetadataCopied[key] = true
+	be.mu.Unlock()
+	if done {
+		return
+	}
+
+	fields := map[string]interface{}{
+		"source":      task.SourceRegistry + "/" + task.SourceRepository,
+		"destination": key,
+	}
+	copied, err := service.CopyRepositoryMetadata(ctx, sourceRepo, destRepo)
+	if err != nil {
+		fields["error"] = err.Error()
+		be.logger.WithFields(fields).Warn("Failed to copy repository metadata")
+		return
+	}
+	if copied {
+		be.logger.WithFields(fields).Info("Copied repository metadata")
+	}
+}
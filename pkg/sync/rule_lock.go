@@ -0,0 +1,85 @@
+package sync
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"freightliner/pkg/replication"
+)
+
+// ErrRuleLocked reports an image rule that another sync run is still running
+var ErrRuleLocked = errors.New("image rule is still running in another sync")
+
+// ruleLockPoll is how often a queued run checks whether the lock was released
+var ruleLockPoll = 500 * time.Millisecond
+
+// RuleLockPath returns the lock file of rule, kept next to the state file:
+// sync.state.json keeps its locks in sync.state.locks/
+func RuleLockPath(statePath, rule string) string {
+	dir := strings.TrimSuffix(statePath, filepath.Ext(statePath)) + ".locks"
+	sum := sha256.Sum256([]byte(rule))
+	return filepath.Join(dir, hex.EncodeToString(sum[:12])+".lock")
+}
+
+// Guarded reports whether syncs take the rule's lock while running it:
+// scheduled rules and rules that set overlap
+func (img ImageSync) Guarded() bool {
+	return img.Schedule != "" || img.Overlap != ""
+}
+
+// FileLock is an exclusive lock on a file held by this process. The
+// operating system releases it if the process dies.
+type FileLock struct {
+	file *os.File
+}
+
+// LockFile takes the lock on path. With replication.OverlapQueue it waits
+// for another holder to release it, or for ctx to end; otherwise it returns
+// ErrRuleLocked while another process holds it.
+func LockFile(ctx context.Context, path string, policy replication.OverlapPolicy) (*FileLock, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create lock directory: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %w", err)
+	}
+
+	for {
+		locked, err := tryLockFile(file)
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to lock %s: %w", path, err)
+		}
+		if locked {
+			return &FileLock{file: file}, nil
+		}
+		if policy != replication.OverlapQueue {
+			file.Close()
+			return nil, ErrRuleLocked
+		}
+
+		select {
+		case <-ctx.Done():
+			file.Close()
+			return nil, ctx.Err()
+		case <-time.After(ruleLockPoll):
+		}
+	}
+}
+
+// Unlock releases the lock
+func (l *FileLock) Unlock() error {
+	if err := unlockFile(l.file); err != nil {
+		l.file.Close()
+		return err
+	}
+	return l.file.Close()
+}
@@ -0,0 +1,16 @@
+//go:build !unix
+
+package sync
+
+import "os"
+
+// tryLockFile does not lock on this platform, so overlapping runs are not
+// detected
+func tryLockFile(file *os.File) (bool, error) {
+	return true, nil
+}
+
+// unlockFile has nothing to release on this platform
+func unlockFile(file *os.File) error {
+	return nil
+}
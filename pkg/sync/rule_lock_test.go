@@ -0,0 +1,64 @@
+//go:build unix
+
+package sync
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"freightliner/pkg/replication"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRuleLockPath(t *testing.T) {
+	path := RuleLockPath("/etc/freightliner/sync.state.json", "library/nginx -> mirror/nginx")
+	assert.Equal(t, "/etc/freightliner/sync.state.locks", filepath.Dir(path))
+	assert.NotEqual(t, path, RuleLockPath("/etc/freightliner/sync.state.json", "library/redis -> mirror/redis"))
+}
+
+func TestLockFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sync.state.locks", "rule.lock")
+	ctx := context.Background()
+
+	held, err := LockFile(ctx, path, replication.OverlapSkip)
+	require.NoError(t, err)
+
+	// Skip gives up at once while the lock is held
+	_, err = LockFile(ctx, path, "")
+	assert.ErrorIs(t, err, ErrRuleLocked)
+
+	// Queue waits for the holder, or until the context ends
+	timeout, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	_, err = LockFile(timeout, path, replication.OverlapQueue)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	original := ruleLockPoll
+	ruleLockPoll = 10 * time.Millisecond
+	defer func() { ruleLockPoll = original }()
+
+	acquired := make(chan *FileLock)
+	go func() {
+		lock, err := LockFile(ctx, path, replication.OverlapQueue)
+		assert.NoError(t, err)
+		acquired <- lock
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("queued lock acquired while held")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	require.NoError(t, held.Unlock())
+	select {
+	case lock := <-acquired:
+		require.NotNil(t, lock)
+		assert.NoError(t, lock.Unlock())
+	case <-time.After(time.Second):
+		t.Fatal("queued lock not acquired after release")
+	}
+}
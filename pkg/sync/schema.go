@@ -2,11 +2,15 @@
 package sync
 
 import (
+	"context"
 	"fmt"
-	"os"
 	"strings"
+	"time"
 
-	"gopkg.in/yaml.v3"
+	freightconfig "freightliner/pkg/config"
+	copyutil "freightliner/pkg/copy"
+	"freightliner/pkg/replication"
+	"freightliner/pkg/transform"
 )
 
 // Config represents the complete sync configuration
@@ -20,6 +24,20 @@ type Config struct {
 	// Images to sync with filtering rules
 	Images []ImageSync `yaml:"images"`
 
+	// Mirrors are further source and destination pairs with image rules of
+	// their own, synced in the same run with the settings below
+	Mirrors []Mirror `yaml:"mirrors,omitempty"`
+
+	// Prune rules remove old tags from destination repositories
+	Prune []PruneRule `yaml:"prune,omitempty"`
+
+	// GarbageCollection starts, or reminds about, the destination registry's
+	// garbage collection after prune rules remove tags
+	GarbageCollection *GarbageCollectionConfig `yaml:"garbage_collection,omitempty"`
+
+	// Generators expand into image and prune rules for many similar repositories
+	Generators []Generator `yaml:"generators,omitempty"`
+
 	// Parallel specifies number of concurrent sync operations
 	Parallel int `yaml:"parallel,omitempty"`
 
@@ -55,6 +73,36 @@ type Config struct {
 
 	// RetryBackoff specifies retry backoff in seconds (default: 5)
 	RetryBackoff int `yaml:"retry_backoff,omitempty"`
+
+	// Profile names a concurrency preset (conservative, balanced,
+	// aggressive) supplying parallel, timeout and retry settings left unset
+	Profile string `yaml:"profile,omitempty"`
+
+	// Policy decides per image whether it is copied, skipped or quarantined
+	Policy *PolicyConfig `yaml:"policy,omitempty"`
+
+	// InferDependencies reads the base image annotation of one image per
+	// repository and copies base images before the images built on them
+	InferDependencies bool `yaml:"infer_dependencies,omitempty"`
+}
+
+// PolicyConfig selects the Rego policy evaluated for every image before it is copied
+type PolicyConfig struct {
+	// URL is an OPA server data API endpoint returning the decision
+	URL string `yaml:"url,omitempty"`
+
+	// Files are Rego policy and data files evaluated with the opa CLI
+	Files []string `yaml:"files,omitempty"`
+
+	// Query is the rule evaluated for files (default: data.freightliner.replication.decision)
+	Query string `yaml:"query,omitempty"`
+
+	// OPABinary is the opa CLI used for files (default: opa on PATH)
+	OPABinary string `yaml:"opa_binary,omitempty"`
+
+	// QuarantinePrefix is prepended to the destination repository of
+	// quarantined images (default: quarantine/)
+	QuarantinePrefix string `yaml:"quarantine_prefix,omitempty"`
 }
 
 // RegistryConfig represents registry connection configuration
@@ -79,6 +127,15 @@ type RegistryConfig struct {
 
 	// Account for ECR
 	Account string `yaml:"account,omitempty"`
+
+	// MediaTypes restricts the manifests pushed to the destination registry.
+	// Defaults to the media_types of the matching named registry.
+	MediaTypes *copyutil.MediaTypePolicy `yaml:"media_types,omitempty"`
+
+	// LoopPrevention annotates images pushed to the destination registry with
+	// their mirror lineage and skips images that came from it, for mirrors
+	// chained or meshed together
+	LoopPrevention *copyutil.LoopPolicy `yaml:"loop_prevention,omitempty"`
 }
 
 // AuthConfig represents authentication configuration
@@ -131,6 +188,10 @@ type ImageSync struct {
 	// DestinationSuffix adds a suffix to destination tags
 	DestinationSuffix string `yaml:"destination_suffix,omitempty"`
 
+	// AliasTags also pushes each copied release under its semver aliases:
+	// "minor" adds 1.2 and "major" adds 1 for 1.2.3
+	AliasTags []string `yaml:"alias_tags,omitempty"`
+
 	// Limit limits the number of tags to sync
 	Limit int `yaml:"limit,omitempty"`
 
@@ -142,6 +203,57 @@ type ImageSync struct {
 
 	// SkipLayers allows skipping specific layers (advanced)
 	SkipLayers []string `yaml:"skip_layers,omitempty"`
+
+	// Regions reads an ECR source repository from each listed region, in
+	// order of preference. A tag found in several regions is copied once,
+	// from the first region that has it.
+	Regions []string `yaml:"regions,omitempty"`
+
+	// DiscoverRegions finds the regions containing the ECR source repository,
+	// probing Regions if set and all commercial regions otherwise
+	DiscoverRegions bool `yaml:"discover_regions,omitempty"`
+
+	// DependsOn lists source repositories, such as base images, whose images
+	// are copied before this rule's images
+	DependsOn []string `yaml:"depends_on,omitempty"`
+
+	// TagHistory keeps the last N digests each tag pointed to at the
+	// destination under <tag>-prev-1 ... <tag>-prev-N, so rollbacks remain
+	// possible after the source moves the tag
+	TagHistory int `yaml:"tag_history,omitempty"`
+
+	// Transform rewrites each image's layers and config on the way to the
+	// destination, applying the named transformers in order
+	Transform []transform.Spec `yaml:"transform,omitempty"`
+
+	// Profile runs this rule's images with a concurrency preset of their
+	// own: at most its worker count at once, at its request rate, with its
+	// timeout and retries
+	Profile string `yaml:"profile,omitempty"`
+
+	// Schedule is a cron expression, with seconds, limiting the rule to the
+	// runs of `sync --due` at which it has come due since it last ran
+	Schedule string `yaml:"schedule,omitempty"`
+
+	// Overlap is "skip" (default) or "queue" and decides what happens when a
+	// sync reaches a scheduled rule, or one that sets overlap, while another
+	// sync is still running it
+	Overlap replication.OverlapPolicy `yaml:"overlap,omitempty"`
+
+	// DigestChangeOnly copies only tags whose source digest changed since
+	// they were last synced, whether or not --since-last-success is given.
+	// Once the rule has synced, a source that reports an unchanged tag list
+	// ETag is skipped without listing tags or comparing manifests, so a tag
+	// moved to a new digest is only picked up with the next tag list change.
+	DigestChangeOnly bool `yaml:"digest_change_only,omitempty"`
+
+	// CreateMissingRepos overrides the create-missing-repos policy for the
+	// rule's destination repository: "true", "false" or "prompt"
+	CreateMissingRepos string `yaml:"create_missing_repos,omitempty"`
+
+	// RepositoryTemplate replaces replicate.repository_template for the
+	// destination repository when the rule creates it
+	RepositoryTemplate *freightconfig.RepositoryTemplateConfig `yaml:"repository_template,omitempty"`
 }
 
 // SignatureConfig represents signature verification configuration
@@ -160,35 +272,93 @@ type SignatureConfig struct {
 
 	// Issuer for keyless verification
 	Issuer string `yaml:"issuer,omitempty"`
+
+	// RequireRekorInclusion rejects images whose signatures are not in the
+	// Rekor transparency log with a valid inclusion proof
+	RequireRekorInclusion bool `yaml:"require_rekor_inclusion,omitempty"`
+
+	// RekorURL is the transparency log to query (default: public Rekor)
+	RekorURL string `yaml:"rekor_url,omitempty"`
+
+	// RekorBundle is a file of Rekor log entries used to verify inclusion
+	// offline instead of querying RekorURL
+	RekorBundle string `yaml:"rekor_bundle,omitempty"`
+
+	// RekorPublicKey is the PEM key that signs Rekor checkpoints. Required
+	// with rekor_bundle; fetched from RekorURL otherwise.
+	RekorPublicKey string `yaml:"rekor_public_key,omitempty"`
+
+	// VerifyTime is the time keyless certificates are checked against on
+	// hosts without a trusted clock: "rekor" for the time the signature was
+	// logged, confirmed by its inclusion proof, or an RFC 3339 timestamp
+	VerifyTime string `yaml:"verify_time,omitempty"`
+
+	// InsecureIgnoreCertTime accepts keyless certificates outside their
+	// validity window. Each signature accepted this way is logged.
+	InsecureIgnoreCertTime bool `yaml:"insecure_ignore_cert_time,omitempty"`
 }
 
-// LoadConfig loads and validates a sync configuration from a YAML file
-func LoadConfig(filename string) (*Config, error) {
-	data, err := os.ReadFile(filename)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read config file: %w", err)
-	}
+// VerifyTimeRekor checks certificates at their signature's Rekor integrated time
+const VerifyTimeRekor = "rekor"
 
-	var config Config
-	if err := yaml.Unmarshal(data, &config); err != nil {
-		return nil, fmt.Errorf("failed to parse YAML: %w", err)
+// validateCertTime checks the certificate time settings of sign_verification
+func (c *SignatureConfig) validateCertTime() error {
+	if c.VerifyTime == "" && !c.InsecureIgnoreCertTime {
+		return nil
 	}
-
-	// Validate configuration
-	if err := config.Validate(); err != nil {
-		return nil, fmt.Errorf("invalid configuration: %w", err)
+	if !c.Enabled || !c.KeylessVerification {
+		return fmt.Errorf("verify_time and insecure_ignore_cert_time require keyless_verification")
+	}
+	if c.VerifyTime != "" && c.InsecureIgnoreCertTime {
+		return fmt.Errorf("verify_time and insecure_ignore_cert_time are mutually exclusive")
+	}
+	switch {
+	case c.VerifyTime == VerifyTimeRekor:
+		if !c.RequireRekorInclusion {
+			return fmt.Errorf("verify_time %q requires require_rekor_inclusion", VerifyTimeRekor)
+		}
+	case c.VerifyTime != "":
+		if _, err := time.Parse(time.RFC3339, c.VerifyTime); err != nil {
+			return fmt.Errorf("verify_time must be %q or an RFC 3339 timestamp: %w", VerifyTimeRekor, err)
+		}
 	}
+	return nil
+}
 
-	// Set defaults
-	config.SetDefaults()
+// LoadConfig loads and validates a sync configuration from a YAML file, or
+// from one of the locations NewConfigSource accepts
+func LoadConfig(filename string) (*Config, error) {
+	return LoadConfigWithProfile(filename, "")
+}
 
-	return &config, nil
+// LoadConfigWithProfile loads a sync configuration like LoadConfig, using
+// profile instead of the file's own profile when set
+func LoadConfigWithProfile(filename, profile string) (*Config, error) {
+	source, err := NewConfigSource(filename)
+	if err != nil {
+		return nil, err
+	}
+	config, _, err := LoadConfigFromSource(context.Background(), source, profile)
+	return config, err
 }
 
 // Validate validates the configuration
 func (c *Config) Validate() error {
-	// Validate source registry
-	if c.Source.Registry == "" {
+	if err := c.validateMirrors(); err != nil {
+		return err
+	}
+	if len(c.Mirrors) > 0 && c.Destination.Registry == "" && c.topLevelEmpty() {
+		return nil
+	}
+
+	// Validate source registry; prune-only configurations need no source
+	needsSource := len(c.Images) > 0 || (len(c.Prune) == 0 && len(c.Generators) == 0)
+	for _, g := range c.Generators {
+		if g.Image != nil || (g.NeedsCatalog() && g.Catalog != CatalogDestination) {
+			needsSource = true
+		}
+	}
+	if c.Source.Registry == "" && needsSource {
 		return fmt.Errorf("source.registry is required")
 	}
 
@@ -196,10 +366,43 @@ func (c *Config) Validate() error {
 	if c.Destination.Registry == "" {
 		return fmt.Errorf("destination.registry is required")
 	}
+	if err := c.Destination.MediaTypes.Validate(); err != nil {
+		return fmt.Errorf("destination.media_types: %w", err)
+	}
+	if err := c.Destination.LoopPrevention.Validate(); err != nil {
+		return fmt.Errorf("destination.loop_prevention: %w", err)
+	}
 
 	// Validate images
-	if len(c.Images) == 0 {
-		return fmt.Errorf("at least one image must be specified")
+	if len(c.Images) == 0 && len(c.Prune) == 0 && len(c.Generators) == 0 {
+		return fmt.Errorf("at least one image must be specified, or a prune rule")
+	}
+
+	for i, g := range c.Generators {
+		if err := g.Validate(); err != nil {
+			return fmt.Errorf("generators[%d]: %w", i, err)
+		}
+	}
+
+	for i, rule := range c.Prune {
+		if err := rule.Validate(); err != nil {
+			return fmt.Errorf("prune[%d]: %w", i, err)
+		}
+	}
+
+	if c.Profile != "" {
+		if _, err := freightconfig.LookupProfile(c.Profile); err != nil {
+			return fmt.Errorf("profile: %w", err)
+		}
+	}
+
+	if p := c.Policy; p != nil {
+		if (p.URL == "") == (len(p.Files) == 0) {
+			return fmt.Errorf("policy: exactly one of url or files is required")
+		}
+		if p.URL != "" && p.Query != "" {
+			return fmt.Errorf("policy: query applies to files; put the rule path in url")
+		}
 	}
 
 	for i, img := range c.Images {
@@ -231,6 +434,78 @@ func (c *Config) Validate() error {
 		if filterCount > 1 {
 			return fmt.Errorf("images[%d]: cannot specify multiple tag filters (tags, tag_regex, semver_constraint, all_tags, latest_n)", i)
 		}
+
+		for _, level := range img.AliasTags {
+			if level != AliasMajor && level != AliasMinor {
+				return fmt.Errorf("images[%d]: alias_tags entries must be %q or %q, got %q", i, AliasMajor, AliasMinor, level)
+			}
+		}
+
+		if sv := img.SignVerification; sv != nil && sv.RequireRekorInclusion {
+			if !sv.Enabled {
+				return fmt.Errorf("images[%d]: sign_verification.require_rekor_inclusion requires sign_verification.enabled", i)
+			}
+			if sv.RekorBundle != "" && sv.RekorPublicKey == "" {
+				return fmt.Errorf("images[%d]: sign_verification.rekor_bundle requires rekor_public_key", i)
+			}
+		}
+
+		if sv := img.SignVerification; sv != nil {
+			if err := sv.validateCertTime(); err != nil {
+				return fmt.Errorf("images[%d]: sign_verification: %w", i, err)
+			}
+		}
+
+		if img.Profile != "" {
+			if _, err := freightconfig.LookupProfile(img.Profile); err != nil {
+				return fmt.Errorf("images[%d]: profile: %w", i, err)
+			}
+		}
+
+		if img.Schedule != "" {
+			if _, err := scheduleParser.Parse(img.Schedule); err != nil {
+				return fmt.Errorf("images[%d]: invalid schedule %q: %w", i, img.Schedule, err)
+			}
+		}
+
+		switch img.CreateMissingRepos {
+		case "", freightconfig.CreateMissingReposTrue, freightconfig.CreateMissingReposFalse, freightconfig.CreateMissingReposPrompt:
+		default:
+			return fmt.Errorf("images[%d]: create_missing_repos must be one of: true, false, prompt, got %q", i, img.CreateMissingRepos)
+		}
+
+		if err := replication.ValidateOverlapPolicy(img.Overlap); err != nil {
+			return fmt.Errorf("images[%d]: %w", i, err)
+		}
+
+		if img.TagHistory < 0 {
+			return fmt.Errorf("images[%d]: tag_history must not be negative", i)
+		}
+
+		if _, err := transform.NewPipeline(img.Transform); err != nil {
+			return fmt.Errorf("images[%d]: transform: %w", i, err)
+		}
+
+		for _, dep := range img.DependsOn {
+			if strings.TrimSpace(dep) == "" {
+				return fmt.Errorf("images[%d]: depends_on must not contain empty entries", i)
+			}
+		}
+
+		if len(img.Regions) > 0 || img.DiscoverRegions {
+			sourceType := c.Source.Type
+			if sourceType == "" {
+				sourceType = detectRegistryType(c.Source.Registry)
+			}
+			if sourceType != "ecr" || strings.HasPrefix(c.Source.Registry, "public.ecr.aws") {
+				return fmt.Errorf("images[%d]: regions and discover_regions require a private ECR source", i)
+			}
+			for _, region := range img.Regions {
+				if strings.TrimSpace(region) == "" {
+					return fmt.Errorf("images[%d]: regions must not contain empty entries", i)
+				}
+			}
+		}
 	}
 
 	return nil
@@ -238,6 +513,7 @@ func (c *Config) Validate() error {
 
 // SetDefaults sets default values for optional fields
 func (c *Config) SetDefaults() {
+	c.applyProfile()
 	if c.Parallel <= 0 {
 		c.Parallel = 3
 	}
@@ -261,6 +537,10 @@ func (c *Config) SetDefaults() {
 	}
 	// EnableAdaptiveBatching defaults to false for backward compatibility
 
+	if c.Policy != nil && c.Policy.QuarantinePrefix == "" {
+		c.Policy.QuarantinePrefix = "quarantine/"
+	}
+
 	// Set default registry types if not specified
 	if c.Source.Type == "" {
 		c.Source.Type = detectRegistryType(c.Source.Registry)
@@ -268,6 +548,15 @@ func (c *Config) SetDefaults() {
 	if c.Destination.Type == "" {
 		c.Destination.Type = detectRegistryType(c.Destination.Registry)
 	}
+	for i := range c.Mirrors {
+		mirror := &c.Mirrors[i]
+		if mirror.Source.Type == "" {
+			mirror.Source.Type = detectRegistryType(mirror.Source.Registry)
+		}
+		if mirror.Destination.Type == "" {
+			mirror.Destination.Type = detectRegistryType(mirror.Destination.Registry)
+		}
+	}
 }
 
 // detectRegistryType detects registry type from URL
@@ -308,6 +597,38 @@ type SyncTask struct {
 	Architecture     string
 	SignVerification *SignatureConfig
 	Priority         int
+
+	// Rule is the RuleKey of the image rule the task came from
+	Rule string
+
+	// SourceDigest is the source manifest digest seen when the task was
+	// planned, if it was looked up
+	SourceDigest string
+
+	// AliasTags are further destination tags pointed at the copied manifest
+	AliasTags []string
+
+	// DependsOn are the source repositories copied before this task
+	DependsOn []string
+
+	// Stage is the task's position in dependency order; tasks of a stage
+	// run after all tasks of earlier stages
+	Stage int
+
+	// TagHistory is the number of earlier digests of the tag kept under
+	// history tags at the destination
+	TagHistory int
+
+	// Transforms are applied to the image before it is pushed
+	Transforms []transform.Spec
+
+	// Profile is the concurrency preset of the task's rule, if it has one
+	Profile string
+
+	// CreateMissingRepos and RepositoryTemplate override the run's
+	// auto-creation policy and template for the destination repository
+	CreateMissingRepos string
+	RepositoryTemplate *freightconfig.RepositoryTemplateConfig
 }
 
 // SyncResult represents the result of a sync operation
@@ -5,6 +5,8 @@ import (
 	"path/filepath"
 	"testing"
 
+	"freightliner/pkg/transform"
+
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -295,6 +297,222 @@ func TestConfig_Validate(t *testing.T) {
 			expectError: true,
 			errorMsg:    "must specify at least one of",
 		},
+		{
+			name: "multi-region ECR source",
+			config: Config{
+				Source:      RegistryConfig{Registry: "123456789012.dkr.ecr.us-west-2.amazonaws.com"},
+				Destination: RegistryConfig{Registry: "my-registry.io"},
+				Images: []ImageSync{
+					{Repository: "team/app", AllTags: true, Regions: []string{"us-west-2", "eu-west-1"}},
+					{Repository: "team/api", AllTags: true, DiscoverRegions: true},
+				},
+			},
+			expectError: false,
+		},
+		{
+			name: "unknown alias level",
+			config: Config{
+				Source:      RegistryConfig{Registry: "docker.io"},
+				Destination: RegistryConfig{Registry: "my-registry.io"},
+				Images: []ImageSync{
+					{Repository: "library/nginx", SemverConstraint: ">=1.0.0", AliasTags: []string{"minor", "patch"}},
+				},
+			},
+			expectError: true,
+			errorMsg:    "alias_tags entries must be",
+		},
+		{
+			name: "regions on non-ECR source",
+			config: Config{
+				Source:      RegistryConfig{Registry: "docker.io"},
+				Destination: RegistryConfig{Registry: "my-registry.io"},
+				Images: []ImageSync{
+					{Repository: "library/nginx", AllTags: true, Regions: []string{"us-west-2"}},
+				},
+			},
+			expectError: true,
+			errorMsg:    "require a private ECR source",
+		},
+		{
+			name: "offline rekor bundle without public key",
+			config: Config{
+				Source:      RegistryConfig{Registry: "docker.io"},
+				Destination: RegistryConfig{Registry: "my-registry.io"},
+				Images: []ImageSync{
+					{Repository: "library/nginx", AllTags: true, SignVerification: &SignatureConfig{
+						Enabled: true, RequireRekorInclusion: true, RekorBundle: "rekor.json",
+					}},
+				},
+			},
+			expectError: true,
+			errorMsg:    "requires rekor_public_key",
+		},
+		{
+			name: "verify time at rekor integrated time without inclusion proofs",
+			config: Config{
+				Source:      RegistryConfig{Registry: "docker.io"},
+				Destination: RegistryConfig{Registry: "my-registry.io"},
+				Images: []ImageSync{
+					{Repository: "library/nginx", AllTags: true, SignVerification: &SignatureConfig{
+						Enabled: true, KeylessVerification: true, VerifyTime: "rekor",
+					}},
+				},
+			},
+			expectError: true,
+			errorMsg:    "requires require_rekor_inclusion",
+		},
+		{
+			name: "malformed verify time",
+			config: Config{
+				Source:      RegistryConfig{Registry: "docker.io"},
+				Destination: RegistryConfig{Registry: "my-registry.io"},
+				Images: []ImageSync{
+					{Repository: "library/nginx", AllTags: true, SignVerification: &SignatureConfig{
+						Enabled: true, KeylessVerification: true, VerifyTime: "yesterday",
+					}},
+				},
+			},
+			expectError: true,
+			errorMsg:    "RFC 3339",
+		},
+		{
+			name: "verify time with ignored certificate time",
+			config: Config{
+				Source:      RegistryConfig{Registry: "docker.io"},
+				Destination: RegistryConfig{Registry: "my-registry.io"},
+				Images: []ImageSync{
+					{Repository: "library/nginx", AllTags: true, SignVerification: &SignatureConfig{
+						Enabled: true, KeylessVerification: true, VerifyTime: "2026-01-02T03:04:05Z", InsecureIgnoreCertTime: true,
+					}},
+				},
+			},
+			expectError: true,
+			errorMsg:    "mutually exclusive",
+		},
+		{
+			name: "ignored certificate time without keyless verification",
+			config: Config{
+				Source:      RegistryConfig{Registry: "docker.io"},
+				Destination: RegistryConfig{Registry: "my-registry.io"},
+				Images: []ImageSync{
+					{Repository: "library/nginx", AllTags: true, SignVerification: &SignatureConfig{
+						Enabled: true, PublicKey: "cosign.pub", InsecureIgnoreCertTime: true,
+					}},
+				},
+			},
+			expectError: true,
+			errorMsg:    "require keyless_verification",
+		},
+		{
+			name: "pinned verify time",
+			config: Config{
+				Source:      RegistryConfig{Registry: "docker.io"},
+				Destination: RegistryConfig{Registry: "my-registry.io"},
+				Images: []ImageSync{
+					{Repository: "library/nginx", AllTags: true, SignVerification: &SignatureConfig{
+						Enabled: true, KeylessVerification: true, VerifyTime: "2026-01-02T03:04:05Z",
+					}},
+				},
+			},
+			expectError: false,
+		},
+		{
+			name: "rekor inclusion without verification enabled",
+			config: Config{
+				Source:      RegistryConfig{Registry: "docker.io"},
+				Destination: RegistryConfig{Registry: "my-registry.io"},
+				Images: []ImageSync{
+					{Repository: "library/nginx", AllTags: true, SignVerification: &SignatureConfig{RequireRekorInclusion: true}},
+				},
+			},
+			expectError: true,
+			errorMsg:    "requires sign_verification.enabled",
+		},
+		{
+			name: "policy from OPA server",
+			config: Config{
+				Source:      RegistryConfig{Registry: "docker.io"},
+				Destination: RegistryConfig{Registry: "my-registry.io"},
+				Images:      []ImageSync{{Repository: "library/nginx", AllTags: true}},
+				Policy:      &PolicyConfig{URL: "http://opa:8181/v1/data/freightliner/replication/decision"},
+			},
+			expectError: false,
+		},
+		{
+			name: "policy with both url and files",
+			config: Config{
+				Source:      RegistryConfig{Registry: "docker.io"},
+				Destination: RegistryConfig{Registry: "my-registry.io"},
+				Images:      []ImageSync{{Repository: "library/nginx", AllTags: true}},
+				Policy:      &PolicyConfig{URL: "http://opa:8181/v1/data/x", Files: []string{"policy.rego"}},
+			},
+			expectError: true,
+			errorMsg:    "exactly one of url or files",
+		},
+		{
+			name: "policy query with url",
+			config: Config{
+				Source:      RegistryConfig{Registry: "docker.io"},
+				Destination: RegistryConfig{Registry: "my-registry.io"},
+				Images:      []ImageSync{{Repository: "library/nginx", AllTags: true}},
+				Policy:      &PolicyConfig{URL: "http://opa:8181/v1/data/x", Query: "data.x"},
+			},
+			expectError: true,
+			errorMsg:    "query applies to files",
+		},
+		{
+			name: "invalid create_missing_repos",
+			config: Config{
+				Source:      RegistryConfig{Registry: "docker.io"},
+				Destination: RegistryConfig{Registry: "my-registry.io"},
+				Images:      []ImageSync{{Repository: "library/nginx", AllTags: true, CreateMissingRepos: "sometimes"}},
+			},
+			expectError: true,
+			errorMsg:    "create_missing_repos must be one of",
+		},
+		{
+			name: "negative tag history",
+			config: Config{
+				Source:      RegistryConfig{Registry: "docker.io"},
+				Destination: RegistryConfig{Registry: "my-registry.io"},
+				Images:      []ImageSync{{Repository: "library/nginx", Tags: []string{"stable"}, TagHistory: -1}},
+			},
+			expectError: true,
+			errorMsg:    "tag_history must not be negative",
+		},
+		{
+			name: "unknown transformer",
+			config: Config{
+				Source:      RegistryConfig{Registry: "docker.io"},
+				Destination: RegistryConfig{Registry: "my-registry.io"},
+				Images: []ImageSync{{
+					Repository: "library/nginx",
+					Tags:       []string{"stable"},
+					Transform:  []transform.Spec{{Name: "strip-files", Options: map[string]interface{}{"paths": []interface{}{"etc/secret"}}}, {Name: "squash"}},
+				}},
+			},
+			expectError: true,
+			errorMsg:    "unknown transformer",
+		},
+		{
+			name: "prune-only config",
+			config: Config{
+				Destination: RegistryConfig{Registry: "my-registry.io"},
+				Prune: []PruneRule{
+					{Repository: "mirror/nginx", KeepLast: 10, ProtectedTags: []string{"latest"}, Schedule: "0 0 3 * * *"},
+				},
+			},
+			expectError: false,
+		},
+		{
+			name: "invalid prune rule",
+			config: Config{
+				Destination: RegistryConfig{Registry: "my-registry.io"},
+				Prune:       []PruneRule{{Repository: "mirror/nginx", MaxAge: "30 days"}},
+			},
+			expectError: true,
+			errorMsg:    "prune[0]: invalid max_age",
+		},
 	}
 
 	for _, tt := range tests {
@@ -321,6 +539,58 @@ func TestConfig_SetDefaults(t *testing.T) {
 	assert.Equal(t, 300, config.Timeout)
 	assert.Equal(t, 3, config.RetryAttempts)
 	assert.Equal(t, 5, config.RetryBackoff)
+	assert.Nil(t, config.Policy)
+}
+
+func TestConfig_SetDefaults_PolicyQuarantinePrefix(t *testing.T) {
+	config := &Config{Policy: &PolicyConfig{URL: "http://opa:8181/v1/data/x"}}
+	config.SetDefaults()
+	assert.Equal(t, "quarantine/", config.Policy.QuarantinePrefix)
+
+	config = &Config{Policy: &PolicyConfig{URL: "http://opa:8181/v1/data/x", QuarantinePrefix: "held/"}}
+	config.SetDefaults()
+	assert.Equal(t, "held/", config.Policy.QuarantinePrefix)
+}
+
+func TestConfig_SetDefaults_Profile(t *testing.T) {
+	// The profile fills unset values; values in the file still win
+	config := &Config{Profile: "conservative", Parallel: 6}
+	config.SetDefaults()
+
+	assert.Equal(t, 6, config.Parallel)
+	assert.Equal(t, 1800, config.Timeout)
+	assert.Equal(t, 5, config.RetryAttempts)
+	assert.Equal(t, 10, config.RetryBackoff)
+	assert.Equal(t, 10, config.BatchSize)
+}
+
+func TestLoadConfigWithProfile(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(configFile, []byte(`
+source:
+  registry: "docker.io"
+destination:
+  registry: "my-registry.io"
+profile: conservative
+images:
+  - repository: "library/nginx"
+    tags: ["latest"]
+    profile: aggressive
+`), 0644))
+
+	config, err := LoadConfig(configFile)
+	require.NoError(t, err)
+	assert.Equal(t, 2, config.Parallel)
+	assert.Equal(t, "aggressive", config.Images[0].Profile)
+
+	// A profile chosen for the run replaces the file's
+	config, err = LoadConfigWithProfile(configFile, "aggressive")
+	require.NoError(t, err)
+	assert.Equal(t, "aggressive", config.Profile)
+	assert.Equal(t, 24, config.Parallel)
+
+	_, err = LoadConfigWithProfile(configFile, "turbo")
+	assert.ErrorContains(t, err, "profile")
 }
 
 func TestConfig_SetDefaults_WithExistingValues(t *testing.T) {
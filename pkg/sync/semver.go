@@ -200,6 +200,84 @@ func SortSemverTags(tags []string) []string {
 	return sorted
 }
 
+// Levels accepted in alias_tags
+const (
+	AliasMajor = "major"
+	AliasMinor = "minor"
+)
+
+// SemverAliases returns, per tag, the alias tags it is also pushed as for
+// levels, keeping a prefix such as "v". Each alias goes to the highest release
+// on its line among tags, so of 1.2.3 and 1.2.4 only 1.2.4 gets "1.2".
+// Prereleases, tags that are not full major.minor.patch versions and aliases
+// that are themselves in tags are left out.
+func SemverAliases(tags []string, levels []string) map[string][]string {
+	aliases := make(map[string][]string)
+	if len(levels) == 0 {
+		return aliases
+	}
+
+	present := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		present[tag] = true
+	}
+
+	type owner struct {
+		tag     string
+		version *semver.Version
+	}
+	owners := make(map[string]owner)
+	var order []string
+
+	for _, tag := range tags {
+		prefix, cleaned := "", tag
+		for _, p := range []string{"v", "V", "release-", "version-", "ver-"} {
+			if strings.HasPrefix(tag, p) {
+				prefix, cleaned = tag[:len(p)], tag[len(p):]
+				break
+			}
+		}
+
+		v, err := semver.StrictNewVersion(cleaned)
+		if err != nil || v.Prerelease() != "" {
+			continue
+		}
+
+		for _, level := range levels {
+			var alias string
+			switch level {
+			case AliasMajor:
+				alias = fmt.Sprintf("%s%d", prefix, v.Major())
+			case AliasMinor:
+				alias = fmt.Sprintf("%s%d.%d", prefix, v.Major(), v.Minor())
+			default:
+				continue
+			}
+			if present[alias] {
+				continue
+			}
+
+			current, ok := owners[alias]
+			if !ok {
+				order = append(order, alias)
+			}
+			if !ok || v.GreaterThan(current.version) {
+				owners[alias] = owner{tag: tag, version: v}
+			}
+		}
+	}
+
+	for _, alias := range order {
+		tag := owners[alias].tag
+		aliases[tag] = append(aliases[tag], alias)
+	}
+	// Most specific alias first
+	for _, list := range aliases {
+		sort.SliceStable(list, func(i, j int) bool { return len(list[i]) > len(list[j]) })
+	}
+	return aliases
+}
+
 // GetMajorVersions groups tags by major version
 func GetMajorVersions(tags []string) map[uint64][]string {
 	groups := make(map[uint64][]string)
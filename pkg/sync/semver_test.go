@@ -422,3 +422,21 @@ func TestSemverConstraintExamples(t *testing.T) {
 		})
 	}
 }
+
+func TestSemverAliases(t *testing.T) {
+	tags := []string{"1.2.3", "1.2.4", "1.3.0", "2.0.0-rc.1", "latest", "v3.1.0", "v3.0.9", "4.1.0", "4.1", "1.10"}
+
+	aliases := SemverAliases(tags, []string{AliasMinor, AliasMajor})
+
+	assert.Equal(t, map[string][]string{
+		"1.2.4":  {"1.2"},
+		"1.3.0":  {"1.3", "1"},
+		"v3.0.9": {"v3.0"},
+		"v3.1.0": {"v3.1", "v3"},
+		"4.1.0":  {"4"},
+	}, aliases)
+
+	assert.Empty(t, SemverAliases(tags, nil))
+	assert.Equal(t, map[string][]string{"1.3.0": {"1"}, "v3.1.0": {"v3"}, "4.1.0": {"4"}},
+		SemverAliases(tags, []string{AliasMajor}))
+}
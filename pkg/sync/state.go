@@ -0,0 +1,218 @@
+package sync
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"freightliner/pkg/replication"
+)
+
+// State records, per image rule, when the rule last ran, when it last synced
+// without failures and the source digest of every tag it copied. Runs with
+// --since-last-success use it to skip tags that have not changed, and runs
+// with --due to skip scheduled rules that have not come due.
+type State struct {
+	Rules map[string]*RuleState `json:"rules"`
+
+	path string
+}
+
+// RuleState is the recorded outcome of one image rule
+type RuleState struct {
+	// LastSuccess is when the last run in which every tag of the rule
+	// synced successfully started
+	LastSuccess time.Time `json:"last_success,omitempty"`
+
+	// LastRun is when the last run that included the rule started, whatever
+	// its outcome; scheduled rules come due relative to it
+	LastRun time.Time `json:"last_run,omitempty"`
+
+	// Digests maps source tags to the digest last copied
+	Digests map[string]string `json:"digests"`
+
+	// TagListETags maps source registries to the tag list ETag they reported
+	// when the rule last synced, for digest_change_only rules
+	TagListETags map[string]string `json:"tag_list_etags,omitempty"`
+}
+
+// DefaultStatePath returns the state file kept next to a sync config:
+// sync.yaml uses sync.state.json
+func DefaultStatePath(configFile string) string {
+	return strings.TrimSuffix(configFile, filepath.Ext(configFile)) + ".state.json"
+}
+
+// RuleKey identifies an image rule in the state file by its source and
+// destination, so reordering rules in the config keeps their history
+func RuleKey(img ImageSync) string {
+	dest := img.Repository
+	if img.DestinationRepository != "" {
+		dest = img.DestinationRepository
+	}
+	key := img.Repository + " -> " + dest
+	if img.DestinationPrefix != "" {
+		key += ":" + img.DestinationPrefix + "*"
+	}
+	return key
+}
+
+// LoadState reads the state file at path. A missing file yields an empty
+// state, so the first run syncs everything.
+func LoadState(path string) (*State, error) {
+	state := &State{Rules: make(map[string]*RuleState), path: path}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sync state: %w", err)
+	}
+
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("failed to parse sync state %s: %w", path, err)
+	}
+	if state.Rules == nil {
+		state.Rules = make(map[string]*RuleState)
+	}
+	return state, nil
+}
+
+// LastSuccess returns when rule last completed without failures, or the
+// zero time if it never has
+func (s *State) LastSuccess(rule string) time.Time {
+	if r := s.Rules[rule]; r != nil {
+		return r.LastSuccess
+	}
+	return time.Time{}
+}
+
+// LastRun returns when rule last ran, or the zero time if it never has
+func (s *State) LastRun(rule string) time.Time {
+	if r := s.Rules[rule]; r != nil {
+		return r.LastRun
+	}
+	return time.Time{}
+}
+
+// Changed reports whether tag must be synced: it has not been copied before,
+// its digest is unknown, or the digest differs from the one last copied
+func (s *State) Changed(rule, tag, digest string) bool {
+	r := s.Rules[rule]
+	if r == nil || digest == "" {
+		return true
+	}
+	return r.Digests[tag] != digest
+}
+
+// RecordTag stores the digest copied for tag
+func (s *State) RecordTag(rule, tag, digest string) {
+	if digest == "" {
+		return
+	}
+	s.rule(rule).Digests[tag] = digest
+}
+
+// TagListETag returns the tag list ETag recorded for registry, or "" when
+// none is or the rule has no recorded digests to fall back on yet
+func (s *State) TagListETag(rule, registry string) string {
+	r := s.Rules[rule]
+	if r == nil || len(r.Digests) == 0 {
+		return ""
+	}
+	return r.TagListETags[registry]
+}
+
+// RecordTagListETag stores the tag list ETag registry reported for rule
+func (s *State) RecordTagListETag(rule, registry, etag string) {
+	r := s.rule(rule)
+	if etag == "" {
+		delete(r.TagListETags, registry)
+		return
+	}
+	if r.TagListETags == nil {
+		r.TagListETags = make(map[string]string)
+	}
+	r.TagListETags[registry] = etag
+}
+
+// ClearTagListETags forgets the tag list ETags of rule, so its next run
+// lists tags and compares digests again
+func (s *State) ClearTagListETags(rule string) {
+	if r := s.Rules[rule]; r != nil {
+		r.TagListETags = nil
+	}
+}
+
+// MarkSuccess records that every tag of rule synced in the run started at
+func (s *State) MarkSuccess(rule string, at time.Time) {
+	s.rule(rule).LastSuccess = at
+}
+
+// MarkRun records that rule ran in the run started at
+func (s *State) MarkRun(rule string, at time.Time) {
+	s.rule(rule).LastRun = at
+}
+
+// Save writes the state to its file, replacing it atomically
+func (s *State) Save() error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode sync state: %w", err)
+	}
+
+	if dir := filepath.Dir(s.path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create sync state directory: %w", err)
+		}
+	}
+
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write sync state: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("failed to write sync state: %w", err)
+	}
+	return nil
+}
+
+// SaveRules writes the state of rules, keeping what other syncs saved for
+// the remaining rules since the state was loaded
+func (s *State) SaveRules(ctx context.Context, rules []string) error {
+	lock, err := LockFile(ctx, s.path+".lock", replication.OverlapQueue)
+	if err != nil {
+		return fmt.Errorf("failed to lock sync state: %w", err)
+	}
+	defer lock.Unlock()
+
+	current, err := LoadState(s.path)
+	if err != nil {
+		return err
+	}
+	for _, rule := range rules {
+		if r := s.Rules[rule]; r != nil {
+			current.Rules[rule] = r
+		}
+	}
+	s.Rules = current.Rules
+	return s.Save()
+}
+
+// rule returns the state of rule, creating it if needed
+func (s *State) rule(rule string) *RuleState {
+	r := s.Rules[rule]
+	if r == nil {
+		r = &RuleState{Digests: make(map[string]string)}
+		s.Rules[rule] = r
+	}
+	if r.Digests == nil {
+		r.Digests = make(map[string]string)
+	}
+	return r
+}
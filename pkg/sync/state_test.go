@@ -0,0 +1,100 @@
+package sync
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefaultStatePath(t *testing.T) {
+	assert.Equal(t, "configs/sync.state.json", DefaultStatePath("configs/sync.yaml"))
+	assert.Equal(t, "sync.state.json", DefaultStatePath("sync"))
+}
+
+func TestRuleKey(t *testing.T) {
+	assert.Equal(t, "library/nginx -> library/nginx", RuleKey(ImageSync{Repository: "library/nginx"}))
+	assert.Equal(t, "library/redis -> cache/redis:mirror-*", RuleKey(ImageSync{
+		Repository:            "library/redis",
+		DestinationRepository: "cache/redis",
+		DestinationPrefix:     "mirror-",
+	}))
+}
+
+func TestState_RoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "sync.state.json")
+
+	state, err := LoadState(path)
+	require.NoError(t, err)
+	assert.True(t, state.Changed("nginx", "1.25", "sha256:aaa"), "unknown tags are changed")
+	assert.True(t, state.LastSuccess("nginx").IsZero())
+
+	completed := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	state.RecordTag("nginx", "1.25", "sha256:aaa")
+	state.RecordTag("nginx", "1.26", "")
+	state.MarkSuccess("nginx", completed)
+	require.NoError(t, state.Save())
+
+	reloaded, err := LoadState(path)
+	require.NoError(t, err)
+	assert.True(t, reloaded.LastSuccess("nginx").Equal(completed))
+	assert.False(t, reloaded.Changed("nginx", "1.25", "sha256:aaa"))
+	assert.True(t, reloaded.Changed("nginx", "1.25", "sha256:bbb"), "retagged images are changed")
+	assert.True(t, reloaded.Changed("nginx", "1.25", ""), "tags with an unknown digest are changed")
+	assert.True(t, reloaded.Changed("nginx", "1.26", "sha256:ccc"), "tags without a recorded digest are changed")
+
+	_, err = os.Stat(path + ".tmp")
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestState_TagListETags(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sync.state.json")
+	state, err := LoadState(path)
+	require.NoError(t, err)
+
+	state.RecordTagListETag("nginx", "docker.io", `"abc"`)
+	assert.Empty(t, state.TagListETag("nginx", "docker.io"), "ETags are not trusted before digests are recorded")
+
+	state.RecordTag("nginx", "1.25", "sha256:aaa")
+	require.NoError(t, state.Save())
+
+	reloaded, err := LoadState(path)
+	require.NoError(t, err)
+	assert.Equal(t, `"abc"`, reloaded.TagListETag("nginx", "docker.io"))
+	assert.Empty(t, reloaded.TagListETag("nginx", "quay.io"))
+
+	reloaded.ClearTagListETags("nginx")
+	assert.Empty(t, reloaded.TagListETag("nginx", "docker.io"))
+}
+
+func TestState_SaveRules(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sync.state.json")
+	first, err := LoadState(path)
+	require.NoError(t, err)
+	second, err := LoadState(path)
+	require.NoError(t, err)
+
+	// Two syncs running different rules keep each other's results
+	first.RecordTag("nginx", "1.25", "sha256:aaa")
+	require.NoError(t, first.SaveRules(context.Background(), []string{"nginx"}))
+	second.RecordTag("redis", "7.2", "sha256:bbb")
+	second.RecordTag("nginx", "1.25", "sha256:stale")
+	require.NoError(t, second.SaveRules(context.Background(), []string{"redis"}))
+
+	reloaded, err := LoadState(path)
+	require.NoError(t, err)
+	assert.False(t, reloaded.Changed("nginx", "1.25", "sha256:aaa"))
+	assert.False(t, reloaded.Changed("redis", "7.2", "sha256:bbb"))
+}
+
+func TestLoadState_Invalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sync.state.json")
+	require.NoError(t, os.WriteFile(path, []byte("{not json"), 0600))
+
+	_, err := LoadState(path)
+	assert.Error(t, err)
+}
@@ -0,0 +1,146 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	copyutil "freightliner/pkg/copy"
+	"freightliner/pkg/interfaces"
+	"freightliner/pkg/transform"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+)
+
+// HistoryTag returns the destination tag holding the digest tag pointed to
+// n moves ago: prod-prev-1 is the digest prod pointed to before its latest
+// move. Registries do not allow "@" in tags, so the position is a suffix.
+func HistoryTag(tag string, n int) string {
+	return fmt.Sprintf("%s-prev-%d", tag, n)
+}
+
+// previousDigests returns up to keep digests the tag pointed to before its
+// current one, newest first. A digest the tag returned to is listed once,
+// at its most recent position.
+func previousDigests(history []interfaces.TagDigest, keep int) []string {
+	if len(history) == 0 {
+		return nil
+	}
+
+	seen := map[string]bool{history[0].Digest: true}
+	var digests []string
+	for _, entry := range history[1:] {
+		if len(digests) == keep {
+			break
+		}
+		if seen[entry.Digest] {
+			continue
+		}
+		seen[entry.Digest] = true
+		digests = append(digests, entry.Digest)
+	}
+	return digests
+}
+
+// sourceTagHistory returns the history of the task's source tag when the
+// source registry records one, or nil when history must be kept by rotating
+// the destination tags instead
+func (be *BatchExecutor) sourceTagHistory(ctx context.Context, task SyncTask, sourceRepo interfaces.Repository) []interfaces.TagDigest {
+	lister, ok := sourceRepo.(interfaces.TagHistoryLister)
+	if !ok {
+		return nil
+	}
+
+	history, err := lister.ListTagHistory(ctx, task.SourceTag)
+	if err != nil {
+		be.logger.WithFields(map[string]interface{}{
+			"repository": task.SourceRepository,
+			"tag":        task.SourceTag,
+			"error":      err.Error(),
+		}).Warn("Failed to list source tag history, keeping history from earlier destination digests")
+		return nil
+	}
+	return history
+}
+
+// copyTagHistory copies the digests the source tag pointed to before its
+// current one to the history tags of destRef, with the task's transforms
+// applied, skipping those already there
+func (be *BatchExecutor) copyTagHistory(ctx context.Context, copier *copyutil.Copier, task SyncTask, history []interfaces.TagDigest, sourceRef, destRef name.Reference, srcOpts, destOpts []remote.Option, transforms *transform.Pipeline) error {
+	for i, digest := range previousDigests(history, task.TagHistory) {
+		historyRef := destRef.Context().Tag(HistoryTag(task.DestTag, i+1))
+		if desc, err := remote.Head(historyRef, append(destOpts, remote.WithContext(ctx))...); err == nil && desc.Digest.String() == digest {
+			continue
+		}
+
+		source := sourceRef.Context().Digest(digest)
+		result, err := copier.CopyImage(ctx, source, historyRef, srcOpts, destOpts, copyutil.CopyOptions{
+			ForceOverwrite: true,
+			Source:         source,
+			Destination:    historyRef,
+			MediaTypes:     be.config.Destination.MediaTypes,
+			Loops:          be.config.Destination.LoopPrevention,
+			Transforms:     transforms,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to copy %s to history tag %s: %w", source, historyRef, err)
+		}
+		if !result.Success {
+			return fmt.Errorf("copy of %s to history tag %s reported failure", source, historyRef)
+		}
+	}
+	return nil
+}
+
+// rotateTagHistory moves the digest destRef points to into its first history
+// tag, shifting older history tags down and dropping those beyond keep,
+// before the tag is moved to sourceDigest. Nothing changes when the tag is
+// new, already points to sourceDigest, or was rotated by an earlier attempt.
+func rotateTagHistory(ctx context.Context, destRef name.Reference, tag, sourceDigest string, keep int, opts []remote.Option) error {
+	opts = append(append([]remote.Option{}, opts...), remote.WithContext(ctx))
+
+	current, err := remote.Get(destRef, opts...)
+	if isManifestNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read %s before moving it: %w", destRef, err)
+	}
+	if current.Digest.String() == sourceDigest {
+		return nil
+	}
+
+	historyRef := func(n int) name.Tag {
+		return destRef.Context().Tag(HistoryTag(tag, n))
+	}
+	if prev, err := remote.Head(historyRef(1), opts...); err == nil && prev.Digest == current.Digest {
+		return nil
+	}
+
+	for n := keep - 1; n >= 1; n-- {
+		desc, err := remote.Get(historyRef(n), opts...)
+		if isManifestNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read history tag %s: %w", historyRef(n), err)
+		}
+		if err := remote.Tag(historyRef(n+1), desc, opts...); err != nil {
+			return fmt.Errorf("failed to move history tag %s: %w", historyRef(n+1), err)
+		}
+	}
+
+	if err := remote.Tag(historyRef(1), current, opts...); err != nil {
+		return fmt.Errorf("failed to push history tag %s: %w", historyRef(1), err)
+	}
+	return nil
+}
+
+// isManifestNotFound reports whether err means the manifest does not exist
+func isManifestNotFound(err error) bool {
+	var terr *transport.Error
+	return errors.As(err, &terr) && terr.StatusCode == http.StatusNotFound
+}
@@ -0,0 +1,80 @@
+package sync
+
+import (
+	"context"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"freightliner/pkg/interfaces"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPreviousDigests(t *testing.T) {
+	history := []interfaces.TagDigest{
+		{Tag: "prod", Digest: "sha256:d"},
+		{Tag: "prod", Digest: "sha256:c"},
+		{Tag: "prod", Digest: "sha256:d"},
+		{Tag: "prod", Digest: "sha256:b"},
+		{Tag: "prod", Digest: "sha256:c"},
+		{Tag: "prod", Digest: "sha256:a"},
+	}
+
+	assert.Equal(t, []string{"sha256:c", "sha256:b"}, previousDigests(history, 2))
+	assert.Equal(t, []string{"sha256:c", "sha256:b", "sha256:a"}, previousDigests(history, 5))
+	assert.Empty(t, previousDigests(history[:1], 3))
+	assert.Empty(t, previousDigests(nil, 3))
+}
+
+func TestRotateTagHistory(t *testing.T) {
+	server := httptest.NewServer(registry.New())
+	defer server.Close()
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	repoRef, err := name.NewRepository(u.Host + "/mirror/app")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	prod := repoRef.Tag("prod")
+
+	// Each push of prod moves the digest it pointed to into the history tags
+	var digests []v1.Hash
+	for i := 0; i < 4; i++ {
+		img, err := random.Image(128, 1)
+		require.NoError(t, err)
+		digest, err := img.Digest()
+		require.NoError(t, err)
+		digests = append(digests, digest)
+
+		require.NoError(t, rotateTagHistory(ctx, prod, "prod", digest.String(), 2, nil))
+		require.NoError(t, remote.Write(prod, img))
+	}
+
+	digestOf := func(tag string) string {
+		desc, err := remote.Head(repoRef.Tag(tag))
+		require.NoError(t, err)
+		return desc.Digest.String()
+	}
+	assert.Equal(t, digests[3].String(), digestOf("prod"))
+	assert.Equal(t, digests[2].String(), digestOf(HistoryTag("prod", 1)))
+	assert.Equal(t, digests[1].String(), digestOf(HistoryTag("prod", 2)))
+	_, err = remote.Head(repoRef.Tag(HistoryTag("prod", 3)))
+	assert.Error(t, err, "history beyond tag_history is dropped")
+
+	// A retried copy of the same move does not rotate again
+	require.NoError(t, rotateTagHistory(ctx, prod, "prod", "sha256:next", 2, nil))
+	require.NoError(t, rotateTagHistory(ctx, prod, "prod", "sha256:next", 2, nil))
+	assert.Equal(t, digests[3].String(), digestOf(HistoryTag("prod", 1)))
+	assert.Equal(t, digests[2].String(), digestOf(HistoryTag("prod", 2)))
+
+	// Syncing the digest prod already points to keeps the history as is
+	require.NoError(t, rotateTagHistory(ctx, prod, "prod", digests[3].String(), 2, nil))
+	assert.Equal(t, digests[3].String(), digestOf(HistoryTag("prod", 1)))
+}
@@ -0,0 +1,293 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"freightliner/pkg/client"
+	"freightliner/pkg/interfaces"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// Trash defaults
+const (
+	DefaultTrashNamespace = "trash"
+	DefaultTrashRetention = 7 * 24 * time.Hour
+)
+
+// trashTimeFormat is the UTC time appended to trashed tags. Empty-trash reads
+// it back to decide when a tag's retention is over.
+const trashTimeFormat = "20060102150405"
+
+// maxTagLength is the longest tag the OCI distribution spec allows
+const maxTagLength = 128
+
+// TrashPolicy moves the tags a prune rule removes into a trash repository,
+// <namespace>/<repository>, as <tag>-<time trashed>. They can be copied back
+// until empty-trash deletes them once the retention is over.
+type TrashPolicy struct {
+	// Namespace is the repository prefix of the trash (default "trash")
+	Namespace string `yaml:"namespace,omitempty"`
+
+	// Retention is how long trashed tags are kept (default "168h")
+	Retention string `yaml:"retention,omitempty"`
+}
+
+// Validate checks the namespace and retention
+func (t *TrashPolicy) Validate() error {
+	if t == nil {
+		return nil
+	}
+	if _, err := t.retention(); err != nil {
+		return err
+	}
+	namespace := t.namespace()
+	if strings.HasPrefix(namespace, "/") || strings.HasSuffix(namespace, "/") {
+		return fmt.Errorf("invalid trash namespace %q", t.Namespace)
+	}
+	if _, err := name.NewRepository("registry.invalid/" + namespace + "/repository"); err != nil {
+		return fmt.Errorf("invalid trash namespace %q: %w", t.Namespace, err)
+	}
+	return nil
+}
+
+// Repository returns the trash repository of repository
+func (t *TrashPolicy) Repository(repository string) string {
+	return t.namespace() + "/" + repository
+}
+
+// namespace returns the namespace, or the default
+func (t *TrashPolicy) namespace() string {
+	if t.Namespace == "" {
+		return DefaultTrashNamespace
+	}
+	return t.Namespace
+}
+
+// retention parses Retention, or returns the default
+func (t *TrashPolicy) retention() (time.Duration, error) {
+	if t.Retention == "" {
+		return DefaultTrashRetention, nil
+	}
+	retention, err := time.ParseDuration(t.Retention)
+	if err != nil || retention <= 0 {
+		return 0, fmt.Errorf("invalid trash retention %q", t.Retention)
+	}
+	return retention, nil
+}
+
+// trashTag returns the tag tag is trashed as at now, shortening tag so that
+// the result stays a valid tag
+func trashTag(tag string, now time.Time) string {
+	suffix := "-" + now.UTC().Format(trashTimeFormat)
+	if len(tag)+len(suffix) > maxTagLength {
+		tag = tag[:maxTagLength-len(suffix)]
+	}
+	return tag + suffix
+}
+
+// trashedAt returns when a trash tag was trashed; ok is false for tags
+// without the time suffix
+func trashedAt(tag string) (time.Time, bool) {
+	i := strings.LastIndex(tag, "-")
+	if i < 0 || len(tag)-i-1 != len(trashTimeFormat) {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(trashTimeFormat, tag[i+1:])
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// moveToTrash pushes the image of tag to the trash repository as a trash
+// tag and returns its reference. Layers are mounted from repo, so the
+// registry copies no blobs.
+func moveToTrash(ctx context.Context, repo PruneRepository, trash *TrashPolicy, tag string, now time.Time) (string, error) {
+	ref, err := repo.GetImageReference(tag)
+	if err != nil {
+		return "", err
+	}
+	opts, err := repo.GetRemoteOptions()
+	if err != nil {
+		return "", err
+	}
+	opts = append(opts, remote.WithContext(ctx))
+
+	trashRepo, err := name.NewRepository(ref.Context().RegistryStr() + "/" + trash.Repository(repo.GetRepositoryName()))
+	if err != nil {
+		return "", fmt.Errorf("invalid trash repository: %w", err)
+	}
+	trashRef := trashRepo.Tag(trashTag(tag, now))
+
+	desc, err := remote.Get(ref, opts...)
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case desc.MediaType.IsIndex():
+		index, err := desc.ImageIndex()
+		if err != nil {
+			return "", err
+		}
+		err = remote.WriteIndex(trashRef, index, opts...)
+	case desc.MediaType.IsImage():
+		img, err := desc.Image()
+		if err != nil {
+			return "", err
+		}
+		err = remote.Write(trashRef, img, opts...)
+	default:
+		err = remote.Tag(trashRef, desc, opts...)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to move %s to trash: %w", tag, err)
+	}
+	return trashRef.String(), nil
+}
+
+// ensureTrashRepository creates the trash repository of rule on registries,
+// such as ECR, that need repositories to exist before images are pushed.
+// Other registries create it on the first push.
+func (p *Pruner) ensureTrashRepository(ctx context.Context, registryClient interfaces.RegistryClient, rule PruneRule) error {
+	trashRepo := rule.Trash.Repository(rule.Repository)
+	if _, err := registryClient.GetRepository(ctx, trashRepo); err == nil {
+		return nil
+	}
+	if _, ok := registryClient.(interfaces.RepositoryCreator); !ok {
+		return nil
+	}
+	if _, err := interfaces.CreateRepository(ctx, registryClient, trashRepo, interfaces.RepositorySettings{}); err != nil {
+		return fmt.Errorf("failed to create trash repository %s: %w", trashRepo, err)
+	}
+	p.logger.WithFields(map[string]interface{}{
+		"repository": trashRepo,
+	}).Info("Created trash repository")
+	return nil
+}
+
+// EmptyTrashDestination empties the trash of rule in the destination
+// registry of cfg
+func (p *Pruner) EmptyTrashDestination(ctx context.Context, factory *client.Factory, cfg *Config, rule PruneRule, dryRun bool) (*PruneResult, error) {
+	if rule.Trash == nil {
+		return nil, fmt.Errorf("prune rule for %s has no trash", rule.Repository)
+	}
+
+	registryClient, err := factory.CreateClientForRegistry(ctx, cfg.Destination.Registry)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create client for registry %s: %w", cfg.Destination.Registry, err)
+	}
+
+	trashRepo := rule.Trash.Repository(rule.Repository)
+	repo, err := registryClient.GetRepository(ctx, trashRepo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get trash repository %s: %w", trashRepo, err)
+	}
+
+	result, err := p.EmptyTrash(ctx, repo, rule, dryRun)
+	if result != nil {
+		result.Registry = cfg.Destination.Registry
+	}
+	return result, err
+}
+
+// EmptyTrash deletes the tags of trash repository repo whose retention under
+// rule is over. Like Prune, a run is a dry run unless the rule enables
+// deletion and dryRun is false, and it honours the rule's freeze. Tags that
+// were not trashed by a prune are kept.
+func (p *Pruner) EmptyTrash(ctx context.Context, repo PruneRepository, rule PruneRule, dryRun bool) (*PruneResult, error) {
+	result := &PruneResult{
+		Repository: repo.GetRepositoryName(),
+		DryRun:     dryRun || !rule.Delete,
+		StartedAt:  p.now().UTC(),
+		Kept:       []PruneDecision{},
+		Removed:    []PruneDecision{},
+		Failed:     []PruneDecision{},
+	}
+	defer func() { result.FinishedAt = p.now().UTC() }()
+
+	retention, err := rule.Trash.retention()
+	if err != nil {
+		return result, err
+	}
+	if !result.DryRun {
+		if err := rule.Freeze.Check(rule.Repository, p.now()); err != nil {
+			return result, err
+		}
+	}
+
+	tags, err := repo.ListTags(ctx)
+	if err != nil {
+		return result, fmt.Errorf("failed to list tags: %w", err)
+	}
+
+	var deleter interfaces.ReferenceDeleter
+	if !result.DryRun {
+		var ok bool
+		if deleter, ok = repo.(interfaces.ReferenceDeleter); !ok {
+			return result, fmt.Errorf("registry does not support deleting tags")
+		}
+	}
+
+	var expired int
+	blobs := make(map[string]map[string]int64, len(tags))
+	for _, tag := range tags {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		decision := PruneDecision{Tag: tag, Action: PruneActionKeep}
+		trashed, ok := trashedAt(tag)
+		switch {
+		case !ok:
+			decision.Reason = "not a trashed tag"
+		case p.now().Sub(trashed) < retention:
+			decision.CreatedAt = trashed
+			decision.Reason = fmt.Sprintf("trashed less than %s ago", retention)
+		default:
+			decision.CreatedAt = trashed
+			decision.Action = PruneActionRemove
+			decision.Reason = fmt.Sprintf("trashed more than %s ago", retention)
+		}
+
+		// Sizes only feed the reclaimable estimate, so tags that cannot be
+		// inspected are still judged by their trash time
+		tm, tagBlobs, _ := inspectTag(ctx, repo, tag)
+		decision.Digest = tm.Digest
+		blobs[tag] = tagBlobs
+
+		if decision.Action == PruneActionKeep {
+			result.Kept = append(result.Kept, decision)
+			continue
+		}
+		expired++
+		if result.DryRun {
+			result.Removed = append(result.Removed, decision)
+			continue
+		}
+		if err := deleter.DeleteReference(ctx, tag); err != nil {
+			decision.Error = err.Error()
+			result.Failed = append(result.Failed, decision)
+			continue
+		}
+		result.Removed = append(result.Removed, decision)
+	}
+	result.ReclaimableBytes = reclaimableBytes(result.Kept, result.Removed, blobs)
+
+	p.logger.WithFields(map[string]interface{}{
+		"repository":        result.Repository,
+		"dry_run":           result.DryRun,
+		"kept":              len(result.Kept),
+		"removed":           len(result.Removed),
+		"failed":            len(result.Failed),
+		"reclaimable_bytes": result.ReclaimableBytes,
+	}).Info("Emptied trash")
+
+	if len(result.Failed) > 0 {
+		return result, fmt.Errorf("failed to remove %d of %d tags from %s", len(result.Failed), expired, result.Repository)
+	}
+	return result, nil
+}
@@ -0,0 +1,118 @@
+package sync
+
+import (
+	"context"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"freightliner/pkg/helper/log"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrashTag(t *testing.T) {
+	now := time.Date(2026, 10, 16, 14, 30, 5, 0, time.UTC)
+
+	tag := trashTag("build-42", now)
+	assert.Equal(t, "build-42-20261016143005", tag)
+	trashed, ok := trashedAt(tag)
+	require.True(t, ok)
+	assert.True(t, trashed.Equal(now))
+
+	long := trashTag(strings.Repeat("a", maxTagLength), now)
+	assert.Len(t, long, maxTagLength)
+	_, ok = trashedAt(long)
+	assert.True(t, ok)
+
+	for _, tag := range []string{"latest", "build-42", "v1-2026101614300"} {
+		_, ok := trashedAt(tag)
+		assert.False(t, ok, tag)
+	}
+}
+
+func TestTrashPolicy_Validate(t *testing.T) {
+	var none *TrashPolicy
+	assert.NoError(t, none.Validate())
+	assert.NoError(t, (&TrashPolicy{}).Validate())
+	assert.NoError(t, (&TrashPolicy{Namespace: "archive/trash", Retention: "72h"}).Validate())
+
+	for _, policy := range []*TrashPolicy{
+		{Retention: "a week"},
+		{Retention: "-1h"},
+		{Namespace: "/trash"},
+		{Namespace: "Trash"},
+	} {
+		assert.Error(t, policy.Validate(), "%+v", policy)
+	}
+
+	rule := PruneRule{Repository: "app", KeepLast: 1, Trash: &TrashPolicy{Retention: "soon"}}
+	assert.ErrorContains(t, rule.Validate(), "invalid trash retention")
+	assert.Equal(t, "trash/mirror/app", (&TrashPolicy{}).Repository("mirror/app"))
+}
+
+func TestPruner_Trash(t *testing.T) {
+	server := httptest.NewServer(registry.New())
+	defer server.Close()
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	repoRef, err := name.NewRepository(u.Host + "/mirror/app")
+	require.NoError(t, err)
+	trashRef, err := name.NewRepository(u.Host + "/trash/mirror/app")
+	require.NoError(t, err)
+
+	now := time.Now().UTC()
+	for tag, age := range map[string]time.Duration{"old": 60 * 24 * time.Hour, "new": time.Hour} {
+		img, err := random.Image(256, 1)
+		require.NoError(t, err)
+		img, err = mutate.CreatedAt(img, v1.Time{Time: now.Add(-age)})
+		require.NoError(t, err)
+		require.NoError(t, remote.Write(repoRef.Tag(tag), img))
+	}
+
+	repo := &registryRepository{repo: repoRef}
+	pruner := NewPruner(log.NewBasicLogger(log.ErrorLevel))
+	pruner.now = func() time.Time { return now }
+	rule := PruneRule{Repository: "mirror/app", MaxAge: "720h", Delete: true, Trash: &TrashPolicy{Retention: "168h"}}
+
+	// Removed tags are moved to the trash before they are deleted
+	result, err := pruner.Prune(context.Background(), repo, rule, false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"old"}, repo.deleted)
+	require.Len(t, result.Removed, 1)
+	trashedTag := trashTag("old", now)
+	assert.Equal(t, trashRef.Tag(trashedTag).String(), result.Removed[0].Trash)
+	assert.Zero(t, result.ReclaimableBytes, "trashed tags keep their blobs")
+
+	trashed, err := remote.Head(trashRef.Tag(trashedTag))
+	require.NoError(t, err)
+	assert.Equal(t, result.Removed[0].Digest, trashed.Digest.String())
+
+	// The trash keeps tags until the retention is over
+	trash := &registryRepository{repo: trashRef}
+	emptied, err := pruner.EmptyTrash(context.Background(), trash, rule, false)
+	require.NoError(t, err)
+	assert.Empty(t, emptied.Removed)
+	assert.Empty(t, trash.deleted)
+
+	pruner.now = func() time.Time { return now.Add(8 * 24 * time.Hour) }
+	emptied, err = pruner.EmptyTrash(context.Background(), trash, rule, true)
+	require.NoError(t, err)
+	assert.True(t, emptied.DryRun)
+	assert.Equal(t, []string{trashedTag}, decisionTags(emptied.Removed))
+	assert.Empty(t, trash.deleted)
+
+	emptied, err = pruner.EmptyTrash(context.Background(), trash, rule, false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{trashedTag}, trash.deleted)
+	assert.Greater(t, emptied.ReclaimableBytes, int64(256))
+}
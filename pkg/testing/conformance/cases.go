@@ -0,0 +1,193 @@
+package conformance
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"testing"
+
+	"freightliner/pkg/helper/errors"
+	"freightliner/pkg/interfaces"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Cases is the table of behaviors a registry client must show
+var Cases = []Case{
+	// Listing
+	{Name: "RegistryName", Run: func(t *testing.T, f *Fixture) {
+		assert.NotEmpty(t, f.Client.GetRegistryName())
+	}},
+	{Name: "ListRepositories", Run: func(t *testing.T, f *Fixture) {
+		repos, err := f.Client.ListRepositories(context.Background(), "")
+		require.NoError(t, err)
+		assert.ElementsMatch(t, f.Repositories(), repos, "every repository is listed once")
+	}},
+	{Name: "ListRepositoriesByPrefix", Run: func(t *testing.T, f *Fixture) {
+		repos, err := f.Client.ListRepositories(context.Background(), "conformance/")
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{RepoAlpha, RepoBeta, RepoPaged}, repos)
+	}},
+	{Name: "ListRepositoriesWithoutMatches", Run: func(t *testing.T, f *Fixture) {
+		repos, err := f.Client.ListRepositories(context.Background(), "missing/")
+		require.NoError(t, err, "no matches is not an error")
+		assert.Empty(t, repos)
+	}},
+	{Name: "ListRepositoriesCanceled", Run: func(t *testing.T, f *Fixture) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := f.Client.ListRepositories(ctx, "")
+		assert.Error(t, err, "a canceled context stops the listing")
+	}},
+	{Name: "ListTags", Run: func(t *testing.T, f *Fixture) {
+		tags, err := repository(t, f, RepoAlpha).ListTags(context.Background())
+		require.NoError(t, err)
+		assert.ElementsMatch(t, f.TagNames(RepoAlpha), tags)
+	}},
+
+	// Pagination
+	{Name: "ListTagsAcrossPages", Run: func(t *testing.T, f *Fixture) {
+		tags, err := repository(t, f, RepoPaged).ListTags(context.Background())
+		require.NoError(t, err)
+		assert.ElementsMatch(t, f.TagNames(RepoPaged), tags, "tags on every page are listed once")
+	}},
+	{Name: "PaginateRepositories", Run: func(t *testing.T, f *Fixture) {
+		paginated, ok := f.Client.(interfaces.PaginatedRepositoryLister)
+		if !ok {
+			t.Skip("client does not implement interfaces.PaginatedRepositoryLister")
+		}
+		ctx := context.Background()
+
+		var listed []string
+		for offset := 0; ; offset += 2 {
+			page, err := paginated.ListRepositoriesWithPagination(ctx, "", 2, offset)
+			require.NoError(t, err)
+			require.LessOrEqual(t, len(page.Repositories), 2, "a page holds at most limit repositories")
+			assert.Equal(t, offset > 0, page.HasPrevious, "offset %d", offset)
+			listed = append(listed, page.Repositories...)
+			if !page.HasNext {
+				break
+			}
+			require.Less(t, offset, 2*len(f.Repositories()), "HasNext stays true past the last repository")
+		}
+		assert.ElementsMatch(t, f.Repositories(), listed, "pages cover every repository once")
+
+		count, err := paginated.CountRepositories(ctx, "conformance/")
+		require.NoError(t, err)
+		assert.Equal(t, 3, count)
+	}},
+
+	// Manifests
+	{Name: "GetManifestByTag", Run: func(t *testing.T, f *Fixture) {
+		manifest, err := repository(t, f, RepoAlpha).GetManifest(context.Background(), "v1")
+		require.NoError(t, err)
+		assertManifest(t, f, manifest, RepoAlpha, "v1")
+	}},
+	{Name: "GetManifestByDigest", Run: func(t *testing.T, f *Fixture) {
+		digest, err := f.Image(t, RepoAlpha, "v1").Digest()
+		require.NoError(t, err)
+		manifest, err := repository(t, f, RepoAlpha).GetManifest(context.Background(), digest.String())
+		require.NoError(t, err)
+		assertManifest(t, f, manifest, RepoAlpha, "v1")
+	}},
+	{Name: "TagsShareManifest", Run: func(t *testing.T, f *Fixture) {
+		repo := repository(t, f, RepoAlpha)
+		latest, err := repo.GetManifest(context.Background(), "latest")
+		require.NoError(t, err)
+		v2, err := repo.GetManifest(context.Background(), "v2")
+		require.NoError(t, err)
+		assert.Equal(t, v2.Digest, latest.Digest, "tags of one image report one digest")
+	}},
+	{Name: "GetLayer", Run: func(t *testing.T, f *Fixture) {
+		layers, err := f.Image(t, RepoBeta, "v1").Layers()
+		require.NoError(t, err)
+		digest, err := layers[0].Digest()
+		require.NoError(t, err)
+
+		reader, err := repository(t, f, RepoBeta).GetLayerReader(context.Background(), digest.String())
+		require.NoError(t, err)
+		defer reader.Close()
+		data, err := io.ReadAll(reader)
+		require.NoError(t, err)
+		assert.Equal(t, digest.String(), fmt.Sprintf("sha256:%x", sha256.Sum256(data)), "the layer is read as stored")
+	}},
+	{Name: "PutManifest", Writes: true, Run: func(t *testing.T, f *Fixture) {
+		repo := repository(t, f, RepoAlpha)
+		manifest, err := repo.GetManifest(context.Background(), "v1")
+		require.NoError(t, err)
+
+		require.NoError(t, repo.PutManifest(context.Background(), "pushed", manifest))
+
+		pushed, err := repository(t, f, RepoAlpha).GetManifest(context.Background(), "pushed")
+		require.NoError(t, err)
+		assert.Equal(t, manifest.Digest, pushed.Digest, "a pushed manifest keeps its digest")
+		assert.Equal(t, manifest.Content, pushed.Content)
+
+		tags, err := repository(t, f, RepoAlpha).ListTags(context.Background())
+		require.NoError(t, err)
+		assert.Contains(t, tags, "pushed")
+	}},
+
+	// Error semantics
+	{Name: "GetRepositoryEmptyName", Run: func(t *testing.T, f *Fixture) {
+		_, err := f.Client.GetRepository(context.Background(), "")
+		assertErrorIs(t, err, errors.ErrInvalidInput)
+	}},
+	{Name: "GetManifestEmptyReference", Run: func(t *testing.T, f *Fixture) {
+		_, err := repository(t, f, RepoAlpha).GetManifest(context.Background(), "")
+		assertErrorIs(t, err, errors.ErrInvalidInput)
+	}},
+	{Name: "GetManifestMissingTag", Run: func(t *testing.T, f *Fixture) {
+		_, err := repository(t, f, RepoAlpha).GetManifest(context.Background(), "missing")
+		assertErrorIs(t, err, errors.ErrNotFound)
+	}},
+	{Name: "GetManifestMissingRepository", Run: func(t *testing.T, f *Fixture) {
+		_, err := repository(t, f, "missing/repo").GetManifest(context.Background(), "v1")
+		assertErrorIs(t, err, errors.ErrNotFound)
+	}},
+	{Name: "ListTagsMissingRepository", Run: func(t *testing.T, f *Fixture) {
+		_, err := repository(t, f, "missing/repo").ListTags(context.Background())
+		assertErrorIs(t, err, errors.ErrNotFound)
+	}},
+}
+
+// repository returns the client's repository of name
+func repository(t *testing.T, f *Fixture, name string) interfaces.Repository {
+	t.Helper()
+	repo, err := f.Client.GetRepository(context.Background(), name)
+	require.NoError(t, err, "GetRepository only fails on invalid names")
+	require.NotNil(t, repo)
+	return repo
+}
+
+// assertManifest checks that manifest is the one seeded at repository:tag
+func assertManifest(t *testing.T, f *Fixture, manifest *interfaces.Manifest, repository, tag string) {
+	t.Helper()
+	require.NotNil(t, manifest)
+	img := f.Image(t, repository, tag)
+
+	digest, err := img.Digest()
+	require.NoError(t, err)
+	mediaType, err := img.MediaType()
+	require.NoError(t, err)
+	raw, err := img.RawManifest()
+	require.NoError(t, err)
+	layers, err := img.Layers()
+	require.NoError(t, err)
+
+	assert.Equal(t, digest.String(), manifest.Digest)
+	assert.Equal(t, string(mediaType), manifest.MediaType)
+	assert.Equal(t, raw, manifest.Content, "the manifest is returned byte for byte")
+	assert.Equal(t, 2, manifest.SchemaVersion)
+	assert.Len(t, manifest.Layers, len(layers))
+}
+
+// assertErrorIs checks that err wraps target, so callers can tell error kinds
+// apart with errors.Is
+func assertErrorIs(t *testing.T, err, target error) {
+	t.Helper()
+	require.Error(t, err)
+	assert.Truef(t, errors.Is(err, target), "error %q does not wrap %q", err, target)
+}
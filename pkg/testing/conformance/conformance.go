@@ -0,0 +1,285 @@
+// Package conformance is a behavioral test suite for registry clients. Authors
+// of third-party interfaces.RegistryClient implementations run it from a test
+// of their own to check that the client lists, paginates, reads and writes
+// manifests and reports errors the way freightliner expects:
+//
+//	func TestConformance(t *testing.T) {
+//		conformance.Run(t, conformance.Options{
+//			NewClient: func(t *testing.T, endpoint string) interfaces.RegistryClient {
+//				client, err := myregistry.NewClient(endpoint)
+//				require.NoError(t, err)
+//				return client
+//			},
+//		})
+//	}
+//
+// The suite serves a seeded registry over plain HTTP on 127.0.0.1, paginating
+// the catalog and tag lists, and passes its host:port to NewClient.
+package conformance
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"freightliner/pkg/interfaces"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// PageSize is the most repositories or tags the registry returns per page.
+// Listing anything larger needs the client to follow Link headers.
+const PageSize = 10
+
+// Repositories seeded in the registry
+const (
+	RepoAlpha = "conformance/alpha"
+	RepoBeta  = "conformance/beta"
+	RepoPaged = "conformance/paged"
+	RepoGamma = "other/gamma"
+)
+
+// Options configures a conformance run
+type Options struct {
+	// NewClient returns the client under test for the registry at endpoint,
+	// a host:port serving plain HTTP. It is called once per case.
+	NewClient func(t *testing.T, endpoint string) interfaces.RegistryClient
+
+	// ReadOnly skips the cases that push to the registry
+	ReadOnly bool
+
+	// Skip names cases that do not apply to the client
+	Skip []string
+}
+
+// Case is one behavioral test of the suite
+type Case struct {
+	// Name identifies the case in test output and Options.Skip
+	Name string
+
+	// Writes marks cases that push to the registry
+	Writes bool
+
+	// Run tests the client against the seeded registry
+	Run func(t *testing.T, f *Fixture)
+}
+
+// Fixture is the seeded registry and the client under test
+type Fixture struct {
+	// Client is the client under test
+	Client interfaces.RegistryClient
+
+	// Endpoint is the host:port of the registry
+	Endpoint string
+
+	// Tags holds the image of every seeded tag, by repository and tag
+	Tags map[string]map[string]v1.Image
+}
+
+// Image returns the image seeded at repository:tag
+func (f *Fixture) Image(t *testing.T, repository, tag string) v1.Image {
+	t.Helper()
+	img, ok := f.Tags[repository][tag]
+	if !ok {
+		t.Fatalf("no image seeded at %s:%s", repository, tag)
+	}
+	return img
+}
+
+// Repositories returns the names of the seeded repositories, sorted
+func (f *Fixture) Repositories() []string {
+	repos := make([]string, 0, len(f.Tags))
+	for repo := range f.Tags {
+		repos = append(repos, repo)
+	}
+	sort.Strings(repos)
+	return repos
+}
+
+// TagNames returns the seeded tags of repository, sorted
+func (f *Fixture) TagNames(repository string) []string {
+	tags := make([]string, 0, len(f.Tags[repository]))
+	for tag := range f.Tags[repository] {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// Run runs every case of the suite against the client opts.NewClient returns
+func Run(t *testing.T, opts Options) {
+	if opts.NewClient == nil {
+		t.Fatal("conformance: Options.NewClient is required")
+	}
+	skip := make(map[string]bool, len(opts.Skip))
+	for _, name := range opts.Skip {
+		skip[name] = true
+	}
+
+	for _, c := range Cases {
+		c := c
+		t.Run(c.Name, func(t *testing.T) {
+			if skip[c.Name] {
+				t.Skip("skipped by Options.Skip")
+			}
+			if c.Writes && opts.ReadOnly {
+				t.Skip("client is read-only")
+			}
+			f := NewFixture(t)
+			f.Client = opts.NewClient(t, f.Endpoint)
+			if f.Client == nil {
+				t.Fatal("NewClient returned nil")
+			}
+			c.Run(t, f)
+		})
+	}
+}
+
+// NewFixture starts a seeded registry, closed when the test ends. The
+// fixture's Client is left for the caller to set.
+func NewFixture(t *testing.T) *Fixture {
+	t.Helper()
+	server := httptest.NewServer(paginate(registry.New(registry.Logger(log.New(io.Discard, "", 0)))))
+	t.Cleanup(server.Close)
+
+	endpoint := strings.TrimPrefix(server.URL, "http://")
+	f := &Fixture{Endpoint: endpoint, Tags: make(map[string]map[string]v1.Image)}
+
+	v1Image := seedImage(t)
+	v2Image := seedImage(t)
+	f.seed(t, RepoAlpha, "v1", v1Image)
+	f.seed(t, RepoAlpha, "v2", v2Image)
+	f.seed(t, RepoAlpha, "latest", v2Image)
+	f.seed(t, RepoBeta, "v1", seedImage(t))
+	f.seed(t, RepoGamma, "v1", seedImage(t))
+
+	// More tags than fit on two pages
+	paged := seedImage(t)
+	for i := 0; i < 2*PageSize+5; i++ {
+		f.seed(t, RepoPaged, fmt.Sprintf("t%03d", i), paged)
+	}
+	return f
+}
+
+// seedImage returns a small random image
+func seedImage(t *testing.T) v1.Image {
+	t.Helper()
+	img, err := random.Image(256, 2)
+	if err != nil {
+		t.Fatalf("failed to create image: %v", err)
+	}
+	return img
+}
+
+// seed pushes img to repository:tag
+func (f *Fixture) seed(t *testing.T, repository, tag string, img v1.Image) {
+	t.Helper()
+	ref, err := name.NewTag(f.Endpoint+"/"+repository+":"+tag, name.Insecure)
+	if err != nil {
+		t.Fatalf("invalid seed reference: %v", err)
+	}
+	if err := remote.Write(ref, img); err != nil {
+		t.Fatalf("failed to seed %s:%s: %v", repository, tag, err)
+	}
+	if f.Tags[repository] == nil {
+		f.Tags[repository] = make(map[string]v1.Image)
+	}
+	f.Tags[repository][tag] = img
+}
+
+// paginate serves the catalog and tag lists of next in sorted pages of at
+// most PageSize entries, linking each page to the next like the distribution
+// spec describes
+func paginate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			next.ServeHTTP(w, r)
+			return
+		}
+		switch {
+		case r.URL.Path == "/v2/_catalog":
+			servePage(w, r, next, "repositories")
+		case strings.HasSuffix(r.URL.Path, "/tags/list"):
+			servePage(w, r, next, "tags")
+		default:
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// servePage lists everything from next and writes the page of the list in
+// field that r asks for with its n and last parameters
+func servePage(w http.ResponseWriter, r *http.Request, next http.Handler, field string) {
+	all := r.Clone(r.Context())
+	all.URL = &url.URL{Path: r.URL.Path}
+	all.RequestURI = ""
+	rec := httptest.NewRecorder()
+	next.ServeHTTP(rec, all)
+	if rec.Code != http.StatusOK {
+		for key, values := range rec.Header() {
+			w.Header()[key] = values
+		}
+		w.WriteHeader(rec.Code)
+		_, _ = w.Write(rec.Body.Bytes())
+		return
+	}
+
+	var body map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var entries []string
+	if list, ok := body[field].([]interface{}); ok {
+		for _, entry := range list {
+			entries = append(entries, fmt.Sprint(entry))
+		}
+	}
+	sort.Strings(entries)
+
+	query := r.URL.Query()
+	if last := query.Get("last"); last != "" {
+		start := sort.SearchStrings(entries, last)
+		if start < len(entries) && entries[start] == last {
+			start++
+		}
+		entries = entries[start:]
+	}
+	n := PageSize
+	if requested, err := strconv.Atoi(query.Get("n")); err == nil && requested > 0 && requested < n {
+		n = requested
+	}
+	if len(entries) > n {
+		entries = entries[:n]
+		nextPage := url.URL{Path: r.URL.Path, RawQuery: url.Values{
+			"n":    {strconv.Itoa(n)},
+			"last": {entries[n-1]},
+		}.Encode()}
+		w.Header().Set("Link", fmt.Sprintf("<%s>; rel=\"next\"", nextPage.String()))
+	}
+	if entries == nil {
+		entries = []string{}
+	}
+	body[field] = entries
+
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(body); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(buf.Bytes())
+}
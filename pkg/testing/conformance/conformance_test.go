@@ -0,0 +1,25 @@
+package conformance
+
+import (
+	"testing"
+
+	"freightliner/pkg/client/generic"
+	"freightliner/pkg/config"
+	"freightliner/pkg/helper/log"
+	"freightliner/pkg/interfaces"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenericClientConformance(t *testing.T) {
+	Run(t, Options{
+		NewClient: func(t *testing.T, endpoint string) interfaces.RegistryClient {
+			client, err := generic.NewClient(generic.ClientOptions{
+				RegistryConfig: config.RegistryConfig{Name: "conformance", Endpoint: endpoint},
+				Logger:         log.NewBasicLogger(log.ErrorLevel),
+			})
+			require.NoError(t, err)
+			return client
+		},
+	})
+}
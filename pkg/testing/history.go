@@ -0,0 +1,277 @@
+package testing
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// History defaults used when the manifest leaves them unset
+const (
+	DefaultHistoryFile        = "test-history.json"
+	DefaultHistoryWindow      = 20
+	DefaultHistoryMinRuns     = 5
+	DefaultFlakyThreshold     = 0.95
+	DefaultQuarantineCategory = "quarantined"
+)
+
+// HistoryConfig configures the test history sidecar and the quarantine of
+// flaky tests
+type HistoryConfig struct {
+	// Path is the history file, relative to the manifest (default: test-history.json)
+	Path string `yaml:"path"`
+
+	// Window is how many recent runs of a test are judged (default: 20)
+	Window int `yaml:"window"`
+
+	// MinRuns is how many runs a test needs before it can be quarantined (default: 5)
+	MinRuns int `yaml:"min_runs"`
+
+	// FlakyThreshold is the pass rate below which a test that also passed
+	// within the window is quarantined (default: 0.95). Tests that never
+	// passed are broken rather than flaky and stay where they are.
+	FlakyThreshold float64 `yaml:"flaky_threshold"`
+
+	// QuarantineCategory is the category quarantined tests join (default: quarantined)
+	QuarantineCategory string `yaml:"quarantine_category"`
+}
+
+// TestHistory holds the recent outcomes and durations of every test, by
+// package and test name
+type TestHistory struct {
+	Packages map[string]map[string]*TestRecord `json:"packages"`
+
+	config HistoryConfig
+}
+
+// TestRecord is the history of one test
+type TestRecord struct {
+	// Outcomes are the most recent results, oldest first: "pass" or "fail"
+	Outcomes []string `json:"outcomes"`
+
+	// Durations are the seconds each of the recent runs took
+	Durations []float64 `json:"durations"`
+
+	Runs     int       `json:"runs"`
+	Failures int       `json:"failures"`
+	LastRun  time.Time `json:"last_run"`
+}
+
+// Test outcomes recorded in the history
+const (
+	OutcomePass = "pass"
+	OutcomeFail = "fail"
+)
+
+// HistoryPath returns the history file of the manifest at manifestPath
+func (m *TestManifest) HistoryPath(manifestPath string) string {
+	path := m.History.Path
+	if path == "" {
+		path = DefaultHistoryFile
+	}
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(filepath.Dir(manifestPath), path)
+}
+
+// withDefaults fills the unset history settings
+func (c HistoryConfig) withDefaults() HistoryConfig {
+	if c.Window <= 0 {
+		c.Window = DefaultHistoryWindow
+	}
+	if c.MinRuns <= 0 {
+		c.MinRuns = DefaultHistoryMinRuns
+	}
+	if c.FlakyThreshold <= 0 {
+		c.FlakyThreshold = DefaultFlakyThreshold
+	}
+	if c.QuarantineCategory == "" {
+		c.QuarantineCategory = DefaultQuarantineCategory
+	}
+	return c
+}
+
+// NewTestHistory creates an empty history judged by config
+func NewTestHistory(config HistoryConfig) *TestHistory {
+	return &TestHistory{
+		Packages: make(map[string]map[string]*TestRecord),
+		config:   config.withDefaults(),
+	}
+}
+
+// LoadTestHistory loads the history at path, or returns an empty one when
+// the file does not exist yet
+func LoadTestHistory(path string, config HistoryConfig) (*TestHistory, error) {
+	history := NewTestHistory(config)
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return history, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history file: %w", err)
+	}
+	if err := json.Unmarshal(data, history); err != nil {
+		return nil, fmt.Errorf("failed to parse history file: %w", err)
+	}
+	if history.Packages == nil {
+		history.Packages = make(map[string]map[string]*TestRecord)
+	}
+	return history, nil
+}
+
+// Save writes the history to path
+func (h *TestHistory) Save(path string) error {
+	data, err := json.MarshalIndent(h, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode history: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write history file: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// Record adds one run of a test, keeping only the configured window
+func (h *TestHistory) Record(packageName, testName, outcome string, duration time.Duration, at time.Time) {
+	tests, ok := h.Packages[packageName]
+	if !ok {
+		tests = make(map[string]*TestRecord)
+		h.Packages[packageName] = tests
+	}
+	record, ok := tests[testName]
+	if !ok {
+		record = &TestRecord{}
+		tests[testName] = record
+	}
+
+	record.Outcomes = append(record.Outcomes, outcome)
+	record.Durations = append(record.Durations, duration.Seconds())
+	if extra := len(record.Outcomes) - h.config.Window; extra > 0 {
+		record.Outcomes = record.Outcomes[extra:]
+	}
+	if extra := len(record.Durations) - h.config.Window; extra > 0 {
+		record.Durations = record.Durations[extra:]
+	}
+	record.Runs++
+	if outcome == OutcomeFail {
+		record.Failures++
+	}
+	record.LastRun = at.UTC()
+}
+
+// testEvent is one line of `go test -json` output
+type testEvent struct {
+	Time    time.Time `json:"Time"`
+	Action  string    `json:"Action"`
+	Package string    `json:"Package"`
+	Test    string    `json:"Test"`
+	Elapsed float64   `json:"Elapsed"`
+	Output  string    `json:"Output"`
+}
+
+// RecordEvents reads a `go test -json` stream and records the pass or fail
+// of every top-level test. Output lines are copied to output when it is not
+// nil, so the run still reads like plain `go test`. It returns how many
+// results were recorded.
+func (h *TestHistory) RecordEvents(r io.Reader, output io.Writer) (int, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	recorded := 0
+	for scanner.Scan() {
+		var event testEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			// Lines that are not events, e.g. build errors, pass through
+			if output != nil {
+				fmt.Fprintln(output, scanner.Text())
+			}
+			continue
+		}
+		if event.Action == "output" && output != nil {
+			fmt.Fprint(output, event.Output)
+		}
+
+		// Subtests are judged with their parent
+		if event.Test == "" || strings.Contains(event.Test, "/") {
+			continue
+		}
+		switch event.Action {
+		case "pass", "fail":
+			at := event.Time
+			if at.IsZero() {
+				at = time.Now()
+			}
+			h.Record(event.Package, event.Test, event.Action,
+				time.Duration(event.Elapsed*float64(time.Second)), at)
+			recorded++
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return recorded, fmt.Errorf("failed to read test events: %w", err)
+	}
+	return recorded, nil
+}
+
+// PassRate returns the share of the recent runs that passed
+func (r *TestRecord) PassRate() float64 {
+	if len(r.Outcomes) == 0 {
+		return 0
+	}
+	passes := 0
+	for _, outcome := range r.Outcomes {
+		if outcome == OutcomePass {
+			passes++
+		}
+	}
+	return float64(passes) / float64(len(r.Outcomes))
+}
+
+// AverageDuration returns the mean duration of the recent runs
+func (r *TestRecord) AverageDuration() time.Duration {
+	if len(r.Durations) == 0 {
+		return 0
+	}
+	var total float64
+	for _, seconds := range r.Durations {
+		total += seconds
+	}
+	return time.Duration(total / float64(len(r.Durations)) * float64(time.Second))
+}
+
+// IsQuarantined reports whether a test is flaky: it ran at least MinRuns
+// times within the window, passed at least once and failed often enough to
+// fall below FlakyThreshold
+func (h *TestHistory) IsQuarantined(packageName, testName string) bool {
+	record, ok := h.Packages[packageName][testName]
+	if !ok || len(record.Outcomes) < h.config.MinRuns {
+		return false
+	}
+	rate := record.PassRate()
+	return rate > 0 && rate < h.config.FlakyThreshold
+}
+
+// QuarantineCategory returns the category quarantined tests join
+func (h *TestHistory) QuarantineCategory() string {
+	return h.config.QuarantineCategory
+}
+
+// Quarantined returns the quarantined tests of a package, sorted
+func (h *TestHistory) Quarantined(packageName string) []string {
+	var tests []string
+	for testName := range h.Packages[packageName] {
+		if h.IsQuarantined(packageName, testName) {
+			tests = append(tests, testName)
+		}
+	}
+	sort.Strings(tests)
+	return tests
+}
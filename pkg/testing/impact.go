@@ -0,0 +1,189 @@
+package testing
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// PackageGraph is what test selection needs to know about the module's
+// packages, from `go list -test`
+type PackageGraph struct {
+	// Dirs maps package directories to their import paths
+	Dirs map[string]string
+
+	// TestDeps maps each package with tests to every package its test
+	// binary builds, itself included
+	TestDeps map[string][]string
+}
+
+// listedPackage is one package of `go list -json` output
+type listedPackage struct {
+	ImportPath string   `json:"ImportPath"`
+	Dir        string   `json:"Dir"`
+	ForTest    string   `json:"ForTest"`
+	Deps       []string `json:"Deps"`
+}
+
+// LoadPackageGraph lists the packages matching patterns, run from dir
+func LoadPackageGraph(dir string, patterns ...string) (*PackageGraph, error) {
+	if len(patterns) == 0 {
+		patterns = []string{"./..."}
+	}
+	args := append([]string{"list", "-e", "-test", "-json=ImportPath,Dir,ForTest,Deps"}, patterns...)
+	cmd := exec.Command("go", args...)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("go list failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return ParsePackageGraph(bytes.NewReader(out))
+}
+
+// ParsePackageGraph reads the output of `go list -test -json`
+func ParsePackageGraph(r io.Reader) (*PackageGraph, error) {
+	graph := &PackageGraph{
+		Dirs:     make(map[string]string),
+		TestDeps: make(map[string][]string),
+	}
+
+	decoder := json.NewDecoder(r)
+	for decoder.More() {
+		var pkg listedPackage
+		if err := decoder.Decode(&pkg); err != nil {
+			return nil, fmt.Errorf("failed to parse go list output: %w", err)
+		}
+
+		switch {
+		case strings.HasSuffix(pkg.ImportPath, ".test") && pkg.ForTest == "":
+			// The test binary of a package depends on everything its
+			// internal and external tests build
+			tested := strings.TrimSuffix(pkg.ImportPath, ".test")
+			deps := []string{tested}
+			for _, dep := range pkg.Deps {
+				deps = append(deps, basePackage(dep))
+			}
+			graph.TestDeps[tested] = deps
+		case pkg.ForTest == "" && pkg.Dir != "":
+			graph.Dirs[filepath.Clean(pkg.Dir)] = pkg.ImportPath
+		}
+	}
+	return graph, nil
+}
+
+// basePackage strips the test variant suffix go list adds to packages
+// recompiled for a test, e.g. "p [q.test]"
+func basePackage(importPath string) string {
+	if i := strings.Index(importPath, " ["); i >= 0 {
+		return importPath[:i]
+	}
+	return importPath
+}
+
+// ChangedPackages maps changed files, as absolute paths, to the packages
+// they belong to. Files outside any package, such as testdata, count for
+// the nearest package above them. all is set when go.mod or go.sum changed,
+// which may change every package.
+func (g *PackageGraph) ChangedPackages(files []string) (packages []string, all bool) {
+	seen := make(map[string]bool)
+	for _, file := range files {
+		switch filepath.Base(file) {
+		case "go.mod", "go.sum", "go.work", "go.work.sum":
+			return nil, true
+		}
+
+		for dir := filepath.Dir(filepath.Clean(file)); ; dir = filepath.Dir(dir) {
+			if importPath, ok := g.Dirs[dir]; ok {
+				if !seen[importPath] {
+					seen[importPath] = true
+					packages = append(packages, importPath)
+				}
+				break
+			}
+			if parent := filepath.Dir(dir); parent == dir {
+				break
+			}
+		}
+	}
+	sort.Strings(packages)
+	return packages, false
+}
+
+// Affected returns the packages whose tests build one of the changed
+// packages, sorted
+func (g *PackageGraph) Affected(changed []string) []string {
+	changedSet := make(map[string]bool, len(changed))
+	for _, pkg := range changed {
+		changedSet[pkg] = true
+	}
+
+	var affected []string
+	for tested, deps := range g.TestDeps {
+		for _, dep := range deps {
+			if changedSet[dep] {
+				affected = append(affected, tested)
+				break
+			}
+		}
+	}
+	sort.Strings(affected)
+	return affected
+}
+
+// All returns every package with tests, sorted
+func (g *PackageGraph) All() []string {
+	packages := make([]string, 0, len(g.TestDeps))
+	for tested := range g.TestDeps {
+		packages = append(packages, tested)
+	}
+	sort.Strings(packages)
+	return packages
+}
+
+// ChangedFiles lists the files, as absolute paths, that differ between the
+// working tree in dir and the git revision since, including uncommitted and
+// untracked changes
+func ChangedFiles(dir, since string) ([]string, error) {
+	root, err := gitOutput(dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, err
+	}
+	root = strings.TrimSpace(root)
+
+	diff, err := gitOutput(dir, "diff", "--name-only", since)
+	if err != nil {
+		return nil, err
+	}
+	untracked, err := gitOutput(dir, "ls-files", "--others", "--exclude-standard", "--full-name")
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, line := range strings.Split(diff+"\n"+untracked, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			files = append(files, filepath.Join(root, filepath.FromSlash(line)))
+		}
+	}
+	return files, nil
+}
+
+// gitOutput runs git in dir and returns its output
+func gitOutput(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git %s failed: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return string(out), nil
+}
@@ -6,6 +6,7 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
 
 	"gopkg.in/yaml.v3"
@@ -21,6 +22,7 @@ type TestManifest struct {
 	Environment EnvironmentDetection      `yaml:"environment_detection"`
 	Reporting   ReportingConfig           `yaml:"reporting"`
 	MakeTargets map[string]MakeTarget     `yaml:"make_targets"`
+	History     HistoryConfig             `yaml:"history"`
 }
 
 // GlobalConfig contains global test execution settings
@@ -83,6 +85,7 @@ type TestFilter struct {
 	manifest    *TestManifest
 	environment string
 	categories  []string
+	history     *TestHistory
 }
 
 // LoadTestManifest loads the test manifest from a YAML file
@@ -123,11 +126,51 @@ func (tf *TestFilter) WithEnvironment(env string) *TestFilter {
 	return tf
 }
 
+// WithHistory quarantines the tests history finds flaky: they join its
+// quarantine category, whether or not the manifest lists them
+func (tf *TestFilter) WithHistory(history *TestHistory) *TestFilter {
+	tf.history = history
+	return tf
+}
+
+// testCategories returns the categories of a test, including the quarantine
+// category when history finds it flaky
+func (tf *TestFilter) testCategories(packageName, testName string, configured []string) []string {
+	if tf.history == nil || !tf.history.IsQuarantined(packageName, testName) {
+		return configured
+	}
+	return append(append([]string(nil), configured...), tf.history.QuarantineCategory())
+}
+
+// quarantined reports whether a test is quarantined and does not run in
+// this environment or category selection, with the reason
+func (tf *TestFilter) quarantined(packageName, testName string) (bool, string) {
+	if tf.history == nil || !tf.history.IsQuarantined(packageName, testName) {
+		return false, ""
+	}
+	category := tf.history.QuarantineCategory()
+	for _, filterCategory := range tf.categories {
+		if filterCategory == category {
+			return false, ""
+		}
+	}
+	if _, defined := tf.manifest.Categories[category]; defined && tf.isCategoryEnabledInEnvironment(category) {
+		return false, ""
+	}
+
+	record := tf.history.Packages[packageName][testName]
+	return true, fmt.Sprintf("quarantined as flaky: passed %.0f%% of the last %d runs",
+		record.PassRate()*100, len(record.Outcomes))
+}
+
 // ShouldRunTest determines if a specific test should be executed
 func (tf *TestFilter) ShouldRunTest(packageName, testName string) (bool, string) {
 	// Check if package exists in manifest
 	pkgConfig, exists := tf.manifest.Packages[packageName]
 	if !exists {
+		if quarantined, reason := tf.quarantined(packageName, testName); quarantined {
+			return false, reason
+		}
 		// Package not in manifest, use global default
 		return tf.manifest.Global.DefaultEnabled, "package not in manifest"
 	}
@@ -140,6 +183,9 @@ func (tf *TestFilter) ShouldRunTest(packageName, testName string) (bool, string)
 	// Check specific test configuration
 	testConfig, exists := pkgConfig.Tests[testName]
 	if !exists {
+		if quarantined, reason := tf.quarantined(packageName, testName); quarantined {
+			return false, reason
+		}
 		// Test not specifically configured, use package default (enabled)
 		return true, "test not specifically configured"
 	}
@@ -149,6 +195,11 @@ func (tf *TestFilter) ShouldRunTest(packageName, testName string) (bool, string)
 		return false, testConfig.Reason
 	}
 
+	if quarantined, reason := tf.quarantined(packageName, testName); quarantined {
+		return false, reason
+	}
+	categories := tf.testCategories(packageName, testName, testConfig.Categories)
+
 	// Check category-based filtering
 	for _, category := range testConfig.Categories {
 		if !tf.isCategoryEnabledInEnvironment(category) {
@@ -160,7 +211,7 @@ func (tf *TestFilter) ShouldRunTest(packageName, testName string) (bool, string)
 	if len(tf.categories) > 0 {
 		hasMatchingCategory := false
 		for _, filterCategory := range tf.categories {
-			for _, testCategory := range testConfig.Categories {
+			for _, testCategory := range categories {
 				if filterCategory == testCategory {
 					hasMatchingCategory = true
 					break
@@ -219,6 +270,9 @@ func (tf *TestFilter) GenerateTestArgs(packageName string) []string {
 
 	pkgConfig, exists := tf.manifest.Packages[packageName]
 	if !exists {
+		if skipPatterns := tf.quarantineSkips(packageName, nil); len(skipPatterns) > 0 {
+			args = append(args, "-skip", strings.Join(skipPatterns, "|"))
+		}
 		return args
 	}
 
@@ -247,6 +301,7 @@ func (tf *TestFilter) GenerateTestArgs(packageName string) []string {
 		args = append(args, "-run", strings.Join(runPatterns, "|"))
 	}
 
+	skipPatterns = append(skipPatterns, tf.quarantineSkips(packageName, pkgConfig.Tests)...)
 	if len(skipPatterns) > 0 {
 		args = append(args, "-skip", strings.Join(skipPatterns, "|"))
 	}
@@ -254,6 +309,25 @@ func (tf *TestFilter) GenerateTestArgs(packageName string) []string {
 	return args
 }
 
+// quarantineSkips returns the -skip patterns of the quarantined tests of a
+// package that the manifest does not configure
+func (tf *TestFilter) quarantineSkips(packageName string, configured map[string]TestConfig) []string {
+	if tf.history == nil {
+		return nil
+	}
+
+	var skipPatterns []string
+	for _, testName := range tf.history.Quarantined(packageName) {
+		if _, ok := configured[testName]; ok {
+			continue
+		}
+		if skip, _ := tf.quarantined(packageName, testName); skip {
+			skipPatterns = append(skipPatterns, fmt.Sprintf("^%s$", regexp.QuoteMeta(testName)))
+		}
+	}
+	return skipPatterns
+}
+
 // PrintSummary prints a summary of test filtering decisions
 func (tf *TestFilter) PrintSummary() {
 	fmt.Printf("Test Manifest Summary (Environment: %s)\n", tf.environment)
@@ -291,6 +365,28 @@ func (tf *TestFilter) PrintSummary() {
 		}
 		fmt.Printf("  %s %s: %s\n", status, categoryName, categoryConfig.Description)
 	}
+
+	if tf.history == nil {
+		return
+	}
+	fmt.Println("\nQuarantined Tests:")
+	packages := make([]string, 0, len(tf.history.Packages))
+	for packageName := range tf.history.Packages {
+		packages = append(packages, packageName)
+	}
+	sort.Strings(packages)
+	quarantined := 0
+	for _, packageName := range packages {
+		for _, testName := range tf.history.Quarantined(packageName) {
+			record := tf.history.Packages[packageName][testName]
+			fmt.Printf("  %s %s: passed %.0f%% of %d runs\n", packageName, testName,
+				record.PassRate()*100, len(record.Outcomes))
+			quarantined++
+		}
+	}
+	if quarantined == 0 {
+		fmt.Println("  none")
+	}
 }
 
 // detectEnvironment determines the current environment based on environment variables
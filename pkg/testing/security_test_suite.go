@@ -17,6 +17,7 @@ import (
 	"time"
 
 	"freightliner/pkg/helper/log"
+	"freightliner/pkg/security/audit"
 )
 
 // SecurityTestSuite provides comprehensive security testing capabilities
@@ -46,31 +47,16 @@ type VulnerabilityPattern struct {
 	Remediation string
 }
 
-// Severity levels for vulnerabilities
-type Severity int
+// Severity levels for vulnerabilities, shared with the runtime self-audit
+type Severity = audit.Severity
 
 const (
-	SeverityLow Severity = iota
-	SeverityMedium
-	SeverityHigh
-	SeverityCritical
+	SeverityLow      = audit.SeverityLow
+	SeverityMedium   = audit.SeverityMedium
+	SeverityHigh     = audit.SeverityHigh
+	SeverityCritical = audit.SeverityCritical
 )
 
-func (s Severity) String() string {
-	switch s {
-	case SeverityLow:
-		return "LOW"
-	case SeverityMedium:
-		return "MEDIUM"
-	case SeverityHigh:
-		return "HIGH"
-	case SeverityCritical:
-		return "CRITICAL"
-	default:
-		return "UNKNOWN"
-	}
-}
-
 // ScanResult contains vulnerability scan results for a file
 type ScanResult struct {
 	FilePath        string
@@ -181,13 +167,9 @@ func NewSecurityTestSuite(logger log.Logger) *SecurityTestSuite {
 			scannedFiles: make(map[string]*ScanResult),
 		},
 		cryptoValidator: &CryptoValidator{
-			weakAlgorithms: []string{"MD5", "SHA1", "DES", "3DES", "RC4"},
+			weakAlgorithms: audit.WeakAlgorithms,
 			strongCiphers:  []string{"AES-256-GCM", "ChaCha20-Poly1305", "AES-128-GCM"},
-			minKeySize: map[string]int{
-				"RSA":   2048,
-				"ECDSA": 256,
-				"DSA":   2048,
-			},
+			minKeySize:     audit.MinKeySize,
 		},
 		networkSecurityTester: &NetworkSecurityTester{
 			httpClient: &http.Client{
@@ -213,33 +195,31 @@ func NewSecurityTestSuite(logger log.Logger) *SecurityTestSuite {
 				{ID: "guest", Username: "guest", Roles: []string{"guest"}, Permissions: []string{}},
 			},
 		},
-		inputValidator: &InputValidator{
-			injectionPatterns: []InjectionPattern{
-				{Name: "SQL Injection", Type: "SQL", Payload: "'; DROP TABLE users; --", Dangerous: true},
-				{Name: "XSS Script", Type: "XSS", Payload: "<script>alert('xss')</script>", Dangerous: true},
-				{Name: "Command Injection", Type: "Command", Payload: "; rm -rf /", Dangerous: true},
-				{Name: "LDAP Injection", Type: "LDAP", Payload: "*)(&", Dangerous: true},
-				{Name: "Path Traversal", Type: "Path", Payload: "../../../etc/passwd", Dangerous: true},
-			},
-		},
+		inputValidator: &InputValidator{},
 	}
 
-	// Initialize vulnerability patterns
-	suite.vulnerabilityScanner.patterns = []VulnerabilityPattern{
-		{
-			Name:        "Hardcoded Password",
-			Pattern:     `password\s*=\s*["'][^"']+["']`,
-			Severity:    SeverityCritical,
-			Description: "Hardcoded password found in source code",
-			Remediation: "Use environment variables or secure credential storage",
-		},
-		{
-			Name:        "Hardcoded API Key",
-			Pattern:     `(api_key|apikey|api-key)\s*=\s*["'][^"']+["']`,
-			Severity:    SeverityHigh,
-			Description: "Hardcoded API key found in source code",
-			Remediation: "Use environment variables or secure credential storage",
-		},
+	// The injection payloads and secret patterns are the ones the runtime
+	// self-audit looks for
+	for _, pattern := range audit.InjectionPatterns {
+		suite.inputValidator.injectionPatterns = append(suite.inputValidator.injectionPatterns, InjectionPattern{
+			Name:      pattern.Name,
+			Type:      pattern.Type,
+			Payload:   pattern.Payload,
+			Dangerous: true,
+		})
+	}
+	for _, pattern := range audit.SecretPatterns {
+		suite.vulnerabilityScanner.patterns = append(suite.vulnerabilityScanner.patterns, VulnerabilityPattern{
+			Name:        pattern.Name,
+			Pattern:     pattern.Pattern.String(),
+			Severity:    pattern.Severity,
+			Description: pattern.Description + " found in source code",
+			Remediation: pattern.Remediation,
+		})
+	}
+
+	// Initialize source-only vulnerability patterns
+	suite.vulnerabilityScanner.patterns = append(suite.vulnerabilityScanner.patterns, []VulnerabilityPattern{
 		{
 			Name:        "SQL Injection Risk",
 			Pattern:     `"SELECT.*FROM.*WHERE.*\+.*"`,
@@ -268,7 +248,7 @@ func NewSecurityTestSuite(logger log.Logger) *SecurityTestSuite {
 			Description: "Potential information leak through debug output",
 			Remediation: "Remove debug output or use structured logging",
 		},
-	}
+	}...)
 
 	return suite
 }
@@ -0,0 +1,149 @@
+package transform
+
+import (
+	"archive/tar"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"freightliner/pkg/helper/errors"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+)
+
+func init() {
+	Register("strip-files", newStripFiles)
+	Register("normalize-timestamps", newNormalizeTimestamps)
+	Register("strip-labels", newStripLabels)
+}
+
+// StripFiles drops files matching any of its patterns from every layer,
+// e.g. files a secrets scanner flagged. A pattern matching a directory drops
+// everything below it.
+type StripFiles struct {
+	Paths []string `yaml:"paths"`
+}
+
+func newStripFiles(options map[string]interface{}) (Transformer, error) {
+	t := &StripFiles{}
+	if err := DecodeOptions(options, t); err != nil {
+		return nil, err
+	}
+	if len(t.Paths) == 0 {
+		return nil, errors.InvalidInputf("paths must list at least one pattern")
+	}
+	for _, pattern := range t.Paths {
+		if _, err := path.Match(cleanPath(pattern), ""); err != nil {
+			return nil, errors.InvalidInputf("invalid path pattern %q: %v", pattern, err)
+		}
+	}
+	return t, nil
+}
+
+// Name implements Transformer
+func (t *StripFiles) Name() string { return "strip-files" }
+
+// TransformFile implements FileTransformer
+func (t *StripFiles) TransformFile(hdr *tar.Header, content io.Reader) (*tar.Header, io.Reader, error) {
+	name := cleanPath(hdr.Name)
+	for _, pattern := range t.Paths {
+		if matchesOrBelow(cleanPath(pattern), name) {
+			return nil, nil, nil
+		}
+	}
+	return hdr, content, nil
+}
+
+// matchesOrBelow reports whether name, or one of its parent directories,
+// matches pattern
+func matchesOrBelow(pattern, name string) bool {
+	for p := name; p != "." && p != ""; p = path.Dir(p) {
+		if ok, _ := path.Match(pattern, p); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// cleanPath turns a layer entry name or pattern into a relative, clean path
+func cleanPath(p string) string {
+	return strings.TrimPrefix(path.Clean("/"+p), "/")
+}
+
+// NormalizeTimestamps sets every file time in the layers, and the creation
+// times in the config, to one fixed time so rebuilt images are reproducible
+type NormalizeTimestamps struct {
+	// Time is the time to set (default: the Unix epoch)
+	Time time.Time `yaml:"time"`
+}
+
+func newNormalizeTimestamps(options map[string]interface{}) (Transformer, error) {
+	t := &NormalizeTimestamps{}
+	if err := DecodeOptions(options, t); err != nil {
+		return nil, err
+	}
+	if t.Time.IsZero() {
+		t.Time = time.Unix(0, 0)
+	}
+	t.Time = t.Time.UTC()
+	return t, nil
+}
+
+// Name implements Transformer
+func (t *NormalizeTimestamps) Name() string { return "normalize-timestamps" }
+
+// TransformFile implements FileTransformer
+func (t *NormalizeTimestamps) TransformFile(hdr *tar.Header, content io.Reader) (*tar.Header, io.Reader, error) {
+	hdr.ModTime = t.Time
+	hdr.AccessTime = time.Time{}
+	hdr.ChangeTime = time.Time{}
+	return hdr, content, nil
+}
+
+// TransformConfig implements ConfigTransformer
+func (t *NormalizeTimestamps) TransformConfig(cfg *v1.ConfigFile) error {
+	cfg.Created = v1.Time{Time: t.Time}
+	for i := range cfg.History {
+		cfg.History[i].Created = v1.Time{Time: t.Time}
+	}
+	return nil
+}
+
+// StripLabels removes config labels whose keys match any of its patterns,
+// e.g. build metadata such as VCS revisions and build hosts
+type StripLabels struct {
+	Labels []string `yaml:"labels"`
+}
+
+func newStripLabels(options map[string]interface{}) (Transformer, error) {
+	t := &StripLabels{}
+	if err := DecodeOptions(options, t); err != nil {
+		return nil, err
+	}
+	if len(t.Labels) == 0 {
+		return nil, errors.InvalidInputf("labels must list at least one pattern")
+	}
+	for _, pattern := range t.Labels {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, errors.InvalidInputf("invalid label pattern %q: %v", pattern, err)
+		}
+	}
+	return t, nil
+}
+
+// Name implements Transformer
+func (t *StripLabels) Name() string { return "strip-labels" }
+
+// TransformConfig implements ConfigTransformer
+func (t *StripLabels) TransformConfig(cfg *v1.ConfigFile) error {
+	for key := range cfg.Config.Labels {
+		for _, pattern := range t.Labels {
+			if ok, _ := path.Match(pattern, key); ok {
+				delete(cfg.Config.Labels, key)
+				break
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,391 @@
+// Package transform rewrites image content while it is copied. Registered
+// transformers change the files in layers, whole layers or the image config;
+// the pipeline rebuilds the layers, config and manifest with new digests.
+package transform
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+
+	"freightliner/pkg/helper/errors"
+	"freightliner/pkg/transport"
+
+	"github.com/google/go-containerregistry/pkg/compression"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"gopkg.in/yaml.v3"
+)
+
+// Transformer changes image content during a copy. A transformer implements
+// at least one of FileTransformer, LayerTransformer and ConfigTransformer.
+type Transformer interface {
+	// Name identifies the transformer in logs
+	Name() string
+}
+
+// FileTransformer rewrites the files of every layer
+type FileTransformer interface {
+	Transformer
+
+	// TransformFile returns the header and content to write in place of a
+	// layer entry, or a nil header to drop the entry. A transformer that
+	// changes the content must set the header's Size to match.
+	TransformFile(hdr *tar.Header, content io.Reader) (*tar.Header, io.Reader, error)
+}
+
+// LayerTransformer replaces whole layers, e.g. to rewrite a base layer
+type LayerTransformer interface {
+	Transformer
+
+	// TransformLayer returns the layer to push in place of layer, which may
+	// be layer itself
+	TransformLayer(layer v1.Layer) (v1.Layer, error)
+}
+
+// ConfigTransformer edits the image config
+type ConfigTransformer interface {
+	Transformer
+
+	// TransformConfig edits cfg in place. The layer diff IDs are set by the
+	// pipeline afterwards.
+	TransformConfig(cfg *v1.ConfigFile) error
+}
+
+// Factory builds a transformer from the options of its Spec
+type Factory func(options map[string]interface{}) (Transformer, error)
+
+var (
+	factoriesMu sync.RWMutex
+	factories   = make(map[string]Factory)
+)
+
+// Register makes a transformer available to sync configs under name,
+// replacing any earlier registration
+func Register(name string, factory Factory) {
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
+	factories[name] = factory
+}
+
+// Registered returns the names of the registered transformers, sorted
+func Registered() []string {
+	factoriesMu.RLock()
+	defer factoriesMu.RUnlock()
+
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Spec names a registered transformer and its options in a sync config
+type Spec struct {
+	// Name is the registered transformer name
+	Name string `yaml:"name"`
+
+	// Options configure the transformer
+	Options map[string]interface{} `yaml:"options,omitempty"`
+}
+
+// New builds the transformer spec names
+func New(spec Spec) (Transformer, error) {
+	factoriesMu.RLock()
+	factory, ok := factories[spec.Name]
+	factoriesMu.RUnlock()
+	if !ok {
+		return nil, errors.InvalidInputf("unknown transformer %q (registered: %v)", spec.Name, Registered())
+	}
+
+	t, err := factory(spec.Options)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid options for transformer %s", spec.Name)
+	}
+	return t, nil
+}
+
+// DecodeOptions decodes a Spec's options into target, a pointer to a struct
+// with yaml tags, rejecting unknown options
+func DecodeOptions(options map[string]interface{}, target interface{}) error {
+	if len(options) == 0 {
+		return nil
+	}
+	data, err := yaml.Marshal(options)
+	if err != nil {
+		return err
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	return decoder.Decode(target)
+}
+
+// Pipeline applies transformers to images in order
+type Pipeline struct {
+	transformers []Transformer
+}
+
+// NewPipeline builds the transformers specs name, in order. It returns nil
+// when specs is empty.
+func NewPipeline(specs []Spec) (*Pipeline, error) {
+	if len(specs) == 0 {
+		return nil, nil
+	}
+
+	p := &Pipeline{}
+	for _, spec := range specs {
+		t, err := New(spec)
+		if err != nil {
+			return nil, err
+		}
+		p.transformers = append(p.transformers, t)
+	}
+	return p, nil
+}
+
+// NewPipelineOf builds a pipeline from transformers, in order
+func NewPipelineOf(transformers ...Transformer) *Pipeline {
+	return &Pipeline{transformers: transformers}
+}
+
+// Empty reports whether the pipeline changes nothing
+func (p *Pipeline) Empty() bool {
+	return p == nil || len(p.transformers) == 0
+}
+
+// Names returns the names of the pipeline's transformers, in order
+func (p *Pipeline) Names() []string {
+	if p == nil {
+		return nil
+	}
+	names := make([]string, 0, len(p.transformers))
+	for _, t := range p.transformers {
+		names = append(names, t.Name())
+	}
+	return names
+}
+
+// Apply returns img with every transformer applied. Rewritten layers are
+// spooled to the work directory until cleanup is called, which must happen
+// after the returned image has been pushed.
+func (p *Pipeline) Apply(img v1.Image) (transformed v1.Image, cleanup func(), err error) {
+	var spooled []string
+	cleanup = func() {
+		for _, path := range spooled {
+			_ = os.Remove(path)
+		}
+	}
+	defer func() {
+		if err != nil {
+			cleanup()
+		}
+	}()
+
+	if p.Empty() {
+		return img, cleanup, nil
+	}
+
+	manifest, err := img.Manifest()
+	if err != nil {
+		return nil, cleanup, errors.Wrap(err, "failed to read manifest")
+	}
+	cfg, err := img.ConfigFile()
+	if err != nil {
+		return nil, cleanup, errors.Wrap(err, "failed to read config")
+	}
+	cfg = cfg.DeepCopy()
+
+	layers, err := img.Layers()
+	if err != nil {
+		return nil, cleanup, errors.Wrap(err, "failed to read layers")
+	}
+
+	base := mutate.ConfigMediaType(mutate.MediaType(empty.Image, manifest.MediaType), manifest.Config.MediaType)
+	addenda := make([]mutate.Addendum, 0, len(layers))
+	diffIDs := make([]v1.Hash, 0, len(layers))
+	for i, source := range layers {
+		layer, path, err := p.transformLayer(source, manifest.MediaType)
+		if path != "" {
+			spooled = append(spooled, path)
+		}
+		if err != nil {
+			return nil, cleanup, errors.Wrapf(err, "failed to transform layer %d", i+1)
+		}
+		if changed, err := layerChanged(source, layer); err != nil {
+			return nil, cleanup, errors.Wrapf(err, "failed to compute digest of layer %d", i+1)
+		} else if changed {
+			layer = rewrittenLayer{layer}
+		}
+
+		diffID, err := layer.DiffID()
+		if err != nil {
+			return nil, cleanup, errors.Wrapf(err, "failed to compute diff ID of layer %d", i+1)
+		}
+		diffIDs = append(diffIDs, diffID)
+
+		addendum := mutate.Addendum{Layer: layer}
+		if i < len(manifest.Layers) && len(manifest.Layers[i].Annotations) > 0 {
+			addendum.Annotations = manifest.Layers[i].Annotations
+		}
+		addenda = append(addenda, addendum)
+	}
+
+	for _, t := range p.transformers {
+		if ct, ok := t.(ConfigTransformer); ok {
+			if err := ct.TransformConfig(cfg); err != nil {
+				return nil, cleanup, errors.Wrapf(err, "transformer %s failed on config", t.Name())
+			}
+		}
+	}
+	cfg.RootFS.DiffIDs = diffIDs
+
+	withLayers, err := mutate.Append(base, addenda...)
+	if err != nil {
+		return nil, cleanup, errors.Wrap(err, "failed to assemble layers")
+	}
+	transformed, err = mutate.ConfigFile(withLayers, cfg)
+	if err != nil {
+		return nil, cleanup, errors.Wrap(err, "failed to set config")
+	}
+	if len(manifest.Annotations) > 0 {
+		transformed = mutate.Annotations(transformed, manifest.Annotations).(v1.Image)
+	}
+	return transformed, cleanup, nil
+}
+
+// rewrittenLayer hides the descriptor of a new layer, so its manifest entry
+// is built from its digest, size and media type alone
+type rewrittenLayer struct {
+	v1.Layer
+}
+
+// layerChanged reports whether layer has different content than source
+func layerChanged(source, layer v1.Layer) (bool, error) {
+	sourceDigest, err := source.Digest()
+	if err != nil {
+		return false, err
+	}
+	digest, err := layer.Digest()
+	if err != nil {
+		return false, err
+	}
+	return digest != sourceDigest, nil
+}
+
+// transformLayer applies the layer and file transformers to layer. It
+// returns the path of the spooled rewrite, if any.
+func (p *Pipeline) transformLayer(layer v1.Layer, manifestType types.MediaType) (v1.Layer, string, error) {
+	var files []FileTransformer
+	for _, t := range p.transformers {
+		if lt, ok := t.(LayerTransformer); ok {
+			next, err := lt.TransformLayer(layer)
+			if err != nil {
+				return nil, "", fmt.Errorf("transformer %s: %w", t.Name(), err)
+			}
+			layer = next
+		}
+		if ft, ok := t.(FileTransformer); ok {
+			files = append(files, ft)
+		}
+	}
+	if len(files) == 0 {
+		return layer, "", nil
+	}
+
+	// Foreign layers cannot be fetched, so their files stay as they are
+	mediaType, err := layer.MediaType()
+	if err != nil {
+		return nil, "", err
+	}
+	if !mediaType.IsDistributable() {
+		return layer, "", nil
+	}
+
+	path, err := rewriteFiles(layer, files)
+	if err != nil {
+		return nil, path, err
+	}
+	rewritten, err := tarball.LayerFromFile(path, layerOptions(mediaType, manifestType)...)
+	return rewritten, path, err
+}
+
+// rewriteFiles writes the uncompressed content of layer, passed through
+// files, to a temporary file in the work directory and returns its path
+func rewriteFiles(layer v1.Layer, files []FileTransformer) (string, error) {
+	dir := transport.WorkDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", errors.Wrap(err, "failed to create work directory")
+	}
+	out, err := os.CreateTemp(dir, "transform-*.tar")
+	if err != nil {
+		return "", errors.Wrap(err, "failed to create spool file")
+	}
+	path := out.Name()
+	defer out.Close()
+
+	rc, err := layer.Uncompressed()
+	if err != nil {
+		return path, errors.Wrap(err, "failed to read layer")
+	}
+	defer rc.Close()
+
+	tr := tar.NewReader(rc)
+	tw := tar.NewWriter(out)
+entries:
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return path, errors.Wrap(err, "failed to read layer entry")
+		}
+
+		var content io.Reader = tr
+		for _, ft := range files {
+			hdr, content, err = ft.TransformFile(hdr, content)
+			if err != nil {
+				return path, fmt.Errorf("transformer %s: %w", ft.Name(), err)
+			}
+			if hdr == nil {
+				continue entries
+			}
+		}
+
+		if err := tw.WriteHeader(hdr); err != nil {
+			return path, errors.Wrapf(err, "failed to write layer entry %s", hdr.Name)
+		}
+		if _, err := io.Copy(tw, content); err != nil {
+			return path, errors.Wrapf(err, "failed to write layer entry %s", hdr.Name)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return path, errors.Wrap(err, "failed to finish layer")
+	}
+	return path, out.Close()
+}
+
+// layerOptions keeps the compression of the source layer where the tarball
+// package can produce it, and gzip otherwise
+func layerOptions(mediaType, manifestType types.MediaType) []tarball.LayerOption {
+	switch mediaType {
+	case types.OCILayerZStd:
+		return []tarball.LayerOption{tarball.WithCompression(compression.ZStd), tarball.WithMediaType(mediaType)}
+	case types.OCILayer, types.DockerLayer:
+		return []tarball.LayerOption{tarball.WithMediaType(mediaType)}
+	}
+	if manifestType == types.DockerManifestSchema2 {
+		return []tarball.LayerOption{tarball.WithMediaType(types.DockerLayer)}
+	}
+	return []tarball.LayerOption{tarball.WithMediaType(types.OCILayer)}
+}
@@ -0,0 +1,169 @@
+package transform
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/google/go-containerregistry/pkg/v1/validate"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testLayer builds a layer holding files, each with its name as content
+func testLayer(t *testing.T, files ...string) v1.Layer {
+	t.Helper()
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, name := range files {
+		require.NoError(t, tw.WriteHeader(&tar.Header{
+			Name:    name,
+			Mode:    0644,
+			Size:    int64(len(name)),
+			ModTime: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC),
+		}))
+		_, err := tw.Write([]byte(name))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+
+	data := buf.Bytes()
+	layer, err := tarball.LayerFromOpener(func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(data)), nil
+	})
+	require.NoError(t, err)
+	return layer
+}
+
+// layerFiles returns the entries of layer by name
+func layerFiles(t *testing.T, layer v1.Layer) map[string]*tar.Header {
+	t.Helper()
+
+	rc, err := layer.Uncompressed()
+	require.NoError(t, err)
+	defer rc.Close()
+
+	files := map[string]*tar.Header{}
+	tr := tar.NewReader(rc)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return files
+		}
+		require.NoError(t, err)
+		files[hdr.Name] = hdr
+	}
+}
+
+func TestPipelineApply(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+
+	img, err := mutate.AppendLayers(empty.Image,
+		testLayer(t, "etc/os-release", "root/.aws/credentials", "root/.aws/config"),
+		testLayer(t, "app/server", "app/.env"),
+	)
+	require.NoError(t, err)
+	img, err = mutate.Config(img, v1.Config{Labels: map[string]string{
+		"org.opencontainers.image.revision": "abc123",
+		"build.host":                        "ci-7",
+		"maintainer":                        "team",
+	}})
+	require.NoError(t, err)
+
+	pipeline, err := NewPipeline([]Spec{
+		{Name: "strip-files", Options: map[string]interface{}{"paths": []interface{}{"root/.aws", "*/.env"}}},
+		{Name: "normalize-timestamps"},
+		{Name: "strip-labels", Options: map[string]interface{}{"labels": []interface{}{"org.opencontainers.image.*", "build.*"}}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"strip-files", "normalize-timestamps", "strip-labels"}, pipeline.Names())
+
+	transformed, cleanup, err := pipeline.Apply(img)
+	require.NoError(t, err)
+	defer cleanup()
+
+	require.NoError(t, validate.Image(transformed), "digests and diff IDs are recomputed")
+
+	layers, err := transformed.Layers()
+	require.NoError(t, err)
+	require.Len(t, layers, 2)
+
+	first := layerFiles(t, layers[0])
+	assert.Contains(t, first, "etc/os-release")
+	assert.NotContains(t, first, "root/.aws/credentials")
+	assert.NotContains(t, first, "root/.aws/config")
+	assert.True(t, first["etc/os-release"].ModTime.Equal(time.Unix(0, 0)))
+
+	second := layerFiles(t, layers[1])
+	assert.Contains(t, second, "app/server")
+	assert.NotContains(t, second, "app/.env")
+
+	cfg, err := transformed.ConfigFile()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"maintainer": "team"}, cfg.Config.Labels)
+	assert.True(t, cfg.Created.Time.Equal(time.Unix(0, 0)))
+
+	srcDigest, err := img.Digest()
+	require.NoError(t, err)
+	digest, err := transformed.Digest()
+	require.NoError(t, err)
+	assert.NotEqual(t, srcDigest, digest)
+}
+
+func TestNewPipeline(t *testing.T) {
+	pipeline, err := NewPipeline(nil)
+	require.NoError(t, err)
+	assert.True(t, pipeline.Empty())
+
+	_, err = NewPipeline([]Spec{{Name: "no-such-transformer"}})
+	assert.ErrorContains(t, err, "unknown transformer")
+
+	_, err = NewPipeline([]Spec{{Name: "strip-files"}})
+	assert.ErrorContains(t, err, "paths")
+
+	_, err = NewPipeline([]Spec{{Name: "strip-labels", Options: map[string]interface{}{"label": []interface{}{"x"}}}})
+	assert.Error(t, err, "unknown options are rejected")
+
+	_, err = NewPipeline([]Spec{{Name: "normalize-timestamps", Options: map[string]interface{}{"time": "2026-01-01T00:00:00Z"}}})
+	assert.NoError(t, err)
+}
+
+// renameLayer is a LayerTransformer replacing every layer with one file
+type renameLayer struct {
+	t *testing.T
+}
+
+func (r renameLayer) Name() string { return "rename-layer" }
+
+func (r renameLayer) TransformLayer(layer v1.Layer) (v1.Layer, error) {
+	return testLayer(r.t, "replaced"), nil
+}
+
+func TestRegisterLayerTransformer(t *testing.T) {
+	Register("rename-layer", func(options map[string]interface{}) (Transformer, error) {
+		return renameLayer{t: t}, nil
+	})
+	assert.Contains(t, Registered(), "rename-layer")
+
+	img, err := mutate.AppendLayers(empty.Image, testLayer(t, "base"))
+	require.NoError(t, err)
+
+	pipeline, err := NewPipeline([]Spec{{Name: "rename-layer"}})
+	require.NoError(t, err)
+	transformed, cleanup, err := pipeline.Apply(img)
+	require.NoError(t, err)
+	defer cleanup()
+	require.NoError(t, validate.Image(transformed))
+
+	layers, err := transformed.Layers()
+	require.NoError(t, err)
+	require.Len(t, layers, 1)
+	assert.Contains(t, layerFiles(t, layers[0]), "replaced")
+}
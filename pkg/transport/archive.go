@@ -10,6 +10,10 @@ import (
 	"path/filepath"
 	"strings"
 	"sync"
+
+	"freightliner/pkg/storage"
+
+	"github.com/opencontainers/go-digest"
 )
 
 // DockerArchiveTransport implements the docker-archive: transport for tar archives
@@ -128,9 +132,16 @@ func (r *DockerArchiveReference) NewImageSource(ctx context.Context) (ImageSourc
 
 // NewImageDestination returns an ImageDestination for writing
 func (r *DockerArchiveReference) NewImageDestination(ctx context.Context) (ImageDestination, error) {
+	// Layers are staged in the work directory until Commit assembles them
+	spool, err := openSpool(WorkDir())
+	if err != nil {
+		return nil, err
+	}
+
 	return &DockerArchiveImageDestination{
 		ref:       r,
 		path:      r.path,
+		spool:     spool,
 		layers:    make(map[string]string),
 		tempFiles: make([]string, 0),
 	}, nil
@@ -503,6 +514,7 @@ type DockerArchiveImageDestination struct {
 	path      string
 	config    []byte
 	manifest  []byte
+	spool     *storage.Spool
 	layers    map[string]string // digest -> temp file path
 	tempFiles []string
 	mu        sync.Mutex
@@ -568,35 +580,32 @@ func (d *DockerArchiveImageDestination) PutBlob(ctx context.Context, stream io.R
 	d.mu.Lock()
 	defer d.mu.Unlock()
 
-	// Create temp file for this blob
-	tempFile, err := os.CreateTemp("", "freightliner-archive-*.tmp")
-	if err != nil {
-		return LayerInfo{}, fmt.Errorf("failed to create temp file: %w", err)
+	if err := d.spool.EnsureFree(inputInfo.Size); err != nil {
+		return LayerInfo{}, fmt.Errorf("failed to write blob: %w", err)
 	}
-	d.tempFiles = append(d.tempFiles, tempFile.Name())
 
-	// Write blob to temp file
-	written, err := io.Copy(tempFile, stream)
+	// Spool blob to a temp file, verifying its digest
+	blob, err := d.spool.Write(ctx, stream, digest.Digest(inputInfo.Digest))
 	if err != nil {
-		tempFile.Close()
 		return LayerInfo{}, fmt.Errorf("failed to write blob: %w", err)
 	}
-	tempFile.Close()
+	d.tempFiles = append(d.tempFiles, blob.Path)
 
 	if isConfig {
 		// Read config data
-		data, err := os.ReadFile(tempFile.Name())
+		data, err := os.ReadFile(blob.Path)
 		if err != nil {
 			return LayerInfo{}, fmt.Errorf("failed to read config: %w", err)
 		}
 		d.config = data
 	} else {
 		// Store layer temp file path
-		d.layers[inputInfo.Digest] = tempFile.Name()
+		d.layers[blob.Digest.String()] = blob.Path
 	}
 
 	outputInfo := inputInfo
-	outputInfo.Size = written
+	outputInfo.Digest = blob.Digest.String()
+	outputInfo.Size = blob.Size
 	return outputInfo, nil
 }
 
@@ -620,7 +629,9 @@ func (d *DockerArchiveImageDestination) PutSignatures(ctx context.Context, signa
 	return fmt.Errorf("docker-archive transport does not support signatures")
 }
 
-// Commit commits the image by creating the tar archive
+// Commit commits the image by creating the tar archive. The archive is
+// written to a partial file next to it and renamed into place once complete,
+// so an interrupted commit never leaves a truncated archive behind.
 func (d *DockerArchiveImageDestination) Commit(ctx context.Context, unparsedToplevel interface{}) error {
 	d.mu.Lock()
 	defer d.mu.Unlock()
@@ -629,15 +640,56 @@ func (d *DockerArchiveImageDestination) Commit(ctx context.Context, unparsedTopl
 		return fmt.Errorf("manifest not set")
 	}
 
-	// Create the archive file
-	file, err := os.Create(d.path)
+	// Check free space for the whole archive before writing any of it
+	size := int64(len(d.config))
+	for _, tempPath := range d.layers {
+		info, err := os.Stat(tempPath)
+		if err != nil {
+			return fmt.Errorf("failed to read layer: %w", err)
+		}
+		size += info.Size()
+	}
+
+	spool, err := openSpool(filepath.Dir(d.path))
 	if err != nil {
+		return err
+	}
+	if err := spool.EnsureFree(size); err != nil {
 		return fmt.Errorf("failed to create archive: %w", err)
 	}
-	defer file.Close()
 
-	tw := tar.NewWriter(file)
-	defer tw.Close()
+	file, err := spool.Create()
+	if err != nil {
+		return fmt.Errorf("failed to create archive: %w", err)
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			file.Close()
+			os.Remove(file.Name())
+		}
+	}()
+
+	if err := d.writeArchive(ctx, file); err != nil {
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+	if err := os.Rename(file.Name(), d.path); err != nil {
+		return fmt.Errorf("failed to create archive: %w", err)
+	}
+	committed = true
+
+	return nil
+}
+
+// writeArchive writes the manifest, config and layers as a tar stream
+func (d *DockerArchiveImageDestination) writeArchive(ctx context.Context, w io.Writer) error {
+	tw := tar.NewWriter(w)
 
 	// Write manifest.json
 	manifestData, err := json.Marshal([]DockerArchiveManifest{
@@ -680,9 +732,8 @@ func (d *DockerArchiveImageDestination) Commit(ctx context.Context, unparsedTopl
 
 	// Write layers
 	for digest, tempPath := range d.layers {
-		layerData, err := os.ReadFile(tempPath)
-		if err != nil {
-			return fmt.Errorf("failed to read layer: %w", err)
+		if err := ctx.Err(); err != nil {
+			return err
 		}
 
 		// Use digest as filename
@@ -691,19 +742,41 @@ func (d *DockerArchiveImageDestination) Commit(ctx context.Context, unparsedTopl
 			filename = strings.ReplaceAll(digest, ":", "-") + ".tar"
 		}
 
-		if err := tw.WriteHeader(&tar.Header{
-			Name: filename,
-			Mode: 0644,
-			Size: int64(len(layerData)),
-		}); err != nil {
-			return fmt.Errorf("failed to write layer header: %w", err)
+		if err := writeLayer(tw, filename, tempPath); err != nil {
+			return err
 		}
+	}
 
-		if _, err := tw.Write(layerData); err != nil {
-			return fmt.Errorf("failed to write layer: %w", err)
-		}
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
 	}
+	return nil
+}
 
+// writeLayer streams a spooled layer into the archive
+func writeLayer(tw *tar.Writer, name, path string) error {
+	layer, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to read layer: %w", err)
+	}
+	defer layer.Close()
+
+	info, err := layer.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to read layer: %w", err)
+	}
+
+	if err := tw.WriteHeader(&tar.Header{
+		Name: name,
+		Mode: 0644,
+		Size: info.Size(),
+	}); err != nil {
+		return fmt.Errorf("failed to write layer header: %w", err)
+	}
+
+	if _, err := io.Copy(tw, layer); err != nil {
+		return fmt.Errorf("failed to write layer: %w", err)
+	}
 	return nil
 }
 
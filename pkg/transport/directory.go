@@ -7,6 +7,12 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
+
+	"freightliner/pkg/storage"
+
+	"github.com/opencontainers/go-digest"
 )
 
 // DirectoryTransport implements the dir: transport for local directories
@@ -114,9 +120,16 @@ func (r *DirectoryReference) NewImageDestination(ctx context.Context) (ImageDest
 		return nil, fmt.Errorf("failed to create directory: %w", err)
 	}
 
+	spool, err := openSpool(filepath.Join(r.path, partialDir))
+	if err != nil {
+		return nil, err
+	}
+
 	return &DirectoryImageDestination{
-		ref:  r,
-		path: r.path,
+		ref:     r,
+		path:    r.path,
+		spool:   spool,
+		written: make(map[string]bool),
 	}, nil
 }
 
@@ -308,10 +321,8 @@ func (s *DirectoryImageSource) GetBlob(ctx context.Context, info LayerInfo, cach
 	// Try digest with and without algorithm prefix
 	blobPath := filepath.Join(s.path, info.Digest)
 	if _, err := os.Stat(blobPath); os.IsNotExist(err) {
-		// Try without algorithm prefix (sha256:xxx -> xxx)
-		if len(info.Digest) > 7 && info.Digest[6] == ':' {
-			blobPath = filepath.Join(s.path, info.Digest[7:])
-		}
+		// Try without algorithm prefix (sha256:xxx or sha512:xxx -> xxx)
+		blobPath = filepath.Join(s.path, blobFileName(info.Digest))
 	}
 
 	file, err := os.Open(blobPath)
@@ -377,7 +388,9 @@ func (s *DirectoryImageSource) LayerInfosForCopy(ctx context.Context) ([]LayerIn
 type DirectoryImageDestination struct {
 	ref     *DirectoryReference
 	path    string
+	spool   *storage.Spool
 	written map[string]bool
+	mu      sync.Mutex
 }
 
 // Reference returns the image reference
@@ -387,6 +400,7 @@ func (d *DirectoryImageDestination) Reference() Reference {
 
 // Close releases resources
 func (d *DirectoryImageDestination) Close() error {
+	closeSpool(d.spool)
 	return nil
 }
 
@@ -430,53 +444,45 @@ func (d *DirectoryImageDestination) HasThreadSafePutBlob() bool {
 	return true
 }
 
-// PutBlob writes a blob (layer or config)
+// PutBlob writes a blob (layer or config). The blob is spooled to a partial
+// file and only renamed to its digest-named path once its digest matches.
 func (d *DirectoryImageDestination) PutBlob(ctx context.Context, stream io.Reader, inputInfo LayerInfo, cache BlobInfoCache, isConfig bool) (LayerInfo, error) {
-	if d.written == nil {
-		d.written = make(map[string]bool)
-	}
-
-	// Use digest as filename
-	filename := inputInfo.Digest
-	if len(filename) > 7 && filename[6] == ':' {
-		filename = filename[7:] // Remove algorithm prefix
-	}
-
-	blobPath := filepath.Join(d.path, filename)
-
 	// Check if already written
-	if d.written[blobPath] {
+	d.mu.Lock()
+	written := d.written[inputInfo.Digest]
+	d.mu.Unlock()
+	if written {
 		return inputInfo, nil
 	}
 
-	// Write blob to file
-	file, err := os.Create(blobPath)
-	if err != nil {
-		return LayerInfo{}, fmt.Errorf("failed to create blob file: %w", err)
+	if err := d.spool.EnsureFree(inputInfo.Size); err != nil {
+		return LayerInfo{}, fmt.Errorf("failed to write blob: %w", err)
 	}
-	defer file.Close()
 
-	written, err := io.Copy(file, stream)
+	blob, err := d.spool.Write(ctx, stream, digest.Digest(inputInfo.Digest))
 	if err != nil {
 		return LayerInfo{}, fmt.Errorf("failed to write blob: %w", err)
 	}
 
-	d.written[blobPath] = true
+	// Use digest without algorithm prefix as filename
+	if err := blob.Commit(filepath.Join(d.path, blob.Digest.Encoded())); err != nil {
+		return LayerInfo{}, fmt.Errorf("failed to write blob: %w", err)
+	}
+
+	d.mu.Lock()
+	d.written[blob.Digest.String()] = true
+	d.mu.Unlock()
 
 	outputInfo := inputInfo
-	outputInfo.Size = written
+	outputInfo.Digest = blob.Digest.String()
+	outputInfo.Size = blob.Size
 
 	return outputInfo, nil
 }
 
 // TryReusingBlob checks if a blob can be reused
 func (d *DirectoryImageDestination) TryReusingBlob(ctx context.Context, info LayerInfo, cache BlobInfoCache, canSubstitute bool) (bool, LayerInfo, error) {
-	filename := info.Digest
-	if len(filename) > 7 && filename[6] == ':' {
-		filename = filename[7:]
-	}
-
-	blobPath := filepath.Join(d.path, filename)
+	blobPath := filepath.Join(d.path, blobFileName(info.Digest))
 	if _, err := os.Stat(blobPath); err == nil {
 		// Blob already exists
 		return true, info, nil
@@ -506,3 +512,12 @@ func init() {
 	// Register directory transport
 	RegisterTransport(NewDirectoryTransport())
 }
+
+// blobFileName returns the encoded part of an "<algorithm>:<encoded>" digest,
+// the name directory layouts store blobs under
+func blobFileName(d string) string {
+	if _, encoded, ok := strings.Cut(d, ":"); ok {
+		return encoded
+	}
+	return d
+}
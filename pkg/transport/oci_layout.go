@@ -8,6 +8,11 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
+
+	"freightliner/pkg/storage"
+
+	"github.com/opencontainers/go-digest"
 )
 
 // OCILayoutTransport implements the oci: transport for OCI Image Layout
@@ -34,7 +39,7 @@ func (t *OCILayoutTransport) ValidateReference(ref string) error {
 }
 
 // ParseReference parses an OCI layout reference
-// Format: /path/to/layout:tag or /path/to/layout@sha256:digest
+// Format: /path/to/layout:tag or /path/to/layout@<algorithm>:digest
 func (t *OCILayoutTransport) ParseReference(ref string) (Reference, error) {
 	if err := t.ValidateReference(ref); err != nil {
 		return nil, err
@@ -44,19 +49,14 @@ func (t *OCILayoutTransport) ParseReference(ref string) (Reference, error) {
 	path := ref
 	reference := "latest"
 
-	// Check for tag separator
-	if idx := strings.LastIndex(ref, ":"); idx > 0 {
-		// Make sure it's not part of a digest (sha256:...)
-		if idx > 6 && ref[idx-6:idx] != "sha256" {
-			path = ref[:idx]
-			reference = ref[idx+1:]
-		}
-	}
-
-	// Check for digest separator
+	// A digest follows "@" and contains the algorithm's colon (sha256:...,
+	// sha512:...); otherwise a tag follows the last colon
 	if idx := strings.LastIndex(ref, "@"); idx > 0 {
 		path = ref[:idx]
 		reference = ref[idx+1:]
+	} else if idx := strings.LastIndex(ref, ":"); idx > 0 && !strings.Contains(ref[idx:], "/") {
+		path = ref[:idx]
+		reference = ref[idx+1:]
 	}
 
 	absPath, err := filepath.Abs(path)
@@ -134,10 +134,17 @@ func (r *OCILayoutReference) NewImageDestination(ctx context.Context) (ImageDest
 		return nil, err
 	}
 
+	spool, err := openSpool(filepath.Join(r.path, partialDir))
+	if err != nil {
+		return nil, err
+	}
+
 	return &OCILayoutImageDestination{
 		ref:       r,
 		path:      r.path,
 		reference: r.reference,
+		spool:     spool,
+		written:   make(map[string]bool),
 	}, nil
 }
 
@@ -211,7 +218,7 @@ func (r *OCILayoutReference) initializeLayout() error {
 		return fmt.Errorf("failed to create layout directory: %w", err)
 	}
 
-	blobsPath := filepath.Join(r.path, "blobs", "sha256")
+	blobsPath := filepath.Join(r.path, "blobs", string(digest.Canonical))
 	if err := os.MkdirAll(blobsPath, 0755); err != nil {
 		return fmt.Errorf("failed to create blobs directory: %w", err)
 	}
@@ -530,7 +537,9 @@ type OCILayoutImageDestination struct {
 	ref       *OCILayoutReference
 	path      string
 	reference string
+	spool     *storage.Spool
 	written   map[string]bool
+	mu        sync.Mutex
 }
 
 // Reference returns the image reference
@@ -540,6 +549,7 @@ func (d *OCILayoutImageDestination) Reference() Reference {
 
 // Close releases resources
 func (d *OCILayoutImageDestination) Close() error {
+	closeSpool(d.spool)
 	return nil
 }
 
@@ -583,40 +593,37 @@ func (d *OCILayoutImageDestination) HasThreadSafePutBlob() bool {
 	return true
 }
 
-// PutBlob writes a blob (layer or config)
+// PutBlob writes a blob (layer or config). The blob is spooled to a partial
+// file and only renamed into blobs/ once its digest matches.
 func (d *OCILayoutImageDestination) PutBlob(ctx context.Context, stream io.Reader, inputInfo LayerInfo, cache BlobInfoCache, isConfig bool) (LayerInfo, error) {
-	if d.written == nil {
-		d.written = make(map[string]bool)
-	}
-
-	blobPath := d.blobPath(inputInfo.Digest)
-
 	// Check if already written
-	if d.written[blobPath] {
+	d.mu.Lock()
+	written := d.written[inputInfo.Digest]
+	d.mu.Unlock()
+	if written {
 		return inputInfo, nil
 	}
 
-	// Create algorithm directory if needed
-	if err := os.MkdirAll(filepath.Dir(blobPath), 0755); err != nil {
-		return LayerInfo{}, fmt.Errorf("failed to create blob directory: %w", err)
+	if err := d.spool.EnsureFree(inputInfo.Size); err != nil {
+		return LayerInfo{}, fmt.Errorf("failed to write blob: %w", err)
 	}
 
-	// Write blob
-	file, err := os.Create(blobPath)
+	blob, err := d.spool.Write(ctx, stream, digest.Digest(inputInfo.Digest))
 	if err != nil {
-		return LayerInfo{}, fmt.Errorf("failed to create blob file: %w", err)
+		return LayerInfo{}, fmt.Errorf("failed to write blob: %w", err)
 	}
-	defer file.Close()
 
-	written, err := io.Copy(file, stream)
-	if err != nil {
+	if err := blob.Commit(d.blobPath(blob.Digest.String())); err != nil {
 		return LayerInfo{}, fmt.Errorf("failed to write blob: %w", err)
 	}
 
-	d.written[blobPath] = true
+	d.mu.Lock()
+	d.written[blob.Digest.String()] = true
+	d.mu.Unlock()
 
 	outputInfo := inputInfo
-	outputInfo.Size = written
+	outputInfo.Digest = blob.Digest.String()
+	outputInfo.Size = blob.Size
 
 	return outputInfo, nil
 }
@@ -632,9 +639,17 @@ func (d *OCILayoutImageDestination) TryReusingBlob(ctx context.Context, info Lay
 
 // PutManifest writes the image manifest
 func (d *OCILayoutImageDestination) PutManifest(ctx context.Context, manifest []byte, instanceDigest *string) error {
-	// Calculate manifest digest
-	// For simplicity, using a basic approach - production code would use proper hashing
-	manifestDigest := fmt.Sprintf("sha256:%x", manifest[:32]) // Simplified
+	// Address the manifest with the algorithm of the digest it was requested
+	// by, or sha256
+	algorithm := digest.Canonical
+	if instanceDigest != nil && *instanceDigest != "" {
+		d, err := digest.Parse(*instanceDigest)
+		if err != nil {
+			return fmt.Errorf("invalid manifest digest %q: %w", *instanceDigest, err)
+		}
+		algorithm = d.Algorithm()
+	}
+	manifestDigest := algorithm.FromBytes(manifest).String()
 
 	// Write manifest blob
 	manifestPath := d.blobPath(manifestDigest)
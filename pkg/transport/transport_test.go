@@ -1,11 +1,13 @@
 package transport
 
 import (
+	"bytes"
 	"context"
 	"os"
 	"path/filepath"
 	"testing"
 
+	"github.com/opencontainers/go-digest"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -457,3 +459,104 @@ func TestImageSourceThreadSafety(t *testing.T) {
 		})
 	}
 }
+
+func TestPutBlobSpooling(t *testing.T) {
+	ctx := context.Background()
+	SetWorkDir(t.TempDir())
+	defer SetWorkDir("")
+
+	content := []byte("layer contents")
+	blobDigest := digest.FromBytes(content)
+
+	t.Run("directory renames verified blob", func(t *testing.T) {
+		tempDir := t.TempDir()
+
+		// A partial file left by a process that no longer exists
+		stale := filepath.Join(tempDir, partialDir, "freightliner-999999999-0000.partial")
+		require.NoError(t, os.MkdirAll(filepath.Dir(stale), 0755))
+		require.NoError(t, os.WriteFile(stale, []byte("trunc"), 0644))
+
+		ref, err := NewDirectoryTransport().ParseReference(tempDir)
+		require.NoError(t, err)
+		dest, err := ref.NewImageDestination(ctx)
+		require.NoError(t, err)
+		assert.NoFileExists(t, stale)
+
+		info, err := dest.PutBlob(ctx, bytes.NewReader(content), LayerInfo{Digest: blobDigest.String()}, nil, false)
+		require.NoError(t, err)
+		assert.Equal(t, int64(len(content)), info.Size)
+
+		data, err := os.ReadFile(filepath.Join(tempDir, blobDigest.Encoded()))
+		require.NoError(t, err)
+		assert.Equal(t, content, data)
+
+		require.NoError(t, dest.Close())
+		assert.NoDirExists(t, filepath.Join(tempDir, partialDir))
+	})
+
+	t.Run("oci layout stores sha512 blobs and manifests", func(t *testing.T) {
+		tempDir := t.TempDir()
+
+		ref, err := NewOCILayoutTransport().ParseReference(tempDir + ":latest")
+		require.NoError(t, err)
+		dest, err := ref.NewImageDestination(ctx)
+		require.NoError(t, err)
+		defer dest.Close()
+
+		blob512 := digest.SHA512.FromBytes(content)
+		_, err = dest.PutBlob(ctx, bytes.NewReader(content), LayerInfo{Digest: blob512.String()}, nil, false)
+		require.NoError(t, err)
+		assert.FileExists(t, filepath.Join(tempDir, "blobs", "sha512", blob512.Encoded()))
+
+		manifest := []byte(`{"schemaVersion":2}`)
+		manifest512 := digest.SHA512.FromBytes(manifest).String()
+		require.NoError(t, dest.PutManifest(ctx, manifest, &manifest512))
+		assert.FileExists(t, filepath.Join(tempDir, "blobs", "sha512", digest.SHA512.FromBytes(manifest).Encoded()))
+
+		byDigest, err := NewOCILayoutTransport().ParseReference(tempDir + "@" + manifest512)
+		require.NoError(t, err)
+		assert.Equal(t, manifest512, byDigest.(*OCILayoutReference).reference)
+	})
+
+	t.Run("oci layout rejects digest mismatch", func(t *testing.T) {
+		tempDir := t.TempDir()
+
+		ref, err := NewOCILayoutTransport().ParseReference(tempDir + ":latest")
+		require.NoError(t, err)
+		dest, err := ref.NewImageDestination(ctx)
+		require.NoError(t, err)
+		defer dest.Close()
+
+		wrong := digest.FromString("something else")
+		_, err = dest.PutBlob(ctx, bytes.NewReader(content), LayerInfo{Digest: wrong.String()}, nil, false)
+		assert.Error(t, err)
+
+		ok, _, err := dest.TryReusingBlob(ctx, LayerInfo{Digest: wrong.String()}, nil, false)
+		require.NoError(t, err)
+		assert.False(t, ok)
+
+		entries, err := os.ReadDir(filepath.Join(tempDir, partialDir))
+		require.NoError(t, err)
+		assert.Empty(t, entries)
+	})
+
+	t.Run("archive is renamed into place", func(t *testing.T) {
+		archivePath := filepath.Join(t.TempDir(), "image.tar")
+
+		ref, err := NewDockerArchiveTransport().ParseReference(archivePath + ":latest")
+		require.NoError(t, err)
+		dest, err := ref.NewImageDestination(ctx)
+		require.NoError(t, err)
+		defer dest.Close()
+
+		_, err = dest.PutBlob(ctx, bytes.NewReader(content), LayerInfo{Digest: blobDigest.String()}, nil, false)
+		require.NoError(t, err)
+		require.NoError(t, dest.PutManifest(ctx, []byte("{}"), nil))
+		require.NoError(t, dest.Commit(ctx, nil))
+
+		entries, err := os.ReadDir(filepath.Dir(archivePath))
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		assert.Equal(t, "image.tar", entries[0].Name())
+	})
+}
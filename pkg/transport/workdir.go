@@ -0,0 +1,55 @@
+package transport
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"freightliner/pkg/storage"
+)
+
+// partialDir is the directory, inside a destination, that blobs are spooled
+// to before being renamed to their digest-named path
+const partialDir = ".partial"
+
+var (
+	workDirMu sync.RWMutex
+	workDir   string
+)
+
+// SetWorkDir sets the directory blobs are staged in before a destination
+// assembles them, such as docker-archive layers. An empty dir restores the
+// default, a "freightliner" directory under the system temp directory.
+func SetWorkDir(dir string) {
+	workDirMu.Lock()
+	defer workDirMu.Unlock()
+	workDir = dir
+}
+
+// WorkDir returns the directory set by SetWorkDir, or the default
+func WorkDir() string {
+	workDirMu.RLock()
+	defer workDirMu.RUnlock()
+	if workDir != "" {
+		return workDir
+	}
+	return filepath.Join(os.TempDir(), "freightliner")
+}
+
+// openSpool opens a spool in dir, removing partial files left there by
+// interrupted runs
+func openSpool(dir string) (*storage.Spool, error) {
+	spool, err := storage.OpenSpool(dir, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open temp area: %w", err)
+	}
+	return spool, nil
+}
+
+// closeSpool removes the spool's directory if nothing is left in it
+func closeSpool(spool *storage.Spool) {
+	if spool != nil {
+		_ = os.Remove(spool.Dir())
+	}
+}
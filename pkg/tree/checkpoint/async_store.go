@@ -0,0 +1,257 @@
+package checkpoint
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"freightliner/pkg/helper/errors"
+)
+
+// SlowStorePolicy says what an AsyncStore does when a write exceeds its timeout
+type SlowStorePolicy string
+
+const (
+	// SlowStoreWait keeps checkpointing, coalescing saves while the slow
+	// write finishes
+	SlowStoreWait SlowStorePolicy = "wait"
+
+	// SlowStoreDisable abandons the slow write and stops checkpointing, so a
+	// hung store cannot hold back the run
+	SlowStoreDisable SlowStorePolicy = "disable"
+)
+
+// DefaultWriteTimeout is the write timeout used when none is configured
+const DefaultWriteTimeout = 30 * time.Second
+
+// ParseSlowStorePolicy parses a slow store policy, defaulting to SlowStoreWait
+func ParseSlowStorePolicy(s string) (SlowStorePolicy, error) {
+	switch SlowStorePolicy(s) {
+	case "", SlowStoreWait:
+		return SlowStoreWait, nil
+	case SlowStoreDisable:
+		return SlowStoreDisable, nil
+	default:
+		return "", errors.InvalidInputf("invalid checkpoint slow store policy: %s (must be wait or disable)", s)
+	}
+}
+
+// AsyncStoreOptions configures NewAsyncStore
+type AsyncStoreOptions struct {
+	// WriteTimeout bounds each write to the underlying store (default DefaultWriteTimeout)
+	WriteTimeout time.Duration
+
+	// SlowStore is the policy once a write times out (default SlowStoreWait)
+	SlowStore SlowStorePolicy
+
+	// OnWriteError is called with each failed or timed out write (optional)
+	OnWriteError func(id string, err error)
+}
+
+// AsyncStore writes checkpoints to another store in the background so savers
+// never wait on the filesystem. Saves snapshot the checkpoint and queue it;
+// at most one snapshot per checkpoint is pending, the newest, so a slow store
+// coalesces intermediate states instead of queueing them.
+type AsyncStore struct {
+	store CheckpointStore
+	opts  AsyncStoreOptions
+
+	mu       sync.Mutex
+	pending  map[string]*TreeCheckpoint
+	running  bool
+	idle     chan struct{} // Closed when the writer has nothing left to write
+	disabled error         // Why checkpointing stopped under SlowStoreDisable
+}
+
+// NewAsyncStore returns a store writing to store in the background
+func NewAsyncStore(store CheckpointStore, opts AsyncStoreOptions) *AsyncStore {
+	if opts.WriteTimeout <= 0 {
+		opts.WriteTimeout = DefaultWriteTimeout
+	}
+	if opts.SlowStore == "" {
+		opts.SlowStore = SlowStoreWait
+	}
+
+	idle := make(chan struct{})
+	close(idle)
+	return &AsyncStore{
+		store:   store,
+		opts:    opts,
+		pending: make(map[string]*TreeCheckpoint),
+		idle:    idle,
+	}
+}
+
+// SaveCheckpoint queues a snapshot of checkpoint for writing. It only fails
+// when the checkpoint cannot be snapshotted; write errors go to OnWriteError.
+func (s *AsyncStore) SaveCheckpoint(checkpoint *TreeCheckpoint) error {
+	if checkpoint == nil {
+		return errors.InvalidInputf("checkpoint cannot be nil")
+	}
+
+	snapshot, err := cloneCheckpoint(checkpoint)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.disabled != nil {
+		return nil
+	}
+	s.pending[snapshot.ID] = snapshot
+	if !s.running {
+		s.running = true
+		s.idle = make(chan struct{})
+		go s.run()
+	}
+	return nil
+}
+
+// run writes pending snapshots until there are none left
+func (s *AsyncStore) run() {
+	for {
+		s.mu.Lock()
+		var next *TreeCheckpoint
+		for id, snapshot := range s.pending {
+			next = snapshot
+			delete(s.pending, id)
+			break
+		}
+		if next == nil {
+			s.running = false
+			close(s.idle)
+			s.mu.Unlock()
+			return
+		}
+		s.mu.Unlock()
+
+		s.write(next)
+	}
+}
+
+// write saves a snapshot to the underlying store within the write timeout
+func (s *AsyncStore) write(snapshot *TreeCheckpoint) {
+	done := make(chan error, 1)
+	go func() {
+		done <- s.store.SaveCheckpoint(snapshot)
+	}()
+
+	timer := time.NewTimer(s.opts.WriteTimeout)
+	defer timer.Stop()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			s.reportError(snapshot.ID, err)
+		}
+		return
+	case <-timer.C:
+	}
+
+	timeoutErr := errors.Timeoutf("checkpoint write took longer than %s", s.opts.WriteTimeout)
+	s.reportError(snapshot.ID, timeoutErr)
+
+	if s.opts.SlowStore == SlowStoreDisable {
+		s.mu.Lock()
+		s.disabled = timeoutErr
+		s.pending = make(map[string]*TreeCheckpoint)
+		s.mu.Unlock()
+		return
+	}
+
+	// Writes of a checkpoint stay in order, so wait out the slow one
+	if err := <-done; err != nil {
+		s.reportError(snapshot.ID, err)
+	}
+}
+
+// reportError passes a write error to OnWriteError
+func (s *AsyncStore) reportError(id string, err error) {
+	if s.opts.OnWriteError != nil {
+		s.opts.OnWriteError(id, err)
+	}
+}
+
+// Flush waits up to timeout for pending snapshots to be written. It returns
+// an error when they were not, or when checkpointing has been disabled.
+func (s *AsyncStore) Flush(timeout time.Duration) error {
+	s.mu.Lock()
+	idle := s.idle
+	s.mu.Unlock()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-idle:
+	case <-timer.C:
+		return errors.Timeoutf("checkpoint writes did not finish within %s", timeout)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.disabled != nil {
+		return errors.Wrap(s.disabled, "checkpointing disabled")
+	}
+	return nil
+}
+
+// Disabled returns why checkpointing stopped, or nil while it is working
+func (s *AsyncStore) Disabled() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.disabled
+}
+
+// LoadCheckpoint returns the pending snapshot of id, if any, or loads it
+// from the underlying store
+func (s *AsyncStore) LoadCheckpoint(id string) (*TreeCheckpoint, error) {
+	s.mu.Lock()
+	snapshot, ok := s.pending[id]
+	s.mu.Unlock()
+	if ok {
+		return cloneCheckpoint(snapshot)
+	}
+	return s.store.LoadCheckpoint(id)
+}
+
+// ListCheckpoints lists the checkpoints of the underlying store
+func (s *AsyncStore) ListCheckpoints() ([]*TreeCheckpoint, error) {
+	return s.store.ListCheckpoints()
+}
+
+// DeleteCheckpoint drops any pending snapshot of id and deletes it from the
+// underlying store
+func (s *AsyncStore) DeleteCheckpoint(id string) error {
+	s.mu.Lock()
+	delete(s.pending, id)
+	s.mu.Unlock()
+	return s.store.DeleteCheckpoint(id)
+}
+
+// CheckpointExists reports whether id is pending or in the underlying store
+func (s *AsyncStore) CheckpointExists(id string) (bool, error) {
+	s.mu.Lock()
+	_, ok := s.pending[id]
+	s.mu.Unlock()
+	if ok {
+		return true, nil
+	}
+	return s.store.CheckpointExists(id)
+}
+
+// cloneCheckpoint deep copies a checkpoint so it can be written while the
+// caller keeps changing the original
+func cloneCheckpoint(checkpoint *TreeCheckpoint) (*TreeCheckpoint, error) {
+	data, err := json.Marshal(checkpoint)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to snapshot checkpoint")
+	}
+	var clone TreeCheckpoint
+	if err := json.Unmarshal(data, &clone); err != nil {
+		return nil, errors.Wrap(err, "failed to snapshot checkpoint")
+	}
+	return &clone, nil
+}
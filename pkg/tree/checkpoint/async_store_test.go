@@ -0,0 +1,141 @@
+package checkpoint
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// slowStore blocks writes until released
+type slowStore struct {
+	CheckpointStore
+	release chan struct{}
+
+	mu    sync.Mutex
+	saved []*TreeCheckpoint
+}
+
+func (s *slowStore) SaveCheckpoint(checkpoint *TreeCheckpoint) error {
+	<-s.release
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.saved = append(s.saved, checkpoint)
+	return nil
+}
+
+func TestAsyncStore(t *testing.T) {
+	store, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create file store: %v", err)
+	}
+	async := NewAsyncStore(store, AsyncStoreOptions{})
+
+	checkpoint := &TreeCheckpoint{ID: "async", Status: StatusInProgress, Repositories: map[string]RepoStatus{}}
+	if err := async.SaveCheckpoint(checkpoint); err != nil {
+		t.Fatalf("SaveCheckpoint() error = %v", err)
+	}
+
+	// Changes after saving do not leak into the queued snapshot
+	checkpoint.Repositories["repo"] = RepoStatus{Status: StatusCompleted}
+	checkpoint.Status = StatusCompleted
+
+	if err := async.Flush(time.Second); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	saved, err := store.LoadCheckpoint("async")
+	if err != nil {
+		t.Fatalf("LoadCheckpoint() error = %v", err)
+	}
+	if saved.Status != StatusInProgress || len(saved.Repositories) != 0 {
+		t.Errorf("Expected the snapshot taken at save time, got %+v", saved)
+	}
+}
+
+func TestAsyncStoreCoalescesSlowWrites(t *testing.T) {
+	slow := &slowStore{release: make(chan struct{})}
+	var timeouts int
+	var mu sync.Mutex
+	async := NewAsyncStore(slow, AsyncStoreOptions{
+		WriteTimeout: 20 * time.Millisecond,
+		OnWriteError: func(id string, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			timeouts++
+		},
+	})
+
+	for progress := 1; progress <= 5; progress++ {
+		if err := async.SaveCheckpoint(&TreeCheckpoint{ID: "slow", Progress: float64(progress)}); err != nil {
+			t.Fatalf("SaveCheckpoint() error = %v", err)
+		}
+	}
+
+	// The pending save is visible before it is written
+	pending, err := async.LoadCheckpoint("slow")
+	if err != nil || pending.Progress != 5 {
+		t.Fatalf("Expected the newest pending snapshot, got %+v, %v", pending, err)
+	}
+
+	if err := async.Flush(50 * time.Millisecond); err == nil {
+		t.Error("Expected Flush to time out while the store is blocked")
+	}
+	close(slow.release)
+	if err := async.Flush(time.Second); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+
+	slow.mu.Lock()
+	defer slow.mu.Unlock()
+	if len(slow.saved) > 2 {
+		t.Errorf("Expected intermediate saves to be coalesced, got %d writes", len(slow.saved))
+	}
+	if last := slow.saved[len(slow.saved)-1]; last.Progress != 5 {
+		t.Errorf("Expected the newest snapshot to be written last, got progress %v", last.Progress)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if timeouts == 0 {
+		t.Error("Expected the slow write to be reported")
+	}
+}
+
+func TestAsyncStoreDisablesOnSlowWrite(t *testing.T) {
+	slow := &slowStore{release: make(chan struct{})}
+	defer close(slow.release)
+	async := NewAsyncStore(slow, AsyncStoreOptions{
+		WriteTimeout: 10 * time.Millisecond,
+		SlowStore:    SlowStoreDisable,
+	})
+
+	if err := async.SaveCheckpoint(&TreeCheckpoint{ID: "hung"}); err != nil {
+		t.Fatalf("SaveCheckpoint() error = %v", err)
+	}
+	if err := async.Flush(time.Second); err == nil {
+		t.Fatal("Expected Flush to report disabled checkpointing")
+	}
+	if async.Disabled() == nil {
+		t.Error("Expected checkpointing to be disabled")
+	}
+
+	// Later saves are dropped without waiting on the store
+	if err := async.SaveCheckpoint(&TreeCheckpoint{ID: "hung"}); err != nil {
+		t.Fatalf("SaveCheckpoint() error = %v", err)
+	}
+	async.mu.Lock()
+	defer async.mu.Unlock()
+	if len(async.pending) != 0 {
+		t.Error("Expected no pending checkpoint once disabled")
+	}
+}
+
+func TestParseSlowStorePolicy(t *testing.T) {
+	for input, want := range map[string]SlowStorePolicy{"": SlowStoreWait, "wait": SlowStoreWait, "disable": SlowStoreDisable} {
+		got, err := ParseSlowStorePolicy(input)
+		if err != nil || got != want {
+			t.Errorf("ParseSlowStorePolicy(%q) = %q, %v; want %q", input, got, err, want)
+		}
+	}
+	if _, err := ParseSlowStorePolicy("drop"); err == nil {
+		t.Error("Expected an error for an unknown policy")
+	}
+}
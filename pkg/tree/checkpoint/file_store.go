@@ -4,11 +4,11 @@ import (
 	"encoding/json"
 	"os"
 	"path/filepath"
-	"strings"
 	"sync"
 	"time"
 
 	"freightliner/pkg/helper/errors"
+	"freightliner/pkg/helper/util"
 )
 
 // FileStore implements the CheckpointStore interface using the filesystem
@@ -23,13 +23,9 @@ type FileStore struct {
 // NewFileStore creates a new file-based checkpoint store
 func NewFileStore(directory string) (*FileStore, error) {
 	// Expand HOME directory if present
-	if strings.HasPrefix(directory, "${HOME}") || strings.HasPrefix(directory, "$HOME") {
-		home, err := os.UserHomeDir()
-		if err != nil {
-			return nil, errors.Wrap(err, "failed to get user home directory")
-		}
-		directory = strings.Replace(directory, "${HOME}", home, 1)
-		directory = strings.Replace(directory, "$HOME", home, 1)
+	directory, err := util.ExpandHome(directory)
+	if err != nil {
+		return nil, err
 	}
 
 	// Create the directory if it doesn't exist
@@ -54,6 +50,7 @@ func (s *FileStore) SaveCheckpoint(checkpoint *TreeCheckpoint) error {
 
 	// Update the checkpoint timestamp
 	checkpoint.LastUpdated = time.Now()
+	checkpoint.SchemaVersion = CurrentSchemaVersion
 
 	// Serialize the checkpoint to JSON
 	data, err := json.MarshalIndent(checkpoint, "", "  ")
@@ -100,13 +97,13 @@ func (s *FileStore) GetCheckpoint(id string) (*TreeCheckpoint, error) {
 		return nil, errors.Wrap(err, "failed to read checkpoint file")
 	}
 
-	// Deserialize the checkpoint
-	var checkpoint TreeCheckpoint
-	if err := json.Unmarshal(data, &checkpoint); err != nil {
-		return nil, errors.Wrap(err, "failed to deserialize checkpoint")
+	// Deserialize the checkpoint, migrating older schema versions
+	checkpoint, err := DecodeCheckpoint(data)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load checkpoint %s", id)
 	}
 
-	return &checkpoint, nil
+	return checkpoint, nil
 }
 
 // CheckpointExists checks if a checkpoint with the given ID exists
@@ -155,12 +152,12 @@ func (s *FileStore) ListCheckpoints() ([]*TreeCheckpoint, error) {
 			continue // Skip files that can't be read
 		}
 
-		var checkpoint TreeCheckpoint
-		if err := json.Unmarshal(data, &checkpoint); err != nil {
-			continue // Skip files that can't be deserialized
+		checkpoint, err := DecodeCheckpoint(data)
+		if err != nil {
+			continue // Skip files that can't be deserialized or migrated
 		}
 
-		checkpoints = append(checkpoints, &checkpoint)
+		checkpoints = append(checkpoints, checkpoint)
 	}
 
 	return checkpoints, nil
@@ -239,12 +236,12 @@ func (s *FileStore) listCheckpointsUnlocked() ([]*TreeCheckpoint, error) {
 			continue // Skip files that can't be read
 		}
 
-		var checkpoint TreeCheckpoint
-		if err := json.Unmarshal(data, &checkpoint); err != nil {
-			continue // Skip files that can't be deserialized
+		checkpoint, err := DecodeCheckpoint(data)
+		if err != nil {
+			continue // Skip files that can't be deserialized or migrated
 		}
 
-		checkpoints = append(checkpoints, &checkpoint)
+		checkpoints = append(checkpoints, checkpoint)
 	}
 
 	return checkpoints, nil
@@ -1,6 +1,7 @@
 package checkpoint
 
 import (
+	"sort"
 	"time"
 
 	"freightliner/pkg/helper/errors"
@@ -138,36 +139,54 @@ func GetRemainingRepositories(cp *TreeCheckpoint, opts ResumableOptions) ([]stri
 	}
 
 	var remaining []string
+	seen := make(map[string]bool, len(cp.RepoTasks))
 
 	// Check RepoTasks for repository status
 	for _, repoTask := range cp.RepoTasks {
-		// Skip completed repositories if requested
-		if opts.SkipCompleted && repoTask.Status == StatusCompleted {
-			continue
+		seen[repoTask.SourceRepository] = true
+		if shouldResume(repoTask.Status, opts) {
+			remaining = append(remaining, repoTask.SourceRepository)
 		}
+	}
 
-		// Skip failed repositories if not retrying
-		if !opts.RetryFailed && repoTask.Status == StatusFailed {
-			continue
+	// The tree replicator tracks repositories in the Repositories map only
+	var tracked []string
+	for repo, status := range cp.Repositories {
+		if !seen[repo] && shouldResume(status.Status, opts) {
+			tracked = append(tracked, repo)
 		}
+	}
+	sort.Strings(tracked)
 
-		// Include repositories that are not completed or if we don't skip completed
-		// Include failed repositories if we retry failed
-		shouldInclude := false
-
-		if repoTask.Status == StatusCompleted && !opts.SkipCompleted {
-			shouldInclude = true
-		} else if repoTask.Status == StatusFailed && opts.RetryFailed {
-			shouldInclude = true
-		} else if repoTask.Status != StatusCompleted && repoTask.Status != StatusFailed {
-			// Include pending, interrupted, in-progress, etc.
-			shouldInclude = true
-		}
+	return append(remaining, tracked...), nil
+}
 
-		if shouldInclude {
-			remaining = append(remaining, repoTask.SourceRepository)
+// shouldResume reports whether a repository with the given status needs
+// processing again
+func shouldResume(status Status, opts ResumableOptions) bool {
+	switch status {
+	case StatusCompleted:
+		return !opts.SkipCompleted
+	case StatusFailed:
+		return opts.RetryFailed
+	default:
+		// Include pending, interrupted, in-progress, etc.
+		return true
+	}
+}
+
+// GetCompletedTags returns the tags already copied for each source repository
+func GetCompletedTags(cp *TreeCheckpoint) map[string][]string {
+	completed := make(map[string][]string)
+	if cp == nil {
+		return completed
+	}
+
+	for repo, status := range cp.Repositories {
+		if len(status.CompletedTags) > 0 {
+			completed[repo] = append([]string(nil), status.CompletedTags...)
 		}
 	}
 
-	return remaining, nil
+	return completed
 }
@@ -0,0 +1,119 @@
+package checkpoint
+
+import (
+	"encoding/json"
+	"sort"
+
+	"freightliner/pkg/helper/errors"
+)
+
+// CurrentSchemaVersion is the checkpoint schema version written by this build.
+// Bump it and register a migration whenever TreeCheckpoint changes in a way
+// older files can't be decoded into directly.
+const CurrentSchemaVersion = 2
+
+// legacySchemaVersion is assumed for checkpoints written before the
+// schema_version field existed.
+const legacySchemaVersion = 1
+
+// migrations upgrade a raw checkpoint document from the keyed version to the
+// next one. Each migration edits the document in place.
+var migrations = map[int]func(doc map[string]interface{}) error{
+	1: migrateV1ToV2,
+}
+
+// DecodeCheckpoint decodes a checkpoint file of any supported schema version,
+// migrating it to CurrentSchemaVersion. Unknown fields are ignored so files
+// from newer builds of the same version still load.
+func DecodeCheckpoint(data []byte) (*TreeCheckpoint, error) {
+	var doc map[string]interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, errors.Wrap(err, "failed to deserialize checkpoint")
+	}
+	if doc == nil {
+		return nil, errors.InvalidInputf("checkpoint document is empty")
+	}
+
+	version, err := schemaVersion(doc)
+	if err != nil {
+		return nil, err
+	}
+	if version > CurrentSchemaVersion {
+		return nil, errors.NotSupportedf(
+			"checkpoint schema version %d is newer than supported version %d; upgrade freightliner to resume it",
+			version, CurrentSchemaVersion)
+	}
+
+	for v := version; v < CurrentSchemaVersion; v++ {
+		migrate, ok := migrations[v]
+		if !ok {
+			return nil, errors.Internalf("no checkpoint migration from schema version %d", v)
+		}
+		if err := migrate(doc); err != nil {
+			return nil, errors.Wrapf(err, "failed to migrate checkpoint from schema version %d", v)
+		}
+	}
+	doc["schema_version"] = CurrentSchemaVersion
+
+	migrated, err := json.Marshal(doc)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to encode migrated checkpoint")
+	}
+
+	var checkpoint TreeCheckpoint
+	if err := json.Unmarshal(migrated, &checkpoint); err != nil {
+		return nil, errors.Wrap(err, "failed to deserialize checkpoint")
+	}
+	if checkpoint.Repositories == nil {
+		checkpoint.Repositories = make(map[string]RepoStatus)
+	}
+
+	return &checkpoint, nil
+}
+
+// schemaVersion reads the schema version of a raw checkpoint document.
+func schemaVersion(doc map[string]interface{}) (int, error) {
+	raw, ok := doc["schema_version"]
+	if !ok || raw == nil {
+		return legacySchemaVersion, nil
+	}
+
+	number, ok := raw.(float64)
+	if !ok || number != float64(int(number)) || number < legacySchemaVersion {
+		return 0, errors.InvalidInputf("invalid checkpoint schema version: %v", raw)
+	}
+
+	return int(number), nil
+}
+
+// migrateV1ToV2 upgrades checkpoints written before schema versioning. Those
+// could store a null repositories map, which resume writes into, and did not
+// always record completed_repositories, so it is rebuilt from the statuses.
+func migrateV1ToV2(doc map[string]interface{}) error {
+	repositories, _ := doc["repositories"].(map[string]interface{})
+	if repositories == nil {
+		repositories = map[string]interface{}{}
+		doc["repositories"] = repositories
+	}
+
+	if completed, ok := doc["completed_repositories"].([]interface{}); ok && len(completed) > 0 {
+		return nil
+	}
+
+	names := make([]string, 0)
+	for name, raw := range repositories {
+		status, _ := raw.(map[string]interface{})
+		if status != nil && status["status"] == string(StatusCompleted) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	completed := make([]interface{}, len(names))
+	for i, name := range names {
+		completed[i] = name
+	}
+	doc["completed_repositories"] = completed
+
+	return nil
+}
@@ -0,0 +1,112 @@
+package checkpoint
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecodeCheckpoint_LegacyFile(t *testing.T) {
+	legacy := `{
+  "id": "legacy",
+  "source_registry": "ecr",
+  "status": "interrupted",
+  "repositories": {
+    "b": {"status": "completed", "source_repo": "b"},
+    "a": {"status": "completed", "source_repo": "a"},
+    "c": {"status": "failed", "source_repo": "c"}
+  },
+  "completed_repositories": null,
+  "retired_field": true
+}`
+
+	cp, err := DecodeCheckpoint([]byte(legacy))
+	if err != nil {
+		t.Fatalf("Failed to decode legacy checkpoint: %v", err)
+	}
+
+	if cp.SchemaVersion != CurrentSchemaVersion {
+		t.Errorf("Expected schema version %d, got %d", CurrentSchemaVersion, cp.SchemaVersion)
+	}
+	if cp.Status != StatusInterrupted {
+		t.Errorf("Expected status %s, got %s", StatusInterrupted, cp.Status)
+	}
+	if want := []string{"a", "b"}; !reflect.DeepEqual(cp.CompletedRepositories, want) {
+		t.Errorf("Expected completed repositories %v, got %v", want, cp.CompletedRepositories)
+	}
+}
+
+func TestDecodeCheckpoint_NullRepositories(t *testing.T) {
+	cp, err := DecodeCheckpoint([]byte(`{"id": "empty", "repositories": null}`))
+	if err != nil {
+		t.Fatalf("Failed to decode checkpoint: %v", err)
+	}
+
+	if cp.Repositories == nil {
+		t.Fatal("Expected repositories map to be initialized")
+	}
+	cp.Repositories["repo"] = RepoStatus{Status: StatusCompleted}
+}
+
+func TestDecodeCheckpoint_Versions(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr string
+	}{
+		{name: "current", data: `{"id": "x", "schema_version": 2}`},
+		{name: "newer", data: `{"id": "x", "schema_version": 99}`, wantErr: "newer than supported"},
+		{name: "fractional", data: `{"id": "x", "schema_version": 1.5}`, wantErr: "invalid checkpoint schema version"},
+		{name: "string", data: `{"id": "x", "schema_version": "2"}`, wantErr: "invalid checkpoint schema version"},
+		{name: "not an object", data: `[]`, wantErr: "failed to deserialize"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := DecodeCheckpoint([]byte(tt.data))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestFileStore_LoadsLegacyCheckpoint(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatalf("Failed to create file store: %v", err)
+	}
+
+	legacy := `{"id": "old", "status": "interrupted", "repositories": {"r": {"status": "completed"}}}`
+	if err := os.WriteFile(filepath.Join(dir, "old.json"), []byte(legacy), 0600); err != nil {
+		t.Fatalf("Failed to write legacy checkpoint: %v", err)
+	}
+
+	cp, err := store.LoadCheckpoint("old")
+	if err != nil {
+		t.Fatalf("Failed to load legacy checkpoint: %v", err)
+	}
+	if len(cp.CompletedRepositories) != 1 {
+		t.Errorf("Expected 1 completed repository, got %v", cp.CompletedRepositories)
+	}
+
+	if err := store.SaveCheckpoint(cp); err != nil {
+		t.Fatalf("Failed to save checkpoint: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "old.json"))
+	if err != nil {
+		t.Fatalf("Failed to read checkpoint: %v", err)
+	}
+	if !strings.Contains(string(data), `"schema_version": 2`) {
+		t.Errorf("Expected saved checkpoint to carry schema version 2, got %s", data)
+	}
+}
@@ -118,9 +118,15 @@ type RepoStatus struct {
 
 	// Error is the error message if status is failed
 	Error string `json:"error,omitempty"`
+
+	// CompletedTags are the source tags already copied, skipped on resume
+	CompletedTags []string `json:"completed_tags,omitempty"`
 }
 
 type TreeCheckpoint struct {
+	// SchemaVersion is the checkpoint format version, see CurrentSchemaVersion
+	SchemaVersion int `json:"schema_version"`
+
 	// ID is a unique identifier for this replication run
 	ID string `json:"id"`
 
@@ -130,6 +136,10 @@ type TreeCheckpoint struct {
 	// LastUpdated is when the checkpoint was last updated
 	LastUpdated time.Time `json:"last_updated"`
 
+	// RunID identifies the run that last worked on the checkpoint; resuming
+	// it records the resuming run
+	RunID string `json:"run_id,omitempty"`
+
 	// SourceRegistry is the source registry name
 	SourceRegistry string `json:"source_registry"`
 
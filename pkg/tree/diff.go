@@ -0,0 +1,463 @@
+package tree
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+
+	"freightliner/pkg/copy"
+	"freightliner/pkg/helper/errors"
+	"freightliner/pkg/interfaces"
+
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+)
+
+// diffChunkSize is the number of shared tags whose digests are looked up and
+// emitted at a time
+const diffChunkSize = 1000
+
+// DiffStatus classifies a tag in a tree diff
+type DiffStatus string
+
+const (
+	// DiffAdded tags exist only in the source and would be copied
+	DiffAdded DiffStatus = "added"
+	// DiffChanged tags exist on both sides with different digests
+	DiffChanged DiffStatus = "changed"
+	// DiffMissing tags exist only in the destination
+	DiffMissing DiffStatus = "missing"
+	// DiffUnchanged tags have the same digest on both sides
+	DiffUnchanged DiffStatus = "unchanged"
+)
+
+// DiffEntry is one tag of a tree diff
+type DiffEntry struct {
+	SourceRepository string     `json:"source_repository"`
+	DestRepository   string     `json:"dest_repository"`
+	Tag              string     `json:"tag"`
+	Status           DiffStatus `json:"status"`
+	SourceDigest     string     `json:"source_digest,omitempty"`
+	DestDigest       string     `json:"dest_digest,omitempty"`
+}
+
+// RepositoryDiff counts the tag differences of one repository
+type RepositoryDiff struct {
+	SourceRepository string `json:"source_repository"`
+	DestRepository   string `json:"dest_repository"`
+	Added            int    `json:"added"`
+	Changed          int    `json:"changed"`
+	Missing          int    `json:"missing"`
+	Unchanged        int    `json:"unchanged"`
+	Error            string `json:"error,omitempty"`
+}
+
+// DiffSummary totals a tree diff
+type DiffSummary struct {
+	Repositories []RepositoryDiff `json:"repositories"`
+	Added        int              `json:"added"`
+	Changed      int              `json:"changed"`
+	Missing      int              `json:"missing"`
+	Unchanged    int              `json:"unchanged"`
+	Failed       int              `json:"failed"`
+}
+
+// DiffTreeOptions provides options for the DiffTree method
+type DiffTreeOptions struct {
+	// SourceClient is the client for the source registry
+	SourceClient interfaces.RegistryClient
+
+	// DestClient is the client for the destination registry
+	DestClient interfaces.RegistryClient
+
+	// SourcePrefix is the prefix for source repositories
+	SourcePrefix string
+
+	// DestPrefix is the prefix for destination repositories
+	DestPrefix string
+
+	// IncludeUnchanged also emits tags that are the same on both sides
+	IncludeUnchanged bool
+}
+
+// DiffTree compares every filtered source repository with its destination
+// and passes each differing tag to emit as soon as it is known. Repositories
+// are split across the replicator's workers and the digests of shared tags
+// are looked up in chunks, so memory stays bounded by the worker count rather
+// than the size of the tree. emit is never called concurrently; an error from
+// it stops the diff.
+func (t *TreeReplicator) DiffTree(ctx context.Context, opts DiffTreeOptions, emit func(DiffEntry) error) (*DiffSummary, error) {
+	repositories, err := t.listAndFilterRepositories(ctx, opts.SourceClient, opts.SourcePrefix)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(repositories)
+	mapped, err := t.nameMapping.MapAll(repositories, opts.SourcePrefix, opts.DestPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	shards := t.workerCount
+	if shards <= 0 {
+		shards = 1
+	}
+	if shards > len(repositories) {
+		shards = len(repositories)
+	}
+
+	t.logger.WithFields(map[string]interface{}{
+		"repositories": len(repositories),
+		"shards":       shards,
+	}).Info("Diffing repository tree")
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	queue := make(chan string)
+	entries := make(chan DiffEntry, diffChunkSize)
+	repoDiffs := make(chan RepositoryDiff, shards)
+
+	var wg sync.WaitGroup
+	for i := 0; i < shards; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for repo := range queue {
+				repoDiffs <- t.diffRepository(ctx, opts, mapped[repo], entries)
+			}
+		}()
+	}
+
+	go func() {
+		defer close(queue)
+		for _, repo := range repositories {
+			select {
+			case queue <- repo:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	go func() {
+		wg.Wait()
+		close(entries)
+		close(repoDiffs)
+	}()
+
+	summary := &DiffSummary{Repositories: make([]RepositoryDiff, 0, len(repositories))}
+	var emitErr error
+	for entries != nil || repoDiffs != nil {
+		select {
+		case entry, ok := <-entries:
+			if !ok {
+				entries = nil
+				continue
+			}
+			if emitErr == nil {
+				if emitErr = emit(entry); emitErr != nil {
+					cancel()
+				}
+			}
+		case repoDiff, ok := <-repoDiffs:
+			if !ok {
+				repoDiffs = nil
+				continue
+			}
+			summary.add(repoDiff)
+		}
+	}
+
+	sort.Slice(summary.Repositories, func(i, j int) bool {
+		return summary.Repositories[i].SourceRepository < summary.Repositories[j].SourceRepository
+	})
+
+	if emitErr != nil {
+		return summary, errors.Wrap(emitErr, "failed to write diff entry")
+	}
+	if ctx.Err() != nil {
+		return summary, ctx.Err()
+	}
+	return summary, nil
+}
+
+// add counts a repository's differences into the summary
+func (s *DiffSummary) add(repoDiff RepositoryDiff) {
+	s.Repositories = append(s.Repositories, repoDiff)
+	s.Added += repoDiff.Added
+	s.Changed += repoDiff.Changed
+	s.Missing += repoDiff.Missing
+	s.Unchanged += repoDiff.Unchanged
+	if repoDiff.Error != "" {
+		s.Failed++
+	}
+}
+
+// diffRepository diffs one source repository against its destination,
+// sending entries in tag order. Failures are recorded on the result.
+func (t *TreeReplicator) diffRepository(ctx context.Context, opts DiffTreeOptions, repo MappedRepository, entries chan<- DiffEntry) RepositoryDiff {
+	result := RepositoryDiff{SourceRepository: repo.Source, DestRepository: repo.Destination}
+
+	err := t.compareRepository(ctx, opts, &result, repo.Annotations, entries)
+	if err != nil {
+		result.Error = err.Error()
+		t.logger.WithFields(map[string]interface{}{
+			"source_repo": repo.Source,
+			"dest_repo":   repo.Destination,
+			"error":       err.Error(),
+		}).Warn("Failed to diff repository")
+	}
+	return result
+}
+
+// compareRepository walks the sorted source and destination tags of a
+// repository, comparing the digests of tags found on both sides. With
+// annotations, a destination digest matches the source manifest as
+// replication annotates it.
+func (t *TreeReplicator) compareRepository(
+	ctx context.Context,
+	opts DiffTreeOptions,
+	result *RepositoryDiff,
+	annotations map[string]string,
+	entries chan<- DiffEntry,
+) error {
+	sourceRepo, err := opts.SourceClient.GetRepository(ctx, result.SourceRepository)
+	if err != nil {
+		return errors.Wrap(err, "failed to get source repository")
+	}
+	destRepo, err := opts.DestClient.GetRepository(ctx, result.DestRepository)
+	if err != nil {
+		return errors.Wrap(err, "failed to get destination repository")
+	}
+
+	sourceTags, err := sourceRepo.ListTags(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to list source repository tags")
+	}
+	destTags, err := destRepo.ListTags(ctx)
+	if err != nil && !isRepositoryNotFound(err) {
+		return errors.Wrap(err, "failed to list destination repository tags")
+	}
+
+	sourceTags = t.filterTags(result.SourceRepository, sourceTags)
+	destTags = t.filterTags(result.DestRepository, destTags)
+	sort.Strings(sourceTags)
+	sort.Strings(destTags)
+
+	send := func(tag string, status DiffStatus, sourceDigest, destDigest string) error {
+		switch status {
+		case DiffAdded:
+			result.Added++
+		case DiffChanged:
+			result.Changed++
+		case DiffMissing:
+			result.Missing++
+		case DiffUnchanged:
+			result.Unchanged++
+			if !opts.IncludeUnchanged {
+				return nil
+			}
+		}
+		entry := DiffEntry{
+			SourceRepository: result.SourceRepository,
+			DestRepository:   result.DestRepository,
+			Tag:              tag,
+			Status:           status,
+			SourceDigest:     sourceDigest,
+			DestDigest:       destDigest,
+		}
+		select {
+		case entries <- entry:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	// Walk both sorted lists at once, queueing shared tags for digest
+	// comparison and flushing them a chunk at a time
+	var shared []string
+	var sourceLookup, destLookup func([]string) (map[string]string, error)
+	flush := func() error {
+		if len(shared) == 0 {
+			return nil
+		}
+		if sourceLookup == nil {
+			sourceLookup = t.digestLookup(ctx, sourceRepo)
+			destLookup = t.digestLookup(ctx, destRepo)
+		}
+		sourceDigests, err := sourceLookup(shared)
+		if err != nil {
+			return errors.Wrap(err, "failed to look up source digests")
+		}
+		destDigests, err := destLookup(shared)
+		if err != nil {
+			return errors.Wrap(err, "failed to look up destination digests")
+		}
+		for _, tag := range shared {
+			// A digest the registry did not report counts as a change
+			status := DiffUnchanged
+			if sourceDigests[tag] == "" || sourceDigests[tag] != destDigests[tag] {
+				status = DiffChanged
+			}
+			if status == DiffChanged && len(annotations) > 0 && sourceDigests[tag] != "" && destDigests[tag] != "" {
+				expected, err := annotatedDigest(ctx, sourceRepo, tag, annotations)
+				if err != nil {
+					return errors.Wrapf(err, "failed to compute annotated digest of tag %s", tag)
+				}
+				if expected == destDigests[tag] {
+					status = DiffUnchanged
+				}
+			}
+			if err := send(tag, status, sourceDigests[tag], destDigests[tag]); err != nil {
+				return err
+			}
+		}
+		shared = shared[:0]
+		return nil
+	}
+
+	i, j := 0, 0
+	for i < len(sourceTags) || j < len(destTags) {
+		switch {
+		case j == len(destTags) || (i < len(sourceTags) && sourceTags[i] < destTags[j]):
+			err = send(sourceTags[i], DiffAdded, "", "")
+			i++
+		case i == len(sourceTags) || destTags[j] < sourceTags[i]:
+			err = send(destTags[j], DiffMissing, "", "")
+			j++
+		default:
+			shared = append(shared, sourceTags[i])
+			i++
+			j++
+			if len(shared) == diffChunkSize {
+				err = flush()
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return flush()
+}
+
+// digestLookup returns a function that looks up the digests of tags in repo,
+// answered from a single metadata listing when the registry offers one and
+// with parallel manifest HEADs otherwise
+func (t *TreeReplicator) digestLookup(ctx context.Context, repo interfaces.Repository) func(tags []string) (map[string]string, error) {
+	if lister, ok := repo.(interfaces.TagDigestLister); ok {
+		listed, err := lister.ListTagDigests(ctx)
+		if err == nil {
+			all := make(map[string]string, len(listed))
+			for _, td := range listed {
+				all[td.Tag] = td.Digest
+			}
+			return func(tags []string) (map[string]string, error) {
+				digests := make(map[string]string, len(tags))
+				for _, tag := range tags {
+					digests[tag] = all[tag]
+				}
+				return digests, nil
+			}
+		}
+		t.logger.WithFields(map[string]interface{}{
+			"repository": repo.GetRepositoryName(),
+			"error":      err.Error(),
+		}).Debug("Failed to list tag digests, falling back to manifest requests")
+	}
+
+	return func(tags []string) (map[string]string, error) {
+		return t.headDigests(ctx, repo, tags)
+	}
+}
+
+// headDigests looks up the digest of each tag with a manifest HEAD, running
+// as many requests at once as tags are copied
+func (t *TreeReplicator) headDigests(ctx context.Context, repo interfaces.Repository, tags []string) (map[string]string, error) {
+	digests := make(map[string]string, len(tags))
+	opts, err := repo.GetRemoteOptions()
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, remote.WithContext(ctx))
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		firstErr error
+	)
+	semaphore := make(chan struct{}, t.calculateOptimalTagConcurrency(len(tags)))
+	for _, tag := range tags {
+		select {
+		case semaphore <- struct{}{}:
+		case <-ctx.Done():
+			wg.Wait()
+			return nil, ctx.Err()
+		}
+
+		wg.Add(1)
+		go func(tag string) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+
+			ref, err := repo.GetImageReference(tag)
+			if err == nil {
+				desc, headErr := remote.Head(ref, opts...)
+				if headErr == nil {
+					mu.Lock()
+					digests[tag] = desc.Digest.String()
+					mu.Unlock()
+					return
+				}
+				err = headErr
+			}
+
+			mu.Lock()
+			if firstErr == nil {
+				firstErr = errors.Wrapf(err, "failed to look up digest of tag %s", tag)
+			}
+			mu.Unlock()
+		}(tag)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return digests, nil
+}
+
+// annotatedDigest returns the digest of the source manifest of tag once
+// annotations are set on it, as replication pushes it
+func annotatedDigest(ctx context.Context, repo interfaces.Repository, tag string, annotations map[string]string) (string, error) {
+	ref, err := repo.GetImageReference(tag)
+	if err != nil {
+		return "", err
+	}
+	opts, err := repo.GetRemoteOptions()
+	if err != nil {
+		return "", err
+	}
+	desc, err := remote.Get(ref, append(opts, remote.WithContext(ctx))...)
+	if err != nil {
+		return "", err
+	}
+	manifest, err := copy.AnnotateManifest(desc.Manifest, annotations)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("sha256:%x", sha256.Sum256(manifest)), nil
+}
+
+// isRepositoryNotFound reports whether err means the repository does not
+// exist yet, which a diff treats as having no tags
+func isRepositoryNotFound(err error) bool {
+	if errors.Is(err, errors.ErrNotFound) {
+		return true
+	}
+	var terr *transport.Error
+	return errors.As(err, &terr) && terr.StatusCode == http.StatusNotFound
+}
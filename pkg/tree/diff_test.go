@@ -0,0 +1,167 @@
+package tree
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"freightliner/pkg/helper/log"
+	"freightliner/pkg/interfaces"
+)
+
+// digestListingClient serves repositories that list their tag digests, with
+// each tag's manifest content standing in for its digest
+type digestListingClient struct {
+	*MockRegistryClient
+}
+
+func (c *digestListingClient) GetRepository(ctx context.Context, name string) (interfaces.Repository, error) {
+	repo, err := c.MockRegistryClient.GetRepository(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return &digestListingRepository{MockRepository: repo.(*MockRepository)}, nil
+}
+
+type digestListingRepository struct {
+	*MockRepository
+}
+
+func (r *digestListingRepository) ListTagDigests(_ context.Context) ([]interfaces.TagDigest, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	digests := make([]interfaces.TagDigest, 0, len(r.Tags))
+	for tag, content := range r.Tags {
+		digests = append(digests, interfaces.TagDigest{Tag: tag, Digest: "sha256:" + string(content)})
+	}
+	return digests, nil
+}
+
+func newDiffTestClient(name string, repos map[string]map[string]string) *digestListingClient {
+	client := &MockRegistryClient{RegistryName: name, Repositories: make(map[string]*MockRepository)}
+	for repo, tags := range repos {
+		mock := &MockRepository{Name: repo, Tags: make(map[string][]byte)}
+		for tag, digest := range tags {
+			mock.Tags[tag] = []byte(digest)
+		}
+		client.Repositories[repo] = mock
+	}
+	return &digestListingClient{MockRegistryClient: client}
+}
+
+func TestDiffTree(t *testing.T) {
+	source := newDiffTestClient("source", map[string]map[string]string{
+		"team/app": {"v1": "a", "v2": "b", "v3": "c", "dev-1": "x"},
+		"team/api": {"v1": "d"},
+		"team/new": {"v1": "e"},
+	})
+	dest := newDiffTestClient("dest", map[string]map[string]string{
+		"mirror/app": {"v1": "a", "v2": "changed", "old": "f"},
+		"mirror/api": {"v1": "d"},
+	})
+
+	replicator := NewTreeReplicator(log.NewBasicLogger(log.ErrorLevel), nil, TreeReplicatorOptions{
+		WorkerCount: 2,
+		ExcludeTags: []string{"dev-*"},
+	})
+
+	var entries []DiffEntry
+	summary, err := replicator.DiffTree(context.Background(), DiffTreeOptions{
+		SourceClient: source,
+		DestClient:   dest,
+		SourcePrefix: "team",
+		DestPrefix:   "mirror",
+	}, func(entry DiffEntry) error {
+		entries = append(entries, entry)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("DiffTree() error = %v", err)
+	}
+
+	got := make(map[string]DiffStatus)
+	for _, entry := range entries {
+		got[entry.DestRepository+":"+entry.Tag] = entry.Status
+	}
+	want := map[string]DiffStatus{
+		"mirror/app:v2":  DiffChanged,
+		"mirror/app:v3":  DiffAdded,
+		"mirror/app:old": DiffMissing,
+		"mirror/new:v1":  DiffAdded,
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected entries %v, got %v", want, got)
+	}
+	for key, status := range want {
+		if got[key] != status {
+			t.Errorf("expected %s to be %s, got %q", key, status, got[key])
+		}
+	}
+
+	if summary.Added != 2 || summary.Changed != 1 || summary.Missing != 1 || summary.Unchanged != 2 || summary.Failed != 0 {
+		t.Errorf("unexpected totals: %+v", summary)
+	}
+	if len(summary.Repositories) != 3 || summary.Repositories[0].SourceRepository != "team/api" {
+		t.Fatalf("expected three repositories in order, got %+v", summary.Repositories)
+	}
+	app := summary.Repositories[1]
+	if app.Added != 1 || app.Changed != 1 || app.Missing != 1 || app.Unchanged != 1 {
+		t.Errorf("unexpected counts for team/app: %+v", app)
+	}
+}
+
+func TestDiffTreeLargeRepository(t *testing.T) {
+	// More shared tags than fit in one chunk
+	sourceTags := make(map[string]string)
+	destTags := make(map[string]string)
+	for i := 0; i < diffChunkSize*2+10; i++ {
+		tag := fmt.Sprintf("v%05d", i)
+		sourceTags[tag] = tag
+		destTags[tag] = tag
+	}
+	destTags["v00007"] = "stale"
+
+	source := newDiffTestClient("source", map[string]map[string]string{"app": sourceTags})
+	dest := newDiffTestClient("dest", map[string]map[string]string{"app": destTags})
+	replicator := NewTreeReplicator(log.NewBasicLogger(log.ErrorLevel), nil, TreeReplicatorOptions{WorkerCount: 4})
+
+	var changed []string
+	summary, err := replicator.DiffTree(context.Background(), DiffTreeOptions{SourceClient: source, DestClient: dest}, func(entry DiffEntry) error {
+		changed = append(changed, entry.Tag)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("DiffTree() error = %v", err)
+	}
+	if len(changed) != 1 || changed[0] != "v00007" {
+		t.Errorf("expected only v00007 to differ, got %v", changed)
+	}
+	if summary.Unchanged != diffChunkSize*2+9 {
+		t.Errorf("expected %d unchanged tags, got %d", diffChunkSize*2+9, summary.Unchanged)
+	}
+}
+
+func TestDiffTreeStopsOnEmitError(t *testing.T) {
+	repos := make(map[string]map[string]string)
+	for i := 0; i < 20; i++ {
+		repos[fmt.Sprintf("app-%02d", i)] = map[string]string{"v1": "a", "v2": "b"}
+	}
+	source := newDiffTestClient("source", repos)
+	dest := newDiffTestClient("dest", nil)
+	replicator := NewTreeReplicator(log.NewBasicLogger(log.ErrorLevel), nil, TreeReplicatorOptions{WorkerCount: 3})
+
+	writeErr := errors.New("disk full")
+	calls := 0
+	_, err := replicator.DiffTree(context.Background(), DiffTreeOptions{SourceClient: source, DestClient: dest}, func(DiffEntry) error {
+		calls++
+		return writeErr
+	})
+	if !errors.Is(err, writeErr) {
+		t.Fatalf("expected the emit error, got %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("expected emit to stop after the first error, got %d calls", calls)
+	}
+}
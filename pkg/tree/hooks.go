@@ -0,0 +1,73 @@
+package tree
+
+import "time"
+
+// RepoStartEvent is passed to OnRepoStart when a repository's replication begins
+type RepoStartEvent struct {
+	SourceRepository string
+	DestRepository   string
+	DryRun           bool
+}
+
+// RepoDoneEvent is passed to OnRepoDone when a repository's replication ends
+type RepoDoneEvent struct {
+	SourceRepository string
+	DestRepository   string
+	DryRun           bool
+	Duration         time.Duration
+	// Err is nil when the repository replicated, including when some of
+	// its tags failed; those are reported to OnTagDone
+	Err error
+}
+
+// TagDoneEvent is passed to OnTagDone when a tag's copy ends
+type TagDoneEvent struct {
+	SourceRepository string
+	DestRepository   string
+	Tag              string
+	DryRun           bool
+	Duration         time.Duration
+	// Err is nil when the tag was copied
+	Err error
+}
+
+// notifyRepoStart calls the OnRepoStart hook, if any
+func (t *TreeReplicator) notifyRepoStart(opts repositoryProcessOptions) {
+	if t.onRepoStart == nil {
+		return
+	}
+	t.onRepoStart(RepoStartEvent{
+		SourceRepository: opts.SourceRepo,
+		DestRepository:   opts.DestRepo,
+		DryRun:           t.dryRun,
+	})
+}
+
+// notifyRepoDone calls the OnRepoDone hook, if any
+func (t *TreeReplicator) notifyRepoDone(opts repositoryProcessOptions, started time.Time, err error) {
+	if t.onRepoDone == nil {
+		return
+	}
+	t.onRepoDone(RepoDoneEvent{
+		SourceRepository: opts.SourceRepo,
+		DestRepository:   opts.DestRepo,
+		DryRun:           t.dryRun,
+		Duration:         time.Since(started),
+		Err:              err,
+	})
+}
+
+// notifyTagDone calls the OnTagDone hook, if any
+func (t *TreeReplicator) notifyTagDone(opts repositoryProcessOptions, tag string, started time.Time, err error) {
+	if t.onTagDone == nil {
+		return
+	}
+	t.onTagDone(TagDoneEvent{
+		SourceRepository: opts.SourceRepo,
+		DestRepository:   opts.DestRepo,
+		Tag:              tag,
+		DryRun:           t.dryRun,
+		Duration:         time.Since(started),
+		Err:              err,
+	})
+}
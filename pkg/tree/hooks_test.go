@@ -0,0 +1,120 @@
+package tree
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"testing"
+
+	"freightliner/pkg/copy"
+	"freightliner/pkg/helper/log"
+	"freightliner/pkg/interfaces"
+)
+
+func TestReplicateTreeHooks(t *testing.T) {
+	source := newDiffTestClient("source", map[string]map[string]string{
+		"team/app": {"v1": "a", "v2": "b", "dev-1": "x"},
+		"team/api": {"v1": "c"},
+	})
+	dest := newDiffTestClient("dest", nil)
+
+	var (
+		mu      sync.Mutex
+		started []string
+		done    []string
+		tags    []string
+	)
+	logger := log.NewBasicLogger(log.ErrorLevel)
+	replicator := NewTreeReplicator(logger, copy.NewCopier(logger, copy.CopierOptions{}), TreeReplicatorOptions{
+		WorkerCount: 2,
+		ExcludeTags: []string{"dev-*"},
+		DryRun:      true,
+		OnRepoStart: func(event RepoStartEvent) {
+			mu.Lock()
+			defer mu.Unlock()
+			started = append(started, event.SourceRepository)
+		},
+		OnRepoDone: func(event RepoDoneEvent) {
+			mu.Lock()
+			defer mu.Unlock()
+			done = append(done, event.DestRepository)
+		},
+		OnTagDone: func(event TagDoneEvent) {
+			mu.Lock()
+			defer mu.Unlock()
+			if !event.DryRun {
+				t.Errorf("expected a dry run event for %s", event.Tag)
+			}
+			tags = append(tags, event.DestRepository+":"+event.Tag)
+		},
+	})
+
+	_, err := replicator.ReplicateTree(context.Background(), ReplicateTreeOptions{
+		SourceClient: source,
+		DestClient:   dest,
+		SourcePrefix: "team",
+		DestPrefix:   "mirror",
+	})
+	if err != nil {
+		t.Fatalf("ReplicateTree() error = %v", err)
+	}
+
+	sort.Strings(started)
+	sort.Strings(done)
+	sort.Strings(tags)
+	if len(started) != 2 || started[0] != "team/api" || started[1] != "team/app" {
+		t.Errorf("unexpected repository starts: %v", started)
+	}
+	if len(done) != 2 || done[0] != "mirror/api" || done[1] != "mirror/app" {
+		t.Errorf("unexpected repository completions: %v", done)
+	}
+	want := []string{"mirror/api:v1", "mirror/app:v1", "mirror/app:v2"}
+	if len(tags) != len(want) {
+		t.Fatalf("expected tag events %v, got %v", want, tags)
+	}
+	for i := range want {
+		if tags[i] != want[i] {
+			t.Errorf("expected tag event %s, got %s", want[i], tags[i])
+		}
+	}
+}
+
+func TestReplicateTreeEnsuresDestinationRepositories(t *testing.T) {
+	source := newDiffTestClient("source", map[string]map[string]string{
+		"team/app": {"v1": "a"},
+		"team/api": {"v1": "c"},
+	})
+	dest := newDiffTestClient("dest", nil)
+
+	var (
+		mu      sync.Mutex
+		ensured []string
+	)
+	logger := log.NewBasicLogger(log.ErrorLevel)
+	replicator := NewTreeReplicator(logger, copy.NewCopier(logger, copy.CopierOptions{}), TreeReplicatorOptions{
+		WorkerCount: 2,
+		DryRun:      true,
+		EnsureDestinationRepository: func(ctx context.Context, destClient interfaces.RegistryClient, destRepo, source string) (interfaces.Repository, error) {
+			mu.Lock()
+			ensured = append(ensured, source+" -> "+destRepo)
+			mu.Unlock()
+			return destClient.GetRepository(ctx, destRepo)
+		},
+	})
+
+	_, err := replicator.ReplicateTree(context.Background(), ReplicateTreeOptions{
+		SourceClient: source,
+		DestClient:   dest,
+		SourcePrefix: "team",
+		DestPrefix:   "mirror",
+	})
+	if err != nil {
+		t.Fatalf("ReplicateTree() error = %v", err)
+	}
+
+	sort.Strings(ensured)
+	want := []string{"source/team/api -> mirror/api", "source/team/app -> mirror/app"}
+	if len(ensured) != len(want) || ensured[0] != want[0] || ensured[1] != want[1] {
+		t.Errorf("expected destination repositories %v to be ensured, got %v", want, ensured)
+	}
+}
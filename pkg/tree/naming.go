@@ -0,0 +1,214 @@
+package tree
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	"freightliner/pkg/helper/errors"
+)
+
+// Name mapping strategies for destination repository names
+const (
+	// NameMappingPreserve keeps the source path, replacing the source prefix
+	// with the destination prefix
+	NameMappingPreserve = "preserve"
+	// NameMappingFlatten joins the trailing path components with the
+	// separator until the name fits MaxDepth
+	NameMappingFlatten = "flatten"
+	// NameMappingHash flattens to MaxDepth if set, then shortens names longer than MaxLength to
+	// a readable stem and a hash of the source repository
+	NameMappingHash = "hash"
+	// NameMappingTruncate flattens to MaxDepth if set, then cuts names longer than MaxLength and
+	// records the source repository in the manifests pushed to them
+	NameMappingTruncate = "truncate"
+)
+
+// SourceRepositoryAnnotation is the manifest annotation holding the source
+// repository of an image pushed to a truncated repository name
+const SourceRepositoryAnnotation = "vnd.freightliner.source-repository"
+
+// hashLength is the number of hex digits of the source repository hash
+// appended to shortened names
+const hashLength = 8
+
+// nameSeparators are the separators the OCI distribution spec allows
+// between the words of a path component
+var nameSeparators = map[string]bool{"-": true, "--": true, "_": true, "__": true, ".": true}
+
+// NameMapping maps source repositories to destination names for registries
+// with different limits on repository path depth and length. The zero value
+// preserves source paths.
+type NameMapping struct {
+	// Strategy is preserve (default), flatten, hash or truncate
+	Strategy string
+
+	// MaxDepth is the most path components a destination name may have,
+	// prefix included; the path below the prefix keeps at least one. Zero
+	// means no limit. Required by flatten.
+	MaxDepth int
+
+	// MaxLength is the most characters a destination name may have, prefix
+	// included. Required by hash and truncate; flatten fails on longer names.
+	MaxLength int
+
+	// Separator joins flattened path components (default "-")
+	Separator string
+}
+
+// MappedRepository is the destination of one source repository
+type MappedRepository struct {
+	Source      string
+	Destination string
+
+	// Annotations are set on every manifest pushed to Destination
+	Annotations map[string]string
+}
+
+// Validate checks the strategy and its limits
+func (m NameMapping) Validate() error {
+	switch m.Strategy {
+	case "", NameMappingPreserve:
+	case NameMappingFlatten:
+		if m.MaxDepth <= 0 {
+			return errors.InvalidInputf("name mapping flatten requires a maximum path depth")
+		}
+	case NameMappingHash, NameMappingTruncate:
+		if m.MaxLength <= 0 {
+			return errors.InvalidInputf("name mapping %s requires a maximum name length", m.Strategy)
+		}
+	default:
+		return errors.InvalidInputf("unknown name mapping %q, expected preserve, flatten, hash or truncate", m.Strategy)
+	}
+	if m.MaxDepth < 0 {
+		return errors.InvalidInputf("name mapping maximum depth must not be negative")
+	}
+	if m.MaxLength < 0 {
+		return errors.InvalidInputf("name mapping maximum length must not be negative")
+	}
+	if m.Separator != "" && !nameSeparators[m.Separator] {
+		return errors.InvalidInputf("invalid name mapping separator %q, expected -, --, _, __ or .", m.Separator)
+	}
+	return nil
+}
+
+// Map returns the destination of repo, replacing sourcePrefix with
+// destPrefix and applying the strategy to the path below destPrefix
+func (m NameMapping) Map(repo, sourcePrefix, destPrefix string) (MappedRepository, error) {
+	mapped := MappedRepository{Source: repo, Destination: strings.Replace(repo, sourcePrefix, destPrefix, 1)}
+	if m.Strategy == "" || m.Strategy == NameMappingPreserve {
+		return mapped, nil
+	}
+	if err := m.Validate(); err != nil {
+		return mapped, err
+	}
+
+	// Only the path below the destination prefix is rewritten
+	prefix := strings.Trim(destPrefix, "/")
+	path := mapped.Destination
+	if prefix != "" && strings.HasPrefix(path, prefix+"/") {
+		path = strings.TrimPrefix(path, prefix+"/")
+	} else {
+		prefix = ""
+	}
+	join := func(path string) string {
+		if prefix == "" {
+			return path
+		}
+		return prefix + "/" + path
+	}
+
+	path = m.flatten(path, prefix)
+	mapped.Destination = join(path)
+	if m.MaxLength == 0 || len(mapped.Destination) <= m.MaxLength {
+		return mapped, nil
+	}
+
+	// Shorten the last component, which holds the flattened tail
+	budget := m.MaxLength - (len(mapped.Destination) - len(lastComponent(path)))
+	stem := path[:len(path)-len(lastComponent(path))]
+	last := lastComponent(path)
+
+	switch m.Strategy {
+	case NameMappingHash:
+		sum := sha256.Sum256([]byte(repo))
+		suffix := "-" + hex.EncodeToString(sum[:])[:hashLength]
+		keep := budget - len(suffix)
+		if keep < 1 {
+			return mapped, errors.InvalidInputf("repository %s does not fit in %d characters under %q", repo, m.MaxLength, destPrefix)
+		}
+		short := trimSeparators(last[:keep])
+		if short == "" {
+			suffix = suffix[1:]
+		}
+		mapped.Destination = join(stem + short + suffix)
+
+	case NameMappingTruncate:
+		if budget < 1 {
+			return mapped, errors.InvalidInputf("repository %s does not fit in %d characters under %q", repo, m.MaxLength, destPrefix)
+		}
+		mapped.Destination = join(stem + trimSeparators(last[:budget]))
+		mapped.Annotations = map[string]string{SourceRepositoryAnnotation: repo}
+
+	default:
+		return mapped, errors.InvalidInputf("repository %s maps to %s, longer than %d characters", repo, mapped.Destination, m.MaxLength)
+	}
+	return mapped, nil
+}
+
+// MapAll maps every repository, failing when two of them would be
+// replicated to the same destination
+func (m NameMapping) MapAll(repositories []string, sourcePrefix, destPrefix string) (map[string]MappedRepository, error) {
+	mapped := make(map[string]MappedRepository, len(repositories))
+	sources := make(map[string]string, len(repositories))
+	for _, repo := range repositories {
+		dest, err := m.Map(repo, sourcePrefix, destPrefix)
+		if err != nil {
+			return nil, err
+		}
+		if other, ok := sources[dest.Destination]; ok && other != repo {
+			return nil, errors.InvalidInputf("repositories %s and %s both map to %s", other, repo, dest.Destination)
+		}
+		sources[dest.Destination] = repo
+		mapped[repo] = dest
+	}
+	return mapped, nil
+}
+
+// flatten joins the trailing components of path with the separator so that
+// path under prefix has at most MaxDepth components, keeping at least one
+func (m NameMapping) flatten(path, prefix string) string {
+	if m.MaxDepth <= 0 {
+		return path
+	}
+	separator := m.Separator
+	if separator == "" {
+		separator = "-"
+	}
+
+	allowed := m.MaxDepth
+	if prefix != "" {
+		allowed -= strings.Count(prefix, "/") + 1
+	}
+	if allowed < 1 {
+		allowed = 1
+	}
+
+	components := strings.Split(path, "/")
+	if len(components) <= allowed {
+		return path
+	}
+	kept := components[:allowed-1]
+	return strings.Join(append(kept, strings.Join(components[allowed-1:], separator)), "/")
+}
+
+// lastComponent returns the part of path after its last slash
+func lastComponent(path string) string {
+	return path[strings.LastIndex(path, "/")+1:]
+}
+
+// trimSeparators drops the separators a cut left at the end of a component,
+// which a repository name may not end with
+func trimSeparators(component string) string {
+	return strings.TrimRight(component, "-_.")
+}
@@ -0,0 +1,150 @@
+package tree
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"freightliner/pkg/helper/log"
+)
+
+func TestNameMappingMap(t *testing.T) {
+	tests := []struct {
+		name       string
+		mapping    NameMapping
+		repo       string
+		sourcePref string
+		destPref   string
+		want       string
+		annotated  bool
+	}{
+		{"zero value preserves", NameMapping{}, "team/svc/api", "team", "mirror", "mirror/svc/api", false},
+		{"preserve", NameMapping{Strategy: NameMappingPreserve, MaxDepth: 1}, "team/svc/api", "team", "mirror", "mirror/svc/api", false},
+		{"flatten under prefix", NameMapping{Strategy: NameMappingFlatten, MaxDepth: 2}, "team/svc/component/api", "team", "mirror", "mirror/svc-component-api", false},
+		{"flatten keeps leading components", NameMapping{Strategy: NameMappingFlatten, MaxDepth: 3}, "team/svc/component/api", "", "", "team/svc/component-api", false},
+		{"flatten with separator", NameMapping{Strategy: NameMappingFlatten, MaxDepth: 2, Separator: "__"}, "team/svc/api", "team", "mirror", "mirror/svc__api", false},
+		{"flatten keeps one component under deep prefix", NameMapping{Strategy: NameMappingFlatten, MaxDepth: 1}, "team/svc/api", "team", "org/mirror", "org/mirror/svc-api", false},
+		{"flatten leaves shallow names", NameMapping{Strategy: NameMappingFlatten, MaxDepth: 3}, "team/api", "team", "mirror", "mirror/api", false},
+		{"hash leaves short names", NameMapping{Strategy: NameMappingHash, MaxLength: 64}, "team/svc/api", "team", "mirror", "mirror/svc/api", false},
+		{"truncate leaves short names", NameMapping{Strategy: NameMappingTruncate, MaxLength: 64}, "team/svc/api", "team", "mirror", "mirror/svc/api", false},
+		{"truncate cuts long names", NameMapping{Strategy: NameMappingTruncate, MaxDepth: 2, MaxLength: 24}, "team/platform/observability-collector", "team", "mirror", "mirror/platform-observab", true},
+		{"truncate trims separators", NameMapping{Strategy: NameMappingTruncate, MaxDepth: 2, MaxLength: 16}, "team/platform/api", "team", "mirror", "mirror/platform", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.mapping.Map(tt.repo, tt.sourcePref, tt.destPref)
+			if err != nil {
+				t.Fatalf("Map() error = %v", err)
+			}
+			if got.Destination != tt.want {
+				t.Errorf("expected %s, got %s", tt.want, got.Destination)
+			}
+			if annotated := got.Annotations[SourceRepositoryAnnotation] == tt.repo; annotated != tt.annotated {
+				t.Errorf("expected annotated %v, got annotations %v", tt.annotated, got.Annotations)
+			}
+		})
+	}
+}
+
+func TestNameMappingHash(t *testing.T) {
+	mapping := NameMapping{Strategy: NameMappingHash, MaxDepth: 2, MaxLength: 30}
+
+	a, err := mapping.Map("team/platform/observability-collector-a", "team", "mirror")
+	if err != nil {
+		t.Fatalf("Map() error = %v", err)
+	}
+	b, err := mapping.Map("team/platform/observability-collector-b", "team", "mirror")
+	if err != nil {
+		t.Fatalf("Map() error = %v", err)
+	}
+	for _, mapped := range []MappedRepository{a, b} {
+		if len(mapped.Destination) > 30 || !strings.HasPrefix(mapped.Destination, "mirror/platform-obser-") {
+			t.Errorf("expected a hashed name of at most 30 characters, got %s", mapped.Destination)
+		}
+		if mapped.Annotations != nil {
+			t.Errorf("hashed names are not annotated, got %v", mapped.Annotations)
+		}
+	}
+	if a.Destination == b.Destination {
+		t.Errorf("expected distinct hashed names, both are %s", a.Destination)
+	}
+
+	again, _ := mapping.Map("team/platform/observability-collector-a", "team", "mirror")
+	if again.Destination != a.Destination {
+		t.Errorf("expected the same name on every run, got %s and %s", a.Destination, again.Destination)
+	}
+
+	if _, err := mapping.Map("team/api", "team", "a-very-long-destination-prefix"); err == nil {
+		t.Error("expected an error when the prefix leaves no room for the name")
+	}
+}
+
+func TestNameMappingValidate(t *testing.T) {
+	invalid := []NameMapping{
+		{Strategy: "squash"},
+		{Strategy: NameMappingFlatten},
+		{Strategy: NameMappingHash},
+		{Strategy: NameMappingTruncate, MaxDepth: 2},
+		{Strategy: NameMappingFlatten, MaxDepth: 2, Separator: "/"},
+		{Strategy: NameMappingFlatten, MaxDepth: 2, MaxLength: -1},
+	}
+	for _, mapping := range invalid {
+		if err := mapping.Validate(); err == nil {
+			t.Errorf("expected %+v to be invalid", mapping)
+		}
+	}
+	if err := (NameMapping{Strategy: NameMappingFlatten, MaxDepth: 2, Separator: "."}).Validate(); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+}
+
+func TestNameMappingMapAllDetectsCollisions(t *testing.T) {
+	mapping := NameMapping{Strategy: NameMappingFlatten, MaxDepth: 2}
+
+	_, err := mapping.MapAll([]string{"team/svc/api", "team/svc-api"}, "team", "mirror")
+	if err == nil || !strings.Contains(err.Error(), "both map to mirror/svc-api") {
+		t.Fatalf("expected a collision error, got %v", err)
+	}
+
+	mapped, err := mapping.MapAll([]string{"team/svc/api", "team/svc/web"}, "team", "mirror")
+	if err != nil {
+		t.Fatalf("MapAll() error = %v", err)
+	}
+	if mapped["team/svc/web"].Destination != "mirror/svc-web" {
+		t.Errorf("unexpected mapping %+v", mapped)
+	}
+}
+
+func TestDiffTreeUsesNameMapping(t *testing.T) {
+	source := newDiffTestClient("source", map[string]map[string]string{
+		"team/svc/api": {"v1": "a", "v2": "b"},
+	})
+	dest := newDiffTestClient("dest", map[string]map[string]string{
+		"mirror/svc-api": {"v1": "a"},
+	})
+
+	replicator := NewTreeReplicator(log.NewBasicLogger(log.ErrorLevel), nil, TreeReplicatorOptions{
+		WorkerCount: 1,
+		NameMapping: NameMapping{Strategy: NameMappingFlatten, MaxDepth: 2},
+	})
+
+	var entries []DiffEntry
+	summary, err := replicator.DiffTree(context.Background(), DiffTreeOptions{
+		SourceClient: source,
+		DestClient:   dest,
+		SourcePrefix: "team",
+		DestPrefix:   "mirror",
+	}, func(entry DiffEntry) error {
+		entries = append(entries, entry)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("DiffTree() error = %v", err)
+	}
+	if len(entries) != 1 || entries[0].DestRepository != "mirror/svc-api" || entries[0].Tag != "v2" || entries[0].Status != DiffAdded {
+		t.Fatalf("expected v2 to be added to mirror/svc-api, got %+v", entries)
+	}
+	if summary.Unchanged != 1 {
+		t.Errorf("expected v1 to be unchanged, got %+v", summary)
+	}
+}
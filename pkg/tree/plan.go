@@ -0,0 +1,307 @@
+package tree
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"freightliner/pkg/copy"
+	"freightliner/pkg/helper/errors"
+	"freightliner/pkg/interfaces"
+)
+
+// PlanTreeOptions provides options for the PlanTree method
+type PlanTreeOptions struct {
+	// SourceClient is the client for the source registry
+	SourceClient interfaces.RegistryClient
+
+	// DestClient is the client for the destination registry
+	DestClient interfaces.RegistryClient
+
+	// SourcePrefix is the prefix for source repositories
+	SourcePrefix string
+
+	// DestPrefix is the prefix for destination repositories
+	DestPrefix string
+
+	// ForceOverwrite plans tags whose destination digest differs as copies
+	// rather than skips
+	ForceOverwrite bool
+
+	// CompareDigests looks up the digests of tags found on both sides so
+	// existing and unchanged tags are planned as skips. Without it every
+	// filtered source tag is planned as a copy.
+	CompareDigests bool
+}
+
+// ExecutePlanOptions provides options for the ExecutePlan method
+type ExecutePlanOptions struct {
+	// SourceClient and DestClient supply the remote options of items planned
+	// without them, e.g. plans read back from JSON
+	SourceClient interfaces.RegistryClient
+	DestClient   interfaces.RegistryClient
+
+	// ForceOverwrite overwrites destinations that appeared after planning
+	ForceOverwrite bool
+}
+
+// PlanTree lists and filters the tree like ReplicateTree, returning what it
+// would copy without copying anything. Callers can filter the plan, e.g.
+// to the items approved for promotion, and pass it to ExecutePlan.
+func (t *TreeReplicator) PlanTree(ctx context.Context, opts PlanTreeOptions) (*copy.Plan, error) {
+	plan := &copy.Plan{}
+	repos := newRepositoryCache(opts.SourceClient, opts.DestClient)
+
+	if opts.CompareDigests {
+		diffOpts := DiffTreeOptions{
+			SourceClient:     opts.SourceClient,
+			DestClient:       opts.DestClient,
+			SourcePrefix:     opts.SourcePrefix,
+			DestPrefix:       opts.DestPrefix,
+			IncludeUnchanged: true,
+		}
+		summary, err := t.DiffTree(ctx, diffOpts, func(entry DiffEntry) error {
+			if entry.Status == DiffMissing {
+				return nil
+			}
+			item, err := repos.planItem(ctx, entry.SourceRepository, entry.DestRepository, entry.Tag)
+			if err != nil {
+				return err
+			}
+			if mapped, err := t.nameMapping.Map(entry.SourceRepository, opts.SourcePrefix, opts.DestPrefix); err == nil {
+				item.Annotations = mapped.Annotations
+			}
+			item.SourceDigest = entry.SourceDigest
+			item.DestDigest = entry.DestDigest
+			switch {
+			case entry.Status == DiffUnchanged:
+				item.Action = copy.PlanSkip
+				item.Reason = "destination has the source digest"
+			case entry.Status == DiffChanged && !opts.ForceOverwrite:
+				item.Action = copy.PlanSkip
+				item.Reason = "destination exists"
+			}
+			plan.Add(item)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		if summary.Failed > 0 {
+			return nil, errors.InvalidInputf("failed to plan %d of %d repositories", summary.Failed, len(summary.Repositories))
+		}
+		sortPlan(plan)
+		return plan, nil
+	}
+
+	repositories, err := t.listAndFilterRepositories(ctx, opts.SourceClient, opts.SourcePrefix)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(repositories)
+	mapped, err := t.nameMapping.MapAll(repositories, opts.SourcePrefix, opts.DestPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, repo := range repositories {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		destRepo := mapped[repo].Destination
+
+		sourceRepo, err := repos.source(ctx, repo)
+		if err != nil {
+			return nil, err
+		}
+		tags, err := sourceRepo.ListTags(ctx)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to list tags of %s", repo)
+		}
+		tags = t.filterTags(repo, tags)
+		sort.Strings(tags)
+		for _, tag := range tags {
+			item, err := repos.planItem(ctx, repo, destRepo, tag)
+			if err != nil {
+				return nil, err
+			}
+			item.Annotations = mapped[repo].Annotations
+			plan.Add(item)
+		}
+	}
+
+	t.logger.WithFields(map[string]interface{}{
+		"repositories": len(repositories),
+		"items":        len(plan.Items),
+	}).Info("Planned repository tree")
+	return plan, nil
+}
+
+// ExecutePlan copies the plan's copy items with the replicator's copier,
+// sharing layer deduplication across the plan and running as many copies at
+// once as MaxTransfers, or WorkerCount when unlimited, allows
+func (t *TreeReplicator) ExecutePlan(ctx context.Context, plan *copy.Plan, opts ExecutePlanOptions) (*TreeReplicationResult, error) {
+	result := &TreeReplicationResult{StartTime: time.Now()}
+
+	// Fill in the remote options of items planned elsewhere
+	if opts.SourceClient != nil && opts.DestClient != nil {
+		repos := newRepositoryCache(opts.SourceClient, opts.DestClient)
+		filled := &copy.Plan{Items: make([]copy.PlanItem, len(plan.Items))}
+		for i, item := range plan.Items {
+			if item.SourceOptions == nil && item.DestOptions == nil && item.SourceRepository != "" {
+				planned, err := repos.planItem(ctx, item.SourceRepository, item.DestRepository, item.Tag)
+				if err != nil {
+					return result, err
+				}
+				item.SourceOptions = planned.SourceOptions
+				item.DestOptions = planned.DestOptions
+			}
+			filled.Items[i] = item
+		}
+		plan = filled
+	}
+
+	concurrency := t.workerCount
+	if t.transferSlots != nil {
+		concurrency = cap(t.transferSlots)
+	}
+
+	copierOpts := copy.CopierOptions{}
+	if t.copier != nil {
+		copierOpts = t.copier.Options()
+	}
+	dedup := copy.NewBlobDedup()
+	copierOpts.Dedup = dedup
+	copier := copy.NewCopier(t.logger, copierOpts)
+
+	t.logger.WithFields(map[string]interface{}{
+		"items":       len(plan.Items),
+		"copies":      len(plan.Copies()),
+		"concurrency": concurrency,
+		"dry_run":     t.dryRun,
+	}).Info("Executing replication plan")
+
+	planResult := copier.ExecutePlan(ctx, plan, copy.ExecuteOptions{
+		CopyOptions: copy.CopyOptions{
+			DryRun:         t.dryRun,
+			ForceOverwrite: opts.ForceOverwrite,
+		},
+		Concurrency: concurrency,
+	})
+
+	repositories := make(map[string]struct{})
+	for _, itemResult := range planResult.Items {
+		repositories[itemResult.Item.SourceRepository] = struct{}{}
+		if itemResult.Err != nil {
+			t.logger.WithFields(map[string]interface{}{
+				"source":      itemResult.Item.Source,
+				"destination": itemResult.Item.Destination,
+			}).Error("Failed to replicate planned image", itemResult.Err)
+		}
+	}
+	result.Repositories = len(repositories)
+	result.ImagesReplicated.Store(int64(planResult.Copied))
+	result.ImagesSkipped.Store(int64(planResult.Skipped))
+	result.ImagesFailed.Store(int64(planResult.Failed))
+	result.Duration = time.Since(result.StartTime)
+	result.Progress = 100
+	t.recordDedupStats(result, dedup)
+
+	if ctx.Err() != nil {
+		result.Interrupted = true
+		return result, ctx.Err()
+	}
+	return result, nil
+}
+
+// sortPlan orders items by source repository and tag, as DiffTree emits
+// repositories in the order their workers finish
+func sortPlan(plan *copy.Plan) {
+	sort.SliceStable(plan.Items, func(i, j int) bool {
+		a, b := plan.Items[i], plan.Items[j]
+		if a.SourceRepository != b.SourceRepository {
+			return a.SourceRepository < b.SourceRepository
+		}
+		return a.Tag < b.Tag
+	})
+}
+
+// repositoryCache resolves each repository of a plan once
+type repositoryCache struct {
+	sourceClient interfaces.RegistryClient
+	destClient   interfaces.RegistryClient
+
+	mu      sync.Mutex
+	sources map[string]interfaces.Repository
+	dests   map[string]interfaces.Repository
+}
+
+func newRepositoryCache(sourceClient, destClient interfaces.RegistryClient) *repositoryCache {
+	return &repositoryCache{
+		sourceClient: sourceClient,
+		destClient:   destClient,
+		sources:      make(map[string]interfaces.Repository),
+		dests:        make(map[string]interfaces.Repository),
+	}
+}
+
+// source returns the source repository named repo
+func (c *repositoryCache) source(ctx context.Context, repo string) (interfaces.Repository, error) {
+	return c.get(ctx, c.sourceClient, c.sources, repo, "source")
+}
+
+// get returns repo from cache, resolving it with client on first use
+func (c *repositoryCache) get(ctx context.Context, client interfaces.RegistryClient, cache map[string]interfaces.Repository, repo, side string) (interfaces.Repository, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if r, ok := cache[repo]; ok {
+		return r, nil
+	}
+	r, err := client.GetRepository(ctx, repo)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get %s repository %s", side, repo)
+	}
+	cache[repo] = r
+	return r, nil
+}
+
+// planItem returns a copy item for tag of sourceRepo to destRepo
+func (c *repositoryCache) planItem(ctx context.Context, sourceRepo, destRepo, tag string) (copy.PlanItem, error) {
+	src, err := c.get(ctx, c.sourceClient, c.sources, sourceRepo, "source")
+	if err != nil {
+		return copy.PlanItem{}, err
+	}
+	dest, err := c.get(ctx, c.destClient, c.dests, destRepo, "destination")
+	if err != nil {
+		return copy.PlanItem{}, err
+	}
+
+	sourceRef, err := src.GetImageReference(tag)
+	if err != nil {
+		return copy.PlanItem{}, errors.Wrap(err, "failed to get source image reference")
+	}
+	destRef, err := dest.GetImageReference(tag)
+	if err != nil {
+		return copy.PlanItem{}, errors.Wrap(err, "failed to get destination image reference")
+	}
+	srcOpts, err := src.GetRemoteOptions()
+	if err != nil {
+		return copy.PlanItem{}, errors.Wrap(err, "failed to get source remote options")
+	}
+	destOpts, err := dest.GetRemoteOptions()
+	if err != nil {
+		return copy.PlanItem{}, errors.Wrap(err, "failed to get destination remote options")
+	}
+
+	return copy.PlanItem{
+		Source:           sourceRef.String(),
+		Destination:      destRef.String(),
+		SourceRepository: sourceRepo,
+		DestRepository:   destRepo,
+		Tag:              tag,
+		Action:           copy.PlanCopy,
+		SourceOptions:    srcOpts,
+		DestOptions:      destOpts,
+	}, nil
+}
@@ -0,0 +1,73 @@
+package tree
+
+import (
+	"context"
+	"testing"
+
+	"freightliner/pkg/copy"
+	"freightliner/pkg/helper/log"
+)
+
+func TestPlanTree(t *testing.T) {
+	source := newDiffTestClient("source", map[string]map[string]string{
+		"team/app": {"v1": "a", "v2": "b", "v3": "c", "dev-1": "x"},
+		"team/new": {"v1": "e"},
+	})
+	dest := newDiffTestClient("dest", map[string]map[string]string{
+		"mirror/app": {"v1": "a", "v2": "changed", "old": "f"},
+	})
+
+	replicator := NewTreeReplicator(log.NewBasicLogger(log.ErrorLevel), nil, TreeReplicatorOptions{
+		WorkerCount: 2,
+		ExcludeTags: []string{"dev-*"},
+	})
+	opts := PlanTreeOptions{
+		SourceClient: source,
+		DestClient:   dest,
+		SourcePrefix: "team",
+		DestPrefix:   "mirror",
+	}
+
+	// Without digests every filtered source tag is a copy
+	plan, err := replicator.PlanTree(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("PlanTree() error = %v", err)
+	}
+	if len(plan.Items) != 4 || len(plan.Copies()) != 4 {
+		t.Fatalf("expected four copies, got %+v", plan.Items)
+	}
+	if first := plan.Items[0]; first.SourceRepository != "team/app" || first.DestRepository != "mirror/app" || first.Tag != "v1" {
+		t.Errorf("unexpected first item: %+v", first)
+	}
+
+	opts.CompareDigests = true
+	plan, err = replicator.PlanTree(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("PlanTree() error = %v", err)
+	}
+	want := map[string]copy.PlanAction{
+		"mirror/app:v1": copy.PlanSkip,
+		"mirror/app:v2": copy.PlanSkip,
+		"mirror/app:v3": copy.PlanCopy,
+		"mirror/new:v1": copy.PlanCopy,
+	}
+	if len(plan.Items) != len(want) {
+		t.Fatalf("expected %d items, got %+v", len(want), plan.Items)
+	}
+	for _, item := range plan.Items {
+		key := item.DestRepository + ":" + item.Tag
+		if item.Action != want[key] {
+			t.Errorf("expected %s to be %s, got %s (%s)", key, want[key], item.Action, item.Reason)
+		}
+	}
+
+	// Overwriting plans the changed tag as a copy
+	opts.ForceOverwrite = true
+	plan, err = replicator.PlanTree(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("PlanTree() error = %v", err)
+	}
+	if len(plan.Copies()) != 3 {
+		t.Errorf("expected three copies with overwrite, got %+v", plan.Copies())
+	}
+}
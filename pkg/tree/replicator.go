@@ -6,6 +6,7 @@ import (
 	"path"
 	"regexp"
 	"runtime"
+	"slices"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -14,7 +15,9 @@ import (
 	"freightliner/pkg/copy"
 	"freightliner/pkg/helper/errors"
 	"freightliner/pkg/helper/log"
+	"freightliner/pkg/helper/runid"
 	"freightliner/pkg/interfaces"
+	"freightliner/pkg/replication"
 	"freightliner/pkg/tree/checkpoint"
 
 	"github.com/google/uuid"
@@ -57,13 +60,25 @@ type TreeReplicationResult struct {
 	CompletedRepositories []string
 	// Whether this is a resumed replication
 	Resumed bool
+	// Layers shared between repositories that were skipped or mounted instead of uploaded
+	BlobsDeduplicated int64
+	// Bytes not uploaded thanks to cross-repository deduplication
+	BytesDeduplicated int64
 }
 
 // TreeReplicatorOptions provides configuration for tree replication
 type TreeReplicatorOptions struct {
-	// WorkerCount is the number of concurrent workers
+	// WorkerCount is the number of repositories replicated concurrently
 	WorkerCount int
 
+	// TagWorkerCount is the number of tags replicated concurrently within each
+	// repository. Zero sizes it automatically from the CPU count.
+	TagWorkerCount int
+
+	// MaxTransfers caps the number of image copies in flight across all
+	// repositories and tags. Zero means no global limit.
+	MaxTransfers int
+
 	// ExcludeRepositories is a list of repository patterns to exclude
 	ExcludeRepositories []string
 
@@ -73,14 +88,44 @@ type TreeReplicatorOptions struct {
 	// IncludeTags is a list of tag patterns to include
 	IncludeTags []string
 
+	// ExplainFilters logs the rule that included or excluded each
+	// repository and tag
+	ExplainFilters bool
+
 	// EnableCheckpointing enables checkpoint functionality
 	EnableCheckpointing bool
 
 	// CheckpointDirectory is the directory for checkpoint files
 	CheckpointDirectory string
 
+	// CheckpointWriteTimeout bounds each checkpoint write, which happens in
+	// the background (default checkpoint.DefaultWriteTimeout)
+	CheckpointWriteTimeout time.Duration
+
+	// CheckpointSlowStore is what happens once a write times out: wait
+	// (default) keeps checkpointing, disable stops it for the run
+	CheckpointSlowStore checkpoint.SlowStorePolicy
+
 	// DryRun indicates whether to perform actual copies
 	DryRun bool
+
+	// NameMapping maps source repositories to destination names, e.g. to
+	// fit destinations with shallower paths than the source (optional)
+	NameMapping NameMapping
+
+	// OnRepoStart, OnRepoDone and OnTagDone are called as repositories and
+	// tags are replicated, e.g. to record metrics or update a database
+	// (optional). They are called concurrently from the replication
+	// workers and should return quickly.
+	OnRepoStart func(RepoStartEvent)
+	OnRepoDone  func(RepoDoneEvent)
+	OnTagDone   func(TagDoneEvent)
+
+	// EnsureDestinationRepository returns the destination repository,
+	// creating it when it is missing and the auto-creation policy allows
+	// (optional; the destination client's repository is used as is when nil).
+	// source is the source registry and repository.
+	EnsureDestinationRepository func(ctx context.Context, destClient interfaces.RegistryClient, destRepo, source string) (interfaces.Repository, error)
 }
 
 // ReplicateTreeOptions provides options for the ReplicateTree method
@@ -105,6 +150,15 @@ type ReplicateTreeOptions struct {
 
 	// SkipCompletedRepositories skips repositories marked as completed in the checkpoint
 	SkipCompletedRepositories bool
+
+	// Repositories replaces listing the source when non-nil, e.g. with the
+	// remaining repositories of a resumed checkpoint
+	Repositories []string
+
+	// CompletedTags lists the tags per source repository that an earlier run
+	// already copied. They are skipped unless ForceOverwrite is set. When
+	// resuming and nil, it is taken from the checkpoint.
+	CompletedTags map[string][]string
 }
 
 // TreeReplicator coordinates the replication of repositories
@@ -112,15 +166,25 @@ type TreeReplicator struct {
 	logger            log.Logger
 	copier            *copy.Copier
 	workerCount       int
+	tagWorkerCount    int
+	transferSlots     chan struct{} // Global limit on in-flight copies, nil when unlimited
 	filters           FilterOptions
 	excludeReposCache *patternCache
 	excludeTagsCache  *patternCache
 	includeTagsCache  *patternCache
+	explainFilters    bool
 	checkpointing     CheckpointOptions
 	checkpointStore   checkpoint.CheckpointStore
+	checkpointWriter  *checkpoint.AsyncStore // Background writer behind checkpointStore
+	checkpointTimeout time.Duration
 	dryRun            bool
+	nameMapping       NameMapping
 	metrics           interface{}  // Metrics interface for tracking replication stats
 	checkpointMu      sync.RWMutex // Protects concurrent access to checkpoint data
+	onRepoStart       func(RepoStartEvent)
+	onRepoDone        func(RepoDoneEvent)
+	onTagDone         func(TagDoneEvent)
+	ensureDestRepo    func(ctx context.Context, destClient interfaces.RegistryClient, destRepo, source string) (interfaces.Repository, error)
 }
 
 // SetMetrics sets the metrics interface for the tree replicator
@@ -140,15 +204,27 @@ func NewTreeReplicator(logger log.Logger, copier *copy.Copier, options TreeRepli
 		logger:            logger,
 		copier:            copier,
 		workerCount:       options.WorkerCount,
+		tagWorkerCount:    options.TagWorkerCount,
 		filters:           filters,
 		excludeReposCache: newPatternCache(filters.ExcludeRepos),
 		excludeTagsCache:  newPatternCache(filters.ExcludeTags),
 		includeTagsCache:  newPatternCache(filters.IncludeTags),
+		explainFilters:    options.ExplainFilters,
 		checkpointing: CheckpointOptions{
 			Enabled: options.EnableCheckpointing,
 			Dir:     options.CheckpointDirectory,
 		},
-		dryRun: options.DryRun,
+		dryRun:      options.DryRun,
+		nameMapping: options.NameMapping,
+		onRepoStart: options.OnRepoStart,
+		onRepoDone:  options.OnRepoDone,
+		onTagDone:   options.OnTagDone,
+
+		ensureDestRepo: options.EnsureDestinationRepository,
+	}
+
+	if options.MaxTransfers > 0 {
+		t.transferSlots = make(chan struct{}, options.MaxTransfers)
 	}
 
 	// Initialize checkpoint store if enabled
@@ -160,7 +236,25 @@ func NewTreeReplicator(logger log.Logger, copier *copy.Copier, options TreeRepli
 				"dir":   t.checkpointing.Dir,
 			}).Warn("Failed to initialize checkpoint store, checkpointing disabled")
 		} else {
-			t.checkpointStore = store
+			// Checkpoints are saved under checkpointMu from the workers, so
+			// write them in the background where a slow filesystem cannot
+			// hold the lock
+			t.checkpointTimeout = options.CheckpointWriteTimeout
+			if t.checkpointTimeout <= 0 {
+				t.checkpointTimeout = checkpoint.DefaultWriteTimeout
+			}
+			t.checkpointWriter = checkpoint.NewAsyncStore(store, checkpoint.AsyncStoreOptions{
+				WriteTimeout: t.checkpointTimeout,
+				SlowStore:    options.CheckpointSlowStore,
+				OnWriteError: func(id string, err error) {
+					t.logger.WithFields(map[string]interface{}{
+						"checkpoint_id": id,
+						"error":         err.Error(),
+						"slow_store":    options.CheckpointSlowStore,
+					}).Warn("Failed to write checkpoint")
+				},
+			})
+			t.checkpointStore = t.checkpointWriter
 		}
 	}
 
@@ -176,8 +270,14 @@ func (t *TreeReplicator) ReplicateTree(
 	result, cancelCtx := t.initReplication(ctx)
 	defer cancelCtx()
 
-	// Initialize checkpoint
-	treeCheckpoint := t.setupCheckpoint(opts, result)
+	// Initialize checkpoint, continuing the resumed one if any
+	treeCheckpoint, err := t.setupCheckpoint(ctx, opts, result)
+	if err != nil {
+		return result, err
+	}
+	if opts.CompletedTags == nil && treeCheckpoint != nil && opts.ResumeFromCheckpoint != "" {
+		opts.CompletedTags = checkpoint.GetCompletedTags(treeCheckpoint)
+	}
 
 	// List and filter repositories
 	repositories, repoCount, err := t.getRepositories(ctx, opts, treeCheckpoint, result)
@@ -185,8 +285,15 @@ func (t *TreeReplicator) ReplicateTree(
 		return result, err
 	}
 
+	// Map destination names up front so colliding names copy nothing
+	mapped, err := t.nameMapping.MapAll(repositories, opts.SourcePrefix, opts.DestPrefix)
+	if err != nil {
+		t.completeReplication(treeCheckpoint, result, checkpoint.StatusFailed)
+		return result, err
+	}
+
 	// Process repositories with worker pool
-	statusErr := t.processRepositories(ctx, opts, repositories, treeCheckpoint, result)
+	statusErr := t.processRepositories(ctx, opts, repositories, mapped, treeCheckpoint, result)
 
 	// Complete replication with appropriate status
 	if statusErr != nil {
@@ -213,13 +320,19 @@ func (t *TreeReplicator) initReplication(ctx context.Context) (*TreeReplicationR
 	return result, cancel
 }
 
-// setupCheckpoint initializes a checkpoint if checkpointing is enabled
+// setupCheckpoint initializes a checkpoint if checkpointing is enabled, or
+// loads the one being resumed
 func (t *TreeReplicator) setupCheckpoint(
+	ctx context.Context,
 	opts ReplicateTreeOptions,
 	result *TreeReplicationResult,
-) *checkpoint.TreeCheckpoint {
+) (*checkpoint.TreeCheckpoint, error) {
+	if opts.ResumeFromCheckpoint != "" {
+		return t.resumeCheckpoint(ctx, opts.ResumeFromCheckpoint, result)
+	}
+
 	if !t.checkpointing.Enabled || t.checkpointStore == nil {
-		return nil
+		return nil, nil
 	}
 
 	treeCheckpoint := &checkpoint.TreeCheckpoint{
@@ -231,6 +344,7 @@ func (t *TreeReplicator) setupCheckpoint(
 		Status:         checkpoint.StatusInProgress,
 		StartTime:      result.StartTime,
 		LastUpdated:    result.StartTime,
+		RunID:          runid.FromContext(ctx),
 		Repositories:   make(map[string]checkpoint.RepoStatus),
 	}
 
@@ -243,7 +357,38 @@ func (t *TreeReplicator) setupCheckpoint(
 		result.CheckpointID = treeCheckpoint.ID
 	}
 
-	return treeCheckpoint
+	return treeCheckpoint, nil
+}
+
+// resumeCheckpoint loads a saved checkpoint and marks it in progress again
+func (t *TreeReplicator) resumeCheckpoint(ctx context.Context, id string, result *TreeReplicationResult) (*checkpoint.TreeCheckpoint, error) {
+	if !t.checkpointing.Enabled || t.checkpointStore == nil {
+		return nil, errors.InvalidInputf("checkpointing must be enabled to resume checkpoint %s", id)
+	}
+
+	treeCheckpoint, err := checkpoint.GetCheckpointByID(t.checkpointStore, id)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load checkpoint to resume")
+	}
+	if treeCheckpoint.Repositories == nil {
+		treeCheckpoint.Repositories = make(map[string]checkpoint.RepoStatus)
+	}
+
+	treeCheckpoint.Status = checkpoint.StatusInProgress
+	treeCheckpoint.LastError = ""
+	if id := runid.FromContext(ctx); id != "" {
+		treeCheckpoint.RunID = id
+	}
+	if err := t.checkpointStore.SaveCheckpoint(treeCheckpoint); err != nil {
+		t.logger.WithFields(map[string]interface{}{
+			"checkpoint_id": treeCheckpoint.ID,
+		}).Warn(errors.Wrap(err, "failed to save resumed checkpoint").Error())
+	}
+
+	result.CheckpointID = treeCheckpoint.ID
+	result.Resumed = true
+
+	return treeCheckpoint, nil
 }
 
 // getRepositories lists and filters repositories from the source
@@ -253,10 +398,14 @@ func (t *TreeReplicator) getRepositories(
 	treeCheckpoint *checkpoint.TreeCheckpoint,
 	result *TreeReplicationResult,
 ) ([]string, int, error) {
-	repositories, err := t.listAndFilterRepositories(ctx, opts.SourceClient, opts.SourcePrefix)
-	if err != nil {
-		t.handleError(err, treeCheckpoint, "Failed to list repositories")
-		return nil, 0, err
+	repositories := opts.Repositories
+	if repositories == nil {
+		listed, err := t.listAndFilterRepositories(ctx, opts.SourceClient, opts.SourcePrefix)
+		if err != nil {
+			t.handleError(err, treeCheckpoint, "Failed to list repositories")
+			return nil, 0, err
+		}
+		repositories = t.skipCompletedRepositories(opts, listed, treeCheckpoint)
 	}
 
 	repoCount := len(repositories)
@@ -273,11 +422,41 @@ func (t *TreeReplicator) getRepositories(
 	return repositories, repoCount, nil
 }
 
+// skipCompletedRepositories drops repositories the resumed checkpoint has
+// already completed when SkipCompletedRepositories is set
+func (t *TreeReplicator) skipCompletedRepositories(
+	opts ReplicateTreeOptions,
+	repositories []string,
+	treeCheckpoint *checkpoint.TreeCheckpoint,
+) []string {
+	if !opts.SkipCompletedRepositories || opts.ResumeFromCheckpoint == "" || treeCheckpoint == nil {
+		return repositories
+	}
+
+	remaining := make([]string, 0, len(repositories))
+	for _, repo := range repositories {
+		if status, ok := treeCheckpoint.Repositories[repo]; ok && status.Status == checkpoint.StatusCompleted {
+			continue
+		}
+		remaining = append(remaining, repo)
+	}
+
+	if skipped := len(repositories) - len(remaining); skipped > 0 {
+		t.logger.WithFields(map[string]interface{}{
+			"checkpoint_id": treeCheckpoint.ID,
+			"skipped":       skipped,
+		}).Info("Skipping repositories completed by the resumed run")
+	}
+
+	return remaining
+}
+
 // processRepositories starts the worker pool and processes all repositories
 func (t *TreeReplicator) processRepositories(
 	ctx context.Context,
 	opts ReplicateTreeOptions,
 	repositories []string,
+	mapped map[string]MappedRepository,
 	treeCheckpoint *checkpoint.TreeCheckpoint,
 	result *TreeReplicationResult,
 ) error {
@@ -287,19 +466,49 @@ func (t *TreeReplicator) processRepositories(
 	t.logger.WithFields(map[string]interface{}{
 		"repositories": repoCount,
 		"workers":      t.workerCount,
+		"tag_workers":  t.tagWorkerCount,
 		"dry_run":      t.dryRun,
 	}).Info("Starting replication")
 
-	// Set up worker pool
-	jobs, metrics, doneSignal := t.setupWorkerPool(ctx, repoCount, opts, treeCheckpoint, result)
+	// Set up worker pool with a bounded queue; queueing waits for workers
+	// so jobs for huge trees are not all held in memory at once
+	poolMetrics, _ := t.metrics.(replication.PoolMetrics)
+	pool := replication.NewWorkerPoolWithOptions(replication.WorkerPoolOptions{
+		Workers:       t.workerCount,
+		BlockWhenFull: true,
+		Logger:        t.logger,
+		Metrics:       poolMetrics,
+	})
+	pool.Start()
+
+	doneSignal := t.setupSignalHandling(ctx, nil)
 	defer close(doneSignal)
 
+	var completedRepos atomic.Int32
+	collected := t.collectResults(pool)
+
+	// Layers shared between repositories are only uploaded once per run
+	dedup := copy.NewBlobDedup()
+	if dedupMetrics, ok := t.metrics.(copy.DedupMetrics); ok {
+		dedup.SetMetrics(dedupMetrics)
+	}
+
 	// Queue repository jobs
-	t.queueRepositoryJobs(ctx, repositories, jobs)
+	t.queueRepositoryJobs(ctx, pool, repositories, mapped, opts, treeCheckpoint, result, dedup, &completedRepos)
 
 	// Wait for completion and update metrics
-	metrics.WaitGroup.Wait()
-	t.updateFinalMetrics(result, metrics.CompletedRepos, repoCount)
+	pool.Wait()
+	<-collected
+	t.updateFinalMetrics(result, &completedRepos, repoCount)
+	t.recordDedupStats(result, dedup)
+	if stats := pool.GetStats(); stats.BlockedSubmits > 0 {
+		t.logger.WithFields(map[string]interface{}{
+			"blocked_submits":   stats.BlockedSubmits,
+			"submit_wait":       stats.SubmitWaitTime.String(),
+			"queue_capacity":    stats.QueueCapacity,
+			"max_queue_latency": stats.MaxQueueLatency.String(),
+		}).Debug("Repository queueing waited for workers")
+	}
 
 	// Check for interruption
 	if ctx.Err() != nil {
@@ -312,90 +521,100 @@ func (t *TreeReplicator) processRepositories(
 	return nil
 }
 
-// setupWorkerPool creates and starts workers for repository processing
-func (t *TreeReplicator) setupWorkerPool(
+// queueRepositoryJobs submits one worker pool task per repository
+func (t *TreeReplicator) queueRepositoryJobs(
 	ctx context.Context,
-	repoCount int,
+	pool *replication.WorkerPool,
+	repositories []string,
+	mapped map[string]MappedRepository,
 	opts ReplicateTreeOptions,
 	treeCheckpoint *checkpoint.TreeCheckpoint,
 	result *TreeReplicationResult,
-) (chan replicationJob, *workerMetrics, chan struct{}) {
-	// Create channels and metrics
-	jobs := make(chan replicationJob, repoCount)
-	var wg sync.WaitGroup
-	var completedRepos atomic.Int32
-	var errorCount atomic.Int32
+	dedup *copy.BlobDedup,
+	completedRepos *atomic.Int32,
+) {
+	for _, repo := range repositories {
+		if ctx.Err() != nil {
+			return
+		}
 
-	// Setup signal handling
-	cancel := func() {} // Default no-op
-	done := t.setupSignalHandling(ctx, cancel)
+		destRepo := mapped[repo].Destination
+		labels := map[string]string{
+			"source":      fmt.Sprintf("%s/%s", opts.SourceClient.GetRegistryName(), repo),
+			"destination": fmt.Sprintf("%s/%s", opts.DestClient.GetRegistryName(), destRepo),
+		}
 
-	// Package metrics for workers
-	metrics := &workerMetrics{
-		WaitGroup:      &wg,
-		CompletedRepos: &completedRepos,
-		ErrorCount:     &errorCount,
-	}
+		processOpts := repositoryProcessOptions{
+			SourceClient:   opts.SourceClient,
+			DestClient:     opts.DestClient,
+			SourceRepo:     repo,
+			DestRepo:       destRepo,
+			Annotations:    mapped[repo].Annotations,
+			ForceOverwrite: opts.ForceOverwrite,
+			CompletedTags:  opts.CompletedTags[repo],
+			TreeCheckpoint: treeCheckpoint,
+			Result:         result,
+			Dedup:          dedup,
+		}
 
-	// Start workers
-	t.startWorkers(ctx, jobs, opts, treeCheckpoint, result, metrics)
+		err := pool.SubmitWithLabels(ctx, repo, labels, func(jobCtx context.Context) error {
+			if jobCtx.Err() != nil {
+				return jobCtx.Err()
+			}
+			defer completedRepos.Add(1)
 
-	return jobs, metrics, done
-}
+			t.logger.WithFields(map[string]interface{}{
+				"source":      labels["source"],
+				"destination": labels["destination"],
+				"dry_run":     t.dryRun,
+			}).Info("Replicating repository")
 
-// workerMetrics holds tracking variables for worker pool
-type workerMetrics struct {
-	WaitGroup      *sync.WaitGroup
-	CompletedRepos *atomic.Int32
-	ErrorCount     *atomic.Int32
+			processOpts.Context = jobCtx
+			started := time.Now()
+			t.notifyRepoStart(processOpts)
+			err := t.processRepository(processOpts)
+			t.notifyRepoDone(processOpts, started, err)
+			if err != nil {
+				t.markRepositoryFailed(processOpts, err)
+				return err
+			}
+			return nil
+		})
+		if err != nil {
+			t.logger.WithFields(map[string]interface{}{
+				"repository": repo,
+			}).Error("Failed to queue repository", err)
+		}
+	}
 }
 
-// startWorkers launches worker goroutines
-func (t *TreeReplicator) startWorkers(
-	ctx context.Context,
-	jobs chan replicationJob,
-	opts ReplicateTreeOptions,
-	treeCheckpoint *checkpoint.TreeCheckpoint,
-	result *TreeReplicationResult,
-	metrics *workerMetrics,
-) {
-	workerOpts := replicationWorkerOptions{
-		Context:        ctx,
-		Jobs:           jobs,
-		WaitGroup:      metrics.WaitGroup,
-		CompletedRepos: metrics.CompletedRepos,
-		ErrorCount:     metrics.ErrorCount,
-		SourceClient:   opts.SourceClient,
-		DestClient:     opts.DestClient,
-		SourcePrefix:   opts.SourcePrefix,
-		DestPrefix:     opts.DestPrefix,
-		ForceOverwrite: opts.ForceOverwrite,
-		TreeCheckpoint: treeCheckpoint,
-		Result:         result,
-	}
+// collectResults drains the pool's results; failures are logged by the pool
+// with the repository labels
+func (t *TreeReplicator) collectResults(pool *replication.WorkerPool) <-chan struct{} {
+	done := make(chan struct{})
 
-	for i := 0; i < t.workerCount; i++ {
-		metrics.WaitGroup.Add(1)
-		go t.replicationWorker(workerOpts)
-	}
+	go func() {
+		defer close(done)
+		for range pool.GetResults() {
+		}
+	}()
+
+	return done
 }
 
-// queueRepositoryJobs sends repository jobs to worker pool
-func (t *TreeReplicator) queueRepositoryJobs(
-	ctx context.Context,
-	repositories []string,
-	jobs chan<- replicationJob,
-) {
-	for _, repo := range repositories {
-		select {
-		case <-ctx.Done():
-			break
-		case jobs <- replicationJob{repository: repo}:
-			// Job queued successfully
-		}
-	}
+// recordDedupStats copies cross-repository deduplication savings into the result
+func (t *TreeReplicator) recordDedupStats(result *TreeReplicationResult, dedup *copy.BlobDedup) {
+	stats := dedup.Stats()
+	result.BlobsDeduplicated = stats.BlobsSkipped + stats.BlobsMounted
+	result.BytesDeduplicated = stats.BytesSaved
 
-	close(jobs)
+	if result.BlobsDeduplicated > 0 {
+		t.logger.WithFields(map[string]interface{}{
+			"blobs_skipped": stats.BlobsSkipped,
+			"blobs_mounted": stats.BlobsMounted,
+			"bytes_saved":   stats.BytesSaved,
+		}).Info("Deduplicated layers shared across repositories")
+	}
 }
 
 // updateFinalMetrics updates final progress and duration metrics
@@ -414,7 +633,11 @@ func (t *TreeReplicator) updateFinalMetrics(
 
 // filterTags applies tag filters using the optimized pattern caches
 // Returns tags that should be included (pass all filters)
-func (t *TreeReplicator) filterTags(tags []string) []string {
+func (t *TreeReplicator) filterTags(repository string, tags []string) []string {
+	if t.explainFilters {
+		return t.explainTags(repository, tags)
+	}
+
 	// Skip filtering if no filters are defined
 	if len(t.filters.ExcludeTags) == 0 && len(t.filters.IncludeTags) == 0 {
 		return tags
@@ -463,7 +686,75 @@ func isTagIncluded(tag string, excludeCache, includeCache *patternCache, include
 	return includeCache != nil && includeCache.matches(tag)
 }
 
-// This type was unused and has been removed
+// filterDecision records the rule that included or excluded a repository
+// or tag
+type filterDecision struct {
+	included bool
+	rule     string // e.g. "exclude-tag"; empty when no filter applies
+	pattern  string
+	reason   string
+}
+
+// explainTags filters tags like filterTags, logging the decision for each
+func (t *TreeReplicator) explainTags(repository string, tags []string) []string {
+	result := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		decision := explainTag(tag, t.excludeTagsCache, t.includeTagsCache)
+		t.logFilterDecision(repository, tag, decision)
+		if decision.included {
+			result = append(result, tag)
+		}
+	}
+	return result
+}
+
+// explainTag evaluates a tag against the exclude and include patterns in the
+// same order as isTagIncluded
+func explainTag(tag string, excludeCache, includeCache *patternCache) filterDecision {
+	if pattern, ok := excludeCache.matchingPattern(tag); ok {
+		return filterDecision{rule: "exclude-tag", pattern: pattern, reason: "tag matches an exclude pattern"}
+	}
+	if includeCache == nil {
+		return filterDecision{included: true, reason: "no include patterns; tag matches no exclude pattern"}
+	}
+	if pattern, ok := includeCache.matchingPattern(tag); ok {
+		return filterDecision{included: true, rule: "include-tag", pattern: pattern, reason: "tag matches an include pattern"}
+	}
+	return filterDecision{
+		rule:    "include-tag",
+		pattern: strings.Join(includeCache.patterns, ","),
+		reason:  "tag matches none of the include patterns",
+	}
+}
+
+// explainRepository evaluates a repository against the exclude patterns
+func explainRepository(repo string, excludeCache *patternCache) filterDecision {
+	if pattern, ok := excludeCache.matchingPattern(repo); ok {
+		return filterDecision{rule: "exclude-repo", pattern: pattern, reason: "repository matches an exclude pattern"}
+	}
+	return filterDecision{included: true, reason: "repository matches no exclude pattern"}
+}
+
+// logFilterDecision logs why a repository, or a tag when tag is set, was
+// included or excluded
+func (t *TreeReplicator) logFilterDecision(repository, tag string, decision filterDecision) {
+	fields := map[string]interface{}{
+		"repository": repository,
+		"decision":   "excluded",
+		"reason":     decision.reason,
+	}
+	if decision.included {
+		fields["decision"] = "included"
+	}
+	if tag != "" {
+		fields["tag"] = tag
+	}
+	if decision.rule != "" {
+		fields["rule"] = decision.rule
+		fields["pattern"] = decision.pattern
+	}
+	t.logger.WithFields(fields).Info("Filter decision")
+}
 
 // listAndFilterRepositories gets repositories and applies filters
 func (t *TreeReplicator) listAndFilterRepositories(
@@ -481,6 +772,18 @@ func (t *TreeReplicator) listAndFilterRepositories(
 		return nil, errors.Wrap(err, "failed to list repositories")
 	}
 
+	if t.explainFilters {
+		filtered := make([]string, 0, len(repositories))
+		for _, repo := range repositories {
+			decision := explainRepository(repo, t.excludeReposCache)
+			t.logFilterDecision(repo, "", decision)
+			if decision.included {
+				filtered = append(filtered, repo)
+			}
+		}
+		return filtered, nil
+	}
+
 	// Apply repository exclusion filters using the cached patterns
 	if t.excludeReposCache != nil {
 		filtered := make([]string, 0, len(repositories))
@@ -513,6 +816,8 @@ type patternCache struct {
 
 	// Performance optimization: pre-compiled regex patterns for complex cases
 	regexPatterns []*regexPattern // Pre-compiled regex patterns for optimal performance
+
+	patterns []string // Original patterns, in order, for explaining matches
 }
 
 // newPatternCache creates an optimized pattern cache from a slice of patterns
@@ -529,6 +834,7 @@ func newPatternCache(patterns []string) *patternCache {
 		complexPatterns: []string{},
 		regexPatterns:   []*regexPattern{},
 		hasWildcard:     false,
+		patterns:        patterns,
 	}
 
 	for _, pattern := range patterns {
@@ -626,6 +932,20 @@ func (pc *patternCache) compileGlobToRegex(pattern string) (*regexPattern, error
 	}, nil
 }
 
+// matchingPattern returns the first pattern, in configured order, that
+// matches s. It is slower than matches and only used to explain decisions.
+func (pc *patternCache) matchingPattern(s string) (string, bool) {
+	if pc == nil {
+		return "", false
+	}
+	for _, pattern := range pc.patterns {
+		if newPatternCache([]string{pattern}).matches(s) {
+			return pattern, true
+		}
+	}
+	return "", false
+}
+
 // matches returns true if the string matches any pattern in the cache
 func (pc *patternCache) matches(s string) bool {
 	// Handle empty case
@@ -712,11 +1032,6 @@ func (pc *patternCache) matchesComplex(s string) bool {
 
 // Unused pattern cache code has been removed
 
-// replicationJob represents a single repository replication task
-type replicationJob struct {
-	repository string
-}
-
 // setupSignalHandling sets up goroutine for handling cancellation signals
 func (t *TreeReplicator) setupSignalHandling(ctx context.Context, _ context.CancelFunc) chan struct{} {
 	done := make(chan struct{})
@@ -733,69 +1048,6 @@ func (t *TreeReplicator) setupSignalHandling(ctx context.Context, _ context.Canc
 	return done
 }
 
-// replicationWorkerOptions holds all parameters for replication workers
-type replicationWorkerOptions struct {
-	Context        context.Context
-	Jobs           <-chan replicationJob
-	WaitGroup      *sync.WaitGroup
-	CompletedRepos *atomic.Int32
-	ErrorCount     *atomic.Int32
-	SourceClient   interfaces.RegistryClient
-	DestClient     interfaces.RegistryClient
-	SourcePrefix   string
-	DestPrefix     string
-	ForceOverwrite bool
-	TreeCheckpoint *checkpoint.TreeCheckpoint
-	Result         *TreeReplicationResult
-}
-
-// replicationWorker processes repository replication jobs
-func (t *TreeReplicator) replicationWorker(opts replicationWorkerOptions) {
-	defer opts.WaitGroup.Done()
-
-	for job := range opts.Jobs {
-		select {
-		case <-opts.Context.Done():
-			return
-		default:
-			// Process job
-			repo := job.repository
-
-			// Generate destination repository name by replacing prefix
-			destRepo := strings.Replace(repo, opts.SourcePrefix, opts.DestPrefix, 1)
-
-			t.logger.WithFields(map[string]interface{}{
-				"source":      fmt.Sprintf("%s/%s", opts.SourceClient.GetRegistryName(), repo),
-				"destination": fmt.Sprintf("%s/%s", opts.DestClient.GetRegistryName(), destRepo),
-				"dry_run":     t.dryRun,
-			}).Info("Replicating repository")
-
-			// Create options for processing the repository
-			processOpts := repositoryProcessOptions{
-				Context:        opts.Context,
-				SourceClient:   opts.SourceClient,
-				DestClient:     opts.DestClient,
-				SourceRepo:     repo,
-				DestRepo:       destRepo,
-				ForceOverwrite: opts.ForceOverwrite,
-				TreeCheckpoint: opts.TreeCheckpoint,
-				Result:         opts.Result,
-			}
-
-			// Process repository
-			if err := t.processRepository(processOpts); err != nil {
-				opts.ErrorCount.Add(1)
-				t.logger.WithFields(map[string]interface{}{
-					"source":      fmt.Sprintf("%s/%s", opts.SourceClient.GetRegistryName(), repo),
-					"destination": fmt.Sprintf("%s/%s", opts.DestClient.GetRegistryName(), destRepo),
-				}).Error("Failed to replicate repository", err)
-			}
-
-			opts.CompletedRepos.Add(1)
-		}
-	}
-}
-
 // repositoryProcessOptions holds options for processing a single repository
 type repositoryProcessOptions struct {
 	Context        context.Context
@@ -803,9 +1055,12 @@ type repositoryProcessOptions struct {
 	DestClient     interfaces.RegistryClient
 	SourceRepo     string
 	DestRepo       string
+	Annotations    map[string]string
 	ForceOverwrite bool
+	CompletedTags  []string
 	TreeCheckpoint *checkpoint.TreeCheckpoint
 	Result         *TreeReplicationResult
+	Dedup          *copy.BlobDedup
 }
 
 // processRepository handles the replication of a single repository
@@ -816,11 +1071,14 @@ func (t *TreeReplicator) processRepository(opts repositoryProcessOptions) error
 	// Update checkpoint if enabled
 	if t.checkpointing.Enabled && t.checkpointStore != nil && opts.TreeCheckpoint != nil {
 		t.checkpointMu.Lock()
-		opts.TreeCheckpoint.Repositories[opts.SourceRepo] = checkpoint.RepoStatus{
-			Status:     checkpoint.StatusInProgress,
-			SourceRepo: opts.SourceRepo,
-			DestRepo:   opts.DestRepo,
-		}
+		// Keep the completed tags of a resumed repository
+		status := opts.TreeCheckpoint.Repositories[opts.SourceRepo]
+		status.Status = checkpoint.StatusInProgress
+		status.SourceRepo = opts.SourceRepo
+		status.DestRepo = opts.DestRepo
+		status.Error = ""
+		status.LastUpdated = time.Now()
+		opts.TreeCheckpoint.Repositories[opts.SourceRepo] = status
 
 		// Save checkpoint while still holding the lock to prevent concurrent access during serialization
 		err := t.checkpointStore.SaveCheckpoint(opts.TreeCheckpoint)
@@ -848,8 +1106,14 @@ func (t *TreeReplicator) processRepository(opts repositoryProcessOptions) error
 		return errors.Wrap(err, "failed to get source repository")
 	}
 
-	// 2. Get destination repository reference
-	destRepo, err := opts.DestClient.GetRepository(opts.Context, opts.DestRepo)
+	// 2. Get destination repository reference, creating it if allowed
+	var destRepo interfaces.Repository
+	if t.ensureDestRepo != nil {
+		source := opts.SourceClient.GetRegistryName() + "/" + opts.SourceRepo
+		destRepo, err = t.ensureDestRepo(opts.Context, opts.DestClient, opts.DestRepo, source)
+	} else {
+		destRepo, err = opts.DestClient.GetRepository(opts.Context, opts.DestRepo)
+	}
 	if err != nil {
 		return errors.Wrap(err, "failed to get destination repository")
 	}
@@ -866,8 +1130,8 @@ func (t *TreeReplicator) processRepository(opts repositoryProcessOptions) error
 		"tags":        tags,
 	}).Info("Found tags in source repository")
 
-	// 4. Filter tags based on configuration
-	filteredTags := t.filterTags(tags)
+	// 4. Filter tags based on configuration, dropping tags a resumed run copied
+	filteredTags := t.skipCompletedTags(opts, t.filterTags(opts.SourceRepo, tags))
 	if len(filteredTags) == 0 {
 		t.logger.WithFields(map[string]interface{}{
 			"source_repo": opts.SourceRepo,
@@ -895,6 +1159,39 @@ func (t *TreeReplicator) processRepository(opts repositoryProcessOptions) error
 	return nil
 }
 
+// skipCompletedTags drops tags an earlier run already copied unless
+// overwriting is forced
+func (t *TreeReplicator) skipCompletedTags(opts repositoryProcessOptions, tags []string) []string {
+	if opts.ForceOverwrite || len(opts.CompletedTags) == 0 {
+		return tags
+	}
+
+	completed := make(map[string]struct{}, len(opts.CompletedTags))
+	for _, tag := range opts.CompletedTags {
+		completed[tag] = struct{}{}
+	}
+
+	remaining := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		if _, ok := completed[tag]; ok {
+			continue
+		}
+		remaining = append(remaining, tag)
+	}
+
+	if skipped := len(tags) - len(remaining); skipped > 0 {
+		if opts.Result != nil {
+			opts.Result.ImagesSkipped.Add(int64(skipped))
+		}
+		t.logger.WithFields(map[string]interface{}{
+			"source_repo": opts.SourceRepo,
+			"skipped":     skipped,
+		}).Info("Skipping tags completed by the resumed run")
+	}
+
+	return remaining
+}
+
 // replicateTags handles the parallel replication of multiple tags
 func (t *TreeReplicator) replicateTags(
 	opts repositoryProcessOptions,
@@ -916,7 +1213,7 @@ func (t *TreeReplicator) replicateTags(
 	}).Info("Starting tag replication")
 
 	// Process tags in parallel for optimal network I/O utilization
-	// Dynamic concurrency based on system capabilities and registry performance
+	// Concurrency is configured per repository or sized from system capabilities
 	maxConcurrentTags := t.calculateOptimalTagConcurrency(len(tags))
 	tagSemaphore := make(chan struct{}, maxConcurrentTags)
 	var wg sync.WaitGroup
@@ -954,6 +1251,18 @@ func (t *TreeReplicator) replicateTags(
 				return
 			}
 
+			// Wait for a global transfer slot shared with other repositories
+			release, err := t.acquireTransferSlot(opts.Context)
+			if err != nil {
+				mu.Lock()
+				tagResults[tag] = err
+				errorCount++
+				mu.Unlock()
+				return
+			}
+			defer release()
+
+			started := time.Now()
 			bytesTransferred, err := t.replicateTagWithMetrics(opts, sourceRepo, destRepo, tag)
 
 			// Safely update shared state
@@ -969,6 +1278,7 @@ func (t *TreeReplicator) replicateTags(
 			} else {
 				successCount++
 				transferredBytes.Add(bytesTransferred)
+				t.markTagCompleted(opts, tag)
 				t.logger.WithFields(map[string]interface{}{
 					"source_repo":       opts.SourceRepo,
 					"dest_repo":         opts.DestRepo,
@@ -986,6 +1296,8 @@ func (t *TreeReplicator) replicateTags(
 				}
 			}
 			mu.Unlock()
+
+			t.notifyTagDone(opts, tag, started, err)
 		}(tag)
 	}
 
@@ -1026,32 +1338,43 @@ func (t *TreeReplicator) replicateTags(
 	return nil
 }
 
-// calculateOptimalTagConcurrency determines optimal concurrency based on system resources and tag count
+// calculateOptimalTagConcurrency determines the number of concurrent tag copies
+// for a repository, using the configured tag worker count when set
 func (t *TreeReplicator) calculateOptimalTagConcurrency(tagCount int) int {
-	// Base concurrency on available CPU cores and expected I/O patterns
-	cores := runtime.NumCPU()
-
-	// For container registry operations, I/O bound operations benefit from higher concurrency
-	// Target: 5-10x CPU cores for I/O bound operations
-	optimalConcurrency := cores * 8
-
-	// Cap based on tag count - no need for more workers than tags
-	if optimalConcurrency > tagCount {
-		optimalConcurrency = tagCount
+	concurrency := t.tagWorkerCount
+	if concurrency <= 0 {
+		// I/O bound registry operations benefit from 5-10x CPU cores,
+		// capped to prevent registry overload
+		concurrency = runtime.NumCPU() * 8
+		if concurrency > 100 {
+			concurrency = 100
+		}
 	}
 
-	// Industry benchmark targets require high throughput
-	// Minimum concurrency for performance: 20
-	if optimalConcurrency < 20 {
-		optimalConcurrency = 20
+	// No need for more workers than tags
+	if concurrency > tagCount {
+		concurrency = tagCount
 	}
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	return concurrency
+}
 
-	// Maximum concurrency to prevent registry overload: 100
-	if optimalConcurrency > 100 {
-		optimalConcurrency = 100
+// acquireTransferSlot blocks until a global transfer slot is free and returns
+// the function that releases it
+func (t *TreeReplicator) acquireTransferSlot(ctx context.Context) (func(), error) {
+	if t.transferSlots == nil {
+		return func() {}, nil
 	}
 
-	return optimalConcurrency
+	select {
+	case t.transferSlots <- struct{}{}:
+		return func() { <-t.transferSlots }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
 }
 
 // replicateTagWithMetrics handles tag replication with performance metrics
@@ -1128,10 +1451,17 @@ func (t *TreeReplicator) replicateTag(
 		ForceOverwrite: opts.ForceOverwrite,
 		Source:         sourceRef,
 		Destination:    destRef,
+		Annotations:    opts.Annotations,
 	}
 
 	// Use the copy package to perform the actual image copying
-	copier := copy.NewCopier(t.logger)
+	// The shared copier is immutable; copies of this run also share its dedup
+	copierOpts := copy.CopierOptions{}
+	if t.copier != nil {
+		copierOpts = t.copier.Options()
+	}
+	copierOpts.Dedup = opts.Dedup
+	copier := copy.NewCopier(t.logger, copierOpts)
 	result, err := copier.CopyImage(opts.Context, sourceRef, destRef, srcOpts, destOpts, copyOptions)
 	if err != nil {
 		return errors.Wrap(err, "failed to copy image")
@@ -1152,14 +1482,77 @@ func (t *TreeReplicator) replicateTag(
 	return nil
 }
 
+// markTagCompleted records a copied tag in the checkpoint so a resumed run
+// can skip it
+func (t *TreeReplicator) markTagCompleted(opts repositoryProcessOptions, tag string) {
+	if !t.checkpointing.Enabled || t.checkpointStore == nil || opts.TreeCheckpoint == nil || t.dryRun {
+		return
+	}
+
+	t.checkpointMu.Lock()
+	defer t.checkpointMu.Unlock()
+
+	repo, ok := opts.TreeCheckpoint.Repositories[opts.SourceRepo]
+	if !ok || slices.Contains(repo.CompletedTags, tag) {
+		return
+	}
+	repo.CompletedTags = append(repo.CompletedTags, tag)
+	repo.LastUpdated = time.Now()
+	opts.TreeCheckpoint.Repositories[opts.SourceRepo] = repo
+
+	if err := t.checkpointStore.SaveCheckpoint(opts.TreeCheckpoint); err != nil {
+		t.logger.WithFields(map[string]interface{}{
+			"checkpoint_id": opts.TreeCheckpoint.ID,
+			"source_repo":   opts.SourceRepo,
+			"tag":           tag,
+			"error":         err.Error(),
+		}).Warn("Failed to save tag checkpoint")
+	}
+}
+
+// markRepositoryFailed records a repository failure, or an interruption when
+// the run was canceled, so a resumed run can retry it
+func (t *TreeReplicator) markRepositoryFailed(opts repositoryProcessOptions, err error) {
+	if !t.checkpointing.Enabled || t.checkpointStore == nil || opts.TreeCheckpoint == nil {
+		return
+	}
+
+	t.checkpointMu.Lock()
+	defer t.checkpointMu.Unlock()
+
+	repo, ok := opts.TreeCheckpoint.Repositories[opts.SourceRepo]
+	if !ok {
+		return
+	}
+	if opts.Context != nil && opts.Context.Err() != nil {
+		repo.Status = checkpoint.StatusInterrupted
+	} else {
+		repo.Status = checkpoint.StatusFailed
+		repo.Error = err.Error()
+	}
+	repo.LastUpdated = time.Now()
+	opts.TreeCheckpoint.Repositories[opts.SourceRepo] = repo
+
+	if saveErr := t.checkpointStore.SaveCheckpoint(opts.TreeCheckpoint); saveErr != nil {
+		t.logger.WithFields(map[string]interface{}{
+			"checkpoint_id": opts.TreeCheckpoint.ID,
+			"source_repo":   opts.SourceRepo,
+			"error":         saveErr.Error(),
+		}).Warn("Failed to save failure checkpoint")
+	}
+}
+
 // markRepositoryCompleted updates checkpoint to mark repository as completed
 func (t *TreeReplicator) markRepositoryCompleted(opts repositoryProcessOptions) {
 	if t.checkpointing.Enabled && t.checkpointStore != nil && opts.TreeCheckpoint != nil {
 		t.checkpointMu.Lock()
 		if repo, ok := opts.TreeCheckpoint.Repositories[opts.SourceRepo]; ok {
 			repo.Status = checkpoint.StatusCompleted
+			repo.LastUpdated = time.Now()
 			opts.TreeCheckpoint.Repositories[opts.SourceRepo] = repo
-			opts.TreeCheckpoint.CompletedRepositories = append(opts.TreeCheckpoint.CompletedRepositories, opts.SourceRepo)
+			if !slices.Contains(opts.TreeCheckpoint.CompletedRepositories, opts.SourceRepo) {
+				opts.TreeCheckpoint.CompletedRepositories = append(opts.TreeCheckpoint.CompletedRepositories, opts.SourceRepo)
+			}
 		}
 
 		// Save checkpoint while still holding the lock to prevent concurrent access during serialization
@@ -1198,6 +1591,7 @@ func (t *TreeReplicator) handleError(err error, treeCheckpoint *checkpoint.TreeC
 				"id":             treeCheckpoint.ID,
 			}).Warn("Failed to save error checkpoint")
 		}
+		t.flushCheckpoints(treeCheckpoint)
 	}
 }
 
@@ -1217,6 +1611,21 @@ func (t *TreeReplicator) completeReplication(treeCheckpoint *checkpoint.TreeChec
 				"status":        status,
 			}).Warn(wrappedErr.Error())
 		}
+		t.flushCheckpoints(treeCheckpoint)
+	}
+}
+
+// flushCheckpoints waits for background checkpoint writes so the final state
+// is on disk when the run returns
+func (t *TreeReplicator) flushCheckpoints(treeCheckpoint *checkpoint.TreeCheckpoint) {
+	if t.checkpointWriter == nil {
+		return
+	}
+	if err := t.checkpointWriter.Flush(t.checkpointTimeout); err != nil {
+		t.logger.WithFields(map[string]interface{}{
+			"checkpoint_id": treeCheckpoint.ID,
+			"error":         err.Error(),
+		}).Warn("Checkpoint may not reflect the end of the run")
 	}
 }
 
@@ -454,3 +454,90 @@ func TestReplicateTreeWithFilters(t *testing.T) {
 	// In a real implementation, we would check which tags were replicated
 	// But since our mock doesn't fully implement the filtering, we only check repository count
 }
+
+func TestCalculateOptimalTagConcurrency(t *testing.T) {
+	logger := log.NewBasicLogger(log.InfoLevel)
+	copier := copy.NewCopier(logger, copy.CopierOptions{})
+
+	configured := NewTreeReplicator(logger, copier, TreeReplicatorOptions{WorkerCount: 4, TagWorkerCount: 3})
+	if got := configured.calculateOptimalTagConcurrency(50); got != 3 {
+		t.Errorf("Expected configured tag concurrency 3, got %d", got)
+	}
+	if got := configured.calculateOptimalTagConcurrency(2); got != 2 {
+		t.Errorf("Expected tag concurrency capped at tag count 2, got %d", got)
+	}
+
+	auto := NewTreeReplicator(logger, copier, TreeReplicatorOptions{WorkerCount: 4})
+	if got := auto.calculateOptimalTagConcurrency(1000); got < 1 || got > 100 {
+		t.Errorf("Expected automatic tag concurrency between 1 and 100, got %d", got)
+	}
+	if got := auto.calculateOptimalTagConcurrency(0); got != 1 {
+		t.Errorf("Expected minimum tag concurrency 1, got %d", got)
+	}
+}
+
+func TestAcquireTransferSlot(t *testing.T) {
+	logger := log.NewBasicLogger(log.InfoLevel)
+	replicator := NewTreeReplicator(logger, copy.NewCopier(logger, copy.CopierOptions{}), TreeReplicatorOptions{
+		WorkerCount:  2,
+		MaxTransfers: 1,
+	})
+
+	release, err := replicator.acquireTransferSlot(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to acquire transfer slot: %v", err)
+	}
+
+	// A second transfer waits until the first releases its slot
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := replicator.acquireTransferSlot(ctx); err == nil {
+		t.Error("Expected error acquiring a transfer slot while all slots are in use")
+	}
+
+	release()
+	release, err = replicator.acquireTransferSlot(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to acquire released transfer slot: %v", err)
+	}
+	release()
+
+	unlimited := NewTreeReplicator(logger, copy.NewCopier(logger, copy.CopierOptions{}), TreeReplicatorOptions{WorkerCount: 2})
+	if unlimited.transferSlots != nil {
+		t.Error("Expected no transfer limit when MaxTransfers is 0")
+	}
+}
+
+func TestExplainTag(t *testing.T) {
+	exclude := newPatternCache([]string{"*-debug"})
+	include := newPatternCache([]string{"v1.*", "latest"})
+
+	tests := []struct {
+		tag      string
+		included bool
+		rule     string
+		pattern  string
+	}{
+		{tag: "v1.2-debug", included: false, rule: "exclude-tag", pattern: "*-debug"},
+		{tag: "v1.2", included: true, rule: "include-tag", pattern: "v1.*"},
+		{tag: "latest", included: true, rule: "include-tag", pattern: "latest"},
+		{tag: "v2.0", included: false, rule: "include-tag", pattern: "v1.*,latest"},
+	}
+
+	for _, tt := range tests {
+		decision := explainTag(tt.tag, exclude, include)
+		if decision.included != tt.included || decision.rule != tt.rule || decision.pattern != tt.pattern {
+			t.Errorf("explainTag(%q) = %+v, want included=%v rule=%q pattern=%q", tt.tag, decision, tt.included, tt.rule, tt.pattern)
+		}
+		if got := isTagIncluded(tt.tag, exclude, include, []string{"v1.*", "latest"}); got != decision.included {
+			t.Errorf("explainTag(%q) disagrees with isTagIncluded: %v", tt.tag, got)
+		}
+	}
+
+	if decision := explainTag("anything", nil, nil); !decision.included || decision.rule != "" {
+		t.Errorf("Expected tag included without a rule when no filters are set, got %+v", decision)
+	}
+	if decision := explainRepository("team/legacy", newPatternCache([]string{"*/legacy"})); decision.included || decision.pattern != "*/legacy" {
+		t.Errorf("Expected repository excluded by */legacy, got %+v", decision)
+	}
+}
@@ -49,7 +49,7 @@ func setupResumeTestEnvironment(t *testing.T) (*TreeReplicator, *MockRegistryCli
 	logger := log.NewBasicLogger(log.InfoLevel)
 
 	// Create copier
-	copier := copy.NewCopier(logger)
+	copier := copy.NewCopier(logger, copy.CopierOptions{})
 
 	// Create replicator with checkpointing enabled
 	replicator := NewTreeReplicator(logger, copier, TreeReplicatorOptions{
@@ -139,6 +139,110 @@ func TestResumeTreeReplication(t *testing.T) {
 	}
 }
 
+func TestResumeTreeReplicationContinuesCheckpoint(t *testing.T) {
+	replicator, sourceRegistry, destRegistry := setupResumeTestEnvironment(t)
+
+	saved := &checkpoint.TreeCheckpoint{
+		ID:           "resume-remaining",
+		SourcePrefix: "project",
+		DestPrefix:   "mirror/project",
+		Status:       checkpoint.StatusInterrupted,
+		Repositories: map[string]checkpoint.RepoStatus{
+			"project/repo1": {Status: checkpoint.StatusCompleted},
+			"project/repo2": {Status: checkpoint.StatusFailed, Error: "boom"},
+			"project/repo3": {Status: checkpoint.StatusInterrupted, CompletedTags: []string{"v3.0"}},
+		},
+		CompletedRepositories: []string{"project/repo1"},
+	}
+	if err := replicator.checkpointStore.SaveCheckpoint(saved); err != nil {
+		t.Fatalf("Failed to save checkpoint: %v", err)
+	}
+
+	result, err := replicator.ResumeTreeReplication(
+		context.Background(),
+		sourceRegistry,
+		destRegistry,
+		ResumeOptions{
+			CheckpointID:  saved.ID,
+			SkipCompleted: true,
+			RetryFailed:   false,
+		},
+	)
+	if err != nil {
+		t.Fatalf("ResumeTreeReplication failed: %v", err)
+	}
+
+	if !result.Resumed || result.CheckpointID != saved.ID {
+		t.Errorf("Expected resumed result for checkpoint %s, got resumed=%v id=%s", saved.ID, result.Resumed, result.CheckpointID)
+	}
+	if result.Repositories != 1 {
+		t.Errorf("Expected 1 repository to be resumed, got %d", result.Repositories)
+	}
+	if skipped := result.ImagesSkipped.Load(); skipped != 1 {
+		t.Errorf("Expected the completed tag to be skipped, got %d skipped", skipped)
+	}
+
+	// Only the interrupted repository is processed
+	destRepos, _ := destRegistry.ListRepositories(context.Background(), "")
+	if len(destRepos) != 1 || destRepos[0] != "mirror/project/repo3" {
+		t.Errorf("Expected only mirror/project/repo3 in destination, got %v", destRepos)
+	}
+
+	reloaded, err := replicator.checkpointStore.LoadCheckpoint(saved.ID)
+	if err != nil {
+		t.Fatalf("Failed to reload checkpoint: %v", err)
+	}
+	if reloaded.Status != checkpoint.StatusCompleted {
+		t.Errorf("Expected checkpoint status %s, got %s", checkpoint.StatusCompleted, reloaded.Status)
+	}
+	if got := reloaded.Repositories["project/repo3"]; got.Status != checkpoint.StatusCompleted || len(got.CompletedTags) != 1 {
+		t.Errorf("Expected project/repo3 completed with its tag kept, got %+v", got)
+	}
+	if got := reloaded.Repositories["project/repo2"].Status; got != checkpoint.StatusFailed {
+		t.Errorf("Expected project/repo2 to stay failed, got %s", got)
+	}
+}
+
+func TestReplicateTreeWithRepositoryList(t *testing.T) {
+	replicator, sourceRegistry, destRegistry := setupResumeTestEnvironment(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, _ := replicator.ReplicateTree(ctx, ReplicateTreeOptions{
+		SourceClient: sourceRegistry,
+		DestClient:   destRegistry,
+		SourcePrefix: "project",
+		DestPrefix:   "mirror/project",
+		Repositories: []string{"project/repo2"},
+	})
+	if result == nil {
+		t.Fatalf("Expected result to be returned")
+	}
+	if result.Repositories != 1 {
+		t.Errorf("Expected 1 repository, got %d", result.Repositories)
+	}
+
+	destRepos, _ := destRegistry.ListRepositories(context.Background(), "")
+	if len(destRepos) != 1 || destRepos[0] != "mirror/project/repo2" {
+		t.Errorf("Expected only mirror/project/repo2 in destination, got %v", destRepos)
+	}
+}
+
+func TestReplicateTreeResumeRequiresCheckpointing(t *testing.T) {
+	logger := log.NewBasicLogger(log.InfoLevel)
+	replicator := NewTreeReplicator(logger, copy.NewCopier(logger, copy.CopierOptions{}), TreeReplicatorOptions{})
+
+	_, err := replicator.ReplicateTree(context.Background(), ReplicateTreeOptions{
+		SourceClient:         &MockRegistryClient{},
+		DestClient:           &MockRegistryClient{},
+		ResumeFromCheckpoint: "missing",
+	})
+	if err == nil {
+		t.Fatal("Expected an error when resuming without checkpointing")
+	}
+}
+
 func TestListResumableReplications(t *testing.T) {
 	replicator, sourceRegistry, destRegistry := setupResumeTestEnvironment(t)
 
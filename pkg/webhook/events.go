@@ -0,0 +1,165 @@
+package webhook
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+
+	"freightliner/pkg/helper/errors"
+
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+// Payload formats of registry push webhooks
+const (
+	// FormatECR is an Amazon ECR "ECR Image Action" event delivered by an
+	// EventBridge API destination
+	FormatECR = "ecr"
+
+	// FormatGCR is a Container Registry or Artifact Registry notification
+	// delivered by a Pub/Sub push subscription
+	FormatGCR = "gcr"
+
+	// FormatRegistry is a Docker Distribution (registry:2, Harbor, Quay and
+	// others) notification envelope
+	FormatRegistry = "registry"
+)
+
+// Formats lists the supported payload formats
+var Formats = []string{FormatECR, FormatGCR, FormatRegistry}
+
+// PushEvent is a tag pushed to a registry
+type PushEvent struct {
+	Repository string `json:"repository"`
+	Tag        string `json:"tag"`
+	Digest     string `json:"digest,omitempty"`
+}
+
+// ParsePushEvents returns the tag pushes in a webhook payload of format.
+// Events that are not successful tag pushes, such as deletes, pulls and
+// pushes by digest only, are left out.
+func ParsePushEvents(format string, payload []byte) ([]PushEvent, error) {
+	switch format {
+	case FormatECR:
+		return parseECR(payload)
+	case FormatGCR:
+		return parseGCR(payload)
+	case FormatRegistry:
+		return parseRegistry(payload)
+	}
+	return nil, errors.InvalidInputf("unsupported webhook format %q (must be one of: %s)", format, strings.Join(Formats, ", "))
+}
+
+// ecrEvent is the EventBridge event ECR emits for image actions
+type ecrEvent struct {
+	Source     string `json:"source"`
+	DetailType string `json:"detail-type"`
+	Detail     struct {
+		Result         string `json:"result"`
+		ActionType     string `json:"action-type"`
+		RepositoryName string `json:"repository-name"`
+		ImageTag       string `json:"image-tag"`
+		ImageDigest    string `json:"image-digest"`
+	} `json:"detail"`
+}
+
+// parseECR reads a successful PUSH "ECR Image Action" event
+func parseECR(payload []byte) ([]PushEvent, error) {
+	var event ecrEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, errors.InvalidInputf("invalid ECR event: %s", err)
+	}
+	if event.Source != "aws.ecr" || event.DetailType != "ECR Image Action" {
+		return nil, errors.InvalidInputf("not an ECR image action event (source %q, detail-type %q)", event.Source, event.DetailType)
+	}
+
+	d := event.Detail
+	if d.ActionType != "PUSH" || d.Result != "SUCCESS" || d.ImageTag == "" {
+		return nil, nil
+	}
+	return []PushEvent{{Repository: d.RepositoryName, Tag: d.ImageTag, Digest: d.ImageDigest}}, nil
+}
+
+// pubsubPush is the body of a Pub/Sub push subscription request
+type pubsubPush struct {
+	Message struct {
+		Data string `json:"data"`
+	} `json:"message"`
+}
+
+// gcrNotification is the message Container Registry and Artifact Registry
+// publish to the gcr topic
+type gcrNotification struct {
+	Action string `json:"action"`
+	Digest string `json:"digest"`
+	Tag    string `json:"tag"`
+}
+
+// parseGCR reads an INSERT notification for a tag. Repositories are named
+// without the registry host and project, as the gcr client names them.
+func parseGCR(payload []byte) ([]PushEvent, error) {
+	var push pubsubPush
+	if err := json.Unmarshal(payload, &push); err != nil {
+		return nil, errors.InvalidInputf("invalid Pub/Sub push: %s", err)
+	}
+	data, err := base64.StdEncoding.DecodeString(push.Message.Data)
+	if err != nil {
+		return nil, errors.InvalidInputf("invalid Pub/Sub message data: %s", err)
+	}
+	var notification gcrNotification
+	if err := json.Unmarshal(data, &notification); err != nil {
+		return nil, errors.InvalidInputf("invalid GCR notification: %s", err)
+	}
+
+	if notification.Action != "INSERT" || notification.Tag == "" {
+		return nil, nil
+	}
+	tag, err := name.NewTag(notification.Tag)
+	if err != nil {
+		return nil, errors.InvalidInputf("invalid GCR notification tag %q: %s", notification.Tag, err)
+	}
+	_, repository, ok := strings.Cut(tag.RepositoryStr(), "/")
+	if !ok {
+		return nil, errors.InvalidInputf("GCR notification tag %q has no project", notification.Tag)
+	}
+
+	event := PushEvent{Repository: repository, Tag: tag.TagStr()}
+	if _, digest, ok := strings.Cut(notification.Digest, "@"); ok {
+		event.Digest = digest
+	}
+	return []PushEvent{event}, nil
+}
+
+// registryEnvelope is a Docker Distribution notification envelope
+type registryEnvelope struct {
+	Events []struct {
+		Action string `json:"action"`
+		Target struct {
+			Repository string `json:"repository"`
+			Tag        string `json:"tag"`
+			Digest     string `json:"digest"`
+		} `json:"target"`
+	} `json:"events"`
+}
+
+// parseRegistry reads the manifest push events of an envelope, each tag once
+func parseRegistry(payload []byte) ([]PushEvent, error) {
+	var envelope registryEnvelope
+	if err := json.Unmarshal(payload, &envelope); err != nil {
+		return nil, errors.InvalidInputf("invalid registry notification: %s", err)
+	}
+
+	var events []PushEvent
+	seen := make(map[PushEvent]bool)
+	for _, e := range envelope.Events {
+		if e.Action != "push" || e.Target.Tag == "" {
+			continue
+		}
+		event := PushEvent{Repository: e.Target.Repository, Tag: e.Target.Tag, Digest: e.Target.Digest}
+		if !seen[event] {
+			seen[event] = true
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
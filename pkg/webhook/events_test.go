@@ -0,0 +1,85 @@
+package webhook
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"freightliner/pkg/helper/errors"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePushEventsECR(t *testing.T) {
+	event := func(action, result, tag string) string {
+		return `{"version":"0","source":"aws.ecr","detail-type":"ECR Image Action","region":"us-east-1",
+			"detail":{"result":"` + result + `","repository-name":"team/app","image-digest":"sha256:abc",
+			"action-type":"` + action + `","image-tag":"` + tag + `"}}`
+	}
+
+	events, err := ParsePushEvents(FormatECR, []byte(event("PUSH", "SUCCESS", "v1.2.0")))
+	require.NoError(t, err)
+	assert.Equal(t, []PushEvent{{Repository: "team/app", Tag: "v1.2.0", Digest: "sha256:abc"}}, events)
+
+	for name, payload := range map[string]string{
+		"delete":         event("DELETE", "SUCCESS", "v1.2.0"),
+		"failed push":    event("PUSH", "FAILURE", "v1.2.0"),
+		"push by digest": event("PUSH", "SUCCESS", ""),
+	} {
+		events, err := ParsePushEvents(FormatECR, []byte(payload))
+		require.NoError(t, err, name)
+		assert.Empty(t, events, name)
+	}
+
+	_, err = ParsePushEvents(FormatECR, []byte(`{"source":"aws.s3","detail-type":"Object Created"}`))
+	assert.True(t, errors.Is(err, errors.ErrInvalidInput), "other EventBridge events are rejected")
+}
+
+func TestParsePushEventsGCR(t *testing.T) {
+	push := func(notification string) []byte {
+		data := base64.StdEncoding.EncodeToString([]byte(notification))
+		return []byte(`{"message":{"data":"` + data + `","messageId":"1"},"subscription":"projects/p/subscriptions/s"}`)
+	}
+
+	events, err := ParsePushEvents(FormatGCR, push(`{"action":"INSERT",
+		"digest":"gcr.io/my-project/team/app@sha256:abc","tag":"gcr.io/my-project/team/app:v1"}`))
+	require.NoError(t, err)
+	assert.Equal(t, []PushEvent{{Repository: "team/app", Tag: "v1", Digest: "sha256:abc"}}, events,
+		"repositories are named without the project")
+
+	events, err = ParsePushEvents(FormatGCR, push(`{"action":"INSERT","digest":"gcr.io/my-project/app@sha256:abc"}`))
+	require.NoError(t, err)
+	assert.Empty(t, events, "pushes by digest are left out")
+
+	events, err = ParsePushEvents(FormatGCR, push(`{"action":"DELETE","tag":"gcr.io/my-project/app:v1"}`))
+	require.NoError(t, err)
+	assert.Empty(t, events)
+
+	_, err = ParsePushEvents(FormatGCR, []byte(`{"message":{"data":"not base64!"}}`))
+	assert.Error(t, err)
+}
+
+func TestParsePushEventsRegistry(t *testing.T) {
+	payload := `{"events":[
+		{"action":"push","target":{"mediaType":"application/vnd.oci.image.manifest.v1+json","repository":"library/nginx","digest":"sha256:abc","tag":"1.27"}},
+		{"action":"push","target":{"mediaType":"application/vnd.oci.image.manifest.v1+json","repository":"library/nginx","digest":"sha256:abc","tag":"1.27"}},
+		{"action":"push","target":{"mediaType":"application/octet-stream","repository":"library/nginx","digest":"sha256:layer"}},
+		{"action":"pull","target":{"repository":"library/nginx","digest":"sha256:abc","tag":"1.27"}},
+		{"action":"push","target":{"repository":"library/redis","digest":"sha256:def","tag":"7"}}
+	]}`
+
+	events, err := ParsePushEvents(FormatRegistry, []byte(payload))
+	require.NoError(t, err)
+	assert.Equal(t, []PushEvent{
+		{Repository: "library/nginx", Tag: "1.27", Digest: "sha256:abc"},
+		{Repository: "library/redis", Tag: "7", Digest: "sha256:def"},
+	}, events, "blob pushes, pulls and repeated deliveries are left out")
+
+	_, err = ParsePushEvents(FormatRegistry, []byte("not json"))
+	assert.Error(t, err)
+}
+
+func TestParsePushEventsUnknownFormat(t *testing.T) {
+	_, err := ParsePushEvents("harbor", []byte("{}"))
+	assert.True(t, errors.Is(err, errors.ErrInvalidInput))
+}
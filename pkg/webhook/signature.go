@@ -0,0 +1,311 @@
+// Package webhook signs the webhook notifications freightliner sends and
+// verifies the signed webhooks it receives.
+//
+// A signed request carries the Unix time it was signed in the
+// X-Freightliner-Timestamp header and one or more signatures in the
+// X-Freightliner-Signature header. A signature covers the timestamp, HTTP
+// method, request path with its query string and body, joined by newlines,
+// so a signed request cannot be replayed against another endpoint:
+//
+//	<timestamp>\n<METHOD>\n<path?query>\n<body>
+//
+// The signatures are comma-separated scheme=value pairs:
+//
+//	hmac-sha256=<hex HMAC-SHA256 with a shared secret>
+//	sig=<base64 Ed25519, ECDSA or RSA PKCS#1 v1.5 signature of the SHA-256 digest>
+//
+// Receivers accept a request when any signature matches, so keys can be
+// rotated by signing with both for a while.
+package webhook
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"freightliner/pkg/helper/errors"
+)
+
+const (
+	// HeaderTimestamp holds the Unix time a request was signed
+	HeaderTimestamp = "X-Freightliner-Timestamp"
+
+	// HeaderSignature holds the request's signatures
+	HeaderSignature = "X-Freightliner-Signature"
+
+	// SchemeHMAC signs with an HMAC-SHA256 shared secret
+	SchemeHMAC = "hmac-sha256"
+
+	// SchemeKey signs with an asymmetric private key
+	SchemeKey = "sig"
+
+	// DefaultMaxAge is how old a signature may be before it is rejected as a
+	// possible replay
+	DefaultMaxAge = 5 * time.Minute
+
+	// maxBodySize bounds the request bodies read for verification
+	maxBodySize = 10 << 20
+)
+
+// Signer signs outgoing webhook requests
+type Signer struct {
+	secret []byte
+	key    crypto.Signer
+	now    func() time.Time
+}
+
+// NewSigner returns a signer using secret, key or both. It returns nil when
+// neither is set, which signs nothing.
+func NewSigner(secret string, key crypto.Signer) *Signer {
+	if secret == "" && key == nil {
+		return nil
+	}
+	return &Signer{secret: []byte(secret), key: key, now: time.Now}
+}
+
+// LoadSigner returns a signer using secret and the PEM private key at
+// keyFile; either may be empty
+func LoadSigner(secret, keyFile string) (*Signer, error) {
+	var key crypto.Signer
+	if keyFile != "" {
+		var err error
+		if key, err = loadPrivateKey(keyFile); err != nil {
+			return nil, err
+		}
+	}
+	return NewSigner(secret, key), nil
+}
+
+// Sign sets the timestamp and signature headers of req for its method, URL
+// and body. A nil signer leaves req unsigned.
+func (s *Signer) Sign(req *http.Request, body []byte) error {
+	if s == nil {
+		return nil
+	}
+
+	timestamp := strconv.FormatInt(s.now().Unix(), 10)
+	message := signedMessage(timestamp, req.Method, req.URL.RequestURI(), body)
+
+	var signatures []string
+	if len(s.secret) > 0 {
+		signatures = append(signatures, SchemeHMAC+"="+hex.EncodeToString(hmacSum(s.secret, message)))
+	}
+	if s.key != nil {
+		sig, err := signMessage(s.key, message)
+		if err != nil {
+			return errors.Wrap(err, "failed to sign webhook")
+		}
+		signatures = append(signatures, SchemeKey+"="+base64.StdEncoding.EncodeToString(sig))
+	}
+
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, strings.Join(signatures, ","))
+	return nil
+}
+
+// Verifier checks the signatures of incoming webhook requests
+type Verifier struct {
+	secret []byte
+	key    crypto.PublicKey
+	maxAge time.Duration
+	now    func() time.Time
+}
+
+// NewVerifier returns a verifier accepting signatures made with secret or
+// the private key of key, no older than maxAge (zero uses DefaultMaxAge). It
+// returns nil when neither secret nor key is set.
+func NewVerifier(secret string, key crypto.PublicKey, maxAge time.Duration) *Verifier {
+	if secret == "" && key == nil {
+		return nil
+	}
+	if maxAge <= 0 {
+		maxAge = DefaultMaxAge
+	}
+	return &Verifier{secret: []byte(secret), key: key, maxAge: maxAge, now: time.Now}
+}
+
+// LoadVerifier returns a verifier using secret and the PEM public key at
+// keyFile; either may be empty
+func LoadVerifier(secret, keyFile string, maxAge time.Duration) (*Verifier, error) {
+	var key crypto.PublicKey
+	if keyFile != "" {
+		var err error
+		if key, err = loadPublicKey(keyFile); err != nil {
+			return nil, err
+		}
+	}
+	return NewVerifier(secret, key, maxAge), nil
+}
+
+// Verify checks that r carries a fresh signature of its method, URL and body
+// made with the verifier's secret or key. Failures wrap
+// errors.ErrUnauthorized.
+func (v *Verifier) Verify(r *http.Request, body []byte) error {
+	timestamp := r.Header.Get(HeaderTimestamp)
+	signatures := r.Header.Get(HeaderSignature)
+	if timestamp == "" || signatures == "" {
+		return errors.Unauthorizedf("webhook is not signed")
+	}
+
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return errors.Unauthorizedf("invalid webhook timestamp %q", timestamp)
+	}
+	age := v.now().Sub(time.Unix(seconds, 0))
+	if age > v.maxAge || age < -v.maxAge {
+		return errors.Unauthorizedf("webhook timestamp is %s from now, more than %s", age.Round(time.Second), v.maxAge)
+	}
+
+	message := signedMessage(timestamp, r.Method, r.URL.RequestURI(), body)
+	for _, signature := range strings.Split(signatures, ",") {
+		scheme, value, ok := strings.Cut(strings.TrimSpace(signature), "=")
+		if !ok {
+			continue
+		}
+		switch scheme {
+		case SchemeHMAC:
+			sum, err := hex.DecodeString(value)
+			if err == nil && len(v.secret) > 0 && hmac.Equal(sum, hmacSum(v.secret, message)) {
+				return nil
+			}
+		case SchemeKey:
+			sig, err := base64.StdEncoding.DecodeString(value)
+			if err == nil && v.key != nil && verifyMessage(v.key, message, sig) {
+				return nil
+			}
+		}
+	}
+	return errors.Unauthorizedf("webhook signature does not match")
+}
+
+// VerifyRequest verifies the signature of r and restores its body for the
+// next reader
+func (v *Verifier) VerifyRequest(r *http.Request) error {
+	var body []byte
+	if r.Body != nil {
+		var err error
+		body, err = io.ReadAll(io.LimitReader(r.Body, maxBodySize))
+		_ = r.Body.Close()
+		if err != nil {
+			return errors.Wrap(err, "failed to read webhook body")
+		}
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	return v.Verify(r, body)
+}
+
+// signedMessage returns the bytes a signature covers
+func signedMessage(timestamp, method, requestURI string, body []byte) []byte {
+	message := make([]byte, 0, len(timestamp)+len(method)+len(requestURI)+3+len(body))
+	for _, field := range []string{timestamp, method, requestURI} {
+		message = append(message, field...)
+		message = append(message, '\n')
+	}
+	return append(message, body...)
+}
+
+// hmacSum returns the HMAC-SHA256 of message
+func hmacSum(secret, message []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(message)
+	return mac.Sum(nil)
+}
+
+// signMessage signs message with key; Ed25519 signs the message itself,
+// other keys its SHA-256 digest
+func signMessage(key crypto.Signer, message []byte) ([]byte, error) {
+	if _, ok := key.Public().(ed25519.PublicKey); ok {
+		return key.Sign(rand.Reader, message, crypto.Hash(0))
+	}
+	digest := sha256.Sum256(message)
+	return key.Sign(rand.Reader, digest[:], crypto.SHA256)
+}
+
+// verifyMessage reports whether sig is key's signature of message
+func verifyMessage(key crypto.PublicKey, message, sig []byte) bool {
+	digest := sha256.Sum256(message)
+	switch key := key.(type) {
+	case ed25519.PublicKey:
+		return ed25519.Verify(key, message, sig)
+	case *ecdsa.PublicKey:
+		return ecdsa.VerifyASN1(key, digest[:], sig)
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig) == nil
+	}
+	return false
+}
+
+// loadPrivateKey reads an unencrypted PEM private key (PKCS#8, SEC 1 or PKCS#1)
+func loadPrivateKey(path string) (crypto.Signer, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var key interface{}
+	switch block.Type {
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse webhook signing key %s", path)
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, errors.InvalidInputf("webhook signing key %s cannot sign", path)
+	}
+	return signer, nil
+}
+
+// loadPublicKey reads a PEM public key in PKIX form
+func loadPublicKey(path string) (crypto.PublicKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse webhook verification key %s", path)
+	}
+	switch key.(type) {
+	case ed25519.PublicKey, *ecdsa.PublicKey, *rsa.PublicKey:
+		return key, nil
+	}
+	return nil, errors.InvalidInputf("unsupported webhook verification key type %T", key)
+}
+
+// readPEM reads the first PEM block of path
+func readPEM(path string) (*pem.Block, error) {
+	data, err := os.ReadFile(path) // #nosec G304 - key path comes from the operator
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read webhook key")
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.InvalidInputf("webhook key %s is not PEM encoded", path)
+	}
+	if strings.Contains(block.Type, "ENCRYPTED") {
+		return nil, errors.InvalidInputf("webhook key %s is encrypted; provide an unencrypted key", path)
+	}
+	return block, nil
+}
@@ -0,0 +1,172 @@
+package webhook
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"freightliner/pkg/helper/errors"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// signedRequest returns a request for body signed by signer at now
+func signedRequest(t *testing.T, signer *Signer, now time.Time, body string) *http.Request {
+	t.Helper()
+	signer.now = func() time.Time { return now }
+	req := httptest.NewRequest("POST", "/hook", strings.NewReader(body))
+	require.NoError(t, signer.Sign(req, []byte(body)))
+	return req
+}
+
+func TestSignVerifyHMAC(t *testing.T) {
+	now := time.Unix(1760000000, 0)
+	verifier := NewVerifier("s3cret", nil, 0)
+	verifier.now = func() time.Time { return now }
+
+	req := signedRequest(t, NewSigner("s3cret", nil), now, `{"rule":"mirror/app"}`)
+	assert.Equal(t, "1760000000", req.Header.Get(HeaderTimestamp))
+	assert.True(t, strings.HasPrefix(req.Header.Get(HeaderSignature), SchemeHMAC+"="))
+	require.NoError(t, verifier.Verify(req, []byte(`{"rule":"mirror/app"}`)))
+
+	tests := []struct {
+		name   string
+		tamper func(r *http.Request)
+		body   string
+	}{
+		{"tampered body", func(r *http.Request) {}, `{"rule":"mirror/other"}`},
+		{"wrong secret", func(r *http.Request) {
+			other := signedRequest(t, NewSigner("other", nil), now, `{"rule":"mirror/app"}`)
+			r.Header.Set(HeaderSignature, other.Header.Get(HeaderSignature))
+		}, `{"rule":"mirror/app"}`},
+		{"missing signature", func(r *http.Request) { r.Header.Del(HeaderSignature) }, `{"rule":"mirror/app"}`},
+		{"moved timestamp", func(r *http.Request) { r.Header.Set(HeaderTimestamp, "1760000001") }, `{"rule":"mirror/app"}`},
+		{"other method", func(r *http.Request) { r.Method = http.MethodPut }, `{"rule":"mirror/app"}`},
+		{"other path", func(r *http.Request) { r.URL.Path = "/api/v1/replicate" }, `{"rule":"mirror/app"}`},
+		{"added query", func(r *http.Request) { r.URL.RawQuery = "force=true" }, `{"rule":"mirror/app"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tampered := req.Clone(context.Background())
+			tt.tamper(tampered)
+			err := verifier.Verify(tampered, []byte(tt.body))
+			require.Error(t, err)
+			assert.True(t, errors.Is(err, errors.ErrUnauthorized))
+		})
+	}
+}
+
+func TestVerifyRejectsStaleSignatures(t *testing.T) {
+	signedAt := time.Unix(1760000000, 0)
+	req := signedRequest(t, NewSigner("s3cret", nil), signedAt, "{}")
+
+	verifier := NewVerifier("s3cret", nil, time.Minute)
+	verifier.now = func() time.Time { return signedAt.Add(59 * time.Second) }
+	assert.NoError(t, verifier.Verify(req, []byte("{}")))
+
+	verifier.now = func() time.Time { return signedAt.Add(2 * time.Minute) }
+	assert.Error(t, verifier.Verify(req, []byte("{}")), "a replayed request is too old")
+
+	verifier.now = func() time.Time { return signedAt.Add(-2 * time.Minute) }
+	assert.Error(t, verifier.Verify(req, []byte("{}")), "a timestamp far in the future is rejected")
+}
+
+func TestSignVerifyKeys(t *testing.T) {
+	edPublic, edPrivate, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	ecPrivate, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	writeKey := func(name, blockType string, der []byte) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0600))
+		return path
+	}
+
+	for _, tt := range []struct {
+		name    string
+		private interface{}
+		public  interface{}
+	}{
+		{"ed25519", edPrivate, edPublic},
+		{"ecdsa", ecPrivate, &ecPrivate.PublicKey},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			privateDER, err := x509.MarshalPKCS8PrivateKey(tt.private)
+			require.NoError(t, err)
+			publicDER, err := x509.MarshalPKIXPublicKey(tt.public)
+			require.NoError(t, err)
+
+			signer, err := LoadSigner("", writeKey(tt.name+".key", "PRIVATE KEY", privateDER))
+			require.NoError(t, err)
+			verifier, err := LoadVerifier("", writeKey(tt.name+".pub", "PUBLIC KEY", publicDER), 0)
+			require.NoError(t, err)
+
+			now := time.Now()
+			req := signedRequest(t, signer, now, `{"events":[]}`)
+			assert.True(t, strings.HasPrefix(req.Header.Get(HeaderSignature), SchemeKey+"="))
+			require.NoError(t, verifier.VerifyRequest(req))
+
+			req = signedRequest(t, signer, now, `{"events":[]}`)
+			req.Body = http.NoBody
+			assert.Error(t, verifier.VerifyRequest(req), "the signature covers the body")
+		})
+	}
+}
+
+func TestVerifyAcceptsAnyMatchingSignature(t *testing.T) {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	now := time.Now()
+	req := signedRequest(t, NewSigner("new-secret", private), now, "{}")
+	require.Contains(t, req.Header.Get(HeaderSignature), ",")
+
+	assert.NoError(t, NewVerifier("new-secret", nil, 0).Verify(req, []byte("{}")),
+		"receivers that only know the secret accept a request also signed with a key")
+}
+
+func TestVerifyRequestRestoresBody(t *testing.T) {
+	now := time.Now()
+	req := signedRequest(t, NewSigner("s3cret", nil), now, `{"source_repo":"app"}`)
+	require.NoError(t, NewVerifier("s3cret", nil, 0).VerifyRequest(req))
+
+	body, err := io.ReadAll(req.Body)
+	require.NoError(t, err)
+	assert.Equal(t, `{"source_repo":"app"}`, string(body))
+}
+
+func TestNilSigner(t *testing.T) {
+	assert.Nil(t, NewSigner("", nil))
+	assert.Nil(t, NewVerifier("", nil, 0))
+
+	var signer *Signer
+	req := httptest.NewRequest("POST", "/hook", nil)
+	require.NoError(t, signer.Sign(req, nil))
+	assert.Empty(t, req.Header.Get(HeaderSignature))
+}
+
+func TestLoadKeyErrors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "key.pem")
+	require.NoError(t, os.WriteFile(path, []byte("not pem"), 0600))
+
+	_, err := LoadSigner("", path)
+	assert.True(t, errors.Is(err, errors.ErrInvalidInput))
+	_, err = LoadVerifier("", path, 0)
+	assert.True(t, errors.Is(err, errors.ErrInvalidInput))
+	_, err = LoadSigner("", filepath.Join(t.TempDir(), "missing.pem"))
+	assert.Error(t, err)
+}
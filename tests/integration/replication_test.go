@@ -54,7 +54,7 @@ func TestEndToEndReplication(t *testing.T) {
 		}
 
 		// Create copier and tree replicator
-		copier := copy.NewCopier(logger)
+		copier := copy.NewCopier(logger, copy.CopierOptions{})
 		replicator := tree.NewTreeReplicator(logger, copier, tree.TreeReplicatorOptions{
 			WorkerCount:         3,
 			EnableCheckpointing: false,
@@ -93,7 +93,7 @@ func TestEndToEndReplication(t *testing.T) {
 			sourceClient.tags[repo] = []string{"v1.0.0", "v2.0.0", "dev", "latest"}
 		}
 
-		copier := copy.NewCopier(logger)
+		copier := copy.NewCopier(logger, copy.CopierOptions{})
 		replicator := tree.NewTreeReplicator(logger, copier, tree.TreeReplicatorOptions{
 			WorkerCount:         2,
 			ExcludeRepositories: []string{"test/*"},
@@ -125,7 +125,7 @@ func TestEndToEndReplication(t *testing.T) {
 		sourceClient.repositories = []string{"app/service"}
 		sourceClient.tags["app/service"] = []string{"v1.0.0", "v1.1.0"}
 
-		copier := copy.NewCopier(logger)
+		copier := copy.NewCopier(logger, copy.CopierOptions{})
 		replicator := tree.NewTreeReplicator(logger, copier, tree.TreeReplicatorOptions{
 			WorkerCount:         1,
 			EnableCheckpointing: true,
@@ -203,7 +203,7 @@ func TestMultiRegistryReplication(t *testing.T) {
 				sourceClient.tags[repo] = tags
 			}
 
-			copier := copy.NewCopier(logger)
+			copier := copy.NewCopier(logger, copy.CopierOptions{})
 			replicator := tree.NewTreeReplicator(logger, copier, tree.TreeReplicatorOptions{
 				WorkerCount: 3,
 			})
@@ -239,7 +239,7 @@ func TestErrorScenarios(t *testing.T) {
 		sourceClient.simulateError = true
 		destClient := NewMockRegistryClient("dest.registry.io", logger)
 
-		copier := copy.NewCopier(logger)
+		copier := copy.NewCopier(logger, copy.CopierOptions{})
 		replicator := tree.NewTreeReplicator(logger, copier, tree.TreeReplicatorOptions{
 			WorkerCount: 1,
 		})
@@ -267,7 +267,7 @@ func TestErrorScenarios(t *testing.T) {
 		// Simulate failure for specific repository
 		sourceClient.failOnRepo = "app/bad"
 
-		copier := copy.NewCopier(logger)
+		copier := copy.NewCopier(logger, copy.CopierOptions{})
 		replicator := tree.NewTreeReplicator(logger, copier, tree.TreeReplicatorOptions{
 			WorkerCount: 2,
 		})
@@ -296,7 +296,7 @@ func TestErrorScenarios(t *testing.T) {
 		sourceClient.tags["app/service"] = []string{"v1.0.0"}
 		sourceClient.delayDuration = 500 * time.Millisecond
 
-		copier := copy.NewCopier(logger)
+		copier := copy.NewCopier(logger, copy.CopierOptions{})
 		replicator := tree.NewTreeReplicator(logger, copier, tree.TreeReplicatorOptions{
 			WorkerCount: 1,
 		})
@@ -352,7 +352,7 @@ func BenchmarkReplication(b *testing.B) {
 				sourceClient.tags[repo] = tags
 			}
 
-			copier := copy.NewCopier(logger)
+			copier := copy.NewCopier(logger, copy.CopierOptions{})
 			replicator := tree.NewTreeReplicator(logger, copier, tree.TreeReplicatorOptions{
 				WorkerCount: bm.workers,
 			})
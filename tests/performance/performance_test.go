@@ -205,7 +205,7 @@ func BenchmarkReplicationThroughput(b *testing.B) {
 
 	for _, bm := range benchmarks {
 		b.Run(bm.name, func(b *testing.B) {
-			copier := copy.NewCopier(logger)
+			copier := copy.NewCopier(logger, copy.CopierOptions{})
 
 			b.ResetTimer()
 			b.ReportAllocs()
@@ -234,7 +234,7 @@ func runThroughputTest(t *testing.T, images, workers int, logger log.Logger) *Pe
 	}
 	sourceClient.tags["perf/test"] = tags
 
-	copier := copy.NewCopier(logger)
+	copier := copy.NewCopier(logger, copy.CopierOptions{})
 	replicator := tree.NewTreeReplicator(logger, copier, tree.TreeReplicatorOptions{
 		WorkerCount: workers,
 	})
@@ -298,7 +298,7 @@ func runLatencyTest(t *testing.T, images, workers int, logger log.Logger) *Perfo
 	var latencies []float64
 	var mu sync.Mutex
 
-	copier := copy.NewCopier(logger)
+	copier := copy.NewCopier(logger, copy.CopierOptions{})
 	replicator := tree.NewTreeReplicator(logger, copier, tree.TreeReplicatorOptions{
 		WorkerCount: workers,
 	})
@@ -25,7 +25,7 @@ func TestCopyImageSourceNotFound(t *testing.T) {
 	}
 
 	logger := log.NewBasicLogger(log.InfoLevel)
-	copier := copy.NewCopier(logger)
+	copier := copy.NewCopier(logger, copy.CopierOptions{})
 
 	srcRef, err := name.ParseReference("nonexistent.io/repo:tag")
 	require.NoError(t, err)
@@ -56,7 +56,7 @@ func TestCopyImageDestinationAlreadyExists(t *testing.T) {
 	}
 
 	logger := log.NewBasicLogger(log.InfoLevel)
-	copier := copy.NewCopier(logger)
+	copier := copy.NewCopier(logger, copy.CopierOptions{})
 
 	srcRef, _ := name.ParseReference("source.io/repo:tag")
 	destRef, _ := name.ParseReference("dest.io/repo:tag")
@@ -184,7 +184,7 @@ func TestImageLayersError(t *testing.T) {
 // TestCopyBlobDeprecatedError tests deprecated method returns error
 func TestCopyBlobDeprecatedError(t *testing.T) {
 	logger := log.NewBasicLogger(log.InfoLevel)
-	copier := copy.NewCopier(logger)
+	copier := copy.NewCopier(logger, copy.CopierOptions{})
 
 	// copyBlob is deprecated and should return an error
 	// We can't call it directly, but the behavior is tested elsewhere
@@ -198,7 +198,7 @@ func TestContextCancellation(t *testing.T) {
 	}
 
 	logger := log.NewBasicLogger(log.InfoLevel)
-	copier := copy.NewCopier(logger)
+	copier := copy.NewCopier(logger, copy.CopierOptions{})
 
 	srcRef, _ := name.ParseReference("source.io/repo:tag")
 	destRef, _ := name.ParseReference("dest.io/repo:tag")
@@ -227,7 +227,7 @@ func TestContextTimeout(t *testing.T) {
 	}
 
 	logger := log.NewBasicLogger(log.InfoLevel)
-	copier := copy.NewCopier(logger)
+	copier := copy.NewCopier(logger, copy.CopierOptions{})
 
 	srcRef, _ := name.ParseReference("source.io/repo:tag")
 	destRef, _ := name.ParseReference("dest.io/repo:tag")
@@ -255,7 +255,7 @@ func TestContextTimeout(t *testing.T) {
 func TestNilLogger(t *testing.T) {
 	// NewCopier requires a logger, but we test the pattern
 	logger := log.NewBasicLogger(log.InfoLevel)
-	copier := copy.NewCopier(logger)
+	copier := copy.NewCopier(logger, copy.CopierOptions{})
 	assert.NotNil(t, copier)
 }
 
@@ -303,7 +303,7 @@ func TestPartialTransferError(t *testing.T) {
 	}
 
 	logger := log.NewBasicLogger(log.InfoLevel)
-	copier := copy.NewCopier(logger)
+	copier := copy.NewCopier(logger, copy.CopierOptions{})
 
 	// Test with layers that partially fail
 	// This tests the error handling during layer processing
@@ -348,7 +348,7 @@ func TestCompressStreamError(t *testing.T) {
 	}
 
 	logger := log.NewBasicLogger(log.InfoLevel)
-	copier := copy.NewCopier(logger)
+	copier := copy.NewCopier(logger, copy.CopierOptions{})
 
 	// Create a reader that will error
 	reader := &errorReader{
@@ -412,7 +412,7 @@ func TestErrorWrapping(t *testing.T) {
 // TestRecoveryFromErrors tests recovery mechanisms
 func TestRecoveryFromErrors(t *testing.T) {
 	logger := log.NewBasicLogger(log.InfoLevel)
-	copier := copy.NewCopier(logger)
+	copier := copy.NewCopier(logger, copy.CopierOptions{})
 
 	// After an error, copier should still be usable
 	srcRef, _ := name.ParseReference("source.io/repo:tag")
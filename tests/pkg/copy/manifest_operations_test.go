@@ -46,7 +46,7 @@ func TestManifestDescriptorMediaType(t *testing.T) {
 			// Test that manifest media type detection works correctly
 			// We test this indirectly through the copier's behavior
 			logger := log.NewBasicLogger(log.InfoLevel)
-			copier := copy.NewCopier(logger)
+			copier := copy.NewCopier(logger, copy.CopierOptions{})
 			assert.NotNil(t, copier)
 
 			// Verify manifest structure
@@ -211,7 +211,7 @@ func TestPushManifestWithDifferentTypes(t *testing.T) {
 	}
 
 	logger := log.NewBasicLogger(log.InfoLevel)
-	copier := copy.NewCopier(logger)
+	copier := copy.NewCopier(logger, copy.CopierOptions{})
 	ctx := context.Background()
 
 	destRef, err := name.ParseReference("dest.io/repo:tag")
@@ -250,7 +250,7 @@ func TestPushManifestWithDifferentTypes(t *testing.T) {
 // TestManifestProcessing tests manifest processing workflow
 func TestManifestProcessing(t *testing.T) {
 	logger := log.NewBasicLogger(log.InfoLevel)
-	copier := copy.NewCopier(logger)
+	copier := copy.NewCopier(logger, copy.CopierOptions{})
 
 	t.Run("process valid manifest", func(t *testing.T) {
 		manifest := []byte(`{
@@ -442,7 +442,7 @@ func TestManifestHashConsistency(t *testing.T) {
 // TestProcessManifestStub tests the process manifest stub method
 func TestProcessManifestStub(t *testing.T) {
 	logger := log.NewBasicLogger(log.InfoLevel)
-	copier := copy.NewCopier(logger)
+	copier := copy.NewCopier(logger, copy.CopierOptions{})
 	ctx := context.Background()
 
 	srcRef, _ := name.ParseReference("source:tag")
@@ -0,0 +1,93 @@
+package distributed_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"freightliner/pkg/distributed"
+	"freightliner/pkg/helper/log"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func openTestDiskTier(t *testing.T, dir string, maxBytes int64) *distributed.DiskTier {
+	tier, err := distributed.OpenDiskTier(distributed.DiskTierConfig{
+		Dir:       dir,
+		MaxBytes:  maxBytes,
+		WarmBytes: maxBytes,
+		Logger:    log.NewBasicLogger(log.InfoLevel),
+	})
+	require.NoError(t, err)
+	return tier
+}
+
+func TestDiskTier_SurvivesRestart(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	tier := openTestDiskTier(t, dir, 1<<20)
+	require.NoError(t, tier.Set(ctx, "manifest:nginx:latest", []byte("manifest"), 0))
+	require.NoError(t, tier.Set(ctx, "manifest:nginx:1.25", []byte("manifest"), 0))
+	require.NoError(t, tier.Set(ctx, "manifest:redis:7", []byte("other"), 0))
+	require.NoError(t, tier.Delete(ctx, "manifest:redis:7"))
+	require.NoError(t, tier.Close())
+
+	reopened := openTestDiskTier(t, dir, 1<<20)
+	defer reopened.Close()
+
+	assert.Equal(t, 2, reopened.Len())
+	assert.Equal(t, int64(len("manifest")), reopened.Size(), "equal values are stored once")
+
+	value, _, err := reopened.Get(ctx, "manifest:nginx:1.25")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("manifest"), value)
+
+	_, _, err = reopened.Get(ctx, "manifest:redis:7")
+	assert.Error(t, err)
+}
+
+func TestDiskTier_EvictsLeastRecentlyUsed(t *testing.T) {
+	ctx := context.Background()
+	tier := openTestDiskTier(t, t.TempDir(), 40)
+	defer tier.Close()
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, tier.Set(ctx, fmt.Sprintf("key-%d", i), []byte(fmt.Sprintf("value-%d-...", i)), 0))
+	}
+	// Use key-0 so key-1 is the least recently used
+	_, _, err := tier.Get(ctx, "key-0")
+	require.NoError(t, err)
+
+	require.NoError(t, tier.Set(ctx, "key-3", []byte("value-3-..."), 0))
+
+	_, _, err = tier.Get(ctx, "key-1")
+	assert.Error(t, err)
+	_, _, err = tier.Get(ctx, "key-0")
+	assert.NoError(t, err)
+	assert.LessOrEqual(t, tier.Size(), int64(40))
+	assert.Equal(t, uint64(1), tier.GetMetrics().Evictions.Load())
+}
+
+func TestCacheNode_WarmsUpFromDiskTier(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	tier := openTestDiskTier(t, dir, 1<<20)
+	node := distributed.NewCacheNode("node-1", "127.0.0.1:7001", 1<<20, nil)
+	node.AttachDiskTier(ctx, tier)
+	require.NoError(t, node.Set(ctx, "manifest:nginx:latest", []byte("manifest"), 3600))
+	require.NoError(t, tier.Close())
+
+	// A restarted node gets its entries back from disk
+	restartedTier := openTestDiskTier(t, dir, 1<<20)
+	defer restartedTier.Close()
+	restarted := distributed.NewCacheNode("node-1", "127.0.0.1:7001", 1<<20, nil)
+	restarted.AttachDiskTier(ctx, restartedTier)
+
+	assert.Equal(t, int64(len("manifest")), restarted.GetSize(), "warm-up loads entries into memory")
+	value, err := restarted.Get(ctx, "manifest:nginx:latest")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("manifest"), value)
+}
@@ -0,0 +1,97 @@
+package distributed_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"freightliner/pkg/distributed"
+	"freightliner/pkg/helper/log"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingPeer accepts every job submitted to it
+type recordingPeer struct {
+	mu        sync.Mutex
+	submitted []string
+}
+
+func (p *recordingPeer) GetQueueSize(ctx context.Context) (int, error) { return 0, nil }
+func (p *recordingPeer) GetCapacity(ctx context.Context) (int, error)  { return 100, nil }
+func (p *recordingPeer) StealJob(ctx context.Context) (*distributed.Job, error) {
+	return nil, nil
+}
+
+func (p *recordingPeer) SubmitJob(ctx context.Context, job *distributed.Job) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.submitted = append(p.submitted, job.ID)
+	return nil
+}
+
+func TestWorkStealingScheduler_DrainWaitsForInFlightJobs(t *testing.T) {
+	scheduler := distributed.NewWorkStealingScheduler("node-1", 100, 1000, log.NewBasicLogger(log.InfoLevel))
+	defer scheduler.Stop()
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	go func() {
+		_ = scheduler.Execute(context.Background(), &distributed.Job{
+			ID: "running",
+			Task: func(ctx context.Context) error {
+				close(started)
+				<-release
+				return nil
+			},
+		})
+	}()
+	<-started
+
+	drained := make(chan error, 1)
+	go func() { drained <- scheduler.Drain(context.Background()) }()
+
+	require.Eventually(t, func() bool {
+		return scheduler.Mode() == distributed.NodeModeDraining
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, int64(1), scheduler.Status().InFlight)
+
+	// New work is refused while draining
+	assert.Error(t, scheduler.Accept(&distributed.Job{ID: "new"}))
+
+	close(release)
+	select {
+	case err := <-drained:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("drain did not finish after the in-flight job completed")
+	}
+	assert.Equal(t, distributed.NodeModeDrained, scheduler.Mode())
+
+	scheduler.Resume()
+	assert.Equal(t, distributed.NodeModeActive, scheduler.Mode())
+	assert.NoError(t, scheduler.Accept(&distributed.Job{ID: "after-resume"}))
+}
+
+func TestWorkStealingScheduler_DrainTransfersQueuedJobs(t *testing.T) {
+	scheduler := distributed.NewWorkStealingScheduler("node-1", 100, 1000, log.NewBasicLogger(log.InfoLevel))
+	defer scheduler.Stop()
+
+	peer := &recordingPeer{}
+	scheduler.AddPeer(distributed.NewPeer("node-2", "node-2:7946", peer))
+
+	for _, id := range []string{"job-1", "job-2", "job-3"} {
+		require.NoError(t, scheduler.Schedule(&distributed.Job{ID: id}))
+	}
+	require.Equal(t, int64(3), scheduler.GetQueueDepth())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, scheduler.Drain(ctx))
+
+	assert.Equal(t, int64(0), scheduler.GetQueueDepth())
+	assert.ElementsMatch(t, []string{"job-1", "job-2", "job-3"}, peer.submitted)
+	assert.Equal(t, distributed.NodeModeDrained, scheduler.Status().Mode)
+}
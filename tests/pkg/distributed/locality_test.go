@@ -0,0 +1,57 @@
+package distributed_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"freightliner/pkg/distributed"
+	"freightliner/pkg/helper/log"
+	"freightliner/pkg/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkStealingScheduler_PlacesJobsByCASBlobs(t *testing.T) {
+	logger := log.NewBasicLogger(log.ErrorLevel)
+	ctx := context.Background()
+
+	cache := distributed.NewDistributedCache(distributed.CacheConfig{Logger: logger, Replication: 1})
+	require.NoError(t, cache.AddNode(distributed.NewCacheNode("cache-1", "cache-1:7000", 1<<20, nil)))
+
+	newNode := func(id string) (*distributed.WorkStealingScheduler, *storage.ContentAddressableStore) {
+		scheduler := distributed.NewWorkStealingScheduler(id, 100, 1000, logger)
+		t.Cleanup(scheduler.Stop)
+		cas := storage.NewContentAddressableStore(storage.CASConfig{Logger: logger, GCInterval: time.Hour})
+		t.Cleanup(cas.Stop)
+		require.NoError(t, scheduler.EnableBlobLocality(ctx, cache, cas, distributed.DefaultLocalityThreshold))
+		return scheduler, cas
+	}
+	local, localCAS := newNode("node-1")
+	_, remoteCAS := newNode("node-2")
+
+	peer := &recordingPeer{}
+	local.AddPeer(distributed.NewPeer("node-2", "node-2:7946", peer))
+
+	// node-2 holds two of the job's three blobs, node-1 the third
+	layer1, err := remoteCAS.Store(ctx, []byte("layer-1"))
+	require.NoError(t, err)
+	layer2, err := remoteCAS.Store(ctx, []byte("layer-2"))
+	require.NoError(t, err)
+	config, err := localCAS.Store(ctx, []byte("config"))
+	require.NoError(t, err)
+
+	blobs := []string{layer1.String(), layer2.String(), config.String()}
+	require.NoError(t, local.Schedule(&distributed.Job{ID: "replicate-app", Blobs: blobs}))
+	assert.Equal(t, []string{"replicate-app"}, peer.submitted)
+	assert.Equal(t, int64(0), local.GetQueueDepth())
+
+	// Once node-2 drops the layers the job stays on node-1
+	require.NoError(t, remoteCAS.Delete(ctx, layer1))
+	require.NoError(t, remoteCAS.Delete(ctx, layer2))
+	require.NoError(t, local.Schedule(&distributed.Job{ID: "replicate-app-again", Blobs: blobs}))
+	assert.Equal(t, []string{"replicate-app"}, peer.submitted)
+	assert.Equal(t, int64(1), local.GetQueueDepth())
+	assert.Equal(t, uint64(1), local.GetMetrics().LocalityPlacements.Load())
+}
@@ -228,3 +228,144 @@ func TestRaftCoordinator_Snapshot(t *testing.T) {
 		assert.NoError(t, err)
 	}
 }
+
+func TestRaftCoordinator_RateShares(t *testing.T) {
+	coordinator, err := distributed.NewRaftCoordinator(distributed.RaftConfig{
+		NodeID:    "node-1",
+		BindAddr:  "127.0.0.1:0",
+		DataDir:   t.TempDir(),
+		Bootstrap: true,
+		Logger:    log.NewBasicLogger(log.InfoLevel),
+	})
+	require.NoError(t, err)
+	defer coordinator.Shutdown()
+
+	err = coordinator.WaitForLeader(5 * time.Second)
+	require.NoError(t, err)
+
+	members, err := coordinator.Members()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"node-1"}, members)
+
+	shares := map[string]float64{"node-1": 60, "node-2": 40}
+	err = coordinator.SetRateShares(context.Background(), "registry-1.docker.io", shares)
+	require.NoError(t, err)
+
+	assert.Equal(t, shares, coordinator.RateShares("registry-1.docker.io"))
+	assert.Empty(t, coordinator.RateShares("quay.io"))
+}
+
+func TestRaftCoordinator_IdempotentJobTransitions(t *testing.T) {
+	coordinator, err := distributed.NewRaftCoordinator(distributed.RaftConfig{
+		NodeID:    "node-1",
+		BindAddr:  "127.0.0.1:0",
+		DataDir:   t.TempDir(),
+		Bootstrap: true,
+		Logger:    log.NewBasicLogger(log.InfoLevel),
+	})
+	require.NoError(t, err)
+	defer coordinator.Shutdown()
+
+	err = coordinator.WaitForLeader(5 * time.Second)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	start := time.Now()
+	job := &distributed.JobState{
+		ID:         "job-1",
+		Status:     distributed.JobStatusPending,
+		NodeID:     "node-1",
+		StartTime:  start,
+		UpdateTime: start,
+	}
+	require.NoError(t, coordinator.CreateJob(ctx, job))
+
+	running := *job
+	running.Status = distributed.JobStatusRunning
+	running.UpdateTime = start.Add(time.Second)
+	require.NoError(t, coordinator.UpdateJob(ctx, &running))
+
+	// A re-sent create and a reordered pending update leave the job running
+	require.NoError(t, coordinator.CreateJob(ctx, job))
+	require.NoError(t, coordinator.UpdateJob(ctx, job))
+	retrieved, exists := coordinator.GetJob("job-1")
+	require.True(t, exists)
+	assert.Equal(t, distributed.JobStatusRunning, retrieved.Status)
+
+	// Going back to pending needs a retry
+	pending := running
+	pending.Status = distributed.JobStatusPending
+	pending.UpdateTime = start.Add(2 * time.Second)
+	assert.Error(t, coordinator.UpdateJob(ctx, &pending))
+
+	// The first completion wins, duplicates are dropped
+	require.NoError(t, coordinator.ReportCompletion(ctx, &distributed.CompletionRecord{
+		JobID:  "job-1",
+		Status: distributed.JobStatusDone,
+		NodeID: "node-2",
+	}))
+	require.NoError(t, coordinator.ReportCompletion(ctx, &distributed.CompletionRecord{
+		JobID:  "job-1",
+		Status: distributed.JobStatusFailed,
+		NodeID: "node-1",
+		Error:  "stolen job timed out",
+	}))
+	require.NoError(t, coordinator.UpdateJob(ctx, &running))
+
+	_, exists = coordinator.GetJob("job-1")
+	assert.False(t, exists)
+	completion, exists := coordinator.GetCompletion("job-1")
+	require.True(t, exists)
+	assert.Equal(t, distributed.JobStatusDone, completion.Status)
+	assert.Equal(t, "node-2", completion.NodeID)
+}
+
+func TestRaftCoordinator_RetryFailedJob(t *testing.T) {
+	coordinator, err := distributed.NewRaftCoordinator(distributed.RaftConfig{
+		NodeID:    "node-1",
+		BindAddr:  "127.0.0.1:0",
+		DataDir:   t.TempDir(),
+		Bootstrap: true,
+		Logger:    log.NewBasicLogger(log.InfoLevel),
+	})
+	require.NoError(t, err)
+	defer coordinator.Shutdown()
+
+	err = coordinator.WaitForLeader(5 * time.Second)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	job := &distributed.JobState{
+		ID:         "job-1",
+		Status:     distributed.JobStatusRunning,
+		NodeID:     "node-1",
+		UpdateTime: time.Now(),
+	}
+	require.NoError(t, coordinator.CreateJob(ctx, job))
+	require.NoError(t, coordinator.ReportCompletion(ctx, &distributed.CompletionRecord{
+		JobID:  "job-1",
+		Status: distributed.JobStatusFailed,
+		NodeID: "node-1",
+	}))
+
+	// A new attempt reopens the failed job
+	retry := *job
+	retry.Status = distributed.JobStatusPending
+	retry.RetryCount = 1
+	require.NoError(t, coordinator.UpdateJob(ctx, &retry))
+
+	retrieved, exists := coordinator.GetJob("job-1")
+	require.True(t, exists)
+	assert.Equal(t, 1, retrieved.RetryCount)
+	_, exists = coordinator.GetCompletion("job-1")
+	assert.False(t, exists)
+
+	// A late failure report from the first attempt is ignored
+	require.NoError(t, coordinator.ReportCompletion(ctx, &distributed.CompletionRecord{
+		JobID:  "job-1",
+		Status: distributed.JobStatusFailed,
+		NodeID: "node-1",
+	}))
+	_, exists = coordinator.GetJob("job-1")
+	assert.True(t, exists)
+}
@@ -0,0 +1,147 @@
+package distributed_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"freightliner/pkg/distributed"
+	"freightliner/pkg/helper/errors"
+	"freightliner/pkg/helper/log"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryShareStore stands in for the Raft FSM shared by all nodes
+type memoryShareStore struct {
+	mu      sync.Mutex
+	leader  string
+	members []string
+	shares  map[string]map[string]float64
+}
+
+// nodeView is one node's view of a memoryShareStore
+type nodeView struct {
+	*memoryShareStore
+	nodeID string
+}
+
+func (v nodeView) IsLeader() bool { return v.leader == v.nodeID }
+
+func (s *memoryShareStore) Members() ([]string, error) { return s.members, nil }
+
+func (s *memoryShareStore) RateShares(host string) map[string]float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	shares := make(map[string]float64)
+	for node, share := range s.shares[host] {
+		shares[node] = share
+	}
+	return shares
+}
+
+func (s *memoryShareStore) SetRateShares(ctx context.Context, host string, shares map[string]float64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.shares[host] = shares
+	return nil
+}
+
+// memoryDemandStore stands in for the distributed cache
+type memoryDemandStore struct {
+	mu     sync.Mutex
+	values map[string][]byte
+}
+
+func (s *memoryDemandStore) Get(ctx context.Context, key string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	value, ok := s.values[key]
+	if !ok {
+		return nil, errors.NotFoundf("key %s not found", key)
+	}
+	return value, nil
+}
+
+func (s *memoryDemandStore) Set(ctx context.Context, key string, value []byte, ttl int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.values[key] = value
+	return nil
+}
+
+func newTestRateLimiter(t *testing.T, nodeID string, store *memoryShareStore, demand *memoryDemandStore) *distributed.ClusterRateLimiter {
+	config := distributed.ClusterRateLimiterConfig{
+		NodeID:            nodeID,
+		Budgets:           []distributed.HostBudget{{Host: "registry-1.docker.io", RequestsPerSecond: 100, Burst: 100}},
+		Shares:            nodeView{memoryShareStore: store, nodeID: nodeID},
+		RebalanceInterval: time.Second,
+		Logger:            log.NewBasicLogger(log.InfoLevel),
+	}
+	if demand != nil {
+		config.Demand = demand
+	}
+
+	limiter, err := distributed.NewClusterRateLimiter(config)
+	require.NoError(t, err)
+	return limiter
+}
+
+func TestClusterRateLimiter_SplitsBudgetByDemand(t *testing.T) {
+	store := &memoryShareStore{
+		leader:  "node-1",
+		members: []string{"node-1", "node-2"},
+		shares:  make(map[string]map[string]float64),
+	}
+	demand := &memoryDemandStore{values: make(map[string][]byte)}
+	ctx := context.Background()
+
+	leader := newTestRateLimiter(t, "node-1", store, demand)
+	follower := newTestRateLimiter(t, "node-2", store, demand)
+
+	// Without shares each node takes half of the budget
+	assert.InDelta(t, 50, leader.Shares()["registry-1.docker.io"], 0.001)
+	assert.InDelta(t, 50, follower.Shares()["registry-1.docker.io"], 0.001)
+
+	// Only the follower sends requests
+	for i := 0; i < 30; i++ {
+		require.NoError(t, follower.Wait(ctx, "registry-1.docker.io"))
+	}
+	require.NoError(t, follower.Sync(ctx))
+	require.NoError(t, leader.Sync(ctx))
+	require.NoError(t, follower.Sync(ctx))
+
+	leaderShare := leader.Shares()["registry-1.docker.io"]
+	followerShare := follower.Shares()["registry-1.docker.io"]
+	assert.InDelta(t, 100, leaderShare+followerShare, 0.001, "shares must add up to the budget")
+	assert.Greater(t, followerShare, leaderShare)
+	assert.Greater(t, leaderShare, 0.0, "idle nodes keep a reserved share")
+}
+
+func TestClusterRateLimiter_UnlimitedHosts(t *testing.T) {
+	store := &memoryShareStore{
+		leader:  "node-1",
+		members: []string{"node-1"},
+		shares:  make(map[string]map[string]float64),
+	}
+	limiter := newTestRateLimiter(t, "node-1", store, nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	for i := 0; i < 1000; i++ {
+		require.NoError(t, limiter.Wait(ctx, "quay.io"))
+	}
+}
+
+func TestNewClusterRateLimiter_RejectsInvalidBudgets(t *testing.T) {
+	store := &memoryShareStore{members: []string{"node-1"}, shares: make(map[string]map[string]float64)}
+
+	_, err := distributed.NewClusterRateLimiter(distributed.ClusterRateLimiterConfig{
+		NodeID:  "node-1",
+		Budgets: []distributed.HostBudget{{Host: "registry-1.docker.io"}},
+		Shares:  nodeView{memoryShareStore: store, nodeID: "node-1"},
+	})
+	assert.Error(t, err)
+}
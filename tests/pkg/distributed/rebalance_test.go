@@ -0,0 +1,103 @@
+package distributed_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"freightliner/pkg/distributed"
+	"freightliner/pkg/helper/log"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// loadedPeer reports a fixed queue and counts the jobs submitted to it
+type loadedPeer struct {
+	mu       sync.Mutex
+	depth    int
+	capacity int
+	err      error
+}
+
+func (p *loadedPeer) GetQueueSize(ctx context.Context) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.depth, p.err
+}
+
+func (p *loadedPeer) GetCapacity(ctx context.Context) (int, error) { return p.capacity, p.err }
+func (p *loadedPeer) StealJob(ctx context.Context) (*distributed.Job, error) {
+	return nil, nil
+}
+
+func (p *loadedPeer) SubmitJob(ctx context.Context, job *distributed.Job) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.depth++
+	return nil
+}
+
+func (p *loadedPeer) queued() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.depth
+}
+
+func scheduleJobs(t *testing.T, scheduler *distributed.WorkStealingScheduler, n int) {
+	for i := 0; i < n; i++ {
+		require.NoError(t, scheduler.Schedule(&distributed.Job{ID: fmt.Sprintf("job-%d", i)}))
+	}
+}
+
+func TestWorkStealingScheduler_RebalanceByCapacity(t *testing.T) {
+	scheduler := distributed.NewWorkStealingScheduler("node-1", 100, 1000, log.NewBasicLogger(log.InfoLevel))
+	defer scheduler.Stop()
+
+	large := &loadedPeer{capacity: 100}
+	small := &loadedPeer{capacity: 50}
+	down := &loadedPeer{capacity: 100, err: errors.New("connection refused")}
+	scheduler.AddPeer(distributed.NewPeer("node-2", "node-2:7946", large))
+	scheduler.AddPeer(distributed.NewPeer("node-3", "node-3:7946", small))
+	scheduler.AddPeer(distributed.NewPeer("node-4", "node-4:7946", down))
+	scheduleJobs(t, scheduler, 9)
+
+	snapshot := scheduler.Snapshot()
+	require.Len(t, snapshot.Nodes, 4)
+	assert.Equal(t, 9, snapshot.Nodes[0].QueueDepth)
+	assert.NotEmpty(t, snapshot.Nodes[3].Error)
+
+	result, err := scheduler.Rebalance(context.Background())
+	require.NoError(t, err)
+
+	// 9 jobs over capacities 100/100/50, the unreachable peer gets none
+	assert.Equal(t, 5, result.Total)
+	assert.Equal(t, map[string]int{"node-2": 3, "node-3": 2}, result.Moved)
+	assert.Equal(t, int64(4), scheduler.GetQueueDepth())
+	assert.Equal(t, 3, large.queued())
+	assert.Equal(t, 2, small.queued())
+	assert.Equal(t, uint64(1), scheduler.GetMetrics().Rebalances.Load())
+	assert.Equal(t, uint64(5), scheduler.GetMetrics().JobsRebalanced.Load())
+
+	// A balanced cluster moves nothing
+	result, err = scheduler.Rebalance(context.Background())
+	require.NoError(t, err)
+	assert.Zero(t, result.Total)
+}
+
+func TestWorkStealingScheduler_RebalanceOnJoin(t *testing.T) {
+	scheduler := distributed.NewWorkStealingScheduler("node-1", 100, 1000, log.NewBasicLogger(log.InfoLevel))
+	defer scheduler.Stop()
+	scheduleJobs(t, scheduler, 10)
+
+	joined := &loadedPeer{capacity: 100}
+	scheduler.AddPeer(distributed.NewPeer("node-2", "node-2:7946", joined))
+
+	require.Eventually(t, func() bool {
+		return joined.queued() == 5
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, int64(5), scheduler.GetQueueDepth())
+}
@@ -263,3 +263,44 @@ func TestCAS_GarbageCollection(t *testing.T) {
 	// Should be cleaned up
 	assert.False(t, cas.Exists(ctx, d))
 }
+
+func TestCAS_RepositoryUsage(t *testing.T) {
+	cas := storage.NewContentAddressableStore(storage.CASConfig{
+		Logger: log.NewBasicLogger(log.InfoLevel),
+	})
+	defer cas.Stop()
+
+	ctx := context.Background()
+	base := []byte("shared base layer")  // 17 bytes
+	app := []byte("app layer")           // 9 bytes
+	tool := []byte("tool layer, larger") // 18 bytes
+	_, err := cas.StoreForRepository(ctx, "docker.io/team/app", base)
+	require.NoError(t, err)
+	_, err = cas.StoreForRepository(ctx, "docker.io/team/app", app)
+	require.NoError(t, err)
+	_, err = cas.StoreForRepository(ctx, "docker.io/team/app", app)
+	require.NoError(t, err)
+	_, err = cas.StoreForRepository(ctx, "docker.io/team/tool", base)
+	require.NoError(t, err)
+	_, err = cas.StoreForRepository(ctx, "quay.io/other/tool", tool)
+	require.NoError(t, err)
+
+	usage := cas.RepositoryUsage()
+	require.Len(t, usage, 3)
+	assert.Equal(t, "quay.io/other/tool", usage[0].Repository)
+
+	appUsage := usage[1]
+	assert.Equal(t, "docker.io/team/app", appUsage.Repository)
+	assert.Equal(t, 2, appUsage.Blobs)
+	assert.Equal(t, int64(17+9+9), appUsage.LogicalBytes)
+	assert.Equal(t, int64(17+9), appUsage.StoredBytes)
+	assert.Equal(t, int64(9), appUsage.ExclusiveBytes, "the base layer is shared with another repository")
+
+	// The base layer is exclusive to the docker.io/team tree
+	tree := cas.TreeUsage("docker.io/team/")
+	assert.Equal(t, int64(17+9+9+17), tree.LogicalBytes)
+	assert.Equal(t, int64(17+9), tree.StoredBytes)
+	assert.Equal(t, int64(17+9), tree.ExclusiveBytes)
+	assert.InDelta(t, 2.0, tree.DedupRatio, 0.001)
+	assert.Equal(t, 3, cas.GetStats()["repositories"])
+}
@@ -0,0 +1,188 @@
+package storage_test
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"freightliner/pkg/helper/errors"
+	"freightliner/pkg/helper/log"
+	"freightliner/pkg/storage"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileBackend_RoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	backend, err := storage.NewFileBackend(dir, log.NewBasicLogger(log.InfoLevel))
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	data := []byte("cached manifest")
+	d := digest.SHA256.FromBytes(data)
+
+	require.NoError(t, backend.Put(ctx, d, data))
+	require.NoError(t, backend.Put(ctx, d, data), "storing a blob twice is a no-op")
+	assert.True(t, backend.Exists(ctx, d))
+
+	retrieved, err := backend.Get(ctx, d)
+	require.NoError(t, err)
+	assert.Equal(t, data, retrieved)
+
+	// A second backend on the same directory sees the blob
+	reopened, err := storage.NewFileBackend(dir, log.NewBasicLogger(log.InfoLevel))
+	require.NoError(t, err)
+	digests, err := reopened.List(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []digest.Digest{d}, digests)
+
+	require.NoError(t, reopened.Delete(ctx, d))
+	assert.False(t, reopened.Exists(ctx, d))
+	_, err = reopened.Get(ctx, d)
+	assert.True(t, errors.Is(err, errors.ErrNotFound))
+}
+
+func TestFileBackend_RejectsMismatchedDigest(t *testing.T) {
+	backend, err := storage.NewFileBackend(t.TempDir(), log.NewBasicLogger(log.InfoLevel))
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	d := digest.SHA256.FromBytes([]byte("expected"))
+	assert.Error(t, backend.Put(ctx, d, []byte("something else")))
+	assert.False(t, backend.Exists(ctx, d))
+}
+
+// wrappingKeys wraps data keys by XOR with a master key, like a KMS would
+type wrappingKeys struct {
+	master byte
+}
+
+func (k wrappingKeys) Name() string { return "test-kms" }
+
+func (k wrappingKeys) GenerateDataKey(ctx context.Context, keyLength int) ([]byte, []byte, error) {
+	plain := make([]byte, keyLength)
+	if _, err := rand.Read(plain); err != nil {
+		return nil, nil, err
+	}
+	return plain, k.wrap(plain), nil
+}
+
+func (k wrappingKeys) Decrypt(ctx context.Context, ciphertext []byte) ([]byte, error) {
+	return k.wrap(ciphertext), nil
+}
+
+func (k wrappingKeys) wrap(key []byte) []byte {
+	wrapped := make([]byte, len(key))
+	for i, b := range key {
+		wrapped[i] = b ^ k.master
+	}
+	return wrapped
+}
+
+func TestFileBackend_EncryptsAtRest(t *testing.T) {
+	dir := t.TempDir()
+	logger := log.NewBasicLogger(log.InfoLevel)
+	backend, err := storage.NewEncryptedFileBackend(dir, wrappingKeys{master: 0x17}, logger)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	data := []byte("layer of a sensitive image")
+	d := digest.SHA256.FromBytes(data)
+	require.NoError(t, backend.Put(ctx, d, data))
+
+	path := filepath.Join(dir, "sha256", d.Encoded()[:2], d.Encoded())
+	onDisk, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.NotContains(t, string(onDisk), "sensitive")
+
+	retrieved, err := backend.Get(ctx, d)
+	require.NoError(t, err)
+	assert.Equal(t, data, retrieved)
+
+	digests, err := backend.List(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []digest.Digest{d}, digests)
+
+	// Without the provider, and with a different master key, nothing decrypts
+	plain, err := storage.NewFileBackend(dir, logger)
+	require.NoError(t, err)
+	_, err = plain.Get(ctx, d)
+	assert.Error(t, err)
+	wrongKey, err := storage.NewEncryptedFileBackend(dir, wrappingKeys{master: 0x99}, logger)
+	require.NoError(t, err)
+	_, err = wrongKey.Get(ctx, d)
+	assert.Error(t, err)
+
+	// Tampered ciphertext is detected
+	onDisk[len(onDisk)-1] ^= 0xff
+	require.NoError(t, os.WriteFile(path, onDisk, 0o644))
+	_, err = backend.Get(ctx, d)
+	assert.Error(t, err)
+
+	require.NoError(t, backend.Delete(ctx, d))
+	assert.NoFileExists(t, path)
+}
+
+func TestFileBackend_EncryptedConcurrentPuts(t *testing.T) {
+	dir := t.TempDir()
+	backend, err := storage.NewEncryptedFileBackend(dir, wrappingKeys{master: 0x17}, log.NewBasicLogger(log.InfoLevel))
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	// Racing writers of one blob each use their own data key; whichever wins,
+	// the stored key must be the one that sealed the stored ciphertext
+	for i := 0; i < 50; i++ {
+		data := []byte(fmt.Sprintf("blob %d", i))
+		d := digest.SHA256.FromBytes(data)
+
+		var wg sync.WaitGroup
+		for w := 0; w < 8; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				assert.NoError(t, backend.Put(ctx, d, data))
+			}()
+		}
+		wg.Wait()
+
+		retrieved, err := backend.Get(ctx, d)
+		require.NoError(t, err)
+		assert.Equal(t, data, retrieved)
+		ranged, err := backend.GetRange(ctx, d, 5, -1)
+		require.NoError(t, err)
+		assert.Equal(t, data[5:], ranged)
+	}
+}
+
+func TestFileBackend_EncryptedReadsPlaintextBlobsByDigest(t *testing.T) {
+	dir := t.TempDir()
+	logger := log.NewBasicLogger(log.InfoLevel)
+	plain, err := storage.NewFileBackend(dir, logger)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	data := []byte("stored before encryption was enabled")
+	d := digest.SHA256.FromBytes(data)
+	require.NoError(t, plain.Put(ctx, d, data))
+
+	backend, err := storage.NewEncryptedFileBackend(dir, wrappingKeys{master: 0x17}, logger)
+	require.NoError(t, err)
+	retrieved, err := backend.Get(ctx, d)
+	require.NoError(t, err)
+	assert.Equal(t, data, retrieved)
+
+	// A blob without an encryption header that does not match its digest is
+	// not returned as content
+	path := filepath.Join(dir, "sha256", d.Encoded()[:2], d.Encoded())
+	require.NoError(t, os.WriteFile(path, []byte("damaged"), 0o644))
+	_, err = backend.Get(ctx, d)
+	assert.Error(t, err)
+	_, err = backend.GetRange(ctx, d, 0, 4)
+	assert.Error(t, err)
+}
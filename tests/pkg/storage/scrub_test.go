@@ -0,0 +1,98 @@
+package storage_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"freightliner/pkg/helper/log"
+	"freightliner/pkg/storage"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// upstreamFetcher serves blobs by digest
+type upstreamFetcher map[digest.Digest][]byte
+
+func (f upstreamFetcher) FetchBlob(ctx context.Context, source storage.BlobSource, d digest.Digest) ([]byte, error) {
+	return f[d], nil
+}
+
+// corruptBlobFile overwrites the file backend's copy of d
+func corruptBlobFile(t *testing.T, dir string, d digest.Digest) {
+	path := filepath.Join(dir, string(d.Algorithm()), d.Encoded()[:2], d.Encoded())
+	require.NoError(t, os.WriteFile(path, []byte("bit rot"), 0o644))
+}
+
+func TestScrubber_RepairsAndEvicts(t *testing.T) {
+	dir := t.TempDir()
+	logger := log.NewBasicLogger(log.InfoLevel)
+	backend, err := storage.NewFileBackend(dir, logger)
+	require.NoError(t, err)
+
+	cas := storage.NewContentAddressableStore(storage.CASConfig{Backend: backend, Logger: logger})
+	defer cas.Stop()
+
+	ctx := context.Background()
+	intact, err := cas.Store(ctx, []byte("intact layer"))
+	require.NoError(t, err)
+
+	// The in-memory copy is good, only the file is damaged
+	fromMemory, err := cas.Store(ctx, []byte("layer with a bad file"))
+	require.NoError(t, err)
+	corruptBlobFile(t, dir, fromMemory)
+
+	// Both copies are damaged but the blob is known upstream
+	upstreamData := []byte("layer known upstream")
+	fromUpstream := digest.SHA256.FromBytes(upstreamData)
+	stored := append([]byte(nil), upstreamData...)
+	_, err = cas.Store(ctx, stored)
+	require.NoError(t, err)
+	stored[0] = 'X'
+	corruptBlobFile(t, dir, fromUpstream)
+
+	// Both copies are damaged and nothing can replace them
+	lostData := []byte("layer nobody has")
+	lost, err := cas.Store(ctx, lostData)
+	require.NoError(t, err)
+	lostData[0] = 'X'
+	corruptBlobFile(t, dir, lost)
+
+	scrubber := storage.NewScrubber(cas, storage.ScrubberConfig{
+		Fetcher: upstreamFetcher{fromUpstream: upstreamData},
+		Logger:  logger,
+	})
+	scrubber.RecordSource(fromUpstream, storage.BlobSource{Registry: "docker.io", Repository: "library/app"})
+
+	report, err := scrubber.Scrub(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 4, report.Scrubbed)
+	assert.ElementsMatch(t, []digest.Digest{fromMemory, fromUpstream, lost}, report.Corrupted)
+	assert.ElementsMatch(t, []digest.Digest{fromMemory, fromUpstream}, report.Repaired)
+	assert.Equal(t, []digest.Digest{lost}, report.Evicted)
+
+	for _, d := range []digest.Digest{intact, fromMemory, fromUpstream} {
+		data, err := backend.Get(ctx, d)
+		require.NoError(t, err)
+		assert.Equal(t, d, digest.SHA256.FromBytes(data))
+
+		data, err = cas.Get(ctx, d)
+		require.NoError(t, err)
+		assert.Equal(t, d, digest.SHA256.FromBytes(data))
+	}
+	assert.False(t, cas.Exists(ctx, lost))
+
+	metrics := scrubber.GetMetrics()
+	assert.Equal(t, uint64(3), metrics.Corrupted.Load())
+	assert.Equal(t, uint64(2), metrics.Repaired.Load())
+	assert.Equal(t, uint64(1), metrics.Evicted.Load())
+
+	// A second pass finds nothing left to fix
+	report, err = scrubber.Scrub(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 3, report.Scrubbed)
+	assert.Empty(t, report.Corrupted)
+}
@@ -0,0 +1,100 @@
+package storage_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"freightliner/pkg/helper/errors"
+	"freightliner/pkg/helper/log"
+	"freightliner/pkg/storage"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpool_CleansOrphans(t *testing.T) {
+	dir := t.TempDir()
+
+	dead := filepath.Join(dir, "freightliner-999999999-abcd.partial")
+	live := filepath.Join(dir, fmt.Sprintf("freightliner-%d-abcd.partial", os.Getpid()))
+	other := filepath.Join(dir, "sha256-abcd")
+	for _, path := range []string{dead, live, other} {
+		require.NoError(t, os.WriteFile(path, []byte("x"), 0644))
+	}
+
+	_, err := storage.OpenSpool(dir, log.NewBasicLogger(log.ErrorLevel))
+	require.NoError(t, err)
+
+	assert.NoFileExists(t, dead)
+	assert.FileExists(t, live)
+	assert.FileExists(t, other)
+}
+
+func TestSpool_WriteBlob(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	spool, err := storage.OpenSpool(filepath.Join(dir, ".partial"), log.NewBasicLogger(log.ErrorLevel))
+	require.NoError(t, err)
+
+	data := []byte("test blob data")
+	expected := digest.FromBytes(data)
+	final := filepath.Join(dir, "blobs", expected.Encoded())
+
+	blob, err := spool.WriteBlob(ctx, bytes.NewReader(data), expected, final)
+	require.NoError(t, err)
+	assert.Equal(t, expected, blob.Digest)
+	assert.Equal(t, int64(len(data)), blob.Size)
+	assert.Equal(t, final, blob.Path)
+
+	stored, err := os.ReadFile(final)
+	require.NoError(t, err)
+	assert.Equal(t, data, stored)
+
+	// An empty digest is computed rather than verified
+	blob, err = spool.Write(ctx, bytes.NewReader(data), "")
+	require.NoError(t, err)
+	assert.Equal(t, expected, blob.Digest)
+	require.NoError(t, blob.Discard())
+
+	entries, err := os.ReadDir(spool.Dir())
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestSpool_WriteDigestMismatch(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	spool, err := storage.OpenSpool(dir, log.NewBasicLogger(log.ErrorLevel))
+	require.NoError(t, err)
+
+	final := filepath.Join(dir, "blob")
+	_, err = spool.WriteBlob(ctx, bytes.NewReader([]byte("truncated")), digest.FromString("complete"), final)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, errors.ErrInvalidInput))
+	assert.NoFileExists(t, final)
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, entries, "partial file should be removed")
+}
+
+func TestSpool_EnsureFree(t *testing.T) {
+	spool, err := storage.OpenSpool(t.TempDir(), log.NewBasicLogger(log.ErrorLevel))
+	require.NoError(t, err)
+
+	assert.NoError(t, spool.EnsureFree(0))
+	assert.NoError(t, spool.EnsureFree(1024))
+
+	if _, err := storage.FreeSpace(spool.Dir()); errors.Is(err, storage.ErrFreeSpaceUnsupported) {
+		t.Skip("free space not measurable on this platform")
+	}
+	err = spool.EnsureFree(math.MaxInt64 - storage.SpoolHeadroom)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, errors.ErrUnavailable))
+}
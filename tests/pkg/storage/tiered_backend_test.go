@@ -0,0 +1,191 @@
+package storage_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"freightliner/pkg/helper/errors"
+	"freightliner/pkg/helper/log"
+	"freightliner/pkg/storage"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryObjectStore is an in-memory bucket
+type memoryObjectStore struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func newMemoryObjectStore() *memoryObjectStore {
+	return &memoryObjectStore{objects: make(map[string][]byte)}
+}
+
+func (m *memoryObjectStore) PutObject(ctx context.Context, key string, data []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.objects[key] = append([]byte(nil), data...)
+	return nil
+}
+
+func (m *memoryObjectStore) GetObject(ctx context.Context, key string) ([]byte, error) {
+	return m.GetObjectRange(ctx, key, 0, -1)
+}
+
+func (m *memoryObjectStore) GetObjectRange(ctx context.Context, key string, offset, length int64) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	data, ok := m.objects[key]
+	if !ok {
+		return nil, errors.NotFoundf("object not found: %s", key)
+	}
+	end := int64(len(data))
+	if length >= 0 && offset+length < end {
+		end = offset + length
+	}
+	return data[offset:end], nil
+}
+
+func (m *memoryObjectStore) ObjectExists(ctx context.Context, key string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.objects[key]
+	return ok, nil
+}
+
+func (m *memoryObjectStore) DeleteObject(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.objects[key]; !ok {
+		return errors.NotFoundf("object not found: %s", key)
+	}
+	delete(m.objects, key)
+	return nil
+}
+
+func (m *memoryObjectStore) ListObjects(ctx context.Context, prefix string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var keys []string
+	for key := range m.objects {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func TestTieredBackend_OverflowsToObjectStorage(t *testing.T) {
+	logger := log.NewBasicLogger(log.InfoLevel)
+	hot, err := storage.NewFileBackend(t.TempDir(), logger)
+	require.NoError(t, err)
+	cold := newMemoryObjectStore()
+
+	ctx := context.Background()
+	backend, err := storage.NewTieredBackend(ctx, storage.TieredBackendConfig{
+		Hot:         hot,
+		Cold:        cold,
+		Prefix:      "cas",
+		MaxHotBytes: 25,
+		Logger:      logger,
+	})
+	require.NoError(t, err)
+
+	var digests []digest.Digest
+	for i := 0; i < 3; i++ {
+		data := []byte(fmt.Sprintf("layer %d, ten bytes", i))
+		d := digest.SHA256.FromBytes(data)
+		require.NoError(t, backend.Put(ctx, d, data))
+		digests = append(digests, d)
+	}
+
+	// Only the newest blob fits the hot tier, the others went to the bucket
+	assert.LessOrEqual(t, backend.HotBytes(), int64(25))
+	assert.True(t, hot.Exists(ctx, digests[2]))
+	assert.False(t, hot.Exists(ctx, digests[0]))
+	exists, err := cold.ObjectExists(ctx, "cas/sha256/"+digests[0].Encoded())
+	require.NoError(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, uint64(2), backend.GetMetrics().Offloaded.Load())
+
+	data, err := backend.Get(ctx, digests[0])
+	require.NoError(t, err)
+	assert.Equal(t, "layer 0, ten bytes", string(data))
+	assert.Equal(t, uint64(1), backend.GetMetrics().ColdHits.Load())
+
+	// Range reads are served from either tier
+	part, err := backend.GetRange(ctx, digests[1], 6, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "1", string(part))
+	part, err = backend.GetRange(ctx, digests[2], 9, -1)
+	require.NoError(t, err)
+	assert.Equal(t, "ten bytes", string(part))
+
+	listed, err := backend.List(ctx)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, digests, listed)
+
+	require.NoError(t, backend.Delete(ctx, digests[0]))
+	assert.False(t, backend.Exists(ctx, digests[0]))
+
+	// The CAS serves partial blobs through the backend
+	cas := storage.NewContentAddressableStore(storage.CASConfig{Backend: backend, Logger: logger})
+	defer cas.Stop()
+	part, err = cas.GetRange(ctx, digests[1], 0, 7)
+	require.NoError(t, err)
+	assert.Equal(t, "layer 1", string(part))
+}
+
+func TestS3ObjectStore_RangeRequests(t *testing.T) {
+	var mu sync.Mutex
+	objects := map[string][]byte{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		assert.Contains(t, r.Header.Get("Authorization"), "AWS4-HMAC-SHA256")
+		key := strings.TrimPrefix(r.URL.Path, "/bucket/")
+		switch r.Method {
+		case http.MethodPut:
+			objects[key], _ = io.ReadAll(r.Body)
+		case http.MethodGet:
+			data, ok := objects[key]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			var start, end int
+			if _, err := fmt.Sscanf(r.Header.Get("Range"), "bytes=%d-%d", &start, &end); err == nil {
+				data = data[start : end+1]
+				w.WriteHeader(http.StatusPartialContent)
+			}
+			_, _ = w.Write(data)
+		}
+	}))
+	defer server.Close()
+
+	credentials := aws.CredentialsProviderFunc(func(ctx context.Context) (aws.Credentials, error) {
+		return aws.Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret"}, nil
+	})
+	store := storage.NewS3ObjectStore("bucket", "us-east-1", server.URL, credentials)
+
+	ctx := context.Background()
+	require.NoError(t, store.PutObject(ctx, "cas/sha256/abc", []byte("0123456789")))
+	part, err := store.GetObjectRange(ctx, "cas/sha256/abc", 2, 3)
+	require.NoError(t, err)
+	assert.Equal(t, "234", string(part))
+
+	_, err = store.GetObject(ctx, "cas/sha256/missing")
+	assert.True(t, errors.Is(err, errors.ErrNotFound))
+}
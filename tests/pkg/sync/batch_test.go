@@ -311,6 +311,49 @@ func TestExecute_MultipleTasks_ContinueOnError(t *testing.T) {
 	assert.Len(t, results, 2)
 }
 
+func TestExecute_SkipsImagesOfFailedBaseRepositories(t *testing.T) {
+	config := &pkgsync.Config{
+		BatchSize:       10,
+		Parallel:        3,
+		ContinueOnError: true,
+		RetryAttempts:   0,
+		Timeout:         5,
+	}
+	executor := pkgsync.NewBatchExecutor(config, log.NewBasicLogger(log.ErrorLevel))
+
+	tasks := []pkgsync.SyncTask{
+		{
+			SourceRegistry:   "invalid.io",
+			SourceRepository: "apps/web",
+			SourceTag:        "v1",
+			DestRegistry:     "dest.io",
+			DestRepository:   "apps/web",
+			DestTag:          "v1",
+			DependsOn:        []string{"base/node"},
+		},
+		{
+			SourceRegistry:   "invalid.io",
+			SourceRepository: "base/node",
+			SourceTag:        "20",
+			DestRegistry:     "dest.io",
+			DestRepository:   "base/node",
+			DestTag:          "20",
+		},
+	}
+
+	results, err := executor.Execute(context.Background(), tasks)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+
+	// The base image runs first; its failure keeps the dependent from running
+	assert.Equal(t, "base/node", results[0].Task.SourceRepository)
+	assert.False(t, results[0].Success)
+	assert.False(t, results[0].Skipped)
+	assert.Equal(t, "apps/web", results[1].Task.SourceRepository)
+	assert.True(t, results[1].Skipped)
+	assert.Contains(t, results[1].SkipReason, "base/node")
+}
+
 func TestExecute_StopOnError(t *testing.T) {
 	mockLogger := new(MockLogger)
 	mockLogger.On("WithFields", mock.Anything).Return(mockLogger).Maybe()